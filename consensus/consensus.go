@@ -35,12 +35,36 @@ type Consenter interface {
 	ExecutionConsumer
 }
 
+// MetricsReporter is implemented by the consensus plugins which expose
+// metrics about their operation, such as their current timeouts
+type MetricsReporter interface {
+	GetMetrics() interface{} // May be called from any go routine
+}
+
+// EvidenceReporter is implemented by the consensus plugins which detect
+// misbehaving validators, and keep the evidence of it
+type EvidenceReporter interface {
+	GetEvidence() []*pb.Evidence // May be called from any go routine
+}
+
+// ConfigReloader is implemented by the consensus plugins which can apply
+// changes of their configuration without restarting
+type ConfigReloader interface {
+	ReloadConfig() (*pb.ConfigReload, error) // May be called from any go routine
+}
+
 // Inquirer is used to retrieve info about the validating network
 type Inquirer interface {
 	GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error)
 	GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err error)
 }
 
+// HealthInquirer is implemented by the stacks which probe the liveness of
+// the peers they chat with, for plugins to react to dead validators
+type HealthInquirer interface {
+	GetPeersHealth() ([]*pb.PeerHealth, error) // May be called from any go routine
+}
+
 // Communicator is used to send messages to other validators
 type Communicator interface {
 	Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error
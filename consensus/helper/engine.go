@@ -26,6 +26,8 @@ import (
 	"github.com/hyperledger/fabric/consensus/controller"
 	"github.com/hyperledger/fabric/consensus/util"
 	"github.com/hyperledger/fabric/core/chaincode"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/tracing"
 	pb "github.com/hyperledger/fabric/protos"
 	"golang.org/x/net/context"
 )
@@ -75,8 +77,14 @@ func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction
 			return response
 		}
 
+		span := tracing.StartChildSpan("consensus", tx.TraceContext)
+		span.SetTag("txid", tx.Uuid)
+		consensusSpans.Add(tx.Uuid, span)
+
 		// Pass the message to the consenter (eg. PBFT) NOTE: Make sure engine has been initialized
 		if eng.consenter == nil {
+			consensusSpans.Finish(tx.Uuid, fmt.Errorf("Engine not initialized"))
+			rejectTx(tx, fmt.Errorf("Engine not initialized"))
 			return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("Engine not initialized")}
 		}
 		// TODO, do we want to put these requests into a queue? This will block until
@@ -84,12 +92,25 @@ func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction
 		// natural feedback to the REST API to determine how long it takes to queue messages
 		err := eng.consenter.RecvMsg(msg, eng.peerEndpoint.ID)
 		if err != nil {
+			consensusSpans.Finish(tx.Uuid, err)
+			rejectTx(tx, err)
 			response = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
 		}
 	}
 	return response
 }
 
+// rejectTx records that the consensus plugin did not accept tx, for its
+// status and its rejection event to tell why
+func rejectTx(tx *pb.Transaction, err error) {
+	lgr, lerr := ledger.GetLedger()
+	if lerr != nil {
+		logger.Errorf("Failed to get the ledger to reject transaction %s: %s", tx.Uuid, lerr)
+		return
+	}
+	lgr.TxRejected(tx, pb.RejectionPhase_CONSENSUS, pb.RejectionReason_CONSENSUS_REJECTED, err)
+}
+
 func (eng *EngineImpl) setConsenter(consenter consensus.Consenter) *EngineImpl {
 	eng.consenter = consenter
 	return eng
@@ -108,6 +129,48 @@ func getEngineImpl() *EngineImpl {
 	return engine
 }
 
+// GetConsensusMetrics returns the metrics exposed by the consensus plugin of
+// the peer
+func GetConsensusMetrics() (interface{}, error) {
+	eng := getEngineImpl()
+	if eng == nil || eng.consenter == nil {
+		return nil, fmt.Errorf("Consensus is not running on this peer")
+	}
+	reporter, ok := eng.consenter.(consensus.MetricsReporter)
+	if !ok {
+		return nil, fmt.Errorf("Consensus plugin %T does not expose metrics", eng.consenter)
+	}
+	return reporter.GetMetrics(), nil
+}
+
+// GetConsensusEvidence returns the evidence of misbehaving validators
+// collected by the consensus plugin of the peer
+func GetConsensusEvidence() ([]*pb.Evidence, error) {
+	eng := getEngineImpl()
+	if eng == nil || eng.consenter == nil {
+		return nil, fmt.Errorf("Consensus is not running on this peer")
+	}
+	reporter, ok := eng.consenter.(consensus.EvidenceReporter)
+	if !ok {
+		return nil, fmt.Errorf("Consensus plugin %T does not collect evidence", eng.consenter)
+	}
+	return reporter.GetEvidence(), nil
+}
+
+// ReloadConsensusConfig has the consensus plugin of the peer read its
+// configuration again, and apply the changes it can while running
+func ReloadConsensusConfig() (*pb.ConfigReload, error) {
+	eng := getEngineImpl()
+	if eng == nil || eng.consenter == nil {
+		return nil, fmt.Errorf("Consensus is not running on this peer")
+	}
+	reloader, ok := eng.consenter.(consensus.ConfigReloader)
+	if !ok {
+		return nil, fmt.Errorf("Consensus plugin %T does not reload its configuration", eng.consenter)
+	}
+	return reloader.ReloadConfig()
+}
+
 // GetEngine returns initialized peer.Engine
 func GetEngine(coord peer.MessageHandlerCoordinator) (peer.Engine, error) {
 	var err error
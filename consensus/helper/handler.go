@@ -95,6 +95,11 @@ func (handler *ConsensusHandler) HandleMessage(msg *pb.Message) error {
 		}
 	}
 
+	if msg.Type == pb.Message_EXECUTION_RESULT {
+		senderPE, _ := handler.To()
+		return getEngineImpl().helper.HandleExecutionResult(msg, senderPE.ID)
+	}
+
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debugf("Did not handle message of type %s, passing on to next MessageHandler", msg.Type)
 	}
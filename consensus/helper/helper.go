@@ -28,11 +28,18 @@ import (
 	"github.com/hyperledger/fabric/consensus/helper/persist"
 	"github.com/hyperledger/fabric/core/chaincode"
 	crypto "github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/flogging"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/tracing"
+	"github.com/hyperledger/fabric/events/producer"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
+// consensusSpans are the spans of the traced transactions this validator
+// received, from their reception to their execution
+var consensusSpans = tracing.NewPending(10000)
+
 // Helper contains the reference to the peer's MessageHandlerCoordinator
 type Helper struct {
 	consenter    consensus.Consenter
@@ -42,9 +49,44 @@ type Helper struct {
 	secHelper    crypto.Peer
 	curBatch     []*pb.Transaction       // TODO, remove after issue 579
 	curBatchErrs []*pb.TransactionResult // TODO, remove after issue 579
+	ledgerBatch  map[string]*ledgerBatch // parts of the batch for the other ledgers, by ledger ID
 	persist.Helper
 
-	executor consensus.Executor
+	executor       consensus.Executor
+	resultChecker  *resultChecker // nil unless the execution results are cross-checked
+	canonicalOrder bool           // whether the transactions are sorted before execution
+}
+
+// ledgerBatch is the part of the current transaction batch executed against
+// a ledger other than the default one. Consensus orders the transactions of
+// every ledger, each committing its own block for the batch.
+type ledgerBatch struct {
+	ledger *ledger.Ledger
+	txs    []*pb.Transaction
+	errs   []*pb.TransactionResult
+}
+
+// ledgerHeadsChaincodeID is the namespace of the state of the default ledger
+// holding the hash of the head block of every other ledger, by ledger ID, so
+// that the state hash of the default ledger, and so the checkpoints of
+// consensus, cover the other ledgers
+const ledgerHeadsChaincodeID = "__ledgers"
+
+// recordLedgerHeads sets the hashes of the head blocks of the other ledgers,
+// by ledger ID, in the state of the default ledger for the current batch
+func recordLedgerHeads(defaultLedger *ledger.Ledger, heads map[string][]byte) error {
+	if len(heads) == 0 {
+		return nil
+	}
+	defaultLedger.TxBegin(ledgerHeadsChaincodeID)
+	for ledgerID, blockHash := range heads {
+		if err := defaultLedger.SetState(ledgerHeadsChaincodeID, ledgerID, blockHash); err != nil {
+			defaultLedger.TxFinished(ledgerHeadsChaincodeID, false)
+			return fmt.Errorf("Failed to record the head of the ledger %s: %v", ledgerID, err)
+		}
+	}
+	defaultLedger.TxFinished(ledgerHeadsChaincodeID, true)
+	return nil
 }
 
 // NewHelper constructs the consensus helper object
@@ -54,6 +96,12 @@ func NewHelper(mhc peer.MessageHandlerCoordinator) *Helper {
 		secOn:       viper.GetBool("security.enabled"),
 		secHelper:   mhc.GetSecHelper(),
 		valid:       true, // Assume our state is consistent until we are told otherwise, TODO: revisit
+
+		canonicalOrder: viper.GetBool("peer.validator.consensus.canonicalorder"),
+	}
+
+	if viper.GetBool("peer.validator.consensus.resultcheck.enabled") {
+		h.resultChecker = newResultChecker(h, viper.GetInt("peer.validator.consensus.resultcheck.history"))
 	}
 
 	h.executor = executor.NewImpl(h, h, mhc)
@@ -105,6 +153,20 @@ func (h *Helper) GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err
 	return
 }
 
+// GetPeersHealth returns the liveness of the peers chatting with this
+// validator, as probed by the peer
+func (h *Helper) GetPeersHealth() ([]*pb.PeerHealth, error) {
+	reporter, ok := h.coordinator.(peer.HealthReporter)
+	if !ok {
+		return nil, fmt.Errorf("Peer %T does not probe the liveness of its peers", h.coordinator)
+	}
+	health, err := reporter.GetPeersHealth()
+	if err != nil {
+		return nil, err
+	}
+	return health.Peers, nil
+}
+
 // Broadcast sends a message to all validating peers
 func (h *Helper) Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error {
 	errors := h.coordinator.Broadcast(msg, peerType)
@@ -167,12 +229,34 @@ func (h *Helper) BeginTxBatch(id interface{}) error {
 	}
 	h.curBatch = nil     // TODO, remove after issue 579
 	h.curBatchErrs = nil // TODO, remove after issue 579
+	h.ledgerBatch = nil
+	return nil
+}
+
+// beginLedgerBatch begins the batch id on the ledger ledgerID, other than the
+// default one, the first time the batch executes a transaction for it
+func (h *Helper) beginLedgerBatch(id interface{}, ledgerID string) error {
+	if _, ok := h.ledgerBatch[ledgerID]; ok {
+		return nil
+	}
+	lgr, err := ledger.GetLedgerByID(ledgerID)
+	if err != nil {
+		return fmt.Errorf("Failed to get the ledger %s: %v", ledgerID, err)
+	}
+	if err := lgr.BeginTxBatch(id); err != nil {
+		return fmt.Errorf("Failed to begin transaction with the ledger %s: %v", ledgerID, err)
+	}
+	if h.ledgerBatch == nil {
+		h.ledgerBatch = make(map[string]*ledgerBatch)
+	}
+	h.ledgerBatch[ledgerID] = &ledgerBatch{ledger: lgr}
 	return nil
 }
 
 // ExecTxs executes all the transactions listed in the txs array
-// one-by-one. If all the executions are successful, it returns
-// the candidate global state hash, and nil error array.
+// one-by-one, each against the ledger of its ledger ID. If all the
+// executions are successful, it returns the candidate global state hash of
+// the default ledger, and nil error array.
 func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error) {
 	// TODO id is currently ignored, fix once the underlying implementation accepts id
 
@@ -180,22 +264,45 @@ func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error)
 	// cxt := context.WithValue(context.Background(), "security", h.coordinator.GetSecHelper())
 	// TODO return directly once underlying implementation no longer returns []error
 
-	res, ccevents, txerrs, err := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
-	h.curBatch = append(h.curBatch, txs...) // TODO, remove after issue 579
-
-	//copy errs to results
-	txresults := make([]*pb.TransactionResult, len(txerrs))
+	if h.canonicalOrder {
+		txs = canonicalOrder(txs)
+	}
+	for _, tx := range txs {
+		consensusSpans.Finish(tx.Uuid, nil)
+		// the transactions for an unknown ledger are rejected by their
+		// execution, and recorded in the block of the default ledger
+		if tx.LedgerID != "" && ledger.CheckLedgerID(tx.LedgerID) == nil {
+			if err := h.beginLedgerBatch(id, tx.LedgerID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// the transactions log the number of the block they are executed for
+	ctxt := context.Background()
+	if ledger, err := ledger.GetLedger(); err == nil {
+		ctxt = flogging.NewContext(ctxt, "block", ledger.GetBlockchainSize())
+	}
+	res, ccevents, txerrs, err := chaincode.ExecuteTransactions(ctxt, chaincode.DefaultChain, txs)
 
 	//process errors for each transaction
 	for i, e := range txerrs {
 		//NOTE- it'll be nice if we can have error values. For now success == 0, error == 1
+		var txresult *pb.TransactionResult
 		if txerrs[i] != nil {
-			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, Error: e.Error(), ErrorCode: 1, ChaincodeEvent: ccevents[i]}
+			phase, reason := chaincode.RejectionOf(e)
+			txresult = &pb.TransactionResult{Uuid: txs[i].Uuid, Error: e.Error(), ErrorCode: 1, ChaincodeEvent: ccevents[i], Phase: phase, Reason: reason}
 		} else {
-			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, ChaincodeEvent: ccevents[i]}
+			txresult = &pb.TransactionResult{Uuid: txs[i].Uuid, ChaincodeEvent: ccevents[i]}
+		}
+		batch, ok := h.ledgerBatch[txs[i].LedgerID]
+		if !ok {
+			h.curBatch = append(h.curBatch, txs[i])           // TODO, remove after issue 579
+			h.curBatchErrs = append(h.curBatchErrs, txresult) // TODO, remove after issue 579
+			continue
 		}
+		batch.txs = append(batch.txs, txs[i])
+		batch.errs = append(batch.errs, txresult)
 	}
-	h.curBatchErrs = append(h.curBatchErrs, txresults...) // TODO, remove after issue 579
 
 	return res, err
 }
@@ -204,15 +311,44 @@ func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error)
 // to be committed. This function returns successfully iff the
 // transactions details and state changes (that may have happened
 // during execution of this transaction-batch) have been committed to
-// permanent storage.
+// permanent storage. The transactions for other ledgers than the default
+// one are committed first, in a block of their ledger; the block of the
+// default ledger is returned, its state recording the new head block of the
+// other ledgers. The commit is not atomic across ledgers: if a ledger fails
+// to commit, the batch is rolled back on the ledgers not committed yet,
+// default ledger included, while those committed before keep their block.
 func (h *Helper) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, error) {
 	ledger, err := ledger.GetLedger()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get the ledger: %v", err)
 	}
+	heads, err := h.commitLedgerBatches(id, metadata)
+	if err == nil {
+		err = recordLedgerHeads(ledger, heads)
+	}
+	if err != nil {
+		if rbErr := ledger.RollbackTxBatch(id); rbErr != nil {
+			logger.Errorf("Failed to rollback transaction with the ledger: %v", rbErr)
+		}
+		for _, tx := range h.curBatch {
+			ledger.TxRejected(tx, pb.RejectionPhase_COMMIT, pb.RejectionReason_COMMIT_FAILED, err)
+		}
+		h.curBatch = nil     // TODO, remove after issue 579
+		h.curBatchErrs = nil // TODO, remove after issue 579
+		return nil, err
+	}
+	// the hashes of the changes made by the transactions are only available until the commit
+	_, txDeltaHashes, err := ledger.GetTempStateHashWithTxDeltaStateHashes()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the state hash: %v", err)
+	}
 	// TODO fix this one the ledger has been fixed to implement
 	if err := ledger.CommitTxBatch(id, h.curBatch, h.curBatchErrs, metadata); err != nil {
-		return nil, fmt.Errorf("Failed to commit transaction to the ledger: %v", err)
+		err = fmt.Errorf("Failed to commit transaction to the ledger: %v", err)
+		for _, tx := range h.curBatch {
+			ledger.TxRejected(tx, pb.RejectionPhase_COMMIT, pb.RejectionReason_COMMIT_FAILED, err)
+		}
+		return nil, err
 	}
 
 	size := ledger.GetBlockchainSize()
@@ -226,14 +362,54 @@ func (h *Helper) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, erro
 		return nil, fmt.Errorf("Failed to get the block at the head of the chain: %v", err)
 	}
 
-	logger.Debugf("Committed block with %d transactions, intended to include %d", len(block.Transactions), len(h.curBatch))
+	flogging.With(logger, "block", size-1).Debugf("Committed block with %d transactions, intended to include %d", len(block.Transactions), len(h.curBatch))
+
+	if h.resultChecker != nil {
+		h.resultChecker.committed(newBlockExecutionResult(block, size-1, txDeltaHashes))
+	}
 
 	return block, nil
 }
 
+// commitLedgerBatches commits the parts of the current batch for the ledgers
+// other than the default one, and returns the hashes of their new head
+// blocks. Once a ledger fails to commit, rejecting its transactions, the
+// remaining ledgers are rolled back.
+func (h *Helper) commitLedgerBatches(id interface{}, metadata []byte) (map[string][]byte, error) {
+	defer func() { h.ledgerBatch = nil }()
+	heads := make(map[string][]byte)
+	var failed error
+	for ledgerID, batch := range h.ledgerBatch {
+		if failed != nil {
+			batch.ledger.RollbackTxBatch(id)
+			continue
+		}
+		if err := batch.ledger.CommitTxBatch(id, batch.txs, batch.errs, metadata); err != nil {
+			failed = fmt.Errorf("Failed to commit transaction to the ledger %s: %v", ledgerID, err)
+			for _, tx := range batch.txs {
+				batch.ledger.TxRejected(tx, pb.RejectionPhase_COMMIT, pb.RejectionReason_COMMIT_FAILED, failed)
+			}
+			continue
+		}
+		info, err := batch.ledger.GetBlockchainInfo()
+		if err != nil {
+			failed = fmt.Errorf("Failed to get the head of the ledger %s: %v", ledgerID, err)
+			continue
+		}
+		heads[ledgerID] = info.CurrentBlockHash
+	}
+	return heads, failed
+}
+
 // RollbackTxBatch discards all the state changes that may have taken
-// place during the execution of current transaction-batch
+// place during the execution of current transaction-batch, on every ledger
 func (h *Helper) RollbackTxBatch(id interface{}) error {
+	for ledgerID, batch := range h.ledgerBatch {
+		if err := batch.ledger.RollbackTxBatch(id); err != nil {
+			logger.Errorf("Failed to rollback transaction with the ledger %s: %v", ledgerID, err)
+		}
+	}
+	h.ledgerBatch = nil
 	ledger, err := ledger.GetLedger()
 	if err != nil {
 		return fmt.Errorf("Failed to get the ledger: %v", err)
@@ -249,12 +425,24 @@ func (h *Helper) RollbackTxBatch(id interface{}) error {
 // PreviewCommitTxBatch retrieves a preview of the block info blob (as
 // returned by GetBlockchainInfoBlob) that would describe the
 // blockchain if CommitTxBatch were invoked.  The blockinfo will
-// change if additional ExecTXs calls are invoked.
+// change if additional ExecTXs calls are invoked. The previewed head blocks
+// of the other ledgers are recorded in the state, as CommitTxBatch does.
 func (h *Helper) PreviewCommitTxBatch(id interface{}, metadata []byte) ([]byte, error) {
 	ledger, err := ledger.GetLedger()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get the ledger: %v", err)
 	}
+	heads := make(map[string][]byte)
+	for ledgerID, batch := range h.ledgerBatch {
+		info, err := batch.ledger.GetTXBatchPreviewBlockInfo(id, batch.txs, metadata)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to preview commit to the ledger %s: %v", ledgerID, err)
+		}
+		heads[ledgerID] = info.CurrentBlockHash
+	}
+	if err := recordLedgerHeads(ledger, heads); err != nil {
+		return nil, err
+	}
 	// TODO fix this once the underlying API is fixed
 	blockInfo, err := ledger.GetTXBatchPreviewBlockInfo(id, h.curBatch, metadata)
 	if err != nil {
@@ -264,6 +452,38 @@ func (h *Helper) PreviewCommitTxBatch(id interface{}, metadata []byte) ([]byte,
 	return rawInfo, nil
 }
 
+// HandleExecutionResult handles the execution result of a block sent by another validating peer
+func (h *Helper) HandleExecutionResult(msg *pb.Message, sender *pb.PeerID) error {
+	if h.resultChecker == nil {
+		logger.Debugf("Ignoring execution result from %v, the execution results are not checked", sender)
+		return nil
+	}
+	result := &pb.BlockExecutionResult{}
+	if err := proto.Unmarshal(msg.Payload, result); err != nil {
+		return fmt.Errorf("Error unmarshalling execution result from %v: %s", sender, err)
+	}
+	h.resultChecker.received(sender, result)
+	return nil
+}
+
+func (h *Helper) getWriteSet(txUUID string) ([]byte, error) {
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the ledger: %v", err)
+	}
+	rwSet, err := ledger.GetTxReadWriteSet(txUUID)
+	if err != nil {
+		return nil, err
+	}
+	return rwSet.Writes.Marshal(), nil
+}
+
+func (h *Helper) reportDivergence(divergence *pb.Divergence) {
+	if err := producer.Send(producer.CreateDivergenceEvent(divergence)); err != nil {
+		logger.Errorf("Failed to send the divergence event of block %d: %v", divergence.BlockNumber, err)
+	}
+}
+
 // GetBlock returns a block from the chain
 func (h *Helper) GetBlock(blockNumber uint64) (block *pb.Block, err error) {
 	ledger, err := ledger.GetLedger()
@@ -16,8 +16,182 @@ limitations under the License.
 
 package helper
 
-import "testing"
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/chaincode"
+	"github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
 
 func TestHelper(t *testing.T) {
 	t.Skip("Helper functions already tested in other consensus components")
 }
+
+func newLedgerInvokeTransaction(t *testing.T, uuid string, ledgerID string) *pb.Transaction {
+	spec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		ChaincodeID: &pb.ChaincodeID{Name: "mycc"},
+		CtorMsg:     &pb.ChaincodeInput{Function: "invoke"},
+		LedgerID:    ledgerID,
+	}}
+	tx, err := pb.NewChaincodeExecute(spec, uuid, pb.Transaction_CHAINCODE_INVOKE)
+	if err != nil {
+		t.Fatalf("Error creating transaction %s: %s", uuid, err)
+	}
+	return tx
+}
+
+func TestCommitTxBatchLedgers(t *testing.T) {
+	config.SetupTestConfig("./../../peer")
+	dir, err := ioutil.TempDir("", "helper")
+	if err != nil {
+		t.Fatalf("Error creating the ledger directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("peer.fileSystemPath", dir)
+	viper.Set("ledger.ledgers", []string{"a", "b"})
+	defer viper.Set("ledger.ledgers", nil)
+	defaultLedger := ledger.InitTestLedger(t)
+	getPeerEndpoint := func() (*pb.PeerEndpoint, error) {
+		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp0"}, Address: "0.0.0.0:30303"}, nil
+	}
+	chaincode.NewChaincodeSupport(chaincode.DefaultChain, getPeerEndpoint, false, time.Second, nil)
+
+	ledgers := map[string]*ledger.Ledger{"": defaultLedger}
+	sizes := make(map[string]uint64)
+	for _, ledgerID := range []string{"a", "b"} {
+		if ledgers[ledgerID], err = ledger.GetLedgerByID(ledgerID); err != nil {
+			t.Fatalf("Error getting ledger %s: %s", ledgerID, err)
+		}
+	}
+	for ledgerID, lgr := range ledgers {
+		sizes[ledgerID] = lgr.GetBlockchainSize()
+	}
+
+	// the transactions are committed to the blocks of their ledgers, the
+	// block of the default ledger being returned. A transaction for an
+	// invalid ledger is rejected, in the block of the default ledger.
+	h := &Helper{}
+	txs := []*pb.Transaction{
+		newLedgerInvokeTransaction(t, "a-1", "a"),
+		newLedgerInvokeTransaction(t, "default-1", ""),
+		newLedgerInvokeTransaction(t, "b-1", "b"),
+		newLedgerInvokeTransaction(t, "invalid-1", "../b"),
+		newLedgerInvokeTransaction(t, "a-2", "a"),
+	}
+	if err = h.BeginTxBatch(1); err != nil {
+		t.Fatalf("Error beginning batch: %s", err)
+	}
+	if _, err = h.ExecTxs(1, txs); err != nil {
+		t.Fatalf("Error executing batch: %s", err)
+	}
+	metadata := []byte("metadata")
+	preview, err := h.PreviewCommitTxBatch(1, metadata)
+	if err != nil {
+		t.Fatalf("Error previewing batch: %s", err)
+	}
+	block, err := h.CommitTxBatch(1, metadata)
+	if err != nil {
+		t.Fatalf("Error committing batch: %s", err)
+	}
+	if !bytes.Equal(preview, h.GetBlockchainInfoBlob()) {
+		t.Error("Expected the committed blockchain info to match the preview")
+	}
+	if len(block.Transactions) != 2 || block.Transactions[0].Uuid != "default-1" || block.Transactions[1].Uuid != "invalid-1" {
+		t.Fatalf("Expected the block of the default ledger to hold default-1 and invalid-1, got %v", block.Transactions)
+	}
+	if result := block.NonHashData.TransactionResults[1]; result.ErrorCode == 0 || result.Reason != pb.RejectionReason_INVALID_TRANSACTION {
+		t.Errorf("Expected invalid-1 to be rejected as invalid, got %v", result)
+	}
+	expected := map[string][]string{"": {"default-1", "invalid-1"}, "a": {"a-1", "a-2"}, "b": {"b-1"}}
+	for ledgerID, uuids := range expected {
+		lgr := ledgers[ledgerID]
+		if size := lgr.GetBlockchainSize(); size != sizes[ledgerID]+1 {
+			t.Fatalf("Expected ledger '%s' to commit a block, got size %d from %d", ledgerID, size, sizes[ledgerID])
+		}
+		sizes[ledgerID]++
+		block, err := lgr.GetBlockByNumber(sizes[ledgerID] - 1)
+		if err != nil {
+			t.Fatalf("Error getting the block of ledger '%s': %s", ledgerID, err)
+		}
+		if !bytes.Equal(block.ConsensusMetadata, metadata) {
+			t.Errorf("Expected the block of ledger '%s' to carry the consensus metadata, got %v", ledgerID, block.ConsensusMetadata)
+		}
+		if len(block.Transactions) != len(uuids) || len(block.NonHashData.TransactionResults) != len(uuids) {
+			t.Fatalf("Expected the block of ledger '%s' to hold %v, got %v", ledgerID, uuids, block.Transactions)
+		}
+		for i, uuid := range uuids {
+			if block.Transactions[i].Uuid != uuid || block.NonHashData.TransactionResults[i].Uuid != uuid {
+				t.Errorf("Expected transaction %d of ledger '%s' to be %s, got %s", i, ledgerID, uuid, block.Transactions[i].Uuid)
+			}
+		}
+		if ledgerID == "" {
+			continue
+		}
+		// the state of the default ledger records the head of the others
+		blockHash, _ := block.GetHash()
+		if head, _ := defaultLedger.GetState(ledgerHeadsChaincodeID, ledgerID, true); !bytes.Equal(head, blockHash) {
+			t.Errorf("Expected the default ledger to record the head %x of ledger '%s', got %x", blockHash, ledgerID, head)
+		}
+	}
+
+	// a rolled back batch commits nothing, on no ledger
+	if err = h.BeginTxBatch(2); err != nil {
+		t.Fatalf("Error beginning batch: %s", err)
+	}
+	if _, err = h.ExecTxs(2, []*pb.Transaction{newLedgerInvokeTransaction(t, "b-2", "b")}); err != nil {
+		t.Fatalf("Error executing batch: %s", err)
+	}
+	if err = h.RollbackTxBatch(2); err != nil {
+		t.Fatalf("Error rolling back batch: %s", err)
+	}
+	for ledgerID, lgr := range ledgers {
+		if size := lgr.GetBlockchainSize(); size != sizes[ledgerID] {
+			t.Errorf("Expected ledger '%s' not to commit the rolled back batch, got size %d from %d", ledgerID, size, sizes[ledgerID])
+		}
+	}
+	if err = h.BeginTxBatch(3); err != nil {
+		t.Fatalf("Error beginning batch after a rollback: %s", err)
+	}
+	if _, err = h.ExecTxs(3, []*pb.Transaction{newLedgerInvokeTransaction(t, "b-3", "b")}); err != nil {
+		t.Fatalf("Error executing batch after a rollback: %s", err)
+	}
+	if _, err = h.CommitTxBatch(3, metadata); err != nil {
+		t.Fatalf("Error committing batch after a rollback: %s", err)
+	}
+
+	// a ledger failing to commit rolls the batch back on the default ledger
+	for ledgerID, lgr := range ledgers {
+		sizes[ledgerID] = lgr.GetBlockchainSize()
+	}
+	if err = h.BeginTxBatch(4); err != nil {
+		t.Fatalf("Error beginning batch: %s", err)
+	}
+	if _, err = h.ExecTxs(4, []*pb.Transaction{newLedgerInvokeTransaction(t, "default-4", ""), newLedgerInvokeTransaction(t, "b-4", "b")}); err != nil {
+		t.Fatalf("Error executing batch: %s", err)
+	}
+	if err = ledgers["b"].RollbackTxBatch(4); err != nil {
+		t.Fatalf("Error rolling back batch on ledger b: %s", err)
+	}
+	if _, err = h.CommitTxBatch(4, metadata); err == nil {
+		t.Fatal("Expected the batch to fail to commit on ledger b")
+	}
+	for ledgerID, lgr := range ledgers {
+		if size := lgr.GetBlockchainSize(); size != sizes[ledgerID] {
+			t.Errorf("Expected ledger '%s' not to commit the failed batch, got size %d from %d", ledgerID, size, sizes[ledgerID])
+		}
+	}
+	if err = h.BeginTxBatch(5); err != nil {
+		t.Fatalf("Error beginning batch after a failed commit: %s", err)
+	}
+	if err = h.RollbackTxBatch(5); err != nil {
+		t.Fatalf("Error rolling back batch: %s", err)
+	}
+}
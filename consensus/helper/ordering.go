@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// The consensus plugins agree on the transactions of a block, but not all of
+// them agree on their order within the block: a primary batching requests may
+// send them in any order. When the canonical order is enabled, the
+// transactions passed for execution are sorted by the hash of their UUID, so
+// that every validating peer executes them in the same order, whatever order
+// they were batched in.
+
+type byUUIDHash struct {
+	txs    []*pb.Transaction
+	hashes [][]byte
+}
+
+func (s byUUIDHash) Len() int { return len(s.txs) }
+
+func (s byUUIDHash) Less(i, j int) bool { return bytes.Compare(s.hashes[i], s.hashes[j]) < 0 }
+
+func (s byUUIDHash) Swap(i, j int) {
+	s.txs[i], s.txs[j] = s.txs[j], s.txs[i]
+	s.hashes[i], s.hashes[j] = s.hashes[j], s.hashes[i]
+}
+
+// canonicalOrder returns a copy of txs sorted by the hash of their UUID,
+// transactions with the same UUID keep their relative order
+func canonicalOrder(txs []*pb.Transaction) []*pb.Transaction {
+	sorted := byUUIDHash{
+		txs:    make([]*pb.Transaction, len(txs)),
+		hashes: make([][]byte, len(txs)),
+	}
+	copy(sorted.txs, txs)
+	for i, tx := range txs {
+		sorted.hashes[i] = util.ComputeCryptoHash([]byte(tx.Uuid))
+	}
+	sort.Stable(sorted)
+	return sorted.txs
+}
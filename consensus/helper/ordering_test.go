@@ -0,0 +1,56 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestCanonicalOrder(t *testing.T) {
+	var txs, reversed []*pb.Transaction
+	for i := 0; i < 10; i++ {
+		txs = append(txs, &pb.Transaction{Uuid: fmt.Sprintf("tx%d", i)})
+	}
+	for i := len(txs) - 1; i >= 0; i-- {
+		reversed = append(reversed, txs[i])
+	}
+
+	sorted := canonicalOrder(txs)
+	sortedReversed := canonicalOrder(reversed)
+	for i := range sorted {
+		if sorted[i] != sortedReversed[i] {
+			t.Fatalf("Expected the same order whatever the batched order, got %s and %s at %d", sorted[i].Uuid, sortedReversed[i].Uuid, i)
+		}
+	}
+	if txs[0].Uuid != "tx0" || reversed[0].Uuid != "tx9" {
+		t.Fatalf("Expected the transactions passed to be left in their order")
+	}
+
+	// transactions with the same UUID keep their order
+	first := &pb.Transaction{Uuid: "dup", Payload: []byte("first")}
+	second := &pb.Transaction{Uuid: "dup", Payload: []byte("second")}
+	index := make(map[*pb.Transaction]int)
+	for i, tx := range canonicalOrder([]*pb.Transaction{first, txs[0], second}) {
+		index[tx] = i
+	}
+	if index[first] > index[second] {
+		t.Fatalf("Expected the first transaction with the UUID to stay before the second")
+	}
+}
@@ -42,6 +42,13 @@ func (h *Helper) ReadState(key string) ([]byte, error) {
 	return db.Get(db.PersistCF, []byte("consensus."+key))
 }
 
+// CompactState reclaims the space of the deleted consensus keys
+func (h *Helper) CompactState() error {
+	db := db.GetDBHandle()
+	// '/' follows '.', the end of the consensus keys
+	return db.CompactRange(db.PersistCF, []byte("consensus."), []byte("consensus/"))
+}
+
 // ReadStateSet retrieves all key,value pairs where the key starts with prefix
 func (h *Helper) ReadStateSet(prefix string) (map[string][]byte, error) {
 	db := db.GetDBHandle()
@@ -51,10 +58,10 @@ func (h *Helper) ReadStateSet(prefix string) (map[string][]byte, error) {
 	it := db.GetIterator(db.PersistCF)
 	defer it.Close()
 	for it.Seek(prefixRaw); it.ValidForPrefix(prefixRaw); it.Next() {
-		key := string(it.Key().Data())
+		key := string(it.Key())
 		key = key[len("consensus."):]
 		// copy data from the slice!
-		ret[key] = append([]byte(nil), it.Value().Data()...)
+		ret[key] = append([]byte(nil), it.Value()...)
 	}
 	return ret, nil
 }
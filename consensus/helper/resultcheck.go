@@ -0,0 +1,240 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// DefaultResultCheckHistory value of 100
+const DefaultResultCheckHistory int = 100
+
+// resultCheckerStack is what the resultChecker needs to exchange the results
+// with the other validating peers and to report the divergences
+type resultCheckerStack interface {
+	Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error
+	Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error
+	// getWriteSet returns the serialized state changes made by a committed transaction
+	getWriteSet(txUUID string) ([]byte, error)
+	// reportDivergence sends a divergence event
+	reportDivergence(divergence *pb.Divergence)
+}
+
+// remoteResult is a result received from another validating peer
+type remoteResult struct {
+	sender *pb.PeerID
+	result *pb.BlockExecutionResult
+}
+
+// resultChecker sends the execution results of the blocks committed by this
+// peer to the other validating peers and compares them with the results sent
+// by the other peers. When the results of a block differ, the peers send each
+// other the write sets of the differing transactions, from which a divergence
+// event is built.
+type resultChecker struct {
+	lock    sync.Mutex
+	stack   resultCheckerStack
+	history uint64
+
+	local   map[uint64]*pb.BlockExecutionResult
+	pending map[uint64][]*remoteResult // received before the block was committed by this peer
+	last    uint64                     // number of the last block committed by this peer
+}
+
+func newResultChecker(stack resultCheckerStack, history int) *resultChecker {
+	if history <= 0 {
+		logger.Errorf("peer.validator.consensus.resultcheck.history is set to %d, but this must be a positive integer, defaulting to %d", history, DefaultResultCheckHistory)
+		history = DefaultResultCheckHistory
+	}
+	return &resultChecker{
+		stack:   stack,
+		history: uint64(history),
+		local:   make(map[uint64]*pb.BlockExecutionResult),
+		pending: make(map[uint64][]*remoteResult),
+	}
+}
+
+// newBlockExecutionResult builds the execution result of a committed block
+// from the hashes of the state changes made by its successful transactions
+func newBlockExecutionResult(block *pb.Block, blockNumber uint64, txDeltaHashes map[string][]byte) *pb.BlockExecutionResult {
+	result := &pb.BlockExecutionResult{BlockNumber: blockNumber, StateHash: block.StateHash}
+	for _, tx := range block.Transactions {
+		deltaHash, ok := txDeltaHashes[tx.Uuid]
+		result.TxResults = append(result.TxResults, &pb.TxExecutionResult{Uuid: tx.Uuid, Failed: !ok, WriteSetHash: deltaHash})
+	}
+	return result
+}
+
+// committed records the execution result of a block committed by this peer,
+// sends it to the other validating peers and compares it with the results
+// already received for the block
+func (rc *resultChecker) committed(result *pb.BlockExecutionResult) {
+	rc.lock.Lock()
+	rc.local[result.BlockNumber] = result
+	rc.last = result.BlockNumber
+	if result.BlockNumber >= rc.history {
+		for blockNumber := range rc.local {
+			if blockNumber <= result.BlockNumber-rc.history {
+				delete(rc.local, blockNumber)
+			}
+		}
+	}
+	for blockNumber := range rc.pending {
+		if blockNumber < result.BlockNumber {
+			delete(rc.pending, blockNumber)
+		}
+	}
+	received := rc.pending[result.BlockNumber]
+	delete(rc.pending, result.BlockNumber)
+	rc.lock.Unlock()
+
+	if msg, err := newExecutionResultMessage(result); err != nil {
+		logger.Errorf("Failed to marshal the execution result of block %d: %v", result.BlockNumber, err)
+	} else if err := rc.stack.Broadcast(msg, pb.PeerEndpoint_VALIDATOR); err != nil {
+		logger.Warningf("Failed to broadcast the execution result of block %d: %v", result.BlockNumber, err)
+	}
+	for _, remote := range received {
+		rc.compare(result, remote)
+	}
+}
+
+// received handles an execution result sent by another validating peer
+func (rc *resultChecker) received(sender *pb.PeerID, result *pb.BlockExecutionResult) {
+	rc.lock.Lock()
+	local, ok := rc.local[result.BlockNumber]
+	if !ok {
+		// keep the results of the blocks this peer is about to commit
+		if result.BlockNumber > rc.last && result.BlockNumber <= rc.last+rc.history && !result.Response {
+			rc.pending[result.BlockNumber] = append(rc.pending[result.BlockNumber], &remoteResult{sender, result})
+		} else {
+			logger.Debugf("Ignoring execution result of block %d from %v, which is not a recent block", result.BlockNumber, sender)
+		}
+	}
+	rc.lock.Unlock()
+	if ok {
+		rc.compare(local, &remoteResult{sender, result})
+	}
+}
+
+func (rc *resultChecker) compare(local *pb.BlockExecutionResult, remote *remoteResult) {
+	if remote.result.Response {
+		rc.reportDivergence(local, remote)
+		return
+	}
+	differing := diffTxResults(local, remote.result)
+	if len(differing) == 0 && bytes.Equal(local.StateHash, remote.result.StateHash) {
+		logger.Debugf("Execution result of block %d from %v matches", local.BlockNumber, remote.sender)
+		return
+	}
+	logger.Warningf("Execution result of block %d from %v differs in %d transaction(s), sending the write sets", local.BlockNumber, remote.sender, len(differing))
+
+	response := &pb.BlockExecutionResult{BlockNumber: local.BlockNumber, StateHash: local.StateHash, Response: true}
+	for _, txResult := range differing {
+		withWriteSet, err := rc.withWriteSet(txResult)
+		if err != nil {
+			logger.Errorf("Failed to get the write set of transaction %s: %v", txResult.Uuid, err)
+			continue
+		}
+		response.TxResults = append(response.TxResults, withWriteSet)
+	}
+	if msg, err := newExecutionResultMessage(response); err != nil {
+		logger.Errorf("Failed to marshal the write sets of block %d: %v", local.BlockNumber, err)
+	} else if err := rc.stack.Unicast(msg, remote.sender); err != nil {
+		logger.Warningf("Failed to send the write sets of block %d to %v: %v", local.BlockNumber, remote.sender, err)
+	}
+}
+
+// reportDivergence sends a divergence event for a response to a differing result
+func (rc *resultChecker) reportDivergence(local *pb.BlockExecutionResult, remote *remoteResult) {
+	divergence := &pb.Divergence{
+		BlockNumber:     local.BlockNumber,
+		PeerID:          remote.sender.Name,
+		LocalStateHash:  local.StateHash,
+		RemoteStateHash: remote.result.StateHash,
+	}
+	localTxResults := txResultsByUUID(local)
+	for _, remoteTxResult := range remote.result.TxResults {
+		txDivergence := &pb.TxDivergence{
+			Uuid:           remoteTxResult.Uuid,
+			RemoteFailed:   remoteTxResult.Failed,
+			RemoteWriteSet: remoteTxResult.WriteSet,
+		}
+		if localTxResult, ok := localTxResults[remoteTxResult.Uuid]; ok {
+			localWithWriteSet, err := rc.withWriteSet(localTxResult)
+			if err != nil {
+				logger.Errorf("Failed to get the write set of transaction %s: %v", localTxResult.Uuid, err)
+				continue
+			}
+			txDivergence.LocalFailed = localWithWriteSet.Failed
+			txDivergence.LocalWriteSet = localWithWriteSet.WriteSet
+		}
+		divergence.Transactions = append(divergence.Transactions, txDivergence)
+	}
+	logger.Errorf("Execution result of block %d differs from the result of %v, the chaincode of %d transaction(s) may not be deterministic",
+		local.BlockNumber, remote.sender, len(divergence.Transactions))
+	rc.stack.reportDivergence(divergence)
+}
+
+func (rc *resultChecker) withWriteSet(txResult *pb.TxExecutionResult) (*pb.TxExecutionResult, error) {
+	withWriteSet := *txResult
+	if txResult.Failed {
+		return &withWriteSet, nil
+	}
+	writeSet, err := rc.stack.getWriteSet(txResult.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	withWriteSet.WriteSet = writeSet
+	return &withWriteSet, nil
+}
+
+// diffTxResults returns the local results of the transactions whose remote
+// results differ or are missing
+func diffTxResults(local *pb.BlockExecutionResult, remote *pb.BlockExecutionResult) []*pb.TxExecutionResult {
+	remoteTxResults := txResultsByUUID(remote)
+	var differing []*pb.TxExecutionResult
+	for _, localTxResult := range local.TxResults {
+		remoteTxResult, ok := remoteTxResults[localTxResult.Uuid]
+		if !ok || remoteTxResult.Failed != localTxResult.Failed ||
+			!bytes.Equal(remoteTxResult.WriteSetHash, localTxResult.WriteSetHash) {
+			differing = append(differing, localTxResult)
+		}
+	}
+	return differing
+}
+
+func txResultsByUUID(result *pb.BlockExecutionResult) map[string]*pb.TxExecutionResult {
+	txResults := make(map[string]*pb.TxExecutionResult)
+	for _, txResult := range result.TxResults {
+		txResults[txResult.Uuid] = txResult
+	}
+	return txResults
+}
+
+func newExecutionResultMessage(result *pb.BlockExecutionResult) (*pb.Message, error) {
+	payload, err := proto.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Message{Type: pb.Message_EXECUTION_RESULT, Payload: payload, Timestamp: util.CreateUtcTimestamp()}, nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// mockResultCheckerStack delivers the messages of a resultChecker to the
+// resultCheckers of the other peers
+type mockResultCheckerStack struct {
+	self        *pb.PeerID
+	peers       map[string]*resultChecker
+	writeSets   map[string][]byte
+	divergences []*pb.Divergence
+}
+
+func (mock *mockResultCheckerStack) deliver(msg *pb.Message, receiver *resultChecker) {
+	result := &pb.BlockExecutionResult{}
+	if err := proto.Unmarshal(msg.Payload, result); err != nil {
+		panic(err)
+	}
+	receiver.received(mock.self, result)
+}
+
+func (mock *mockResultCheckerStack) Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error {
+	for name, peer := range mock.peers {
+		if name != mock.self.Name {
+			mock.deliver(msg, peer)
+		}
+	}
+	return nil
+}
+
+func (mock *mockResultCheckerStack) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
+	mock.deliver(msg, mock.peers[receiverHandle.Name])
+	return nil
+}
+
+func (mock *mockResultCheckerStack) getWriteSet(txUUID string) ([]byte, error) {
+	return mock.writeSets[txUUID], nil
+}
+
+func (mock *mockResultCheckerStack) reportDivergence(divergence *pb.Divergence) {
+	mock.divergences = append(mock.divergences, divergence)
+}
+
+func newMockResultCheckers(names ...string) map[string]*mockResultCheckerStack {
+	peers := make(map[string]*resultChecker)
+	stacks := make(map[string]*mockResultCheckerStack)
+	for _, name := range names {
+		stacks[name] = &mockResultCheckerStack{self: &pb.PeerID{Name: name}, peers: peers, writeSets: make(map[string][]byte)}
+		peers[name] = newResultChecker(stacks[name], 10)
+	}
+	return stacks
+}
+
+func newTestExecutionResult(blockNumber uint64, stateHash string, writeSets map[string][]byte) *pb.BlockExecutionResult {
+	block := &pb.Block{StateHash: []byte(stateHash)}
+	txDeltaHashes := make(map[string][]byte)
+	for _, uuid := range []string{"tx1", "tx2"} {
+		block.Transactions = append(block.Transactions, &pb.Transaction{Uuid: uuid})
+		if writeSet, ok := writeSets[uuid]; ok {
+			txDeltaHashes[uuid] = append([]byte("hash of "), writeSet...)
+		}
+	}
+	return newBlockExecutionResult(block, blockNumber, txDeltaHashes)
+}
+
+func TestResultCheckerMatchingResults(t *testing.T) {
+	stacks := newMockResultCheckers("vp0", "vp1", "vp2")
+	for name, stack := range stacks {
+		stack.writeSets["tx1"] = []byte("a=1")
+		stack.peers[name].committed(newTestExecutionResult(1, "state1", stack.writeSets))
+	}
+	for name, stack := range stacks {
+		if len(stack.divergences) != 0 {
+			t.Fatalf("Expected no divergence on %s, got %v", name, stack.divergences)
+		}
+	}
+}
+
+func TestResultCheckerDivergence(t *testing.T) {
+	stacks := newMockResultCheckers("vp0", "vp1", "vp2")
+	for name, stack := range stacks {
+		stack.writeSets["tx1"] = []byte("a=1")
+		writeSets := stack.writeSets
+		if name == "vp2" {
+			writeSets = map[string][]byte{"tx1": []byte("a=2")}
+			stack.writeSets = writeSets
+		}
+		stateHash := "state1"
+		if name == "vp2" {
+			stateHash = "state2"
+		}
+		stack.peers[name].committed(newTestExecutionResult(1, stateHash, writeSets))
+	}
+
+	for _, name := range []string{"vp0", "vp1"} {
+		divergences := stacks[name].divergences
+		if len(divergences) != 1 {
+			t.Fatalf("Expected one divergence on %s, got %v", name, divergences)
+		}
+		divergence := divergences[0]
+		if divergence.PeerID != "vp2" || divergence.BlockNumber != 1 || len(divergence.Transactions) != 1 {
+			t.Fatalf("Unexpected divergence on %s: %v", name, divergence)
+		}
+		txDivergence := divergence.Transactions[0]
+		if txDivergence.Uuid != "tx1" || !bytes.Equal(txDivergence.LocalWriteSet, []byte("a=1")) ||
+			!bytes.Equal(txDivergence.RemoteWriteSet, []byte("a=2")) || txDivergence.LocalFailed || txDivergence.RemoteFailed {
+			t.Fatalf("Unexpected transaction divergence on %s: %v", name, txDivergence)
+		}
+	}
+	if divergences := stacks["vp2"].divergences; len(divergences) != 2 {
+		t.Fatalf("Expected a divergence from each of the other peers on vp2, got %v", divergences)
+	}
+}
+
+func TestResultCheckerFailedTransaction(t *testing.T) {
+	stacks := newMockResultCheckers("vp0", "vp1")
+	stacks["vp0"].writeSets["tx2"] = []byte("b=1")
+	// vp1 commits the block before receiving the result of vp0
+	stacks["vp1"].peers["vp1"].committed(newTestExecutionResult(1, "state1", nil))
+	stacks["vp0"].peers["vp0"].committed(newTestExecutionResult(1, "state2", stacks["vp0"].writeSets))
+
+	divergences := stacks["vp1"].divergences
+	if len(divergences) != 1 || len(divergences[0].Transactions) != 1 {
+		t.Fatalf("Expected one divergence with one transaction on vp1, got %v", divergences)
+	}
+	txDivergence := divergences[0].Transactions[0]
+	if txDivergence.Uuid != "tx2" || !txDivergence.LocalFailed || txDivergence.RemoteFailed ||
+		!bytes.Equal(txDivergence.RemoteWriteSet, []byte("b=1")) {
+		t.Fatalf("Unexpected transaction divergence on vp1: %v", txDivergence)
+	}
+}
+
+func TestResultCheckerHistory(t *testing.T) {
+	stacks := newMockResultCheckers("vp0")
+	checker := stacks["vp0"].peers["vp0"]
+	for blockNumber := uint64(1); blockNumber <= 20; blockNumber++ {
+		checker.committed(newTestExecutionResult(blockNumber, "state", nil))
+	}
+	if len(checker.local) != 10 {
+		t.Fatalf("Expected the results of the last 10 blocks to be kept, got %d", len(checker.local))
+	}
+
+	sender := &pb.PeerID{Name: "vp1"}
+	checker.received(sender, newTestExecutionResult(25, "state", nil))
+	checker.received(sender, newTestExecutionResult(31, "state", nil))
+	if len(checker.pending) != 1 || len(checker.pending[25]) != 1 {
+		t.Fatalf("Expected only the result of block 25 to be pending, got %v", checker.pending)
+	}
+	checker.committed(newTestExecutionResult(26, "state", nil))
+	if len(checker.pending) != 0 {
+		t.Fatalf("Expected the pending results of older blocks to be dropped, got %v", checker.pending)
+	}
+}
@@ -25,9 +25,10 @@ import (
 	"github.com/op/go-logging"
 
 	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/util"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/hyperledger/fabric/core/util"
+	coreutil "github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -39,11 +40,15 @@ func init() {
 
 // Noops is a plugin object implementing the consensus.Consenter interface.
 type Noops struct {
-	stack    consensus.Stack
-	txQ      *txq
-	timer    *time.Timer
-	duration time.Duration
-	channel  chan *pb.Transaction
+	stack   consensus.Stack
+	cutter  *util.BlockCutter
+	timer   *time.Timer
+	channel chan *pb.Transaction
+}
+
+// NoopsMetrics are the statistics of the blocks cut by NOOPS
+type NoopsMetrics struct {
+	Blocks util.CutStats `json:"blocks"`
 }
 
 // Setting up a singleton NOOPS consenter
@@ -59,7 +64,6 @@ func GetNoops(c consensus.Stack) consensus.Consenter {
 
 // newNoops is a constructor returning a consensus.Consenter object.
 func newNoops(c consensus.Stack) consensus.Consenter {
-	var err error
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debug("Creating a NOOPS object")
 	}
@@ -67,23 +71,25 @@ func newNoops(c consensus.Stack) consensus.Consenter {
 	i.stack = c
 	config := loadConfig()
 	blockSize := config.GetInt("block.size")
+	blockMaxBytes := config.GetInt("block.maxbytes")
 	blockTimeout := config.GetString("block.timeout")
-	if _, err = strconv.Atoi(blockTimeout); err == nil {
+	if _, err := strconv.Atoi(blockTimeout); err == nil {
 		blockTimeout = blockTimeout + "s" //if string does not have unit of measure, default to seconds
 	}
-	i.duration, err = time.ParseDuration(blockTimeout)
-	if err != nil || i.duration == 0 {
+	duration, err := time.ParseDuration(blockTimeout)
+	if err != nil || duration == 0 {
 		panic(fmt.Errorf("Cannot parse block timeout: %s", err))
 	}
 
 	logger.Infof("NOOPS consensus type = %T", i)
 	logger.Infof("NOOPS block size = %v", blockSize)
-	logger.Infof("NOOPS block timeout = %v", i.duration)
+	logger.Infof("NOOPS block max bytes = %v", blockMaxBytes)
+	logger.Infof("NOOPS block timeout = %v", duration)
 
-	i.txQ = newTXQ(blockSize)
+	i.cutter = util.NewBlockCutter(util.CutPolicy{MaxCount: blockSize, MaxBytes: blockMaxBytes, Timeout: duration})
 
 	i.channel = make(chan *pb.Transaction, 100)
-	i.timer = time.NewTimer(i.duration) // start timer now so we can just reset it
+	i.timer = time.NewTimer(duration) // start timer now so we can just reset it
 	i.timer.Stop()
 	go i.handleChannels()
 	return i
@@ -136,20 +142,9 @@ func (i *Noops) broadcastConsensusMsg(msg *pb.Message) error {
 	return nil
 }
 
-func (i *Noops) canProcessBlock(tx *pb.Transaction) bool {
-	// For NOOPS, if we have completed the sync since we last connected,
-	// we can assume that we are at the current state; otherwise, we need to
-	// wait for the sync process to complete before we can exec the transactions
-
-	// TODO: Ask coordinator if we need to start sync
-
-	i.txQ.append(tx)
-
-	// start timer if we get a tx
-	if i.txQ.size() == 1 {
-		i.timer.Reset(i.duration)
-	}
-	return i.txQ.isFull()
+// GetMetrics returns the statistics of the blocks cut by NOOPS
+func (i *Noops) GetMetrics() interface{} {
+	return NoopsMetrics{Blocks: i.cutter.Stats()}
 }
 
 func (i *Noops) handleChannels() {
@@ -158,29 +153,40 @@ func (i *Noops) handleChannels() {
 	for {
 		select {
 		case tx := <-i.channel:
-			if i.canProcessBlock(tx) {
+			// For NOOPS, if we have completed the sync since we last connected,
+			// we can assume that we are at the current state; otherwise, we need to
+			// wait for the sync process to complete before we can exec the transactions
+
+			// TODO: Ask coordinator if we need to start sync
+
+			for _, block := range i.cutter.Ordered(tx, len(tx.Payload)) {
 				if logger.IsEnabledFor(logging.DEBUG) {
 					logger.Debug("Process block due to size")
 				}
-				if err := i.processBlock(); nil != err {
+				if err := i.processBlock(block); nil != err {
 					logger.Error(err.Error())
 				}
 			}
+
+			// start timer if we get the first tx of a block
+			if i.cutter.Pending() == 1 {
+				i.timer.Reset(i.cutter.Policy().Timeout)
+			}
 		case <-i.timer.C:
 			if logger.IsEnabledFor(logging.DEBUG) {
 				logger.Debug("Process block due to time")
 			}
-			if err := i.processBlock(); nil != err {
+			if err := i.processBlock(i.cutter.Cut()); nil != err {
 				logger.Error(err.Error())
 			}
 		}
 	}
 }
 
-func (i *Noops) processBlock() error {
+func (i *Noops) processBlock(block []interface{}) error {
 	i.timer.Stop()
 
-	if i.txQ.size() < 1 {
+	if len(block) < 1 {
 		if logger.IsEnabledFor(logging.DEBUG) {
 			logger.Debug("processBlock() called but transaction Q is empty")
 		}
@@ -190,7 +196,11 @@ func (i *Noops) processBlock() error {
 	var delta *statemgmt.StateDelta
 	var err error
 
-	if err = i.processTransactions(); nil != err {
+	txarr := make([]*pb.Transaction, len(block))
+	for j, tx := range block {
+		txarr[j] = tx.(*pb.Transaction)
+	}
+	if err = i.processTransactions(txarr); nil != err {
 		return err
 	}
 	if data, delta, err = i.getBlockData(); nil != err {
@@ -200,8 +210,8 @@ func (i *Noops) processBlock() error {
 	return nil
 }
 
-func (i *Noops) processTransactions() error {
-	timestamp := util.CreateUtcTimestamp()
+func (i *Noops) processTransactions(txarr []*pb.Transaction) error {
+	timestamp := coreutil.CreateUtcTimestamp()
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debugf("Starting TX batch with timestamp: %v", timestamp)
 	}
@@ -209,8 +219,7 @@ func (i *Noops) processTransactions() error {
 		return err
 	}
 
-	// Grab all transactions from the FIFO queue and run them in order
-	txarr := i.txQ.getTXs()
+	// Run the transactions of the block in order
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debugf("Executing batch of %d transactions with timestamp %v", len(txarr), timestamp)
 	}
@@ -269,11 +278,8 @@ func (i *Noops) getBlockData() (*pb.Block, *statemgmt.StateDelta, error) {
 }
 
 func (i *Noops) notifyBlockAdded(block *pb.Block, delta *statemgmt.StateDelta) error {
-	//make Payload nil to reduce block size..
-	//anything else to remove .. do we need StateDelta ?
-	for _, tx := range block.Transactions {
-		tx.Payload = nil
-	}
+	// The NVPs append the block to their blockchain and gossip it, so the
+	// transactions are sent whole for the chain to hash consistently
 	data, err := proto.Marshal(&pb.BlockState{Block: block, StateDelta: delta.Marshal()})
 	if err != nil {
 		return fmt.Errorf("Fail to marshall BlockState structure: %v", err)
@@ -287,7 +293,7 @@ func (i *Noops) notifyBlockAdded(block *pb.Block, delta *statemgmt.StateDelta) e
 	// in the execution. That is, they can compare their current block with
 	// the network block
 	msg := &pb.Message{Type: pb.Message_SYNC_BLOCK_ADDED,
-		Payload: data, Timestamp: util.CreateUtcTimestamp()}
+		Payload: data, Timestamp: coreutil.CreateUtcTimestamp()}
 	if errs := i.stack.Broadcast(msg, pb.PeerEndpoint_NON_VALIDATOR); nil != errs {
 		return fmt.Errorf("Failed to broadcast with errors: %v", errs)
 	}
@@ -33,6 +33,7 @@ type communicator interface {
 type broadcaster struct {
 	comm communicator
 
+	lock     sync.Mutex // guards f and msgChans, which change on reconfiguration
 	f        int
 	msgChans map[uint64]chan *pb.Message
 	closed   sync.WaitGroup
@@ -40,6 +41,16 @@ type broadcaster struct {
 }
 
 func newBroadcaster(self uint64, N int, f int, c communicator) *broadcaster {
+	b := &broadcaster{
+		comm:     c,
+		closedCh: make(chan struct{}),
+	}
+	b.setReplicas(self, N, f)
+	return b
+}
+
+// setReplicas sets the replicas messages are broadcast to
+func (b *broadcaster) setReplicas(self uint64, N int, f int) {
 	queueSize := 10 // XXX increase after testing
 
 	chans := make(map[uint64]chan *pb.Message)
@@ -49,13 +60,11 @@ func newBroadcaster(self uint64, N int, f int, c communicator) *broadcaster {
 		}
 		chans[uint64(i)] = make(chan *pb.Message, queueSize)
 	}
-	b := &broadcaster{
-		comm:     c,
-		f:        f,
-		msgChans: chans,
-		closedCh: make(chan struct{}),
-	}
-	return b
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.f = f
+	b.msgChans = chans
 }
 
 func (b *broadcaster) Close() {
@@ -103,14 +112,19 @@ func (b *broadcaster) send(msg *pb.Message, dest *uint64) error {
 	default:
 	}
 
+	b.lock.Lock()
+	msgChans := b.msgChans
+	f := b.f
+	b.lock.Unlock()
+
 	var destCount int
 	var required int
 	if dest != nil {
 		destCount = 1
 		required = 1
 	} else {
-		destCount = len(b.msgChans)
-		required = destCount - b.f
+		destCount = len(msgChans)
+		required = destCount - f
 	}
 
 	wait := make(chan bool, destCount)
@@ -119,8 +133,8 @@ func (b *broadcaster) send(msg *pb.Message, dest *uint64) error {
 		b.closed.Add(1)
 		go b.unicastOne(msg, *dest, wait)
 	} else {
-		b.closed.Add(len(b.msgChans))
-		for i := range b.msgChans {
+		b.closed.Add(len(msgChans))
+		for i := range msgChans {
 			go b.unicastOne(msg, i, wait)
 		}
 	}
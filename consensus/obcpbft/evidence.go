@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/events/producer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// maxEvidence is the number of evidences of misbehavior a replica keeps, the
+// oldest are dropped first
+const maxEvidence = 100
+
+// evidenceStore holds the evidences of misbehavior collected by the replica
+// for reading them outside of the PBFT thread
+type evidenceStore struct {
+	sync.Mutex
+	evidence []*pb.Evidence
+}
+
+// reportMisbehavior records that replicaID sent the conflicting messages first
+// and second for view and seqNo, and sends a misbehavior event
+func (instance *pbftCore) reportMisbehavior(typ pb.Evidence_Type, replicaID uint64, view uint64, seqNo uint64, first *Message, second *Message) {
+	instance.evidence.Lock()
+	defer instance.evidence.Unlock()
+	for _, e := range instance.evidence.evidence {
+		if e.Type == typ && e.ReplicaID == replicaID && e.View == view && e.SequenceNumber == seqNo {
+			return // reported already
+		}
+	}
+
+	logger.Warningf("Replica %d detected replica %d sending %s messages for view=%d/seqNo=%d", instance.id, replicaID, typ, view, seqNo)
+	evidence := &pb.Evidence{
+		Type:           typ,
+		ReplicaID:      replicaID,
+		View:           view,
+		SequenceNumber: seqNo,
+		Timestamp:      util.CreateUtcTimestamp(),
+		ReporterID:     instance.id,
+	}
+	var err error
+	if evidence.First, err = proto.Marshal(first); err != nil {
+		logger.Errorf("Replica %d could not marshal evidence: %s", instance.id, err)
+		return
+	}
+	if evidence.Second, err = proto.Marshal(second); err != nil {
+		logger.Errorf("Replica %d could not marshal evidence: %s", instance.id, err)
+		return
+	}
+	raw, err := proto.Marshal(evidence)
+	if err != nil {
+		logger.Errorf("Replica %d could not marshal evidence: %s", instance.id, err)
+		return
+	}
+	if evidence.Signature, err = instance.consumer.sign(raw); err != nil {
+		logger.Errorf("Replica %d could not sign evidence: %s", instance.id, err)
+		return
+	}
+
+	if len(instance.evidence.evidence) >= maxEvidence {
+		instance.evidence.evidence = instance.evidence.evidence[1:]
+	}
+	instance.evidence.evidence = append(instance.evidence.evidence, evidence)
+
+	// do not wait on the event consumers from the PBFT thread
+	go func() {
+		if err := producer.Send(producer.CreateEvidenceEvent(evidence)); err != nil {
+			logger.Errorf("Replica %d failed to send the misbehavior event: %s", instance.id, err)
+		}
+	}()
+}
+
+// getEvidence may be called from any go routine
+func (instance *pbftCore) getEvidence() []*pb.Evidence {
+	instance.evidence.Lock()
+	defer instance.evidence.Unlock()
+	evidence := make([]*pb.Evidence, len(instance.evidence.evidence))
+	copy(evidence, instance.evidence.evidence)
+	return evidence
+}
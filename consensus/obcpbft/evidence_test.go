@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestEvidenceOfConflictingMessages(t *testing.T) {
+	mock := &omniProto{
+		validateImpl: func(msg []byte) error {
+			return nil
+		},
+		broadcastImpl: func(msg []byte) {},
+		signImpl: func(msg []byte) ([]byte, error) {
+			return []byte("signed"), nil
+		},
+		verifyImpl: func(senderID uint64, signature []byte, message []byte) error {
+			return nil
+		},
+	}
+	instance := newPbftCore(1, loadConfig(), mock, &inertTimerFactory{})
+	defer instance.close()
+
+	// replica 2 prepares two requests for the same sequence number, the
+	// evidence is only reported once
+	instance.recvPrepare(&Prepare{View: 0, SequenceNumber: 1, RequestDigest: "foo", ReplicaId: 2})
+	instance.recvPrepare(&Prepare{View: 0, SequenceNumber: 1, RequestDigest: "foo", ReplicaId: 2})
+	if evidence := instance.getEvidence(); len(evidence) != 0 {
+		t.Fatalf("Expected no evidence for a duplicate prepare, got %v", evidence)
+	}
+	instance.recvPrepare(&Prepare{View: 0, SequenceNumber: 1, RequestDigest: "bar", ReplicaId: 2})
+	instance.recvPrepare(&Prepare{View: 0, SequenceNumber: 1, RequestDigest: "baz", ReplicaId: 2})
+	evidence := instance.getEvidence()
+	if len(evidence) != 1 {
+		t.Fatalf("Expected one evidence of conflicting prepares, got %v", evidence)
+	}
+	e := evidence[0]
+	if e.Type != pb.Evidence_CONFLICTING_PREPARE || e.ReplicaID != 2 || e.SequenceNumber != 1 || e.ReporterID != 1 {
+		t.Fatalf("Expected evidence of conflicting prepares from replica 2, got %v", e)
+	}
+	if string(e.Signature) != "signed" || e.Timestamp == nil {
+		t.Fatalf("Expected signed and timestamped evidence, got %v", e)
+	}
+	first, second := &Message{}, &Message{}
+	if err := proto.Unmarshal(e.First, first); err != nil || first.GetPrepare() == nil || first.GetPrepare().RequestDigest != "foo" {
+		t.Fatalf("Expected the first prepare in the evidence, got %v (%v)", first, err)
+	}
+	if err := proto.Unmarshal(e.Second, second); err != nil || second.GetPrepare() == nil || second.GetPrepare().RequestDigest != "bar" {
+		t.Fatalf("Expected the second prepare in the evidence, got %v (%v)", second, err)
+	}
+
+	instance.recvCommit(&Commit{View: 0, SequenceNumber: 1, RequestDigest: "foo", ReplicaId: 3})
+	instance.recvCommit(&Commit{View: 0, SequenceNumber: 1, RequestDigest: "bar", ReplicaId: 3})
+	if evidence = instance.getEvidence(); len(evidence) != 2 || evidence[1].Type != pb.Evidence_CONFLICTING_COMMIT || evidence[1].ReplicaID != 3 {
+		t.Fatalf("Expected evidence of conflicting commits from replica 3, got %v", evidence)
+	}
+
+	// the primary pre-prepares two requests for the same sequence number
+	req1 := createPbftRequestWithChainTx(1, 0)
+	req2 := createPbftRequestWithChainTx(2, 0)
+	instance.recvPrePrepare(&PrePrepare{View: 0, SequenceNumber: 2, RequestDigest: hashReq(req1), Request: req1, ReplicaId: 0})
+	instance.recvPrePrepare(&PrePrepare{View: 0, SequenceNumber: 2, RequestDigest: hashReq(req2), Request: req2, ReplicaId: 0})
+	if evidence = instance.getEvidence(); len(evidence) != 3 || evidence[2].Type != pb.Evidence_CONFLICTING_PRE_PREPARE || evidence[2].ReplicaID != 0 {
+		t.Fatalf("Expected evidence of conflicting pre-prepares from the primary, got %v", evidence)
+	}
+}
@@ -65,6 +65,17 @@ func (shim *legacyGenericShim) init(id uint64, config *viper.Viper, consumer leg
 	logger.Debug("Replica %d legacyGenericShim now initialized: %v", id, shim)
 }
 
+// GetMetrics returns the current PBFT timeouts of the replica, and the
+// statistics of the garbage collection of its persisted state
+func (shim *legacyGenericShim) GetMetrics() interface{} {
+	return shim.pbft.getMetrics()
+}
+
+// GetEvidence returns the evidence of misbehavior collected by the replica
+func (shim *legacyGenericShim) GetEvidence() []*pb.Evidence {
+	return shim.pbft.getEvidence()
+}
+
 // Executed is called whenever Execute completes, no-op for now as the legacy code uses the legacy API
 func (shim *legacyGenericShim) Executed(tag interface{}) {
 	// Never called
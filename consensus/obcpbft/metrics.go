@@ -0,0 +1,32 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import "github.com/hyperledger/fabric/core/metrics"
+
+var (
+	viewChangesSent = metrics.NewCounter("fabric_pbft_view_changes_total",
+		"Number of view changes started by the replica")
+	newViewsAccepted = metrics.NewCounter("fabric_pbft_new_views_total",
+		"Number of new views accepted by the replica")
+	currentView = metrics.NewGauge("fabric_pbft_view",
+		"Current view of the replica")
+)
+
+func init() {
+	metrics.MustRegister(viewChangesSent, newViewsAccepted, currentView)
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/hyperledger/fabric/consensus"
 	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+	"github.com/hyperledger/fabric/consensus/util"
 	pb "github.com/hyperledger/fabric/protos"
 
 	"google/protobuf"
@@ -37,11 +38,9 @@ type obcBatch struct {
 	pbft        *pbftCore
 	broadcaster *broadcaster
 
-	batchSize        int
-	batchStore       []*Request
+	batchCutter      *util.BlockCutter
 	batchTimer       events.Timer
 	batchTimerActive bool
-	batchTimeout     time.Duration
 
 	manager events.Manager // TODO, remove eventually, the event manager
 
@@ -50,6 +49,8 @@ type obcBatch struct {
 
 	reqStore *requestStore // Holds the outstanding and pending requests
 
+	reloader *configReloader
+
 	persistForward
 }
 
@@ -72,8 +73,6 @@ type batchMessageEvent batchMessage
 type batchTimerEvent struct{}
 
 func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatch {
-	var err error
-
 	op := &obcBatch{
 		obcGeneric: obcGeneric{stack: stack},
 	}
@@ -90,14 +89,17 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 	op.externalEventReceiver.manager = op.manager
 	op.broadcaster = newBroadcaster(id, op.pbft.N, op.pbft.f, stack)
 
-	op.batchSize = config.GetInt("general.batchsize")
-	op.batchStore = nil
-	op.batchTimeout, err = time.ParseDuration(config.GetString("general.timeout.batch"))
+	batchSize := config.GetInt("general.batchsize")
+	batchMaxBytes := config.GetInt("general.batchmaxbytes")
+	batchTimeout, err := time.ParseDuration(config.GetString("general.timeout.batch"))
 	if err != nil {
 		panic(fmt.Errorf("Cannot parse batch timeout: %s", err))
 	}
-	logger.Infof("PBFT Batch size = %d", op.batchSize)
-	logger.Infof("PBFT Batch timeout = %v", op.batchTimeout)
+	logger.Infof("PBFT Batch size = %d", batchSize)
+	logger.Infof("PBFT Batch max bytes = %d", batchMaxBytes)
+	logger.Infof("PBFT Batch timeout = %v", batchTimeout)
+	op.batchCutter = util.NewBlockCutter(util.CutPolicy{MaxCount: batchSize, MaxBytes: batchMaxBytes, Timeout: batchTimeout})
+	op.reloader = newConfigReloader(config)
 
 	op.incomingChan = make(chan *batchMessage)
 
@@ -111,6 +113,33 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 	return op
 }
 
+// BatchMetrics are the metrics of a batch replica, and the statistics of the
+// batches it cut as primary
+type BatchMetrics struct {
+	ReplicaMetrics
+	Blocks util.CutStats `json:"blocks"`
+}
+
+// GetMetrics returns the current PBFT timeouts of the replica, the statistics
+// of the garbage collection of its persisted state and of the batches it cut
+func (op *obcBatch) GetMetrics() interface{} {
+	return BatchMetrics{
+		ReplicaMetrics: op.pbft.getMetrics(),
+		Blocks:         op.batchCutter.Stats(),
+	}
+}
+
+// GetEvidence returns the evidence of misbehavior collected by the replica
+func (op *obcBatch) GetEvidence() []*pb.Evidence {
+	return op.pbft.getEvidence()
+}
+
+// ReloadConfig reads config.yaml and the environment again, and has the
+// replica apply the changes of its timeouts
+func (op *obcBatch) ReloadConfig() (*pb.ConfigReload, error) {
+	return op.reloader.reload(op.manager.Queue())
+}
+
 // Close tells us to release resources we are holding
 func (op *obcBatch) Close() {
 	op.batchTimer.Halt()
@@ -212,6 +241,9 @@ func (op *obcBatch) execute(seqNo uint64, raw []byte) {
 		if outstanding, pending := op.reqStore.remove(req); !outstanding || !pending {
 			logger.Debugf("Batch replica %d missing transaction %s outstanding=%v, pending=%v", op.pbft.id, tx.Uuid, outstanding, pending)
 		}
+		if tx.Type == pb.Transaction_CONSENSUS_RECONFIGURE {
+			op.pbft.scheduleReconfiguration(seqNo, tx)
+		}
 		txs = append(txs, tx)
 	}
 
@@ -222,6 +254,11 @@ func (op *obcBatch) execute(seqNo uint64, raw []byte) {
 	op.stack.Execute(meta, txs) // This executes in the background, we will receive an executedEvent once it completes
 }
 
+// reconfigured is invoked by pbft once it applied a reconfiguration
+func (op *obcBatch) reconfigured(N int, f int) {
+	op.broadcaster.setReplicas(op.pbft.id, N, f)
+}
+
 // =============================================================================
 // functions specific to batch mode
 // =============================================================================
@@ -232,32 +269,39 @@ func (op *obcBatch) leaderProcReq(req *Request) events.Event {
 	hash := hashReq(req)
 
 	logger.Debugf("Batch primary %d queueing new request %s", op.pbft.id, hash)
-	op.batchStore = append(op.batchStore, req)
+	batches := op.batchCutter.Ordered(req, len(req.Payload))
 	op.reqStore.storePending(req)
 
-	if !op.batchTimerActive {
+	if len(batches) > 0 {
+		op.stopBatchTimer()
+	}
+	if op.batchCutter.Pending() > 0 && !op.batchTimerActive {
 		op.startBatchTimer()
 	}
 
-	if len(op.batchStore) >= op.batchSize {
-		return op.sendBatch()
+	if len(batches) == 0 {
+		return nil
 	}
-
-	return nil
+	// the request may have cut the queued requests, and a batch of its own
+	for _, batch := range batches[:len(batches)-1] {
+		if msg := op.sendBatch(batch); msg != nil {
+			op.manager.Inject(msg)
+		}
+	}
+	return op.sendBatch(batches[len(batches)-1])
 }
 
-func (op *obcBatch) sendBatch() events.Event {
-	op.stopBatchTimer()
-
-	if len(op.batchStore) == 0 {
+func (op *obcBatch) sendBatch(batch []interface{}) events.Event {
+	if len(batch) == 0 {
 		logger.Error("Told to send an empty batch store for ordering, ignoring")
 		return nil
 	}
 
-	earliestRequest := op.batchStore[0]
-
-	reqBlock := &RequestBlock{op.batchStore}
-	op.batchStore = nil
+	reqBlock := &RequestBlock{make([]*Request, len(batch))}
+	for i, req := range batch {
+		reqBlock.Requests[i] = req.(*Request)
+	}
+	earliestRequest := reqBlock.Requests[0]
 
 	reqsPacked, err := proto.Marshal(reqBlock)
 	if err != nil {
@@ -359,7 +403,7 @@ func (op *obcBatch) resubmitOutstandingReqs() events.Event {
 	// we run out of requests, or a new batch message is triggered (this path will re-enter after execution)
 	// Do not enter while an execution is in progress to prevent duplicating a request
 	if op.pbft.primary(op.pbft.view) == op.pbft.id && op.pbft.activeView && op.pbft.currentExec == nil {
-		needed := op.batchSize - len(op.batchStore)
+		needed := op.batchCutter.Policy().MaxCount - op.batchCutter.Pending()
 
 		for op.reqStore.hasNonPending() {
 			outstanding := op.reqStore.getNextNonPending(needed)
@@ -395,8 +439,9 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 		return op.resubmitOutstandingReqs()
 	case batchTimerEvent:
 		logger.Infof("Replica %d batch timer expired", op.pbft.id)
-		if op.pbft.activeView && (len(op.batchStore) > 0) {
-			return op.sendBatch()
+		if op.pbft.activeView && (op.batchCutter.Pending() > 0) {
+			op.stopBatchTimer()
+			return op.sendBatch(op.batchCutter.Cut())
 		}
 	case viewChangedEvent:
 		// Outstanding reqs doesn't make sense for batch, as all the requests in a batch may be processed
@@ -440,6 +485,12 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 		}
 
 		return op.resubmitOutstandingReqs()
+	case timeoutsReloadedEvent:
+		if timeout := op.batchCutter.Policy().Timeout; timeout != et.batch {
+			logger.Infof("Replica %d batch timeout changed from %v to %v", op.pbft.id, timeout, et.batch)
+			op.batchCutter.SetTimeout(et.batch)
+		}
+		return op.pbft.ProcessEvent(event)
 	case stateUpdatedEvent:
 		// When the state is updated, clear any outstanding requests, they may have been processed while we were gone
 		op.reqStore = newRequestStore()
@@ -452,7 +503,7 @@ func (op *obcBatch) ProcessEvent(event events.Event) events.Event {
 }
 
 func (op *obcBatch) startBatchTimer() {
-	op.batchTimer.Reset(op.batchTimeout, batchTimerEvent{})
+	op.batchTimer.Reset(op.batchCutter.Policy().Timeout, batchTimerEvent{})
 	logger.Debugf("Replica %d started the batch timer", op.pbft.id)
 	op.batchTimerActive = true
 }
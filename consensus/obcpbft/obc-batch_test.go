@@ -22,6 +22,7 @@ import (
 
 	"github.com/hyperledger/fabric/consensus"
 	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+	"github.com/hyperledger/fabric/consensus/util"
 	pb "github.com/hyperledger/fabric/protos"
 
 	"github.com/golang/protobuf/proto"
@@ -41,7 +42,8 @@ func TestNetworkBatch(t *testing.T) {
 	batchSize := 2
 	validatorCount := 4
 	net := makeConsumerNetwork(validatorCount, obcBatchHelper, func(ce *consumerEndpoint) {
-		ce.consumer.(*obcBatch).batchSize = batchSize
+		op := ce.consumer.(*obcBatch)
+		op.batchCutter = util.NewBlockCutter(util.CutPolicy{MaxCount: batchSize, Timeout: op.batchCutter.Policy().Timeout})
 	})
 	defer net.stop()
 
@@ -58,9 +60,8 @@ func TestNetworkBatch(t *testing.T) {
 	net.process()
 	net.process()
 
-	if l := len(net.endpoints[0].(*consumerEndpoint).consumer.(*obcBatch).batchStore); l != 0 {
-		t.Errorf("%d messages expected in primary's batchStore, found %v", 0,
-			net.endpoints[0].(*consumerEndpoint).consumer.(*obcBatch).batchStore)
+	if l := net.endpoints[0].(*consumerEndpoint).consumer.(*obcBatch).batchCutter.Pending(); l != 0 {
+		t.Errorf("%d messages expected in primary's batchStore, found %d", 0, l)
 	}
 
 	for _, ep := range net.endpoints {
@@ -129,14 +129,17 @@ func (op *obcClassic) validate(txRaw []byte) error {
 
 // execute an opaque request which corresponds to an OBC Transaction
 func (op *obcClassic) execute(seqNo uint64, txRaw []byte) {
-	go func() {
-		tx := &pb.Transaction{}
-		err := proto.Unmarshal(txRaw, tx)
-		if err != nil {
-			logger.Errorf("Unable to unmarshal transaction: %v", err)
-			return
-		}
+	tx := &pb.Transaction{}
+	err := proto.Unmarshal(txRaw, tx)
+	if err != nil {
+		logger.Errorf("Unable to unmarshal transaction: %v", err)
+		return
+	}
+	if tx.Type == pb.Transaction_CONSENSUS_RECONFIGURE {
+		op.pbft.scheduleReconfiguration(seqNo, tx)
+	}
 
+	go func() {
 		meta, _ := proto.Marshal(&Metadata{seqNo})
 
 		id := []byte("foo")
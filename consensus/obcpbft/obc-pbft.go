@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/hyperledger/fabric/consensus"
+	coreconfig "github.com/hyperledger/fabric/core/config"
 	pb "github.com/hyperledger/fabric/protos"
 
 	"github.com/golang/protobuf/proto"
@@ -66,6 +67,15 @@ func New(stack consensus.Stack) consensus.Consenter {
 }
 
 func loadConfig() (config *viper.Viper) {
+	config, err := readConfig()
+	if err != nil {
+		panic(fmt.Errorf("Error reading %s plugin config: %s", configPrefix, err))
+	}
+	return
+}
+
+// readConfig reads config.yaml, with the overrides of the environment
+func readConfig() (config *viper.Viper, err error) {
 	config = viper.New()
 
 	// for environment variables
@@ -85,13 +95,65 @@ func loadConfig() (config *viper.Viper) {
 		config.AddConfigPath(obcpbftpath)
 	}
 
-	err := config.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("Error reading %s plugin config: %s", configPrefix, err))
-	}
+	err = config.ReadInConfig()
 	return
 }
 
+// configRules are the rules config.yaml must meet, on top of those between
+// keys checked by ValidateConfig
+var configRules = []coreconfig.Rule{
+	{Key: "general.mode", Kind: coreconfig.String, Required: true, Values: []string{"classic", "batch", "sieve"}},
+	{Key: "general.N", Kind: coreconfig.Int, Required: true, Range: &coreconfig.Range{Min: 1}},
+	{Key: "general.f", Kind: coreconfig.Int, Required: true, Range: &coreconfig.Range{Min: 0}},
+	{Key: "general.K", Kind: coreconfig.Int, Required: true, Range: &coreconfig.Range{Min: 1}},
+	{Key: "general.logmultiplier", Kind: coreconfig.Int, Required: true, Range: &coreconfig.Range{Min: 2}},
+	{Key: "general.batchsize", Kind: coreconfig.Int, Range: &coreconfig.Range{Min: 1}},
+	{Key: "general.batchmaxbytes", Kind: coreconfig.Int, Range: &coreconfig.Range{Min: 0}},
+	{Key: "general.compactinterval", Kind: coreconfig.Int, Range: &coreconfig.Range{Min: 0}},
+	{Key: "general.pipelinedepth", Kind: coreconfig.Int, Range: &coreconfig.Range{Min: 0}},
+	{Key: "general.viewchangeperiod", Kind: coreconfig.Int, Range: &coreconfig.Range{Min: 0}},
+	{Key: "general.byzantine", Kind: coreconfig.Bool},
+	{Key: "general.reconfigurable", Kind: coreconfig.Bool},
+	{Key: "general.wal.enabled", Kind: coreconfig.Bool},
+	{Key: "general.wal.fsync", Kind: coreconfig.String, Values: []string{walFsyncAlways, walFsyncCheckpoint, walFsyncNever}, When: "general.wal.enabled"},
+	{Key: "general.leader.policy", Kind: coreconfig.String, Values: []string{leaderRoundRobin, leaderWeighted, leaderBlacklist}},
+	{Key: "general.timeout.batch", Kind: coreconfig.Duration, Required: true, Range: &coreconfig.Range{Min: 1}},
+	{Key: "general.timeout.request", Kind: coreconfig.Duration, Required: true, Range: &coreconfig.Range{Min: 1}},
+	{Key: "general.timeout.requestmax", Kind: coreconfig.Duration},
+	{Key: "general.timeout.requestdecay", Kind: coreconfig.Float, Range: &coreconfig.Range{Min: 0, Max: 1}},
+	{Key: "general.timeout.viewchange", Kind: coreconfig.Duration, Required: true, Range: &coreconfig.Range{Min: 1}},
+	{Key: "general.timeout.nullrequest", Kind: coreconfig.Duration},
+}
+
+// Config returns the PBFT configuration, read from config.yaml and the
+// environment
+func Config() *viper.Viper {
+	return config
+}
+
+// ValidateConfig checks the PBFT configuration c, and returns an error for
+// each problem found, which would otherwise make the plugin fail to start
+func ValidateConfig(c *viper.Viper) []error {
+	errs := coreconfig.Validate(c, configRules)
+	if N, f := c.GetInt("general.N"), c.GetInt("general.f"); f*3+1 > N {
+		errs = append(errs, fmt.Errorf("general.N: need at least %d replicas to tolerate %d byzantine faults, but only %d replicas configured", f*3+1, f, N))
+	}
+	if c.IsSet("general.timeout.requestdecay") && c.GetFloat64("general.timeout.requestdecay") <= 0 {
+		errs = append(errs, fmt.Errorf("general.timeout.requestdecay: request timeout decay must be in (0, 1]"))
+	}
+	if strings.ToLower(c.GetString("general.leader.policy")) == leaderWeighted {
+		if _, err := parseReplicaList(c.GetString("general.leader.weights")); err != nil {
+			errs = append(errs, fmt.Errorf("general.leader.weights: %s", err))
+		}
+	}
+	if strings.ToLower(c.GetString("general.leader.policy")) == leaderBlacklist {
+		if _, err := parseReplicaList(c.GetString("general.leader.blacklist")); err != nil {
+			errs = append(errs, fmt.Errorf("general.leader.blacklist: %s", err))
+		}
+	}
+	return errs
+}
+
 // Returns the uint64 ID corresponding to a peer handle
 func getValidatorID(handle *pb.PeerID) (id uint64, err error) {
 	// as requested here: https://github.com/hyperledger/fabric/issues/462#issuecomment-170785410
@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+)
+
+func TestValidateConfig(t *testing.T) {
+	config := loadConfig()
+	if errs := ValidateConfig(config); len(errs) != 0 {
+		t.Fatalf("Expected config.yaml to be valid, got %v", errs)
+	}
+
+	config.Set("general.mode", "fast")
+	config.Set("general.N", 3)
+	config.Set("general.timeout.request", "soon")
+	errs := ValidateConfig(config)
+	expected := []string{"general.mode:", "general.timeout.request:", "general.N:"}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err.Error(), expected[i]) {
+			t.Errorf("Expected error %d to be about %s, got %s", i, expected[i], err)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	// the configuration last applied differs from config.yaml
+	applied := loadConfig()
+	applied.Set("general.timeout.viewchange", "7s")
+	applied.Set("general.batchsize", 20)
+	cr := newConfigReloader(applied)
+	queue := make(chan events.Event, 1)
+
+	result, err := cr.reload(queue)
+	if err != nil {
+		t.Fatalf("Error reloading the configuration: %s", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Key != "pbft.general.timeout.viewchange" || result.Changes[0].NewValue != "2s" {
+		t.Fatalf("Expected the view change timeout to change to 2s, got %v", result.Changes)
+	}
+	if !reflect.DeepEqual(result.RestartRequired, []string{"pbft.general.batchsize"}) {
+		t.Fatalf("Expected pbft.general.batchsize to require a restart, got %v", result.RestartRequired)
+	}
+
+	instance := newPbftCore(0, applied, &omniProto{}, &inertTimerFactory{})
+	defer instance.close()
+	instance.ProcessEvent(<-queue)
+	if instance.newViewTimeout != 2*time.Second {
+		t.Fatalf("Expected the reloaded view change timeout, got %v", instance.newViewTimeout)
+	}
+
+	if result, err = cr.reload(queue); err != nil || len(result.Changes) != 0 || len(result.RestartRequired) != 0 {
+		t.Fatalf("Expected no change reloading the same configuration, got %v, %v", result, err)
+	}
+
+	os.Setenv("CORE_PBFT_GENERAL_TIMEOUT_VIEWCHANGE", "soon")
+	defer os.Unsetenv("CORE_PBFT_GENERAL_TIMEOUT_VIEWCHANGE")
+	if _, err = cr.reload(queue); err == nil {
+		t.Fatalf("Expected an invalid view change timeout to fail the reload")
+	}
+}
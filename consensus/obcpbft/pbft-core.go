@@ -27,6 +27,7 @@ import (
 	"github.com/hyperledger/fabric/consensus"
 	"github.com/hyperledger/fabric/consensus/obcpbft/events"
 	_ "github.com/hyperledger/fabric/core" // Needed for logging format init
+	pb "github.com/hyperledger/fabric/protos"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
@@ -131,6 +132,8 @@ type pbftCore struct {
 	K             uint64            // checkpoint period
 	logMultiplier uint64            // use this value to calculate log size : k*logMultiplier
 	L             uint64            // log size
+	pipelineDepth uint64            // max. number of requests the primary has pre-prepared but not executed, 0 for no bound
+	pipelineFull  bool              // whether the primary held back a request because its pipeline was full
 	lastExec      uint64            // last request we executed
 	replicaCount  int               // number of replicas; PBFT `|R|`
 	seqNo         uint64            // PBFT "n", strictly monotonic increasing sequence number
@@ -148,6 +151,7 @@ type pbftCore struct {
 	timerActive        bool                // is the timer running?
 	newViewTimer       events.Timer        // timeout triggering a view change
 	requestTimeout     time.Duration       // progress timeout for requests
+	requestTimeouts    adaptiveTimeout     // adapts the request timeout to the commit latency
 	newViewTimeout     time.Duration       // progress timeout for new views
 	newViewTimerReason string              // what triggered the timer
 	lastNewViewTimeout time.Duration       // last timeout we used during this view change
@@ -155,11 +159,22 @@ type pbftCore struct {
 
 	nullRequestTimer   events.Timer  // timeout triggering a null request
 	nullRequestTimeout time.Duration // duration for this timeout
+	timeoutMetrics     timeoutMetrics
 	viewChangePeriod   uint64        // period between automatic view changes
 	viewChangeSeqNo    uint64        // next seqNo to perform view change
 
 	missingReqs map[string]bool // for all the assigned, non-checkpointed requests we might be missing during view-change
 
+	wal      *messageLog   // write-ahead log of the messages, nil if disabled
+	evidence evidenceStore // misbehavior detected, exposed to other go routines
+	gc       stateGC       // garbage collection of the persisted state, metrics exposed to other go routines
+
+	leaderPolicy leaderPolicy // maps views to their primary
+
+	reconfigurable  bool             // whether reconfiguration transactions are applied
+	reconfigAdmins  map[string]bool  // hex encoded hashes of the certificates of the admins signing them
+	pendingReconfig *reconfiguration // reconfiguration waiting for a stable checkpoint
+
 	// implementation of PBFT `in`
 	reqStore        map[string]*Request   // track requests
 	certStore       map[msgID]*msgCert    // track quorum certificates for requests
@@ -234,14 +249,24 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 		panic("Log multiplier must be greater than or equal to 2")
 	}
 	instance.L = instance.logMultiplier * instance.K // log size
+	instance.pipelineDepth = uint64(config.GetInt("general.pipelinedepth"))
 	instance.viewChangePeriod = uint64(config.GetInt("general.viewchangeperiod"))
 
 	instance.byzantine = config.GetBool("general.byzantine")
+	instance.reconfigurable = config.GetBool("general.reconfigurable")
+	instance.reconfigAdmins = make(map[string]bool)
+	for _, certHash := range config.GetStringSlice("general.reconfigadmins") {
+		instance.reconfigAdmins[certHash] = true
+	}
+	instance.wal = newMessageLog(config, id)
+	instance.leaderPolicy = newLeaderPolicy(config, instance.N)
+	instance.gc.compactInterval = uint64(config.GetInt("general.compactinterval"))
 
 	instance.requestTimeout, err = time.ParseDuration(config.GetString("general.timeout.request"))
 	if err != nil {
 		panic(fmt.Errorf("Cannot parse request timeout: %s", err))
 	}
+	instance.requestTimeouts = newAdaptiveTimeout(config, instance.requestTimeout)
 	instance.newViewTimeout, err = time.ParseDuration(config.GetString("general.timeout.viewchange"))
 	if err != nil {
 		panic(fmt.Errorf("Cannot parse new view timeout: %s", err))
@@ -258,11 +283,27 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 	logger.Infof("PBFT Max number of validating peers (N) = %v", instance.N)
 	logger.Infof("PBFT Max number of failing peers (f) = %v", instance.f)
 	logger.Infof("PBFT byzantine flag = %v", instance.byzantine)
+	logger.Infof("PBFT leader policy = %T", instance.leaderPolicy)
 	logger.Infof("PBFT request timeout = %v", instance.requestTimeout)
+	if instance.requestTimeouts.enabled {
+		logger.Infof("PBFT adaptive request timeout bound = %v, decay = %v", instance.requestTimeouts.max, instance.requestTimeouts.decay)
+	} else {
+		logger.Infof("PBFT adaptive request timeout disabled")
+	}
 	logger.Infof("PBFT view change timeout = %v", instance.newViewTimeout)
 	logger.Infof("PBFT Checkpoint period (K) = %v", instance.K)
 	logger.Infof("PBFT Log multiplier = %v", instance.logMultiplier)
 	logger.Infof("PBFT log size (L) = %v", instance.L)
+	if instance.pipelineDepth > 0 {
+		logger.Infof("PBFT pipeline depth = %v", instance.pipelineDepth)
+	} else {
+		logger.Infof("PBFT pipeline depth bound by the log size")
+	}
+	if instance.gc.compactInterval > 0 {
+		logger.Infof("PBFT persisted state compaction interval = %v checkpoints", instance.gc.compactInterval)
+	} else {
+		logger.Infof("PBFT persisted state compaction disabled")
+	}
 	if instance.nullRequestTimeout > 0 {
 		logger.Infof("PBFT null requests timeout = %v", instance.nullRequestTimeout)
 	} else {
@@ -290,6 +331,7 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 	instance.chkpts[0] = "XXX GENESIS"
 
 	instance.lastNewViewTimeout = instance.newViewTimeout
+	instance.updateTimeoutMetrics()
 	instance.outstandingReqs = make(map[string]*Request)
 	instance.missingReqs = make(map[string]bool)
 
@@ -305,6 +347,9 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 func (instance *pbftCore) close() {
 	instance.newViewTimer.Halt()
 	instance.nullRequestTimer.Halt()
+	if instance.wal != nil {
+		instance.wal.close()
+	}
 }
 
 // allow the view-change protocol to kick-off when the timer expires
@@ -314,6 +359,8 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 	logger.Debugf("Replica %d processing event", instance.id)
 
 	switch et := e.(type) {
+	case timeoutsReloadedEvent:
+		instance.reloadTimeouts(et)
 	case viewChangeTimerEvent:
 		logger.Infof("Replica %d view change timer expired, sending view change: %s", instance.id, instance.newViewTimerReason)
 		instance.timerActive = false
@@ -410,7 +457,7 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 
 // Given a certain view n, what is the expected primary?
 func (instance *pbftCore) primary(n uint64) uint64 {
-	return n % uint64(instance.replicaCount)
+	return instance.leaderPolicy.primary(n, instance.replicaCount)
 }
 
 // Is the sequence number between watermarks?
@@ -549,6 +596,10 @@ func (instance *pbftCore) nullRequestHandler() {
 
 func (instance *pbftCore) recvMsg(msg *Message, senderID uint64) (interface{}, error) {
 
+	if senderID >= uint64(instance.N) {
+		return nil, fmt.Errorf("Sender ID (%v) is not part of the validator set of %d replicas", senderID, instance.N)
+	}
+
 	if req := msg.GetRequest(); req != nil {
 		if senderID != req.ReplicaId {
 			return nil, fmt.Errorf("Sender ID included in request message (%v) doesn't match ID corresponding to the receiving stream (%v)", req.ReplicaId, senderID)
@@ -641,6 +692,12 @@ func (instance *pbftCore) sendPrePrepare(req *Request, digest string) {
 		return
 	}
 
+	if instance.pipelineDepth > 0 && n > instance.lastExec+instance.pipelineDepth {
+		logger.Debugf("Replica %d is primary, not sending pre-prepare for request %s because %d requests are in flight", instance.id, digest, instance.pipelineDepth)
+		instance.pipelineFull = true
+		return
+	}
+
 	if n > instance.viewChangeSeqNo {
 		logger.Info("Primary %d about to switch to next primary, not sending pre-prepare with seqno=%d", instance.id, n)
 		return
@@ -660,6 +717,7 @@ func (instance *pbftCore) sendPrePrepare(req *Request, digest string) {
 	cert.prePrepare = preprep
 	cert.digest = digest
 	instance.persistQSet()
+	instance.logMessage(&Message{&Message_PrePrepare{preprep}})
 
 	instance.innerBroadcast(&Message{&Message_PrePrepare{preprep}})
 	instance.maybeSendCommit(digest, instance.view, n)
@@ -734,12 +792,17 @@ func (instance *pbftCore) recvPrePrepare(preprep *PrePrepare) error {
 	cert := instance.getCert(preprep.View, preprep.SequenceNumber)
 	if cert.digest != "" && cert.digest != preprep.RequestDigest {
 		logger.Warningf("Pre-prepare found for same view/seqNo but different digest: received %s, stored %s", preprep.RequestDigest, cert.digest)
+		if cert.prePrepare != nil && cert.prePrepare.ReplicaId == preprep.ReplicaId {
+			instance.reportMisbehavior(pb.Evidence_CONFLICTING_PRE_PREPARE, preprep.ReplicaId, preprep.View, preprep.SequenceNumber,
+				&Message{&Message_PrePrepare{cert.prePrepare}}, &Message{&Message_PrePrepare{preprep}})
+		}
 		instance.sendViewChange()
 		return nil
 	}
 
 	cert.prePrepare = preprep
 	cert.digest = preprep.RequestDigest
+	instance.logMessage(&Message{&Message_PrePrepare{preprep}})
 
 	// Store the request if, for whatever reason, haven't received it from an earlier broadcast.
 	if _, ok := instance.reqStore[preprep.RequestDigest]; !ok && preprep.RequestDigest != "" {
@@ -806,12 +869,17 @@ func (instance *pbftCore) recvPrepare(prep *Prepare) error {
 
 	for _, prevPrep := range cert.prepare {
 		if prevPrep.ReplicaId == prep.ReplicaId {
+			if prevPrep.RequestDigest != prep.RequestDigest {
+				instance.reportMisbehavior(pb.Evidence_CONFLICTING_PREPARE, prep.ReplicaId, prep.View, prep.SequenceNumber,
+					&Message{&Message_Prepare{prevPrep}}, &Message{&Message_Prepare{prep}})
+			}
 			logger.Warningf("Ignoring duplicate prepare from %d", prep.ReplicaId)
 			return nil
 		}
 	}
 	cert.prepare = append(cert.prepare, prep)
 	instance.persistPSet()
+	instance.logMessage(&Message{&Message_Prepare{prep}})
 
 	return instance.maybeSendCommit(prep.RequestDigest, prep.View, prep.SequenceNumber)
 }
@@ -856,15 +924,22 @@ func (instance *pbftCore) recvCommit(commit *Commit) error {
 	cert := instance.getCert(commit.View, commit.SequenceNumber)
 	for _, prevCommit := range cert.commit {
 		if prevCommit.ReplicaId == commit.ReplicaId {
+			if prevCommit.RequestDigest != commit.RequestDigest {
+				instance.reportMisbehavior(pb.Evidence_CONFLICTING_COMMIT, commit.ReplicaId, commit.View, commit.SequenceNumber,
+					&Message{&Message_Commit{prevCommit}}, &Message{&Message_Commit{commit}})
+			}
 			logger.Warningf("Ignoring duplicate commit from %d", commit.ReplicaId)
 			return nil
 		}
 	}
 	cert.commit = append(cert.commit, commit)
+	instance.logMessage(&Message{&Message_Commit{commit}})
 
 	if instance.committed(commit.RequestDigest, commit.View, commit.SequenceNumber) {
+		instance.requestCommitted()
 		instance.stopTimer()
 		instance.lastNewViewTimeout = instance.newViewTimeout
+		instance.updateTimeoutMetrics()
 		delete(instance.outstandingReqs, commit.RequestDigest)
 
 		instance.executeOutstanding()
@@ -924,6 +999,10 @@ func (instance *pbftCore) retryStateTransfer(optional *stateUpdateTarget) {
 
 }
 
+// executeOutstanding executes the request following lastExec once it is
+// committed. With several pre-prepares in flight requests may commit out of
+// order, those wait for their predecessors and are executed in turn as each
+// execution completes.
 func (instance *pbftCore) executeOutstanding() {
 	if instance.currentExec != nil {
 		logger.Debugf("Replica %d not attempting to executeOutstanding because it is currently executing %d", instance.id, *instance.currentExec)
@@ -1001,6 +1080,11 @@ func (instance *pbftCore) Checkpoint(seqNo uint64, id []byte) {
 	instance.chkpts[seqNo] = idAsString
 
 	instance.persistCheckpoint(seqNo, id)
+	if instance.wal != nil {
+		if err := instance.wal.checkpoint(); err != nil {
+			logger.Errorf("Replica %d could not sync the write-ahead log: %s", instance.id, err)
+		}
+	}
 	instance.recvCheckpoint(chkpt)
 	instance.innerBroadcast(&Message{&Message_Checkpoint{chkpt}})
 }
@@ -1021,6 +1105,12 @@ func (instance *pbftCore) execDoneSync() {
 	instance.currentExec = nil
 
 	instance.executeOutstanding()
+
+	// the execution made room in the pipeline for the held back requests
+	if instance.pipelineFull {
+		instance.pipelineFull = false
+		instance.resubmitRequests()
+	}
 }
 
 func (instance *pbftCore) moveWatermarks(n uint64) {
@@ -1065,6 +1155,8 @@ func (instance *pbftCore) moveWatermarks(n uint64) {
 	}
 
 	instance.h = h
+	instance.compactMessageLog()
+	instance.collectGarbage()
 
 	logger.Debugf("Replica %d updated low watermark to %d",
 		instance.id, instance.h)
@@ -1224,6 +1316,11 @@ func (instance *pbftCore) recvCheckpoint(chkpt *Checkpoint) events.Event {
 
 	instance.moveWatermarks(chkpt.SequenceNumber)
 
+	if instance.applyReconfiguration() {
+		// hand off to the new validator set, whose primary is elected by the view change
+		return instance.sendViewChange()
+	}
+
 	return instance.processNewView()
 }
 
@@ -1348,12 +1445,16 @@ func (instance *pbftCore) startTimerIfOutstandingRequests() {
 func (instance *pbftCore) softStartTimer(timeout time.Duration, reason string) {
 	logger.Debugf("Replica %d soft starting new view timer for %s: %s", instance.id, timeout, reason)
 	instance.newViewTimerReason = reason
+	if !instance.timerActive {
+		instance.timerStarted()
+	}
 	instance.timerActive = true
 	instance.newViewTimer.SoftReset(timeout, viewChangeTimerEvent{})
 }
 
 func (instance *pbftCore) startTimer(timeout time.Duration, reason string) {
 	logger.Debugf("Replica %d starting new view timer for %s: %s", instance.id, timeout, reason)
+	instance.requestTimeouts.timerSet = time.Time{}
 	instance.timerActive = true
 	instance.newViewTimer.Reset(timeout, viewChangeTimerEvent{})
 }
@@ -1361,6 +1462,7 @@ func (instance *pbftCore) startTimer(timeout time.Duration, reason string) {
 func (instance *pbftCore) stopTimer() {
 	logger.Debugf("Replica %d stopping a running new view timer", instance.id)
 	instance.timerActive = false
+	instance.requestTimeouts.timerSet = time.Time{}
 	instance.newViewTimer.Stop()
 }
 
@@ -1769,3 +1769,55 @@ func TestStateNetworkMovesOnDuringSlowStateTransfer(t *testing.T) {
 		t.Fatalf("Expected watermark movement to %d because of state transfer, but low watermark is %d", seqNo, instance.h)
 	}
 }
+
+func TestPipelineDepth(t *testing.T) {
+	prePrepares := 0
+	mock := &omniProto{
+		validateImpl: func(msg []byte) error {
+			return nil
+		},
+		broadcastImpl: func(msgPayload []byte) {
+			msg := &Message{}
+			if err := proto.Unmarshal(msgPayload, msg); err != nil {
+				t.Fatalf("Failed to unmarshal broadcast message: %s", err)
+			}
+			if msg.GetPrePrepare() != nil {
+				prePrepares++
+			}
+		},
+	}
+	config := loadConfig()
+	config.Set("general.pipelinedepth", 2)
+	instance := newPbftCore(0, config, mock, &inertTimerFactory{})
+	defer instance.close()
+
+	for i := int64(1); i <= 3; i++ {
+		events.SendEvent(instance, createPbftRequestWithChainTx(i, 0))
+	}
+	if prePrepares != 2 || !instance.pipelineFull {
+		t.Fatalf("Expected the primary to hold back requests past a pipeline depth of 2, got %d pre-prepares", prePrepares)
+	}
+}
+
+func TestNetworkPipelined(t *testing.T) {
+	validatorCount := 4
+	config := loadConfig()
+	config.Set("general.pipelinedepth", 1)
+	net := makePBFTNetwork(validatorCount, config)
+	defer net.stop()
+
+	for i := int64(1); i <= 3; i++ {
+		net.pbftEndpoints[0].manager.Queue() <- createPbftRequestWithChainTx(i, 0)
+	}
+
+	err := net.process()
+	if err != nil {
+		t.Fatalf("Processing failed: %s", err)
+	}
+
+	for _, pep := range net.pbftEndpoints {
+		if pep.sc.executions != 3 || pep.sc.lastSeqNo != 3 {
+			t.Errorf("Instance %d expected to execute the 3 requests held back by the pipeline, executed %d up to seqNo %d", pep.id, pep.sc.executions, pep.sc.lastSeqNo)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The requests and checkpoints persisted by the replica are deleted as the
+// watermarks move, but only those the replica still tracks: the requests of a
+// replica which restarted, transferred state or changed view may remain in the
+// database forever. Each time the low watermark moves, the persisted requests
+// the replica no longer tracks, and the checkpoints below the low watermark,
+// are deleted, and every compactInterval times the database is compacted to
+// reclaim the space of the deleted keys.
+
+// stateCompactor is implemented by the consumers whose persisted state can be
+// compacted
+type stateCompactor interface {
+	CompactState() error
+}
+
+// GCMetrics are the statistics of the garbage collection of the consensus
+// state persisted by a replica
+type GCMetrics struct {
+	Collections    uint64 `json:"collections"`
+	Compactions    uint64 `json:"compactions"`
+	DeletedKeys    uint64 `json:"deletedKeys"`
+	ReclaimedBytes uint64 `json:"reclaimedBytes"` // size of the keys and values deleted
+}
+
+// ReplicaMetrics are the metrics of a PBFT replica
+type ReplicaMetrics struct {
+	TimeoutMetrics
+	GC GCMetrics `json:"gc"`
+}
+
+type stateGC struct {
+	sync.Mutex
+	compactInterval uint64 // collections between compactions, 0 to never compact
+	deleted         uint64 // keys deleted since the last compaction
+	metrics         GCMetrics
+}
+
+// collectGarbage deletes the persisted requests which are no longer tracked,
+// and the checkpoints below the low watermark
+func (instance *pbftCore) collectGarbage() {
+	var keys, bytes uint64
+	del := func(key string, value []byte) {
+		instance.consumer.DelState(key)
+		keys++
+		bytes += uint64(len(key) + len(value))
+	}
+
+	if reqs, err := instance.consumer.ReadStateSet("req."); err == nil {
+		for key, raw := range reqs {
+			if _, ok := instance.reqStore[key[len("req."):]]; !ok {
+				del(key, raw)
+			}
+		}
+	}
+	if chkpts, err := instance.consumer.ReadStateSet("chkpt."); err == nil {
+		for key, id := range chkpts {
+			var seqNo uint64
+			if _, err = fmt.Sscanf(key, "chkpt.%d", &seqNo); err == nil && seqNo < instance.h {
+				del(key, id)
+			}
+		}
+	}
+	if keys > 0 {
+		logger.Debugf("Replica %d deleted %d keys, %d bytes, of persisted state below low watermark %d", instance.id, keys, bytes, instance.h)
+	}
+
+	instance.gc.Lock()
+	instance.gc.metrics.Collections++
+	instance.gc.metrics.DeletedKeys += keys
+	instance.gc.metrics.ReclaimedBytes += bytes
+	instance.gc.deleted += keys
+	compact := instance.gc.compactInterval > 0 && instance.gc.metrics.Collections%instance.gc.compactInterval == 0 && instance.gc.deleted > 0
+	instance.gc.Unlock()
+
+	if !compact {
+		return
+	}
+	compactor, ok := instance.consumer.(stateCompactor)
+	if !ok {
+		return
+	}
+	if err := compactor.CompactState(); err != nil {
+		logger.Warningf("Replica %d could not compact persisted state: %s", instance.id, err)
+		return
+	}
+	logger.Debugf("Replica %d compacted persisted state", instance.id)
+
+	instance.gc.Lock()
+	instance.gc.metrics.Compactions++
+	instance.gc.deleted = 0
+	instance.gc.Unlock()
+}
+
+// getGCMetrics may be called from any go routine
+func (instance *pbftCore) getGCMetrics() GCMetrics {
+	instance.gc.Lock()
+	defer instance.gc.Unlock()
+	return instance.gc.metrics
+}
+
+// getMetrics may be called from any go routine
+func (instance *pbftCore) getMetrics() ReplicaMetrics {
+	return ReplicaMetrics{
+		TimeoutMetrics: instance.getTimeoutMetrics(),
+		GC:             instance.getGCMetrics(),
+	}
+}
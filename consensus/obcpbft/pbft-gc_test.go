@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type compactingStack struct {
+	*omniProto
+	compactions int
+}
+
+func (stack *compactingStack) CompactState() error {
+	stack.compactions++
+	return nil
+}
+
+func TestGarbageCollection(t *testing.T) {
+	persist := make(map[string][]byte)
+	stack := &compactingStack{omniProto: &omniProto{
+		StoreStateImpl: func(key string, value []byte) error {
+			persist[key] = value
+			return nil
+		},
+		DelStateImpl: func(key string) {
+			delete(persist, key)
+		},
+		ReadStateImpl: func(key string) ([]byte, error) {
+			if val, ok := persist[key]; ok {
+				return val, nil
+			}
+			return nil, fmt.Errorf("key not found")
+		},
+		ReadStateSetImpl: func(prefix string) (map[string][]byte, error) {
+			r := make(map[string][]byte)
+			for k, v := range persist {
+				if strings.HasPrefix(k, prefix) {
+					r[k] = v
+				}
+			}
+			return r, nil
+		},
+	}}
+	config := loadConfig()
+	config.Set("general.compactinterval", 2)
+	instance := newPbftCore(1, config, stack, &inertTimerFactory{})
+	defer instance.close()
+
+	// a request the replica no longer tracks, e.g. from before a restart
+	persist["req.stale"] = []byte("stale request")
+	persist["chkpt.5"] = []byte("stale checkpoint")
+	req := createPbftRequestWithChainTx(1, 0)
+	digest := hashReq(req)
+	instance.reqStore[digest] = req
+	instance.persistRequest(digest)
+
+	instance.moveWatermarks(instance.K)
+	if _, ok := persist["req.stale"]; ok {
+		t.Fatalf("Expected the untracked request to be deleted")
+	}
+	if _, ok := persist["chkpt.5"]; ok {
+		t.Fatalf("Expected the checkpoint below the low watermark to be deleted")
+	}
+	if _, ok := persist["req."+digest]; !ok {
+		t.Fatalf("Expected the tracked request to be kept")
+	}
+	metrics := instance.getMetrics().GC
+	if metrics.DeletedKeys != 2 || metrics.ReclaimedBytes != uint64(len("req.stale")+len("stale request")+len("chkpt.5")+len("stale checkpoint")) {
+		t.Fatalf("Expected 2 keys to be reported deleted, got %+v", metrics)
+	}
+
+	// the state is compacted every other collection, the first one ran when
+	// the state was restored
+	if metrics.Collections != 2 || metrics.Compactions != 1 || stack.compactions != 1 {
+		t.Fatalf("Expected one compaction after two collections, got %+v, %d compactions", metrics, stack.compactions)
+	}
+	instance.moveWatermarks(2 * instance.K)
+	instance.moveWatermarks(3 * instance.K)
+	if metrics = instance.getGCMetrics(); metrics.Collections != 4 || stack.compactions != 1 {
+		t.Fatalf("Expected no compaction without deleted keys, got %+v, %d compactions", metrics, stack.compactions)
+	}
+}
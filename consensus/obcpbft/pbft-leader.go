@@ -0,0 +1,162 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// The leader policy maps a view to its primary. Every correct replica must
+// map a view to the same primary, or they accept pre-prepares and new-views
+// from different replicas and the view never makes progress, so a policy may
+// only depend on the view, the number of replicas and its configuration,
+// which must be the same on all replicas. Performance or faults observed by
+// a replica alone are not agreed upon, and are left to the operators to act
+// upon by configuring the weights or the blacklist.
+
+const (
+	leaderRoundRobin = "roundrobin" // view mod N
+	leaderWeighted   = "weighted"   // replicas lead views in proportion to their weight
+	leaderBlacklist  = "blacklist"  // round robin over the replicas not blacklisted
+)
+
+type leaderPolicy interface {
+	// primary returns the primary of view among n replicas
+	primary(view uint64, n int) uint64
+}
+
+type roundRobinPolicy struct{}
+
+func (roundRobinPolicy) primary(view uint64, n int) uint64 {
+	return view % uint64(n)
+}
+
+// weightedPolicy makes each replica primary for as many views of every round
+// as its weight, interleaving them with the views of the other replicas as a
+// smooth weighted round robin does. Replicas without a configured weight,
+// such as replicas added by a reconfiguration, weigh 1.
+type weightedPolicy struct {
+	weights []uint64
+}
+
+func (p *weightedPolicy) primary(view uint64, n int) uint64 {
+	weights := make([]int64, n)
+	var total int64
+	for i := range weights {
+		weights[i] = 1
+		if i < len(p.weights) {
+			weights[i] = int64(p.weights[i])
+		}
+		total += weights[i]
+	}
+	if total == 0 {
+		return roundRobinPolicy{}.primary(view, n)
+	}
+
+	current := make([]int64, n)
+	best := 0
+	for step := int64(0); step <= int64(view%uint64(total)); step++ {
+		best = -1
+		for i := range current {
+			current[i] += weights[i]
+			if best < 0 || current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+	}
+	return uint64(best)
+}
+
+// blacklistPolicy rotates the primary over the replicas which are not
+// blacklisted. If all replicas are, it falls back to round robin.
+type blacklistPolicy struct {
+	blacklist map[uint64]bool
+}
+
+func (p *blacklistPolicy) primary(view uint64, n int) uint64 {
+	var eligible []uint64
+	for id := uint64(0); id < uint64(n); id++ {
+		if !p.blacklist[id] {
+			eligible = append(eligible, id)
+		}
+	}
+	if len(eligible) == 0 {
+		return roundRobinPolicy{}.primary(view, n)
+	}
+	return eligible[view%uint64(len(eligible))]
+}
+
+// parseReplicaList parses a comma separated list of non-negative integers
+func parseReplicaList(list string) ([]uint64, error) {
+	var values []uint64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// newLeaderPolicy returns the leader policy configured for n replicas
+func newLeaderPolicy(config *viper.Viper, n int) leaderPolicy {
+	policy := strings.ToLower(config.GetString("general.leader.policy"))
+	switch policy {
+	case leaderRoundRobin, "":
+		return roundRobinPolicy{}
+	case leaderWeighted:
+		weights, err := parseReplicaList(config.GetString("general.leader.weights"))
+		if err != nil {
+			panic(fmt.Errorf("Invalid leader weights: %s", err))
+		}
+		if len(weights) > n {
+			logger.Warningf("%d leader weights configured for %d replicas, ignoring the extra ones until the network grows", len(weights), n)
+		}
+		return &weightedPolicy{weights: weights}
+	case leaderBlacklist:
+		ids, err := parseReplicaList(config.GetString("general.leader.blacklist"))
+		if err != nil {
+			panic(fmt.Errorf("Invalid leader blacklist: %s", err))
+		}
+		p := &blacklistPolicy{blacklist: make(map[uint64]bool)}
+		for _, id := range ids {
+			p.blacklist[id] = true
+		}
+		blacklisted := 0
+		for id := range p.blacklist {
+			if id < uint64(n) {
+				blacklisted++
+			}
+		}
+		if blacklisted == n {
+			logger.Warningf("All %d replicas are blacklisted from leading, rotating the primary over all of them", n)
+		}
+		return p
+	default:
+		panic(fmt.Errorf("Invalid leader policy %s, expected %s, %s or %s", policy, leaderRoundRobin, leaderWeighted, leaderBlacklist))
+	}
+}
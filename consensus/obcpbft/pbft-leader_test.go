@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"reflect"
+	"testing"
+)
+
+func primaries(policy leaderPolicy, views int, n int) []uint64 {
+	var ids []uint64
+	for v := 0; v < views; v++ {
+		ids = append(ids, policy.primary(uint64(v), n))
+	}
+	return ids
+}
+
+func TestLeaderPolicies(t *testing.T) {
+	config := loadConfig()
+	if ids := primaries(newLeaderPolicy(config, 4), 6, 4); !reflect.DeepEqual(ids, []uint64{0, 1, 2, 3, 0, 1}) {
+		t.Fatalf("Expected round robin by default, got %v", ids)
+	}
+
+	config.Set("general.leader.policy", "weighted")
+	config.Set("general.leader.weights", "3, 1, 0")
+	ids := primaries(newLeaderPolicy(config, 4), 10, 4)
+	if expected := []uint64{0, 1, 0, 3, 0, 0, 1, 0, 3, 0}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Expected weighted primaries %v, got %v", expected, ids)
+	}
+
+	config.Set("general.leader.policy", "blacklist")
+	config.Set("general.leader.blacklist", "1,3")
+	policy := newLeaderPolicy(config, 4)
+	if ids := primaries(policy, 4, 4); !reflect.DeepEqual(ids, []uint64{0, 2, 0, 2}) {
+		t.Fatalf("Expected the blacklisted replicas to be skipped, got %v", ids)
+	}
+	if ids := primaries(policy, 4, 5); !reflect.DeepEqual(ids, []uint64{0, 2, 4, 0}) {
+		t.Fatalf("Expected the replica added by a reconfiguration to lead, got %v", ids)
+	}
+	config.Set("general.leader.blacklist", "0,1,2,3")
+	if ids := primaries(newLeaderPolicy(config, 4), 4, 4); !reflect.DeepEqual(ids, []uint64{0, 1, 2, 3}) {
+		t.Fatalf("Expected round robin when all the replicas are blacklisted, got %v", ids)
+	}
+
+	instance := newPbftCore(0, config, &omniProto{}, &inertTimerFactory{})
+	defer instance.close()
+	if instance.primary(1) != 1 {
+		t.Fatalf("Expected the configured policy to choose the primary, got %d", instance.primary(1))
+	}
+}
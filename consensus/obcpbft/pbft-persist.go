@@ -20,7 +20,7 @@ import (
 	"encoding/base64"
 	"fmt"
 
-	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/util"
 )
 
 func (instance *pbftCore) persistQSet() {
@@ -44,7 +44,7 @@ func (instance *pbftCore) persistPSet() {
 }
 
 func (instance *pbftCore) persistPQSet(key string, set []*ViewChange_PQ) {
-	raw, err := proto.Marshal(&PQset{set})
+	raw, err := util.Encode(util.ProtoCodec, &PQset{set})
 	if err != nil {
 		logger.Warningf("Replica %d could not persist pqset: %s", instance.id, err)
 		return
@@ -59,7 +59,7 @@ func (instance *pbftCore) restorePQSet(key string) []*ViewChange_PQ {
 		return nil
 	}
 	val := &PQset{}
-	err = proto.Unmarshal(raw, val)
+	err = util.Decode(raw, val, util.ProtoCodec)
 	if err != nil {
 		logger.Errorf("Replica %d could not unmarshal %s - local state is damaged: %s", instance.id, key, err)
 		return nil
@@ -69,7 +69,7 @@ func (instance *pbftCore) restorePQSet(key string) []*ViewChange_PQ {
 
 func (instance *pbftCore) persistRequest(digest string) {
 	req := instance.reqStore[digest]
-	raw, err := proto.Marshal(req)
+	raw, err := util.Encode(util.ProtoCodec, req)
 	if err != nil {
 		logger.Warningf("Replica %d could not persist request: %s", instance.id, err)
 		return
@@ -128,7 +128,7 @@ func (instance *pbftCore) restoreState() {
 	if err == nil {
 		for k, v := range reqs {
 			req := &Request{}
-			err = proto.Unmarshal(v, req)
+			err = util.Decode(v, req, util.ProtoCodec)
 			if err != nil {
 				logger.Warningf("Replica %d could not restore request %s", instance.id, k)
 			} else {
@@ -139,6 +139,8 @@ func (instance *pbftCore) restoreState() {
 		logger.Warningf("Replica %d could not restore reqStore: %s", instance.id, err)
 	}
 
+	instance.restoreMessageLog()
+
 	chkpts, err := instance.consumer.ReadStateSet("chkpt.")
 	if err == nil {
 		highSeq := uint64(0)
@@ -161,6 +163,7 @@ func (instance *pbftCore) restoreState() {
 	}
 
 	instance.restoreLastSeqNo()
+	instance.restoreReconfiguration()
 
 	logger.Infof("Replica %d restored state: view: %d, seqNo: %d, pset: %d, qset: %d, reqs: %d, chkpts: %d",
 		instance.id, instance.view, instance.seqNo, len(instance.pset), len(instance.qset), len(instance.reqStore), len(instance.chkpts))
@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// The request timeout adapts to the commit latency observed by the replica:
+// it doubles on every view change, so that a burst of load slower to commit
+// than the timeout does not cause view change after view change, and decays
+// back on every commit towards the configured request timeout, or
+// latencyMultiplier times the average commit latency if higher.
+const latencyMultiplier = 4
+
+type adaptiveTimeout struct {
+	enabled  bool
+	min      time.Duration // configured request timeout
+	max      time.Duration // bound of the timeout
+	decay    float64       // factor applied to the timeout on every commit
+	latency  time.Duration // moving average of the commit latency
+	timerSet time.Time     // when the new view timer was started
+}
+
+// TimeoutMetrics are the current timeouts of a PBFT replica, in milliseconds
+type TimeoutMetrics struct {
+	RequestTimeout     float64 `json:"requestTimeout"`
+	NewViewTimeout     float64 `json:"newViewTimeout"`
+	NullRequestTimeout float64 `json:"nullRequestTimeout"`
+	// CommitLatency is the moving average of the time a request takes to
+	// commit, measured from when the replica starts its new view timer
+	CommitLatency float64 `json:"commitLatency"`
+}
+
+// timeoutMetrics holds the metrics of the replica for reading them outside of
+// the PBFT thread
+type timeoutMetrics struct {
+	sync.Mutex
+	metrics TimeoutMetrics
+}
+
+func newAdaptiveTimeout(config *viper.Viper, requestTimeout time.Duration) adaptiveTimeout {
+	at := adaptiveTimeout{min: requestTimeout, max: requestTimeout, decay: 1}
+	if max := config.GetString("general.timeout.requestmax"); max != "" {
+		var err error
+		if at.max, err = time.ParseDuration(max); err != nil {
+			panic(fmt.Errorf("Cannot parse request timeout bound: %s", err))
+		}
+	}
+	if config.IsSet("general.timeout.requestdecay") {
+		at.decay = config.GetFloat64("general.timeout.requestdecay")
+	}
+	if at.decay <= 0 || at.decay > 1 {
+		panic(fmt.Errorf("Request timeout decay must be in (0, 1], got %v", at.decay))
+	}
+	at.enabled = at.max > at.min
+	return at
+}
+
+// timerStarted records when the new view timer was started for the oldest
+// outstanding request
+func (instance *pbftCore) timerStarted() {
+	instance.requestTimeouts.timerSet = time.Now()
+}
+
+// requestCommitted adapts the request timeout to the latency of a commit
+// which stopped the new view timer
+func (instance *pbftCore) requestCommitted() {
+	at := &instance.requestTimeouts
+	if !at.enabled || at.timerSet.IsZero() {
+		return
+	}
+	latency := time.Since(at.timerSet)
+	at.timerSet = time.Time{}
+	if at.latency == 0 {
+		at.latency = latency
+	} else {
+		at.latency = (7*at.latency + latency) / 8
+	}
+
+	timeout := time.Duration(float64(instance.requestTimeout) * at.decay)
+	if target := latencyMultiplier * at.latency; timeout < target {
+		timeout = target
+	}
+	instance.setRequestTimeout(timeout)
+}
+
+// viewChanged backs off the request timeout on a view change
+func (instance *pbftCore) viewChanged() {
+	if !instance.requestTimeouts.enabled {
+		return
+	}
+	instance.setRequestTimeout(2 * instance.requestTimeout)
+	logger.Infof("Replica %d request timeout is now %v", instance.id, instance.requestTimeout)
+}
+
+func (instance *pbftCore) setRequestTimeout(timeout time.Duration) {
+	at := &instance.requestTimeouts
+	if timeout < at.min {
+		timeout = at.min
+	}
+	if timeout > at.max {
+		timeout = at.max
+	}
+	instance.requestTimeout = timeout
+	instance.updateTimeoutMetrics()
+}
+
+func (instance *pbftCore) updateTimeoutMetrics() {
+	instance.timeoutMetrics.Lock()
+	defer instance.timeoutMetrics.Unlock()
+	instance.timeoutMetrics.metrics = TimeoutMetrics{
+		RequestTimeout:     milliseconds(instance.requestTimeout),
+		NewViewTimeout:     milliseconds(instance.lastNewViewTimeout),
+		NullRequestTimeout: milliseconds(instance.nullRequestTimeout),
+		CommitLatency:      milliseconds(instance.requestTimeouts.latency),
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// getTimeoutMetrics may be called from any go routine
+func (instance *pbftCore) getTimeoutMetrics() TimeoutMetrics {
+	instance.timeoutMetrics.Lock()
+	defer instance.timeoutMetrics.Unlock()
+	return instance.timeoutMetrics.metrics
+}
@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveRequestTimeout(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.timeout.requestmax", "6s")
+	config.Set("general.timeout.requestdecay", 0.5)
+	instance := &pbftCore{requestTimeout: 2 * time.Second}
+	instance.requestTimeouts = newAdaptiveTimeout(config, instance.requestTimeout)
+
+	// the timeout doubles on view changes, up to its bound
+	instance.viewChanged()
+	if instance.requestTimeout != 4*time.Second {
+		t.Fatalf("Expected a request timeout of 4s after a view change, got %v", instance.requestTimeout)
+	}
+	instance.viewChanged()
+	if instance.requestTimeout != 6*time.Second {
+		t.Fatalf("Expected the request timeout to be bound to 6s, got %v", instance.requestTimeout)
+	}
+	if metrics := instance.getTimeoutMetrics(); metrics.RequestTimeout != 6000 {
+		t.Fatalf("Expected the metrics to report a request timeout of 6000ms, got %v", metrics.RequestTimeout)
+	}
+
+	// and decays on commits, down to the configured timeout
+	instance.requestTimeouts.timerSet = time.Now()
+	instance.requestCommitted()
+	if instance.requestTimeout != 3*time.Second {
+		t.Fatalf("Expected a request timeout of 3s after a commit, got %v", instance.requestTimeout)
+	}
+	instance.requestTimeouts.timerSet = time.Now()
+	instance.requestCommitted()
+	if instance.requestTimeout != 2*time.Second {
+		t.Fatalf("Expected the request timeout to decay to 2s, got %v", instance.requestTimeout)
+	}
+
+	// unless commits are slower
+	instance.requestTimeouts.latency = time.Second
+	instance.requestTimeouts.timerSet = time.Now().Add(-time.Second)
+	instance.requestCommitted()
+	if instance.requestTimeout < latencyMultiplier*time.Second || instance.requestTimeout >= 6*time.Second {
+		t.Fatalf("Expected a request timeout of about %v after slow commits, got %v", latencyMultiplier*time.Second, instance.requestTimeout)
+	}
+	if metrics := instance.getTimeoutMetrics(); metrics.CommitLatency < 1000 {
+		t.Fatalf("Expected the metrics to report a commit latency of at least 1000ms, got %v", metrics.CommitLatency)
+	}
+
+	// the timeout is fixed without a bound above it
+	config.Set("general.timeout.requestmax", "2s")
+	instance = &pbftCore{requestTimeout: 2 * time.Second}
+	instance.requestTimeouts = newAdaptiveTimeout(config, instance.requestTimeout)
+	instance.viewChanged()
+	if instance.requestTimeout != 2*time.Second {
+		t.Fatalf("Expected a fixed request timeout, got %v", instance.requestTimeout)
+	}
+}
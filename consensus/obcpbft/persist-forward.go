@@ -39,3 +39,11 @@ func (p persistForward) StoreState(key string, val []byte) error {
 func (p persistForward) DelState(key string) {
 	p.persistor.DelState(key)
 }
+
+// CompactState compacts the persisted state, if the persistor supports it
+func (p persistForward) CompactState() error {
+	if compactor, ok := p.persistor.(stateCompactor); ok {
+		return compactor.CompactState()
+	}
+	return nil
+}
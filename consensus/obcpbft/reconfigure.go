@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// A reconfiguration transaction changes the validator set, N and f, of the
+// network. Replicas are identified by their position in the validator set, so
+// growing the set to N adds the replicas up to N-1 and shrinking it removes the
+// replicas from N on. The transaction is ordered like any other, and applied
+// by every replica once the checkpoint following its execution is stable, at
+// which point the replicas hand off to the new validator set with a view
+// change. Only the transactions signed by the enrollment certificate of a
+// configured admin, or co-signed by a quorum of the current validators, are
+// applied.
+
+// reconfiguration is a change of validator set ordered at seqNo
+type reconfiguration struct {
+	seqNo      uint64
+	validators *pb.ValidatorSet
+}
+
+// reconfigurable is implemented by the consumers which need to know about the
+// validator set, it is invoked on the PBFT thread once a reconfiguration has
+// been applied
+type reconfigurable interface {
+	reconfigured(N int, f int)
+}
+
+func validateValidatorSet(validators *pb.ValidatorSet) error {
+	if validators.N < 3*validators.F+1 {
+		return fmt.Errorf("need at least %d replicas to tolerate %d byzantine faults, but only %d replicas requested", 3*validators.F+1, validators.F, validators.N)
+	}
+	return nil
+}
+
+// reconfigurationSignedBytes returns the bytes the signer and the co-signers
+// of the reconfiguration transaction tx sign, as the security layer of the
+// peer does: tx without its signatures and its trace context
+func reconfigurationSignedBytes(tx *pb.Transaction) ([]byte, error) {
+	unsigned := *tx
+	unsigned.Signature = nil
+	unsigned.TraceContext = nil
+	if tx.MultiSignature != nil {
+		unsigned.MultiSignature = &pb.MultiSignature{Policy: tx.MultiSignature.Policy}
+	}
+	return proto.Marshal(&unsigned)
+}
+
+// authorizeReconfiguration checks that the reconfiguration transaction tx is
+// signed by the enrollment certificate of a configured admin, or co-signed by
+// an intersection quorum of the current validators
+func (instance *pbftCore) authorizeReconfiguration(tx *pb.Transaction) error {
+	raw, err := reconfigurationSignedBytes(tx)
+	if err != nil {
+		return err
+	}
+
+	if tx.Cert != nil && tx.Signature != nil && len(instance.reconfigAdmins) > 0 {
+		// the certificate hashes of the admins are only computed with
+		// security enabled
+		if primitives.GetDefaultHash() == nil {
+			return errors.New("admin signatures require security enabled")
+		}
+		if instance.reconfigAdmins[hex.EncodeToString(primitives.Hash(tx.Cert))] {
+			cert, err := primitives.DERToX509Certificate(tx.Cert)
+			if err != nil {
+				return fmt.Errorf("invalid admin certificate: %s", err)
+			}
+			if ok, _ := primitives.ECDSAVerify(cert.PublicKey, raw, tx.Signature); !ok {
+				return errors.New("invalid admin signature")
+			}
+			return nil
+		}
+	}
+
+	signed := make(map[uint64]bool)
+	for _, signature := range tx.MultiSignature.GetSignatures() {
+		for id := uint64(0); id < uint64(instance.N); id++ {
+			if !signed[id] && instance.consumer.verify(id, signature.Signature, raw) == nil {
+				signed[id] = true
+				break
+			}
+		}
+	}
+	if len(signed) < instance.intersectionQuorum() {
+		return fmt.Errorf("signed neither by an admin nor by a quorum of validators, %d of %d validator signatures", len(signed), instance.intersectionQuorum())
+	}
+	return nil
+}
+
+// scheduleReconfiguration records the reconfiguration transaction tx executed
+// at seqNo, to be applied at the next stable checkpoint, if it is authorized.
+// It must be invoked on the PBFT thread, in order of execution.
+func (instance *pbftCore) scheduleReconfiguration(seqNo uint64, tx *pb.Transaction) {
+	if !instance.reconfigurable {
+		logger.Warningf("Replica %d ignoring reconfiguration transaction %s, reconfiguration is disabled", instance.id, tx.Uuid)
+		return
+	}
+	if tx.LedgerID != "" {
+		logger.Warningf("Replica %d ignoring reconfiguration transaction %s for ledger %s, only the default ledger reconfigures consensus", instance.id, tx.Uuid, tx.LedgerID)
+		return
+	}
+	if err := instance.authorizeReconfiguration(tx); err != nil {
+		logger.Warningf("Replica %d ignoring unauthorized reconfiguration transaction %s: %s", instance.id, tx.Uuid, err)
+		return
+	}
+	validators := &pb.ValidatorSet{}
+	if err := proto.Unmarshal(tx.Payload, validators); err != nil {
+		logger.Warningf("Replica %d ignoring reconfiguration transaction %s, could not unmarshal validator set: %s", instance.id, tx.Uuid, err)
+		return
+	}
+	if err := validateValidatorSet(validators); err != nil {
+		logger.Warningf("Replica %d ignoring reconfiguration transaction %s: %s", instance.id, tx.Uuid, err)
+		return
+	}
+	raw, err := proto.Marshal(validators)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist reconfiguration: %s", instance.id, err)
+		return
+	}
+
+	// a later reconfiguration supersedes the pending one
+	if instance.pendingReconfig != nil {
+		instance.consumer.DelState(fmt.Sprintf("reconfig.%d", instance.pendingReconfig.seqNo))
+	}
+	instance.pendingReconfig = &reconfiguration{seqNo: seqNo, validators: validators}
+	instance.consumer.StoreState(fmt.Sprintf("reconfig.%d", seqNo), raw)
+	logger.Infof("Replica %d scheduled reconfiguration to N=%d, f=%d ordered at seqNo %d", instance.id, validators.N, validators.F, seqNo)
+}
+
+// applyReconfiguration applies the pending reconfiguration if it was executed
+// before the low watermark, returning whether it did
+func (instance *pbftCore) applyReconfiguration() bool {
+	if instance.pendingReconfig == nil || instance.pendingReconfig.seqNo > instance.h {
+		return false
+	}
+	instance.reconfigure(instance.pendingReconfig)
+	if consumer, ok := instance.consumer.(reconfigurable); ok {
+		consumer.reconfigured(instance.N, instance.f)
+	}
+	return true
+}
+
+func (instance *pbftCore) reconfigure(reconfig *reconfiguration) {
+	instance.N = int(reconfig.validators.N)
+	instance.f = int(reconfig.validators.F)
+	instance.replicaCount = instance.N
+	instance.pendingReconfig = nil
+
+	raw, err := proto.Marshal(reconfig.validators)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist validator set: %s", instance.id, err)
+	} else {
+		instance.consumer.StoreState("validators", raw)
+	}
+	instance.consumer.DelState(fmt.Sprintf("reconfig.%d", reconfig.seqNo))
+	logger.Infof("Replica %d reconfigured to N=%d, f=%d", instance.id, instance.N, instance.f)
+}
+
+// restoreReconfiguration restores the validator set from the last applied
+// reconfiguration, and the pending one, over the configured N and f
+func (instance *pbftCore) restoreReconfiguration() {
+	if raw, err := instance.consumer.ReadState("validators"); err == nil {
+		validators := &pb.ValidatorSet{}
+		if err = proto.Unmarshal(raw, validators); err != nil {
+			logger.Errorf("Replica %d could not unmarshal validator set - local state is damaged: %s", instance.id, err)
+		} else {
+			instance.N = int(validators.N)
+			instance.f = int(validators.F)
+			instance.replicaCount = instance.N
+			logger.Infof("Replica %d restored reconfigured validator set N=%d, f=%d", instance.id, instance.N, instance.f)
+		}
+	}
+
+	reconfigs, err := instance.consumer.ReadStateSet("reconfig.")
+	if err != nil {
+		return
+	}
+	for key, raw := range reconfigs {
+		reconfig := &reconfiguration{validators: &pb.ValidatorSet{}}
+		if _, err = fmt.Sscanf(key, "reconfig.%d", &reconfig.seqNo); err != nil {
+			logger.Warningf("Replica %d could not restore reconfiguration key %s", instance.id, key)
+			continue
+		}
+		if err = proto.Unmarshal(raw, reconfig.validators); err != nil {
+			logger.Warningf("Replica %d could not restore reconfiguration %s: %s", instance.id, key, err)
+			continue
+		}
+		instance.pendingReconfig = reconfig
+	}
+	// the replica may have stopped after the checkpoint became stable, but
+	// before applying the reconfiguration
+	if instance.pendingReconfig != nil && instance.pendingReconfig.seqNo <= instance.h {
+		instance.reconfigure(instance.pendingReconfig)
+	}
+}
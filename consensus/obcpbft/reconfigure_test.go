@@ -0,0 +1,203 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// validatorSignature is the signature of replica id verified by
+// verifyValidatorSignature
+func validatorSignature(id uint64, message []byte) []byte {
+	return append([]byte(fmt.Sprintf("vp%d:", id)), message...)
+}
+
+func verifyValidatorSignature(senderID uint64, signature []byte, message []byte) error {
+	if !bytes.Equal(signature, validatorSignature(senderID, message)) {
+		return fmt.Errorf("invalid signature of replica %d", senderID)
+	}
+	return nil
+}
+
+// newReconfigureTransaction returns a reconfiguration transaction co-signed
+// by the replicas signers
+func newReconfigureTransaction(t *testing.T, N uint32, f uint32, signers ...uint64) *pb.Transaction {
+	tx, err := pb.NewReconfigureTransaction(&pb.ValidatorSet{N: N, F: f}, fmt.Sprintf("reconfig-%d-%d", N, f))
+	if err != nil {
+		t.Fatalf("Failed to create reconfiguration transaction: %s", err)
+	}
+	tx.MultiSignature = &pb.MultiSignature{Policy: &pb.MultiSignaturePolicy{Threshold: uint32(len(signers))}}
+	raw, err := reconfigurationSignedBytes(tx)
+	if err != nil {
+		t.Fatalf("Failed to marshal reconfiguration transaction: %s", err)
+	}
+	for _, id := range signers {
+		tx.MultiSignature.Signatures = append(tx.MultiSignature.Signatures, &pb.TransactionSignature{Signature: validatorSignature(id, raw)})
+	}
+	return tx
+}
+
+func TestReconfiguration(t *testing.T) {
+	persist := make(map[string][]byte)
+	stack := &omniProto{
+		verifyImpl: verifyValidatorSignature,
+		StoreStateImpl: func(key string, value []byte) error {
+			persist[key] = value
+			return nil
+		},
+		DelStateImpl: func(key string) {
+			delete(persist, key)
+		},
+		ReadStateImpl: func(key string) ([]byte, error) {
+			if val, ok := persist[key]; ok {
+				return val, nil
+			}
+			return nil, fmt.Errorf("key not found")
+		},
+		ReadStateSetImpl: func(prefix string) (map[string][]byte, error) {
+			r := make(map[string][]byte)
+			for k, v := range persist {
+				if strings.HasPrefix(k, prefix) {
+					r[k] = v
+				}
+			}
+			return r, nil
+		},
+	}
+
+	// reconfiguration transactions are ignored unless enabled
+	instance := newPbftCore(1, loadConfig(), stack, &inertTimerFactory{})
+	instance.scheduleReconfiguration(1, newReconfigureTransaction(t, 7, 2, 0, 1, 2))
+	if instance.pendingReconfig != nil {
+		t.Fatalf("Expected the reconfiguration to be ignored when disabled")
+	}
+	instance.close()
+
+	config := loadConfig()
+	config.Set("general.reconfigurable", true)
+	instance = newPbftCore(1, config, stack, &inertTimerFactory{})
+	instance.scheduleReconfiguration(1, newReconfigureTransaction(t, 6, 2, 0, 1, 2))
+	if instance.pendingReconfig != nil {
+		t.Fatalf("Expected the reconfiguration to an invalid validator set to be ignored")
+	}
+	instance.scheduleReconfiguration(3, newReconfigureTransaction(t, 7, 2, 0, 1, 2))
+	if instance.applyReconfiguration() {
+		t.Fatalf("Expected the reconfiguration not to be applied before a stable checkpoint")
+	}
+	instance.close()
+
+	// the pending reconfiguration survives a restart, and is applied once
+	// the checkpoint following it is stable
+	instance = newPbftCore(1, config, stack, &inertTimerFactory{})
+	defer instance.close()
+	if instance.pendingReconfig == nil || instance.pendingReconfig.seqNo != 3 {
+		t.Fatalf("Expected the pending reconfiguration to be restored, got %v", instance.pendingReconfig)
+	}
+	instance.moveWatermarks(instance.K)
+	if !instance.applyReconfiguration() {
+		t.Fatalf("Expected the reconfiguration to be applied at the stable checkpoint")
+	}
+	if instance.N != 7 || instance.f != 2 || instance.replicaCount != 7 {
+		t.Fatalf("Expected N=7, f=2 after the reconfiguration, got N=%d, f=%d, replicaCount=%d", instance.N, instance.f, instance.replicaCount)
+	}
+	if _, err := instance.recvMsg(&Message{&Message_Checkpoint{&Checkpoint{ReplicaId: 6}}}, 6); err != nil {
+		t.Fatalf("Expected messages from the added replica to be accepted: %s", err)
+	}
+	if _, err := instance.recvMsg(&Message{&Message_Checkpoint{&Checkpoint{ReplicaId: 7}}}, 7); err == nil {
+		t.Fatalf("Expected messages from outside the validator set to be rejected")
+	}
+
+	// the validator set is restored over the configured one
+	restored := newPbftCore(1, config, stack, &inertTimerFactory{})
+	defer restored.close()
+	if restored.N != 7 || restored.f != 2 || restored.pendingReconfig != nil {
+		t.Fatalf("Expected the validator set N=7, f=2 to be restored, got N=%d, f=%d", restored.N, restored.f)
+	}
+	validators := &pb.ValidatorSet{}
+	if err := proto.Unmarshal(persist["validators"], validators); err != nil || validators.N != 7 {
+		t.Fatalf("Expected the validator set to be persisted, got %v (%v)", validators, err)
+	}
+}
+
+func TestReconfigurationAuthorization(t *testing.T) {
+	if err := primitives.SetSecurityLevel("SHA3", 256); err != nil {
+		t.Fatalf("Failed to set the security level: %s", err)
+	}
+	adminCert, adminKey, err := primitives.NewSelfSignedCert()
+	if err != nil {
+		t.Fatalf("Failed to create the admin certificate: %s", err)
+	}
+	otherCert, otherKey, err := primitives.NewSelfSignedCert()
+	if err != nil {
+		t.Fatalf("Failed to create a certificate: %s", err)
+	}
+	signedBy := func(cert []byte, key interface{}) *pb.Transaction {
+		tx := newReconfigureTransaction(t, 7, 2)
+		tx.MultiSignature = nil
+		tx.Cert = cert
+		raw, err := reconfigurationSignedBytes(tx)
+		if err != nil {
+			t.Fatalf("Failed to marshal reconfiguration transaction: %s", err)
+		}
+		if tx.Signature, err = primitives.ECDSASign(key, raw); err != nil {
+			t.Fatalf("Failed to sign reconfiguration transaction: %s", err)
+		}
+		return tx
+	}
+	forged := signedBy(adminCert, otherKey)
+	forged.Cert = adminCert
+
+	config := loadConfig()
+	config.Set("general.reconfigadmins", []string{hex.EncodeToString(primitives.Hash(adminCert))})
+	instance := newPbftCore(1, config, &omniProto{verifyImpl: verifyValidatorSignature}, &inertTimerFactory{})
+	defer instance.close()
+
+	tests := []struct {
+		name string
+		tx   *pb.Transaction
+		ok   bool
+	}{
+		{"unsigned", newReconfigureTransaction(t, 7, 2), false},
+		{"signed by 2 validators", newReconfigureTransaction(t, 7, 2, 0, 3), false},
+		{"signed 3 times by a validator", newReconfigureTransaction(t, 7, 2, 2, 2, 2), false},
+		{"signed by 3 validators", newReconfigureTransaction(t, 7, 2, 0, 2, 3), true},
+		{"signed by the admin", signedBy(adminCert, adminKey), true},
+		{"signed by another user", signedBy(otherCert, otherKey), false},
+		{"forged admin signature", forged, false},
+	}
+	for _, test := range tests {
+		if err := instance.authorizeReconfiguration(test.tx); (err == nil) != test.ok {
+			t.Errorf("Expected the reconfiguration %s to be authorized: %t, got %v", test.name, test.ok, err)
+		}
+	}
+
+	// a validator signature does not cover another transaction
+	tx := newReconfigureTransaction(t, 7, 2, 0, 2, 3)
+	tx.Payload = newReconfigureTransaction(t, 4, 1).Payload
+	if err := instance.authorizeReconfiguration(tx); err == nil {
+		t.Errorf("Expected the validator signatures of another validator set to be rejected")
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+	coreconfig "github.com/hyperledger/fabric/core/config"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// reloadableKeys are the keys of config.yaml whose changes a running replica
+// applies when its configuration is reloaded. The timers already running
+// keep the timeout they were started with.
+var reloadableKeys = []string{
+	"general.timeout.batch",
+	"general.timeout.request",
+	"general.timeout.requestmax",
+	"general.timeout.requestdecay",
+	"general.timeout.viewchange",
+	"general.timeout.nullrequest",
+}
+
+// timeoutsReloadedEvent is sent when the timeouts were reloaded, for the
+// replica to apply them in its thread
+type timeoutsReloadedEvent struct {
+	request     time.Duration
+	requestMax  time.Duration
+	decay       float64
+	viewChange  time.Duration
+	nullRequest time.Duration
+	batch       time.Duration
+}
+
+// configReloader reloads the configuration of a replica, it is safe for
+// concurrent use
+type configReloader struct {
+	sync.Mutex
+	config *viper.Viper // the configuration last applied
+}
+
+func newConfigReloader(config *viper.Viper) *configReloader {
+	return &configReloader{config: config}
+}
+
+// reload reads the configuration again and, if it is valid, queues the
+// timeouts for the replica to apply. The configuration keys of the changes
+// are given the pbft prefix of their environment variables.
+func (cr *configReloader) reload(queue chan<- events.Event) (*pb.ConfigReload, error) {
+	cr.Lock()
+	defer cr.Unlock()
+
+	config, err := readConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s plugin config: %s", configPrefix, err)
+	}
+	if errs := ValidateConfig(config); len(errs) > 0 {
+		return nil, fmt.Errorf("Invalid %s plugin config, keeping the current one: %v", configPrefix, errs)
+	}
+
+	result := &pb.ConfigReload{}
+	for _, change := range coreconfig.Diff(cr.config, config) {
+		key := "pbft." + change.Key
+		if !isReloadable(change.Key) {
+			logger.Warningf("Configuration %s changed, the change takes effect on restart", key)
+			result.RestartRequired = append(result.RestartRequired, key)
+			continue
+		}
+		logger.Infof("Configuration %s changed from [%v] to [%v]", key, change.Old, change.New)
+		result.Changes = append(result.Changes, &pb.ConfigChange{Key: key, OldValue: fmt.Sprint(change.Old), NewValue: fmt.Sprint(change.New)})
+	}
+	if len(result.Changes) > 0 {
+		queue <- parseTimeouts(config)
+	}
+	cr.config = config
+	return result, nil
+}
+
+func isReloadable(key string) bool {
+	for _, k := range reloadableKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeouts parses the timeouts of a configuration which passed
+// ValidateConfig, with the defaults of newPbftCore and newAdaptiveTimeout
+func parseTimeouts(config *viper.Viper) timeoutsReloadedEvent {
+	parse := func(key string) time.Duration {
+		d, _ := time.ParseDuration(config.GetString(key))
+		return d
+	}
+	t := timeoutsReloadedEvent{
+		request:     parse("general.timeout.request"),
+		requestMax:  parse("general.timeout.requestmax"),
+		decay:       1,
+		viewChange:  parse("general.timeout.viewchange"),
+		nullRequest: parse("general.timeout.nullrequest"),
+		batch:       parse("general.timeout.batch"),
+	}
+	if t.requestMax < t.request {
+		t.requestMax = t.request
+	}
+	if config.IsSet("general.timeout.requestdecay") {
+		t.decay = config.GetFloat64("general.timeout.requestdecay")
+	}
+	return t
+}
+
+// reloadTimeouts applies reloaded timeouts, the adaptive request timeout
+// being brought within its new bounds
+func (instance *pbftCore) reloadTimeouts(t timeoutsReloadedEvent) {
+	at := &instance.requestTimeouts
+	at.min, at.max, at.decay = t.request, t.requestMax, t.decay
+	at.enabled = at.max > at.min
+	instance.newViewTimeout = t.viewChange
+	instance.nullRequestTimeout = t.nullRequest
+	instance.setRequestTimeout(instance.requestTimeout)
+	logger.Infof("Replica %d reloaded its timeouts: request %v (bound %v, decay %v), view change %v, null request %v",
+		instance.id, instance.requestTimeout, at.max, at.decay, instance.newViewTimeout, instance.nullRequestTimeout)
+}
@@ -0,0 +1,285 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+	"github.com/hyperledger/fabric/consensus/simulator"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// simReplica runs a PBFT replica on a simulated network
+type simReplica struct {
+	id         uint64
+	net        *simulator.Network
+	pbft       *pbftCore
+	executed   [][]byte
+	executions uint64
+	lastSeqNo  uint64
+	skipped    bool
+	mockPersist
+}
+
+func (sr *simReplica) ProcessEvent(e events.Event) events.Event {
+	if msg, ok := e.(*simulator.Message); ok {
+		pbftMsg := &Message{}
+		if err := proto.Unmarshal(msg.Payload.([]byte), pbftMsg); err != nil {
+			panic(fmt.Sprintf("Replica %d received a message which did not unmarshal: %s", sr.id, err))
+		}
+		return &pbftMessage{msg: pbftMsg, sender: msg.Src}
+	}
+	return sr.pbft.ProcessEvent(e)
+}
+
+func (sr *simReplica) broadcast(msgPayload []byte) {
+	sr.net.Broadcast(sr.id, msgPayload)
+}
+
+func (sr *simReplica) unicast(msgPayload []byte, receiverID uint64) error {
+	sr.net.Send(sr.id, receiverID, msgPayload)
+	return nil
+}
+
+func (sr *simReplica) execute(seqNo uint64, txRaw []byte) {
+	sr.executed = append(sr.executed, txRaw)
+	sr.executions++
+	sr.lastSeqNo = seqNo
+	sr.net.Inject(sr.id, execDoneEvent{})
+}
+
+func (sr *simReplica) getState() []byte {
+	return []byte(fmt.Sprintf("%d", sr.executions))
+}
+
+func (sr *simReplica) getLastSeqNo() (uint64, error) {
+	if sr.executions < 1 {
+		return 0, fmt.Errorf("no execution yet")
+	}
+	return sr.lastSeqNo, nil
+}
+
+func (sr *simReplica) skipTo(seqNo uint64, id []byte, replicas []uint64) {
+	sr.skipped = true
+	sr.executions = seqNo
+	sr.lastSeqNo = seqNo
+	sr.net.Inject(sr.id, stateUpdatedEvent{
+		chkpt:  &checkpointMessage{seqNo: seqNo, id: id},
+		target: &pb.BlockchainInfo{},
+	})
+}
+
+func (sr *simReplica) validate(txRaw []byte) error {
+	return nil
+}
+
+func (sr *simReplica) sign(msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+func (sr *simReplica) verify(senderID uint64, signature []byte, message []byte) error {
+	return nil
+}
+
+func (sr *simReplica) invalidateState() {}
+func (sr *simReplica) validateState()   {}
+
+// makeSimulation creates N replicas on a simulated network, with the
+// adaptive request timeout disabled as it measures latency in real time
+func makeSimulation(N int, seed int64, config *viper.Viper) (*simulator.Network, []*simReplica) {
+	if config == nil {
+		config = loadConfig()
+	}
+	config.Set("general.N", N)
+	config.Set("general.f", (N-1)/3)
+	config.Set("general.timeout.requestmax", config.GetString("general.timeout.request"))
+
+	net := simulator.NewNetwork(N, seed)
+	replicas := make([]*simReplica, N)
+	for i := range replicas {
+		sr := &simReplica{id: uint64(i), net: net}
+		sr.pbft = newPbftCore(sr.id, config, sr, net.TimerFactory(sr.id))
+		net.SetNode(sr.id, sr)
+		replicas[i] = sr
+	}
+	return net, replicas
+}
+
+// simRequests has the client send count requests to the replicas, one every
+// interval
+func simRequests(net *simulator.Network, count int, interval time.Duration, replicas ...*simReplica) {
+	for i := 0; i < count; i++ {
+		req := createPbftRequestWithChainTx(int64(net.Now()/time.Millisecond)+int64(i), 0)
+		net.After(time.Duration(i)*interval, func() {
+			for _, sr := range replicas {
+				net.Inject(sr.id, req)
+			}
+		})
+	}
+}
+
+// executedAll returns whether each replica executed at least count requests
+func executedAll(count uint64, replicas ...*simReplica) func() bool {
+	return func() bool {
+		for _, sr := range replicas {
+			if sr.executions < count {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func checkAgreement(t *testing.T, replicas ...*simReplica) {
+	for _, sr := range replicas[1:] {
+		if !reflect.DeepEqual(sr.executed, replicas[0].executed) {
+			t.Errorf("Replica %d executed %d requests, which differ from the %d of replica %d", sr.id, len(sr.executed), len(replicas[0].executed), replicas[0].id)
+		}
+	}
+}
+
+func TestSimulationReordering(t *testing.T) {
+	simulate := func() ([]*simReplica, simulator.Stats) {
+		net, replicas := makeSimulation(4, 7, nil)
+		defer func() {
+			for _, sr := range replicas {
+				sr.pbft.close()
+			}
+		}()
+		net.AddFilter(simulator.RandomDelay(50*time.Millisecond, simulator.All))
+		simRequests(net, 20, 10*time.Millisecond, replicas...)
+		if err := net.Run(time.Minute, executedAll(20, replicas...)); err != nil {
+			t.Fatalf("Replicas did not execute the requests: %s", err)
+		}
+		checkAgreement(t, replicas...)
+		return replicas, net.Stats()
+	}
+
+	replicas, stats := simulate()
+	for _, sr := range replicas {
+		if sr.pbft.view != 0 {
+			t.Errorf("Expected replica %d to stay in view 0, is in view %d", sr.id, sr.pbft.view)
+		}
+	}
+	if _, again := simulate(); again.Sent != stats.Sent {
+		t.Errorf("Expected the same simulation to send the same number of messages, got %d and %d", stats.Sent, again.Sent)
+	}
+}
+
+func TestSimulationPrimaryCrash(t *testing.T) {
+	net, replicas := makeSimulation(4, 0, nil)
+	defer func() {
+		for _, sr := range replicas {
+			sr.pbft.close()
+		}
+	}()
+	net.AddFilter(simulator.Crash(0))
+	simRequests(net, 5, 10*time.Millisecond, replicas...)
+
+	correct := replicas[1:]
+	if err := net.Run(time.Minute, executedAll(5, correct...)); err != nil {
+		t.Fatalf("Replicas did not execute the requests after the primary crashed: %s", err)
+	}
+	checkAgreement(t, correct...)
+	for _, sr := range correct {
+		if sr.pbft.view == 0 {
+			t.Errorf("Expected replica %d to move to a new view", sr.id)
+		}
+	}
+}
+
+func TestSimulationPartition(t *testing.T) {
+	config := loadConfig()
+	config.Set("general.K", 2)
+	config.Set("general.logmultiplier", 2)
+	net, replicas := makeSimulation(4, 0, config)
+	defer func() {
+		for _, sr := range replicas {
+			sr.pbft.close()
+		}
+	}()
+
+	// replica 3 is cut off from the network and the client for a while
+	heal := 10 * time.Second
+	net.AddFilter(simulator.During(0, heal, simulator.Partition([]uint64{0, 1, 2}, []uint64{3})))
+	simRequests(net, 10, 100*time.Millisecond, replicas[:3]...)
+	net.After(heal, func() {
+		simRequests(net, 6, 100*time.Millisecond, replicas...)
+	})
+
+	if err := net.Run(time.Minute, executedAll(16, replicas...)); err != nil {
+		t.Fatalf("Replicas did not execute the requests: %s", err)
+	}
+	checkAgreement(t, replicas[:3]...)
+	if !replicas[3].skipped {
+		t.Errorf("Expected the partitioned replica to catch up through state transfer")
+	}
+}
+
+func TestSimulationEquivocatingPrimary(t *testing.T) {
+	net, replicas := makeSimulation(4, 0, nil)
+	defer func() {
+		for _, sr := range replicas {
+			sr.pbft.close()
+		}
+	}()
+
+	// the primary of view 0 pre-prepares another request for replicas 2 and 3
+	net.AddFilter(simulator.Rewrite(simulator.From(0), func(msg *simulator.Message) bool {
+		pbftMsg := &Message{}
+		if err := proto.Unmarshal(msg.Payload.([]byte), pbftMsg); err != nil {
+			return true
+		}
+		preprep := pbftMsg.GetPrePrepare()
+		if preprep == nil || preprep.View != 0 || msg.Dst < 2 {
+			return true
+		}
+		forged := createPbftRequestWithChainTx(1000+int64(preprep.SequenceNumber), 0)
+		preprep = &PrePrepare{
+			View:           preprep.View,
+			SequenceNumber: preprep.SequenceNumber,
+			RequestDigest:  hashReq(forged),
+			Request:        forged,
+			ReplicaId:      preprep.ReplicaId,
+		}
+		raw, err := proto.Marshal(&Message{&Message_PrePrepare{preprep}})
+		if err != nil {
+			return true
+		}
+		msg.Payload = raw
+		return true
+	}))
+	simRequests(net, 3, 10*time.Millisecond, replicas...)
+
+	correct := replicas[1:]
+	if err := net.Run(time.Minute, executedAll(3, correct...)); err != nil {
+		t.Fatalf("Replicas did not execute the requests despite the equivocating primary: %s", err)
+	}
+	checkAgreement(t, correct...)
+	for _, sr := range correct {
+		if sr.pbft.view == 0 {
+			t.Errorf("Expected replica %d to replace the equivocating primary", sr.id)
+		}
+	}
+}
@@ -124,10 +124,12 @@ func (instance *pbftCore) calcQSet() map[qidx]*ViewChange_PQ {
 
 func (instance *pbftCore) sendViewChange() events.Event {
 	instance.stopTimer()
+	instance.viewChanged()
 
 	delete(instance.newViewStore, instance.view)
 	instance.view++
 	instance.activeView = false
+	viewChangesSent.Inc()
 
 	instance.pset = instance.calcPSet()
 	instance.qset = instance.calcQSet()
@@ -246,6 +248,7 @@ func (instance *pbftCore) recvViewChange(vc *ViewChange) events.Event {
 		if quorum >= instance.allCorrectReplicasQuorum() {
 			instance.startTimer(instance.lastNewViewTimeout, "new view change")
 			instance.lastNewViewTimeout = 2 * instance.lastNewViewTimeout
+			instance.updateTimeoutMetrics()
 			return viewChangeQuorumEvent{}
 		}
 
@@ -462,6 +465,8 @@ func (instance *pbftCore) processNewView2(nv *NewView) events.Event {
 
 	instance.activeView = true
 	delete(instance.newViewStore, instance.view-1)
+	newViewsAccepted.Inc()
+	currentView.Set(float64(instance.view))
 
 	instance.seqNo = instance.h
 	for n, d := range nv.Xset {
@@ -487,6 +492,7 @@ func (instance *pbftCore) processNewView2(nv *NewView) events.Event {
 			instance.seqNo = n
 		}
 		instance.persistQSet()
+		instance.logMessage(&Message{&Message_PrePrepare{preprep}})
 	}
 
 	instance.updateViewChangeSeqNo()
@@ -0,0 +1,274 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+)
+
+// The write-ahead log records the pre-prepare, prepare and commit messages
+// of the replica as they are accepted, so that a replica restarting after a
+// crash recovers the certificates of the requests in its log and rejoins the
+// agreement on them, instead of waiting for the network to move past its
+// watermarks and triggering state transfer. The log is compacted to the
+// certificates above the low watermark each time the watermarks move.
+//
+// Each record is the length and the CRC-32 of the marshalled message, followed
+// by the message. A record torn by a crash ends the replay, and is truncated.
+
+const (
+	walFsyncAlways     = "always"     // sync every record
+	walFsyncCheckpoint = "checkpoint" // sync when the replica checkpoints
+	walFsyncNever      = "never"      // leave it to the operating system
+
+	walHeaderSize = 8
+)
+
+type messageLog struct {
+	path  string
+	file  *os.File
+	fsync string
+}
+
+// newMessageLog opens the write-ahead log of replica id, or returns nil if
+// it is disabled
+func newMessageLog(config *viper.Viper, id uint64) *messageLog {
+	if !config.GetBool("general.wal.enabled") {
+		return nil
+	}
+	dir := config.GetString("general.wal.directory")
+	if dir == "" {
+		dir = filepath.Join(viper.GetString("peer.fileSystemPath"), "pbft")
+	}
+	wal := &messageLog{
+		path:  filepath.Join(dir, fmt.Sprintf("wal.%d", id)),
+		fsync: config.GetString("general.wal.fsync"),
+	}
+	switch wal.fsync {
+	case walFsyncAlways, walFsyncCheckpoint, walFsyncNever:
+	case "":
+		wal.fsync = walFsyncAlways
+	default:
+		panic(fmt.Errorf("Invalid write-ahead log fsync policy %s, expected %s, %s or %s", wal.fsync, walFsyncAlways, walFsyncCheckpoint, walFsyncNever))
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(fmt.Errorf("Cannot create write-ahead log directory: %s", err))
+	}
+	var err error
+	if wal.file, err = os.OpenFile(wal.path, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		panic(fmt.Errorf("Cannot open write-ahead log: %s", err))
+	}
+	return wal
+}
+
+// replay invokes fn with the messages of the log in order, and positions the
+// log after the last complete record
+func (wal *messageLog) replay(fn func(msg *Message)) error {
+	data, err := ioutil.ReadAll(wal.file)
+	if err != nil {
+		return err
+	}
+	offset := 0
+	for offset+walHeaderSize <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		sum := binary.BigEndian.Uint32(data[offset+4:])
+		if offset+walHeaderSize+size > len(data) {
+			break
+		}
+		raw := data[offset+walHeaderSize : offset+walHeaderSize+size]
+		if crc32.ChecksumIEEE(raw) != sum {
+			break
+		}
+		msg := &Message{}
+		if err = proto.Unmarshal(raw, msg); err != nil {
+			break
+		}
+		fn(msg)
+		offset += walHeaderSize + size
+	}
+	if offset < len(data) {
+		logger.Warningf("Truncating %d bytes of incomplete records from write-ahead log %s", len(data)-offset, wal.path)
+		if err = wal.file.Truncate(int64(offset)); err != nil {
+			return err
+		}
+	}
+	_, err = wal.file.Seek(int64(offset), os.SEEK_SET)
+	return err
+}
+
+func walRecord(msg *Message) ([]byte, error) {
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, walHeaderSize, walHeaderSize+len(raw))
+	binary.BigEndian.PutUint32(record, uint32(len(raw)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(raw))
+	return append(record, raw...), nil
+}
+
+func (wal *messageLog) append(msg *Message) error {
+	record, err := walRecord(msg)
+	if err != nil {
+		return err
+	}
+	if _, err = wal.file.Write(record); err != nil {
+		return err
+	}
+	if wal.fsync == walFsyncAlways {
+		return wal.file.Sync()
+	}
+	return nil
+}
+
+// checkpoint syncs the log if its policy is to sync on checkpoints
+func (wal *messageLog) checkpoint() error {
+	if wal.fsync == walFsyncCheckpoint {
+		return wal.file.Sync()
+	}
+	return nil
+}
+
+// rewrite replaces the content of the log with msgs
+func (wal *messageLog) rewrite(msgs []*Message) error {
+	tmpPath := wal.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		var record []byte
+		if record, err = walRecord(msg); err == nil {
+			_, err = tmp.Write(record)
+		}
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if wal.fsync != walFsyncNever {
+		if err = tmp.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err = os.Rename(tmpPath, wal.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	wal.file.Close()
+	wal.file = tmp
+	return nil
+}
+
+func (wal *messageLog) close() {
+	wal.file.Close()
+}
+
+// logMessage appends an accepted pre-prepare, prepare or commit to the
+// write-ahead log
+func (instance *pbftCore) logMessage(msg *Message) {
+	if instance.wal == nil {
+		return
+	}
+	if err := instance.wal.append(msg); err != nil {
+		logger.Errorf("Replica %d could not append to the write-ahead log: %s", instance.id, err)
+	}
+}
+
+// compactMessageLog rewrites the write-ahead log with the certificates above
+// the low watermark
+func (instance *pbftCore) compactMessageLog() {
+	if instance.wal == nil {
+		return
+	}
+	var msgs []*Message
+	for _, cert := range instance.certStore {
+		if cert.prePrepare != nil {
+			msgs = append(msgs, &Message{&Message_PrePrepare{cert.prePrepare}})
+		}
+		for _, prep := range cert.prepare {
+			msgs = append(msgs, &Message{&Message_Prepare{prep}})
+		}
+		for _, commit := range cert.commit {
+			msgs = append(msgs, &Message{&Message_Commit{commit}})
+		}
+	}
+	if err := instance.wal.rewrite(msgs); err != nil {
+		logger.Errorf("Replica %d could not compact the write-ahead log: %s", instance.id, err)
+	}
+}
+
+// restoreMessageLog replays the write-ahead log into the certificate store
+func (instance *pbftCore) restoreMessageLog() {
+	if instance.wal == nil {
+		return
+	}
+	count := 0
+	err := instance.wal.replay(func(msg *Message) {
+		count++
+		if preprep := msg.GetPrePrepare(); preprep != nil {
+			cert := instance.getCert(preprep.View, preprep.SequenceNumber)
+			cert.prePrepare = preprep
+			cert.digest = preprep.RequestDigest
+			if preprep.Request != nil {
+				instance.reqStore[preprep.RequestDigest] = preprep.Request
+			}
+			if instance.view < preprep.View {
+				instance.view = preprep.View
+			}
+			if instance.seqNo < preprep.SequenceNumber {
+				instance.seqNo = preprep.SequenceNumber
+			}
+		} else if prep := msg.GetPrepare(); prep != nil {
+			cert := instance.getCert(prep.View, prep.SequenceNumber)
+			for _, prevPrep := range cert.prepare {
+				if prevPrep.ReplicaId == prep.ReplicaId {
+					return
+				}
+			}
+			cert.prepare = append(cert.prepare, prep)
+			if prep.ReplicaId == instance.id {
+				cert.sentPrepare = true
+			}
+		} else if commit := msg.GetCommit(); commit != nil {
+			cert := instance.getCert(commit.View, commit.SequenceNumber)
+			for _, prevCommit := range cert.commit {
+				if prevCommit.ReplicaId == commit.ReplicaId {
+					return
+				}
+			}
+			cert.commit = append(cert.commit, commit)
+			if commit.ReplicaId == instance.id {
+				cert.sentCommit = true
+			}
+		}
+	})
+	if err != nil {
+		logger.Errorf("Replica %d could not replay the write-ahead log: %s", instance.id, err)
+	}
+	logger.Infof("Replica %d replayed %d messages from the write-ahead log", instance.id, count)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package obcpbft
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+)
+
+func TestMessageLogReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pbft-wal")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := loadConfig()
+	config.Set("general.wal.enabled", true)
+	config.Set("general.wal.directory", dir)
+	executed := false
+	mock := &omniProto{
+		validateImpl: func(msg []byte) error {
+			return nil
+		},
+		broadcastImpl: func(msg []byte) {
+		},
+		executeImpl: func(seqNo uint64, txRaw []byte) {
+			executed = seqNo == 1
+		},
+	}
+	instance := newPbftCore(1, config, mock, &inertTimerFactory{})
+
+	req := createPbftRequestWithChainTx(1, 0)
+	digest := hashReq(req)
+	events.SendEvent(instance, &PrePrepare{View: 0, SequenceNumber: 1, RequestDigest: digest, Request: req, ReplicaId: 0})
+	for _, id := range []uint64{2, 3} {
+		events.SendEvent(instance, &Prepare{View: 0, SequenceNumber: 1, RequestDigest: digest, ReplicaId: id})
+	}
+	if !instance.prepared(digest, 0, 1) || !instance.getCert(0, 1).sentCommit {
+		t.Fatalf("Expected the request to be prepared and the commit sent")
+	}
+	instance.close()
+
+	// a torn record is dropped
+	f, err := os.OpenFile(filepath.Join(dir, "wal.1"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open the write-ahead log: %s", err)
+	}
+	f.Write([]byte{0, 0, 1})
+	f.Close()
+
+	instance = newPbftCore(1, config, mock, &inertTimerFactory{})
+	defer instance.close()
+	cert := instance.getCert(0, 1)
+	if !instance.prepared(digest, 0, 1) || !cert.sentPrepare || !cert.sentCommit || len(cert.commit) != 1 {
+		t.Fatalf("Expected the certificate to be restored from the write-ahead log, got %+v", cert)
+	}
+	if _, ok := instance.reqStore[digest]; !ok || instance.seqNo != 1 {
+		t.Fatalf("Expected the request and sequence number to be restored")
+	}
+
+	// the restored replica carries on with the agreement
+	for _, id := range []uint64{0, 2} {
+		events.SendEvent(instance, &Commit{View: 0, SequenceNumber: 1, RequestDigest: digest, ReplicaId: id})
+	}
+	if !instance.committed(digest, 0, 1) || !executed {
+		t.Fatalf("Expected the request to commit and execute after the restart")
+	}
+
+	// and the log is compacted when the watermarks move
+	instance.moveWatermarks(instance.K)
+	if info, err := os.Stat(filepath.Join(dir, "wal.1")); err != nil || info.Size() != 0 {
+		t.Fatalf("Expected the write-ahead log to be compacted, got %v (%v)", info, err)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import "time"
+
+// Match selects the messages a fault applies to
+type Match func(msg *Message) bool
+
+// All matches every message
+func All(msg *Message) bool {
+	return true
+}
+
+// From matches the messages sent by any of the nodes
+func From(ids ...uint64) Match {
+	return func(msg *Message) bool {
+		return contains(ids, msg.Src)
+	}
+}
+
+// To matches the messages sent to any of the nodes
+func To(ids ...uint64) Match {
+	return func(msg *Message) bool {
+		return contains(ids, msg.Dst)
+	}
+}
+
+func contains(ids []uint64, id uint64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Drop drops the matching messages
+func Drop(match Match) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		return 0, match(msg)
+	}
+}
+
+// DropRate drops the matching messages with probability rate
+func DropRate(rate float64, match Match) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		return 0, match(msg) && net.Rand().Float64() < rate
+	}
+}
+
+// Delay delays the matching messages by delay
+func Delay(delay time.Duration, match Match) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		if match(msg) {
+			return delay, false
+		}
+		return 0, false
+	}
+}
+
+// RandomDelay delays the matching messages by up to max, which reorders them
+func RandomDelay(max time.Duration, match Match) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		if match(msg) && max > 0 {
+			return time.Duration(net.Rand().Int63n(int64(max))), false
+		}
+		return 0, false
+	}
+}
+
+// Crash drops all the messages from and to the nodes, as if they crashed
+func Crash(ids ...uint64) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		return 0, contains(ids, msg.Src) || contains(ids, msg.Dst)
+	}
+}
+
+// Partition drops the messages between nodes of different groups, nodes in
+// no group being partitioned from all the others
+func Partition(groups ...[]uint64) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		for _, group := range groups {
+			if contains(group, msg.Src) {
+				return 0, !contains(group, msg.Dst)
+			}
+		}
+		return 0, true
+	}
+}
+
+// Rewrite lets a Byzantine node tamper with the matching messages it sends:
+// fn may change the payload, returning false drops the message
+func Rewrite(match Match, fn func(msg *Message) bool) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		if match(msg) {
+			return 0, !fn(msg)
+		}
+		return 0, false
+	}
+}
+
+// During applies filter to the messages sent between from and until, in
+// virtual time since the start of the simulation
+func During(from time.Duration, until time.Duration, filter Filter) Filter {
+	return func(net *Network, msg *Message) (time.Duration, bool) {
+		if net.Now() < from || net.Now() >= until {
+			return 0, false
+		}
+		return filter(net, msg)
+	}
+}
@@ -0,0 +1,295 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator runs consensus nodes in a single go routine over a
+// simulated network, under a virtual clock. Nodes are event receivers, the
+// messages they send and the timers they start are scheduled as events at a
+// virtual time, and the events are delivered one at a time in time order, so
+// that a simulation with the same nodes, seed and faults always plays out the
+// same way. Faults are injected by filters deciding the delay of each
+// message, or dropping or rewriting it.
+package simulator
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+)
+
+var logger = logging.MustGetLogger("consensus/simulator")
+
+// Message is a message sent from node Src to node Dst. It is delivered to Dst
+// as an event, which the node turns back into whatever it sent.
+type Message struct {
+	Src     uint64
+	Dst     uint64
+	Payload interface{}
+}
+
+// Filter decides the fate of a message when it is sent: it returns how long
+// to delay it, in addition to the delay of the previous filters, or whether
+// to drop it. It may also rewrite the message.
+type Filter func(net *Network, msg *Message) (delay time.Duration, drop bool)
+
+// Stats are the statistics of the messages of a simulation
+type Stats struct {
+	Sent      uint64
+	Dropped   uint64
+	Delivered uint64
+	Events    uint64 // events delivered, messages included
+}
+
+type scheduled struct {
+	at    time.Duration
+	seq   uint64 // orders the events scheduled at the same time
+	node  uint64
+	event events.Event
+	fn    func()
+}
+
+type schedule []*scheduled
+
+func (s schedule) Len() int { return len(s) }
+func (s schedule) Less(i, j int) bool {
+	if s[i].at != s[j].at {
+		return s[i].at < s[j].at
+	}
+	return s[i].seq < s[j].seq
+}
+func (s schedule) Swap(i, j int)       { s[i], s[j] = s[j], s[i] }
+func (s *schedule) Push(x interface{}) { *s = append(*s, x.(*scheduled)) }
+func (s *schedule) Pop() interface{} {
+	old := *s
+	item := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return item
+}
+
+// Network is a simulated network of nodes, identified by their index
+type Network struct {
+	now      time.Duration
+	seq      uint64
+	pending  schedule
+	nodes    []events.Receiver
+	filters  []Filter
+	rand     *rand.Rand
+	stats    Stats
+	Latency  time.Duration // delay of every message, before the filters
+	MaxSteps uint64        // events delivered by a Run before giving up, 0 for no bound
+}
+
+// NewNetwork creates a network of n nodes, to be set with SetNode, whose
+// pseudo-random choices derive from seed
+func NewNetwork(n int, seed int64) *Network {
+	return &Network{
+		nodes:    make([]events.Receiver, n),
+		rand:     rand.New(rand.NewSource(seed)),
+		Latency:  time.Millisecond,
+		MaxSteps: 1000000,
+	}
+}
+
+// SetNode sets the receiver of the events of node id
+func (net *Network) SetNode(id uint64, node events.Receiver) {
+	net.nodes[id] = node
+}
+
+// Size returns the number of nodes
+func (net *Network) Size() int {
+	return len(net.nodes)
+}
+
+// Now returns the virtual time elapsed since the start of the simulation
+func (net *Network) Now() time.Duration {
+	return net.now
+}
+
+// Rand returns the source of pseudo-randomness of the simulation, filters
+// must use it rather than any other for the simulation to be deterministic
+func (net *Network) Rand() *rand.Rand {
+	return net.rand
+}
+
+// Stats returns the statistics of the messages
+func (net *Network) Stats() Stats {
+	return net.stats
+}
+
+// AddFilter adds a filter applied to the messages sent from now on, after the
+// filters added before it
+func (net *Network) AddFilter(filter Filter) {
+	net.filters = append(net.filters, filter)
+}
+
+// ClearFilters removes all the filters
+func (net *Network) ClearFilters() {
+	net.filters = nil
+}
+
+func (net *Network) schedule(delay time.Duration, item *scheduled) {
+	item.at = net.now + delay
+	item.seq = net.seq
+	net.seq++
+	heap.Push(&net.pending, item)
+}
+
+// Send sends the message from src to dst, through the filters
+func (net *Network) Send(src uint64, dst uint64, payload interface{}) {
+	if dst >= uint64(len(net.nodes)) {
+		logger.Warningf("Node %d sent a message to unknown node %d", src, dst)
+		return
+	}
+	net.stats.Sent++
+	msg := &Message{Src: src, Dst: dst, Payload: payload}
+	delay := net.Latency
+	for _, filter := range net.filters {
+		extra, drop := filter(net, msg)
+		if drop {
+			net.stats.Dropped++
+			return
+		}
+		delay += extra
+	}
+	net.schedule(delay, &scheduled{node: msg.Dst, event: msg})
+}
+
+// Broadcast sends the payload from src to every other node
+func (net *Network) Broadcast(src uint64, payload interface{}) {
+	for dst := range net.nodes {
+		if uint64(dst) != src {
+			net.Send(src, uint64(dst), payload)
+		}
+	}
+}
+
+// Inject delivers event to node id as soon as the events already due are
+func (net *Network) Inject(id uint64, event events.Event) {
+	net.schedule(0, &scheduled{node: id, event: event})
+}
+
+// After runs fn once the virtual clock advanced by delay, e.g. to script a
+// fault
+func (net *Network) After(delay time.Duration, fn func()) {
+	net.schedule(delay, &scheduled{fn: fn})
+}
+
+// Step delivers the next event, and returns false if there is none
+func (net *Network) Step() bool {
+	if len(net.pending) == 0 {
+		return false
+	}
+	item := heap.Pop(&net.pending).(*scheduled)
+	net.now = item.at
+	net.stats.Events++
+	if item.fn != nil {
+		item.fn()
+		return true
+	}
+	if _, ok := item.event.(*Message); ok {
+		net.stats.Delivered++
+	}
+	if node := net.nodes[item.node]; node != nil {
+		events.SendEvent(node, item.event)
+	}
+	return true
+}
+
+// Run delivers the events until done returns true, checked after each event,
+// until the virtual clock passes the deadline, or until no event is left. It
+// returns an error unless done returned true.
+func (net *Network) Run(deadline time.Duration, done func() bool) error {
+	for steps := uint64(0); ; steps++ {
+		if done != nil && done() {
+			return nil
+		}
+		if net.MaxSteps > 0 && steps >= net.MaxSteps {
+			return fmt.Errorf("Simulation did not complete in %d events, at %v", steps, net.now)
+		}
+		if len(net.pending) > 0 && net.pending[0].at > deadline {
+			net.now = deadline
+			return fmt.Errorf("Simulation did not complete by %v", deadline)
+		}
+		if !net.Step() {
+			if done == nil {
+				return nil
+			}
+			return fmt.Errorf("Simulation ran out of events at %v", net.now)
+		}
+	}
+}
+
+// TimerFactory returns a factory of timers delivering their events to node
+// id at virtual times
+func (net *Network) TimerFactory(id uint64) events.TimerFactory {
+	return &timerFactory{net: net, node: id}
+}
+
+type timerFactory struct {
+	net  *Network
+	node uint64
+}
+
+func (tf *timerFactory) CreateTimer() events.Timer {
+	return &timer{net: tf.net, node: tf.node}
+}
+
+// timer honors the contract of events.Timer: once stopped or reset, the
+// event it was started with is not delivered
+type timer struct {
+	net    *Network
+	node   uint64
+	gen    uint64 // incremented on every start and stop, so stale expiries are ignored
+	active bool
+}
+
+func (t *timer) start(duration time.Duration, event events.Event) {
+	t.gen++
+	t.active = true
+	gen := t.gen
+	t.net.After(duration, func() {
+		if !t.active || t.gen != gen {
+			return
+		}
+		t.active = false
+		if node := t.net.nodes[t.node]; node != nil {
+			events.SendEvent(node, event)
+		}
+	})
+}
+
+func (t *timer) SoftReset(duration time.Duration, event events.Event) {
+	if !t.active {
+		t.start(duration, event)
+	}
+}
+
+func (t *timer) Reset(duration time.Duration, event events.Event) {
+	t.start(duration, event)
+}
+
+func (t *timer) Stop() {
+	t.gen++
+	t.active = false
+}
+
+func (t *timer) Halt() {
+	t.Stop()
+}
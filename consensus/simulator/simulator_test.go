@@ -0,0 +1,163 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus/obcpbft/events"
+)
+
+// recorder records the messages it receives, and forwards the first ones
+type recorder struct {
+	id       uint64
+	net      *Network
+	received []string
+	forward  int
+}
+
+func (r *recorder) ProcessEvent(e events.Event) events.Event {
+	switch et := e.(type) {
+	case *Message:
+		r.received = append(r.received, fmt.Sprintf("%d:%v@%v", et.Src, et.Payload, r.net.Now()))
+		if r.forward > 0 {
+			r.forward--
+			r.net.Broadcast(r.id, et.Payload)
+		}
+	case string:
+		r.received = append(r.received, fmt.Sprintf("%s@%v", et, r.net.Now()))
+	}
+	return nil
+}
+
+func makeRecorders(net *Network, forward int) []*recorder {
+	recorders := make([]*recorder, net.Size())
+	for i := range recorders {
+		recorders[i] = &recorder{id: uint64(i), net: net, forward: forward}
+		net.SetNode(uint64(i), recorders[i])
+	}
+	return recorders
+}
+
+func simulate(seed int64) [][]string {
+	net := NewNetwork(4, seed)
+	net.AddFilter(RandomDelay(10*time.Millisecond, All))
+	recorders := makeRecorders(net, 2)
+	for i := 0; i < 3; i++ {
+		net.Broadcast(0, i)
+	}
+	if err := net.Run(time.Second, nil); err != nil {
+		panic(err)
+	}
+	var received [][]string
+	for _, r := range recorders {
+		received = append(received, r.received)
+	}
+	return received
+}
+
+func TestDeterminism(t *testing.T) {
+	first := simulate(42)
+	if second := simulate(42); !reflect.DeepEqual(first, second) {
+		t.Fatalf("Expected the same seed to yield the same simulation, got %v and %v", first, second)
+	}
+	if other := simulate(43); reflect.DeepEqual(first, other) {
+		t.Fatalf("Expected another seed to yield another simulation")
+	}
+}
+
+func TestTimers(t *testing.T) {
+	net := NewNetwork(1, 0)
+	r := makeRecorders(net, 0)[0]
+	tf := net.TimerFactory(0)
+
+	stopped := tf.CreateTimer()
+	stopped.Reset(time.Second, "stopped")
+	reset := tf.CreateTimer()
+	reset.Reset(time.Second, "first")
+	soft := tf.CreateTimer()
+	soft.SoftReset(3*time.Second, "soft")
+	net.After(500*time.Millisecond, func() {
+		stopped.Stop()
+		reset.Reset(time.Second, "reset")
+		soft.SoftReset(time.Second, "ignored")
+	})
+	if err := net.Run(time.Minute, nil); err != nil {
+		t.Fatalf("Simulation failed: %s", err)
+	}
+	expected := []string{"reset@1.5s", "soft@3s"}
+	if !reflect.DeepEqual(r.received, expected) {
+		t.Fatalf("Expected timer events %v, got %v", expected, r.received)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	net := NewNetwork(4, 0)
+	recorders := makeRecorders(net, 0)
+	net.AddFilter(During(0, time.Second, Partition([]uint64{0, 1}, []uint64{2, 3})))
+	net.AddFilter(Drop(To(1)))
+	net.AddFilter(Rewrite(From(2), func(msg *Message) bool {
+		msg.Payload = "forged"
+		return msg.Dst != 0
+	}))
+	net.AddFilter(Delay(time.Second, To(3)))
+
+	net.Broadcast(0, "p")
+	net.Broadcast(2, "q")
+	net.After(time.Second, func() {
+		net.Broadcast(0, "healed")
+	})
+	if err := net.Run(time.Minute, nil); err != nil {
+		t.Fatalf("Simulation failed: %s", err)
+	}
+
+	expected := [][]string{
+		nil,
+		nil,
+		{"0:healed@1.001s"},
+		{"2:forged@1.001s", "0:healed@2.001s"},
+	}
+	for i, r := range recorders {
+		if !reflect.DeepEqual(r.received, expected[i]) {
+			t.Errorf("Expected node %d to receive %v, got %v", i, expected[i], r.received)
+		}
+	}
+	if stats := net.Stats(); stats.Sent != 9 || stats.Dropped != 6 || stats.Delivered != 3 {
+		t.Errorf("Expected 9 messages sent, 6 dropped and 3 delivered, got %+v", stats)
+	}
+}
+
+func TestRunDeadline(t *testing.T) {
+	net := NewNetwork(2, 0)
+	makeRecorders(net, 0)
+	tf := net.TimerFactory(0)
+	var tick func()
+	tick = func() {
+		tf.CreateTimer().Reset(time.Second, "tick")
+		net.After(time.Second, tick)
+	}
+	tick()
+	if err := net.Run(10*time.Second, func() bool { return false }); err == nil {
+		t.Fatalf("Expected the simulation not to complete")
+	}
+	if net.Now() != 10*time.Second {
+		t.Fatalf("Expected the clock to stop at the deadline, got %v", net.Now())
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// CutPolicy defines when a BlockCutter cuts the items it queues into a block
+type CutPolicy struct {
+	MaxCount int           // cut once this many items are queued
+	MaxBytes int           // cut before the queued items exceed this size, 0 if unbounded
+	Timeout  time.Duration // cut this long after the first item of a block was queued
+}
+
+// CutStats are the statistics of the blocks cut by a BlockCutter
+type CutStats struct {
+	Blocks       uint64 `json:"blocks"`
+	CutByCount   uint64 `json:"cutByCount"`
+	CutByBytes   uint64 `json:"cutByBytes"`
+	CutByTimeout uint64 `json:"cutByTimeout"`
+	Items        uint64 `json:"items"` // items in the blocks cut
+	Bytes        uint64 `json:"bytes"` // size of the items in the blocks cut
+}
+
+// BlockCutter queues the items ordered by a consenter, and cuts them into
+// blocks according to its policy. A block is cut once MaxCount items are
+// queued, or before an item which would take it over MaxBytes; an item larger
+// than MaxBytes gets a block of its own. The BlockCutter does not keep time:
+// the consenter starts its timer when the first item of a block is queued,
+// and cuts the block with Cut when it expires. It is not safe for concurrent
+// use, except for Stats.
+type BlockCutter struct {
+	policy  CutPolicy
+	pending []interface{}
+	bytes   int
+
+	statsLock sync.Mutex
+	stats     CutStats
+}
+
+// NewBlockCutter creates a BlockCutter with the given policy
+func NewBlockCutter(policy CutPolicy) *BlockCutter {
+	if policy.MaxCount < 1 {
+		policy.MaxCount = 1
+	}
+	if policy.MaxBytes < 0 {
+		policy.MaxBytes = 0
+	}
+	return &BlockCutter{policy: policy}
+}
+
+// Policy returns the policy of the BlockCutter
+func (bc *BlockCutter) Policy() CutPolicy {
+	return bc.policy
+}
+
+// SetTimeout changes the timeout of the policy, for the blocks whose timer
+// starts from now on
+func (bc *BlockCutter) SetTimeout(timeout time.Duration) {
+	bc.policy.Timeout = timeout
+}
+
+// Ordered queues item, of the given size, and returns the blocks cut as a
+// result, in order, if any
+func (bc *BlockCutter) Ordered(item interface{}, size int) [][]interface{} {
+	var blocks [][]interface{}
+	if bc.policy.MaxBytes > 0 && len(bc.pending) > 0 && bc.bytes+size > bc.policy.MaxBytes {
+		blocks = append(blocks, bc.cut(&bc.stats.CutByBytes))
+	}
+
+	bc.pending = append(bc.pending, item)
+	bc.bytes += size
+
+	if len(bc.pending) >= bc.policy.MaxCount {
+		blocks = append(blocks, bc.cut(&bc.stats.CutByCount))
+	} else if bc.policy.MaxBytes > 0 && bc.bytes >= bc.policy.MaxBytes {
+		blocks = append(blocks, bc.cut(&bc.stats.CutByBytes))
+	}
+	return blocks
+}
+
+// Cut returns the queued items as a block, once the timeout of the block
+// expired, or nil if there are none
+func (bc *BlockCutter) Cut() []interface{} {
+	if len(bc.pending) == 0 {
+		return nil
+	}
+	return bc.cut(&bc.stats.CutByTimeout)
+}
+
+func (bc *BlockCutter) cut(reason *uint64) []interface{} {
+	block := bc.pending
+	bc.statsLock.Lock()
+	bc.stats.Blocks++
+	*reason++
+	bc.stats.Items += uint64(len(block))
+	bc.stats.Bytes += uint64(bc.bytes)
+	bc.statsLock.Unlock()
+
+	bc.pending = nil
+	bc.bytes = 0
+	return block
+}
+
+// Pending returns the number of items queued for the next block
+func (bc *BlockCutter) Pending() int {
+	return len(bc.pending)
+}
+
+// Stats returns the statistics of the blocks cut, it may be called from any
+// go routine
+func (bc *BlockCutter) Stats() CutStats {
+	bc.statsLock.Lock()
+	defer bc.statsLock.Unlock()
+	return bc.stats
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockCutterCount(t *testing.T) {
+	bc := NewBlockCutter(CutPolicy{MaxCount: 2, Timeout: time.Second})
+	if blocks := bc.Ordered("a", 100); len(blocks) != 0 || bc.Pending() != 1 {
+		t.Fatalf("Expected no block to be cut with one item, got %v", blocks)
+	}
+	blocks := bc.Ordered("b", 100)
+	if len(blocks) != 1 || len(blocks[0]) != 2 || blocks[0][0] != "a" || blocks[0][1] != "b" || bc.Pending() != 0 {
+		t.Fatalf("Expected a block of the two items, got %v", blocks)
+	}
+	if stats := bc.Stats(); stats.Blocks != 1 || stats.CutByCount != 1 || stats.Items != 2 || stats.Bytes != 200 {
+		t.Fatalf("Expected the statistics of one block cut by count, got %+v", stats)
+	}
+}
+
+func TestBlockCutterBytes(t *testing.T) {
+	bc := NewBlockCutter(CutPolicy{MaxCount: 10, MaxBytes: 250, Timeout: time.Second})
+	bc.Ordered("a", 100)
+	bc.Ordered("b", 100)
+
+	// the item taking the block over its size starts the next block
+	blocks := bc.Ordered("c", 100)
+	if len(blocks) != 1 || len(blocks[0]) != 2 || bc.Pending() != 1 {
+		t.Fatalf("Expected a block of the first two items, got %v, %d pending", blocks, bc.Pending())
+	}
+
+	// the item filling the block exactly cuts it
+	blocks = bc.Ordered("d", 150)
+	if len(blocks) != 1 || len(blocks[0]) != 2 || bc.Pending() != 0 {
+		t.Fatalf("Expected a block of the next two items, got %v, %d pending", blocks, bc.Pending())
+	}
+
+	// an item larger than the bound gets a block of its own
+	bc.Ordered("e", 100)
+	blocks = bc.Ordered("f", 1000)
+	if len(blocks) != 2 || len(blocks[0]) != 1 || blocks[1][0] != "f" || bc.Pending() != 0 {
+		t.Fatalf("Expected two blocks of one item, got %v, %d pending", blocks, bc.Pending())
+	}
+	if stats := bc.Stats(); stats.Blocks != 4 || stats.CutByBytes != 4 || stats.Items != 6 || stats.Bytes != 1550 {
+		t.Fatalf("Expected the statistics of four blocks cut by size, got %+v", stats)
+	}
+}
+
+func TestBlockCutterTimeout(t *testing.T) {
+	bc := NewBlockCutter(CutPolicy{MaxCount: 10, Timeout: time.Second})
+	if block := bc.Cut(); block != nil {
+		t.Fatalf("Expected no block to be cut without items, got %v", block)
+	}
+	bc.Ordered("a", 1)
+	if block := bc.Cut(); len(block) != 1 || bc.Pending() != 0 {
+		t.Fatalf("Expected a block of one item, got %v", block)
+	}
+	if stats := bc.Stats(); stats.Blocks != 1 || stats.CutByTimeout != 1 {
+		t.Fatalf("Expected the statistics of one block cut by timeout, got %+v", stats)
+	}
+}
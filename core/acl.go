@@ -0,0 +1,392 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	"google/protobuf"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// The peer-local ACL restricts the operations of the Devops and Admin
+// services which change the peer or the chain, to the callers whose TLS
+// client certificate matches one of the entries configured for the
+// operation. The certificate is the only identity the peer knows the caller
+// by, so the ACL requires peer.tls.clientAuthRequired. An operation without
+// entries is denied to everyone, and so are the guarded operations called
+// over REST without a client certificate or over the local socket. The
+// guards list every operation of the services, so that one added to them is
+// not served until it is guarded or known to be read-only.
+
+// Operations guarded by the ACL, as configured under peer.acl.operations
+const (
+	ACLDeploy      = "deploy"      // Devops.Deploy, Build and Install, and submitted deploy transactions
+	ACLUpgrade     = "upgrade"     // Devops.Upgrade, and submitted upgrade transactions
+	ACLInvoke      = "invoke"      // Devops.Login, Invoke and InvokeBatch, and submitted invoke transactions
+	ACLReconfigure = "reconfigure" // Devops.Reconfigure
+	ACLRevocation  = "revocation"  // Devops.UpdateRevocationList
+	ACLAdmin       = "admin"       // Admin.StartServer, StopServer, ConnectPeer, DisconnectPeer, ReloadConfig and SetModuleLogLevel
+)
+
+var aclOperations = []string{ACLDeploy, ACLUpgrade, ACLInvoke, ACLReconfigure, ACLRevocation, ACLAdmin}
+
+var aclRoles = map[string]membersrvc.Role{
+	"client":    membersrvc.Role_CLIENT,
+	"peer":      membersrvc.Role_PEER,
+	"validator": membersrvc.Role_VALIDATOR,
+	"auditor":   membersrvc.Role_AUDITOR,
+}
+
+// aclCondition is a condition on the client certificate, e.g. OU=ops
+type aclCondition struct {
+	attribute string
+	value     string
+}
+
+// aclEntry is a list of conditions which must all hold
+type aclEntry []aclCondition
+
+// ACL maps the guarded operations to the entries allowed to call them
+type ACL struct {
+	entries map[string][]aclEntry
+}
+
+// NewACL creates the ACL configured by peer.acl, or returns nil if it is not
+// enabled
+func NewACL() (*ACL, error) {
+	if !viper.GetBool("peer.acl.enabled") {
+		return nil, nil
+	}
+	acl := &ACL{entries: make(map[string][]aclEntry)}
+	for _, op := range aclOperations {
+		for _, raw := range viper.GetStringSlice("peer.acl.operations." + op) {
+			entry, err := parseACLEntry(raw)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid ACL entry %q of operation %s: %s", raw, op, err)
+			}
+			acl.entries[op] = append(acl.entries[op], entry)
+		}
+		if len(acl.entries[op]) == 0 {
+			log.Infof("No ACL entry for operation %s, denying it to all callers", op)
+		}
+	}
+	if !viper.GetBool("peer.tls.enabled") || !viper.GetBool("peer.tls.clientAuthRequired") {
+		log.Warning("The ACL is enabled without peer.tls.clientAuthRequired, the guarded operations are denied to all callers")
+	}
+	return acl, nil
+}
+
+// parseACLEntry parses a comma separated list of conditions, each of them
+// role=<client|peer|validator|auditor>, CN=<common name>,
+// O=<organization> or OU=<organizational unit>
+func parseACLEntry(raw string) (aclEntry, error) {
+	var entry aclEntry
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("expected attribute=value, got %q", field)
+		}
+		cond := aclCondition{attribute: strings.ToUpper(strings.TrimSpace(kv[0])), value: strings.TrimSpace(kv[1])}
+		switch cond.attribute {
+		case "ROLE":
+			cond.value = strings.ToLower(cond.value)
+			if _, ok := aclRoles[cond.value]; !ok {
+				return nil, fmt.Errorf("unknown role %s", cond.value)
+			}
+		case "CN", "O", "OU":
+		default:
+			return nil, fmt.Errorf("unknown attribute %s", kv[0])
+		}
+		entry = append(entry, cond)
+	}
+	if len(entry) == 0 {
+		return nil, fmt.Errorf("no condition")
+	}
+	return entry, nil
+}
+
+// matches returns whether the certificate meets all the conditions
+func (entry aclEntry) matches(cert *x509.Certificate) bool {
+	for _, cond := range entry {
+		var ok bool
+		switch cond.attribute {
+		case "ROLE":
+			ok = certificateRole(cert)&aclRoles[cond.value] != 0
+		case "CN":
+			ok = cert.Subject.CommonName == cond.value
+		case "O":
+			ok = containsString(cert.Subject.Organization, cond.value)
+		case "OU":
+			ok = containsString(cert.Subject.OrganizationalUnit, cond.value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// certificateRole returns the role the certificate was issued for, carried
+// as in the enrollment certificates, or Role_NONE
+func certificateRole(cert *x509.Certificate) membersrvc.Role {
+	for _, ext := range cert.Extensions {
+		if utils.IntArrayEquals(ext.Id, crypto.ECertSubjectRole) {
+			role, err := strconv.ParseInt(string(ext.Value), 10, 32)
+			if err != nil {
+				return membersrvc.Role_NONE
+			}
+			return membersrvc.Role(role)
+		}
+	}
+	return membersrvc.Role_NONE
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error unless the caller of ctx may call operation op
+func (acl *ACL) Check(ctx context.Context, op string) error {
+	cert := callerCertificate(ctx)
+	if cert == nil {
+		log.Warningf("Denied %s to a caller without a verified client certificate", op)
+		return grpc.Errorf(codes.PermissionDenied, "%s requires a verified client certificate", op)
+	}
+	for _, entry := range acl.entries[op] {
+		if entry.matches(cert) {
+			log.Debugf("Allowed %s to %s", op, cert.Subject.CommonName)
+			return nil
+		}
+	}
+	log.Warningf("Denied %s to %s", op, cert.Subject.CommonName)
+	return grpc.Errorf(codes.PermissionDenied, "%s is not allowed to %s", cert.Subject.CommonName, op)
+}
+
+// callerCertificate returns the verified TLS client certificate of the caller
+func callerCertificate(ctx context.Context) *x509.Certificate {
+	authInfo, ok := credentials.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := authInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return tlsInfo.State.VerifiedChains[0][0]
+}
+
+// GuardDevops returns devops with the operations guarded by the ACL
+func (acl *ACL) GuardDevops(devops pb.DevopsServer) pb.DevopsServer {
+	return &aclDevops{devops: devops, acl: acl}
+}
+
+// GuardAdmin returns admin with the operations guarded by the ACL
+func (acl *ACL) GuardAdmin(admin pb.AdminServer) pb.AdminServer {
+	return &aclAdmin{admin: admin, acl: acl}
+}
+
+type aclDevops struct {
+	devops pb.DevopsServer
+	acl    *ACL
+}
+
+func (d *aclDevops) Login(ctx context.Context, secret *pb.Secret) (*pb.Response, error) {
+	if err := d.acl.Check(ctx, ACLInvoke); err != nil {
+		return nil, err
+	}
+	return d.devops.Login(ctx, secret)
+}
+
+func (d *aclDevops) Build(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if err := d.acl.Check(ctx, ACLDeploy); err != nil {
+		return nil, err
+	}
+	return d.devops.Build(ctx, spec)
+}
+
+func (d *aclDevops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if err := d.acl.Check(ctx, ACLDeploy); err != nil {
+		return nil, err
+	}
+	return d.devops.Deploy(ctx, spec)
+}
+
+func (d *aclDevops) Install(ctx context.Context, cds *pb.ChaincodeDeploymentSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if err := d.acl.Check(ctx, ACLDeploy); err != nil {
+		return nil, err
+	}
+	return d.devops.Install(ctx, cds)
+}
+
+func (d *aclDevops) Upgrade(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if err := d.acl.Check(ctx, ACLUpgrade); err != nil {
+		return nil, err
+	}
+	return d.devops.Upgrade(ctx, spec)
+}
+
+func (d *aclDevops) Reconfigure(ctx context.Context, validatorSet *pb.ValidatorSet) (*pb.Response, error) {
+	if err := d.acl.Check(ctx, ACLReconfigure); err != nil {
+		return nil, err
+	}
+	return d.devops.Reconfigure(ctx, validatorSet)
+}
+
+func (d *aclDevops) Invoke(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	if err := d.acl.Check(ctx, ACLInvoke); err != nil {
+		return nil, err
+	}
+	return d.devops.Invoke(ctx, spec)
+}
+
+func (d *aclDevops) InvokeBatch(ctx context.Context, batch *pb.ChaincodeInvocationBatch) (*pb.BatchResponse, error) {
+	if err := d.acl.Check(ctx, ACLInvoke); err != nil {
+		return nil, err
+	}
+	return d.devops.InvokeBatch(ctx, batch)
+}
+
+func (d *aclDevops) UpdateRevocationList(ctx context.Context, revocationList *pb.RevocationList) (*pb.Response, error) {
+	if err := d.acl.Check(ctx, ACLRevocation); err != nil {
+		return nil, err
+	}
+	return d.devops.UpdateRevocationList(ctx, revocationList)
+}
+
+// Submit guards the submitted transactions as the operation creating them,
+// the queries are not guarded and the other transactions are denied
+func (d *aclDevops) Submit(ctx context.Context, tx *pb.Transaction) (*pb.Response, error) {
+	var op string
+	switch tx.Type {
+	case pb.Transaction_CHAINCODE_DEPLOY:
+		op = ACLDeploy
+	case pb.Transaction_CHAINCODE_UPGRADE:
+		op = ACLUpgrade
+	case pb.Transaction_CHAINCODE_INVOKE:
+		op = ACLInvoke
+	case pb.Transaction_CHAINCODE_QUERY:
+		return d.devops.Submit(ctx, tx)
+	default:
+		return nil, grpc.Errorf(codes.PermissionDenied, "%s transactions cannot be submitted", tx.Type)
+	}
+	if err := d.acl.Check(ctx, op); err != nil {
+		return nil, err
+	}
+	return d.devops.Submit(ctx, tx)
+}
+
+// The queries do not change the peer or the chain
+
+func (d *aclDevops) ListChaincodes(ctx context.Context, empty *google_protobuf.Empty) (*pb.ChaincodeDeployments, error) {
+	return d.devops.ListChaincodes(ctx, empty)
+}
+
+func (d *aclDevops) Query(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	return d.devops.Query(ctx, spec)
+}
+
+func (d *aclDevops) QueryStream(spec *pb.ChaincodeInvocationSpec, stream pb.Devops_QueryStreamServer) error {
+	return d.devops.QueryStream(spec, stream)
+}
+
+func (d *aclDevops) QueryQuorum(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	return d.devops.QueryQuorum(ctx, spec)
+}
+
+type aclAdmin struct {
+	admin pb.AdminServer
+	acl   *ACL
+}
+
+func (a *aclAdmin) StartServer(ctx context.Context, empty *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := a.acl.Check(ctx, ACLAdmin); err != nil {
+		return nil, err
+	}
+	return a.admin.StartServer(ctx, empty)
+}
+
+func (a *aclAdmin) StopServer(ctx context.Context, empty *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := a.acl.Check(ctx, ACLAdmin); err != nil {
+		return nil, err
+	}
+	return a.admin.StopServer(ctx, empty)
+}
+
+func (a *aclAdmin) ConnectPeer(ctx context.Context, peer *pb.NetworkPeer) (*google_protobuf.Empty, error) {
+	if err := a.acl.Check(ctx, ACLAdmin); err != nil {
+		return nil, err
+	}
+	return a.admin.ConnectPeer(ctx, peer)
+}
+
+func (a *aclAdmin) DisconnectPeer(ctx context.Context, peer *pb.NetworkPeer) (*google_protobuf.Empty, error) {
+	if err := a.acl.Check(ctx, ACLAdmin); err != nil {
+		return nil, err
+	}
+	return a.admin.DisconnectPeer(ctx, peer)
+}
+
+func (a *aclAdmin) ReloadConfig(ctx context.Context, empty *google_protobuf.Empty) (*pb.ConfigReload, error) {
+	if err := a.acl.Check(ctx, ACLAdmin); err != nil {
+		return nil, err
+	}
+	return a.admin.ReloadConfig(ctx, empty)
+}
+
+func (a *aclAdmin) SetModuleLogLevel(ctx context.Context, in *pb.ModuleLogLevel) (*pb.ModuleLogLevels, error) {
+	if err := a.acl.Check(ctx, ACLAdmin); err != nil {
+		return nil, err
+	}
+	return a.admin.SetModuleLogLevel(ctx, in)
+}
+
+// The status and the log levels are read by everyone
+
+func (a *aclAdmin) GetStatus(ctx context.Context, empty *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	return a.admin.GetStatus(ctx, empty)
+}
+
+func (a *aclAdmin) GetNetworkStatus(ctx context.Context, empty *google_protobuf.Empty) (*pb.NetworkStatus, error) {
+	return a.admin.GetNetworkStatus(ctx, empty)
+}
+
+func (a *aclAdmin) GetModuleLogLevels(ctx context.Context, in *pb.ModuleLogLevel) (*pb.ModuleLogLevels, error) {
+	return a.admin.GetModuleLogLevels(ctx, in)
+}
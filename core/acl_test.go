@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func callerContext(cert *x509.Certificate) context.Context {
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return credentials.NewContext(context.Background(), credentials.TLSInfo{State: state})
+}
+
+func TestACL_Parse(t *testing.T) {
+	for _, raw := range []string{"", "OU", "OU=", "role=admin", "serial=1"} {
+		if _, err := parseACLEntry(raw); err == nil {
+			t.Errorf("Expected ACL entry %q to be invalid", raw)
+		}
+	}
+	entry, err := parseACLEntry(" role=Validator , ou=ops")
+	if err != nil {
+		t.Fatalf("Failed parsing ACL entry: %s", err)
+	}
+	if len(entry) != 2 || entry[0] != (aclCondition{"ROLE", "validator"}) || entry[1] != (aclCondition{"OU", "ops"}) {
+		t.Fatalf("Unexpected ACL entry %v", entry)
+	}
+}
+
+func TestACL_Check(t *testing.T) {
+	viper.Set("peer.acl.enabled", true)
+	viper.Set("peer.acl.operations.deploy", []string{"CN=admin", "role=validator,OU=ops"})
+	defer viper.Set("peer.acl.enabled", false)
+	defer viper.Set("peer.acl.operations.deploy", []string{})
+	acl, err := NewACL()
+	if err != nil {
+		t.Fatalf("Failed creating the ACL: %s", err)
+	}
+
+	admin := &x509.Certificate{Subject: pkix.Name{CommonName: "admin"}}
+	validator := &x509.Certificate{
+		Subject:    pkix.Name{CommonName: "vp0", OrganizationalUnit: []string{"ops"}},
+		Extensions: []pkix.Extension{{Id: crypto.ECertSubjectRole, Value: []byte("4")}},
+	}
+	client := &x509.Certificate{
+		Subject:    pkix.Name{CommonName: "jim", OrganizationalUnit: []string{"ops"}},
+		Extensions: []pkix.Extension{{Id: crypto.ECertSubjectRole, Value: []byte("1")}},
+	}
+
+	if err := acl.Check(callerContext(admin), ACLDeploy); err != nil {
+		t.Errorf("Expected admin to be allowed to deploy: %s", err)
+	}
+	if err := acl.Check(callerContext(validator), ACLDeploy); err != nil {
+		t.Errorf("Expected the validator to be allowed to deploy: %s", err)
+	}
+	if err := acl.Check(callerContext(client), ACLDeploy); err == nil {
+		t.Errorf("Expected the client to be denied deploying")
+	}
+	if err := acl.Check(context.Background(), ACLDeploy); err == nil {
+		t.Errorf("Expected a caller without certificate to be denied deploying")
+	}
+	if err := acl.Check(callerContext(admin), ACLAdmin); err == nil {
+		t.Errorf("Expected admin operations without entries to be denied")
+	}
+}
+
+func TestACL_GuardDevops(t *testing.T) {
+	viper.Set("peer.acl.enabled", true)
+	defer viper.Set("peer.acl.enabled", false)
+	acl, err := NewACL()
+	if err != nil {
+		t.Fatalf("Failed creating the ACL: %s", err)
+	}
+	devops := acl.GuardDevops(NewDevopsServer(nil))
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: "github.com/hyperledger/fabric/examples/chaincode/go/chaincode_example01"}}
+	admin := &x509.Certificate{Subject: pkix.Name{CommonName: "admin"}}
+	if _, err := devops.Deploy(callerContext(admin), spec); err == nil {
+		t.Fatalf("Expected the deploy to be denied")
+	}
+	if _, err := devops.InvokeBatch(callerContext(admin), &pb.ChaincodeInvocationBatch{}); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected the invoke batch to be denied, got %v", err)
+	}
+	if _, err := devops.UpdateRevocationList(callerContext(admin), &pb.RevocationList{}); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected the revocation list update to be denied, got %v", err)
+	}
+
+	// the submitted transactions are guarded as the operation creating them
+	for _, txType := range []pb.Transaction_Type{pb.Transaction_CHAINCODE_DEPLOY, pb.Transaction_CHAINCODE_UPGRADE, pb.Transaction_CHAINCODE_INVOKE, pb.Transaction_CONSENSUS_RECONFIGURE} {
+		if _, err := devops.Submit(callerContext(admin), &pb.Transaction{Type: txType}); grpc.Code(err) != codes.PermissionDenied {
+			t.Fatalf("Expected the submitted %s transaction to be denied, got %v", txType, err)
+		}
+	}
+	if _, err := devops.Submit(callerContext(admin), &pb.Transaction{Type: pb.Transaction_CHAINCODE_QUERY}); grpc.Code(err) == codes.PermissionDenied {
+		t.Fatalf("Expected the submitted query not to be guarded, got %v", err)
+	}
+}
@@ -17,8 +17,12 @@ limitations under the License.
 package core
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"runtime"
+	"sort"
+	"strings"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
@@ -26,6 +30,7 @@ import (
 
 	"google/protobuf"
 
+	"github.com/hyperledger/fabric/core/flogging"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -37,8 +42,26 @@ func NewAdminServer() *ServerAdmin {
 	return s
 }
 
+// NetworkAdmin is implemented by the peers whose connections to the other
+// peers are administered through the Admin service
+type NetworkAdmin interface {
+	GetPeerEndpoint() (*pb.PeerEndpoint, error)
+	GetNetworkTopology() (*pb.NetworkTopology, error)
+	ConnectPeer(address string) error
+	DisconnectPeer(peer string) error
+}
+
+// NewAdminServerWithNetwork creates an Admin service instance administering
+// the connections of network, and reporting the metrics of the consensus
+// plugin returned by consensusMetrics, if not nil
+func NewAdminServerWithNetwork(network NetworkAdmin, consensusMetrics func() (interface{}, error)) *ServerAdmin {
+	return &ServerAdmin{network: network, consensusMetrics: consensusMetrics}
+}
+
 // ServerAdmin implementation of the Admin service for the Peer
 type ServerAdmin struct {
+	network          NetworkAdmin
+	consensusMetrics func() (interface{}, error)
 }
 
 func worker(id int, die chan struct{}) {
@@ -79,3 +102,114 @@ func (*ServerAdmin) StopServer(context.Context, *google_protobuf.Empty) (*pb.Ser
 	defer os.Exit(0)
 	return status, nil
 }
+
+type peersStatusByID []*pb.PeerStatus
+
+func (s peersStatusByID) Len() int           { return len(s) }
+func (s peersStatusByID) Less(i, j int) bool { return s[i].Endpoint.ID.Name < s[j].Endpoint.ID.Name }
+func (s peersStatusByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// GetNetworkStatus reports the peers the server chats with, by peer ID, the
+// heights of their blockchains and the metrics of the consensus plugin
+func (s *ServerAdmin) GetNetworkStatus(context.Context, *google_protobuf.Empty) (*pb.NetworkStatus, error) {
+	if s.network == nil {
+		return nil, fmt.Errorf("The network of this server is not administered")
+	}
+	self, err := s.network.GetPeerEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting the endpoint of the peer: %s", err)
+	}
+	topology, err := s.network.GetNetworkTopology()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting the network topology: %s", err)
+	}
+	heights := make(map[string]uint64)
+	for _, peer := range topology.Topology {
+		heights[peer.ID.Name] = peer.BlockHeight
+	}
+
+	status := &pb.NetworkStatus{Self: self, BlockHeight: heights[self.ID.Name]}
+	for _, endpoint := range topology.Peers {
+		if endpoint.ID == nil || endpoint.ID.Name == self.ID.Name {
+			continue
+		}
+		status.Peers = append(status.Peers, &pb.PeerStatus{Endpoint: endpoint, BlockHeight: heights[endpoint.ID.Name]})
+	}
+	sort.Sort(peersStatusByID(status.Peers))
+
+	if s.consensusMetrics == nil {
+		status.ConsensusError = "Consensus is not running on this peer"
+	} else if metrics, err := s.consensusMetrics(); err != nil {
+		status.ConsensusError = err.Error()
+	} else if raw, err := json.Marshal(metrics); err != nil {
+		status.ConsensusError = fmt.Sprintf("Error marshalling the consensus metrics: %s", err)
+	} else {
+		status.Consensus = string(raw)
+	}
+	return status, nil
+}
+
+// ConnectPeer has the server chat with the peer at the address
+func (s *ServerAdmin) ConnectPeer(ctx context.Context, peer *pb.NetworkPeer) (*google_protobuf.Empty, error) {
+	if s.network == nil {
+		return nil, fmt.Errorf("The network of this server is not administered")
+	}
+	if err := s.network.ConnectPeer(peer.Address); err != nil {
+		return nil, err
+	}
+	log.Infof("Connecting to peer %s on request", peer.Address)
+	return &google_protobuf.Empty{}, nil
+}
+
+// DisconnectPeer has the server end its chats with the peer of the address
+// or ID
+func (s *ServerAdmin) DisconnectPeer(ctx context.Context, peer *pb.NetworkPeer) (*google_protobuf.Empty, error) {
+	if s.network == nil {
+		return nil, fmt.Errorf("The network of this server is not administered")
+	}
+	if err := s.network.DisconnectPeer(peer.Address); err != nil {
+		return nil, err
+	}
+	log.Infof("Disconnected peer %s on request", peer.Address)
+	return &google_protobuf.Empty{}, nil
+}
+
+// ReloadConfig has the server read its configuration again, and apply the
+// changes of the values it can change while running
+func (s *ServerAdmin) ReloadConfig(context.Context, *google_protobuf.Empty) (*pb.ConfigReload, error) {
+	log.Info("Reloading the configuration on request")
+	return ReloadConfig()
+}
+
+// GetModuleLogLevels returns the logging levels in effect for the module and
+// its submodules, or for all the modules with an empty module
+func (s *ServerAdmin) GetModuleLogLevels(ctx context.Context, in *pb.ModuleLogLevel) (*pb.ModuleLogLevels, error) {
+	return moduleLogLevels(in.Module), nil
+}
+
+// SetModuleLogLevel sets the logging level of the module, the default level
+// with an empty module, until the server restarts
+func (s *ServerAdmin) SetModuleLogLevel(ctx context.Context, in *pb.ModuleLogLevel) (*pb.ModuleLogLevels, error) {
+	old := flogging.GetModuleLevel(in.Module)
+	level, err := flogging.SetModuleLevel(in.Module, in.Level)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Logging level of module '%s' changed from %s to %s on request", in.Module, old, level)
+	return moduleLogLevels(in.Module), nil
+}
+
+// moduleLogLevels returns the levels of the module, if any, and of the
+// modules known under it
+func moduleLogLevels(module string) *pb.ModuleLogLevels {
+	levels := &pb.ModuleLogLevels{}
+	if module != "" {
+		levels.Modules = append(levels.Modules, &pb.ModuleLogLevel{Module: module, Level: flogging.GetModuleLevel(module)})
+	}
+	for _, m := range flogging.Modules() {
+		if module == "" || strings.HasPrefix(m, module+"/") {
+			levels.Modules = append(levels.Modules, &pb.ModuleLogLevel{Module: m, Level: flogging.GetModuleLevel(m)})
+		}
+	}
+	return levels
+}
@@ -16,9 +16,128 @@ limitations under the License.
 
 package core
 
-import "testing"
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+	"google/protobuf"
+
+	"github.com/hyperledger/fabric/core/flogging"
+	pb "github.com/hyperledger/fabric/protos"
+)
 
 func TestServer_Status(t *testing.T) {
 	t.Skip("TBD")
 	//performHandshake(t, peerClientConn)
 }
+
+type mockNetworkAdmin struct {
+	connected []string
+}
+
+func (m *mockNetworkAdmin) GetPeerEndpoint() (*pb.PeerEndpoint, error) {
+	return &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp0"}, Address: "10.0.0.1:30303"}, nil
+}
+
+func (m *mockNetworkAdmin) GetNetworkTopology() (*pb.NetworkTopology, error) {
+	return &pb.NetworkTopology{
+		Peers: []*pb.PeerEndpoint{
+			{ID: &pb.PeerID{Name: "vp2"}, Address: "10.0.0.3:30303"},
+			{ID: &pb.PeerID{Name: "vp1"}, Address: "10.0.0.2:30303"},
+		},
+		Topology: []*pb.PeerTopology{
+			{ID: &pb.PeerID{Name: "vp0"}, BlockHeight: 7},
+			{ID: &pb.PeerID{Name: "vp1"}, BlockHeight: 6},
+			{ID: &pb.PeerID{Name: "vp2"}, BlockHeight: 5},
+		},
+	}, nil
+}
+
+func (m *mockNetworkAdmin) ConnectPeer(address string) error {
+	m.connected = append(m.connected, address)
+	return nil
+}
+
+func (m *mockNetworkAdmin) DisconnectPeer(peer string) error {
+	for i, address := range m.connected {
+		if address == peer {
+			m.connected = append(m.connected[:i], m.connected[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("Not connected to peer %s", peer)
+}
+
+func TestServer_NetworkStatus(t *testing.T) {
+	metrics := func() (interface{}, error) {
+		return map[string]uint64{"view": 1}, nil
+	}
+	status, err := NewAdminServerWithNetwork(&mockNetworkAdmin{}, metrics).GetNetworkStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Failed getting the network status: %s", err)
+	}
+	if status.Self.ID.Name != "vp0" || status.BlockHeight != 7 {
+		t.Errorf("Unexpected status of the server: %v at height %d", status.Self, status.BlockHeight)
+	}
+	if len(status.Peers) != 2 || status.Peers[0].Endpoint.ID.Name != "vp1" || status.Peers[0].BlockHeight != 6 || status.Peers[1].BlockHeight != 5 {
+		t.Errorf("Expected the peers sorted by ID with their heights, got %v", status.Peers)
+	}
+	if status.Consensus != `{"view":1}` || status.ConsensusError != "" {
+		t.Errorf("Unexpected consensus metrics %q, error %q", status.Consensus, status.ConsensusError)
+	}
+
+	status, err = NewAdminServerWithNetwork(&mockNetworkAdmin{}, nil).GetNetworkStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Failed getting the network status without consensus: %s", err)
+	}
+	if status.Consensus != "" || status.ConsensusError == "" {
+		t.Errorf("Expected an error in place of the consensus metrics, got %q", status.Consensus)
+	}
+
+	if _, err := NewAdminServer().GetNetworkStatus(context.Background(), &google_protobuf.Empty{}); err == nil {
+		t.Errorf("Expected an error getting the network status of a server without network")
+	}
+}
+
+func TestServer_ConnectDisconnectPeer(t *testing.T) {
+	network := &mockNetworkAdmin{}
+	server := NewAdminServerWithNetwork(network, nil)
+	peer := &pb.NetworkPeer{Address: "10.0.0.4:30303"}
+	if _, err := server.ConnectPeer(context.Background(), peer); err != nil {
+		t.Fatalf("Failed connecting the peer: %s", err)
+	}
+	if len(network.connected) != 1 || network.connected[0] != peer.Address {
+		t.Fatalf("Expected the network to connect to %s, connected to %v", peer.Address, network.connected)
+	}
+	if _, err := server.DisconnectPeer(context.Background(), peer); err != nil {
+		t.Fatalf("Failed disconnecting the peer: %s", err)
+	}
+	if _, err := server.DisconnectPeer(context.Background(), peer); err == nil {
+		t.Errorf("Expected an error disconnecting a peer not connected")
+	}
+}
+
+func TestServer_ModuleLogLevels(t *testing.T) {
+	admin := NewAdminServer()
+	defer flogging.ResetModuleLevel("admintest")
+	logging.MustGetLogger("admintest/child").Debug("registers the module")
+
+	levels, err := admin.SetModuleLogLevel(context.Background(), &pb.ModuleLogLevel{Module: "admintest", Level: "debug"})
+	if err != nil {
+		t.Fatalf("Failed setting the logging level: %s", err)
+	}
+	expected := []*pb.ModuleLogLevel{{Module: "admintest", Level: "DEBUG"}, {Module: "admintest/child", Level: "DEBUG"}}
+	if !reflect.DeepEqual(levels.Modules, expected) {
+		t.Fatalf("Expected the levels %v, got %v", expected, levels.Modules)
+	}
+	levels, err = admin.GetModuleLogLevels(context.Background(), &pb.ModuleLogLevel{Module: "admintest/child"})
+	if err != nil || len(levels.Modules) != 1 || levels.Modules[0].Level != "DEBUG" {
+		t.Fatalf("Expected the child module to inherit DEBUG, got %v, %v", levels, err)
+	}
+	if _, err = admin.SetModuleLogLevel(context.Background(), &pb.ModuleLogLevel{Module: "admintest", Level: "loud"}); err == nil {
+		t.Fatalf("Expected an invalid level to be rejected")
+	}
+}
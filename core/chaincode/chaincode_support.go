@@ -18,18 +18,26 @@ package chaincode
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
 
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/container"
 	"github.com/hyperledger/fabric/core/container/ccintf"
 	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/flogging"
 	"github.com/hyperledger/fabric/core/ledger"
 	pb "github.com/hyperledger/fabric/protos"
 )
@@ -43,8 +51,10 @@ const (
 	// DevModeUserRunsChaincode property allows user to run chaincode in development environment
 	DevModeUserRunsChaincode       string = "dev"
 	chaincodeStartupTimeoutDefault int    = 5000
+	chaincodeExecuteTimeoutDefault int    = 30000
 	chaincodeInstallPathDefault    string = "/opt/gopath/bin/"
 	peerAddressDefault             string = "0.0.0.0:30303"
+	rangeQueryBatchSizeDefault     uint32 = 100
 )
 
 // chains is a map between different blockchains and their ChaincodeSupport.
@@ -59,6 +69,12 @@ func init() {
 //This is where the VM that's running the chaincode would hook in
 type chaincodeRTEnv struct {
 	handler *Handler
+	//deployment spec of a chaincode launched by the peer, nil if the user
+	//runs the chaincode
+	cds *pb.ChaincodeDeploymentSpec
+	//one-time token the launched chaincode presents when it registers,
+	//cleared once it has
+	token string
 }
 
 // runningChaincodes contains maps of chaincodeIDs to their chaincodeRTEs
@@ -74,15 +90,58 @@ func GetChain(name ChainName) *ChaincodeSupport {
 }
 
 //call this under lock
-func (chaincodeSupport *ChaincodeSupport) preLaunchSetup(chaincode string) chan bool {
+func (chaincodeSupport *ChaincodeSupport) preLaunchSetup(chaincode string, cds *pb.ChaincodeDeploymentSpec, token string) chan bool {
 	//register placeholder Handler. This will be transferred in registerHandler
 	//NOTE: from this point, existence of handler for this chaincode means the chaincode
 	//is in the process of getting started (or has been started)
 	notfy := make(chan bool, 1)
-	chaincodeSupport.runningChaincodes.chaincodeMap[chaincode] = &chaincodeRTEnv{handler: &Handler{readyNotify: notfy}}
+	chaincodeSupport.runningChaincodes.chaincodeMap[chaincode] = &chaincodeRTEnv{handler: &Handler{readyNotify: notfy}, cds: cds, token: token}
 	return notfy
 }
 
+// newRegistrationToken returns a random token for a chaincode to be launched,
+// given to it in its environment
+func newRegistrationToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("Error generating registration token: %s", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// registrationToken returns the token a chaincode presented in the metadata
+// of its stream, if any
+func registrationToken(ctxt context.Context) string {
+	md, ok := metadata.FromContext(ctxt)
+	if !ok {
+		return ""
+	}
+	if tokens := md[shim.RegistrationTokenKey]; len(tokens) > 0 {
+		return tokens[0]
+	}
+	return ""
+}
+
+// chaincodeSupportAddress returns the address the chaincodes connect to when
+// the chaincode support server listens on listenAddress: chaincode.address if
+// set, or else the host of the peer with the port of the listener
+func chaincodeSupportAddress(listenAddress string, peerAddress string) string {
+	if address := viper.GetString("chaincode.address"); address != "" {
+		return address
+	}
+	host, _, err := net.SplitHostPort(peerAddress)
+	if err != nil {
+		chaincodeLogger.Errorf("Error parsing peer address %s, using chaincode.listenAddress: %s", peerAddress, err)
+		return listenAddress
+	}
+	_, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		chaincodeLogger.Errorf("Error parsing chaincode.listenAddress %s: %s", listenAddress, err)
+		return listenAddress
+	}
+	return net.JoinHostPort(host, port)
+}
+
 //call this under lock
 func (chaincodeSupport *ChaincodeSupport) chaincodeHasBeenLaunched(chaincode string) (*chaincodeRTEnv, bool) {
 	chrte, hasbeenlaunched := chaincodeSupport.runningChaincodes.chaincodeMap[chaincode]
@@ -94,7 +153,7 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 	pnid := viper.GetString("peer.networkId")
 	pid := viper.GetString("peer.id")
 
-	s := &ChaincodeSupport{name: chainname, runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}, secHelper: secHelper, peerNetworkID: pnid, peerID: pid}
+	s := &ChaincodeSupport{name: chainname, runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}, txSimulations: &txSimulations{simulationMap: make(map[string]*txSimulation)}, secHelper: secHelper, peerNetworkID: pnid, peerID: pid}
 
 	//initialize global chain
 	chains[chainname] = s
@@ -112,6 +171,20 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 		s.peerAddress = peerAddressDefault
 	}
 
+	//the chaincodes connect to their own listener if there is one, with TLS if
+	//enabled for it, or else to the peer listener
+	if listenAddress := viper.GetString("chaincode.listenAddress"); listenAddress != "" {
+		s.peerAddress = chaincodeSupportAddress(listenAddress, s.peerAddress)
+		s.tlsEnabled = viper.GetBool("chaincode.tls.enabled")
+	} else {
+		s.tlsEnabled = comm.TLSEnabled()
+	}
+	if s.tlsEnabled {
+		if s.tlsRootCAs, err = comm.RootCAsPEM(); err != nil {
+			chaincodeLogger.Errorf("Error getting the CA certificates the chaincodes verify the peer with: %s", err)
+		}
+	}
+
 	s.userRunsCC = userrunsCC
 
 	s.ccStartupTimeout = ccstartuptimeout
@@ -122,6 +195,17 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 		s.chaincodeInstallPath = chaincodeInstallPathDefault
 	}
 
+	s.rangeQueryBatchSize = uint32(viper.GetInt("chaincode.rangeQueryBatchSize"))
+	if s.rangeQueryBatchSize == 0 {
+		s.rangeQueryBatchSize = rangeQueryBatchSizeDefault
+	}
+
+	s.queryChunkSize = viper.GetInt("chaincode.queryChunkSize")
+	s.privateCollections = getPrivateCollections()
+
+	s.pool = newChaincodePool(viper.GetInt("chaincode.pool.size"))
+	s.metering = newChaincodeMetering()
+
 	return s
 }
 
@@ -135,6 +219,7 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 type ChaincodeSupport struct {
 	name                 ChainName
 	runningChaincodes    *runningChaincodes
+	txSimulations        *txSimulations
 	peerAddress          string
 	ccStartupTimeout     time.Duration
 	chaincodeInstallPath string
@@ -142,6 +227,13 @@ type ChaincodeSupport struct {
 	secHelper            crypto.Peer
 	peerNetworkID        string
 	peerID               string
+	rangeQueryBatchSize  uint32
+	queryChunkSize       int
+	privateCollections   map[string]bool
+	pool                 *chaincodePool
+	metering             *chaincodeMetering
+	tlsEnabled           bool
+	tlsRootCAs           []byte
 }
 
 // DuplicateChaincodeHandlerError returned if attempt to register same chaincodeID while a stream already exists.
@@ -165,9 +257,24 @@ func (chaincodeSupport *ChaincodeSupport) registerHandler(chaincodehandler *Hand
 
 	chrte2, ok := chaincodeSupport.chaincodeHasBeenLaunched(key)
 	if ok && chrte2.handler.registered == true {
-		chaincodeLogger.Debugf("duplicate registered handler(key:%s) return error", key)
-		// Duplicate, return error
-		return newDuplicateChaincodeHandlerError(chaincodehandler)
+		if !chaincodeSupport.userRunsCC || chrte2.cds != nil {
+			chaincodeLogger.Debugf("duplicate registered handler(key:%s) return error", key)
+			// Duplicate, return error
+			return newDuplicateChaincodeHandlerError(chaincodehandler)
+		}
+		//in dev mode a rebuilt chaincode reconnects with the same name before
+		//the stream of the previous one has ended. The new one replaces it and
+		//keeps its state, which is stored under the name of the chaincode
+		chaincodeLogger.Infof("chaincode %s reconnected, replacing its previous handler", key)
+		chrte2.handler.replace()
+		delete(chaincodeSupport.runningChaincodes.chaincodeMap, key)
+		chrte2 = nil
+	}
+	if chaincodehandler.authenticate && !chaincodeSupport.userRunsCC {
+		if err := checkRegistrationToken(chrte2, chaincodehandler); err != nil {
+			chaincodeLogger.Warningf("Rejected registration of chaincode %s: %s", key, err)
+			return err
+		}
 	}
 	//a placeholder, unregistered handler will be setup by query or transaction processing that comes
 	//through via consensus. In this case we swap the handler and give it the notify channel
@@ -190,6 +297,20 @@ func (chaincodeSupport *ChaincodeSupport) registerHandler(chaincodehandler *Hand
 	return nil
 }
 
+//checkRegistrationToken checks that a chaincode registering over the network
+//has been launched by the peer and presents the token it was given, which is
+//then consumed. call this under lock
+func checkRegistrationToken(chrte *chaincodeRTEnv, chaincodehandler *Handler) error {
+	if chrte == nil || chrte.cds == nil || chrte.token == "" {
+		return fmt.Errorf("chaincode %s is not being launched by the peer", chaincodehandler.ChaincodeID.Name)
+	}
+	if subtle.ConstantTimeCompare([]byte(chrte.token), []byte(chaincodehandler.registrationToken)) != 1 {
+		return fmt.Errorf("invalid registration token for chaincode %s", chaincodehandler.ChaincodeID.Name)
+	}
+	chrte.token = ""
+	return nil
+}
+
 func (chaincodeSupport *ChaincodeSupport) deregisterHandler(chaincodehandler *Handler) error {
 
 	// clean up rangeQueryIteratorMap
@@ -203,8 +324,9 @@ func (chaincodeSupport *ChaincodeSupport) deregisterHandler(chaincodehandler *Ha
 	chaincodeLogger.Debugf("Deregister handler: %s", key)
 	chaincodeSupport.runningChaincodes.Lock()
 	defer chaincodeSupport.runningChaincodes.Unlock()
-	if _, ok := chaincodeSupport.chaincodeHasBeenLaunched(key); !ok {
-		// Handler NOT found
+	chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(key)
+	if !ok || chrte.handler != chaincodehandler {
+		// Handler NOT found, or the chaincode has been stopped and relaunched
 		return fmt.Errorf("Error deregistering handler, could not find handler with key: %s", key)
 	}
 	delete(chaincodeSupport.runningChaincodes.chaincodeMap, key)
@@ -234,10 +356,10 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 		select {
 		case ccMsg := <-notfy:
 			if ccMsg.Type == pb.ChaincodeMessage_ERROR {
-				err = fmt.Errorf("Error initializing container %s: %s", chaincode, string(ccMsg.Payload))
+				err = &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_CHAINCODE_ERROR, Err: fmt.Errorf("Error initializing container %s: %s", chaincode, string(ccMsg.Payload))}
 			}
 		case <-time.After(timeout):
-			err = fmt.Errorf("Timeout expired while executing send init message")
+			err = &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_TIMEOUT, Err: fmt.Errorf("Timeout expired while executing send init message")}
 		}
 	}
 
@@ -247,12 +369,33 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 	return err
 }
 
-//get args and env given chaincodeID
-func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID) (args []string, envs []string, err error) {
+//get args and env given chaincodeID and the language of the chaincode
+func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID, cLang pb.ChaincodeSpec_Type) (args []string, envs []string, err error) {
 	envs = []string{"CORE_CHAINCODE_ID_NAME=" + cID.Name}
+	if chaincodeSupport.queryChunkSize > 0 {
+		envs = append(envs, fmt.Sprintf("CORE_CHAINCODE_QUERYCHUNKSIZE=%d", chaincodeSupport.queryChunkSize))
+	}
+	if chaincodeSupport.tlsEnabled {
+		envs = append(envs, "CORE_PEER_TLS_ENABLED=true")
+		if len(chaincodeSupport.tlsRootCAs) > 0 {
+			envs = append(envs, "CORE_CHAINCODE_TLS_ROOTCAS="+string(chaincodeSupport.tlsRootCAs))
+		}
+		if override := viper.GetString("peer.tls.serverhostoverride"); override != "" {
+			envs = append(envs, "CORE_PEER_TLS_SERVERHOSTOVERRIDE="+override)
+		}
+	}
 
-	//chaincode executable will be same as the name of the chaincode
-	args = []string{chaincodeSupport.chaincodeInstallPath + cID.Name, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
+	switch cLang {
+	case pb.ChaincodeSpec_JAVA:
+		//chaincode jar will be named after the chaincode
+		args = []string{"java", "-jar", chaincodeSupport.chaincodeInstallPath + cID.Name + ".jar", fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
+	case pb.ChaincodeSpec_NODE:
+		//chaincode package directory will be named after the chaincode, node runs its main script
+		args = []string{"node", chaincodeSupport.chaincodeInstallPath + cID.Name, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
+	default:
+		//chaincode executable will be same as the name of the chaincode
+		args = []string{chaincodeSupport.chaincodeInstallPath + cID.Name, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
+	}
 
 	chaincodeLogger.Debugf("Executable is %s", args[0])
 
@@ -266,6 +409,11 @@ func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.
 		return false, fmt.Errorf("chaincode name not set")
 	}
 
+	token, err := newRegistrationToken()
+	if err != nil {
+		return false, err
+	}
+
 	chaincodeSupport.runningChaincodes.Lock()
 	var ok bool
 	//if its in the map, there must be a connected stream...nothing to do
@@ -275,21 +423,25 @@ func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.
 		return true, nil
 	}
 	alreadyRunning := false
-	notfy := chaincodeSupport.preLaunchSetup(chaincode)
+	notfy := chaincodeSupport.preLaunchSetup(chaincode, cds, token)
 	chaincodeSupport.runningChaincodes.Unlock()
 
 	//launch the chaincode
 
-	args, env, err := chaincodeSupport.getArgsAndEnv(cID)
+	args, env, err := chaincodeSupport.getArgsAndEnv(cID, cds.ChaincodeSpec.Type)
 	if err != nil {
+		chaincodeSupport.runningChaincodes.Lock()
+		delete(chaincodeSupport.runningChaincodes.chaincodeMap, chaincode)
+		chaincodeSupport.runningChaincodes.Unlock()
 		return alreadyRunning, err
 	}
+	env = append(env, "CORE_CHAINCODE_REGISTRATION_TOKEN="+token)
 
 	chaincodeLogger.Debugf("start container: %s(networkid:%s,peerid:%s)", chaincode, chaincodeSupport.peerNetworkID, chaincodeSupport.peerID)
 
 	vmtype, _ := chaincodeSupport.getVMType(cds)
 
-	sir := container.StartImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID}, Reader: targz, Args: args, Env: env}
+	sir := container.StartImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID}, Reader: targz, Args: args, Env: env, Limits: getChaincodeLimits(chaincode).resources}
 
 	ipcCtxt := context.WithValue(ctxt, ccintf.GetCCHandlerKey(), chaincodeSupport)
 
@@ -356,8 +508,31 @@ func (chaincodeSupport *ChaincodeSupport) Stop(context context.Context, cds *pb.
 	return err
 }
 
+// stopTimedOutChaincode stops the chaincode of chrte after the timeout of an
+// execution, unless it has already been stopped or restarted
+func (chaincodeSupport *ChaincodeSupport) stopTimedOutChaincode(context context.Context, chaincode string, chrte *chaincodeRTEnv) {
+	if chrte.cds == nil {
+		chaincodeLogger.Warningf("Chaincode %s timed out but is not run by the peer, not stopping it", chaincode)
+		return
+	}
+
+	chaincodeSupport.runningChaincodes.RLock()
+	current, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode)
+	chaincodeSupport.runningChaincodes.RUnlock()
+	if !ok || current != chrte {
+		return
+	}
+
+	chaincodeLogger.Errorf("Chaincode %s timed out, stopping it", chaincode)
+	if err := chaincodeSupport.Stop(context, chrte.cds); err != nil {
+		chaincodeLogger.Errorf("Error stopping timed out chaincode %s: %s", chaincode, err)
+	}
+}
+
 // Launch will launch the chaincode if not running (if running return nil) and will wait for handler of the chaincode to get into FSM ready state.
 func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.Transaction) (*pb.ChaincodeID, *pb.ChaincodeInput, error) {
+	txLogger := flogging.FromContext(context, chaincodeLogger)
+
 	//build the chaincode
 	var cID *pb.ChaincodeID
 	var cMsg *pb.ChaincodeInput
@@ -365,7 +540,7 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	var initargs []string
 
 	cds := &pb.ChaincodeDeploymentSpec{}
-	if t.Type == pb.Transaction_CHAINCODE_DEPLOY {
+	if t.Type == pb.Transaction_CHAINCODE_DEPLOY || t.Type == pb.Transaction_CHAINCODE_UPGRADE {
 		err := proto.Unmarshal(t.Payload, cds)
 		if err != nil {
 			return nil, nil, err
@@ -382,6 +557,20 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 		}
 		cID = ci.ChaincodeSpec.ChaincodeID
 		cMsg = ci.ChaincodeSpec.CtorMsg
+
+		//invocations of an upgraded chaincode go to its latest version
+		ledger, ledgerErr := ledger.GetLedgerByID(t.LedgerID)
+		if ledgerErr != nil {
+			return nil, nil, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
+		}
+		current, err := getCurrentChaincode(ledger, cID.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if current != cID.Name {
+			txLogger.Debugf("chaincode %s has been upgraded to %s", cID.Name, current)
+			cID = &pb.ChaincodeID{Path: cID.Path, Name: current}
+		}
 	} else {
 		chaincodeSupport.runningChaincodes.Unlock()
 		return nil, nil, fmt.Errorf("invalid transaction type: %d", t.Type)
@@ -395,16 +584,19 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	if chrte, ok = chaincodeSupport.chaincodeHasBeenLaunched(chaincode); ok {
 		if !chrte.handler.registered {
 			chaincodeSupport.runningChaincodes.Unlock()
-			chaincodeLogger.Debugf("premature execution - chaincode (%s) is being launched", chaincode)
+			txLogger.Debugf("premature execution - chaincode (%s) is being launched", chaincode)
 			err = fmt.Errorf("premature execution - chaincode (%s) is being launched", chaincode)
 			return cID, cMsg, err
 		}
 		if chrte.handler.isRunning() {
-			chaincodeLogger.Debugf("chaincode is running(no need to launch) : %s", chaincode)
+			txLogger.Debugf("chaincode is running(no need to launch) : %s", chaincode)
 			chaincodeSupport.runningChaincodes.Unlock()
+			if chaincodeSupport.pool != nil {
+				chaincodeSupport.pool.touch(chaincode)
+			}
 			return cID, cMsg, nil
 		}
-		chaincodeLogger.Debugf("Container not in READY state(%s)...send init/ready", chrte.handler.FSM.Current())
+		txLogger.Debugf("Container not in READY state(%s)...send init/ready", chrte.handler.FSM.Current())
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
@@ -422,8 +614,8 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	//         5) query successfully retrives committed tx and calls sendInitOrReady
 	// See issue #710
 
-	if t.Type != pb.Transaction_CHAINCODE_DEPLOY {
-		ledger, ledgerErr := ledger.GetLedger()
+	if t.Type != pb.Transaction_CHAINCODE_DEPLOY && t.Type != pb.Transaction_CHAINCODE_UPGRADE {
+		ledger, ledgerErr := ledger.GetLedgerByID(t.LedgerID)
 		if ledgerErr != nil {
 			return cID, cMsg, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
 		}
@@ -453,11 +645,13 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	//from here on : if we launch the container and get an error, we need to stop the container
 
 	//launch container if it is a System container or not in dev mode
+	launched := false
 	if (!chaincodeSupport.userRunsCC || cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM) && (chrte == nil || chrte.handler == nil) {
+		launched = true
 		var targz io.Reader = bytes.NewBuffer(cds.CodePackage)
 		_, err = chaincodeSupport.launchAndWaitForRegister(context, cds, cID, t.Uuid, targz)
 		if err != nil {
-			chaincodeLogger.Debugf("launchAndWaitForRegister failed %s", err)
+			txLogger.Debugf("launchAndWaitForRegister failed %s", err)
 			return cID, cMsg, err
 		}
 	}
@@ -466,17 +660,25 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 		//send init (if (f,args)) and wait for ready state
 		err = chaincodeSupport.sendInitOrReady(context, t.Uuid, chaincode, f, initargs, chaincodeSupport.ccStartupTimeout, t, depTx)
 		if err != nil {
-			chaincodeLogger.Debugf("sending init failed(%s)", err)
-			err = fmt.Errorf("Failed to init chaincode(%s)", err)
+			txLogger.Debugf("sending init failed(%s)", err)
+			err = txFailure(pb.RejectionPhase_EXECUTION, pb.RejectionReason_LAUNCH_FAILED, err, fmt.Errorf("Failed to init chaincode(%s)", err))
 			errIgnore := chaincodeSupport.Stop(context, cds)
 			if errIgnore != nil {
-				chaincodeLogger.Debugf("stop failed %s(%s)", errIgnore, err)
+				txLogger.Debugf("stop failed %s(%s)", errIgnore, err)
 			}
 		}
-		chaincodeLogger.Debug("sending init completed")
+		txLogger.Debug("sending init completed")
 	}
 
-	chaincodeLogger.Debug("LaunchChaincode complete")
+	if err == nil && chaincodeSupport.pool != nil {
+		chaincodeSupport.pool.touch(chaincode)
+		if launched {
+			//stopping the containers should not delay the transaction
+			go chaincodeSupport.evictIdleChaincodes(chaincode)
+		}
+	}
+
+	txLogger.Debug("LaunchChaincode complete")
 
 	return cID, cMsg, err
 }
@@ -523,7 +725,14 @@ func (chaincodeSupport *ChaincodeSupport) Deploy(context context.Context, t *pb.
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
-	args, envs, err := chaincodeSupport.getArgsAndEnv(cID)
+	//system chaincodes are part of the peer, only deployed packages are signed
+	if cds.ExecEnv != pb.ChaincodeDeploymentSpec_SYSTEM {
+		if err = verifyCodePackage(chaincodeSupport.getSecHelper(), cds); err != nil {
+			return cds, &TxError{Phase: pb.RejectionPhase_VM_BUILD, Reason: pb.RejectionReason_INVALID_CODE_PACKAGE, Err: err}
+		}
+	}
+
+	args, envs, err := chaincodeSupport.getArgsAndEnv(cID, cds.ChaincodeSpec.Type)
 	if err != nil {
 		return cds, fmt.Errorf("error getting args for chaincode %s", err)
 	}
@@ -550,8 +759,13 @@ func (chaincodeSupport *ChaincodeSupport) HandleChaincodeStream(ctxt context.Con
 }
 
 // Register the bidi stream entry point called by chaincode to register with the Peer.
+// Unless the user runs the chaincodes, only the chaincodes launched by the peer
+// are accepted, presenting the registration token they were launched with.
 func (chaincodeSupport *ChaincodeSupport) Register(stream pb.ChaincodeSupport_RegisterServer) error {
-	return chaincodeSupport.HandleChaincodeStream(stream.Context(), stream)
+	handler := newChaincodeSupportHandler(chaincodeSupport, stream)
+	handler.authenticate = true
+	handler.registrationToken = registrationToken(stream.Context())
+	return handler.processStream()
 }
 
 // createTransactionMessage creates a transaction message.
@@ -575,18 +789,21 @@ func createQueryMessage(uuid string, cMsg *pb.ChaincodeInput) (*pb.ChaincodeMess
 
 // Execute executes a transaction and waits for it to complete until a timeout value.
 func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincode string, msg *pb.ChaincodeMessage, timeout time.Duration, tx *pb.Transaction) (*pb.ChaincodeMessage, error) {
+	txLogger := flogging.FromContext(ctxt, chaincodeLogger)
+
 	chaincodeSupport.runningChaincodes.Lock()
 	//we expect the chaincode to be running... sanity check
 	chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode)
 	if !ok {
 		chaincodeSupport.runningChaincodes.Unlock()
-		chaincodeLogger.Debugf("cannot execute-chaincode is not running: %s", chaincode)
+		txLogger.Debugf("cannot execute-chaincode is not running: %s", chaincode)
 		return nil, fmt.Errorf("Cannot execute transaction or query for %s", chaincode)
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
 	var notfy chan *pb.ChaincodeMessage
 	var err error
+	start := time.Now()
 	if notfy, err = chrte.handler.sendExecuteMessage(msg, tx); err != nil {
 		return nil, fmt.Errorf("Error sending %s: %s", msg.Type.String(), err)
 	}
@@ -596,11 +813,21 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 		//response is sent to user or calling chaincode. ChaincodeMessage_ERROR and ChaincodeMessage_QUERY_ERROR
 		//are typically treated as error
 	case <-time.After(timeout):
-		err = fmt.Errorf("Timeout expired while executing transaction")
+		err = &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_TIMEOUT, Err: fmt.Errorf("Timeout expired while executing transaction")}
+		txLogger.Warningf("Chaincode %s did not respond within %v", chaincode, timeout)
 	}
 
 	//our responsibility to delete transaction context if sendExecuteMessage succeeded
 	chrte.handler.deleteTxContext(msg.Uuid)
 
+	failed := err != nil || ccresp.Type == pb.ChaincodeMessage_ERROR || ccresp.Type == pb.ChaincodeMessage_QUERY_ERROR
+	chaincodeSupport.metering.recordExecution(chaincode, msg.Type, time.Since(start), failed)
+
+	if ccresp == nil && err != nil {
+		//the chaincode may hang the following transactions, stop it so that
+		//it is restarted by the next one
+		chaincodeSupport.stopTimedOutChaincode(ctxt, chaincode, chrte)
+	}
+
 	return ccresp, err
 }
@@ -0,0 +1,132 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/looplab/fsm"
+)
+
+func newTestRegisteredHandler(t *testing.T, chaincodeSupport *ChaincodeSupport, name string) *Handler {
+	handler := newChaincodeSupportHandler(chaincodeSupport, nil)
+	handler.ChaincodeID = &pb.ChaincodeID{Name: name}
+	testutil.AssertNoError(t, chaincodeSupport.registerHandler(handler), "Error registering handler")
+	return handler
+}
+
+func TestRegisterHandlerDevModeReconnect(t *testing.T) {
+	chaincodeSupport := &ChaincodeSupport{runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}, userRunsCC: true}
+	previous := newTestRegisteredHandler(t, chaincodeSupport, "mycc")
+	previous.txCtxs["txUuid"] = &transactionContext{responseNotifier: make(chan *pb.ChaincodeMessage, 1)}
+
+	// the rebuilt chaincode replaces the previous one
+	current := newTestRegisteredHandler(t, chaincodeSupport, "mycc")
+	chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched("mycc")
+	testutil.AssertEquals(t, ok, true)
+	testutil.AssertSame(t, chrte.handler, current)
+	select {
+	case <-previous.disconnect:
+	default:
+		t.Fatal("Expected the stream of the previous handler to be ended")
+	}
+	msg := <-previous.txCtxs["txUuid"].responseNotifier
+	testutil.AssertEquals(t, msg.Type, pb.ChaincodeMessage_ERROR)
+
+	// and is kept when the stream of the previous one ends
+	testutil.AssertError(t, chaincodeSupport.deregisterHandler(previous), "Expected error deregistering the replaced handler")
+	chrte, ok = chaincodeSupport.chaincodeHasBeenLaunched("mycc")
+	testutil.AssertEquals(t, ok, true)
+	testutil.AssertSame(t, chrte.handler, current)
+}
+
+func TestRegisterHandlerDuplicate(t *testing.T) {
+	chaincodeSupport := &ChaincodeSupport{runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}}
+	newTestRegisteredHandler(t, chaincodeSupport, "mycc")
+
+	handler := newChaincodeSupportHandler(chaincodeSupport, nil)
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+	err := chaincodeSupport.registerHandler(handler)
+	if _, ok := err.(*DuplicateChaincodeHandlerError); !ok {
+		t.Fatalf("Expected a duplicate handler error, got %v", err)
+	}
+}
+
+func TestHandleQueryCompletedChunks(t *testing.T) {
+	chaincodeSupport := &ChaincodeSupport{runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}}
+	handler := newTestRegisteredHandler(t, chaincodeSupport, "mycc")
+	handler.FSM = fsm.NewFSM(readystate, fsm.Events{}, fsm.Callbacks{})
+	txctx, err := handler.createTxContext("txUuid", nil)
+	testutil.AssertNoError(t, err, "Error creating transaction context")
+
+	for _, chunk := range []string{"large ", "query "} {
+		msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY_COMPLETED_CHUNK, Payload: []byte(chunk), Uuid: "txUuid"}
+		testutil.AssertNoError(t, handler.HandleMessage(msg), "Error handling query result chunk")
+	}
+	select {
+	case <-txctx.responseNotifier:
+		t.Fatal("Expected the query result to be notified once complete")
+	default:
+	}
+
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY_COMPLETED, Payload: []byte("result"), Uuid: "txUuid"}
+	testutil.AssertNoError(t, handler.HandleMessage(msg), "Error handling query completion")
+	msg = <-txctx.responseNotifier
+	testutil.AssertEquals(t, msg.Type, pb.ChaincodeMessage_QUERY_COMPLETED)
+	testutil.AssertEquals(t, string(msg.Payload), "large query result")
+}
+
+func TestRegisterHandlerToken(t *testing.T) {
+	chaincodeSupport := &ChaincodeSupport{runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}}
+	notfy := chaincodeSupport.preLaunchSetup("mycc", &pb.ChaincodeDeploymentSpec{}, "secret")
+
+	newHandler := func(name string, token string) *Handler {
+		handler := newChaincodeSupportHandler(chaincodeSupport, nil)
+		handler.ChaincodeID = &pb.ChaincodeID{Name: name}
+		handler.authenticate = true
+		handler.registrationToken = token
+		return handler
+	}
+	testutil.AssertError(t, chaincodeSupport.registerHandler(newHandler("othercc", "secret")), "Expected error registering a chaincode which is not being launched")
+	testutil.AssertError(t, chaincodeSupport.registerHandler(newHandler("mycc", "")), "Expected error registering without a token")
+	testutil.AssertError(t, chaincodeSupport.registerHandler(newHandler("mycc", "guess")), "Expected error registering with an invalid token")
+
+	handler := newHandler("mycc", "secret")
+	testutil.AssertNoError(t, chaincodeSupport.registerHandler(handler), "Error registering with the launch token")
+	chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched("mycc")
+	testutil.AssertEquals(t, ok, true)
+	testutil.AssertSame(t, chrte.handler, handler)
+	testutil.AssertSame(t, handler.readyNotify, notfy)
+
+	// the token is consumed once the chaincode has registered
+	testutil.AssertEquals(t, chrte.token, "")
+	chaincodeSupport.deregisterHandler(handler)
+	chaincodeSupport.runningChaincodes.chaincodeMap["mycc"] = &chaincodeRTEnv{handler: &Handler{}, cds: &pb.ChaincodeDeploymentSpec{}}
+	testutil.AssertError(t, chaincodeSupport.registerHandler(newHandler("mycc", "secret")), "Expected error registering with a consumed token")
+}
+
+func TestRegistrationTokenFromMetadata(t *testing.T) {
+	ctxt := metadata.NewContext(context.Background(), metadata.Pairs(shim.RegistrationTokenKey, "secret"))
+	testutil.AssertEquals(t, registrationToken(ctxt), "secret")
+	testutil.AssertEquals(t, registrationToken(context.Background()), "")
+}
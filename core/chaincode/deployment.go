@@ -0,0 +1,129 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// deploymentNamespace is the state namespace recording the deployed
+// chaincodes, by name. Like the upgrades, the deployments are written by the
+// deploy and upgrade transactions, so all the validators agree on them.
+const deploymentNamespace = "chaincode.deployments"
+
+// CodePackageHash returns the hex SHA-256 hash of the chaincode package of
+// cds, by which a package built offline is recognized once deployed
+func CodePackageHash(cds *pb.ChaincodeDeploymentSpec) string {
+	hash := sha256.Sum256(cds.CodePackage)
+	return hex.EncodeToString(hash[:])
+}
+
+// recordDeployment records the deployment of the chaincode of cds in the
+// state of its deploy or upgrade transaction, which must have been started.
+// An upgrade must have been recorded by recordUpgrade.
+func recordDeployment(ledger *ledger.Ledger, cds *pb.ChaincodeDeploymentSpec) error {
+	spec := cds.ChaincodeSpec
+	deployment := &pb.ChaincodeDeployment{
+		Name:                  spec.ChaincodeID.Name,
+		Path:                  spec.ChaincodeID.Path,
+		Type:                  spec.Type,
+		Version:               1,
+		UpgradedChaincodeName: cds.UpgradedChaincodeName,
+		CodeHash:              CodePackageHash(cds),
+		Signed:                len(cds.CodeSignature) > 0,
+	}
+	if cds.UpgradedChaincodeName != "" {
+		upgraded, err := getDeployment(ledger, cds.UpgradedChaincodeName, false)
+		if err != nil {
+			return err
+		}
+		// the chaincodes deployed before the deployments were recorded are
+		// taken for first versions
+		if upgraded != nil {
+			deployment.Version = upgraded.Version + 1
+		} else {
+			deployment.Version = 2
+		}
+	}
+
+	raw, err := proto.Marshal(deployment)
+	if err != nil {
+		return fmt.Errorf("Error marshalling the deployment of chaincode %s: %s", deployment.Name, err)
+	}
+	if err = ledger.SetState(deploymentNamespace, deployment.Name, raw); err != nil {
+		return fmt.Errorf("Error recording the deployment of chaincode %s: %s", deployment.Name, err)
+	}
+	return nil
+}
+
+// getDeployment returns the recorded deployment of chaincode, or nil if it is
+// not recorded
+func getDeployment(ledger *ledger.Ledger, chaincode string, committed bool) (*pb.ChaincodeDeployment, error) {
+	raw, err := ledger.GetState(deploymentNamespace, chaincode, committed)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting the deployment of chaincode %s: %s", chaincode, err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	deployment := &pb.ChaincodeDeployment{}
+	if err = proto.Unmarshal(raw, deployment); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling the deployment of chaincode %s: %s", chaincode, err)
+	}
+	return deployment, nil
+}
+
+type deploymentsByName []*pb.ChaincodeDeployment
+
+func (s deploymentsByName) Len() int           { return len(s) }
+func (s deploymentsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s deploymentsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// GetDeployments returns the committed deployments of the chaincodes, sorted
+// by name, with the successors of the upgraded chaincodes
+func GetDeployments(ledger *ledger.Ledger) ([]*pb.ChaincodeDeployment, error) {
+	itr, err := ledger.GetStateRangeScanIterator(deploymentNamespace, "", "", true)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the deployed chaincodes: %s", err)
+	}
+	defer itr.Close()
+
+	var deployments []*pb.ChaincodeDeployment
+	for itr.Next() {
+		name, raw := itr.GetKeyValue()
+		deployment := &pb.ChaincodeDeployment{}
+		if err = proto.Unmarshal(raw, deployment); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling the deployment of chaincode %s: %s", name, err)
+		}
+		successor, err := ledger.GetState(upgradeNamespace, successorKey(name), true)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting the upgrades of chaincode %s: %s", name, err)
+		}
+		deployment.Successor = string(successor)
+		deployments = append(deployments, deployment)
+	}
+	sort.Sort(deploymentsByName(deployments))
+	return deployments, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestDeployments(t *testing.T) {
+	ledgerPtr := ledger.InitTestLedger(t)
+
+	oldcc := newTestUpgradeSpec("oldcc", "")
+	oldcc.ChaincodeSpec.ChaincodeID.Path = "github.com/mycc"
+	oldcc.CodePackage = []byte("old package")
+	oldcc.CodeSignature = []byte("signature")
+	deployTx, err := pb.NewChaincodeDeployTransaction(oldcc, "oldcc")
+	testutil.AssertNoError(t, err, "Error creating deploy transaction")
+	err = commitTestTransaction(t, ledgerPtr, 1, deployTx, func() error {
+		return recordDeployment(ledgerPtr, oldcc)
+	})
+	testutil.AssertNoError(t, err, "Error recording deployment")
+
+	newcc := newTestUpgradeSpec("newcc", "oldcc")
+	newcc.CodePackage = []byte("new package")
+	upgradeTx, err := pb.NewChaincodeUpgradeTransaction(newcc, "newcc")
+	testutil.AssertNoError(t, err, "Error creating upgrade transaction")
+	err = commitTestTransaction(t, ledgerPtr, 2, upgradeTx, func() error {
+		if err := recordUpgrade(ledgerPtr, newcc); err != nil {
+			return err
+		}
+		return recordDeployment(ledgerPtr, newcc)
+	})
+	testutil.AssertNoError(t, err, "Error recording upgrade")
+
+	// a failed deployment is not recorded
+	failedTx, err := pb.NewChaincodeDeployTransaction(newTestUpgradeSpec("failedcc", ""), "failedcc")
+	testutil.AssertNoError(t, err, "Error creating deploy transaction")
+	commitTestTransaction(t, ledgerPtr, 3, failedTx, func() error {
+		testutil.AssertNoError(t, recordDeployment(ledgerPtr, newTestUpgradeSpec("failedcc", "")), "Error recording deployment")
+		return fmt.Errorf("failed")
+	})
+
+	deployments, err := GetDeployments(ledgerPtr)
+	testutil.AssertNoError(t, err, "Error getting deployments")
+	testutil.AssertEquals(t, len(deployments), 2)
+	testutil.AssertEquals(t, deployments[0].Name, "newcc")
+	testutil.AssertEquals(t, deployments[0].Version, uint64(2))
+	testutil.AssertEquals(t, deployments[0].UpgradedChaincodeName, "oldcc")
+	testutil.AssertEquals(t, deployments[0].Successor, "")
+	testutil.AssertEquals(t, deployments[0].Signed, false)
+	testutil.AssertEquals(t, deployments[1].Name, "oldcc")
+	testutil.AssertEquals(t, deployments[1].Path, "github.com/mycc")
+	testutil.AssertEquals(t, deployments[1].Version, uint64(1))
+	testutil.AssertEquals(t, deployments[1].Successor, "newcc")
+	testutil.AssertEquals(t, deployments[1].CodeHash, CodePackageHash(oldcc))
+	testutil.AssertEquals(t, deployments[1].Signed, true)
+}
@@ -24,16 +24,36 @@ import (
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/flogging"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/tracing"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
-//Execute - execute transaction or a query
+//Execute - execute transaction or a query. The txid of the transaction is
+//added to the logging fields of ctxt, with its trace if it is traced
 func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, *pb.ChaincodeEvent, error) {
+	span := tracing.StartChildSpan("execute", t.TraceContext)
+	if span == nil {
+		return execute(ctxt, chain, t)
+	}
+	span.SetTag("txid", t.Uuid)
+	span.SetTag("type", t.Type)
+	result, event, err := execute(flogging.NewContext(ctxt, "trace", span.TraceIDString()), chain, t)
+	span.SetError(err)
+	span.Finish()
+	return result, event, err
+}
+
+func execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, *pb.ChaincodeEvent, error) {
 	var err error
 
+	ctxt = flogging.NewContext(ctxt, "txid", t.Uuid)
+	flogging.FromContext(ctxt, chaincodeLogger).Debugf("Executing %s transaction", t.Type)
+
 	// get a handle to ledger to mark the begin/finish of a tx
-	ledger, ledgerErr := ledger.GetLedger()
+	ledger, ledgerErr := ledger.GetLedgerByID(t.LedgerID)
 	if ledgerErr != nil {
 		return nil, nil, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
 	}
@@ -43,29 +63,64 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 		t, err = secHelper.TransactionPreExecution(t)
 		// Note that t is now decrypted and is a deep clone of the original input t
 		if nil != err {
-			return nil, nil, err
+			return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: err}
 		}
 	}
 
-	if t.Type == pb.Transaction_CHAINCODE_DEPLOY {
-		_, err := chain.Deploy(ctxt, t)
+	if t.Type == pb.Transaction_CHAINCODE_DEPLOY || t.Type == pb.Transaction_CHAINCODE_UPGRADE {
+		cds, err := chain.Deploy(ctxt, t)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Failed to deploy chaincode spec(%s)", err)
+			return nil, nil, txFailure(pb.RejectionPhase_VM_BUILD, pb.RejectionReason_BUILD_FAILED, err, fmt.Errorf("Failed to deploy chaincode spec(%s)", err))
+		}
+		if cds == nil {
+			// the deployment spec is not returned when the user runs the chaincode
+			cds = &pb.ChaincodeDeploymentSpec{}
+			if err = proto.Unmarshal(t.Payload, cds); err != nil {
+				return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to unmarshal chaincode deployment spec(%s)", err)}
+			}
 		}
 
 		//launch and wait for ready
 		markTxBegin(ledger, t)
+		if t.Type == pb.Transaction_CHAINCODE_UPGRADE {
+			if err = authorizeUpgrade(chain.getSecHelper(), ledger, cds); err != nil {
+				markTxFinish(ledger, t, false)
+				return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to upgrade chaincode(%s)", err)}
+			}
+			// the new chaincode takes over the state of the upgraded chaincode from its Init
+			if err = recordUpgrade(ledger, cds); err != nil {
+				markTxFinish(ledger, t, false)
+				return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to upgrade chaincode(%s)", err)}
+			}
+		} else if cds.ExecEnv != pb.ChaincodeDeploymentSpec_SYSTEM {
+			if err = recordDeployer(chain.getSecHelper(), ledger, cds); err != nil {
+				markTxFinish(ledger, t, false)
+				return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to deploy chaincode(%s)", err)}
+			}
+		}
+		if cds.ExecEnv != pb.ChaincodeDeploymentSpec_SYSTEM {
+			if err = recordDeployment(ledger, cds); err != nil {
+				markTxFinish(ledger, t, false)
+				return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to deploy chaincode(%s)", err)}
+			}
+		}
+		chain.beginTxSimulation(t.Uuid, cds.ChaincodeSpec.ChaincodeID.Name, ledger)
 		_, _, err = chain.Launch(ctxt, t)
+		nestedErr := chain.endTxSimulation(t.Uuid)
 		if err != nil {
 			markTxFinish(ledger, t, false)
-			return nil, nil, fmt.Errorf("%s", err)
+			return nil, nil, txFailure(pb.RejectionPhase_EXECUTION, pb.RejectionReason_LAUNCH_FAILED, err, fmt.Errorf("%s", err))
+		} else if nestedErr != nil {
+			// Rollback the changes of the chaincodes invoked by the transaction too
+			markTxFinish(ledger, t, false)
+			return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_NESTED_FAILURE, Err: fmt.Errorf("Transaction failed: %s", nestedErr)}
 		}
 		markTxFinish(ledger, t, true)
 	} else if t.Type == pb.Transaction_CHAINCODE_INVOKE || t.Type == pb.Transaction_CHAINCODE_QUERY {
 		//will launch if necessary (and wait for ready)
 		cID, cMsg, err := chain.Launch(ctxt, t)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Failed to launch chaincode spec(%s)", err)
+			return nil, nil, txFailure(pb.RejectionPhase_EXECUTION, pb.RejectionReason_LAUNCH_FAILED, err, fmt.Errorf("Failed to launch chaincode spec(%s)", err))
 		}
 
 		//this should work because it worked above...
@@ -75,58 +130,60 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 			return nil, nil, fmt.Errorf("Failed to stablish stream to container %s", chaincode)
 		}
 
-		// TODO: Need to comment next line and uncomment call to getTimeout, when transaction blocks are being created
-		timeout := time.Duration(30000) * time.Millisecond
-		//timeout, err := getTimeout(cID)
-
-		if err != nil {
-			return nil, nil, fmt.Errorf("Failed to retrieve chaincode spec(%s)", err)
-		}
+		timeout := getChaincodeLimits(chaincode).executeTimeout
 
 		var ccMsg *pb.ChaincodeMessage
 		if t.Type == pb.Transaction_CHAINCODE_INVOKE {
 			ccMsg, err = createTransactionMessage(t.Uuid, cMsg)
 			if err != nil {
-				return nil, nil, fmt.Errorf("Failed to transaction message(%s)", err)
+				return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to transaction message(%s)", err)}
 			}
 		} else {
 			ccMsg, err = createQueryMessage(t.Uuid, cMsg)
 			if err != nil {
-				return nil, nil, fmt.Errorf("Failed to query message(%s)", err)
+				return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to query message(%s)", err)}
 			}
 		}
 
 		markTxBegin(ledger, t)
+		if t.Type == pb.Transaction_CHAINCODE_INVOKE {
+			chain.beginTxSimulation(t.Uuid, chaincode, ledger)
+		}
 		resp, err := chain.Execute(ctxt, chaincode, ccMsg, timeout, t)
+		nestedErr := chain.endTxSimulation(t.Uuid)
 		if err != nil {
 			// Rollback transaction
 			markTxFinish(ledger, t, false)
-			return nil, nil, fmt.Errorf("Failed to execute transaction or query(%s)", err)
+			return nil, nil, txFailure(pb.RejectionPhase_EXECUTION, pb.RejectionReason_CHAINCODE_ERROR, err, fmt.Errorf("Failed to execute transaction or query(%s)", err))
 		} else if resp == nil {
 			// Rollback transaction
 			markTxFinish(ledger, t, false)
-			return nil, nil, fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
+			return nil, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_CHAINCODE_ERROR, Err: fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)}
 		} else {
 			if resp.ChaincodeEvent != nil {
 				resp.ChaincodeEvent.ChaincodeID = chaincode
 				resp.ChaincodeEvent.TxID = t.Uuid
 			}
 
-			if resp.Type == pb.ChaincodeMessage_COMPLETED || resp.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
+			if resp.Type == pb.ChaincodeMessage_COMPLETED && nestedErr != nil {
+				// Rollback the changes of the chaincodes invoked by the transaction too, even if it handled the failure
+				markTxFinish(ledger, t, false)
+				return nil, resp.ChaincodeEvent, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_NESTED_FAILURE, Err: fmt.Errorf("Transaction failed: %s", nestedErr)}
+			} else if resp.Type == pb.ChaincodeMessage_COMPLETED || resp.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
 				// Success
 				markTxFinish(ledger, t, true)
 				return resp.Payload, resp.ChaincodeEvent, nil
 			} else if resp.Type == pb.ChaincodeMessage_ERROR || resp.Type == pb.ChaincodeMessage_QUERY_ERROR {
 				// Rollback transaction
 				markTxFinish(ledger, t, false)
-				return nil, resp.ChaincodeEvent, fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
+				return nil, resp.ChaincodeEvent, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_CHAINCODE_ERROR, Err: fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))}
 			}
 			markTxFinish(ledger, t, false)
-			return resp.Payload, nil, fmt.Errorf("receive a response for (%s) but in invalid state(%d)", t.Uuid, resp.Type)
+			return resp.Payload, nil, &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_CHAINCODE_ERROR, Err: fmt.Errorf("receive a response for (%s) but in invalid state(%d)", t.Uuid, resp.Type)}
 		}
 
 	} else {
-		err = fmt.Errorf("Invalid transaction type %s", t.Type.String())
+		err = &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Invalid transaction type %s", t.Type.String())}
 	}
 	return nil, nil, err
 }
@@ -134,7 +191,8 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 //ExecuteTransactions - will execute transactions on the array one by one
 //will return an array of errors one for each transaction. If the execution
 //succeeded, array element will be nil. returns []byte of state hash or
-//error
+//error. Each transaction is executed against the ledger of its ledger ID, the
+//state hash being the one of the default ledger
 func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) (stateHash []byte, ccevents []*pb.ChaincodeEvent, txerrs []error, err error) {
 	var chain = GetChain(cname)
 	if chain == nil {
@@ -144,7 +202,29 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 	txerrs = make([]error, len(xacts))
 	ccevents = make([]*pb.ChaincodeEvent, len(xacts))
 	for i, t := range xacts {
+		if err := ledger.CheckLedgerID(t.LedgerID); err != nil {
+			txerrs[i] = &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Transaction %s is for an unknown ledger: %s", t.Uuid, err)}
+			continue
+		}
+		// the validating peers and the revocation list are those of the
+		// default ledger
+		if t.LedgerID != "" && (t.Type == pb.Transaction_CONSENSUS_RECONFIGURE || t.Type == pb.Transaction_PKI_CRL_UPDATE) {
+			txerrs[i] = &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Transaction %s of type %s is for ledger %s, only the default ledger accepts it", t.Uuid, t.Type, t.LedgerID)}
+			continue
+		}
+		// reconfigurations are applied by the consensus plugin, they are only
+		// recorded in the block
+		if t.Type == pb.Transaction_CONSENSUS_RECONFIGURE {
+			continue
+		}
+		if t.Type == pb.Transaction_PKI_CRL_UPDATE {
+			txerrs[i] = updateRevocationList(chain, t)
+			continue
+		}
 		_, ccevents[i], txerrs[i] = Execute(ctxt, chain, t)
+		if txerrs[i] != nil {
+			flogging.FromContext(ctxt, chaincodeLogger).With("txid", t.Uuid).Debugf("Transaction failed: %s", txerrs[i])
+		}
 	}
 
 	var lgr *ledger.Ledger
@@ -191,6 +271,36 @@ func getTimeout(cID *pb.ChaincodeID) (time.Duration, error) {
 	return -1, errFailedToGetChainCodeSpecForTransaction
 }
 
+// updateRevocationList stores the revocation list of a PKI_CRL_UPDATE
+// transaction in the state, once the security layer verified that it is
+// signed by the ECA and more recent than the committed one. No chaincode is
+// executed.
+func updateRevocationList(chain *ChaincodeSupport, t *pb.Transaction) error {
+	secHelper := chain.getSecHelper()
+	if secHelper == nil {
+		return &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Transaction %s updates the revocation list, which requires security enabled", t.Uuid)}
+	}
+	if _, err := secHelper.TransactionPreValidation(t); err != nil {
+		return &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Invalid revocation list in transaction %s: %s", t.Uuid, err)}
+	}
+	revocationList := &pb.RevocationList{}
+	if err := proto.Unmarshal(t.Payload, revocationList); err != nil {
+		return &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_INVALID_TRANSACTION, Err: fmt.Errorf("Failed to unmarshal revocation list(%s)", err)}
+	}
+
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+	markTxBegin(ledger, t)
+	err = ledger.SetState(crypto.RevocationListNamespace, crypto.RevocationListKey, revocationList.Crl)
+	markTxFinish(ledger, t, err == nil)
+	if err != nil {
+		return fmt.Errorf("Failed to store revocation list(%s)", err)
+	}
+	return nil
+}
+
 func markTxBegin(ledger *ledger.Ledger, t *pb.Transaction) {
 	if t.Type == pb.Transaction_CHAINCODE_QUERY {
 		return
@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"io"
 	"sync"
-	"time"
 
 	"github.com/golang/protobuf/proto"
 	ccintf "github.com/hyperledger/fabric/core/container/ccintf"
@@ -33,6 +32,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/metrics"
 )
 
 const (
@@ -41,8 +41,8 @@ const (
 	initstate        = "init"        //in:ESTABLISHED, rcv:-, send: INIT
 	readystate       = "ready"       //in:ESTABLISHED,TRANSACTION, rcv:COMPLETED
 	transactionstate = "transaction" //in:READY, rcv: xact from consensus, send: TRANSACTION
-	busyinitstate    = "busyinit"    //in:INIT, rcv: PUT_STATE, DEL_STATE, INVOKE_CHAINCODE
-	busyxactstate    = "busyxact"    //in:TRANSACION, rcv: PUT_STATE, DEL_STATE, INVOKE_CHAINCODE
+	busyinitstate    = "busyinit"    //in:INIT, rcv: PUT_STATE, PUT_STATE_MULTIPLE_KEYS, DEL_STATE, PUT_PRIVATE_STATE, DEL_PRIVATE_STATE, INVOKE_CHAINCODE
+	busyxactstate    = "busyxact"    //in:TRANSACION, rcv: PUT_STATE, PUT_STATE_MULTIPLE_KEYS, DEL_STATE, PUT_PRIVATE_STATE, DEL_PRIVATE_STATE, INVOKE_CHAINCODE
 	endstate         = "end"         //in:INIT,ESTABLISHED, rcv: error, terminate container
 
 )
@@ -61,6 +61,9 @@ type transactionContext struct {
 
 	// tracks open iterators used for range queries
 	rangeQueryIteratorMap map[string]statemgmt.RangeScanIterator
+
+	// the QUERY_COMPLETED_CHUNK messages of the query result received so far
+	queryResult []byte
 }
 
 type nextStateInfo struct {
@@ -83,6 +86,10 @@ type Handler struct {
 	chaincodeSupport *ChaincodeSupport
 	registered       bool
 	readyNotify      chan bool
+	// set for the streams of the chaincodes registering over the network,
+	// which have to present the token they were launched with
+	authenticate      bool
+	registrationToken string
 	// Map of tx uuid to either invoke or query tx (decrypted). Each tx will be
 	// added prior to execute and remove when done execute
 	txCtxs map[string]*transactionContext
@@ -94,6 +101,12 @@ type Handler struct {
 
 	// used to do Send after making sure the state transition is complete
 	nextState chan *nextStateInfo
+
+	// namespace of the state of the chaincode in each ledger, by ledger ID
+	stateNamespaces map[string]string
+
+	// closed to end the stream when the chaincode reconnects in dev mode
+	disconnect chan struct{}
 }
 
 func shortuuid(uuid string) string {
@@ -110,6 +123,7 @@ func (handler *Handler) serialSend(msg *pb.ChaincodeMessage) error {
 		chaincodeLogger.Errorf("Error sending %s: %s", msg.Type.String(), err)
 		return fmt.Errorf("Error sending %s: %s", msg.Type.String(), err)
 	}
+	handler.metering().recordMessage(handler.chaincodeName(), msg, true)
 	return nil
 }
 
@@ -134,6 +148,20 @@ func (handler *Handler) getTxContext(uuid string) *transactionContext {
 	return handler.txCtxs[uuid]
 }
 
+// getLedgerID returns the ID of the ledger the transaction is executed against
+func (handler *Handler) getLedgerID(uuid string) string {
+	txContext := handler.getTxContext(uuid)
+	if txContext == nil || txContext.transactionSecContext == nil {
+		return ""
+	}
+	return txContext.transactionSecContext.LedgerID
+}
+
+// getLedger returns the ledger the transaction is executed against
+func (handler *Handler) getLedger(uuid string) (*ledger.Ledger, error) {
+	return ledger.GetLedgerByID(handler.getLedgerID(uuid))
+}
+
 func (handler *Handler) deleteTxContext(uuid string) {
 	handler.Lock()
 	defer handler.Unlock()
@@ -260,13 +288,29 @@ func (handler *Handler) deregister() error {
 	return nil
 }
 
+// replace ends the stream of the handler, which has been replaced by a new
+// registration of its chaincode, and fails the transactions it is executing
+func (handler *Handler) replace() {
+	handler.Lock()
+	defer handler.Unlock()
+	close(handler.disconnect)
+	for uuid, txctx := range handler.txCtxs {
+		msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte("Chaincode reconnected during the transaction"), Uuid: uuid}
+		select {
+		case txctx.responseNotifier <- msg:
+		default:
+		}
+	}
+}
+
 func (handler *Handler) triggerNextState(msg *pb.ChaincodeMessage, send bool) {
 	handler.nextState <- &nextStateInfo{msg, send}
 }
 
 func (handler *Handler) processStream() error {
 	defer handler.deregister()
-	msgAvail := make(chan *pb.ChaincodeMessage)
+	//buffered so that a pending Recv does not block once the stream has ended
+	msgAvail := make(chan *pb.ChaincodeMessage, 1)
 	var nsInfo *nextStateInfo
 	var in *pb.ChaincodeMessage
 	var err error
@@ -301,6 +345,7 @@ func (handler *Handler) processStream() error {
 				return err
 			}
 			chaincodeLogger.Debugf("[%s]Received message %s from shim", shortuuid(in.Uuid), in.Type.String())
+			handler.metering().recordMessage(handler.chaincodeName(), in, false)
 			if in.Type.String() == pb.ChaincodeMessage_ERROR.String() {
 				chaincodeLogger.Debugf("Got error: %s", string(in.Payload))
 			}
@@ -315,6 +360,9 @@ func (handler *Handler) processStream() error {
 				return err
 			}
 			chaincodeLogger.Debugf("[%s]Move state message %s", shortuuid(in.Uuid), in.Type.String())
+		case <-handler.disconnect:
+			chaincodeLogger.Debugf("Chaincode %s reconnected, ending previous chaincode support stream", handler.ChaincodeID.Name)
+			return fmt.Errorf("Chaincode %s reconnected, ending previous chaincode support stream", handler.ChaincodeID.Name)
 		}
 		err = handler.HandleMessage(in)
 		if err != nil {
@@ -335,6 +383,7 @@ func (handler *Handler) processStream() error {
 func HandleChaincodeStream(chaincodeSupport *ChaincodeSupport, ctxt context.Context, stream ccintf.ChaincodeStream) error {
 	deadline, ok := ctxt.Deadline()
 	chaincodeLogger.Debugf("Current context deadline = %s, ok = %v", deadline, ok)
+	defer metrics.StreamOpened("chaincode")()
 	handler := newChaincodeSupportHandler(chaincodeSupport, stream)
 	return handler.processStream()
 }
@@ -344,6 +393,8 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 		ChatStream: peerChatStream,
 	}
 	v.chaincodeSupport = chaincodeSupport
+	v.stateNamespaces = make(map[string]string)
+	v.disconnect = make(chan struct{})
 	//we want this to block
 	v.nextState = make(chan *nextStateInfo)
 
@@ -356,10 +407,16 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_READY.String(), Src: []string{establishedstate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_TRANSACTION.String(), Src: []string{readystate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_PUT_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_PUT_STATE_MULTIPLE_KEYS.String(), Src: []string{transactionstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_DEL_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_PUT_PRIVATE_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_DEL_PRIVATE_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_INVOKE_CHAINCODE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_PUT_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_PUT_STATE_MULTIPLE_KEYS.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_DEL_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_PUT_PRIVATE_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_DEL_PRIVATE_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_INVOKE_CHAINCODE.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_COMPLETED.String(), Src: []string{initstate, readystate, transactionstate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{readystate}, Dst: readystate},
@@ -367,6 +424,21 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{transactionstate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{initstate}, Dst: initstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
@@ -394,11 +466,17 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			"before_" + pb.ChaincodeMessage_COMPLETED.String():              func(e *fsm.Event) { v.beforeCompletedEvent(e, v.FSM.Current()) },
 			"before_" + pb.ChaincodeMessage_INIT.String():                   func(e *fsm.Event) { v.beforeInitState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_GET_STATE.String():               func(e *fsm.Event) { v.afterGetState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS.String(): func(e *fsm.Event) { v.afterGetStateMultipleKeys(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE.String():       func(e *fsm.Event) { v.afterRangeQueryState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String():  func(e *fsm.Event) { v.afterRangeQueryStateNext(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(): func(e *fsm.Event) { v.afterRangeQueryStateClose(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_PUT_STATE.String():               func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_PUT_STATE_MULTIPLE_KEYS.String(): func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_DEL_STATE.String():               func(e *fsm.Event) { v.afterDelState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_PRIVATE_STATE.String():       func(e *fsm.Event) { v.afterGetPrivateState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH.String():  func(e *fsm.Event) { v.afterGetPrivateState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_PUT_PRIVATE_STATE.String():       func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_DEL_PRIVATE_STATE.String():       func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_INVOKE_CHAINCODE.String():        func(e *fsm.Event) { v.afterInvokeChaincode(e, v.FSM.Current()) },
 			"enter_" + establishedstate:                                     func(e *fsm.Event) { v.enterEstablishedState(e, v.FSM.Current()) },
 			"enter_" + initstate:                                            func(e *fsm.Event) { v.enterInitState(e, v.FSM.Current()) },
@@ -455,6 +533,12 @@ func (handler *Handler) getIsTransaction(uuid string) bool {
 	return handler.isTransaction[uuid]
 }
 
+// readsUncommittedState returns true if the chaincode reads the state changes
+// made so far by the transaction, when it is invoked or queried on behalf of it
+func (handler *Handler) readsUncommittedState(uuid string) bool {
+	return handler.getIsTransaction(uuid) || handler.chaincodeSupport.inTxSimulation(uuid)
+}
+
 func (handler *Handler) deleteIsTransaction(uuid string) {
 	handler.Lock()
 	defer handler.Unlock()
@@ -505,6 +589,33 @@ func (handler *Handler) beforeRegisterEvent(e *fsm.Event, state string) {
 	}
 }
 
+// appendQueryChunk adds a QUERY_COMPLETED_CHUNK message to the query result
+// of its transaction context, the result is notified with the QUERY_COMPLETED
+// message which ends it
+func (handler *Handler) appendQueryChunk(msg *pb.ChaincodeMessage) {
+	handler.Lock()
+	defer handler.Unlock()
+	if tctx := handler.txCtxs[msg.Uuid]; tctx != nil {
+		tctx.queryResult = append(tctx.queryResult, msg.Payload...)
+	} else {
+		chaincodeLogger.Debugf("[%s]Query result chunk for unknown transaction context", shortuuid(msg.Uuid))
+	}
+}
+
+// takeQueryResult returns the chunks of the query result received for uuid
+// and releases them
+func (handler *Handler) takeQueryResult(uuid string) []byte {
+	handler.Lock()
+	defer handler.Unlock()
+	tctx := handler.txCtxs[uuid]
+	if tctx == nil {
+		return nil
+	}
+	queryResult := tctx.queryResult
+	tctx.queryResult = nil
+	return queryResult
+}
+
 func (handler *Handler) notify(msg *pb.ChaincodeMessage) {
 	handler.Lock()
 	defer handler.Unlock()
@@ -576,7 +687,7 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 		}()
 
 		key := string(msg.Payload)
-		ledgerObj, ledgerErr := ledger.GetLedger()
+		ledgerObj, ledgerErr := handler.getLedger(msg.Uuid)
 		if ledgerErr != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(ledgerErr.Error())
@@ -587,10 +698,12 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 		}
 
 		// Invoke ledger to get state
-		chaincodeID := handler.ChaincodeID.Name
-
-		readCommittedState := !handler.getIsTransaction(msg.Uuid)
-		res, err := ledgerObj.GetState(chaincodeID, key, readCommittedState)
+		readCommittedState := !handler.readsUncommittedState(msg.Uuid)
+		chaincodeID, err := handler.getStateNamespace(msg.Uuid, ledgerObj)
+		var res []byte
+		if err == nil {
+			res, err = ledgerObj.GetState(chaincodeID, key, readCommittedState)
+		}
 		if err != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
@@ -606,6 +719,7 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 				// Send response msg back to chaincode. GetState will not trigger event
 				chaincodeLogger.Debugf("[%s]Got state. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
 				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
+				handler.metering().recordKeysRead(handler.chaincodeName(), 1)
 			} else {
 				// Send err msg back to chaincode.
 				chaincodeLogger.Errorf("[%s]Got error (%s) while decrypting. Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR)
@@ -618,7 +732,86 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 	}()
 }
 
-const maxRangeQueryStateLimit = 100
+// afterGetStateMultipleKeys handles a GET_STATE_MULTIPLE_KEYS request from the chaincode.
+func (handler *Handler) afterGetStateMultipleKeys(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debugf("[%s]Received %s, invoking get state from ledger", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS)
+
+	// Query ledger for state
+	handler.handleGetStateMultipleKeys(msg)
+}
+
+// Handles query to ledger to get the state of multiple keys
+func (handler *Handler) handleGetStateMultipleKeys(msg *pb.ChaincodeMessage) {
+	// See handleGetState for why the state is retrieved in a go routine
+	go func() {
+		// Check if this is the unique state request from this chaincode uuid
+		uniqueReq := handler.createUUIDEntry(msg.Uuid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Debug("Another state request pending for this Uuid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteUUIDEntry(msg.Uuid)
+			chaincodeLogger.Debugf("[%s]handleGetStateMultipleKeys serial send %s", shortuuid(serialSendMsg.Uuid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		getStateMultipleKeys := &pb.GetStateMultipleKeys{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, getStateMultipleKeys)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Errorf("[%s]Failed to unmarshall get state request. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		ledgerObj, ledgerErr := handler.getLedger(msg.Uuid)
+		if ledgerErr != nil {
+			payload := []byte(ledgerErr.Error())
+			chaincodeLogger.Errorf("Failed to get chaincode state(%s). Sending %s", ledgerErr, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		// Invoke ledger to get state
+		readCommittedState := !handler.readsUncommittedState(msg.Uuid)
+		chaincodeID, err := handler.getStateNamespace(msg.Uuid, ledgerObj)
+		var values [][]byte
+		if err == nil {
+			values, err = ledgerObj.GetStateMultipleKeys(chaincodeID, getStateMultipleKeys.Keys, readCommittedState)
+		}
+		for i := 0; err == nil && i < len(values); i++ {
+			// Decrypt the data if the confidential is enabled, the values of keys which do not exist are left empty
+			if values[i] != nil {
+				values[i], err = handler.decrypt(msg.Uuid, values[i])
+			}
+		}
+		var payload []byte
+		if err == nil {
+			payload, err = proto.Marshal(&pb.GetStateMultipleKeysResponse{Values: values})
+		}
+		if err != nil {
+			// Send error msg back to chaincode. GetState will not trigger event
+			chaincodeLogger.Errorf("[%s]Failed to get chaincode state(%s). Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
+
+		// Send response msg back to chaincode. GetState will not trigger event
+		chaincodeLogger.Debugf("[%s]Got state of %d keys. Sending %s", shortuuid(msg.Uuid), len(values), pb.ChaincodeMessage_RESPONSE)
+		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payload, Uuid: msg.Uuid}
+		handler.metering().recordKeysRead(handler.chaincodeName(), len(values))
+	}()
+}
 
 // afterRangeQueryState handles a RANGE_QUERY_STATE request from the chaincode.
 func (handler *Handler) afterRangeQueryState(e *fsm.Event, state string) {
@@ -667,7 +860,7 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 
 		hasNext := true
 
-		ledger, ledgerErr := ledger.GetLedger()
+		ledger, ledgerErr := handler.getLedger(msg.Uuid)
 		if ledgerErr != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(ledgerErr.Error())
@@ -676,10 +869,12 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		chaincodeID := handler.ChaincodeID.Name
-
-		readCommittedState := !handler.getIsTransaction(msg.Uuid)
-		rangeIter, err := ledger.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState)
+		readCommittedState := !handler.readsUncommittedState(msg.Uuid)
+		chaincodeID, err := handler.getStateNamespace(msg.Uuid, ledger)
+		var rangeIter statemgmt.RangeScanIterator
+		if err == nil {
+			rangeIter, err = ledger.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState)
+		}
 		if err != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
@@ -696,7 +891,7 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 
 		var keysAndValues []*pb.RangeQueryStateKeyValue
 		var i = uint32(0)
-		for ; hasNext && i < maxRangeQueryStateLimit; i++ {
+		for ; hasNext && i < handler.chaincodeSupport.rangeQueryBatchSize; i++ {
 			key, value := rangeIter.GetKeyValue()
 			// Decrypt the data if the confidential is enabled
 			decryptedValue, err := handler.decrypt(msg.Uuid, value)
@@ -736,6 +931,7 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 
 		chaincodeLogger.Debugf("Got keys and values. Sending %s", pb.ChaincodeMessage_RESPONSE)
 		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Uuid: msg.Uuid}
+		handler.metering().recordKeysRead(handler.chaincodeName(), len(keysAndValues))
 
 	}()
 }
@@ -798,7 +994,7 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 		var keysAndValues []*pb.RangeQueryStateKeyValue
 		var i = uint32(0)
 		hasNext := true
-		for ; hasNext && i < maxRangeQueryStateLimit; i++ {
+		for ; hasNext && i < handler.chaincodeSupport.rangeQueryBatchSize; i++ {
 			key, value := rangeIter.GetKeyValue()
 			// Decrypt the data if the confidential is enabled
 			decryptedValue, err := handler.decrypt(msg.Uuid, value)
@@ -838,6 +1034,7 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 
 		chaincodeLogger.Debugf("Got keys and values. Sending %s", pb.ChaincodeMessage_RESPONSE)
 		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Uuid: msg.Uuid}
+		handler.metering().recordKeysRead(handler.chaincodeName(), len(keysAndValues))
 
 	}()
 }
@@ -911,14 +1108,14 @@ func (handler *Handler) handleRangeQueryStateClose(msg *pb.ChaincodeMessage) {
 	}()
 }
 
-// afterPutState handles a PUT_STATE request from the chaincode.
+// afterPutState handles a PUT_STATE, PUT_STATE_MULTIPLE_KEYS, PUT_PRIVATE_STATE or DEL_PRIVATE_STATE request from the chaincode.
 func (handler *Handler) afterPutState(e *fsm.Event, state string) {
-	_, ok := e.Args[0].(*pb.ChaincodeMessage)
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
-	chaincodeLogger.Debugf("Received %s in state %s, invoking put state to ledger", pb.ChaincodeMessage_PUT_STATE, state)
+	chaincodeLogger.Debugf("Received %s in state %s, invoking put state to ledger", msg.Type, state)
 
 	// Put state into ledger handled within enterBusyState
 }
@@ -977,7 +1174,7 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			handler.triggerNextState(triggerNextStateMsg, true)
 		}()
 
-		ledgerObj, ledgerErr := ledger.GetLedger()
+		ledgerObj, ledgerErr := handler.getLedger(msg.Uuid)
 		if ledgerErr != nil {
 			// Send error msg back to chaincode and trigger event
 			payload := []byte(ledgerErr.Error())
@@ -986,9 +1183,15 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			return
 		}
 
-		chaincodeID := handler.ChaincodeID.Name
-		var err error
+		// The state of the chaincode is the state of the chaincode it upgraded, if any
+		chaincodeID, err := handler.getStateNamespace(msg.Uuid, ledgerObj)
+		if err != nil {
+			chaincodeLogger.Errorf("[%s]Failed to handle %s: %s. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), err, pb.ChaincodeMessage_ERROR)
+			triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
 		var res []byte
+		keysWritten := 0
 
 		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() {
 			putStateInfo := &pb.PutStateInfo{}
@@ -1005,11 +1208,40 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			if pVal, err = handler.encrypt(msg.Uuid, putStateInfo.Value); err == nil {
 				// Invoke ledger to put state
 				err = ledgerObj.SetState(chaincodeID, putStateInfo.Key, pVal)
+				keysWritten = 1
+			}
+		} else if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE_MULTIPLE_KEYS.String() {
+			putStateMultipleKeys := &pb.PutStateMultipleKeys{}
+			unmarshalErr := proto.Unmarshal(msg.Payload, putStateMultipleKeys)
+			if unmarshalErr != nil {
+				payload := []byte(unmarshalErr.Error())
+				chaincodeLogger.Debugf("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+				return
+			}
+
+			kvs := make(map[string][]byte)
+			for _, putStateInfo := range putStateMultipleKeys.KeyValues {
+				var pVal []byte
+				// Encrypt the data if the confidential is enabled
+				if pVal, err = handler.encrypt(msg.Uuid, putStateInfo.Value); err != nil {
+					break
+				}
+				kvs[putStateInfo.Key] = pVal
+			}
+			if err == nil {
+				// Invoke ledger to put state
+				err = ledgerObj.SetStateMultipleKeys(chaincodeID, kvs)
+				keysWritten = len(kvs)
 			}
 		} else if msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() {
 			// Invoke ledger to delete state
 			key := string(msg.Payload)
 			err = ledgerObj.DeleteState(chaincodeID, key)
+			keysWritten = 1
+		} else if msg.Type == pb.ChaincodeMessage_PUT_PRIVATE_STATE || msg.Type == pb.ChaincodeMessage_DEL_PRIVATE_STATE {
+			err = handler.putPrivateState(ledgerObj, chaincodeID, msg)
+			keysWritten = 1
 		} else if msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
 			//check and prohibit C-call-C for CONFIDENTIAL txs
 			if triggerNextStateMsg = handler.canCallChaincode(msg.Uuid); triggerNextStateMsg != nil {
@@ -1024,26 +1256,41 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 				return
 			}
 
-			// Get the chaincodeID to invoke
-			newChaincodeID := chaincodeSpec.ChaincodeID.Name
+			// Get the chaincodeID to invoke, the latest version if it has been upgraded
+			newChaincodeID, resolveErr := handler.getCurrentChaincode(msg.Uuid, chaincodeSpec.ChaincodeID.Name)
+			if resolveErr != nil {
+				chaincodeLogger.Errorf("[%s]Failed to invoke chaincode %s: %s. Sending %s", shortuuid(msg.Uuid), chaincodeSpec.ChaincodeID.Name, resolveErr, pb.ChaincodeMessage_ERROR)
+				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(resolveErr.Error()), Uuid: msg.Uuid}
+				return
+			}
+			chaincodeSpec.ChaincodeID.Name = newChaincodeID
 
-			// Create the transaction object
+			// Create the transaction object, the invoked chaincode runs against the same ledger
+			chaincodeSpec.LedgerID = handler.getLedgerID(msg.Uuid)
 			chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: chaincodeSpec}
 			transaction, _ := pb.NewChaincodeExecute(chaincodeInvocationSpec, msg.Uuid, pb.Transaction_CHAINCODE_INVOKE)
 
+			// The invoked chaincode runs in a nested scope of the transaction
+			if enterErr := handler.chaincodeSupport.enterChaincode(msg.Uuid, newChaincodeID, false); enterErr != nil {
+				chaincodeLogger.Errorf("[%s]Failed to invoke chaincode %s: %s. Sending %s", shortuuid(msg.Uuid), newChaincodeID, enterErr, pb.ChaincodeMessage_ERROR)
+				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(enterErr.Error()), Uuid: msg.Uuid}
+				return
+			}
+
 			// Launch the new chaincode if not already running
 			_, chaincodeInput, launchErr := handler.chaincodeSupport.Launch(context.Background(), transaction)
 			if launchErr != nil {
+				handler.chaincodeSupport.exitChaincode(msg.Uuid, false, launchErr)
 				payload := []byte(launchErr.Error())
 				chaincodeLogger.Debugf("[%s]Failed to launch invoked chaincode. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
 				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 				return
 			}
 
-			// TODO: Need to handle timeout correctly
-			timeout := time.Duration(30000) * time.Millisecond
+			timeout := getChaincodeLimits(newChaincodeID).executeTimeout
 
 			ccMsg, _ := createTransactionMessage(transaction.Uuid, chaincodeInput)
+			handler.metering().recordCall(handler.chaincodeName(), newChaincodeID)
 
 			// Execute the chaincode
 			//NOTE: when confidential C-call-C is understood, transaction should have the correct sec context for enc/dec
@@ -1057,6 +1304,13 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			} else {
 				res, err = proto.Marshal(response)
 			}
+
+			// The state changes of the invoked chaincode are merged into the transaction unless it failed
+			invocationErr := execErr
+			if invocationErr == nil && response.Type == pb.ChaincodeMessage_ERROR {
+				invocationErr = fmt.Errorf("%s", response.Payload)
+			}
+			handler.chaincodeSupport.exitChaincode(msg.Uuid, false, invocationErr)
 		}
 
 		if err != nil {
@@ -1067,6 +1321,10 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			return
 		}
 
+		if keysWritten > 0 {
+			handler.metering().recordKeysWritten(handler.chaincodeName(), keysWritten)
+		}
+
 		// Send response msg back to chaincode.
 		chaincodeLogger.Debugf("[%s]Completed %s. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_RESPONSE)
 		triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
@@ -1218,6 +1476,13 @@ func (handler *Handler) setChaincodeSecurityContext(tx *pb.Transaction, msg *pb.
 			msg.SecurityContext.Payload = ctorMsgRaw
 		}
 		msg.SecurityContext.TxTimestamp = tx.Timestamp
+
+		// The co-signers were verified against the policy of the transaction
+		// when it was pre-validated
+		msg.SecurityContext.Signers = nil
+		for _, signature := range tx.GetMultiSignature().GetSignatures() {
+			msg.SecurityContext.Signers = append(msg.SecurityContext.Signers, signature.Cert)
+		}
 	}
 	return nil
 }
@@ -1254,6 +1519,9 @@ func (handler *Handler) initOrReady(uuid string, f *string, initArgs []string, t
 		ccMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_READY, Uuid: uuid}
 		send = true
 	}
+	if tx != nil {
+		ccMsg.LedgerID = tx.LedgerID
+	}
 
 	if err := handler.initializeSecContext(tx, depTx); err != nil {
 		handler.deleteTxContext(uuid)
@@ -1302,13 +1570,28 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		// Get the chaincodeID to invoke
-		newChaincodeID := chaincodeSpec.ChaincodeID.Name
+		// Get the chaincodeID to invoke, the latest version if it has been upgraded
+		newChaincodeID, resolveErr := handler.getCurrentChaincode(msg.Uuid, chaincodeSpec.ChaincodeID.Name)
+		if resolveErr != nil {
+			chaincodeLogger.Errorf("[%s]Failed to query chaincode %s: %s. Sending %s", shortuuid(msg.Uuid), chaincodeSpec.ChaincodeID.Name, resolveErr, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(resolveErr.Error()), Uuid: msg.Uuid}
+			return
+		}
+		chaincodeSpec.ChaincodeID.Name = newChaincodeID
 
-		// Create the transaction object
+		// Create the transaction object, the queried chaincode runs against the same ledger
+		chaincodeSpec.LedgerID = handler.getLedgerID(msg.Uuid)
 		chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: chaincodeSpec}
 		transaction, _ := pb.NewChaincodeExecute(chaincodeInvocationSpec, msg.Uuid, pb.Transaction_CHAINCODE_QUERY)
 
+		// The queried chaincode reads the state changes made so far by the transaction, if any
+		if enterErr := handler.chaincodeSupport.enterChaincode(msg.Uuid, newChaincodeID, true); enterErr != nil {
+			chaincodeLogger.Errorf("[%s]Failed to query chaincode %s: %s. Sending %s", shortuuid(msg.Uuid), newChaincodeID, enterErr, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(enterErr.Error()), Uuid: msg.Uuid}
+			return
+		}
+		defer handler.chaincodeSupport.exitChaincode(msg.Uuid, true, nil)
+
 		// Launch the new chaincode if not already running
 		_, chaincodeInput, launchErr := handler.chaincodeSupport.Launch(context.Background(), transaction)
 		if launchErr != nil {
@@ -1318,10 +1601,10 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		// TODO: Need to handle timeout correctly
-		timeout := time.Duration(30000) * time.Millisecond
+		timeout := getChaincodeLimits(newChaincodeID).executeTimeout
 
 		ccMsg, _ := createQueryMessage(transaction.Uuid, chaincodeInput)
+		handler.metering().recordCall(handler.chaincodeName(), newChaincodeID)
 
 		// Query the chaincode
 		//NOTE: when confidential C-call-C is understood, transaction should have the correct sec context for enc/dec
@@ -1355,9 +1638,16 @@ func (handler *Handler) HandleMessage(msg *pb.ChaincodeMessage) error {
 	chaincodeLogger.Debugf("[%s]Handling ChaincodeMessage of type: %s in state %s", shortuuid(msg.Uuid), msg.Type, handler.FSM.Current())
 
 	//QUERY_COMPLETED message can happen ONLY for Transaction_QUERY (stateless)
-	if msg.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
+	if msg.Type == pb.ChaincodeMessage_QUERY_COMPLETED_CHUNK {
+		chaincodeLogger.Debugf("[%s]HandleMessage- QUERY_COMPLETED_CHUNK of %d bytes", msg.Uuid, len(msg.Payload))
+		handler.appendQueryChunk(msg)
+		return nil
+	} else if msg.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
 		chaincodeLogger.Debugf("[%s]HandleMessage- QUERY_COMPLETED. Notify", msg.Uuid)
 		handler.deleteIsTransaction(msg.Uuid)
+		if queryResult := handler.takeQueryResult(msg.Uuid); queryResult != nil {
+			msg.Payload = append(queryResult, msg.Payload...)
+		}
 		var err error
 		if msg.Payload, err = handler.encrypt(msg.Uuid, msg.Payload); nil != err {
 			chaincodeLogger.Debugf("[%s]Failed to encrypt query result %s", msg.Uuid, string(msg.Payload))
@@ -1379,7 +1669,8 @@ func (handler *Handler) HandleMessage(msg *pb.ChaincodeMessage) error {
 	}
 	if handler.FSM.Cannot(msg.Type.String()) {
 		// Check if this is a request from validator in query context
-		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
+		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() ||
+			msg.Type == pb.ChaincodeMessage_PUT_PRIVATE_STATE || msg.Type == pb.ChaincodeMessage_DEL_PRIVATE_STATE {
 			// Check if this UUID is a transaction
 			if !handler.getIsTransaction(msg.Uuid) {
 				payload := []byte(fmt.Sprintf("[%s]Cannot handle %s in query context", msg.Uuid, msg.Type.String()))
@@ -1429,6 +1720,7 @@ func (handler *Handler) sendExecuteMessage(msg *pb.ChaincodeMessage, tx *pb.Tran
 	if err != nil {
 		return nil, err
 	}
+	msg.LedgerID = tx.LedgerID
 
 	// Mark UUID as either transaction or query
 	chaincodeLogger.Debugf("[%s]Inside sendExecuteMessage. Message %s", shortuuid(msg.Uuid), msg.Type.String())
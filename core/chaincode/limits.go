@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/container/ccintf"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// chaincodeLimits are the execution timeout and the resource limits of a
+// chaincode
+type chaincodeLimits struct {
+	executeTimeout time.Duration
+	resources      ccintf.ResourceLimits
+}
+
+// getChaincodeLimits returns the limits of chaincode configured in the
+// chaincode section of core.yaml. The values under chaincode.overrides.<name>
+// replace the global values for the chaincode of that name.
+func getChaincodeLimits(chaincode string) chaincodeLimits {
+	executeTimeout := viper.GetInt("chaincode.executetimeout")
+	memory := viper.GetInt("chaincode.limits.memory")
+	cpuShares := viper.GetInt("chaincode.limits.cpushares")
+
+	// viper splits keys on dots, so the overrides are looked up in the map
+	// rather than by key
+	if override, ok := viper.GetStringMap("chaincode.overrides")[chaincode]; ok {
+		values := cast.ToStringMap(override)
+		if v, ok := values["executetimeout"]; ok {
+			executeTimeout = cast.ToInt(v)
+		}
+		if v, ok := values["memory"]; ok {
+			memory = cast.ToInt(v)
+		}
+		if v, ok := values["cpushares"]; ok {
+			cpuShares = cast.ToInt(v)
+		}
+	}
+
+	if executeTimeout <= 0 {
+		executeTimeout = chaincodeExecuteTimeoutDefault
+	}
+	return chaincodeLimits{
+		executeTimeout: time.Duration(executeTimeout) * time.Millisecond,
+		// the memory limit is configured in megabytes
+		resources: ccintf.ResourceLimits{Memory: int64(memory) * 1024 * 1024, CPUShares: int64(cpuShares)},
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/spf13/viper"
+)
+
+func TestChaincodeLimits(t *testing.T) {
+	// restore the configuration read by TestMain for the other tests
+	for _, key := range []string{"chaincode.executetimeout", "chaincode.limits.memory", "chaincode.limits.cpushares", "chaincode.overrides"} {
+		defer viper.Set(key, viper.Get(key))
+	}
+	viper.Set("chaincode.executetimeout", 0)
+	viper.Set("chaincode.limits.memory", 256)
+	viper.Set("chaincode.limits.cpushares", 512)
+	viper.Set("chaincode.overrides", map[string]interface{}{
+		"slowcc": map[interface{}]interface{}{"executetimeout": 60000, "memory": 1024},
+	})
+
+	limits := getChaincodeLimits("mycc")
+	testutil.AssertEquals(t, limits.executeTimeout, time.Duration(chaincodeExecuteTimeoutDefault)*time.Millisecond)
+	testutil.AssertEquals(t, limits.resources.Memory, int64(256*1024*1024))
+	testutil.AssertEquals(t, limits.resources.CPUShares, int64(512))
+
+	limits = getChaincodeLimits("slowcc")
+	testutil.AssertEquals(t, limits.executeTimeout, 60*time.Second)
+	testutil.AssertEquals(t, limits.resources.Memory, int64(1024*1024*1024))
+	testutil.AssertEquals(t, limits.resources.CPUShares, int64(512))
+}
@@ -0,0 +1,173 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/metrics"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// The metrics of the executions exported to Prometheus, by chaincode and
+// message type, whether metering is on or not
+var (
+	executionSeconds = metrics.NewHistogram("fabric_chaincode_execution_seconds",
+		"Time taken by the chaincode to execute a transaction or query, by chaincode and message type",
+		nil, "chaincode", "type")
+	executionErrors = metrics.NewCounter("fabric_chaincode_execution_errors_total",
+		"Number of transactions and queries which failed in the chaincode, by chaincode and message type",
+		"chaincode", "type")
+)
+
+func init() {
+	metrics.MustRegister(executionSeconds, executionErrors)
+}
+
+// ChaincodeMetrics is the usage of a chaincode on this peer since it started
+type ChaincodeMetrics struct {
+	// Invocations and Queries are the invokes and queries executed, including
+	// the ones made by other chaincodes, Errors the ones which failed
+	Invocations uint64 `json:"invocations"`
+	Queries     uint64 `json:"queries"`
+	Errors      uint64 `json:"errors"`
+	// ExecutionTime is the total time of the invokes and queries, in
+	// milliseconds
+	ExecutionTime float64 `json:"executionTime"`
+	KeysRead      uint64  `json:"keysRead"`
+	KeysWritten   uint64  `json:"keysWritten"`
+	// BytesSent and BytesReceived are the sizes of the messages sent to and
+	// received from the chaincode over the shim stream
+	BytesSent     uint64 `json:"bytesSent"`
+	BytesReceived uint64 `json:"bytesReceived"`
+	// Calls is the call graph of the chaincode: the number of invokes and
+	// queries it made, by the name of the chaincode called
+	Calls map[string]uint64 `json:"calls,omitempty"`
+}
+
+// chaincodeMetering accumulates the metrics of the chaincodes, by name
+type chaincodeMetering struct {
+	sync.Mutex
+	metrics map[string]*ChaincodeMetrics
+}
+
+func newChaincodeMetering() *chaincodeMetering {
+	return &chaincodeMetering{metrics: make(map[string]*ChaincodeMetrics)}
+}
+
+// record updates the metrics of chaincode under lock. Metering is off for a
+// ChaincodeSupport created without it.
+func (metering *chaincodeMetering) record(chaincode string, update func(*ChaincodeMetrics)) {
+	if metering == nil {
+		return
+	}
+	metering.Lock()
+	defer metering.Unlock()
+	m, ok := metering.metrics[chaincode]
+	if !ok {
+		m = &ChaincodeMetrics{}
+		metering.metrics[chaincode] = m
+	}
+	update(m)
+}
+
+func (metering *chaincodeMetering) recordExecution(chaincode string, msgType pb.ChaincodeMessage_Type, elapsed time.Duration, failed bool) {
+	executionSeconds.Observe(elapsed.Seconds(), chaincode, msgType.String())
+	if failed {
+		executionErrors.Inc(chaincode, msgType.String())
+	}
+	metering.record(chaincode, func(m *ChaincodeMetrics) {
+		if msgType == pb.ChaincodeMessage_QUERY {
+			m.Queries++
+		} else {
+			m.Invocations++
+		}
+		if failed {
+			m.Errors++
+		}
+		m.ExecutionTime += float64(elapsed) / float64(time.Millisecond)
+	})
+}
+
+func (metering *chaincodeMetering) recordKeysRead(chaincode string, keys int) {
+	metering.record(chaincode, func(m *ChaincodeMetrics) { m.KeysRead += uint64(keys) })
+}
+
+func (metering *chaincodeMetering) recordKeysWritten(chaincode string, keys int) {
+	metering.record(chaincode, func(m *ChaincodeMetrics) { m.KeysWritten += uint64(keys) })
+}
+
+func (metering *chaincodeMetering) recordMessage(chaincode string, msg *pb.ChaincodeMessage, sent bool) {
+	size := uint64(proto.Size(msg))
+	metering.record(chaincode, func(m *ChaincodeMetrics) {
+		if sent {
+			m.BytesSent += size
+		} else {
+			m.BytesReceived += size
+		}
+	})
+}
+
+func (metering *chaincodeMetering) recordCall(caller string, callee string) {
+	metering.record(caller, func(m *ChaincodeMetrics) {
+		if m.Calls == nil {
+			m.Calls = make(map[string]uint64)
+		}
+		m.Calls[callee]++
+	})
+}
+
+// GetMetrics returns a copy of the metrics of the chaincodes executed by this
+// peer, by chaincode name
+func (chaincodeSupport *ChaincodeSupport) GetMetrics() map[string]*ChaincodeMetrics {
+	metrics := make(map[string]*ChaincodeMetrics)
+	metering := chaincodeSupport.metering
+	if metering == nil {
+		return metrics
+	}
+	metering.Lock()
+	defer metering.Unlock()
+	for chaincode, m := range metering.metrics {
+		c := *m
+		if m.Calls != nil {
+			c.Calls = make(map[string]uint64)
+			for callee, count := range m.Calls {
+				c.Calls[callee] = count
+			}
+		}
+		metrics[chaincode] = &c
+	}
+	return metrics
+}
+
+// chaincodeName returns the name of the chaincode of the handler, which is
+// unknown until it registered
+func (handler *Handler) chaincodeName() string {
+	if handler.ChaincodeID == nil {
+		return ""
+	}
+	return handler.ChaincodeID.Name
+}
+
+func (handler *Handler) metering() *chaincodeMetering {
+	if handler.chaincodeSupport == nil {
+		return nil
+	}
+	return handler.chaincodeSupport.metering
+}
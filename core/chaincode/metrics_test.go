@@ -0,0 +1,67 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type nullChaincodeStream struct{}
+
+func (nullChaincodeStream) Send(*pb.ChaincodeMessage) error {
+	return nil
+}
+
+func (nullChaincodeStream) Recv() (*pb.ChaincodeMessage, error) {
+	return nil, nil
+}
+
+func TestChaincodeMetering(t *testing.T) {
+	chaincodeSupport := &ChaincodeSupport{metering: newChaincodeMetering()}
+	handler := newChaincodeSupportHandler(chaincodeSupport, nullChaincodeStream{})
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: []byte("value"), Uuid: "txUuid"}
+	testutil.AssertNoError(t, handler.serialSend(msg), "Error sending message")
+	metering := chaincodeSupport.metering
+	metering.recordMessage("mycc", msg, false)
+	metering.recordExecution("mycc", pb.ChaincodeMessage_TRANSACTION, 2*time.Millisecond, false)
+	metering.recordExecution("mycc", pb.ChaincodeMessage_QUERY, time.Millisecond, true)
+	metering.recordKeysRead("mycc", 3)
+	metering.recordKeysWritten("mycc", 2)
+	metering.recordCall("mycc", "othercc")
+	metering.recordCall("mycc", "othercc")
+
+	metrics := chaincodeSupport.GetMetrics()
+	size := uint64(proto.Size(msg))
+	testutil.AssertEquals(t, metrics["mycc"], &ChaincodeMetrics{Invocations: 1, Queries: 1, Errors: 1, ExecutionTime: 3,
+		KeysRead: 3, KeysWritten: 2, BytesSent: size, BytesReceived: size, Calls: map[string]uint64{"othercc": 2}})
+
+	// the metrics returned are a copy
+	metrics["mycc"].Calls["othercc"] = 0
+	testutil.AssertEquals(t, chaincodeSupport.GetMetrics()["mycc"].Calls["othercc"], uint64(2))
+
+	// metering is off without a registry
+	handler = newChaincodeSupportHandler(&ChaincodeSupport{}, nullChaincodeStream{})
+	testutil.AssertNoError(t, handler.serialSend(msg), "Error sending message")
+	testutil.AssertEquals(t, len(handler.chaincodeSupport.GetMetrics()), 0)
+}
@@ -19,7 +19,8 @@ package car
 import (
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"os"
 
 	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/protos"
@@ -40,15 +41,30 @@ func generateHashcode(spec *pb.ChaincodeSpec, path string) (string, error) {
 
 	hash := util.GenerateHashFromSignature(spec.ChaincodeID.Path, ctor.Function, ctor.Args)
 
-	buf, err := ioutil.ReadFile(path)
+	fi, err := os.Stat(path)
 	if err != nil {
 		return "", fmt.Errorf("Error reading file: %s", err)
 	}
 
-	newSlice := make([]byte, len(hash)+len(buf))
-	copy(newSlice[len(buf):], hash[:])
-	//hash = md5.Sum(newSlice)
-	hash = util.ComputeCryptoHash(newSlice)
+	//the hash has always been computed over as many zero bytes as the archive
+	//has, followed by the hash of the spec, rather than over the archive. It is
+	//kept so that the chaincodes deployed so far keep their names.
+	hw := util.NewCryptoHashWriter()
+	if _, err = io.CopyN(hw, zeros{}, fi.Size()); err != nil {
+		return "", fmt.Errorf("Error hashing file: %s", err)
+	}
+	hw.Write(hash)
+	hash = hw.Sum(nil)
 
 	return hex.EncodeToString(hash[:]), nil
 }
+
+//zeros reads an endless stream of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,52 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package car
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestGenerateHashcodeUnchanged(t *testing.T) {
+	path := "test/org.hyperledger.chaincode.example02-0.1-SNAPSHOT.car"
+	spec := &pb.ChaincodeSpec{
+		ChaincodeID: &pb.ChaincodeID{Path: "http://localhost/example02.car"},
+		CtorMsg:     &pb.ChaincodeInput{Function: "init", Args: []string{"a", "100", "b", "200"}},
+	}
+
+	// the derivation of the names of the chaincodes deployed so far
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := util.GenerateHashFromSignature(spec.ChaincodeID.Path, spec.CtorMsg.Function, spec.CtorMsg.Args)
+	newSlice := make([]byte, len(hash)+len(buf))
+	copy(newSlice[len(buf):], hash[:])
+	expected := hex.EncodeToString(util.ComputeCryptoHash(newSlice))
+
+	hashcode, err := generateHashcode(spec, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashcode != expected {
+		t.Fatalf("Expected the hashcode of the chaincode to be %s, got %s", expected, hashcode)
+	}
+}
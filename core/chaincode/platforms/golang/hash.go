@@ -18,10 +18,12 @@ package golang
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -41,18 +43,24 @@ var logger = logging.MustGetLogger("golang/hash")
 
 //core hash computation factored out for testing
 func computeHash(contents []byte, hash []byte) []byte {
-	newSlice := make([]byte, len(hash)+len(contents))
+	hash, _ = computeHashFromReader(bytes.NewReader(contents), hash)
+	return hash
+}
 
-	//copy the contents
-	copy(newSlice[0:len(contents)], contents[:])
+//computeHashFromReader computes hash(contents, previous hash) streaming the
+//contents from the reader so they never have to be held in memory
+func computeHashFromReader(is io.Reader, hash []byte) ([]byte, error) {
+	hw := util.NewCryptoHashWriter()
 
-	//add the previous hash
-	copy(newSlice[len(contents):], hash[:])
+	//hash the contents
+	if _, err := io.Copy(hw, is); err != nil {
+		return hash, err
+	}
 
-	//compute new hash
-	hash = util.ComputeCryptoHash(newSlice)
+	//add the previous hash
+	hw.Write(hash)
 
-	return hash
+	return hw.Sum(nil), nil
 }
 
 //hashFilesInDir computes h=hash(h,file bytes) for each file in a directory
@@ -77,23 +85,37 @@ func hashFilesInDir(rootDir string, dir string, hash []byte, tw *tar.Writer) ([]
 			continue
 		}
 		fqp := filepath.Join(rootDir, name)
-		buf, err := ioutil.ReadFile(fqp)
+		hash, err = hashFile(fqp, filepath.Join("src", name), hash, tw)
 		if err != nil {
-			fmt.Printf("Error reading %s\n", err)
 			return hash, err
 		}
+	}
+	return hash, nil
+}
 
+//hashFile computes h=hash(h,file bytes) for a single file, adding the file to
+//the tarball in the same pass if tw is not nil
+func hashFile(fqp string, packagepath string, hash []byte, tw *tar.Writer) ([]byte, error) {
+	fd, err := os.Open(fqp)
+	if err != nil {
+		fmt.Printf("Error reading %s\n", err)
+		return hash, err
+	}
+	defer fd.Close()
+
+	if tw == nil {
 		//get the new hash from file contents
-		hash = computeHash(buf, hash)
+		return computeHashFromReader(bufio.NewReader(fd), hash)
+	}
 
-		if tw != nil {
-			is := bytes.NewReader(buf)
-			if err = cutil.WriteStreamToPackage(is, fqp, filepath.Join("src", name), tw); err != nil {
-				return hash, fmt.Errorf("Error adding file to tar %s", err)
-			}
-		}
+	hw := util.NewCryptoHashWriter()
+	is := io.TeeReader(bufio.NewReader(fd), hw)
+	if err = cutil.WriteStreamToPackage(is, fqp, packagepath, tw); err != nil {
+		return hash, fmt.Errorf("Error adding file to tar %s", err)
 	}
-	return hash, nil
+	hw.Write(hash)
+
+	return hw.Sum(nil), nil
 }
 
 func isCodeExist(tmppath string) error {
@@ -0,0 +1,59 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package java
+
+import (
+	"archive/tar"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// chaincodeJar is the self-contained jar the gradle build of a Java chaincode must produce
+const chaincodeJar = "build/libs/chaincode.jar"
+
+// tw is expected to have the chaincode source in it from generateHashcode. This
+// method will just add the Dockerfile building the chaincode
+func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
+
+	installPath := viper.GetString("chaincode.installpath")
+	if installPath == "" {
+		// same default as the chaincode support
+		installPath = "/opt/gopath/bin/"
+	}
+
+	var buf []string
+
+	//let the jar's name be chaincode ID's name
+	buf = append(buf, viper.GetString("chaincode.java.Dockerfile"))
+	buf = append(buf, "COPY src /root/chaincode")
+	buf = append(buf, fmt.Sprintf("RUN cd /root/chaincode && gradle build && mkdir -p %s && cp %s %s%s.jar", installPath, chaincodeJar, installPath, spec.ChaincodeID.Name))
+
+	dockerFileContents := strings.Join(buf, "\n")
+	dockerFileSize := int64(len([]byte(dockerFileContents)))
+
+	//Make headers identical by using zero time
+	var zeroTime time.Time
+	tw.WriteHeader(&tar.Header{Name: "Dockerfile", Size: dockerFileSize, ModTime: zeroTime, AccessTime: zeroTime, ChangeTime: zeroTime})
+	tw.Write([]byte(dockerFileContents))
+
+	return nil
+}
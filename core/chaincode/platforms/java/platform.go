@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package java
+
+import (
+	"archive/tar"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Platform for chaincodes written in Java
+type Platform struct {
+}
+
+// ValidateSpec validates Java chaincodes. The path must be a local directory
+// holding the gradle project of the chaincode
+func (javaPlatform *Platform) ValidateSpec(spec *pb.ChaincodeSpec) error {
+	url, err := url.Parse(spec.ChaincodeID.Path)
+	if err != nil || url == nil {
+		return fmt.Errorf("invalid path: %s", err)
+	}
+	if url.Scheme != "" {
+		return fmt.Errorf("Only local paths are supported for Java chaincode: %s", spec.ChaincodeID.Path)
+	}
+
+	if _, err := os.Stat(filepath.Join(spec.ChaincodeID.Path, "build.gradle")); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Path to chaincode is not a gradle project: %s", spec.ChaincodeID.Path)
+		}
+		return fmt.Errorf("Error validating chaincode path: %s", err)
+	}
+	return nil
+}
+
+// WritePackage writes the Java chaincode package
+func (javaPlatform *Platform) WritePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
+
+	var err error
+	spec.ChaincodeID.Name, err = generateHashcode(spec, tw)
+	if err != nil {
+		return err
+	}
+
+	err = writeChaincodePackage(spec, tw)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package java
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+const examplePath = "../../../../examples/chaincode/java/chaincode_example02"
+
+func newSpec(path string) *pb.ChaincodeSpec {
+	return &pb.ChaincodeSpec{
+		Type:        pb.ChaincodeSpec_JAVA,
+		ChaincodeID: &pb.ChaincodeID{Path: path},
+		CtorMsg:     &pb.ChaincodeInput{Function: "init", Args: []string{"a", "100", "b", "200"}},
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	platform := &Platform{}
+	if err := platform.ValidateSpec(newSpec(examplePath)); err != nil {
+		t.Fatalf("Error validating the spec of the example chaincode: %s", err)
+	}
+	if err := platform.ValidateSpec(newSpec("https://github.com/hyperledger/fabric/examples/chaincode/java/chaincode_example02")); err == nil {
+		t.Fatal("Expected an error validating a remote chaincode path")
+	}
+	if err := platform.ValidateSpec(newSpec("../../../../examples/chaincode/java")); err == nil {
+		t.Fatal("Expected an error validating a chaincode path without build.gradle")
+	}
+}
+
+func TestWritePackage(t *testing.T) {
+	// the build output of the chaincode must not be part of the package
+	buildDir := filepath.Join(examplePath, "build")
+	if _, err := os.Stat(buildDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Join(buildDir, "libs"), 0755); err != nil {
+			t.Fatalf("Error creating the build directory: %s", err)
+		}
+		defer os.RemoveAll(buildDir)
+		if err := ioutil.WriteFile(filepath.Join(buildDir, "libs", "chaincode.jar"), []byte("jar"), 0644); err != nil {
+			t.Fatalf("Error creating the chaincode jar: %s", err)
+		}
+	}
+
+	spec := newSpec(examplePath)
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	if err := (&Platform{}).WritePackage(spec, tw); err != nil {
+		t.Fatalf("Error writing the package: %s", err)
+	}
+	tw.Close()
+	if spec.ChaincodeID.Name == "" {
+		t.Fatal("Expected the chaincode name to be set to the hash of the chaincode")
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading the package: %s", err)
+		}
+		contents, _ := ioutil.ReadAll(tr)
+		files[header.Name] = contents
+	}
+
+	for _, name := range []string{"Dockerfile", "src/build.gradle", "src/src/main/java/example/SimpleChaincode.java"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("Expected %s in the package", name)
+		}
+	}
+	for name := range files {
+		if strings.HasPrefix(name, "src/build/") {
+			t.Fatalf("Unexpected build output %s in the package", name)
+		}
+	}
+	if !strings.Contains(string(files["Dockerfile"]), spec.ChaincodeID.Name+".jar") {
+		t.Fatalf("Expected the Dockerfile to install the chaincode as %s.jar, got %s", spec.ChaincodeID.Name, files["Dockerfile"])
+	}
+}
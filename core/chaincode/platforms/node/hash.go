@@ -0,0 +1,123 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/op/go-logging"
+
+	cutil "github.com/hyperledger/fabric/core/container/util"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var logger = logging.MustGetLogger("node/hash")
+
+// directories of a node package which are not part of the chaincode source,
+// the dependencies are installed by npm when the chaincode image is built
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+}
+
+// hashFilesInDir computes h=hash(h,file bytes) for each file in a directory,
+// adding the file to the tarball under src in the same pass. Directory entries
+// are traversed recursively. In the end a single hash value is returned for
+// the entire directory structure
+func hashFilesInDir(rootDir string, dir string, hash []byte, tw *tar.Writer) ([]byte, error) {
+	currentDir := filepath.Join(rootDir, dir)
+	logger.Debugf("hashFiles %s", currentDir)
+	//ReadDir returns sorted list of files in dir
+	fis, err := ioutil.ReadDir(currentDir)
+	if err != nil {
+		return hash, fmt.Errorf("ReadDir failed %s\n", err)
+	}
+	for _, fi := range fis {
+		name := filepath.Join(dir, fi.Name())
+		if fi.IsDir() {
+			if skippedDirs[fi.Name()] {
+				continue
+			}
+			hash, err = hashFilesInDir(rootDir, name, hash, tw)
+			if err != nil {
+				return hash, err
+			}
+			continue
+		}
+		hash, err = hashFile(filepath.Join(rootDir, name), filepath.Join("src", name), hash, tw)
+		if err != nil {
+			return hash, err
+		}
+	}
+	return hash, nil
+}
+
+func hashFile(fqp string, packagepath string, hash []byte, tw *tar.Writer) ([]byte, error) {
+	fd, err := os.Open(fqp)
+	if err != nil {
+		return hash, fmt.Errorf("Error reading file: %s", err)
+	}
+	defer fd.Close()
+
+	hw := util.NewCryptoHashWriter()
+	is := io.TeeReader(bufio.NewReader(fd), hw)
+	if err = cutil.WriteStreamToPackage(is, fqp, packagepath, tw); err != nil {
+		return hash, fmt.Errorf("Error adding file to tar %s", err)
+	}
+	hw.Write(hash)
+
+	return hw.Sum(nil), nil
+}
+
+// generateHashcode gets hashcode of the node package under the chaincode
+// path and adds the files of the package to the package.
+// NOTE: for dev mode, user builds and runs chaincode manually. The name provided
+// by the user is equivalent to the path. This method will treat the name
+// as codebytes and compute the hash from it. ie, user cannot run the chaincode
+// with the same (name, ctor, args)
+func generateHashcode(spec *pb.ChaincodeSpec, tw *tar.Writer) (string, error) {
+	if spec == nil {
+		return "", fmt.Errorf("Cannot generate hashcode from nil spec")
+	}
+
+	chaincodeID := spec.ChaincodeID
+	if chaincodeID == nil || chaincodeID.Path == "" {
+		return "", fmt.Errorf("Cannot generate hashcode from empty chaincode path")
+	}
+
+	ctor := spec.CtorMsg
+	if ctor == nil || ctor.Function == "" {
+		return "", fmt.Errorf("Cannot generate hashcode from empty ctor")
+	}
+
+	hash := util.GenerateHashFromSignature(chaincodeID.Path, ctor.Function, ctor.Args)
+
+	hash, err := hashFilesInDir(chaincodeID.Path, "", hash, tw)
+	if err != nil {
+		return "", fmt.Errorf("Could not get hashcode for %s - %s\n", chaincodeID.Path, err)
+	}
+
+	return hex.EncodeToString(hash[:]), nil
+}
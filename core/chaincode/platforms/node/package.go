@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"archive/tar"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// tw is expected to have the chaincode source in it from generateHashcode. This
+// method will just add the Dockerfile installing the chaincode package and its
+// dependencies
+func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
+
+	installPath := viper.GetString("chaincode.installpath")
+	if installPath == "" {
+		// same default as the chaincode support
+		installPath = "/opt/gopath/bin/"
+	}
+
+	var buf []string
+
+	//let the package directory's name be chaincode ID's name
+	buf = append(buf, viper.GetString("chaincode.node.Dockerfile"))
+	buf = append(buf, fmt.Sprintf("COPY src %s%s", installPath, spec.ChaincodeID.Name))
+	buf = append(buf, fmt.Sprintf("RUN cd %s%s && npm install --production", installPath, spec.ChaincodeID.Name))
+
+	dockerFileContents := strings.Join(buf, "\n")
+	dockerFileSize := int64(len([]byte(dockerFileContents)))
+
+	//Make headers identical by using zero time
+	var zeroTime time.Time
+	tw.WriteHeader(&tar.Header{Name: "Dockerfile", Size: dockerFileSize, ModTime: zeroTime, AccessTime: zeroTime, ChangeTime: zeroTime})
+	tw.Write([]byte(dockerFileContents))
+
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Platform for chaincodes written in JavaScript and run by Node.js
+type Platform struct {
+}
+
+// packageJSON holds the fields of package.json the platform relies on
+type packageJSON struct {
+	Name string `json:"name"`
+	Main string `json:"main"`
+}
+
+// ValidateSpec validates Node.js chaincodes. The path must be a local directory
+// holding a package.json, whose main script is run by node
+func (nodePlatform *Platform) ValidateSpec(spec *pb.ChaincodeSpec) error {
+	url, err := url.Parse(spec.ChaincodeID.Path)
+	if err != nil || url == nil {
+		return fmt.Errorf("invalid path: %s", err)
+	}
+	if url.Scheme != "" {
+		return fmt.Errorf("Only local paths are supported for Node.js chaincode: %s", spec.ChaincodeID.Path)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(spec.ChaincodeID.Path, "package.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Path to chaincode is not a node package, package.json not found: %s", spec.ChaincodeID.Path)
+		}
+		return fmt.Errorf("Error validating chaincode path: %s", err)
+	}
+	pkg := &packageJSON{}
+	if err = json.Unmarshal(contents, pkg); err != nil {
+		return fmt.Errorf("Error parsing package.json of chaincode %s: %s", spec.ChaincodeID.Path, err)
+	}
+	if pkg.Name == "" {
+		return fmt.Errorf("package.json of chaincode %s has no name", spec.ChaincodeID.Path)
+	}
+
+	// node runs the main script of the package, index.js by default, and
+	// resolves it with or without the .js extension
+	main := pkg.Main
+	if main == "" {
+		main = "index.js"
+	}
+	for _, script := range []string{main, main + ".js"} {
+		if fi, err := os.Stat(filepath.Join(spec.ChaincodeID.Path, script)); err == nil && !fi.IsDir() {
+			return nil
+		}
+	}
+	return fmt.Errorf("Main script %s of chaincode %s not found", main, spec.ChaincodeID.Path)
+}
+
+// WritePackage writes the Node.js chaincode package
+func (nodePlatform *Platform) WritePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
+
+	var err error
+	spec.ChaincodeID.Name, err = generateHashcode(spec, tw)
+	if err != nil {
+		return err
+	}
+
+	err = writeChaincodePackage(spec, tw)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
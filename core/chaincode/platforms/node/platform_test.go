@@ -0,0 +1,133 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// writeChaincode creates a node package holding files, and returns its path
+func writeChaincode(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "nodechaincode")
+	if err != nil {
+		t.Fatalf("Error creating the chaincode directory: %s", err)
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Error creating the directory of %s: %s", name, err)
+		}
+		if err = ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("Error writing %s: %s", name, err)
+		}
+	}
+	return dir
+}
+
+func newSpec(path string) *pb.ChaincodeSpec {
+	return &pb.ChaincodeSpec{
+		Type:        pb.ChaincodeSpec_NODE,
+		ChaincodeID: &pb.ChaincodeID{Path: path},
+		CtorMsg:     &pb.ChaincodeInput{Function: "init", Args: []string{"a", "100", "b", "200"}},
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	platform := &Platform{}
+	for _, test := range []struct {
+		files map[string]string
+		valid bool
+	}{
+		{map[string]string{"package.json": `{"name": "example"}`, "index.js": ""}, true},
+		{map[string]string{"package.json": `{"name": "example", "main": "lib/chaincode"}`, "lib/chaincode.js": ""}, true},
+		{map[string]string{"index.js": ""}, false},
+		{map[string]string{"package.json": `{"name": `, "index.js": ""}, false},
+		{map[string]string{"package.json": `{"main": "index.js"}`, "index.js": ""}, false},
+		{map[string]string{"package.json": `{"name": "example", "main": "chaincode.js"}`, "index.js": ""}, false},
+	} {
+		path := writeChaincode(t, test.files)
+		defer os.RemoveAll(path)
+		err := platform.ValidateSpec(newSpec(path))
+		if test.valid && err != nil {
+			t.Fatalf("Error validating the chaincode %v: %s", test.files, err)
+		}
+		if !test.valid && err == nil {
+			t.Fatalf("Expected an error validating the chaincode %v", test.files)
+		}
+	}
+
+	if err := platform.ValidateSpec(newSpec("https://github.com/hyperledger/fabric/examples/chaincode/node")); err == nil {
+		t.Fatal("Expected an error validating a remote chaincode path")
+	}
+}
+
+func TestWritePackage(t *testing.T) {
+	path := writeChaincode(t, map[string]string{
+		"package.json":                  `{"name": "example", "main": "lib/chaincode.js"}`,
+		"lib/chaincode.js":              "",
+		"node_modules/dep/package.json": `{"name": "dep"}`,
+	})
+	defer os.RemoveAll(path)
+
+	spec := newSpec(path)
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	if err := (&Platform{}).WritePackage(spec, tw); err != nil {
+		t.Fatalf("Error writing the package: %s", err)
+	}
+	tw.Close()
+	if spec.ChaincodeID.Name == "" {
+		t.Fatal("Expected the chaincode name to be set to the hash of the chaincode")
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading the package: %s", err)
+		}
+		contents, _ := ioutil.ReadAll(tr)
+		files[header.Name] = contents
+	}
+
+	for _, name := range []string{"Dockerfile", "src/package.json", "src/lib/chaincode.js"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("Expected %s in the package", name)
+		}
+	}
+	for name := range files {
+		if strings.HasPrefix(name, "src/node_modules/") {
+			t.Fatalf("Unexpected dependency %s in the package", name)
+		}
+	}
+	if !strings.Contains(string(files["Dockerfile"]), "npm install --production") {
+		t.Fatalf("Expected the Dockerfile to install the dependencies of the chaincode, got %s", files["Dockerfile"])
+	}
+}
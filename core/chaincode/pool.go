@@ -0,0 +1,144 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// chaincodePool keeps the chaincodes launched by the peer in the order they
+// were last used. Once more than size of them are running, the least recently
+// used idle ones are stopped. A size of 0 keeps all of them running.
+type chaincodePool struct {
+	sync.Mutex
+	size     int
+	lru      *list.List
+	elements map[string]*list.Element
+}
+
+func newChaincodePool(size int) *chaincodePool {
+	return &chaincodePool{size: size, lru: list.New(), elements: make(map[string]*list.Element)}
+}
+
+// touch marks chaincode as the most recently used
+func (pool *chaincodePool) touch(chaincode string) {
+	pool.Lock()
+	defer pool.Unlock()
+	if e, ok := pool.elements[chaincode]; ok {
+		pool.lru.MoveToFront(e)
+		return
+	}
+	pool.elements[chaincode] = pool.lru.PushFront(chaincode)
+}
+
+func (pool *chaincodePool) remove(chaincode string) {
+	pool.Lock()
+	defer pool.Unlock()
+	if e, ok := pool.elements[chaincode]; ok {
+		pool.lru.Remove(e)
+		delete(pool.elements, chaincode)
+	}
+}
+
+// leastRecentlyUsed returns the chaincodes of the pool, least recently used
+// first
+func (pool *chaincodePool) leastRecentlyUsed() []string {
+	pool.Lock()
+	defer pool.Unlock()
+	chaincodes := make([]string, 0, pool.lru.Len())
+	for e := pool.lru.Back(); e != nil; e = e.Prev() {
+		chaincodes = append(chaincodes, e.Value.(string))
+	}
+	return chaincodes
+}
+
+// isIdle returns true if the chaincode of the handler is ready and is not
+// executing any transaction or query
+func (handler *Handler) isIdle() bool {
+	if !handler.registered || !handler.isRunning() {
+		return false
+	}
+	handler.Lock()
+	defer handler.Unlock()
+	return len(handler.txCtxs) == 0
+}
+
+// evictIdleChaincodes stops the least recently used idle chaincodes while
+// more chaincodes than the size of the pool are running. The chaincode just
+// used is kept.
+func (chaincodeSupport *ChaincodeSupport) evictIdleChaincodes(used string) {
+	pool := chaincodeSupport.pool
+	if pool == nil || pool.size <= 0 {
+		return
+	}
+
+	var idle []*chaincodeRTEnv
+	running := 0
+	chaincodeSupport.runningChaincodes.RLock()
+	for _, chaincode := range pool.leastRecentlyUsed() {
+		chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode)
+		//system chaincodes and the chaincodes run by the user are not pooled
+		if !ok || chrte.cds == nil || chrte.cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM {
+			pool.remove(chaincode)
+			continue
+		}
+		running++
+		if chaincode != used && chrte.handler.isIdle() {
+			idle = append(idle, chrte)
+		}
+	}
+	chaincodeSupport.runningChaincodes.RUnlock()
+
+	for i := 0; running > pool.size && i < len(idle); i++ {
+		chaincode := idle[i].cds.ChaincodeSpec.ChaincodeID.Name
+		chaincodeLogger.Infof("Stopping least recently used chaincode %s, %d chaincodes running", chaincode, running)
+		if err := chaincodeSupport.Stop(context.Background(), idle[i].cds); err != nil {
+			chaincodeLogger.Errorf("Error stopping chaincode %s: %s", chaincode, err)
+		}
+		pool.remove(chaincode)
+		running--
+	}
+}
+
+// PrestartChaincodes launches the deployed chaincodes listed in
+// chaincode.pool.prestart, so that the first transactions after the peer
+// starts do not wait for their containers to be started. The chaincodes are
+// launched as for a query, without invoking them.
+func (chaincodeSupport *ChaincodeSupport) PrestartChaincodes(context context.Context) {
+	if chaincodeSupport.userRunsCC {
+		return
+	}
+	for _, chaincode := range viper.GetStringSlice("chaincode.pool.prestart") {
+		spec := &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: chaincode}, CtorMsg: &pb.ChaincodeInput{}}
+		tx, err := pb.NewChaincodeExecute(&pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}, util.GenerateUUID(), pb.Transaction_CHAINCODE_QUERY)
+		if err != nil {
+			chaincodeLogger.Errorf("Error creating transaction to prestart chaincode %s: %s", chaincode, err)
+			continue
+		}
+		if _, _, err = chaincodeSupport.Launch(context, tx); err != nil {
+			chaincodeLogger.Warningf("Error prestarting chaincode %s: %s", chaincode, err)
+			continue
+		}
+		chaincodeLogger.Infof("Prestarted chaincode %s", chaincode)
+	}
+}
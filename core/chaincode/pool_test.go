@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/looplab/fsm"
+)
+
+func TestChaincodePoolLRU(t *testing.T) {
+	pool := newChaincodePool(2)
+	pool.touch("a")
+	pool.touch("b")
+	pool.touch("c")
+	pool.touch("a")
+	testutil.AssertEquals(t, pool.leastRecentlyUsed(), []string{"b", "c", "a"})
+	pool.remove("c")
+	testutil.AssertEquals(t, pool.leastRecentlyUsed(), []string{"b", "a"})
+}
+
+func TestEvictIdleChaincodes(t *testing.T) {
+	chaincodeSupport := &ChaincodeSupport{runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}, pool: newChaincodePool(2)}
+	handlers := make(map[string]*Handler)
+	for _, name := range []string{"a", "b", "c"} {
+		handler := newTestRegisteredHandler(t, chaincodeSupport, name)
+		handler.FSM = fsm.NewFSM(readystate, fsm.Events{}, fsm.Callbacks{})
+		spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Name: name}}
+		chaincodeSupport.runningChaincodes.chaincodeMap[name].cds = &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec}
+		chaincodeSupport.pool.touch(name)
+		handlers[name] = handler
+	}
+	// the least recently used chaincode is executing a transaction
+	handlers["a"].txCtxs["txUuid"] = &transactionContext{}
+
+	chaincodeSupport.evictIdleChaincodes("c")
+	for name, running := range map[string]bool{"a": true, "b": false, "c": true} {
+		_, ok := chaincodeSupport.chaincodeHasBeenLaunched(name)
+		testutil.AssertEquals(t, ok, running)
+	}
+	testutil.AssertEquals(t, chaincodeSupport.pool.leastRecentlyUsed(), []string{"a", "c"})
+
+	// nothing is stopped while the pool is not full
+	chaincodeSupport.evictIdleChaincodes("c")
+	_, ok := chaincodeSupport.chaincodeHasBeenLaunched("a")
+	testutil.AssertEquals(t, ok, true)
+}
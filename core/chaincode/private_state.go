@@ -0,0 +1,151 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/looplab/fsm"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// getPrivateCollections returns the private state collections this peer is a
+// member of, configured in chaincode.privateState.collections as
+// "chaincode/collection" entries, by their ledger namespace
+func getPrivateCollections() map[string]bool {
+	collections := make(map[string]bool)
+	for _, entry := range viper.GetStringSlice("chaincode.privateState.collections") {
+		parts := strings.SplitN(entry, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			chaincodeLogger.Warningf("Ignoring private state collection %q, expected chaincode/collection", entry)
+			continue
+		}
+		collections[ledger.PrivateStateNamespace(parts[0], parts[1])] = true
+	}
+	return collections
+}
+
+// isPrivateCollectionMember returns whether this peer stores the values of the
+// private state collection of chaincodeID
+func (chaincodeSupport *ChaincodeSupport) isPrivateCollectionMember(chaincodeID string, collection string) bool {
+	return chaincodeSupport.privateCollections[ledger.PrivateStateNamespace(chaincodeID, collection)]
+}
+
+// afterGetPrivateState handles a GET_PRIVATE_STATE or GET_PRIVATE_STATE_HASH
+// request from the chaincode.
+func (handler *Handler) afterGetPrivateState(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debugf("[%s]Received %s, invoking get private state from ledger", shortuuid(msg.Uuid), msg.Type)
+
+	handler.handleGetPrivateState(msg)
+}
+
+// Handles query to ledger to get a private state value or its hash
+func (handler *Handler) handleGetPrivateState(msg *pb.ChaincodeMessage) {
+	// See handleGetState for why this runs in a go routine
+	go func() {
+		uniqueReq := handler.createUUIDEntry(msg.Uuid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Debug("Another state request pending for this Uuid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteUUIDEntry(msg.Uuid)
+			chaincodeLogger.Debugf("[%s]handleGetPrivateState serial send %s", shortuuid(serialSendMsg.Uuid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		res, err := handler.getPrivateState(msg)
+		if err != nil {
+			chaincodeLogger.Errorf("[%s]Failed to get private state(%s). Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
+		if res != nil {
+			handler.metering().recordKeysRead(handler.chaincodeName(), 1)
+		}
+		chaincodeLogger.Debugf("[%s]Got private state. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
+		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
+	}()
+}
+
+func (handler *Handler) getPrivateState(msg *pb.ChaincodeMessage) ([]byte, error) {
+	privateStateInfo := &pb.PrivateStateInfo{}
+	if err := proto.Unmarshal(msg.Payload, privateStateInfo); err != nil {
+		return nil, err
+	}
+	ledgerObj, err := handler.getLedger(msg.Uuid)
+	if err != nil {
+		return nil, err
+	}
+	chaincodeID, err := handler.getStateNamespace(msg.Uuid, ledgerObj)
+	if err != nil {
+		return nil, err
+	}
+	readCommittedState := !handler.readsUncommittedState(msg.Uuid)
+
+	if msg.Type == pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH {
+		return ledgerObj.GetPrivateStateHash(chaincodeID, privateStateInfo.Collection, privateStateInfo.Key, readCommittedState)
+	}
+
+	// Only the members of the collection have its values, a transaction
+	// reading them would not execute the same on every validating peer
+	if handler.getIsTransaction(msg.Uuid) {
+		return nil, fmt.Errorf("Cannot handle %s in transaction context", msg.Type)
+	}
+	if !handler.chaincodeSupport.isPrivateCollectionMember(chaincodeID, privateStateInfo.Collection) {
+		return nil, fmt.Errorf("Peer is not a member of private state collection %s of chaincode %s", privateStateInfo.Collection, chaincodeID)
+	}
+	res, err := ledgerObj.GetPrivateState(chaincodeID, privateStateInfo.Collection, privateStateInfo.Key, readCommittedState)
+	if err != nil || res == nil {
+		return res, err
+	}
+	return handler.decrypt(msg.Uuid, res)
+}
+
+// putPrivateState handles a PUT_PRIVATE_STATE or DEL_PRIVATE_STATE of the
+// transaction uuid, within enterBusyState. Every peer records the hash of the
+// value, only the members of the collection the value itself.
+func (handler *Handler) putPrivateState(ledgerObj *ledger.Ledger, chaincodeID string, msg *pb.ChaincodeMessage) error {
+	privateStateInfo := &pb.PrivateStateInfo{}
+	if err := proto.Unmarshal(msg.Payload, privateStateInfo); err != nil {
+		return err
+	}
+	member := handler.chaincodeSupport.isPrivateCollectionMember(chaincodeID, privateStateInfo.Collection)
+	if msg.Type == pb.ChaincodeMessage_DEL_PRIVATE_STATE {
+		return ledgerObj.DeletePrivateState(chaincodeID, privateStateInfo.Collection, privateStateInfo.Key, member)
+	}
+	// Encrypt the data if the confidential is enabled
+	pVal, err := handler.encrypt(msg.Uuid, privateStateInfo.Value)
+	if err != nil {
+		return err
+	}
+	return ledgerObj.SetPrivateState(chaincodeID, privateStateInfo.Collection, privateStateInfo.Key, pVal, member)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func newPrivateStateMessage(t *testing.T, msgType pb.ChaincodeMessage_Type, collection string, key string, value []byte) *pb.ChaincodeMessage {
+	payload, err := proto.Marshal(&pb.PrivateStateInfo{Collection: collection, Key: key, Value: value})
+	testutil.AssertNoError(t, err, "Error marshalling PrivateStateInfo")
+	return &pb.ChaincodeMessage{Type: msgType, Payload: payload, Uuid: "txUuid"}
+}
+
+func TestPrivateState(t *testing.T) {
+	defer viper.Set("chaincode.privateState.collections", viper.Get("chaincode.privateState.collections"))
+	viper.Set("chaincode.privateState.collections", []string{"mycc/shared", "invalid"})
+
+	chaincodeSupport := &ChaincodeSupport{runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}, txSimulations: &txSimulations{simulationMap: make(map[string]*txSimulation)},
+		privateCollections: getPrivateCollections()}
+	testutil.AssertEquals(t, chaincodeSupport.isPrivateCollectionMember("mycc", "shared"), true)
+	testutil.AssertEquals(t, chaincodeSupport.isPrivateCollectionMember("mycc", "other"), false)
+	handler := newTestRegisteredHandler(t, chaincodeSupport, "mycc")
+	handler.txCtxs["txUuid"] = &transactionContext{}
+	handler.markIsTransaction("txUuid", true)
+
+	ledgerPtr := ledger.InitTestLedger(t)
+	ledgerPtr.BeginTxBatch(1)
+	ledgerPtr.TxBegin("txUuid")
+	for _, collection := range []string{"shared", "other"} {
+		msg := newPrivateStateMessage(t, pb.ChaincodeMessage_PUT_PRIVATE_STATE, collection, "key", []byte("value"))
+		testutil.AssertNoError(t, handler.putPrivateState(ledgerPtr, "mycc", msg), "Error putting private state")
+	}
+	ledgerPtr.TxFinished("txUuid", true)
+	testutil.AssertNoError(t, ledgerPtr.CommitTxBatch(1, []*pb.Transaction{}, nil, []byte("proof")), "Error committing batch")
+
+	// every peer has the hash of the values, transactions included
+	for _, collection := range []string{"shared", "other"} {
+		hash, err := handler.getPrivateState(newPrivateStateMessage(t, pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH, collection, "key", nil))
+		testutil.AssertNoError(t, err, "Error getting private state hash")
+		testutil.AssertEquals(t, hash, util.ComputeCryptoHash([]byte("value")))
+	}
+
+	// the values can only be read by queries, on the members
+	getMsg := newPrivateStateMessage(t, pb.ChaincodeMessage_GET_PRIVATE_STATE, "shared", "key", nil)
+	_, err := handler.getPrivateState(getMsg)
+	testutil.AssertError(t, err, "Expected error reading private state in a transaction")
+	handler.markIsTransaction("txUuid", false)
+	value, err := handler.getPrivateState(getMsg)
+	testutil.AssertNoError(t, err, "Error getting private state")
+	testutil.AssertEquals(t, value, []byte("value"))
+	_, err = handler.getPrivateState(newPrivateStateMessage(t, pb.ChaincodeMessage_GET_PRIVATE_STATE, "other", "key", nil))
+	testutil.AssertError(t, err, "Expected error reading a collection the peer is not a member of")
+	value, err = ledgerPtr.GetPrivateState("mycc", "other", "key", true)
+	testutil.AssertNoError(t, err, "Error getting private state")
+	testutil.AssertNil(t, value)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// TxError is the error a transaction failed with, along with the phase of
+// its processing which failed and the reason of the failure
+type TxError struct {
+	Phase  pb.RejectionPhase
+	Reason pb.RejectionReason
+	Err    error
+}
+
+func (e *TxError) Error() string {
+	return e.Err.Error()
+}
+
+// txFailure returns err as the failure of a transaction in phase for reason,
+// unless cause, the error err reports, is a TxError with a reason of its own,
+// which is kept
+func txFailure(phase pb.RejectionPhase, reason pb.RejectionReason, cause error, err error) error {
+	if txErr, ok := cause.(*TxError); ok && txErr.Reason != pb.RejectionReason_UNDEFINED_REASON {
+		phase, reason = txErr.Phase, txErr.Reason
+	}
+	return &TxError{Phase: phase, Reason: reason, Err: err}
+}
+
+// RejectionOf returns the phase and the reason of the failure of a
+// transaction which failed with err, errors other than TxError being execution
+// failures of undefined reason
+func RejectionOf(err error) (pb.RejectionPhase, pb.RejectionReason) {
+	if txErr, ok := err.(*TxError); ok {
+		return txErr.Phase, txErr.Reason
+	}
+	return pb.RejectionPhase_EXECUTION, pb.RejectionReason_UNDEFINED_REASON
+}
@@ -0,0 +1,48 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestRejectionOf(t *testing.T) {
+	timeout := &TxError{Phase: pb.RejectionPhase_EXECUTION, Reason: pb.RejectionReason_TIMEOUT, Err: fmt.Errorf("Timeout expired while executing transaction")}
+	cases := []struct {
+		err    error
+		phase  pb.RejectionPhase
+		reason pb.RejectionReason
+		msg    string
+	}{
+		{fmt.Errorf("unexpected"), pb.RejectionPhase_EXECUTION, pb.RejectionReason_UNDEFINED_REASON, "unexpected"},
+		{timeout, pb.RejectionPhase_EXECUTION, pb.RejectionReason_TIMEOUT, "Timeout expired while executing transaction"},
+		// the reason of the cause is kept
+		{txFailure(pb.RejectionPhase_EXECUTION, pb.RejectionReason_CHAINCODE_ERROR, timeout, fmt.Errorf("Failed to execute transaction or query(%s)", timeout)),
+			pb.RejectionPhase_EXECUTION, pb.RejectionReason_TIMEOUT, "Failed to execute transaction or query(Timeout expired while executing transaction)"},
+		{txFailure(pb.RejectionPhase_VM_BUILD, pb.RejectionReason_BUILD_FAILED, fmt.Errorf("no such image"), fmt.Errorf("Failed to deploy chaincode spec(no such image)")),
+			pb.RejectionPhase_VM_BUILD, pb.RejectionReason_BUILD_FAILED, "Failed to deploy chaincode spec(no such image)"},
+	}
+	for i, c := range cases {
+		phase, reason := RejectionOf(c.err)
+		if phase != c.phase || reason != c.reason || c.err.Error() != c.msg {
+			t.Errorf("Case %d: expected %s %s %q, got %s %s %q", i, c.phase, c.reason, c.msg, phase, reason, c.err)
+		}
+	}
+}
@@ -20,6 +20,8 @@ package shim
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
@@ -27,6 +29,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	gp "google/protobuf"
 
@@ -39,6 +42,8 @@ import (
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 )
 
 // Logger for the shim package.
@@ -47,6 +52,12 @@ var chaincodeLogger = logging.MustGetLogger("shim")
 // Handler to shim that handles all control logic.
 var handler *Handler
 
+// RegistrationTokenKey is the key of the stream metadata in which a chaincode
+// launched by the peer presents the token given to it in
+// CORE_CHAINCODE_REGISTRATION_TOKEN. The peer rejects the registrations
+// without a valid token, unless the user runs the chaincodes.
+const RegistrationTokenKey = "chaincode-registration-token"
+
 // Chaincode interface must be implemented by all chaincodes. The fabric runs
 // the transactions by calling these functions as specified.
 type Chaincode interface {
@@ -69,6 +80,7 @@ type ChaincodeStub struct {
 	UUID            string
 	securityContext *pb.ChaincodeSecurityContext
 	chaincodeEvent  *pb.ChaincodeEvent
+	ledgerID        string
 }
 
 // Peer address derived from command line or env var
@@ -95,6 +107,10 @@ func Start(cc Chaincode) error {
 
 	chaincodeLogger.Debugf("Peer address: %s", getPeerAddress())
 
+	if viper.GetBool("chaincode.dev.watch") {
+		go watchExecutable()
+	}
+
 	// Establish connection with validating peer
 	clientConn, err := newPeerClientConnection()
 	if err != nil {
@@ -107,7 +123,11 @@ func Start(cc Chaincode) error {
 	chaincodeSupportClient := pb.NewChaincodeSupportClient(clientConn)
 
 	// Establish stream with validating peer
-	stream, err := chaincodeSupportClient.Register(context.Background())
+	ctxt := context.Background()
+	if token := viper.GetString("chaincode.registration.token"); token != "" {
+		ctxt = metadata.NewContext(ctxt, metadata.Pairs(RegistrationTokenKey, token))
+	}
+	stream, err := chaincodeSupportClient.Register(ctxt)
 	if err != nil {
 		return fmt.Errorf("Error chatting with leader at address=%s:  %s", getPeerAddress(), err)
 	}
@@ -156,11 +176,29 @@ func getPeerAddress() string {
 func newPeerClientConnection() (*grpc.ClientConn, error) {
 	var peerAddress = getPeerAddress()
 	if comm.TLSEnabled() {
+		// the peer hands the CA certificates to the chaincodes it launches
+		if rootCAs := viper.GetString("chaincode.tls.rootcas"); rootCAs != "" {
+			creds, err := newPeerTLSCredentials([]byte(rootCAs))
+			if err != nil {
+				return nil, err
+			}
+			return comm.NewClientConnectionWithAddress(peerAddress, true, true, creds)
+		}
 		return comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer())
 	}
 	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
 }
 
+// newPeerTLSCredentials returns the credentials verifying the certificate of
+// the peer with the PEM encoded CA certificates rootCAs
+func newPeerTLSCredentials(rootCAs []byte) (credentials.TransportAuthenticator, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(rootCAs) {
+		return nil, fmt.Errorf("No CA certificate found in CORE_CHAINCODE_TLS_ROOTCAS")
+	}
+	return credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: viper.GetString("peer.tls.serverhostoverride")}), nil
+}
+
 func chatWithPeer(chaincodename string, stream PeerChaincodeStream, cc Chaincode) error {
 
 	// Create the shim handler responsible for all control logic
@@ -238,8 +276,9 @@ func chatWithPeer(chaincodename string, stream PeerChaincodeStream, cc Chaincode
 }
 
 // -- init stub ---
-func (stub *ChaincodeStub) init(uuid string, secContext *pb.ChaincodeSecurityContext) {
+func (stub *ChaincodeStub) init(uuid string, ledgerID string, secContext *pb.ChaincodeSecurityContext) {
 	stub.UUID = uuid
+	stub.ledgerID = ledgerID
 	stub.securityContext = secContext
 }
 
@@ -274,15 +313,65 @@ func (stub *ChaincodeStub) PutState(key string, value []byte) error {
 	return handler.handlePutState(key, value, stub.UUID)
 }
 
+// GetStateMultipleKeys returns the byte array values specified by the `keys`
+// in a single request to the validator. The value of a key that does not
+// exist is nil.
+func (stub *ChaincodeStub) GetStateMultipleKeys(keys []string) ([][]byte, error) {
+	return handler.handleGetStateMultipleKeys(keys, stub.UUID)
+}
+
+// PutStateMultipleKeys writes the specified `values` of the `keys` into the
+// ledger in a single request to the validator.
+func (stub *ChaincodeStub) PutStateMultipleKeys(keys []string, values [][]byte) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("Got %d keys but %d values", len(keys), len(values))
+	}
+	return handler.handlePutStateMultipleKeys(keys, values, stub.UUID)
+}
+
 // DelState removes the specified `key` and its value from the ledger.
 func (stub *ChaincodeStub) DelState(key string) error {
 	return handler.handleDelState(key, stub.UUID)
 }
 
-//ReadCertAttribute is used to read an specific attribute from the transaction certificate, *attributeName* is passed as input parameter to this function.
+// GetPrivateState returns the value of `key` in the private state
+// `collection` of the chaincode. Only the validating peers configured as
+// members of the collection store its values, so it can only be called by
+// queries executed on those peers.
+func (stub *ChaincodeStub) GetPrivateState(collection string, key string) ([]byte, error) {
+	return handler.handlePrivateState(pb.ChaincodeMessage_GET_PRIVATE_STATE, collection, key, nil, stub.UUID)
+}
+
+// GetPrivateStateHash returns the hash of the value of `key` in the private
+// state `collection` of the chaincode, which every validating peer keeps in
+// the world state.
+func (stub *ChaincodeStub) GetPrivateStateHash(collection string, key string) ([]byte, error) {
+	return handler.handlePrivateState(pb.ChaincodeMessage_GET_PRIVATE_STATE_HASH, collection, key, nil, stub.UUID)
+}
+
+// PutPrivateState writes the specified `value` of `key` into the private
+// state `collection` of the chaincode. The members of the collection store
+// the value, every validating peer records its hash in the world state.
+func (stub *ChaincodeStub) PutPrivateState(collection string, key string, value []byte) error {
+	_, err := handler.handlePrivateState(pb.ChaincodeMessage_PUT_PRIVATE_STATE, collection, key, value, stub.UUID)
+	return err
+}
+
+// DelPrivateState removes `key` and its value from the private state
+// `collection` of the chaincode.
+func (stub *ChaincodeStub) DelPrivateState(collection string, key string) error {
+	_, err := handler.handlePrivateState(pb.ChaincodeMessage_DEL_PRIVATE_STATE, collection, key, nil, stub.UUID)
+	return err
+}
+
+//GetCallerAttribute returns the value of the attribute *attributeName* the TCA embedded in the transaction certificate of the caller, or an error if it did not.
+//Besides the attributes of the ACA, the caller may request the attributes of its enrollment, attr.EnrollmentRole and attr.EnrollmentAffiliation.
 // Example:
-//  attrValue,error:=stub.ReadCertAttribute("position")
-func (stub *ChaincodeStub) ReadCertAttribute(attributeName string) ([]byte, error) {
+//  role, err := stub.GetCallerAttribute(attr.EnrollmentRole)
+//  if err != nil || string(role) != "CLIENT" {
+//      return nil, errors.New("Access denied.")
+//  }
+func (stub *ChaincodeStub) GetCallerAttribute(attributeName string) ([]byte, error) {
 	attributesHandler, err := attr.NewAttributesHandlerImpl(stub)
 	if err != nil {
 		return nil, err
@@ -290,6 +379,14 @@ func (stub *ChaincodeStub) ReadCertAttribute(attributeName string) ([]byte, erro
 	return attributesHandler.GetValue(attributeName)
 }
 
+//ReadCertAttribute is used to read an specific attribute from the transaction certificate, *attributeName* is passed as input parameter to this function.
+//It is the same as GetCallerAttribute.
+// Example:
+//  attrValue,error:=stub.ReadCertAttribute("position")
+func (stub *ChaincodeStub) ReadCertAttribute(attributeName string) ([]byte, error) {
+	return stub.GetCallerAttribute(attributeName)
+}
+
 //VerifyAttribute is used to verify if the transaction certificate has an attribute with name *attributeName* and value *attributeValue* which are the input parameters received by this function.
 //Example:
 //    containsAttr, error := stub.VerifyAttribute("position", "Software Engineer")
@@ -334,6 +431,15 @@ func (stub *ChaincodeStub) RangeQueryState(startKey, endKey string) (*StateRange
 	return &StateRangeQueryIterator{handler, stub.UUID, response, 0}, nil
 }
 
+// GetStateRange returns an iterator over all keys in the state between the
+// startKey and endKey, inclusive. The peer sends the keys and values in batches
+// of at most chaincode.rangeQueryBatchSize entries, and the iterator requests
+// the next batch only when the current one has been read, so large ranges can
+// be scanned without holding all the results in memory.
+func (stub *ChaincodeStub) GetStateRange(startKey, endKey string) (*StateRangeQueryIterator, error) {
+	return stub.RangeQueryState(startKey, endKey)
+}
+
 // HasNext returns true if the range query iterator contains additional keys
 // and values.
 func (iter *StateRangeQueryIterator) HasNext() bool {
@@ -374,6 +480,73 @@ func (iter *StateRangeQueryIterator) Close() error {
 	return err
 }
 
+// COMPOSITE KEY FUNCTIONALITY
+
+// Composite keys start with compositeKeyNamespace and every part of the key is
+// terminated by compositeKeyDelimiter. The state implementation relies on this
+// layout to keep keys sharing the object type and first attribute together, so
+// keep it in sync with core/ledger/statemgmt/buckettree.
+const (
+	compositeKeyNamespace = "\x00"
+	compositeKeyDelimiter = "\x00"
+)
+
+// CreateCompositeKey combines the given object type and attributes into a
+// single key that can be used with PutState. Composite keys sort by object type
+// and then by attributes, so all keys of an object type with given leading
+// attributes can be retrieved with PartialCompositeKeyQuery. The object type and
+// attributes must be valid UTF-8 strings without U+0000 and U+10FFFF.
+func (stub *ChaincodeStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	if err := validateCompositeKeyAttribute(objectType); err != nil {
+		return "", err
+	}
+	var keyBuffer bytes.Buffer
+	keyBuffer.WriteString(compositeKeyNamespace)
+	keyBuffer.WriteString(objectType)
+	keyBuffer.WriteString(compositeKeyDelimiter)
+	for _, attribute := range attributes {
+		if err := validateCompositeKeyAttribute(attribute); err != nil {
+			return "", err
+		}
+		keyBuffer.WriteString(attribute)
+		keyBuffer.WriteString(compositeKeyDelimiter)
+	}
+	return keyBuffer.String(), nil
+}
+
+// SplitCompositeKey returns the object type and attributes of a key created
+// with CreateCompositeKey.
+func (stub *ChaincodeStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) || !strings.HasSuffix(compositeKey, compositeKeyDelimiter) || len(compositeKey) < 2 {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+	parts := strings.Split(compositeKey[len(compositeKeyNamespace):len(compositeKey)-len(compositeKeyDelimiter)], compositeKeyDelimiter)
+	return parts[0], parts[1:], nil
+}
+
+// PartialCompositeKeyQuery returns an iterator over all the composite keys of
+// the given object type starting with the given attributes, as a range query
+// over the keys sharing that prefix.
+func (stub *ChaincodeStub) PartialCompositeKeyQuery(objectType string, attributes []string) (*StateRangeQueryIterator, error) {
+	startKey, err := stub.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return stub.RangeQueryState(startKey, startKey+string(utf8.MaxRune))
+}
+
+func validateCompositeKeyAttribute(attribute string) error {
+	if !utf8.ValidString(attribute) {
+		return fmt.Errorf("Composite key attribute %q is not a valid UTF-8 string", attribute)
+	}
+	for _, r := range attribute {
+		if r == 0 || r == utf8.MaxRune {
+			return fmt.Errorf("Composite key attribute %q contains U+%04X, which is reserved", attribute, r)
+		}
+	}
+	return nil
+}
+
 // TABLE FUNCTIONALITY
 // TODO More comments here with documentation
 
@@ -647,6 +820,20 @@ func (stub *ChaincodeStub) GetTxTimestamp() (*gp.Timestamp, error) {
 	return stub.securityContext.TxTimestamp, nil
 }
 
+// GetSigners returns the enrollment certificates of the co-signers of a
+// multi-signature transaction, which the peers verified against the policy
+// of the transaction before executing it. It is empty for other transactions.
+func (stub *ChaincodeStub) GetSigners() ([][]byte, error) {
+	return stub.securityContext.Signers, nil
+}
+
+// GetLedgerID returns the ID of the ledger of the peer the transaction is
+// executed against, which is empty for the default ledger. All state accessed
+// through the stub belongs to this ledger.
+func (stub *ChaincodeStub) GetLedgerID() string {
+	return stub.ledgerID
+}
+
 func (stub *ChaincodeStub) getTable(tableName string) (*Table, error) {
 
 	tableName, err := getTableNameKey(tableName)
@@ -832,8 +1019,14 @@ func (stub *ChaincodeStub) insertRowInternal(tableName string, row Row, update b
 }
 
 // ------------- ChaincodeEvent API ----------------------
-// SetEvent saves the event to be sent when a transaction is made part of a block
+// SetEvent saves the event to be sent when a transaction is made part of a block.
+// The event is recorded in the result of the transaction and delivered by the
+// event hub of the peer to the consumers registered for the chaincode and the
+// name of the event. Only the last event set by a transaction is sent.
 func (stub *ChaincodeStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return errors.New("Invalid event name. Event name must be 1 or more characters.")
+	}
 	stub.chaincodeEvent = &pb.ChaincodeEvent{EventName: name, Payload: payload}
 	return nil
 }
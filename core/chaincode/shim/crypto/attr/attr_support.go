@@ -25,6 +25,14 @@ import (
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 )
 
+const (
+	//EnrollmentRole is the name of the attribute holding the role of the caller, e.g. CLIENT.
+	EnrollmentRole = attributes.EnrollmentRoleAttributeName
+
+	//EnrollmentAffiliation is the name of the attribute holding the affiliation of the caller.
+	EnrollmentAffiliation = attributes.EnrollmentAffiliationAttributeName
+)
+
 //Attribute defines a name, value pair to be verified.
 type Attribute struct {
 	Name  string
@@ -167,7 +175,11 @@ func (attributesHandler *AttributesHandlerImpl) GetValue(attributeName string) (
 	if err != nil {
 		return nil, err
 	}
-	value, err := attributes.ReadTCertAttributeByPosition(attributesHandler.cert, header[attributeName])
+	position, ok := header[attributeName]
+	if !ok {
+		return nil, errors.New("Attribute '" + attributeName + "' not found")
+	}
+	value, err := attributes.ReadTCertAttributeByPosition(attributesHandler.cert, position)
 	if err != nil {
 		return nil, errors.New("Error reading attribute value '" + err.Error() + "'")
 	}
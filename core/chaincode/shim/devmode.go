@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// watchInterval is the interval at which the chaincode executable is checked
+// for changes
+const watchInterval = time.Second
+
+// watchExecutable restarts the chaincode when its executable is rebuilt. The
+// restarted chaincode registers again with the same name, and in dev mode the
+// peer replaces the handler of the previous one, keeping the state of the
+// chaincode. It is enabled with CORE_CHAINCODE_DEV_WATCH=true.
+func watchExecutable() {
+	path, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		chaincodeLogger.Errorf("Error finding the chaincode executable to watch: %s", err)
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		chaincodeLogger.Errorf("Error watching the chaincode executable: %s", err)
+		return
+	}
+	chaincodeLogger.Infof("Watching %s, the chaincode is restarted when it is rebuilt", path)
+
+	modTime := info.ModTime()
+	changed := false
+	for {
+		time.Sleep(watchInterval)
+		info, err = os.Stat(path)
+		if err != nil {
+			// the executable is being replaced
+			continue
+		}
+		if !info.ModTime().Equal(modTime) {
+			// wait for the build to finish writing the executable
+			modTime = info.ModTime()
+			changed = true
+			continue
+		}
+		if changed {
+			chaincodeLogger.Infof("Chaincode executable %s has been rebuilt, restarting", path)
+			if err = restartExecutable(path); err != nil {
+				chaincodeLogger.Errorf("Error restarting the chaincode: %s", err)
+			}
+			changed = false
+		}
+	}
+}
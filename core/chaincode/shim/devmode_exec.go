@@ -1,3 +1,5 @@
+// +build !windows
+
 /*
 Copyright IBM Corp. 2016 All Rights Reserved.
 
@@ -14,51 +16,15 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package noops
+package shim
 
 import (
-	pb "github.com/hyperledger/fabric/protos"
+	"os"
+	"syscall"
 )
 
-type txq struct {
-	i int
-	q []*pb.Transaction
-}
-
-func newTXQ(size int) *txq {
-	o := &txq{}
-	o.i = 0
-	if size < 1 {
-		size = 1
-	}
-	o.q = make([]*pb.Transaction, size)
-	return o
-}
-
-func (o *txq) append(tx *pb.Transaction) {
-	if cap(o.q) > o.i {
-		o.q[o.i] = tx
-		o.i++
-	}
-}
-
-func (o *txq) getTXs() []*pb.Transaction {
-	length := o.i
-	o.i = 0
-	return o.q[:length]
-}
-
-func (o *txq) isFull() bool {
-	if cap(o.q) == o.i {
-		return true
-	}
-	return false
-}
-
-func (o *txq) size() int {
-	return o.i
-}
-
-func (o *txq) reset() {
-	o.i = 0
+// restartExecutable replaces the chaincode process with a new run of path, with
+// the same arguments and environment
+func restartExecutable(path string) error {
+	return syscall.Exec(path, os.Args, os.Environ())
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	pb "github.com/hyperledger/fabric/protos"
 	"github.com/looplab/fsm"
+	"github.com/spf13/viper"
 )
 
 // PeerChaincodeStream interface for stream between Peer and chaincode instance.
@@ -76,6 +77,21 @@ func (handler *Handler) serialSend(msg *pb.ChaincodeMessage) error {
 	return nil
 }
 
+// sendQueryChunks sends the query result res to the peer in QUERY_COMPLETED_CHUNK
+// messages of chaincode.queryChunkSize bytes while it is larger than that, and
+// returns the remainder of the result, to be sent in the QUERY_COMPLETED message
+func (handler *Handler) sendQueryChunks(uuid string, res []byte) ([]byte, error) {
+	chunkSize := viper.GetInt("chaincode.queryChunkSize")
+	for chunkSize > 0 && len(res) > chunkSize {
+		chunk := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY_COMPLETED_CHUNK, Payload: res[:chunkSize], Uuid: uuid}
+		if err := handler.serialSend(chunk); err != nil {
+			return nil, err
+		}
+		res = res[chunkSize:]
+	}
+	return res, nil
+}
+
 func (handler *Handler) createChannel(uuid string) (chan pb.ChaincodeMessage, error) {
 	handler.Lock()
 	defer handler.Unlock()
@@ -222,7 +238,7 @@ func (handler *Handler) handleInit(msg *pb.ChaincodeMessage) {
 		// Call chaincode's Run
 		// Create the ChaincodeStub which the chaincode can use to callback
 		stub := new(ChaincodeStub)
-		stub.init(msg.Uuid, msg.SecurityContext)
+		stub.init(msg.Uuid, msg.LedgerID, msg.SecurityContext)
 		res, err := handler.cc.Init(stub, input.Function, input.Args)
 
 		// delete isTransaction entry
@@ -289,7 +305,7 @@ func (handler *Handler) handleTransaction(msg *pb.ChaincodeMessage) {
 		// Call chaincode's Run
 		// Create the ChaincodeStub which the chaincode can use to callback
 		stub := new(ChaincodeStub)
-		stub.init(msg.Uuid, msg.SecurityContext)
+		stub.init(msg.Uuid, msg.LedgerID, msg.SecurityContext)
 		res, err := handler.cc.Invoke(stub, input.Function, input.Args)
 
 		// delete isTransaction entry
@@ -336,7 +352,7 @@ func (handler *Handler) handleQuery(msg *pb.ChaincodeMessage) {
 		// Call chaincode's Query
 		// Create the ChaincodeStub which the chaincode can use to callback
 		stub := new(ChaincodeStub)
-		stub.init(msg.Uuid, msg.SecurityContext)
+		stub.init(msg.Uuid, msg.LedgerID, msg.SecurityContext)
 		res, err := handler.cc.Query(stub, input.Function, input.Args)
 
 		// delete isTransaction entry
@@ -350,6 +366,14 @@ func (handler *Handler) handleQuery(msg *pb.ChaincodeMessage) {
 			return
 		}
 
+		// Results larger than the chunk size are sent in several messages
+		if res, err = handler.sendQueryChunks(msg.Uuid, res); err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Errorf("[%s]Failed to send query result. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_QUERY_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
 		// Send COMPLETED message to chaincode support
 		chaincodeLogger.Debugf("[%s]Query completed. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_QUERY_COMPLETED)
 		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY_COMPLETED, Payload: res, Uuid: msg.Uuid}
@@ -430,7 +454,6 @@ func (handler *Handler) afterError(e *fsm.Event) {
 	}
 }
 
-// TODO: Implement method to get and put entire state map and not one key at a time?
 // handleGetState communicates with the validator to fetch the requested state information from the ledger.
 func (handler *Handler) handleGetState(key string, uuid string) ([]byte, error) {
 	// Create the channel on which to communicate the response from validating peer
@@ -529,6 +552,120 @@ func (handler *Handler) handlePutState(key string, value []byte, uuid string) er
 	return errors.New("Incorrect chaincode message received")
 }
 
+// handleGetStateMultipleKeys communicates with the validator to fetch the state of multiple keys from the ledger in a single request.
+func (handler *Handler) handleGetStateMultipleKeys(keys []string, uuid string) ([][]byte, error) {
+	payload := &pb.GetStateMultipleKeys{Keys: keys}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, errors.New("Failed to process get state multiple keys request")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Debug("Another state request pending for this Uuid. Cannot process.")
+		return nil, uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send GET_STATE_MULTIPLE_KEYS message to validator chaincode support
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debugf("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_STATE_MULTIPLE_KEYS)
+	if err = handler.serialSend(msg); err != nil {
+		chaincodeLogger.Errorf("[%s]error sending GET_STATE_MULTIPLE_KEYS %s", shortuuid(uuid), err)
+		return nil, errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Errorf("[%s]Received unexpected message type", shortuuid(responseMsg.Uuid))
+		return nil, errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debugf("[%s]GetStateMultipleKeys received payload %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE)
+		response := &pb.GetStateMultipleKeysResponse{}
+		if err = proto.Unmarshal(responseMsg.Payload, response); err != nil {
+			chaincodeLogger.Errorf("[%s]GetStateMultipleKeys failed to unmarshall response", shortuuid(responseMsg.Uuid))
+			return nil, errors.New("Error unmarshalling GetStateMultipleKeysResponse.")
+		}
+		if len(response.Values) != len(keys) {
+			return nil, fmt.Errorf("Received %d values for %d keys", len(response.Values), len(keys))
+		}
+		return response.Values, nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Errorf("[%s]GetStateMultipleKeys received error %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR)
+		return nil, errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Errorf("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)
+	return nil, errors.New("Incorrect chaincode message received")
+}
+
+// handlePutStateMultipleKeys communicates with the validator to put the state of multiple keys into the ledger in a single request.
+func (handler *Handler) handlePutStateMultipleKeys(keys []string, values [][]byte, uuid string) error {
+	// Check if this is a transaction
+	chaincodeLogger.Debugf("[%s]Inside putstatemultiplekeys, isTransaction = %t", shortuuid(uuid), handler.isTransaction[uuid])
+	if !handler.isTransaction[uuid] {
+		return errors.New("Cannot put state in query context")
+	}
+
+	payload := &pb.PutStateMultipleKeys{}
+	for i, key := range keys {
+		payload.KeyValues = append(payload.KeyValues, &pb.PutStateInfo{Key: key, Value: values[i]})
+	}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return errors.New("Failed to process put state multiple keys request")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Errorf("[%s]Another state request pending for this Uuid. Cannot process.", shortuuid(uuid))
+		return uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send PUT_STATE_MULTIPLE_KEYS message to validator chaincode support
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE_MULTIPLE_KEYS, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debugf("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_PUT_STATE_MULTIPLE_KEYS)
+	if err = handler.serialSend(msg); err != nil {
+		chaincodeLogger.Errorf("[%s]error sending PUT_STATE_MULTIPLE_KEYS %s", msg.Uuid, err)
+		return errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Errorf("[%s]Received unexpected message type", msg.Uuid)
+		return errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debugf("[%s]Received %s. Successfully updated state of %d keys", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE, len(keys))
+		return nil
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Errorf("[%s]Received %s. Payload: %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR, responseMsg.Payload)
+		return errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Errorf("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)
+	return errors.New("Incorrect chaincode message received")
+}
+
 // handleDelState communicates with the validator to delete a key from the state in the ledger.
 func (handler *Handler) handleDelState(key string, uuid string) error {
 	// Check if this is a transaction
@@ -577,6 +714,66 @@ func (handler *Handler) handleDelState(key string, uuid string) error {
 	return errors.New("Incorrect chaincode message received")
 }
 
+// handlePrivateState communicates with the validator to get, put or delete a
+// key of a private state collection of the chaincode. The value is only sent
+// by PUT_PRIVATE_STATE and only returned by GET_PRIVATE_STATE and
+// GET_PRIVATE_STATE_HASH.
+func (handler *Handler) handlePrivateState(msgType pb.ChaincodeMessage_Type, collection string, key string, value []byte, uuid string) ([]byte, error) {
+	// Check if this is a transaction
+	if msgType == pb.ChaincodeMessage_PUT_PRIVATE_STATE || msgType == pb.ChaincodeMessage_DEL_PRIVATE_STATE {
+		if !handler.isTransaction[uuid] {
+			return nil, fmt.Errorf("Cannot handle %s in query context", msgType)
+		}
+	} else if msgType == pb.ChaincodeMessage_GET_PRIVATE_STATE && handler.isTransaction[uuid] {
+		return nil, fmt.Errorf("Cannot handle %s in transaction context", msgType)
+	}
+
+	payload := &pb.PrivateStateInfo{Collection: collection, Key: key, Value: value}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, errors.New("Failed to process private state request")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Errorf("[%s]Another state request pending for this Uuid. Cannot process.", shortuuid(uuid))
+		return nil, uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	msg := &pb.ChaincodeMessage{Type: msgType, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debugf("[%s]Sending %s", shortuuid(msg.Uuid), msgType)
+	if err = handler.serialSend(msg); err != nil {
+		chaincodeLogger.Errorf("[%s]error sending %s %s", shortuuid(uuid), msgType, err)
+		return nil, errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Errorf("[%s]Received unexpected message type", shortuuid(uuid))
+		return nil, errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debugf("[%s]Received %s. Completed %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE, msgType)
+		return responseMsg.Payload, nil
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Errorf("[%s]Received %s. Payload: %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR, responseMsg.Payload)
+		return nil, errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Errorf("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)
+	return nil, errors.New("Incorrect chaincode message received")
+}
+
 func (handler *Handler) handleRangeQueryState(startKey, endKey string, uuid string) (*pb.RangeQueryStateResponse, error) {
 	// Create the channel on which to communicate the response from validating peer
 	respChan, uniqueReqErr := handler.createChannel(uuid)
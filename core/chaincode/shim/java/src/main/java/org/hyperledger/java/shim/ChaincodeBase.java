@@ -0,0 +1,120 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.java.shim;
+
+import java.util.logging.Level;
+import java.util.logging.Logger;
+
+import io.grpc.ManagedChannel;
+import io.grpc.Metadata;
+import io.grpc.netty.NegotiationType;
+import io.grpc.netty.NettyChannelBuilder;
+import io.grpc.stub.MetadataUtils;
+import protos.ChaincodeSupportGrpc;
+
+/**
+ * ChaincodeBase is the Java counterpart of the Chaincode interface of the Go
+ * shim. Chaincodes extend it and call {@link #start(String[])} from their main
+ * method, the peer then calls init, invoke and query with the function and the
+ * arguments of the transactions.
+ */
+public abstract class ChaincodeBase {
+
+	private static final Logger logger = Logger.getLogger(ChaincodeBase.class.getName());
+
+	private static final String PEER_ADDRESS_FLAG = "-peer.address=";
+
+	/**
+	 * The metadata in which the chaincode presents the registration token the
+	 * peer launched it with, as RegistrationTokenKey in the Go shim.
+	 */
+	private static final Metadata.Key<String> REGISTRATION_TOKEN_KEY = Metadata.Key.of(
+			"chaincode-registration-token", Metadata.ASCII_STRING_MARSHALLER);
+
+	/**
+	 * Init is called during the deploy transaction after the container has
+	 * been established, allowing the chaincode to initialize its internal data.
+	 */
+	public abstract byte[] init(ChaincodeStub stub, String function, String[] args) throws Exception;
+
+	/**
+	 * Invoke is called for every invoke transaction. The chaincode may change
+	 * its state variables.
+	 */
+	public abstract byte[] invoke(ChaincodeStub stub, String function, String[] args) throws Exception;
+
+	/**
+	 * Query is called for every query transaction. The chaincode may only read
+	 * (but not modify) its state variables and return the result.
+	 */
+	public abstract byte[] query(ChaincodeStub stub, String function, String[] args) throws Exception;
+
+	/**
+	 * Start registers the chaincode with the peer given by the -peer.address
+	 * flag, or by CORE_PEER_ADDRESS, and serves the requests of the peer until
+	 * the stream is closed. Unlike the Go shim, the Java shim does not support
+	 * TLS connections to the peer yet.
+	 */
+	public void start(String[] args) {
+		String peerAddress = getPeerAddress(args);
+		String chaincodeName = System.getenv("CORE_CHAINCODE_ID_NAME");
+		if (chaincodeName == null || chaincodeName.isEmpty()) {
+			throw new IllegalStateException("Error chaincode id not provided, CORE_CHAINCODE_ID_NAME is not set");
+		}
+
+		ManagedChannel channel = newPeerClientConnection(peerAddress);
+		try {
+			Handler handler = new Handler(this, chaincodeName);
+			ChaincodeSupportGrpc.ChaincodeSupportStub stub = ChaincodeSupportGrpc.newStub(channel);
+			String token = System.getenv("CORE_CHAINCODE_REGISTRATION_TOKEN");
+			if (token != null && !token.isEmpty()) {
+				Metadata headers = new Metadata();
+				headers.put(REGISTRATION_TOKEN_KEY, token);
+				stub = MetadataUtils.attachHeaders(stub, headers);
+			}
+			handler.chatWithPeer(stub);
+		} catch (InterruptedException e) {
+			Thread.currentThread().interrupt();
+		} finally {
+			channel.shutdownNow();
+		}
+	}
+
+	static String getPeerAddress(String[] args) {
+		for (String arg : args) {
+			if (arg.startsWith(PEER_ADDRESS_FLAG)) {
+				return arg.substring(PEER_ADDRESS_FLAG.length());
+			}
+		}
+		String peerAddress = System.getenv("CORE_PEER_ADDRESS");
+		if (peerAddress == null || peerAddress.isEmpty()) {
+			throw new IllegalStateException("Error peer address not provided, use -peer.address or CORE_PEER_ADDRESS");
+		}
+		return peerAddress;
+	}
+
+	private static ManagedChannel newPeerClientConnection(String peerAddress) {
+		logger.log(Level.FINE, "Connecting to peer {0}", peerAddress);
+		int separator = peerAddress.lastIndexOf(':');
+		if (separator < 0) {
+			throw new IllegalArgumentException("Error peer address " + peerAddress + " is not of the form host:port");
+		}
+		String host = peerAddress.substring(0, separator);
+		int port = Integer.parseInt(peerAddress.substring(separator + 1));
+		return NettyChannelBuilder.forAddress(host, port).negotiationType(NegotiationType.PLAINTEXT).build();
+	}
+}
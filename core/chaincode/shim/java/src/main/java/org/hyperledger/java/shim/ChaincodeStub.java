@@ -0,0 +1,192 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.java.shim;
+
+import java.util.Arrays;
+import java.util.List;
+
+import com.google.protobuf.ByteString;
+import com.google.protobuf.Timestamp;
+
+import protos.Chaincode.ChaincodeSecurityContext;
+import protos.Chaincodeevent.ChaincodeEvent;
+
+/**
+ * ChaincodeStub is passed to the chaincode for each Init, Invoke and Query and
+ * gives the chaincode access to the state and to the other chaincodes, as the
+ * ChaincodeStub of the Go shim.
+ */
+public class ChaincodeStub {
+
+	private final String uuid;
+	private final String ledgerID;
+	private final ChaincodeSecurityContext securityContext;
+	private final Handler handler;
+	private ChaincodeEvent event;
+
+	ChaincodeStub(String uuid, String ledgerID, ChaincodeSecurityContext securityContext, Handler handler) {
+		this.uuid = uuid;
+		this.ledgerID = ledgerID;
+		this.securityContext = securityContext;
+		this.handler = handler;
+	}
+
+	/**
+	 * getUuid returns the uuid of the transaction or query being executed.
+	 */
+	public String getUuid() {
+		return uuid;
+	}
+
+	/**
+	 * getLedgerID returns the ID of the ledger of the peer the transaction is
+	 * executed against, which is empty for the default ledger.
+	 */
+	public String getLedgerID() {
+		return ledgerID;
+	}
+
+	/**
+	 * invokeChaincode invokes another chaincode on behalf of the transaction,
+	 * and returns the result of its invoke.
+	 */
+	public byte[] invokeChaincode(String chaincodeName, String function, String... args) throws ChaincodeException {
+		return handler.handleInvokeChaincode(chaincodeName, function, Arrays.asList(args), uuid);
+	}
+
+	/**
+	 * queryChaincode queries another chaincode and returns the result of its
+	 * query.
+	 */
+	public byte[] queryChaincode(String chaincodeName, String function, String... args) throws ChaincodeException {
+		return handler.handleQueryChaincode(chaincodeName, function, Arrays.asList(args), uuid);
+	}
+
+	/**
+	 * getState returns the value of key in the state, or null if the key does
+	 * not exist.
+	 */
+	public byte[] getState(String key) throws ChaincodeException {
+		return handler.handleGetState(key, uuid);
+	}
+
+	/**
+	 * putState writes value to key in the state. It may only be called by
+	 * init and invoke.
+	 */
+	public void putState(String key, byte[] value) throws ChaincodeException {
+		if (value == null) {
+			throw new ChaincodeException("Cannot put a nil value for key " + key);
+		}
+		handler.handlePutState(key, value, uuid);
+	}
+
+	/**
+	 * getStateMultipleKeys returns the values of keys in the state, in the
+	 * order of the keys, with a single request to the peer. The value of a key
+	 * which does not exist is null.
+	 */
+	public List<byte[]> getStateMultipleKeys(List<String> keys) throws ChaincodeException {
+		return handler.handleGetStateMultipleKeys(keys, uuid);
+	}
+
+	/**
+	 * putStateMultipleKeys writes values[i] to keys[i] in the state with a
+	 * single request to the peer.
+	 */
+	public void putStateMultipleKeys(List<String> keys, List<byte[]> values) throws ChaincodeException {
+		if (keys.size() != values.size()) {
+			throw new ChaincodeException(String.format("Number of keys (%d) does not match number of values (%d)", keys.size(), values.size()));
+		}
+		if (values.contains(null)) {
+			throw new ChaincodeException("Cannot put a nil value");
+		}
+		handler.handlePutStateMultipleKeys(keys, values, uuid);
+	}
+
+	/**
+	 * delState removes key from the state. It may only be called by init and
+	 * invoke.
+	 */
+	public void delState(String key) throws ChaincodeException {
+		handler.handleDelState(key, uuid);
+	}
+
+	/**
+	 * rangeQueryState returns an iterator over the keys between startKey and
+	 * endKey, inclusive, and their values.
+	 */
+	public StateRangeQueryIterator rangeQueryState(String startKey, String endKey) throws ChaincodeException {
+		return new StateRangeQueryIterator(handler, uuid, handler.handleRangeQueryState(startKey, endKey, uuid));
+	}
+
+	/**
+	 * setEvent sets the event sent with the result of the transaction. Only
+	 * the last event set is sent.
+	 */
+	public void setEvent(String name, byte[] payload) throws ChaincodeException {
+		if (name == null || name.isEmpty()) {
+			throw new ChaincodeException("Invalid event name. Event name must be 1 or more characters.");
+		}
+		ChaincodeEvent.Builder builder = ChaincodeEvent.newBuilder().setEventName(name);
+		if (payload != null) {
+			builder.setPayload(ByteString.copyFrom(payload));
+		}
+		event = builder.build();
+	}
+
+	ChaincodeEvent getEvent() {
+		return event;
+	}
+
+	/**
+	 * getCallerCertificate returns the caller certificate.
+	 */
+	public byte[] getCallerCertificate() {
+		return securityContext.getCallerCert().toByteArray();
+	}
+
+	/**
+	 * getCallerMetadata returns the caller metadata.
+	 */
+	public byte[] getCallerMetadata() {
+		return securityContext.getMetadata().toByteArray();
+	}
+
+	/**
+	 * getBinding returns the transaction binding.
+	 */
+	public byte[] getBinding() {
+		return securityContext.getBinding().toByteArray();
+	}
+
+	/**
+	 * getPayload returns the transaction payload, which is a ChaincodeSpec
+	 * defined in fabric/protos/chaincode.proto.
+	 */
+	public byte[] getPayload() {
+		return securityContext.getPayload().toByteArray();
+	}
+
+	/**
+	 * getTxTimestamp returns the timestamp of the transaction, taken from the
+	 * peer receiving the transaction.
+	 */
+	public Timestamp getTxTimestamp() {
+		return securityContext.getTxTimestamp();
+	}
+}
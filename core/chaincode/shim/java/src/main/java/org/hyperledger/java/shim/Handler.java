@@ -0,0 +1,404 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.java.shim;
+
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Map;
+import java.util.Set;
+import java.util.concurrent.ArrayBlockingQueue;
+import java.util.concurrent.BlockingQueue;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.CountDownLatch;
+import java.util.concurrent.ExecutorService;
+import java.util.concurrent.Executors;
+import java.util.logging.Level;
+import java.util.logging.Logger;
+
+import com.google.protobuf.ByteString;
+import com.google.protobuf.InvalidProtocolBufferException;
+
+import io.grpc.Status;
+import io.grpc.stub.StreamObserver;
+import protos.Chaincode.ChaincodeID;
+import protos.Chaincode.ChaincodeInput;
+import protos.Chaincode.ChaincodeMessage;
+import protos.Chaincode.ChaincodeMessage.Type;
+import protos.Chaincode.ChaincodeSpec;
+import protos.Chaincode.GetStateMultipleKeys;
+import protos.Chaincode.GetStateMultipleKeysResponse;
+import protos.Chaincode.PutStateInfo;
+import protos.Chaincode.PutStateMultipleKeys;
+import protos.Chaincode.RangeQueryState;
+import protos.Chaincode.RangeQueryStateClose;
+import protos.Chaincode.RangeQueryStateNext;
+import protos.Chaincode.RangeQueryStateResponse;
+import protos.ChaincodeSupportGrpc.ChaincodeSupportStub;
+
+/**
+ * Handler implements the shim side of the chaincode protocol, the same way as
+ * the Handler of the Go shim. The chaincode registers with the peer, then the
+ * peer sends INIT, TRANSACTION and QUERY messages which are executed on their
+ * own thread. While executing, the chaincode sends state requests to the peer,
+ * whose RESPONSE or ERROR message is delivered to the requester by uuid.
+ */
+class Handler {
+
+	private static final Logger logger = Logger.getLogger(Handler.class.getName());
+
+	private enum State {
+		CREATED, ESTABLISHED, INIT, READY, TRANSACTION
+	}
+
+	private final ChaincodeBase chaincode;
+	private final String chaincodeName;
+	private final ExecutorService executor = Executors.newCachedThreadPool();
+	private final CountDownLatch done = new CountDownLatch(1);
+
+	// responses of the peer to the pending requests, by uuid
+	private final Map<String, BlockingQueue<ChaincodeMessage>> responseQueues = new ConcurrentHashMap<>();
+	// uuids executing a transaction, which allows put/del state
+	private final Set<String> transactions = ConcurrentHashMap.newKeySet();
+
+	private State state = State.CREATED;
+	private StreamObserver<ChaincodeMessage> peerStream;
+
+	Handler(ChaincodeBase chaincode, String chaincodeName) {
+		this.chaincode = chaincode;
+		this.chaincodeName = chaincodeName;
+	}
+
+	/**
+	 * chatWithPeer registers the chaincode on the stream and handles the
+	 * messages of the peer until the stream ends.
+	 */
+	void chatWithPeer(ChaincodeSupportStub stub) throws InterruptedException {
+		peerStream = stub.register(new StreamObserver<ChaincodeMessage>() {
+			@Override
+			public void onNext(ChaincodeMessage message) {
+				handleMessage(message);
+			}
+
+			@Override
+			public void onError(Throwable t) {
+				logger.log(Level.SEVERE, "Received error from server, ending chaincode stream", t);
+				done.countDown();
+			}
+
+			@Override
+			public void onCompleted() {
+				logger.fine("Received EOF, ending chaincode stream");
+				done.countDown();
+			}
+		});
+
+		logger.log(Level.FINE, "Registering.. sending {0}", Type.REGISTER);
+		ByteString payload = ChaincodeID.newBuilder().setName(chaincodeName).build().toByteString();
+		send(ChaincodeMessage.newBuilder().setType(Type.REGISTER).setPayload(payload).build());
+
+		try {
+			done.await();
+		} finally {
+			// interrupts the requests waiting for a response
+			executor.shutdownNow();
+		}
+	}
+
+	private synchronized void handleMessage(ChaincodeMessage message) {
+		logger.log(Level.FINE, "[{0}]Received message {1} from peer in state {2}",
+				new Object[] { shortUuid(message.getUuid()), message.getType(), state });
+
+		switch (message.getType()) {
+		case REGISTERED:
+			transition(message, State.CREATED, State.ESTABLISHED);
+			break;
+		case READY:
+			transition(message, State.ESTABLISHED, State.READY);
+			break;
+		case INIT:
+			if (transition(message, State.ESTABLISHED, State.INIT)) {
+				executor.execute(() -> handleInit(message));
+			}
+			break;
+		case TRANSACTION:
+			if (transition(message, State.READY, State.TRANSACTION)) {
+				executor.execute(() -> handleTransaction(message));
+			}
+			break;
+		case QUERY:
+			if (state == State.READY || state == State.TRANSACTION) {
+				executor.execute(() -> handleQuery(message));
+			} else {
+				unexpectedMessage(message);
+			}
+			break;
+		case RESPONSE:
+		case ERROR:
+			BlockingQueue<ChaincodeMessage> queue = responseQueues.get(message.getUuid());
+			if (queue != null) {
+				queue.offer(message);
+			} else if (state == State.CREATED) {
+				logger.log(Level.SEVERE, "Error registering chaincode {0}: {1}",
+						new Object[] { chaincodeName, message.getPayload().toStringUtf8() });
+				endChat(message.getPayload().toStringUtf8());
+			} else {
+				logger.log(Level.WARNING, "[{0}]Received {1} with no request pending",
+						new Object[] { shortUuid(message.getUuid()), message.getType() });
+			}
+			break;
+		default:
+			unexpectedMessage(message);
+		}
+	}
+
+	private boolean transition(ChaincodeMessage message, State src, State dst) {
+		if (state != src) {
+			unexpectedMessage(message);
+			return false;
+		}
+		state = dst;
+		return true;
+	}
+
+	private void unexpectedMessage(ChaincodeMessage message) {
+		String error = String.format("[%s]Chaincode handler cannot handle message (%s) in state %s",
+				shortUuid(message.getUuid()), message.getType(), state);
+		logger.severe(error);
+		endChat(error);
+	}
+
+	private void endChat(String error) {
+		synchronized (peerStream) {
+			peerStream.onError(Status.INTERNAL.withDescription(error).asRuntimeException());
+		}
+		done.countDown();
+	}
+
+	private synchronized void completed(State next, ChaincodeMessage message) {
+		state = next;
+		send(message);
+	}
+
+	private void handleInit(ChaincodeMessage message) {
+		ChaincodeMessage result = execute(message, true,
+				(stub, input) -> chaincode.init(stub, input.getFunction(), toArray(input)),
+				Type.COMPLETED, Type.ERROR);
+		completed(result.getType() == Type.COMPLETED ? State.READY : State.ESTABLISHED, result);
+	}
+
+	private void handleTransaction(ChaincodeMessage message) {
+		ChaincodeMessage result = execute(message, true,
+				(stub, input) -> chaincode.invoke(stub, input.getFunction(), toArray(input)),
+				Type.COMPLETED, Type.ERROR);
+		completed(State.READY, result);
+	}
+
+	private void handleQuery(ChaincodeMessage message) {
+		ChaincodeMessage result = execute(message, false,
+				(stub, input) -> chaincode.query(stub, input.getFunction(), toArray(input)),
+				Type.QUERY_COMPLETED, Type.QUERY_ERROR);
+		send(result);
+	}
+
+	@FunctionalInterface
+	private interface Function {
+		byte[] apply(ChaincodeStub stub, ChaincodeInput input) throws Exception;
+	}
+
+	private ChaincodeMessage execute(ChaincodeMessage message, boolean isTransaction, Function function,
+			Type completed, Type failed) {
+		ChaincodeMessage.Builder result = ChaincodeMessage.newBuilder().setUuid(message.getUuid());
+		ChaincodeInput input;
+		try {
+			input = ChaincodeInput.parseFrom(message.getPayload());
+		} catch (InvalidProtocolBufferException e) {
+			logger.log(Level.FINE, "[{0}]Incorrect payload format. Sending {1}",
+					new Object[] { shortUuid(message.getUuid()), failed });
+			return result.setType(failed).setPayload(ByteString.copyFromUtf8(e.getMessage())).build();
+		}
+
+		if (isTransaction) {
+			transactions.add(message.getUuid());
+		}
+		ChaincodeStub stub = new ChaincodeStub(message.getUuid(), message.getLedgerID(),
+				message.getSecurityContext(), this);
+		try {
+			byte[] response = function.apply(stub, input);
+			logger.log(Level.FINE, "[{0}]Execution completed. Sending {1}",
+					new Object[] { shortUuid(message.getUuid()), completed });
+			result.setType(completed);
+			if (response != null) {
+				result.setPayload(ByteString.copyFrom(response));
+			}
+		} catch (Exception e) {
+			logger.log(Level.SEVERE, String.format("[%s]Execution failed. Sending %s", shortUuid(message.getUuid()), failed), e);
+			String error = e.getMessage() != null ? e.getMessage() : e.toString();
+			result.setType(failed).setPayload(ByteString.copyFromUtf8(error));
+		} finally {
+			transactions.remove(message.getUuid());
+		}
+		if (stub.getEvent() != null) {
+			result.setChaincodeEvent(stub.getEvent());
+		}
+		return result.build();
+	}
+
+	private static String[] toArray(ChaincodeInput input) {
+		return input.getArgsList().toArray(new String[0]);
+	}
+
+	private void send(ChaincodeMessage message) {
+		synchronized (peerStream) {
+			peerStream.onNext(message);
+		}
+	}
+
+	/**
+	 * request sends a request to the peer on behalf of uuid and waits for the
+	 * RESPONSE, whose payload is returned.
+	 */
+	private ByteString request(String uuid, Type type, ByteString payload) throws ChaincodeException {
+		BlockingQueue<ChaincodeMessage> queue = new ArrayBlockingQueue<>(1);
+		if (responseQueues.putIfAbsent(uuid, queue) != null) {
+			logger.log(Level.SEVERE, "[{0}]Another request pending for this Uuid. Cannot process.", shortUuid(uuid));
+			throw new ChaincodeException(String.format("[%s]Cannot create response channel", shortUuid(uuid)));
+		}
+		try {
+			logger.log(Level.FINE, "[{0}]Sending {1}", new Object[] { shortUuid(uuid), type });
+			send(ChaincodeMessage.newBuilder().setType(type).setPayload(payload).setUuid(uuid).build());
+
+			ChaincodeMessage response = queue.take();
+			if (response.getType() == Type.RESPONSE) {
+				return response.getPayload();
+			}
+			logger.log(Level.SEVERE, "[{0}]Received {1}", new Object[] { shortUuid(uuid), response.getType() });
+			throw new ChaincodeException(response.getPayload().toStringUtf8());
+		} catch (InterruptedException e) {
+			Thread.currentThread().interrupt();
+			throw new ChaincodeException("Chaincode stream ended while waiting for the response of the peer");
+		} finally {
+			responseQueues.remove(uuid);
+		}
+	}
+
+	private void checkTransaction(String uuid, String operation) throws ChaincodeException {
+		if (!transactions.contains(uuid)) {
+			throw new ChaincodeException("Cannot " + operation + " in query context");
+		}
+	}
+
+	byte[] handleGetState(String key, String uuid) throws ChaincodeException {
+		ByteString payload = request(uuid, Type.GET_STATE, ByteString.copyFromUtf8(key));
+		return payload.isEmpty() ? null : payload.toByteArray();
+	}
+
+	void handlePutState(String key, byte[] value, String uuid) throws ChaincodeException {
+		checkTransaction(uuid, "put state");
+		PutStateInfo payload = PutStateInfo.newBuilder().setKey(key).setValue(ByteString.copyFrom(value)).build();
+		request(uuid, Type.PUT_STATE, payload.toByteString());
+	}
+
+	void handleDelState(String key, String uuid) throws ChaincodeException {
+		checkTransaction(uuid, "del state");
+		request(uuid, Type.DEL_STATE, ByteString.copyFromUtf8(key));
+	}
+
+	List<byte[]> handleGetStateMultipleKeys(List<String> keys, String uuid) throws ChaincodeException {
+		GetStateMultipleKeys payload = GetStateMultipleKeys.newBuilder().addAllKeys(keys).build();
+		ByteString response = request(uuid, Type.GET_STATE_MULTIPLE_KEYS, payload.toByteString());
+		List<ByteString> values = parse(() -> GetStateMultipleKeysResponse.parseFrom(response)).getValuesList();
+		List<byte[]> result = new ArrayList<>(values.size());
+		for (ByteString value : values) {
+			result.add(value.isEmpty() ? null : value.toByteArray());
+		}
+		return result;
+	}
+
+	void handlePutStateMultipleKeys(List<String> keys, List<byte[]> values, String uuid) throws ChaincodeException {
+		checkTransaction(uuid, "put state");
+		PutStateMultipleKeys.Builder payload = PutStateMultipleKeys.newBuilder();
+		for (int i = 0; i < keys.size(); i++) {
+			payload.addKeyValues(PutStateInfo.newBuilder().setKey(keys.get(i)).setValue(ByteString.copyFrom(values.get(i))));
+		}
+		request(uuid, Type.PUT_STATE_MULTIPLE_KEYS, payload.build().toByteString());
+	}
+
+	RangeQueryStateResponse handleRangeQueryState(String startKey, String endKey, String uuid) throws ChaincodeException {
+		RangeQueryState payload = RangeQueryState.newBuilder().setStartKey(startKey).setEndKey(endKey).build();
+		ByteString response = request(uuid, Type.RANGE_QUERY_STATE, payload.toByteString());
+		return parse(() -> RangeQueryStateResponse.parseFrom(response));
+	}
+
+	RangeQueryStateResponse handleRangeQueryStateNext(String id, String uuid) throws ChaincodeException {
+		RangeQueryStateNext payload = RangeQueryStateNext.newBuilder().setID(id).build();
+		ByteString response = request(uuid, Type.RANGE_QUERY_STATE_NEXT, payload.toByteString());
+		return parse(() -> RangeQueryStateResponse.parseFrom(response));
+	}
+
+	void handleRangeQueryStateClose(String id, String uuid) throws ChaincodeException {
+		RangeQueryStateClose payload = RangeQueryStateClose.newBuilder().setID(id).build();
+		request(uuid, Type.RANGE_QUERY_STATE_CLOSE, payload.toByteString());
+	}
+
+	byte[] handleInvokeChaincode(String chaincodeName, String function, List<String> args, String uuid)
+			throws ChaincodeException {
+		checkTransaction(uuid, "invoke chaincode");
+		ChaincodeMessage response = callChaincode(Type.INVOKE_CHAINCODE, chaincodeName, function, args, uuid);
+		if (response.getType() != Type.COMPLETED) {
+			throw new ChaincodeException(response.getPayload().toStringUtf8());
+		}
+		return response.getPayload().toByteArray();
+	}
+
+	byte[] handleQueryChaincode(String chaincodeName, String function, List<String> args, String uuid)
+			throws ChaincodeException {
+		ChaincodeMessage response = callChaincode(Type.INVOKE_QUERY, chaincodeName, function, args, uuid);
+		if (response.getType() != Type.QUERY_COMPLETED) {
+			throw new ChaincodeException(response.getPayload().toStringUtf8());
+		}
+		return response.getPayload().toByteArray();
+	}
+
+	// callChaincode returns the message sent by the called chaincode, which the
+	// peer relays in the payload of its response
+	private ChaincodeMessage callChaincode(Type type, String chaincodeName, String function, List<String> args,
+			String uuid) throws ChaincodeException {
+		ChaincodeSpec payload = ChaincodeSpec.newBuilder()
+				.setChaincodeID(ChaincodeID.newBuilder().setName(chaincodeName))
+				.setCtorMsg(ChaincodeInput.newBuilder().setFunction(function).addAllArgs(args))
+				.build();
+		ByteString response = request(uuid, type, payload.toByteString());
+		return parse(() -> ChaincodeMessage.parseFrom(response));
+	}
+
+	@FunctionalInterface
+	private interface Parser<T> {
+		T parse() throws InvalidProtocolBufferException;
+	}
+
+	private static <T> T parse(Parser<T> parser) throws ChaincodeException {
+		try {
+			return parser.parse();
+		} catch (InvalidProtocolBufferException e) {
+			throw new ChaincodeException("Error unmarshaling the response of the peer: " + e.getMessage());
+		}
+	}
+
+	private static String shortUuid(String uuid) {
+		return uuid.length() < 8 ? uuid : uuid.substring(0, 8);
+	}
+}
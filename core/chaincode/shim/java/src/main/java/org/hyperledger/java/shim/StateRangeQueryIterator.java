@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.java.shim;
+
+import java.util.AbstractMap.SimpleImmutableEntry;
+import java.util.Map;
+
+import protos.Chaincode.RangeQueryStateKeyValue;
+import protos.Chaincode.RangeQueryStateResponse;
+
+/**
+ * StateRangeQueryIterator iterates over the keys and values returned by a range
+ * query. The peer sends them in batches, and the next batch is requested only
+ * when the current one has been read.
+ */
+public class StateRangeQueryIterator {
+
+	private final Handler handler;
+	private final String uuid;
+	private RangeQueryStateResponse response;
+	private int currentLoc;
+
+	StateRangeQueryIterator(Handler handler, String uuid, RangeQueryStateResponse response) {
+		this.handler = handler;
+		this.uuid = uuid;
+		this.response = response;
+	}
+
+	/**
+	 * hasNext returns true if the range query iterator contains additional keys
+	 * and values.
+	 */
+	public boolean hasNext() {
+		return currentLoc < response.getKeysAndValuesCount() || response.getHasMore();
+	}
+
+	/**
+	 * next returns the next key and value in the range query iterator.
+	 */
+	public Map.Entry<String, byte[]> next() throws ChaincodeException {
+		if (currentLoc >= response.getKeysAndValuesCount()) {
+			if (!response.getHasMore()) {
+				throw new ChaincodeException("No such key");
+			}
+			response = handler.handleRangeQueryStateNext(response.getID(), uuid);
+			currentLoc = 0;
+		}
+		RangeQueryStateKeyValue keyValue = response.getKeysAndValues(currentLoc++);
+		return new SimpleImmutableEntry<>(keyValue.getKey(), keyValue.getValue().toByteArray());
+	}
+
+	/**
+	 * close closes the range query iterator. This should be called when done
+	 * reading from the iterator to free up resources.
+	 */
+	public void close() throws ChaincodeException {
+		handler.handleRangeQueryStateClose(response.getID(), uuid);
+	}
+}
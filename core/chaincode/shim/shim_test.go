@@ -117,3 +117,58 @@ func TestChaincodeLogging(t *testing.T) {
 		t.Errorf("'bar' should be enabled for LogCritical")
 	}
 }
+
+// TestCompositeKey tests that composite keys can be split back into the
+// object type and attributes they were created from.
+func TestCompositeKey(t *testing.T) {
+	stub := &ChaincodeStub{}
+	compositeKey, err := stub.CreateCompositeKey("owner", []string{"alice", "asset1"})
+	if err != nil {
+		t.Fatalf("CreateCompositeKey failed: %s", err)
+	}
+	if compositeKey != "\x00owner\x00alice\x00asset1\x00" {
+		t.Errorf("CreateCompositeKey returned %q", compositeKey)
+	}
+	objectType, attributes, err := stub.SplitCompositeKey(compositeKey)
+	if err != nil {
+		t.Fatalf("SplitCompositeKey failed: %s", err)
+	}
+	if objectType != "owner" || len(attributes) != 2 || attributes[0] != "alice" || attributes[1] != "asset1" {
+		t.Errorf("SplitCompositeKey returned %q, %q", objectType, attributes)
+	}
+
+	compositeKey, _ = stub.CreateCompositeKey("owner", nil)
+	if objectType, attributes, _ = stub.SplitCompositeKey(compositeKey); objectType != "owner" || len(attributes) != 0 {
+		t.Errorf("SplitCompositeKey returned %q, %q for a key without attributes", objectType, attributes)
+	}
+
+	if _, err = stub.CreateCompositeKey("owner", []string{"ali\x00ce"}); err == nil {
+		t.Errorf("CreateCompositeKey should reject attributes containing U+0000")
+	}
+	if _, err = stub.CreateCompositeKey("owner\xff", nil); err == nil {
+		t.Errorf("CreateCompositeKey should reject invalid UTF-8")
+	}
+	if _, _, err = stub.SplitCompositeKey("key1"); err == nil {
+		t.Errorf("SplitCompositeKey should reject simple keys")
+	}
+}
+
+// TestSetEvent tests that the event set by a chaincode replaces the previous
+// one and that nameless events are rejected.
+func TestSetEvent(t *testing.T) {
+	stub := &ChaincodeStub{}
+	if err := stub.SetEvent("", []byte("payload")); err == nil {
+		t.Errorf("SetEvent should reject an empty event name")
+	}
+	if stub.chaincodeEvent != nil {
+		t.Errorf("SetEvent should not set a rejected event")
+	}
+
+	stub.SetEvent("event1", []byte("payload1"))
+	if err := stub.SetEvent("event2", []byte("payload2")); err != nil {
+		t.Fatalf("SetEvent failed: %s", err)
+	}
+	if stub.chaincodeEvent.EventName != "event2" || string(stub.chaincodeEvent.Payload) != "payload2" {
+		t.Errorf("SetEvent set %v, expected the last event", stub.chaincodeEvent)
+	}
+}
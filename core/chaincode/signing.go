@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// GetCodeSigningMessage returns the message signed by the deployer of the
+// chaincode package of cds. The name of a deployed chaincode is the hash of its
+// package and constructor, so signing it with the package binds the signature
+// to the chaincode.
+func GetCodeSigningMessage(cds *pb.ChaincodeDeploymentSpec) []byte {
+	msg := []byte(cds.ChaincodeSpec.ChaincodeID.Name)
+	return append(msg, cds.CodePackage...)
+}
+
+// SignCodePackage signs the chaincode package of cds with the enrollment key
+// of the deployer and adds the signature and the enrollment certificate to cds
+func SignCodePackage(handler crypto.CertificateHandler, cds *pb.ChaincodeDeploymentSpec) error {
+	sig, err := handler.Sign(GetCodeSigningMessage(cds))
+	if err != nil {
+		return fmt.Errorf("Error signing chaincode package: %s", err)
+	}
+	cds.DeployerCert = handler.GetCertificate()
+	cds.CodeSignature = sig
+	return nil
+}
+
+// verifyCodePackage checks the signature of the chaincode package of cds when
+// chaincode.signedDeployment.enabled is set, and that the deployer is one of
+// the enrollment IDs listed in chaincode.signedDeployment.deployers. An empty
+// list accepts any deployer enrolled with the ECA.
+func verifyCodePackage(secHelper crypto.Peer, cds *pb.ChaincodeDeploymentSpec) error {
+	if !viper.GetBool("chaincode.signedDeployment.enabled") {
+		return nil
+	}
+	chaincode := cds.ChaincodeSpec.ChaincodeID.Name
+	if secHelper == nil {
+		return fmt.Errorf("Error verifying chaincode %s: signed deployment requires security to be enabled", chaincode)
+	}
+	if len(cds.DeployerCert) == 0 || len(cds.CodeSignature) == 0 {
+		return fmt.Errorf("Error verifying chaincode %s: the chaincode package is not signed", chaincode)
+	}
+
+	deployer, err := secHelper.VerifyEnrollmentSignature(cds.DeployerCert, cds.CodeSignature, GetCodeSigningMessage(cds))
+	if err != nil {
+		return fmt.Errorf("Error verifying the signature of chaincode %s: %s", chaincode, err)
+	}
+
+	deployers := viper.GetStringSlice("chaincode.signedDeployment.deployers")
+	if len(deployers) == 0 {
+		return nil
+	}
+	for _, d := range deployers {
+		if d == deployer {
+			return nil
+		}
+	}
+	return fmt.Errorf("Error verifying chaincode %s: %s is not allowed to deploy chaincodes", chaincode, deployer)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// mockECertHandler signs a message by prefixing it with the enrollment ID,
+// which is its certificate
+type mockECertHandler struct {
+	crypto.CertificateHandler
+	enrollID string
+}
+
+func (h *mockECertHandler) GetCertificate() []byte {
+	return []byte(h.enrollID)
+}
+
+func (h *mockECertHandler) Sign(msg []byte) ([]byte, error) {
+	return append([]byte(h.enrollID), msg...), nil
+}
+
+// mockSecHelper verifies the signatures of mockECertHandler
+type mockSecHelper struct {
+	crypto.Peer
+}
+
+func (p *mockSecHelper) VerifyEnrollmentSignature(cert, signature, message []byte) (string, error) {
+	if !bytes.Equal(signature, append(cert, message...)) {
+		return "", fmt.Errorf("invalid signature")
+	}
+	return string(cert), nil
+}
+
+func TestVerifyCodePackage(t *testing.T) {
+	// restore the configuration read by TestMain for the other tests
+	for _, key := range []string{"chaincode.signedDeployment.enabled", "chaincode.signedDeployment.deployers"} {
+		defer viper.Set(key, viper.Get(key))
+	}
+
+	newCDS := func() *pb.ChaincodeDeploymentSpec {
+		spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}
+		return &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: []byte("code")}
+	}
+	peer := &mockSecHelper{}
+
+	// packages are not verified unless signed deployment is enabled
+	viper.Set("chaincode.signedDeployment.enabled", false)
+	testutil.AssertNoError(t, verifyCodePackage(nil, newCDS()), "Verifying with signed deployment disabled")
+
+	viper.Set("chaincode.signedDeployment.enabled", true)
+	viper.Set("chaincode.signedDeployment.deployers", []string{})
+	testutil.AssertError(t, verifyCodePackage(nil, newCDS()), "Verifying without security")
+	testutil.AssertError(t, verifyCodePackage(peer, newCDS()), "Verifying an unsigned package")
+
+	cds := newCDS()
+	testutil.AssertNoError(t, SignCodePackage(&mockECertHandler{enrollID: "jim"}, cds), "Signing the package")
+	testutil.AssertEquals(t, cds.DeployerCert, []byte("jim"))
+	testutil.AssertNoError(t, verifyCodePackage(peer, cds), "Verifying with any deployer allowed")
+
+	// the signature covers the name and the package
+	tampered := newCDS()
+	tampered.CodePackage = []byte("other code")
+	tampered.DeployerCert, tampered.CodeSignature = cds.DeployerCert, cds.CodeSignature
+	testutil.AssertError(t, verifyCodePackage(peer, tampered), "Verifying a tampered package")
+
+	viper.Set("chaincode.signedDeployment.deployers", []string{"alice", "jim"})
+	testutil.AssertNoError(t, verifyCodePackage(peer, cds), "Verifying an allowed deployer")
+	viper.Set("chaincode.signedDeployment.deployers", []string{"alice"})
+	testutil.AssertError(t, verifyCodePackage(peer, cds), "Verifying a deployer not allowed")
+}
@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// txSimulation is the context shared by a transaction and all the chaincodes
+// it invokes or queries, directly or through other chaincodes. The nested
+// invocations run in nested scopes of the transaction in the ledger, so their
+// reads and writes are merged into the read/write set of the transaction.
+type txSimulation struct {
+	ledger *ledger.Ledger
+	// chaincodes executing on behalf of the transaction, outermost first
+	callStack []string
+	// first failure of a nested invocation, which fails the whole transaction
+	err error
+}
+
+type txSimulations struct {
+	sync.Mutex
+	// simulation context of each transaction being executed
+	simulationMap map[string]*txSimulation
+}
+
+// beginTxSimulation creates the simulation context of a transaction about to
+// execute chaincodeID. The ledger tx must have been started.
+func (chaincodeSupport *ChaincodeSupport) beginTxSimulation(uuid string, chaincodeID string, ledger *ledger.Ledger) {
+	chaincodeSupport.txSimulations.Lock()
+	defer chaincodeSupport.txSimulations.Unlock()
+	chaincodeSupport.txSimulations.simulationMap[uuid] = &txSimulation{ledger: ledger, callStack: []string{chaincodeID}}
+}
+
+// endTxSimulation removes the simulation context of a transaction and returns
+// the failure of its nested invocations, if any
+func (chaincodeSupport *ChaincodeSupport) endTxSimulation(uuid string) error {
+	chaincodeSupport.txSimulations.Lock()
+	defer chaincodeSupport.txSimulations.Unlock()
+	simulation, ok := chaincodeSupport.txSimulations.simulationMap[uuid]
+	if !ok {
+		return nil
+	}
+	delete(chaincodeSupport.txSimulations.simulationMap, uuid)
+	return simulation.err
+}
+
+// inTxSimulation returns true if uuid is a transaction being executed, and
+// not a query, so that the chaincodes it queries read its uncommitted state
+func (chaincodeSupport *ChaincodeSupport) inTxSimulation(uuid string) bool {
+	chaincodeSupport.txSimulations.Lock()
+	defer chaincodeSupport.txSimulations.Unlock()
+	_, ok := chaincodeSupport.txSimulations.simulationMap[uuid]
+	return ok
+}
+
+// enterChaincode records that chaincodeID is invoked, or queried if query is
+// true, by another chaincode on behalf of uuid. A chaincode that is already
+// executing cannot be invoked again, as its handler is busy with uuid. Invocations
+// start a nested scope of the transaction in the ledger.
+func (chaincodeSupport *ChaincodeSupport) enterChaincode(uuid string, chaincodeID string, query bool) error {
+	chaincodeSupport.txSimulations.Lock()
+	defer chaincodeSupport.txSimulations.Unlock()
+	simulation, ok := chaincodeSupport.txSimulations.simulationMap[uuid]
+	if !ok {
+		// a query, which has no state changes to merge
+		return nil
+	}
+	for _, executing := range simulation.callStack {
+		if executing == chaincodeID {
+			return fmt.Errorf("Chaincode %s is already executing in transaction %s, recursive invocations are not supported", chaincodeID, uuid)
+		}
+	}
+	simulation.callStack = append(simulation.callStack, chaincodeID)
+	if !query {
+		simulation.ledger.NestedTxBegin(uuid)
+	}
+	return nil
+}
+
+// exitChaincode records the completion of the innermost chaincode invoked, or
+// queried if query is true, on behalf of uuid. The state changes of a failed
+// invocation are discarded and the failure is reported when the transaction
+// completes, so all the changes of the transaction are rolled back together.
+func (chaincodeSupport *ChaincodeSupport) exitChaincode(uuid string, query bool, err error) {
+	chaincodeSupport.txSimulations.Lock()
+	defer chaincodeSupport.txSimulations.Unlock()
+	simulation, ok := chaincodeSupport.txSimulations.simulationMap[uuid]
+	if !ok {
+		return
+	}
+	chaincodeID := simulation.callStack[len(simulation.callStack)-1]
+	simulation.callStack = simulation.callStack[:len(simulation.callStack)-1]
+	if query {
+		return
+	}
+	simulation.ledger.NestedTxFinished(uuid, err == nil)
+	if err != nil && simulation.err == nil {
+		simulation.err = fmt.Errorf("Invocation of chaincode %s failed: %s", chaincodeID, err)
+	}
+}
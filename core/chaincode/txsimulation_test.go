@@ -0,0 +1,72 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestTxSimulationNestedInvocations(t *testing.T) {
+	ledgerPtr := ledger.InitTestLedger(t)
+	chaincodeSupport := &ChaincodeSupport{txSimulations: &txSimulations{simulationMap: make(map[string]*txSimulation)}}
+
+	ledgerPtr.BeginTxBatch(1)
+	ledgerPtr.TxBegin("txUuid")
+	chaincodeSupport.beginTxSimulation("txUuid", "chaincodeA", ledgerPtr)
+	testutil.AssertEquals(t, chaincodeSupport.inTxSimulation("txUuid"), true)
+	ledgerPtr.SetState("chaincodeA", "key1", []byte("value1"))
+
+	// chaincodeA invokes chaincodeB, which queries chaincodeC
+	testutil.AssertNoError(t, chaincodeSupport.enterChaincode("txUuid", "chaincodeB", false), "Error invoking chaincodeB")
+	ledgerPtr.SetState("chaincodeB", "key2", []byte("value2"))
+	testutil.AssertNoError(t, chaincodeSupport.enterChaincode("txUuid", "chaincodeC", true), "Error querying chaincodeC")
+	value, _ := ledgerPtr.GetState("chaincodeB", "key2", false)
+	testutil.AssertEquals(t, value, []byte("value2"))
+	chaincodeSupport.exitChaincode("txUuid", true, nil)
+
+	// chaincodeB cannot invoke chaincodeA again
+	testutil.AssertError(t, chaincodeSupport.enterChaincode("txUuid", "chaincodeA", false), "Expected error invoking chaincodeA recursively")
+	chaincodeSupport.exitChaincode("txUuid", false, nil)
+
+	// the changes of a failed invocation are discarded
+	testutil.AssertNoError(t, chaincodeSupport.enterChaincode("txUuid", "chaincodeC", false), "Error invoking chaincodeC")
+	ledgerPtr.SetState("chaincodeC", "key3", []byte("value3"))
+	chaincodeSupport.exitChaincode("txUuid", false, fmt.Errorf("chaincodeC failed"))
+	value, _ = ledgerPtr.GetState("chaincodeB", "key2", false)
+	testutil.AssertEquals(t, value, []byte("value2"))
+	value, _ = ledgerPtr.GetState("chaincodeC", "key3", false)
+	testutil.AssertNil(t, value)
+
+	// and the failure fails the whole transaction
+	testutil.AssertError(t, chaincodeSupport.endTxSimulation("txUuid"), "Expected the failure of chaincodeC")
+	testutil.AssertEquals(t, chaincodeSupport.inTxSimulation("txUuid"), false)
+	ledgerPtr.TxFinished("txUuid", false)
+}
+
+func TestTxSimulationQuery(t *testing.T) {
+	chaincodeSupport := &ChaincodeSupport{txSimulations: &txSimulations{simulationMap: make(map[string]*txSimulation)}}
+
+	// chaincodes queried on behalf of a query are not tracked
+	testutil.AssertEquals(t, chaincodeSupport.inTxSimulation("queryUuid"), false)
+	testutil.AssertNoError(t, chaincodeSupport.enterChaincode("queryUuid", "chaincodeB", true), "Error querying chaincodeB")
+	chaincodeSupport.exitChaincode("queryUuid", true, nil)
+	testutil.AssertNoError(t, chaincodeSupport.endTxSimulation("queryUuid"), "Error ending a query")
+}
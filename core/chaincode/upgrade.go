@@ -0,0 +1,223 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// upgradeNamespace is the state namespace recording the upgrades of the
+// chaincodes. Deployed chaincodes are named by the hex hash of their package,
+// so it does not clash with the state of a chaincode. The upgrades are written
+// by the upgrade transactions, so all the validators agree on them.
+const upgradeNamespace = "chaincode.upgrades"
+
+// successorKey holds the name of the chaincode which upgraded chaincode
+func successorKey(chaincode string) string {
+	return "successor/" + chaincode
+}
+
+// stateNamespaceKey holds the namespace of the state of a chaincode deployed
+// by an upgrade, the namespace of other chaincodes is their name
+func stateNamespaceKey(chaincode string) string {
+	return "namespace/" + chaincode
+}
+
+// deployerKey holds the enrollment ID of the deployer of a chaincode, allowed
+// to upgrade it. The versions deployed by upgrades keep the deployer of the
+// first version.
+func deployerKey(chaincode string) string {
+	return "deployer/" + chaincode
+}
+
+// getCodeSigner returns the enrollment ID of the signer of the chaincode
+// package of cds, "" if security is disabled or the package is not signed
+func getCodeSigner(secHelper crypto.Peer, cds *pb.ChaincodeDeploymentSpec) (string, error) {
+	if secHelper == nil || len(cds.DeployerCert) == 0 || len(cds.CodeSignature) == 0 {
+		return "", nil
+	}
+	signer, err := secHelper.VerifyEnrollmentSignature(cds.DeployerCert, cds.CodeSignature, GetCodeSigningMessage(cds))
+	if err != nil {
+		return "", fmt.Errorf("Error verifying the signature of chaincode %s: %s", cds.ChaincodeSpec.ChaincodeID.Name, err)
+	}
+	return signer, nil
+}
+
+// recordDeployer records the signer of the chaincode package of cds as the
+// deployer of the chaincode, in the state of its deploy transaction, which
+// must have been started
+func recordDeployer(secHelper crypto.Peer, ledger *ledger.Ledger, cds *pb.ChaincodeDeploymentSpec) error {
+	deployer, err := getCodeSigner(secHelper, cds)
+	if err != nil || deployer == "" {
+		return err
+	}
+	chaincode := cds.ChaincodeSpec.ChaincodeID.Name
+	if err = ledger.SetState(upgradeNamespace, deployerKey(chaincode), []byte(deployer)); err != nil {
+		return fmt.Errorf("Error recording the deployer of chaincode %s: %s", chaincode, err)
+	}
+	return nil
+}
+
+// authorizeUpgrade checks that, when security is enabled, the chaincode
+// package of the upgrade cds is signed by the deployer of the chaincode it
+// upgrades or by one of the enrollment IDs listed in chaincode.upgrade.upgraders
+func authorizeUpgrade(secHelper crypto.Peer, ledger *ledger.Ledger, cds *pb.ChaincodeDeploymentSpec) error {
+	if secHelper == nil {
+		return nil
+	}
+	upgraded := cds.UpgradedChaincodeName
+	upgrader, err := getCodeSigner(secHelper, cds)
+	if err != nil {
+		return err
+	}
+	if upgrader == "" {
+		return fmt.Errorf("Upgrade of chaincode %s is not signed", upgraded)
+	}
+
+	for _, u := range viper.GetStringSlice("chaincode.upgrade.upgraders") {
+		if u == upgrader {
+			return nil
+		}
+	}
+	deployer, err := ledger.GetState(upgradeNamespace, deployerKey(upgraded), false)
+	if err != nil {
+		return fmt.Errorf("Error getting the deployer of chaincode %s: %s", upgraded, err)
+	}
+	if deployer == nil || string(deployer) != upgrader {
+		return fmt.Errorf("%s is not allowed to upgrade chaincode %s", upgrader, upgraded)
+	}
+	return nil
+}
+
+// getCurrentChaincode returns the name of the latest version of chaincode. The
+// committed upgrades are followed, so the invocations of a chaincode switch to
+// the new version only once the block of the upgrade is committed.
+func getCurrentChaincode(ledger *ledger.Ledger, chaincode string) (string, error) {
+	for {
+		successor, err := ledger.GetState(upgradeNamespace, successorKey(chaincode), true)
+		if err != nil {
+			return "", fmt.Errorf("Error getting the upgrades of chaincode %s: %s", chaincode, err)
+		}
+		if successor == nil {
+			return chaincode, nil
+		}
+		chaincode = string(successor)
+	}
+}
+
+// getStateNamespace returns the namespace of the state of chaincode. The
+// uncommitted state is read, so that a chaincode initialized by an upgrade
+// transaction already accesses the state of the chaincode it upgrades.
+func getStateNamespace(ledger *ledger.Ledger, chaincode string) (string, error) {
+	namespace, err := ledger.GetState(upgradeNamespace, stateNamespaceKey(chaincode), false)
+	if err != nil {
+		return "", fmt.Errorf("Error getting the state namespace of chaincode %s: %s", chaincode, err)
+	}
+	if namespace == nil {
+		return chaincode, nil
+	}
+	return string(namespace), nil
+}
+
+// recordUpgrade checks that the chaincode upgraded by cds is deployed and is
+// the latest version, and records the upgrade in the state of the upgrade
+// transaction, which must have been started
+func recordUpgrade(ledger *ledger.Ledger, cds *pb.ChaincodeDeploymentSpec) error {
+	upgraded := cds.UpgradedChaincodeName
+	chaincode := cds.ChaincodeSpec.ChaincodeID.Name
+	if upgraded == "" {
+		return fmt.Errorf("Upgrade of chaincode %s does not name the chaincode to upgrade", chaincode)
+	}
+	if upgraded == chaincode {
+		return fmt.Errorf("Chaincode %s cannot be upgraded to itself", chaincode)
+	}
+
+	if _, err := ledger.GetTransactionByUUID(upgraded); err != nil {
+		return fmt.Errorf("Chaincode %s to upgrade is not deployed: %s", upgraded, err)
+	}
+	if _, err := ledger.GetTransactionByUUID(chaincode); err == nil {
+		return fmt.Errorf("Chaincode %s is already deployed", chaincode)
+	}
+	for _, name := range []string{upgraded, chaincode} {
+		successor, err := ledger.GetState(upgradeNamespace, successorKey(name), false)
+		if err != nil {
+			return fmt.Errorf("Error getting the upgrades of chaincode %s: %s", name, err)
+		}
+		if successor != nil {
+			return fmt.Errorf("Chaincode %s has already been upgraded to %s", name, successor)
+		}
+	}
+
+	namespace, err := getStateNamespace(ledger, upgraded)
+	if err != nil {
+		return err
+	}
+	if err = ledger.SetState(upgradeNamespace, successorKey(upgraded), []byte(chaincode)); err != nil {
+		return fmt.Errorf("Error recording the upgrade of chaincode %s: %s", upgraded, err)
+	}
+	if err = ledger.SetState(upgradeNamespace, stateNamespaceKey(chaincode), []byte(namespace)); err != nil {
+		return fmt.Errorf("Error recording the upgrade of chaincode %s: %s", upgraded, err)
+	}
+	deployer, err := ledger.GetState(upgradeNamespace, deployerKey(upgraded), false)
+	if err != nil {
+		return fmt.Errorf("Error getting the deployer of chaincode %s: %s", upgraded, err)
+	}
+	if deployer != nil {
+		if err = ledger.SetState(upgradeNamespace, deployerKey(chaincode), deployer); err != nil {
+			return fmt.Errorf("Error recording the upgrade of chaincode %s: %s", upgraded, err)
+		}
+	}
+	chaincodeLogger.Infof("Upgrading chaincode %s to %s, state namespace %s", upgraded, chaincode, namespace)
+	return nil
+}
+
+// getCurrentChaincode returns the latest version of the chaincode invoked or
+// queried by the chaincode of the handler on behalf of uuid
+func (handler *Handler) getCurrentChaincode(uuid string, chaincode string) (string, error) {
+	ledgerObj, err := handler.getLedger(uuid)
+	if err != nil {
+		return "", err
+	}
+	return getCurrentChaincode(ledgerObj, chaincode)
+}
+
+// getStateNamespace returns the namespace of the state of the chaincode of the
+// handler in the ledger of uuid. The namespace of a chaincode never changes, so
+// it is looked up once per ledger.
+func (handler *Handler) getStateNamespace(uuid string, ledgerObj *ledger.Ledger) (string, error) {
+	ledgerID := handler.getLedgerID(uuid)
+	handler.RLock()
+	namespace, ok := handler.stateNamespaces[ledgerID]
+	handler.RUnlock()
+	if ok {
+		return namespace, nil
+	}
+
+	namespace, err := getStateNamespace(ledgerObj, handler.ChaincodeID.Name)
+	if err != nil {
+		return "", err
+	}
+	handler.Lock()
+	handler.stateNamespaces[ledgerID] = namespace
+	handler.Unlock()
+	return namespace, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func newTestUpgradeSpec(chaincode string, upgraded string) *pb.ChaincodeDeploymentSpec {
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Name: chaincode}}
+	return &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, UpgradedChaincodeName: upgraded}
+}
+
+func commitTestTransaction(t *testing.T, ledgerPtr *ledger.Ledger, batchID int, tx *pb.Transaction, execute func() error) error {
+	ledgerPtr.BeginTxBatch(batchID)
+	ledgerPtr.TxBegin(tx.Uuid)
+	err := execute()
+	ledgerPtr.TxFinished(tx.Uuid, err == nil)
+	testutil.AssertNoError(t, ledgerPtr.CommitTxBatch(batchID, []*pb.Transaction{tx}, nil, nil), "Error committing batch")
+	return err
+}
+
+func TestUpgrade(t *testing.T) {
+	ledgerPtr := ledger.InitTestLedger(t)
+
+	deployTx, err := pb.NewChaincodeDeployTransaction(newTestUpgradeSpec("oldcc", ""), "oldcc")
+	testutil.AssertNoError(t, err, "Error creating deploy transaction")
+	commitTestTransaction(t, ledgerPtr, 1, deployTx, func() error {
+		return ledgerPtr.SetState("oldcc", "key", []byte("value"))
+	})
+
+	// an upgrade must name a deployed and different chaincode
+	testutil.AssertError(t, recordUpgrade(ledgerPtr, newTestUpgradeSpec("newcc", "")), "Expected error upgrading no chaincode")
+	testutil.AssertError(t, recordUpgrade(ledgerPtr, newTestUpgradeSpec("oldcc", "oldcc")), "Expected error upgrading chaincode to itself")
+	testutil.AssertError(t, recordUpgrade(ledgerPtr, newTestUpgradeSpec("newcc", "unknowncc")), "Expected error upgrading undeployed chaincode")
+
+	cds := newTestUpgradeSpec("newcc", "oldcc")
+	upgradeTx, err := pb.NewChaincodeUpgradeTransaction(cds, "newcc")
+	testutil.AssertNoError(t, err, "Error creating upgrade transaction")
+	testutil.AssertEquals(t, upgradeTx.Type, pb.Transaction_CHAINCODE_UPGRADE)
+	err = commitTestTransaction(t, ledgerPtr, 2, upgradeTx, func() error {
+		if err := recordUpgrade(ledgerPtr, cds); err != nil {
+			return err
+		}
+
+		// the new version is initialized on the state of the old one, which
+		// is still invoked until the upgrade is committed
+		namespace, err := getStateNamespace(ledgerPtr, "newcc")
+		testutil.AssertNoError(t, err, "Error getting state namespace")
+		testutil.AssertEquals(t, namespace, "oldcc")
+		current, err := getCurrentChaincode(ledgerPtr, "oldcc")
+		testutil.AssertNoError(t, err, "Error getting current chaincode")
+		testutil.AssertEquals(t, current, "oldcc")
+		return nil
+	})
+	testutil.AssertNoError(t, err, "Error upgrading chaincode")
+
+	current, err := getCurrentChaincode(ledgerPtr, "oldcc")
+	testutil.AssertNoError(t, err, "Error getting current chaincode")
+	testutil.AssertEquals(t, current, "newcc")
+	namespace, err := getStateNamespace(ledgerPtr, "oldcc")
+	testutil.AssertNoError(t, err, "Error getting state namespace")
+	testutil.AssertEquals(t, namespace, "oldcc")
+
+	// a chaincode is upgraded once, from its latest version
+	ledgerPtr.BeginTxBatch(3)
+	ledgerPtr.TxBegin("othercc")
+	testutil.AssertError(t, recordUpgrade(ledgerPtr, newTestUpgradeSpec("othercc", "oldcc")), "Expected error upgrading chaincode twice")
+	testutil.AssertError(t, recordUpgrade(ledgerPtr, newTestUpgradeSpec("oldcc", "newcc")), "Expected error upgrading to a deployed chaincode")
+	ledgerPtr.TxFinished("othercc", false)
+	ledgerPtr.RollbackTxBatch(3)
+}
+
+func TestAuthorizeUpgrade(t *testing.T) {
+	defer viper.Set("chaincode.upgrade.upgraders", viper.Get("chaincode.upgrade.upgraders"))
+	viper.Set("chaincode.upgrade.upgraders", []string{})
+
+	ledgerPtr := ledger.InitTestLedger(t)
+	peer := &mockSecHelper{}
+
+	cds := newTestUpgradeSpec("oldcc", "")
+	testutil.AssertNoError(t, SignCodePackage(&mockECertHandler{enrollID: "jim"}, cds), "Error signing the package")
+	deployTx, err := pb.NewChaincodeDeployTransaction(cds, "oldcc")
+	testutil.AssertNoError(t, err, "Error creating deploy transaction")
+	err = commitTestTransaction(t, ledgerPtr, 1, deployTx, func() error {
+		return recordDeployer(peer, ledgerPtr, cds)
+	})
+	testutil.AssertNoError(t, err, "Error recording the deployer")
+
+	// without security the upgrades are not signed
+	testutil.AssertNoError(t, authorizeUpgrade(nil, ledgerPtr, newTestUpgradeSpec("newcc", "oldcc")), "Error authorizing upgrade without security")
+	testutil.AssertError(t, authorizeUpgrade(peer, ledgerPtr, newTestUpgradeSpec("newcc", "oldcc")), "Expected error authorizing unsigned upgrade")
+
+	byAlice := newTestUpgradeSpec("newcc", "oldcc")
+	testutil.AssertNoError(t, SignCodePackage(&mockECertHandler{enrollID: "alice"}, byAlice), "Error signing the package")
+	testutil.AssertError(t, authorizeUpgrade(peer, ledgerPtr, byAlice), "Expected error authorizing upgrade by another user")
+	viper.Set("chaincode.upgrade.upgraders", []string{"alice"})
+	testutil.AssertNoError(t, authorizeUpgrade(peer, ledgerPtr, byAlice), "Error authorizing upgrade by an allowed upgrader")
+	viper.Set("chaincode.upgrade.upgraders", []string{})
+
+	// the signature covers the package of the new version
+	tampered := newTestUpgradeSpec("newcc", "oldcc")
+	tampered.CodePackage = []byte("other code")
+	tampered.DeployerCert, tampered.CodeSignature = byAlice.DeployerCert, byAlice.CodeSignature
+	testutil.AssertError(t, authorizeUpgrade(peer, ledgerPtr, tampered), "Expected error authorizing tampered upgrade")
+
+	byJim := newTestUpgradeSpec("newcc", "oldcc")
+	testutil.AssertNoError(t, SignCodePackage(&mockECertHandler{enrollID: "jim"}, byJim), "Error signing the package")
+	upgradeTx, err := pb.NewChaincodeUpgradeTransaction(byJim, "newcc")
+	testutil.AssertNoError(t, err, "Error creating upgrade transaction")
+	err = commitTestTransaction(t, ledgerPtr, 2, upgradeTx, func() error {
+		if err := authorizeUpgrade(peer, ledgerPtr, byJim); err != nil {
+			return err
+		}
+		return recordUpgrade(ledgerPtr, byJim)
+	})
+	testutil.AssertNoError(t, err, "Error upgrading chaincode by its deployer")
+
+	// the new version keeps the deployer of the first one
+	deployer, err := ledgerPtr.GetState(upgradeNamespace, deployerKey("newcc"), true)
+	testutil.AssertNoError(t, err, "Error getting the deployer")
+	testutil.AssertEquals(t, string(deployer), "jim")
+}
@@ -0,0 +1,59 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NormalizeAddress returns the canonical form of address, a host:port where
+// the host is a name, an IPv4 address or a bracketed IPv6 address, such as
+// [::1]:30303. IP addresses are formatted as net.IP formats them, so that
+// the same address is always written the same way. An IPv6 address which is
+// not bracketed is rejected, as its port cannot be told apart from it.
+func NormalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("Invalid address %s, expected host:port, with IPv6 hosts in brackets: %s", address, err)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return "", fmt.Errorf("Invalid port in address %s: %s", address, err)
+	}
+	// zones, as in fe80::1%eth0, are not parsed by net.ParseIP
+	ip, zone := host, ""
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		ip, zone = host[:i], host[i:]
+	}
+	if parsed := net.ParseIP(ip); parsed != nil {
+		host = parsed.String() + zone
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// SameAddress returns whether the addresses a and b are the same once
+// normalized, or are equal if either is not a valid address
+func SameAddress(a string, b string) bool {
+	na, errA := NormalizeAddress(a)
+	nb, errB := NormalizeAddress(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return na == nb
+}
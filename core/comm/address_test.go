@@ -0,0 +1,52 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import "testing"
+
+func TestNormalizeAddress(t *testing.T) {
+	for address, expected := range map[string]string{
+		"localhost:30303":         "localhost:30303",
+		" 127.0.0.1:30303 ":       "127.0.0.1:30303",
+		"[::1]:30303":             "[::1]:30303",
+		"[0:0::1]:30303":          "[::1]:30303",
+		"[2001:DB8:0:0::1]:7051":  "[2001:db8::1]:7051",
+		"[::ffff:10.0.0.1]:30303": "10.0.0.1:30303",
+		"[fe80::0:1%eth0]:30303":  "[fe80::1%eth0]:30303",
+		"[::]:30303":              "[::]:30303",
+		":30303":                  ":30303",
+		"vp0.example.com:http":    "vp0.example.com:http",
+	} {
+		normalized, err := NormalizeAddress(address)
+		if err != nil || normalized != expected {
+			t.Errorf("Expected %q to normalize to %q, got %q (%v)", address, expected, normalized, err)
+		}
+	}
+
+	for _, address := range []string{"", "localhost", "::1:30303", "2001:db8::1", "[::1]", "localhost:port0"} {
+		if normalized, err := NormalizeAddress(address); err == nil {
+			t.Errorf("Expected %q to be rejected, got %q", address, normalized)
+		}
+	}
+
+	if !SameAddress("[::1]:30303", "[0::1]:30303") || SameAddress("[::1]:30303", "127.0.0.1:30303") {
+		t.Errorf("Expected IPv6 addresses to compare once normalized")
+	}
+	if !SameAddress("someHost", "someHost") || SameAddress("someHost", "otherHost") {
+		t.Errorf("Expected invalid addresses to compare as strings")
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+// sizeLimitedCodec is the protobuf codec of gRPC, which refuses to send or
+// receive messages larger than its limits, 0 meaning no limit
+type sizeLimitedCodec struct {
+	maxSend int
+	maxRecv int
+}
+
+func (c sizeLimitedCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := proto.Marshal(v.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+	if c.maxSend > 0 && len(data) > c.maxSend {
+		return nil, fmt.Errorf("Error sending message of %d bytes, larger than the maximum of %d bytes", len(data), c.maxSend)
+	}
+	return data, nil
+}
+
+func (c sizeLimitedCodec) Unmarshal(data []byte, v interface{}) error {
+	if c.maxRecv > 0 && len(data) > c.maxRecv {
+		return fmt.Errorf("Error receiving message of %d bytes, larger than the maximum of %d bytes", len(data), c.maxRecv)
+	}
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (c sizeLimitedCodec) String() string {
+	return "proto"
+}
+
+// NewCodec returns the gRPC codec enforcing the configured maximum sizes of
+// the messages sent and received
+func NewCodec() grpc.Codec {
+	return sizeLimitedCodec{maxSend: MaxSendMessageSize(), maxRecv: MaxRecvMessageSize()}
+}
+
+// ServerOptions returns the options of gRPC servers enforcing the configured
+// maximum message sizes
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.CustomCodec(NewCodec())}
+}
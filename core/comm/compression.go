@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// CompressionGzip is the name of the gzip compression advertised to peers
+const CompressionGzip = "gzip"
+
+// Compress returns payload compressed with gzip
+func Compress(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the payload compressed with gzip, and fails if it
+// decompresses to more than max bytes, unless max is 0
+func Decompress(compressed []byte, max int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var reader io.Reader = r
+	if max > 0 {
+		reader = io.LimitReader(r, int64(max)+1)
+	}
+	payload, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if max > 0 && len(payload) > max {
+		return nil, fmt.Errorf("Error decompressing payload larger than the maximum of %d bytes", max)
+	}
+	return payload, nil
+}
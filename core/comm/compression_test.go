@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestCompression(t *testing.T) {
+	payload := bytes.Repeat([]byte("block"), 1000)
+	compressed, err := Compress(payload)
+	if err != nil {
+		t.Fatalf("Failed to compress: %s", err)
+	}
+	if len(compressed) >= len(payload) {
+		t.Fatalf("Expected %d bytes to compress, got %d bytes", len(payload), len(compressed))
+	}
+	if decompressed, err := Decompress(compressed, len(payload)); err != nil || !bytes.Equal(decompressed, payload) {
+		t.Fatalf("Expected the payload to decompress to the original one (%v)", err)
+	}
+	if _, err := Decompress(compressed, len(payload)-1); err == nil {
+		t.Fatalf("Expected decompressing beyond the maximum size to fail")
+	}
+	if _, err := Decompress(payload, 0); err == nil {
+		t.Fatalf("Expected decompressing a payload which is not compressed to fail")
+	}
+}
+
+func TestCodecSizeLimits(t *testing.T) {
+	codec := sizeLimitedCodec{maxSend: 100, maxRecv: 100}
+	small := &pb.Message{Payload: make([]byte, 10)}
+	data, err := codec.Marshal(small)
+	if err != nil {
+		t.Fatalf("Failed to marshal a small message: %s", err)
+	}
+	if err = codec.Unmarshal(data, &pb.Message{}); err != nil {
+		t.Fatalf("Failed to unmarshal a small message: %s", err)
+	}
+
+	large := &pb.Message{Payload: make([]byte, 200)}
+	if _, err = codec.Marshal(large); err == nil {
+		t.Fatalf("Expected marshalling a message above the send limit to fail")
+	}
+	data, err = sizeLimitedCodec{}.Marshal(large)
+	if err != nil {
+		t.Fatalf("Failed to marshal without limits: %s", err)
+	}
+	if err = codec.Unmarshal(data, &pb.Message{}); err == nil {
+		t.Fatalf("Expected unmarshalling a message above the receive limit to fail")
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package comm
 
 import (
+	"fmt"
+
 	"github.com/spf13/viper"
 )
 
@@ -25,12 +27,24 @@ var configurationCached = false
 
 // Cached values of commonly used configuration constants.
 var tlsEnabled bool
+var maxSendMessageSize int
+var maxRecvMessageSize int
+var compression string
+var compressionThreshold int
 
 // CacheConfiguration computes and caches commonly-used constants and
 // computed constants as package variables. Routines which were previously
 func CacheConfiguration() (err error) {
 
 	tlsEnabled = viper.GetBool("peer.tls.enabled")
+	maxSendMessageSize = viper.GetInt("peer.grpc.maxSendMessageSize")
+	maxRecvMessageSize = viper.GetInt("peer.grpc.maxRecvMessageSize")
+	compression = viper.GetString("peer.grpc.compression")
+	compressionThreshold = viper.GetInt("peer.grpc.compressionThreshold")
+	if compression != CompressionGzip && compression != "none" && compression != "" {
+		err = fmt.Errorf("Invalid peer.grpc.compression %s, expected %s or none", compression, CompressionGzip)
+		compression = "none"
+	}
 
 	configurationCached = true
 
@@ -51,3 +65,40 @@ func TLSEnabled() bool {
 	}
 	return tlsEnabled
 }
+
+// MaxSendMessageSize returns the cached value for the
+// "peer.grpc.maxSendMessageSize" configuration value, 0 if unbounded
+func MaxSendMessageSize() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return maxSendMessageSize
+}
+
+// MaxRecvMessageSize returns the cached value for the
+// "peer.grpc.maxRecvMessageSize" configuration value, 0 if unbounded
+func MaxRecvMessageSize() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return maxRecvMessageSize
+}
+
+// CompressionEnabled returns whether "peer.grpc.compression" enables gzip
+// compression of the payloads sent to peers accepting it
+func CompressionEnabled() bool {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return compression == CompressionGzip
+}
+
+// CompressionThreshold returns the cached value for the
+// "peer.grpc.compressionThreshold" configuration value, the size from which
+// payloads are compressed
+func CompressionThreshold() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return compressionThreshold
+}
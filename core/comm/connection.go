@@ -5,10 +5,8 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/grpclog"
 
 	"github.com/op/go-logging"
-	"github.com/spf13/viper"
 )
 
 const defaultTimeout = time.Second * 3
@@ -16,14 +14,20 @@ const defaultTimeout = time.Second * 3
 var commLogger = logging.MustGetLogger("comm")
 
 // NewClientConnectionWithAddress Returns a new grpc.ClientConn to the given address.
+// An address prefixed with unix:// is the path of a unix socket, which is
+// connected to without TLS as its access is controlled by its permissions.
 func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled bool, creds credentials.TransportAuthenticator) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
-	if tslEnabled {
+	if path, ok := UnixSocketPath(peerAddress); ok {
+		peerAddress = path
+		opts = append(opts, grpc.WithInsecure(), grpc.WithDialer(dialUnix))
+	} else if tslEnabled {
 		opts = append(opts, grpc.WithTransportCredentials(creds))
 	} else {
 		opts = append(opts, grpc.WithInsecure())
 	}
 	opts = append(opts, grpc.WithTimeout(defaultTimeout))
+	opts = append(opts, grpc.WithCodec(NewCodec()))
 	if block {
 		opts = append(opts, grpc.WithBlock())
 	}
@@ -33,22 +37,3 @@ func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled b
 	}
 	return conn, err
 }
-
-// InitTLSForPeer returns TLS credentials for peer
-func InitTLSForPeer() credentials.TransportAuthenticator {
-	var sn string
-	if viper.GetString("peer.tls.serverhostoverride") != "" {
-		sn = viper.GetString("peer.tls.serverhostoverride")
-	}
-	var creds credentials.TransportAuthenticator
-	if viper.GetString("peer.tls.cert.file") != "" {
-		var err error
-		creds, err = credentials.NewClientTLSFromFile(viper.GetString("peer.tls.cert.file"), sn)
-		if err != nil {
-			grpclog.Fatalf("Failed to create TLS credentials %v", err)
-		}
-	} else {
-		creds = credentials.NewClientTLSFromCert(nil, sn)
-	}
-	return creds
-}
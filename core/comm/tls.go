@@ -0,0 +1,242 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/grpclog"
+)
+
+// tlsMaterial is the TLS material of the peer, loaded from the files
+// configured under peer.tls. The servers pick the current material on every
+// handshake and the clients on every connection, so that ReloadTLSCredentials
+// rotates certificates without restarting the peer.
+type tlsMaterial struct {
+	serverCert *tls.Certificate // nil if no key is configured, e.g. for chaincodes
+	serverErr  error            // why the server certificate did not load, reported to servers only
+	clientCert *tls.Certificate // presented to the servers requiring client authentication
+	rootCAs    *x509.CertPool   // verifies the servers, and the clients if required
+	rootCAsPEM []byte           // the root CAs as read, handed to the chaincodes
+	clientAuth bool
+}
+
+var currentTLS struct {
+	sync.RWMutex
+	material *tlsMaterial
+}
+
+func loadKeyPair(certFile string, keyFile string) (*tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading key pair %s and %s: %s", certFile, keyFile, err)
+	}
+	return &cert, nil
+}
+
+// loadTLSMaterial loads the peer.tls configuration. The root CAs default to
+// the server certificate, self-signed as it is, and the client certificate
+// to the server one.
+func loadTLSMaterial() (*tlsMaterial, error) {
+	material := &tlsMaterial{clientAuth: viper.GetBool("peer.tls.clientAuthRequired")}
+	var err error
+	material.serverCert, material.serverErr = loadKeyPair(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
+	if material.clientCert, err = loadKeyPair(viper.GetString("peer.tls.clientCert.file"), viper.GetString("peer.tls.clientKey.file")); err != nil {
+		return nil, err
+	}
+	if material.clientCert == nil {
+		material.clientCert = material.serverCert
+	}
+
+	var caFiles []string
+	for _, file := range strings.Split(viper.GetString("peer.tls.rootcas.files"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			caFiles = append(caFiles, file)
+		}
+	}
+	if len(caFiles) == 0 && viper.GetString("peer.tls.cert.file") != "" {
+		caFiles = []string{viper.GetString("peer.tls.cert.file")}
+	}
+	if len(caFiles) > 0 {
+		material.rootCAs = x509.NewCertPool()
+		for _, file := range caFiles {
+			pem, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading CA certificates %s: %s", file, err)
+			}
+			if !material.rootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("No CA certificate found in %s", file)
+			}
+			material.rootCAsPEM = append(material.rootCAsPEM, pem...)
+			material.rootCAsPEM = append(material.rootCAsPEM, '\n')
+		}
+	}
+	if material.clientAuth && material.rootCAs == nil {
+		return nil, fmt.Errorf("Client authentication requires CA certificates to verify the clients")
+	}
+	return material, nil
+}
+
+func getTLSMaterial() (*tlsMaterial, error) {
+	currentTLS.RLock()
+	material := currentTLS.material
+	currentTLS.RUnlock()
+	if material != nil {
+		return material, nil
+	}
+
+	currentTLS.Lock()
+	defer currentTLS.Unlock()
+	if currentTLS.material == nil {
+		material, err := loadTLSMaterial()
+		if err != nil {
+			return nil, err
+		}
+		currentTLS.material = material
+	}
+	return currentTLS.material, nil
+}
+
+// ReloadTLSCredentials loads the TLS certificates and CA certificates from
+// their files again. The connections established already are kept, the new
+// ones use the new material. On error the current material is kept.
+func ReloadTLSCredentials() error {
+	material, err := loadTLSMaterial()
+	if err != nil {
+		return err
+	}
+	currentTLS.Lock()
+	currentTLS.material = material
+	currentTLS.Unlock()
+	commLogger.Info("Reloaded the TLS credentials")
+	return nil
+}
+
+// serverTLSConfig returns the configuration for a server handshake with the
+// current material, verifying the client certificates if verifyClients is
+// set and client authentication is required
+func serverTLSConfig(verifyClients bool, nextProtos []string) (*tls.Config, error) {
+	material, err := getTLSMaterial()
+	if err != nil {
+		return nil, err
+	}
+	if material.serverErr != nil {
+		return nil, material.serverErr
+	}
+	if material.serverCert == nil {
+		return nil, fmt.Errorf("No server certificate and key configured in peer.tls")
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{*material.serverCert},
+		NextProtos:   nextProtos,
+	}
+	if verifyClients && material.clientAuth {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = material.rootCAs
+	}
+	return config, nil
+}
+
+func rotatingServerTLSConfig(verifyClients bool, nextProtos []string) (*tls.Config, error) {
+	if _, err := serverTLSConfig(verifyClients, nextProtos); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return serverTLSConfig(verifyClients, nextProtos)
+		},
+	}, nil
+}
+
+// ServerTLSConfig returns a TLS configuration for HTTP servers, which picks
+// the current material on every handshake. Clients have to present a
+// certificate signed by one of the root CAs if peer.tls.clientAuthRequired is
+// set and verifyClients is.
+func ServerTLSConfig(verifyClients bool) (*tls.Config, error) {
+	return rotatingServerTLSConfig(verifyClients, []string{"h2", "http/1.1"})
+}
+
+// ServerTLSCredentials returns the TLS credentials of the gRPC servers of the
+// peer, which authenticate the clients if peer.tls.clientAuthRequired is set
+func ServerTLSCredentials() (credentials.TransportAuthenticator, error) {
+	config, err := rotatingServerTLSConfig(true, []string{"h2"})
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// ChaincodeServerTLSCredentials returns the TLS credentials of the chaincode
+// support server. It does not authenticate the clients, the chaincodes have
+// no certificate and authenticate with their registration token instead.
+func ChaincodeServerTLSCredentials() (credentials.TransportAuthenticator, error) {
+	config, err := rotatingServerTLSConfig(false, []string{"h2"})
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// RootCAsPEM returns the PEM encoded root CA certificates configured in
+// peer.tls, which verify the certificate of the peer
+func RootCAsPEM() ([]byte, error) {
+	material, err := getTLSMaterial()
+	if err != nil {
+		return nil, err
+	}
+	if len(material.rootCAsPEM) == 0 {
+		return nil, fmt.Errorf("No CA certificates configured in peer.tls")
+	}
+	return material.rootCAsPEM, nil
+}
+
+// ClientTLSConfig returns the TLS configuration for connections to servers
+// whose certificate is signed by one of the root CAs, presenting the client
+// certificate if any
+func ClientTLSConfig() (*tls.Config, error) {
+	material, err := getTLSMaterial()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		ServerName: viper.GetString("peer.tls.serverhostoverride"),
+		RootCAs:    material.rootCAs,
+	}
+	if material.clientCert != nil {
+		config.Certificates = []tls.Certificate{*material.clientCert}
+	}
+	return config, nil
+}
+
+// InitTLSForPeer returns TLS credentials for peer
+func InitTLSForPeer() credentials.TransportAuthenticator {
+	config, err := ClientTLSConfig()
+	if err != nil {
+		grpclog.Fatalf("Failed to create TLS credentials %v", err)
+	}
+	return credentials.NewTLS(config)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate named cn, signed by parent or self-signed
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write writes the certificate and key PEM files into dir and returns their path
+func (c *testCert) write(t *testing.T, dir string, name string) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	keyRaw, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("Error marshalling key: %s", err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600); err != nil {
+		t.Fatalf("Error writing certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyRaw}), 0600); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+	return certFile, keyFile
+}
+
+// handshake runs a TLS handshake between the server and client configurations
+// and returns the name of the server certificate
+func handshake(server *tls.Config, client *tls.Config) (string, error) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	serverErr := make(chan error, 1)
+	go func() {
+		conn := tls.Server(serverConn, server)
+		err := conn.Handshake()
+		if err != nil {
+			serverConn.Close()
+		} else {
+			// Complete the handshake on the client side, with TLS 1.3
+			// the client verifies its certificate only once read
+			conn.Write([]byte{0})
+		}
+		serverErr <- err
+	}()
+	conn := tls.Client(clientConn, client)
+	if err := conn.Handshake(); err != nil {
+		return "", err
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return "", err
+	}
+	if err := <-serverErr; err != nil {
+		return "", err
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverFile, serverKeyFile := newTestCert(t, "peer", ca).write(t, dir, "server")
+	clientFile, clientKeyFile := newTestCert(t, "client", ca).write(t, dir, "client")
+	strangerFile, strangerKeyFile := newTestCert(t, "stranger", nil).write(t, dir, "stranger")
+
+	settings := map[string]string{
+		"peer.tls.cert.file":          serverFile,
+		"peer.tls.key.file":           serverKeyFile,
+		"peer.tls.clientCert.file":    clientFile,
+		"peer.tls.clientKey.file":     clientKeyFile,
+		"peer.tls.rootcas.files":      caFile,
+		"peer.tls.clientAuthRequired": "true",
+		"peer.tls.serverhostoverride": "peer",
+	}
+	for key, value := range settings {
+		defer viper.Set(key, viper.Get(key))
+		viper.Set(key, value)
+	}
+	defer ReloadTLSCredentials()
+	if err := ReloadTLSCredentials(); err != nil {
+		t.Fatalf("Error loading the TLS credentials: %s", err)
+	}
+
+	server, err := ServerTLSConfig(true)
+	if err != nil {
+		t.Fatalf("Error getting the server configuration: %s", err)
+	}
+	client, err := ClientTLSConfig()
+	if err != nil {
+		t.Fatalf("Error getting the client configuration: %s", err)
+	}
+	if name, err := handshake(server, client); err != nil || name != "peer" {
+		t.Fatalf("Expected a mutually authenticated handshake with peer, got %s (err %v)", name, err)
+	}
+
+	// A client whose certificate is not signed by the CA is refused
+	viper.Set("peer.tls.clientCert.file", strangerFile)
+	viper.Set("peer.tls.clientKey.file", strangerKeyFile)
+	if err := ReloadTLSCredentials(); err != nil {
+		t.Fatalf("Error reloading the TLS credentials: %s", err)
+	}
+	if client, err = ClientTLSConfig(); err != nil {
+		t.Fatalf("Error getting the client configuration: %s", err)
+	}
+	if _, err := handshake(server, client); err == nil {
+		t.Fatalf("Expected the server to refuse a client certificate not signed by the CA")
+	}
+	noAuth, err := ServerTLSConfig(false)
+	if err != nil {
+		t.Fatalf("Error getting the server configuration: %s", err)
+	}
+	if _, err := handshake(noAuth, client); err != nil {
+		t.Fatalf("Expected a server not verifying clients to accept the client: %s", err)
+	}
+
+	// Rotating the server certificate takes effect on the next handshake
+	newTestCert(t, "peer", ca).write(t, dir, "server")
+	if err := ReloadTLSCredentials(); err != nil {
+		t.Fatalf("Error reloading the TLS credentials: %s", err)
+	}
+	rotated, err := ioutil.ReadFile(serverFile)
+	if err != nil {
+		t.Fatalf("Error reading the rotated certificate: %s", err)
+	}
+	block, _ := pem.Decode(rotated)
+	seen := make(chan []byte, 1)
+	client = &tls.Config{ServerName: "peer", RootCAs: client.RootCAs,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			seen <- rawCerts[0]
+			return nil
+		}}
+	if _, err := handshake(noAuth, client); err != nil {
+		t.Fatalf("Error handshaking with the rotated certificate: %s", err)
+	}
+	if raw := <-seen; string(raw) != string(block.Bytes) {
+		t.Fatalf("Expected the server to present the rotated certificate")
+	}
+
+	// A failed reload keeps the current credentials
+	viper.Set("peer.tls.rootcas.files", filepath.Join(dir, "missing.pem"))
+	if err := ReloadTLSCredentials(); err == nil {
+		t.Fatalf("Expected reloading a missing CA file to fail")
+	}
+	if _, err := handshake(noAuth, client); err != nil {
+		t.Fatalf("Expected the current credentials to be kept: %s", err)
+	}
+}
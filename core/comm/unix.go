@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// UnixSocketPrefix prefixes the addresses which are paths of unix sockets
+const UnixSocketPrefix = "unix://"
+
+// UnixSocketPath returns the path of the unix socket of address, and whether
+// address is one
+func UnixSocketPath(address string) (string, bool) {
+	if !strings.HasPrefix(address, UnixSocketPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, UnixSocketPrefix), true
+}
+
+func dialUnix(path string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", path, timeout)
+}
+
+// ListenUnixSocket listens on the unix socket at path, with the permissions
+// of mode, replacing the socket left behind by a previous run
+func ListenUnixSocket(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a unix socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("Error removing the stale unix socket %s: %s", path, err)
+		}
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		lis.Close()
+		return nil, fmt.Errorf("Error setting the permissions of %s: %s", path, err)
+	}
+	return lis, nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unixsocket")
+	if err != nil {
+		t.Fatalf("Error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peer.sock")
+
+	if _, ok := UnixSocketPath("0.0.0.0:30303"); ok {
+		t.Fatalf("Expected a TCP address not to be a unix socket")
+	}
+	if socket, ok := UnixSocketPath(UnixSocketPrefix + path); !ok || socket != path {
+		t.Fatalf("Expected the path %s, got %s", path, socket)
+	}
+
+	// a socket left behind by a previous run is replaced
+	for i := 0; i < 2; i++ {
+		lis, err := ListenUnixSocket(path, 0600)
+		if err != nil {
+			t.Fatalf("Error listening on %s: %s", path, err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+			t.Fatalf("Expected the socket to be created with mode 0600, got %v (%v)", info.Mode(), err)
+		}
+		server := grpc.NewServer()
+		go server.Serve(lis)
+		// TLS is not used over the socket, even if enabled
+		conn, err := NewClientConnectionWithAddress(UnixSocketPrefix+path, true, true, nil)
+		if err != nil {
+			t.Fatalf("Error connecting to the unix socket: %s", err)
+		}
+		conn.Close()
+		// a stopped server leaves its socket behind, as a killed peer does
+		lis.(*net.UnixListener).SetUnlinkOnClose(false)
+		server.Stop()
+	}
+
+	regular := filepath.Join(dir, "regular")
+	if err := ioutil.WriteFile(regular, nil, 0600); err != nil {
+		t.Fatalf("Error creating %s: %s", regular, err)
+	}
+	if _, err := ListenUnixSocket(regular, 0600); err == nil {
+		t.Fatalf("Expected a file which is not a socket not to be replaced")
+	}
+}
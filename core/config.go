@@ -22,6 +22,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/hyperledger/fabric/core/util"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 )
@@ -87,6 +88,11 @@ var securityEnabled bool
 // file can be avoided on future requests
 func CacheConfiguration() error {
 	securityEnabled = viper.GetBool("security.enabled")
+	if hashAlgorithm := viper.GetString("ledger.blockchain.hashAlgorithm"); hashAlgorithm != "" {
+		if err := util.SetDefaultHash(hashAlgorithm); err != nil {
+			return fmt.Errorf("Invalid ledger.blockchain.hashAlgorithm: %s", err)
+		}
+	}
 	configurationCached = true
 	return nil
 }
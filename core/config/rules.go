@@ -0,0 +1,107 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+// CoreRules returns the rules the peer, consensus, security and vm sections
+// of core.yaml must meet. The DB drivers and VM providers depend on the
+// build, the caller passes the ones available.
+func CoreRules(dbDrivers []string, vmProviders []string) []Rule {
+	positive := &Range{Min: 1}
+	nonNegative := &Range{Min: 0}
+
+	return []Rule{
+		// peer
+		{Key: "peer.id", Kind: String, Required: true},
+		{Key: "peer.listenAddress", Kind: Address, Required: true},
+		{Key: "peer.address", Kind: Address, Required: true},
+		{Key: "peer.addressAutoDetect", Kind: Bool},
+		{Key: "peer.externalAddress", Kind: Address},
+		{Key: "peer.outboundOnly", Kind: Bool},
+		{Key: "peer.gomaxprocs", Kind: Int},
+		{Key: "peer.workers", Kind: Int, Range: positive},
+		{Key: "peer.limits.chat.rate", Kind: Float, Range: nonNegative},
+		{Key: "peer.limits.chat.burst", Kind: Int, Range: nonNegative},
+		{Key: "peer.limits.chat.queueSize", Kind: Int, Range: positive},
+		{Key: "peer.limits.chat.disconnectWindow", Kind: Duration, Range: nonNegative},
+		{Key: "peer.limits.chat.pauseTimeout", Kind: Duration, Range: nonNegative},
+		{Key: "peer.health.pingPeriod", Kind: Duration, Range: positive},
+		{Key: "peer.health.suspectAfter", Kind: Int, Range: positive},
+		{Key: "peer.health.deadAfter", Kind: Int, Range: positive},
+		{Key: "peer.sync.blocks.channelSize", Kind: Int, Range: positive},
+		{Key: "peer.sync.state.snapshot.channelSize", Kind: Int, Range: positive},
+		{Key: "peer.sync.state.deltas.channelSize", Kind: Int, Range: positive},
+		{Key: "peer.sync.gossip.enabled", Kind: Bool},
+		{Key: "peer.sync.gossip.period", Kind: Duration, Required: true, Range: positive, When: "peer.sync.gossip.enabled"},
+		{Key: "peer.sync.gossip.fanout", Kind: Int, Range: positive, When: "peer.sync.gossip.enabled"},
+		{Key: "peer.grpc.maxSendMessageSize", Kind: Int, Range: nonNegative},
+		{Key: "peer.grpc.maxRecvMessageSize", Kind: Int, Range: nonNegative},
+		{Key: "peer.grpc.compression", Kind: String, Values: []string{"gzip", "none"}},
+		{Key: "peer.tls.enabled", Kind: Bool},
+		{Key: "peer.tls.cert.file", Kind: File, Required: true, When: "peer.tls.enabled"},
+		{Key: "peer.tls.key.file", Kind: File, Required: true, When: "peer.tls.enabled"},
+		{Key: "peer.tls.clientAuthRequired", Kind: Bool},
+		{Key: "peer.pki.tls.enabled", Kind: Bool},
+		{Key: "peer.pki.tls.rootcert.file", Kind: File, Required: true, When: "peer.pki.tls.enabled"},
+		{Key: "peer.discovery.provider", Kind: String, Values: []string{"static", "dns", "kubernetes"}},
+		{Key: "peer.discovery.period", Kind: Duration, Range: positive},
+		{Key: "peer.discovery.refreshPeriod", Kind: Duration, Range: positive},
+		{Key: "peer.discovery.reconnect.initialDelay", Kind: Duration, Range: positive},
+		{Key: "peer.discovery.reconnect.maxDelay", Kind: Duration, Range: positive},
+		{Key: "peer.discovery.touchPeriod", Kind: Int, Range: nonNegative},
+		{Key: "peer.fileSystemPath", Kind: String, Required: true},
+		{Key: "peer.db.driver", Kind: String, Values: dbDrivers},
+		{Key: "peer.db.encryption.enabled", Kind: Bool},
+		{Key: "peer.db.encryption.rotationInterval", Kind: Duration, Range: nonNegative},
+		{Key: "peer.profile.enabled", Kind: Bool},
+		{Key: "peer.profile.listenAddress", Kind: Address, Required: true, When: "peer.profile.enabled"},
+		{Key: "peer.metrics.enabled", Kind: Bool},
+		{Key: "peer.metrics.listenAddress", Kind: Address, Required: true, When: "peer.metrics.enabled"},
+		{Key: "peer.tracing.enabled", Kind: Bool},
+		{Key: "peer.tracing.samplingRate", Kind: Float, Range: &Range{Min: 0, Max: 1}, When: "peer.tracing.enabled"},
+		{Key: "peer.tracing.endpoint", Kind: String},
+
+		// consensus
+		{Key: "peer.validator.enabled", Kind: Bool},
+		{Key: "peer.validator.consensus.plugin", Kind: String, Values: []string{"noops", "pbft"}, When: "peer.validator.enabled"},
+		{Key: "peer.validator.consensus.buffersize", Kind: Int, Range: positive, When: "peer.validator.enabled"},
+		{Key: "peer.validator.events.address", Kind: Address, Required: true, When: "peer.validator.enabled"},
+		{Key: "peer.validator.events.buffersize", Kind: Int, Range: nonNegative, When: "peer.validator.enabled"},
+		{Key: "peer.validator.events.timeout", Kind: Int, When: "peer.validator.enabled"},
+
+		// security
+		{Key: "security.enabled", Kind: Bool},
+		{Key: "security.enrollID", Kind: String, Required: true, When: "security.enabled"},
+		{Key: "security.privacy", Kind: Bool},
+		{Key: "security.level", Kind: Int, Values: []string{"256", "384"}},
+		{Key: "security.hashAlgorithm", Kind: String, Values: []string{"SHA2", "SHA3"}},
+		{Key: "security.tcert.batch.size", Kind: Int, Range: positive},
+		{Key: "security.enrollment.renewBefore", Kind: Duration, Range: nonNegative},
+		{Key: "peer.pki.eca.paddr", Kind: Address, Required: true, When: "security.enabled"},
+		{Key: "peer.pki.tca.paddr", Kind: Address, Required: true, When: "security.enabled"},
+		{Key: "peer.pki.tlsca.paddr", Kind: Address, Required: true, When: "security.enabled"},
+
+		// vm
+		{Key: "vm.endpoint", Kind: String, Required: true},
+		{Key: "vm.provider", Kind: String, Values: vmProviders},
+		{Key: "vm.docker.connection.attempts", Kind: Int, Range: positive},
+		{Key: "vm.docker.connection.retryInterval", Kind: Duration, Range: nonNegative},
+		{Key: "vm.docker.tls.enabled", Kind: Bool},
+		{Key: "vm.docker.tls.cert.file", Kind: File, Required: true, When: "vm.docker.tls.enabled"},
+		{Key: "vm.docker.tls.ca.file", Kind: File, Required: true, When: "vm.docker.tls.enabled"},
+		{Key: "vm.docker.tls.key.file", Kind: File, Required: true, When: "vm.docker.tls.enabled"},
+	}
+}
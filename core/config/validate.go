@@ -0,0 +1,260 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Kind is the type a configuration value must have
+type Kind int
+
+// The kinds of configuration values
+const (
+	String   Kind = iota
+	Bool          // true or false, as understood by strconv.ParseBool
+	Int           // an integer
+	Float         // a floating point number
+	Duration      // a duration such as 5s, or a number of nanoseconds
+	Address       // a host:port address
+	File          // the path of a file which must exist
+)
+
+// Range bounds an Int, a Float, or a Duration in nanoseconds, both ends
+// included. A Max of 0 leaves the value unbounded from above.
+type Range struct {
+	Min float64
+	Max float64
+}
+
+// Rule constrains the value of a configuration key
+type Rule struct {
+	Key      string
+	Kind     Kind
+	Required bool     // the value must be set and not empty
+	Range    *Range   // nil for no bounds
+	Values   []string // the values allowed, compared case insensitively, nil for any
+	When     string   // the rule only applies if this boolean key is set
+}
+
+// Source is a configuration to check, a *viper.Viper or Global
+type Source interface {
+	Get(key string) interface{}
+	GetBool(key string) bool
+	AllSettings() map[string]interface{}
+}
+
+// Global is the configuration of the global viper, which the peer reads
+// core.yaml into
+var Global Source = globalViper{}
+
+type globalViper struct{}
+
+func (globalViper) Get(key string) interface{}          { return viper.Get(key) }
+func (globalViper) GetBool(key string) bool             { return viper.GetBool(key) }
+func (globalViper) AllSettings() map[string]interface{} { return viper.AllSettings() }
+
+// Validate checks the configuration v against the rules, and returns an error
+// for each rule which is not met
+func Validate(v Source, rules []Rule) []error {
+	var errs []error
+	for _, rule := range rules {
+		if err := rule.check(v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (r Rule) check(v Source) error {
+	if r.When != "" && !v.GetBool(r.When) {
+		return nil
+	}
+	var value string
+	if raw := v.Get(r.Key); raw != nil {
+		value = strings.TrimSpace(fmt.Sprint(raw))
+	}
+	if value == "" {
+		if r.Required {
+			return fmt.Errorf("%s is required", r.Key)
+		}
+		return nil
+	}
+
+	var number float64
+	var err error
+	switch r.Kind {
+	case Bool:
+		_, err = strconv.ParseBool(value)
+	case Int:
+		var i int64
+		i, err = strconv.ParseInt(value, 10, 64)
+		number = float64(i)
+	case Float:
+		number, err = strconv.ParseFloat(value, 64)
+	case Duration:
+		var d time.Duration
+		if ns, nsErr := strconv.ParseInt(value, 10, 64); nsErr == nil {
+			d = time.Duration(ns)
+		} else {
+			d, err = time.ParseDuration(value)
+		}
+		number = float64(d)
+	case Address:
+		err = checkAddress(value)
+	case File:
+		_, err = os.Stat(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: invalid value [%s]: %s", r.Key, value, err)
+	}
+
+	if r.Range != nil && (number < r.Range.Min || (r.Range.Max != 0 && number > r.Range.Max)) {
+		if r.Range.Max == 0 {
+			return fmt.Errorf("%s: value [%s] must be at least %s", r.Key, value, r.format(r.Range.Min))
+		}
+		return fmt.Errorf("%s: value [%s] must be between %s and %s", r.Key, value, r.format(r.Range.Min), r.format(r.Range.Max))
+	}
+	if r.Values != nil {
+		for _, allowed := range r.Values {
+			if strings.EqualFold(value, allowed) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: value [%s] must be one of %v", r.Key, value, r.Values)
+	}
+	return nil
+}
+
+func (r Rule) format(bound float64) string {
+	if r.Kind == Duration {
+		return time.Duration(bound).String()
+	}
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
+
+func checkAddress(address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return fmt.Errorf("invalid port [%s]", port)
+	}
+	return nil
+}
+
+// Settings returns the configuration v read from its file as nested maps.
+// If effective is set, the value of each key is the one the peer uses, which
+// includes its environment override, rather than the one of the file.
+func Settings(v Source, effective bool) map[string]interface{} {
+	settings := make(map[string]interface{})
+	for key, value := range v.AllSettings() {
+		settings[key] = settingsOf(v, key, value, effective)
+	}
+	return settings
+}
+
+func settingsOf(v Source, key string, value interface{}, effective bool) interface{} {
+	var children map[string]interface{}
+	switch m := value.(type) {
+	case map[string]interface{}:
+		children = m
+	case map[interface{}]interface{}:
+		children = make(map[string]interface{}, len(m))
+		for k, child := range m {
+			children[fmt.Sprint(k)] = child
+		}
+	default:
+		if effective {
+			return v.Get(key)
+		}
+		return value
+	}
+
+	settings := make(map[string]interface{}, len(children))
+	for k, child := range children {
+		settings[k] = settingsOf(v, key+"."+k, child, effective)
+	}
+	return settings
+}
+
+// Change is a key whose value differs between two configurations
+type Change struct {
+	Key string
+	Old interface{} // nil if the key is only in the new configuration
+	New interface{} // nil if the key is only in the old configuration
+}
+
+// Diff returns the keys of the files of the configurations old and new whose
+// effective values differ, in order
+func Diff(old Source, new Source) []Change {
+	oldValues := make(map[string]interface{})
+	flatten("", Settings(old, true), oldValues)
+	newValues := make(map[string]interface{})
+	flatten("", Settings(new, true), newValues)
+
+	keys := make(map[string]bool)
+	for key := range oldValues {
+		keys[key] = true
+	}
+	for key := range newValues {
+		keys[key] = true
+	}
+	var changes []Change
+	for key := range keys {
+		o, n := oldValues[key], newValues[key]
+		if valueString(o) == valueString(n) {
+			continue
+		}
+		changes = append(changes, Change{Key: key, Old: o, New: n})
+	}
+	sort.Sort(changesByKey(changes))
+	return changes
+}
+
+// valueString formats a configuration value, an unset value being empty
+func valueString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+type changesByKey []Change
+
+func (s changesByKey) Len() int           { return len(s) }
+func (s changesByKey) Less(i, j int) bool { return s[i].Key < s[j].Key }
+func (s changesByKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func flatten(prefix string, settings map[string]interface{}, values map[string]interface{}) {
+	for k, v := range settings {
+		if children, ok := v.(map[string]interface{}); ok {
+			flatten(prefix+k+".", children, values)
+		} else {
+			values[prefix+k] = v
+		}
+	}
+}
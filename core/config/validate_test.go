@@ -0,0 +1,144 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testConfig = `
+peer:
+    address: 0.0.0.0:30303
+    workers: 2
+    tls:
+        enabled: false
+        cert:
+            file:
+    sync:
+        period: 5s
+security:
+    level: 256
+`
+
+func newTestConfig(t *testing.T) *viper.Viper {
+	v := viper.New()
+	v.SetEnvPrefix("TESTCONFIG")
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBufferString(testConfig)); err != nil {
+		t.Fatalf("Error reading the test configuration: %s", err)
+	}
+	return v
+}
+
+var testRules = []Rule{
+	{Key: "peer.address", Kind: Address, Required: true},
+	{Key: "peer.workers", Kind: Int, Range: &Range{Min: 1, Max: 16}},
+	{Key: "peer.tls.enabled", Kind: Bool},
+	{Key: "peer.tls.cert.file", Kind: File, Required: true, When: "peer.tls.enabled"},
+	{Key: "peer.sync.period", Kind: Duration, Range: &Range{Min: 1}},
+	{Key: "security.level", Kind: Int, Values: []string{"256", "384"}},
+}
+
+func TestValidate(t *testing.T) {
+	v := newTestConfig(t)
+	if errs := Validate(v, testRules); len(errs) != 0 {
+		t.Fatalf("Expected the test configuration to be valid, got %v", errs)
+	}
+
+	v.Set("peer.address", "0.0.0.0")
+	v.Set("peer.workers", 17)
+	v.Set("peer.tls.enabled", "yes")
+	v.Set("peer.sync.period", "0s")
+	v.Set("security.level", 512)
+	errs := Validate(v, testRules)
+	var keys []string
+	for _, err := range errs {
+		keys = append(keys, strings.SplitN(err.Error(), ":", 2)[0])
+	}
+	expected := []string{"peer.address", "peer.workers", "peer.tls.enabled", "peer.sync.period", "security.level"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Expected errors for %v, got %v", expected, errs)
+	}
+
+	v.Set("peer.tls.enabled", true)
+	errs = Validate(v, testRules[3:4])
+	if len(errs) != 1 || errs[0].Error() != "peer.tls.cert.file is required" {
+		t.Fatalf("Expected the certificate to be required once TLS is enabled, got %v", errs)
+	}
+	v.Set("peer.tls.cert.file", "/nonexistent/cert.pem")
+	if errs = Validate(v, testRules[3:4]); len(errs) != 1 {
+		t.Fatalf("Expected a missing certificate file to be reported, got %v", errs)
+	}
+}
+
+func TestValidateEnvironmentOverride(t *testing.T) {
+	v := newTestConfig(t)
+	os.Setenv("TESTCONFIG_PEER_WORKERS", "many")
+	defer os.Unsetenv("TESTCONFIG_PEER_WORKERS")
+	if errs := Validate(v, testRules); len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "peer.workers:") {
+		t.Fatalf("Expected the environment override of peer.workers to be invalid, got %v", errs)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	v := newTestConfig(t)
+	os.Setenv("TESTCONFIG_PEER_SYNC_PERIOD", "1m")
+	defer os.Unsetenv("TESTCONFIG_PEER_SYNC_PERIOD")
+
+	period := func(settings map[string]interface{}) interface{} {
+		peer := settings["peer"].(map[string]interface{})
+		return peer["sync"].(map[string]interface{})["period"]
+	}
+	if p := period(Settings(v, false)); p != "5s" {
+		t.Fatalf("Expected the period of the file, got %v", p)
+	}
+	settings := Settings(v, true)
+	if p := period(settings); p != "1m" {
+		t.Fatalf("Expected the period of the environment, got %v", p)
+	}
+	if level := settings["security"].(map[string]interface{})["level"]; level != 256 {
+		t.Fatalf("Expected the security level of the file, got %v", level)
+	}
+}
+
+func TestCoreRules(t *testing.T) {
+	v := viper.New()
+	v.SetConfigName("core")
+	v.AddConfigPath("../../peer")
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("Error reading core.yaml: %s", err)
+	}
+	if errs := Validate(v, CoreRules([]string{"rocksdb"}, []string{"docker"})); len(errs) != 0 {
+		t.Fatalf("Expected the core.yaml of the repository to be valid, got %v", errs)
+	}
+	// the DB driver defaults to one available in the build
+	if errs := Validate(v, CoreRules([]string{"goleveldb"}, []string{"docker"})); len(errs) != 0 {
+		t.Fatalf("Expected the default DB driver to be valid in any build, got %v", errs)
+	}
+	v.Set("peer.db.driver", "rocksdb")
+	if errs := Validate(v, CoreRules([]string{"goleveldb"}, []string{"docker"})); len(errs) != 1 {
+		t.Fatalf("Expected a DB driver missing from the build to be reported, got %v", errs)
+	}
+}
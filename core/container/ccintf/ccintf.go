@@ -42,6 +42,15 @@ func GetCCHandlerKey() string {
 	return "CCHANDLER"
 }
 
+//ResourceLimits are the limits of the resources a chaincode instance may use,
+//zero means no limit
+type ResourceLimits struct {
+	//Memory is the memory limit in bytes
+	Memory int64
+	//CPUShares is the CPU weight of the instance relative to other instances
+	CPUShares int64
+}
+
 //CCID encapsulates chaincode ID
 type CCID struct {
 	ChaincodeSpec *pb.ChaincodeSpec
@@ -19,6 +19,7 @@ package container
 import (
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 
 	"golang.org/x/net/context"
@@ -26,12 +27,16 @@ import (
 	"github.com/hyperledger/fabric/core/container/ccintf"
 	"github.com/hyperledger/fabric/core/container/dockercontroller"
 	"github.com/hyperledger/fabric/core/container/inproccontroller"
+	"github.com/hyperledger/fabric/core/container/processcontroller"
+	"github.com/spf13/viper"
 )
 
-//abstract virtual image for supporting arbitrary virual machines
-type vm interface {
+//VMProvider - abstract virtual image for supporting arbitrary virual machines.
+//The chaincodes deployed in containers are run by the provider selected by
+//vm.provider in core.yaml, system chaincodes are always run in process
+type VMProvider interface {
 	Deploy(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error
-	Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error
+	Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader, limits ccintf.ResourceLimits) error
 	Stop(ctxt context.Context, ccid ccintf.CCID, timeout uint, dontkill bool, dontremove bool) error
 	Destroy(ctxt context.Context, ccid ccintf.CCID, force bool, noprune bool) error
 	GetVMName(ccID ccintf.CCID) (string, error)
@@ -43,7 +48,7 @@ type refCountedLock struct {
 }
 
 //VMController - manages VMs
-//   . abstract construction of different types of VMs (we only care about Docker for now)
+//   . abstract construction of different types of VMs (Docker or a registered VMProvider)
 //   . manage lifecycle of VM (start with build, start, stop ...
 //     eventually probably need fine grained management)
 type VMController struct {
@@ -61,24 +66,64 @@ const (
 	SYSTEM = "System"
 )
 
+//vmProviders are the providers which can be selected by vm.provider
+var vmProviders = map[string]func() VMProvider{
+	"docker":  func() VMProvider { return &dockercontroller.DockerVM{} },
+	"process": func() VMProvider { return &processcontroller.ProcessVM{} },
+}
+
 //NewVMController - creates/returns singleton
 func init() {
 	vmcontroller = new(VMController)
 	vmcontroller.containerLocks = make(map[string]*refCountedLock)
 }
 
-func (vmc *VMController) newVM(typ string) vm {
+//RegisterVMProvider adds a provider which can be selected by setting
+//vm.provider to name. It must be called before the peer starts chaincodes.
+func RegisterVMProvider(name string, newProvider func() VMProvider) error {
+	vmcontroller.Lock()
+	defer vmcontroller.Unlock()
+	if _, ok := vmProviders[name]; ok {
+		return fmt.Errorf("VM provider %s is already registered", name)
+	}
+	vmProviders[name] = newProvider
+	return nil
+}
+
+//VMProviders returns the names of the providers which can be selected by
+//vm.provider, in order
+func VMProviders() []string {
+	vmcontroller.RLock()
+	defer vmcontroller.RUnlock()
+	var names []string
+	for name := range vmProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (vmc *VMController) newVM(typ string) VMProvider {
 	var (
-		v vm
+		v VMProvider
 	)
 
 	switch typ {
-	case DOCKER:
-		v = &dockercontroller.DockerVM{}
 	case SYSTEM:
 		v = &inproccontroller.InprocVM{}
 	default:
-		v = &dockercontroller.DockerVM{}
+		provider := viper.GetString("vm.provider")
+		if provider == "" {
+			provider = "docker"
+		}
+		vmc.RLock()
+		newProvider, ok := vmProviders[provider]
+		vmc.RUnlock()
+		if !ok {
+			vmLogger.Errorf("Unknown VM provider %s", provider)
+			return nil
+		}
+		v = newProvider()
 	}
 	return v
 }
@@ -123,7 +168,7 @@ func (vmc *VMController) unlockContainer(id string) {
 //note that we'd stop on the first method on the stack that does not
 //take context
 type VMCReqIntf interface {
-	do(ctxt context.Context, v vm) VMCResp
+	do(ctxt context.Context, v VMProvider) VMCResp
 	getCCID() ccintf.CCID
 }
 
@@ -144,7 +189,7 @@ type CreateImageReq struct {
 	Env          []string
 }
 
-func (bp CreateImageReq) do(ctxt context.Context, v vm) VMCResp {
+func (bp CreateImageReq) do(ctxt context.Context, v VMProvider) VMCResp {
 	var resp VMCResp
 
 	if err := v.Deploy(ctxt, bp.CCID, bp.Args, bp.Env, bp.AttachStdin, bp.AttachStdout, bp.Reader); err != nil {
@@ -168,12 +213,13 @@ type StartImageReq struct {
 	Env          []string
 	AttachStdin  bool
 	AttachStdout bool
+	Limits       ccintf.ResourceLimits
 }
 
-func (si StartImageReq) do(ctxt context.Context, v vm) VMCResp {
+func (si StartImageReq) do(ctxt context.Context, v VMProvider) VMCResp {
 	var resp VMCResp
 
-	if err := v.Start(ctxt, si.CCID, si.Args, si.Env, si.AttachStdin, si.AttachStdout, si.Reader); err != nil {
+	if err := v.Start(ctxt, si.CCID, si.Args, si.Env, si.AttachStdin, si.AttachStdout, si.Reader, si.Limits); err != nil {
 		resp = VMCResp{Err: err}
 	} else {
 		resp = VMCResp{}
@@ -196,7 +242,7 @@ type StopImageReq struct {
 	Dontremove bool
 }
 
-func (si StopImageReq) do(ctxt context.Context, v vm) VMCResp {
+func (si StopImageReq) do(ctxt context.Context, v VMProvider) VMCResp {
 	var resp VMCResp
 
 	if err := v.Stop(ctxt, si.CCID, si.Timeout, si.Dontkill, si.Dontremove); err != nil {
@@ -220,7 +266,7 @@ type DestroyImageReq struct {
 	NoPrune bool
 }
 
-func (di DestroyImageReq) do(ctxt context.Context, v vm) VMCResp {
+func (di DestroyImageReq) do(ctxt context.Context, v VMProvider) VMCResp {
 	var resp VMCResp
 
 	if err := v.Destroy(ctxt, di.CCID, di.Force, di.NoPrune); err != nil {
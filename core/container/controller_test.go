@@ -27,7 +27,10 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric/core/container/ccintf"
+	"github.com/hyperledger/fabric/core/container/inproccontroller"
+	"github.com/hyperledger/fabric/core/container/processcontroller"
 	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
 
 	"golang.org/x/net/context"
 )
@@ -270,3 +273,30 @@ func TestVMCStopContainer(t *testing.T) {
 	fmt.Println("VMCStopContainer-waiting for response")
 	<-c
 }
+
+func TestVMCSelectProvider(t *testing.T) {
+	defer viper.Set("vm.provider", viper.Get("vm.provider"))
+
+	viper.Set("vm.provider", "process")
+	if _, ok := vmcontroller.newVM(DOCKER).(*processcontroller.ProcessVM); !ok {
+		t.Fatal("Expected the process VM provider")
+	}
+	if _, ok := vmcontroller.newVM(SYSTEM).(*inproccontroller.InprocVM); !ok {
+		t.Fatal("Expected system chaincodes to run in process")
+	}
+
+	viper.Set("vm.provider", "unknown")
+	if v := vmcontroller.newVM(DOCKER); v != nil {
+		t.Fatalf("Expected no VM for an unknown provider, got %T", v)
+	}
+	if err := RegisterVMProvider("unknown", func() VMProvider { return &processcontroller.ProcessVM{} }); err != nil {
+		t.Fatalf("Error registering VM provider: %s", err)
+	}
+	defer delete(vmProviders, "unknown")
+	if v := vmcontroller.newVM(DOCKER); v == nil {
+		t.Fatal("Expected the registered VM provider")
+	}
+	if err := RegisterVMProvider("docker", func() VMProvider { return &processcontroller.ProcessVM{} }); err == nil {
+		t.Fatal("Expected an error registering a provider twice")
+	}
+}
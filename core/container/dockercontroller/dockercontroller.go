@@ -56,9 +56,22 @@ func init() {
 	dockerLogger.Debug("Load docker HostConfig: %+v", hostConfig)
 }
 
-func (vm *DockerVM) createContainer(ctxt context.Context, client *docker.Client, imageID string, containerID string, args []string, env []string, attachstdin bool, attachstdout bool) error {
+//getHostConfig returns the configured host config with the resource limits of
+//the chaincode container
+func getHostConfig(limits ccintf.ResourceLimits) *docker.HostConfig {
+	hc := *hostConfig
+	if limits.Memory > 0 {
+		hc.Memory = limits.Memory
+	}
+	if limits.CPUShares > 0 {
+		hc.CPUShares = limits.CPUShares
+	}
+	return &hc
+}
+
+func (vm *DockerVM) createContainer(ctxt context.Context, client *docker.Client, imageID string, containerID string, args []string, env []string, attachstdin bool, attachstdout bool, hc *docker.HostConfig) error {
 	config := docker.Config{Cmd: args, Image: imageID, Env: env, AttachStdin: attachstdin, AttachStdout: attachstdout}
-	copts := docker.CreateContainerOptions{Name: containerID, Config: &config}
+	copts := docker.CreateContainerOptions{Name: containerID, Config: &config, HostConfig: hc}
 	dockerLogger.Debugf("Create container: %s", containerID)
 	_, err := client.CreateContainer(copts)
 	if err != nil {
@@ -105,8 +118,9 @@ func (vm *DockerVM) Deploy(ctxt context.Context, ccid ccintf.CCID, args []string
 	return nil
 }
 
-//Start starts a container using a previously created docker image
-func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+//Start starts a container using a previously created docker image, with the
+//given resource limits
+func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader, limits ccintf.ResourceLimits) error {
 	imageID, _ := vm.GetVMName(ccid)
 	client, err := cutil.NewDockerClient()
 	if err != nil {
@@ -120,8 +134,9 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 	dockerLogger.Debugf("Cleanup container %s", containerID)
 	vm.stopInternal(ctxt, client, containerID, 0, false, false)
 
+	hc := getHostConfig(limits)
 	dockerLogger.Debugf("Start container %s", containerID)
-	err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout)
+	err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout, hc)
 	if err != nil {
 		//if image not found try to create image and retry
 		if err == docker.ErrNoSuchImage {
@@ -132,7 +147,7 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 				}
 
 				dockerLogger.Debug("start-recreated image successfully")
-				if err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout); err != nil {
+				if err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout, hc); err != nil {
 					dockerLogger.Errorf("start-could not recreate container post recreate image: %s", err)
 					return err
 				}
@@ -146,7 +161,7 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 		}
 	}
 
-	err = client.StartContainer(containerID, hostConfig)
+	err = client.StartContainer(containerID, hc)
 	if err != nil {
 		dockerLogger.Errorf("start-could not start container %s", err)
 		return err
@@ -142,8 +142,9 @@ func (ipc *inprocContainer) launchInProc(ctxt context.Context, id string, args [
 	return err
 }
 
-//Start starts a previously registered system codechain
-func (vm *InprocVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+//Start starts a previously registered system codechain. The resource limits
+//do not apply to chaincodes running in the peer process
+func (vm *InprocVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader, limits ccintf.ResourceLimits) error {
 	path := ccid.ChaincodeSpec.ChaincodeID.Path
 
 	ipctemplate := typeRegistry[path]
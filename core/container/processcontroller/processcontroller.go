@@ -0,0 +1,277 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processcontroller
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hyperledger/fabric/core/container/ccintf"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+var processLogger = logging.MustGetLogger("processcontroller")
+
+type chaincodeProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+//running processes, by vm name
+var processes = struct {
+	sync.Mutex
+	m map[string]*chaincodeProcess
+}{m: make(map[string]*chaincodeProcess)}
+
+//ProcessVM is a vm running chaincodes as processes of the peer host, for hosts
+//without a docker daemon. It is identified by the directory of the chaincode.
+type ProcessVM struct {
+	id string
+}
+
+//getWorkDir returns the directory the chaincode packages are built in
+func getWorkDir() string {
+	if dir := viper.GetString("vm.process.workdir"); dir != "" {
+		return dir
+	}
+	return filepath.Join(viper.GetString("peer.fileSystemPath"), "chaincodes")
+}
+
+func (vm *ProcessVM) getDir(ccid ccintf.CCID) string {
+	id, _ := vm.GetVMName(ccid)
+	return filepath.Join(getWorkDir(), id)
+}
+
+//getExecutable returns the path of the chaincode executable under dir
+func getExecutable(dir string) string {
+	return filepath.Join(dir, "bin", "chaincode")
+}
+
+//extractPackage writes the files of the gzipped tar chaincode package to dir
+func extractPackage(reader io.Reader, dir string) error {
+	gr, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("Error reading chaincode package: %s", err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading chaincode package: %s", err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		path := filepath.Join(dir, hdr.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("Invalid file %s in chaincode package", hdr.Name)
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("Error writing %s: %s", path, err)
+		}
+	}
+}
+
+//buildChaincode builds the executable of the chaincode from the sources of
+//the package extracted in dir, which is the GOPATH of the build
+func buildChaincode(spec *pb.ChaincodeSpec, dir string) error {
+	if spec.Type != pb.ChaincodeSpec_GOLANG {
+		return fmt.Errorf("%s chaincodes are not supported by the process VM provider", spec.Type)
+	}
+
+	path := spec.ChaincodeID.Path
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "http://"), "https://")
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		return fmt.Errorf("empty url location")
+	}
+
+	//the executable is static so that it can be run chrooted to the sandbox
+	cmd := exec.Command("go", "build", "-o", getExecutable(dir), path)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPATH="+dir, "CGO_ENABLED=0", "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Error building chaincode: %s\n%s", err, out)
+	}
+	return nil
+}
+
+//Deploy extracts the chaincode package in its directory and builds the
+//chaincode executable
+func (vm *ProcessVM) Deploy(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+	dir := vm.getDir(ccid)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("Error cleaning up %s: %s", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error creating %s: %s", dir, err)
+	}
+	if err := extractPackage(reader, dir); err != nil {
+		return err
+	}
+	if err := buildChaincode(ccid.ChaincodeSpec, dir); err != nil {
+		return err
+	}
+
+	processLogger.Debugf("Built chaincode %s", dir)
+	return nil
+}
+
+//Start runs the chaincode executable with the arguments following the name of
+//the executable in the container. Resource limits are not applied to processes.
+func (vm *ProcessVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader, limits ccintf.ResourceLimits) error {
+	id, _ := vm.GetVMName(ccid)
+	dir := vm.getDir(ccid)
+
+	if _, err := os.Stat(getExecutable(dir)); err != nil {
+		//the deployed chaincode was not built on this host, build it from the package
+		if reader == nil {
+			return fmt.Errorf("Chaincode %s has not been deployed", id)
+		}
+		if err = vm.Deploy(ctxt, ccid, args, env, attachstdin, attachstdout, reader); err != nil {
+			return err
+		}
+	}
+
+	//stop if necessary
+	vm.stopInternal(id, 0, false)
+
+	log, err := os.OpenFile(filepath.Join(dir, "chaincode.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating the log of chaincode %s: %s", id, err)
+	}
+	defer log.Close()
+
+	var cmdArgs []string
+	if len(args) > 1 {
+		cmdArgs = args[1:]
+	}
+	cmd := exec.Command(getExecutable(dir), cmdArgs...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if viper.GetBool("vm.process.sandbox") {
+		if err = sandbox(cmd, dir); err != nil {
+			return err
+		}
+	}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("Error starting chaincode %s: %s", id, err)
+	}
+
+	p := &chaincodeProcess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		processLogger.Debugf("Chaincode %s exited: %v", id, err)
+		close(p.done)
+	}()
+	processes.Lock()
+	processes.m[id] = p
+	processes.Unlock()
+
+	processLogger.Debugf("Started chaincode %s, pid %d", id, cmd.Process.Pid)
+	return nil
+}
+
+//Stop stops a running chaincode. The process is killed if it has not exited
+//timeout seconds after being terminated, unless dontkill is set. Processes
+//have nothing to remove.
+func (vm *ProcessVM) Stop(ctxt context.Context, ccid ccintf.CCID, timeout uint, dontkill bool, dontremove bool) error {
+	id, _ := vm.GetVMName(ccid)
+	return vm.stopInternal(id, timeout, dontkill)
+}
+
+func (vm *ProcessVM) stopInternal(id string, timeout uint, dontkill bool) error {
+	processes.Lock()
+	p, ok := processes.m[id]
+	if ok {
+		delete(processes.m, id)
+	}
+	processes.Unlock()
+	if !ok {
+		return fmt.Errorf("Chaincode %s is not running", id)
+	}
+
+	if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		processLogger.Debugf("Stop chaincode %s(%s)", id, err)
+	}
+	select {
+	case <-p.done:
+		processLogger.Debugf("Stopped chaincode %s", id)
+		return nil
+	case <-time.After(time.Duration(timeout) * time.Second):
+	}
+	if dontkill {
+		return nil
+	}
+	if err := p.cmd.Process.Kill(); err != nil {
+		processLogger.Debugf("Kill chaincode %s(%s)", id, err)
+		return err
+	}
+	<-p.done
+	processLogger.Debugf("Killed chaincode %s", id)
+	return nil
+}
+
+//Destroy removes the directory of the chaincode
+func (vm *ProcessVM) Destroy(ctxt context.Context, ccid ccintf.CCID, force bool, noprune bool) error {
+	dir := vm.getDir(ccid)
+	if err := os.RemoveAll(dir); err != nil {
+		processLogger.Errorf("error while destroying %s: %s", dir, err)
+		return err
+	}
+	processLogger.Debugf("Destroyed %s", dir)
+	return nil
+}
+
+//GetVMName generates the name of the chaincode directory from peer information
+//given the hashcode, as docker names the images
+func (vm *ProcessVM) GetVMName(ccid ccintf.CCID) (string, error) {
+	if ccid.NetworkID != "" {
+		return fmt.Sprintf("%s-%s-%s", ccid.NetworkID, ccid.PeerID, ccid.ChaincodeSpec.ChaincodeID.Name), nil
+	} else if ccid.PeerID != "" {
+		return fmt.Sprintf("%s-%s", ccid.PeerID, ccid.ChaincodeSpec.ChaincodeID.Name), nil
+	} else {
+		return ccid.ChaincodeSpec.ChaincodeID.Name, nil
+	}
+}
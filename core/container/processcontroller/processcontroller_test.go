@@ -0,0 +1,126 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processcontroller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/container/ccintf"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+func getPackage(t *testing.T, files map[string]string) *bytes.Buffer {
+	buf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, contents := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}); err != nil {
+			t.Fatalf("Error writing package: %s", err)
+		}
+		tw.Write([]byte(contents))
+	}
+	tw.Close()
+	gw.Close()
+	return buf
+}
+
+func TestExtractPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processcontroller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = extractPackage(getPackage(t, map[string]string{"src/mycc/mycc.go": "package main"}), dir)
+	if err != nil {
+		t.Fatalf("Error extracting package: %s", err)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "src", "mycc", "mycc.go"))
+	if err != nil || string(contents) != "package main" {
+		t.Fatalf("Expected the extracted source, got %q (%v)", contents, err)
+	}
+
+	err = extractPackage(getPackage(t, map[string]string{"../escaped.go": "package main"}), dir)
+	if err == nil {
+		t.Fatal("Expected an error extracting a file outside of the chaincode directory")
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test chaincode is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "processcontroller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer viper.Set("vm.process.workdir", viper.Get("vm.process.workdir"))
+	viper.Set("vm.process.workdir", dir)
+
+	vm := &ProcessVM{}
+	ccid := ccintf.CCID{ChaincodeSpec: &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}, PeerID: "vp0"}
+	ctxt := context.Background()
+
+	if err = vm.Start(ctxt, ccid, []string{"/opt/gopath/bin/mycc"}, nil, false, false, nil, ccintf.ResourceLimits{}); err == nil {
+		t.Fatal("Expected an error starting a chaincode which has not been deployed")
+	}
+
+	// stand in for the built chaincode
+	executable := getExecutable(vm.getDir(ccid))
+	os.MkdirAll(filepath.Dir(executable), 0755)
+	if err = ioutil.WriteFile(executable, []byte("#!/bin/sh\necho started $1\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = vm.Start(ctxt, ccid, []string{"/opt/gopath/bin/mycc", "-peer.address=0.0.0.0:30303"}, nil, false, false, nil, ccintf.ResourceLimits{}); err != nil {
+		t.Fatalf("Error starting chaincode: %s", err)
+	}
+	logPath := filepath.Join(vm.getDir(ccid), "chaincode.log")
+	expected := "started -peer.address=0.0.0.0:30303\n"
+	var log []byte
+	for i := 0; i < 100 && string(log) != expected; i++ {
+		time.Sleep(50 * time.Millisecond)
+		log, _ = ioutil.ReadFile(logPath)
+	}
+	if string(log) != expected {
+		t.Fatalf("Expected the chaincode to be started with its arguments, got %q", log)
+	}
+
+	if err = vm.Stop(ctxt, ccid, 1, false, false); err != nil {
+		t.Fatalf("Error stopping chaincode: %s", err)
+	}
+	if err = vm.Stop(ctxt, ccid, 1, false, false); err == nil {
+		t.Fatal("Expected an error stopping a chaincode which is not running")
+	}
+
+	if err = vm.Destroy(ctxt, ccid, false, false); err != nil {
+		t.Fatalf("Error destroying chaincode: %s", err)
+	}
+	if _, err = os.Stat(vm.getDir(ccid)); !os.IsNotExist(err) {
+		t.Fatal("Expected the chaincode directory to be removed")
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processcontroller
+
+import (
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+//sandbox runs cmd in new mount, pid, ipc and uts namespaces, chrooted to dir.
+//The network namespace is shared so that the chaincode reaches the peer.
+func sandbox(cmd *exec.Cmd, dir string) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Chroot:     dir,
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+	}
+	//the paths are resolved in the chroot
+	rel, err := filepath.Rel(dir, cmd.Path)
+	if err != nil {
+		return err
+	}
+	cmd.Path = "/" + rel
+	cmd.Dir = "/"
+	return nil
+}
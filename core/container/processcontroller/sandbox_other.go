@@ -0,0 +1,29 @@
+// +build !linux
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package processcontroller
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+//sandbox is only supported on linux
+func sandbox(cmd *exec.Cmd, dir string) error {
+	return fmt.Errorf("vm.process.sandbox is only supported on linux")
+}
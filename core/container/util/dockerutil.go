@@ -17,11 +17,17 @@ limitations under the License.
 package util
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/fsouza/go-dockerclient"
 	"github.com/spf13/viper"
 )
 
-//NewDockerClient creates a docker client
+//NewDockerClient creates a client of the docker daemon at vm.endpoint, which
+//may be remote and secured with TLS. The daemon is pinged before the client is
+//returned, and the ping is retried as configured in vm.docker.connection, so
+//that operations fail early with a clear error when it is unreachable.
 func NewDockerClient() (client *docker.Client, err error) {
 	endpoint := viper.GetString("vm.endpoint")
 	tlsenabled := viper.GetBool("vm.docker.tls.enabled")
@@ -33,5 +39,34 @@ func NewDockerClient() (client *docker.Client, err error) {
 	} else {
 		client, err = docker.NewClient(endpoint)
 	}
-	return
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pingDockerDaemon(client, endpoint); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+//pingDockerDaemon checks that the daemon of the client answers, making up to
+//vm.docker.connection.attempts attempts
+func pingDockerDaemon(client *docker.Client, endpoint string) error {
+	attempts := viper.GetInt("vm.docker.connection.attempts")
+	if attempts <= 0 {
+		attempts = 1
+	}
+	interval := viper.GetDuration("vm.docker.connection.retryInterval")
+
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if err = client.Ping(); err == nil {
+			return nil
+		}
+		vmLogger.Warningf("Docker daemon at %s is not reachable (attempt %d of %d): %s", endpoint, i, attempts, err)
+		if i < attempts {
+			time.Sleep(interval)
+		}
+	}
+	return fmt.Errorf("Error connecting to the docker daemon at %s: %s", endpoint, err)
 }
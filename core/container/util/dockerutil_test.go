@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestNewDockerClientRetriesPing(t *testing.T) {
+	for _, key := range []string{"vm.endpoint", "vm.docker.tls.enabled", "vm.docker.connection.attempts", "vm.docker.connection.retryInterval"} {
+		defer viper.Set(key, viper.Get(key))
+	}
+
+	// the daemon answers the third ping only
+	pings := 0
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings++
+		if pings < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer daemon.Close()
+
+	viper.Set("vm.endpoint", daemon.URL)
+	viper.Set("vm.docker.tls.enabled", false)
+	viper.Set("vm.docker.connection.retryInterval", time.Millisecond)
+
+	viper.Set("vm.docker.connection.attempts", 2)
+	if _, err := NewDockerClient(); err == nil {
+		t.Fatal("Expected an error connecting to an unhealthy daemon")
+	}
+	if pings != 2 {
+		t.Fatalf("Expected 2 pings, got %d", pings)
+	}
+
+	if _, err := NewDockerClient(); err != nil {
+		t.Fatalf("Error connecting to the daemon: %s", err)
+	}
+	if pings != 3 {
+		t.Fatalf("Expected 3 pings, got %d", pings)
+	}
+}
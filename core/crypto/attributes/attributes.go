@@ -49,6 +49,21 @@ var (
 	HeaderAttributeName = "attributeHeader"
 )
 
+const (
+	//EnrollmentRoleAttributeName is the name of the attribute holding the role of the member, e.g. CLIENT.
+	//The TCA embeds it from the enrollment certificate of the member when requested.
+	EnrollmentRoleAttributeName = "enrollmentRole"
+
+	//EnrollmentAffiliationAttributeName is the name of the attribute holding the affiliation of the member.
+	//The TCA embeds it from the enrollment certificate of the member when requested.
+	EnrollmentAffiliationAttributeName = "enrollmentAffiliation"
+)
+
+//IsEnrollmentAttribute returns whether the attribute is read from the enrollment certificate by the TCA rather than from the ACA.
+func IsEnrollmentAttribute(attributeName string) bool {
+	return attributeName == EnrollmentRoleAttributeName || attributeName == EnrollmentAffiliationAttributeName
+}
+
 //ParseAttributesHeader parses a string and returns a map with the attributes.
 func ParseAttributesHeader(header string) (map[string]int, error) {
 	if !strings.HasPrefix(header, headerPrefix) {
@@ -0,0 +1,175 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/primitives/aes"
+	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+type auditorImpl struct {
+	id         string
+	eciesSPI   primitives.AsymmetricCipherSPI
+	privateKey primitives.PrivateKey
+}
+
+// NewAuditor returns the auditor id, whose key shares are decrypted with the
+// PEM encoded ECDSA private key, protected by pwd if not nil. The clients
+// encrypt the key shares with the matching public key, configured under
+// security.audit.auditors.
+func NewAuditor(id string, privateKeyPEM, pwd []byte) (Auditor, error) {
+	raw, err := primitives.PEMtoPrivateKey(privateKeyPEM, pwd)
+	if err != nil {
+		log.Errorf("Failed parsing private key of auditor [%s]: [%s].", id, err)
+
+		return nil, err
+	}
+	ecdsaSK, ok := raw.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("Invalid private key of auditor " + id + ". Expected an ECDSA private key.")
+	}
+
+	eciesSPI := ecies.NewSPI()
+	privateKey, err := eciesSPI.NewPrivateKey(nil, ecdsaSK)
+	if err != nil {
+		log.Errorf("Failed creating private key of auditor [%s]: [%s].", id, err)
+
+		return nil, err
+	}
+
+	return &auditorImpl{id: id, eciesSPI: eciesSPI, privateKey: privateKey}, nil
+}
+
+func (auditor *auditorImpl) GetID() string {
+	return auditor.id
+}
+
+func (auditor *auditorImpl) DecryptTransaction(tx *obc.Transaction) (*obc.Transaction, error) {
+	if tx.ConfidentialityLevel != obc.ConfidentialityLevel_CONFIDENTIAL {
+		return nil, errors.New("Transaction is not confidential.")
+	}
+	if len(tx.Nonce) == 0 {
+		return nil, errors.New("Failed decrypting payload. Invalid nonce.")
+	}
+
+	key, err := auditor.getTxKey(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// clone tx
+	raw, err := proto.Marshal(tx)
+	if err != nil {
+		log.Errorf("Failed cloning transaction [%s].", err)
+
+		return nil, err
+	}
+	clone := &obc.Transaction{}
+	if err = proto.Unmarshal(raw, clone); err != nil {
+		log.Errorf("Failed cloning transaction [%s].", err)
+
+		return nil, err
+	}
+
+	// decrypt chaincodeID, payload and metadata
+	var decrypt func(field byte, ct []byte) ([]byte, error)
+	switch tx.ConfidentialityProtocolVersion {
+	case "1.2":
+		ccPrivateKey, err := auditor.eciesSPI.DeserializePrivateKey(key)
+		if err != nil {
+			log.Errorf("Failed deserializing transaction key [%s].", err)
+
+			return nil, err
+		}
+		cipher, err := auditor.eciesSPI.NewAsymmetricCipherFromPrivateKey(ccPrivateKey)
+		if err != nil {
+			log.Errorf("Failed init transaction decryption engine [%s].", err)
+
+			return nil, err
+		}
+		decrypt = func(field byte, ct []byte) ([]byte, error) {
+			return cipher.Process(ct)
+		}
+	case "2.0":
+		if len(key) != primitives.AESKeyLength {
+			return nil, errors.New("Invalid transaction key.")
+		}
+		aesSPI := aes.NewAES256GSMSPI()
+		decrypt = func(field byte, ct []byte) ([]byte, error) {
+			return decryptTxField2_0(aesSPI, getTxFieldKey2_0(key, clone.Nonce, field), ct)
+		}
+	default:
+		return nil, utils.ErrInvalidProtocolVersion
+	}
+
+	if clone.ChaincodeID, err = decrypt(1, clone.ChaincodeID); err != nil {
+		log.Errorf("Failed decrypting chaincode [%s].", err)
+
+		return nil, err
+	}
+	if clone.Payload, err = decrypt(2, clone.Payload); err != nil {
+		log.Errorf("Failed decrypting payload [%s].", err)
+
+		return nil, err
+	}
+	if len(clone.Metadata) != 0 {
+		if clone.Metadata, err = decrypt(3, clone.Metadata); err != nil {
+			log.Errorf("Failed decrypting metadata [%s].", err)
+
+			return nil, err
+		}
+	}
+
+	return clone, nil
+}
+
+// getTxKey decrypts the key of the transaction shared with this auditor
+func (auditor *auditorImpl) getTxKey(tx *obc.Transaction) ([]byte, error) {
+	for _, share := range tx.ToAuditors {
+		// Auditor identifiers are case insensitive, as configuration keys are
+		if !strings.EqualFold(share.AuditorID, auditor.id) {
+			continue
+		}
+
+		cipher, err := auditor.eciesSPI.NewAsymmetricCipherFromPrivateKey(auditor.privateKey)
+		if err != nil {
+			log.Errorf("Failed init decryption engine [%s].", err)
+
+			return nil, err
+		}
+
+		key, err := cipher.Process(share.Key)
+		if err != nil {
+			log.Errorf("Failed decrypting key share of auditor [%s]: [%s].", auditor.id, err)
+
+			return nil, err
+		}
+
+		return key, nil
+	}
+
+	return nil, errors.New("Transaction has no key share for auditor " + auditor.id + ".")
+}
@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// auditorKey is the public key of an auditor, to which the client encrypts
+// the keys of its confidential transactions to the audited chaincodes
+type auditorKey struct {
+	id         string
+	publicKey  primitives.PublicKey
+	chaincodes map[string]bool
+}
+
+// audits returns whether the auditor audits the chaincode, designated by its
+// name or its path
+func (auditor *auditorKey) audits(chaincodeID *obc.ChaincodeID) bool {
+	return (chaincodeID.Name != "" && auditor.chaincodes[chaincodeID.Name]) ||
+		(chaincodeID.Path != "" && auditor.chaincodes[chaincodeID.Path])
+}
+
+// initAuditors loads the auditors of the configuration
+func (client *clientImpl) initAuditors() error {
+	auditors := viper.GetStringMap("security.audit.auditors")
+
+	ids := make([]string, 0, len(auditors))
+	for id := range auditors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	client.auditors = nil
+	for _, id := range ids {
+		properties := make(map[string]interface{})
+		for key, value := range cast.ToStringMap(auditors[id]) {
+			properties[strings.ToLower(key)] = value
+		}
+
+		raw, err := ioutil.ReadFile(cast.ToString(properties["publickeyfile"]))
+		if err != nil {
+			client.Errorf("Failed reading public key of auditor [%s]: [%s].", id, err)
+
+			return err
+		}
+		pk, err := primitives.PEMtoPublicKey(raw, nil)
+		if err != nil {
+			client.Errorf("Failed parsing public key of auditor [%s]: [%s].", id, err)
+
+			return err
+		}
+		ecdsaPK, ok := pk.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("Invalid public key of auditor " + id + ". Expected an ECDSA public key.")
+		}
+		publicKey, err := client.eciesSPI.NewPublicKey(nil, ecdsaPK)
+		if err != nil {
+			client.Errorf("Failed creating public key of auditor [%s]: [%s].", id, err)
+
+			return err
+		}
+
+		chaincodes := make(map[string]bool)
+		for _, chaincode := range cast.ToStringSlice(properties["chaincodes"]) {
+			chaincodes[chaincode] = true
+		}
+		if len(chaincodes) == 0 {
+			client.Warningf("Auditor [%s] audits no chaincode.", id)
+		}
+
+		client.auditors = append(client.auditors, &auditorKey{id: id, publicKey: publicKey, chaincodes: chaincodes})
+	}
+
+	return nil
+}
+
+// encryptTxKeyToAuditors appends to the transaction the transaction key
+// encrypted to each auditor of its chaincode. It must be called before the
+// chaincodeID is encrypted.
+func (client *clientImpl) encryptTxKeyToAuditors(tx *obc.Transaction, txKey []byte) error {
+	if len(client.auditors) == 0 {
+		return nil
+	}
+
+	chaincodeID := &obc.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err != nil {
+		client.Errorf("Failed unmarshalling chaincodeID: [%s]", err)
+
+		return err
+	}
+
+	for _, auditor := range client.auditors {
+		if !auditor.audits(chaincodeID) {
+			continue
+		}
+
+		cipher, err := client.eciesSPI.NewAsymmetricCipherFromPublicKey(auditor.publicKey)
+		if err != nil {
+			client.Errorf("Failed creating new encryption scheme: [%s]", err)
+
+			return err
+		}
+
+		key, err := cipher.Process(txKey)
+		if err != nil {
+			client.Errorf("Failed encrypting transaction key to auditor [%s]: [%s]", auditor.id, err)
+
+			return err
+		}
+
+		tx.ToAuditors = append(tx.ToAuditors, &obc.AuditorKeyShare{AuditorID: auditor.id, Key: key})
+	}
+
+	return nil
+}
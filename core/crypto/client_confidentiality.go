@@ -22,6 +22,7 @@ import (
 	"errors"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/primitives/aes"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
 )
@@ -40,6 +41,9 @@ func (client *clientImpl) encryptTx(tx *obc.Transaction) error {
 	case "1.2":
 		client.Debug("Using confidentiality protocol version 1.2")
 		return client.encryptTxVersion1_2(tx)
+	case "2.0":
+		client.Debug("Using confidentiality protocol version 2.0")
+		return client.encryptTxVersion2_0(tx)
 	}
 
 	return utils.ErrInvalidProtocolVersion
@@ -47,7 +51,10 @@ func (client *clientImpl) encryptTx(tx *obc.Transaction) error {
 
 func (client *clientImpl) encryptTxVersion1_1(tx *obc.Transaction) error {
 	// client.enrollChainKey is an AES key represented as byte array
-	enrollChainKey := client.enrollChainKey.([]byte)
+	enrollChainKey, err := client.getEnrollmentChainSecretKey()
+	if err != nil {
+		return err
+	}
 
 	// Derive key
 	txKey := primitives.HMAC(enrollChainKey, tx.Nonce)
@@ -172,6 +179,11 @@ func (client *clientImpl) encryptTxVersion1_2(tx *obc.Transaction) error {
 	}
 	tx.ToValidators = encMsgToValidators
 
+	// Share the chaincode private key with the auditors
+	if err = client.encryptTxKeyToAuditors(tx, privBytes); err != nil {
+		return err
+	}
+
 	// Encrypt the rest of the fields
 
 	// Init with chainccode pk
@@ -213,3 +225,116 @@ func (client *clientImpl) encryptTxVersion1_2(tx *obc.Transaction) error {
 
 	return nil
 }
+
+// chainCodeValidatorMessage2_0 represents a message to validators
+type chainCodeValidatorMessage2_0 struct {
+	TxKey    []byte
+	StateKey []byte
+}
+
+// getTxFieldKey2_0 derives the key of a field of a transaction from the
+// transaction key and nonce, so that fields can not be swapped between
+// transactions or with each other
+func getTxFieldKey2_0(txKey, nonce []byte, field byte) []byte {
+	return primitives.HMACAESTruncated(txKey, append([]byte{field}, nonce...))
+}
+
+// encryptTxVersion2_0 encrypts the transaction with a fresh AES key, sent to
+// the validators encrypted with the chain public key, from which the keys of
+// the fields are derived. Fields are encrypted with AES-GCM, which costs a
+// single asymmetric encryption per transaction and authenticates them.
+func (client *clientImpl) encryptTxVersion2_0(tx *obc.Transaction) error {
+	// Create the transaction key
+	txKey, err := primitives.GenAESKey()
+	if err != nil {
+		client.Errorf("Failed creating transaction key: [%s]", err)
+
+		return err
+	}
+
+	// Prepare chaincode stateKey
+	var stateKey []byte
+	switch tx.Type {
+	case obc.Transaction_CHAINCODE_DEPLOY:
+		stateKey, err = primitives.GenAESKey()
+		if err != nil {
+			client.Errorf("Failed creating state key: [%s]", err)
+
+			return err
+		}
+	case obc.Transaction_CHAINCODE_QUERY:
+		stateKey = primitives.HMACAESTruncated(client.queryStateKey, append([]byte{6}, tx.Nonce...))
+	case obc.Transaction_CHAINCODE_INVOKE:
+		stateKey = make([]byte, 0)
+	}
+
+	// Encrypt message to the validators
+	cipher, err := client.eciesSPI.NewAsymmetricCipherFromPublicKey(client.chainPublicKey)
+	if err != nil {
+		client.Errorf("Failed creating new encryption scheme: [%s]", err)
+
+		return err
+	}
+
+	msgToValidators, err := asn1.Marshal(chainCodeValidatorMessage2_0{txKey, stateKey})
+	if err != nil {
+		client.Errorf("Failed preparing message to the validators: [%s]", err)
+
+		return err
+	}
+
+	encMsgToValidators, err := cipher.Process(msgToValidators)
+	if err != nil {
+		client.Errorf("Failed encrypting message to the validators: [%s]", err)
+
+		return err
+	}
+	tx.ToValidators = encMsgToValidators
+
+	// Share the transaction key with the auditors
+	if err = client.encryptTxKeyToAuditors(tx, txKey); err != nil {
+		return err
+	}
+
+	// Encrypt the rest of the fields
+	aesSPI := aes.NewAES256GSMSPI()
+
+	// Encrypt chaincodeID
+	encryptedChaincodeID, err := encryptTxField2_0(aesSPI, getTxFieldKey2_0(txKey, tx.Nonce, 1), tx.ChaincodeID)
+	if err != nil {
+		client.Errorf("Failed encrypting chaincodeID: [%s]", err)
+
+		return err
+	}
+	tx.ChaincodeID = encryptedChaincodeID
+
+	// Encrypt payload
+	encryptedPayload, err := encryptTxField2_0(aesSPI, getTxFieldKey2_0(txKey, tx.Nonce, 2), tx.Payload)
+	if err != nil {
+		client.Errorf("Failed encrypting payload: [%s]", err)
+
+		return err
+	}
+	tx.Payload = encryptedPayload
+
+	// Encrypt metadata
+	if len(tx.Metadata) != 0 {
+		encryptedMetadata, err := encryptTxField2_0(aesSPI, getTxFieldKey2_0(txKey, tx.Nonce, 3), tx.Metadata)
+		if err != nil {
+			client.Errorf("Failed encrypting metadata: [%s]", err)
+
+			return err
+		}
+		tx.Metadata = encryptedMetadata
+	}
+
+	return nil
+}
+
+func encryptTxField2_0(aesSPI primitives.StreamCipherSPI, key, field []byte) ([]byte, error) {
+	cipher, err := aesSPI.NewStreamCipherForEncryptionFromSerializedKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Process(field)
+}
@@ -57,5 +57,10 @@ func (client *clientImpl) initCryptoEngine() (err error) {
 		return
 	}
 
+	// Init auditors
+	if err = client.initAuditors(); err != nil {
+		return
+	}
+
 	return
 }
@@ -35,7 +35,11 @@ type clientImpl struct {
 
 	// TCA KDFKey
 	tCertOwnerKDFKey []byte
+	tCertBlindingKey []byte // of split-key TCerts, known to the client only
 	tCertPool        tCertPool
+
+	// Auditors
+	auditors []*auditorKey
 }
 
 // NewChaincodeDeployTransaction is used to deploy chaincode.
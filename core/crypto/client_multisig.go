@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// NewChaincodeMultiSignatureExecute is used to execute chaincode's functions
+// once the co-signers satisfying policy signed the transaction.
+func (client *clientImpl) NewChaincodeMultiSignatureExecute(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, policy *obc.MultiSignaturePolicy, attributes ...string) (*obc.Transaction, error) {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return nil, utils.ErrNotInitialized
+	}
+	if policy == nil || policy.Threshold == 0 || int(policy.Threshold) > len(policy.CertHashes) {
+		return nil, utils.ErrInvalidMultiSignaturePolicy
+	}
+
+	// Get next available (not yet used) transaction certificate
+	tBlocks, err := client.tCertPool.GetNextTCerts(1, attributes...)
+	if err != nil {
+		client.Errorf("Failed to obtain a (not yet used) TCert [%s].", err.Error())
+		return nil, err
+	}
+
+	if len(tBlocks) != 1 {
+		client.Error("Failed to obtain a (not yet used) TCert.")
+		return nil, errors.New("Failed to obtain a TCert for Chaincode Execution. Expected exactly one returned TCert.")
+	}
+	tCert := tBlocks[0].tCert
+
+	/// Create a new transaction
+	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nil, tCert, attributes...)
+	if err != nil {
+		client.Errorf("Failed creating new execute transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	// Append the policy and the certificate to the transaction, both signed
+	tx.MultiSignature = &obc.MultiSignature{Policy: policy}
+	tx.Cert = tCert.GetCertificate().Raw
+
+	rawTx, err := getSignedTransactionBytes(tx)
+	if err != nil {
+		client.Errorf("Failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+	tx.Signature, err = tCert.Sign(rawTx)
+	if err != nil {
+		client.Errorf("Failed creating signature [%s].", err.Error())
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// SignTransaction co-signs tx with the enrollment key. The caller appends the
+// signature to the signatures of the multi-signature of tx.
+func (client *clientImpl) SignTransaction(tx *obc.Transaction) (*obc.TransactionSignature, error) {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return nil, utils.ErrNotInitialized
+	}
+	if tx.MultiSignature == nil {
+		return nil, utils.ErrInvalidMultiSignaturePolicy
+	}
+
+	// Only sign transactions well signed by their submitter
+	if err := client.checkTransaction(tx); err != nil {
+		client.Errorf("Failed checking transaction to co-sign [%s].", err.Error())
+		return nil, err
+	}
+
+	rawTx, err := getSignedTransactionBytes(tx)
+	if err != nil {
+		client.Errorf("Failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+	signature, err := client.signWithEnrollmentKey(rawTx)
+	if err != nil {
+		client.Errorf("Failed co-signing transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	return &obc.TransactionSignature{Cert: utils.Clone(client.enrollCert.Raw), Signature: signature}, nil
+}
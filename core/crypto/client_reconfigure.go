@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// NewReconfigureTransaction is used to reconfigure the validating peers
+// taking part in consensus to validatorSet. The transaction is signed with
+// the enrollment key, consensus admins being identified by their enrollment
+// certificates. If policy is not nil, the validating peers it lists co-sign
+// the transaction.
+func (client *clientImpl) NewReconfigureTransaction(validatorSet *obc.ValidatorSet, uuid string, policy *obc.MultiSignaturePolicy) (*obc.Transaction, error) {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return nil, utils.ErrNotInitialized
+	}
+	if policy != nil && (policy.Threshold == 0 || int(policy.Threshold) > len(policy.CertHashes)) {
+		return nil, utils.ErrInvalidMultiSignaturePolicy
+	}
+
+	tx, err := obc.NewReconfigureTransaction(validatorSet, uuid)
+	if err != nil {
+		client.Errorf("Failed creating new reconfiguration transaction [%s].", err.Error())
+		return nil, err
+	}
+	if policy != nil {
+		tx.MultiSignature = &obc.MultiSignature{Policy: policy}
+	}
+	tx.Cert = utils.Clone(client.enrollCert.Raw)
+
+	rawTx, err := getSignedTransactionBytes(tx)
+	if err != nil {
+		client.Errorf("Failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+	tx.Signature, err = client.signWithEnrollmentKey(rawTx)
+	if err != nil {
+		client.Errorf("Failed creating signature [%s].", err.Error())
+		return nil, err
+	}
+
+	return tx, nil
+}
@@ -36,12 +36,17 @@ func (client *clientImpl) DecryptQueryResult(queryTx *obc.Transaction, ct []byte
 
 	switch queryTx.ConfidentialityProtocolVersion {
 	case "1.1":
-		enrollChainKey := client.enrollChainKey.([]byte)
+		enrollChainKey, err := client.getEnrollmentChainSecretKey()
+		if err != nil {
+			return nil, err
+		}
 		queryKey = primitives.HMACAESTruncated(enrollChainKey, append([]byte{6}, queryTx.Nonce...))
 		//	client.log.Info("QUERY Decrypting with key: ", utils.EncodeBase64(queryKey))
 		break
-	case "1.2":
+	case "1.2", "2.0":
 		queryKey = primitives.HMACAESTruncated(client.queryStateKey, append([]byte{6}, queryTx.Nonce...))
+	default:
+		return nil, utils.ErrInvalidProtocolVersion
 	}
 
 	if len(ct) <= primitives.NonceSize {
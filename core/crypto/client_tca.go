@@ -41,12 +41,20 @@ func (client *clientImpl) initTCertEngine() (err error) {
 		return
 	}
 
+	// load or generate the blinding key of split-key TCerts
+	if err = client.loadTCertBlindingKey(); err != nil {
+		return
+	}
+
 	// init TCerPool
 	client.Debugf("Using multithreading [%t]", client.conf.IsMultithreadingEnabled())
 	client.Debugf("TCert batch size [%d]", client.conf.getTCertBatchSize())
+	client.Debugf("Using TCert prefetching [%t]", client.conf.IsTCertPrefetchEnabled())
 
 	if client.conf.IsMultithreadingEnabled() {
 		client.tCertPool = new(tCertPoolMultithreadingImpl)
+	} else if client.conf.IsTCertPrefetchEnabled() {
+		client.tCertPool = new(tCertPoolPrefetchImpl)
 	} else {
 		client.tCertPool = new(tCertPoolSingleThreadImpl)
 	}
@@ -105,6 +113,18 @@ func (client *clientImpl) getTCertFromExternalDER(der []byte) (tCert, error) {
 		return nil, err
 	}
 
+	// Split-key TCerts derive from their nonce rather than from a TCertIndex
+	if nonce, err := primitives.GetCriticalExtension(x509Cert, primitives.TCertSplitKeyNonce); err == nil {
+		tempSK, err := client.getSplitKeyTCertSigningKey(x509Cert, nonce)
+		if err != nil {
+			client.Warningf("Failed deriving split-key TCert signing key [%s]. This is an foreign certificate.", err.Error())
+
+			return &tCertImpl{client, x509Cert, nil, []byte{}}, nil
+		}
+
+		return &tCertImpl{client, x509Cert, tempSK, []byte{}}, nil
+	}
+
 	// Handle Critical Extension TCertEncTCertIndex
 	tCertIndexCT, err := primitives.GetCriticalExtension(x509Cert, primitives.TCertEncTCertIndex)
 	if err != nil {
@@ -258,6 +278,18 @@ func (client *clientImpl) getTCertFromDER(certBlk *TCertDBBlock) (certBlock *TCe
 		return
 	}
 
+	// Split-key TCerts derive from their nonce rather than from a TCertIndex
+	if nonce, err := primitives.GetCriticalExtension(x509Cert, primitives.TCertSplitKeyNonce); err == nil {
+		tempSK, err := client.getSplitKeyTCertSigningKey(x509Cert, nonce)
+		if err != nil {
+			client.Errorf("Failed deriving split-key TCert signing key [%s].", err.Error())
+
+			return nil, err
+		}
+
+		return &TCertBlock{&tCertImpl{client, x509Cert, tempSK, certBlk.preK0}, certBlk.attributesHash}, nil
+	}
+
 	// Handle Critical Extenstion TCertEncTCertIndex
 	tCertIndexCT, err := primitives.GetCriticalExtension(x509Cert, primitives.TCertEncTCertIndex)
 	if err != nil {
@@ -423,6 +455,23 @@ func (client *clientImpl) getTCertsFromTCA(attrhash string, attributes []string,
 			continue
 		}
 
+		// Split-key TCerts derive from their nonce rather than from a TCertIndex
+		if nonce, err := primitives.GetCriticalExtension(x509Cert, primitives.TCertSplitKeyNonce); err == nil {
+			tempSK, err := client.getSplitKeyTCertSigningKey(x509Cert, nonce)
+			if err != nil {
+				client.Errorf("Failed deriving split-key TCert signing key [%s].", err.Error())
+
+				continue
+			}
+			j++
+
+			prek0Cp := make([]byte, len(prek0))
+			copy(prek0Cp, prek0)
+			client.tCertPool.AddTCert(&TCertBlock{&tCertImpl{client, x509Cert, tempSK, prek0Cp}, attrhash})
+
+			continue
+		}
+
 		// Handle Critical Extenstion TCertEncTCertIndex
 		tCertIndexCT, err := primitives.GetCriticalExtension(x509Cert, primitives.TCertEncTCertIndex)
 		if err != nil {
@@ -584,6 +633,15 @@ func (client *clientImpl) callTCACreateCertificateSet(num int, attributes []stri
 		Sig:        nil,
 	}
 
+	// Split-key TCerts are requested with a key share each
+	if client.conf.IsTCertSplitKeyEnabled() {
+		req.KeyShares, req.KeyShareProof, err = client.newTCertKeyShares(num)
+		if err != nil {
+			client.Errorf("Failed creating TCert key shares [%s].", err.Error())
+			return nil, nil, err
+		}
+	}
+
 	rawReq, err := proto.Marshal(req)
 	if err != nil {
 		client.Errorf("Failed marshaling request [%s].", err.Error())
@@ -591,17 +649,14 @@ func (client *clientImpl) callTCACreateCertificateSet(num int, attributes []stri
 	}
 
 	// 2. Sign rawReq
-	r, s, err := client.ecdsaSignWithEnrollmentKey(rawReq)
+	sig, err := client.newSignature(client.enrollSigner, rawReq)
 	if err != nil {
 		client.Errorf("Failed creating signature for [% x]: [%s].", rawReq, err.Error())
 		return nil, nil, err
 	}
 
-	R, _ := r.MarshalText()
-	S, _ := s.MarshalText()
-
 	// 3. Append the signature
-	req.Sig = &membersrvc.Signature{Type: membersrvc.CryptoType_ECDSA, R: R, S: S}
+	req.Sig = sig
 
 	// 4. Send request
 	certSet, err := tcaP.CreateCertificateSet(context.Background(), req)
@@ -0,0 +1,126 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"errors"
+	"math/big"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+)
+
+// tCertNonceSize is the size of the nonce of a split-key TCert
+const tCertNonceSize = 16
+
+func (client *clientImpl) loadTCertBlindingKey() error {
+	if client.ks.isAliasSet(client.conf.getTCertBlindingKeyFilename()) {
+		blindingKey, err := client.ks.loadKey(client.conf.getTCertBlindingKeyFilename())
+		if err != nil {
+			client.Errorf("Failed loading TCert blinding key [%s].", err.Error())
+
+			return err
+		}
+		client.tCertBlindingKey = blindingKey
+
+		return nil
+	}
+	if !client.conf.IsTCertSplitKeyEnabled() {
+		return nil
+	}
+
+	// The blinding key never leaves the client, it is all the TCA lacks to
+	// link the split-key TCerts of the client
+	blindingKey, err := primitives.GetRandomBytes(primitives.AESKeyLength)
+	if err != nil {
+		client.Errorf("Failed generating TCert blinding key [%s].", err.Error())
+
+		return err
+	}
+	if err := client.ks.storeKey(client.conf.getTCertBlindingKeyFilename(), blindingKey); err != nil {
+		client.Errorf("Failed storing TCert blinding key [%s].", err.Error())
+
+		return err
+	}
+	client.tCertBlindingKey = blindingKey
+
+	return nil
+}
+
+// newTCertKeyShares returns num key shares of split-key TCerts with fresh
+// nonces, and the proof the client knows their blinding values
+func (client *clientImpl) newTCertKeyShares(num int) ([]*membersrvc.TCertKeyShare, []byte, error) {
+	if client.tCertBlindingKey == nil {
+		return nil, nil, errors.New("TCert blinding key not initialized yet.")
+	}
+	curve := client.enrollPrivKey.Curve
+
+	keyShares := make([]*membersrvc.TCertKeyShare, num)
+	secrets := make([]*big.Int, num)
+	shares := make([][]byte, num)
+	for i := range keyShares {
+		nonce, err := primitives.GetRandomBytes(tCertNonceSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		secrets[i] = primitives.ExpandScalar(curve, client.tCertBlindingKey, nonce)
+		shares[i] = primitives.NewKeyShare(curve, secrets[i])
+		keyShares[i] = &membersrvc.TCertKeyShare{Nonce: nonce, Share: shares[i]}
+	}
+
+	proof, err := primitives.NewKeyShareBatchProof(curve, secrets, shares, client.enrollCert.Raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keyShares, proof, nil
+}
+
+// getSplitKeyTCertSigningKey derives the signing key of the split-key TCert
+// x509Cert with the given nonce: the enrollment key, plus the ExpansionValue
+// derived from the nonce with the TCertOwnerKDFKey, plus the blinding value
+// derived from the nonce with the blinding key.
+func (client *clientImpl) getSplitKeyTCertSigningKey(x509Cert *x509.Certificate, nonce []byte) (*ecdsa.PrivateKey, error) {
+	if client.tCertOwnerKDFKey == nil || client.tCertBlindingKey == nil {
+		return nil, errors.New("TCert keys not initialized yet.")
+	}
+
+	// Verify certificate against root
+	if _, err := primitives.CheckCertAgainRoot(x509Cert, client.tcaCertPool); err != nil {
+		return nil, err
+	}
+
+	curve := client.enrollPrivKey.Curve
+	expansionKey := primitives.HMAC(client.tCertOwnerKDFKey, []byte{2})
+
+	d := new(big.Int).Set(client.enrollPrivKey.D)
+	d.Add(d, primitives.ExpandScalar(curve, expansionKey, nonce))
+	d.Add(d, primitives.ExpandScalar(curve, client.tCertBlindingKey, nonce))
+	d.Mod(d, curve.Params().N)
+
+	tempSK := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: d}
+	tempSK.PublicKey.X, tempSK.PublicKey.Y = curve.ScalarBaseMult(d.Bytes())
+
+	// Check that the derived public key is the same as the one in the certificate
+	if err := primitives.CheckCertPKAgainstSK(x509Cert, interface{}(tempSK)); err != nil {
+		return nil, err
+	}
+
+	return tempSK, nil
+}
@@ -0,0 +1,232 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"sync"
+)
+
+// tCertPoolPrefetchEntry holds the TCerts of a set of attributes
+type tCertPoolPrefetchEntry struct {
+	attributes []string
+	tCerts     []*TCertBlock
+
+	// refilling is set while TCerts are requested to the TCA for the entry
+	refilling bool
+
+	// fetched and used count the TCerts received from the TCA and handed out
+	fetched int
+	used    int
+}
+
+// tCertPoolPrefetchImpl prefetches TCerts in batches and refills each set of
+// attributes in the background as soon as its available TCerts fall below the
+// low-water mark, so that transactions do not wait for the TCA. The unused
+// TCerts are stored in the keystore when the pool stops, and loaded when it
+// starts again.
+type tCertPoolPrefetchImpl struct {
+	client *clientImpl
+
+	entries map[string]*tCertPoolPrefetchEntry
+	stopped bool
+
+	m        sync.Mutex
+	refilled *sync.Cond
+	refills  sync.WaitGroup
+}
+
+func (tCertPool *tCertPoolPrefetchImpl) init(client *clientImpl) (err error) {
+	tCertPool.client = client
+	tCertPool.client.Debug("Init TCert Prefetch Pool...")
+
+	tCertPool.entries = make(map[string]*tCertPoolPrefetchEntry)
+	tCertPool.refilled = sync.NewCond(&tCertPool.m)
+
+	return
+}
+
+//Start starts the pool processing: it loads the unused TCerts and prefetches TCerts without attributes if needed.
+func (tCertPool *tCertPoolPrefetchImpl) Start() (err error) {
+	tCertPool.client.Debug("Starting TCert Prefetch Pool...")
+
+	// Load unused TCerts if any
+	tCertDBBlocks, err := tCertPool.client.ks.loadUnusedTCerts()
+	if err != nil {
+		tCertPool.client.Errorf("Failed loading TCerts from cache: [%s]", err)
+
+		return
+	}
+
+	tCertPool.client.Debugf("Found [%d] unused TCerts in cache.", len(tCertDBBlocks))
+
+	for _, tCertDBBlock := range tCertDBBlocks {
+		tCertBlock, err := tCertPool.client.getTCertFromDER(tCertDBBlock)
+		if err != nil {
+			tCertPool.client.Errorf("Failed paring TCert [% x]: [%s]", tCertDBBlock.tCertDER, err)
+
+			continue
+		}
+		tCertPool.AddTCert(tCertBlock)
+	}
+
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	tCertPool.refillIfNeeded(tCertPool.getEntry(nil))
+
+	return
+}
+
+//Stop stops the background refills, waiting for the ones in progress, and stores the unused TCerts.
+func (tCertPool *tCertPoolPrefetchImpl) Stop() (err error) {
+	tCertPool.m.Lock()
+	tCertPool.stopped = true
+	tCertPool.m.Unlock()
+
+	tCertPool.refills.Wait()
+
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	for attributesHash, entry := range tCertPool.entries {
+		tCertPool.client.Debugf("TCerts [%s]: fetched [%d], used [%d], unused [%d].",
+			attributesHash, entry.fetched, entry.used, len(entry.tCerts))
+
+		if err = tCertPool.client.ks.storeUnusedTCerts(entry.tCerts); err != nil {
+			return
+		}
+	}
+
+	tCertPool.client.Debug("Store unused TCerts...done!")
+
+	return
+}
+
+//GetNextTCerts returns TCerts from the pool valid to the passed attributes. If no TCert is available the TCA is invoked to generate them.
+func (tCertPool *tCertPoolPrefetchImpl) GetNextTCerts(nCerts int, attributes ...string) ([]*TCertBlock, error) {
+	blocks := make([]*TCertBlock, nCerts)
+	for i := 0; i < nCerts; i++ {
+		block, err := tCertPool.getNextTCert(attributes)
+		if err != nil {
+			return nil, err
+		}
+		blocks[i] = block
+	}
+	return blocks, nil
+}
+
+func (tCertPool *tCertPoolPrefetchImpl) getNextTCert(attributes []string) (*TCertBlock, error) {
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	entry := tCertPool.getEntry(attributes)
+
+	for len(entry.tCerts) == 0 {
+		if entry.refilling {
+			// Wait for the refill in progress
+			tCertPool.refilled.Wait()
+
+			continue
+		}
+
+		tCertPool.client.Debugf("No TCert available for [%s], fetching them.", calculateAttributesHash(attributes))
+
+		entry.refilling = true
+		tCertPool.m.Unlock()
+		err := tCertPool.client.getTCertsFromTCA(calculateAttributesHash(attributes), attributes, tCertPool.client.conf.getTCertBatchSize())
+		tCertPool.m.Lock()
+		entry.refilling = false
+		tCertPool.refilled.Broadcast()
+
+		if err != nil {
+			tCertPool.client.Errorf("Failed loading TCerts from TCA: [%s]", err)
+
+			return nil, err
+		}
+	}
+
+	tCertBlock := entry.tCerts[len(entry.tCerts)-1]
+	entry.tCerts = entry.tCerts[:len(entry.tCerts)-1]
+	entry.used++
+
+	tCertPool.refillIfNeeded(entry)
+
+	return tCertBlock, nil
+}
+
+//AddTCert adds a TCert into the pool is invoked by the client after TCA is called.
+func (tCertPool *tCertPoolPrefetchImpl) AddTCert(tCertBlock *TCertBlock) (err error) {
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	tCertPool.client.Debugf("Adding new Cert [% x].", tCertBlock.tCert.GetCertificate().Raw)
+
+	entry := tCertPool.entries[tCertBlock.attributesHash]
+	if entry == nil {
+		// The attributes are known once TCerts are requested for them
+		entry = &tCertPoolPrefetchEntry{}
+		tCertPool.entries[tCertBlock.attributesHash] = entry
+	}
+	entry.tCerts = append(entry.tCerts, tCertBlock)
+	entry.fetched++
+
+	return
+}
+
+// getEntry returns the entry of the attributes, creating it if needed. The
+// pool must be locked.
+func (tCertPool *tCertPoolPrefetchImpl) getEntry(attributes []string) *tCertPoolPrefetchEntry {
+	attributesHash := calculateAttributesHash(attributes)
+	entry := tCertPool.entries[attributesHash]
+	if entry == nil {
+		entry = &tCertPoolPrefetchEntry{}
+		tCertPool.entries[attributesHash] = entry
+	}
+	if entry.attributes == nil {
+		entry.attributes = append([]string{}, attributes...)
+	}
+	return entry
+}
+
+// refillIfNeeded requests a batch of TCerts to the TCA in the background if
+// the entry is below the low-water mark and not being refilled. The pool
+// must be locked.
+func (tCertPool *tCertPoolPrefetchImpl) refillIfNeeded(entry *tCertPoolPrefetchEntry) {
+	if tCertPool.stopped || entry.refilling || len(entry.tCerts) >= tCertPool.client.conf.getTCertLowWaterMark() {
+		return
+	}
+
+	entry.refilling = true
+	tCertPool.refills.Add(1)
+
+	go func(attributes []string) {
+		defer tCertPool.refills.Done()
+
+		attributesHash := calculateAttributesHash(attributes)
+		tCertPool.client.Debugf("Refilling TCerts for [%s].", attributesHash)
+
+		err := tCertPool.client.getTCertsFromTCA(attributesHash, attributes, tCertPool.client.conf.getTCertBatchSize())
+		if err != nil {
+			tCertPool.client.Errorf("Failed refilling TCerts from TCA: [%s]", err)
+		}
+
+		tCertPool.m.Lock()
+		entry.refilling = false
+		tCertPool.refilled.Broadcast()
+		tCertPool.m.Unlock()
+	}(entry.attributes)
+}
@@ -34,8 +34,14 @@ func (client *clientImpl) createTransactionNonce() ([]byte, error) {
 }
 
 func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, nonce []byte, tCert tCert, attrs ...string) (*obc.Transaction, error) {
-	// Create a new transaction
-	tx, err := obc.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, uuid)
+	// Create a new transaction, an upgrade if the chaincode replaces a deployed one
+	var tx *obc.Transaction
+	var err error
+	if chaincodeDeploymentSpec.UpgradedChaincodeName != "" {
+		tx, err = obc.NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec, uuid)
+	} else {
+		tx, err = obc.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, uuid)
+	}
 	if err != nil {
 		client.Errorf("Failed creating new transaction [%s].", err.Error())
 		return nil, err
@@ -65,7 +71,7 @@ func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeD
 		tx.ConfidentialityLevel = obc.ConfidentialityLevel_CONFIDENTIAL
 
 		// 2. set confidentiality protocol version
-		tx.ConfidentialityProtocolVersion = client.conf.GetConfidentialityProtocolVersion()
+		tx.ConfidentialityProtocolVersion = client.getConfidentialityProtocolVersion(chaincodeDeploymentSpec.ChaincodeSpec)
 
 		// 3. encrypt tx
 		err = client.encryptTx(tx)
@@ -79,6 +85,15 @@ func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeD
 	return tx, nil
 }
 
+// getConfidentialityProtocolVersion returns the confidentiality protocol
+// version requested by the spec, the one of the configuration if none is
+func (client *clientImpl) getConfidentialityProtocolVersion(chaincodeSpec *obc.ChaincodeSpec) string {
+	if chaincodeSpec.ConfidentialityProtocolVersion != "" {
+		return chaincodeSpec.ConfidentialityProtocolVersion
+	}
+	return client.conf.GetConfidentialityProtocolVersion()
+}
+
 func getMetadata(chaincodeSpec *obc.ChaincodeSpec, tCert tCert, attrs ...string) ([]byte, error) {
 	//TODO this code is being commented due temporarily is not enabled attributes encryption.
 	/*
@@ -127,7 +142,7 @@ func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvo
 		tx.ConfidentialityLevel = obc.ConfidentialityLevel_CONFIDENTIAL
 
 		// 2. set confidentiality protocol version
-		tx.ConfidentialityProtocolVersion = client.conf.GetConfidentialityProtocolVersion()
+		tx.ConfidentialityProtocolVersion = client.getConfidentialityProtocolVersion(chaincodeInvocation.ChaincodeSpec)
 
 		// 3. encrypt tx
 		err = client.encryptTx(tx)
@@ -172,7 +187,7 @@ func (client *clientImpl) createQueryTx(chaincodeInvocation *obc.ChaincodeInvoca
 		tx.ConfidentialityLevel = obc.ConfidentialityLevel_CONFIDENTIAL
 
 		// 2. set confidentiality protocol version
-		tx.ConfidentialityProtocolVersion = client.conf.GetConfidentialityProtocolVersion()
+		tx.ConfidentialityProtocolVersion = client.getConfidentialityProtocolVersion(chaincodeInvocation.ChaincodeSpec)
 
 		// 3. encrypt tx
 		err = client.encryptTx(tx)
@@ -430,15 +445,12 @@ func (client *clientImpl) checkTransaction(tx *obc.Transaction) error {
 		}
 		// TODO: verify cert
 
-		// 3. Marshall tx without signature
-		signature := tx.Signature
-		tx.Signature = nil
-		rawTx, err := proto.Marshal(tx)
+		// 3. Marshall tx without signatures
+		rawTx, err := getSignedTransactionBytes(tx)
 		if err != nil {
 			client.Errorf("Failed marshaling tx [%s].", err.Error())
 			return err
 		}
-		tx.Signature = signature
 
 		// 2. Verify signature
 		ver, err := client.verify(cert.PublicKey, rawTx, tx.Signature)
@@ -48,7 +48,8 @@ type Node interface {
 type Client interface {
 	Node
 
-	// NewChaincodeDeployTransaction is used to deploy chaincode.
+	// NewChaincodeDeployTransaction is used to deploy chaincode. It creates an
+	// upgrade transaction if chaincodeDeploymentSpec names the chaincode it upgrades.
 	NewChaincodeDeployTransaction(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, attributes ...string) (*obc.Transaction, error)
 
 	// NewChaincodeExecute is used to execute chaincode's functions.
@@ -57,6 +58,20 @@ type Client interface {
 	// NewChaincodeQuery is used to query chaincode's functions.
 	NewChaincodeQuery(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, attributes ...string) (*obc.Transaction, error)
 
+	// NewChaincodeMultiSignatureExecute is used to execute chaincode's functions
+	// once the co-signers satisfying policy signed the transaction. The
+	// policy lists the SHA3-256 hashes of the enrollment certificates of the
+	// co-signers.
+	NewChaincodeMultiSignatureExecute(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, policy *obc.MultiSignaturePolicy, attributes ...string) (*obc.Transaction, error)
+
+	// NewReconfigureTransaction is used to reconfigure the validating peers
+	// taking part in consensus, signed with the enrollment key. The
+	// validating peers listed by policy, if not nil, co-sign it.
+	NewReconfigureTransaction(validatorSet *obc.ValidatorSet, uuid string, policy *obc.MultiSignaturePolicy) (*obc.Transaction, error)
+
+	// SignTransaction co-signs a multi-signature transaction with the enrollment key
+	SignTransaction(tx *obc.Transaction) (*obc.TransactionSignature, error)
+
 	// DecryptQueryResult is used to decrypt the result of a query transaction
 	DecryptQueryResult(queryTx *obc.Transaction, result []byte) ([]byte, error)
 
@@ -104,6 +119,12 @@ type Peer interface {
 	// If vkID is nil, then the signature is verified against this validator's verification key.
 	Verify(vkID, signature, message []byte) error
 
+	// VerifyEnrollmentSignature checks that cert is an enrollment certificate
+	// issued by the ECA and that signature is a valid signature of message
+	// under its verification key. It returns the enrollment ID of the
+	// certificate if the verification succeeded.
+	VerifyEnrollmentSignature(cert, signature, message []byte) (string, error)
+
 	// GetStateEncryptor returns a StateEncryptor linked to pair defined by
 	// the deploy transaction and the execute transaction. Notice that,
 	// executeTx can also correspond to a deploy transaction.
@@ -112,6 +133,18 @@ type Peer interface {
 	GetTransactionBinding(tx *obc.Transaction) ([]byte, error)
 }
 
+// Auditor is an entity able to decrypt the confidential transactions of the
+// chaincodes it audits, but not their state
+type Auditor interface {
+
+	// GetID returns this auditor's identifier
+	GetID() string
+
+	// DecryptTransaction returns a clone of tx whose chaincodeID, payload
+	// and metadata are decrypted with the key shared with this auditor.
+	DecryptTransaction(tx *obc.Transaction) (*obc.Transaction, error)
+}
+
 // StateEncryptor is used to encrypt chaincode's state
 type StateEncryptor interface {
 
@@ -149,7 +182,8 @@ type TransactionHandler interface {
 	// GetBinding returns a binding to the underlying transaction
 	GetBinding() ([]byte, error)
 
-	// NewChaincodeDeployTransaction is used to deploy chaincode
+	// NewChaincodeDeployTransaction is used to deploy chaincode, or to upgrade
+	// the chaincode named by chaincodeDeploymentSpec
 	NewChaincodeDeployTransaction(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, attributeNames ...string) (*obc.Transaction, error)
 
 	// NewChaincodeExecute is used to execute chaincode's functions
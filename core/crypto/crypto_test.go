@@ -29,7 +29,9 @@ import (
 	"reflect"
 	"testing"
 
+	"crypto"
 	"crypto/rand"
+	"crypto/x509/pkix"
 
 	"runtime"
 	"time"
@@ -116,6 +118,40 @@ func TestMain(m *testing.M) {
 		os.Exit(ret)
 	}
 
+	//Seventh scenario with the TCert prefetch pool
+	properties["security.hashAlgorithm"] = "SHA3"
+	properties["security.level"] = "256"
+	properties["security.tcert.prefetch.enabled"] = "true"
+	ret = runTestsOnScenario(m, properties, "Using TCert prefetching")
+	if ret != 0 {
+		os.Exit(ret)
+	}
+
+	//Eighth scenario using confidentialityProtocolVersion = 2.0
+	properties["security.tcert.prefetch.enabled"] = "false"
+	properties["security.confidentialityProtocolVersion"] = "2.0"
+	ret = runTestsOnScenario(m, properties, "Using confidentialityProtocolVersion = 2.0")
+	if ret != 0 {
+		os.Exit(ret)
+	}
+
+	//Ninth scenario with Ed25519 keys for the CAs, peers and validators
+	properties["security.confidentialityProtocolVersion"] = "1.2"
+	properties["security.signatureAlgorithm"] = "Ed25519"
+	ret = runTestsOnScenario(m, properties, "Using Ed25519")
+	if ret != 0 {
+		os.Exit(ret)
+	}
+
+	//Tenth scenario with split-key TCerts
+	properties["security.signatureAlgorithm"] = ""
+	properties["tca.derivation"] = "split"
+	properties["security.tcert.derivation"] = "split"
+	ret = runTestsOnScenario(m, properties, "Using split-key TCerts")
+	if ret != 0 {
+		os.Exit(ret)
+	}
+
 	os.Exit(ret)
 }
 
@@ -505,6 +541,95 @@ func TestClientGetAttributesFromTCertWithUnusedTCerts(t *testing.T) {
 	}
 }
 
+func TestClientTCertPoolRefill(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	tCertPool, ok := deployer.(*clientImpl).tCertPool.(*tCertPoolPrefetchImpl)
+	if !ok {
+		// The TCert prefetch pool is not enabled
+		return
+	}
+
+	batchSize := deployer.(*clientImpl).conf.getTCertBatchSize()
+	lowWaterMark := deployer.(*clientImpl).conf.getTCertLowWaterMark()
+	nCerts := batchSize - lowWaterMark + 1
+
+	tcerts, err := deployer.GetNextTCerts(nCerts, attrs...)
+	if err != nil {
+		t.Fatalf("Failed getting tcerts: [%s]", err)
+	}
+	if len(tcerts) != nCerts {
+		t.Fatalf("Expected [%d] tcerts, got [%d]", nCerts, len(tcerts))
+	}
+
+	// Below the low-water mark, the pool must have been refilled in background
+	tCertPool.refills.Wait()
+
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	entry := tCertPool.entries[calculateAttributesHash(attrs)]
+	if entry == nil {
+		t.Fatalf("No pool entry for the attributes")
+	}
+	if entry.used != nCerts {
+		t.Fatalf("Expected [%d] used tcerts, got [%d]", nCerts, entry.used)
+	}
+	if len(entry.tCerts) < lowWaterMark {
+		t.Fatalf("Expected the pool to be refilled above [%d] tcerts, got [%d]", lowWaterMark, len(entry.tCerts))
+	}
+}
+
+func TestClientSplitKeyTCert(t *testing.T) {
+	if viper.GetString("tca.derivation") != "split" {
+		t.Skip("The TCA does not issue split-key TCerts.")
+	}
+
+	initNodes()
+	defer closeNodes()
+
+	handler, err := invoker.GetTCertificateHandlerNext()
+	if err != nil {
+		t.Fatalf("Failed getting handler: [%s]", err)
+	}
+	cert, err := primitives.DERToX509Certificate(handler.GetCertificate())
+	if err != nil {
+		t.Fatalf("Failed parsing TCert [%s].", err)
+	}
+	if _, err = primitives.GetCriticalExtension(cert, primitives.TCertSplitKeyNonce); err != nil {
+		t.Fatalf("Split-key TCert must carry a nonce [%s].", err)
+	}
+	if _, err = primitives.GetCriticalExtension(cert, primitives.TCertEncTCertIndex); err == nil {
+		t.Fatal("Split-key TCert must not carry a TCertIndex.")
+	}
+	if _, err = primitives.GetCriticalExtension(cert, primitives.TCertEncEnrollmentID); err == nil {
+		t.Fatal("Split-key TCert must not carry the enrollment ID.")
+	}
+	if cert.Subject.CommonName != "" {
+		t.Fatalf("Split-key TCert must not name its owner, got [%s].", cert.Subject.CommonName)
+	}
+
+	// The owner derives the signing key of the TCert, other clients do not
+	tCert, err := invoker.(*clientImpl).getTCertFromExternalDER(handler.GetCertificate())
+	if err != nil || tCert.(*tCertImpl).sk == nil {
+		t.Fatalf("Owner failed deriving the signing key of the TCert [%s].", err)
+	}
+	tCert, err = deployer.(*clientImpl).getTCertFromExternalDER(handler.GetCertificate())
+	if err != nil || tCert.(*tCertImpl).sk != nil {
+		t.Fatalf("Another client must see the TCert as a foreign certificate [%s].", err)
+	}
+
+	// Peers verify the transactions signed with split-key TCerts
+	_, tx, err := createPublicExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating transaction [%s].", err)
+	}
+	if _, err = peer.TransactionPreValidation(tx); err != nil {
+		t.Fatalf("Failed pre-validating transaction signed with a split-key TCert [%s].", err)
+	}
+}
+
 func TestClientGetTCertHandlerNext(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -876,6 +1001,73 @@ func TestPeerSignVerify(t *testing.T) {
 	}
 }
 
+func TestPeerSignatureSuite(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	suite, err := peer.(*peerImpl).getSignatureSuite()
+	if err != nil {
+		t.Fatalf("Failed getting signature suite [%s].", err)
+	}
+	for _, node := range []*nodeImpl{peer.(*peerImpl).nodeImpl, validator.(*validatorImpl).nodeImpl} {
+		certSuite, err := primitives.GetSignatureSuiteOf(node.enrollCert.PublicKey)
+		if err != nil {
+			t.Fatalf("Failed getting signature suite of enrollment certificate [%s].", err)
+		}
+		if certSuite.Name() != suite.Name() {
+			t.Fatalf("Enrollment certificate of [%s] should carry a %s key, not %s.", node.GetName(), suite.Name(), certSuite.Name())
+		}
+	}
+
+	// Clients enroll with ECDSA keys, whatever the configured algorithm
+	certSuite, err := primitives.GetSignatureSuiteOf(deployer.(*clientImpl).enrollCert.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed getting signature suite of enrollment certificate [%s].", err)
+	}
+	if !primitives.IsECDSASignatureSuite(certSuite) {
+		t.Fatalf("Clients should enroll with an ECDSA key, not %s.", certSuite.Name())
+	}
+}
+
+func TestPeerRenewEnrollmentCertificate(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	before := peer.(*peerImpl).enrollCert
+
+	// ECerts are valid for 90 days, renew them at start when expiring within 100
+	renewBefore := viper.Get("security.enrollment.renewBefore")
+	viper.Set("security.enrollment.renewBefore", "2400h")
+	defer viper.Set("security.enrollment.renewBefore", renewBefore)
+
+	name := peer.GetName()
+	if err := ClosePeer(peer); err != nil {
+		t.Fatalf("Failed closing peer [%s].", err)
+	}
+	var err error
+	peer, err = InitPeer(name, ksPwd)
+	if err != nil {
+		t.Fatalf("Failed initializing peer with an expiring enrollment certificate [%s].", err)
+	}
+
+	after := peer.(*peerImpl).enrollCert
+	if after.SerialNumber.Cmp(before.SerialNumber) == 0 {
+		t.Fatal("Enrollment certificate should have been renewed.")
+	}
+	if !reflect.DeepEqual(after.PublicKey, before.PublicKey) {
+		t.Fatal("Renewed enrollment certificate should certify the enrollment key.")
+	}
+
+	msg := []byte("Hello World!!!")
+	signature, err := peer.Sign(msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+	if err = validator.Verify(peer.GetID(), signature, msg); err != nil {
+		t.Fatalf("Failed verifying signature under the renewed enrollment certificate [%s].", err)
+	}
+}
+
 func TestPeerVerify(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -912,6 +1104,182 @@ func TestPeerVerify(t *testing.T) {
 	}
 }
 
+func TestPeerVerifyEnrollmentSignature(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	handler, err := deployer.GetEnrollmentCertificateHandler()
+	if err != nil {
+		t.Fatalf("Failed getting enrollment certificate handler [%s].", err)
+	}
+
+	msg := []byte("Hello World!!!")
+	signature, err := handler.Sign(msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+
+	enrollID, err := peer.VerifyEnrollmentSignature(handler.GetCertificate(), signature, msg)
+	if err != nil {
+		t.Fatalf("Failed verifying signature [%s].", err)
+	}
+	if enrollID != (&utils.NodeConfiguration{Type: "client", Name: "user1"}).GetEnrollmentID() {
+		t.Fatalf("Verify returned the wrong enrollment id [%s].", enrollID)
+	}
+
+	_, err = peer.VerifyEnrollmentSignature(handler.GetCertificate(), signature, msg[1:])
+	if err == nil {
+		t.Fatal("Verify should fail when given an invalid message.", err)
+	}
+
+	_, err = peer.VerifyEnrollmentSignature(handler.GetCertificate(), nil, msg)
+	if err == nil {
+		t.Fatal("Verify should fail when given an empty signature.", err)
+	}
+
+	_, err = peer.VerifyEnrollmentSignature(msg, signature, msg)
+	if err == nil {
+		t.Fatal("Verify should fail when given an invalid certificate.", err)
+	}
+}
+
+func TestPeerVerifyRevokedEnrollmentSignature(t *testing.T) {
+	viper.Set("security.revocation.enabled", true)
+	defer viper.Set("security.revocation.enabled", false)
+
+	initNodes()
+	defer closeNodes()
+
+	handler, err := deployer.GetEnrollmentCertificateHandler()
+	if err != nil {
+		t.Fatalf("Failed getting enrollment certificate handler [%s].", err)
+	}
+	msg := []byte("Hello World!!!")
+	signature, err := handler.Sign(msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+	cert, err := primitives.DERToX509Certificate(handler.GetCertificate())
+	if err != nil {
+		t.Fatalf("Failed parsing enrollment certificate [%s].", err)
+	}
+
+	// Sign a revocation list listing the enrollment certificate with the ECA key
+	cooked, err := ioutil.ReadFile(filepath.Join(viper.GetString("server.rootpath"), "eca.priv"))
+	if err != nil {
+		t.Fatalf("Failed reading ECA key [%s].", err)
+	}
+	ecaKey, err := primitives.PEMtoPrivateKey(cooked, nil)
+	if err != nil {
+		t.Fatalf("Failed parsing ECA key [%s].", err)
+	}
+	p := peer.(*peerImpl)
+	ecaCert, _, err := p.ks.loadCertX509AndDer(p.conf.getECACertsChainFilename())
+	if err != nil {
+		t.Fatalf("Failed loading ECA certificate [%s].", err)
+	}
+	revoked := []pkix.RevokedCertificate{{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()}}
+	crl, err := ecaCert.CreateCRL(rand.Reader, ecaKey.(crypto.Signer), revoked, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed creating revocation list [%s].", err)
+	}
+
+	readRevocationList := p.readRevocationList
+	defer func() { p.readRevocationList = readRevocationList }()
+
+	p.readRevocationList = func() ([]byte, error) { return nil, nil }
+	if _, err = peer.VerifyEnrollmentSignature(handler.GetCertificate(), signature, msg); err != nil {
+		t.Fatalf("Failed verifying signature without revocation list [%s].", err)
+	}
+
+	p.readRevocationList = func() ([]byte, error) { return crl, nil }
+	if _, err = peer.VerifyEnrollmentSignature(handler.GetCertificate(), signature, msg); err != utils.ErrCertificateRevoked {
+		t.Fatalf("Verify should fail when given a revoked certificate [%s].", err)
+	}
+
+	// A revocation list not signed by the ECA is rejected
+	forgedKey, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Failed generating key [%s].", err)
+	}
+	forged, err := ecaCert.CreateCRL(rand.Reader, forgedKey, revoked, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed creating revocation list [%s].", err)
+	}
+	p.readRevocationList = func() ([]byte, error) { return forged, nil }
+	if _, err = peer.VerifyEnrollmentSignature(handler.GetCertificate(), signature, msg); err == nil {
+		t.Fatal("Verify should fail when the revocation list is not signed by the ECA.")
+	}
+}
+
+func TestPeerMultiSignatureTransaction(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	var certHashes [][]byte
+	for _, client := range []Client{deployer, invoker} {
+		handler, err := client.GetEnrollmentCertificateHandler()
+		if err != nil {
+			t.Fatalf("Failed getting enrollment certificate handler [%s].", err)
+		}
+		certHashes = append(certHashes, primitives.Hash(handler.GetCertificate()))
+	}
+
+	cis := &obc.ChaincodeInvocationSpec{
+		ChaincodeSpec: &obc.ChaincodeSpec{
+			Type:                 obc.ChaincodeSpec_GOLANG,
+			ChaincodeID:          &obc.ChaincodeID{Path: "Contract001"},
+			ConfidentialityLevel: obc.ConfidentialityLevel_PUBLIC,
+		},
+	}
+	if _, err := invoker.NewChaincodeMultiSignatureExecute(cis, util.GenerateUUID(), &obc.MultiSignaturePolicy{Threshold: 3, CertHashes: certHashes}); err != utils.ErrInvalidMultiSignaturePolicy {
+		t.Fatalf("Creating a transaction with an unsatisfiable policy should fail [%s].", err)
+	}
+	tx, err := invoker.NewChaincodeMultiSignatureExecute(cis, util.GenerateUUID(), &obc.MultiSignaturePolicy{Threshold: 2, CertHashes: certHashes})
+	if err != nil {
+		t.Fatalf("Failed creating multi-signature transaction [%s].", err)
+	}
+	if _, err = peer.TransactionPreValidation(tx); err != utils.ErrMultiSignaturePolicyNotSatisfied {
+		t.Fatalf("Transaction without co-signers should not satisfy the policy [%s].", err)
+	}
+
+	for _, client := range []Client{deployer, invoker} {
+		signature, err := client.SignTransaction(tx)
+		if err != nil {
+			t.Fatalf("Failed co-signing transaction [%s].", err)
+		}
+		tx.MultiSignature.Signatures = append(tx.MultiSignature.Signatures, signature)
+	}
+	if _, err = peer.TransactionPreValidation(tx); err != nil {
+		t.Fatalf("Failed pre-validating multi-signature transaction [%s].", err)
+	}
+	if _, err = validator.TransactionPreValidation(tx); err != nil {
+		t.Fatalf("Failed pre-validating multi-signature transaction [%s].", err)
+	}
+
+	// The same co-signer signing twice counts once
+	signatures := tx.MultiSignature.Signatures
+	tx.MultiSignature.Signatures = []*obc.TransactionSignature{signatures[0], signatures[0]}
+	if _, err = peer.TransactionPreValidation(tx); err == nil {
+		t.Fatal("Pre-validation should fail when a co-signer signed twice.")
+	}
+
+	// A signature which does not verify is rejected
+	forged := *signatures[1]
+	forged.Signature = signatures[0].Signature
+	tx.MultiSignature.Signatures = []*obc.TransactionSignature{signatures[0], &forged}
+	if _, err = peer.TransactionPreValidation(tx); err == nil {
+		t.Fatal("Pre-validation should fail when a co-signer signature is invalid.")
+	}
+
+	// The policy is signed by the submitter
+	tx.MultiSignature.Signatures = signatures[:1]
+	tx.MultiSignature.Policy = &obc.MultiSignaturePolicy{Threshold: 1, CertHashes: certHashes}
+	if _, err = peer.TransactionPreValidation(tx); err != utils.ErrInvalidTransactionSignature {
+		t.Fatalf("Pre-validation should fail when the policy is tampered with [%s].", err)
+	}
+}
+
 func TestValidatorID(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -1211,6 +1579,264 @@ func TestValidatorQueryTransaction(t *testing.T) {
 	}
 }
 
+func TestValidatorMixedConfidentialityProtocolVersions(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	newSpec := func(version string) *obc.ChaincodeSpec {
+		return &obc.ChaincodeSpec{
+			Type:                           obc.ChaincodeSpec_GOLANG,
+			ChaincodeID:                    &obc.ChaincodeID{Path: "Contract001"},
+			ConfidentialityLevel:           obc.ConfidentialityLevel_CONFIDENTIAL,
+			ConfidentialityProtocolVersion: version,
+			Metadata:                       []byte("Hello World"),
+		}
+	}
+	preExecute := func(otx, tx *obc.Transaction, version string) *obc.Transaction {
+		if tx.ConfidentialityProtocolVersion != version {
+			t.Fatalf("Expected confidentiality protocol version [%s], got [%s]", version, tx.ConfidentialityProtocolVersion)
+		}
+		if _, err := validator.TransactionPreValidation(tx); err != nil {
+			t.Fatalf("Failed pre-validating transaction [%s].", err)
+		}
+		ptx, err := validator.TransactionPreExecution(tx)
+		if err != nil {
+			t.Fatalf("Failed pre-executing transaction [%s].", err)
+		}
+		if err := isEqual(otx, ptx); err != nil {
+			t.Fatalf("Decrypted transaction differs from the original: [%s]", err)
+		}
+		return ptx
+	}
+
+	// Chaincodes deployed with a version are executed by transactions of the other
+	versions := [][]string{{"1.2", "2.0"}, {"2.0", "1.2"}}
+	for _, v := range versions {
+		deployVersion, executeVersion := v[0], v[1]
+		t.Logf("Deploying with version [%s], executing with version [%s]", deployVersion, executeVersion)
+
+		cds := &obc.ChaincodeDeploymentSpec{ChaincodeSpec: newSpec(deployVersion)}
+		uuid := util.GenerateUUID()
+		otx, err := obc.NewChaincodeDeployTransaction(cds, uuid)
+		if err != nil {
+			t.Fatalf("Failed creating deploy transaction [%s]", err)
+		}
+		otx.Metadata = cds.ChaincodeSpec.Metadata
+		deployTx, err := deployer.NewChaincodeDeployTransaction(cds, uuid, attrs...)
+		if err != nil {
+			t.Fatalf("Failed creating deploy transaction [%s]", err)
+		}
+		deployTx = preExecute(otx, deployTx, deployVersion)
+
+		executeTxs := make([]*obc.Transaction, 3)
+		for i := range executeTxs {
+			cis := &obc.ChaincodeInvocationSpec{ChaincodeSpec: newSpec(executeVersion)}
+			uuid := util.GenerateUUID()
+			txType := obc.Transaction_CHAINCODE_INVOKE
+			if i == len(executeTxs)-1 {
+				txType = obc.Transaction_CHAINCODE_QUERY
+			}
+			otx, err := obc.NewChaincodeExecute(cis, uuid, txType)
+			if err != nil {
+				t.Fatalf("Failed creating execute transaction [%s]", err)
+			}
+			otx.Metadata = cis.ChaincodeSpec.Metadata
+			var tx *obc.Transaction
+			if txType == obc.Transaction_CHAINCODE_QUERY {
+				tx, err = invoker.NewChaincodeQuery(cis, uuid, attrs...)
+			} else {
+				tx, err = invoker.NewChaincodeExecute(cis, uuid, attrs...)
+			}
+			if err != nil {
+				t.Fatalf("Failed creating execute transaction [%s]", err)
+			}
+			executeTxs[i] = preExecute(otx, tx, executeVersion)
+		}
+
+		// The state written by an invocation is read by the next one and the query
+		pt := []byte("Hello World")
+		seOne, err := validator.GetStateEncryptor(deployTx, executeTxs[0])
+		if err != nil {
+			t.Fatalf("Failed creating state encryptor [%s].", err)
+		}
+		ct, err := seOne.Encrypt(pt)
+		if err != nil {
+			t.Fatalf("Failed encrypting state [%s].", err)
+		}
+		seTwo, err := validator.GetStateEncryptor(deployTx, executeTxs[1])
+		if err != nil {
+			t.Fatalf("Failed creating state encryptor [%s].", err)
+		}
+		if aPt, err := seTwo.Decrypt(ct); err != nil || !bytes.Equal(pt, aPt) {
+			t.Fatalf("Failed decrypting state [%s != %s]: %v", string(pt), string(aPt), err)
+		}
+		seThree, err := validator.GetStateEncryptor(deployTx, executeTxs[2])
+		if err != nil {
+			t.Fatalf("Failed creating state encryptor [%s].", err)
+		}
+		aPt, err := seThree.Decrypt(ct)
+		if err != nil || !bytes.Equal(pt, aPt) {
+			t.Fatalf("Failed decrypting state [%s != %s]: %v", string(pt), string(aPt), err)
+		}
+		ctQ, err := seThree.Encrypt(aPt)
+		if err != nil {
+			t.Fatalf("Failed encrypting query result [%s].", err)
+		}
+		if aPtQ, err := invoker.DecryptQueryResult(executeTxs[2], ctQ); err != nil || !bytes.Equal(pt, aPtQ) {
+			t.Fatalf("Failed decrypting query result [%s != %s]: %v", string(pt), string(aPtQ), err)
+		}
+	}
+}
+
+func TestValidatorTamperedConfidentialTransaction(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	_, tx, err := createConfidentialExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating invoke transaction [%s]", err)
+	}
+
+	tampered := func(tamper func(tx *obc.Transaction)) *obc.Transaction {
+		clone, err := validator.(*validatorImpl).deepCloneTransaction(tx)
+		if err != nil {
+			t.Fatalf("Failed cloning transaction [%s].", err)
+		}
+		tamper(clone)
+		return clone
+	}
+
+	// The validators have no secret enrollment chain key for version 1.1
+	for _, version := range []string{"1.1", "3.0"} {
+		tx := tampered(func(tx *obc.Transaction) { tx.ConfidentialityProtocolVersion = version })
+		if _, err := validator.TransactionPreExecution(tx); err == nil {
+			t.Fatalf("Pre-execution of a transaction of version [%s] should fail", version)
+		}
+	}
+
+	if tx.ConfidentialityProtocolVersion == "2.0" {
+		// Fields are authenticated with keys bound to their position
+		tx := tampered(func(tx *obc.Transaction) { tx.Payload, tx.ChaincodeID = tx.ChaincodeID, tx.Payload })
+		if _, err := validator.TransactionPreExecution(tx); err == nil {
+			t.Fatal("Pre-execution of a transaction with swapped fields should fail")
+		}
+	}
+}
+
+func TestAuditorDecryptTransaction(t *testing.T) {
+	initNodes()
+	defer closeNodes()
+
+	// auditor1 audits Contract001 and auditor2 Contract002
+	dir, err := ioutil.TempDir("", "auditors")
+	if err != nil {
+		t.Fatalf("Failed creating directory [%s].", err)
+	}
+	defer os.RemoveAll(dir)
+
+	auditors := make(map[string]Auditor)
+	auditorsConf := make(map[string]interface{})
+	for i, id := range []string{"auditor1", "auditor2"} {
+		sk, err := primitives.NewECDSAKey()
+		if err != nil {
+			t.Fatalf("Failed generating auditor key [%s].", err)
+		}
+		skPEM, err := primitives.PrivateKeyToPEM(sk, nil)
+		if err != nil {
+			t.Fatalf("Failed encoding auditor private key [%s].", err)
+		}
+		auditors[id], err = NewAuditor(id, skPEM, nil)
+		if err != nil {
+			t.Fatalf("Failed creating auditor [%s].", err)
+		}
+
+		pkPEM, err := primitives.PublicKeyToPEM(&sk.PublicKey, nil)
+		if err != nil {
+			t.Fatalf("Failed encoding auditor public key [%s].", err)
+		}
+		pkFile := filepath.Join(dir, id+".pub")
+		if err = ioutil.WriteFile(pkFile, pkPEM, 0600); err != nil {
+			t.Fatalf("Failed writing auditor public key [%s].", err)
+		}
+		auditorsConf[id] = map[string]interface{}{
+			"publicKeyFile": pkFile,
+			"chaincodes":    []string{fmt.Sprintf("Contract00%d", i+1)},
+		}
+	}
+
+	client := invoker.(*clientImpl)
+	viper.Set("security.audit.auditors", auditorsConf)
+	defer viper.Set("security.audit.auditors", nil)
+	if err = client.initAuditors(); err != nil {
+		t.Fatalf("Failed loading auditors [%s].", err)
+	}
+	defer func() { client.auditors = nil }()
+
+	for _, version := range []string{"1.2", "2.0"} {
+		cis := &obc.ChaincodeInvocationSpec{
+			ChaincodeSpec: &obc.ChaincodeSpec{
+				Type:                           obc.ChaincodeSpec_GOLANG,
+				ChaincodeID:                    &obc.ChaincodeID{Path: "Contract001"},
+				CtorMsg:                        nil,
+				ConfidentialityLevel:           obc.ConfidentialityLevel_CONFIDENTIAL,
+				ConfidentialityProtocolVersion: version,
+				Metadata:                       []byte("Hello World"),
+			},
+		}
+		uuid := util.GenerateUUID()
+		otx, err := obc.NewChaincodeExecute(cis, uuid, obc.Transaction_CHAINCODE_INVOKE)
+		if err != nil {
+			t.Fatalf("Failed creating execute transaction [%s]", err)
+		}
+		otx.Metadata = cis.ChaincodeSpec.Metadata
+		tx, err := invoker.NewChaincodeExecute(cis, uuid, attrs...)
+		if err != nil {
+			t.Fatalf("Failed creating execute transaction [%s]", err)
+		}
+
+		// Only the auditor of the chaincode gets a key share
+		if len(tx.ToAuditors) != 1 || tx.ToAuditors[0].AuditorID != "auditor1" {
+			t.Fatalf("Expected a single key share for auditor1, got [%v]", tx.ToAuditors)
+		}
+		atx, err := auditors["auditor1"].DecryptTransaction(tx)
+		if err != nil {
+			t.Fatalf("Failed decrypting transaction as auditor [%s].", err)
+		}
+		if err = isEqual(otx, atx); err != nil {
+			t.Fatalf("Decrypted transaction differs from the original: [%s]", err)
+		}
+		if _, err = auditors["auditor2"].DecryptTransaction(tx); err == nil {
+			t.Fatalf("Auditor of another chaincode must not decrypt the transaction")
+		}
+
+		// Key shares are signed with the rest of the transaction
+		if _, err = validator.TransactionPreValidation(tx); err != nil {
+			t.Fatalf("Failed pre-validating transaction [%s].", err)
+		}
+		tampered, err := validator.(*validatorImpl).deepCloneTransaction(tx)
+		if err != nil {
+			t.Fatalf("Failed cloning transaction [%s].", err)
+		}
+		tampered.ToAuditors = nil
+		if _, err = validator.TransactionPreValidation(tampered); err == nil {
+			t.Fatalf("Removing the key shares must invalidate the signature")
+		}
+
+		// Transactions to chaincodes without auditors have no key share
+		cis.ChaincodeSpec.ChaincodeID = &obc.ChaincodeID{Path: "Contract003"}
+		tx, err = invoker.NewChaincodeExecute(cis, util.GenerateUUID(), attrs...)
+		if err != nil {
+			t.Fatalf("Failed creating execute transaction [%s]", err)
+		}
+		if len(tx.ToAuditors) != 0 {
+			t.Fatalf("Expected no key share, got [%v]", tx.ToAuditors)
+		}
+		if _, err = auditors["auditor1"].DecryptTransaction(tx); err == nil {
+			t.Fatalf("Auditor must not decrypt transactions of chaincodes it does not audit")
+		}
+	}
+}
+
 func TestValidatorStateEncryptor(t *testing.T) {
 	initNodes()
 	defer closeNodes()
@@ -19,7 +19,10 @@ package crypto
 import (
 	"errors"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/spf13/viper"
 )
 
@@ -57,12 +60,19 @@ type configuration struct {
 
 	securityLevel                  int
 	hashAlgorithm                  string
+	signatureAlgorithm             string
 	confidentialityProtocolVersion string
 
 	tlsServerName string
 
 	multiThreading bool
 	tCertBatchSize int
+
+	tCertPrefetch     bool
+	tCertLowWaterMark int
+	tCertSplitKey     bool
+
+	enrollmentRenewBefore time.Duration
 }
 
 func (conf *configuration) init() error {
@@ -120,6 +130,16 @@ func (conf *configuration) init() error {
 		}
 	}
 
+	conf.signatureAlgorithm = ""
+	if viper.IsSet("security.signatureAlgorithm") {
+		conf.signatureAlgorithm = viper.GetString("security.signatureAlgorithm")
+	}
+	if conf.signatureAlgorithm != "" {
+		if _, err := primitives.GetSignatureSuite(conf.signatureAlgorithm); err != nil {
+			return err
+		}
+	}
+
 	conf.confidentialityProtocolVersion = "1.2"
 	if viper.IsSet("security.confidentialityProtocolVersion") {
 		ovveride := viper.GetString("security.confidentialityProtocolVersion")
@@ -127,6 +147,9 @@ func (conf *configuration) init() error {
 			conf.confidentialityProtocolVersion = ovveride
 		}
 	}
+	if !isConfidentialityProtocolVersionSupported(conf.confidentialityProtocolVersion) {
+		return errors.New("Invalid confidentiality protocol version " + conf.confidentialityProtocolVersion + ". Supported versions are " + strings.Join(confidentialityProtocolVersions, ", "))
+	}
 
 	// Set TLS host override
 	conf.tlsServerName = "tlsca"
@@ -146,6 +169,33 @@ func (conf *configuration) init() error {
 		}
 	}
 
+	// Set TCert prefetching
+	conf.tCertPrefetch = false
+	if viper.IsSet("security.tcert.prefetch.enabled") {
+		conf.tCertPrefetch = viper.GetBool("security.tcert.prefetch.enabled")
+	}
+
+	// Set tCertLowWaterMark
+	conf.tCertLowWaterMark = conf.tCertBatchSize / 4
+	if viper.IsSet("security.tcert.prefetch.lowWaterMark") {
+		ovveride := viper.GetInt("security.tcert.prefetch.lowWaterMark")
+		if ovveride != 0 {
+			conf.tCertLowWaterMark = ovveride
+		}
+	}
+	if conf.tCertLowWaterMark < 1 {
+		conf.tCertLowWaterMark = 1
+	}
+
+	// Set TCert derivation, split-key TCerts are requested with key shares
+	conf.tCertSplitKey = viper.GetString("security.tcert.derivation") == "split"
+
+	// Set the enrollment certificate renewal period, 0 disables renewal
+	conf.enrollmentRenewBefore = 30 * 24 * time.Hour
+	if viper.IsSet("security.enrollment.renewBefore") {
+		conf.enrollmentRenewBefore = viper.GetDuration("security.enrollment.renewBefore")
+	}
+
 	// Set multithread
 	conf.multiThreading = false
 	if viper.IsSet("security.multithreading.enabled") {
@@ -155,6 +205,20 @@ func (conf *configuration) init() error {
 	return nil
 }
 
+// confidentialityProtocolVersions are the confidentiality protocol versions
+// transactions can be encrypted with. Version 1.1 is only decrypted, by nodes
+// holding a secret enrollment chain key.
+var confidentialityProtocolVersions = []string{"1.2", "2.0"}
+
+func isConfidentialityProtocolVersionSupported(version string) bool {
+	for _, v := range confidentialityProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
 func (conf *configuration) checkProperty(property string) error {
 	res := viper.GetString(property)
 	if res == "" {
@@ -215,6 +279,21 @@ func (conf *configuration) getEnrollmentKeyFilename() string {
 	return "enrollment.key"
 }
 
+// getEnrollmentKeyLabel returns the label of the enrollment key when it is
+// held by a key provider, which may hold the keys of several nodes
+func (conf *configuration) getEnrollmentKeyLabel() string {
+	return conf.name + "." + conf.getEnrollmentKeyFilename()
+}
+
+// getKeyProviderFor returns the name of the key provider holding the keys of
+// keyType, or the empty string if they are in the keystore
+func (conf *configuration) getKeyProviderFor(keyType string) string {
+	if viper.GetBool("security.pkcs11.enabled") && viper.GetBool("security.pkcs11.keys."+keyType) {
+		return "pkcs11"
+	}
+	return ""
+}
+
 func (conf *configuration) getEnrollmentCertFilename() string {
 	return "enrollment.cert"
 }
@@ -291,6 +370,30 @@ func (conf *configuration) getTCertBatchSize() int {
 	return conf.tCertBatchSize
 }
 
+func (conf *configuration) getTCertBlindingKeyFilename() string {
+	return "tcert.blinding.key"
+}
+
+func (conf *configuration) IsTCertSplitKeyEnabled() bool {
+	return conf.tCertSplitKey
+}
+
+func (conf *configuration) IsTCertPrefetchEnabled() bool {
+	return conf.tCertPrefetch
+}
+
+func (conf *configuration) getTCertLowWaterMark() int {
+	return conf.tCertLowWaterMark
+}
+
+func (conf *configuration) getSignatureAlgorithm() string {
+	return conf.signatureAlgorithm
+}
+
+func (conf *configuration) getEnrollmentRenewBefore() time.Duration {
+	return conf.enrollmentRenewBefore
+}
+
 func (conf *configuration) GetConfidentialityProtocolVersion() string {
 	return conf.confidentialityProtocolVersion
 }
@@ -115,6 +115,11 @@ func (node *nodeImpl) initCryptoEngine() error {
 		return err
 	}
 
+	// Renew the enrollment certificate ahead of its expiry
+	if err := node.renewEnrollmentCertificateIfExpiring(); err != nil {
+		return err
+	}
+
 	node.Debug("Initializing node crypto engine...done!")
 
 	return nil
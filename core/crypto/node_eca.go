@@ -17,8 +17,9 @@ limitations under the License.
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/rand"
+	"crypto/ed25519"
 	"crypto/x509"
 	"google/protobuf"
 	"time"
@@ -32,6 +33,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
+	"github.com/hyperledger/fabric/core/util"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
@@ -87,16 +89,19 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 	node.Debugf("Storing enrollment data for user [%s]...", enrollID)
 
 	// Store enrollment id
-	err = ioutil.WriteFile(node.conf.getEnrollmentIDPath(), []byte(enrollID), 0700)
+	err = util.SaveToDiskAtomic(node.conf.getEnrollmentIDPath(), []byte(enrollID), 0700)
 	if err != nil {
 		node.Errorf("Failed storing enrollment certificate [id=%s]: [%s]", enrollID, err)
 		return err
 	}
 
-	// Store enrollment key
-	if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), key); err != nil {
-		node.Errorf("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
-		return err
+	// Store enrollment key, unless it is held by a key provider
+	switch key.(type) {
+	case *ecdsa.PrivateKey, ed25519.PrivateKey:
+		if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), key); err != nil {
+			node.Errorf("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
+			return err
+		}
 	}
 
 	// Store enrollment cert
@@ -148,9 +153,41 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 	return nil
 }
 
+// getEnrollmentKeyProvider returns the key provider holding the enrollment
+// key, nil if the enrollment key is in the keystore. Clients derive the keys
+// of their TCerts from their enrollment key, which they must hold.
+func (node *nodeImpl) getEnrollmentKeyProvider() (primitives.KeyProvider, error) {
+	name := node.conf.getKeyProviderFor("enrollment")
+	if name == "" {
+		return nil, nil
+	}
+	if node.eType == NodeClient {
+		return nil, errors.New("Clients derive the TCert keys from the enrollment key, which cannot be held by a key provider.")
+	}
+	return primitives.GetKeyProvider(name)
+}
+
 func (node *nodeImpl) loadEnrollmentKey() error {
 	node.Debug("Loading enrollment key...")
 
+	provider, err := node.getEnrollmentKeyProvider()
+	if err != nil {
+		node.Errorf("Failed getting enrollment key provider [%s].", err.Error())
+
+		return err
+	}
+	if provider != nil {
+		signer, err := provider.GetSigner(node.conf.getEnrollmentKeyLabel())
+		if err != nil {
+			node.Errorf("Failed loading enrollment private key [%s].", err.Error())
+
+			return err
+		}
+		node.enrollSigner = signer
+
+		return nil
+	}
+
 	enrollPrivKey, err := node.ks.loadPrivateKey(node.conf.getEnrollmentKeyFilename())
 	if err != nil {
 		node.Errorf("Failed loading enrollment private key [%s].", err.Error())
@@ -158,7 +195,17 @@ func (node *nodeImpl) loadEnrollmentKey() error {
 		return err
 	}
 
-	node.enrollPrivKey = enrollPrivKey.(*ecdsa.PrivateKey)
+	switch key := enrollPrivKey.(type) {
+	case *ecdsa.PrivateKey:
+		node.enrollPrivKey = key
+		node.enrollSigner = key
+	case ed25519.PrivateKey:
+		node.enrollSigner = key
+	default:
+		node.Errorf("Failed loading enrollment private key: unsupported key type [%T].", enrollPrivKey)
+
+		return primitives.ErrInvalidSecretKeyType
+	}
 
 	return nil
 }
@@ -175,8 +222,7 @@ func (node *nodeImpl) loadEnrollmentCertificate() error {
 	node.enrollCert = cert
 
 	// TODO: move this to retrieve
-	pk := node.enrollCert.PublicKey.(*ecdsa.PublicKey)
-	err = primitives.VerifySignCapability(node.enrollPrivKey, pk)
+	err = primitives.VerifySignCapability(node.enrollSigner, node.enrollCert.PublicKey)
 	if err != nil {
 		node.Errorf("Failed checking enrollment certificate against enrollment key [%s].", err.Error())
 
@@ -245,6 +291,17 @@ func (node *nodeImpl) loadEnrollmentChainKey() error {
 	return nil
 }
 
+// getEnrollmentChainSecretKey returns the secret enrollment chain key used by
+// the confidentiality protocol version 1.1. The ECA issues a chain key pair
+// instead since version 1.2, in which case an error is returned.
+func (node *nodeImpl) getEnrollmentChainSecretKey() ([]byte, error) {
+	enrollChainKey, ok := node.enrollChainKey.([]byte)
+	if !ok {
+		return nil, errors.New("No secret enrollment chain key for confidentiality protocol version 1.1.")
+	}
+	return enrollChainKey, nil
+}
+
 func (node *nodeImpl) loadECACertsChain() error {
 	node.Debug("Loading ECA certificates chain...")
 
@@ -335,19 +392,47 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 
 	// Run the protocol
 
-	signPriv, err := primitives.NewECDSAKey()
+	suite, err := node.getSignatureSuite()
+	if err != nil {
+		node.Errorf("Failed getting signature suite [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+
+	var signPriv crypto.Signer
+	provider, err := node.getEnrollmentKeyProvider()
 	if err != nil {
-		node.Errorf("Failed generating ECDSA key [%s].", err.Error())
+		node.Errorf("Failed getting enrollment key provider [%s].", err.Error())
 
 		return nil, nil, nil, err
 	}
-	signPub, err := x509.MarshalPKIXPublicKey(&signPriv.PublicKey)
+	if provider != nil {
+		if !primitives.IsECDSASignatureSuite(suite) {
+			node.Errorf("Key providers only support ECDSA enrollment keys, not [%s].", suite.Name())
+
+			return nil, nil, nil, errors.New("Key providers only support ECDSA enrollment keys")
+		}
+		signPriv, err = provider.GenerateECDSAKey(node.conf.getEnrollmentKeyLabel())
+	} else {
+		signPriv, err = suite.GenerateKey()
+	}
+	if err != nil {
+		node.Errorf("Failed generating %s key [%s].", suite.Name(), err.Error())
+
+		return nil, nil, nil, err
+	}
+	signPub, err := x509.MarshalPKIXPublicKey(signPriv.Public())
 	if err != nil {
 		node.Errorf("Failed mashalling ECDSA key [%s].", err.Error())
 
 		return nil, nil, nil, err
 	}
 
+	signType := membersrvc.CryptoType_ECDSA
+	if !primitives.IsECDSASignatureSuite(suite) {
+		signType = membersrvc.CryptoType_ED25519
+	}
+
 	encPriv, err := primitives.NewECDSAKey()
 	if err != nil {
 		node.Errorf("Failed generating Encryption key [%s].", err.Error())
@@ -365,7 +450,7 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
 		Id:   &membersrvc.Identity{Id: id},
 		Tok:  &membersrvc.Token{Tok: []byte(pw)},
-		Sign: &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: signPub},
+		Sign: &membersrvc.PublicKey{Type: signType, Key: signPub},
 		Enc:  &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: encPub},
 		Sig:  nil}
 
@@ -405,19 +490,13 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	req.Tok.Tok = out
 	req.Sig = nil
 
-	hash := primitives.NewHash()
 	raw, _ := proto.Marshal(req)
-	hash.Write(raw)
-
-	r, s, err := ecdsa.Sign(rand.Reader, signPriv, hash.Sum(nil))
+	req.Sig, err = node.newSignature(signPriv, raw)
 	if err != nil {
 		node.Errorf("Failed signing [%s].", err.Error())
 
 		return nil, nil, nil, err
 	}
-	R, _ := r.MarshalText()
-	S, _ := s.MarshalText()
-	req.Sig = &membersrvc.Signature{Type: membersrvc.CryptoType_ECDSA, R: R, S: S}
 
 	resp, err = ecaP.CreateCertificatePair(context.Background(), req)
 	if err != nil {
@@ -445,6 +524,13 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 		return nil, nil, nil, err
 	}
 
+	certSuite, err := primitives.GetSignatureSuiteOf(x509SignCert.PublicKey)
+	if err != nil || certSuite.Name() != suite.Name() {
+		node.Errorf("Enrollment certificate for signing does not carry a %s key", suite.Name())
+
+		return nil, nil, nil, primitives.ErrInvalidPublicKeyType
+	}
+
 	err = primitives.CheckCertAgainstSKAndRoot(x509SignCert, signPriv, node.ecaCertPool)
 	if err != nil {
 		node.Errorf("Failed checking signing enrollment certificate for signing: [%s]", err)
@@ -479,6 +565,111 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	return signPriv, resp.Certs.Sign, resp.Pkchain, nil
 }
 
+// renewEnrollmentCertificateIfExpiring renews the enrollment certificate if
+// it expires within the configured renewal period. The node keeps using its
+// certificate if the renewal fails while it is still valid.
+func (node *nodeImpl) renewEnrollmentCertificateIfExpiring() error {
+	renewBefore := node.conf.getEnrollmentRenewBefore()
+	if renewBefore <= 0 || time.Now().Add(renewBefore).Before(node.enrollCert.NotAfter) {
+		return nil
+	}
+
+	node.Infof("Enrollment certificate expires at [%s], renewing...", node.enrollCert.NotAfter)
+	if err := node.renewEnrollmentCertificate(); err != nil {
+		if time.Now().After(node.enrollCert.NotAfter) {
+			node.Errorf("Failed renewing expired enrollment certificate [%s].", err.Error())
+
+			return err
+		}
+		node.Warningf("Failed renewing enrollment certificate, retrying at next start [%s].", err.Error())
+
+		return nil
+	}
+	node.Infof("Enrollment certificate renewed, expires at [%s].", node.enrollCert.NotAfter)
+
+	return nil
+}
+
+// renewEnrollmentCertificate obtains from the ECA a fresh enrollment
+// certificate for the enrollment key, and stores it in place of the current
+// one, whose key signs the request.
+func (node *nodeImpl) renewEnrollmentCertificate() error {
+	// Get a new ECA Client
+	sock, ecaP, err := node.getECAClient()
+	defer sock.Close()
+
+	// The encryption key is only certified, as at enrollment
+	encPriv, err := primitives.NewECDSAKey()
+	if err != nil {
+		node.Errorf("Failed generating Encryption key [%s].", err.Error())
+
+		return err
+	}
+	encPub, err := x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+	if err != nil {
+		node.Errorf("Failed marshalling Encryption key [%s].", err.Error())
+
+		return err
+	}
+
+	req := &membersrvc.ECertRenewReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:   &membersrvc.Identity{Id: node.enrollID},
+		Cert: &membersrvc.Cert{Cert: node.enrollCert.Raw},
+		Enc:  &membersrvc.PublicKey{Type: membersrvc.CryptoType_ECDSA, Key: encPub},
+		Sig:  nil}
+
+	raw, _ := proto.Marshal(req)
+	req.Sig, err = node.newSignature(node.enrollSigner, raw)
+	if err != nil {
+		node.Errorf("Failed signing [%s].", err.Error())
+
+		return err
+	}
+
+	resp, err := ecaP.RenewCertificatePair(context.Background(), req)
+	if err != nil {
+		node.Errorf("Failed invoking RenewCertificatePair [%s].", err.Error())
+
+		return err
+	}
+
+	if resp.FetchResult != nil && resp.FetchResult.Status != membersrvc.FetchAttrsResult_SUCCESS {
+		node.Warning(resp.FetchResult.Msg)
+	}
+
+	// Verify response
+	x509SignCert, err := primitives.DERToX509Certificate(resp.Certs.Sign)
+	if err != nil {
+		node.Errorf("Failed parsing renewed enrollment certificate for signing: [%s]", err)
+
+		return err
+	}
+
+	_, err = primitives.GetCriticalExtension(x509SignCert, ECertSubjectRole)
+	if err != nil {
+		node.Errorf("Failed parsing ECertSubjectRole in renewed enrollment certificate for signing: [%s]", err)
+
+		return err
+	}
+
+	err = primitives.CheckCertAgainstSKAndRoot(x509SignCert, node.enrollSigner, node.ecaCertPool)
+	if err != nil {
+		node.Errorf("Failed checking renewed enrollment certificate for signing: [%s]", err)
+
+		return err
+	}
+
+	// Store and load the renewed enrollment cert
+	if err := node.ks.storeCert(node.conf.getEnrollmentCertFilename(), resp.Certs.Sign); err != nil {
+		node.Errorf("Failed storing renewed enrollment certificate [id=%s]: [%s]", node.enrollID, err)
+
+		return err
+	}
+
+	return node.loadEnrollmentCertificate()
+}
+
 func (node *nodeImpl) getECACertificate() ([]byte, error) {
 	responce, err := node.callECAReadCACertificate(context.Background())
 	if err != nil {
@@ -17,6 +17,7 @@ limitations under the License.
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/x509"
 
@@ -47,10 +48,13 @@ type nodeImpl struct {
 	// 48-bytes identifier
 	id []byte
 
-	// Enrollment Certificate and private key
+	// Enrollment Certificate and private key. The private key is nil when it
+	// is held by a key provider or is not an ECDSA key, enrollSigner signs in
+	// all cases.
 	enrollID       string
 	enrollCert     *x509.Certificate
 	enrollPrivKey  *ecdsa.PrivateKey
+	enrollSigner   crypto.Signer
 	enrollCertHash []byte
 
 	// Enrollment Chain
@@ -25,6 +25,7 @@ import (
 	"sync"
 
 	"github.com/hyperledger/fabric/core/crypto/utils"
+	"github.com/hyperledger/fabric/core/util"
 
 	// Required to successfully initialized the driver
 	"github.com/hyperledger/fabric/core/crypto/primitives"
@@ -124,7 +125,7 @@ func (ks *keyStore) storePrivateKey(alias string, privateKey interface{}) error
 		return err
 	}
 
-	err = ioutil.WriteFile(ks.node.conf.getPathForAlias(alias), rawKey, 0700)
+	err = util.SaveToDiskAtomic(ks.node.conf.getPathForAlias(alias), rawKey, 0700)
 	if err != nil {
 		ks.node.Errorf("Failed storing private key [%s]: [%s]", alias, err)
 		return err
@@ -140,7 +141,7 @@ func (ks *keyStore) storePrivateKeyInClear(alias string, privateKey interface{})
 		return err
 	}
 
-	err = ioutil.WriteFile(ks.node.conf.getPathForAlias(alias), rawKey, 0700)
+	err = util.SaveToDiskAtomic(ks.node.conf.getPathForAlias(alias), rawKey, 0700)
 	if err != nil {
 		ks.node.Errorf("Failed storing private key [%s]: [%s]", alias, err)
 		return err
@@ -177,7 +178,7 @@ func (ks *keyStore) storePublicKey(alias string, publicKey interface{}) error {
 		return err
 	}
 
-	err = ioutil.WriteFile(ks.node.conf.getPathForAlias(alias), rawKey, 0700)
+	err = util.SaveToDiskAtomic(ks.node.conf.getPathForAlias(alias), rawKey, 0700)
 	if err != nil {
 		ks.node.Errorf("Failed storing private key [%s]: [%s]", alias, err)
 		return err
@@ -214,7 +215,7 @@ func (ks *keyStore) storeKey(alias string, key []byte) error {
 		return err
 	}
 
-	err = ioutil.WriteFile(ks.node.conf.getPathForAlias(alias), pem, 0700)
+	err = util.SaveToDiskAtomic(ks.node.conf.getPathForAlias(alias), pem, 0700)
 	if err != nil {
 		ks.node.Errorf("Failed storing key [%s]: [%s]", alias, err)
 		return err
@@ -245,7 +246,7 @@ func (ks *keyStore) loadKey(alias string) ([]byte, error) {
 }
 
 func (ks *keyStore) storeCert(alias string, der []byte) error {
-	err := ioutil.WriteFile(ks.node.conf.getPathForAlias(alias), primitives.DERCertToPEM(der), 0700)
+	err := util.SaveToDiskAtomic(ks.node.conf.getPathForAlias(alias), primitives.DERCertToPEM(der), 0700)
 	if err != nil {
 		ks.node.Errorf("Failed storing certificate [%s]: [%s]", alias, err)
 		return err
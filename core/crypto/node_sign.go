@@ -17,27 +17,56 @@ limitations under the License.
 package crypto
 
 import (
-	"math/big"
+	"crypto"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
 )
 
+// getSignatureSuite returns the signature suite of the enrollment key. TCerts
+// are derived from the enrollment key of clients, which is therefore always an
+// ECDSA key.
+func (node *nodeImpl) getSignatureSuite() (primitives.SignatureSuite, error) {
+	suite, err := primitives.GetSignatureSuite(node.conf.getSignatureAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+	if node.eType == NodeClient && !primitives.IsECDSASignatureSuite(suite) {
+		return primitives.GetSignatureSuite("")
+	}
+	return suite, nil
+}
+
 func (node *nodeImpl) sign(signKey interface{}, msg []byte) ([]byte, error) {
-	return primitives.ECDSASign(signKey, msg)
+	return primitives.Sign(signKey, msg)
 }
 
 func (node *nodeImpl) signWithEnrollmentKey(msg []byte) ([]byte, error) {
-	return primitives.ECDSASign(node.enrollPrivKey, msg)
+	return primitives.Sign(node.enrollSigner, msg)
 }
 
-func (node *nodeImpl) ecdsaSignWithEnrollmentKey(msg []byte) (*big.Int, *big.Int, error) {
-	return primitives.ECDSASignDirect(node.enrollPrivKey, msg)
+// newSignature signs msg with signKey as the membership services expect
+func (node *nodeImpl) newSignature(signKey crypto.Signer, msg []byte) (*membersrvc.Signature, error) {
+	cryptoType := membersrvc.CryptoType_ECDSA
+	suite, err := primitives.GetSignatureSuiteOf(signKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if !primitives.IsECDSASignatureSuite(suite) {
+		cryptoType = membersrvc.CryptoType_ED25519
+	}
+
+	R, S, err := primitives.SignDirect(signKey, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &membersrvc.Signature{Type: cryptoType, R: R, S: S}, nil
 }
 
 func (node *nodeImpl) verify(verKey interface{}, msg, signature []byte) (bool, error) {
-	return primitives.ECDSAVerify(verKey, msg, signature)
+	return primitives.Verify(verKey, msg, signature)
 }
 
 func (node *nodeImpl) verifyWithEnrollmentCert(msg, signature []byte) (bool, error) {
-	return primitives.ECDSAVerify(node.enrollCert.PublicKey, msg, signature)
+	return primitives.Verify(node.enrollCert.PublicKey, msg, signature)
 }
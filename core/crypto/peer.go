@@ -129,7 +129,7 @@ func CloseAllPeers() (bool, []error) {
 // Private Methods
 
 func newPeer() *peerImpl {
-	return &peerImpl{&nodeImpl{}, sync.RWMutex{}, nil, false}
+	return &peerImpl{&nodeImpl{}, sync.RWMutex{}, nil, revocationList{}, nil, false}
 }
 
 func closePeerInternal(peer Peer, force bool) error {
@@ -0,0 +1,160 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	"github.com/hyperledger/fabric/core/ledger"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// The ECA revokes enrollment certificates by publishing the certificate
+// revocation list it signs in a PKI_CRL_UPDATE transaction. Executing the
+// transaction stores the list in the state, from where the peers read it to
+// reject the transactions and the messages signed with a revoked certificate.
+// Transaction certificates do not carry the enrollment certificate they derive
+// from in the clear, so the list also revokes the unexpired transaction
+// certificates the TCA issued for a revoked enrollment certificate.
+const (
+	// RevocationListNamespace is the state namespace of the revocation list.
+	// Deployed chaincodes are named by the hex hash of their package, so it
+	// does not clash with the state of a chaincode.
+	RevocationListNamespace = "pki.revocations"
+
+	// RevocationListKey is the key of the revocation list of the ECA
+	RevocationListKey = "eca"
+)
+
+// revocationList caches the serial numbers of the certificates revoked by the
+// revocation list last read from the state
+type revocationList struct {
+	sync.Mutex
+	raw     []byte
+	serials map[string]bool
+}
+
+func revocationCheckEnabled() bool {
+	return viper.GetBool("security.revocation.enabled")
+}
+
+// readCommittedRevocationList returns the revocation list committed to the
+// ledger, nil if the ECA did not publish any
+func readCommittedRevocationList() ([]byte, error) {
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	return ledger.GetState(RevocationListNamespace, RevocationListKey, true)
+}
+
+// parseRevocationList parses the DER encoded revocation list and checks that
+// it is signed by the ECA
+func (peer *peerImpl) parseRevocationList(raw []byte) (*pkix.CertificateList, error) {
+	crl, err := x509.ParseCRL(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing revocation list: %s", err)
+	}
+	ecaCert, _, err := peer.ks.loadCertX509AndDer(peer.conf.getECACertsChainFilename())
+	if err != nil {
+		return nil, fmt.Errorf("Failed loading ECA certificate: %s", err)
+	}
+	if err := ecaCert.CheckCRLSignature(crl); err != nil {
+		return nil, fmt.Errorf("Failed verifying the signature of the revocation list against the ECA certificate: %s", err)
+	}
+	return crl, nil
+}
+
+// verifyRevocationListTransaction checks that the revocation list of a
+// PKI_CRL_UPDATE transaction is signed by the ECA and more recent than the
+// committed one, so that an older list cannot be replayed to reinstate the
+// certificates revoked since.
+func (peer *peerImpl) verifyRevocationListTransaction(tx *obc.Transaction) error {
+	revocationList := &obc.RevocationList{}
+	if err := proto.Unmarshal(tx.Payload, revocationList); err != nil {
+		return fmt.Errorf("Failed unmarshalling revocation list: %s", err)
+	}
+	crl, err := peer.parseRevocationList(revocationList.Crl)
+	if err != nil {
+		return err
+	}
+
+	current, err := peer.readRevocationList()
+	if err != nil {
+		return fmt.Errorf("Failed reading the current revocation list: %s", err)
+	}
+	if current == nil {
+		return nil
+	}
+	currentCRL, err := x509.ParseCRL(current)
+	if err != nil {
+		return fmt.Errorf("Failed parsing the current revocation list: %s", err)
+	}
+	if !crl.TBSCertList.ThisUpdate.After(currentCRL.TBSCertList.ThisUpdate) {
+		return fmt.Errorf("Revocation list of %s is not more recent than the current one, of %s", crl.TBSCertList.ThisUpdate, currentCRL.TBSCertList.ThisUpdate)
+	}
+	return nil
+}
+
+// checkRevocation returns utils.ErrCertificateRevoked if cert is listed in
+// the committed revocation list
+func (peer *peerImpl) checkRevocation(cert *x509.Certificate) error {
+	if !revocationCheckEnabled() {
+		return nil
+	}
+
+	raw, err := peer.readRevocationList()
+	if err != nil {
+		peer.Errorf("Failed reading the revocation list: [%s]", err)
+
+		return err
+	}
+
+	peer.revocationList.Lock()
+	defer peer.revocationList.Unlock()
+	if peer.revocationList.serials == nil || !bytes.Equal(raw, peer.revocationList.raw) {
+		serials := make(map[string]bool)
+		if raw != nil {
+			crl, err := peer.parseRevocationList(raw)
+			if err != nil {
+				peer.Errorf("Failed parsing the revocation list: [%s]", err)
+
+				return err
+			}
+			for _, revoked := range crl.TBSCertList.RevokedCertificates {
+				serials[revoked.SerialNumber.String()] = true
+			}
+		}
+		peer.revocationList.raw = raw
+		peer.revocationList.serials = serials
+	}
+
+	if peer.revocationList.serials[cert.SerialNumber.String()] {
+		peer.Errorf("Certificate [%s] is revoked", cert.SerialNumber)
+
+		return utils.ErrCertificateRevoked
+	}
+	return nil
+}
@@ -38,6 +38,9 @@ func (peer *peerImpl) getEnrollmentCert(id []byte) (*x509.Certificate, error) {
 
 	if cert := peer.getNodeEnrollmentCertificate(sid); cert != nil {
 		peer.Debugf("Enrollment certificate for [%s] already in memory.", sid)
+		if err := peer.checkRevocation(cert); err != nil {
+			return nil, err
+		}
 		return cert, nil
 	}
 
@@ -59,6 +62,9 @@ func (peer *peerImpl) getEnrollmentCert(id []byte) (*x509.Certificate, error) {
 
 	peer.putNodeEnrollmentCertificate(sid, cert)
 
+	if err := peer.checkRevocation(cert); err != nil {
+		return nil, err
+	}
 	return cert, nil
 }
 
@@ -17,12 +17,11 @@ limitations under the License.
 package crypto
 
 import (
-	"crypto/ecdsa"
 	"crypto/x509"
 	"fmt"
+	"strings"
 	"sync"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
@@ -34,6 +33,10 @@ type peerImpl struct {
 	nodeEnrollmentCertificatesMutex sync.RWMutex
 	nodeEnrollmentCertificates      map[string]*x509.Certificate
 
+	// Revocation list of the ECA, read from the state
+	revocationList     revocationList
+	readRevocationList func() ([]byte, error)
+
 	isInitialized bool
 }
 
@@ -60,6 +63,15 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 	//	peer.debug("Pre validating [%s].", tx.String())
 	peer.Debugf("Tx confdential level [%s].", tx.ConfidentialityLevel.String())
 
+	// The revocation list is signed by the ECA rather than by the submitter
+	if tx.Type == obc.Transaction_PKI_CRL_UPDATE {
+		if err := peer.verifyRevocationListTransaction(tx); err != nil {
+			peer.Errorf("TransactionPreValidation: invalid revocation list [%s].", err.Error())
+			return tx, err
+		}
+		return tx, nil
+	}
+
 	if tx.Cert != nil && tx.Signature != nil {
 		// Verify the transaction
 		// 1. Unmarshal cert
@@ -69,17 +81,26 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 			return tx, err
 		}
 
-		// TODO: verify cert
+		// Split-key TCerts can not be traced back to an enrollment
+		// certificate, verify at least that they are issued by the TCA
+		if _, err := primitives.GetCriticalExtension(cert, primitives.TCertSplitKeyNonce); err == nil {
+			if _, err := primitives.CheckCertAgainRoot(cert, peer.tcaCertPool); err != nil {
+				peer.Errorf("TransactionPreValidation: split-key TCert not issued by the TCA [%s].", err.Error())
+				return tx, err
+			}
+		}
+
+		// Verify cert was not revoked
+		if err := peer.checkRevocation(cert); err != nil {
+			return tx, err
+		}
 
-		// 3. Marshall tx without signature
-		signature := tx.Signature
-		tx.Signature = nil
-		rawTx, err := proto.Marshal(tx)
+		// 3. Marshall tx without signatures
+		rawTx, err := getSignedTransactionBytes(tx)
 		if err != nil {
 			peer.Errorf("TransactionPreExecution: failed marshaling tx [%s].", err.Error())
 			return tx, err
 		}
-		tx.Signature = signature
 
 		// 2. Verify signature
 		ok, err := peer.verify(cert.PublicKey, rawTx, tx.Signature)
@@ -91,6 +112,14 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 		if !ok {
 			return tx, utils.ErrInvalidTransactionSignature
 		}
+
+		// 4. Verify the co-signers satisfy the multi-signature policy
+		if tx.MultiSignature != nil {
+			if err := peer.verifyMultiSignature(tx); err != nil {
+				peer.Errorf("TransactionPreValidation: invalid multi-signature [%s].", err.Error())
+				return tx, err
+			}
+		}
 	} else {
 		if tx.Cert == nil {
 			return tx, utils.ErrTransactionCertificate
@@ -139,7 +168,7 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
-	vk := cert.PublicKey.(*ecdsa.PublicKey)
+	vk := cert.PublicKey
 
 	ok, err := peer.verify(vk, message, signature)
 	if err != nil {
@@ -157,6 +186,56 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 	return nil
 }
 
+// VerifyEnrollmentSignature checks that cert is an enrollment certificate
+// issued by the ECA and that signature is a valid signature of message under
+// its verification key. It returns the enrollment ID of the certificate if the
+// verification succeeded.
+func (peer *peerImpl) VerifyEnrollmentSignature(cert, signature, message []byte) (string, error) {
+	if !peer.isInitialized {
+		return "", utils.ErrNotInitialized
+	}
+	if len(signature) == 0 {
+		return "", fmt.Errorf("Invalid signature. It is empty.")
+	}
+
+	x509Cert, err := primitives.DERToX509Certificate(cert)
+	if err != nil {
+		peer.Errorf("Failed parsing enrollment certificate: [%s]", err)
+
+		return "", err
+	}
+	// The role marks enrollment certificates, it must be handled before verifying the chain
+	if _, err = primitives.GetCriticalExtension(x509Cert, ECertSubjectRole); err != nil {
+		peer.Errorf("Failed parsing ECertSubjectRole in enrollment certificate: [%s]", err)
+
+		return "", err
+	}
+	if _, err = primitives.CheckCertAgainRoot(x509Cert, peer.ecaCertPool); err != nil {
+		peer.Errorf("Failed checking enrollment certificate against the ECA root: [%s]", err)
+
+		return "", err
+	}
+	if err = peer.checkRevocation(x509Cert); err != nil {
+		return "", err
+	}
+
+	ok, err := peer.verify(x509Cert.PublicKey, message, signature)
+	if err != nil {
+		peer.Errorf("Failed verifying signature of [%s]: [%s]", x509Cert.Subject.CommonName, err)
+
+		return "", err
+	}
+	if !ok {
+		peer.Errorf("Failed invalid signature of [%s]", x509Cert.Subject.CommonName)
+
+		return "", utils.ErrInvalidSignature
+	}
+
+	// The common name of an enrollment certificate is the enrollment ID
+	// followed by the affiliation and the role, separated by backslashes
+	return strings.SplitN(x509Cert.Subject.CommonName, "\\", 2)[0], nil
+}
+
 func (peer *peerImpl) GetStateEncryptor(deployTx, invokeTx *obc.Transaction) (StateEncryptor, error) {
 	return nil, utils.ErrNotImplemented
 }
@@ -212,6 +291,7 @@ func (peer *peerImpl) init(eType NodeType, id string, pwd []byte) error {
 
 	// EnrollCerts
 	peer.nodeEnrollmentCertificates = make(map[string]*x509.Certificate)
+	peer.readRevocationList = readCommittedRevocationList
 
 	return nil
 }
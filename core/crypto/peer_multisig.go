@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// getSignedTransactionBytes returns the bytes the submitter and the co-signers
+// of tx sign, which are tx without its signature, without the signatures of
+// the co-signers and without its trace context. The policy of the co-signers
+// is signed.
+func getSignedTransactionBytes(tx *obc.Transaction) ([]byte, error) {
+	unsigned := *tx
+	unsigned.Signature = nil
+	unsigned.TraceContext = nil
+	if tx.MultiSignature != nil {
+		unsigned.MultiSignature = &obc.MultiSignature{Policy: tx.MultiSignature.Policy}
+	}
+	return proto.Marshal(&unsigned)
+}
+
+// verifyMultiSignature checks that the co-signers of tx satisfy its policy:
+// each signature must be a valid signature of an enrollment certificate
+// listed by the policy and not revoked, and at least threshold distinct
+// listed certificates must have signed.
+func (peer *peerImpl) verifyMultiSignature(tx *obc.Transaction) error {
+	policy := tx.MultiSignature.GetPolicy()
+	if policy == nil {
+		return utils.ErrInvalidMultiSignaturePolicy
+	}
+	if policy.Threshold == 0 || int(policy.Threshold) > len(policy.CertHashes) {
+		peer.Errorf("Invalid multi-signature policy, threshold %d of %d certificates.", policy.Threshold, len(policy.CertHashes))
+
+		return utils.ErrInvalidMultiSignaturePolicy
+	}
+	listed := make(map[string]bool)
+	for _, certHash := range policy.CertHashes {
+		listed[string(certHash)] = true
+	}
+
+	rawTx, err := getSignedTransactionBytes(tx)
+	if err != nil {
+		peer.Errorf("Failed marshaling tx [%s].", err.Error())
+
+		return err
+	}
+
+	signed := make(map[string]bool)
+	for _, signature := range tx.MultiSignature.Signatures {
+		certHash := string(primitives.Hash(signature.Cert))
+		if !listed[certHash] {
+			return errors.New("Invalid co-signer. Its certificate is not listed by the multi-signature policy.")
+		}
+		if signed[certHash] {
+			return errors.New("Invalid co-signer. It signed the transaction more than once.")
+		}
+		if _, err := peer.VerifyEnrollmentSignature(signature.Cert, signature.Signature, rawTx); err != nil {
+			return fmt.Errorf("Invalid co-signer signature [%s]", err)
+		}
+		signed[certHash] = true
+	}
+
+	if len(signed) < int(policy.Threshold) {
+		peer.Errorf("Multi-signature policy not satisfied, %d of %d required signatures.", len(signed), policy.Threshold)
+
+		return utils.ErrMultiSignaturePolicyNotSatisfied
+	}
+
+	return nil
+}
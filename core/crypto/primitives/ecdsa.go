@@ -17,9 +17,11 @@ limitations under the License.
 package primitives
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/asn1"
+	"errors"
 	"math/big"
 )
 
@@ -35,7 +37,18 @@ func NewECDSAKey() (*ecdsa.PrivateKey, error) {
 
 // ECDSASignDirect signs
 func ECDSASignDirect(signKey interface{}, msg []byte) (*big.Int, *big.Int, error) {
-	temp := signKey.(*ecdsa.PrivateKey)
+	temp, ok := signKey.(*ecdsa.PrivateKey)
+	if !ok {
+		raw, err := signerSign(signKey, msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		ecdsaSignature := new(ECDSASignature)
+		if _, err := asn1.Unmarshal(raw, ecdsaSignature); err != nil {
+			return nil, nil, err
+		}
+		return ecdsaSignature.R, ecdsaSignature.S, nil
+	}
 	h := Hash(msg)
 	r, s, err := ecdsa.Sign(rand.Reader, temp, h)
 	if err != nil {
@@ -45,9 +58,25 @@ func ECDSASignDirect(signKey interface{}, msg []byte) (*big.Int, *big.Int, error
 	return r, s, nil
 }
 
+// signerSign signs with a key held by a key provider, which returns the
+// ASN.1 encoded signature
+func signerSign(signKey interface{}, msg []byte) ([]byte, error) {
+	signer, ok := signKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("Invalid signing key type.")
+	}
+	if _, ok := signer.Public().(*ecdsa.PublicKey); !ok {
+		return nil, errors.New("Invalid signing key type.")
+	}
+	return signer.Sign(rand.Reader, Hash(msg), defaultHashID)
+}
+
 // ECDSASign signs
 func ECDSASign(signKey interface{}, msg []byte) ([]byte, error) {
-	temp := signKey.(*ecdsa.PrivateKey)
+	temp, ok := signKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return signerSign(signKey, msg)
+	}
 	h := Hash(msg)
 	r, s, err := ecdsa.Sign(rand.Reader, temp, h)
 	if err != nil {
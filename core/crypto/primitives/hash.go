@@ -17,12 +17,14 @@ limitations under the License.
 package primitives
 
 import (
+	"crypto"
 	"crypto/hmac"
 	"hash"
 )
 
 var (
 	defaultHash          func() hash.Hash
+	defaultHashID        crypto.Hash // identifies defaultHash to crypto.Signer implementations
 	defaultHashAlgorithm string
 )
 
@@ -17,6 +17,7 @@ limitations under the License.
 package primitives
 
 import (
+	"crypto"
 	"crypto/elliptic"
 	"crypto/sha256"
 	"crypto/sha512"
@@ -36,9 +37,11 @@ func initSHA2(level int) (err error) {
 	case 256:
 		defaultCurve = elliptic.P256()
 		defaultHash = sha256.New
+		defaultHashID = crypto.SHA256
 	case 384:
 		defaultCurve = elliptic.P384()
 		defaultHash = sha512.New384
+		defaultHashID = crypto.SHA384
 	default:
 		err = fmt.Errorf("Security level not supported [%d]", level)
 	}
@@ -51,9 +54,11 @@ func initSHA3(level int) (err error) {
 	case 256:
 		defaultCurve = elliptic.P256()
 		defaultHash = sha3.New256
+		defaultHashID = crypto.SHA3_256
 	case 384:
 		defaultCurve = elliptic.P384()
 		defaultHash = sha3.New384
+		defaultHashID = crypto.SHA3_384
 	default:
 		err = fmt.Errorf("Security level not supported [%d]", level)
 	}
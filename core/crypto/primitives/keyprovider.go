@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+)
+
+// KeyProvider provides private keys which never leave the device holding
+// them, such as a hardware security module. The keys are identified by a
+// label, and used through the crypto.Signer interface, which ECDSASign and
+// ECDSASignDirect accept in place of an *ecdsa.PrivateKey.
+type KeyProvider interface {
+	// GenerateECDSAKey generates an ECDSA key on the default curve, labelled label
+	GenerateECDSAKey(label string) (crypto.Signer, error)
+
+	// GetSigner returns the signer of the key labelled label
+	GetSigner(label string) (crypto.Signer, error)
+}
+
+// KeyWrapper is implemented by the key providers which can encrypt secret
+// keys with a key of their own, labelled label, so that the wrapped keys can
+// be stored next to the data they protect and only be used with the device
+type KeyWrapper interface {
+	// WrapKey encrypts key with the wrapping key labelled label, generating
+	// it if it does not exist
+	WrapKey(label string, key []byte) ([]byte, error)
+
+	// UnwrapKey decrypts a key wrapped by WrapKey
+	UnwrapKey(label string, wrapped []byte) ([]byte, error)
+}
+
+// KeyProviderFactory creates a key provider, reading its own configuration
+type KeyProviderFactory func() (KeyProvider, error)
+
+var (
+	keyProvidersMutex    sync.Mutex
+	keyProviderFactories = make(map[string]KeyProviderFactory)
+	keyProviders         = make(map[string]KeyProvider)
+)
+
+// RegisterKeyProvider registers the factory of the key provider name. The
+// providers needing a native library register themselves when built with the
+// corresponding build tag.
+func RegisterKeyProvider(name string, factory KeyProviderFactory) {
+	keyProvidersMutex.Lock()
+	defer keyProvidersMutex.Unlock()
+
+	keyProviderFactories[name] = factory
+}
+
+// GetKeyProvider returns the key provider name, created on first use and
+// shared by all the callers
+func GetKeyProvider(name string) (KeyProvider, error) {
+	keyProvidersMutex.Lock()
+	defer keyProvidersMutex.Unlock()
+
+	if provider, ok := keyProviders[name]; ok {
+		return provider, nil
+	}
+	factory, ok := keyProviderFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("Key provider [%s] not available, it has to be enabled with the [%s] build tag", name, name)
+	}
+	provider, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	keyProviders[name] = provider
+
+	return provider, nil
+}
+
+// ecdsaPublicKeyOf returns the public key of an ECDSA private key or of a
+// signer of an ECDSA key
+func ecdsaPublicKeyOf(privateKey interface{}) (*ecdsa.PublicKey, bool) {
+	switch priv := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		return &priv.PublicKey, true
+	case crypto.Signer:
+		pub, ok := priv.Public().(*ecdsa.PublicKey)
+		return pub, ok
+	}
+	return nil, false
+}
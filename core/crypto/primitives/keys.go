@@ -18,6 +18,7 @@ package primitives
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -57,6 +58,19 @@ func PrivateKeyToPEM(privateKey interface{}, pwd []byte) ([]byte, error) {
 				Bytes: raw,
 			},
 		), nil
+	case ed25519.PrivateKey:
+		raw, err := x509.MarshalPKCS8PrivateKey(x)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return pem.EncodeToMemory(
+			&pem.Block{
+				Type:  "ED25519 PRIVATE KEY",
+				Bytes: raw,
+			},
+		), nil
 	default:
 		return nil, utils.ErrInvalidKey
 	}
@@ -85,6 +99,26 @@ func PrivateKeyToEncryptedPEM(privateKey interface{}, pwd []byte) ([]byte, error
 
 		return pem.EncodeToMemory(block), nil
 
+	case ed25519.PrivateKey:
+		raw, err := x509.MarshalPKCS8PrivateKey(x)
+
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := x509.EncryptPEMBlock(
+			rand.Reader,
+			"ED25519 PRIVATE KEY",
+			raw,
+			pwd,
+			x509.PEMCipherAES256)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return pem.EncodeToMemory(block), nil
+
 	default:
 		return nil, utils.ErrInvalidKey
 	}
@@ -100,7 +134,7 @@ func DERToPrivateKey(der []byte) (key interface{}, err error) {
 	//fmt.Printf("DERToPrivateKey Err [%s]\n", err)
 	if key, err = x509.ParsePKCS8PrivateKey(der); err == nil {
 		switch key.(type) {
-		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
 			return
 		default:
 			return nil, errors.New("Found unknown private key type in PKCS#8 wrapping")
@@ -233,6 +267,19 @@ func PublicKeyToPEM(publicKey interface{}, pwd []byte) ([]byte, error) {
 			},
 		), nil
 
+	case ed25519.PublicKey:
+		PubASN1, err := x509.MarshalPKIXPublicKey(x)
+		if err != nil {
+			return nil, err
+		}
+
+		return pem.EncodeToMemory(
+			&pem.Block{
+				Type:  "ED25519 PUBLIC KEY",
+				Bytes: PubASN1,
+			},
+		), nil
+
 	default:
 		return nil, utils.ErrInvalidKey
 	}
@@ -261,6 +308,26 @@ func PublicKeyToEncryptedPEM(publicKey interface{}, pwd []byte) ([]byte, error)
 
 		return pem.EncodeToMemory(block), nil
 
+	case ed25519.PublicKey:
+		raw, err := x509.MarshalPKIXPublicKey(x)
+
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := x509.EncryptPEMBlock(
+			rand.Reader,
+			"ED25519 PUBLIC KEY",
+			raw,
+			pwd,
+			x509.PEMCipherAES256)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return pem.EncodeToMemory(block), nil
+
 	default:
 		return nil, utils.ErrInvalidKey
 	}
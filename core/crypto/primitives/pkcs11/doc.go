@@ -0,0 +1,27 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pkcs11 is the key provider of the private keys held by a PKCS#11
+// token, such as a hardware security module or SoftHSM. It needs cgo and
+// github.com/miekg/pkcs11, and is only built with the pkcs11 build tag:
+//
+//	go build -tags pkcs11 ./peer
+//
+// It then registers itself as the "pkcs11" key provider of the primitives,
+// configured by security.pkcs11 in core.yaml. It also wraps secret keys, such
+// as the data keys of an encrypted DB, with AES keys of the token. The tests
+// run against a SoftHSM token with scripts/goPKCS11Tests.sh.
+package pkcs11
@@ -0,0 +1,361 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+var logger = logging.MustGetLogger("crypto/pkcs11")
+
+var (
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+)
+
+func init() {
+	primitives.RegisterKeyProvider("pkcs11", newProvider)
+}
+
+// provider holds a session on the token, shared by all its signers
+type provider struct {
+	sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+func newProvider() (primitives.KeyProvider, error) {
+	library := viper.GetString("security.pkcs11.library")
+	ctx := pkcs11.New(library)
+	if ctx == nil {
+		return nil, fmt.Errorf("Failed loading PKCS#11 library [%s]", library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, fmt.Errorf("Failed initializing PKCS#11 library [%s]: [%s]", library, err)
+	}
+
+	slot, err := findSlot(ctx, viper.GetString("security.pkcs11.label"))
+	if err != nil {
+		return nil, err
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("Failed opening session on PKCS#11 slot [%d]: [%s]", slot, err)
+	}
+	err = ctx.Login(session, pkcs11.CKU_USER, viper.GetString("security.pkcs11.pin"))
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		return nil, fmt.Errorf("Failed logging in PKCS#11 slot [%d]: [%s]", slot, err)
+	}
+	logger.Infof("Using PKCS#11 slot [%d] of library [%s]", slot, library)
+
+	return &provider{ctx: ctx, session: session}, nil
+}
+
+// findSlot returns the slot of the token labelled label, or the first slot
+// with a token if label is empty
+func findSlot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("Failed listing PKCS#11 slots: [%s]", err)
+	}
+	for _, slot := range slots {
+		if label == "" {
+			return slot, nil
+		}
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if info.Label == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("No PKCS#11 token labelled [%s]", label)
+}
+
+func curveParameters(curve elliptic.Curve) ([]byte, error) {
+	switch curve {
+	case elliptic.P256():
+		return asn1.Marshal(oidNamedCurveP256)
+	case elliptic.P384():
+		return asn1.Marshal(oidNamedCurveP384)
+	}
+	return nil, primitives.ErrInvalidKeyGeneratorParameter
+}
+
+func (p *provider) GenerateECDSAKey(label string) (crypto.Signer, error) {
+	curve := primitives.GetDefaultCurve()
+	params, err := curveParameters(curve)
+	if err != nil {
+		return nil, err
+	}
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	// A node registering again replaces its key
+	if err := p.destroyObjects(label); err != nil {
+		return nil, err
+	}
+	pubHandle, privHandle, err := p.ctx.GenerateKeyPair(p.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		publicTemplate, privateTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Failed generating PKCS#11 key [%s]: [%s]", label, err)
+	}
+	pub, err := p.readPublicKey(pubHandle, curve)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signer{provider: p, handle: privHandle, pub: pub}, nil
+}
+
+func (p *provider) GetSigner(label string) (crypto.Signer, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	privHandle, err := p.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	pubHandle, err := p.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := p.readPublicKey(pubHandle, primitives.GetDefaultCurve())
+	if err != nil {
+		return nil, err
+	}
+
+	return &signer{provider: p, handle: privHandle, pub: pub}, nil
+}
+
+func (p *provider) findObjects(template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := p.ctx.FindObjectsInit(p.session, template); err != nil {
+		return nil, err
+	}
+	defer p.ctx.FindObjectsFinal(p.session)
+
+	var handles []pkcs11.ObjectHandle
+	for {
+		found, _, err := p.ctx.FindObjects(p.session, 16)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return handles, nil
+		}
+		handles = append(handles, found...)
+	}
+}
+
+func (p *provider) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	handles, err := p.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Failed finding PKCS#11 key [%s]: [%s]", label, err)
+	}
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("PKCS#11 key [%s] not found", label)
+	}
+	return handles[0], nil
+}
+
+func (p *provider) destroyObjects(label string) error {
+	handles, err := p.findObjects([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_LABEL, label)})
+	if err != nil {
+		return fmt.Errorf("Failed finding PKCS#11 key [%s]: [%s]", label, err)
+	}
+	for _, handle := range handles {
+		if err := p.ctx.DestroyObject(p.session, handle); err != nil {
+			return fmt.Errorf("Failed destroying PKCS#11 key [%s]: [%s]", label, err)
+		}
+	}
+	return nil
+}
+
+// readPublicKey reads the point of an EC public key, a DER encoded octet
+// string holding the uncompressed point
+func (p *provider) readPublicKey(handle pkcs11.ObjectHandle, curve elliptic.Curve) (*ecdsa.PublicKey, error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, handle, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)})
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading PKCS#11 public key: [%s]", err)
+	}
+	if len(attrs) != 1 {
+		return nil, errors.New("Failed reading PKCS#11 public key: no EC point")
+	}
+	var point []byte
+	if _, err := asn1.Unmarshal(attrs[0].Value, &point); err != nil {
+		return nil, fmt.Errorf("Failed parsing PKCS#11 public key: [%s]", err)
+	}
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, errors.New("Failed parsing PKCS#11 public key: invalid EC point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// signer signs with an ECDSA private key of the token
+type signer struct {
+	provider *provider
+	handle   pkcs11.ObjectHandle
+	pub      *ecdsa.PublicKey
+}
+
+func (s *signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign signs the digest on the token, ignoring rand and opts, and returns the
+// ASN.1 encoded signature as ecdsa.PrivateKey does
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.provider.Lock()
+	defer s.provider.Unlock()
+
+	ctx := s.provider.ctx
+	err := ctx.SignInit(s.provider.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, s.handle)
+	if err != nil {
+		return nil, fmt.Errorf("Failed signing with PKCS#11 key: [%s]", err)
+	}
+	raw, err := ctx.Sign(s.provider.session, digest)
+	if err != nil {
+		return nil, fmt.Errorf("Failed signing with PKCS#11 key: [%s]", err)
+	}
+	if len(raw) == 0 || len(raw)%2 != 0 {
+		return nil, errors.New("Failed signing with PKCS#11 key: invalid signature")
+	}
+
+	// The token returns r and s concatenated
+	r := new(big.Int).SetBytes(raw[:len(raw)/2])
+	sig := new(big.Int).SetBytes(raw[len(raw)/2:])
+	return asn1.Marshal(primitives.ECDSASignature{R: r, S: sig})
+}
+
+// wrapping keys are AES keys of the token, used with CBC and a random IV
+// prefixed to the wrapped key
+const wrapIVSize = 16
+
+// WrapKey implements primitives.KeyWrapper
+func (p *provider) WrapKey(label string, key []byte) ([]byte, error) {
+	iv := make([]byte, wrapIVSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	handle, err := p.findObject(pkcs11.CKO_SECRET_KEY, label)
+	if err != nil {
+		if handle, err = p.generateWrappingKey(label); err != nil {
+			return nil, err
+		}
+	}
+	err = p.ctx.EncryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_CBC_PAD, iv)}, handle)
+	if err != nil {
+		return nil, fmt.Errorf("Failed wrapping with PKCS#11 key [%s]: [%s]", label, err)
+	}
+	wrapped, err := p.ctx.Encrypt(p.session, key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed wrapping with PKCS#11 key [%s]: [%s]", label, err)
+	}
+	return append(iv, wrapped...), nil
+}
+
+// UnwrapKey implements primitives.KeyWrapper
+func (p *provider) UnwrapKey(label string, wrapped []byte) ([]byte, error) {
+	if len(wrapped) <= wrapIVSize {
+		return nil, errors.New("Failed unwrapping with PKCS#11 key: wrapped key too short")
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	handle, err := p.findObject(pkcs11.CKO_SECRET_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	err = p.ctx.DecryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_CBC_PAD, wrapped[:wrapIVSize])}, handle)
+	if err != nil {
+		return nil, fmt.Errorf("Failed unwrapping with PKCS#11 key [%s]: [%s]", label, err)
+	}
+	key, err := p.ctx.Decrypt(p.session, wrapped[wrapIVSize:])
+	if err != nil {
+		return nil, fmt.Errorf("Failed unwrapping with PKCS#11 key [%s]: [%s]", label, err)
+	}
+	return key, nil
+}
+
+func (p *provider) generateWrappingKey(label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	handle, err := p.ctx.GenerateKey(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil)}, template)
+	if err != nil {
+		return 0, fmt.Errorf("Failed generating PKCS#11 key [%s]: [%s]", label, err)
+	}
+	logger.Infof("Generated PKCS#11 wrapping key [%s]", label)
+	return handle, nil
+}
@@ -0,0 +1,126 @@
+// +build pkcs11
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkcs11
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/spf13/viper"
+)
+
+// The tests run against the token configured by PKCS11_TEST_LIBRARY,
+// PKCS11_TEST_PIN and PKCS11_TEST_LABEL, such as the SoftHSM token set up by
+// scripts/goPKCS11Tests.sh, and are skipped if PKCS11_TEST_LIBRARY is not set
+func newTestProvider(t *testing.T) *provider {
+	library := os.Getenv("PKCS11_TEST_LIBRARY")
+	if library == "" {
+		t.Skip("Set PKCS11_TEST_LIBRARY to run the test against a PKCS#11 token")
+	}
+	if err := primitives.SetSecurityLevel("SHA2", 256); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("security.pkcs11.library", library)
+	viper.Set("security.pkcs11.pin", os.Getenv("PKCS11_TEST_PIN"))
+	viper.Set("security.pkcs11.label", os.Getenv("PKCS11_TEST_LABEL"))
+
+	p, err := primitives.GetKeyProvider("pkcs11")
+	if err != nil {
+		t.Fatalf("Failed creating the PKCS#11 key provider: %s", err)
+	}
+	return p.(*provider)
+}
+
+func TestSign(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.destroyObjects("test-sign")
+
+	signer, err := p.GenerateECDSAKey("test-sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("message signed on the token")
+	sig, err := primitives.ECDSASign(signer, msg)
+	if err != nil {
+		t.Fatalf("Failed signing: %s", err)
+	}
+	if ok, err := primitives.ECDSAVerify(signer.Public(), msg, sig); err != nil || !ok {
+		t.Fatalf("Failed verifying the signature of the token: %v", err)
+	}
+
+	// The key is found again by its label
+	found, err := p.GetSigner("test-sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, foundPub := signer.Public().(*ecdsa.PublicKey), found.Public().(*ecdsa.PublicKey)
+	if pub.X.Cmp(foundPub.X) != 0 || pub.Y.Cmp(foundPub.Y) != 0 {
+		t.Fatal("Expected the key found by its label to be the generated key")
+	}
+	if sig, err = primitives.ECDSASign(found, msg); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := primitives.ECDSAVerify(pub, msg, sig); !ok {
+		t.Fatal("Failed verifying the signature of the key found by its label")
+	}
+
+	// Generating the key again replaces it
+	if _, err = p.GenerateECDSAKey("test-sign"); err != nil {
+		t.Fatal(err)
+	}
+	if handles, err := p.findObjects(nil); err != nil || len(handles) == 0 {
+		t.Fatalf("Failed listing the objects of the token: %v", err)
+	}
+	if _, err = p.GetSigner("test-missing"); err == nil {
+		t.Fatal("Expected a missing key to be reported")
+	}
+}
+
+func TestWrapKey(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.destroyObjects("test-wrap")
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	wrapped, err := p.WrapKey("test-wrap", key)
+	if err != nil {
+		t.Fatalf("Failed wrapping: %s", err)
+	}
+	if bytes.Contains(wrapped, key) {
+		t.Fatal("Expected the wrapped key not to hold the key")
+	}
+	unwrapped, err := p.UnwrapKey("test-wrap", wrapped)
+	if err != nil || !bytes.Equal(unwrapped, key) {
+		t.Fatalf("Failed unwrapping: %v", err)
+	}
+
+	// The wrapping key generated by the first call is reused
+	again, err := p.WrapKey("test-wrap", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unwrapped, err = p.UnwrapKey("test-wrap", again); err != nil || !bytes.Equal(unwrapped, key) {
+		t.Fatalf("Failed unwrapping with the same wrapping key: %v", err)
+	}
+	if _, err = p.UnwrapKey("test-wrap", wrapped[:wrapIVSize]); err == nil {
+		t.Fatal("Expected a truncated wrapped key to be rejected")
+	}
+}
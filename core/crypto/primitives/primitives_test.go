@@ -1,10 +1,13 @@
 package primitives
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"crypto/x509"
 	"encoding/asn1"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
 	"reflect"
@@ -195,6 +198,166 @@ func TestECDSA(t *testing.T) {
 	}
 }
 
+// testSigner holds an ECDSA key as a key provider would
+type testSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *testSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s *testSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.Sign(rand, digest, opts)
+}
+
+var testKeyProviders int
+
+type testKeyProvider struct {
+	keys map[string]*testSigner
+}
+
+func (p *testKeyProvider) GenerateECDSAKey(label string) (crypto.Signer, error) {
+	key, err := NewECDSAKey()
+	if err != nil {
+		return nil, err
+	}
+	p.keys[label] = &testSigner{key}
+	return p.keys[label], nil
+}
+
+func (p *testKeyProvider) GetSigner(label string) (crypto.Signer, error) {
+	signer, ok := p.keys[label]
+	if !ok {
+		return nil, fmt.Errorf("Key [%s] not found", label)
+	}
+	return signer, nil
+}
+
+func TestKeyProvider(t *testing.T) {
+	if _, err := GetKeyProvider("test-missing"); err == nil {
+		t.Fatalf("Getting a key provider not registered should fail")
+	}
+
+	// TestMain runs the tests several times, and providers are created once
+	testKeyProviders++
+	name := fmt.Sprintf("test-%d", testKeyProviders)
+	created := 0
+	RegisterKeyProvider(name, func() (KeyProvider, error) {
+		created++
+		return &testKeyProvider{keys: make(map[string]*testSigner)}, nil
+	})
+	provider, err := GetKeyProvider(name)
+	if err != nil {
+		t.Fatalf("Failed getting key provider [%s]", err)
+	}
+	if again, _ := GetKeyProvider(name); again != provider || created != 1 {
+		t.Fatalf("Key provider should be created once, created %d times", created)
+	}
+
+	signer, err := provider.GenerateECDSAKey("enrollment")
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err)
+	}
+	if signer, err = provider.GetSigner("enrollment"); err != nil {
+		t.Fatalf("Failed getting signer [%s]", err)
+	}
+
+	msg := []byte("Hello World")
+	sigma, err := ECDSASign(signer, msg)
+	if err != nil {
+		t.Fatalf("Failed signing [%s]", err)
+	}
+	if ok, err := ECDSAVerify(signer.Public(), msg, sigma); err != nil || !ok {
+		t.Fatalf("Failed verification [%s]", err)
+	}
+
+	R, S, err := ECDSASignDirect(signer, msg)
+	if err != nil {
+		t.Fatalf("Failed signing (direct) [%s]", err)
+	}
+	if !ecdsa.Verify(signer.Public().(*ecdsa.PublicKey), Hash(msg), R, S) {
+		t.Fatalf("Failed verification (direct)")
+	}
+
+	if _, err = ECDSASign("not a key", msg); err == nil {
+		t.Fatalf("Signing with an invalid key should fail")
+	}
+}
+
+func TestSignatureSuites(t *testing.T) {
+	suite, err := GetSignatureSuite("")
+	if err != nil {
+		t.Fatalf("Failed getting default signature suite [%s]", err)
+	}
+	if !IsECDSASignatureSuite(suite) || !suite.IsKeyOf(&ecdsa.PublicKey{Curve: GetDefaultCurve()}) {
+		t.Fatalf("Default signature suite should be ECDSA, got [%s]", suite.Name())
+	}
+	if _, err := GetSignatureSuite("RSA"); err == nil {
+		t.Fatalf("Getting an unsupported signature suite should fail")
+	}
+
+	msg := []byte("Hello World")
+	for _, name := range []string{SignatureSuiteECDSAP256, SignatureSuiteECDSAP384, "ed25519"} {
+		suite, err := GetSignatureSuite(name)
+		if err != nil {
+			t.Fatalf("Failed getting signature suite [%s]: [%s]", name, err)
+		}
+		key, err := suite.GenerateKey()
+		if err != nil {
+			t.Fatalf("Failed generating key of suite [%s]: [%s]", name, err)
+		}
+		if of, err := GetSignatureSuiteOf(key.Public()); err != nil || of != suite {
+			t.Fatalf("Key of suite [%s] should belong to it, got [%v] [%s]", name, of, err)
+		}
+
+		sigma, err := Sign(key, msg)
+		if err != nil {
+			t.Fatalf("Failed signing with suite [%s]: [%s]", name, err)
+		}
+		if ok, err := Verify(key.Public(), msg, sigma); err != nil || !ok {
+			t.Fatalf("Failed verification with suite [%s]: [%s]", name, err)
+		}
+		if ok, _ := Verify(key.Public(), msg[1:], sigma); ok {
+			t.Fatalf("Verification with suite [%s] should fail", name)
+		}
+		R, S, err := SignDirect(key, msg)
+		if err != nil {
+			t.Fatalf("Failed signing (direct) with suite [%s]: [%s]", name, err)
+		}
+		if ok, err := VerifyDirect(key.Public(), msg, R, S); err != nil || !ok {
+			t.Fatalf("Failed verification (direct) with suite [%s]: [%s]", name, err)
+		}
+		if ok, _ := VerifyDirect(key.Public(), msg[1:], R, S); ok {
+			t.Fatalf("Verification (direct) with suite [%s] should fail", name)
+		}
+
+		// Keys and certificates keep their suite
+		raw, err := PrivateKeyToPEM(key, nil)
+		if err != nil {
+			t.Fatalf("Failed converting private key of suite [%s] to PEM [%s]", name, err)
+		}
+		keyFromPEM, err := PEMtoPrivateKey(raw, nil)
+		if err != nil {
+			t.Fatalf("Failed converting PEM to private key of suite [%s]: [%s]", name, err)
+		}
+		if err = CheckCertPKAgainstSK(&x509.Certificate{PublicKey: key.Public()}, keyFromPEM); err != nil {
+			t.Fatalf("Private key of suite [%s] should match its public key [%s]", name, err)
+		}
+		raw, err = PublicKeyToPEM(key.Public(), nil)
+		if err != nil {
+			t.Fatalf("Failed converting public key of suite [%s] to PEM [%s]", name, err)
+		}
+		pub, err := PEMtoPublicKey(raw, nil)
+		if err != nil {
+			t.Fatalf("Failed converting PEM to public key of suite [%s]: [%s]", name, err)
+		}
+		if !suite.IsKeyOf(pub) {
+			t.Fatalf("Public key from PEM should belong to suite [%s]", name)
+		}
+	}
+}
+
 func TestECDSAKeys(t *testing.T) {
 	key, err := NewECDSAKey()
 	if err != nil {
@@ -415,6 +578,40 @@ func TestHMAC(t *testing.T) {
 
 }
 
+func TestKeyShareBatchProof(t *testing.T) {
+	curve := GetDefaultCurve()
+	context := []byte("enrollment certificate")
+
+	var secrets []*big.Int
+	var shares [][]byte
+	for i := 0; i < 5; i++ {
+		secret := ExpandScalar(curve, context, []byte{byte(i)})
+		secrets = append(secrets, secret)
+		shares = append(shares, NewKeyShare(curve, secret))
+	}
+	proof, err := NewKeyShareBatchProof(curve, secrets, shares, context)
+	if err != nil {
+		t.Fatalf("Failed creating key share proof [%s]", err)
+	}
+	if err = VerifyKeyShareBatchProof(curve, shares, proof, context); err != nil {
+		t.Fatalf("Failed verifying key share proof [%s]", err)
+	}
+
+	if err = VerifyKeyShareBatchProof(curve, shares, proof, []byte("another context")); err == nil {
+		t.Fatal("Verifying a key share proof in another context must fail")
+	}
+	if err = VerifyKeyShareBatchProof(curve, shares[1:], proof, context); err == nil {
+		t.Fatal("Verifying a key share proof for other shares must fail")
+	}
+	forged := append([][]byte{NewKeyShare(curve, big.NewInt(42))}, shares[1:]...)
+	if err = VerifyKeyShareBatchProof(curve, forged, proof, context); err == nil {
+		t.Fatal("Verifying a key share proof for a share of an unknown value must fail")
+	}
+	if _, err = NewKeyShareBatchProof(curve, secrets[1:], shares, context); err == nil {
+		t.Fatal("Proving the knowledge of fewer values than shares must fail")
+	}
+}
+
 func TestX509(t *testing.T) {
 
 	// Generate a self signed cert
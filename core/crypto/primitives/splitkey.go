@@ -0,0 +1,153 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// The key of a split-key TCert is the enrollment key of its owner, plus an
+// expansion value the TCA and the owner derive from the TCert nonce, plus a
+// blinding value only the owner knows. The owner sends the TCA the nonces and
+// the key shares, the blinding values times the base point, with a batch
+// proof that it knows the blinding values, so that the TCA certifies keys it
+// can not tell apart from random ones once it forgot the key shares.
+
+// ExpandScalar maps HMAC(key, msg) to a scalar of curve, in [1, N-1]
+func ExpandScalar(curve elliptic.Curve, key, msg []byte) *big.Int {
+	one := big.NewInt(1)
+	k := new(big.Int).SetBytes(HMAC(key, msg))
+	k.Mod(k, new(big.Int).Sub(curve.Params().N, one))
+	return k.Add(k, one)
+}
+
+// NewKeyShare returns the key share of the blinding value secret, the
+// marshalled point secret times the base point of curve
+func NewKeyShare(curve elliptic.Curve, secret *big.Int) []byte {
+	x, y := curve.ScalarBaseMult(secret.Bytes())
+	return elliptic.Marshal(curve, x, y)
+}
+
+// NewKeyShareBatchProof proves the knowledge of the blinding values secrets
+// of shares, bound to context, with a single Schnorr proof of knowledge of
+// the discrete logarithm of a random linear combination of the shares.
+func NewKeyShareBatchProof(curve elliptic.Curve, secrets []*big.Int, shares [][]byte, context []byte) ([]byte, error) {
+	if len(secrets) == 0 || len(secrets) != len(shares) {
+		return nil, errors.New("Invalid key shares.")
+	}
+	n := curve.Params().N
+	coefficients, digest := keyShareCoefficients(curve, shares, context)
+
+	secret := new(big.Int)
+	for i, s := range secrets {
+		secret.Add(secret, new(big.Int).Mul(coefficients[i], s))
+	}
+	secret.Mod(secret, n)
+
+	w, err := rand.Int(rand.Reader, new(big.Int).Sub(n, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	w.Add(w, big.NewInt(1))
+	commitment := NewKeyShare(curve, w)
+
+	// s = w + c * sum(a_i r_i)
+	s := new(big.Int).Mul(keyShareChallenge(curve, digest, commitment), secret)
+	s.Add(s, w)
+	s.Mod(s, n)
+
+	byteLen := (curve.Params().BitSize + 7) / 8
+	proof := make([]byte, len(commitment)+byteLen)
+	copy(proof, commitment)
+	sBytes := s.Bytes()
+	copy(proof[len(proof)-len(sBytes):], sBytes)
+
+	return proof, nil
+}
+
+// VerifyKeyShareBatchProof checks that proof proves the knowledge of the
+// blinding values of shares, bound to context. It fails unless every share
+// is a point of curve.
+func VerifyKeyShareBatchProof(curve elliptic.Curve, shares [][]byte, proof []byte, context []byte) error {
+	if len(shares) == 0 {
+		return errors.New("Invalid key shares. There are none.")
+	}
+	byteLen := (curve.Params().BitSize + 7) / 8
+	pointLen := 1 + 2*byteLen
+	if len(proof) != pointLen+byteLen {
+		return errors.New("Invalid key share proof length.")
+	}
+	tX, tY := elliptic.Unmarshal(curve, proof[:pointLen])
+	if tX == nil {
+		return errors.New("Invalid key share proof commitment.")
+	}
+	s := new(big.Int).SetBytes(proof[pointLen:])
+	if s.Sign() == 0 || s.Cmp(curve.Params().N) >= 0 {
+		return errors.New("Invalid key share proof response.")
+	}
+
+	coefficients, digest := keyShareCoefficients(curve, shares, context)
+
+	// sG = T + c * sum(a_i K_i)
+	var kX, kY *big.Int
+	for i, share := range shares {
+		x, y := elliptic.Unmarshal(curve, share)
+		if x == nil {
+			return errors.New("Invalid key share. It is not a point of the curve.")
+		}
+		x, y = curve.ScalarMult(x, y, coefficients[i].Bytes())
+		if kX == nil {
+			kX, kY = x, y
+		} else {
+			kX, kY = curve.Add(kX, kY, x, y)
+		}
+	}
+	cX, cY := curve.ScalarMult(kX, kY, keyShareChallenge(curve, digest, proof[:pointLen]).Bytes())
+	rX, rY := curve.Add(tX, tY, cX, cY)
+	sX, sY := curve.ScalarBaseMult(s.Bytes())
+	if sX.Cmp(rX) != 0 || sY.Cmp(rY) != 0 {
+		return errors.New("Invalid key share proof.")
+	}
+
+	return nil
+}
+
+// keyShareCoefficients returns the coefficients of the linear combination of
+// shares, each derived from all the shares and context so that the shares
+// can not be chosen to cancel each other out, and the digest they derive from
+func keyShareCoefficients(curve elliptic.Curve, shares [][]byte, context []byte) ([]*big.Int, []byte) {
+	h := NewHash()
+	h.Write(context)
+	for _, share := range shares {
+		h.Write(share)
+	}
+	digest := h.Sum(nil)
+
+	coefficients := make([]*big.Int, len(shares))
+	for i := range shares {
+		coefficients[i] = ExpandScalar(curve, digest, []byte(strconv.Itoa(i)))
+	}
+	return coefficients, digest
+}
+
+func keyShareChallenge(curve elliptic.Curve, digest, commitment []byte) *big.Int {
+	return ExpandScalar(curve, digest, commitment)
+}
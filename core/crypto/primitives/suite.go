@@ -0,0 +1,200 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package primitives
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Names of the signature suites, as configured by security.signatureAlgorithm
+const (
+	// SignatureSuiteECDSAP256 signs the hash of the messages with ECDSA on P-256
+	SignatureSuiteECDSAP256 = "ECDSA-P256"
+	// SignatureSuiteECDSAP384 signs the hash of the messages with ECDSA on P-384
+	SignatureSuiteECDSAP384 = "ECDSA-P384"
+	// SignatureSuiteEd25519 signs the messages with Ed25519
+	SignatureSuiteEd25519 = "Ed25519"
+)
+
+// SignatureSuite is an algorithm of signing keys. The suite of a key is
+// encoded in the certificates of its public key, by its algorithm and curve.
+type SignatureSuite interface {
+	// Name returns the name of the suite
+	Name() string
+
+	// GenerateKey generates a new signing key of the suite
+	GenerateKey() (crypto.Signer, error)
+
+	// IsKeyOf returns whether the public key is a key of the suite
+	IsKeyOf(pub interface{}) bool
+}
+
+type ecdsaSuite struct {
+	name  string
+	curve func() elliptic.Curve
+}
+
+func (suite *ecdsaSuite) Name() string {
+	return suite.name
+}
+
+func (suite *ecdsaSuite) GenerateKey() (crypto.Signer, error) {
+	return ecdsa.GenerateKey(suite.curve(), rand.Reader)
+}
+
+func (suite *ecdsaSuite) IsKeyOf(pub interface{}) bool {
+	ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+	return ok && ecdsaPub.Curve == suite.curve()
+}
+
+type ed25519Suite struct{}
+
+func (ed25519Suite) Name() string {
+	return SignatureSuiteEd25519
+}
+
+func (ed25519Suite) GenerateKey() (crypto.Signer, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+func (ed25519Suite) IsKeyOf(pub interface{}) bool {
+	_, ok := pub.(ed25519.PublicKey)
+	return ok
+}
+
+var signatureSuites = []SignatureSuite{
+	&ecdsaSuite{SignatureSuiteECDSAP256, elliptic.P256},
+	&ecdsaSuite{SignatureSuiteECDSAP384, elliptic.P384},
+	ed25519Suite{},
+}
+
+// GetSignatureSuite returns the signature suite name, matched case
+// insensitively, or ECDSA on the default curve if name is empty
+func GetSignatureSuite(name string) (SignatureSuite, error) {
+	if name == "" {
+		for _, suite := range signatureSuites {
+			if ecdsaSuite, ok := suite.(*ecdsaSuite); ok && ecdsaSuite.curve() == GetDefaultCurve() {
+				return suite, nil
+			}
+		}
+		return nil, fmt.Errorf("No signature suite for the default curve")
+	}
+	for _, suite := range signatureSuites {
+		if strings.EqualFold(suite.Name(), name) {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("Signature algorithm not supported [%s]", name)
+}
+
+// GetSignatureSuiteOf returns the signature suite of the public key
+func GetSignatureSuiteOf(pub interface{}) (SignatureSuite, error) {
+	for _, suite := range signatureSuites {
+		if suite.IsKeyOf(pub) {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("Public key of unsupported signature algorithm [%T]", pub)
+}
+
+// IsECDSASignatureSuite returns whether the suite signs with ECDSA keys, from
+// which transaction certificates can be derived
+func IsECDSASignatureSuite(suite SignatureSuite) bool {
+	_, ok := suite.(*ecdsaSuite)
+	return ok
+}
+
+// Sign signs msg with signKey, an ECDSA or Ed25519 private key or a signer of
+// one of them. ECDSA signatures are ASN.1 encoded.
+func Sign(signKey interface{}, msg []byte) ([]byte, error) {
+	switch key := signKey.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, msg), nil
+	case *ecdsa.PrivateKey:
+		return ECDSASign(key, msg)
+	case crypto.Signer:
+		if _, ok := key.Public().(ed25519.PublicKey); ok {
+			return key.Sign(rand.Reader, msg, crypto.Hash(0))
+		}
+	}
+	return ECDSASign(signKey, msg)
+}
+
+// Verify verifies that signature is a signature of msg under verKey, an ECDSA
+// or Ed25519 public key
+func Verify(verKey interface{}, msg, signature []byte) (bool, error) {
+	switch key := verKey.(type) {
+	case ed25519.PublicKey:
+		return len(key) == ed25519.PublicKeySize && ed25519.Verify(key, msg, signature), nil
+	case *ecdsa.PublicKey:
+		return ECDSAVerify(key, msg, signature)
+	default:
+		return false, ErrInvalidPublicKeyType
+	}
+}
+
+// SignDirect signs msg with signKey and returns the signature as the pair the
+// membership services messages carry: the text encoded (r, s) pair for ECDSA,
+// the signature and nil for Ed25519
+func SignDirect(signKey interface{}, msg []byte) ([]byte, []byte, error) {
+	if _, ok := publicKeyOf(signKey).(ed25519.PublicKey); ok {
+		sigma, err := Sign(signKey, msg)
+		return sigma, nil, err
+	}
+
+	r, s, err := ECDSASignDirect(signKey, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return R, S, nil
+}
+
+// VerifyDirect verifies a signature of msg under verKey returned by SignDirect
+func VerifyDirect(verKey interface{}, msg, R, S []byte) (bool, error) {
+	switch key := verKey.(type) {
+	case ed25519.PublicKey:
+		return Verify(key, msg, R)
+	case *ecdsa.PublicKey:
+		r, s := new(big.Int), new(big.Int)
+		if r.UnmarshalText(R) != nil || s.UnmarshalText(S) != nil {
+			return false, nil
+		}
+		return ecdsa.Verify(key, Hash(msg), r, s), nil
+	default:
+		return false, ErrInvalidPublicKeyType
+	}
+}
+
+func publicKeyOf(signKey interface{}) crypto.PublicKey {
+	if signer, ok := signKey.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return nil
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package primitives
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -46,6 +48,10 @@ var (
 
 	// TCertAttributesHeaders is the ASN1 object identifier of attributes header.
 	TCertAttributesHeaders = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 9}
+
+	// TCertSplitKeyNonce is the ASN1 object identifier of the nonce of a
+	// split-key TCert, from which its owner derives the key.
+	TCertSplitKeyNonce = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 7, 1}
 )
 
 // DERToX509Certificate converts der to x509
@@ -218,7 +224,7 @@ func CheckCertPKAgainstSK(x509Cert *x509.Certificate, privateKey interface{}) er
 			return errors.New("Private key does not match public key")
 		}
 	case *ecdsa.PublicKey:
-		priv, ok := privateKey.(*ecdsa.PrivateKey)
+		priv, ok := ecdsaPublicKeyOf(privateKey)
 		if !ok {
 			return errors.New("Private key type does not match public key type")
 
@@ -226,6 +232,18 @@ func CheckCertPKAgainstSK(x509Cert *x509.Certificate, privateKey interface{}) er
 		if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
 			return errors.New("Private key does not match public key")
 		}
+	case ed25519.PublicKey:
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return errors.New("Private key type does not match public key type")
+		}
+		priv, ok := signer.Public().(ed25519.PublicKey)
+		if !ok {
+			return errors.New("Private key type does not match public key type")
+		}
+		if !pub.Equal(priv) {
+			return errors.New("Private key does not match public key")
+		}
 	default:
 		return errors.New("Unknown public key algorithm")
 	}
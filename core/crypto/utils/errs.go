@@ -84,6 +84,15 @@ var (
 
 	// ErrInvalidProtocolVersion Invalid protocol version
 	ErrInvalidProtocolVersion = errors.New("Invalid protocol version")
+
+	// ErrCertificateRevoked Certificate revoked
+	ErrCertificateRevoked = errors.New("Certificate revoked.")
+
+	// ErrInvalidMultiSignaturePolicy Invalid multi-signature policy
+	ErrInvalidMultiSignaturePolicy = errors.New("Invalid multi-signature policy.")
+
+	// ErrMultiSignaturePolicyNotSatisfied Multi-signature policy not satisfied
+	ErrMultiSignaturePolicyNotSatisfied = errors.New("Multi-signature policy not satisfied.")
 )
 
 // ErrToString converts and error to a string. If the error is nil, it returns the string "<clean>"
@@ -22,6 +22,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/primitives/aes"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
 )
@@ -51,6 +52,8 @@ func (validator *validatorImpl) deepCloneAndDecryptTx(tx *obc.Transaction) (*obc
 		return validator.deepCloneAndDecryptTx1_1(tx)
 	case "1.2":
 		return validator.deepCloneAndDecryptTx1_2(tx)
+	case "2.0":
+		return validator.deepCloneAndDecryptTx2_0(tx)
 	}
 	return nil, utils.ErrInvalidProtocolVersion
 }
@@ -69,7 +72,10 @@ func (validator *validatorImpl) deepCloneAndDecryptTx1_1(tx *obc.Transaction) (*
 
 	// Derive root key
 	// client.enrollChainKey is an AES key represented as byte array
-	enrollChainKey := validator.enrollChainKey.([]byte)
+	enrollChainKey, err := validator.getEnrollmentChainSecretKey()
+	if err != nil {
+		return nil, err
+	}
 
 	key := primitives.HMAC(enrollChainKey, clone.Nonce)
 
@@ -191,3 +197,92 @@ func (validator *validatorImpl) deepCloneAndDecryptTx1_2(tx *obc.Transaction) (*
 
 	return clone, nil
 }
+
+func (validator *validatorImpl) deepCloneAndDecryptTx2_0(tx *obc.Transaction) (*obc.Transaction, error) {
+	if tx.Nonce == nil || len(tx.Nonce) == 0 {
+		return nil, errors.New("Failed decrypting payload. Invalid nonce.")
+	}
+
+	// clone tx
+	clone, err := validator.deepCloneTransaction(tx)
+	if err != nil {
+		validator.Errorf("Failed deep cloning [%s].", err.Error())
+		return nil, err
+	}
+
+	validator.Debugf("Transaction type [%s].", tx.Type.String())
+
+	validator.Debug("Extract transaction key...")
+
+	msgToValidators, err := validator.getValidatorMessage2_0(tx)
+	if err != nil {
+		return nil, err
+	}
+	txKey := msgToValidators.TxKey
+
+	validator.Debug("Extract transaction key...done")
+
+	aesSPI := aes.NewAES256GSMSPI()
+
+	// Decrypt ChaincodeID
+	chaincodeID, err := decryptTxField2_0(aesSPI, getTxFieldKey2_0(txKey, clone.Nonce, 1), clone.ChaincodeID)
+	if err != nil {
+		validator.Errorf("Failed decrypting chaincode [%s].", err.Error())
+		return nil, err
+	}
+	clone.ChaincodeID = chaincodeID
+
+	// Decrypt Payload
+	payload, err := decryptTxField2_0(aesSPI, getTxFieldKey2_0(txKey, clone.Nonce, 2), clone.Payload)
+	if err != nil {
+		validator.Errorf("Failed decrypting payload [%s].", err.Error())
+		return nil, err
+	}
+	clone.Payload = payload
+
+	// Decrypt metadata
+	if len(clone.Metadata) != 0 {
+		metadata, err := decryptTxField2_0(aesSPI, getTxFieldKey2_0(txKey, clone.Nonce, 3), clone.Metadata)
+		if err != nil {
+			validator.Errorf("Failed decrypting metadata [%s].", err.Error())
+			return nil, err
+		}
+		clone.Metadata = metadata
+	}
+
+	return clone, nil
+}
+
+func (validator *validatorImpl) getValidatorMessage2_0(tx *obc.Transaction) (*chainCodeValidatorMessage2_0, error) {
+	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(validator.chainPrivateKey)
+	if err != nil {
+		validator.Errorf("Failed init decryption engine [%s].", err.Error())
+		return nil, err
+	}
+
+	msgToValidatorsRaw, err := cipher.Process(tx.ToValidators)
+	if err != nil {
+		validator.Errorf("Failed decrypting message to validators [% x]: [%s].", tx.ToValidators, err.Error())
+		return nil, err
+	}
+
+	msgToValidators := new(chainCodeValidatorMessage2_0)
+	_, err = asn1.Unmarshal(msgToValidatorsRaw, msgToValidators)
+	if err != nil {
+		validator.Errorf("Failed unmarshalling message to validators [%s].", err.Error())
+		return nil, err
+	}
+	if len(msgToValidators.TxKey) != primitives.AESKeyLength {
+		return nil, errors.New("Invalid transaction key.")
+	}
+
+	return msgToValidators, nil
+}
+
+func decryptTxField2_0(aesSPI primitives.StreamCipherSPI, key, field []byte) ([]byte, error) {
+	cipher, err := aesSPI.NewStreamCipherForDecryptionFromSerializedKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Process(field)
+}
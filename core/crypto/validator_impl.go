@@ -109,7 +109,7 @@ func (validator *validatorImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
-	vk := cert.PublicKey.(*ecdsa.PublicKey)
+	vk := cert.PublicKey
 
 	ok, err := validator.verify(vk, message, signature)
 	if err != nil {
@@ -30,18 +30,12 @@ import (
 	obc "github.com/hyperledger/fabric/protos"
 )
 
+// GetStateEncryptor returns the state encryptor of executeTx on the chaincode
+// deployed by deployTx. The keys of the state derive from deployTx following
+// its confidentiality protocol version, whereas the key of a query result
+// follows the version of the query, so that a chaincode deployed with a
+// version can be executed by transactions of any other.
 func (validator *validatorImpl) GetStateEncryptor(deployTx, executeTx *obc.Transaction) (StateEncryptor, error) {
-	switch executeTx.ConfidentialityProtocolVersion {
-	case "1.1":
-		return validator.getStateEncryptor1_1(deployTx, executeTx)
-	case "1.2":
-		return validator.getStateEncryptor1_2(deployTx, executeTx)
-	}
-
-	return nil, utils.ErrInvalidConfidentialityLevel
-}
-
-func (validator *validatorImpl) getStateEncryptor1_1(deployTx, executeTx *obc.Transaction) (StateEncryptor, error) {
 	// Check nonce
 	if deployTx.Nonce == nil || len(deployTx.Nonce) == 0 {
 		return nil, errors.New("Invalid deploy nonce.")
@@ -60,29 +54,29 @@ func (validator *validatorImpl) getStateEncryptor1_1(deployTx, executeTx *obc.Tr
 	if !reflect.DeepEqual(deployTx.ChaincodeID, executeTx.ChaincodeID) {
 		return nil, utils.ErrDifferentChaincodeID
 	}
-	// Check the confidentiality protocol version
-	if deployTx.ConfidentialityProtocolVersion != executeTx.ConfidentialityProtocolVersion {
-		return nil, utils.ErrDifferrentConfidentialityProtocolVersion
-	}
 
-	validator.Debugf("Parsing transaction. Type [%s]. Confidentiality Protocol Version [%d]", executeTx.Type.String(), executeTx.ConfidentialityProtocolVersion)
+	validator.Debugf("Parsing transaction. Type [%s]. Confidentiality Protocol Version [%s]. Deployed with version [%s]",
+		executeTx.Type.String(), executeTx.ConfidentialityProtocolVersion, deployTx.ConfidentialityProtocolVersion)
 
-	// client.enrollChainKey is an AES key represented as byte array
-	enrollChainKey := validator.enrollChainKey.([]byte)
+	// Compute deployTxKey key from the deploy transaction. This is used to decrypt the actual state
+	// of the chaincode
+	deployTxKey, err := validator.getDeployTxKey(deployTx)
+	if err != nil {
+		return nil, err
+	}
 
 	if executeTx.Type == obc.Transaction_CHAINCODE_QUERY {
 		validator.Debug("Parsing Query transaction...")
 
-		// Compute deployTxKey key from the deploy transaction. This is used to decrypt the actual state
-		// of the chaincode
-		deployTxKey := primitives.HMAC(enrollChainKey, deployTx.Nonce)
-
 		// Compute the key used to encrypt the result of the query
-		queryKey := primitives.HMACTruncated(enrollChainKey, append([]byte{6}, executeTx.Nonce...), primitives.AESKeyLength)
+		queryKey, err := validator.getQueryKey(executeTx)
+		if err != nil {
+			return nil, err
+		}
 
 		// Init the state encryptor
 		se := queryStateEncryptor{}
-		err := se.init(validator.nodeImpl, queryKey, deployTxKey)
+		err = se.init(validator.nodeImpl, queryKey, deployTxKey)
 		if err != nil {
 			return nil, err
 		}
@@ -90,9 +84,6 @@ func (validator *validatorImpl) getStateEncryptor1_1(deployTx, executeTx *obc.Tr
 		return &se, nil
 	}
 
-	// Compute deployTxKey key from the deploy transaction
-	deployTxKey := primitives.HMAC(enrollChainKey, deployTx.Nonce)
-
 	// Mask executeTx.Nonce
 	executeTxNonce := primitives.HMACTruncated(deployTxKey, primitives.Hash(executeTx.Nonce), primitives.NonceSize)
 
@@ -103,7 +94,7 @@ func (validator *validatorImpl) getStateEncryptor1_1(deployTx, executeTx *obc.Tr
 
 	// Init the state encryptor
 	se := stateEncryptorImpl{}
-	err := se.init(validator.nodeImpl, stateKey, nonceStateKey, deployTxKey, executeTxNonce)
+	err = se.init(validator.nodeImpl, stateKey, nonceStateKey, deployTxKey, executeTxNonce)
 	if err != nil {
 		return nil, err
 	}
@@ -111,78 +102,57 @@ func (validator *validatorImpl) getStateEncryptor1_1(deployTx, executeTx *obc.Tr
 	return &se, nil
 }
 
-func (validator *validatorImpl) getStateEncryptor1_2(deployTx, executeTx *obc.Transaction) (StateEncryptor, error) {
-	// Check nonce
-	if deployTx.Nonce == nil || len(deployTx.Nonce) == 0 {
-		return nil, errors.New("Invalid deploy nonce.")
-	}
-	if executeTx.Nonce == nil || len(executeTx.Nonce) == 0 {
-		return nil, errors.New("Invalid invoke nonce.")
-	}
-	// Check ChaincodeID
-	if deployTx.ChaincodeID == nil {
-		return nil, errors.New("Invalid deploy chaincodeID.")
-	}
-	if executeTx.ChaincodeID == nil {
-		return nil, errors.New("Invalid execute chaincodeID.")
-	}
-	// Check that deployTx and executeTx refers to the same chaincode
-	if !reflect.DeepEqual(deployTx.ChaincodeID, executeTx.ChaincodeID) {
-		return nil, utils.ErrDifferentChaincodeID
-	}
-	// Check the confidentiality protocol version
-	if deployTx.ConfidentialityProtocolVersion != executeTx.ConfidentialityProtocolVersion {
-		return nil, utils.ErrDifferrentConfidentialityProtocolVersion
-	}
-
-	validator.Debugf("Parsing transaction. Type [%s]. Confidentiality Protocol Version [%s]", executeTx.Type.String(), executeTx.ConfidentialityProtocolVersion)
-
-	deployStateKey, err := validator.getStateKeyFromTransaction(deployTx)
-
-	if executeTx.Type == obc.Transaction_CHAINCODE_QUERY {
-		validator.Debug("Parsing Query transaction...")
-
-		executeStateKey, err := validator.getStateKeyFromTransaction(executeTx)
-
-		// Compute deployTxKey key from the deploy transaction. This is used to decrypt the actual state
-		// of the chaincode
-		deployTxKey := primitives.HMAC(deployStateKey, deployTx.Nonce)
-
-		// Compute the key used to encrypt the result of the query
-		//queryKey := utils.HMACTruncated(executeStateKey, append([]byte{6}, executeTx.Nonce...), utils.AESKeyLength)
+// getDeployTxKey returns the key of the deploy transaction from which the
+// keys of the state of the chaincode derive
+func (validator *validatorImpl) getDeployTxKey(deployTx *obc.Transaction) ([]byte, error) {
+	switch deployTx.ConfidentialityProtocolVersion {
+	case "1.1":
+		// client.enrollChainKey is an AES key represented as byte array
+		enrollChainKey, err := validator.getEnrollmentChainSecretKey()
+		if err != nil {
+			return nil, err
+		}
 
-		// Init the state encryptor
-		se := queryStateEncryptor{}
-		err = se.init(validator.nodeImpl, executeStateKey, deployTxKey)
+		return primitives.HMAC(enrollChainKey, deployTx.Nonce), nil
+	case "1.2", "2.0":
+		deployStateKey, err := validator.getStateKeyFromTransaction(deployTx)
 		if err != nil {
 			return nil, err
 		}
 
-		return &se, nil
+		return primitives.HMAC(deployStateKey, deployTx.Nonce), nil
 	}
 
-	// Compute deployTxKey key from the deploy transaction
-	deployTxKey := primitives.HMAC(deployStateKey, deployTx.Nonce)
-
-	// Mask executeTx.Nonce
-	executeTxNonce := primitives.HMACTruncated(deployTxKey, primitives.Hash(executeTx.Nonce), primitives.NonceSize)
+	return nil, utils.ErrInvalidProtocolVersion
+}
 
-	// Compute stateKey to encrypt the states and nonceStateKey to generates IVs. This
-	// allows validators to reach consesus
-	stateKey := primitives.HMACTruncated(deployTxKey, append([]byte{3}, executeTxNonce...), primitives.AESKeyLength)
-	nonceStateKey := primitives.HMAC(deployTxKey, append([]byte{4}, executeTxNonce...))
+// getQueryKey returns the key used to encrypt the result of the query
+func (validator *validatorImpl) getQueryKey(queryTx *obc.Transaction) ([]byte, error) {
+	switch queryTx.ConfidentialityProtocolVersion {
+	case "1.1":
+		enrollChainKey, err := validator.getEnrollmentChainSecretKey()
+		if err != nil {
+			return nil, err
+		}
 
-	// Init the state encryptor
-	se := stateEncryptorImpl{}
-	err = se.init(validator.nodeImpl, stateKey, nonceStateKey, deployTxKey, executeTxNonce)
-	if err != nil {
-		return nil, err
+		return primitives.HMACTruncated(enrollChainKey, append([]byte{6}, queryTx.Nonce...), primitives.AESKeyLength), nil
+	case "1.2", "2.0":
+		return validator.getStateKeyFromTransaction(queryTx)
 	}
 
-	return &se, nil
+	return nil, utils.ErrInvalidProtocolVersion
 }
 
 func (validator *validatorImpl) getStateKeyFromTransaction(tx *obc.Transaction) ([]byte, error) {
+	if tx.ConfidentialityProtocolVersion == "2.0" {
+		msgToValidators, err := validator.getValidatorMessage2_0(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		return msgToValidators.StateKey, nil
+	}
+
 	cipher, err := validator.eciesSPI.NewAsymmetricCipherFromPrivateKey(validator.chainPrivateKey)
 	if err != nil {
 		validator.Errorf("Failed init decryption engine [%s].", err.Error())
@@ -21,11 +21,12 @@ import (
 	"io"
 	"os"
 	"path"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 var dbLogger = logging.MustGetLogger("db")
@@ -35,31 +36,46 @@ const stateCF = "stateCF"
 const stateDeltaCF = "stateDeltaCF"
 const indexesCF = "indexesCF"
 const persistCF = "persistCF"
+const privateStateCF = "privateStateCF"
 
 var columnfamilies = []string{
-	blockchainCF, // blocks of the block chain
-	stateCF,      // world state
-	stateDeltaCF, // open transaction state
-	indexesCF,    // tx uuid -> blockno
-	persistCF,    // persistent per-peer state (consensus)
+	blockchainCF,   // blocks of the block chain
+	stateCF,        // world state
+	stateDeltaCF,   // open transaction state
+	indexesCF,      // tx uuid -> blockno
+	persistCF,      // persistent per-peer state (consensus)
+	privateStateCF, // values of the private state collections the peer is a member of
 }
 
-// OpenchainDB encapsulates rocksdb's structures
+// OpenchainDB encapsulates the Driver the DB is kept in and its column families
 type OpenchainDB struct {
-	DB           *gorocksdb.DB
-	BlockchainCF *gorocksdb.ColumnFamilyHandle
-	StateCF      *gorocksdb.ColumnFamilyHandle
-	StateDeltaCF *gorocksdb.ColumnFamilyHandle
-	IndexesCF    *gorocksdb.ColumnFamilyHandle
-	PersistCF    *gorocksdb.ColumnFamilyHandle
+	driver       Driver
+	BlockchainCF ColumnFamily
+	StateCF      ColumnFamily
+	StateDeltaCF ColumnFamily
+	IndexesCF    ColumnFamily
+	PersistCF    ColumnFamily
+	// PrivateStateCF is not part of the world state, only the hashes of its
+	// values are
+	PrivateStateCF ColumnFamily
+	ledgerID       string
 }
 
 var openchainDB *OpenchainDB
 var isOpen bool
 
-// CreateDB creates a rocks db database
+// ledgerDBs are the open DBs of the ledgers other than the default ledger
+var ledgerDBs = make(map[string]*OpenchainDB)
+var ledgerDBsLock sync.Mutex
+
+var validLedgerID = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// CreateDB creates a database
 func CreateDB() error {
-	dbPath := getDBPath()
+	return createDBAt(getDBPath())
+}
+
+func createDBAt(dbPath string) error {
 	dbLogger.Debugf("Creating DB at [%s]", dbPath)
 	missing, err := dirMissingOrEmpty(dbPath)
 	if err != nil {
@@ -74,16 +90,21 @@ func CreateDB() error {
 		dbLogger.Errorf("Error calling  os.MkdirAll for directory path [%s]: %s", dbPath, err)
 		return fmt.Errorf("Error making directory path [%s]: %s", dbPath, err)
 	}
-	opts := gorocksdb.NewDefaultOptions()
-	defer opts.Destroy()
-	opts.SetCreateIfMissing(true)
 
-	db, err := gorocksdb.OpenDb(opts, dbPath)
+	if encryptionEnabled() {
+		// A new DB holds no value to re-encrypt
+		if _, err = openKeyring(getKeyringPath(dbPath), false); err != nil {
+			return err
+		}
+	}
+	driver, err := newDBDriver(dbPath)
 	if err != nil {
 		return err
 	}
-
-	defer db.Close()
+	if _, err = driver.Open(dbPath, columnfamilies); err != nil {
+		return err
+	}
+	defer driver.Close()
 
 	dbLogger.Debugf("DB created at [%s]", dbPath)
 	return nil
@@ -108,13 +129,80 @@ func GetDBHandle() *OpenchainDB {
 	return openchainDB
 }
 
+// GetLedgerDBHandle returns a handle to the DB of the ledger with the given
+// ID. The default ledger, with the empty ID, is kept in the DB returned by
+// GetDBHandle. Every other ledger is kept in a DB of its own, with its own
+// column families, in the directory ledgers/<ledgerID> under peer.fileSystemPath.
+func GetLedgerDBHandle(ledgerID string) (*OpenchainDB, error) {
+	if ledgerID == "" {
+		return GetDBHandle(), nil
+	}
+	if err := CheckLedgerID(ledgerID); err != nil {
+		return nil, err
+	}
+	ledgerDBsLock.Lock()
+	defer ledgerDBsLock.Unlock()
+	if ledgerDB, ok := ledgerDBs[ledgerID]; ok {
+		return ledgerDB, nil
+	}
+	dbPath := getLedgerDBPath(ledgerID)
+	missing, err := dirMissingOrEmpty(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if missing {
+		if err = createDBAt(dbPath); err != nil {
+			return nil, fmt.Errorf("Error creating DB of ledger [%s]: %s", ledgerID, err)
+		}
+	}
+	driver, err := newDBDriver(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	cfs, err := driver.Open(dbPath, columnfamilies)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening DB of ledger [%s]: %s", ledgerID, err)
+	}
+	ledgerDB := &OpenchainDB{driver, cfs[0], cfs[1], cfs[2], cfs[3], cfs[4], cfs[5], ledgerID}
+	ledgerDBs[ledgerID] = ledgerDB
+	return ledgerDB, nil
+}
+
+// CheckLedgerID returns an error unless ledgerID is the empty ID of the
+// default ledger or one of the ledgers declared in ledger.ledgers, so that
+// no DB is created for the IDs sent by clients
+func CheckLedgerID(ledgerID string) error {
+	if ledgerID == "" {
+		return nil
+	}
+	if !validLedgerID.MatchString(ledgerID) {
+		return fmt.Errorf("Invalid ledger ID [%s], only letters, digits, '_' and '-' are allowed", ledgerID)
+	}
+	for _, declared := range viper.GetStringSlice("ledger.ledgers") {
+		if declared == ledgerID {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown ledger ID [%s], not declared in ledger.ledgers", ledgerID)
+}
+
+// LedgerID returns the ID of the ledger kept in the DB, which is empty for the default ledger
+func (openchainDB *OpenchainDB) LedgerID() string {
+	return openchainDB.ledgerID
+}
+
+// Driver returns the driver the DB is kept in
+func (openchainDB *OpenchainDB) Driver() Driver {
+	return openchainDB.driver
+}
+
 // GetFromBlockchainCF get value for given key from column family - blockchainCF
 func (openchainDB *OpenchainDB) GetFromBlockchainCF(key []byte) ([]byte, error) {
 	return openchainDB.Get(openchainDB.BlockchainCF, key)
 }
 
 // GetFromBlockchainCFSnapshot get value for given key from column family in a DB snapshot - blockchainCF
-func (openchainDB *OpenchainDB) GetFromBlockchainCFSnapshot(snapshot *gorocksdb.Snapshot, key []byte) ([]byte, error) {
+func (openchainDB *OpenchainDB) GetFromBlockchainCFSnapshot(snapshot Snapshot, key []byte) ([]byte, error) {
 	return openchainDB.getFromSnapshot(snapshot, openchainDB.BlockchainCF, key)
 }
 
@@ -133,32 +221,48 @@ func (openchainDB *OpenchainDB) GetFromIndexesCF(key []byte) ([]byte, error) {
 	return openchainDB.Get(openchainDB.IndexesCF, key)
 }
 
+// GetFromPrivateStateCF get value for given key from column family - privateStateCF
+func (openchainDB *OpenchainDB) GetFromPrivateStateCF(key []byte) ([]byte, error) {
+	return openchainDB.Get(openchainDB.PrivateStateCF, key)
+}
+
 // GetBlockchainCFIterator get iterator for column family - blockchainCF
-func (openchainDB *OpenchainDB) GetBlockchainCFIterator() *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetBlockchainCFIterator() Iterator {
 	return openchainDB.GetIterator(openchainDB.BlockchainCF)
 }
 
 // GetStateCFIterator get iterator for column family - stateCF
-func (openchainDB *OpenchainDB) GetStateCFIterator() *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetStateCFIterator() Iterator {
 	return openchainDB.GetIterator(openchainDB.StateCF)
 }
 
 // GetStateCFSnapshotIterator get iterator for column family - stateCF. This iterator
 // is based on a snapshot and should be used for long running scans, such as
 // reading the entire state. Remember to call iterator.Close() when you are done.
-func (openchainDB *OpenchainDB) GetStateCFSnapshotIterator(snapshot *gorocksdb.Snapshot) *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetStateCFSnapshotIterator(snapshot Snapshot) Iterator {
 	return openchainDB.getSnapshotIterator(snapshot, openchainDB.StateCF)
 }
 
 // GetStateDeltaCFIterator get iterator for column family - stateDeltaCF
-func (openchainDB *OpenchainDB) GetStateDeltaCFIterator() *gorocksdb.Iterator {
+func (openchainDB *OpenchainDB) GetStateDeltaCFIterator() Iterator {
 	return openchainDB.GetIterator(openchainDB.StateDeltaCF)
 }
 
 // GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
 // when you are done with the snapshot.
-func (openchainDB *OpenchainDB) GetSnapshot() *gorocksdb.Snapshot {
-	return openchainDB.DB.NewSnapshot()
+func (openchainDB *OpenchainDB) GetSnapshot() Snapshot {
+	return openchainDB.driver.NewSnapshot()
+}
+
+// NewWriteBatch returns a batch of changes to be written with Write. You MUST
+// call writeBatch.Destroy() when you are done with the batch.
+func (openchainDB *OpenchainDB) NewWriteBatch() WriteBatch {
+	return openchainDB.driver.NewWriteBatch()
+}
+
+// Write atomically persists all the changes of the write batch
+func (openchainDB *OpenchainDB) Write(writeBatch WriteBatch) error {
+	return openchainDB.driver.Write(writeBatch)
 }
 
 func getDBPath() string {
@@ -172,6 +276,43 @@ func getDBPath() string {
 	return dbPath + "db"
 }
 
+func getLedgerDBPath(ledgerID string) string {
+	return path.Join(path.Dir(getDBPath()), "ledgers", ledgerID, "db")
+}
+
+func getDriverName() string {
+	driverName := viper.GetString("peer.db.driver")
+	if driverName == "" {
+		driverName = defaultDriver()
+	}
+	return driverName
+}
+
+// defaultDriver is used when peer.db.driver is not set, it is rocksdb in
+// builds with cgo and leveldb otherwise
+func defaultDriver() string {
+	if _, ok := drivers[DriverRocksDB]; ok {
+		return DriverRocksDB
+	}
+	return DriverLevelDB
+}
+
+// newDBDriver returns the driver of the DB at dbPath, encrypting its values if
+// peer.db.encryption.enabled is set
+func newDBDriver(dbPath string) (Driver, error) {
+	driver, err := newDriver(getDriverName())
+	if err != nil {
+		return nil, err
+	}
+	if encryptionEnabled() {
+		return newEncryptedDriver(driver), nil
+	}
+	if _, err := os.Stat(getKeyringPath(dbPath)); err == nil {
+		return nil, fmt.Errorf("DB at [%s] is encrypted with the keys of [%s], peer.db.encryption.enabled must be set", dbPath, getKeyringPath(dbPath))
+	}
+	return driver, nil
+}
+
 func createDBIfDBPathEmpty() error {
 	dbPath := getDBPath()
 	missing, err := dirMissingOrEmpty(dbPath)
@@ -193,93 +334,72 @@ func openDB() (*OpenchainDB, error) {
 		return openchainDB, nil
 	}
 
-	dbPath := getDBPath()
-	opts := gorocksdb.NewDefaultOptions()
-	defer opts.Destroy()
-
-	opts.SetCreateIfMissing(false)
-	opts.SetCreateIfMissingColumnFamilies(true)
-
-	cfNames := []string{"default"}
-	cfNames = append(cfNames, columnfamilies...)
-	var cfOpts []*gorocksdb.Options
-	for range cfNames {
-		cfOpts = append(cfOpts, opts)
+	driver, err := newDBDriver(getDBPath())
+	if err != nil {
+		return nil, err
 	}
-
-	db, cfHandlers, err := gorocksdb.OpenDbColumnFamilies(opts, dbPath, cfNames, cfOpts)
-
+	cfs, err := driver.Open(getDBPath(), columnfamilies)
 	if err != nil {
 		fmt.Println("Error opening DB", err)
 		return nil, err
 	}
 	isOpen = true
-	// XXX should we close cfHandlers[0]?
-	return &OpenchainDB{db, cfHandlers[1], cfHandlers[2], cfHandlers[3], cfHandlers[4], cfHandlers[5]}, nil
+	return &OpenchainDB{driver, cfs[0], cfs[1], cfs[2], cfs[3], cfs[4], cfs[5], ""}, nil
 }
 
-// CloseDB releases all column family handles and closes rocksdb
+// CloseDB releases all column family handles and closes the DB
 func (openchainDB *OpenchainDB) CloseDB() {
-	openchainDB.BlockchainCF.Destroy()
-	openchainDB.StateCF.Destroy()
-	openchainDB.StateDeltaCF.Destroy()
-	openchainDB.IndexesCF.Destroy()
-	openchainDB.PersistCF.Destroy()
-	openchainDB.DB.Close()
-	isOpen = false
+	openchainDB.driver.Close()
+	if openchainDB.ledgerID == "" {
+		isOpen = false
+		return
+	}
+	ledgerDBsLock.Lock()
+	delete(ledgerDBs, openchainDB.ledgerID)
+	ledgerDBsLock.Unlock()
+}
+
+// closeLedgerDBs closes the DBs of all the ledgers other than the default ledger
+func closeLedgerDBs() {
+	ledgerDBsLock.Lock()
+	defer ledgerDBsLock.Unlock()
+	for ledgerID, ledgerDB := range ledgerDBs {
+		ledgerDB.driver.Close()
+		delete(ledgerDBs, ledgerID)
+	}
 }
 
 // DeleteState delets ALL state keys/values from the DB. This is generally
 // only used during state synchronization when creating a new state from
 // a snapshot.
 func (openchainDB *OpenchainDB) DeleteState() error {
-	err := openchainDB.DB.DropColumnFamily(openchainDB.StateCF)
-	if err != nil {
-		dbLogger.Errorf("Error dropping state CF: %s", err)
-		return err
-	}
-	err = openchainDB.DB.DropColumnFamily(openchainDB.StateDeltaCF)
-	if err != nil {
-		dbLogger.Errorf("Error dropping state delta CF: %s", err)
-		return err
-	}
-	opts := gorocksdb.NewDefaultOptions()
-	defer opts.Destroy()
-	openchainDB.StateCF, err = openchainDB.DB.CreateColumnFamily(opts, stateCF)
+	var err error
+	openchainDB.StateCF, err = openchainDB.driver.ClearColumnFamily(openchainDB.StateCF)
 	if err != nil {
-		dbLogger.Errorf("Error creating state CF: %s", err)
+		dbLogger.Errorf("Error clearing state CF: %s", err)
 		return err
 	}
-	openchainDB.StateDeltaCF, err = openchainDB.DB.CreateColumnFamily(opts, stateDeltaCF)
+	openchainDB.StateDeltaCF, err = openchainDB.driver.ClearColumnFamily(openchainDB.StateDeltaCF)
 	if err != nil {
-		dbLogger.Errorf("Error creating state delta CF: %s", err)
+		dbLogger.Errorf("Error clearing state delta CF: %s", err)
 		return err
 	}
 	return nil
 }
 
 // Get returns the valud for the given column family and key
-func (openchainDB *OpenchainDB) Get(cfHandler *gorocksdb.ColumnFamilyHandle, key []byte) ([]byte, error) {
-	opt := gorocksdb.NewDefaultReadOptions()
-	defer opt.Destroy()
-	slice, err := openchainDB.DB.GetCF(opt, cfHandler, key)
+func (openchainDB *OpenchainDB) Get(cf ColumnFamily, key []byte) ([]byte, error) {
+	data, err := openchainDB.driver.Get(cf, key)
 	if err != nil {
 		fmt.Println("Error while trying to retrieve key:", key)
 		return nil, err
 	}
-	defer slice.Free()
-	if slice.Data() == nil {
-		return nil, nil
-	}
-	data := makeCopy(slice.Data())
 	return data, nil
 }
 
 // Put saves the key/value in the given column family
-func (openchainDB *OpenchainDB) Put(cfHandler *gorocksdb.ColumnFamilyHandle, key []byte, value []byte) error {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := openchainDB.DB.PutCF(opt, cfHandler, key, value)
+func (openchainDB *OpenchainDB) Put(cf ColumnFamily, key []byte, value []byte) error {
+	err := openchainDB.driver.Put(cf, key, value)
 	if err != nil {
 		fmt.Println("Error while trying to write key:", key)
 		return err
@@ -288,10 +408,8 @@ func (openchainDB *OpenchainDB) Put(cfHandler *gorocksdb.ColumnFamilyHandle, key
 }
 
 // Delete delets the given key in the specified column family
-func (openchainDB *OpenchainDB) Delete(cfHandler *gorocksdb.ColumnFamilyHandle, key []byte) error {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := openchainDB.DB.DeleteCF(opt, cfHandler, key)
+func (openchainDB *OpenchainDB) Delete(cf ColumnFamily, key []byte) error {
+	err := openchainDB.driver.Delete(cf, key)
 	if err != nil {
 		fmt.Println("Error while trying to delete key:", key)
 		return err
@@ -299,34 +417,32 @@ func (openchainDB *OpenchainDB) Delete(cfHandler *gorocksdb.ColumnFamilyHandle,
 	return nil
 }
 
-func (openchainDB *OpenchainDB) getFromSnapshot(snapshot *gorocksdb.Snapshot, cfHandler *gorocksdb.ColumnFamilyHandle, key []byte) ([]byte, error) {
-	opt := gorocksdb.NewDefaultReadOptions()
-	defer opt.Destroy()
-	opt.SetSnapshot(snapshot)
-	slice, err := openchainDB.DB.GetCF(opt, cfHandler, key)
+// CompactRange reclaims the space of the deleted keys of the given column
+// family from start, inclusive, to limit, exclusive, if the driver supports it
+func (openchainDB *OpenchainDB) CompactRange(cf ColumnFamily, start []byte, limit []byte) error {
+	compactor, ok := openchainDB.driver.(Compactor)
+	if !ok {
+		return nil
+	}
+	return compactor.CompactRange(cf, start, limit)
+}
+
+func (openchainDB *OpenchainDB) getFromSnapshot(snapshot Snapshot, cf ColumnFamily, key []byte) ([]byte, error) {
+	data, err := snapshot.Get(cf, key)
 	if err != nil {
 		fmt.Println("Error while trying to retrieve key:", key)
 		return nil, err
 	}
-	defer slice.Free()
-	data := append([]byte(nil), slice.Data()...)
 	return data, nil
 }
 
 // GetIterator returns an iterator for the given column family
-func (openchainDB *OpenchainDB) GetIterator(cfHandler *gorocksdb.ColumnFamilyHandle) *gorocksdb.Iterator {
-	opt := gorocksdb.NewDefaultReadOptions()
-	opt.SetFillCache(true)
-	defer opt.Destroy()
-	return openchainDB.DB.NewIteratorCF(opt, cfHandler)
-}
-
-func (openchainDB *OpenchainDB) getSnapshotIterator(snapshot *gorocksdb.Snapshot, cfHandler *gorocksdb.ColumnFamilyHandle) *gorocksdb.Iterator {
-	opt := gorocksdb.NewDefaultReadOptions()
-	defer opt.Destroy()
-	opt.SetSnapshot(snapshot)
-	iter := openchainDB.DB.NewIteratorCF(opt, cfHandler)
-	return iter
+func (openchainDB *OpenchainDB) GetIterator(cf ColumnFamily) Iterator {
+	return openchainDB.driver.NewIterator(cf)
+}
+
+func (openchainDB *OpenchainDB) getSnapshotIterator(snapshot Snapshot, cf ColumnFamily) Iterator {
+	return snapshot.NewIterator(cf)
 }
 
 func dirMissingOrEmpty(path string) (bool, error) {
@@ -20,10 +20,11 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/metrics"
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 func TestMain(m *testing.M) {
@@ -132,6 +133,26 @@ func TestDeleteState(t *testing.T) {
 	}
 }
 
+func TestCompactRange(t *testing.T) {
+	testDBWrapper := NewTestDBWrapper()
+	testDBWrapper.CreateFreshDB(t)
+	openchainDB := GetDBHandle()
+	defer testDBWrapper.cleanup()
+	openchainDB.Put(openchainDB.PersistCF, []byte("consensus.key1"), []byte("value1"))
+	openchainDB.Put(openchainDB.PersistCF, []byte("consensus.key2"), []byte("value2"))
+	openchainDB.Delete(openchainDB.PersistCF, []byte("consensus.key1"))
+	if err := openchainDB.CompactRange(openchainDB.PersistCF, []byte("consensus."), []byte("consensus/")); err != nil {
+		t.Fatalf("Error compacting range: %s", err)
+	}
+	value, err := openchainDB.Get(openchainDB.PersistCF, []byte("consensus.key2"))
+	if err != nil || !bytes.Equal(value, []byte("value2")) {
+		t.Fatalf("Expected the value of the key kept to survive the compaction, got [%s] (%v)", value, err)
+	}
+	if value, _ = openchainDB.Get(openchainDB.PersistCF, []byte("consensus.key1")); value != nil {
+		t.Fatalf("A nil value expected. Found [%s]", value)
+	}
+}
+
 func TestDBSnapshot(t *testing.T) {
 	testDBWrapper := NewTestDBWrapper()
 	testDBWrapper.CreateFreshDB(t)
@@ -226,14 +247,54 @@ func TestDBIteratorAndSnapshotIterator(t *testing.T) {
 	testIterator(t, itr, map[string][]byte{"key6": []byte("value6"), "key7": []byte("value7")})
 }
 
-func testIterator(t *testing.T, itr *gorocksdb.Iterator, expectedValues map[string][]byte) {
+func TestGetLedgerDBHandle(t *testing.T) {
+	createTestDB()
+	defer deleteTestDB()
+	defer closeLedgerDBs()
+	viper.Set("ledger.ledgers", []string{"ledger_1"})
+	defer viper.Set("ledger.ledgers", nil)
+
+	defaultDB, err := GetLedgerDBHandle("")
+	if err != nil || defaultDB != GetDBHandle() {
+		t.Fatalf("Expected the default DB for an empty ledger ID, got err %v", err)
+	}
+	ledgerDB, err := GetLedgerDBHandle("ledger_1")
+	if err != nil {
+		t.Fatalf("Error opening DB of ledger: %s", err)
+	}
+	if ledgerDB.LedgerID() != "ledger_1" || defaultDB.LedgerID() != "" {
+		t.Fatalf("Unexpected ledger IDs [%s] and [%s]", ledgerDB.LedgerID(), defaultDB.LedgerID())
+	}
+	if sameDB, _ := GetLedgerDBHandle("ledger_1"); sameDB != ledgerDB {
+		t.Fatal("Expected the same DB handle for the same ledger ID")
+	}
+
+	writeBatch := ledgerDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.PutCF(ledgerDB.StateCF, []byte("key1"), []byte("value1"))
+	if err := ledgerDB.Write(writeBatch); err != nil {
+		t.Fatalf("Error while writing to db: %s", err)
+	}
+	if value, _ := ledgerDB.GetFromStateCF([]byte("key1")); !bytes.Equal(value, []byte("value1")) {
+		t.Fatalf("Expected value [value1] in the DB of the ledger, found [%s]", value)
+	}
+	if value, _ := defaultDB.GetFromStateCF([]byte("key1")); value != nil {
+		t.Fatalf("Expected no value in the default DB, found [%s]", value)
+	}
+
+	for _, ledgerID := range []string{"../ledger", "ledger/1", "ledger 1", "ledger_2"} {
+		if _, err := GetLedgerDBHandle(ledgerID); err == nil {
+			t.Fatalf("Expected error for invalid ledger ID [%s]", ledgerID)
+		}
+	}
+}
+
+func testIterator(t *testing.T, itr Iterator, expectedValues map[string][]byte) {
 	itrResults := make(map[string][]byte)
 	itr.SeekToFirst()
 	for ; itr.Valid(); itr.Next() {
-		key := itr.Key()
-		value := itr.Value()
-		k := makeCopy(key.Data())
-		v := makeCopy(value.Data())
+		k := makeCopy(itr.Key())
+		v := makeCopy(itr.Value())
 		itrResults[string(k)] = v
 	}
 	if len(itrResults) != len(expectedValues) {
@@ -282,15 +343,13 @@ func setupTestConfig() {
 
 func performBasicReadWrite(t *testing.T) {
 	openchainDB := GetDBHandle()
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
 	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("dummyKey"), []byte("dummyValue"))
 	writeBatch.PutCF(openchainDB.StateCF, []byte("dummyKey1"), []byte("dummyValue1"))
 	writeBatch.PutCF(openchainDB.StateDeltaCF, []byte("dummyKey2"), []byte("dummyValue2"))
 	writeBatch.PutCF(openchainDB.IndexesCF, []byte("dummyKey3"), []byte("dummyValue3"))
-	err := openchainDB.DB.Write(opt, writeBatch)
+	err := openchainDB.Write(writeBatch)
 	if err != nil {
 		t.Fatalf("Error while writing to db: %s", err)
 	}
@@ -326,3 +385,38 @@ func performBasicReadWrite(t *testing.T) {
 		t.Fatalf("read error. Bytes not equal. Expected [%s], found [%s]", "dummyValue3", value)
 	}
 }
+
+// propertyDriver reads the properties of column families from a map
+type propertyDriver struct {
+	Driver
+	properties map[string]string
+}
+
+func (driver *propertyDriver) GetProperty(cf ColumnFamily, name string) string {
+	return driver.properties[cf.Name()+"/"+name]
+}
+
+type namedCF string
+
+func (cf namedCF) Name() string {
+	return string(cf)
+}
+
+func TestDBPropertyMetrics(t *testing.T) {
+	driver := &propertyDriver{properties: map[string]string{
+		"stateCF/rocksdb.estimate-num-keys":      "2",
+		"blockchainCF/rocksdb.estimate-num-keys": "10",
+		"indexesCF/rocksdb.estimate-num-keys":    "",
+	}}
+	dbs := []*OpenchainDB{
+		{driver, namedCF(blockchainCF), namedCF(stateCF), namedCF(stateDeltaCF), namedCF(indexesCF), namedCF(persistCF), namedCF(privateStateCF), "ledger_1"},
+	}
+	samples := readDBProperty(dbs, "rocksdb.estimate-num-keys")
+	expected := []metrics.Sample{
+		{Labels: []string{"ledger_1", blockchainCF}, Value: 10},
+		{Labels: []string{"ledger_1", stateCF}, Value: 2},
+	}
+	if !reflect.DeepEqual(samples, expected) {
+		t.Fatalf("Expected the samples %v, got %v", expected, samples)
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"testing"
 
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 // TestDBWrapper wraps the db. Can be used by other modules for testing
@@ -65,6 +64,7 @@ func (testDB *TestDBWrapper) CreateFreshDBGinkgo() error {
 
 func (testDB *TestDBWrapper) cleanup() {
 	if testDB.performCleanup {
+		closeLedgerDBs()
 		GetDBHandle().CloseDB()
 		testDB.performCleanup = false
 	}
@@ -76,26 +76,19 @@ func (testDB *TestDBWrapper) removeDBPath() {
 }
 
 // WriteToDB tests can use this method for persisting a given batch to db
-func (testDB *TestDBWrapper) WriteToDB(t testing.TB, writeBatch *gorocksdb.WriteBatch) {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := GetDBHandle().DB.Write(opt, writeBatch)
+func (testDB *TestDBWrapper) WriteToDB(t testing.TB, writeBatch WriteBatch) {
+	err := GetDBHandle().Write(writeBatch)
 	if err != nil {
 		t.Fatalf("Error while writing to db. Error:%s", err)
 	}
 }
 
-// GetFromDB gets the value for the given key from default column-family
+// GetFromDB gets the value for the given key from StateCF column-family
 func (testDB *TestDBWrapper) GetFromDB(t testing.TB, key []byte) []byte {
-	db := GetDBHandle().DB
-	opt := gorocksdb.NewDefaultReadOptions()
-	defer opt.Destroy()
-	slice, err := db.Get(opt, key)
-	defer slice.Free()
+	value, err := GetDBHandle().Get(GetDBHandle().StateCF, key)
 	if err != nil {
 		t.Fatalf("Error while getting key-value from DB: %s", err)
 	}
-	value := append([]byte(nil), slice.Data()...)
 	return value
 }
 
@@ -125,19 +118,27 @@ func (testDB *TestDBWrapper) CloseDB(t testing.TB) {
 	openchainDB.CloseDB()
 }
 
-// GetEstimatedNumKeys returns estimated number of key-values in db. This is not accurate in all the cases
+// GetEstimatedNumKeys returns estimated number of key-values in db. This is not accurate in all the cases,
+// and only available with the rocksdb driver
 func (testDB *TestDBWrapper) GetEstimatedNumKeys(t testing.TB) map[string]string {
 	openchainDB := GetDBHandle()
 	result := make(map[string]string, 5)
-	result["stateCF"] = openchainDB.DB.GetPropertyCF("rocksdb.estimate-num-keys", openchainDB.StateCF)
-	result["stateDeltaCF"] = openchainDB.DB.GetPropertyCF("rocksdb.estimate-num-keys", openchainDB.StateDeltaCF)
-	result["blockchainCF"] = openchainDB.DB.GetPropertyCF("rocksdb.estimate-num-keys", openchainDB.BlockchainCF)
-	result["indexCF"] = openchainDB.DB.GetPropertyCF("rocksdb.estimate-num-keys", openchainDB.IndexesCF)
+	propertyReader, ok := openchainDB.driver.(PropertyReader)
+	if !ok {
+		return result
+	}
+	result["stateCF"] = propertyReader.GetProperty(openchainDB.StateCF, "rocksdb.estimate-num-keys")
+	result["stateDeltaCF"] = propertyReader.GetProperty(openchainDB.StateDeltaCF, "rocksdb.estimate-num-keys")
+	result["blockchainCF"] = propertyReader.GetProperty(openchainDB.BlockchainCF, "rocksdb.estimate-num-keys")
+	result["indexCF"] = propertyReader.GetProperty(openchainDB.IndexesCF, "rocksdb.estimate-num-keys")
 	return result
 }
 
-// GetDBStats returns statistics for the database
+// GetDBStats returns statistics for the database, only available with the rocksdb driver
 func (testDB *TestDBWrapper) GetDBStats() string {
-	openchainDB := GetDBHandle()
-	return openchainDB.DB.GetProperty("rocksdb.stats")
+	propertyReader, ok := GetDBHandle().driver.(PropertyReader)
+	if !ok {
+		return ""
+	}
+	return propertyReader.GetProperty(nil, "rocksdb.stats")
 }
@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Driver is implemented by the key-value stores the DB can be kept in. A
+// driver provides a number of column families, each of which is an
+// independent ordered key space.
+type Driver interface {
+	// Open opens the store at dbPath, creating it and any of the given column
+	// families that are missing, and returns the column families in order
+	Open(dbPath string, cfNames []string) ([]ColumnFamily, error)
+	Close()
+
+	// Get returns nil if the key does not exist
+	Get(cf ColumnFamily, key []byte) ([]byte, error)
+	Put(cf ColumnFamily, key []byte, value []byte) error
+	Delete(cf ColumnFamily, key []byte) error
+
+	NewWriteBatch() WriteBatch
+	// Write applies all the changes of the write batch atomically
+	Write(writeBatch WriteBatch) error
+
+	NewIterator(cf ColumnFamily) Iterator
+	// NewSnapshot returns a point-in-time view of the store, which must be released
+	NewSnapshot() Snapshot
+
+	// ClearColumnFamily deletes all the keys of the column family and returns
+	// the handle to be used for it from now on
+	ClearColumnFamily(cf ColumnFamily) (ColumnFamily, error)
+}
+
+// ColumnFamily is a handle to a column family of a Driver
+type ColumnFamily interface {
+	Name() string
+}
+
+// WriteBatch collects changes to be written atomically with Driver.Write.
+// Destroy must be called when the batch is no longer needed.
+type WriteBatch interface {
+	PutCF(cf ColumnFamily, key []byte, value []byte)
+	DeleteCF(cf ColumnFamily, key []byte)
+	Destroy()
+}
+
+// Iterator iterates over the keys of a column family in order. The slices
+// returned by Key and Value are only valid until the iterator is moved.
+type Iterator interface {
+	Seek(key []byte)
+	SeekToFirst()
+	Valid() bool
+	// ValidForPrefix is Valid for iterators positioned on keys with the given prefix
+	ValidForPrefix(prefix []byte) bool
+	Next()
+	Prev()
+	Key() []byte
+	Value() []byte
+	Close()
+}
+
+// Snapshot is a point-in-time view of the store
+type Snapshot interface {
+	// Get returns nil if the key does not exist
+	Get(cf ColumnFamily, key []byte) ([]byte, error)
+	NewIterator(cf ColumnFamily) Iterator
+	Release()
+}
+
+// PropertyReader is implemented by drivers which can report statistics of the store
+type PropertyReader interface {
+	// GetProperty returns the value of a driver specific property of the
+	// column family, or of the whole store if cf is nil
+	GetProperty(cf ColumnFamily, name string) string
+}
+
+// Compactor is implemented by drivers which can reclaim the space of the
+// deleted keys of a store on demand
+type Compactor interface {
+	// CompactRange compacts the keys of the column family from start,
+	// inclusive, to limit, exclusive
+	CompactRange(cf ColumnFamily, start []byte, limit []byte) error
+}
+
+// DriverRocksDB is the name of the RocksDB driver, only available in builds with cgo
+const DriverRocksDB = "rocksdb"
+
+var drivers = make(map[string]func() Driver)
+
+// registerDriver makes a driver selectable with peer.db.driver. Drivers
+// register themselves from init functions of files which are only part of
+// builds supporting them.
+func registerDriver(name string, newDriver func() Driver) {
+	if _, ok := drivers[name]; ok {
+		panic(fmt.Sprintf("DB driver [%s] registered twice", name))
+	}
+	drivers[name] = newDriver
+}
+
+// Drivers returns the names of the drivers available in this build, in order
+func Drivers() []string {
+	var available []string
+	for name := range drivers {
+		available = append(available, name)
+	}
+	sort.Strings(available)
+	return available
+}
+
+func newDriver(name string) (Driver, error) {
+	newDriver, ok := drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("DB driver [%s] is not available in this build, available drivers are %v", name, Drivers())
+	}
+	return newDriver(), nil
+}
+
+// OpenDriver opens the store at dbPath with the driver selected with
+// peer.db.driver, creating it and any of the given column families that are
+// missing. It is meant for scratch stores of maintenance tasks, which are not
+// part of the DB of the peer. If peer.db.encryption.enabled is set, their
+// values are encrypted under a key generated for the store, which is lost
+// when it is closed.
+func OpenDriver(dbPath string, cfNames []string) (Driver, []ColumnFamily, error) {
+	driver, err := newDriver(getDriverName())
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfs []ColumnFamily
+	if encryptionEnabled() {
+		encrypted := newEncryptedDriver(driver)
+		cfs, err = encrypted.openScratch(dbPath, cfNames)
+		driver = encrypted
+	} else {
+		cfs, err = driver.Open(dbPath, cfNames)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error opening store at [%s]: %s", dbPath, err)
+	}
+	return driver, cfs, nil
+}
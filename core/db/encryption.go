@@ -0,0 +1,774 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+// When peer.db.encryption.enabled is set, the values of all the column
+// families are encrypted with AES-GCM under a data key of the peer, and
+// authenticated together with their column family and key, so that a value
+// can not be moved to another key without being detected. The keys are left
+// in the clear, as the iterators depend on their order.
+//
+// The data keys are kept in a keyring file next to the DB, wrapped by the key
+// provider of peer.db.encryption.wrap.provider if set, so that the keyring is
+// useless without the device holding the wrapping key. Rotating the data key
+// makes a new key current for the writes, and re-encrypts the values under
+// the previous keys in the background, after which they are retired. Values
+// written before the encryption was enabled are re-encrypted the same way.
+
+// encryptedValueMagic starts the encrypted values, followed by the ID of
+// their data key, the nonce and the sealed value
+const encryptedValueMagic = 0xEC
+
+const (
+	dataKeySize           = 32
+	encryptedHeaderSize   = 1 + 4
+	reencryptionBatchSize = 256
+	keyringFileMode       = 0600
+)
+
+var errEncryptionStopped = errors.New("DB closed before the re-encryption completed")
+
+func encryptionEnabled() bool {
+	return viper.GetBool("peer.db.encryption.enabled")
+}
+
+func getKeyringPath(dbPath string) string {
+	return dbPath + ".keyring"
+}
+
+type keyringKey struct {
+	ID      uint32    `json:"id"`
+	Created time.Time `json:"created"`
+	// Key is wrapped by the key provider of the keyring, if any
+	Key []byte `json:"key"`
+}
+
+type keyringFile struct {
+	Current  uint32       `json:"current"`
+	Provider string       `json:"provider,omitempty"`
+	Label    string       `json:"label,omitempty"`
+	Pending  bool         `json:"pending,omitempty"`
+	Keys     []keyringKey `json:"keys"`
+}
+
+// keyring holds the data keys of a DB. The current key encrypts the values
+// written, the others only decrypt the values not re-encrypted yet. Retired
+// keys are no longer saved, but still decrypt the values read from the
+// snapshots taken before they were retired.
+type keyring struct {
+	sync.RWMutex
+	path     string // empty for the keyrings of scratch stores, which are not saved
+	provider string
+	label    string
+	current  uint32
+	// pending is set while values remain to be re-encrypted with the current key
+	pending bool
+	keys    map[uint32][]byte
+	created map[uint32]time.Time
+	aeads   map[uint32]cipher.AEAD
+	retired map[uint32]bool
+}
+
+func newKeyring(path string) *keyring {
+	return &keyring{
+		path:     path,
+		provider: viper.GetString("peer.db.encryption.wrap.provider"),
+		label:    viper.GetString("peer.db.encryption.wrap.label"),
+		keys:     make(map[uint32][]byte),
+		created:  make(map[uint32]time.Time),
+		aeads:    make(map[uint32]cipher.AEAD),
+		retired:  make(map[uint32]bool),
+	}
+}
+
+// openKeyring loads the keyring at path, or creates it with a new data key.
+// pending tells whether a new keyring is for a DB already holding values.
+func openKeyring(path string, pending bool) (*keyring, error) {
+	ring := newKeyring(path)
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		ring.pending = pending
+		if _, err := ring.addKey(); err != nil {
+			return nil, err
+		}
+		dbLogger.Infof("Created keyring [%s] of the DB encryption", path)
+		return ring, ring.save()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading keyring [%s]: %s", path, err)
+	}
+
+	file := &keyringFile{}
+	if err := json.Unmarshal(raw, file); err != nil {
+		return nil, fmt.Errorf("Error parsing keyring [%s]: %s", path, err)
+	}
+	for _, k := range file.Keys {
+		key := k.Key
+		if file.Provider != "" {
+			if key, err = unwrapDataKey(file.Provider, file.Label, k.Key); err != nil {
+				return nil, fmt.Errorf("Error unwrapping key %d of keyring [%s]: %s", k.ID, path, err)
+			}
+		}
+		if err := ring.setKey(k.ID, key, k.Created); err != nil {
+			return nil, fmt.Errorf("Invalid key %d in keyring [%s]: %s", k.ID, path, err)
+		}
+	}
+	if _, ok := ring.aeads[file.Current]; !ok {
+		return nil, fmt.Errorf("Current key %d missing from keyring [%s]", file.Current, path)
+	}
+	ring.current = file.Current
+	ring.pending = file.Pending
+	if file.Provider != ring.provider || file.Label != ring.label {
+		// The wrapping configuration changed, wrap the keys accordingly
+		dbLogger.Infof("Rewrapping the keys of keyring [%s] with key provider [%s]", path, ring.provider)
+		if err := ring.save(); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+func wrapDataKey(provider, label string, key []byte) ([]byte, error) {
+	wrapper, err := getKeyWrapper(provider)
+	if err != nil {
+		return nil, err
+	}
+	return wrapper.WrapKey(label, key)
+}
+
+func unwrapDataKey(provider, label string, wrapped []byte) ([]byte, error) {
+	wrapper, err := getKeyWrapper(provider)
+	if err != nil {
+		return nil, err
+	}
+	return wrapper.UnwrapKey(label, wrapped)
+}
+
+func getKeyWrapper(provider string) (primitives.KeyWrapper, error) {
+	keyProvider, err := primitives.GetKeyProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	wrapper, ok := keyProvider.(primitives.KeyWrapper)
+	if !ok {
+		return nil, fmt.Errorf("Key provider [%s] can not wrap keys", provider)
+	}
+	return wrapper, nil
+}
+
+func (ring *keyring) setKey(id uint32, key []byte, created time.Time) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	ring.keys[id] = key
+	ring.created[id] = created
+	ring.aeads[id] = aead
+	return nil
+}
+
+// addKey generates a data key and makes it current, and returns the ID of
+// the previous one
+func (ring *keyring) addKey() (uint32, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return 0, err
+	}
+
+	ring.Lock()
+	defer ring.Unlock()
+	id := ring.current + 1
+	for _, ok := ring.aeads[id]; ok; _, ok = ring.aeads[id] {
+		id++
+	}
+	if err := ring.setKey(id, key, time.Now().UTC()); err != nil {
+		return 0, err
+	}
+	previous := ring.current
+	ring.current = id
+	return previous, nil
+}
+
+// removeKey forgets a key which never encrypted anything, and makes
+// previous current again
+func (ring *keyring) removeKey(id uint32, previous uint32) {
+	ring.Lock()
+	defer ring.Unlock()
+	delete(ring.keys, id)
+	delete(ring.created, id)
+	delete(ring.aeads, id)
+	ring.current = previous
+}
+
+func (ring *keyring) currentKey() (uint32, time.Time) {
+	ring.RLock()
+	defer ring.RUnlock()
+	return ring.current, ring.created[ring.current]
+}
+
+func (ring *keyring) isPending() bool {
+	ring.RLock()
+	defer ring.RUnlock()
+	return ring.pending
+}
+
+func (ring *keyring) setPending(pending bool) {
+	ring.Lock()
+	defer ring.Unlock()
+	ring.pending = pending
+}
+
+// retire retires the keys other than the current one
+func (ring *keyring) retire() {
+	ring.Lock()
+	defer ring.Unlock()
+	for id := range ring.aeads {
+		if id != ring.current {
+			ring.retired[id] = true
+		}
+	}
+}
+
+// save writes the keys not retired to a temporary file renamed over the
+// keyring, so that it is never left half written
+func (ring *keyring) save() error {
+	if ring.path == "" {
+		return nil
+	}
+	ring.RLock()
+	file := &keyringFile{Current: ring.current, Provider: ring.provider, Label: ring.label, Pending: ring.pending}
+	for id, key := range ring.keys {
+		if !ring.retired[id] {
+			file.Keys = append(file.Keys, keyringKey{ID: id, Created: ring.created[id], Key: key})
+		}
+	}
+	ring.RUnlock()
+
+	if ring.provider != "" {
+		for i := range file.Keys {
+			wrapped, err := wrapDataKey(ring.provider, ring.label, file.Keys[i].Key)
+			if err != nil {
+				return fmt.Errorf("Error wrapping key %d of keyring [%s]: %s", file.Keys[i].ID, ring.path, err)
+			}
+			file.Keys[i].Key = wrapped
+		}
+	}
+	raw, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(ring.path), 0755); err != nil {
+		return err
+	}
+	tmp := ring.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, keyringFileMode); err != nil {
+		return fmt.Errorf("Error writing keyring [%s]: %s", ring.path, err)
+	}
+	if err := os.Rename(tmp, ring.path); err != nil {
+		return fmt.Errorf("Error writing keyring [%s]: %s", ring.path, err)
+	}
+	return nil
+}
+
+// additionalData binds a value to its column family and key
+func additionalData(cf ColumnFamily, key []byte) []byte {
+	return append(append([]byte(cf.Name()), 0), key...)
+}
+
+func (ring *keyring) encrypt(cf ColumnFamily, key []byte, value []byte) []byte {
+	ring.RLock()
+	id := ring.current
+	aead := ring.aeads[id]
+	ring.RUnlock()
+
+	sealed := make([]byte, encryptedHeaderSize+aead.NonceSize(), encryptedHeaderSize+aead.NonceSize()+len(value)+aead.Overhead())
+	sealed[0] = encryptedValueMagic
+	binary.BigEndian.PutUint32(sealed[1:], id)
+	nonce := sealed[encryptedHeaderSize:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(fmt.Sprintf("Error generating nonce: %s", err))
+	}
+	return aead.Seal(sealed, nonce, value, additionalData(cf, key))
+}
+
+// keyID returns the ID of the key a value is encrypted with, or false if the
+// value is not encrypted
+func keyID(value []byte) (uint32, bool) {
+	if len(value) < encryptedHeaderSize || value[0] != encryptedValueMagic {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(value[1:]), true
+}
+
+// decrypt decrypts a value. While the keyring is pending, the values which
+// do not decrypt were written before the encryption was enabled, and are
+// returned as they are.
+func (ring *keyring) decrypt(cf ColumnFamily, key []byte, value []byte) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	id, encrypted := keyID(value)
+	ring.RLock()
+	pending := ring.pending
+	aead, known := ring.aeads[id]
+	ring.RUnlock()
+
+	var err error
+	switch {
+	case !encrypted:
+		err = fmt.Errorf("Value of key [%x] in [%s] is not encrypted", key, cf.Name())
+	case !known:
+		err = fmt.Errorf("Value of key [%x] in [%s] is encrypted with unknown key %d", key, cf.Name(), id)
+	case len(value) < encryptedHeaderSize+aead.NonceSize():
+		err = fmt.Errorf("Value of key [%x] in [%s] is truncated", key, cf.Name())
+	default:
+		nonce := value[encryptedHeaderSize : encryptedHeaderSize+aead.NonceSize()]
+		plain, openErr := aead.Open(nil, nonce, value[encryptedHeaderSize+aead.NonceSize():], additionalData(cf, key))
+		if openErr == nil {
+			if plain == nil {
+				plain = []byte{}
+			}
+			return plain, nil
+		}
+		err = fmt.Errorf("Value of key [%x] in [%s] failed authentication", key, cf.Name())
+	}
+	if pending {
+		return value, nil
+	}
+	return nil, err
+}
+
+// encryptedDriver encrypts the values stored by another driver
+type encryptedDriver struct {
+	Driver
+	dbPath string
+	ring   *keyring
+	// lock is held shared by the writes, and exclusively to rotate the key
+	// and while the re-encryption rewrites a batch of values, so that it
+	// never overwrites a newer value
+	lock sync.RWMutex
+	cfs  map[string]ColumnFamily
+	// reencryption serializes the re-encryptions
+	reencryption sync.Mutex
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+func newEncryptedDriver(driver Driver) *encryptedDriver {
+	return &encryptedDriver{Driver: driver}
+}
+
+// Open implements method in interface 'Driver'
+func (driver *encryptedDriver) Open(dbPath string, cfNames []string) ([]ColumnFamily, error) {
+	ring, err := openKeyring(getKeyringPath(dbPath), true)
+	if err != nil {
+		return nil, err
+	}
+	driver.ring = ring
+	return driver.open(dbPath, cfNames)
+}
+
+// openScratch opens a store whose values are encrypted under a key generated
+// for it, which is lost when it is closed
+func (driver *encryptedDriver) openScratch(dbPath string, cfNames []string) ([]ColumnFamily, error) {
+	driver.ring = newKeyring("")
+	if _, err := driver.ring.addKey(); err != nil {
+		return nil, err
+	}
+	return driver.open(dbPath, cfNames)
+}
+
+func (driver *encryptedDriver) open(dbPath string, cfNames []string) ([]ColumnFamily, error) {
+	driver.dbPath = dbPath
+	cfs, err := driver.Driver.Open(dbPath, cfNames)
+	if err != nil {
+		return nil, err
+	}
+	driver.cfs = make(map[string]ColumnFamily)
+	for _, cf := range cfs {
+		driver.cfs[cf.Name()] = cf
+	}
+	driver.stop = make(chan struct{})
+	driver.done = make(chan struct{})
+	go driver.background()
+	return cfs, nil
+}
+
+// Close implements method in interface 'Driver'
+func (driver *encryptedDriver) Close() {
+	close(driver.stop)
+	<-driver.done
+	driver.Driver.Close()
+}
+
+// Get implements method in interface 'Driver'
+func (driver *encryptedDriver) Get(cf ColumnFamily, key []byte) ([]byte, error) {
+	value, err := driver.Driver.Get(cf, key)
+	if err != nil {
+		return nil, err
+	}
+	return driver.ring.decrypt(cf, key, value)
+}
+
+// Put implements method in interface 'Driver'
+func (driver *encryptedDriver) Put(cf ColumnFamily, key []byte, value []byte) error {
+	driver.lock.RLock()
+	defer driver.lock.RUnlock()
+	return driver.Driver.Put(cf, key, driver.ring.encrypt(cf, key, value))
+}
+
+// Delete implements method in interface 'Driver'
+func (driver *encryptedDriver) Delete(cf ColumnFamily, key []byte) error {
+	driver.lock.RLock()
+	defer driver.lock.RUnlock()
+	return driver.Driver.Delete(cf, key)
+}
+
+type encryptedWriteOp struct {
+	cf     ColumnFamily
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// encryptedWriteBatch collects the changes, which are only encrypted when
+// the batch is written, with the key current then
+type encryptedWriteBatch struct {
+	ops []encryptedWriteOp
+}
+
+func (writeBatch *encryptedWriteBatch) PutCF(cf ColumnFamily, key []byte, value []byte) {
+	writeBatch.ops = append(writeBatch.ops, encryptedWriteOp{cf: cf, key: makeCopy(key), value: makeCopy(value)})
+}
+
+func (writeBatch *encryptedWriteBatch) DeleteCF(cf ColumnFamily, key []byte) {
+	writeBatch.ops = append(writeBatch.ops, encryptedWriteOp{cf: cf, key: makeCopy(key), delete: true})
+}
+
+func (writeBatch *encryptedWriteBatch) Destroy() {
+	writeBatch.ops = nil
+}
+
+// NewWriteBatch implements method in interface 'Driver'
+func (driver *encryptedDriver) NewWriteBatch() WriteBatch {
+	return &encryptedWriteBatch{}
+}
+
+// Write implements method in interface 'Driver'
+func (driver *encryptedDriver) Write(writeBatch WriteBatch) error {
+	driver.lock.RLock()
+	defer driver.lock.RUnlock()
+	batch := driver.Driver.NewWriteBatch()
+	defer batch.Destroy()
+	for _, op := range writeBatch.(*encryptedWriteBatch).ops {
+		if op.delete {
+			batch.DeleteCF(op.cf, op.key)
+		} else {
+			batch.PutCF(op.cf, op.key, driver.ring.encrypt(op.cf, op.key, op.value))
+		}
+	}
+	return driver.Driver.Write(batch)
+}
+
+type encryptedIterator struct {
+	Iterator
+	cf   ColumnFamily
+	ring *keyring
+}
+
+// Value decrypts the value of the current key. Failing to do so means the
+// store was tampered with, which the iterator can not report otherwise.
+func (itr *encryptedIterator) Value() []byte {
+	value, err := itr.ring.decrypt(itr.cf, itr.Key(), itr.Iterator.Value())
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// NewIterator implements method in interface 'Driver'
+func (driver *encryptedDriver) NewIterator(cf ColumnFamily) Iterator {
+	return &encryptedIterator{driver.Driver.NewIterator(cf), cf, driver.ring}
+}
+
+type encryptedSnapshot struct {
+	Snapshot
+	ring *keyring
+}
+
+func (snapshot *encryptedSnapshot) Get(cf ColumnFamily, key []byte) ([]byte, error) {
+	value, err := snapshot.Snapshot.Get(cf, key)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.ring.decrypt(cf, key, value)
+}
+
+func (snapshot *encryptedSnapshot) NewIterator(cf ColumnFamily) Iterator {
+	return &encryptedIterator{snapshot.Snapshot.NewIterator(cf), cf, snapshot.ring}
+}
+
+// NewSnapshot implements method in interface 'Driver'
+func (driver *encryptedDriver) NewSnapshot() Snapshot {
+	return &encryptedSnapshot{driver.Driver.NewSnapshot(), driver.ring}
+}
+
+// ClearColumnFamily implements method in interface 'Driver'
+func (driver *encryptedDriver) ClearColumnFamily(cf ColumnFamily) (ColumnFamily, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	newCF, err := driver.Driver.ClearColumnFamily(cf)
+	if err != nil {
+		return nil, err
+	}
+	driver.cfs[newCF.Name()] = newCF
+	return newCF, nil
+}
+
+// GetProperty implements method in interface 'PropertyReader', if the
+// encrypted driver does
+func (driver *encryptedDriver) GetProperty(cf ColumnFamily, name string) string {
+	propertyReader, ok := driver.Driver.(PropertyReader)
+	if !ok {
+		return ""
+	}
+	return propertyReader.GetProperty(cf, name)
+}
+
+// CompactRange implements method in interface 'Compactor', if the encrypted
+// driver does
+func (driver *encryptedDriver) CompactRange(cf ColumnFamily, start []byte, limit []byte) error {
+	compactor, ok := driver.Driver.(Compactor)
+	if !ok {
+		return nil
+	}
+	return compactor.CompactRange(cf, start, limit)
+}
+
+// rotateKey makes a new data key current. The values under the previous
+// keys are re-encrypted by the next re-encryption.
+func (driver *encryptedDriver) rotateKey() error {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	previous, err := driver.ring.addKey()
+	if err != nil {
+		return err
+	}
+	current, _ := driver.ring.currentKey()
+	driver.ring.setPending(true)
+	if err := driver.ring.save(); err != nil {
+		// the new key must not encrypt anything unless it is saved
+		driver.ring.removeKey(current, previous)
+		return err
+	}
+	dbLogger.Infof("Rotated the data key of the DB at [%s] to key %d", driver.dbPath, current)
+	return nil
+}
+
+// reencrypt re-encrypts the values not encrypted with the current key, then
+// retires the other keys
+func (driver *encryptedDriver) reencrypt() error {
+	driver.reencryption.Lock()
+	defer driver.reencryption.Unlock()
+
+	if !driver.ring.isPending() {
+		return nil
+	}
+	dbLogger.Infof("Re-encrypting the DB at [%s]", driver.dbPath)
+	count := 0
+	for {
+		started, _ := driver.ring.currentKey()
+		rewritten, err := driver.reencryptPass()
+		count += rewritten
+		if err != nil {
+			return err
+		}
+
+		driver.lock.Lock()
+		current, _ := driver.ring.currentKey()
+		if current != started {
+			// rotated again meanwhile, the values already rewritten are not
+			// under the current key
+			driver.lock.Unlock()
+			continue
+		}
+		driver.ring.setPending(false)
+		driver.ring.retire()
+		err = driver.ring.save()
+		if err != nil {
+			driver.ring.setPending(true)
+		}
+		driver.lock.Unlock()
+		if err != nil {
+			return err
+		}
+		dbLogger.Infof("Re-encrypted %d values of the DB at [%s] with key %d", count, driver.dbPath, current)
+		return nil
+	}
+}
+
+// reencryptPass re-encrypts all the column families once, and returns the
+// number of values rewritten
+func (driver *encryptedDriver) reencryptPass() (int, error) {
+	driver.lock.RLock()
+	var names []string
+	for name := range driver.cfs {
+		names = append(names, name)
+	}
+	driver.lock.RUnlock()
+	sort.Strings(names)
+
+	count := 0
+	for _, name := range names {
+		var from []byte
+		for {
+			select {
+			case <-driver.stop:
+				return count, errEncryptionStopped
+			default:
+			}
+			next, rewritten, err := driver.reencryptBatch(name, from)
+			count += rewritten
+			if err != nil {
+				return count, err
+			}
+			if next == nil {
+				break
+			}
+			from = next
+		}
+	}
+	return count, nil
+}
+
+// reencryptBatch re-encrypts up to reencryptionBatchSize values of a column
+// family from key from, and returns the key to continue from, or nil once
+// the column family is done. It holds the lock exclusively, so no value it
+// reads is overwritten before it rewrites it.
+func (driver *encryptedDriver) reencryptBatch(name string, from []byte) ([]byte, int, error) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+
+	current, _ := driver.ring.currentKey()
+	cf := driver.cfs[name]
+	itr := driver.Driver.NewIterator(cf)
+	defer itr.Close()
+	if from == nil {
+		itr.SeekToFirst()
+	} else {
+		itr.Seek(from)
+	}
+
+	batch := driver.Driver.NewWriteBatch()
+	defer batch.Destroy()
+	rewritten := 0
+	for ; itr.Valid(); itr.Next() {
+		if rewritten == reencryptionBatchSize {
+			return makeCopy(itr.Key()), rewritten, driver.Driver.Write(batch)
+		}
+		raw := itr.Value()
+		if id, ok := keyID(raw); ok && id == current {
+			continue
+		}
+		key := makeCopy(itr.Key())
+		value, err := driver.ring.decrypt(cf, key, raw)
+		if err != nil {
+			return nil, rewritten, err
+		}
+		batch.PutCF(cf, key, driver.ring.encrypt(cf, key, makeCopy(value)))
+		rewritten++
+	}
+	return nil, rewritten, driver.Driver.Write(batch)
+}
+
+// background resumes the re-encryption left when the DB was closed, and
+// rotates the data key every peer.db.encryption.rotationInterval
+func (driver *encryptedDriver) background() {
+	defer close(driver.done)
+	if err := driver.reencrypt(); err != nil && err != errEncryptionStopped {
+		dbLogger.Errorf("Error re-encrypting the DB at [%s]: %s", driver.dbPath, err)
+	}
+	interval := viper.GetDuration("peer.db.encryption.rotationInterval")
+	if driver.ring.path == "" || interval <= 0 {
+		<-driver.stop
+		return
+	}
+	for {
+		_, created := driver.ring.currentKey()
+		wait := created.Add(interval).Sub(time.Now())
+		if driver.ring.isPending() {
+			// the last re-encryption failed, try again later
+			wait = time.Minute
+		}
+		select {
+		case <-driver.stop:
+			return
+		case <-time.After(wait):
+		}
+		if !driver.ring.isPending() {
+			if err := driver.rotateKey(); err != nil {
+				dbLogger.Errorf("Error rotating the data key of the DB at [%s]: %s", driver.dbPath, err)
+				continue
+			}
+		}
+		if err := driver.reencrypt(); err != nil {
+			if err == errEncryptionStopped {
+				return
+			}
+			dbLogger.Errorf("Error re-encrypting the DB at [%s]: %s", driver.dbPath, err)
+		}
+	}
+}
+
+// RotateEncryptionKey makes a new data key current for the values written
+// from now on, and re-encrypts the values under the previous keys, before
+// retiring them. It returns once the values are re-encrypted.
+func (openchainDB *OpenchainDB) RotateEncryptionKey() error {
+	driver, ok := openchainDB.driver.(*encryptedDriver)
+	if !ok {
+		return errors.New("The DB is not encrypted, peer.db.encryption.enabled is not set")
+	}
+	if err := driver.rotateKey(); err != nil {
+		return err
+	}
+	return driver.reencrypt()
+}
@@ -0,0 +1,174 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func enableTestEncryption(t *testing.T) func() {
+	viper.Set("peer.db.encryption.enabled", true)
+	deleteTestDBPath()
+	if err := CreateDB(); err != nil {
+		t.Fatalf("Failed to create DB: %s", err)
+	}
+	return func() {
+		deleteTestDB()
+		viper.Set("peer.db.encryption.enabled", false)
+	}
+}
+
+func rawValue(t *testing.T, openchainDB *OpenchainDB, cf ColumnFamily, key []byte) []byte {
+	value, err := openchainDB.driver.(*encryptedDriver).Driver.Get(cf, key)
+	if err != nil {
+		t.Fatalf("Failed reading raw value: %s", err)
+	}
+	return value
+}
+
+func readKeyring(t *testing.T) *keyringFile {
+	raw, err := ioutil.ReadFile(getKeyringPath(getDBPath()))
+	if err != nil {
+		t.Fatalf("Failed reading keyring: %s", err)
+	}
+	file := &keyringFile{}
+	if err := json.Unmarshal(raw, file); err != nil {
+		t.Fatalf("Failed parsing keyring: %s", err)
+	}
+	return file
+}
+
+func TestEncryptedDB(t *testing.T) {
+	defer enableTestEncryption(t)()
+	performBasicReadWrite(t)
+
+	openchainDB := GetDBHandle()
+	raw := rawValue(t, openchainDB, openchainDB.StateCF, []byte("dummyKey1"))
+	if bytes.Contains(raw, []byte("dummyValue1")) {
+		t.Fatalf("Expected the value to be encrypted, found [%s]", raw)
+	}
+	if readKeyring(t).Pending {
+		t.Fatalf("Expected the keyring of a new DB not to be pending")
+	}
+
+	snapshot := openchainDB.GetSnapshot()
+	defer snapshot.Release()
+	value, err := openchainDB.GetFromBlockchainCFSnapshot(snapshot, []byte("dummyKey"))
+	if err != nil || !bytes.Equal(value, []byte("dummyValue")) {
+		t.Fatalf("Expected [dummyValue] from the snapshot, found [%s], error [%v]", value, err)
+	}
+	itr := openchainDB.GetStateCFIterator()
+	defer itr.Close()
+	testIterator(t, itr, map[string][]byte{"dummyKey1": []byte("dummyValue1")})
+
+	if err := openchainDB.Put(openchainDB.StateCF, []byte("empty"), []byte{}); err != nil {
+		t.Fatalf("Failed writing empty value: %s", err)
+	}
+	if value, err := openchainDB.GetFromStateCF([]byte("empty")); err != nil || value == nil || len(value) != 0 {
+		t.Fatalf("Expected an empty value, found [%v], error [%v]", value, err)
+	}
+
+	// A value moved to another key does not authenticate
+	if err := openchainDB.driver.(*encryptedDriver).Driver.Put(openchainDB.StateCF, []byte("moved"), raw); err != nil {
+		t.Fatalf("Failed writing raw value: %s", err)
+	}
+	if _, err := openchainDB.GetFromStateCF([]byte("moved")); err == nil {
+		t.Fatalf("Expected a value moved to another key to fail authentication")
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	defer enableTestEncryption(t)()
+	performBasicReadWrite(t)
+
+	openchainDB := GetDBHandle()
+	before, _ := keyID(rawValue(t, openchainDB, openchainDB.StateCF, []byte("dummyKey1")))
+	if err := openchainDB.RotateEncryptionKey(); err != nil {
+		t.Fatalf("Failed rotating the key: %s", err)
+	}
+	after, _ := keyID(rawValue(t, openchainDB, openchainDB.StateCF, []byte("dummyKey1")))
+	if after == before {
+		t.Fatalf("Expected the value to be re-encrypted with a new key, still under key %d", before)
+	}
+	if file := readKeyring(t); file.Pending || file.Current != after || len(file.Keys) != 1 {
+		t.Fatalf("Expected the keyring to only hold key %d, found %+v", after, file)
+	}
+
+	openchainDB.CloseDB()
+	openchainDB = GetDBHandle()
+	performBasicReadWrite(t)
+}
+
+func TestEncryptionOfExistingDB(t *testing.T) {
+	deleteTestDBPath()
+	if err := CreateDB(); err != nil {
+		t.Fatalf("Failed to create DB: %s", err)
+	}
+	performBasicReadWrite(t)
+	GetDBHandle().CloseDB()
+
+	defer viper.Set("peer.db.encryption.enabled", false)
+	viper.Set("peer.db.encryption.enabled", true)
+	openchainDB := GetDBHandle()
+	driver := openchainDB.driver.(*encryptedDriver)
+	// waits for the re-encryption started when the DB was opened
+	if err := driver.reencrypt(); err != nil {
+		t.Fatalf("Failed re-encrypting: %s", err)
+	}
+	if _, ok := keyID(rawValue(t, openchainDB, openchainDB.IndexesCF, []byte("dummyKey3"))); !ok {
+		t.Fatalf("Expected the existing values to be re-encrypted")
+	}
+	if readKeyring(t).Pending {
+		t.Fatalf("Expected the re-encryption to complete")
+	}
+	performBasicReadWrite(t)
+	openchainDB.CloseDB()
+
+	viper.Set("peer.db.encryption.enabled", false)
+	if _, err := openDB(); err == nil {
+		t.Fatalf("Expected an encrypted DB not to open without encryption")
+	}
+	deleteTestDBPath()
+}
+
+func TestEncryptedScratchStore(t *testing.T) {
+	defer viper.Set("peer.db.encryption.enabled", false)
+	viper.Set("peer.db.encryption.enabled", true)
+	dir, err := ioutil.TempDir("", "fabric-db-scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver, cfs, err := OpenDriver(dir, []string{"scratch"})
+	if err != nil {
+		t.Fatalf("Failed opening scratch store: %s", err)
+	}
+	defer driver.Close()
+	if err := driver.Put(cfs[0], []byte("k"), []byte("value")); err != nil {
+		t.Fatalf("Failed writing: %s", err)
+	}
+	if raw, _ := driver.(*encryptedDriver).Driver.Get(cfs[0], []byte("k")); bytes.Contains(raw, []byte("value")) {
+		t.Fatalf("Expected the scratch store to be encrypted")
+	}
+	if value, err := driver.Get(cfs[0], []byte("k")); err != nil || !bytes.Equal(value, []byte("value")) {
+		t.Fatalf("Expected [value], found [%s], error [%v]", value, err)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"bytes"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// DriverLevelDB is the name of the LevelDB driver
+const DriverLevelDB = "leveldb"
+
+// number of keys deleted per write batch by ClearColumnFamily
+const levelDBClearBatchSize = 1000
+
+func init() {
+	registerDriver(DriverLevelDB, func() Driver { return &levelDBDriver{} })
+}
+
+// levelDBDriver keeps the DB in a pure Go LevelDB, for builds without cgo.
+// LevelDB has no column families, so the keys of each column family are
+// stored under the prefix <column family name>\x00.
+type levelDBDriver struct {
+	db *leveldb.DB
+}
+
+type levelDBColumnFamily struct {
+	name   string
+	prefix []byte
+}
+
+func (cf *levelDBColumnFamily) Name() string {
+	return cf.name
+}
+
+func (cf *levelDBColumnFamily) dbKey(key []byte) []byte {
+	return append(append([]byte{}, cf.prefix...), key...)
+}
+
+func asLevelDBColumnFamily(cf ColumnFamily) *levelDBColumnFamily {
+	return cf.(*levelDBColumnFamily)
+}
+
+func (driver *levelDBDriver) Open(dbPath string, cfNames []string) ([]ColumnFamily, error) {
+	db, err := leveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	driver.db = db
+	var cfs []ColumnFamily
+	for _, name := range cfNames {
+		cfs = append(cfs, &levelDBColumnFamily{name, append([]byte(name), 0)})
+	}
+	return cfs, nil
+}
+
+func (driver *levelDBDriver) Close() {
+	driver.db.Close()
+}
+
+func (driver *levelDBDriver) Get(cf ColumnFamily, key []byte) ([]byte, error) {
+	value, err := driver.db.Get(asLevelDBColumnFamily(cf).dbKey(key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (driver *levelDBDriver) Put(cf ColumnFamily, key []byte, value []byte) error {
+	return driver.db.Put(asLevelDBColumnFamily(cf).dbKey(key), value, nil)
+}
+
+func (driver *levelDBDriver) Delete(cf ColumnFamily, key []byte) error {
+	return driver.db.Delete(asLevelDBColumnFamily(cf).dbKey(key), nil)
+}
+
+type levelDBWriteBatch struct {
+	batch *leveldb.Batch
+}
+
+func (writeBatch *levelDBWriteBatch) PutCF(cf ColumnFamily, key []byte, value []byte) {
+	writeBatch.batch.Put(asLevelDBColumnFamily(cf).dbKey(key), value)
+}
+
+func (writeBatch *levelDBWriteBatch) DeleteCF(cf ColumnFamily, key []byte) {
+	writeBatch.batch.Delete(asLevelDBColumnFamily(cf).dbKey(key))
+}
+
+func (writeBatch *levelDBWriteBatch) Destroy() {
+	writeBatch.batch.Reset()
+}
+
+func (driver *levelDBDriver) NewWriteBatch() WriteBatch {
+	return &levelDBWriteBatch{new(leveldb.Batch)}
+}
+
+func (driver *levelDBDriver) Write(writeBatch WriteBatch) error {
+	return driver.db.Write(writeBatch.(*levelDBWriteBatch).batch, nil)
+}
+
+// levelDBIterator is restricted to the keys of one column family and strips
+// the column family prefix from them
+type levelDBIterator struct {
+	cf  *levelDBColumnFamily
+	itr iterator.Iterator
+}
+
+func (itr *levelDBIterator) Seek(key []byte) {
+	itr.itr.Seek(itr.cf.dbKey(key))
+}
+
+func (itr *levelDBIterator) SeekToFirst() {
+	itr.itr.First()
+}
+
+func (itr *levelDBIterator) Valid() bool {
+	return itr.itr.Valid()
+}
+
+func (itr *levelDBIterator) ValidForPrefix(prefix []byte) bool {
+	return itr.Valid() && bytes.HasPrefix(itr.Key(), prefix)
+}
+
+func (itr *levelDBIterator) Next() {
+	itr.itr.Next()
+}
+
+func (itr *levelDBIterator) Prev() {
+	itr.itr.Prev()
+}
+
+func (itr *levelDBIterator) Key() []byte {
+	return itr.itr.Key()[len(itr.cf.prefix):]
+}
+
+func (itr *levelDBIterator) Value() []byte {
+	return itr.itr.Value()
+}
+
+func (itr *levelDBIterator) Close() {
+	itr.itr.Release()
+}
+
+func (driver *levelDBDriver) NewIterator(cf ColumnFamily) Iterator {
+	levelDBCF := asLevelDBColumnFamily(cf)
+	return &levelDBIterator{levelDBCF, driver.db.NewIterator(util.BytesPrefix(levelDBCF.prefix), nil)}
+}
+
+type levelDBSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
+func (snapshot *levelDBSnapshot) Get(cf ColumnFamily, key []byte) ([]byte, error) {
+	value, err := snapshot.snapshot.Get(asLevelDBColumnFamily(cf).dbKey(key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (snapshot *levelDBSnapshot) NewIterator(cf ColumnFamily) Iterator {
+	levelDBCF := asLevelDBColumnFamily(cf)
+	return &levelDBIterator{levelDBCF, snapshot.snapshot.NewIterator(util.BytesPrefix(levelDBCF.prefix), nil)}
+}
+
+func (snapshot *levelDBSnapshot) Release() {
+	snapshot.snapshot.Release()
+}
+
+func (driver *levelDBDriver) NewSnapshot() Snapshot {
+	snapshot, err := driver.db.GetSnapshot()
+	if err != nil {
+		// GetSnapshot only fails once the DB is closed
+		panic(err)
+	}
+	return &levelDBSnapshot{snapshot}
+}
+
+// ClearColumnFamily deletes the keys of the column family in batches, the
+// column family handle stays valid
+func (driver *levelDBDriver) ClearColumnFamily(cf ColumnFamily) (ColumnFamily, error) {
+	levelDBCF := asLevelDBColumnFamily(cf)
+	itr := driver.db.NewIterator(util.BytesPrefix(levelDBCF.prefix), nil)
+	defer itr.Release()
+	batch := new(leveldb.Batch)
+	for itr.Next() {
+		batch.Delete(append([]byte{}, itr.Key()...))
+		if batch.Len() == levelDBClearBatchSize {
+			if err := driver.db.Write(batch, nil); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return nil, err
+	}
+	if err := driver.db.Write(batch, nil); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// CompactRange implements method in interface 'Compactor'
+func (driver *levelDBDriver) CompactRange(cf ColumnFamily, start []byte, limit []byte) error {
+	levelDBCF := asLevelDBColumnFamily(cf)
+	return driver.db.CompactRange(util.Range{Start: levelDBCF.dbKey(start), Limit: levelDBCF.dbKey(limit)})
+}
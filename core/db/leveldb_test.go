@@ -0,0 +1,89 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLevelDBDriverColumnFamilies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabric-leveldb-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	driver, err := newDriver(DriverLevelDB)
+	if err != nil {
+		t.Fatalf("Error creating driver: %s", err)
+	}
+	cfs, err := driver.Open(dir, []string{"cf1", "cf2"})
+	if err != nil {
+		t.Fatalf("Error opening driver: %s", err)
+	}
+	defer driver.Close()
+
+	writeBatch := driver.NewWriteBatch()
+	writeBatch.PutCF(cfs[0], []byte("key1"), []byte("value1"))
+	writeBatch.PutCF(cfs[0], []byte("key2"), []byte("value2"))
+	writeBatch.PutCF(cfs[1], []byte("key1"), []byte("other"))
+	if err := driver.Write(writeBatch); err != nil {
+		t.Fatalf("Error writing batch: %s", err)
+	}
+	writeBatch.Destroy()
+
+	value, err := driver.Get(cfs[1], []byte("key1"))
+	if err != nil || !bytes.Equal(value, []byte("other")) {
+		t.Fatalf("Expected value [other] in cf2, got [%s], err %v", value, err)
+	}
+	value, err = driver.Get(cfs[1], []byte("key2"))
+	if err != nil || value != nil {
+		t.Fatalf("Expected no value for key2 in cf2, got [%s], err %v", value, err)
+	}
+
+	snapshot := driver.NewSnapshot()
+	defer snapshot.Release()
+
+	itr := driver.NewIterator(cfs[0])
+	var keys []string
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.Key()))
+	}
+	itr.Close()
+	if len(keys) != 2 || keys[0] != "key1" || keys[1] != "key2" {
+		t.Fatalf("Expected keys [key1 key2] in cf1, got %v", keys)
+	}
+
+	if _, err := driver.ClearColumnFamily(cfs[0]); err != nil {
+		t.Fatalf("Error clearing column family: %s", err)
+	}
+	itr = driver.NewIterator(cfs[0])
+	itr.SeekToFirst()
+	if itr.Valid() {
+		t.Fatalf("Expected cf1 to be empty, found key [%s]", itr.Key())
+	}
+	itr.Close()
+	if value, _ := driver.Get(cfs[1], []byte("key1")); value == nil {
+		t.Fatal("Clearing cf1 should not have cleared cf2")
+	}
+	if value, _ := snapshot.Get(cfs[0], []byte("key2")); !bytes.Equal(value, []byte("value2")) {
+		t.Fatalf("Expected value [value2] in the snapshot, got [%s]", value)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+// dbProperties are the properties of the column families of the driver
+// exported as metrics, if the driver reads them. They are read when the
+// metrics are exported rather than kept up to date.
+var dbProperties = []struct {
+	metric   string
+	help     string
+	property string
+}{
+	{"fabric_rocksdb_estimated_keys", "Estimated number of keys, by ledger and column family", "rocksdb.estimate-num-keys"},
+	{"fabric_rocksdb_live_data_bytes", "Estimated size of the live data, by ledger and column family", "rocksdb.estimate-live-data-size"},
+	{"fabric_rocksdb_sst_files_bytes", "Total size of the SST files, by ledger and column family", "rocksdb.total-sst-files-size"},
+	{"fabric_rocksdb_memtables_bytes", "Size of the memtables, by ledger and column family", "rocksdb.cur-size-all-mem-tables"},
+}
+
+func init() {
+	for _, p := range dbProperties {
+		property := p.property
+		metrics.MustRegister(metrics.NewGaugeFunc(p.metric, p.help, func() []metrics.Sample {
+			return readDBProperty(openDBs(), property)
+		}, "ledger", "cf"))
+	}
+}
+
+// openDBs returns the open DBs, the default one first
+func openDBs() []*OpenchainDB {
+	var dbs []*OpenchainDB
+	if isOpen {
+		dbs = append(dbs, openchainDB)
+	}
+	ledgerDBsLock.Lock()
+	defer ledgerDBsLock.Unlock()
+	for _, ledgerDB := range ledgerDBs {
+		dbs = append(dbs, ledgerDB)
+	}
+	return dbs
+}
+
+// readDBProperty reads the property of the column families of the DBs whose
+// driver reads it
+func readDBProperty(dbs []*OpenchainDB, property string) []metrics.Sample {
+	var samples []metrics.Sample
+	for _, openchainDB := range dbs {
+		propertyReader, ok := openchainDB.driver.(PropertyReader)
+		if !ok {
+			continue
+		}
+		for _, cf := range []ColumnFamily{openchainDB.BlockchainCF, openchainDB.StateCF, openchainDB.StateDeltaCF,
+			openchainDB.IndexesCF, openchainDB.PersistCF, openchainDB.PrivateStateCF} {
+			value, err := strconv.ParseFloat(propertyReader.GetProperty(cf, property), 64)
+			if err != nil {
+				continue
+			}
+			samples = append(samples, metrics.Sample{Labels: []string{openchainDB.ledgerID, cf.Name()}, Value: value})
+		}
+	}
+	return samples
+}
@@ -0,0 +1,235 @@
+// +build cgo
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"bytes"
+
+	"github.com/tecbot/gorocksdb"
+)
+
+func init() {
+	registerDriver(DriverRocksDB, func() Driver { return &RocksDBDriver{} })
+}
+
+// RocksDBDriver keeps the DB in RocksDB, with a RocksDB column family for
+// every column family. It needs cgo.
+type RocksDBDriver struct {
+	DB  *gorocksdb.DB
+	cfs map[string]*rocksDBColumnFamily
+}
+
+type rocksDBColumnFamily struct {
+	name   string
+	handle *gorocksdb.ColumnFamilyHandle
+}
+
+func (cf *rocksDBColumnFamily) Name() string {
+	return cf.name
+}
+
+// ColumnFamilyHandle returns the RocksDB handle of cf, for tools inspecting
+// RocksDB specific properties
+func (driver *RocksDBDriver) ColumnFamilyHandle(cf ColumnFamily) *gorocksdb.ColumnFamilyHandle {
+	return cf.(*rocksDBColumnFamily).handle
+}
+
+// Open implements method in interface 'Driver'
+func (driver *RocksDBDriver) Open(dbPath string, cfNames []string) ([]ColumnFamily, error) {
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+
+	opts.SetCreateIfMissing(true)
+	opts.SetCreateIfMissingColumnFamilies(true)
+
+	allCFNames := append([]string{"default"}, cfNames...)
+	var cfOpts []*gorocksdb.Options
+	for range allCFNames {
+		cfOpts = append(cfOpts, opts)
+	}
+
+	db, cfHandlers, err := gorocksdb.OpenDbColumnFamilies(opts, dbPath, allCFNames, cfOpts)
+	if err != nil {
+		return nil, err
+	}
+	driver.DB = db
+	driver.cfs = make(map[string]*rocksDBColumnFamily)
+	// XXX should we close cfHandlers[0]?
+	var cfs []ColumnFamily
+	for i, name := range cfNames {
+		cf := &rocksDBColumnFamily{name, cfHandlers[i+1]}
+		driver.cfs[name] = cf
+		cfs = append(cfs, cf)
+	}
+	return cfs, nil
+}
+
+// Close implements method in interface 'Driver'. The handles of the column
+// families returned by Open are released as well.
+func (driver *RocksDBDriver) Close() {
+	for _, cf := range driver.cfs {
+		cf.handle.Destroy()
+	}
+	driver.DB.Close()
+}
+
+// Get implements method in interface 'Driver'
+func (driver *RocksDBDriver) Get(cf ColumnFamily, key []byte) ([]byte, error) {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	return driver.get(opt, cf, key)
+}
+
+func (driver *RocksDBDriver) get(opt *gorocksdb.ReadOptions, cf ColumnFamily, key []byte) ([]byte, error) {
+	slice, err := driver.DB.GetCF(opt, driver.ColumnFamilyHandle(cf), key)
+	if err != nil {
+		return nil, err
+	}
+	defer slice.Free()
+	if slice.Data() == nil {
+		return nil, nil
+	}
+	return makeCopy(slice.Data()), nil
+}
+
+// Put implements method in interface 'Driver'
+func (driver *RocksDBDriver) Put(cf ColumnFamily, key []byte, value []byte) error {
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return driver.DB.PutCF(opt, driver.ColumnFamilyHandle(cf), key, value)
+}
+
+// Delete implements method in interface 'Driver'
+func (driver *RocksDBDriver) Delete(cf ColumnFamily, key []byte) error {
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return driver.DB.DeleteCF(opt, driver.ColumnFamilyHandle(cf), key)
+}
+
+type rocksDBWriteBatch struct {
+	batch *gorocksdb.WriteBatch
+}
+
+func (writeBatch *rocksDBWriteBatch) PutCF(cf ColumnFamily, key []byte, value []byte) {
+	writeBatch.batch.PutCF(cf.(*rocksDBColumnFamily).handle, key, value)
+}
+
+func (writeBatch *rocksDBWriteBatch) DeleteCF(cf ColumnFamily, key []byte) {
+	writeBatch.batch.DeleteCF(cf.(*rocksDBColumnFamily).handle, key)
+}
+
+func (writeBatch *rocksDBWriteBatch) Destroy() {
+	writeBatch.batch.Destroy()
+}
+
+// NewWriteBatch implements method in interface 'Driver'
+func (driver *RocksDBDriver) NewWriteBatch() WriteBatch {
+	return &rocksDBWriteBatch{gorocksdb.NewWriteBatch()}
+}
+
+// Write implements method in interface 'Driver'
+func (driver *RocksDBDriver) Write(writeBatch WriteBatch) error {
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return driver.DB.Write(opt, writeBatch.(*rocksDBWriteBatch).batch)
+}
+
+type rocksDBIterator struct {
+	*gorocksdb.Iterator
+}
+
+func (itr *rocksDBIterator) Key() []byte {
+	return itr.Iterator.Key().Data()
+}
+
+func (itr *rocksDBIterator) Value() []byte {
+	return itr.Iterator.Value().Data()
+}
+
+func (itr *rocksDBIterator) ValidForPrefix(prefix []byte) bool {
+	return itr.Valid() && bytes.HasPrefix(itr.Key(), prefix)
+}
+
+// NewIterator implements method in interface 'Driver'
+func (driver *RocksDBDriver) NewIterator(cf ColumnFamily) Iterator {
+	opt := gorocksdb.NewDefaultReadOptions()
+	opt.SetFillCache(true)
+	defer opt.Destroy()
+	return &rocksDBIterator{driver.DB.NewIteratorCF(opt, driver.ColumnFamilyHandle(cf))}
+}
+
+type rocksDBSnapshot struct {
+	driver   *RocksDBDriver
+	snapshot *gorocksdb.Snapshot
+}
+
+func (snapshot *rocksDBSnapshot) Get(cf ColumnFamily, key []byte) ([]byte, error) {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	opt.SetSnapshot(snapshot.snapshot)
+	return snapshot.driver.get(opt, cf, key)
+}
+
+func (snapshot *rocksDBSnapshot) NewIterator(cf ColumnFamily) Iterator {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	opt.SetSnapshot(snapshot.snapshot)
+	return &rocksDBIterator{snapshot.driver.DB.NewIteratorCF(opt, snapshot.driver.ColumnFamilyHandle(cf))}
+}
+
+func (snapshot *rocksDBSnapshot) Release() {
+	snapshot.snapshot.Release()
+}
+
+// NewSnapshot implements method in interface 'Driver'
+func (driver *RocksDBDriver) NewSnapshot() Snapshot {
+	return &rocksDBSnapshot{driver, driver.DB.NewSnapshot()}
+}
+
+// ClearColumnFamily implements method in interface 'Driver' by dropping and
+// recreating the column family
+func (driver *RocksDBDriver) ClearColumnFamily(cf ColumnFamily) (ColumnFamily, error) {
+	if err := driver.DB.DropColumnFamily(driver.ColumnFamilyHandle(cf)); err != nil {
+		return nil, err
+	}
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+	handle, err := driver.DB.CreateColumnFamily(opts, cf.Name())
+	if err != nil {
+		delete(driver.cfs, cf.Name())
+		return nil, err
+	}
+	newCF := &rocksDBColumnFamily{cf.Name(), handle}
+	driver.cfs[cf.Name()] = newCF
+	return newCF, nil
+}
+
+// GetProperty implements method in interface 'PropertyReader'
+func (driver *RocksDBDriver) GetProperty(cf ColumnFamily, name string) string {
+	if cf == nil {
+		return driver.DB.GetProperty(name)
+	}
+	return driver.DB.GetPropertyCF(name, driver.ColumnFamilyHandle(cf))
+}
+
+// CompactRange implements method in interface 'Compactor'
+func (driver *RocksDBDriver) CompactRange(cf ColumnFamily, start []byte, limit []byte) error {
+	driver.DB.CompactRangeCF(driver.ColumnFamilyHandle(cf), gorocksdb.Range{Start: start, Limit: limit})
+	return nil
+}
@@ -19,22 +19,33 @@ package core
 import (
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 
+	"google/protobuf"
+
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/chaincode/platforms"
 	"github.com/hyperledger/fabric/core/container"
 	crypto "github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/quorum"
+	sysccapi "github.com/hyperledger/fabric/core/system_chaincode/api"
 	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
 var devopsLogger = logging.MustGetLogger("devops")
 
+// IDGenerationAlgPayloadHash requests that the transaction ID of an invocation
+// is derived from its payload instead of being randomly generated
+const IDGenerationAlgPayloadHash = "payloadHash"
+
 // NewDevopsServer creates and returns a new Devops server instance.
 func NewDevopsServer(coord peer.MessageHandlerCoordinator) *Devops {
 	d := new(Devops)
@@ -67,12 +78,18 @@ func (*Devops) Build(context context.Context, spec *pb.ChaincodeSpec) (*pb.Chain
 			return nil, err
 		}
 
-		vm, err := container.NewVM()
-		if err != nil {
-			return nil, fmt.Errorf("Error getting vm")
+		var err error
+		if provider := viper.GetString("vm.provider"); provider != "" && provider != "docker" {
+			//the chaincode is built by the validators running it
+			codePackageBytes, err = container.GetChaincodePackageBytes(spec)
+		} else {
+			var vm *container.VM
+			vm, err = container.NewVM()
+			if err != nil {
+				return nil, fmt.Errorf("Error getting vm")
+			}
+			codePackageBytes, err = vm.BuildChaincodeContainer(spec)
 		}
-
-		codePackageBytes, err = vm.BuildChaincodeContainer(spec)
 		if err != nil {
 			err = fmt.Errorf("Error getting chaincode package bytes: %s", err)
 			devopsLogger.Error(fmt.Sprintf("%s", err))
@@ -115,12 +132,51 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 		return nil, err
 	}
 
+	return chaincodeDeploymentSpec, d.deploy(chaincodeDeploymentSpec)
+}
+
+// Install deploys the chaincode package of cds, built beforehand as by Build,
+// so that the chaincode is built away from the peers. A package signed by its
+// deployer keeps its signature, an unsigned one is signed with the enrollment
+// key of the user of the security context of the spec, as by Deploy.
+func (d *Devops) Install(ctx context.Context, cds *pb.ChaincodeDeploymentSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	spec := cds.ChaincodeSpec
+	if spec == nil || spec.ChaincodeID == nil || spec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("Error installing chaincode: the package does not name the chaincode")
+	}
+	if len(cds.CodePackage) == 0 {
+		return nil, fmt.Errorf("Error installing chaincode %s: the package holds no code", spec.ChaincodeID.Name)
+	}
+	if cds.ExecEnv != pb.ChaincodeDeploymentSpec_DOCKER || cds.UpgradedChaincodeName != "" {
+		return nil, fmt.Errorf("Error installing chaincode %s: the package is not a deployment package", spec.ChaincodeID.Name)
+	}
+	if viper.GetString("chaincode.mode") == chaincode.DevModeUserRunsChaincode {
+		return nil, fmt.Errorf("Error installing chaincode %s: packages are not installed when the user runs the chaincode", spec.ChaincodeID.Name)
+	}
+
+	devopsLogger.Infof("Installing chaincode %s, package hash %s", spec.ChaincodeID.Name, chaincode.CodePackageHash(cds))
+	return cds, d.deploy(cds)
+}
+
+// deploy sends the deploy transaction of cds to the validators, or its upgrade
+// transaction if cds names the chaincode it upgrades, signing the chaincode
+// package unless it is signed already
+func (d *Devops) deploy(chaincodeDeploymentSpec *pb.ChaincodeDeploymentSpec) error {
+	spec := chaincodeDeploymentSpec.ChaincodeSpec
+
 	// Now create the Transactions message and send to Peer.
 
 	transID := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
+	if sysccapi.IsSysCC(transID) {
+		return fmt.Errorf("Error deploying chaincode: %s is the name of a system chaincode", transID)
+	}
+	if err := ledger.CheckLedgerID(spec.LedgerID); err != nil {
+		return fmt.Errorf("Error deploying chaincode: %s", err)
+	}
 
 	var tx *pb.Transaction
 	var sec crypto.Client
+	var err error
 
 	if peer.SecurityEnabled() {
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
@@ -133,7 +189,20 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 		spec.SecureContext = ""
 
 		if nil != err {
-			return nil, err
+			return err
+		}
+
+		// sign the chaincode package with the enrollment key of the deployer,
+		// unless it was signed when it was packaged
+		if len(chaincodeDeploymentSpec.CodeSignature) == 0 {
+			var ecertHandler crypto.CertificateHandler
+			ecertHandler, err = sec.GetEnrollmentCertificateHandler()
+			if err != nil {
+				return err
+			}
+			if err = chaincode.SignCodePackage(ecertHandler, chaincodeDeploymentSpec); err != nil {
+				return err
+			}
 		}
 
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
@@ -141,15 +210,19 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 		}
 		tx, err = sec.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, transID, spec.Attributes...)
 		if nil != err {
-			return nil, err
+			return err
 		}
 	} else {
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
 			devopsLogger.Debugf("Creating deployment transaction (%s)", transID)
 		}
-		tx, err = pb.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, transID)
+		if chaincodeDeploymentSpec.UpgradedChaincodeName != "" {
+			tx, err = pb.NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec, transID)
+		} else {
+			tx, err = pb.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, transID)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
+			return fmt.Errorf("Error deploying chaincode: %s ", err)
 		}
 	}
 
@@ -161,34 +234,163 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 		err = fmt.Errorf(string(resp.Msg))
 	}
 
-	return chaincodeDeploymentSpec, err
+	return err
 }
 
-func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, attributes []string, invoke bool) (*pb.Response, error) {
+// ListChaincodes lists the chaincodes deployed to the chain, as committed
+func (d *Devops) ListChaincodes(ctx context.Context, empty *google_protobuf.Empty) (*pb.ChaincodeDeployments, error) {
+	ledgerObj, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting ledger: %s", err)
+	}
+	deployments, err := chaincode.GetDeployments(ledgerObj)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ChaincodeDeployments{Chaincodes: deployments}, nil
+}
 
-	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
-		return nil, fmt.Errorf("name not given for invoke/query")
+// Upgrade deploys the supplied chaincode as the new version of the deployed
+// chaincode named by spec.ChaincodeID.Name. The new version takes over the
+// state of the chaincode, and the invocations of the chaincode are sent to the
+// new version once the upgrade transaction is committed. With security
+// enabled, the validators accept the upgrade only if the package is signed by
+// the deployer of the chaincode or by one of the chaincode.upgrade.upgraders.
+func (d *Devops) Upgrade(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if spec.ChaincodeID == nil || spec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("Error upgrading chaincode: name of the chaincode to upgrade is required")
+	}
+	if viper.GetString("chaincode.mode") == chaincode.DevModeUserRunsChaincode {
+		return nil, fmt.Errorf("Error upgrading chaincode: upgrade is not supported when the user runs the chaincode")
+	}
+	// the state of a confidential chaincode is encrypted with a key of its own
+	if spec.ConfidentialityLevel == pb.ConfidentialityLevel_CONFIDENTIAL {
+		return nil, fmt.Errorf("Error upgrading chaincode: confidential chaincodes cannot be upgraded")
 	}
 
-	// Now create the Transactions message and send to Peer.
-	uuid := util.GenerateUUID()
-	var transaction *pb.Transaction
-	var err error
-	var sec crypto.Client
+	// the name of the chaincode to upgrade is replaced by the name of the
+	// new version
+	upgradedChaincodeName := spec.ChaincodeID.Name
+	chaincodeDeploymentSpec, err := d.getChaincodeBytes(ctx, spec)
+	if err != nil {
+		devopsLogger.Error(fmt.Sprintf("Error upgrading chaincode spec: %v\n\n error: %s", spec, err))
+		return nil, err
+	}
+	chaincodeDeploymentSpec.UpgradedChaincodeName = upgradedChaincodeName
+
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Upgrading chaincode %s to %s", upgradedChaincodeName, chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
+	}
+	return chaincodeDeploymentSpec, d.deploy(chaincodeDeploymentSpec)
+}
+
+// Reconfigure submits a transaction reconfiguring the validating peers taking
+// part in consensus to the supplied validator set, signed by the consensus
+// admin named by its secure context. The consensus plugin applies it once
+// every replica has executed it, at a stable checkpoint.
+func (d *Devops) Reconfigure(ctx context.Context, validatorSet *pb.ValidatorSet) (*pb.Response, error) {
+	if validatorSet.N < 3*validatorSet.F+1 {
+		return nil, fmt.Errorf("Error reconfiguring: %d validating peers cannot tolerate %d faults, at least %d are required", validatorSet.N, validatorSet.F, 3*validatorSet.F+1)
+	}
+	if !peer.SecurityEnabled() {
+		return nil, fmt.Errorf("Error reconfiguring: reconfiguration requires security enabled")
+	}
+	if validatorSet.SecureContext == "" {
+		return nil, fmt.Errorf("Error reconfiguring: must supply the username of the admin signing the reconfiguration")
+	}
+
+	sec, err := crypto.InitClient(validatorSet.SecureContext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error reconfiguring: %s", err)
+	}
+	defer crypto.CloseClient(sec)
+	unsigned := &pb.ValidatorSet{N: validatorSet.N, F: validatorSet.F}
+	tx, err := sec.NewReconfigureTransaction(unsigned, util.GenerateUUID(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error reconfiguring: %s", err)
+	}
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Sending reconfiguration transaction (%s) to N=%d, f=%d to validator", tx.Uuid, validatorSet.N, validatorSet.F)
+	}
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}, nil
+}
+
+// UpdateRevocationList submits a transaction replacing the certificate
+// revocation list of the ECA, which the ECA pushes when it publishes one. The
+// list is signed by the ECA, so it is not submitted on behalf of a user: the
+// signature is verified by the security layer of every validating peer, on
+// receipt and before it is stored in the state.
+func (d *Devops) UpdateRevocationList(ctx context.Context, revocationList *pb.RevocationList) (*pb.Response, error) {
+	if !peer.SecurityEnabled() {
+		return nil, fmt.Errorf("Error updating the revocation list: revocation requires security enabled")
+	}
+
+	tx, err := pb.NewRevocationListTransaction(revocationList, util.GenerateUUID())
+	if err != nil {
+		return nil, fmt.Errorf("Error updating the revocation list: %s", err)
+	}
+	if _, err := d.coord.GetSecHelper().TransactionPreValidation(tx); err != nil {
+		return nil, fmt.Errorf("Error updating the revocation list: %s", err)
+	}
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Sending revocation list transaction (%s) to validator", tx.Uuid)
+	}
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}, nil
+}
+
+// Submit sends a transaction built and signed by the client, which holds its
+// own keys, to consensus, rather than one built for a user logged in on this
+// peer. With security enabled, the transaction must carry its certificate and
+// be signed with its key, which is verified before sending it. The response
+// of a query is its result, encrypted if the query is confidential, that of
+// the other transactions their UUID.
+func (d *Devops) Submit(ctx context.Context, tx *pb.Transaction) (*pb.Response, error) {
+	switch tx.Type {
+	case pb.Transaction_CHAINCODE_DEPLOY, pb.Transaction_CHAINCODE_UPGRADE, pb.Transaction_CHAINCODE_INVOKE, pb.Transaction_CHAINCODE_QUERY:
+	default:
+		return nil, fmt.Errorf("Error submitting transaction: unexpected transaction type %s", tx.Type)
+	}
+	if tx.Uuid == "" {
+		return nil, fmt.Errorf("Error submitting transaction: UUID not given")
+	}
+	if err := ledger.CheckLedgerID(tx.LedgerID); err != nil {
+		return nil, fmt.Errorf("Error submitting transaction (%s): %s", tx.Uuid, err)
+	}
 	if peer.SecurityEnabled() {
-		if devopsLogger.IsEnabledFor(logging.DEBUG) {
-			devopsLogger.Debugf("Initializing secure devops using context %s", chaincodeInvocationSpec.ChaincodeSpec.SecureContext)
+		if tx.Cert == nil || tx.Signature == nil {
+			return nil, fmt.Errorf("Error submitting transaction (%s): the transaction must be signed when security is enabled", tx.Uuid)
 		}
-		sec, err = crypto.InitClient(chaincodeInvocationSpec.ChaincodeSpec.SecureContext, nil)
-		defer crypto.CloseClient(sec)
-		// remove the security context since we are no longer need it down stream
-		chaincodeInvocationSpec.ChaincodeSpec.SecureContext = ""
-		if nil != err {
-			return nil, err
+		if _, err := d.coord.GetSecHelper().TransactionPreValidation(tx); err != nil {
+			return nil, fmt.Errorf("Error submitting transaction (%s): invalid signature: %s", tx.Uuid, err)
 		}
 	}
 
-	transaction, err = d.createExecTx(chaincodeInvocationSpec, attributes, uuid, invoke, sec)
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Sending client transaction (%s) to validator", tx.Uuid)
+	}
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	if tx.Type == pb.Transaction_CHAINCODE_QUERY {
+		return resp, nil
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}, nil
+}
+
+func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, attributes []string, invoke bool) (*pb.Response, error) {
+	transaction, sec, err := d.newInvocationTx(chaincodeInvocationSpec, attributes, invoke)
+	if nil != sec {
+		defer crypto.CloseClient(sec)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +411,56 @@ func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.
 	return resp, err
 }
 
+// newInvocationTx creates the transaction of an invocation or query, signed
+// with the secure context of the spec if security is enabled. The client of
+// the secure context is returned even on error, and must then be closed.
+func (d *Devops) newInvocationTx(chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, attributes []string, invoke bool) (*pb.Transaction, crypto.Client, error) {
+	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
+		return nil, nil, fmt.Errorf("name not given for invoke/query")
+	}
+	if err := ledger.CheckLedgerID(chaincodeInvocationSpec.ChaincodeSpec.LedgerID); err != nil {
+		return nil, nil, err
+	}
+
+	// Now create the Transactions message and send to Peer.
+	uuid, err := generateInvocationUUID(chaincodeInvocationSpec)
+	if err != nil {
+		return nil, nil, err
+	}
+	var sec crypto.Client
+	if peer.SecurityEnabled() {
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debugf("Initializing secure devops using context %s", chaincodeInvocationSpec.ChaincodeSpec.SecureContext)
+		}
+		sec, err = crypto.InitClient(chaincodeInvocationSpec.ChaincodeSpec.SecureContext, nil)
+		// remove the security context since we are no longer need it down stream
+		chaincodeInvocationSpec.ChaincodeSpec.SecureContext = ""
+		if nil != err {
+			return nil, sec, err
+		}
+	}
+
+	transaction, err := d.createExecTx(chaincodeInvocationSpec, attributes, uuid, invoke, sec)
+	return transaction, sec, err
+}
+
+// generateInvocationUUID returns the transaction ID for an invocation as requested by its idGenerationAlg.
+// Deploy transactions do not need this, their ID is the chaincode name which is already derived from the code
+func generateInvocationUUID(spec *pb.ChaincodeInvocationSpec) (string, error) {
+	switch spec.IdGenerationAlg {
+	case "":
+		return util.GenerateUUID(), nil
+	case IDGenerationAlgPayloadHash:
+		input, err := proto.Marshal(spec.ChaincodeSpec.CtorMsg)
+		if err != nil {
+			return "", fmt.Errorf("Error marshalling chaincode input: %s", err)
+		}
+		return util.GenerateUUIDFromBytes(append([]byte(spec.ChaincodeSpec.ChaincodeID.Name), input...)), nil
+	default:
+		return "", fmt.Errorf("Unknown ID generation algorithm [%s]", spec.IdGenerationAlg)
+	}
+}
+
 func (d *Devops) createExecTx(spec *pb.ChaincodeInvocationSpec, attributes []string, uuid string, invokeTx bool, sec crypto.Client) (*pb.Transaction, error) {
 	var tx *pb.Transaction
 	var err error
@@ -254,6 +506,138 @@ func (d *Devops) Query(ctx context.Context, chaincodeInvocationSpec *pb.Chaincod
 	return d.invokeOrQuery(ctx, chaincodeInvocationSpec, chaincodeInvocationSpec.ChaincodeSpec.Attributes, false)
 }
 
+// InvokeBatch performs each supplied invocation through a transaction. The
+// transactions are all created before any is sent, and then sent together,
+// at most chaincode.invokeBatch.maxInFlight at a time, so that consensus
+// receives them as a single burst. An invocation failing does not fail the
+// others, the response of each is in the order of the invocations, its msg
+// being the transaction UUID or the error.
+func (d *Devops) InvokeBatch(ctx context.Context, batch *pb.ChaincodeInvocationBatch) (*pb.BatchResponse, error) {
+	maxSize := viper.GetInt("chaincode.invokeBatch.maxSize")
+	if maxSize > 0 && len(batch.Invocations) > maxSize {
+		return nil, fmt.Errorf("Batch of %d invocations exceeds the maximum of %d", len(batch.Invocations), maxSize)
+	}
+
+	responses := make([]*pb.Response, len(batch.Invocations))
+	transactions := make([]*pb.Transaction, len(batch.Invocations))
+	for i, spec := range batch.Invocations {
+		if spec.ChaincodeSpec == nil || spec.ChaincodeSpec.ChaincodeID == nil {
+			responses[i] = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("chaincodeID not given for invoke")}
+			continue
+		}
+		tx, sec, err := d.newInvocationTx(spec, spec.ChaincodeSpec.Attributes, true)
+		if nil != sec {
+			crypto.CloseClient(sec)
+		}
+		if err != nil {
+			responses[i] = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+			continue
+		}
+		transactions[i] = tx
+	}
+
+	maxInFlight := viper.GetInt("chaincode.invokeBatch.maxInFlight")
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	inFlight := make(chan struct{}, maxInFlight)
+	var wg sync.WaitGroup
+	for i, tx := range transactions {
+		if tx == nil {
+			continue
+		}
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			responses[i] = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Transaction not sent: %s", ctx.Err()))}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, tx *pb.Transaction) {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+			if resp := d.coord.ExecuteTransaction(tx); resp.Status == pb.Response_FAILURE {
+				responses[i] = resp
+			} else {
+				responses[i] = &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}
+			}
+		}(i, tx)
+	}
+	wg.Wait()
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Sent a batch of %d invocations to validator", len(batch.Invocations))
+	}
+	return &pb.BatchResponse{Responses: responses}, nil
+}
+
+// QueryQuorum performs the supplied query on this peer and on the validating
+// peers it is connected to, and returns the result once f+1 of them returned
+// the same, f being the number of faulty validating peers tolerated by the
+// network. With security enabled, the user must be logged in on every peer.
+func (d *Devops) QueryQuorum(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	peers, err := d.coord.GetPeers()
+	if err != nil {
+		return nil, fmt.Errorf("Error querying: could not get the peers: %s", err)
+	}
+	var addresses []string
+	for _, endpoint := range peers.Peers {
+		if endpoint.Type == pb.PeerEndpoint_VALIDATOR {
+			addresses = append(addresses, endpoint.Address)
+		}
+	}
+	validators := len(addresses)
+	if peer.ValidatorEnabled() {
+		validators++
+	}
+
+	queries, conns, err := quorum.Dial(addresses)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	queries = append(queries, d.Query)
+
+	f := quorum.FaultsTolerated(validators)
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debugf("Querying %d peers of a network of %d validating peers for a quorum of %d", len(queries), validators, f+1)
+	}
+	return quorum.Query(ctx, chaincodeInvocationSpec, f+1, queries)
+}
+
+// QueryStream performs the supplied query on the specified chaincode and streams
+// the result in chunks of at most chaincode.queryChunkSize bytes, so that large
+// results do not have to fit in a single message
+func (d *Devops) QueryStream(chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, stream pb.Devops_QueryStreamServer) error {
+	resp, err := d.invokeOrQuery(stream.Context(), chaincodeInvocationSpec, chaincodeInvocationSpec.ChaincodeSpec.Attributes, false)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunkQueryResult(resp.Msg, viper.GetInt("chaincode.queryChunkSize")) {
+		if err = stream.Send(&pb.Response{Status: resp.Status, Msg: chunk}); err != nil {
+			return fmt.Errorf("Error sending query result: %s", err)
+		}
+	}
+	return nil
+}
+
+// chunkQueryResult splits result in chunks of chunkSize bytes, the last one
+// being shorter. There is always at least one chunk, and a single one if
+// chunkSize is 0.
+func chunkQueryResult(result []byte, chunkSize int) [][]byte {
+	chunks := [][]byte{}
+	for chunkSize > 0 && len(result) > chunkSize {
+		chunks = append(chunks, result[:chunkSize])
+		result = result[chunkSize:]
+	}
+	return append(chunks, result)
+}
+
 // CheckSpec to see if chaincode resides within current package capture for language.
 func CheckSpec(spec *pb.ChaincodeSpec) error {
 	// Don't allow nil value
@@ -17,10 +17,18 @@ limitations under the License.
 package core
 
 import (
+	"bytes"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/peer"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -92,3 +100,245 @@ func TestDevops_Spec_NoVersion(t *testing.T) {
 	t.Logf("Deploy result = %s, err = %s", buildResult, err)
 	//performHandshake(t, peerClientConn)
 }
+
+func TestDevops_GenerateInvocationUUID(t *testing.T) {
+	spec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		ChaincodeID: &pb.ChaincodeID{Name: "mycc"},
+		CtorMsg:     &pb.ChaincodeInput{Function: "invoke", Args: []string{"a", "b", "10"}}}}
+
+	uuid1, err := generateInvocationUUID(spec)
+	if err != nil {
+		t.Fatalf("Error generating random UUID: %s", err)
+	}
+	uuid2, _ := generateInvocationUUID(spec)
+	if uuid1 == uuid2 {
+		t.Fatalf("Expected random UUIDs to be different")
+	}
+
+	spec.IdGenerationAlg = IDGenerationAlgPayloadHash
+	uuid1, err = generateInvocationUUID(spec)
+	if err != nil {
+		t.Fatalf("Error generating UUID from payload: %s", err)
+	}
+	uuid2, _ = generateInvocationUUID(spec)
+	if uuid1 != uuid2 {
+		t.Fatalf("Expected UUIDs derived from the same payload to be equal")
+	}
+	spec.ChaincodeSpec.CtorMsg.Args[2] = "20"
+	if uuid2, _ = generateInvocationUUID(spec); uuid1 == uuid2 {
+		t.Fatalf("Expected UUIDs derived from different payloads to be different")
+	}
+
+	spec.IdGenerationAlg = "unknown"
+	if _, err = generateInvocationUUID(spec); err == nil {
+		t.Fatalf("Expected error for unknown ID generation algorithm")
+	}
+}
+
+func TestDevops_ChunkQueryResult(t *testing.T) {
+	result := []byte("0123456789")
+	chunks := chunkQueryResult(result, 4)
+	if len(chunks) != 3 || len(chunks[2]) != 2 {
+		t.Fatalf("Expected chunks of 4, 4 and 2 bytes, got %q", chunks)
+	}
+	if !bytes.Equal(bytes.Join(chunks, nil), result) {
+		t.Fatalf("Expected the chunks to add up to the result, got %q", chunks)
+	}
+	if chunks = chunkQueryResult(result, 0); len(chunks) != 1 {
+		t.Fatalf("Expected a single chunk without a chunk size, got %q", chunks)
+	}
+	if chunks = chunkQueryResult(nil, 4); len(chunks) != 1 {
+		t.Fatalf("Expected a single chunk for an empty result, got %q", chunks)
+	}
+}
+
+// submitCoordinator executes the transactions it is given by returning
+// their payload, and verifies their signature against the signer
+type submitCoordinator struct {
+	peer.MessageHandlerCoordinator
+	executed []*pb.Transaction
+	signer   string
+}
+
+func (c *submitCoordinator) ExecuteTransaction(tx *pb.Transaction) *pb.Response {
+	c.executed = append(c.executed, tx)
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: tx.Payload}
+}
+
+func (c *submitCoordinator) GetSecHelper() crypto.Peer {
+	return &submitSecHelper{signer: c.signer}
+}
+
+type submitSecHelper struct {
+	crypto.Peer
+	signer string
+}
+
+func (h *submitSecHelper) TransactionPreValidation(tx *pb.Transaction) (*pb.Transaction, error) {
+	if string(tx.Signature) != h.signer {
+		return nil, fmt.Errorf("signature of %s", tx.Signature)
+	}
+	return tx, nil
+}
+
+func TestDevops_Submit(t *testing.T) {
+	coord := &submitCoordinator{signer: "alice"}
+	devopsServer := NewDevopsServer(coord)
+
+	invoke := &pb.Transaction{Type: pb.Transaction_CHAINCODE_INVOKE, Uuid: "invoke", Payload: []byte("args")}
+	if resp, err := devopsServer.Submit(context.Background(), invoke); err != nil || string(resp.Msg) != "invoke" {
+		t.Fatalf("Expected an invocation to return its UUID, got %v (%v)", resp, err)
+	}
+	query := &pb.Transaction{Type: pb.Transaction_CHAINCODE_QUERY, Uuid: "query", Payload: []byte("result")}
+	if resp, err := devopsServer.Submit(context.Background(), query); err != nil || string(resp.Msg) != "result" {
+		t.Fatalf("Expected a query to return its result, got %v (%v)", resp, err)
+	}
+	for _, tx := range []*pb.Transaction{
+		{Type: pb.Transaction_CHAINCODE_INVOKE},
+		{Type: pb.Transaction_CONSENSUS_RECONFIGURE, Uuid: "reconfigure"},
+	} {
+		if _, err := devopsServer.Submit(context.Background(), tx); err == nil {
+			t.Fatalf("Expected %s to be rejected", tx)
+		}
+	}
+
+	viper.Set("security.enabled", true)
+	peer.CacheConfiguration()
+	defer func() {
+		viper.Set("security.enabled", false)
+		peer.CacheConfiguration()
+	}()
+	coord.executed = nil
+	for _, tx := range []*pb.Transaction{
+		{Type: pb.Transaction_CHAINCODE_INVOKE, Uuid: "unsigned"},
+		{Type: pb.Transaction_CHAINCODE_INVOKE, Uuid: "forged", Cert: []byte("cert"), Signature: []byte("mallory")},
+	} {
+		if _, err := devopsServer.Submit(context.Background(), tx); err == nil {
+			t.Fatalf("Expected the %s transaction to be rejected", tx.Uuid)
+		}
+	}
+	signed := &pb.Transaction{Type: pb.Transaction_CHAINCODE_INVOKE, Uuid: "signed", Cert: []byte("cert"), Signature: []byte("alice")}
+	if _, err := devopsServer.Submit(context.Background(), signed); err != nil || len(coord.executed) != 1 {
+		t.Fatalf("Expected the signed transaction alone to be executed, got %v (%v)", coord.executed, err)
+	}
+}
+
+// batchCoordinator fails the transactions of the chaincode named fail, and
+// records the most transactions executed at once
+type batchCoordinator struct {
+	peer.MessageHandlerCoordinator
+	sync.Mutex
+	inFlight    int
+	maxInFlight int
+	executed    int
+}
+
+func (c *batchCoordinator) ExecuteTransaction(tx *pb.Transaction) *pb.Response {
+	c.Lock()
+	c.inFlight++
+	c.executed++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.Unlock()
+	time.Sleep(time.Millisecond)
+	c.Lock()
+	c.inFlight--
+	c.Unlock()
+
+	spec := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(tx.Payload, spec); err != nil || spec.ChaincodeSpec.ChaincodeID.Name == "fail" {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("failed")}
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}
+}
+
+func TestDevops_InvokeBatch(t *testing.T) {
+	viper.Set("chaincode.invokeBatch.maxSize", 10)
+	viper.Set("chaincode.invokeBatch.maxInFlight", 2)
+	defer func() {
+		viper.Set("chaincode.invokeBatch.maxSize", 500)
+		viper.Set("chaincode.invokeBatch.maxInFlight", 100)
+	}()
+	coord := &batchCoordinator{}
+	devopsServer := NewDevopsServer(coord)
+
+	invocation := func(name string) *pb.ChaincodeInvocationSpec {
+		return &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+			Type:        pb.ChaincodeSpec_GOLANG,
+			ChaincodeID: &pb.ChaincodeID{Name: name},
+			CtorMsg:     &pb.ChaincodeInput{Function: "invoke"},
+		}}
+	}
+	batch := &pb.ChaincodeInvocationBatch{}
+	for _, name := range []string{"mycc", "mycc", "fail", "", "mycc", "mycc"} {
+		batch.Invocations = append(batch.Invocations, invocation(name))
+	}
+	batch.Invocations = append(batch.Invocations, &pb.ChaincodeInvocationSpec{})
+
+	resp, err := devopsServer.InvokeBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("Error invoking the batch: %s", err)
+	}
+	if len(resp.Responses) != len(batch.Invocations) {
+		t.Fatalf("Expected %d responses, got %d", len(batch.Invocations), len(resp.Responses))
+	}
+	uuids := make(map[string]bool)
+	for i, r := range resp.Responses {
+		failed := i == 2 || i == 3 || i == 6
+		if failed != (r.Status == pb.Response_FAILURE) {
+			t.Errorf("Expected invocation %d to fail: %t, got %s", i, failed, r)
+		}
+		if !failed {
+			uuids[string(r.Msg)] = true
+		}
+	}
+	if len(uuids) != 4 {
+		t.Errorf("Expected 4 distinct transaction UUIDs, got %v", uuids)
+	}
+	if coord.executed != 5 || coord.maxInFlight > 2 {
+		t.Errorf("Expected 5 transactions executed at most 2 at a time, got %d at most %d at a time", coord.executed, coord.maxInFlight)
+	}
+
+	for len(batch.Invocations) <= 10 {
+		batch.Invocations = append(batch.Invocations, invocation("mycc"))
+	}
+	if _, err = devopsServer.InvokeBatch(context.Background(), batch); err == nil {
+		t.Fatalf("Expected a batch larger than the maximum to be rejected")
+	}
+}
+
+func TestDevops_Install(t *testing.T) {
+	coord := &submitCoordinator{}
+	devopsServer := NewDevopsServer(coord)
+
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: "mycc", Name: "hash"}, CtorMsg: &pb.ChaincodeInput{Function: "init"}}
+	signed := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: []byte("package"), DeployerCert: []byte("cert"), CodeSignature: []byte("alice")}
+	if _, err := devopsServer.Install(context.Background(), signed); err != nil {
+		t.Fatalf("Error installing the package: %s", err)
+	}
+	if len(coord.executed) != 1 || coord.executed[0].Type != pb.Transaction_CHAINCODE_DEPLOY || coord.executed[0].Uuid != "hash" {
+		t.Fatalf("Expected the deploy transaction of the package to be executed, got %v", coord.executed)
+	}
+	deployed := &pb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(coord.executed[0].Payload, deployed); err != nil {
+		t.Fatalf("Error unmarshalling the deployed package: %s", err)
+	}
+	if !bytes.Equal(deployed.CodePackage, signed.CodePackage) || !bytes.Equal(deployed.CodeSignature, signed.CodeSignature) {
+		t.Fatalf("Expected the package to be deployed with its signature, got %v", deployed)
+	}
+
+	for _, cds := range []*pb.ChaincodeDeploymentSpec{
+		{},
+		{ChaincodeSpec: spec},
+		{ChaincodeSpec: spec, CodePackage: []byte("package"), UpgradedChaincodeName: "oldcc"},
+		{ChaincodeSpec: spec, CodePackage: []byte("package"), ExecEnv: pb.ChaincodeDeploymentSpec_SYSTEM},
+	} {
+		if _, err := devopsServer.Install(context.Background(), cds); err == nil {
+			t.Errorf("Expected package %v to be rejected", cds)
+		}
+	}
+	if len(coord.executed) != 1 {
+		t.Fatalf("Expected the rejected packages not to be deployed, got %v", coord.executed)
+	}
+}
@@ -19,31 +19,164 @@ package core
 import (
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/hyperledger/fabric/core/comm"
+	d "github.com/hyperledger/fabric/discovery"
 )
 
 // StaticDiscovery is an implementation of Discovery
 type StaticDiscovery struct {
-	rootNodes []string
-	random    *rand.Rand
+	sync.Mutex
+	rootNodes       []string
+	discoveredNodes []string
+	random          *rand.Rand
+	staticNodes     []string   // the configured root nodes, if there is a provider
+	provider        d.Provider // nil unless the root nodes are looked up
 }
 
 // NewStaticDiscovery is a constructor of a Discovery implementation
 // Accepts as a parameter the root node configuration, which is a single node,
-// or a comma separated list of nodes with no spaces
+// or a comma separated list of nodes, IPv6 hosts being bracketed as in
+// [::1]:30303. The addresses are normalized, see comm.NormalizeAddress.
 func NewStaticDiscovery(rootNodesString string) *StaticDiscovery {
 	sd := StaticDiscovery{}
-	sd.rootNodes = strings.Split(rootNodesString, ",")
+	for _, node := range strings.Split(rootNodesString, ",") {
+		node = strings.TrimSpace(node)
+		if normalized, err := comm.NormalizeAddress(node); err == nil {
+			node = normalized
+		} else if node != "" {
+			coreLogger.Warningf("Root node %s: %s", node, err)
+		}
+		sd.rootNodes = append(sd.rootNodes, node)
+	}
 	sd.random = rand.New(rand.NewSource(time.Now().Unix()))
 	return &sd
 }
 
+// NewDiscoveryWithProvider creates a Discovery whose root nodes are those of
+// rootNodesString, as for NewStaticDiscovery, along with those the provider
+// looks up, which Refresh looks up again
+func NewDiscoveryWithProvider(rootNodesString string, provider d.Provider) *StaticDiscovery {
+	sd := NewStaticDiscovery(rootNodesString)
+	for _, node := range sd.rootNodes {
+		if node != "" {
+			sd.staticNodes = append(sd.staticNodes, node)
+		}
+	}
+	sd.provider = provider
+	if _, _, err := sd.Refresh(); err != nil {
+		coreLogger.Errorf("Error looking up the root nodes: %s", err)
+	}
+	return sd
+}
+
+// Refresh looks the root nodes up again with the provider, if any, and
+// returns the root nodes added and removed. The root nodes are kept as they
+// were if the lookup fails.
+func (sd *StaticDiscovery) Refresh() (added []string, removed []string, err error) {
+	if sd.provider == nil {
+		return nil, nil, nil
+	}
+	addresses, err := sd.provider.Lookup()
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes := append([]string{}, sd.staticNodes...)
+	for _, address := range addresses {
+		if normalized, err := comm.NormalizeAddress(address); err == nil {
+			address = normalized
+		}
+		if address != "" && !contains(nodes, address) {
+			nodes = append(nodes, address)
+		}
+	}
+
+	sd.Lock()
+	defer sd.Unlock()
+	for _, node := range nodes {
+		if !contains(sd.rootNodes, node) {
+			added = append(added, node)
+		}
+	}
+	for _, node := range sd.rootNodes {
+		if node != "" && !contains(nodes, node) {
+			removed = append(removed, node)
+		}
+	}
+	if len(nodes) == 0 {
+		// as for an empty root node configuration
+		nodes = []string{""}
+	}
+	sd.rootNodes = nodes
+	return added, removed, nil
+}
+
 // GetRandomNode returns a random root node out of the nodes the discovery was initialized with
 func (sd *StaticDiscovery) GetRandomNode() string {
+	sd.Lock()
+	defer sd.Unlock()
 	return sd.rootNodes[sd.random.Intn(len(sd.rootNodes))]
 }
 
 // GetRootNodes returns an array of all the nodes it was initialized with
 func (sd *StaticDiscovery) GetRootNodes() []string {
+	sd.Lock()
+	defer sd.Unlock()
 	return append([]string{}, sd.rootNodes...)
 }
+
+// AddNode adds the address of a discovered peer, returning false if it is a
+// root node or was added already
+func (sd *StaticDiscovery) AddNode(address string) bool {
+	if normalized, err := comm.NormalizeAddress(address); err == nil {
+		address = normalized
+	}
+	sd.Lock()
+	defer sd.Unlock()
+	if address == "" || contains(sd.rootNodes, address) || contains(sd.discoveredNodes, address) {
+		return false
+	}
+	sd.discoveredNodes = append(sd.discoveredNodes, address)
+	return true
+}
+
+// RemoveNode removes the address of a discovered peer, returning false if it
+// is not one. The root nodes are kept.
+func (sd *StaticDiscovery) RemoveNode(address string) bool {
+	if normalized, err := comm.NormalizeAddress(address); err == nil {
+		address = normalized
+	}
+	sd.Lock()
+	defer sd.Unlock()
+	for i, node := range sd.discoveredNodes {
+		if node == address {
+			sd.discoveredNodes = append(sd.discoveredNodes[:i], sd.discoveredNodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetAllNodes returns the root nodes followed by the discovered nodes
+func (sd *StaticDiscovery) GetAllNodes() []string {
+	sd.Lock()
+	defer sd.Unlock()
+	var nodes []string
+	for _, node := range sd.rootNodes {
+		if node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	return append(nodes, sd.discoveredNodes...)
+}
+
+func contains(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	d "github.com/hyperledger/fabric/discovery"
+)
+
+// The providers of peer.discovery.provider
+const (
+	DiscoveryProviderStatic     = "static"
+	DiscoveryProviderDNS        = "dns"
+	DiscoveryProviderKubernetes = "kubernetes"
+)
+
+// NewDiscoveryProvider returns the provider of the root nodes configured by
+// peer.discovery.provider, nil if the root nodes are static
+func NewDiscoveryProvider() (d.Provider, error) {
+	switch provider := strings.ToLower(viper.GetString("peer.discovery.provider")); provider {
+	case "", DiscoveryProviderStatic:
+		return nil, nil
+	case DiscoveryProviderDNS:
+		name := viper.GetString("peer.discovery.dns.name")
+		if name == "" {
+			return nil, fmt.Errorf("peer.discovery.dns.name must be set for the %s discovery provider", provider)
+		}
+		return NewDNSProvider(name), nil
+	case DiscoveryProviderKubernetes:
+		return NewKubernetesProvider(
+			viper.GetString("peer.discovery.kubernetes.apiServer"),
+			viper.GetString("peer.discovery.kubernetes.namespace"),
+			viper.GetString("peer.discovery.kubernetes.service"),
+			viper.GetString("peer.discovery.kubernetes.port"))
+	default:
+		return nil, fmt.Errorf("Unknown discovery provider %s, expected %s, %s or %s", provider, DiscoveryProviderStatic, DiscoveryProviderDNS, DiscoveryProviderKubernetes)
+	}
+}
+
+// DNSProvider looks up the root nodes in the SRV records of a name, such as
+// _peer._tcp.validators.example.com, whose targets and ports are the
+// addresses of the peers
+type DNSProvider struct {
+	name      string
+	lookupSRV func(service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NewDNSProvider creates a provider of the root nodes in the SRV records of
+// name
+func NewDNSProvider(name string) *DNSProvider {
+	return &DNSProvider{name: name, lookupSRV: net.LookupSRV}
+}
+
+// Lookup returns the addresses of the SRV records, by priority and weight
+func (p *DNSProvider) Lookup() ([]string, error) {
+	_, records, err := p.lookupSRV("", "", p.name)
+	if err != nil {
+		return nil, fmt.Errorf("Error looking up the SRV records of %s: %s", p.name, err)
+	}
+	var addresses []string
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+	return addresses, nil
+}
+
+// The files the service account of a pod is mounted in
+const (
+	kubernetesTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubernetesCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	kubernetesNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// KubernetesProvider looks up the root nodes in the endpoints of a service
+// through the Kubernetes API, which are the addresses of the ready pods of
+// the service, e.g. a headless service of the validators, so that the root
+// nodes follow the pods as they are rescheduled
+type KubernetesProvider struct {
+	endpointsURL string
+	port         string // name of the port, the first port if empty
+	token        string
+	client       *http.Client
+}
+
+// NewKubernetesProvider creates a provider of the root nodes in the
+// endpoints of service. The API server, the namespace and the credentials
+// default to those of the pod the peer runs in.
+func NewKubernetesProvider(apiServer string, namespace string, service string, port string) (*KubernetesProvider, error) {
+	if service == "" {
+		return nil, fmt.Errorf("peer.discovery.kubernetes.service must be set for the %s discovery provider", DiscoveryProviderKubernetes)
+	}
+	inCluster := apiServer == ""
+	if inCluster {
+		host, hostPort := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || hostPort == "" {
+			return nil, fmt.Errorf("peer.discovery.kubernetes.apiServer must be set outside of a Kubernetes pod")
+		}
+		apiServer = "https://" + net.JoinHostPort(host, hostPort)
+	}
+	if namespace == "" {
+		raw, err := ioutil.ReadFile(kubernetesNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the namespace of the pod, set peer.discovery.kubernetes.namespace: %s", err)
+		}
+		namespace = strings.TrimSpace(string(raw))
+	}
+
+	p := &KubernetesProvider{
+		endpointsURL: fmt.Sprintf("%s/api/v1/namespaces/%s/endpoints/%s", strings.TrimSuffix(apiServer, "/"), url.QueryEscape(namespace), url.QueryEscape(service)),
+		port:         port,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+	if inCluster {
+		token, err := ioutil.ReadFile(kubernetesTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the service account token: %s", err)
+		}
+		p.token = strings.TrimSpace(string(token))
+		ca, err := ioutil.ReadFile(kubernetesCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the service account CA: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificate found in %s", kubernetesCAFile)
+		}
+		p.client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return p, nil
+}
+
+// kubernetesEndpoints is the part of a Kubernetes Endpoints object the
+// provider reads
+type kubernetesEndpoints struct {
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+// Lookup returns the addresses of the ready endpoints of the service
+func (p *KubernetesProvider) Lookup() ([]string, error) {
+	req, err := http.NewRequest("GET", p.endpointsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting %s: %s", p.endpointsURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error getting %s: %s", p.endpointsURL, resp.Status)
+	}
+	endpoints := &kubernetesEndpoints{}
+	if err := json.NewDecoder(resp.Body).Decode(endpoints); err != nil {
+		return nil, fmt.Errorf("Error decoding the endpoints: %s", err)
+	}
+
+	var addresses []string
+	for _, subset := range endpoints.Subsets {
+		port := 0
+		for _, servicePort := range subset.Ports {
+			if p.port == "" || servicePort.Name == p.port {
+				port = servicePort.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			addresses = append(addresses, net.JoinHostPort(address.IP, strconv.Itoa(port)))
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDNSProvider(t *testing.T) {
+	provider := NewDNSProvider("_peer._tcp.validators.example.com")
+	provider.lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		if name != "_peer._tcp.validators.example.com" {
+			return "", nil, fmt.Errorf("no such host %s", name)
+		}
+		return "", []*net.SRV{
+			{Target: "vp0.validators.example.com.", Port: 30303},
+			{Target: "vp1.validators.example.com.", Port: 30304},
+		}, nil
+	}
+	addresses, err := provider.Lookup()
+	if expected := []string{"vp0.validators.example.com:30303", "vp1.validators.example.com:30304"}; err != nil || !reflect.DeepEqual(addresses, expected) {
+		t.Fatalf("Expected the root nodes %v, got %v (%v)", expected, addresses, err)
+	}
+
+	provider.name = "unknown.example.com"
+	if _, err := provider.Lookup(); err == nil {
+		t.Fatalf("Expected the lookup of an unknown name to fail")
+	}
+}
+
+func TestKubernetesProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/fabric/endpoints/validators" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"kind": "Endpoints", "subsets": [
+			{"addresses": [{"ip": "10.0.0.1"}, {"ip": "fd00::2"}],
+			 "notReadyAddresses": [{"ip": "10.0.0.3"}],
+			 "ports": [{"name": "rest", "port": 5000}, {"name": "peer", "port": 30303}]}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewKubernetesProvider(server.URL, "fabric", "validators", "peer")
+	if err != nil {
+		t.Fatalf("Error creating the provider: %s", err)
+	}
+	addresses, err := provider.Lookup()
+	if expected := []string{"10.0.0.1:30303", "[fd00::2]:30303"}; err != nil || !reflect.DeepEqual(addresses, expected) {
+		t.Fatalf("Expected the ready endpoints %v, got %v (%v)", expected, addresses, err)
+	}
+
+	provider.port = ""
+	if addresses, err := provider.Lookup(); err != nil || len(addresses) != 2 || addresses[0] != "10.0.0.1:5000" {
+		t.Fatalf("Expected the first port of the endpoints without a port name, got %v (%v)", addresses, err)
+	}
+
+	provider, _ = NewKubernetesProvider(server.URL, "fabric", "unknown", "")
+	if _, err := provider.Lookup(); err == nil {
+		t.Fatalf("Expected the lookup of an unknown service to fail")
+	}
+	if _, err := NewKubernetesProvider(server.URL, "fabric", "", ""); err == nil {
+		t.Fatalf("Expected a provider without a service to be rejected")
+	}
+}
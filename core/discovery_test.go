@@ -17,6 +17,7 @@ limitations under the License.
 package core
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -61,6 +62,88 @@ func TestDiscovery_GetMulti(t *testing.T) {
 	assertRootNodeRandomValues(t, []string{"a", "b", "c", "d", "e"}, NewStaticDiscovery("a,b,c,d,e"))
 }
 
+func TestDiscovery_AddNode(t *testing.T) {
+	discovery := NewStaticDiscovery("a,b")
+	if discovery.AddNode("a") || discovery.AddNode("") {
+		t.Fatalf("Expected root nodes and empty addresses not to be added")
+	}
+	if !discovery.AddNode("c") || discovery.AddNode("c") {
+		t.Fatalf("Expected a discovered node to be added once")
+	}
+	if nodes := discovery.GetAllNodes(); strings.Join(nodes, ",") != "a,b,c" {
+		t.Fatalf("Expected all nodes to be [a b c], got %v", nodes)
+	}
+	if nodes := NewStaticDiscovery("").GetAllNodes(); len(nodes) != 0 {
+		t.Fatalf("Expected no nodes without root node, got %v", nodes)
+	}
+}
+
+func TestDiscovery_RemoveNode(t *testing.T) {
+	discovery := NewStaticDiscovery("a,b")
+	discovery.AddNode("c")
+	discovery.AddNode("d")
+	if discovery.RemoveNode("a") || discovery.RemoveNode("e") {
+		t.Fatalf("Expected root nodes and unknown nodes not to be removed")
+	}
+	if !discovery.RemoveNode("c") || discovery.RemoveNode("c") {
+		t.Fatalf("Expected a discovered node to be removed once")
+	}
+	if nodes := discovery.GetAllNodes(); strings.Join(nodes, ",") != "a,b,d" {
+		t.Fatalf("Expected all nodes to be [a b d], got %v", nodes)
+	}
+}
+
+func TestDiscovery_IPv6RootNodes(t *testing.T) {
+	discovery := NewStaticDiscovery("[::1]:30303, [2001:DB8::0:1]:30303,10.0.0.1:30303")
+	if nodes := discovery.GetRootNodes(); strings.Join(nodes, ",") != "[::1]:30303,[2001:db8::1]:30303,10.0.0.1:30303" {
+		t.Fatalf("Expected the root nodes to be normalized, got %v", nodes)
+	}
+	if discovery.AddNode("[0:0::1]:30303") {
+		t.Fatalf("Expected a root node written differently not to be added")
+	}
+	if !discovery.AddNode("[2001:db8::2]:30303") || discovery.AddNode("[2001:db8:0::2]:30303") {
+		t.Fatalf("Expected a discovered IPv6 node to be added once")
+	}
+}
+
+// staticProvider looks up the addresses it was last set
+type staticProvider struct {
+	addresses []string
+	err       error
+}
+
+func (p *staticProvider) Lookup() ([]string, error) {
+	return p.addresses, p.err
+}
+
+func TestDiscovery_Refresh(t *testing.T) {
+	provider := &staticProvider{addresses: []string{"10.0.0.1:30303", "10.0.0.2:30303"}}
+	discovery := NewDiscoveryWithProvider("vp0:30303", provider)
+	if nodes := discovery.GetRootNodes(); strings.Join(nodes, ",") != "vp0:30303,10.0.0.1:30303,10.0.0.2:30303" {
+		t.Fatalf("Expected the configured and looked up root nodes, got %v", nodes)
+	}
+
+	provider.addresses = []string{"10.0.0.2:30303", "10.0.0.3:30303"}
+	added, removed, err := discovery.Refresh()
+	if err != nil || strings.Join(added, ",") != "10.0.0.3:30303" || strings.Join(removed, ",") != "10.0.0.1:30303" {
+		t.Fatalf("Expected 10.0.0.3 to be added and 10.0.0.1 removed, got %v and %v (%v)", added, removed, err)
+	}
+
+	provider.err = fmt.Errorf("lookup failed")
+	if _, _, err := discovery.Refresh(); err == nil {
+		t.Fatalf("Expected the failed lookup to be reported")
+	}
+	if nodes := discovery.GetRootNodes(); strings.Join(nodes, ",") != "vp0:30303,10.0.0.2:30303,10.0.0.3:30303" {
+		t.Fatalf("Expected the root nodes to be kept as they were after a failed lookup, got %v", nodes)
+	}
+
+	// without any root node, the discovery is as if none was configured
+	empty := NewDiscoveryWithProvider("", &staticProvider{})
+	if nodes := empty.GetRootNodes(); len(nodes) != 1 || nodes[0] != "" {
+		t.Fatalf("Expected no root node, got %v", nodes)
+	}
+}
+
 func assertRandomRootNode(t *testing.T, expected string, discovery d.Discovery) {
 	rootNode := discovery.GetRandomNode()
 
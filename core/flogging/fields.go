@@ -0,0 +1,183 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flogging
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+)
+
+// Field is a named value added to the records of a Logger, such as the txid
+// of the transaction they are about
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields are the fields of a Logger, in the order they were added. A Logger
+// passes them as the first argument of its records, so that they prefix the
+// message in text, and are fields of their own in JSON.
+type Fields []Field
+
+// String formats the fields as [key=value ...]
+func (f Fields) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, field := range f {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		fmt.Fprintf(&buf, "%s=%v", field.Key, field.Value)
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+// with returns the fields with those of keyvals, alternating keys and values,
+// a key already set taking the new value
+func (f Fields) with(keyvals ...interface{}) Fields {
+	fields := make(Fields, len(f), len(f)+len(keyvals)/2)
+	copy(fields, f)
+next:
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		for j := range fields {
+			if fields[j].Key == key {
+				fields[j].Value = keyvals[i+1]
+				continue next
+			}
+		}
+		fields = append(fields, Field{Key: key, Value: keyvals[i+1]})
+	}
+	return fields
+}
+
+// Logger logs to a go-logging logger, adding its fields to every record
+type Logger struct {
+	logger *logging.Logger
+	fields Fields
+}
+
+// With returns a logger adding the fields of keyvals, alternating keys and
+// values, to the records of logger
+func With(logger *logging.Logger, keyvals ...interface{}) *Logger {
+	l := *logger
+	// the records are logged from the methods of Logger
+	l.ExtraCalldepth++
+	return &Logger{logger: &l, fields: Fields(nil).with(keyvals...)}
+}
+
+// With returns a logger adding the fields of keyvals to those of l
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	return &Logger{logger: l.logger, fields: l.fields.with(keyvals...)}
+}
+
+// Fields returns the fields of the logger
+func (l *Logger) Fields() Fields {
+	return l.fields
+}
+
+// IsEnabledFor returns whether the records of the level are logged
+func (l *Logger) IsEnabledFor(level logging.Level) bool {
+	return l.logger.IsEnabledFor(level)
+}
+
+// args prefixes the arguments of a record with the fields, if any
+func (l *Logger) args(args []interface{}) []interface{} {
+	if len(l.fields) == 0 {
+		return args
+	}
+	return append([]interface{}{l.fields}, args...)
+}
+
+// format prefixes the format of a record with that of the fields, if any
+func (l *Logger) format(format string) string {
+	if len(l.fields) == 0 {
+		return format
+	}
+	return "%s " + format
+}
+
+// Critical logs a message using CRITICAL as log level.
+func (l *Logger) Critical(args ...interface{}) { l.logger.Critical(l.args(args)...) }
+
+// Criticalf logs a message using CRITICAL as log level.
+func (l *Logger) Criticalf(format string, args ...interface{}) {
+	l.logger.Criticalf(l.format(format), l.args(args)...)
+}
+
+// Error logs a message using ERROR as log level.
+func (l *Logger) Error(args ...interface{}) { l.logger.Error(l.args(args)...) }
+
+// Errorf logs a message using ERROR as log level.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(l.format(format), l.args(args)...)
+}
+
+// Warning logs a message using WARNING as log level.
+func (l *Logger) Warning(args ...interface{}) { l.logger.Warning(l.args(args)...) }
+
+// Warningf logs a message using WARNING as log level.
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.logger.Warningf(l.format(format), l.args(args)...)
+}
+
+// Notice logs a message using NOTICE as log level.
+func (l *Logger) Notice(args ...interface{}) { l.logger.Notice(l.args(args)...) }
+
+// Noticef logs a message using NOTICE as log level.
+func (l *Logger) Noticef(format string, args ...interface{}) {
+	l.logger.Noticef(l.format(format), l.args(args)...)
+}
+
+// Info logs a message using INFO as log level.
+func (l *Logger) Info(args ...interface{}) { l.logger.Info(l.args(args)...) }
+
+// Infof logs a message using INFO as log level.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(l.format(format), l.args(args)...)
+}
+
+// Debug logs a message using DEBUG as log level.
+func (l *Logger) Debug(args ...interface{}) { l.logger.Debug(l.args(args)...) }
+
+// Debugf logs a message using DEBUG as log level.
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(l.format(format), l.args(args)...)
+}
+
+type fieldsKey struct{}
+
+// NewContext returns a context carrying the fields of ctx with those of
+// keyvals, for the functions it is passed to to log them with FromContext
+func NewContext(ctx context.Context, keyvals ...interface{}) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).(Fields)
+	return context.WithValue(ctx, fieldsKey{}, fields.with(keyvals...))
+}
+
+// FromContext returns a logger adding the fields carried by ctx to the
+// records of logger
+func FromContext(ctx context.Context, logger *logging.Logger) *Logger {
+	l := With(logger)
+	if ctx != nil {
+		l.fields, _ = ctx.Value(fieldsKey{}).(Fields)
+	}
+	return l
+}
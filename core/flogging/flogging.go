@@ -0,0 +1,161 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package flogging sets the backend of the go-logging loggers of the peer:
+// their output in text or JSON, their levels, which modules inherit from
+// their parent modules, and the fields which loggers derived with With and
+// FromContext add to their records.
+package flogging
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/op/go-logging"
+)
+
+// The formats of the log output
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
+// textFormat is the format of the records in text
+const textFormat = "%{color}%{time:15:04:05.000} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}"
+
+// levels is the backend of all the loggers once SetFormat was called
+var levels = &moduleLevels{
+	levels:  make(map[string]logging.Level),
+	modules: make(map[string]bool),
+}
+
+// SetFormat has the loggers write their records to w in the format, text or
+// JSON. The levels of the modules are kept.
+func SetFormat(format string, w io.Writer) error {
+	var formatter logging.Formatter
+	switch strings.ToLower(format) {
+	case "", TextFormat:
+		formatter = logging.MustStringFormatter(textFormat)
+	case JSONFormat:
+		formatter = jsonFormatter{}
+	default:
+		return fmt.Errorf("Logging format '%s' not recognized, expected %s or %s", format, TextFormat, JSONFormat)
+	}
+	levels.Lock()
+	levels.backend = logging.NewBackendFormatter(logging.NewLogBackend(w, "", 0), formatter)
+	levels.Unlock()
+	logging.SetBackend(levels)
+	return nil
+}
+
+// SetModuleLevel sets the level of the module and of its submodules which
+// have no level of their own, e.g. of consensus/obcpbft for consensus, and
+// returns the level as it is named
+func SetModuleLevel(module string, level string) (string, error) {
+	l, err := logging.LogLevel(level)
+	if err != nil {
+		return "", fmt.Errorf("Invalid logging level '%s' for module '%s': %s", level, module, err)
+	}
+	logging.SetLevel(l, module)
+	return l.String(), nil
+}
+
+// ResetModuleLevel removes the level set for the module, which then inherits
+// the level of its parent module
+func ResetModuleLevel(module string) {
+	levels.Lock()
+	defer levels.Unlock()
+	delete(levels.levels, module)
+}
+
+// GetModuleLevel returns the level in effect for the module
+func GetModuleLevel(module string) string {
+	return logging.GetLevel(module).String()
+}
+
+// Modules returns the modules which logged or whose level was set, in order
+func Modules() []string {
+	levels.RLock()
+	defer levels.RUnlock()
+	var modules []string
+	for module := range levels.modules {
+		modules = append(modules, module)
+	}
+	for module := range levels.levels {
+		if !levels.modules[module] && module != "" {
+			modules = append(modules, module)
+		}
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// moduleLevels is a logging.LeveledBackend, safe for concurrent use unlike the
+// default one of go-logging, under which a module without a level inherits
+// that of its parent module
+type moduleLevels struct {
+	sync.RWMutex
+	backend logging.Backend
+	levels  map[string]logging.Level
+	modules map[string]bool // the modules which logged
+}
+
+func (ml *moduleLevels) GetLevel(module string) logging.Level {
+	ml.RLock()
+	defer ml.RUnlock()
+	for {
+		if level, ok := ml.levels[module]; ok {
+			return level
+		}
+		if module == "" {
+			// as go-logging, log everything when no level is set
+			return logging.DEBUG
+		}
+		if i := strings.LastIndex(module, "/"); i >= 0 {
+			module = module[:i]
+		} else {
+			module = ""
+		}
+	}
+}
+
+func (ml *moduleLevels) SetLevel(level logging.Level, module string) {
+	ml.Lock()
+	defer ml.Unlock()
+	ml.levels[module] = level
+}
+
+func (ml *moduleLevels) IsEnabledFor(level logging.Level, module string) bool {
+	ml.RLock()
+	known := ml.modules[module]
+	ml.RUnlock()
+	if !known {
+		ml.Lock()
+		ml.modules[module] = true
+		ml.Unlock()
+	}
+	return level <= ml.GetLevel(module)
+}
+
+func (ml *moduleLevels) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	ml.RLock()
+	backend := ml.backend
+	ml.RUnlock()
+	return backend.Log(level, calldepth+1, rec)
+}
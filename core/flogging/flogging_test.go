@@ -0,0 +1,133 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flogging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+)
+
+func TestModuleLevels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := SetFormat(TextFormat, &buf); err != nil {
+		t.Fatalf("Error setting the format: %s", err)
+	}
+	defer SetFormat(TextFormat, os.Stderr)
+	child := logging.MustGetLogger("flogtest/levels/child")
+
+	if _, err := SetModuleLevel("flogtest/levels", "warning"); err != nil {
+		t.Fatalf("Error setting the level: %s", err)
+	}
+	child.Info("hidden")
+	child.Warning("inherited")
+	if level, err := SetModuleLevel("flogtest/levels/child", "debug"); err != nil || level != "DEBUG" {
+		t.Fatalf("Expected the level to be set to DEBUG, got %s, %v", level, err)
+	}
+	child.Info("overridden")
+	ResetModuleLevel("flogtest/levels/child")
+	child.Info("hidden again")
+
+	out := buf.String()
+	for _, msg := range []string{"inherited", "overridden"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("Expected the output to contain %s, got %s", msg, out)
+		}
+	}
+	if strings.Contains(out, "hidden") {
+		t.Errorf("Expected the output not to contain the records below the level, got %s", out)
+	}
+	if level := GetModuleLevel("flogtest/levels/child"); level != "WARNING" {
+		t.Errorf("Expected the child to inherit WARNING, got %s", level)
+	}
+	modules := Modules()
+	for _, module := range []string{"flogtest/levels", "flogtest/levels/child"} {
+		found := false
+		for _, m := range modules {
+			found = found || m == module
+		}
+		if !found {
+			t.Errorf("Expected %s among the modules, got %v", module, modules)
+		}
+	}
+	if _, err := SetModuleLevel("flogtest/levels", "loud"); err == nil {
+		t.Errorf("Expected an invalid level to be rejected")
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := SetFormat("JSON", &buf); err != nil {
+		t.Fatalf("Error setting the format: %s", err)
+	}
+	defer SetFormat(TextFormat, os.Stderr)
+	logger := With(logging.MustGetLogger("flogtest/json"), "txid", "abc")
+
+	logger.With("block", 5).Infof("Executing %d \"transactions\"", 2)
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %s: %s", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"level":  "INFO",
+		"module": "flogtest/json",
+		"func":   "TestJSONFormat",
+		"txid":   "abc",
+		"block":  float64(5),
+		"msg":    "Executing 2 \"transactions\"",
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, record[key])
+		}
+	}
+
+	if err := SetFormat("xml", &buf); err == nil {
+		t.Errorf("Expected an unknown format to be rejected")
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	if err := SetFormat(TextFormat, &buf); err != nil {
+		t.Fatalf("Error setting the format: %s", err)
+	}
+	defer SetFormat(TextFormat, os.Stderr)
+
+	ctx := NewContext(context.Background(), "block", 5, "txid", "abc")
+	ctx = NewContext(ctx, "txid", "def")
+	logger := FromContext(ctx, logging.MustGetLogger("flogtest/context"))
+	if expected := (Fields{{"block", 5}, {"txid", "def"}}); !reflect.DeepEqual(logger.Fields(), expected) {
+		t.Fatalf("Expected fields %v, got %v", expected, logger.Fields())
+	}
+	logger.Warning("Executed")
+	if out := buf.String(); !strings.Contains(out, "TestContextFields -> WARN") || !strings.HasSuffix(out, " [block=5 txid=def] Executed\n") {
+		t.Errorf("Expected the fields to prefix the message logged from the test, got %s", out)
+	}
+
+	buf.Reset()
+	FromContext(context.Background(), logging.MustGetLogger("flogtest/context")).Warning("Executed")
+	if out := buf.String(); strings.Contains(out, "[]") || !strings.HasSuffix(out, " Executed\n") {
+		t.Errorf("Expected no prefix without fields, got %s", out)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flogging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// jsonFormatter formats a record as a JSON object on a line, with the time,
+// level, module, function, id and message of the record, and its fields
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	add := func(key string, value interface{}) {
+		if buf.Len() > 1 {
+			buf.WriteString(",")
+		}
+		writeJSON(&buf, key)
+		buf.WriteString(":")
+		writeJSON(&buf, value)
+	}
+	add("time", r.Time.Format(time.RFC3339Nano))
+	add("level", r.Level.String())
+	add("module", r.Module)
+	function := "???"
+	if pc, _, _, ok := runtime.Caller(calldepth + 1); ok {
+		if f := runtime.FuncForPC(pc); f != nil {
+			function = f.Name()[strings.LastIndex(f.Name(), ".")+1:]
+		}
+	}
+	add("func", function)
+	add("id", r.ID)
+
+	msg := r.Message()
+	if len(r.Args) > 0 {
+		if fields, ok := r.Args[0].(Fields); ok {
+			msg = strings.TrimPrefix(msg, fields.String()+" ")
+			for _, field := range fields {
+				add(field.Key, field.Value)
+			}
+		}
+	}
+	add("msg", msg)
+	buf.WriteString("}")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeJSON writes the value in JSON, values of other types than strings,
+// numbers and booleans as their string
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	switch value.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	default:
+		value = fmt.Sprint(value)
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(raw)
+}
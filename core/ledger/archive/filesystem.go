@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric/core/util"
+)
+
+// FileStore archives every block to a separate file in a directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore writing to dir, which is created if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating block archive directory %s: %s", dir, err)
+	}
+	return &FileStore{dir}, nil
+}
+
+// Put implements method in interface 'Store'
+func (store *FileStore) Put(blockNumber uint64, blockBytes []byte) error {
+	return util.SaveToDisk(filepath.Join(store.dir, blockKey(blockNumber)), blockBytes)
+}
+
+// Get implements method in interface 'Store'
+func (store *FileStore) Get(blockNumber uint64) ([]byte, error) {
+	blockBytes, err := ioutil.ReadFile(filepath.Join(store.dir, blockKey(blockNumber)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return blockBytes, err
+}
@@ -0,0 +1,166 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config is the configuration of an S3Store
+type S3Config struct {
+	// Endpoint is the base URL of the service, e.g. https://s3.amazonaws.com
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Prefix is prepended to the names of the objects of the blocks
+	Prefix string
+}
+
+// S3Store archives blocks as objects in a bucket of an S3 compatible object
+// store. Requests use path style addressing and are signed with AWS
+// signature version 4.
+type S3Store struct {
+	config *S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Store returns an S3Store for the given configuration
+func NewS3Store(config *S3Config) (*S3Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("Endpoint and bucket must be set for the S3 block archive")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("Invalid S3 endpoint %s: %s", config.Endpoint, err)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &S3Store{config: config, client: &http.Client{Timeout: 60 * time.Second}, now: time.Now}, nil
+}
+
+// Put implements method in interface 'Store'
+func (store *S3Store) Put(blockNumber uint64, blockBytes []byte) error {
+	resp, err := store.do("PUT", store.config.Prefix+blockKey(blockNumber), blockBytes)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error archiving block %d to S3: %s", blockNumber, resp.Status)
+	}
+	return nil
+}
+
+// Get implements method in interface 'Store'
+func (store *S3Store) Get(blockNumber uint64) ([]byte, error) {
+	resp, err := store.do("GET", store.config.Prefix+blockKey(blockNumber), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("Error fetching archived block %d from S3: %s", blockNumber, resp.Status)
+}
+
+func (store *S3Store) do(method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(store.config.Endpoint, "/")+"/"+store.config.Bucket+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating S3 request: %s", err)
+	}
+	store.sign(req, body)
+	resp, err := store.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error sending S3 request: %s", err)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS signature version 4 headers to req
+func (store *S3Store) sign(req *http.Request, body []byte) {
+	now := store.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := hexSHA256(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + store.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+store.config.SecretAccessKey), date)
+	key = hmacSHA256(key, store.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		store.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// Store keeps blocks that have been pruned from the local DB
+type Store interface {
+	// Put stores the serialized block under its block number
+	Put(blockNumber uint64, blockBytes []byte) error
+	// Get returns the serialized block, or nil if the block is not in the store
+	Get(blockNumber uint64) ([]byte, error)
+}
+
+// Names of the supported stores
+const (
+	StoreFilesystem = "filesystem"
+	StoreS3         = "s3"
+)
+
+// NewStoreFromConfig creates the store configured under ledger.blockchain.archive
+// for the ledger with the given ID. The blocks of ledgers other than the default
+// ledger, with the empty ID, are kept under ledgers/<ledgerID> in the store.
+func NewStoreFromConfig(ledgerID string) (Store, error) {
+	storeName := viper.GetString("ledger.blockchain.archive.store")
+	switch storeName {
+	case "", StoreFilesystem:
+		path := viper.GetString("ledger.blockchain.archive.filesystem.path")
+		if path == "" {
+			path = filepath.Join(viper.GetString("peer.fileSystemPath"), "archive")
+		}
+		if ledgerID != "" {
+			path = filepath.Join(path, "ledgers", ledgerID)
+		}
+		return NewFileStore(path)
+	case StoreS3:
+		prefix := ""
+		if ledgerID != "" {
+			prefix = "ledgers/" + ledgerID + "/"
+		}
+		return NewS3Store(&S3Config{
+			Prefix:          prefix,
+			Endpoint:        viper.GetString("ledger.blockchain.archive.s3.endpoint"),
+			Bucket:          viper.GetString("ledger.blockchain.archive.s3.bucket"),
+			Region:          viper.GetString("ledger.blockchain.archive.s3.region"),
+			AccessKeyID:     viper.GetString("ledger.blockchain.archive.s3.accessKeyID"),
+			SecretAccessKey: viper.GetString("ledger.blockchain.archive.s3.secretAccessKey"),
+		})
+	}
+	return nil, fmt.Errorf("Unknown block archive store [%s]", storeName)
+}
+
+func blockKey(blockNumber uint64) string {
+	return fmt.Sprintf("%020d.block", blockNumber)
+}
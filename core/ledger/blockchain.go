@@ -22,9 +22,9 @@ import (
 	"strconv"
 
 	"github.com/hyperledger/fabric/core/db"
+	ledgerutil "github.com/hyperledger/fabric/core/ledger/util"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 	"golang.org/x/net/context"
 )
 
@@ -35,6 +35,9 @@ type blockchain struct {
 	previousBlockHash  []byte
 	indexer            blockchainIndexer
 	lastProcessedBlock *lastProcessedBlock
+	archiver           *blockArchiver
+	compression        ledgerutil.Compression
+	db                 *db.OpenchainDB
 }
 
 type lastProcessedBlock struct {
@@ -45,15 +48,19 @@ type lastProcessedBlock struct {
 
 var indexBlockDataSynchronously = true
 
-func newBlockchain() (*blockchain, error) {
-	size, err := fetchBlockchainSizeFromDB()
+func newBlockchain(openchainDB *db.OpenchainDB) (*blockchain, error) {
+	size, err := fetchBlockchainSizeFromDB(openchainDB)
 	if err != nil {
 		return nil, err
 	}
-	blockchain := &blockchain{0, nil, nil, nil}
+	compression, err := ledgerutil.CompressionFromConfig()
+	if err != nil {
+		return nil, err
+	}
+	blockchain := &blockchain{0, nil, nil, nil, nil, compression, openchainDB}
 	blockchain.size = size
 	if size > 0 {
-		previousBlock, err := fetchBlockFromDB(size - 1)
+		previousBlock, err := fetchBlockFromDB(openchainDB, size-1)
 		if err != nil {
 			return nil, err
 		}
@@ -68,6 +75,15 @@ func newBlockchain() (*blockchain, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	blockchain.archiver, err = newBlockArchiverFromConfig(openchainDB)
+	if err != nil {
+		return nil, err
+	}
+	if blockchain.archiver != nil {
+		blockchain.archiver.start()
+		blockchain.archiver.blockAdded(blockchain.size)
+	}
 	return blockchain, nil
 }
 
@@ -94,9 +110,12 @@ func (blockchain *blockchain) getSize() uint64 {
 	return blockchain.size
 }
 
-// getBlock get block at arbitrary height in block chain
+// getBlock get block at arbitrary height in block chain, fetching it from the archive if it has been archived
 func (blockchain *blockchain) getBlock(blockNumber uint64) (*protos.Block, error) {
-	return fetchBlockFromDB(blockNumber)
+	if blockchain.archiver != nil {
+		return blockchain.archiver.getBlock(blockNumber)
+	}
+	return fetchBlockFromDB(blockchain.db, blockNumber)
 }
 
 // getBlockByHash get block by block hash
@@ -108,6 +127,15 @@ func (blockchain *blockchain) getBlockByHash(blockHash []byte) (*protos.Block, e
 	return blockchain.getBlock(blockNumber)
 }
 
+// getBlockByTxUUID get the block containing the transaction with the given uuid
+func (blockchain *blockchain) getBlockByTxUUID(txUUID string) (*protos.Block, error) {
+	blockNumber, _, err := blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	if err != nil {
+		return nil, err
+	}
+	return blockchain.getBlock(blockNumber)
+}
+
 func (blockchain *blockchain) getTransactionByUUID(txUUID string) (*protos.Transaction, error) {
 	blockNumber, txIndex, err := blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
 	if err != nil {
@@ -166,11 +194,15 @@ func (blockchain *blockchain) getBlockchainInfoForBlock(height uint64, block *pr
 func (blockchain *blockchain) buildBlock(block *protos.Block, stateHash []byte) *protos.Block {
 	block.SetPreviousBlockHash(blockchain.previousBlockHash)
 	block.StateHash = stateHash
+	if blockchain.size == 0 {
+		// record the hash algorithm in the genesis block
+		block.HashAlgorithm = util.GetDefaultHashName()
+	}
 	return block
 }
 
 func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Context,
-	block *protos.Block, stateHash []byte, writeBatch *gorocksdb.WriteBatch) (uint64, error) {
+	block *protos.Block, stateHash []byte, writeBatch db.WriteBatch) (uint64, error) {
 	block = blockchain.buildBlock(block, stateHash)
 	if block.NonHashData == nil {
 		block.NonHashData = &protos.NonHashData{LocalLedgerCommitTimestamp: util.CreateUtcTimestamp()}
@@ -182,12 +214,12 @@ func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Conte
 	if err != nil {
 		return 0, err
 	}
-	blockBytes, blockBytesErr := block.Bytes()
+	blockBytes, blockBytesErr := blockchain.marshalBlockForDB(block)
 	if blockBytesErr != nil {
 		return 0, blockBytesErr
 	}
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
+	writeBatch.PutCF(blockchain.db.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	writeBatch.PutCF(blockchain.db.BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
 	if blockchain.indexer.isSynchronous() {
 		blockchain.indexer.createIndexesSync(block, blockNumber, blockHash, writeBatch)
 	}
@@ -203,18 +235,21 @@ func (blockchain *blockchain) blockPersistenceStatus(success bool) {
 			blockchain.indexer.createIndexesAsync(blockchain.lastProcessedBlock.block,
 				blockchain.lastProcessedBlock.blockNumber, blockchain.lastProcessedBlock.blockHash)
 		}
+		if blockchain.archiver != nil {
+			blockchain.archiver.blockAdded(blockchain.size)
+		}
 	}
 	blockchain.lastProcessedBlock = nil
 }
 
 func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber uint64) error {
-	blockBytes, blockBytesErr := block.Bytes()
+	blockBytes, blockBytesErr := blockchain.marshalBlockForDB(block)
 	if blockBytesErr != nil {
 		return blockBytesErr
 	}
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := blockchain.db.NewWriteBatch()
 	defer writeBatch.Destroy()
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	writeBatch.PutCF(blockchain.db.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
 
 	blockHash, err := block.GetHash()
 	if err != nil {
@@ -225,7 +260,7 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 	// really blockchain height, not size.
 	if blockchain.getSize() < blockNumber+1 {
 		sizeBytes := encodeUint64(blockNumber + 1)
-		writeBatch.PutCF(db.GetDBHandle().BlockchainCF, blockCountKey, sizeBytes)
+		writeBatch.PutCF(blockchain.db.BlockchainCF, blockCountKey, sizeBytes)
 		blockchain.size = blockNumber + 1
 		blockchain.previousBlockHash = blockHash
 	}
@@ -234,28 +269,44 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 		blockchain.indexer.createIndexesSync(block, blockNumber, blockHash, writeBatch)
 	}
 
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err = db.GetDBHandle().DB.Write(opt, writeBatch)
+	err = blockchain.db.Write(writeBatch)
 	if err != nil {
 		return err
 	}
+	if blockchain.archiver != nil {
+		blockchain.archiver.blockAdded(blockchain.size)
+	}
 	return nil
 }
 
-func fetchBlockFromDB(blockNumber uint64) (*protos.Block, error) {
-	blockBytes, err := db.GetDBHandle().GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
+func fetchBlockFromDB(openchainDB *db.OpenchainDB, blockNumber uint64) (*protos.Block, error) {
+	blockBytes, err := openchainDB.GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
 	if err != nil {
 		return nil, err
 	}
 	if blockBytes == nil {
 		return nil, nil
 	}
-	return protos.UnmarshallBlock(blockBytes)
+	return unmarshalBlockFromDB(blockBytes)
+}
+
+// marshalBlockForDB serializes the block and compresses it with the configured compression
+func (blockchain *blockchain) marshalBlockForDB(block *protos.Block) ([]byte, error) {
+	blockBytes, err := block.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return ledgerutil.Compress(blockchain.compression, blockBytes)
+}
+
+// unmarshalBlockFromDB deserializes a block written by marshalBlockForDB, with
+// any compression
+func unmarshalBlockFromDB(blockBytes []byte) (*protos.Block, error) {
+	return protos.UnmarshallBlock(ledgerutil.Decompress(blockBytes))
 }
 
-func fetchBlockchainSizeFromDB() (uint64, error) {
-	bytes, err := db.GetDBHandle().GetFromBlockchainCF(blockCountKey)
+func fetchBlockchainSizeFromDB(openchainDB *db.OpenchainDB) (uint64, error) {
+	bytes, err := openchainDB.GetFromBlockchainCF(blockCountKey)
 	if err != nil {
 		return 0, err
 	}
@@ -265,8 +316,8 @@ func fetchBlockchainSizeFromDB() (uint64, error) {
 	return decodeToUint64(bytes), nil
 }
 
-func fetchBlockchainSizeFromSnapshot(snapshot *gorocksdb.Snapshot) (uint64, error) {
-	blockNumberBytes, err := db.GetDBHandle().GetFromBlockchainCFSnapshot(snapshot, blockCountKey)
+func fetchBlockchainSizeFromSnapshot(openchainDB *db.OpenchainDB, snapshot db.Snapshot) (uint64, error) {
+	blockNumberBytes, err := openchainDB.GetFromBlockchainCFSnapshot(snapshot, blockCountKey)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,198 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/archive"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+var archivedHeightKey = []byte("archivedHeight")
+var archivedBlockHashKeyPrefix = []byte("archivedBlockHash")
+
+// blockArchiver moves all but the most recent blocks from the DB to an
+// archive store. The hashes of the archived blocks and the indexes are kept
+// in the DB, so archived blocks can be located and verified when fetched back.
+// The genesis block is never archived.
+type blockArchiver struct {
+	sync.RWMutex
+	db         *db.OpenchainDB
+	store      archive.Store
+	keepBlocks uint64
+	// blocks 1 to archivedHeight-1 are in the archive store
+	archivedHeight uint64
+	newBlock       chan uint64
+}
+
+// newBlockArchiverFromConfig returns nil if archiving is not enabled
+func newBlockArchiverFromConfig(openchainDB *db.OpenchainDB) (*blockArchiver, error) {
+	if !viper.GetBool("ledger.blockchain.archive.enabled") {
+		return nil, nil
+	}
+	keepBlocks := viper.GetInt("ledger.blockchain.archive.keepBlocks")
+	if keepBlocks <= 0 {
+		return nil, fmt.Errorf("ledger.blockchain.archive.keepBlocks must be positive, got %d", keepBlocks)
+	}
+	store, err := archive.NewStoreFromConfig(openchainDB.LedgerID())
+	if err != nil {
+		return nil, err
+	}
+	return newBlockArchiver(openchainDB, store, uint64(keepBlocks))
+}
+
+func newBlockArchiver(openchainDB *db.OpenchainDB, store archive.Store, keepBlocks uint64) (*blockArchiver, error) {
+	archivedHeightBytes, err := openchainDB.GetFromBlockchainCF(archivedHeightKey)
+	if err != nil {
+		return nil, err
+	}
+	archivedHeight := uint64(1)
+	if archivedHeightBytes != nil {
+		archivedHeight = decodeToUint64(archivedHeightBytes)
+	}
+	return &blockArchiver{db: openchainDB, store: store, keepBlocks: keepBlocks, archivedHeight: archivedHeight, newBlock: make(chan uint64, 1)}, nil
+}
+
+func (archiver *blockArchiver) start() {
+	go func() {
+		for size := range archiver.newBlock {
+			if err := archiver.archiveBlocks(size); err != nil {
+				ledgerLogger.Warningf("Error archiving blocks: %s", err)
+			}
+		}
+	}()
+}
+
+// blockAdded notifies the archiver of the new blockchain size without blocking.
+// If the archiver is busy, the notification is dropped and it catches up on a later one.
+func (archiver *blockArchiver) blockAdded(size uint64) {
+	select {
+	case archiver.newBlock <- size:
+	default:
+	}
+}
+
+// isArchived must be called with the archiver lock held
+func (archiver *blockArchiver) isArchived(blockNumber uint64) bool {
+	return blockNumber > 0 && blockNumber < archiver.archivedHeight
+}
+
+// archiveBlocks archives the blocks of a blockchain of the given size which are
+// older than the most recent keepBlocks blocks. It stops at the first block
+// missing from the DB, which happens while state transfer is filling in blocks.
+func (archiver *blockArchiver) archiveBlocks(size uint64) error {
+	for {
+		archiver.RLock()
+		blockNumber := archiver.archivedHeight
+		archiver.RUnlock()
+		if blockNumber+archiver.keepBlocks >= size {
+			return nil
+		}
+
+		blockBytes, err := archiver.db.GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
+		if err != nil {
+			return err
+		}
+		if blockBytes == nil {
+			ledgerLogger.Debugf("Block %d is not in the DB yet, postponing archiving", blockNumber)
+			return nil
+		}
+		block, err := unmarshalBlockFromDB(blockBytes)
+		if err != nil {
+			return err
+		}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			return err
+		}
+		if err := archiver.store.Put(blockNumber, blockBytes); err != nil {
+			return fmt.Errorf("Error archiving block %d: %s", blockNumber, err)
+		}
+
+		// The block is removed from the DB and archivedHeight moves past it
+		// under the write lock, so readers holding the read lock see the
+		// block either in the DB or in the archive
+		archiver.Lock()
+		writeBatch := archiver.db.NewWriteBatch()
+		writeBatch.PutCF(archiver.db.BlockchainCF, encodeArchivedBlockHashKey(blockNumber), blockHash)
+		writeBatch.PutCF(archiver.db.BlockchainCF, archivedHeightKey, encodeUint64(blockNumber+1))
+		writeBatch.DeleteCF(archiver.db.BlockchainCF, encodeBlockNumberDBKey(blockNumber))
+		err = archiver.db.Write(writeBatch)
+		writeBatch.Destroy()
+		if err == nil {
+			archiver.archivedHeight = blockNumber + 1
+		}
+		archiver.Unlock()
+		if err != nil {
+			return err
+		}
+		ledgerLogger.Debugf("Archived block %d", blockNumber)
+	}
+}
+
+// getBlock returns the block from the DB, or from the archive store if it has
+// been archived. The read lock is held across the DB lookup and the check of
+// archivedHeight, so a concurrent archiveBlocks cannot remove the block from
+// the DB in between, but not across the fetch from the archive store, which
+// may be remote and slow: archived blocks never change.
+func (archiver *blockArchiver) getBlock(blockNumber uint64) (*protos.Block, error) {
+	archiver.RLock()
+	block, err := fetchBlockFromDB(archiver.db, blockNumber)
+	archived := archiver.isArchived(blockNumber)
+	archiver.RUnlock()
+	if err != nil || block != nil || !archived {
+		return block, err
+	}
+	return archiver.fetchBlock(blockNumber)
+}
+
+// fetchBlock returns the archived block from the archive store after checking
+// it against the hash kept in the DB.
+func (archiver *blockArchiver) fetchBlock(blockNumber uint64) (*protos.Block, error) {
+	blockBytes, err := archiver.store.Get(blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching archived block %d: %s", blockNumber, err)
+	}
+	if blockBytes == nil {
+		return nil, fmt.Errorf("Archived block %d is missing from the archive store", blockNumber)
+	}
+	block, err := unmarshalBlockFromDB(blockBytes)
+	if err != nil {
+		return nil, err
+	}
+	blockHash, err := block.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	expectedHash, err := archiver.db.GetFromBlockchainCF(encodeArchivedBlockHashKey(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(blockHash, expectedHash) {
+		return nil, fmt.Errorf("Hash %x of archived block %d does not match the expected hash %x", blockHash, blockNumber, expectedHash)
+	}
+	return block, nil
+}
+
+func encodeArchivedBlockHashKey(blockNumber uint64) []byte {
+	return append(append([]byte{}, archivedBlockHashKeyPrefix...), encodeUint64(blockNumber)...)
+}
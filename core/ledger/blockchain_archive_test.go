@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/archive"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestBlockchain_Archive(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	dir, err := ioutil.TempDir("", "archive")
+	testutil.AssertNoError(t, err, "Error creating temp dir")
+	defer os.RemoveAll(dir)
+	store, err := archive.NewFileStore(dir)
+	testutil.AssertNoError(t, err, "Error creating file store")
+
+	blockchainTestWrapper := newTestBlockchainWrapper(t)
+	blockchain := blockchainTestWrapper.blockchain
+	blockchain.archiver, err = newBlockArchiver(db.GetDBHandle(), store, 1)
+	testutil.AssertNoError(t, err, "Error creating block archiver")
+	blocks, _, err := blockchainTestWrapper.populateBlockChainWithSampleData()
+	testutil.AssertNoError(t, err, "Error while populating the blockchain")
+
+	testutil.AssertNoError(t, blockchain.archiver.archiveBlocks(blockchain.getSize()), "Error archiving blocks")
+
+	// Only block 1 is archived, the genesis block and the last block stay in the DB
+	for i, archived := range []bool{false, true, false} {
+		dbBlock, err := fetchBlockFromDB(db.GetDBHandle(), uint64(i))
+		testutil.AssertNoError(t, err, "Error fetching block from DB")
+		testutil.AssertEquals(t, dbBlock == nil, archived)
+
+		expectedHash, _ := blocks[i].GetHash()
+		blockHash, _ := blockchainTestWrapper.getBlock(uint64(i)).GetHash()
+		testutil.AssertEquals(t, blockHash, expectedHash)
+	}
+	blockHash, _ := blocks[1].GetHash()
+	testutil.AssertEquals(t, blockchainTestWrapper.getBlockByHash(blockHash).Transactions, blocks[1].Transactions)
+	testutil.AssertEquals(t, blockchainTestWrapper.getTransactionByUUID(blocks[1].Transactions[0].Uuid), blocks[1].Transactions[0])
+
+	// The archived height survives a restart
+	archiver, err := newBlockArchiver(db.GetDBHandle(), store, 1)
+	testutil.AssertNoError(t, err, "Error creating block archiver")
+	testutil.AssertEquals(t, archiver.archivedHeight, uint64(2))
+
+	// A block replaced in the archive is rejected
+	lastBlockBytes, _ := blockchainTestWrapper.getBlock(2).Bytes()
+	testutil.AssertNoError(t, store.Put(1, lastBlockBytes), "Error overwriting archived block")
+	_, err = blockchain.getBlock(1)
+	testutil.AssertError(t, err, "Expected error fetching a tampered archived block")
+}
+
+func TestBlockchain_ArchiveConcurrentGetBlock(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	dir, err := ioutil.TempDir("", "archive")
+	testutil.AssertNoError(t, err, "Error creating temp dir")
+	defer os.RemoveAll(dir)
+	store, err := archive.NewFileStore(dir)
+	testutil.AssertNoError(t, err, "Error creating file store")
+
+	blockchainTestWrapper := newTestBlockchainWrapper(t)
+	blockchain := blockchainTestWrapper.blockchain
+	blockchain.archiver, err = newBlockArchiver(db.GetDBHandle(), store, 1)
+	testutil.AssertNoError(t, err, "Error creating block archiver")
+	_, _, err = blockchainTestWrapper.populateBlockChainWithSampleData()
+	testutil.AssertNoError(t, err, "Error while populating the blockchain")
+
+	done := make(chan error)
+	go func() {
+		done <- blockchain.archiver.archiveBlocks(blockchain.getSize())
+	}()
+	for archiving := true; archiving; {
+		select {
+		case err := <-done:
+			testutil.AssertNoError(t, err, "Error archiving blocks")
+			archiving = false
+		default:
+		}
+		block, err := blockchain.getBlock(1)
+		testutil.AssertNoError(t, err, "Error fetching block")
+		testutil.AssertNotNil(t, block)
+	}
+}
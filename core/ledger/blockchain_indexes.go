@@ -17,24 +17,29 @@ limitations under the License.
 package ledger
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var indexLogger = logging.MustGetLogger("indexes")
 var prefixBlockHashKey = byte(1)
 var prefixTxUUIDKey = byte(2)
 var prefixAddressBlockNumCompositeKey = byte(3)
+var prefixTxReadWriteSetKey = byte(4)
+var prefixChaincodeTxKey = byte(5)
+var prefixTypeTxKey = byte(6)
+var prefixTimeTxKey = byte(7)
 
 type blockchainIndexer interface {
 	isSynchronous() bool
 	start(blockchain *blockchain) error
-	createIndexesSync(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error
+	createIndexesSync(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error
 	createIndexesAsync(block *protos.Block, blockNumber uint64, blockHash []byte) error
 	fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error)
 	fetchTransactionIndexByUUID(txUUID string) (uint64, uint64, error)
@@ -43,6 +48,7 @@ type blockchainIndexer interface {
 
 // Implementation for sync indexer
 type blockchainIndexerSync struct {
+	db *db.OpenchainDB
 }
 
 func newBlockchainIndexerSync() *blockchainIndexerSync {
@@ -54,12 +60,13 @@ func (indexer *blockchainIndexerSync) isSynchronous() bool {
 }
 
 func (indexer *blockchainIndexerSync) start(blockchain *blockchain) error {
+	indexer.db = blockchain.db
 	return nil
 }
 
 func (indexer *blockchainIndexerSync) createIndexesSync(
-	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	return addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
+	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error {
+	return addIndexDataForPersistence(indexer.db, block, blockNumber, blockHash, writeBatch)
 }
 
 func (indexer *blockchainIndexerSync) createIndexesAsync(block *protos.Block, blockNumber uint64, blockHash []byte) error {
@@ -67,11 +74,11 @@ func (indexer *blockchainIndexerSync) createIndexesAsync(block *protos.Block, bl
 }
 
 func (indexer *blockchainIndexerSync) fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error) {
-	return fetchBlockNumberByBlockHashFromDB(blockHash)
+	return fetchBlockNumberByBlockHashFromDB(indexer.db, blockHash)
 }
 
 func (indexer *blockchainIndexerSync) fetchTransactionIndexByUUID(txUUID string) (uint64, uint64, error) {
-	return fetchTransactionIndexByUUIDFromDB(txUUID)
+	return fetchTransactionIndexByUUIDFromDB(indexer.db, txUUID)
 }
 
 func (indexer *blockchainIndexerSync) stop() {
@@ -79,8 +86,7 @@ func (indexer *blockchainIndexerSync) stop() {
 }
 
 // Functions for persisting and retrieving index data
-func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	openchainDB := db.GetDBHandle()
+func addIndexDataForPersistence(openchainDB *db.OpenchainDB, block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error {
 	cf := openchainDB.IndexesCF
 
 	// add blockhash -> blockNumber
@@ -95,11 +101,18 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 		// add TxUUID -> (blockNumber,indexWithinBlock)
 		writeBatch.PutCF(cf, encodeTxUUIDKey(tx.Uuid), encodeBlockNumTxIndex(blockNumber, uint64(txIndex)))
 
+		// add (chaincode name,blockNumber,indexWithinBlock), (type,...) and (timestamp,...) for listing transactions
+		blockNumTxIndex := encodeBlockNumTxIndex(blockNumber, uint64(txIndex))
+		writeBatch.PutCF(cf, encodeChaincodeTxKey(getTxChaincodeName(tx), blockNumber, uint64(txIndex)), blockNumTxIndex)
+		writeBatch.PutCF(cf, encodeTypeTxKey(tx.Type, blockNumber, uint64(txIndex)), blockNumTxIndex)
+		seconds, nanos := getTxTimestamp(tx)
+		writeBatch.PutCF(cf, encodeTimeTxKey(seconds, nanos, blockNumber, uint64(txIndex)), blockNumTxIndex)
+
 		txExecutingAddress := getTxExecutingAddress(tx)
 		addressToTxIndexesMap[txExecutingAddress] = append(addressToTxIndexesMap[txExecutingAddress], uint64(txIndex))
 
 		switch tx.Type {
-		case protos.Transaction_CHAINCODE_DEPLOY, protos.Transaction_CHAINCODE_INVOKE:
+		case protos.Transaction_CHAINCODE_DEPLOY, protos.Transaction_CHAINCODE_UPGRADE, protos.Transaction_CHAINCODE_INVOKE:
 			authroizedAddresses, chaincodeID := getAuthorisedAddresses(tx)
 			for _, authroizedAddress := range authroizedAddresses {
 				addressToChaincodeIDsMap[authroizedAddress] = append(addressToChaincodeIDsMap[authroizedAddress], chaincodeID)
@@ -112,9 +125,9 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 	return nil
 }
 
-func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
+func fetchBlockNumberByBlockHashFromDB(openchainDB *db.OpenchainDB, blockHash []byte) (uint64, error) {
 	indexLogger.Debugf("fetchBlockNumberByBlockHashFromDB() for blockhash [%x]", blockHash)
-	blockNumberBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeBlockHashKey(blockHash))
+	blockNumberBytes, err := openchainDB.GetFromIndexesCF(encodeBlockHashKey(blockHash))
 	if err != nil {
 		return 0, err
 	}
@@ -126,8 +139,8 @@ func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
 	return blockNumber, nil
 }
 
-func fetchTransactionIndexByUUIDFromDB(txUUID string) (uint64, uint64, error) {
-	blockNumTxIndexBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeTxUUIDKey(txUUID))
+func fetchTransactionIndexByUUIDFromDB(openchainDB *db.OpenchainDB, txUUID string) (uint64, uint64, error) {
+	blockNumTxIndexBytes, err := openchainDB.GetFromIndexesCF(encodeTxUUIDKey(txUUID))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -137,11 +150,59 @@ func fetchTransactionIndexByUUIDFromDB(txUUID string) (uint64, uint64, error) {
 	return decodeBlockNumTxIndex(blockNumTxIndexBytes)
 }
 
+// addTxReadWriteSetsForPersistence adds the read/write sets of the transactions of a block to the write batch.
+// Unlike the other indexes, these are always written along with the block as they are only available in memory
+// until the block is committed
+func addTxReadWriteSetsForPersistence(openchainDB *db.OpenchainDB, transactions []*protos.Transaction, rwSets map[string]*statemgmt.TxReadWriteSet, writeBatch db.WriteBatch) {
+	cf := openchainDB.IndexesCF
+	for _, tx := range transactions {
+		rwSet, ok := rwSets[tx.Uuid]
+		if !ok {
+			continue
+		}
+		writeBatch.PutCF(cf, encodeTxReadWriteSetKey(tx.Uuid), rwSet.Marshal())
+	}
+}
+
+func fetchTxReadWriteSetFromDB(openchainDB *db.OpenchainDB, txUUID string) (*statemgmt.TxReadWriteSet, error) {
+	rwSetBytes, err := openchainDB.GetFromIndexesCF(encodeTxReadWriteSetKey(txUUID))
+	if err != nil {
+		return nil, err
+	}
+	if rwSetBytes == nil {
+		return nil, ErrResourceNotFound
+	}
+	rwSet := statemgmt.NewTxReadWriteSet()
+	err = rwSet.Unmarshal(rwSetBytes)
+	if err != nil {
+		return nil, err
+	}
+	return rwSet, nil
+}
+
 func getTxExecutingAddress(tx *protos.Transaction) string {
 	// TODO Fetch address form tx
 	return "address1"
 }
 
+// getTxChaincodeName returns the name of the chaincode targeted by tx, empty
+// if its chaincode ID does not unmarshal
+func getTxChaincodeName(tx *protos.Transaction) string {
+	cID := &protos.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, cID); err != nil {
+		return ""
+	}
+	return cID.Name
+}
+
+// getTxTimestamp returns the timestamp of tx, zero if it has none
+func getTxTimestamp(tx *protos.Transaction) (int64, int32) {
+	if tx.Timestamp == nil {
+		return 0, 0
+	}
+	return tx.Timestamp.Seconds, tx.Timestamp.Nanos
+}
+
 func getAuthorisedAddresses(tx *protos.Transaction) ([]string, *protos.ChaincodeID) {
 	// TODO fetch address from chaincode deployment tx
 	// TODO this method should also return error
@@ -196,6 +257,10 @@ func encodeTxUUIDKey(txUUID string) []byte {
 	return prependKeyPrefix(prefixTxUUIDKey, []byte(txUUID))
 }
 
+func encodeTxReadWriteSetKey(txUUID string) []byte {
+	return prependKeyPrefix(prefixTxReadWriteSetKey, []byte(txUUID))
+}
+
 func encodeAddressBlockNumCompositeKey(address string, blockNumber uint64) []byte {
 	b := proto.NewBuffer([]byte{prefixAddressBlockNumCompositeKey})
 	b.EncodeRawBytes([]byte(address))
@@ -203,6 +268,54 @@ func encodeAddressBlockNumCompositeKey(address string, blockNumber uint64) []byt
 	return b.Bytes()
 }
 
+// The keys of the indexes listing transactions end with the block number and
+// the index of the transaction within the block, in big endian so that the
+// keys sort in the order of the blockchain.
+func appendBlockNumTxIndex(key []byte, blockNumber uint64, txIndex uint64) []byte {
+	position := make([]byte, 16)
+	binary.BigEndian.PutUint64(position, blockNumber)
+	binary.BigEndian.PutUint64(position[8:], txIndex)
+	return append(key, position...)
+}
+
+func encodeChaincodeTxKeyPrefix(chaincodeName string) []byte {
+	b := proto.NewBuffer([]byte{prefixChaincodeTxKey})
+	b.EncodeRawBytes([]byte(chaincodeName))
+	return b.Bytes()
+}
+
+func encodeChaincodeTxKey(chaincodeName string, blockNumber uint64, txIndex uint64) []byte {
+	return appendBlockNumTxIndex(encodeChaincodeTxKeyPrefix(chaincodeName), blockNumber, txIndex)
+}
+
+func encodeTypeTxKeyPrefix(txType protos.Transaction_Type) []byte {
+	key := []byte{prefixTypeTxKey, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(key[1:], uint32(txType))
+	return key
+}
+
+func encodeTypeTxKey(txType protos.Transaction_Type, blockNumber uint64, txIndex uint64) []byte {
+	return appendBlockNumTxIndex(encodeTypeTxKeyPrefix(txType), blockNumber, txIndex)
+}
+
+// encodeTimeTxKeyPrefix encodes a timestamp so that the keys sort in time
+// order, the timestamps before 1970 sorting first
+func encodeTimeTxKeyPrefix(seconds int64, nanos int32) []byte {
+	key := make([]byte, 13)
+	key[0] = prefixTimeTxKey
+	if seconds > 0 {
+		binary.BigEndian.PutUint64(key[1:], uint64(seconds))
+	}
+	if seconds >= 0 && nanos > 0 {
+		binary.BigEndian.PutUint32(key[9:], uint32(nanos))
+	}
+	return key
+}
+
+func encodeTimeTxKey(seconds int64, nanos int32, blockNumber uint64, txIndex uint64) []byte {
+	return appendBlockNumTxIndex(encodeTimeTxKeyPrefix(seconds, nanos), blockNumber, txIndex)
+}
+
 func encodeListTxIndexes(listTx []uint64) []byte {
 	b := proto.NewBuffer([]byte{})
 	for i := range listTx {
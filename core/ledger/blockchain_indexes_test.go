@@ -65,6 +65,13 @@ func TestIndexes_GetTransactionByUUID(t *testing.T) {
 	testIndexesGetTransactionByUUID(t)
 }
 
+func TestIndexes_GetBlockByTxUUID(t *testing.T) {
+	defaultSetting := indexBlockDataSynchronously
+	indexBlockDataSynchronously = true
+	defer func() { indexBlockDataSynchronously = defaultSetting }()
+	testIndexesGetBlockByTxUUID(t)
+}
+
 func testIndexesGetBlockByBlockNumber(t *testing.T) {
 	testDBWrapper.CreateFreshDB(t)
 	testBlockchainWrapper := newTestBlockchainWrapper(t)
@@ -159,3 +166,22 @@ func testIndexesGetTransactionByUUID(t *testing.T) {
 	testutil.AssertEquals(t, testBlockchainWrapper.getTransactionByUUID(uuid3), tx3)
 	testutil.AssertEquals(t, testBlockchainWrapper.getTransactionByUUID(uuid4), tx4)
 }
+
+func testIndexesGetBlockByTxUUID(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testBlockchainWrapper := newTestBlockchainWrapper(t)
+	defer func() { testBlockchainWrapper.blockchain.indexer.stop() }()
+	tx1, uuid1 := buildTestTx(t)
+	tx2, uuid2 := buildTestTx(t)
+	blockNumber1 := testBlockchainWrapper.addNewBlock(protos.NewBlock([]*protos.Transaction{tx1, tx2}, nil), []byte("stateHash1"))
+
+	tx3, uuid3 := buildTestTx(t)
+	blockNumber2 := testBlockchainWrapper.addNewBlock(protos.NewBlock([]*protos.Transaction{tx3}, nil), []byte("stateHash2"))
+
+	testutil.AssertEquals(t, testBlockchainWrapper.getBlockByTxUUID(uuid1), testBlockchainWrapper.getBlock(blockNumber1))
+	testutil.AssertEquals(t, testBlockchainWrapper.getBlockByTxUUID(uuid2), testBlockchainWrapper.getBlock(blockNumber1))
+	testutil.AssertEquals(t, testBlockchainWrapper.getBlockByTxUUID(uuid3), testBlockchainWrapper.getBlock(blockNumber2))
+
+	_, err := testBlockchainWrapper.blockchain.getBlockByTxUUID("non-existing-uuid")
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+}
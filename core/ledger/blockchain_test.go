@@ -20,7 +20,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	ledgerutil "github.com/hyperledger/fabric/core/ledger/util"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
 )
@@ -73,6 +75,15 @@ func TestBlockChain_SingleBlock(t *testing.T) {
 	testutil.AssertEquals(t, blockchainTestWrapper.fetchBlockchainSizeFromDB(), uint64(1))
 }
 
+func TestBlockChain_GenesisHashAlgorithm(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	blockchainTestWrapper := newTestBlockchainWrapper(t)
+	blockchainTestWrapper.addNewBlock(protos.NewBlock(nil, nil), []byte("stateHash0"))
+	blockchainTestWrapper.addNewBlock(protos.NewBlock(nil, nil), []byte("stateHash1"))
+	testutil.AssertEquals(t, blockchainTestWrapper.getBlock(0).HashAlgorithm, util.GetDefaultHashName())
+	testutil.AssertEquals(t, blockchainTestWrapper.getBlock(1).HashAlgorithm, "")
+}
+
 func TestBlockChain_SimpleChain(t *testing.T) {
 	testDBWrapper.CreateFreshDB(t)
 	blockchainTestWrapper := newTestBlockchainWrapper(t)
@@ -137,3 +148,28 @@ func TestBlockchainBlockLedgerCommitTimestamp(t *testing.T) {
 		t.Fatal("Expected block time to be after start time")
 	}
 }
+
+func TestBlockchainCompression(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	blockchainTestWrapper := newTestBlockchainWrapper(t)
+	blockchain := blockchainTestWrapper.blockchain
+	tx, _ := buildTestTx(t)
+
+	// blocks written before and after enabling compression are both readable
+	blockchainTestWrapper.addNewBlock(protos.NewBlock([]*protos.Transaction{tx}, nil), []byte("stateHash0"))
+	blockchain.compression = ledgerutil.CompressionZlib
+	blockchainTestWrapper.addNewBlock(protos.NewBlock([]*protos.Transaction{tx}, nil), []byte("stateHash1"))
+
+	uncompressedBytes, err := db.GetDBHandle().GetFromBlockchainCF(encodeBlockNumberDBKey(0))
+	testutil.AssertNoError(t, err, "Error fetching block 0 from the DB")
+	compressedBytes, err := db.GetDBHandle().GetFromBlockchainCF(encodeBlockNumberDBKey(1))
+	testutil.AssertNoError(t, err, "Error fetching block 1 from the DB")
+	block0 := blockchainTestWrapper.getBlock(0)
+	block1 := blockchainTestWrapper.getBlock(1)
+	block0Bytes, _ := block0.Bytes()
+	block1Bytes, _ := block1.Bytes()
+	testutil.AssertEquals(t, uncompressedBytes, block0Bytes)
+	testutil.AssertNotEquals(t, compressedBytes, block1Bytes)
+	testutil.AssertEquals(t, block1.StateHash, []byte("stateHash1"))
+	testutil.AssertEquals(t, block1.Transactions[0].Uuid, tx.Uuid)
+}
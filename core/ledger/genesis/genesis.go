@@ -17,9 +17,11 @@ limitations under the License.
 package genesis
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/util"
 	"github.com/op/go-logging"
 )
 
@@ -29,7 +31,8 @@ var makeGenesisError error
 var once sync.Once
 
 // MakeGenesis creates the genesis block based on configuration in core.yaml
-// and adds it to the blockchain.
+// and adds it to the blockchain. If the genesis block already exists, the
+// hash algorithm recorded in it is checked against the configured one.
 func MakeGenesis() error {
 	once.Do(func() {
 		ledger, err := ledger.GetLedger()
@@ -43,6 +46,22 @@ func MakeGenesis() error {
 			if makeGenesisError = ledger.BeginTxBatch(0); makeGenesisError == nil {
 				makeGenesisError = ledger.CommitTxBatch(0, nil, nil, nil)
 			}
+			return
+		}
+
+		genesisBlock, err := ledger.GetBlockByNumber(0)
+		if err != nil {
+			makeGenesisError = err
+			return
+		}
+		// Blocks created before the hash algorithm was recorded used SHAKE256
+		recorded := genesisBlock.HashAlgorithm
+		if recorded == "" {
+			recorded = util.HashSHAKE256
+		}
+		if !util.IsSameHash(recorded, util.GetDefaultHashName()) {
+			makeGenesisError = fmt.Errorf("Configured hash algorithm [%s] does not match the one recorded in the genesis block [%s]",
+				util.GetDefaultHashName(), recorded)
 		}
 	})
 	return makeGenesisError
@@ -21,17 +21,18 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 	"github.com/hyperledger/fabric/events/producer"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 
 	"github.com/hyperledger/fabric/protos"
 	"golang.org/x/net/context"
+	"os"
 )
 
 var ledgerLogger = logging.MustGetLogger("ledger")
@@ -82,12 +83,25 @@ type Ledger struct {
 	blockchain *blockchain
 	state      *state.State
 	currentID  interface{}
+	db         *db.OpenchainDB
+	blockAdded blockNotifier
+	txResults  *txResultCache
+}
+
+// blockNotifier closes its channel once a block is added, and starts over
+type blockNotifier struct {
+	sync.Mutex
+	ch chan struct{}
 }
 
 var ledger *Ledger
 var ledgerError error
 var once sync.Once
 
+// ledgers are the ledgers other than the default ledger, by ID
+var ledgers = make(map[string]*Ledger)
+var ledgersLock sync.Mutex
+
 // GetLedger - gives a reference to a 'singleton' ledger
 func GetLedger() (*Ledger, error) {
 	once.Do(func() {
@@ -96,15 +110,58 @@ func GetLedger() (*Ledger, error) {
 	return ledger, ledgerError
 }
 
+// GetLedgerByID gives a reference to the 'singleton' ledger with the given ID,
+// creating the ledger if it does not exist yet. Only the ledgers declared in
+// ledger.ledgers are served. The empty ID is the ID of the
+// default ledger returned by GetLedger. Every ledger has its own blockchain,
+// world state and indexes, isolated from those of the other ledgers.
+func GetLedgerByID(ledgerID string) (*Ledger, error) {
+	if ledgerID == "" {
+		return GetLedger()
+	}
+	ledgersLock.Lock()
+	defer ledgersLock.Unlock()
+	if ledger, ok := ledgers[ledgerID]; ok {
+		return ledger, nil
+	}
+	openchainDB, err := db.GetLedgerDBHandle(ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := newLedger(openchainDB)
+	if err != nil {
+		return nil, err
+	}
+	ledgers[ledgerID] = ledger
+	return ledger, nil
+}
+
+// CheckLedgerID returns an error unless a ledger with the given ID can be
+// obtained with GetLedgerByID, that is unless it is declared in
+// ledger.ledgers, so that transactions for other ledgers are rejected before
+// they are executed
+func CheckLedgerID(ledgerID string) error {
+	return db.CheckLedgerID(ledgerID)
+}
+
 // GetNewLedger - gives a reference to a new ledger TODO need better approach
 func GetNewLedger() (*Ledger, error) {
-	blockchain, err := newBlockchain()
+	return newLedger(db.GetDBHandle())
+}
+
+func newLedger(openchainDB *db.OpenchainDB) (*Ledger, error) {
+	blockchain, err := newBlockchain(openchainDB)
 	if err != nil {
 		return nil, err
 	}
 
-	state := state.NewState()
-	return &Ledger{blockchain, state, nil}, nil
+	state := state.NewState(openchainDB)
+	return &Ledger{blockchain: blockchain, state: state, db: openchainDB, txResults: newLedgerTxResultCache()}, nil
+}
+
+// ID returns the ID of the ledger, which is empty for the default ledger
+func (ledger *Ledger) ID() string {
+	return ledger.db.LedgerID()
 }
 
 /////////////////// Transaction-batch related methods ///////////////////////////////
@@ -143,33 +200,67 @@ func (ledger *Ledger) GetTXBatchPreviewBlockInfo(id interface{},
 // CommitTxBatch - gets invoked when the current transaction-batch needs to be committed
 // This function returns successfully iff the transactions details and state changes (that
 // may have happened during execution of this transaction-batch) have been committed to permanent storage
+//
+// The commit is pipelined. The read/write sets of the transactions are serialized while the state hash
+// is computed. The block, which includes the state hash, is then built and indexed while the state changes
+// are added to the write batch. All the changes are finally written to the DB atomically.
+//
+// The traced transactions have a commit span, and are committed without their trace context.
 func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Transaction, transactionResults []*protos.TransactionResult, metadata []byte) error {
+	spans := startTxSpans("commit", transactions)
+	err := ledger.commitTxBatch(id, transactions, transactionResults, metadata)
+	for _, span := range spans {
+		if err == nil {
+			span.SetTag("block", ledger.blockchain.getSize()-1)
+		}
+		span.SetError(err)
+		span.Finish()
+	}
+	return err
+}
+
+func (ledger *Ledger) commitTxBatch(id interface{}, transactions []*protos.Transaction, transactionResults []*protos.TransactionResult, metadata []byte) error {
+	start := time.Now()
 	err := ledger.checkValidIDCommitORRollback(id)
 	if err != nil {
 		return err
 	}
 
+	rwSetsBatch := newBufferedWriteBatch()
+	rwSetsDone := make(chan struct{})
+	go func() {
+		addTxReadWriteSetsForPersistence(ledger.db, transactions, ledger.state.GetTxReadWriteSets(), rwSetsBatch)
+		close(rwSetsDone)
+	}()
 	stateHash, err := ledger.state.GetHash()
+	<-rwSetsDone
 	if err != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
 		return err
 	}
 
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := ledger.db.NewWriteBatch()
 	defer writeBatch.Destroy()
-	block := protos.NewBlock(transactions, metadata)
+	block := protos.NewBlock(withoutTraceContext(transactions), metadata)
 	block.NonHashData = &protos.NonHashData{TransactionResults: transactionResults}
-	newBlockNumber, err := ledger.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, writeBatch)
-	if err != nil {
+	blockBatch := newBufferedWriteBatch()
+	var blockErr error
+	blockDone := make(chan struct{})
+	go func() {
+		_, blockErr = ledger.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, blockBatch)
+		close(blockDone)
+	}()
+	ledger.state.AddChangesForPersistence(ledger.blockchain.getSize(), writeBatch)
+	<-blockDone
+	if blockErr != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
-		return err
+		return blockErr
 	}
-	ledger.state.AddChangesForPersistence(newBlockNumber, writeBatch)
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	dbErr := db.GetDBHandle().DB.Write(opt, writeBatch)
+	blockBatch.addTo(writeBatch)
+	rwSetsBatch.addTo(writeBatch)
+	dbErr := ledger.db.Write(writeBatch)
 	if dbErr != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
@@ -179,7 +270,11 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 	ledger.resetForNextTxGroup(true)
 	ledger.blockchain.blockPersistenceStatus(true)
 
-	sendProducerBlockEvent(block)
+	blockNumber := ledger.blockchain.getSize() - 1
+	ledger.txResults.committed(block, blockNumber)
+	ledger.recordCommit(block, start)
+	sendProducerBlockEvent(block, blockNumber)
+	ledger.notifyBlockAdded()
 	return nil
 }
 
@@ -206,6 +301,18 @@ func (ledger *Ledger) TxFinished(txUUID string, txSuccessful bool) {
 	ledger.state.TxFinish(txUUID, txSuccessful)
 }
 
+// NestedTxBegin - Marks the begin of a nested invocation, such as a chaincode invoked by another
+// chaincode, within the on-going transaction
+func (ledger *Ledger) NestedTxBegin(txUUID string) {
+	ledger.state.NestedTxBegin(txUUID)
+}
+
+// NestedTxFinished - Marks the finish of the innermost nested invocation of the on-going transaction.
+// Its reads are merged into the transaction and so are its state changes, if txSuccessful is true
+func (ledger *Ledger) NestedTxFinished(txUUID string, txSuccessful bool) {
+	ledger.state.NestedTxFinish(txUUID, txSuccessful)
+}
+
 /////////////////// world-state related methods /////////////////////////////////////
 /////////////////////////////////////////////////////////////////////////////////////
 
@@ -229,6 +336,35 @@ func (ledger *Ledger) GetState(chaincodeID string, key string, committed bool) (
 	return ledger.state.Get(chaincodeID, key, committed)
 }
 
+// GetStateAt returns the committed value of the key as of the given block, that is after the
+// transactions of the block. The value is derived from the state deltas, which are kept for the
+// number of blocks configured in ledger.state.deltaHistorySize.
+func (ledger *Ledger) GetStateAt(chaincodeID string, key string, blockNumber uint64) ([]byte, error) {
+	for {
+		// The size is read from the db, which persists it in the same write batch as the
+		// state, so a block committed while the value is read shows as a different size
+		size, err := fetchBlockchainSizeFromDB(ledger.db)
+		if err != nil {
+			return nil, err
+		}
+		if blockNumber >= size {
+			return nil, ErrOutOfBounds
+		}
+		value, err := ledger.state.GetAt(chaincodeID, key, blockNumber, size-1)
+		if err != nil {
+			return nil, err
+		}
+		sizeAfter, err := fetchBlockchainSizeFromDB(ledger.db)
+		if err != nil {
+			return nil, err
+		}
+		if sizeAfter == size {
+			return value, nil
+		}
+		ledgerLogger.Debugf("Block [%d] committed while reading key [%s] of chaincode [%s], retrying", size, key, chaincodeID)
+	}
+}
+
 // GetStateRangeScanIterator returns an iterator to get all the keys (and values) between startKey and endKey
 // (assuming lexical order of the keys) for a chaincodeID.
 // If committed is true, the key-values are retrieved only from the db. If committed is false, the results from db
@@ -266,6 +402,12 @@ func (ledger *Ledger) GetStateMultipleKeys(chaincodeID string, keys []string, co
 // SetStateMultipleKeys sets the values for the multiple keys.
 // This method is mainly to amortize the cost of grpc communication between chaincode shim peer
 func (ledger *Ledger) SetStateMultipleKeys(chaincodeID string, kvs map[string][]byte) error {
+	for key, value := range kvs {
+		if key == "" || value == nil {
+			return newLedgerError(ErrorTypeInvalidArgument,
+				fmt.Sprintf("An empty string key or a nil value is not supported. Method invoked with key='%s', value='%#v'", key, value))
+		}
+	}
 	return ledger.state.SetMultipleKeys(chaincodeID, kvs)
 }
 
@@ -273,8 +415,8 @@ func (ledger *Ledger) SetStateMultipleKeys(chaincodeID string, kvs map[string][]
 // should be used when transferring the state from one peer to another peer. You must call
 // stateSnapshot.Release() once you are done with the snapsnot to free up resources.
 func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
-	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
+	dbSnapshot := ledger.db.GetSnapshot()
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(ledger.db, dbSnapshot)
 	if err != nil {
 		dbSnapshot.Release()
 		return nil, err
@@ -286,6 +428,34 @@ func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
 	return ledger.state.GetSnapshot(blockHeight-1, dbSnapshot)
 }
 
+// GetStateProof returns a proof of the committed value of the key at the given block, which
+// can be checked with verify.VerifyStateProof against the StateHash of the block. Only the
+// state at the last block can be proven. If the key does not exist, returns a proof of
+// non-inclusion for a 'sparsemerkle' state and nil for the other state implementations.
+func (ledger *Ledger) GetStateProof(chaincodeID string, key string, blockNumber uint64) (*verify.StateProof, error) {
+	size := ledger.GetBlockchainSize()
+	if blockNumber >= size {
+		return nil, ErrOutOfBounds
+	}
+	if blockNumber != size-1 {
+		return nil, newLedgerError(ErrorTypeInvalidArgument,
+			fmt.Sprintf("Only the state at the last block [%d] can be proven, requested block [%d]", size-1, blockNumber))
+	}
+	block, err := ledger.blockchain.getBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := ledger.state.GetStateProof(chaincodeID, key)
+	if err != nil || proof == nil {
+		return nil, err
+	}
+	// a block committed while the proof was built leaves it matching neither block
+	if err := verify.VerifyStateProof(proof, block.StateHash); err != nil {
+		return nil, fmt.Errorf("Error verifying the proof for block [%d], the state may have changed meanwhile: %s", blockNumber, err)
+	}
+	return proof, nil
+}
+
 // GetStateDelta will return the state delta for the specified block if
 // available.  If not available because it has been discarded, returns nil,nil.
 func (ledger *Ledger) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
@@ -313,10 +483,13 @@ func (ledger *Ledger) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta,
 // be used to roll forwards from state at block 2 to state at block 3. If
 // stateDelta.RollBackwards=false, the delta retrieved for block 3 can be
 // used to roll backwards from the state at block 3 to the state at block 2.
+// Several deltas may be applied with the same id before committing, they are
+// then committed together.
 func (ledger *Ledger) ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error {
-	err := ledger.checkValidIDBegin()
-	if err != nil {
-		return err
+	if ledger.currentID == nil || !reflect.DeepEqual(ledger.currentID, id) {
+		if err := ledger.checkValidIDBegin(); err != nil {
+			return err
+		}
 	}
 	ledger.currentID = id
 	ledger.state.ApplyStateDelta(delta)
@@ -334,6 +507,21 @@ func (ledger *Ledger) CommitStateDelta(id interface{}) error {
 	return ledger.state.CommitStateDelta()
 }
 
+// CommitBlockStateDelta commits the state delta passed to
+// ledger.ApplyStateDelta as the state delta of block blockNumber, which is
+// recorded so that GetStateDelta serves it to other peers
+func (ledger *Ledger) CommitBlockStateDelta(id interface{}, blockNumber uint64) error {
+	err := ledger.checkValidIDCommitORRollback(id)
+	if err != nil {
+		return err
+	}
+	defer ledger.resetForNextTxGroup(true)
+	writeBatch := ledger.db.NewWriteBatch()
+	defer writeBatch.Destroy()
+	ledger.state.AddChangesForPersistence(blockNumber, writeBatch)
+	return ledger.db.Write(writeBatch)
+}
+
 // RollbackStateDelta will discard the state delta passed
 // to ledger.ApplyStateDelta
 func (ledger *Ledger) RollbackStateDelta(id interface{}) error {
@@ -352,6 +540,29 @@ func (ledger *Ledger) DeleteALLStateKeysAndValues() error {
 	return ledger.state.DeleteState()
 }
 
+// RehashState rebuilds the world state for a bucket tree with numBuckets
+// buckets and maxGroupingAtEachLevel, using scratchDir, which must not exist,
+// for the copy of the state made meanwhile. The blocks are not changed, so the
+// state hash of the last block no longer matches the state. The ledger must
+// not be used otherwise, and the peer is to be restarted with the new bucket
+// tree configuration afterwards.
+func (ledger *Ledger) RehashState(numBuckets int, maxGroupingAtEachLevel int, scratchDir string) ([]byte, error) {
+	if ledger.currentID != nil {
+		return nil, fmt.Errorf("Cannot rehash the state while the tx batch [%v] is in progress", ledger.currentID)
+	}
+	if numBuckets < 1 || maxGroupingAtEachLevel < 2 {
+		return nil, fmt.Errorf("Invalid bucket tree configuration numBuckets=[%d], maxGroupingAtEachLevel=[%d]", numBuckets, maxGroupingAtEachLevel)
+	}
+	if _, err := os.Stat(scratchDir); !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Scratch dir [%s] for rehashing the state must not exist", scratchDir)
+	}
+	stateHash, err := ledger.state.RehashBucketTree(numBuckets, maxGroupingAtEachLevel, scratchDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error rehashing state: %s", err)
+	}
+	return stateHash, nil
+}
+
 /////////////////// blockchain related methods /////////////////////////////////////
 /////////////////////////////////////////////////////////////////////////////////////
 
@@ -375,11 +586,23 @@ func (ledger *Ledger) GetBlockchainSize() uint64 {
 	return ledger.blockchain.getSize()
 }
 
+// GetBlockByTxID return the block containing the transaction with the given uuid.
+// Returns ErrResourceNotFound if no committed transaction has this uuid
+func (ledger *Ledger) GetBlockByTxID(txUUID string) (*protos.Block, error) {
+	return ledger.blockchain.getBlockByTxUUID(txUUID)
+}
+
 // GetTransactionByUUID return transaction by it's uuid
 func (ledger *Ledger) GetTransactionByUUID(txUUID string) (*protos.Transaction, error) {
 	return ledger.blockchain.getTransactionByUUID(txUUID)
 }
 
+// GetTxReadWriteSet returns the keys read and the changes made by a committed transaction during its
+// execution. Transactions which failed or were received through state transfer have no read/write set
+func (ledger *Ledger) GetTxReadWriteSet(txUUID string) (*statemgmt.TxReadWriteSet, error) {
+	return fetchTxReadWriteSetFromDB(ledger.db, txUUID)
+}
+
 // PutRawBlock puts a raw block on the chain. This function should only be
 // used for synchronization between peers.
 func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error {
@@ -387,10 +610,34 @@ func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error
 	if err != nil {
 		return err
 	}
-	sendProducerBlockEvent(block)
+	ledger.txResults.committed(block, blockNumber)
+	ledgerHeight.Set(float64(ledger.blockchain.getSize()), ledger.db.LedgerID())
+	sendProducerBlockEvent(block, blockNumber)
+	ledger.notifyBlockAdded()
 	return nil
 }
 
+// BlockAdded returns a channel which is closed once a block is added to the
+// blockchain, whether committed or put by state transfer, for the readers
+// following the blockchain to wait on
+func (ledger *Ledger) BlockAdded() <-chan struct{} {
+	ledger.blockAdded.Lock()
+	defer ledger.blockAdded.Unlock()
+	if ledger.blockAdded.ch == nil {
+		ledger.blockAdded.ch = make(chan struct{})
+	}
+	return ledger.blockAdded.ch
+}
+
+func (ledger *Ledger) notifyBlockAdded() {
+	ledger.blockAdded.Lock()
+	defer ledger.blockAdded.Unlock()
+	if ledger.blockAdded.ch != nil {
+		close(ledger.blockAdded.ch)
+		ledger.blockAdded.ch = nil
+	}
+}
+
 // VerifyChain will verify the integrety of the blockchain. This is accomplished
 // by ensuring that the previous block hash stored in each block matches
 // the actual hash of the previous block in the chain. The return value is the
@@ -462,45 +709,10 @@ func (ledger *Ledger) resetForNextTxGroup(txCommited bool) {
 	ledger.state.ClearInMemoryChanges(txCommited)
 }
 
-func sendProducerBlockEvent(block *protos.Block) {
-
-	// Remove payload from deploy transactions. This is done to make block
-	// events more lightweight as the payload for these types of transactions
-	// can be very large.
-	blockTransactions := block.GetTransactions()
-	for _, transaction := range blockTransactions {
-		if transaction.Type == protos.Transaction_CHAINCODE_DEPLOY {
-			deploymentSpec := &protos.ChaincodeDeploymentSpec{}
-			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
-			if err != nil {
-				ledgerLogger.Errorf("Error unmarshalling deployment transaction for block event: %s", err)
-				continue
-			}
-			deploymentSpec.CodePackage = nil
-			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
-			if err != nil {
-				ledgerLogger.Errorf("Error marshalling deployment transaction for block event: %s", err)
-				continue
-			}
-			transaction.Payload = deploymentSpecBytes
-		}
-	}
-
-	producer.Send(producer.CreateBlockEvent(block))
-
-	//when we send block event, send chaincode events as well
-	sendChaincodeEvents(block)
-}
-
-//send chaincode events created by transactions in the block
-func sendChaincodeEvents(block *protos.Block) {
-	nonHashData := block.GetNonHashData()
-	if nonHashData != nil {
-		trs := nonHashData.GetTransactionResults()
-		for _, tr := range trs {
-			if tr.ChaincodeEvent != nil {
-				producer.Send(producer.CreateChaincodeEvent(tr.ChaincodeEvent))
-			}
-		}
+// sendProducerBlockEvent sends the events of block blockNumber: the block
+// event, then the chaincode events and the rejections of its transactions
+func sendProducerBlockEvent(block *protos.Block, blockNumber uint64) {
+	for _, e := range producer.CreateBlockEvents(block, blockNumber) {
+		producer.Send(e)
 	}
 }
@@ -0,0 +1,226 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// number of state snapshot records applied by Import before each commit
+const importCommitSize = 1000
+
+// maximum size of a record accepted by Import
+const maxExportRecordSize = 1 << 30
+
+// Export writes the chain and the state at its last block to w, as a stream of protobuf messages each
+// preceded by its varint encoded size. The stream starts with a BlockchainInfo whose height is the number
+// of Block messages following it. The state follows as SyncStateSnapshot messages holding one key each, in
+// the same way as the state snapshots sent to other peers, terminated by a message with an empty delta.
+// As only the state at the last block is exported, the state deltas of earlier blocks are not needed.
+func (ledger *Ledger) Export(w io.Writer) error {
+	snapshot, err := ledger.GetStateSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+	lastBlockNumber := snapshot.GetBlockNumber()
+	lastBlock, err := ledger.GetBlockByNumber(lastBlockNumber)
+	if err != nil {
+		return fmt.Errorf("Error fetching block %d: %s", lastBlockNumber, err)
+	}
+	lastBlockHash, err := lastBlock.GetHash()
+	if err != nil {
+		return fmt.Errorf("Error computing hash of block %d: %s", lastBlockNumber, err)
+	}
+
+	info := &protos.BlockchainInfo{Height: lastBlockNumber + 1, CurrentBlockHash: lastBlockHash, PreviousBlockHash: lastBlock.PreviousBlockHash}
+	if err := writeExportRecord(w, info); err != nil {
+		return err
+	}
+	for blockNumber := uint64(0); blockNumber <= lastBlockNumber; blockNumber++ {
+		block, err := ledger.GetBlockByNumber(blockNumber)
+		if err != nil {
+			return fmt.Errorf("Error fetching block %d: %s", blockNumber, err)
+		}
+		if err := writeExportRecord(w, block); err != nil {
+			return err
+		}
+	}
+
+	var sequence uint64
+	for ; snapshot.Next(); sequence++ {
+		delta := statemgmt.NewStateDelta()
+		k, v := snapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		delta.Set(chaincodeID, key, v, nil)
+		syncStateSnapshot := &protos.SyncStateSnapshot{Delta: delta.Marshal(), Sequence: sequence, BlockNumber: lastBlockNumber}
+		if err := writeExportRecord(w, syncStateSnapshot); err != nil {
+			return err
+		}
+	}
+	return writeExportRecord(w, &protos.SyncStateSnapshot{Delta: []byte{}, Sequence: sequence, BlockNumber: lastBlockNumber})
+}
+
+// Import reads a chain written by Export into the ledger, which must have no blocks. The hash chaining of
+// the blocks is verified, and so is the imported state against the state hash of the last block, which
+// requires the state implementation to be configured the same way as on the exporting peer. The blocks
+// are only stored once the state is verified, on error the ledger is left without blocks and state.
+func (ledger *Ledger) Import(r io.Reader) error {
+	if size := ledger.GetBlockchainSize(); size > 0 {
+		return fmt.Errorf("Cannot import chain, ledger already has %d blocks", size)
+	}
+	reader := bufio.NewReader(r)
+	blocks, err := readExportedBlocks(reader)
+	if err != nil {
+		return err
+	}
+	lastBlock := blocks[len(blocks)-1]
+
+	err = ledger.importState(reader, uint64(len(blocks)-1), lastBlock.StateHash)
+	if err != nil {
+		if ledger.currentID != nil {
+			ledger.resetForNextTxGroup(false)
+		}
+		if emptyErr := ledger.DeleteALLStateKeysAndValues(); emptyErr != nil {
+			ledgerLogger.Errorf("Error emptying state after failed import: %s", emptyErr)
+		}
+		return err
+	}
+	for blockNumber, block := range blocks {
+		if err := ledger.PutRawBlock(block, uint64(blockNumber)); err != nil {
+			return fmt.Errorf("Error storing block %d: %s", blockNumber, err)
+		}
+	}
+	return nil
+}
+
+// readExportedBlocks reads the blocks of an exported chain, checking that each block holds the hash of the
+// previous one and that the last block has the hash announced at the start of the stream
+func readExportedBlocks(reader *bufio.Reader) ([]*protos.Block, error) {
+	info := &protos.BlockchainInfo{}
+	if err := readExportRecord(reader, info); err != nil {
+		return nil, fmt.Errorf("Error reading chain info: %s", err)
+	}
+	if info.Height == 0 {
+		return nil, fmt.Errorf("Exported chain has no blocks")
+	}
+	var blocks []*protos.Block
+	var previousBlockHash []byte
+	for blockNumber := uint64(0); blockNumber < info.Height; blockNumber++ {
+		block := &protos.Block{}
+		if err := readExportRecord(reader, block); err != nil {
+			return nil, fmt.Errorf("Error reading block %d: %s", blockNumber, err)
+		}
+		if blockNumber > 0 && !bytes.Equal(block.PreviousBlockHash, previousBlockHash) {
+			return nil, fmt.Errorf("Previous block hash of block %d does not match the hash of block %d", blockNumber, blockNumber-1)
+		}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			return nil, fmt.Errorf("Error computing hash of block %d: %s", blockNumber, err)
+		}
+		blocks = append(blocks, block)
+		previousBlockHash = blockHash
+	}
+	if !bytes.Equal(previousBlockHash, info.CurrentBlockHash) {
+		return nil, fmt.Errorf("Hash %x of the last block does not match the hash %x of the exported chain", previousBlockHash, info.CurrentBlockHash)
+	}
+	return blocks, nil
+}
+
+func (ledger *Ledger) importState(reader *bufio.Reader, blockNumber uint64, stateHash []byte) error {
+	if err := ledger.DeleteALLStateKeysAndValues(); err != nil {
+		return fmt.Errorf("Error emptying state before import: %s", err)
+	}
+	id := "import"
+	for sequence := uint64(0); ; sequence++ {
+		syncStateSnapshot := &protos.SyncStateSnapshot{}
+		if err := readExportRecord(reader, syncStateSnapshot); err != nil {
+			return fmt.Errorf("Error reading state: %s", err)
+		}
+		if syncStateSnapshot.Sequence != sequence || syncStateSnapshot.BlockNumber != blockNumber {
+			return fmt.Errorf("Read state record %d for block %d, expected record %d for block %d",
+				syncStateSnapshot.Sequence, syncStateSnapshot.BlockNumber, sequence, blockNumber)
+		}
+		if len(syncStateSnapshot.Delta) == 0 {
+			break
+		}
+		delta := statemgmt.NewStateDelta()
+		if err := delta.Unmarshal(syncStateSnapshot.Delta); err != nil {
+			return fmt.Errorf("Error unmarshalling state delta: %s", err)
+		}
+		if err := ledger.ApplyStateDelta(id, delta); err != nil {
+			return fmt.Errorf("Error applying state delta: %s", err)
+		}
+		if (sequence+1)%importCommitSize == 0 {
+			if err := ledger.CommitStateDelta(id); err != nil {
+				return fmt.Errorf("Error committing state delta: %s", err)
+			}
+		}
+	}
+	if ledger.currentID != nil {
+		if err := ledger.CommitStateDelta(id); err != nil {
+			return fmt.Errorf("Error committing state delta: %s", err)
+		}
+	}
+
+	importedStateHash, err := ledger.GetTempStateHash()
+	if err != nil {
+		return fmt.Errorf("Error computing hash of imported state: %s", err)
+	}
+	if !bytes.Equal(importedStateHash, stateHash) {
+		return fmt.Errorf("Hash %x of imported state does not match state hash %x of block %d", importedStateHash, stateHash, blockNumber)
+	}
+	return nil
+}
+
+func writeExportRecord(w io.Writer, msg proto.Message) error {
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("Error marshalling %T: %s", msg, err)
+	}
+	if _, err := w.Write(proto.EncodeVarint(uint64(len(msgBytes)))); err != nil {
+		return err
+	}
+	_, err = w.Write(msgBytes)
+	return err
+}
+
+func readExportRecord(reader *bufio.Reader, msg proto.Message) error {
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("Unexpected end of stream")
+		}
+		return err
+	}
+	if size > maxExportRecordSize {
+		return fmt.Errorf("Record size %d exceeds the maximum of %d", size, maxExportRecordSize)
+	}
+	msgBytes := make([]byte, size)
+	if _, err := io.ReadFull(reader, msgBytes); err != nil {
+		return err
+	}
+	return proto.Unmarshal(msgBytes, msg)
+}
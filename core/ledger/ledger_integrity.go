@@ -0,0 +1,159 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+// IntegrityReport lists the problems found by VerifyIntegrity in a range of blocks
+type IntegrityReport struct {
+	StartBlock uint64
+	EndBlock   uint64
+	// BrokenHashChain are the blocks whose previous block hash does not match
+	// the hash of the previous block
+	BrokenHashChain []uint64
+	// StateHashChecked is true if the range ends with the last block, whose
+	// state hash can be checked against the current state
+	StateHashChecked  bool
+	StateHashMismatch bool
+	// MissingIndexes are the blocks for which the block hash index or the index
+	// of one of their transactions is missing or points to another block
+	MissingIndexes []uint64
+}
+
+// IsValid returns true if no problem was found
+func (report *IntegrityReport) IsValid() bool {
+	return len(report.BrokenHashChain) == 0 && !report.StateHashMismatch && len(report.MissingIndexes) == 0
+}
+
+// VerifyIntegrity re-verifies the blocks from startBlock to endBlock (both inclusive). Unlike VerifyChain,
+// which stops at the first broken link, all the blocks of the range are checked for the chaining of their
+// hashes and the completeness of their indexes. If endBlock is the last block of the chain, its state hash is
+// also checked against the current state, which requires that no transaction batch is in progress. An error is
+// only returned if the blocks cannot be read.
+func (ledger *Ledger) VerifyIntegrity(startBlock uint64, endBlock uint64) (*IntegrityReport, error) {
+	if endBlock >= ledger.GetBlockchainSize() || startBlock > endBlock {
+		return nil, ErrOutOfBounds
+	}
+	report := &IntegrityReport{StartBlock: startBlock, EndBlock: endBlock}
+	var previousBlockHash []byte
+	for blockNumber := startBlock; blockNumber <= endBlock; blockNumber++ {
+		block, err := ledger.blockchain.getBlock(blockNumber)
+		if err != nil {
+			return nil, fmt.Errorf("Error fetching block %d: %s", blockNumber, err)
+		}
+		if block == nil {
+			return nil, fmt.Errorf("Block %d is missing from the block store", blockNumber)
+		}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			return nil, fmt.Errorf("Error computing hash of block %d: %s", blockNumber, err)
+		}
+		if blockNumber > startBlock && !bytes.Equal(block.PreviousBlockHash, previousBlockHash) {
+			ledgerLogger.Warningf("Previous block hash of block %d does not match the hash of block %d", blockNumber, blockNumber-1)
+			report.BrokenHashChain = append(report.BrokenHashChain, blockNumber)
+		}
+		indexed, err := ledger.isBlockIndexed(block.GetTransactions(), blockNumber, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		if !indexed {
+			ledgerLogger.Warningf("Indexes of block %d are missing or inconsistent", blockNumber)
+			report.MissingIndexes = append(report.MissingIndexes, blockNumber)
+		}
+		previousBlockHash = blockHash
+
+		if blockNumber == ledger.GetBlockchainSize()-1 && ledger.currentID == nil {
+			stateHash, err := ledger.state.GetHash()
+			if err != nil {
+				return nil, fmt.Errorf("Error computing state hash: %s", err)
+			}
+			report.StateHashChecked = true
+			if !bytes.Equal(stateHash, block.StateHash) {
+				ledgerLogger.Warningf("State hash of block %d does not match the current state", blockNumber)
+				report.StateHashMismatch = true
+			}
+		}
+	}
+	return report, nil
+}
+
+func (ledger *Ledger) isBlockIndexed(transactions []*protos.Transaction, blockNumber uint64, blockHash []byte) (bool, error) {
+	indexedBlockNumber, err := ledger.blockchain.indexer.fetchBlockNumberByBlockHash(blockHash)
+	if err != nil {
+		if ledgerErr, ok := err.(*Error); ok && ledgerErr.Type() == ErrorTypeBlockNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if indexedBlockNumber != blockNumber {
+		return false, nil
+	}
+	for txIndex, tx := range transactions {
+		indexedBlockNumber, indexedTxIndex, err := ledger.blockchain.indexer.fetchTransactionIndexByUUID(tx.Uuid)
+		if err == ErrResourceNotFound {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if indexedBlockNumber != blockNumber || indexedTxIndex != uint64(txIndex) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RepairIndexes rebuilds the indexes of the blocks from startBlock to endBlock (both inclusive) from the
+// blocks in the block store. It must not be called while a transaction batch is being committed.
+func (ledger *Ledger) RepairIndexes(startBlock uint64, endBlock uint64) error {
+	if endBlock >= ledger.GetBlockchainSize() || startBlock > endBlock {
+		return ErrOutOfBounds
+	}
+	for blockNumber := startBlock; blockNumber <= endBlock; blockNumber++ {
+		block, err := ledger.blockchain.getBlock(blockNumber)
+		if err != nil {
+			return fmt.Errorf("Error fetching block %d: %s", blockNumber, err)
+		}
+		if block == nil {
+			return fmt.Errorf("Block %d is missing from the block store", blockNumber)
+		}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			return fmt.Errorf("Error computing hash of block %d: %s", blockNumber, err)
+		}
+		err = ledger.rewriteIndexes(block, blockNumber, blockHash)
+		if err != nil {
+			return fmt.Errorf("Error rebuilding indexes of block %d: %s", blockNumber, err)
+		}
+	}
+	return nil
+}
+
+func (ledger *Ledger) rewriteIndexes(block *protos.Block, blockNumber uint64, blockHash []byte) error {
+	writeBatch := ledger.db.NewWriteBatch()
+	defer writeBatch.Destroy()
+	err := addIndexDataForPersistence(ledger.db, block, blockNumber, blockHash, writeBatch)
+	if err != nil {
+		return err
+	}
+	return ledger.db.Write(writeBatch)
+}
@@ -18,12 +18,21 @@ package ledger
 
 import (
 	"bytes"
+	"fmt"
+	"google/protobuf"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+	"github.com/hyperledger/fabric/core/tracing"
+	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+	"path/filepath"
 )
 
 func TestLedgerCommit(t *testing.T) {
@@ -41,6 +50,46 @@ func TestLedgerCommit(t *testing.T) {
 	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1"))
 }
 
+// spanRecorder records the spans reported
+type spanRecorder struct {
+	spans []*tracing.Span
+}
+
+func (r *spanRecorder) Report(span *tracing.Span) {
+	r.spans = append(r.spans, span)
+}
+
+func TestLedgerCommitTraced(t *testing.T) {
+	recorder := &spanRecorder{}
+	tracing.SetTracer(tracing.NewTracer("vp0", 1, recorder))
+	defer tracing.SetTracer(nil)
+
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(1)
+	traced, _ := buildTestTx(t)
+	traced.TraceContext = &protos.SpanContext{TraceID: 1, SpanID: 2}
+	untraced, _ := buildTestTx(t)
+	err := ledger.CommitTxBatch(1, []*protos.Transaction{traced, untraced}, nil, []byte("proof"))
+	testutil.AssertNoError(t, err, "Error committing the batch")
+
+	block, err := ledger.GetBlockByNumber(ledger.GetBlockchainSize() - 1)
+	testutil.AssertNoError(t, err, "Error getting the block")
+	testutil.AssertEquals(t, len(block.Transactions), 2)
+	for _, tx := range block.Transactions {
+		testutil.AssertNil(t, tx.TraceContext)
+	}
+	testutil.AssertNotNil(t, traced.TraceContext)
+
+	testutil.AssertEquals(t, len(recorder.spans), 1)
+	span := recorder.spans[0]
+	testutil.AssertEquals(t, span.Name, "commit")
+	testutil.AssertEquals(t, span.TraceID, uint64(1))
+	testutil.AssertEquals(t, span.ParentID, uint64(2))
+	testutil.AssertEquals(t, span.Tags["txid"], traced.Uuid)
+	testutil.AssertEquals(t, span.Tags["block"], fmt.Sprint(ledger.GetBlockchainSize()-1))
+}
+
 func TestLedgerRollback(t *testing.T) {
 	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
 	ledger := ledgerTestWrapper.ledger
@@ -315,6 +364,24 @@ func TestLedgerSetRawState(t *testing.T) {
 	}
 }
 
+func TestLedgerCommitBlockStateDelta(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincode1", "key1", []byte("value1"), nil)
+	ledgerTestWrapper.ApplyStateDelta(1, delta)
+	err := ledger.CommitBlockStateDelta(2, 3)
+	testutil.AssertError(t, err, "Expected error committing delta with a different id")
+	err = ledger.CommitBlockStateDelta(1, 3)
+	testutil.AssertNoError(t, err, "Error committing block state delta")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1"))
+
+	block := new(protos.Block)
+	block.StateHash = []byte("bar")
+	ledger.PutRawBlock(block, 3)
+	testutil.AssertEquals(t, ledgerTestWrapper.GetStateDelta(3), delta)
+}
+
 func TestDeleteAllStateKeysAndValues(t *testing.T) {
 	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
 	ledger := ledgerTestWrapper.ledger
@@ -415,6 +482,104 @@ func TestVerifyChain(t *testing.T) {
 	testutil.AssertError(t, err, "Expected error as high block is out of bounds")
 }
 
+func TestVerifyIntegrityAndRepairIndexes(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	var txUUIDs []string
+	for i := 0; i < 5; i++ {
+		transaction, uuid := buildTestTx(t)
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin(uuid)
+		ledger.SetState("chaincode1", "key"+strconv.Itoa(i), []byte("value"+strconv.Itoa(i)))
+		ledger.TxFinished(uuid, true)
+		ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof"))
+		txUUIDs = append(txUUIDs, uuid)
+	}
+
+	report, err := ledger.VerifyIntegrity(0, 4)
+	testutil.AssertNoError(t, err, "Error verifying integrity")
+	testutil.AssertEquals(t, report.IsValid(), true)
+	testutil.AssertEquals(t, report.StateHashChecked, true)
+	report, err = ledger.VerifyIntegrity(1, 3)
+	testutil.AssertNoError(t, err, "Error verifying integrity")
+	testutil.AssertEquals(t, report.IsValid(), true)
+	testutil.AssertEquals(t, report.StateHashChecked, false)
+
+	// Remove the transaction index of block 2 and rebuild it
+	err = db.GetDBHandle().Delete(db.GetDBHandle().IndexesCF, encodeTxUUIDKey(txUUIDs[2]))
+	testutil.AssertNoError(t, err, "Error deleting index")
+	report, err = ledger.VerifyIntegrity(0, 4)
+	testutil.AssertNoError(t, err, "Error verifying integrity")
+	testutil.AssertEquals(t, report.MissingIndexes, []uint64{2})
+	testutil.AssertNoError(t, ledger.RepairIndexes(0, 4), "Error repairing indexes")
+	report, err = ledger.VerifyIntegrity(0, 4)
+	testutil.AssertNoError(t, err, "Error verifying integrity")
+	testutil.AssertEquals(t, report.IsValid(), true)
+
+	// A bad block breaks the chain before and after it
+	goodBlock := ledgerTestWrapper.GetBlockByNumber(2)
+	badBlock := protos.NewBlock(nil, nil)
+	badBlock.PreviousBlockHash = []byte("evil")
+	ledgerTestWrapper.PutRawBlock(badBlock, 2)
+	report, err = ledger.VerifyIntegrity(0, 4)
+	testutil.AssertNoError(t, err, "Error verifying integrity")
+	testutil.AssertEquals(t, report.BrokenHashChain, []uint64{2, 3})
+	ledgerTestWrapper.PutRawBlock(goodBlock, 2)
+
+	// The last block has to match the current state
+	lastBlock := ledgerTestWrapper.GetBlockByNumber(4)
+	lastBlock.StateHash = []byte("evil")
+	ledgerTestWrapper.PutRawBlock(lastBlock, 4)
+	report, err = ledger.VerifyIntegrity(0, 4)
+	testutil.AssertNoError(t, err, "Error verifying integrity")
+	testutil.AssertEquals(t, report.StateHashMismatch, true)
+
+	_, err = ledger.VerifyIntegrity(3, 2)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+	testutil.AssertEquals(t, ledger.RepairIndexes(0, 5), ErrOutOfBounds)
+}
+
+func TestLedgerExportImport(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+	for i := 0; i < 3; i++ {
+		transaction, uuid := buildTestTx(t)
+		l.BeginTxBatch(i)
+		l.TxBegin(uuid)
+		l.SetState("chaincode"+strconv.Itoa(i), "key"+strconv.Itoa(i), []byte("value"+strconv.Itoa(i)))
+		l.TxFinished(uuid, true)
+		l.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	}
+	var exported bytes.Buffer
+	testutil.AssertNoError(t, l.Export(&exported), "Error exporting chain")
+	blocks := []*protos.Block{ledgerTestWrapper.GetBlockByNumber(0), ledgerTestWrapper.GetBlockByNumber(1), ledgerTestWrapper.GetBlockByNumber(2)}
+
+	// Export a chain with a tampered block
+	tamperedBlock := ledgerTestWrapper.GetBlockByNumber(1)
+	tamperedBlock.ConsensusMetadata = []byte("evil")
+	ledgerTestWrapper.PutRawBlock(tamperedBlock, 1)
+	var tampered bytes.Buffer
+	testutil.AssertNoError(t, l.Export(&tampered), "Error exporting chain")
+
+	ledgerTestWrapper = createFreshDBAndTestLedgerWrapper(t)
+	l = ledgerTestWrapper.ledger
+	testutil.AssertError(t, l.Import(&tampered), "Expected error importing a chain with a tampered block")
+	truncated := exported.Bytes()[:exported.Len()-3]
+	testutil.AssertError(t, l.Import(bytes.NewReader(truncated)), "Expected error importing a truncated chain")
+	testutil.AssertEquals(t, l.GetBlockchainSize(), uint64(0))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode0", "key0", true))
+	testutil.AssertNoError(t, l.BeginTxBatch(1), "A failed import should not leave a batch in progress")
+	testutil.AssertNoError(t, l.RollbackTxBatch(1), "Error rolling back batch")
+
+	testutil.AssertNoError(t, l.Import(&exported), "Error importing chain")
+	testutil.AssertEquals(t, l.GetBlockchainSize(), uint64(3))
+	for i, block := range blocks {
+		testutil.AssertEquals(t, ledgerTestWrapper.GetBlockByNumber(uint64(i)), block)
+		testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode"+strconv.Itoa(i), "key"+strconv.Itoa(i), true), []byte("value"+strconv.Itoa(i)))
+	}
+	testutil.AssertError(t, l.Import(bytes.NewReader(truncated)), "Expected error importing into a ledger with blocks")
+}
+
 func TestBlockNumberOutOfBoundsError(t *testing.T) {
 	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
 	ledger := ledgerTestWrapper.ledger
@@ -943,6 +1108,18 @@ func TestLedgerInvalidInput(t *testing.T) {
 		t.Fatal("A 'LedgerError' of type 'ErrorTypeInvalidArgument' should have been thrown")
 	}
 
+	// nil value and empty string key in multiple keys
+	err = l.SetStateMultipleKeys("chaincodeID1", map[string][]byte{"key1": []byte("value1"), "key2": nil})
+	ledgerErr, ok = err.(*Error)
+	if !(ok && ledgerErr.Type() == ErrorTypeInvalidArgument) {
+		t.Fatal("A 'LedgerError' of type 'ErrorTypeInvalidArgument' should have been thrown")
+	}
+	err = l.SetStateMultipleKeys("chaincodeID1", map[string][]byte{"": []byte("value1")})
+	ledgerErr, ok = err.(*Error)
+	if !(ok && ledgerErr.Type() == ErrorTypeInvalidArgument) {
+		t.Fatal("A 'LedgerError' of type 'ErrorTypeInvalidArgument' should have been thrown")
+	}
+
 	l.SetState("chaincodeID1", "key1", []byte("value1"))
 	l.TxFinished("txUUID", true)
 	tx, _ := buildTestTx(t)
@@ -950,3 +1127,309 @@ func TestLedgerInvalidInput(t *testing.T) {
 	value, _ := l.GetState("chaincodeID1", "key1", true)
 	testutil.AssertEquals(t, value, []byte("value1"))
 }
+
+func TestLedgerGetStateProof(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+	for i := 0; i < 2; i++ {
+		l.BeginTxBatch(i)
+		l.TxBegin("txUUID")
+		l.SetState("chaincodeID1", "key1", []byte("value1_"+strconv.Itoa(i)))
+		l.SetState("chaincodeID2", "key"+strconv.Itoa(i), []byte("value2"))
+		l.TxFinished("txUUID", true)
+		tx, _ := buildTestTx(t)
+		l.CommitTxBatch(i, []*protos.Transaction{tx}, nil, nil)
+	}
+
+	proof, err := l.GetStateProof("chaincodeID1", "key1", 1)
+	testutil.AssertNoError(t, err, "Error getting state proof")
+	testutil.AssertEquals(t, proof.Value, []byte("value1_1"))
+	block := ledgerTestWrapper.GetBlockByNumber(1)
+	testutil.AssertNoError(t, verify.VerifyStateProof(proof, block.StateHash), "Error verifying state proof")
+
+	proof.Value = []byte("value1_0")
+	testutil.AssertError(t, verify.VerifyStateProof(proof, block.StateHash), "Expected error verifying a proof with a changed value")
+
+	proof, err = l.GetStateProof("chaincodeID1", "non-existing-key", 1)
+	testutil.AssertNoError(t, err, "Error getting state proof")
+	testutil.AssertNil(t, proof)
+
+	_, err = l.GetStateProof("chaincodeID1", "key1", 0)
+	ledgerErr, ok := err.(*Error)
+	if !(ok && ledgerErr.Type() == ErrorTypeInvalidArgument) {
+		t.Fatalf("A 'LedgerError' of type 'ErrorTypeInvalidArgument' should have been thrown for an earlier block, got [%v]", err)
+	}
+	_, err = l.GetStateProof("chaincodeID1", "key1", 2)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+}
+
+func TestLedgerGetStateAt(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+	for i := 0; i < 3; i++ {
+		l.BeginTxBatch(i)
+		l.TxBegin("txUUID")
+		l.SetState("chaincodeID1", "key1", []byte("value"+strconv.Itoa(i)))
+		if i == 1 {
+			l.SetState("chaincodeID1", "key2", []byte("value"))
+		}
+		l.TxFinished("txUUID", true)
+		tx, _ := buildTestTx(t)
+		l.CommitTxBatch(i, []*protos.Transaction{tx}, nil, nil)
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := l.GetStateAt("chaincodeID1", "key1", uint64(i))
+		testutil.AssertNoError(t, err, "Error getting state at block")
+		testutil.AssertEquals(t, value, []byte("value"+strconv.Itoa(i)))
+	}
+	value, err := l.GetStateAt("chaincodeID1", "key2", 0)
+	testutil.AssertNoError(t, err, "Error getting state at block")
+	testutil.AssertNil(t, value)
+
+	_, err = l.GetStateAt("chaincodeID1", "key1", 3)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+}
+
+func TestLedgerGetTxReadWriteSet(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+	tx, _ := buildTestTx(t)
+	l.BeginTxBatch(0)
+	l.TxBegin(tx.Uuid)
+	l.GetState("chaincodeID1", "key1", false)
+	l.SetState("chaincodeID1", "key2", []byte("value2"))
+	l.TxFinished(tx.Uuid, true)
+	l.CommitTxBatch(0, []*protos.Transaction{tx}, nil, nil)
+
+	rwSet, err := l.GetTxReadWriteSet(tx.Uuid)
+	testutil.AssertNoError(t, err, "Error getting read/write set of tx")
+	testutil.AssertEquals(t, rwSet.Reads, []*statemgmt.KeyRead{{ChaincodeID: "chaincodeID1", Key: "key1"}})
+	testutil.AssertEquals(t, rwSet.Writes.Get("chaincodeID1", "key2").GetValue(), []byte("value2"))
+
+	_, err = l.GetTxReadWriteSet("non-existing-tx")
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+}
+
+func TestLedgerGetLedgerByID(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	defaultLedger := ledgerTestWrapper.ledger
+	viper.Set("ledger.ledgers", []string{"ledger1"})
+	defer func() {
+		viper.Set("ledger.ledgers", nil)
+		ledgersLock.Lock()
+		delete(ledgers, "ledger1")
+		ledgersLock.Unlock()
+	}()
+
+	ledger1, err := GetLedgerByID("ledger1")
+	testutil.AssertNoError(t, err, "Error getting ledger by ID")
+	testutil.AssertEquals(t, ledger1.ID(), "ledger1")
+	sameLedger, _ := GetLedgerByID("ledger1")
+	testutil.AssertSame(t, sameLedger, ledger1)
+
+	tx, _ := buildTestTx(t)
+	ledger1.BeginTxBatch(1)
+	ledger1.TxBegin(tx.Uuid)
+	ledger1.SetState("chaincode1", "key1", []byte("value1"))
+	ledger1.TxFinished(tx.Uuid, true)
+	testutil.AssertNoError(t, ledger1.CommitTxBatch(1, []*protos.Transaction{tx}, nil, nil), "Error committing tx batch")
+
+	state, _ := ledger1.GetState("chaincode1", "key1", true)
+	testutil.AssertEquals(t, state, []byte("value1"))
+	testutil.AssertEquals(t, ledger1.GetBlockchainSize(), uint64(1))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode1", "key1", true))
+	testutil.AssertEquals(t, defaultLedger.GetBlockchainSize(), uint64(0))
+
+	_, err = GetLedgerByID("ledger/1")
+	testutil.AssertError(t, err, "Expected error for invalid ledger ID")
+	_, err = GetLedgerByID("ledger2")
+	testutil.AssertError(t, err, "Expected error for undeclared ledger ID")
+}
+
+func TestLedgerRehashState(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+	tx, _ := buildTestTx(t)
+	l.BeginTxBatch(0)
+	l.TxBegin(tx.Uuid)
+	l.SetState("chaincode1", "key1", []byte("value1"))
+	l.SetState("chaincode2", "key2", []byte("value2"))
+	l.TxFinished(tx.Uuid, true)
+	l.CommitTxBatch(0, []*protos.Transaction{tx}, nil, nil)
+
+	scratchDir := filepath.Join(viper.GetString("peer.fileSystemPath"), "rehash")
+	_, err := l.RehashState(101, 1, scratchDir)
+	testutil.AssertError(t, err, "Expected error for invalid bucket tree configuration")
+
+	stateHash, err := l.RehashState(101, 3, scratchDir)
+	testutil.AssertNoError(t, err, "Error rehashing state")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetTempStateHash(), stateHash)
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode2", "key2", true), []byte("value2"))
+}
+
+func TestLedgerPrivateState(t *testing.T) {
+	commitPrivateState := func(member bool) (*ledgerTestWrapper, []byte) {
+		ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+		l := ledgerTestWrapper.ledger
+		l.BeginTxBatch(0)
+		l.TxBegin("txUuid1")
+		testutil.AssertNoError(t, l.SetPrivateState("chaincode1", "collection1", "key1", []byte("value1"), member), "Error setting private state")
+		testutil.AssertNoError(t, l.SetPrivateState("chaincode1", "collection1", "key2", []byte("value2"), member), "Error setting private state")
+		l.TxFinished("txUuid1", true)
+		// the private changes of failed txs are discarded
+		l.TxBegin("txUuid2")
+		l.DeletePrivateState("chaincode1", "collection1", "key2", member)
+		l.TxFinished("txUuid2", false)
+		testutil.AssertNoError(t, l.CommitTxBatch(0, []*protos.Transaction{}, nil, nil), "Error committing private state")
+		return ledgerTestWrapper, ledgerTestWrapper.GetTempStateHash()
+	}
+
+	ledgerTestWrapper, memberStateHash := commitPrivateState(true)
+	l := ledgerTestWrapper.ledger
+	value, _ := l.GetPrivateState("chaincode1", "collection1", "key1", true)
+	testutil.AssertEquals(t, value, []byte("value1"))
+	value, _ = l.GetPrivateState("chaincode1", "collection1", "key2", true)
+	testutil.AssertEquals(t, value, []byte("value2"))
+	hash, _ := l.GetPrivateStateHash("chaincode1", "collection1", "key1", true)
+	testutil.AssertEquals(t, hash, util.ComputeCryptoHash([]byte("value1")))
+	// the private keys are not in the namespace of the chaincode
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode1", "key1", true))
+
+	l.BeginTxBatch(1)
+	l.TxBegin("txUuid3")
+	l.DeletePrivateState("chaincode1", "collection1", "key2", true)
+	l.TxFinished("txUuid3", true)
+	testutil.AssertNoError(t, l.CommitTxBatch(1, []*protos.Transaction{}, nil, nil), "Error committing private state")
+	value, _ = l.GetPrivateState("chaincode1", "collection1", "key2", true)
+	testutil.AssertNil(t, value)
+	hash, _ = l.GetPrivateStateHash("chaincode1", "collection1", "key2", true)
+	testutil.AssertNil(t, hash)
+
+	// peers which are not members of the collection compute the same state hash
+	ledgerTestWrapper, stateHash := commitPrivateState(false)
+	testutil.AssertEquals(t, stateHash, memberStateHash)
+	value, _ = ledgerTestWrapper.ledger.GetPrivateState("chaincode1", "collection1", "key1", true)
+	testutil.AssertNil(t, value)
+}
+
+func TestLedgerListTransactions(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+
+	// block i holds an invoke of chaincode1 at time 100+10*i, and a deploy of
+	// chaincode2 at time 105+10*i
+	var invokes, deploys []string
+	for i := 0; i < 3; i++ {
+		invoke, err := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode1"}, util.GenerateUUID(), "invoke", nil)
+		testutil.AssertNoError(t, err, "Error building transaction")
+		invoke.Type = protos.Transaction_CHAINCODE_INVOKE
+		invoke.Timestamp = &google_protobuf.Timestamp{Seconds: int64(100 + 10*i)}
+		deploy, err := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode2"}, util.GenerateUUID(), "init", nil)
+		testutil.AssertNoError(t, err, "Error building transaction")
+		deploy.Type = protos.Transaction_CHAINCODE_DEPLOY
+		deploy.Timestamp = &google_protobuf.Timestamp{Seconds: int64(105 + 10*i)}
+		l.BeginTxBatch(i)
+		l.CommitTxBatch(i, []*protos.Transaction{invoke, deploy}, nil, nil)
+		invokes = append(invokes, invoke.Uuid)
+		deploys = append(deploys, deploy.Uuid)
+	}
+
+	list := func(filter *TxFilter, offset uint64, limit uint64) []string {
+		indexed, err := l.ListTransactions(filter, offset, limit)
+		testutil.AssertNoError(t, err, "Error listing transactions")
+		var uuids []string
+		for _, indexedTx := range indexed {
+			uuids = append(uuids, indexedTx.Transaction.Uuid)
+		}
+		return uuids
+	}
+	testutil.AssertEquals(t, list(&TxFilter{ChaincodeName: "chaincode1"}, 0, 0), invokes)
+	testutil.AssertEquals(t, list(&TxFilter{ChaincodeName: "chaincode1"}, 1, 1), invokes[1:2])
+	testutil.AssertEquals(t, list(&TxFilter{Type: protos.Transaction_CHAINCODE_DEPLOY}, 0, 2), deploys[:2])
+	testutil.AssertEquals(t, list(&TxFilter{Since: time.Unix(105, 0), Until: time.Unix(120, 0)}, 0, 0), []string{deploys[0], invokes[1], deploys[1]})
+	testutil.AssertEquals(t, list(&TxFilter{ChaincodeName: "chaincode2", Since: time.Unix(110, 0)}, 0, 0), deploys[1:])
+	testutil.AssertEquals(t, list(&TxFilter{}, 4, 0), []string{invokes[2], deploys[2]})
+	testutil.AssertEquals(t, len(list(&TxFilter{ChaincodeName: "chaincode3"}, 0, 0)), 0)
+
+	indexed, err := l.ListTransactions(&TxFilter{Type: protos.Transaction_CHAINCODE_INVOKE}, 2, 0)
+	testutil.AssertNoError(t, err, "Error listing transactions")
+	testutil.AssertEquals(t, len(indexed), 1)
+	testutil.AssertEquals(t, indexed[0].BlockNumber, uint64(2))
+	testutil.AssertEquals(t, indexed[0].TxIndex, uint64(0))
+}
+
+func TestLedgerTxResults(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+
+	committed, err := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode1"}, util.GenerateUUID(), "invoke", nil)
+	testutil.AssertNoError(t, err, "Error building transaction")
+	failed, err := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode1"}, util.GenerateUUID(), "invoke", nil)
+	testutil.AssertNoError(t, err, "Error building transaction")
+	pending := util.GenerateUUID()
+
+	l.TxSubmitted(committed.Uuid)
+	l.TxSubmitted(pending)
+	result, err := l.GetTxResult(committed.Uuid)
+	testutil.AssertNoError(t, err, "Error getting transaction result")
+	testutil.AssertEquals(t, result.Status, TxPending)
+
+	l.BeginTxBatch(1)
+	txResults := []*protos.TransactionResult{{Uuid: committed.Uuid}, {Uuid: failed.Uuid, ErrorCode: 1, Error: "chaincode error", Phase: protos.RejectionPhase_EXECUTION, Reason: protos.RejectionReason_CHAINCODE_ERROR}}
+	l.CommitTxBatch(1, []*protos.Transaction{committed, failed}, txResults, nil)
+
+	check := func() {
+		result, err := l.GetTxResult(committed.Uuid)
+		testutil.AssertNoError(t, err, "Error getting transaction result")
+		testutil.AssertEquals(t, result.Status, TxCommitted)
+		testutil.AssertEquals(t, result.BlockNumber, uint64(0))
+		result, err = l.GetTxResult(failed.Uuid)
+		testutil.AssertNoError(t, err, "Error getting transaction result")
+		testutil.AssertEquals(t, result.Status, TxFailed)
+		testutil.AssertEquals(t, result.ErrorCode, uint32(1))
+		testutil.AssertEquals(t, result.Error, "chaincode error")
+		testutil.AssertEquals(t, result.Phase, protos.RejectionPhase_EXECUTION)
+		testutil.AssertEquals(t, result.Reason, protos.RejectionReason_CHAINCODE_ERROR)
+	}
+	check()
+	result, err = l.GetTxResult(pending)
+	testutil.AssertNoError(t, err, "Error getting transaction result")
+	testutil.AssertEquals(t, result.Status, TxPending)
+	_, err = l.GetTxResult(util.GenerateUUID())
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+
+	// the results evicted from the cache are read from the blocks, pending
+	// transactions evicted are forgotten
+	l.txResults = newTxResultCache(1)
+	l.TxSubmitted(pending)
+	l.TxSubmitted(util.GenerateUUID())
+	check()
+	_, err = l.GetTxResult(pending)
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+}
+
+func TestLedgerTxRejected(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	l := ledgerTestWrapper.ledger
+
+	tx, err := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode1"}, util.GenerateUUID(), "invoke", nil)
+	testutil.AssertNoError(t, err, "Error building transaction")
+	l.TxSubmitted(tx.Uuid)
+	l.TxRejected(tx, protos.RejectionPhase_CONSENSUS, protos.RejectionReason_CONSENSUS_REJECTED, fmt.Errorf("request queue full"))
+
+	result, err := l.GetTxResult(tx.Uuid)
+	testutil.AssertNoError(t, err, "Error getting transaction result")
+	testutil.AssertEquals(t, result.Status, TxRejected)
+	testutil.AssertEquals(t, result.Error, "request queue full")
+	testutil.AssertEquals(t, result.Phase, protos.RejectionPhase_CONSENSUS)
+	testutil.AssertEquals(t, result.Reason, protos.RejectionReason_CONSENSUS_REJECTED)
+
+	// a transaction rejected by a failed commit may be committed later on
+	l.BeginTxBatch(1)
+	l.CommitTxBatch(1, []*protos.Transaction{tx}, []*protos.TransactionResult{{Uuid: tx.Uuid}}, nil)
+	result, err = l.GetTxResult(tx.Uuid)
+	testutil.AssertNoError(t, err, "Error getting transaction result")
+	testutil.AssertEquals(t, result.Status, TxCommitted)
+}
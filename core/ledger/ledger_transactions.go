@@ -0,0 +1,164 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+// TxFilter selects the transactions listed by ListTransactions. The zero value
+// of a field matches any transaction.
+type TxFilter struct {
+	ChaincodeName string
+	Type          protos.Transaction_Type // UNDEFINED for any type
+	Since         time.Time               // inclusive
+	Until         time.Time               // exclusive
+}
+
+// IndexedTransaction is a transaction listed by ListTransactions, along with
+// its position in the blockchain
+type IndexedTransaction struct {
+	BlockNumber uint64
+	TxIndex     uint64
+	Transaction *protos.Transaction
+}
+
+func (filter *TxFilter) matches(tx *protos.Transaction) bool {
+	if filter.ChaincodeName != "" && getTxChaincodeName(tx) != filter.ChaincodeName {
+		return false
+	}
+	if filter.Type != protos.Transaction_UNDEFINED && tx.Type != filter.Type {
+		return false
+	}
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		seconds, nanos := getTxTimestamp(tx)
+		timestamp := time.Unix(seconds, int64(nanos))
+		if !filter.Since.IsZero() && timestamp.Before(filter.Since) {
+			return false
+		}
+		if !filter.Until.IsZero() && !timestamp.Before(filter.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListTransactions returns the transactions matching filter, skipping the
+// first offset ones and returning at most limit, or all of them if limit is
+// 0. The transactions are looked up through the index of their chaincode
+// name, else of their type, else of their timestamp, and listed in the order
+// of that index: the order of the blockchain, or the time order when only a
+// time range is given. Without a filter, the blockchain is walked. Blocks
+// committed by a peer predating these indexes are indexed by running
+// 'peer ledger repair'.
+func (ledger *Ledger) ListTransactions(filter *TxFilter, offset uint64, limit uint64) ([]*IndexedTransaction, error) {
+	var prefix, start, end []byte
+	switch {
+	case filter.ChaincodeName != "":
+		prefix = encodeChaincodeTxKeyPrefix(filter.ChaincodeName)
+	case filter.Type != protos.Transaction_UNDEFINED:
+		prefix = encodeTypeTxKeyPrefix(filter.Type)
+	case !filter.Since.IsZero() || !filter.Until.IsZero():
+		prefix = []byte{prefixTimeTxKey}
+		if !filter.Since.IsZero() {
+			start = encodeTimeTxKeyPrefix(filter.Since.Unix(), int32(filter.Since.Nanosecond()))
+		}
+		if !filter.Until.IsZero() {
+			end = encodeTimeTxKeyPrefix(filter.Until.Unix(), int32(filter.Until.Nanosecond()))
+		}
+	default:
+		return ledger.walkTransactions(filter, offset, limit)
+	}
+	if start == nil {
+		start = prefix
+	}
+
+	var (
+		transactions []*IndexedTransaction
+		skipped      uint64
+		block        *protos.Block
+		blockNumber  uint64
+	)
+	itr := ledger.db.GetIterator(ledger.db.IndexesCF)
+	defer itr.Close()
+	for itr.Seek(start); itr.ValidForPrefix(prefix); itr.Next() {
+		if end != nil && bytes.Compare(itr.Key(), end) >= 0 {
+			break
+		}
+		number, txIndex, err := decodeBlockNumTxIndex(itr.Value())
+		if err != nil {
+			return nil, err
+		}
+		if block == nil || number != blockNumber {
+			if block, err = ledger.blockchain.getBlock(number); err != nil {
+				return nil, err
+			}
+			blockNumber = number
+		}
+		if block == nil || txIndex >= uint64(len(block.Transactions)) {
+			indexLogger.Warningf("Index of transactions points to missing transaction %d of block %d", txIndex, number)
+			continue
+		}
+		tx := block.Transactions[txIndex]
+		if !filter.matches(tx) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		transactions = append(transactions, &IndexedTransaction{BlockNumber: number, TxIndex: txIndex, Transaction: tx})
+		if limit > 0 && uint64(len(transactions)) >= limit {
+			break
+		}
+	}
+	return transactions, nil
+}
+
+// walkTransactions lists the transactions matching filter by walking the
+// blockchain
+func (ledger *Ledger) walkTransactions(filter *TxFilter, offset uint64, limit uint64) ([]*IndexedTransaction, error) {
+	var transactions []*IndexedTransaction
+	var skipped uint64
+	size := ledger.blockchain.getSize()
+	for number := uint64(0); number < size; number++ {
+		block, err := ledger.blockchain.getBlock(number)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			continue
+		}
+		for txIndex, tx := range block.Transactions {
+			if !filter.matches(tx) {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			transactions = append(transactions, &IndexedTransaction{BlockNumber: number, TxIndex: uint64(txIndex), Transaction: tx})
+			if limit > 0 && uint64(len(transactions)) >= limit {
+				return transactions, nil
+			}
+		}
+	}
+	return transactions, nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/events/producer"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// TxStatus is the status of a transaction known to the peer
+type TxStatus int
+
+const (
+	// TxPending is the status of a transaction submitted through the peer and
+	// not committed yet
+	TxPending TxStatus = iota
+	// TxCommitted is the status of a transaction committed successfully
+	TxCommitted
+	// TxFailed is the status of a transaction committed in a block with an
+	// error, its changes being discarded
+	TxFailed
+	// TxRejected is the status of a transaction rejected before being
+	// committed, which is in no block
+	TxRejected
+)
+
+var txStatusNames = map[TxStatus]string{
+	TxPending:   "PENDING",
+	TxCommitted: "COMMITTED",
+	TxFailed:    "FAILED",
+	TxRejected:  "REJECTED",
+}
+
+func (s TxStatus) String() string {
+	return txStatusNames[s]
+}
+
+// TxResult is the outcome of a transaction. BlockNumber is only set once the
+// transaction is committed, ErrorCode, Error, Phase and Reason once it failed
+// or was rejected.
+type TxResult struct {
+	UUID        string
+	Status      TxStatus
+	BlockNumber uint64
+	ErrorCode   uint32
+	Error       string
+	Phase       protos.RejectionPhase
+	Reason      protos.RejectionReason
+	Updated     time.Time
+}
+
+// defaultTxResultsCacheSize bounds the results cached if
+// ledger.txResults.cacheSize is not set
+const defaultTxResultsCacheSize = 10000
+
+// txResultCache caches the results of the latest transactions, evicting the
+// least recently updated once full
+type txResultCache struct {
+	sync.Mutex
+	capacity int
+	order    *list.List // of *TxResult, least recently updated first
+	results  map[string]*list.Element
+}
+
+func newTxResultCache(capacity int) *txResultCache {
+	if capacity <= 0 {
+		capacity = defaultTxResultsCacheSize
+	}
+	return &txResultCache{capacity: capacity, order: list.New(), results: make(map[string]*list.Element)}
+}
+
+// newLedgerTxResultCache returns the cache of the size configured in
+// ledger.txResults.cacheSize
+func newLedgerTxResultCache() *txResultCache {
+	return newTxResultCache(viper.GetInt("ledger.txResults.cacheSize"))
+}
+
+// put records result, replacing the previous result of the transaction
+func (c *txResultCache) put(result *TxResult) {
+	c.Lock()
+	defer c.Unlock()
+	c.putLocked(result)
+}
+
+func (c *txResultCache) putLocked(result *TxResult) {
+	if elem, ok := c.results[result.UUID]; ok {
+		c.order.Remove(elem)
+	}
+	c.results[result.UUID] = c.order.PushBack(result)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.results, oldest.Value.(*TxResult).UUID)
+	}
+}
+
+// submitted records the transaction uuid as pending, unless it is known
+func (c *txResultCache) submitted(uuid string) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.results[uuid]; ok {
+		return
+	}
+	c.putLocked(&TxResult{UUID: uuid, Status: TxPending, Updated: time.Now()})
+}
+
+// committed records the results of the transactions of block blockNumber
+func (c *txResultCache) committed(block *protos.Block, blockNumber uint64) {
+	failures := make(map[string]*protos.TransactionResult)
+	if block.NonHashData != nil {
+		for _, txResult := range block.NonHashData.TransactionResults {
+			if txResult != nil && txResult.ErrorCode != 0 {
+				failures[txResult.Uuid] = txResult
+			}
+		}
+	}
+	now := time.Now()
+	c.Lock()
+	defer c.Unlock()
+	for _, tx := range block.Transactions {
+		result := &TxResult{UUID: tx.Uuid, Status: TxCommitted, BlockNumber: blockNumber, Updated: now}
+		if failure, ok := failures[tx.Uuid]; ok {
+			result.Status, result.ErrorCode, result.Error = TxFailed, failure.ErrorCode, failure.Error
+			result.Phase, result.Reason = failure.Phase, failure.Reason
+		}
+		c.putLocked(result)
+	}
+}
+
+func (c *txResultCache) get(uuid string) (*TxResult, bool) {
+	c.Lock()
+	defer c.Unlock()
+	elem, ok := c.results[uuid]
+	if !ok {
+		return nil, false
+	}
+	result := *elem.Value.(*TxResult)
+	return &result, true
+}
+
+// TxSubmitted records that the transaction uuid was submitted through the
+// peer, for GetTxResult to report it pending until it is committed
+func (ledger *Ledger) TxSubmitted(uuid string) {
+	ledger.txResults.submitted(uuid)
+}
+
+// TxRejected records that the transaction tx was rejected in phase for
+// reason before being committed, and sends a rejection event for it. The
+// result is replaced if the transaction is committed later on, e.g. by a
+// state transfer after a failed commit.
+func (ledger *Ledger) TxRejected(tx *protos.Transaction, phase protos.RejectionPhase, reason protos.RejectionReason, err error) {
+	ledger.txResults.put(&TxResult{UUID: tx.Uuid, Status: TxRejected, ErrorCode: 1, Error: err.Error(), Phase: phase, Reason: reason, Updated: time.Now()})
+	if err := producer.Send(producer.CreateTxRejectionEvent(tx, phase, reason, err.Error())); err != nil {
+		ledgerLogger.Errorf("Error sending rejection event of transaction %s: %s", tx.Uuid, err)
+	}
+}
+
+// GetTxResult returns the result of the transaction uuid. Results of the
+// latest transactions are cached when committed, up to
+// ledger.txResults.cacheSize of them, and those of older transactions are
+// read from their block. Returns ErrResourceNotFound if the transaction was
+// neither committed nor submitted through the peer.
+func (ledger *Ledger) GetTxResult(uuid string) (*TxResult, error) {
+	if result, ok := ledger.txResults.get(uuid); ok {
+		return result, nil
+	}
+	blockNumber, _, err := ledger.blockchain.indexer.fetchTransactionIndexByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ledger.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	result := &TxResult{UUID: uuid, Status: TxCommitted, BlockNumber: blockNumber}
+	if block.NonHashData != nil {
+		for _, txResult := range block.NonHashData.TransactionResults {
+			if txResult != nil && txResult.Uuid == uuid && txResult.ErrorCode != 0 {
+				result.Status, result.ErrorCode, result.Error = TxFailed, txResult.ErrorCode, txResult.Error
+				result.Phase, result.Reason = txResult.Phase, txResult.Reason
+			}
+		}
+	}
+	if block.NonHashData != nil && block.NonHashData.LocalLedgerCommitTimestamp != nil {
+		result.Updated = time.Unix(block.NonHashData.LocalLedgerCommitTimestamp.Seconds, int64(block.NonHashData.LocalLedgerCommitTimestamp.Nanos))
+	}
+	return result, nil
+}
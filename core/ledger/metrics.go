@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/metrics"
+	"github.com/hyperledger/fabric/protos"
+)
+
+var (
+	blocksCommitted = metrics.NewCounter("fabric_ledger_blocks_committed_total",
+		"Number of blocks committed, by ledger", "ledger")
+	transactionsCommitted = metrics.NewCounter("fabric_ledger_transactions_committed_total",
+		"Number of transactions committed, by ledger and result", "ledger", "result")
+	blockCommitSeconds = metrics.NewHistogram("fabric_ledger_block_commit_seconds",
+		"Time taken to commit a block, from CommitTxBatch to its persistence, by ledger", nil, "ledger")
+	ledgerHeight = metrics.NewGauge("fabric_ledger_height",
+		"Number of blocks in the block chain, by ledger", "ledger")
+)
+
+func init() {
+	metrics.MustRegister(blocksCommitted, transactionsCommitted, blockCommitSeconds, ledgerHeight)
+}
+
+// recordCommit updates the metrics of the ledger once block is committed
+func (ledger *Ledger) recordCommit(block *protos.Block, start time.Time) {
+	ledgerID := ledger.db.LedgerID()
+	failed := 0
+	if block.NonHashData != nil {
+		for _, result := range block.NonHashData.TransactionResults {
+			if result.ErrorCode != 0 {
+				failed++
+			}
+		}
+	}
+	blocksCommitted.Inc(ledgerID)
+	transactionsCommitted.Add(float64(len(block.Transactions)-failed), ledgerID, "success")
+	transactionsCommitted.Add(float64(failed), ledgerID, "failure")
+	blockCommitSeconds.Observe(time.Since(start).Seconds(), ledgerID)
+	ledgerHeight.Set(float64(ledger.blockchain.getSize()), ledgerID)
+}
@@ -28,7 +28,6 @@ import (
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 func BenchmarkDB(b *testing.B) {
@@ -203,14 +202,14 @@ func BenchmarkLedgerRandomTransactions(b *testing.B) {
 func populateDB(tb testing.TB, kvSize int, totalKeys int, keyPrefix string) {
 	dbWrapper := db.NewTestDBWrapper()
 	dbWrapper.CreateFreshDB(tb)
-	batch := gorocksdb.NewWriteBatch()
+	batch := db.GetDBHandle().NewWriteBatch()
 	for i := 0; i < totalKeys; i++ {
 		key := []byte(keyPrefix + strconv.Itoa(i))
 		value := testutil.ConstructRandomBytes(tb, kvSize-len(key))
-		batch.Put(key, value)
+		batch.PutCF(db.GetDBHandle().StateCF, key, value)
 		if i%1000 == 0 {
 			dbWrapper.WriteToDB(tb, batch)
-			batch = gorocksdb.NewWriteBatch()
+			batch = db.GetDBHandle().NewWriteBatch()
 		}
 	}
 	dbWrapper.CloseDB(tb)
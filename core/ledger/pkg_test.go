@@ -20,11 +20,11 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 	"golang.org/x/net/context"
 )
 
@@ -42,13 +42,13 @@ type blockchainTestWrapper struct {
 }
 
 func newTestBlockchainWrapper(t *testing.T) *blockchainTestWrapper {
-	blockchain, err := newBlockchain()
+	blockchain, err := newBlockchain(db.GetDBHandle())
 	testutil.AssertNoError(t, err, "Error while getting handle to chain")
 	return &blockchainTestWrapper{t, blockchain}
 }
 
 func (testWrapper *blockchainTestWrapper) addNewBlock(block *protos.Block, stateHash []byte) uint64 {
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.GetDBHandle().NewWriteBatch()
 	defer writeBatch.Destroy()
 	newBlockNumber, err := testWrapper.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, writeBatch)
 	testutil.AssertNoError(testWrapper.t, err, "Error while adding a new block")
@@ -58,7 +58,7 @@ func (testWrapper *blockchainTestWrapper) addNewBlock(block *protos.Block, state
 }
 
 func (testWrapper *blockchainTestWrapper) fetchBlockchainSizeFromDB() uint64 {
-	size, err := fetchBlockchainSizeFromDB()
+	size, err := fetchBlockchainSizeFromDB(db.GetDBHandle())
 	testutil.AssertNoError(testWrapper.t, err, "Error while fetching blockchain size from db")
 	return size
 }
@@ -98,6 +98,13 @@ func (testWrapper *blockchainTestWrapper) getTransactionByUUID(txUUID string) *p
 	testutil.AssertNoError(testWrapper.t, err, "Error while getting tx from blockchain")
 	return tx
 }
+
+func (testWrapper *blockchainTestWrapper) getBlockByTxUUID(txUUID string) *protos.Block {
+	block, err := testWrapper.blockchain.getBlockByTxUUID(txUUID)
+	testutil.AssertNoError(testWrapper.t, err, "Error while getting block by tx uuid from blockchain")
+	return block
+}
+
 func (testWrapper *blockchainTestWrapper) populateBlockChainWithSampleData() (blocks []*protos.Block, hashes [][]byte, err error) {
 	var allBlocks []*protos.Block
 	var allHashes [][]byte
@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/util"
+)
+
+// A private state collection of a chaincode holds keys whose values are only
+// stored by the peers members of the collection. The world state holds the
+// hash of the values instead, in a namespace of its own, so that every peer
+// computes the same state hash whether it is a member or not.
+
+// PrivateStateNamespace returns the namespace of a private state collection
+// of chaincodeID, both in the world state and in the private state
+func PrivateStateNamespace(chaincodeID string, collection string) string {
+	return chaincodeID + "~" + collection
+}
+
+// GetPrivateState returns the value of key in the private state collection of
+// chaincodeID, or nil if the key does not exist or the peer is not a member of
+// the collection. If committed is false, this first looks in memory and if
+// missing, pulls from db. If committed is true, this pulls from the db only.
+func (ledger *Ledger) GetPrivateState(chaincodeID string, collection string, key string, committed bool) ([]byte, error) {
+	return ledger.state.GetPrivate(PrivateStateNamespace(chaincodeID, collection), key, committed)
+}
+
+// GetPrivateStateHash returns the hash of the value of key in the private
+// state collection of chaincodeID, as kept in the world state
+func (ledger *Ledger) GetPrivateStateHash(chaincodeID string, collection string, key string, committed bool) ([]byte, error) {
+	return ledger.state.Get(PrivateStateNamespace(chaincodeID, collection), key, committed)
+}
+
+// SetPrivateState sets key to value in the private state collection of
+// chaincodeID. The hash of the value is set in the world state and, if member
+// is true, the value is kept in the private state. Does not immediately write
+// to DB.
+func (ledger *Ledger) SetPrivateState(chaincodeID string, collection string, key string, value []byte, member bool) error {
+	if collection == "" || key == "" || value == nil {
+		return newLedgerError(ErrorTypeInvalidArgument,
+			fmt.Sprintf("An empty string collection or key or a nil value is not supported. Method invoked with collection='%s', key='%s'", collection, key))
+	}
+	namespace := PrivateStateNamespace(chaincodeID, collection)
+	if err := ledger.state.Set(namespace, key, util.ComputeCryptoHash(value)); err != nil {
+		return err
+	}
+	if member {
+		ledger.state.SetPrivate(namespace, key, value)
+	}
+	return nil
+}
+
+// DeletePrivateState tracks the deletion of key in the private state
+// collection of chaincodeID, both of the hash of its value in the world state
+// and, if member is true, of its value in the private state. Does not
+// immediately write to DB.
+func (ledger *Ledger) DeletePrivateState(chaincodeID string, collection string, key string, member bool) error {
+	namespace := PrivateStateNamespace(chaincodeID, collection)
+	if err := ledger.state.Delete(namespace, key); err != nil {
+		return err
+	}
+	if member {
+		ledger.state.DeletePrivate(namespace, key)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/util"
+)
+
+var stateTransferProgressKey = []byte("ledger.stateTransferProgress")
+
+// StateTransferProgress records how far a state transfer got, so that a peer
+// restarted during state transfer can continue instead of starting over
+type StateTransferProgress struct {
+	// StateBlockNumber is the block number the committed state corresponds to,
+	// or the block number of the snapshot being installed
+	StateBlockNumber uint64
+	// SnapshotInProgress is set while a state snapshot is being installed
+	SnapshotInProgress bool
+	// SnapshotSequence is the number of snapshot deltas committed so far
+	SnapshotSequence uint64
+}
+
+// CommitStateDeltaWithProgress commits the state delta passed to
+// ledger.ApplyStateDelta to the DB, atomically with the state transfer progress
+func (ledger *Ledger) CommitStateDeltaWithProgress(id interface{}, progress *StateTransferProgress) error {
+	err := ledger.checkValidIDCommitORRollback(id)
+	if err != nil {
+		return err
+	}
+	defer ledger.resetForNextTxGroup(true)
+
+	progressBytes, err := util.Encode(util.JSONCodec, progress)
+	if err != nil {
+		return fmt.Errorf("Error encoding state transfer progress: %s", err)
+	}
+	writeBatch := ledger.db.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.PutCF(ledger.db.PersistCF, stateTransferProgressKey, progressBytes)
+	return ledger.state.CommitStateDeltaWithBatch(writeBatch)
+}
+
+// GetStateTransferProgress returns the progress recorded by the last call to
+// CommitStateDeltaWithProgress, or nil if no state transfer is in progress
+func (ledger *Ledger) GetStateTransferProgress() (*StateTransferProgress, error) {
+	progressBytes, err := ledger.db.Get(ledger.db.PersistCF, stateTransferProgressKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading state transfer progress: %s", err)
+	}
+	if progressBytes == nil {
+		return nil, nil
+	}
+	progress := &StateTransferProgress{}
+	if err := util.Decode(progressBytes, progress, nil); err != nil {
+		return nil, fmt.Errorf("Error decoding state transfer progress: %s", err)
+	}
+	return progress, nil
+}
+
+// ClearStateTransferProgress removes the recorded progress once state transfer completed
+func (ledger *Ledger) ClearStateTransferProgress() error {
+	return ledger.db.Delete(ledger.db.PersistCF, stateTransferProgressKey)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestStateTransferProgress(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	progress, err := ledger.GetStateTransferProgress()
+	testutil.AssertNoError(t, err, "Error getting state transfer progress")
+	testutil.AssertNil(t, progress)
+
+	// Two deltas applied with the same id are committed together with the progress
+	delta1 := statemgmt.NewStateDelta()
+	delta1.Set("chaincode1", "key1", []byte("value1"), nil)
+	delta2 := statemgmt.NewStateDelta()
+	delta2.Set("chaincode2", "key2", []byte("value2"), nil)
+	ledgerTestWrapper.ApplyStateDelta(1, delta1)
+	ledgerTestWrapper.ApplyStateDelta(1, delta2)
+	expected := &StateTransferProgress{StateBlockNumber: 5, SnapshotInProgress: true, SnapshotSequence: 2}
+	err = ledger.CommitStateDeltaWithProgress(1, expected)
+	testutil.AssertNoError(t, err, "Error committing state delta with progress")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode2", "key2", true), []byte("value2"))
+
+	progress, err = ledger.GetStateTransferProgress()
+	testutil.AssertNoError(t, err, "Error getting state transfer progress")
+	testutil.AssertEquals(t, progress, expected)
+
+	// A different id must not join the applied delta
+	ledgerTestWrapper.ApplyStateDelta(2, delta1)
+	err = ledger.ApplyStateDelta(3, delta2)
+	testutil.AssertError(t, err, "Expected error applying delta with a different id")
+	err = ledger.CommitStateDeltaWithProgress(3, expected)
+	testutil.AssertError(t, err, "Expected error committing delta with a different id")
+	ledger.RollbackStateDelta(2)
+
+	err = ledger.ClearStateTransferProgress()
+	testutil.AssertNoError(t, err, "Error clearing state transfer progress")
+	progress, err = ledger.GetStateTransferProgress()
+	testutil.AssertNoError(t, err, "Error getting state transfer progress")
+	testutil.AssertNil(t, progress)
+}
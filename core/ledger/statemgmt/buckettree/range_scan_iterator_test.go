@@ -147,3 +147,42 @@ func TestRangeScanIteratorEmptyArray(t *testing.T) {
 	testutil.AssertEquals(t, results["key3"], []byte{})
 	rangeScanItr.Close()
 }
+
+func TestRangeScanIteratorCompositeKeys(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapper(t)
+	stateDelta := statemgmt.NewStateDelta()
+
+	// insert keys in the layout created by the shim's CreateCompositeKey
+	stateDelta.Set("chaincodeID1", "\x00owner\x00alice\x00asset1\x00", []byte("value1"), nil)
+	stateDelta.Set("chaincodeID1", "\x00owner\x00alice\x00asset2\x00", []byte("value2"), nil)
+	stateDelta.Set("chaincodeID1", "\x00owner\x00alice\x00asset3\x00", []byte("value3"), nil)
+	stateDelta.Set("chaincodeID1", "\x00owner\x00bob\x00asset4\x00", []byte("value4"), nil)
+	stateDelta.Set("chaincodeID1", "\x00owner\x00alicia\x00asset5\x00", []byte("value5"), nil)
+	stateDelta.Set("chaincodeID1", "key1", []byte("value6"), nil)
+	stateDelta.Set("chaincodeID2", "\x00owner\x00alice\x00asset6\x00", []byte("value7"), nil)
+
+	stateImplTestWrapper.prepareWorkingSet(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	// composite keys are hashed to buckets like any other key, so the scan visits all the buckets
+	rangeScanItr := stateImplTestWrapper.getRangeScanIterator("chaincodeID1", "\x00owner\x00alice\x00", "\x00owner\x00alice\x00\U0010FFFF")
+	var results = make(map[string][]byte)
+	for rangeScanItr.Next() {
+		key, value := rangeScanItr.GetKeyValue()
+		results[key] = value
+	}
+	rangeScanItr.Close()
+	testutil.AssertEquals(t, len(results), 3)
+	testutil.AssertEquals(t, results["\x00owner\x00alice\x00asset2\x00"], []byte("value2"))
+
+	rangeScanItr = stateImplTestWrapper.getRangeScanIterator("chaincodeID1", "\x00owner\x00", "\x00owner\x00\U0010FFFF")
+	results = make(map[string][]byte)
+	for rangeScanItr.Next() {
+		key, value := rangeScanItr.GetKeyValue()
+		results[key] = value
+	}
+	rangeScanItr.Close()
+	testutil.AssertEquals(t, len(results), 5)
+	testutil.AssertEquals(t, results["\x00owner\x00bob\x00asset4\x00"], []byte("value4"))
+}
@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// rehashBatchSize is the number of key-values written to the bucket tree
+// of the new configuration at a time
+const rehashBatchSize = 1000
+
+// Rehash rebuilds the bucket tree kept in the DB, which must have been built
+// with the configuration the StateImpl was initialized with, for the given
+// configuration, and returns the new crypto-hash of the state. The StateImpl
+// is re-initialized with the given configuration.
+//
+// Data nodes are keyed by their bucket number, so the key-values are first
+// copied to a scratch store created in scratchDir, which is removed once the
+// tree is rebuilt. Rehash must only be used while the peer is stopped.
+func (stateImpl *StateImpl) Rehash(configs map[string]interface{}, scratchDir string) ([]byte, error) {
+	if stateImpl.dataNodesDelta != nil {
+		return nil, fmt.Errorf("Cannot rehash the bucket tree while a working set is prepared")
+	}
+	scratch, cfs, err := db.OpenDriver(scratchDir, []string{"keyValues"})
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratchDir)
+	defer scratch.Close()
+
+	numKeys, err := stateImpl.copyKeyValuesTo(scratch, cfs[0])
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Copied %d key-values of the bucket tree to the scratch store at [%s]", numKeys, scratchDir)
+
+	if err = stateImpl.db.DeleteState(); err != nil {
+		return nil, err
+	}
+	stateImpl.persistedStateHash = nil
+	stateImpl.lastComputedCryptoHash = nil
+	if err = stateImpl.Initialize(configs); err != nil {
+		return nil, err
+	}
+
+	itr := scratch.NewIterator(cfs[0])
+	defer itr.Close()
+	stateDelta := statemgmt.NewStateDelta()
+	numInBatch := 0
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		chaincodeID, key := statemgmt.DecodeCompositeKey(statemgmt.Copy(itr.Key()))
+		stateDelta.Set(chaincodeID, key, statemgmt.Copy(itr.Value()), nil)
+		numInBatch++
+		if numInBatch == rehashBatchSize {
+			if err = stateImpl.persistRehashBatch(stateDelta); err != nil {
+				return nil, err
+			}
+			stateDelta = statemgmt.NewStateDelta()
+			numInBatch = 0
+		}
+	}
+	if err = stateImpl.persistRehashBatch(stateDelta); err != nil {
+		return nil, err
+	}
+	logger.Infof("Rebuilt the bucket tree for configurations %#v", configs)
+	return stateImpl.lastComputedCryptoHash, nil
+}
+
+// copyKeyValuesTo writes all the key-values of the bucket tree, keyed by
+// their composite key, to the column family of the scratch store
+func (stateImpl *StateImpl) copyKeyValuesTo(scratch db.Driver, cf db.ColumnFamily) (int, error) {
+	snapshot := stateImpl.db.GetSnapshot()
+	defer snapshot.Release()
+	itr, err := newStateSnapshotIterator(stateImpl.db, snapshot)
+	if err != nil {
+		return 0, err
+	}
+	defer itr.Close()
+
+	numKeys := 0
+	writeBatch := scratch.NewWriteBatch()
+	defer func() { writeBatch.Destroy() }()
+	for itr.Next() {
+		compositeKey, value := itr.GetRawKeyValue()
+		writeBatch.PutCF(cf, compositeKey, value)
+		numKeys++
+		if numKeys%rehashBatchSize == 0 {
+			if err := scratch.Write(writeBatch); err != nil {
+				return 0, fmt.Errorf("Error writing to the scratch store: %s", err)
+			}
+			writeBatch.Destroy()
+			writeBatch = scratch.NewWriteBatch()
+		}
+	}
+	if err := scratch.Write(writeBatch); err != nil {
+		return 0, fmt.Errorf("Error writing to the scratch store: %s", err)
+	}
+	return numKeys, nil
+}
+
+func (stateImpl *StateImpl) persistRehashBatch(stateDelta *statemgmt.StateDelta) error {
+	if stateDelta.IsEmpty() {
+		return nil
+	}
+	stateImpl.PrepareWorkingSet(stateDelta)
+	if _, err := stateImpl.ComputeCryptoHash(); err != nil {
+		stateImpl.ClearWorkingSet(false)
+		return err
+	}
+	writeBatch := stateImpl.db.NewWriteBatch()
+	defer writeBatch.Destroy()
+	if err := stateImpl.AddChangesForPersistence(writeBatch); err != nil {
+		stateImpl.ClearWorkingSet(false)
+		return err
+	}
+	if err := stateImpl.db.Write(writeBatch); err != nil {
+		stateImpl.ClearWorkingSet(false)
+		return fmt.Errorf("Error writing the rebuilt bucket tree: %s", err)
+	}
+	stateImpl.ClearWorkingSet(true)
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestStateImpl_Rehash(t *testing.T) {
+	// more keys than are written in one batch
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 2*rehashBatchSize+10; i++ {
+		stateDelta.Set(fmt.Sprintf("chaincodeID%d", i%3), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	newConfigs := map[string]interface{}{ConfigNumBuckets: 100, ConfigMaxGroupingAtEachLevel: 4}
+
+	// expected hash of the same key-values in a tree built with the new configuration
+	testDBWrapper.CreateFreshDB(t)
+	expectedStateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 100, 4)
+	expectedHash := expectedStateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 26, 3)
+	oldHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	testutil.AssertNotEquals(t, oldHash, expectedHash)
+
+	scratchDir, err := ioutil.TempDir("", "fabric-rehash-test")
+	testutil.AssertNoError(t, err, "Error creating scratch dir")
+	defer os.RemoveAll(scratchDir)
+	hash, err := stateImplTestWrapper.stateImpl.Rehash(newConfigs, scratchDir)
+	testutil.AssertNoError(t, err, "Error rehashing bucket tree")
+	testutil.AssertEquals(t, hash, expectedHash)
+	_, err = os.Stat(scratchDir)
+	testutil.AssertEquals(t, os.IsNotExist(err), true)
+
+	// the rebuilt tree is read back with the new configuration
+	stateImplTestWrapper.configMap = newConfigs
+	stateImplTestWrapper.constructNewStateImpl()
+	testutil.AssertEquals(t, stateImplTestWrapper.computeCryptoHash(), expectedHash)
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "key1"), []byte("value1"))
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID2", "key2009"), []byte("value2009"))
+
+	// further changes are applied to the rebuilt tree
+	stateDelta = statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key1", []byte("newValue1"), nil)
+	stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "key1"), []byte("newValue1"))
+}
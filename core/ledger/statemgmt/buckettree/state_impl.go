@@ -18,11 +18,12 @@ package buckettree
 
 import (
 	"bytes"
+	"fmt"
 
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var logger = logging.MustGetLogger("buckettree")
@@ -35,17 +36,18 @@ type StateImpl struct {
 	lastComputedCryptoHash []byte
 	recomputeCryptoHash    bool
 	bucketCache            *bucketCache
+	db                     *db.OpenchainDB
 }
 
-// NewStateImpl constructs a new StateImpl
-func NewStateImpl() *StateImpl {
-	return &StateImpl{}
+// NewStateImpl constructs a new StateImpl keeping the state in the given DB
+func NewStateImpl(openchainDB *db.OpenchainDB) *StateImpl {
+	return &StateImpl{db: openchainDB}
 }
 
 // Initialize - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
 	initConfig(configs)
-	rootBucketNode, err := fetchBucketNodeFromDB(constructRootBucketKey())
+	rootBucketNode, err := fetchBucketNodeFromDB(stateImpl.db, constructRootBucketKey())
 	if err != nil {
 		return err
 	}
@@ -58,7 +60,7 @@ func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
 	if !ok {
 		bucketCacheMaxSize = defaultBucketCacheMaxSize
 	}
-	stateImpl.bucketCache = newBucketCache(bucketCacheMaxSize)
+	stateImpl.bucketCache = newBucketCache(stateImpl.db, bucketCacheMaxSize)
 	stateImpl.bucketCache.loadAllBucketNodesFromDB()
 	return nil
 }
@@ -66,7 +68,7 @@ func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
 // Get - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) Get(chaincodeID string, key string) ([]byte, error) {
 	dataKey := newDataKey(chaincodeID, key)
-	dataNode, err := fetchDataNodeFromDB(dataKey)
+	dataNode, err := fetchDataNodeFromDB(stateImpl.db, dataKey)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +130,7 @@ func (stateImpl *StateImpl) processDataNodeDelta() error {
 	afftectedBuckets := stateImpl.dataNodesDelta.getAffectedBuckets()
 	for _, bucketKey := range afftectedBuckets {
 		updatedDataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(bucketKey)
-		existingDataNodes, err := fetchDataNodesFromDBFor(bucketKey)
+		existingDataNodes, err := fetchDataNodesFromDBFor(stateImpl.db, bucketKey)
 		if err != nil {
 			return err
 		}
@@ -216,7 +218,7 @@ func computeDataNodesCryptoHash(bucketKey *bucketKey, updatedNodes dataNodes, ex
 }
 
 // AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
-func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch db.WriteBatch) error {
 
 	if stateImpl.dataNodesDelta == nil {
 		return nil
@@ -233,8 +235,8 @@ func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.Write
 	return nil
 }
 
-func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB := db.GetDBHandle()
+func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch db.WriteBatch) {
+	openchainDB := stateImpl.db
 	affectedBuckets := stateImpl.dataNodesDelta.getAffectedBuckets()
 	for _, affectedBucket := range affectedBuckets {
 		dataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(affectedBucket)
@@ -250,8 +252,8 @@ func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch *gorocks
 	}
 }
 
-func (stateImpl *StateImpl) addBucketNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB := db.GetDBHandle()
+func (stateImpl *StateImpl) addBucketNodeChangesForPersistence(writeBatch db.WriteBatch) {
+	openchainDB := stateImpl.db
 	secondLastLevel := conf.getLowestLevel() - 1
 	for level := secondLastLevel; level >= 0; level-- {
 		bucketNodes := stateImpl.bucketTreeDelta.getBucketNodesAt(level)
@@ -292,11 +294,53 @@ func (stateImpl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
-	return newStateSnapshotIterator(snapshot)
+func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+	return newStateSnapshotIterator(stateImpl.db, snapshot)
 }
 
 // GetRangeScanIterator - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
-	return newRangeScanIterator(chaincodeID, startKey, endKey)
+	return newRangeScanIterator(stateImpl.db, chaincodeID, startKey, endKey)
+}
+
+// GetStateProof - method implementation for interface 'statemgmt.ProvableState'
+func (stateImpl *StateImpl) GetStateProof(chaincodeID string, key string) (*verify.StateProof, error) {
+	dataKey := newDataKey(chaincodeID, key)
+	bucketKey := dataKey.getBucketKey()
+	dataNodes, err := fetchDataNodesFromDBFor(stateImpl.db, bucketKey)
+	if err != nil {
+		return nil, err
+	}
+	proof := &verify.StateProof{Type: verify.ProofTypeBucketTree, ChaincodeID: chaincodeID, Key: key}
+	found := false
+	for _, dataNode := range dataNodes {
+		nodeChaincodeID, nodeKey := dataNode.getKeyElements()
+		if bytes.Equal(dataNode.getCompositeKey(), dataKey.compositeKey) {
+			proof.Value = dataNode.getValue()
+			found = true
+		}
+		proof.BucketEntries = append(proof.BucketEntries, &verify.KeyValue{ChaincodeID: nodeChaincodeID, Key: nodeKey, Value: dataNode.getValue()})
+	}
+	if !found {
+		return nil, nil
+	}
+
+	for childKey := bucketKey; childKey.level > 0; childKey = childKey.getParentKey() {
+		parentKey := childKey.getParentKey()
+		parentNode, err := fetchBucketNodeFromDB(stateImpl.db, parentKey)
+		if err != nil {
+			return nil, err
+		}
+		if parentNode == nil {
+			return nil, fmt.Errorf("Bucket [%s] on the path of key [%s] of chaincode [%s] is missing from the DB", parentKey, key, chaincodeID)
+		}
+		proofNode := &verify.ProofNode{ChildIndex: parentKey.getChildIndex(childKey)}
+		for i, childCryptoHash := range parentNode.childrenCryptoHash {
+			if childCryptoHash != nil && i != proofNode.ChildIndex {
+				proofNode.Siblings = append(proofNode.Siblings, &verify.ChildHash{Index: i, Hash: childCryptoHash})
+			}
+		}
+		proof.Path = append(proof.Path, proofNode)
+	}
+	return proof, nil
 }
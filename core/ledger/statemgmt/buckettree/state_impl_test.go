@@ -19,8 +19,10 @@ package buckettree
 import (
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 )
 
 func TestStateImpl_ComputeHash_AllInMemory_NoContents(t *testing.T) {
@@ -345,27 +347,27 @@ func TestStateImpl_DB_Changes(t *testing.T) {
 	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID2", "key1"), []byte("value3"))
 
 	// fetch datanode from DB
-	dataNodeFromDB, _ := fetchDataNodeFromDB(newDataKey("chaincodeID2", "key1"))
+	dataNodeFromDB, _ := fetchDataNodeFromDB(db.GetDBHandle(), newDataKey("chaincodeID2", "key1"))
 	testutil.AssertEquals(t, dataNodeFromDB, newDataNode(newDataKey("chaincodeID2", "key1"), []byte("value3")))
 
 	//fetch non-existing data node from DB
-	dataNodeFromDB, _ = fetchDataNodeFromDB(newDataKey("chaincodeID10", "key10"))
+	dataNodeFromDB, _ = fetchDataNodeFromDB(db.GetDBHandle(), newDataKey("chaincodeID10", "key10"))
 	t.Logf("isNIL...[%t]", dataNodeFromDB == nil)
 	testutil.AssertNil(t, dataNodeFromDB)
 
 	// fetch all data nodes from db that belong to bucket 1 at lowest level
-	dataNodesFromDB, _ := fetchDataNodesFromDBFor(newBucketKeyAtLowestLevel(1))
+	dataNodesFromDB, _ := fetchDataNodesFromDBFor(db.GetDBHandle(), newBucketKeyAtLowestLevel(1))
 	testutil.AssertContainsAll(t, dataNodesFromDB,
 		dataNodes{newDataNode(newDataKey("chaincodeID1", "key1"), []byte("value1")),
 			newDataNode(newDataKey("chaincodeID1", "key2"), []byte("value2"))})
 
 	// fetch all data nodes from db that belong to bucket 2 at lowest level
-	dataNodesFromDB, _ = fetchDataNodesFromDBFor(newBucketKeyAtLowestLevel(2))
+	dataNodesFromDB, _ = fetchDataNodesFromDBFor(db.GetDBHandle(), newBucketKeyAtLowestLevel(2))
 	testutil.AssertContainsAll(t, dataNodesFromDB,
 		dataNodes{newDataNode(newDataKey("chaincodeID2", "key1"), []byte("value3"))})
 
 	// fetch first bucket at second level
-	bucketNodeFromDB, _ := fetchBucketNodeFromDB(newBucketKey(2, 1))
+	bucketNodeFromDB, _ := fetchBucketNodeFromDB(db.GetDBHandle(), newBucketKey(2, 1))
 	testutil.AssertEquals(t, bucketNodeFromDB.bucketKey, newBucketKey(2, 1))
 	//check childrenCryptoHash entries in the bucket node from DB
 	testutil.AssertEquals(t, bucketNodeFromDB.childrenCryptoHash[0],
@@ -377,7 +379,7 @@ func TestStateImpl_DB_Changes(t *testing.T) {
 	testutil.AssertNil(t, bucketNodeFromDB.childrenCryptoHash[2])
 
 	// third bucket at second level should be nil
-	bucketNodeFromDB, _ = fetchBucketNodeFromDB(newBucketKey(2, 3))
+	bucketNodeFromDB, _ = fetchBucketNodeFromDB(db.GetDBHandle(), newBucketKey(2, 3))
 	testutil.AssertNil(t, bucketNodeFromDB)
 }
 
@@ -398,3 +400,43 @@ func TestStateImpl_DB_EmptyArrayValues(t *testing.T) {
 		t.Fatalf("Expected a nil. found = %#v", nilVal)
 	}
 }
+
+func TestStateImpl_GetStateProof(t *testing.T) {
+	// number of buckets at each level 26,9,3,1
+	testHasher, stateImplTestWrapper, stateDelta := createFreshDBAndInitTestStateImplWithCustomHasher(t, 26, 3)
+	testHasher.populate("chaincodeID1", "key1", 0)
+	testHasher.populate("chaincodeID2", "key2", 0)
+	testHasher.populate("chaincodeID2", "key3", 0)
+	testHasher.populate("chaincodeID4", "key4", 3)
+	testHasher.populate("chaincodeID5", "key5", 25)
+	testHasher.populate("chaincodeID1", "non-existing-key", 0)
+
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	stateDelta.Set("chaincodeID2", "key2", []byte("value2"), nil)
+	stateDelta.Set("chaincodeID2", "key3", []byte{}, nil)
+	stateDelta.Set("chaincodeID4", "key4", []byte("value4"), nil)
+	stateDelta.Set("chaincodeID5", "key5", []byte("value5"), nil)
+	rootHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	for _, kv := range [][]string{{"chaincodeID1", "key1"}, {"chaincodeID2", "key2"}, {"chaincodeID2", "key3"}, {"chaincodeID4", "key4"}, {"chaincodeID5", "key5"}} {
+		proof, err := stateImplTestWrapper.stateImpl.GetStateProof(kv[0], kv[1])
+		testutil.AssertNoError(t, err, "Error getting state proof")
+		testutil.AssertEquals(t, proof.Value, stateImplTestWrapper.get(kv[0], kv[1]))
+		testutil.AssertEquals(t, len(proof.Path), 3)
+		testutil.AssertNoError(t, verify.VerifyStateProof(proof, rootHash), "Error verifying state proof")
+	}
+
+	proof, _ := stateImplTestWrapper.stateImpl.GetStateProof("chaincodeID1", "key1")
+	proof.BucketEntries = proof.BucketEntries[:1]
+	testutil.AssertError(t, verify.VerifyStateProof(proof, rootHash), "Expected error verifying a proof with a missing bucket entry")
+
+	// the first bucket at level 1 has the first two buckets at level 2 as children
+	proof, _ = stateImplTestWrapper.stateImpl.GetStateProof("chaincodeID1", "key1")
+	proof.Path[1].ChildIndex = 2
+	testutil.AssertError(t, verify.VerifyStateProof(proof, rootHash), "Expected error verifying a proof with a wrong path")
+
+	proof, err := stateImplTestWrapper.stateImpl.GetStateProof("chaincodeID1", "non-existing-key")
+	testutil.AssertNoError(t, err, "Error getting state proof")
+	testutil.AssertNil(t, proof)
+}
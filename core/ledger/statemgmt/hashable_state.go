@@ -17,7 +17,8 @@ limitations under the License.
 package statemgmt
 
 import (
-	"github.com/tecbot/gorocksdb"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 )
 
 // HashableState - Interface that is be implemented by state management
@@ -42,7 +43,7 @@ type HashableState interface {
 	// to persist for committing the  stateDelta (passed in PrepareWorkingSet method) to DB.
 	// In addition to the information in the StateDelta, the implementation may also want to
 	// persist intermediate results for faster crypto-hash computation
-	AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error
+	AddChangesForPersistence(writeBatch db.WriteBatch) error
 
 	// ClearWorkingSet state implementation may clear any data structures that it may have constructed
 	// for computing cryptoHash and persisting the changes for the stateDelta (passed in PrepareWorkingSet method)
@@ -52,7 +53,7 @@ type HashableState interface {
 	// All the key-value of global state. A particular implementation may need to remove additional information
 	// that the implementation keeps for faster crypto-hash computation. For instance, filter a few of the
 	// key-values or remove some data from particular key-values.
-	GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (StateSnapshotIterator, error)
+	GetStateSnapshotIterator(snapshot db.Snapshot) (StateSnapshotIterator, error)
 
 	// GetRangeScanIterator - state implementation to provide an iterator that is supposed to give
 	// All the key-values for a given chaincodeID such that a return key should be lexically greater than or
@@ -68,6 +69,15 @@ type HashableState interface {
 	PerfHintKeyChanged(chaincodeID string, key string)
 }
 
+// ProvableState - Interface that is implemented by the state management implementations
+// which can prove the committed value of a key against the crypto-hash of the committed state
+type ProvableState interface {
+
+	// GetStateProof returns the proof of the committed value of the key. An implementation which
+	// cannot prove that a key does not exist returns nil if the key does not exist
+	GetStateProof(chaincodeID string, key string) (*verify.StateProof, error)
+}
+
 // StateSnapshotIterator An interface that is to be implemented by the return value of
 // GetStateSnapshotIterator method in the implementation of HashableState interface
 type StateSnapshotIterator interface {
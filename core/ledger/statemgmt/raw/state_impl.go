@@ -19,18 +19,18 @@ package raw
 import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
 )
 
 // StateImpl implements raw state management. This implementation does not support computation of crypto-hash of the state.
 // It simply stores the compositeKey and value in the db
 type StateImpl struct {
 	stateDelta *statemgmt.StateDelta
+	db         *db.OpenchainDB
 }
 
-// NewRawState constructs new instance of raw state
-func NewRawState() *StateImpl {
-	return &StateImpl{}
+// NewRawState constructs new instance of raw state keeping the state in the given DB
+func NewRawState(openchainDB *db.OpenchainDB) *StateImpl {
+	return &StateImpl{db: openchainDB}
 }
 
 // Initialize - method implementation for interface 'statemgmt.HashableState'
@@ -41,8 +41,7 @@ func (impl *StateImpl) Initialize(configs map[string]interface{}) error {
 // Get - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) Get(chaincodeID string, key string) ([]byte, error) {
 	compositeKey := statemgmt.ConstructCompositeKey(chaincodeID, key)
-	openchainDB := db.GetDBHandle()
-	return openchainDB.GetFromStateCF(compositeKey)
+	return impl.db.GetFromStateCF(compositeKey)
 }
 
 // PrepareWorkingSet - method implementation for interface 'statemgmt.HashableState'
@@ -62,12 +61,12 @@ func (impl *StateImpl) ComputeCryptoHash() ([]byte, error) {
 }
 
 // AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (impl *StateImpl) AddChangesForPersistence(writeBatch db.WriteBatch) error {
 	delta := impl.stateDelta
 	if delta == nil {
 		return nil
 	}
-	openchainDB := db.GetDBHandle()
+	openchainDB := impl.db
 	updatedChaincodeIds := delta.GetUpdatedChaincodeIds(false)
 	for _, updatedChaincodeID := range updatedChaincodeIds {
 		updates := delta.GetUpdates(updatedChaincodeID)
@@ -88,7 +87,7 @@ func (impl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (impl *StateImpl) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	panic("Not a full-fledged state implementation. Implemented only for measuring best-case performance benchmark")
 }
 
@@ -0,0 +1,35 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sparsemerkle
+
+import "github.com/hyperledger/fabric/core/db"
+
+func fetchNodeFromDB(openchainDB *db.OpenchainDB, pos position) (*node, error) {
+	nodeBytes, err := openchainDB.GetFromStateCF(pos.getEncodedBytes())
+	if err != nil {
+		logger.Errorf("Error in retrieving sparse Merkle trie node from DB at position [%s]. Error:%s", pos, err)
+		return nil, err
+	}
+	if nodeBytes == nil {
+		return nil, nil
+	}
+	return unmarshalNode(nodeBytes)
+}
+
+func fetchValueFromDB(openchainDB *db.OpenchainDB, chaincodeID string, key string) ([]byte, error) {
+	return openchainDB.GetFromStateCF(encodeValueKey(chaincodeID, key))
+}
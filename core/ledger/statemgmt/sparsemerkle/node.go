@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sparsemerkle
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+)
+
+// Keys of the trie nodes and of the values in the DB start with different
+// prefixes, so that the values can be iterated in the order of their keys
+const (
+	nodeKeyPrefix  = byte(0)
+	valueKeyPrefix = byte(1)
+)
+
+// node is either the leaf of a key, or an inner node with the hashes of its
+// children. A subtree holding a single key is replaced by the leaf of the key,
+// so that the trie only gets as deep as needed to tell the keys apart.
+type node struct {
+	keyHash   []byte
+	valueHash []byte
+
+	childHashes [2][]byte
+}
+
+func newLeafNode(keyHash []byte, valueHash []byte) *node {
+	return &node{keyHash: keyHash, valueHash: valueHash}
+}
+
+func (node *node) isLeaf() bool {
+	return node.keyHash != nil
+}
+
+func (node *node) computeCryptoHash() []byte {
+	if node.isLeaf() {
+		return verify.SparseMerkleLeafHash(node.keyHash, node.valueHash)
+	}
+	return verify.SparseMerkleNodeHash(node.childHashes[0], node.childHashes[1])
+}
+
+func (node *node) marshal() ([]byte, error) {
+	buffer := proto.NewBuffer([]byte{})
+	var err error
+	if node.isLeaf() {
+		err = encodeAll(buffer, []byte{0}, node.keyHash, node.valueHash)
+	} else {
+		err = encodeAll(buffer, []byte{1}, node.childHashes[0], node.childHashes[1])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func encodeAll(buffer *proto.Buffer, fields ...[]byte) error {
+	for _, field := range fields {
+		if err := buffer.EncodeRawBytes(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalNode(serializedContent []byte) (*node, error) {
+	buffer := proto.NewBuffer(serializedContent)
+	var fields [3][]byte
+	for i := range fields {
+		field, err := buffer.DecodeRawBytes(false)
+		if err != nil {
+			return nil, fmt.Errorf("Error unmarshalling sparse Merkle trie node: %s", err)
+		}
+		fields[i] = field
+	}
+	if len(fields[0]) != 1 {
+		return nil, fmt.Errorf("Error unmarshalling sparse Merkle trie node: invalid node type")
+	}
+	if fields[0][0] == 0 {
+		return newLeafNode(fields[1], fields[2]), nil
+	}
+	return &node{childHashes: [2][]byte{fields[1], fields[2]}}, nil
+}
+
+// position is the place of a node in the trie, given by the bits of the path
+// from the root to the node
+type position struct {
+	depth int
+	path  []byte
+}
+
+var rootPosition = position{0, make([]byte, verify.SparseMerkleDepth/8)}
+
+func (pos position) child(bit byte) position {
+	path := append([]byte{}, pos.path...)
+	if bit == 1 {
+		path[pos.depth/8] |= 1 << uint(7-pos.depth%8)
+	}
+	return position{pos.depth + 1, path}
+}
+
+func (pos position) getEncodedBytes() []byte {
+	encodedBytes := []byte{nodeKeyPrefix, 0, 0}
+	binary.BigEndian.PutUint16(encodedBytes[1:], uint16(pos.depth))
+	return append(encodedBytes, pos.path[:(pos.depth+7)/8]...)
+}
+
+func (pos position) String() string {
+	return fmt.Sprintf("depth=[%d], path=[%x]", pos.depth, pos.path[:(pos.depth+7)/8])
+}
+
+func encodeValueKey(chaincodeID string, key string) []byte {
+	return append([]byte{valueKeyPrefix}, statemgmt.ConstructCompositeKey(chaincodeID, key)...)
+}
+
+func decodeValueKey(encodedBytes []byte) []byte {
+	return encodedBytes[1:]
+}
@@ -0,0 +1,74 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package sparsemerkle
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+var testDBWrapper = db.NewTestDBWrapper()
+
+type stateImplTestWrapper struct {
+	stateImpl *StateImpl
+	t         *testing.T
+}
+
+func newStateImplTestWrapper(t *testing.T) *stateImplTestWrapper {
+	stateImpl := NewStateImpl(db.GetDBHandle())
+	err := stateImpl.Initialize(nil)
+	testutil.AssertNoError(t, err, "Error while initializing stateImpl")
+	return &stateImplTestWrapper{stateImpl, t}
+}
+
+func (testWrapper *stateImplTestWrapper) get(chaincodeID string, key string) []byte {
+	value, err := testWrapper.stateImpl.Get(chaincodeID, key)
+	testutil.AssertNoError(testWrapper.t, err, "Error while getting value")
+	return value
+}
+
+func (testWrapper *stateImplTestWrapper) prepareWorkingSetAndComputeCryptoHash(stateDelta *statemgmt.StateDelta) []byte {
+	testWrapper.stateImpl.PrepareWorkingSet(stateDelta)
+	cryptoHash, err := testWrapper.stateImpl.ComputeCryptoHash()
+	testutil.AssertNoError(testWrapper.t, err, "Error while computing crypto hash")
+	testWrapper.t.Logf("Cryptohash = [%x]", cryptoHash)
+	return cryptoHash
+}
+
+func (testWrapper *stateImplTestWrapper) persistChangesAndResetInMemoryChanges() {
+	writeBatch := db.GetDBHandle().NewWriteBatch()
+	defer writeBatch.Destroy()
+	err := testWrapper.stateImpl.AddChangesForPersistence(writeBatch)
+	testutil.AssertNoError(testWrapper.t, err, "Error while adding changes to db write-batch")
+	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
+	testWrapper.stateImpl.ClearWorkingSet(true)
+}
+
+// commit computes the crypto-hash for the state delta, persists the changes and returns the crypto-hash
+func (testWrapper *stateImplTestWrapper) commit(stateDelta *statemgmt.StateDelta) []byte {
+	cryptoHash := testWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	testWrapper.persistChangesAndResetInMemoryChanges()
+	return cryptoHash
+}
+
+func TestMain(m *testing.M) {
+	testutil.SetupTestConfig()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package sparsemerkle
+
+import (
+	"bytes"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// RangeScanIterator implements the interface 'statemgmt.RangeScanIterator'
+type RangeScanIterator struct {
+	dbItr        db.Iterator
+	prefix       []byte
+	endKey       string
+	currentKey   string
+	currentValue []byte
+	done         bool
+}
+
+func newRangeScanIterator(openchainDB *db.OpenchainDB, chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
+	dbItr := openchainDB.GetStateCFIterator()
+	dbItr.Seek(encodeValueKey(chaincodeID, startKey))
+	return &RangeScanIterator{dbItr, encodeValueKey(chaincodeID, ""), endKey, "", nil, false}, nil
+}
+
+// Next - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *RangeScanIterator) Next() bool {
+	if itr.done {
+		return false
+	}
+	if itr.dbItr.Valid() {
+		// making a copy of key-value bytes because, underlying key bytes are reused by itr.
+		// no need to free slices as iterator frees memory when closed.
+		keyBytes := statemgmt.Copy(itr.dbItr.Key())
+		if bytes.HasPrefix(keyBytes, itr.prefix) {
+			_, currentKey := statemgmt.DecodeCompositeKey(decodeValueKey(keyBytes))
+			if itr.endKey == "" || currentKey <= itr.endKey {
+				itr.currentKey = currentKey
+				itr.currentValue = statemgmt.Copy(itr.dbItr.Value())
+				itr.dbItr.Next()
+				return true
+			}
+		}
+	}
+	// retrieved all the keys in the given range
+	itr.done = true
+	return false
+}
+
+// GetKeyValue - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *RangeScanIterator) GetKeyValue() (string, []byte) {
+	return itr.currentKey, itr.currentValue
+}
+
+// Close - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *RangeScanIterator) Close() {
+	itr.dbItr.Close()
+}
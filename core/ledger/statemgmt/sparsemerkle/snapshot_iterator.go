@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package sparsemerkle
+
+import (
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// StateSnapshotIterator implements the interface 'statemgmt.StateSnapshotIterator'
+type StateSnapshotIterator struct {
+	dbItr        db.Iterator
+	currentKey   []byte
+	currentValue []byte
+}
+
+func newStateSnapshotIterator(openchainDB *db.OpenchainDB, snapshot db.Snapshot) (*StateSnapshotIterator, error) {
+	dbItr := openchainDB.GetStateCFSnapshotIterator(snapshot)
+	// the values are kept after all the trie nodes
+	dbItr.Seek([]byte{valueKeyPrefix})
+	return &StateSnapshotIterator{dbItr, nil, nil}, nil
+}
+
+// Next - see interface 'statemgmt.StateSnapshotIterator' for details
+func (snapshotItr *StateSnapshotIterator) Next() bool {
+	if !snapshotItr.dbItr.Valid() {
+		return false
+	}
+	// making a copy of key-value bytes because, underlying key bytes are reused by itr.
+	// no need to free slices as iterator frees memory when closed.
+	snapshotItr.currentKey = decodeValueKey(statemgmt.Copy(snapshotItr.dbItr.Key()))
+	snapshotItr.currentValue = statemgmt.Copy(snapshotItr.dbItr.Value())
+	snapshotItr.dbItr.Next()
+	return true
+}
+
+// GetRawKeyValue - see interface 'statemgmt.StateSnapshotIterator' for details
+func (snapshotItr *StateSnapshotIterator) GetRawKeyValue() ([]byte, []byte) {
+	return snapshotItr.currentKey, snapshotItr.currentValue
+}
+
+// Close - see interface 'statemgmt.StateSnapshotIterator' for details
+func (snapshotItr *StateSnapshotIterator) Close() {
+	snapshotItr.dbItr.Close()
+}
@@ -0,0 +1,381 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sparsemerkle keeps the state in a sparse Merkle trie, in which the
+// leaf of a key is found by following the bits of the hash of the key from the
+// root. As the position of every key is fixed, the trie can prove both that a
+// key has a value and that a key does not exist.
+package sparsemerkle
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("sparsemerkle")
+
+// StateImpl - implements the interfaces 'statemgmt.HashableState' and 'statemgmt.ProvableState'
+type StateImpl struct {
+	stateDelta             *statemgmt.StateDelta
+	changedNodes           map[string]*changedNode
+	persistedStateHash     []byte
+	lastComputedCryptoHash []byte
+	recomputeCryptoHash    bool
+	db                     *db.OpenchainDB
+}
+
+// changedNode is a node written to the DB when the working set is persisted,
+// a nil node is deleted
+type changedNode struct {
+	pos  position
+	node *node
+}
+
+// leafUpdate is the change of the value of a key, a nil valueHash deletes the key
+type leafUpdate struct {
+	keyHash   []byte
+	valueHash []byte
+}
+
+// NewStateImpl constructs a new StateImpl keeping the state in the given DB
+func NewStateImpl(openchainDB *db.OpenchainDB) *StateImpl {
+	return &StateImpl{db: openchainDB}
+}
+
+// Initialize - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
+	rootNode, err := fetchNodeFromDB(stateImpl.db, rootPosition)
+	if err != nil {
+		return err
+	}
+	if rootNode != nil {
+		stateImpl.persistedStateHash = rootNode.computeCryptoHash()
+		stateImpl.lastComputedCryptoHash = stateImpl.persistedStateHash
+	}
+	return nil
+}
+
+// Get - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) Get(chaincodeID string, key string) ([]byte, error) {
+	return fetchValueFromDB(stateImpl.db, chaincodeID, key)
+}
+
+// PrepareWorkingSet - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) PrepareWorkingSet(stateDelta *statemgmt.StateDelta) error {
+	if stateDelta.IsEmpty() {
+		logger.Debug("Ignoring working-set as it is empty")
+		return nil
+	}
+	stateImpl.stateDelta = stateDelta
+	stateImpl.changedNodes = make(map[string]*changedNode)
+	stateImpl.recomputeCryptoHash = true
+	return nil
+}
+
+// ClearWorkingSet - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) ClearWorkingSet(changesPersisted bool) {
+	if changesPersisted {
+		stateImpl.persistedStateHash = stateImpl.lastComputedCryptoHash
+	} else {
+		stateImpl.lastComputedCryptoHash = stateImpl.persistedStateHash
+	}
+	stateImpl.stateDelta = nil
+	stateImpl.changedNodes = nil
+	stateImpl.recomputeCryptoHash = false
+}
+
+// ComputeCryptoHash - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) ComputeCryptoHash() ([]byte, error) {
+	if !stateImpl.recomputeCryptoHash {
+		return stateImpl.lastComputedCryptoHash, nil
+	}
+	updates, err := stateImpl.getSortedLeafUpdates()
+	if err != nil {
+		return nil, err
+	}
+	rootNode, err := fetchNodeFromDB(stateImpl.db, rootPosition)
+	if err != nil {
+		return nil, err
+	}
+	rootNode, err = stateImpl.update(rootPosition, rootNode, updates)
+	if err != nil {
+		return nil, err
+	}
+	stateImpl.setNode(rootPosition, rootNode)
+	stateImpl.lastComputedCryptoHash = nil
+	if rootNode != nil {
+		stateImpl.lastComputedCryptoHash = rootNode.computeCryptoHash()
+	}
+	stateImpl.recomputeCryptoHash = false
+	return stateImpl.lastComputedCryptoHash, nil
+}
+
+func (stateImpl *StateImpl) getSortedLeafUpdates() ([]*leafUpdate, error) {
+	var updates []*leafUpdate
+	for _, chaincodeID := range stateImpl.stateDelta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range stateImpl.stateDelta.GetUpdates(chaincodeID) {
+			update := &leafUpdate{keyHash: verify.SparseMerkleKeyHash(chaincodeID, key)}
+			if !updatedValue.IsDelete() {
+				update.valueHash = verify.SparseMerkleValueHash(updatedValue.GetValue())
+			}
+			updates = append(updates, update)
+		}
+	}
+	sort.Sort(leafUpdates(updates))
+	for i := 1; i < len(updates); i++ {
+		if bytes.Equal(updates[i-1].keyHash, updates[i].keyHash) {
+			return nil, fmt.Errorf("Two keys of the state delta have the same key hash [%x]", updates[i].keyHash)
+		}
+	}
+	return updates, nil
+}
+
+// update applies the updates, which all belong below pos, to the subtree at
+// pos and returns its new root. The children of the returned node are added
+// to the changed nodes, writing the node itself is left to the caller.
+func (stateImpl *StateImpl) update(pos position, existing *node, updates []*leafUpdate) (*node, error) {
+	if len(updates) == 0 {
+		return existing, nil
+	}
+	if existing == nil || existing.isLeaf() {
+		return stateImpl.build(pos, mergeLeaves(existing, updates)), nil
+	}
+
+	split := splitIndex(pos.depth, len(updates), func(i int) []byte { return updates[i].keyHash })
+	childUpdates := [2][]*leafUpdate{updates[:split], updates[split:]}
+	childHashes := existing.childHashes
+	var children [2]*node
+	for bit := range childUpdates {
+		if len(childUpdates[bit]) == 0 {
+			continue
+		}
+		childPos := pos.child(byte(bit))
+		child, err := stateImpl.fetchChild(existing, bit, childPos)
+		if err != nil {
+			return nil, err
+		}
+		if children[bit], err = stateImpl.update(childPos, child, childUpdates[bit]); err != nil {
+			return nil, err
+		}
+		childHashes[bit] = nil
+		if children[bit] != nil {
+			childHashes[bit] = children[bit].computeCryptoHash()
+		}
+	}
+
+	// a node left with a single key is replaced by the leaf of the key
+	for bit := range childHashes {
+		other := 1 - bit
+		if len(childHashes[bit]) != 0 {
+			continue
+		}
+		if len(childHashes[other]) == 0 {
+			stateImpl.setNode(pos.child(0), nil)
+			stateImpl.setNode(pos.child(1), nil)
+			return nil, nil
+		}
+		if children[other] == nil {
+			otherChild, err := fetchNodeFromDB(stateImpl.db, pos.child(byte(other)))
+			if err != nil {
+				return nil, err
+			}
+			if otherChild == nil {
+				return nil, fmt.Errorf("Sparse Merkle trie node at position [%s] is missing from the DB", pos.child(byte(other)))
+			}
+			children[other] = otherChild
+		}
+		if children[other].isLeaf() {
+			stateImpl.setNode(pos.child(0), nil)
+			stateImpl.setNode(pos.child(1), nil)
+			return children[other], nil
+		}
+	}
+	for bit := range childUpdates {
+		if len(childUpdates[bit]) != 0 {
+			stateImpl.setNode(pos.child(byte(bit)), children[bit])
+		}
+	}
+	return &node{childHashes: childHashes}, nil
+}
+
+func (stateImpl *StateImpl) fetchChild(parent *node, bit int, childPos position) (*node, error) {
+	if len(parent.childHashes[bit]) == 0 {
+		return nil, nil
+	}
+	child, err := fetchNodeFromDB(stateImpl.db, childPos)
+	if err != nil {
+		return nil, err
+	}
+	if child == nil {
+		return nil, fmt.Errorf("Sparse Merkle trie node at position [%s] is missing from the DB", childPos)
+	}
+	return child, nil
+}
+
+// build returns the root of a new subtree at pos holding the given leaves,
+// which are sorted by key hash
+func (stateImpl *StateImpl) build(pos position, leaves []*node) *node {
+	switch len(leaves) {
+	case 0:
+		return nil
+	case 1:
+		return leaves[0]
+	}
+	split := splitIndex(pos.depth, len(leaves), func(i int) []byte { return leaves[i].keyHash })
+	newNode := &node{}
+	for bit, childLeaves := range [2][]*node{leaves[:split], leaves[split:]} {
+		child := stateImpl.build(pos.child(byte(bit)), childLeaves)
+		if child != nil {
+			stateImpl.setNode(pos.child(byte(bit)), child)
+			newNode.childHashes[bit] = child.computeCryptoHash()
+		}
+	}
+	return newNode
+}
+
+// mergeLeaves returns the leaves of a subtree which only holds the existing
+// leaf, if not nil, after applying the updates
+func mergeLeaves(existing *node, updates []*leafUpdate) []*node {
+	var leaves []*node
+	existingUpdated := existing == nil
+	for _, update := range updates {
+		if !existingUpdated && bytes.Compare(existing.keyHash, update.keyHash) <= 0 {
+			existingUpdated = true
+			if !bytes.Equal(existing.keyHash, update.keyHash) {
+				leaves = append(leaves, existing)
+			}
+		}
+		if update.valueHash != nil {
+			leaves = append(leaves, newLeafNode(update.keyHash, update.valueHash))
+		}
+	}
+	if !existingUpdated {
+		leaves = append(leaves, existing)
+	}
+	return leaves
+}
+
+// splitIndex returns the index of the first of the sorted key hashes whose
+// bit at the given depth is 1
+func splitIndex(depth int, n int, keyHash func(int) []byte) int {
+	return sort.Search(n, func(i int) bool { return verify.SparseMerkleBit(keyHash(i), depth) == 1 })
+}
+
+func (stateImpl *StateImpl) setNode(pos position, node *node) {
+	stateImpl.changedNodes[string(pos.getEncodedBytes())] = &changedNode{pos, node}
+}
+
+// AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch db.WriteBatch) error {
+	if stateImpl.stateDelta == nil {
+		return nil
+	}
+	if stateImpl.recomputeCryptoHash {
+		if _, err := stateImpl.ComputeCryptoHash(); err != nil {
+			return err
+		}
+	}
+	openchainDB := stateImpl.db
+	for _, chaincodeID := range stateImpl.stateDelta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range stateImpl.stateDelta.GetUpdates(chaincodeID) {
+			if updatedValue.IsDelete() {
+				writeBatch.DeleteCF(openchainDB.StateCF, encodeValueKey(chaincodeID, key))
+			} else {
+				writeBatch.PutCF(openchainDB.StateCF, encodeValueKey(chaincodeID, key), updatedValue.GetValue())
+			}
+		}
+	}
+	for encodedPos, changedNode := range stateImpl.changedNodes {
+		if changedNode.node == nil {
+			writeBatch.DeleteCF(openchainDB.StateCF, []byte(encodedPos))
+			continue
+		}
+		serializedContent, err := changedNode.node.marshal()
+		if err != nil {
+			return err
+		}
+		writeBatch.PutCF(openchainDB.StateCF, []byte(encodedPos), serializedContent)
+	}
+	return nil
+}
+
+// PerfHintKeyChanged - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
+}
+
+// GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+	return newStateSnapshotIterator(stateImpl.db, snapshot)
+}
+
+// GetRangeScanIterator - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newRangeScanIterator(stateImpl.db, chaincodeID, startKey, endKey)
+}
+
+// GetStateProof - method implementation for interface 'statemgmt.ProvableState'.
+// A key which does not exist gets a proof of non-inclusion.
+func (stateImpl *StateImpl) GetStateProof(chaincodeID string, key string) (*verify.StateProof, error) {
+	proof := &verify.StateProof{Type: verify.ProofTypeSparseMerkle, ChaincodeID: chaincodeID, Key: key}
+	keyHash := verify.SparseMerkleKeyHash(chaincodeID, key)
+	pos := rootPosition
+	currentNode, err := fetchNodeFromDB(stateImpl.db, pos)
+	if err != nil {
+		return nil, err
+	}
+	var siblings [][]byte
+	for currentNode != nil && !currentNode.isLeaf() {
+		bit := verify.SparseMerkleBit(keyHash, pos.depth)
+		siblings = append(siblings, currentNode.childHashes[1-bit])
+		pos = pos.child(bit)
+		if currentNode, err = stateImpl.fetchChild(currentNode, int(bit), pos); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(siblings) - 1; i >= 0; i-- {
+		proof.MerkleSiblings = append(proof.MerkleSiblings, siblings[i])
+	}
+
+	switch {
+	case currentNode == nil:
+		proof.NonInclusion = true
+	case !bytes.Equal(currentNode.keyHash, keyHash):
+		proof.NonInclusion = true
+		proof.OtherLeaf = &verify.SparseMerkleLeaf{KeyHash: currentNode.keyHash, ValueHash: currentNode.valueHash}
+	default:
+		value, err := fetchValueFromDB(stateImpl.db, chaincodeID, key)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, fmt.Errorf("Value of key [%s] of chaincode [%s] is missing from the DB", key, chaincodeID)
+		}
+		proof.Value = value
+	}
+	return proof, nil
+}
+
+type leafUpdates []*leafUpdate
+
+func (u leafUpdates) Len() int           { return len(u) }
+func (u leafUpdates) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+func (u leafUpdates) Less(i, j int) bool { return bytes.Compare(u[i].keyHash, u[j].keyHash) < 0 }
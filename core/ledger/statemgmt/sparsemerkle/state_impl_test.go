@@ -0,0 +1,227 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package sparsemerkle
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+)
+
+func TestStateImpl_ComputeHash_NoContents(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newStateImplTestWrapper(t)
+	hash := testWrapper.prepareWorkingSetAndComputeCryptoHash(statemgmt.NewStateDelta())
+	testutil.AssertNil(t, hash)
+}
+
+func TestStateImpl_ComputeHash_SingleKey(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newStateImplTestWrapper(t)
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	hash := testWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	expectedHash := verify.SparseMerkleLeafHash(verify.SparseMerkleKeyHash("chaincodeID1", "key1"),
+		verify.SparseMerkleValueHash([]byte("value1")))
+	testutil.AssertEquals(t, hash, expectedHash)
+}
+
+func TestStateImpl_ComputeHash_Incremental(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newStateImplTestWrapper(t)
+	rnd := rand.New(rand.NewSource(1))
+	expectedState := make(map[string][]byte)
+	for block := 0; block < 20; block++ {
+		stateDelta := statemgmt.NewStateDelta()
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("key%d", rnd.Intn(100))
+			if _, ok := expectedState[key]; ok && rnd.Intn(3) == 0 {
+				stateDelta.Delete("chaincodeID1", key, nil)
+				delete(expectedState, key)
+				continue
+			}
+			value := []byte(fmt.Sprintf("value%d-%d", block, i))
+			stateDelta.Set("chaincodeID1", key, value, nil)
+			expectedState[key] = value
+		}
+		hash := testWrapper.commit(stateDelta)
+		testutil.AssertEquals(t, hash, computeHashFromScratch(t, expectedState))
+	}
+	for key, value := range expectedState {
+		testutil.AssertEquals(t, testWrapper.get("chaincodeID1", key), value)
+	}
+
+	// reloading from the DB gives the same crypto-hash
+	hash, _ := testWrapper.stateImpl.ComputeCryptoHash()
+	testutil.AssertEquals(t, newStateImplTestWrapper(t).stateImpl.persistedStateHash, hash)
+}
+
+func TestStateImpl_DeleteAllKeys(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newStateImplTestWrapper(t)
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 5; i++ {
+		stateDelta.Set("chaincodeID1", fmt.Sprintf("key%d", i), []byte("value"), nil)
+	}
+	hash1 := testWrapper.commit(stateDelta)
+
+	stateDelta = statemgmt.NewStateDelta()
+	for i := 5; i < 50; i++ {
+		stateDelta.Set("chaincodeID2", fmt.Sprintf("key%d", i), []byte("value"), nil)
+	}
+	testWrapper.commit(stateDelta)
+
+	stateDelta = statemgmt.NewStateDelta()
+	for i := 5; i < 50; i++ {
+		stateDelta.Delete("chaincodeID2", fmt.Sprintf("key%d", i), nil)
+	}
+	testutil.AssertEquals(t, testWrapper.commit(stateDelta), hash1)
+
+	stateDelta = statemgmt.NewStateDelta()
+	for i := 0; i < 5; i++ {
+		stateDelta.Delete("chaincodeID1", fmt.Sprintf("key%d", i), nil)
+	}
+	testutil.AssertNil(t, testWrapper.commit(stateDelta))
+
+	// no trie nodes are left behind
+	itr := db.GetDBHandle().GetStateCFIterator()
+	defer itr.Close()
+	itr.SeekToFirst()
+	testutil.AssertEquals(t, itr.Valid(), false)
+}
+
+func TestStateImpl_GetStateProof(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newStateImplTestWrapper(t)
+
+	// an empty state proves that no key exists
+	proof, err := testWrapper.stateImpl.GetStateProof("chaincodeID1", "key1")
+	testutil.AssertNoError(t, err, "Error while getting state proof")
+	testutil.AssertEquals(t, proof.NonInclusion, true)
+	testutil.AssertNoError(t, verify.VerifyStateProof(proof, nil), "Error verifying proof against empty state")
+
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 100; i++ {
+		stateDelta.Set("chaincodeID1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	hash := testWrapper.commit(stateDelta)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		proof, err := testWrapper.stateImpl.GetStateProof("chaincodeID1", key)
+		testutil.AssertNoError(t, err, "Error while getting state proof")
+		testutil.AssertEquals(t, proof.NonInclusion, false)
+		testutil.AssertEquals(t, proof.Value, []byte(fmt.Sprintf("value%d", i)))
+		testutil.AssertNoError(t, verify.VerifyStateProof(proof, hash), fmt.Sprintf("Error verifying proof of key [%s]", key))
+
+		proof.Value = []byte("tampered")
+		testutil.AssertError(t, verify.VerifyStateProof(proof, hash), "Expected error verifying proof with tampered value")
+	}
+
+	otherLeafFound := false
+	for i := 100; i < 200; i++ {
+		key := fmt.Sprintf("key%d", i)
+		proof, err := testWrapper.stateImpl.GetStateProof("chaincodeID1", key)
+		testutil.AssertNoError(t, err, "Error while getting state proof")
+		testutil.AssertEquals(t, proof.NonInclusion, true)
+		testutil.AssertNil(t, proof.Value)
+		testutil.AssertNoError(t, verify.VerifyStateProof(proof, hash), fmt.Sprintf("Error verifying non-inclusion proof of key [%s]", key))
+		if proof.OtherLeaf != nil {
+			otherLeafFound = true
+			proof.OtherLeaf = nil
+			testutil.AssertError(t, verify.VerifyStateProof(proof, hash), "Expected error verifying proof without the leaf of the other key")
+		}
+	}
+	testutil.AssertEquals(t, otherLeafFound, true)
+}
+
+func TestStateImpl_RangeScanIterator(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newStateImplTestWrapper(t)
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	for i := 1; i <= 7; i++ {
+		stateDelta.Set("chaincodeID2", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	stateDelta.Set("chaincodeID3", "key1", []byte("value1"), nil)
+	testWrapper.commit(stateDelta)
+
+	rangeScanItr, err := testWrapper.stateImpl.GetRangeScanIterator("chaincodeID2", "key2", "key5")
+	testutil.AssertNoError(t, err, "Error while getting range scan iterator")
+	statemgmt.AssertIteratorContains(t, rangeScanItr, map[string][]byte{
+		"key2": []byte("value2"),
+		"key3": []byte("value3"),
+		"key4": []byte("value4"),
+		"key5": []byte("value5"),
+	})
+	rangeScanItr.Close()
+
+	rangeScanItr, err = testWrapper.stateImpl.GetRangeScanIterator("chaincodeID2", "", "")
+	testutil.AssertNoError(t, err, "Error while getting range scan iterator")
+	expectedMap := make(map[string][]byte)
+	for i := 1; i <= 7; i++ {
+		expectedMap[fmt.Sprintf("key%d", i)] = []byte(fmt.Sprintf("value%d", i))
+	}
+	statemgmt.AssertIteratorContains(t, rangeScanItr, expectedMap)
+	rangeScanItr.Close()
+}
+
+func TestStateImpl_SnapshotIterator(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newStateImplTestWrapper(t)
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 10; i++ {
+		stateDelta.Set(fmt.Sprintf("chaincodeID%d", i%3), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	testWrapper.commit(stateDelta)
+
+	snapshot := db.GetDBHandle().GetSnapshot()
+	defer snapshot.Release()
+	itr, err := testWrapper.stateImpl.GetStateSnapshotIterator(snapshot)
+	testutil.AssertNoError(t, err, "Error while getting snapshot iterator")
+	defer itr.Close()
+	numKeys := 0
+	for itr.Next() {
+		compositeKey, value := itr.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+		testutil.AssertEquals(t, value, stateDelta.Get(chaincodeID, key).GetValue())
+		numKeys++
+	}
+	testutil.AssertEquals(t, numKeys, 10)
+}
+
+// computeHashFromScratch returns the crypto-hash of a trie holding only the given keys of chaincodeID1
+func computeHashFromScratch(t *testing.T, keyValues map[string][]byte) []byte {
+	stateImpl := NewStateImpl(db.GetDBHandle())
+	stateDelta := statemgmt.NewStateDelta()
+	for key, value := range keyValues {
+		stateDelta.Set("chaincodeID1", key, value, nil)
+	}
+	stateImpl.stateDelta = stateDelta
+	stateImpl.changedNodes = make(map[string]*changedNode)
+	updates, err := stateImpl.getSortedLeafUpdates()
+	testutil.AssertNoError(t, err, "Error while sorting updates")
+	root := stateImpl.build(rootPosition, mergeLeaves(nil, updates))
+	if root == nil {
+		return nil
+	}
+	return root.computeCryptoHash()
+}
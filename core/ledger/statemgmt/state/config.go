@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/hyperledger/fabric/core/ledger/util"
 	"github.com/spf13/viper"
 )
 
@@ -28,6 +29,7 @@ var loadConfigOnce sync.Once
 var stateImplName string
 var stateImplConfigs map[string]interface{}
 var deltaHistorySize int
+var compression util.Compression
 
 func initConfig() {
 	loadConfigOnce.Do(func() { loadConfig() })
@@ -44,11 +46,18 @@ func loadConfig() {
 	if len(stateImplName) == 0 {
 		stateImplName = detaultStateImpl
 		stateImplConfigs = nil
-	} else if stateImplName != "buckettree" && stateImplName != "trie" && stateImplName != "raw" {
+	} else if stateImplName != "buckettree" && stateImplName != "trie" && stateImplName != "raw" &&
+		stateImplName != "sparsemerkle" {
 		panic(fmt.Errorf("Error during initialization of state implementation. State data structure '%s' is not valid.", stateImplName))
 	}
 
 	if deltaHistorySize < 0 {
 		panic(fmt.Errorf("Delta history size must be greater than or equal to 0. Current value is %d.", deltaHistorySize))
 	}
+
+	var err error
+	compression, err = util.CompressionFromConfig()
+	if err != nil {
+		panic(fmt.Errorf("Error during initialization of state implementation. %s", err))
+	}
 }
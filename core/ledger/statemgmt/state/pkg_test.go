@@ -23,7 +23,6 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
-	"github.com/tecbot/gorocksdb"
 )
 
 var testDBWrapper = db.NewTestDBWrapper()
@@ -45,7 +44,7 @@ type stateTestWrapper struct {
 }
 
 func newStateTestWrapper(t *testing.T) *stateTestWrapper {
-	return &stateTestWrapper{t, NewState()}
+	return &stateTestWrapper{t, NewState(db.GetDBHandle())}
 }
 
 func (testWrapper *stateTestWrapper) get(chaincodeID string, key string, committed bool) []byte {
@@ -62,7 +61,7 @@ func (testWrapper *stateTestWrapper) getSnapshot() *StateSnapshot {
 }
 
 func (testWrapper *stateTestWrapper) persistAndClearInMemoryChanges(blockNumber uint64) {
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.GetDBHandle().NewWriteBatch()
 	defer writeBatch.Destroy()
 	testWrapper.state.AddChangesForPersistence(blockNumber, writeBatch)
 	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
@@ -0,0 +1,77 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// The private state holds values which are only stored by some of the peers.
+// It is not part of the world state and is neither hashed nor transferred to
+// other peers, but its changes are committed and rolled back with the txs
+// like the changes to the world state.
+
+// GetPrivate returns the private value of key in namespace. If committed is
+// false, this first looks in memory and if missing, pulls from db. If
+// committed is true, this pulls from the db only.
+func (state *State) GetPrivate(namespace string, key string, committed bool) ([]byte, error) {
+	if !committed {
+		valueHolder := state.currentTxPrivateStateDelta.Get(namespace, key)
+		if valueHolder != nil {
+			return valueHolder.GetValue(), nil
+		}
+		valueHolder = state.privateStateDelta.Get(namespace, key)
+		if valueHolder != nil {
+			return valueHolder.GetValue(), nil
+		}
+	}
+	return state.db.GetFromPrivateStateCF(statemgmt.ConstructCompositeKey(namespace, key))
+}
+
+// SetPrivate sets the private value of key in namespace. Does not immediately
+// write to DB
+func (state *State) SetPrivate(namespace string, key string, value []byte) {
+	logger.Debugf("setPrivate() namespace=[%s], key=[%s]", namespace, key)
+	if !state.txInProgress() {
+		panic("State can be changed only in context of a tx.")
+	}
+	state.currentTxPrivateStateDelta.Set(namespace, key, value, nil)
+}
+
+// DeletePrivate tracks the deletion of the private value of key in namespace.
+// Does not immediately write to DB
+func (state *State) DeletePrivate(namespace string, key string) {
+	logger.Debugf("deletePrivate() namespace=[%s], key=[%s]", namespace, key)
+	if !state.txInProgress() {
+		panic("State can be changed only in context of a tx.")
+	}
+	state.currentTxPrivateStateDelta.Delete(namespace, key, nil)
+}
+
+func (state *State) addPrivateChangesForPersistence(writeBatch db.WriteBatch) {
+	for _, namespace := range state.privateStateDelta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range state.privateStateDelta.GetUpdates(namespace) {
+			dbKey := statemgmt.ConstructCompositeKey(namespace, key)
+			if updatedValue.IsDelete() {
+				writeBatch.DeleteCF(state.db.PrivateStateCF, dbKey)
+			} else {
+				writeBatch.PutCF(state.db.PrivateStateCF, dbKey, updatedValue.GetValue())
+			}
+		}
+	}
+}
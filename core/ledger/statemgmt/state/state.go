@@ -24,17 +24,17 @@ import (
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/raw"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/sparsemerkle"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/trie"
+	"github.com/hyperledger/fabric/core/ledger/util"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var logger = logging.MustGetLogger("state")
 
 const detaultStateImpl = "buckettree"
 
-var stateImpl statemgmt.HashableState
-
 // State structure for maintaining world state.
 // This encapsulates a particular implementation for managing the state persistence
 // This is not thread safe
@@ -44,21 +44,32 @@ type State struct {
 	currentTxStateDelta   *statemgmt.StateDelta
 	currentTxUUID         string
 	txStateDeltaHash      map[string][]byte
+	currentTxReads        *txReads
+	nestedTxScopes        []*nestedTxScope
+	txReadWriteSets       map[string]*statemgmt.TxReadWriteSet
 	updateStateImpl       bool
 	historyStateDeltaSize uint64
+	db                    *db.OpenchainDB
+	// the changes to the private state, which are kept apart from the world
+	// state and only persisted locally
+	privateStateDelta          *statemgmt.StateDelta
+	currentTxPrivateStateDelta *statemgmt.StateDelta
 }
 
-// NewState constructs a new State. This Initializes encapsulated state implementation
-func NewState() *State {
+// NewState constructs a new State kept in the given DB. This Initializes encapsulated state implementation
+func NewState(openchainDB *db.OpenchainDB) *State {
 	initConfig()
 	logger.Infof("Initializing state implementation [%s]", stateImplName)
+	var stateImpl statemgmt.HashableState
 	switch stateImplName {
 	case "buckettree":
-		stateImpl = buckettree.NewStateImpl()
+		stateImpl = buckettree.NewStateImpl(openchainDB)
 	case "trie":
-		stateImpl = trie.NewStateTrie()
+		stateImpl = trie.NewStateTrie(openchainDB)
 	case "raw":
-		stateImpl = raw.NewRawState()
+		stateImpl = raw.NewRawState(openchainDB)
+	case "sparsemerkle":
+		stateImpl = sparsemerkle.NewStateImpl(openchainDB)
 	default:
 		panic("Should not reach here. Configs should have checked for the stateImplName being a valid names ")
 	}
@@ -67,7 +78,53 @@ func NewState() *State {
 		panic(fmt.Errorf("Error during initialization of state implementation: %s", err))
 	}
 	return &State{stateImpl, statemgmt.NewStateDelta(), statemgmt.NewStateDelta(), "", make(map[string][]byte),
-		false, uint64(deltaHistorySize)}
+		newTxReads(), nil, make(map[string]*statemgmt.TxReadWriteSet), false, uint64(deltaHistorySize), openchainDB,
+		statemgmt.NewStateDelta(), statemgmt.NewStateDelta()}
+}
+
+// txReads records the reads of the on-going tx, each key and range only once
+type txReads struct {
+	reads      []*statemgmt.KeyRead
+	rangeReads []*statemgmt.RangeRead
+	readKeys   map[statemgmt.KeyRead]bool
+	readRanges map[statemgmt.RangeRead]bool
+}
+
+func newTxReads() *txReads {
+	return &txReads{readKeys: make(map[statemgmt.KeyRead]bool), readRanges: make(map[statemgmt.RangeRead]bool)}
+}
+
+func (reads *txReads) addRead(chaincodeID string, key string) {
+	read := statemgmt.KeyRead{ChaincodeID: chaincodeID, Key: key}
+	if !reads.readKeys[read] {
+		reads.readKeys[read] = true
+		reads.reads = append(reads.reads, &read)
+	}
+}
+
+func (reads *txReads) addRangeRead(chaincodeID string, startKey string, endKey string) {
+	rangeRead := statemgmt.RangeRead{ChaincodeID: chaincodeID, StartKey: startKey, EndKey: endKey}
+	if !reads.readRanges[rangeRead] {
+		reads.readRanges[rangeRead] = true
+		reads.rangeReads = append(reads.rangeReads, &rangeRead)
+	}
+}
+
+func (reads *txReads) merge(anotherReads *txReads) {
+	for _, read := range anotherReads.reads {
+		reads.addRead(read.ChaincodeID, read.Key)
+	}
+	for _, rangeRead := range anotherReads.rangeReads {
+		reads.addRangeRead(rangeRead.ChaincodeID, rangeRead.StartKey, rangeRead.EndKey)
+	}
+}
+
+// nestedTxScope holds the state changes and the reads of the enclosing scope
+// of the on-going tx while a nested invocation is in progress
+type nestedTxScope struct {
+	stateDelta        *statemgmt.StateDelta
+	reads             *txReads
+	privateStateDelta *statemgmt.StateDelta
 }
 
 // TxBegin marks begin of a new tx. If a tx is already in progress, this call panics
@@ -94,11 +151,59 @@ func (state *State) TxFinish(txUUID string, txSuccessful bool) {
 		} else {
 			state.txStateDeltaHash[txUUID] = nil
 		}
+		state.txReadWriteSets[txUUID] = &statemgmt.TxReadWriteSet{
+			Reads:      state.currentTxReads.reads,
+			RangeReads: state.currentTxReads.rangeReads,
+			Writes:     state.currentTxStateDelta}
+		state.privateStateDelta.ApplyChanges(state.currentTxPrivateStateDelta)
 	}
 	state.currentTxStateDelta = statemgmt.NewStateDelta()
+	state.currentTxPrivateStateDelta = statemgmt.NewStateDelta()
+	state.currentTxReads = newTxReads()
+	state.nestedTxScopes = nil
 	state.currentTxUUID = ""
 }
 
+// NestedTxBegin marks begin of a nested invocation within the on-going tx, such as a chaincode invoked
+// by another chaincode. The nested invocation sees the state changes made so far by the tx. If txUUID
+// is not same as of the on-going tx, this call panics
+func (state *State) NestedTxBegin(txUUID string) {
+	logger.Debugf("nestedTxBegin() for txUuid [%s] at depth [%d]", txUUID, len(state.nestedTxScopes)+1)
+	if state.currentTxUUID != txUUID {
+		panic(fmt.Errorf("Different Uuid in tx-begin [%s] and nested-tx-begin [%s]", state.currentTxUUID, txUUID))
+	}
+	state.nestedTxScopes = append(state.nestedTxScopes, &nestedTxScope{state.currentTxStateDelta, state.currentTxReads, state.currentTxPrivateStateDelta})
+	nestedStateDelta := statemgmt.NewStateDelta()
+	nestedStateDelta.ApplyChanges(state.currentTxStateDelta)
+	state.currentTxStateDelta = nestedStateDelta
+	nestedPrivateStateDelta := statemgmt.NewStateDelta()
+	nestedPrivateStateDelta.ApplyChanges(state.currentTxPrivateStateDelta)
+	state.currentTxPrivateStateDelta = nestedPrivateStateDelta
+	state.currentTxReads = newTxReads()
+}
+
+// NestedTxFinish marks the completion of the innermost nested invocation of the on-going tx. The reads
+// of the nested invocation are merged into the enclosing scope and so are its state changes, if it is
+// successful. If txUUID is not same as of the on-going tx or no nested invocation is in progress, this call panics
+func (state *State) NestedTxFinish(txUUID string, txSuccessful bool) {
+	logger.Debugf("nestedTxFinish() for txUuid [%s] at depth [%d], txSuccessful=[%t]", txUUID, len(state.nestedTxScopes), txSuccessful)
+	if state.currentTxUUID != txUUID {
+		panic(fmt.Errorf("Different Uuid in tx-begin [%s] and nested-tx-finish [%s]", state.currentTxUUID, txUUID))
+	}
+	if len(state.nestedTxScopes) == 0 {
+		panic(fmt.Errorf("No nested invocation in progress for tx [%s]", txUUID))
+	}
+	enclosing := state.nestedTxScopes[len(state.nestedTxScopes)-1]
+	state.nestedTxScopes = state.nestedTxScopes[:len(state.nestedTxScopes)-1]
+	// the reads are kept even if the nested invocation fails, as the tx may have acted on its failure
+	enclosing.reads.merge(state.currentTxReads)
+	if !txSuccessful {
+		state.currentTxStateDelta = enclosing.stateDelta
+		state.currentTxPrivateStateDelta = enclosing.privateStateDelta
+	}
+	state.currentTxReads = enclosing.reads
+}
+
 func (state *State) txInProgress() bool {
 	return state.currentTxUUID != ""
 }
@@ -107,6 +212,9 @@ func (state *State) txInProgress() bool {
 // pulls from db. If committed is true, this pulls from the db only.
 func (state *State) Get(chaincodeID string, key string, committed bool) ([]byte, error) {
 	if !committed {
+		if state.txInProgress() {
+			state.currentTxReads.addRead(chaincodeID, key)
+		}
 		valueHolder := state.currentTxStateDelta.Get(chaincodeID, key)
 		if valueHolder != nil {
 			return valueHolder.GetValue(), nil
@@ -119,6 +227,31 @@ func (state *State) Get(chaincodeID string, key string, committed bool) ([]byte,
 	return state.stateImpl.Get(chaincodeID, key)
 }
 
+// GetAt returns the committed value of the key as of block blockNumber, given the number of the
+// last committed block. The current value is rolled back with the state deltas of the later blocks,
+// so only blocks within the state delta history can be queried. The caller has to detect a block
+// committed after lastBlockNumber while the value is read, as the value would then include it.
+func (state *State) GetAt(chaincodeID string, key string, blockNumber uint64, lastBlockNumber uint64) ([]byte, error) {
+	value, err := state.stateImpl.Get(chaincodeID, key)
+	if err != nil {
+		return nil, err
+	}
+	for deltaBlockNumber := lastBlockNumber; deltaBlockNumber > blockNumber; deltaBlockNumber-- {
+		stateDelta, err := state.FetchStateDeltaFromDB(deltaBlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if stateDelta == nil {
+			return nil, fmt.Errorf("State delta for block [%d] is not available, the state can only be queried for the last [%d] blocks",
+				deltaBlockNumber, state.historyStateDeltaSize)
+		}
+		if updatedValue := stateDelta.Get(chaincodeID, key); updatedValue != nil {
+			value = updatedValue.GetPreviousValue()
+		}
+	}
+	return value, nil
+}
+
 // GetRangeScanIterator returns an iterator to get all the keys (and values) between startKey and endKey
 // (assuming lexical order of the keys) for a chaincodeID.
 func (state *State) GetRangeScanIterator(chaincodeID string, startKey string, endKey string, committed bool) (statemgmt.RangeScanIterator, error) {
@@ -130,6 +263,9 @@ func (state *State) GetRangeScanIterator(chaincodeID string, startKey string, en
 	if committed {
 		return stateImplItr, nil
 	}
+	if state.txInProgress() {
+		state.currentTxReads.addRangeRead(chaincodeID, startKey, endKey)
+	}
 	return newCompositeRangeScanIterator(
 		statemgmt.NewStateDeltaRangeScanIterator(state.currentTxStateDelta, chaincodeID, startKey, endKey),
 		statemgmt.NewStateDeltaRangeScanIterator(state.stateDelta, chaincodeID, startKey, endKey),
@@ -247,10 +383,18 @@ func (state *State) GetTxStateDeltaHash() map[string][]byte {
 	return state.txStateDeltaHash
 }
 
+// GetTxReadWriteSets returns the read/write sets of the successful txs since the most
+// recent call to method ClearInMemoryChanges
+func (state *State) GetTxReadWriteSets() map[string]*statemgmt.TxReadWriteSet {
+	return state.txReadWriteSets
+}
+
 // ClearInMemoryChanges remove from memory all the changes to state
 func (state *State) ClearInMemoryChanges(changesPersisted bool) {
 	state.stateDelta = statemgmt.NewStateDelta()
+	state.privateStateDelta = statemgmt.NewStateDelta()
 	state.txStateDeltaHash = make(map[string][]byte)
+	state.txReadWriteSets = make(map[string]*statemgmt.TxReadWriteSet)
 	state.stateImpl.ClearWorkingSet(changesPersisted)
 }
 
@@ -261,13 +405,23 @@ func (state *State) getStateDelta() *statemgmt.StateDelta {
 
 // GetSnapshot returns a snapshot of the global state for the current block. stateSnapshot.Release()
 // must be called once you are done.
-func (state *State) GetSnapshot(blockNumber uint64, dbSnapshot *gorocksdb.Snapshot) (*StateSnapshot, error) {
-	return newStateSnapshot(blockNumber, dbSnapshot)
+func (state *State) GetSnapshot(blockNumber uint64, dbSnapshot db.Snapshot) (*StateSnapshot, error) {
+	return newStateSnapshot(state.stateImpl, blockNumber, dbSnapshot)
+}
+
+// GetStateProof returns the proof of the committed value of the key, or, depending on the state
+// implementation, either a proof of non-inclusion or nil if the key does not exist
+func (state *State) GetStateProof(chaincodeID string, key string) (*verify.StateProof, error) {
+	provableState, ok := state.stateImpl.(statemgmt.ProvableState)
+	if !ok {
+		return nil, fmt.Errorf("State implementation [%s] does not support state proofs", stateImplName)
+	}
+	return provableState.GetStateProof(chaincodeID, key)
 }
 
 // FetchStateDeltaFromDB fetches the StateDelta corrsponding to given blockNumber
 func (state *State) FetchStateDeltaFromDB(blockNumber uint64) (*statemgmt.StateDelta, error) {
-	stateDeltaBytes, err := db.GetDBHandle().GetFromStateDeltaCF(encodeStateDeltaKey(blockNumber))
+	stateDeltaBytes, err := state.db.GetFromStateDeltaCF(encodeStateDeltaKey(blockNumber))
 	if err != nil {
 		return nil, err
 	}
@@ -275,21 +429,26 @@ func (state *State) FetchStateDeltaFromDB(blockNumber uint64) (*statemgmt.StateD
 		return nil, nil
 	}
 	stateDelta := statemgmt.NewStateDelta()
-	stateDelta.Unmarshal(stateDeltaBytes)
+	stateDelta.Unmarshal(util.Decompress(stateDeltaBytes))
 	return stateDelta, nil
 }
 
 // AddChangesForPersistence adds key-value pairs to writeBatch
-func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gorocksdb.WriteBatch) {
+func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch db.WriteBatch) {
 	logger.Debug("state.addChangesForPersistence()...start")
 	if state.updateStateImpl {
 		state.stateImpl.PrepareWorkingSet(state.stateDelta)
 		state.updateStateImpl = false
 	}
 	state.stateImpl.AddChangesForPersistence(writeBatch)
+	state.addPrivateChangesForPersistence(writeBatch)
 
-	serializedStateDelta := state.stateDelta.Marshal()
-	cf := db.GetDBHandle().StateDeltaCF
+	serializedStateDelta, err := util.Compress(compression, state.stateDelta.Marshal())
+	if err != nil {
+		// only unknown compressions fail, which loadConfig rejects
+		panic(err)
+	}
+	cf := state.db.StateDeltaCF
 	logger.Debugf("Adding state-delta corresponding to block number[%d]", blockNumber)
 	writeBatch.PutCF(cf, encodeStateDeltaKey(blockNumber), serializedStateDelta)
 	if blockNumber >= state.historyStateDeltaSize {
@@ -306,7 +465,12 @@ func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gor
 // ApplyStateDelta applies already prepared stateDelta to the existing state.
 // This is an in memory change only. state.CommitStateDelta must be used to
 // commit the state to the DB. This method is to be used in state transfer.
+// Deltas applied again before committing are merged with the earlier ones.
 func (state *State) ApplyStateDelta(delta *statemgmt.StateDelta) {
+	if state.updateStateImpl {
+		state.stateDelta.ApplyChanges(delta)
+		return
+	}
 	state.stateDelta = delta
 	state.updateStateImpl = true
 }
@@ -314,17 +478,21 @@ func (state *State) ApplyStateDelta(delta *statemgmt.StateDelta) {
 // CommitStateDelta commits the changes from state.ApplyStateDelta to the
 // DB.
 func (state *State) CommitStateDelta() error {
+	writeBatch := state.db.NewWriteBatch()
+	defer writeBatch.Destroy()
+	return state.CommitStateDeltaWithBatch(writeBatch)
+}
+
+// CommitStateDeltaWithBatch commits the changes from state.ApplyStateDelta to
+// the DB together with the changes already added to writeBatch
+func (state *State) CommitStateDeltaWithBatch(writeBatch db.WriteBatch) error {
 	if state.updateStateImpl {
 		state.stateImpl.PrepareWorkingSet(state.stateDelta)
 		state.updateStateImpl = false
 	}
 
-	writeBatch := gorocksdb.NewWriteBatch()
-	defer writeBatch.Destroy()
 	state.stateImpl.AddChangesForPersistence(writeBatch)
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	return db.GetDBHandle().DB.Write(opt, writeBatch)
+	return state.db.Write(writeBatch)
 }
 
 // DeleteState deletes ALL state keys/values from the DB. This is generally
@@ -332,13 +500,34 @@ func (state *State) CommitStateDelta() error {
 // a snapshot.
 func (state *State) DeleteState() error {
 	state.ClearInMemoryChanges(false)
-	err := db.GetDBHandle().DeleteState()
+	err := state.db.DeleteState()
 	if err != nil {
 		logger.Errorf("Error deleting state: %s", err)
 	}
 	return err
 }
 
+// RehashBucketTree rebuilds the world state, which must be kept in a bucket
+// tree, for the given numBuckets and maxGroupingAtEachLevel and returns the
+// new state hash. The other configurations of the bucket tree are kept.
+// scratchDir is a directory which does not exist yet, used while rebuilding.
+func (state *State) RehashBucketTree(numBuckets int, maxGroupingAtEachLevel int, scratchDir string) ([]byte, error) {
+	bucketTree, ok := state.stateImpl.(*buckettree.StateImpl)
+	if !ok {
+		return nil, fmt.Errorf("The state data structure is '%s', only a 'buckettree' can be rehashed", stateImplName)
+	}
+	if state.txInProgress() || !state.stateDelta.IsEmpty() {
+		return nil, fmt.Errorf("Cannot rehash the state while it has uncommitted changes")
+	}
+	configs := make(map[string]interface{})
+	for name, value := range stateImplConfigs {
+		configs[name] = value
+	}
+	configs[buckettree.ConfigNumBuckets] = numBuckets
+	configs[buckettree.ConfigMaxGroupingAtEachLevel] = maxGroupingAtEachLevel
+	return bucketTree.Rehash(configs, scratchDir)
+}
+
 func encodeStateDeltaKey(blockNumber uint64) []byte {
 	return encodeUint64(blockNumber)
 }
@@ -19,7 +19,10 @@ package state
 import (
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/util"
 )
 
 func TestStateChanges(t *testing.T) {
@@ -122,6 +125,48 @@ func TestStateTxWrongCallCausePanic_3(t *testing.T) {
 	state.TxFinish("anotherUuid", true)
 }
 
+func TestStateNestedTxBehavior(t *testing.T) {
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	state.TxBegin("txUuid")
+	state.Set("chaincode1", "key1", []byte("value1"))
+
+	// a successful nested invocation sees and extends the changes of the tx
+	state.NestedTxBegin("txUuid")
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", false), []byte("value1"))
+	state.Set("chaincode2", "key2", []byte("value2"))
+	state.NestedTxFinish("txUuid", true)
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode2", "key2", false), []byte("value2"))
+
+	// the changes of a failed nested invocation, including the ones of its own nested invocations, are discarded
+	state.NestedTxBegin("txUuid")
+	state.Set("chaincode1", "key1", []byte("value1_new"))
+	state.NestedTxBegin("txUuid")
+	state.Get("chaincode3", "key3", false)
+	state.Set("chaincode3", "key3", []byte("value3"))
+	state.NestedTxFinish("txUuid", true)
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode3", "key3", false), []byte("value3"))
+	state.NestedTxFinish("txUuid", false)
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", false), []byte("value1"))
+	testutil.AssertNil(t, stateTestWrapper.get("chaincode3", "key3", false))
+	state.TxFinish("txUuid", true)
+
+	rwSet := state.GetTxReadWriteSets()["txUuid"]
+	testutil.AssertEquals(t, rwSet.Reads, []*statemgmt.KeyRead{
+		{ChaincodeID: "chaincode1", Key: "key1"},
+		{ChaincodeID: "chaincode2", Key: "key2"},
+		{ChaincodeID: "chaincode3", Key: "key3"}})
+	testutil.AssertEquals(t, rwSet.Writes.Get("chaincode1", "key1").GetValue(), []byte("value1"))
+	testutil.AssertEquals(t, rwSet.Writes.Get("chaincode2", "key2").GetValue(), []byte("value2"))
+	testutil.AssertNil(t, rwSet.Writes.Get("chaincode3", "key3"))
+}
+
+func TestStateNestedTxWrongCallCausePanic(t *testing.T) {
+	_, state := createFreshDBAndConstructState(t)
+	defer testutil.AssertPanic(t, "A panic should occur when a nested-tx-finish is invoked with out calling a nested-tx-begin")
+	state.TxBegin("txUuid")
+	state.NestedTxFinish("txUuid", true)
+}
+
 func TestDeleteState(t *testing.T) {
 
 	stateTestWrapper, state := createFreshDBAndConstructState(t)
@@ -169,3 +214,85 @@ func TestStateDeltaSizeSetting(t *testing.T) {
 		t.Fatalf("Error reading historyStateDeltaSize. Expected 500, but got %d", state.historyStateDeltaSize)
 	}
 }
+
+func TestStateDeltaCompression(t *testing.T) {
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	defer func() { compression = util.CompressionNone }()
+	for blockNumber, c := range []util.Compression{util.CompressionNone, util.CompressionZlib} {
+		compression = c
+		state.TxBegin("txUuid")
+		state.Set("chaincode1", "key1", []byte{'v', byte('0' + blockNumber)})
+		state.TxFinish("txUuid", true)
+		stateTestWrapper.persistAndClearInMemoryChanges(uint64(blockNumber))
+	}
+
+	uncompressedBytes, _ := db.GetDBHandle().GetFromStateDeltaCF(encodeStateDeltaKey(0))
+	compressedBytes, _ := db.GetDBHandle().GetFromStateDeltaCF(encodeStateDeltaKey(1))
+	delta0, err := state.FetchStateDeltaFromDB(0)
+	testutil.AssertNoError(t, err, "Error fetching state delta 0")
+	delta1, err := state.FetchStateDeltaFromDB(1)
+	testutil.AssertNoError(t, err, "Error fetching state delta 1")
+	testutil.AssertEquals(t, uncompressedBytes, delta0.Marshal())
+	testutil.AssertNotEquals(t, compressedBytes, delta1.Marshal())
+	testutil.AssertEquals(t, delta0.Get("chaincode1", "key1").GetValue(), []byte("v0"))
+	testutil.AssertEquals(t, delta1.Get("chaincode1", "key1").GetValue(), []byte("v1"))
+}
+
+func TestStateGetAt(t *testing.T) {
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	state.historyStateDeltaSize = 2
+	for blockNumber, value := range [][]byte{[]byte("value0"), []byte("value1"), nil, []byte("value3")} {
+		state.TxBegin("txUuid")
+		if value == nil {
+			state.Delete("chaincode1", "key1")
+		} else {
+			state.Set("chaincode1", "key1", value)
+		}
+		state.TxFinish("txUuid", true)
+		stateTestWrapper.persistAndClearInMemoryChanges(uint64(blockNumber))
+	}
+
+	value, err := state.GetAt("chaincode1", "key1", 3, 3)
+	testutil.AssertNoError(t, err, "Error getting state at block 3")
+	testutil.AssertEquals(t, value, []byte("value3"))
+	value, err = state.GetAt("chaincode1", "key1", 2, 3)
+	testutil.AssertNoError(t, err, "Error getting state at block 2")
+	testutil.AssertNil(t, value)
+	value, err = state.GetAt("chaincode1", "key1", 1, 3)
+	testutil.AssertNoError(t, err, "Error getting state at block 1")
+	testutil.AssertEquals(t, value, []byte("value1"))
+
+	// the state delta of block 1 is outside of the history
+	_, err = state.GetAt("chaincode1", "key1", 0, 3)
+	testutil.AssertError(t, err, "Expected error getting state before the state delta history")
+}
+
+func TestStateTxReadWriteSets(t *testing.T) {
+	_, state := createFreshDBAndConstructState(t)
+	state.TxBegin("txUuid1")
+	state.Get("chaincode1", "key1", false)
+	state.Get("chaincode1", "key1", false)
+	state.Set("chaincode1", "key2", []byte("value2"))
+	itr, _ := state.GetRangeScanIterator("chaincode1", "key1", "key3", false)
+	itr.Close()
+	state.TxFinish("txUuid1", true)
+
+	state.TxBegin("txUuid2")
+	state.Set("chaincode1", "key3", []byte("value3"))
+	state.TxFinish("txUuid2", false)
+
+	rwSets := state.GetTxReadWriteSets()
+	testutil.AssertEquals(t, len(rwSets), 1)
+	rwSet := rwSets["txUuid1"]
+	testutil.AssertEquals(t, rwSet.Reads, []*statemgmt.KeyRead{{ChaincodeID: "chaincode1", Key: "key1"}})
+	testutil.AssertEquals(t, rwSet.RangeReads, []*statemgmt.RangeRead{{ChaincodeID: "chaincode1", StartKey: "key1", EndKey: "key3"}})
+	testutil.AssertEquals(t, rwSet.Writes.Get("chaincode1", "key2").GetValue(), []byte("value2"))
+
+	unmarshaledRWSet := statemgmt.NewTxReadWriteSet()
+	err := unmarshaledRWSet.Unmarshal(rwSet.Marshal())
+	testutil.AssertNoError(t, err, "Error unmarshaling read/write set")
+	testutil.AssertEquals(t, unmarshaledRWSet, rwSet)
+
+	state.ClearInMemoryChanges(false)
+	testutil.AssertEquals(t, len(state.GetTxReadWriteSets()), 0)
+}
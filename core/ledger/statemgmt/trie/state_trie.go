@@ -21,8 +21,8 @@ import (
 
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var stateTrieLogger = logging.MustGetLogger("stateTrie")
@@ -35,16 +35,17 @@ type StateTrie struct {
 	persistedStateHash     []byte
 	lastComputedCryptoHash []byte
 	recomputeCryptoHash    bool
+	db                     *db.OpenchainDB
 }
 
-// NewStateTrie contructs a new empty StateTrie
-func NewStateTrie() *StateTrie {
-	return &StateTrie{}
+// NewStateTrie contructs a new empty StateTrie keeping the state in the given DB
+func NewStateTrie(openchainDB *db.OpenchainDB) *StateTrie {
+	return &StateTrie{db: openchainDB}
 }
 
 // Initialize the state trie with the root key
 func (stateTrie *StateTrie) Initialize(configs map[string]interface{}) error {
-	rootNode, err := fetchTrieNodeFromDB(rootTrieKey)
+	rootNode, err := fetchTrieNodeFromDB(stateTrie.db, rootTrieKey)
 	if err != nil {
 		panic(fmt.Errorf("Error in fetching root node from DB while initializing state trie: %s", err))
 	}
@@ -57,7 +58,7 @@ func (stateTrie *StateTrie) Initialize(configs map[string]interface{}) error {
 
 // Get the value for a given chaincode ID and key
 func (stateTrie *StateTrie) Get(chaincodeID string, key string) ([]byte, error) {
-	trieNode, err := fetchTrieNodeFromDB(newTrieKey(chaincodeID, key))
+	trieNode, err := fetchTrieNodeFromDB(stateTrie.db, newTrieKey(chaincodeID, key))
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +118,7 @@ func (stateTrie *StateTrie) ComputeCryptoHash() ([]byte, error) {
 
 func (stateTrie *StateTrie) processChangedNode(changedNode *trieNode) error {
 	stateTrieLogger.Debugf("Enter - processChangedNode() for node [%s]", changedNode)
-	dbNode, err := fetchTrieNodeFromDB(changedNode.trieKey)
+	dbNode, err := fetchTrieNodeFromDB(stateTrie.db, changedNode.trieKey)
 	if err != nil {
 		return err
 	}
@@ -141,7 +142,7 @@ func (stateTrie *StateTrie) processChangedNode(changedNode *trieNode) error {
 }
 
 // AddChangesForPersistence commits current changes to the database
-func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch db.WriteBatch) error {
 	if stateTrie.recomputeCryptoHash {
 		_, err := stateTrie.ComputeCryptoHash()
 		if err != nil {
@@ -154,7 +155,7 @@ func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.Write
 		return nil
 	}
 
-	openchainDB := db.GetDBHandle()
+	openchainDB := stateTrie.db
 	lowestLevel := stateTrie.trieDelta.getLowestLevel()
 	for level := lowestLevel; level >= 0; level-- {
 		changedNodes := stateTrie.trieDelta.deltaMap[level]
@@ -180,11 +181,43 @@ func (stateTrie *StateTrie) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
-	return newStateSnapshotIterator(snapshot)
+func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+	return newStateSnapshotIterator(stateTrie.db, snapshot)
 }
 
 // GetRangeScanIterator returns an iterator for performing a range scan between the start and end keys
 func (stateTrie *StateTrie) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
-	return newRangeScanIterator(chaincodeID, startKey, endKey)
+	return newRangeScanIterator(stateTrie.db, chaincodeID, startKey, endKey)
+}
+
+// GetStateProof returns the proof of the committed value of a key, or nil if the key does not exist
+func (stateTrie *StateTrie) GetStateProof(chaincodeID string, key string) (*verify.StateProof, error) {
+	trieKey := newTrieKey(chaincodeID, key)
+	trieNode, err := fetchTrieNodeFromDB(stateTrie.db, trieKey)
+	if err != nil {
+		return nil, err
+	}
+	if trieNode == nil || trieNode.value == nil {
+		return nil, nil
+	}
+	proof := &verify.StateProof{Type: verify.ProofTypeTrie, ChaincodeID: chaincodeID, Key: key, Value: trieNode.value}
+	proof.NodeChildren = trieNode.getChildHashes(-1)
+
+	for childKey := trieKey; !childKey.isRootKey(); childKey = childKey.getParentTrieKey() {
+		parentKey := childKey.getParentTrieKey()
+		parentNode, err := fetchTrieNodeFromDB(stateTrie.db, parentKey)
+		if err != nil {
+			return nil, err
+		}
+		if parentNode == nil {
+			return nil, fmt.Errorf("Trie node [%s] on the path of key [%s] of chaincode [%s] is missing from the DB", parentKey, key, chaincodeID)
+		}
+		proofNode := &verify.ProofNode{ChildIndex: childKey.getIndexInParent()}
+		if parentNode.containsValue() {
+			proofNode.Content = parentNode.valueCryptoHashContent()
+		}
+		proofNode.Siblings = parentNode.getChildHashes(proofNode.ChildIndex)
+		proof.Path = append(proof.Path, proofNode)
+	}
+	return proof, nil
 }
@@ -19,13 +19,15 @@ package trie
 import (
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 )
 
 func TestStateTrie_ComputeHash_AllInMemory_NoContents(t *testing.T) {
 	testDBWrapper.CreateFreshDB(t)
-	stateTrie := NewStateTrie()
+	stateTrie := NewStateTrie(db.GetDBHandle())
 	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
 	hash := stateTrieTestWrapper.PrepareWorkingSetAndComputeCryptoHash(statemgmt.NewStateDelta())
 	testutil.AssertEquals(t, hash, nil)
@@ -33,7 +35,7 @@ func TestStateTrie_ComputeHash_AllInMemory_NoContents(t *testing.T) {
 
 func TestStateTrie_ComputeHash_AllInMemory(t *testing.T) {
 	testDBWrapper.CreateFreshDB(t)
-	stateTrie := NewStateTrie()
+	stateTrie := NewStateTrie(db.GetDBHandle())
 	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
 	stateDelta := statemgmt.NewStateDelta()
 
@@ -76,7 +78,7 @@ func TestStateTrie_ComputeHash_AllInMemory(t *testing.T) {
 
 func TestStateTrie_GetSet_WithDB(t *testing.T) {
 	testDBWrapper.CreateFreshDB(t)
-	stateTrie := NewStateTrie()
+	stateTrie := NewStateTrie(db.GetDBHandle())
 	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
 	stateDelta := statemgmt.NewStateDelta()
 	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
@@ -100,7 +102,7 @@ func TestStateTrie_GetSet_WithDB(t *testing.T) {
 
 func TestStateTrie_ComputeHash_WithDB_Spread_Keys(t *testing.T) {
 	testDBWrapper.CreateFreshDB(t)
-	stateTrie := NewStateTrie()
+	stateTrie := NewStateTrie(db.GetDBHandle())
 	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
 
 	// Add a few keys and write to DB
@@ -180,7 +182,7 @@ func TestStateTrie_ComputeHash_WithDB_Spread_Keys(t *testing.T) {
 
 func TestStateTrie_ComputeHash_WithDB_Staggered_Keys(t *testing.T) {
 	testDBWrapper.CreateFreshDB(t)
-	stateTrie := NewStateTrie()
+	stateTrie := NewStateTrie(db.GetDBHandle())
 	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
 
 	/////////////////////////////////////////////////////////
@@ -244,3 +246,35 @@ func TestStateTrie_ComputeHash_WithDB_Staggered_Keys(t *testing.T) {
 	rootHash5 := stateTrieTestWrapper.PrepareWorkingSetAndComputeCryptoHash(stateDelta)
 	testutil.AssertEquals(t, rootHash5, expectedHashK)
 }
+
+func TestStateTrie_GetStateProof(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	stateTrie := NewStateTrie(db.GetDBHandle())
+	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("ID", "key1", []byte("value_key1"), nil)
+	stateDelta.Set("ID", "key2", []byte("value_key2"), nil)
+	stateDelta.Set("ID", "k", []byte("value_k"), nil)
+	stateDelta.Set("ID2", "key", []byte{}, nil)
+	rootHash := stateTrieTestWrapper.PrepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateTrieTestWrapper.PersistChangesAndResetInMemoryChanges()
+
+	for _, key := range []string{"key1", "key2", "k"} {
+		proof, err := stateTrie.GetStateProof("ID", key)
+		testutil.AssertNoError(t, err, "Error getting state proof")
+		testutil.AssertEquals(t, proof.Value, []byte("value_"+key))
+		testutil.AssertNoError(t, verify.VerifyStateProof(proof, rootHash), "Error verifying state proof")
+	}
+	proof, err := stateTrie.GetStateProof("ID2", "key")
+	testutil.AssertNoError(t, err, "Error getting state proof")
+	testutil.AssertNoError(t, verify.VerifyStateProof(proof, rootHash), "Error verifying state proof")
+
+	proof, _ = stateTrie.GetStateProof("ID", "key1")
+	proof.Value = []byte("value_key2")
+	testutil.AssertError(t, verify.VerifyStateProof(proof, rootHash), "Expected error verifying a proof with a changed value")
+
+	// "ke" is an intermediate node without a value
+	proof, err = stateTrie.GetStateProof("ID", "ke")
+	testutil.AssertNoError(t, err, "Error getting state proof")
+	testutil.AssertNil(t, proof)
+}
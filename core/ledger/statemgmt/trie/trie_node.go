@@ -21,6 +21,7 @@ import (
 	"sort"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 	"github.com/hyperledger/fabric/core/util"
 )
 
@@ -93,10 +94,7 @@ func (trieNode *trieNode) computeCryptoHash() []byte {
 	var cryptoHashContent []byte
 	if trieNode.containsValue() {
 		stateTrieLogger.Debugf("Adding value to hash computation for trieNode [%s]", trieNode)
-		key := trieNode.trieKey.getEncodedBytes()
-		cryptoHashContent = append(cryptoHashContent, proto.EncodeVarint(uint64(len(key)))...)
-		cryptoHashContent = append(cryptoHashContent, key...)
-		cryptoHashContent = append(cryptoHashContent, trieNode.value...)
+		cryptoHashContent = trieNode.valueCryptoHashContent()
 	}
 
 	sortedChildrenIndexes := trieNode.getSortedChildrenIndex()
@@ -123,6 +121,25 @@ func (trieNode *trieNode) computeCryptoHash() []byte {
 	return util.ComputeCryptoHash(cryptoHashContent)
 }
 
+// valueCryptoHashContent returns the part of the content hashed for the node which covers its key and value
+func (trieNode *trieNode) valueCryptoHashContent() []byte {
+	key := trieNode.trieKey.getEncodedBytes()
+	cryptoHashContent := proto.EncodeVarint(uint64(len(key)))
+	cryptoHashContent = append(cryptoHashContent, key...)
+	return append(cryptoHashContent, trieNode.value...)
+}
+
+// getChildHashes returns the crypto-hashes of the children of the node, except the one at index skipIndex
+func (trieNode *trieNode) getChildHashes(skipIndex int) []*verify.ChildHash {
+	var childHashes []*verify.ChildHash
+	for _, index := range trieNode.getSortedChildrenIndex() {
+		if index != skipIndex {
+			childHashes = append(childHashes, &verify.ChildHash{Index: index, Hash: trieNode.childrenCryptoHashes[index]})
+		}
+	}
+	return childHashes
+}
+
 func (trieNode *trieNode) containsValue() bool {
 	if trieNode.isRootNode() {
 		return false
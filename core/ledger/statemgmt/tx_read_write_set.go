@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// TxReadWriteSet holds the keys read and the changes made by a transaction
+// during its execution
+type TxReadWriteSet struct {
+	Reads      []*KeyRead
+	RangeReads []*RangeRead
+	Writes     *StateDelta
+}
+
+// KeyRead is a key read by a transaction
+type KeyRead struct {
+	ChaincodeID string
+	Key         string
+}
+
+// RangeRead is a range of keys scanned by a transaction
+type RangeRead struct {
+	ChaincodeID string
+	StartKey    string
+	EndKey      string
+}
+
+// NewTxReadWriteSet constructs an empty TxReadWriteSet
+func NewTxReadWriteSet() *TxReadWriteSet {
+	return &TxReadWriteSet{nil, nil, NewStateDelta()}
+}
+
+// Marshal serializes the TxReadWriteSet
+func (rwSet *TxReadWriteSet) Marshal() []byte {
+	buffer := proto.NewBuffer([]byte{})
+	encodeVarint(buffer, uint64(len(rwSet.Reads)))
+	for _, read := range rwSet.Reads {
+		encodeStrings(buffer, read.ChaincodeID, read.Key)
+	}
+	encodeVarint(buffer, uint64(len(rwSet.RangeReads)))
+	for _, rangeRead := range rwSet.RangeReads {
+		encodeStrings(buffer, rangeRead.ChaincodeID, rangeRead.StartKey, rangeRead.EndKey)
+	}
+	err := buffer.EncodeRawBytes(rwSet.Writes.Marshal())
+	if err != nil {
+		panic(fmt.Errorf("This error should not occur: %s", err))
+	}
+	return buffer.Bytes()
+}
+
+// Unmarshal deserializes TxReadWriteSet
+func (rwSet *TxReadWriteSet) Unmarshal(bytes []byte) error {
+	buffer := proto.NewBuffer(bytes)
+	size, err := buffer.DecodeVarint()
+	if err != nil {
+		return fmt.Errorf("Error unmarshaling reads: %s", err)
+	}
+	rwSet.Reads = make([]*KeyRead, size)
+	for i := range rwSet.Reads {
+		values, err := decodeStrings(buffer, 2)
+		if err != nil {
+			return fmt.Errorf("Error unmarshaling reads: %s", err)
+		}
+		rwSet.Reads[i] = &KeyRead{values[0], values[1]}
+	}
+	size, err = buffer.DecodeVarint()
+	if err != nil {
+		return fmt.Errorf("Error unmarshaling range reads: %s", err)
+	}
+	rwSet.RangeReads = make([]*RangeRead, size)
+	for i := range rwSet.RangeReads {
+		values, err := decodeStrings(buffer, 3)
+		if err != nil {
+			return fmt.Errorf("Error unmarshaling range reads: %s", err)
+		}
+		rwSet.RangeReads[i] = &RangeRead{values[0], values[1], values[2]}
+	}
+	writesBytes, err := buffer.DecodeRawBytes(false)
+	if err != nil {
+		return fmt.Errorf("Error unmarshaling writes: %s", err)
+	}
+	rwSet.Writes = NewStateDelta()
+	return rwSet.Writes.Unmarshal(writesBytes)
+}
+
+func encodeVarint(buffer *proto.Buffer, x uint64) {
+	err := buffer.EncodeVarint(x)
+	if err != nil {
+		panic(fmt.Errorf("This error should not occur: %s", err))
+	}
+}
+
+func encodeStrings(buffer *proto.Buffer, values ...string) {
+	for _, value := range values {
+		err := buffer.EncodeStringBytes(value)
+		if err != nil {
+			panic(fmt.Errorf("This error should not occur: %s", err))
+		}
+	}
+}
+
+func decodeStrings(buffer *proto.Buffer, count int) ([]string, error) {
+	values := make([]string, count)
+	for i := range values {
+		value, err := buffer.DecodeStringBytes()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/hyperledger/fabric/core/tracing"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// startTxSpans starts a span of the name for each traced transaction
+func startTxSpans(name string, transactions []*protos.Transaction) []*tracing.Span {
+	var spans []*tracing.Span
+	for _, tx := range transactions {
+		if span := tracing.StartChildSpan(name, tx.TraceContext); span != nil {
+			span.SetTag("txid", tx.Uuid)
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// withoutTraceContext returns the transactions without their trace context,
+// for the blocks not to depend on which transactions are traced. The traced
+// transactions are copied rather than changed, as consensus holds them.
+func withoutTraceContext(transactions []*protos.Transaction) []*protos.Transaction {
+	var stripped []*protos.Transaction
+	for i, tx := range transactions {
+		if tx.TraceContext == nil {
+			if stripped != nil {
+				stripped[i] = tx
+			}
+			continue
+		}
+		if stripped == nil {
+			stripped = make([]*protos.Transaction, len(transactions))
+			copy(stripped, transactions[:i])
+		}
+		untraced := *tx
+		untraced.TraceContext = nil
+		stripped[i] = &untraced
+	}
+	if stripped == nil {
+		return transactions
+	}
+	return stripped
+}
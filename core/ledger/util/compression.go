@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/viper"
+)
+
+// Compression identifies the algorithm a value is compressed with before it
+// is written to the DB. The value is recorded in the format header of
+// compressed values, so it must never change.
+type Compression byte
+
+const (
+	// CompressionNone writes values as they are, without a format header
+	CompressionNone Compression = 0
+	// CompressionZlib compresses values with zlib
+	CompressionZlib Compression = 1
+)
+
+// compressionMagic starts every compressed value and is followed by the
+// Compression used. It is an invalid protobuf tag (wire type 6), so
+// compressed blocks cannot be confused with uncompressed ones.
+const compressionMagic byte = 0xfe
+
+var compressionNames = map[string]Compression{
+	"none": CompressionNone,
+	"zlib": CompressionZlib,
+}
+
+// CompressionFromConfig returns the compression configured in ledger.compression,
+// which defaults to none
+func CompressionFromConfig() (Compression, error) {
+	name := viper.GetString("ledger.compression")
+	if name == "" {
+		return CompressionNone, nil
+	}
+	compression, ok := compressionNames[name]
+	if !ok {
+		return CompressionNone, fmt.Errorf("Unknown ledger compression '%s', options are 'none' and 'zlib'", name)
+	}
+	return compression, nil
+}
+
+// Compress compresses data with the given compression and prefixes it with the
+// format header. Data is returned unchanged for CompressionNone.
+func Compress(compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionZlib:
+		var buf bytes.Buffer
+		buf.Write([]byte{compressionMagic, byte(compression)})
+		writer := zlib.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("Error compressing with zlib: %s", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("Error compressing with zlib: %s", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("Unknown compression %d", compression)
+	}
+}
+
+// Decompress returns the original data of a value written by Compress. Values
+// without the format header are returned unchanged. A serialized state delta
+// with exactly 254 chaincodes starts with bytes which look like the header;
+// such values fail to decompress and are returned unchanged as well, so that
+// genuinely corrupted values are reported by the caller when unmarshalling.
+func Decompress(data []byte) []byte {
+	if len(data) < 2 || data[0] != compressionMagic {
+		return data
+	}
+	switch Compression(data[1]) {
+	case CompressionZlib:
+		reader, err := zlib.NewReader(bytes.NewReader(data[2:]))
+		if err != nil {
+			return data
+		}
+		defer reader.Close()
+		decompressed, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return data
+		}
+		return decompressed
+	default:
+		return data
+	}
+}
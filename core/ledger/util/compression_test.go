@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	data := bytes.Repeat([]byte("some state delta content "), 100)
+	for _, compression := range []Compression{CompressionNone, CompressionZlib} {
+		compressed, err := Compress(compression, data)
+		if err != nil {
+			t.Fatalf("Error compressing with compression %d: %s", compression, err)
+		}
+		if compression == CompressionZlib && len(compressed) >= len(data) {
+			t.Fatalf("Expected zlib to shrink repetitive data of size %d, got size %d", len(data), len(compressed))
+		}
+		if decompressed := Decompress(compressed); !bytes.Equal(decompressed, data) {
+			t.Fatalf("Data not same after decompressing with compression %d", compression)
+		}
+	}
+	if _, err := Compress(Compression(99), data); err == nil {
+		t.Fatal("Expected error compressing with an unknown compression")
+	}
+}
+
+func TestDecompressLegacyValues(t *testing.T) {
+	// a state delta with 254 chaincodes serializes to bytes starting like the format header
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 254; i++ {
+		stateDelta.Set(fmt.Sprintf("chaincode%d", i), "key", []byte("value"), nil)
+	}
+	legacyValues := [][]byte{nil, {}, []byte("legacy"), {compressionMagic}, stateDelta.Marshal()}
+	if serialized := legacyValues[4]; serialized[0] != compressionMagic || Compression(serialized[1]) != CompressionZlib {
+		t.Fatalf("Expected the serialized state delta to start like the format header, got %x", serialized[:2])
+	}
+	for _, value := range legacyValues {
+		if decompressed := Decompress(value); !bytes.Equal(decompressed, value) {
+			t.Fatalf("Expected value [%x] without format header to be returned unchanged, got [%x]", value, decompressed)
+		}
+	}
+}
+
+func TestCompressionFromConfig(t *testing.T) {
+	compression, err := CompressionFromConfig()
+	if err != nil || compression != CompressionNone {
+		t.Fatalf("Expected compression none when not configured, got %d, err %v", compression, err)
+	}
+}
+
+func BenchmarkCompressZlib(b *testing.B) {
+	data := benchmarkStateDelta(b)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Compress(CompressionZlib, data)
+	}
+}
+
+func BenchmarkDecompressZlib(b *testing.B) {
+	data := benchmarkStateDelta(b)
+	compressed, _ := Compress(CompressionZlib, data)
+	b.Logf("Compressed state delta of size %d to size %d", len(data), len(compressed))
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Decompress(compressed)
+	}
+}
+
+func benchmarkStateDelta(b *testing.B) []byte {
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 1000; i++ {
+		stateDelta.Set("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf(`{"owner":"user%d","amount":%d}`, i%10, i)), nil)
+	}
+	return stateDelta.Marshal()
+}
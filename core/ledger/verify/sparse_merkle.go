@@ -0,0 +1,106 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/util"
+)
+
+// SparseMerkleDepth is the number of bits of the key hashes, which address
+// the leaves of a sparse Merkle trie
+const SparseMerkleDepth = 256
+
+// SparseMerkleKeyHash returns the hash of a key, whose bits from the most
+// significant one give the path from the root to the leaf of the key
+func SparseMerkleKeyHash(chaincodeID string, key string) []byte {
+	return util.ComputeCryptoHash(compositeKey(chaincodeID, key))[:SparseMerkleDepth/8]
+}
+
+// SparseMerkleValueHash returns the hash of a value kept in a leaf
+func SparseMerkleValueHash(value []byte) []byte {
+	return util.ComputeCryptoHash(value)
+}
+
+// SparseMerkleLeafHash returns the hash of the leaf of a key. A subtree
+// holding a single key is replaced by the leaf of the key.
+func SparseMerkleLeafHash(keyHash []byte, valueHash []byte) []byte {
+	return util.ComputeCryptoHash(append(append([]byte{0}, keyHash...), valueHash...))
+}
+
+// SparseMerkleNodeHash returns the hash of a node with the given child
+// hashes, which are empty for empty subtrees
+func SparseMerkleNodeHash(left []byte, right []byte) []byte {
+	content := append([]byte{1}, sparseMerkleChildHash(left)...)
+	return util.ComputeCryptoHash(append(content, sparseMerkleChildHash(right)...))
+}
+
+// SparseMerkleBit returns the bit of the key hash at the given depth, which
+// is 1 if the path to the leaf of the key continues with the right child
+func SparseMerkleBit(keyHash []byte, depth int) byte {
+	return (keyHash[depth/8] >> uint(7-depth%8)) & 1
+}
+
+var sparseMerkleEmptyHash = make([]byte, len(util.ComputeCryptoHash(nil)))
+
+func sparseMerkleChildHash(hash []byte) []byte {
+	if len(hash) == 0 {
+		return sparseMerkleEmptyHash
+	}
+	return hash
+}
+
+// computeSparseMerkleRootHash computes the root hash of the trie from the leaf
+// found on the path to the key, or from an empty subtree, and the siblings
+func computeSparseMerkleRootHash(proof *StateProof) ([]byte, error) {
+	keyHash := SparseMerkleKeyHash(proof.ChaincodeID, proof.Key)
+	depth := len(proof.MerkleSiblings)
+	if depth > SparseMerkleDepth {
+		return nil, fmt.Errorf("Proof has %d siblings, more than the depth of the trie", depth)
+	}
+	var cryptoHash []byte
+	switch {
+	case !proof.NonInclusion:
+		if proof.OtherLeaf != nil {
+			return nil, fmt.Errorf("Proof of inclusion of key [%s] of chaincode [%s] has the leaf of another key", proof.Key, proof.ChaincodeID)
+		}
+		cryptoHash = SparseMerkleLeafHash(keyHash, SparseMerkleValueHash(proof.Value))
+	case proof.Value != nil:
+		return nil, fmt.Errorf("Proof of non-inclusion of key [%s] of chaincode [%s] has a value", proof.Key, proof.ChaincodeID)
+	case proof.OtherLeaf != nil:
+		otherKeyHash := proof.OtherLeaf.KeyHash
+		if len(otherKeyHash) != len(keyHash) || bytes.Equal(otherKeyHash, keyHash) {
+			return nil, fmt.Errorf("Proof of non-inclusion of key [%s] of chaincode [%s] has an invalid leaf", proof.Key, proof.ChaincodeID)
+		}
+		for i := 0; i < depth; i++ {
+			if SparseMerkleBit(otherKeyHash, i) != SparseMerkleBit(keyHash, i) {
+				return nil, fmt.Errorf("Proof of non-inclusion of key [%s] of chaincode [%s] has a leaf off the path of the key", proof.Key, proof.ChaincodeID)
+			}
+		}
+		cryptoHash = SparseMerkleLeafHash(otherKeyHash, proof.OtherLeaf.ValueHash)
+	}
+	for i, sibling := range proof.MerkleSiblings {
+		if SparseMerkleBit(keyHash, depth-1-i) == 0 {
+			cryptoHash = SparseMerkleNodeHash(cryptoHash, sibling)
+		} else {
+			cryptoHash = SparseMerkleNodeHash(sibling, cryptoHash)
+		}
+	}
+	return cryptoHash, nil
+}
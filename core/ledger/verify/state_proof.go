@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package verify checks proofs of values in the world state without access
+// to the ledger of a peer. It only depends on the crypto-hash function, which
+// must be configured the same way as on the peers.
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/util"
+)
+
+// ProofType identifies the state implementation which built a StateProof
+type ProofType string
+
+const (
+	// ProofTypeBucketTree is a proof built by the 'buckettree' state implementation
+	ProofTypeBucketTree = ProofType("buckettree")
+	// ProofTypeTrie is a proof built by the 'trie' state implementation
+	ProofTypeTrie = ProofType("trie")
+	// ProofTypeSparseMerkle is a proof built by the 'sparsemerkle' state implementation
+	ProofTypeSparseMerkle = ProofType("sparsemerkle")
+)
+
+// StateProof proves the value of a key in the world state with a given
+// crypto-hash, as found in the StateHash of a block. The proof consists of
+// what is needed to compute the crypto-hash of the bucket or trie node holding
+// the key, and then of each of its ancestors up to the root of the tree.
+type StateProof struct {
+	Type        ProofType
+	ChaincodeID string
+	Key         string
+	Value       []byte
+
+	// BucketEntries are, for bucket tree proofs, all the key-values of the
+	// bucket containing the key, ordered by chaincodeID and key
+	BucketEntries []*KeyValue
+	// NodeChildren are, for trie proofs, the child hashes of the trie node of the key
+	NodeChildren []*ChildHash
+
+	// Path are the ancestors of the bucket or trie node of the key, from its
+	// parent up to the root
+	Path []*ProofNode
+
+	// NonInclusion is set on sparse Merkle trie proofs that the key does not
+	// exist, Value is nil then
+	NonInclusion bool
+	// MerkleSiblings are, for sparse Merkle trie proofs, the hashes of the
+	// siblings of the nodes on the path to the key, from the deepest node up to
+	// the children of the root. Empty subtrees have empty hashes.
+	MerkleSiblings [][]byte
+	// OtherLeaf is, for sparse Merkle trie proofs of non-inclusion, the leaf of
+	// another key found where the leaf of the key would be, if any
+	OtherLeaf *SparseMerkleLeaf
+}
+
+// ProofNode is an ancestor of the bucket or trie node of the key
+type ProofNode struct {
+	// Content is the part of the hashed content of the node preceding the
+	// child hashes. Only trie nodes holding a value have one.
+	Content []byte
+	// ChildIndex is the index of the child on the path to the key
+	ChildIndex int
+	// Siblings are the hashes of all the other children of the node
+	Siblings []*ChildHash
+}
+
+// ChildHash is the crypto-hash of the child of a node at a given index
+type ChildHash struct {
+	Index int
+	Hash  []byte
+}
+
+// SparseMerkleLeaf is a leaf of a sparse Merkle trie
+type SparseMerkleLeaf struct {
+	KeyHash   []byte
+	ValueHash []byte
+}
+
+// KeyValue is a key-value stored in a bucket
+type KeyValue struct {
+	ChaincodeID string
+	Key         string
+	Value       []byte
+}
+
+// VerifyStateProof returns an error unless the proof shows that the key had
+// the value of the proof in the state with the given crypto-hash
+func VerifyStateProof(proof *StateProof, stateHash []byte) error {
+	var cryptoHash []byte
+	var err error
+	switch proof.Type {
+	case ProofTypeBucketTree:
+		cryptoHash, err = computeBucketCryptoHash(proof)
+	case ProofTypeTrie:
+		cryptoHash, err = computeTrieNodeCryptoHash(proof)
+	case ProofTypeSparseMerkle:
+		cryptoHash, err = computeSparseMerkleRootHash(proof)
+	default:
+		err = fmt.Errorf("Unknown proof type [%s]", proof.Type)
+	}
+	if err != nil {
+		return err
+	}
+	for _, node := range proof.Path {
+		if cryptoHash, err = computeNodeCryptoHash(node, cryptoHash); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(cryptoHash, stateHash) {
+		return fmt.Errorf("Proof for key [%s] of chaincode [%s] leads to state hash [%x], expected [%x]",
+			proof.Key, proof.ChaincodeID, cryptoHash, stateHash)
+	}
+	return nil
+}
+
+// computeBucketCryptoHash computes the hash of the bucket the same way as the
+// bucket tree does, after checking that the value of the key is in the bucket
+func computeBucketCryptoHash(proof *StateProof) ([]byte, error) {
+	var hashingData []byte
+	appendSize := func(size int) {
+		hashingData = append(hashingData, proto.EncodeVarint(uint64(size))...)
+	}
+	appendSizeAndData := func(b []byte) {
+		appendSize(len(b))
+		hashingData = append(hashingData, b...)
+	}
+
+	found := false
+	entries := proof.BucketEntries
+	for i, entry := range entries {
+		if i > 0 && bytes.Compare(compositeKey(entries[i-1].ChaincodeID, entries[i-1].Key), compositeKey(entry.ChaincodeID, entry.Key)) >= 0 {
+			return nil, fmt.Errorf("Bucket entries of the proof are not in order at entry %d", i)
+		}
+		if entry.ChaincodeID == proof.ChaincodeID && entry.Key == proof.Key {
+			if !bytes.Equal(entry.Value, proof.Value) {
+				return nil, fmt.Errorf("Bucket of the proof holds a different value for key [%s] of chaincode [%s]", proof.Key, proof.ChaincodeID)
+			}
+			found = true
+		}
+		if i == 0 || entries[i-1].ChaincodeID != entry.ChaincodeID {
+			numKeys := 1
+			for _, next := range entries[i+1:] {
+				if next.ChaincodeID != entry.ChaincodeID {
+					break
+				}
+				numKeys++
+			}
+			appendSizeAndData([]byte(entry.ChaincodeID))
+			appendSize(numKeys)
+		}
+		appendSizeAndData([]byte(entry.Key))
+		appendSizeAndData(entry.Value)
+	}
+	if !found {
+		return nil, fmt.Errorf("Bucket of the proof does not hold key [%s] of chaincode [%s]", proof.Key, proof.ChaincodeID)
+	}
+	return util.ComputeCryptoHash(hashingData), nil
+}
+
+// computeTrieNodeCryptoHash computes the hash of the trie node of the key the
+// same way as the trie does. The trie does not delimit the value of a node from
+// the child hashes following it, so the value proven for a key which is a
+// prefix of other keys may have child hashes appended.
+func computeTrieNodeCryptoHash(proof *StateProof) ([]byte, error) {
+	node := &ProofNode{TrieNodeValueContent(proof.ChaincodeID, proof.Key, proof.Value), -1, proof.NodeChildren}
+	return computeNodeCryptoHash(node, nil)
+}
+
+// TrieNodeValueContent returns the part of the hashed content of a trie node
+// for the value of the key
+func TrieNodeValueContent(chaincodeID string, key string, value []byte) []byte {
+	trieKey := compositeKey(chaincodeID, key)
+	content := proto.EncodeVarint(uint64(len(trieKey)))
+	content = append(content, trieKey...)
+	return append(content, value...)
+}
+
+// computeNodeCryptoHash computes the hash of a node given the hash of its
+// child on the path, which is left out if the index of the child is negative.
+// A node without content and a single child has the hash of its child.
+func computeNodeCryptoHash(node *ProofNode, childCryptoHash []byte) ([]byte, error) {
+	children := append([]*ChildHash{}, node.Siblings...)
+	if node.ChildIndex >= 0 {
+		children = append(children, &ChildHash{node.ChildIndex, childCryptoHash})
+	}
+	sort.Sort(childHashes(children))
+	cryptoHashContent := append([]byte{}, node.Content...)
+	for i, child := range children {
+		if len(child.Hash) == 0 {
+			return nil, fmt.Errorf("Proof has an empty hash for child %d", child.Index)
+		}
+		if i > 0 && children[i-1].Index == child.Index {
+			return nil, fmt.Errorf("Proof has more than one hash for child %d", child.Index)
+		}
+		cryptoHashContent = append(cryptoHashContent, child.Hash...)
+	}
+	if len(cryptoHashContent) == 0 {
+		return nil, fmt.Errorf("Proof has a node without content and children")
+	}
+	if len(node.Content) == 0 && len(children) == 1 {
+		return children[0].Hash, nil
+	}
+	return util.ComputeCryptoHash(cryptoHashContent), nil
+}
+
+func compositeKey(chaincodeID string, key string) []byte {
+	return []byte(chaincodeID + "\x00" + key)
+}
+
+type childHashes []*ChildHash
+
+func (c childHashes) Len() int           { return len(c) }
+func (c childHashes) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c childHashes) Less(i, j int) bool { return c[i].Index < c[j].Index }
@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"testing"
+
+	"fmt"
+	"github.com/hyperledger/fabric/core/util"
+)
+
+func TestVerifyStateProof_Trie(t *testing.T) {
+	// a trie holding only the key "k" of chaincode "c" propagates the hash of its node up to the root
+	nodeHash := util.ComputeCryptoHash(TrieNodeValueContent("c", "k", []byte("v")))
+	proof := &StateProof{Type: ProofTypeTrie, ChaincodeID: "c", Key: "k", Value: []byte("v"),
+		Path: []*ProofNode{{ChildIndex: 'k'}, {ChildIndex: 0}, {ChildIndex: 'c'}}}
+	if err := VerifyStateProof(proof, nodeHash); err != nil {
+		t.Fatalf("Error verifying proof: %s", err)
+	}
+
+	// with a sibling, the hash of the node is combined with it
+	siblingHash := util.ComputeCryptoHash([]byte("sibling"))
+	proof.Path[2].Siblings = []*ChildHash{{'d', siblingHash}}
+	rootHash := util.ComputeCryptoHash(append(append([]byte{}, nodeHash...), siblingHash...))
+	if err := VerifyStateProof(proof, rootHash); err != nil {
+		t.Fatalf("Error verifying proof: %s", err)
+	}
+	if err := VerifyStateProof(proof, nodeHash); err == nil {
+		t.Fatal("Expected error verifying proof against the wrong hash")
+	}
+
+	proof.Path[2].Siblings = []*ChildHash{{'c', siblingHash}}
+	if err := VerifyStateProof(proof, rootHash); err == nil {
+		t.Fatal("Expected error verifying proof with two hashes for the same child")
+	}
+
+	proof.Type = ProofType("unknown")
+	if err := VerifyStateProof(proof, rootHash); err == nil {
+		t.Fatal("Expected error verifying proof of an unknown type")
+	}
+}
+
+func TestVerifyStateProof_BucketEntriesOrder(t *testing.T) {
+	proof := &StateProof{Type: ProofTypeBucketTree, ChaincodeID: "c", Key: "k1", Value: []byte("v1"),
+		BucketEntries: []*KeyValue{{"c", "k2", []byte("v2")}, {"c", "k1", []byte("v1")}}}
+	if _, err := computeBucketCryptoHash(proof); err == nil {
+		t.Fatal("Expected error for unordered bucket entries")
+	}
+
+	proof.BucketEntries = []*KeyValue{{"c", "k1", []byte("v1")}, {"c", "k2", []byte("v2")}, {"d", "k1", []byte("v3")}}
+	if _, err := computeBucketCryptoHash(proof); err != nil {
+		t.Fatalf("Error computing bucket hash: %s", err)
+	}
+	proof.Value = []byte("v3")
+	if _, err := computeBucketCryptoHash(proof); err == nil {
+		t.Fatal("Expected error for a value differing from the bucket entry")
+	}
+}
+
+func TestVerifyStateProof_SparseMerkle(t *testing.T) {
+	// a trie holding only the key "k1" of chaincode "c" is the leaf of the key
+	leaf := &SparseMerkleLeaf{SparseMerkleKeyHash("c", "k1"), SparseMerkleValueHash([]byte("v1"))}
+	rootHash := SparseMerkleLeafHash(leaf.KeyHash, leaf.ValueHash)
+	proof := &StateProof{Type: ProofTypeSparseMerkle, ChaincodeID: "c", Key: "k1", Value: []byte("v1")}
+	if err := VerifyStateProof(proof, rootHash); err != nil {
+		t.Fatalf("Error verifying proof: %s", err)
+	}
+
+	// the leaf of "k1" proves that "k2" does not exist
+	proof = &StateProof{Type: ProofTypeSparseMerkle, ChaincodeID: "c", Key: "k2", NonInclusion: true, OtherLeaf: leaf}
+	if err := VerifyStateProof(proof, rootHash); err != nil {
+		t.Fatalf("Error verifying proof of non-inclusion: %s", err)
+	}
+	proof.Key = "k1"
+	if err := VerifyStateProof(proof, rootHash); err == nil {
+		t.Fatal("Expected error verifying proof of non-inclusion with the leaf of the key itself")
+	}
+
+	// a key whose path leaves the path of "k1" at the root cannot be disproven by the leaf of "k1"
+	for i := 0; ; i++ {
+		proof.Key = fmt.Sprintf("k%d", i)
+		if SparseMerkleBit(SparseMerkleKeyHash("c", proof.Key), 0) != SparseMerkleBit(leaf.KeyHash, 0) {
+			break
+		}
+	}
+	proof.MerkleSiblings = [][]byte{{}}
+	if err := VerifyStateProof(proof, SparseMerkleNodeHash(rootHash, nil)); err == nil {
+		t.Fatal("Expected error verifying proof of non-inclusion with a leaf off the path of the key")
+	}
+
+	// the empty trie proves that no key exists
+	proof = &StateProof{Type: ProofTypeSparseMerkle, ChaincodeID: "c", Key: "k1", NonInclusion: true}
+	if err := VerifyStateProof(proof, nil); err != nil {
+		t.Fatalf("Error verifying proof of non-inclusion against the empty trie: %s", err)
+	}
+	proof.Value = []byte("v1")
+	if err := VerifyStateProof(proof, nil); err == nil {
+		t.Fatal("Expected error verifying proof of non-inclusion with a value")
+	}
+}
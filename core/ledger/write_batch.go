@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/hyperledger/fabric/core/db"
+)
+
+// bufferedWriteBatch collects changes in memory so that they can be prepared
+// concurrently with other changes of the same block and then added, in order,
+// to the write batch of the block. The write batches of the DB drivers are not
+// safe for concurrent use.
+type bufferedWriteBatch struct {
+	changes []*bufferedChange
+}
+
+type bufferedChange struct {
+	cf     db.ColumnFamily
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+func newBufferedWriteBatch() *bufferedWriteBatch {
+	return &bufferedWriteBatch{}
+}
+
+func (writeBatch *bufferedWriteBatch) PutCF(cf db.ColumnFamily, key []byte, value []byte) {
+	writeBatch.changes = append(writeBatch.changes, &bufferedChange{cf, key, value, false})
+}
+
+func (writeBatch *bufferedWriteBatch) DeleteCF(cf db.ColumnFamily, key []byte) {
+	writeBatch.changes = append(writeBatch.changes, &bufferedChange{cf, key, nil, true})
+}
+
+func (writeBatch *bufferedWriteBatch) Destroy() {
+	writeBatch.changes = nil
+}
+
+// addTo adds the buffered changes to another write batch in the order they were made
+func (writeBatch *bufferedWriteBatch) addTo(target db.WriteBatch) {
+	for _, change := range writeBatch.changes {
+		if change.delete {
+			target.DeleteCF(change.cf, change.key)
+		} else {
+			target.PutCF(change.cf, change.key, change.value)
+		}
+	}
+}
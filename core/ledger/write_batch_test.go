@@ -0,0 +1,44 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestBufferedWriteBatch(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	cf := db.GetDBHandle().StateCF
+	bufferedBatch := newBufferedWriteBatch()
+	bufferedBatch.PutCF(cf, []byte("key1"), []byte("value1"))
+	bufferedBatch.PutCF(cf, []byte("key2"), []byte("value2"))
+	bufferedBatch.DeleteCF(cf, []byte("key2"))
+	bufferedBatch.PutCF(cf, []byte("key3"), []byte("value3"))
+	bufferedBatch.PutCF(cf, []byte("key3"), []byte("value3-updated"))
+
+	writeBatch := db.GetDBHandle().NewWriteBatch()
+	defer writeBatch.Destroy()
+	bufferedBatch.addTo(writeBatch)
+	testDBWrapper.WriteToDB(t, writeBatch)
+
+	testutil.AssertEquals(t, testDBWrapper.GetFromStateCF(t, []byte("key1")), []byte("value1"))
+	testutil.AssertNil(t, testDBWrapper.GetFromStateCF(t, []byte("key2")))
+	testutil.AssertEquals(t, testDBWrapper.GetFromStateCF(t, []byte("key3")), []byte("value3-updated"))
+}
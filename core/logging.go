@@ -22,6 +22,8 @@ import (
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/flogging"
 )
 
 // A logger to log logging logs!
@@ -31,18 +33,30 @@ var loggingLogger = logging.MustGetLogger("logging")
 // case of configuration errors.
 var loggingDefaultLevel = logging.INFO
 
+// loggingCommand is the command LoggingInit was last called for,
+// loggingModules the modules whose level its specification overrode, and
+// loggingFormat the format of the output it set
+var loggingCommand string
+var loggingModules = make(map[string]bool)
+var loggingFormat = flogging.TextFormat
+
 // LoggingInit is a 'hook' called at the beginning of command processing to
 // parse logging-related options specified either on the command-line or in
 // config files.  Command-line options take precedence over config file
 // options, and can also be passed as suitably-named environment variables. To
 // change module logging levels at runtime call `logging.SetLevel(level,
-// module)`.  To debug this routine include logging=debug as the first
-// term of the logging specification.
+// module)`, which also sets the level of the submodules without a level of
+// their own.  To debug this routine include logging=debug as the first
+// term of the logging specification.  The format of the output is set by
+// LoggingFormatInit.
 func LoggingInit(command string) {
+	LoggingFormatInit()
+
 	// Parse the logging specification in the form
 	//     [<module>[,<module>...]=]<level>[:[<module>[,<module>...]=]<level>...]
 	defaultLevel := loggingDefaultLevel
 	var err error
+	modules := make(map[string]bool)
 	spec := viper.GetString("logging_level")
 	if spec == "" {
 		spec = viper.GetString("logging." + command)
@@ -66,8 +80,8 @@ func LoggingInit(command string) {
 				} else if split[0] == "" {
 					loggingLogger.Warningf("Invalid logging override specification '%s' ignored - no module specified", field)
 				} else {
-					modules := strings.Split(split[0], ",")
-					for _, module := range modules {
+					for _, module := range strings.Split(split[0], ",") {
+						modules[module] = true
 						logging.SetLevel(level, module)
 						loggingLogger.Debugf("Setting logging level for module '%s' to %s", module, level)
 					}
@@ -77,19 +91,52 @@ func LoggingInit(command string) {
 			}
 		}
 	}
-	// Set the default logging level for all modules
+	// Set the default logging level for all modules, the modules the
+	// previous specification overrode and this one does not inheriting it
 	logging.SetLevel(defaultLevel, "")
+	for module := range loggingModules {
+		if !modules[module] {
+			flogging.ResetModuleLevel(module)
+		}
+	}
+	loggingCommand = command
+	loggingModules = modules
 	loggingLogger.Debugf("Setting default logging level to %s for command '%s'", defaultLevel, command)
 }
 
-// Initiate 'leveled' logging to stderr.
-func init() {
+// LoggingFormatInit sets the format of the log output, text or json, from
+// the --logging-format command-line option, or from logging.format in the
+// config files.  LoggingInit calls it, the peer also before initializing the
+// crypto layer so that all its records share the format.
+func LoggingFormatInit() {
+	format := viper.GetString("logging_format")
+	if format == "" {
+		format = viper.GetString("logging.format")
+	}
+	if format == "" {
+		format = flogging.TextFormat
+	}
+	if !strings.EqualFold(format, loggingFormat) {
+		if err := flogging.SetFormat(format, os.Stderr); err != nil {
+			loggingLogger.Warningf("%s, keeping %s", err, loggingFormat)
+		} else {
+			loggingFormat = strings.ToLower(format)
+		}
+	}
+}
 
-	format := logging.MustStringFormatter(
-		"%{color}%{time:15:04:05.000} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}",
-	)
+// reloadLogging applies the logging specification of the command LoggingInit
+// was last called for again, and the logging level of the crypto module,
+// after they were reloaded
+func reloadLogging() {
+	LoggingInit(loggingCommand)
+	if level, err := logging.LogLevel(viper.GetString("logging.crypto")); err == nil {
+		logging.SetLevel(level, "crypto")
+	}
+}
 
-	backend := logging.NewLogBackend(os.Stderr, "", 0)
-	backendFormatter := logging.NewBackendFormatter(backend, format)
-	logging.SetBackend(backendFormatter).SetLevel(loggingDefaultLevel, "")
+// Initiate 'leveled' logging to stderr.
+func init() {
+	flogging.SetFormat(flogging.TextFormat, os.Stderr)
+	logging.SetLevel(loggingDefaultLevel, "")
 }
@@ -0,0 +1,438 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics exports the metrics of the peer in the text format of
+// Prometheus. The packages of the peer declare their counters, gauges and
+// histograms as package variables, register them in their init function, and
+// the peer serves them all on /metrics with Handler.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default upper bounds of the buckets of a histogram, in
+// seconds for a duration
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector is a metric family, a metric and the values of its labels
+type Collector interface {
+	// Name returns the name of the metric
+	Name() string
+	// write writes the metric in the text format
+	write(w io.Writer)
+}
+
+// family is the description of a metric
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (f *family) Name() string {
+	return f.name
+}
+
+func (f *family) header(w io.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, help, f.name, f.kind)
+}
+
+// key returns the key of the values of the labels, checking their number
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got the values %v", f.name, f.labels, values))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// sample writes a sample of the metric, suffix being appended to its name
+// and extra to its labels
+func (f *family) sample(w io.Writer, suffix string, values []string, value float64, extra ...string) {
+	var buf bytes.Buffer
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for i, name := range f.labels {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `%s="%s"`, name, escape.Replace(values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if buf.Len() > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `%s="%s"`, extra[i], escape.Replace(extra[i+1]))
+	}
+	labels := ""
+	if buf.Len() > 0 {
+		labels = "{" + buf.String() + "}"
+	}
+	fmt.Fprintf(w, "%s%s%s %s\n", f.name, suffix, labels, formatValue(value))
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// value is the value of a counter or a gauge for values of its labels
+type value struct {
+	labels []string
+	value  float64
+}
+
+// values are the values of a counter or of a gauge, by the values of their
+// labels
+type values struct {
+	family
+	sync.Mutex
+	values map[string]*value
+}
+
+func newValues(kind string, name string, help string, labels []string) values {
+	return values{family: family{name: name, help: help, kind: kind, labels: labels}, values: make(map[string]*value)}
+}
+
+func (vs *values) add(delta float64, set bool, labels []string) {
+	key := vs.key(labels)
+	vs.Lock()
+	defer vs.Unlock()
+	v, ok := vs.values[key]
+	if !ok {
+		v = &value{labels: append([]string(nil), labels...)}
+		vs.values[key] = v
+	}
+	if set {
+		v.value = delta
+	} else {
+		v.value += delta
+	}
+}
+
+func (vs *values) get(labels []string) float64 {
+	key := vs.key(labels)
+	vs.Lock()
+	defer vs.Unlock()
+	if v, ok := vs.values[key]; ok {
+		return v.value
+	}
+	return 0
+}
+
+func (vs *values) write(w io.Writer) {
+	vs.Lock()
+	defer vs.Unlock()
+	vs.header(w)
+	if len(vs.labels) == 0 && len(vs.values) == 0 {
+		vs.sample(w, "", nil, 0)
+		return
+	}
+	for _, key := range sortedKeys(vs.values) {
+		v := vs.values[key]
+		vs.sample(w, "", v.labels, v.value)
+	}
+}
+
+// Counter is a metric which only goes up, such as the number of transactions
+// committed
+type Counter struct {
+	values
+}
+
+// NewCounter creates a counter with the names of its labels, if any
+func NewCounter(name string, help string, labels ...string) *Counter {
+	return &Counter{newValues("counter", name, help, labels)}
+}
+
+// Inc adds one to the counter for the values of its labels
+func (c *Counter) Inc(labels ...string) {
+	c.add(1, false, labels)
+}
+
+// Add adds delta, which must not be negative, to the counter for the values
+// of its labels
+func (c *Counter) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.name))
+	}
+	c.add(delta, false, labels)
+}
+
+// Value returns the value of the counter for the values of its labels
+func (c *Counter) Value(labels ...string) float64 {
+	return c.get(labels)
+}
+
+// Gauge is a metric which goes up and down, such as the number of streams
+// open
+type Gauge struct {
+	values
+}
+
+// NewGauge creates a gauge with the names of its labels, if any
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{newValues("gauge", name, help, labels)}
+}
+
+// Set sets the gauge for the values of its labels
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.add(v, true, labels)
+}
+
+// Add adds delta to the gauge for the values of its labels
+func (g *Gauge) Add(delta float64, labels ...string) {
+	g.add(delta, false, labels)
+}
+
+// Inc adds one to the gauge for the values of its labels
+func (g *Gauge) Inc(labels ...string) {
+	g.add(1, false, labels)
+}
+
+// Dec subtracts one from the gauge for the values of its labels
+func (g *Gauge) Dec(labels ...string) {
+	g.add(-1, false, labels)
+}
+
+// Value returns the value of the gauge for the values of its labels
+func (g *Gauge) Value(labels ...string) float64 {
+	return g.get(labels)
+}
+
+// Sample is a value of a GaugeFunc for the values of its labels
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// GaugeFunc is a gauge whose values are read when the metrics are exported,
+// such as the statistics of the DB
+type GaugeFunc struct {
+	family
+	fn func() []Sample
+}
+
+// NewGaugeFunc creates a gauge whose values fn returns, with the names of its
+// labels, if any
+func NewGaugeFunc(name string, help string, fn func() []Sample, labels ...string) *GaugeFunc {
+	return &GaugeFunc{family: family{name: name, help: help, kind: "gauge", labels: labels}, fn: fn}
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.header(w)
+	samples := g.fn()
+	sort.Sort(byLabels(samples))
+	for _, s := range samples {
+		g.key(s.Labels)
+		g.sample(w, "", s.Labels, s.Value)
+	}
+}
+
+type byLabels []Sample
+
+func (s byLabels) Len() int      { return len(s) }
+func (s byLabels) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLabels) Less(i, j int) bool {
+	return strings.Join(s[i].Labels, "\xff") < strings.Join(s[j].Labels, "\xff")
+}
+
+// histogramValues are the observations of a histogram for values of its
+// labels
+type histogramValues struct {
+	labels []string
+	counts []uint64 // by bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Histogram is a metric counting observations, such as durations, in
+// buckets
+type Histogram struct {
+	family
+	sync.Mutex
+	buckets []float64
+	values  map[string]*histogramValues
+}
+
+// NewHistogram creates a histogram with the upper bounds of its buckets,
+// DefBuckets if nil, and the names of its labels, if any
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{
+		family:  family{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValues),
+	}
+}
+
+// Observe adds the observation to the histogram for the values of its labels
+func (h *Histogram) Observe(v float64, labels ...string) {
+	key := h.key(labels)
+	h.Lock()
+	defer h.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValues{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// Count returns the number of observations for the values of its labels
+func (h *Histogram) Count(labels ...string) uint64 {
+	key := h.key(labels)
+	h.Lock()
+	defer h.Unlock()
+	if hv, ok := h.values[key]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+	h.header(w)
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hv.counts[i]
+			h.sample(w, "_bucket", hv.labels, float64(cumulative), "le", formatValue(bound))
+		}
+		h.sample(w, "_bucket", hv.labels, float64(hv.count), "le", "+Inf")
+		h.sample(w, "_sum", hv.labels, hv.sum)
+		h.sample(w, "_count", hv.labels, float64(hv.count))
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*value:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*histogramValues:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Registry is a set of metrics exported together
+type Registry struct {
+	sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// DefaultRegistry is the registry of the metrics of the peer
+var DefaultRegistry = NewRegistry()
+
+// Register adds the metric to the registry, unless one of the same name is
+// registered already
+func (r *Registry) Register(c Collector) error {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.collectors[c.Name()]; ok {
+		return fmt.Errorf("Metric %s is registered already", c.Name())
+	}
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// MustRegister registers the metrics, and panics if any cannot be
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Write writes the metrics in the text format, sorted by name
+func (r *Registry) Write(w io.Writer) error {
+	r.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make(map[string]Collector, len(r.collectors))
+	for name, c := range r.collectors {
+		collectors[name] = c
+	}
+	r.RUnlock()
+
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		collectors[name].write(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Handler returns an HTTP handler serving the metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := r.Write(rw); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// MustRegister registers the metrics in the DefaultRegistry
+func MustRegister(cs ...Collector) {
+	DefaultRegistry.MustRegister(cs...)
+}
+
+// Handler returns an HTTP handler serving the metrics of the DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
@@ -0,0 +1,107 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	counter := NewCounter("test_requests_total", "Number of requests", "code")
+	gauge := NewGauge("test_temperature", "Temperature\nin degrees")
+	histogram := NewHistogram("test_latency_seconds", "Latency", []float64{1, 0.1})
+	dbSize := NewGaugeFunc("test_db_bytes", "Size of the DB", func() []Sample {
+		return []Sample{{Labels: []string{`cf "a"`}, Value: 1024}}
+	}, "cf")
+	r.MustRegister(counter, gauge, histogram, dbSize)
+	if err := r.Register(NewCounter("test_requests_total", "Again")); err == nil {
+		t.Fatalf("Expected a metric of the same name to be refused")
+	}
+
+	counter.Inc("200")
+	counter.Add(2, "200")
+	counter.Inc("500")
+	gauge.Set(20)
+	gauge.Dec()
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Failed writing the metrics: %s", err)
+	}
+	expected := `# HELP test_db_bytes Size of the DB
+# TYPE test_db_bytes gauge
+test_db_bytes{cf="cf \"a\""} 1024
+# HELP test_latency_seconds Latency
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 5.55
+test_latency_seconds_count 3
+# HELP test_requests_total Number of requests
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 3
+test_requests_total{code="500"} 1
+# HELP test_temperature Temperature\nin degrees
+# TYPE test_temperature gauge
+test_temperature 19
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected the metrics\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func TestLabelsMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected a panic when the values do not match the labels")
+		}
+	}()
+	NewCounter("test_total", "Test", "a", "b").Inc("a")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(NewCounter("test_total", "Test"))
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "test_total 0\n") {
+		t.Errorf("Expected the counter in the response, got %s", rec.Body.String())
+	}
+}
+
+func TestStreamOpened(t *testing.T) {
+	closed := StreamOpened("test")
+	StreamOpened("test")
+	closed()
+	if open := streamsOpen.Value("test"); open != 1 {
+		t.Errorf("Expected 1 stream open, got %v", open)
+	}
+	if opened := streamsOpened.Value("test"); opened != 2 {
+		t.Errorf("Expected 2 streams opened, got %v", opened)
+	}
+}
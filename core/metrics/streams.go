@@ -0,0 +1,38 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+var (
+	streamsOpen = NewGauge("fabric_grpc_streams",
+		"Number of gRPC streams open, by service", "stream")
+	streamsOpened = NewCounter("fabric_grpc_streams_opened_total",
+		"Number of gRPC streams opened, by service", "stream")
+)
+
+func init() {
+	MustRegister(streamsOpen, streamsOpened)
+}
+
+// StreamOpened counts a gRPC stream of the service as open, and returns the
+// function to call when the stream closes
+func StreamOpened(stream string) func() {
+	streamsOpen.Inc(stream)
+	streamsOpened.Inc(stream)
+	return func() {
+		streamsOpen.Dec(stream)
+	}
+}
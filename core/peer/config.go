@@ -32,9 +32,11 @@ package peer
 import (
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/spf13/viper"
 
+	"github.com/hyperledger/fabric/core/comm"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -53,12 +55,32 @@ var syncStateSnapshotChannelSize int
 var syncStateDeltasChannelSize int
 var syncBlocksChannelSize int
 var validatorEnabled bool
+var outboundOnly bool
+var gossipEnabled bool
+var gossipPeriod time.Duration
+var gossipFanout int
+var gossipMaxBlocks int
+var discoveryPersist bool
+var discoveryRefreshPeriod time.Duration
+var reconnectInitialDelay time.Duration
+var reconnectMaxDelay time.Duration
+var chatRateLimit float64
+var chatRateBurst int
+var chatDisconnectThreshold int
+var chatDisconnectWindow time.Duration
+var chatQueueSize int
+var chatPauseTimeout time.Duration
+var healthPingPeriod time.Duration
+var healthSuspectAfter int
+var healthDeadAfter int
+var healthDisconnectDead bool
 
 // Note: There is some kind of circular import issue that prevents us from
 // importing the "core" package into the "peer" package. The
 // 'peer.SecurityEnabled' bit is a duplicate of the 'core.SecurityEnabled'
 // bit.
 var securityEnabled bool
+var signMessages bool
 
 // CacheConfiguration computes and caches commonly-used constants and
 // computed constants as package variables. Routines which were previously
@@ -76,13 +98,14 @@ func CacheConfiguration() (err error) {
 			}
 			peerAddress = net.JoinHostPort(GetLocalIP(), port)
 			peerLogger.Infof("Auto detected peer address: %s", peerAddress)
-		} else {
-			peerAddress = viper.GetString("peer.address")
+		} else if peerAddress, err = comm.NormalizeAddress(viper.GetString("peer.address")); err != nil {
+			err = fmt.Errorf("Error parsing peer.address: %s", err)
 		}
 		return
 	}
 
-	// getPeerEndpoint returns the PeerEndpoint for this Peer instance.  Affected by env:peer.addressAutoDetect
+	// getPeerEndpoint returns the PeerEndpoint for this Peer instance, which
+	// advertises peer.externalAddress if set.  Affected by env:peer.addressAutoDetect
 	getPeerEndpoint := func() (*pb.PeerEndpoint, error) {
 		var peerAddress string
 		var peerType pb.PeerEndpoint_Type
@@ -90,14 +113,21 @@ func CacheConfiguration() (err error) {
 		if err != nil {
 			return nil, err
 		}
+		if externalAddress := viper.GetString("peer.externalAddress"); externalAddress != "" {
+			if peerAddress, err = comm.NormalizeAddress(externalAddress); err != nil {
+				return nil, fmt.Errorf("Error parsing peer.externalAddress: %s", err)
+			}
+		}
 		if viper.GetBool("peer.validator.enabled") {
 			peerType = pb.PeerEndpoint_VALIDATOR
 		} else {
 			peerType = pb.PeerEndpoint_NON_VALIDATOR
 		}
-		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: viper.GetString("peer.id")}, Address: peerAddress, Type: peerType}, nil
+		metadata := &pb.EndpointMetadata{Compression: []string{comm.CompressionGzip}, MaxMessageSize: uint32(comm.MaxRecvMessageSize()), OutboundOnly: outboundOnly, Version: viper.GetString("peer.version")}
+		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: viper.GetString("peer.id")}, Address: peerAddress, Type: peerType, Metadata: metadata}, nil
 	}
 
+	outboundOnly = viper.GetBool("peer.outboundOnly")
 	localAddress, localAddressError = getLocalAddress()
 	peerEndpoint, peerEndpointError = getPeerEndpoint()
 
@@ -105,8 +135,27 @@ func CacheConfiguration() (err error) {
 	syncStateDeltasChannelSize = viper.GetInt("peer.sync.state.deltas.channelSize")
 	syncBlocksChannelSize = viper.GetInt("peer.sync.blocks.channelSize")
 	validatorEnabled = viper.GetBool("peer.validator.enabled")
+	gossipEnabled = viper.GetBool("peer.sync.gossip.enabled")
+	gossipPeriod = viper.GetDuration("peer.sync.gossip.period")
+	gossipFanout = viper.GetInt("peer.sync.gossip.fanout")
+	gossipMaxBlocks = viper.GetInt("peer.sync.gossip.maxBlocks")
+	discoveryPersist = viper.GetBool("peer.discovery.persist")
+	discoveryRefreshPeriod = viper.GetDuration("peer.discovery.refreshPeriod")
+	reconnectInitialDelay = viper.GetDuration("peer.discovery.reconnect.initialDelay")
+	reconnectMaxDelay = viper.GetDuration("peer.discovery.reconnect.maxDelay")
+	chatRateLimit = viper.GetFloat64("peer.limits.chat.rate")
+	chatRateBurst = viper.GetInt("peer.limits.chat.burst")
+	chatDisconnectThreshold = viper.GetInt("peer.limits.chat.disconnectThreshold")
+	chatDisconnectWindow = viper.GetDuration("peer.limits.chat.disconnectWindow")
+	chatQueueSize = viper.GetInt("peer.limits.chat.queueSize")
+	chatPauseTimeout = viper.GetDuration("peer.limits.chat.pauseTimeout")
+	healthPingPeriod = viper.GetDuration("peer.health.pingPeriod")
+	healthSuspectAfter = viper.GetInt("peer.health.suspectAfter")
+	healthDeadAfter = viper.GetInt("peer.health.deadAfter")
+	healthDisconnectDead = viper.GetBool("peer.health.disconnectDead")
 
 	securityEnabled = viper.GetBool("security.enabled")
+	signMessages = viper.GetBool("security.signMessages")
 
 	configurationCached = true
 
@@ -127,7 +176,8 @@ func cacheConfiguration() {
 
 //Functional forms
 
-// GetLocalAddress returns the peer.address property
+// GetLocalAddress returns the peer.address property, the address other
+// processes on the host, such as the chaincodes, reach the peer at
 func GetLocalAddress() (string, error) {
 	if !configurationCached {
 		cacheConfiguration()
@@ -135,6 +185,8 @@ func GetLocalAddress() (string, error) {
 	return localAddress, localAddressError
 }
 
+// GetPeerEndpoint returns the endpoint of the peer, with the address it
+// advertises to the other peers
 func GetPeerEndpoint() (*pb.PeerEndpoint, error) {
 	if !configurationCached {
 		cacheConfiguration()
@@ -166,6 +218,188 @@ func SyncBlocksChannelSize() int {
 	return syncBlocksChannelSize
 }
 
+// GossipEnabled returns whether a non-validating peer takes part in the block
+// gossip, the peer.sync.gossip.enabled property
+func GossipEnabled() bool {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return gossipEnabled && !validatorEnabled && gossipPeriod > 0
+}
+
+// GossipPeriod returns the peer.sync.gossip.period property
+func GossipPeriod() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return gossipPeriod
+}
+
+// GossipFanout returns the peer.sync.gossip.fanout property
+func GossipFanout() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return gossipFanout
+}
+
+// GossipMaxBlocks returns the peer.sync.gossip.maxBlocks property
+func GossipMaxBlocks() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return gossipMaxBlocks
+}
+
+// OutboundOnly returns whether the peer cannot be connected to and only
+// chats with the peers it connects to, the peer.outboundOnly property
+func OutboundOnly() bool {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return outboundOnly
+}
+
+// DiscoveryPersist returns the peer.discovery.persist property
+func DiscoveryPersist() bool {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return discoveryPersist
+}
+
+// DiscoveryRefreshPeriod returns the peer.discovery.refreshPeriod property,
+// the period of the lookups of the root nodes by the discovery provider
+func DiscoveryRefreshPeriod() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return discoveryRefreshPeriod
+}
+
+// ReconnectInitialDelay returns the peer.discovery.reconnect.initialDelay
+// property, or 1s if not set
+func ReconnectInitialDelay() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	if reconnectInitialDelay <= 0 {
+		return time.Second
+	}
+	return reconnectInitialDelay
+}
+
+// ReconnectMaxDelay returns the peer.discovery.reconnect.maxDelay property,
+// which is at least the initial delay
+func ReconnectMaxDelay() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	if reconnectMaxDelay < ReconnectInitialDelay() {
+		return ReconnectInitialDelay()
+	}
+	return reconnectMaxDelay
+}
+
+// ChatRateLimit returns the peer.limits.chat.rate property, the messages per
+// second a peer may send on a chat stream, 0 for no limit
+func ChatRateLimit() float64 {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return chatRateLimit
+}
+
+// ChatRateBurst returns the peer.limits.chat.burst property
+func ChatRateBurst() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return chatRateBurst
+}
+
+// ChatDisconnectThreshold returns the peer.limits.chat.disconnectThreshold
+// property, 0 to never disconnect a peer for exceeding the rate limit
+func ChatDisconnectThreshold() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return chatDisconnectThreshold
+}
+
+// ChatDisconnectWindow returns the peer.limits.chat.disconnectWindow property
+func ChatDisconnectWindow() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return chatDisconnectWindow
+}
+
+// ChatQueueSize returns the peer.limits.chat.queueSize property, or 1000 if
+// not set
+func ChatQueueSize() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	if chatQueueSize <= 0 {
+		return 1000
+	}
+	return chatQueueSize
+}
+
+// ChatPauseTimeout returns the peer.limits.chat.pauseTimeout property, or 10s
+// if not set
+func ChatPauseTimeout() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	if chatPauseTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return chatPauseTimeout
+}
+
+// HealthPingPeriod returns the peer.health.pingPeriod property, 0 not to
+// ping the peers
+func HealthPingPeriod() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return healthPingPeriod
+}
+
+// HealthSuspectAfter returns the peer.health.suspectAfter property, or 1 if
+// not set
+func HealthSuspectAfter() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	if healthSuspectAfter <= 0 {
+		return 1
+	}
+	return healthSuspectAfter
+}
+
+// HealthDeadAfter returns the peer.health.deadAfter property, which is at
+// least the suspectAfter one
+func HealthDeadAfter() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	if healthDeadAfter < HealthSuspectAfter() {
+		return HealthSuspectAfter()
+	}
+	return healthDeadAfter
+}
+
+// HealthDisconnectDead returns the peer.health.disconnectDead property
+func HealthDisconnectDead() bool {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return healthDisconnectDead
+}
+
 // ValidatorEnabled returns the peer.validator.enabled property
 func ValidatorEnabled() bool {
 	if !configurationCached {
@@ -180,3 +414,12 @@ func SecurityEnabled() bool {
 	}
 	return securityEnabled
 }
+
+// SignMessages returns whether the peers sign the messages of their chat
+// streams, the security.signMessages property, which requires security
+func SignMessages() bool {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return securityEnabled && signMessages
+}
@@ -0,0 +1,315 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// A chat stream is managed by a chatConnection, which limits the rate of the
+// messages the remote peer sends, disconnects it if it keeps exceeding the
+// limit, and queues the messages received for a handler go routine. When the
+// queue fills up, the remote peer is sent a DISC_PAUSE, and stops sending
+// until it receives a DISC_RESUME, sent once half of the queue is handled.
+// The connection stops reading the stream while the queue is full, so that a
+// remote peer which does not pause is held back by the flow control of the
+// stream. The connection also pings the remote peer to track its health,
+// and answers its pings, regardless of the queue. If message signing is
+// enabled, it signs all the messages it sends, and drops the messages
+// received which are not signed by the remote peer.
+
+// rateLimiter is a token bucket allowing rate messages per second, beyond a
+// burst of messages. It also counts the messages it did not allow within a
+// window, for the remote peer to be disconnected beyond a threshold.
+type rateLimiter struct {
+	rate      float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	threshold int
+	window    time.Duration
+	start     time.Time // of the window
+	exceeded  int       // messages not allowed since the start of the window
+}
+
+func newRateLimiter(rate float64, burst int, threshold int, window time.Duration) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), threshold: threshold, window: window}
+}
+
+// allow returns whether a message received at now is within the rate limit
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+	if now.Sub(l.start) > l.window {
+		l.start = now
+		l.exceeded = 0
+	}
+	l.exceeded++
+	return false
+}
+
+// disconnect returns whether the remote peer exceeded the limit more than
+// the threshold within the window, and should be disconnected
+func (l *rateLimiter) disconnect() bool {
+	return l.threshold > 0 && l.exceeded > l.threshold
+}
+
+// rateLimited returns whether the messages of type msgType count against the
+// rate limit. The messages answering a request of this peer, which may come
+// in bursts such as the chunks of a state snapshot, are only held back by the
+// inbound queue.
+func rateLimited(msgType pb.Message_Type) bool {
+	switch msgType {
+	case pb.Message_SYNC_BLOCKS, pb.Message_SYNC_STATE_SNAPSHOT, pb.Message_SYNC_STATE_DELTAS:
+		return false
+	default:
+		return true
+	}
+}
+
+// chatConnection wraps the chat stream of a handler
+type chatConnection struct {
+	stream       ChatStream
+	handler      MessageHandler
+	sendLock     sync.Mutex
+	limiter      *rateLimiter
+	queue        chan *pb.Message
+	done         chan struct{}
+	pauseTimeout time.Duration
+	health       *peerHealth
+	topology     peerTopology
+	signer       *messageSigner // nil unless messages are signed
+	dead         chan struct{}  // closed once the remote peer is deemed dead
+	dropped      chan struct{}  // closed once the chat is to be ended by this peer
+	dropOnce     sync.Once
+	stopped      chan struct{} // closed once the chat ended
+
+	sync.Mutex
+	resumed      chan struct{} // closed once the remote peer resumes us, nil unless paused
+	remotePaused bool
+}
+
+func newChatConnection(stream ChatStream) *chatConnection {
+	return &chatConnection{
+		stream:       stream,
+		limiter:      newRateLimiter(ChatRateLimit(), ChatRateBurst(), ChatDisconnectThreshold(), ChatDisconnectWindow()),
+		queue:        make(chan *pb.Message, ChatQueueSize()),
+		done:         make(chan struct{}),
+		pauseTimeout: ChatPauseTimeout(),
+		health:       newPeerHealth(HealthSuspectAfter(), HealthDeadAfter()),
+		dead:         make(chan struct{}),
+		dropped:      make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+}
+
+// Send sends msg through the stream, once the remote peer resumes this peer
+// if it paused it. If the remote peer does not resume it within the pause
+// timeout, the DISC_RESUME is deemed lost.
+func (c *chatConnection) Send(msg *pb.Message) error {
+	c.Lock()
+	resumed := c.resumed
+	c.Unlock()
+	if resumed != nil {
+		select {
+		case <-resumed:
+		case <-time.After(c.pauseTimeout):
+			peerLogger.Warningf("Not resumed by the remote peer within %v, sending anyway", c.pauseTimeout)
+			c.setPaused(false)
+		}
+	}
+	return c.send(msg)
+}
+
+// send sends msg through the stream regardless of the flow control
+func (c *chatConnection) send(msg *pb.Message) error {
+	if c.signer != nil {
+		signed, err := c.signer.sign(msg)
+		if err != nil {
+			return err
+		}
+		msg = signed
+	}
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	return c.stream.Send(msg)
+}
+
+// Recv receives the next message from the stream
+func (c *chatConnection) Recv() (*pb.Message, error) {
+	return c.stream.Recv()
+}
+
+func (c *chatConnection) setPaused(paused bool) {
+	c.Lock()
+	defer c.Unlock()
+	if paused && c.resumed == nil {
+		c.resumed = make(chan struct{})
+	} else if !paused && c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+// receive processes a message received from the stream. It returns an error
+// if the remote peer is to be disconnected for flooding this peer, and blocks
+// while the inbound queue is full.
+func (c *chatConnection) receive(msg *pb.Message) error {
+	if c.signer != nil {
+		if err := c.signer.verify(msg); err != nil {
+			peerLogger.Warningf("Dropping message from %s: %s", c.peerID(), err)
+			return nil
+		}
+	}
+	c.health.seen(time.Now())
+	if rateLimited(msg.Type) && !c.limiter.allow(time.Now()) {
+		if c.limiter.disconnect() {
+			return fmt.Errorf("Peer sent more than %d messages over the rate limit of %v messages per second within %v", c.limiter.threshold, c.limiter.rate, c.limiter.window)
+		}
+		peerLogger.Warningf("Dropping %s message over the rate limit of %v messages per second", msg.Type, c.limiter.rate)
+		return nil
+	}
+	switch msg.Type {
+	case pb.Message_DISC_PAUSE:
+		c.setPaused(true)
+		return nil
+	case pb.Message_DISC_RESUME:
+		c.setPaused(false)
+		return nil
+	case pb.Message_DISC_PING:
+		if err := c.send(&pb.Message{Type: pb.Message_DISC_PONG, Payload: msg.Payload}); err != nil {
+			return fmt.Errorf("Error sending %s: %s", pb.Message_DISC_PONG, err)
+		}
+		return nil
+	case pb.Message_DISC_PONG:
+		c.health.pong(msg.Payload, time.Now())
+		return nil
+	}
+	if len(c.queue) == cap(c.queue) {
+		c.Lock()
+		pause := !c.remotePaused
+		c.remotePaused = true
+		c.Unlock()
+		if pause {
+			peerLogger.Debugf("Inbound queue of %d messages full, sending %s", cap(c.queue), pb.Message_DISC_PAUSE)
+			if err := c.send(&pb.Message{Type: pb.Message_DISC_PAUSE}); err != nil {
+				return fmt.Errorf("Error sending %s: %s", pb.Message_DISC_PAUSE, err)
+			}
+		}
+	}
+	c.queue <- msg
+	return nil
+}
+
+// probe pings the remote peer every period until the chat ends, and closes
+// the dead channel once the remote peer is deemed dead if disconnect is set
+func (c *chatConnection) probe(period time.Duration, disconnect bool) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	state := pb.PeerHealth_ALIVE
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.stopped:
+			return
+		}
+		payload, newState := c.health.ping(time.Now())
+		if newState != state {
+			peerLogger.Warningf("Peer %s is now %s", c.peerID(), newState)
+			state = newState
+		}
+		if state == pb.PeerHealth_DEAD && disconnect {
+			close(c.dead)
+			return
+		}
+		if err := c.send(&pb.Message{Type: pb.Message_DISC_PING, Payload: payload}); err != nil {
+			peerLogger.Errorf("Error sending %s to %s: %s", pb.Message_DISC_PING, c.peerID(), err)
+		}
+	}
+}
+
+// peerID returns the ID of the remote peer, once it said hello
+func (c *chatConnection) peerID() *pb.PeerID {
+	if c.handler == nil {
+		return nil
+	}
+	endpoint, err := c.handler.To()
+	if err != nil {
+		return nil
+	}
+	return endpoint.ID
+}
+
+// handle hands the queued messages to handler until the queue is closed
+func (c *chatConnection) handle(handler MessageHandler) {
+	defer close(c.done)
+	for msg := range c.queue {
+		c.Lock()
+		resume := c.remotePaused && len(c.queue) <= cap(c.queue)/2
+		if resume {
+			c.remotePaused = false
+		}
+		c.Unlock()
+		if resume {
+			if err := c.send(&pb.Message{Type: pb.Message_DISC_RESUME}); err != nil {
+				peerLogger.Errorf("Error sending %s: %s", pb.Message_DISC_RESUME, err)
+			}
+		}
+
+		if err := decodeMessage(msg); err != nil {
+			peerLogger.Errorf("Error decoding message: %s", err)
+			continue
+		}
+		c.topology.observe(msg)
+		if err := handler.HandleMessage(msg); err != nil {
+			peerLogger.Errorf("Error handling message: %s", err)
+		}
+	}
+}
+
+// drop has the chat end, e.g. once an administrator disconnected the peer
+func (c *chatConnection) drop() {
+	c.dropOnce.Do(func() { close(c.dropped) })
+}
+
+// close closes the queue, and waits for the messages queued to be handled
+func (c *chatConnection) close() {
+	close(c.stopped)
+	// the handler go routine may wait for the remote peer to resume it
+	c.setPaused(false)
+	close(c.queue)
+	<-c.done
+}
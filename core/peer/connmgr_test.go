@@ -0,0 +1,180 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(10, 2, 3, time.Minute)
+	start := time.Now()
+	for i, expected := range []bool{true, true, false, false} {
+		if allowed := l.allow(start); allowed != expected {
+			t.Fatalf("Expected message %d of the burst allowed to be %v", i, expected)
+		}
+	}
+	if l.disconnect() {
+		t.Fatalf("Expected the peer not to be disconnected under the threshold")
+	}
+	// a token per 100ms
+	if !l.allow(start.Add(100 * time.Millisecond)) {
+		t.Fatalf("Expected a message to be allowed once a token is added")
+	}
+	l.allow(start.Add(100 * time.Millisecond))
+	l.allow(start.Add(100 * time.Millisecond))
+	if !l.disconnect() {
+		t.Fatalf("Expected the peer to be disconnected over the threshold")
+	}
+	// the count restarts with the window
+	l.allow(start.Add(2 * time.Minute))
+	l.allow(start.Add(2 * time.Minute))
+	l.allow(start.Add(2 * time.Minute))
+	if l.disconnect() {
+		t.Fatalf("Expected the count of messages over the limit to restart with the window")
+	}
+
+	unlimited := newRateLimiter(0, 0, 1, time.Minute)
+	for i := 0; i < 100; i++ {
+		if !unlimited.allow(start) {
+			t.Fatalf("Expected no limit with a rate of 0")
+		}
+	}
+}
+
+// chanStream passes the messages sent to a channel
+type chanStream struct {
+	sent chan *pb.Message
+}
+
+func (s *chanStream) Send(msg *pb.Message) error {
+	s.sent <- msg
+	return nil
+}
+
+func (s *chanStream) Recv() (*pb.Message, error) {
+	select {}
+}
+
+// blockingHandler handles a message once released
+type blockingHandler struct {
+	MessageHandler
+	release chan struct{}
+}
+
+func (h *blockingHandler) HandleMessage(msg *pb.Message) error {
+	<-h.release
+	return nil
+}
+
+func expectSent(t *testing.T, stream *chanStream, msgType pb.Message_Type) {
+	select {
+	case msg := <-stream.sent:
+		if msg.Type != msgType {
+			t.Fatalf("Expected %s to be sent, got %s", msgType, msg.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %s to be sent", msgType)
+	}
+}
+
+func TestChatConnectionFlowControl(t *testing.T) {
+	stream := &chanStream{sent: make(chan *pb.Message, 10)}
+	conn := &chatConnection{
+		stream:       stream,
+		limiter:      newRateLimiter(0, 0, 0, 0),
+		queue:        make(chan *pb.Message, 2),
+		done:         make(chan struct{}),
+		pauseTimeout: time.Minute,
+		health:       newPeerHealth(1, 3),
+		stopped:      make(chan struct{}),
+	}
+	handler := &blockingHandler{release: make(chan struct{})}
+	go conn.handle(handler)
+
+	// the handler holds the first message, the next two fill the queue
+	conn.receive(&pb.Message{Type: pb.Message_SYNC_BLOCK_ADDED})
+	for len(conn.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	received := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			if err := conn.receive(&pb.Message{Type: pb.Message_SYNC_BLOCK_ADDED}); err != nil {
+				t.Errorf("Error receiving message: %s", err)
+			}
+		}
+		close(received)
+	}()
+	expectSent(t, stream, pb.Message_DISC_PAUSE)
+	select {
+	case <-received:
+		t.Fatalf("Expected the connection to stop receiving while the queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+	handler.release <- struct{}{}
+	handler.release <- struct{}{}
+	expectSent(t, stream, pb.Message_DISC_RESUME)
+	<-received
+	handler.release <- struct{}{}
+	handler.release <- struct{}{}
+
+	// sending waits for the remote peer to resume this peer
+	conn.receive(&pb.Message{Type: pb.Message_DISC_PAUSE})
+	go conn.Send(&pb.Message{Type: pb.Message_DISC_GET_PEERS})
+	select {
+	case msg := <-stream.sent:
+		t.Fatalf("Expected %s not to be sent while paused", msg.Type)
+	case <-time.After(10 * time.Millisecond):
+	}
+	conn.receive(&pb.Message{Type: pb.Message_DISC_RESUME})
+	expectSent(t, stream, pb.Message_DISC_GET_PEERS)
+
+	// unless the resume is lost
+	conn.pauseTimeout = 10 * time.Millisecond
+	conn.receive(&pb.Message{Type: pb.Message_DISC_PAUSE})
+	go conn.Send(&pb.Message{Type: pb.Message_DISC_GET_PEERS})
+	expectSent(t, stream, pb.Message_DISC_GET_PEERS)
+	conn.close()
+}
+
+func TestChatConnectionDisconnect(t *testing.T) {
+	conn := &chatConnection{
+		stream:  &chanStream{sent: make(chan *pb.Message, 10)},
+		limiter: newRateLimiter(1, 1, 2, time.Minute),
+		queue:   make(chan *pb.Message, 10),
+		health:  newPeerHealth(1, 3),
+	}
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = conn.receive(&pb.Message{Type: pb.Message_DISC_GET_PEERS})
+	}
+	if err == nil {
+		t.Fatalf("Expected a peer flooding the connection to be disconnected")
+	}
+	if len(conn.queue) != 1 {
+		t.Fatalf("Expected the messages over the rate limit to be dropped, %d queued", len(conn.queue))
+	}
+	for i := 0; i < 5; i++ {
+		if err := conn.receive(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT}); err != nil {
+			t.Fatalf("Expected the answers to requests not to be limited: %s", err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/discovery"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var discoveryListKey = []byte("peer.discovery")
+
+// chatWithBootstrapNodes starts chatting with the root nodes, and with the
+// peers discovered before a restart if they are persisted
+func (p *PeerImpl) chatWithBootstrapNodes() {
+	rootNodes := p.discoverySvc.GetRootNodes()
+	p.chatWithSomePeers(rootNodes)
+	if refresher, ok := p.discoverySvc.(discovery.Refresher); ok && DiscoveryRefreshPeriod() > 0 {
+		go p.refreshRootNodes(refresher, DiscoveryRefreshPeriod())
+	}
+	if !DiscoveryPersist() {
+		return
+	}
+	if err := p.loadDiscoveryList(); err != nil {
+		peerLogger.Errorf("Error loading the discovered peers: %s", err)
+		return
+	}
+	for _, address := range p.discoverySvc.GetAllNodes() {
+		if !containsAddress(rootNodes, address) {
+			// As when the peer is discovered by PeersDiscovered
+			p.chatWithSomePeers([]string{address})
+		}
+	}
+}
+
+// refreshRootNodes looks the root nodes up again every period, chatting with
+// the new ones and no longer with those removed, e.g. as the pods of the
+// validators are rescheduled
+func (p *PeerImpl) refreshRootNodes(refresher discovery.Refresher, period time.Duration) {
+	for range time.Tick(period) {
+		added, removed, err := refresher.Refresh()
+		if err != nil {
+			peerLogger.Errorf("Error refreshing the root nodes: %s", err)
+			continue
+		}
+		for _, address := range removed {
+			peerLogger.Infof("Root node %s was removed", address)
+			p.stopChatting(address)
+		}
+		if len(added) > 0 {
+			peerLogger.Infof("Root nodes %v were added", added)
+			p.chatWithSomePeers(added)
+		}
+	}
+}
+
+// reachable returns whether other peers can connect to the peer at the
+// address of its endpoint, which an outbound only peer, e.g. behind a NAT,
+// does not advertise
+func reachable(endpoint *pb.PeerEndpoint) bool {
+	return endpoint.Address != "" && (endpoint.Metadata == nil || !endpoint.Metadata.OutboundOnly)
+}
+
+// discoverNode adds the address of a peer this peer chats with to the
+// discovery service, and persists the discovered peers if it is new
+func (p *PeerImpl) discoverNode(address string) {
+	if p.discoverySvc == nil || !p.discoverySvc.AddNode(address) {
+		return
+	}
+	peerLogger.Debugf("Discovered peer address %s", address)
+	if DiscoveryPersist() {
+		if err := p.storeDiscoveryList(); err != nil {
+			peerLogger.Errorf("Error storing the discovered peers: %s", err)
+		}
+	}
+}
+
+func (p *PeerImpl) loadDiscoveryList() error {
+	dbHandle := db.GetDBHandle()
+	raw, err := dbHandle.Get(dbHandle.PersistCF, discoveryListKey)
+	if err != nil {
+		return fmt.Errorf("Error reading the discovered peers: %s", err)
+	}
+	if raw == nil {
+		return nil
+	}
+	addresses := &pb.PeersAddresses{}
+	if err := proto.Unmarshal(raw, addresses); err != nil {
+		return fmt.Errorf("Error unmarshalling the discovered peers: %s", err)
+	}
+	for _, address := range addresses.Addresses {
+		p.discoverySvc.AddNode(address)
+	}
+	peerLogger.Debugf("Loaded %d discovered peer addresses", len(addresses.Addresses))
+	return nil
+}
+
+func (p *PeerImpl) storeDiscoveryList() error {
+	addresses := &pb.PeersAddresses{}
+	rootNodes := p.discoverySvc.GetRootNodes()
+	for _, address := range p.discoverySvc.GetAllNodes() {
+		if !containsAddress(rootNodes, address) {
+			addresses.Addresses = append(addresses.Addresses, address)
+		}
+	}
+	raw, err := proto.Marshal(addresses)
+	if err != nil {
+		return fmt.Errorf("Error marshalling the discovered peers: %s", err)
+	}
+	dbHandle := db.GetDBHandle()
+	return dbHandle.Put(dbHandle.PersistCF, discoveryListKey, raw)
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// reconnectBackoff is the delay before connecting to a peer again, which
+// doubles on each failed attempt up to max
+type reconnectBackoff struct {
+	initial time.Duration
+	max     time.Duration
+	delay   time.Duration
+}
+
+func newReconnectBackoff() *reconnectBackoff {
+	return &reconnectBackoff{initial: ReconnectInitialDelay(), max: ReconnectMaxDelay()}
+}
+
+// next returns the delay before the next attempt
+func (b *reconnectBackoff) next() time.Duration {
+	if b.delay == 0 {
+		b.delay = b.initial
+	} else {
+		b.delay *= 2
+	}
+	if b.delay > b.max {
+		b.delay = b.max
+	}
+	return b.delay
+}
+
+// reset restarts from the initial delay once connected
+func (b *reconnectBackoff) reset() {
+	b.delay = 0
+}
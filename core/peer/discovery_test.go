@@ -0,0 +1,175 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// mockDiscovery keeps the nodes in a list, as core.StaticDiscovery does
+type mockDiscovery struct {
+	rootNodes []string
+	nodes     []string
+}
+
+func (d *mockDiscovery) GetRandomNode() string {
+	return d.rootNodes[0]
+}
+
+func (d *mockDiscovery) GetRootNodes() []string {
+	return d.rootNodes
+}
+
+func (d *mockDiscovery) AddNode(address string) bool {
+	if containsAddress(d.nodes, address) {
+		return false
+	}
+	d.nodes = append(d.nodes, address)
+	return true
+}
+
+func (d *mockDiscovery) RemoveNode(address string) bool {
+	for i, node := range d.nodes {
+		if node == address && !containsAddress(d.rootNodes, address) {
+			d.nodes = append(d.nodes[:i], d.nodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *mockDiscovery) GetAllNodes() []string {
+	return d.nodes
+}
+
+func newMockDiscovery(rootNodes ...string) *mockDiscovery {
+	return &mockDiscovery{rootNodes: rootNodes, nodes: append([]string{}, rootNodes...)}
+}
+
+func TestDiscoveryPersistence(t *testing.T) {
+	ledger.InitTestLedger(t)
+	p := &PeerImpl{discoverySvc: newMockDiscovery("root1", "root2")}
+	if err := p.loadDiscoveryList(); err != nil {
+		t.Fatalf("Error loading an empty discovery list: %s", err)
+	}
+	p.discoverySvc.AddNode("peer1")
+	p.discoverySvc.AddNode("peer2")
+	if err := p.storeDiscoveryList(); err != nil {
+		t.Fatalf("Error storing the discovery list: %s", err)
+	}
+
+	// After a restart, possibly with other root nodes
+	restarted := &PeerImpl{discoverySvc: newMockDiscovery("root2", "root3")}
+	if err := restarted.loadDiscoveryList(); err != nil {
+		t.Fatalf("Error loading the discovery list: %s", err)
+	}
+	expected := []string{"root2", "root3", "peer1", "peer2"}
+	if nodes := restarted.discoverySvc.GetAllNodes(); !reflect.DeepEqual(nodes, expected) {
+		t.Fatalf("Expected nodes %v after a restart, got %v", expected, nodes)
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	backoff := &reconnectBackoff{initial: time.Second, max: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range expected {
+		if next := backoff.next(); next != delay {
+			t.Fatalf("Expected delay %v on attempt %d, got %v", delay, i, next)
+		}
+	}
+	backoff.reset()
+	if next := backoff.next(); next != time.Second {
+		t.Fatalf("Expected the initial delay after a reset, got %v", next)
+	}
+}
+
+func TestOutboundOnlyEndpoint(t *testing.T) {
+	for key, value := range map[string]interface{}{"peer.externalAddress": "203.0.113.1:30303", "peer.outboundOnly": true} {
+		defer viper.Set(key, viper.Get(key))
+		viper.Set(key, value)
+	}
+	defer CacheConfiguration()
+	if err := CacheConfiguration(); err != nil {
+		t.Fatalf("Error caching the configuration: %s", err)
+	}
+	endpoint, err := GetPeerEndpoint()
+	if err != nil {
+		t.Fatalf("Error getting the peer endpoint: %s", err)
+	}
+	if endpoint.Address != "203.0.113.1:30303" {
+		t.Fatalf("Expected the peer to advertise its external address, got %s", endpoint.Address)
+	}
+	if localAddress, _ := GetLocalAddress(); localAddress != viper.GetString("peer.address") {
+		t.Fatalf("Expected the local address to stay %s, got %s", viper.GetString("peer.address"), localAddress)
+	}
+	if reachable(endpoint) {
+		t.Fatalf("Expected an outbound only endpoint not to be reachable")
+	}
+
+	// Peers neither connect to nor persist an outbound only peer
+	p := &PeerImpl{handlerMap: &handlerMap{m: make(map[pb.PeerID]MessageHandler)}, discoverySvc: newMockDiscovery("root")}
+	outbound := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "outbound"}, Address: "10.0.0.1:30303", Metadata: &pb.EndpointMetadata{OutboundOnly: true}}
+	if err := p.PeersDiscovered(&pb.PeersMessage{Peers: []*pb.PeerEndpoint{outbound}}); err != nil {
+		t.Fatalf("Error processing the discovered peers: %s", err)
+	}
+	if len(p.chatting.m) != 0 {
+		t.Fatalf("Expected not to chat with an outbound only peer, chatting with %v", p.chatting.m)
+	}
+	if err := p.RegisterHandler(&Handler{ToPeerEndpoint: outbound}); err != nil {
+		t.Fatalf("Error registering handler: %s", err)
+	}
+	inbound := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "inbound"}, Address: "10.0.0.2:30303"}
+	if err := p.RegisterHandler(&Handler{ToPeerEndpoint: inbound}); err != nil {
+		t.Fatalf("Error registering handler: %s", err)
+	}
+	expected := []string{"root", inbound.Address}
+	if nodes := p.discoverySvc.GetAllNodes(); !reflect.DeepEqual(nodes, expected) {
+		t.Fatalf("Expected nodes %v, got %v", expected, nodes)
+	}
+}
+
+func TestDisconnectPeer(t *testing.T) {
+	p := &PeerImpl{discoverySvc: newMockDiscovery("root")}
+	p.discoverySvc.AddNode("10.0.0.2:30303")
+	p.startChatting("10.0.0.2:30303")
+	p.startChatting("root")
+	if err := p.DisconnectPeer("10.0.0.2:30303"); err != nil {
+		t.Fatalf("Error disconnecting the peer: %s", err)
+	}
+	if p.keepChatting("10.0.0.2:30303") {
+		t.Fatalf("Expected to stop chatting with the disconnected peer")
+	}
+	if nodes := p.discoverySvc.GetAllNodes(); !reflect.DeepEqual(nodes, []string{"root"}) {
+		t.Fatalf("Expected the disconnected peer to be forgotten, got nodes %v", nodes)
+	}
+	if err := p.DisconnectPeer("10.0.0.2:30303"); err == nil {
+		t.Fatalf("Expected an error disconnecting a peer not connected")
+	}
+	if err := p.DisconnectPeer("root"); err != nil {
+		t.Fatalf("Error disconnecting the root node: %s", err)
+	}
+	if nodes := p.discoverySvc.GetAllNodes(); !reflect.DeepEqual(nodes, []string{"root"}) {
+		t.Fatalf("Expected the root node to be kept, got nodes %v", nodes)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// blockSource serves the blocks and state deltas pushed to other peers
+type blockSource interface {
+	BlockChainAccessor
+	BlockChainUtil
+	StateAccessor
+}
+
+// gossipStack is what the block gossip of a non-validating peer relies upon
+type gossipStack interface {
+	blockSource
+	BlockChainModifier
+	CommitBlockStateDelta(id interface{}, blockNumber uint64) error
+	GetPeers() (*pb.PeersMessage, error)
+	Unicast(*pb.Message, *pb.PeerID) error
+}
+
+// gossipCoordinator is implemented by the coordinators of the peers taking
+// part in the block gossip, that is the non-validating peers
+type gossipCoordinator interface {
+	gossiper() *blockGossip
+}
+
+// blockGossip disseminates blocks among non-validating peers by
+// anti-entropy: every period, a peer sends the digest of its blockchain to a
+// few random neighbors, which push it the blocks it is missing. The blocks a
+// peer appends, whether sent by a validating peer or pushed by another
+// non-validating peer, are forwarded to a few random non-validating peers
+// in turn.
+type blockGossip struct {
+	sync.Mutex // serializes appending blocks to the blockchain
+	stack      gossipStack
+	fanout     int
+	maxBlocks  int
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+}
+
+func newBlockGossip(stack gossipStack, fanout int, maxBlocks int) *blockGossip {
+	return &blockGossip{
+		stack:     stack,
+		fanout:    fanout,
+		maxBlocks: maxBlocks,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// run sends the digest of the blockchain to random neighbors every period
+func (g *blockGossip) run(period time.Duration) {
+	for range time.Tick(period) {
+		g.gossipDigest()
+	}
+}
+
+// gossipDigest sends the digest of the blockchain to fanout random peers,
+// validating or not, as both serve the blocks a peer is missing
+func (g *blockGossip) gossipDigest() {
+	msg, err := newGossipDigest(g.stack)
+	if err != nil {
+		peerLogger.Errorf("Error creating gossip digest: %s", err)
+		return
+	}
+	for _, id := range g.neighbors(pb.PeerEndpoint_UNDEFINED, nil) {
+		if err := g.stack.Unicast(msg, id); err != nil {
+			peerLogger.Warningf("Error sending gossip digest: %s", err)
+		}
+	}
+}
+
+// neighbors picks up to fanout random peers of type typ, all types if
+// typ is PeerEndpoint_UNDEFINED, other than exclude
+func (g *blockGossip) neighbors(typ pb.PeerEndpoint_Type, exclude *pb.PeerID) []*pb.PeerID {
+	peers, err := g.stack.GetPeers()
+	if err != nil {
+		peerLogger.Errorf("Error getting the peers to gossip with: %s", err)
+		return nil
+	}
+	var ids []*pb.PeerID
+	for _, peer := range peers.Peers {
+		if typ != pb.PeerEndpoint_UNDEFINED && peer.Type != typ {
+			continue
+		}
+		if exclude != nil && peer.ID.Name == exclude.Name {
+			continue
+		}
+		ids = append(ids, peer.ID)
+	}
+	g.randLock.Lock()
+	for i := range ids {
+		j := i + g.rand.Intn(len(ids)-i)
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	g.randLock.Unlock()
+	if len(ids) > g.fanout {
+		ids = ids[:g.fanout]
+	}
+	return ids
+}
+
+// handleDigest replies to the digest of a peer ahead of this one with the
+// digest of this peer, so that it pushes the missing blocks
+func (g *blockGossip) handleDigest(info *pb.BlockchainInfo, reply func(*pb.Message) error) error {
+	if info.Height <= g.stack.GetBlockchainSize() {
+		return nil
+	}
+	msg, err := newGossipDigest(g.stack)
+	if err != nil {
+		return err
+	}
+	return reply(msg)
+}
+
+// addBlock appends the block to the blockchain if it follows the current
+// block and its state delta yields its state hash, and forwards it to fanout
+// random non-validating peers other than the sender if it did. It returns
+// whether the block was appended, blocks already in or ahead of the
+// blockchain being ignored.
+func (g *blockGossip) addBlock(blockState *pb.BlockState, sender *pb.PeerID) (bool, error) {
+	block := blockState.Block
+	if block == nil {
+		return false, fmt.Errorf("No block in the block state")
+	}
+
+	g.Lock()
+	height := g.stack.GetBlockchainSize()
+	if height > 0 {
+		current, err := g.stack.GetBlockByNumber(height - 1)
+		if err != nil {
+			g.Unlock()
+			return false, fmt.Errorf("Error getting block %d: %s", height-1, err)
+		}
+		currentHash, err := g.stack.HashBlock(current)
+		if err != nil {
+			g.Unlock()
+			return false, fmt.Errorf("Error hashing block %d: %s", height-1, err)
+		}
+		if !bytes.Equal(block.PreviousBlockHash, currentHash) {
+			g.Unlock()
+			return false, nil
+		}
+	} else if len(block.PreviousBlockHash) != 0 {
+		g.Unlock()
+		return false, nil
+	}
+	err := g.appendBlock(height, block, blockState.StateDelta)
+	g.Unlock()
+	if err != nil {
+		return false, err
+	}
+	peerLogger.Debugf("Appended gossiped block %d", height)
+
+	// Forward outside the lock, as the neighbors may be forwarding to us
+	msg, err := newBlockAdded(blockState)
+	if err != nil {
+		return true, err
+	}
+	for _, id := range g.neighbors(pb.PeerEndpoint_NON_VALIDATOR, sender) {
+		if err := g.stack.Unicast(msg, id); err != nil {
+			peerLogger.Warningf("Error forwarding block %d: %s", height, err)
+		}
+	}
+	return true, nil
+}
+
+func (g *blockGossip) appendBlock(blockNumber uint64, block *pb.Block, rawDelta []byte) error {
+	delta := statemgmt.NewStateDelta()
+	if len(rawDelta) > 0 {
+		if err := delta.Unmarshal(rawDelta); err != nil {
+			return fmt.Errorf("Error unmarshalling the state delta of block %d: %s", blockNumber, err)
+		}
+	}
+	if err := g.stack.ApplyStateDelta(g, delta); err != nil {
+		return fmt.Errorf("Error applying the state delta of block %d: %s", blockNumber, err)
+	}
+	stateHash, err := g.stack.GetCurrentStateHash()
+	if err != nil {
+		g.stack.RollbackStateDelta(g)
+		return fmt.Errorf("Error getting the state hash of block %d: %s", blockNumber, err)
+	}
+	if !bytes.Equal(stateHash, block.StateHash) {
+		g.stack.RollbackStateDelta(g)
+		return fmt.Errorf("The state delta of block %d yields state hash %x rather than %x", blockNumber, stateHash, block.StateHash)
+	}
+	if err := g.stack.CommitBlockStateDelta(g, blockNumber); err != nil {
+		return fmt.Errorf("Error committing the state delta of block %d: %s", blockNumber, err)
+	}
+	if err := g.stack.PutBlock(blockNumber, block); err != nil {
+		return fmt.Errorf("Error putting block %d: %s", blockNumber, err)
+	}
+	return nil
+}
+
+// newGossipDigest returns a SYNC_GOSSIP_DIGEST message with the
+// BlockchainInfo of the stack
+func newGossipDigest(stack blockSource) (*pb.Message, error) {
+	info := &pb.BlockchainInfo{Height: stack.GetBlockchainSize()}
+	if info.Height > 0 {
+		block, err := stack.GetBlockByNumber(info.Height - 1)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting block %d: %s", info.Height-1, err)
+		}
+		if info.CurrentBlockHash, err = stack.HashBlock(block); err != nil {
+			return nil, fmt.Errorf("Error hashing block %d: %s", info.Height-1, err)
+		}
+		info.PreviousBlockHash = block.PreviousBlockHash
+	}
+	data, err := proto.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling BlockchainInfo: %s", err)
+	}
+	return &pb.Message{Type: pb.Message_SYNC_GOSSIP_DIGEST, Payload: data, Timestamp: util.CreateUtcTimestamp()}, nil
+}
+
+func newBlockAdded(blockState *pb.BlockState) (*pb.Message, error) {
+	data, err := proto.Marshal(blockState)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling BlockState: %s", err)
+	}
+	return &pb.Message{Type: pb.Message_SYNC_BLOCK_ADDED, Payload: data, Timestamp: util.CreateUtcTimestamp()}, nil
+}
+
+// pushMissingBlocks sends the peer whose blockchain info is given, if it is
+// behind on the same chain, up to maxBlocks of the blocks it is missing
+func pushMissingBlocks(stack blockSource, info *pb.BlockchainInfo, maxBlocks int, send func(*pb.Message) error) error {
+	height := stack.GetBlockchainSize()
+	if info.Height >= height {
+		return nil
+	}
+	if info.Height > 0 {
+		block, err := stack.GetBlockByNumber(info.Height - 1)
+		if err != nil {
+			return fmt.Errorf("Error getting block %d: %s", info.Height-1, err)
+		}
+		hash, err := stack.HashBlock(block)
+		if err != nil {
+			return fmt.Errorf("Error hashing block %d: %s", info.Height-1, err)
+		}
+		if !bytes.Equal(hash, info.CurrentBlockHash) {
+			peerLogger.Warningf("Peer with blockchain height %d is not on the same chain, not pushing blocks", info.Height)
+			return nil
+		}
+	}
+	end := height
+	if maxBlocks > 0 && info.Height+uint64(maxBlocks) < end {
+		end = info.Height + uint64(maxBlocks)
+	}
+	for blockNumber := info.Height; blockNumber < end; blockNumber++ {
+		block, err := stack.GetBlockByNumber(blockNumber)
+		if err != nil {
+			return fmt.Errorf("Error getting block %d: %s", blockNumber, err)
+		}
+		delta, err := stack.GetStateDelta(blockNumber)
+		if err != nil {
+			return fmt.Errorf("Error getting the state delta of block %d: %s", blockNumber, err)
+		}
+		if delta == nil {
+			// The delta is no longer retained, the peer has to transfer state
+			peerLogger.Debugf("No state delta for block %d, not pushing blocks", blockNumber)
+			return nil
+		}
+		msg, err := newBlockAdded(&pb.BlockState{Block: block, StateDelta: delta.Marshal()})
+		if err != nil {
+			return err
+		}
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
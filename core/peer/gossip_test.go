@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// chainSource serves blocks and state deltas recorded from another ledger
+type chainSource struct {
+	blocks []*pb.Block
+	deltas []*statemgmt.StateDelta
+}
+
+func (s *chainSource) GetBlockByNumber(blockNumber uint64) (*pb.Block, error) {
+	return s.blocks[blockNumber], nil
+}
+
+func (s *chainSource) GetBlockchainSize() uint64 {
+	return uint64(len(s.blocks))
+}
+
+func (s *chainSource) GetCurrentStateHash() ([]byte, error) {
+	return s.blocks[len(s.blocks)-1].StateHash, nil
+}
+
+func (s *chainSource) HashBlock(block *pb.Block) ([]byte, error) {
+	return block.GetHash()
+}
+
+func (s *chainSource) VerifyBlockchain(start, finish uint64) (uint64, error) {
+	return 0, nil
+}
+
+func (s *chainSource) GetStateSnapshot() (*state.StateSnapshot, error) {
+	return nil, fmt.Errorf("No snapshot")
+}
+
+func (s *chainSource) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
+	return s.deltas[blockNumber], nil
+}
+
+// buildChainSource commits a few blocks to a fresh ledger and records them
+// along with their state deltas
+func buildChainSource(t *testing.T) *chainSource {
+	l := ledger.InitTestLedger(t)
+	source := &chainSource{}
+	for i := 0; i < 4; i++ {
+		l.BeginTxBatch(i)
+		l.TxBegin("txUUID")
+		l.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+		l.TxFinished("txUUID", true)
+		if err := l.CommitTxBatch(i, []*pb.Transaction{}, nil, []byte("proof")); err != nil {
+			t.Fatalf("Error committing block %d: %s", i, err)
+		}
+		block, err := l.GetBlockByNumber(uint64(i))
+		if err != nil {
+			t.Fatalf("Error getting block %d: %s", i, err)
+		}
+		delta, err := l.GetStateDelta(uint64(i))
+		if err != nil {
+			t.Fatalf("Error getting the state delta of block %d: %s", i, err)
+		}
+		source.blocks = append(source.blocks, block)
+		source.deltas = append(source.deltas, delta)
+	}
+	return source
+}
+
+// newGossipPeer returns a peer on a fresh ledger, connected to recording
+// handlers of the given types
+func newGossipPeer(t *testing.T, types ...pb.PeerEndpoint_Type) (*PeerImpl, map[string]*recordingStream) {
+	p := &PeerImpl{
+		handlerMap:    &handlerMap{m: make(map[pb.PeerID]MessageHandler)},
+		ledgerWrapper: &ledgerWrapper{ledger: ledger.InitTestLedger(t)},
+	}
+	streams := make(map[string]*recordingStream)
+	for i, typ := range types {
+		id := &pb.PeerID{Name: fmt.Sprintf("peer%d", i)}
+		stream := &recordingStream{}
+		handler := &Handler{ToPeerEndpoint: &pb.PeerEndpoint{ID: id, Type: typ}, ChatStream: stream}
+		if err := p.RegisterHandler(handler); err != nil {
+			t.Fatalf("Error registering handler: %s", err)
+		}
+		streams[id.Name] = stream
+	}
+	return p, streams
+}
+
+func digestOf(t *testing.T, stack blockSource) *pb.BlockchainInfo {
+	msg, err := newGossipDigest(stack)
+	if err != nil {
+		t.Fatalf("Error creating gossip digest: %s", err)
+	}
+	info := &pb.BlockchainInfo{}
+	if err := proto.Unmarshal(msg.Payload, info); err != nil {
+		t.Fatalf("Error unmarshalling gossip digest: %s", err)
+	}
+	return info
+}
+
+func pushed(t *testing.T, stack blockSource, info *pb.BlockchainInfo, maxBlocks int) []*pb.BlockState {
+	var blockStates []*pb.BlockState
+	send := func(msg *pb.Message) error {
+		blockState := &pb.BlockState{}
+		if err := proto.Unmarshal(msg.Payload, blockState); err != nil {
+			t.Fatalf("Error unmarshalling pushed block: %s", err)
+		}
+		blockStates = append(blockStates, blockState)
+		return nil
+	}
+	if err := pushMissingBlocks(stack, info, maxBlocks, send); err != nil {
+		t.Fatalf("Error pushing blocks: %s", err)
+	}
+	return blockStates
+}
+
+func TestBlockGossip(t *testing.T) {
+	source := buildChainSource(t)
+	p, streams := newGossipPeer(t, pb.PeerEndpoint_VALIDATOR, pb.PeerEndpoint_NON_VALIDATOR,
+		pb.PeerEndpoint_NON_VALIDATOR, pb.PeerEndpoint_NON_VALIDATOR)
+	g := newBlockGossip(p, 2, 2)
+	validator := &pb.PeerID{Name: "peer0"}
+
+	// The source pushes the first blocks to the empty peer
+	blockStates := pushed(t, source, digestOf(t, p), g.maxBlocks)
+	if len(blockStates) != 2 {
+		t.Fatalf("Expected 2 blocks pushed, got %d", len(blockStates))
+	}
+	for i, blockState := range blockStates {
+		if added, err := g.addBlock(blockState, validator); err != nil || !added {
+			t.Fatalf("Expected block %d to be added, got %v (err %v)", i, added, err)
+		}
+	}
+	if added, _ := g.addBlock(blockStates[1], validator); added {
+		t.Fatalf("Expected a block already in the blockchain to be ignored")
+	}
+	if height := p.GetBlockchainSize(); height != 2 {
+		t.Fatalf("Expected blockchain height 2, got %d", height)
+	}
+
+	// Each appended block was forwarded to fanout non-validating peers
+	forwarded := 0
+	for name, stream := range streams {
+		if name == validator.Name && len(stream.sent) > 0 {
+			t.Errorf("Expected no block forwarded to the validating peer")
+		}
+		forwarded += len(stream.sent)
+	}
+	if forwarded != 4 {
+		t.Errorf("Expected 4 blocks forwarded, got %d", forwarded)
+	}
+
+	// The peer pulls the other blocks by replying to the digest of the source
+	var reply *pb.Message
+	if err := g.handleDigest(digestOf(t, source), func(msg *pb.Message) error { reply = msg; return nil }); err != nil || reply == nil {
+		t.Fatalf("Expected a reply to the digest of a peer ahead, got %v (err %v)", reply, err)
+	}
+	info := &pb.BlockchainInfo{}
+	proto.Unmarshal(reply.Payload, info)
+	blockStates = pushed(t, source, info, g.maxBlocks)
+	if len(blockStates) != 2 {
+		t.Fatalf("Expected 2 blocks pushed, got %d", len(blockStates))
+	}
+
+	// A block whose state delta does not yield its state hash is rejected
+	tampered := statemgmt.NewStateDelta()
+	tampered.Set("chaincode1", "key2", []byte("tampered"), nil)
+	if added, err := g.addBlock(&pb.BlockState{Block: blockStates[0].Block, StateDelta: tampered.Marshal()}, validator); err == nil || added {
+		t.Fatalf("Expected a block with a tampered state delta to be rejected")
+	}
+	for _, blockState := range blockStates {
+		if _, err := g.addBlock(blockState, validator); err != nil {
+			t.Fatalf("Error adding block: %s", err)
+		}
+	}
+	if height := p.GetBlockchainSize(); height != 4 {
+		t.Fatalf("Expected blockchain height 4, got %d", height)
+	}
+	value, err := p.ledgerWrapper.ledger.GetState("chaincode1", "key3", true)
+	if err != nil || string(value) != "value3" {
+		t.Fatalf("Expected value3 for chaincode1/key3, got %s (err %v)", value, err)
+	}
+	replied := false
+	if err := g.handleDigest(digestOf(t, source), func(msg *pb.Message) error { replied = true; return nil }); err != nil || replied {
+		t.Fatalf("Expected no reply to the digest of a peer not ahead")
+	}
+
+	// The peer serves the gossiped blocks in turn
+	if blockStates = pushed(t, p, &pb.BlockchainInfo{}, 10); len(blockStates) != 4 {
+		t.Fatalf("Expected the peer to push 4 blocks, got %d", len(blockStates))
+	}
+	fork := digestOf(t, source)
+	fork.Height = 2
+	if blockStates = pushed(t, p, fork, 10); len(blockStates) != 0 {
+		t.Fatalf("Expected no blocks pushed to a peer on another chain, got %d", len(blockStates))
+	}
+}
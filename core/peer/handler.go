@@ -25,7 +25,7 @@ import (
 	"github.com/looplab/fsm"
 	"github.com/spf13/viper"
 
-	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/comm"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -72,6 +72,7 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 			{Name: pb.Message_SYNC_STATE_SNAPSHOT.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_STATE_GET_DELTAS.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_STATE_DELTAS.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_SYNC_GOSSIP_DIGEST.String(), Src: []string{"established"}, Dst: "established"},
 		},
 		fsm.Callbacks{
 			"enter_state":                                           func(e *fsm.Event) { d.enterState(e) },
@@ -85,6 +86,7 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 			"before_" + pb.Message_SYNC_STATE_SNAPSHOT.String():     func(e *fsm.Event) { d.beforeSyncStateSnapshot(e) },
 			"before_" + pb.Message_SYNC_STATE_GET_DELTAS.String():   func(e *fsm.Event) { d.beforeSyncStateGetDeltas(e) },
 			"before_" + pb.Message_SYNC_STATE_DELTAS.String():       func(e *fsm.Event) { d.beforeSyncStateDeltas(e) },
+			"before_" + pb.Message_SYNC_GOSSIP_DIGEST.String():      func(e *fsm.Event) { d.beforeGossipDigest(e) },
 		},
 	)
 
@@ -239,8 +241,57 @@ func (d *Handler) beforeBlockAdded(e *fsm.Event) {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
-	// Add the block and any delta state to the ledger
-	_ = msg
+	// Only the peers taking part in the block gossip add the block to their ledger
+	g := d.gossiper()
+	if g == nil {
+		return
+	}
+	blockState := &pb.BlockState{}
+	if err := proto.Unmarshal(msg.Payload, blockState); err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling BlockState: %s", err))
+		return
+	}
+	if _, err := g.addBlock(blockState, d.ToPeerEndpoint.ID); err != nil {
+		peerLogger.Warningf("Error adding block from %s: %s", d.ToPeerEndpoint.ID, err)
+	}
+}
+
+func (d *Handler) beforeGossipDigest(e *fsm.Event) {
+	peerLogger.Debugf("Received message: %s", e.Event)
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	info := &pb.BlockchainInfo{}
+	if err := proto.Unmarshal(msg.Payload, info); err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling BlockchainInfo: %s", err))
+		return
+	}
+	// Validating peers push blocks too, but do not gossip themselves
+	maxBlocks := GossipMaxBlocks()
+	g := d.gossiper()
+	if g != nil {
+		maxBlocks = g.maxBlocks
+	}
+	if err := pushMissingBlocks(d.Coordinator, info, maxBlocks, d.SendMessage); err != nil {
+		peerLogger.Errorf("Error pushing blocks to %s: %s", d.ToPeerEndpoint.ID, err)
+		return
+	}
+	if g != nil {
+		if err := g.handleDigest(info, d.SendMessage); err != nil {
+			peerLogger.Errorf("Error replying to the gossip digest of %s: %s", d.ToPeerEndpoint.ID, err)
+		}
+	}
+}
+
+// gossiper returns the block gossip of the coordinator, or nil if the peer
+// does not take part in it
+func (d *Handler) gossiper() *blockGossip {
+	if coord, ok := d.Coordinator.(gossipCoordinator); ok {
+		return coord.gossiper()
+	}
+	return nil
 }
 
 func (d *Handler) when(stateToCheck string) bool {
@@ -271,13 +322,72 @@ func (d *Handler) SendMessage(msg *pb.Message) error {
 	d.chatMutex.Lock()
 	defer d.chatMutex.Unlock()
 	peerLogger.Debugf("Sending message to stream of type: %s ", msg.Type)
-	err := d.ChatStream.Send(msg)
+	msg, err := d.encodeMessage(msg)
+	if err != nil {
+		return err
+	}
+	err = d.ChatStream.Send(msg)
 	if err != nil {
 		return fmt.Errorf("Error Sending message through ChatStream: %s", err)
 	}
 	return nil
 }
 
+// encodeMessage compresses the payload of msg if the remote peer accepts it,
+// and checks that the remote peer receives messages this large
+func (d *Handler) encodeMessage(msg *pb.Message) (*pb.Message, error) {
+	metadata := d.ToPeerEndpoint.GetMetadata()
+	if metadata == nil {
+		// the remote peer did not say hello yet, or does not support either
+		return msg, nil
+	}
+	if msg.Type != pb.Message_DISC_HELLO && msg.Compression == pb.Message_NONE && comm.CompressionEnabled() &&
+		len(msg.Payload) >= comm.CompressionThreshold() && acceptsCompression(metadata, comm.CompressionGzip) {
+		compressed, err := comm.Compress(msg.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("Error compressing %s message payload: %s", msg.Type, err)
+		}
+		if len(compressed) < len(msg.Payload) {
+			// the message may be broadcast to other peers, do not modify it
+			compressedMsg := *msg
+			compressedMsg.Payload = compressed
+			compressedMsg.Compression = pb.Message_GZIP
+			msg = &compressedMsg
+		}
+	}
+	if max := int(metadata.MaxMessageSize); max > 0 && proto.Size(msg) > max {
+		return nil, fmt.Errorf("Error sending %s message of %d bytes to %s, which receives messages of at most %d bytes", msg.Type, proto.Size(msg), d.ToPeerEndpoint.ID, max)
+	}
+	return msg, nil
+}
+
+func acceptsCompression(metadata *pb.EndpointMetadata, compression string) bool {
+	for _, c := range metadata.Compression {
+		if c == compression {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeMessage decompresses the payload of a message received from a peer
+func decodeMessage(msg *pb.Message) error {
+	switch msg.Compression {
+	case pb.Message_NONE:
+		return nil
+	case pb.Message_GZIP:
+		payload, err := comm.Decompress(msg.Payload, comm.MaxRecvMessageSize())
+		if err != nil {
+			return fmt.Errorf("Error decompressing %s message payload: %s", msg.Type, err)
+		}
+		msg.Payload = payload
+		msg.Compression = pb.Message_NONE
+		return nil
+	default:
+		return fmt.Errorf("Unsupported compression %s of %s message payload", msg.Compression, msg.Type)
+	}
+}
+
 // start starts the Peer server function
 func (d *Handler) start() error {
 	discPeriod := viper.GetDuration("peer.discovery.period")
@@ -473,9 +583,9 @@ func (d *Handler) sendBlocks(syncBlockRange *pb.SyncBlockRange) {
 //
 // ----------------------------------------------------------------------------
 
-// RequestStateSnapshot request the state snapshot deltas from the other PeerEndpoint, will provide them through the returned channel.
+// RequestStateSnapshot request the state snapshot deltas starting at startSequence from the other PeerEndpoint, will provide them through the returned channel.
 // this will also stop writing any received syncStateSnapshot(s) to channels created from Prior calls to RequestStateSnapshot()
-func (d *Handler) RequestStateSnapshot() (<-chan *pb.SyncStateSnapshot, error) {
+func (d *Handler) RequestStateSnapshot(startSequence uint64) (<-chan *pb.SyncStateSnapshot, error) {
 	d.snapshotRequestHandler.Lock()
 	defer d.snapshotRequestHandler.Unlock()
 	// Reset the handler
@@ -483,6 +593,7 @@ func (d *Handler) RequestStateSnapshot() (<-chan *pb.SyncStateSnapshot, error) {
 
 	// Create the syncStateSnapshotRequest
 	syncStateSnapshotRequest := d.snapshotRequestHandler.createRequest()
+	syncStateSnapshotRequest.StartSequence = startSequence
 	syncStateSnapshotRequestBytes, err := proto.Marshal(syncStateSnapshotRequest)
 	if err != nil {
 		return nil, fmt.Errorf("Error marshaling syncStateSnapshotRequest during GetStateSnapshot: %s", err)
@@ -567,44 +678,16 @@ func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapsh
 	}
 	defer snapshot.Release()
 
-	// Iterate over the state deltas and send to requestor
-	currBlockNumber := snapshot.GetBlockNumber()
-	var sequence uint64
-	// Loop through and send the Deltas
-	for i := 0; snapshot.Next(); i++ {
-		delta := statemgmt.NewStateDelta()
-		k, v := snapshot.GetRawKeyValue()
-		cID, kID := statemgmt.DecodeCompositeKey(k)
-		delta.Set(cID, kID, v, nil)
-
-		deltaAsBytes := delta.Marshal()
-		// Encode a SyncStateSnapsot into the payload
-		sequence = uint64(i)
-		syncStateSnapshot := &pb.SyncStateSnapshot{Delta: deltaAsBytes, Sequence: sequence, BlockNumber: currBlockNumber, Request: syncStateSnapshotRequest}
-
+	send := func(syncStateSnapshot *pb.SyncStateSnapshot) error {
 		syncStateSnapshotBytes, err := proto.Marshal(syncStateSnapshot)
 		if err != nil {
-			peerLogger.Errorf("Error marshalling syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err)
-			break
-		}
-		if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
-			peerLogger.Errorf("Error sending syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err)
-			break
+			return fmt.Errorf("Error marshalling syncStateSnapshot: %s", err)
 		}
+		return d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes})
 	}
-
-	// Now send the terminating message
-	syncStateSnapshot := &pb.SyncStateSnapshot{Delta: []byte{}, Sequence: sequence + 1, BlockNumber: currBlockNumber, Request: syncStateSnapshotRequest}
-	syncStateSnapshotBytes, err := proto.Marshal(syncStateSnapshot)
-	if err != nil {
-		peerLogger.Errorf("Error marshalling terminating syncStateSnapsot message for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err)
-		return
+	if err := sendStateSnapshotDeltas(snapshot, syncStateSnapshotRequest, send); err != nil {
+		peerLogger.Errorf("Error sending state snapshot for correlationId = %d: %s", syncStateSnapshotRequest.CorrelationId, err)
 	}
-	if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
-		peerLogger.Errorf("Error sending terminating syncStateSnapsot for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err)
-		return
-	}
-
 }
 
 // ----------------------------------------------------------------------------
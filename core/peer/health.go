@@ -0,0 +1,164 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	"google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// HealthReporter is implemented by the coordinators which probe the
+// liveness of the peers they chat with
+type HealthReporter interface {
+	GetPeersHealth() (*pb.PeersHealth, error)
+}
+
+// peerHealth is the liveness state machine of a peer chatting with this
+// peer. The peer is pinged periodically, and is SUSPECT once it missed
+// suspectAfter pings in a row, DEAD once it missed deadAfter of them, and
+// ALIVE again as soon as any message is received from it, so that a peer
+// which does not answer pings yet keeps chatting stays alive.
+type peerHealth struct {
+	sync.Mutex
+	suspectAfter int
+	deadAfter    int
+	state        pb.PeerHealth_State
+	seq          uint64 // of the last ping
+	pending      bool   // whether the last ping is unanswered
+	sentAt       time.Time
+	missed       int
+	rtt          time.Duration // smoothed round trip time
+	minRTT       time.Duration
+	maxRTT       time.Duration
+	lastSeen     time.Time
+}
+
+func newPeerHealth(suspectAfter int, deadAfter int) *peerHealth {
+	return &peerHealth{suspectAfter: suspectAfter, deadAfter: deadAfter, lastSeen: time.Now()}
+}
+
+// seen records that a message was received from the peer at now
+func (h *peerHealth) seen(now time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	h.lastSeen = now
+	h.missed = 0
+	h.state = pb.PeerHealth_ALIVE
+}
+
+// ping returns the payload of the ping to send at now, after counting the
+// previous one as missed if nothing was received from the peer since it. It
+// also returns the state of the peer, ping included.
+func (h *peerHealth) ping(now time.Time) ([]byte, pb.PeerHealth_State) {
+	h.Lock()
+	defer h.Unlock()
+	if h.pending && h.lastSeen.Before(h.sentAt) {
+		h.missed++
+		switch {
+		case h.missed >= h.deadAfter:
+			h.state = pb.PeerHealth_DEAD
+		case h.missed >= h.suspectAfter:
+			h.state = pb.PeerHealth_SUSPECT
+		}
+	}
+	h.seq++
+	h.pending = true
+	h.sentAt = now
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, h.seq)
+	return payload, h.state
+}
+
+// pong records the answer received at now to the ping with payload
+func (h *peerHealth) pong(payload []byte, now time.Time) {
+	h.seen(now)
+	if len(payload) != 8 {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	if !h.pending || binary.BigEndian.Uint64(payload) != h.seq {
+		// a late answer to a previous ping
+		return
+	}
+	h.pending = false
+	// as the smoothed round trip time of TCP
+	sample := now.Sub(h.sentAt)
+	if h.rtt == 0 {
+		h.rtt = sample
+	} else {
+		h.rtt += (sample - h.rtt) / 8
+	}
+	if h.minRTT == 0 || sample < h.minRTT {
+		h.minRTT = sample
+	}
+	if sample > h.maxRTT {
+		h.maxRTT = sample
+	}
+}
+
+// roundTripTimes returns the smoothed, minimum and maximum round trip times
+// of the pings answered so far, 0 if none was
+func (h *peerHealth) roundTripTimes() (smoothed time.Duration, min time.Duration, max time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	return h.rtt, h.minRTT, h.maxRTT
+}
+
+// report returns the health of the peer of endpoint
+func (h *peerHealth) report(endpoint *pb.PeerEndpoint) *pb.PeerHealth {
+	h.Lock()
+	defer h.Unlock()
+	return &pb.PeerHealth{
+		ID:            endpoint.ID,
+		Address:       endpoint.Address,
+		State:         h.state,
+		RoundTripTime: uint64(h.rtt / time.Microsecond),
+		MissedPings:   uint32(h.missed),
+		LastSeen:      &google_protobuf.Timestamp{Seconds: h.lastSeen.Unix(), Nanos: int32(h.lastSeen.Nanosecond())},
+	}
+}
+
+type peersHealthByID []*pb.PeerHealth
+
+func (s peersHealthByID) Len() int           { return len(s) }
+func (s peersHealthByID) Less(i, j int) bool { return s[i].ID.Name < s[j].ID.Name }
+func (s peersHealthByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// GetPeersHealth returns the health of the peers chatting with this peer,
+// by peer ID
+func (p *PeerImpl) GetPeersHealth() (*pb.PeersHealth, error) {
+	p.connections.RLock()
+	defer p.connections.RUnlock()
+	health := &pb.PeersHealth{}
+	for conn := range p.connections.m {
+		endpoint, err := conn.handler.To()
+		if err != nil || endpoint.ID == nil {
+			// the peer did not say hello yet
+			continue
+		}
+		health.Peers = append(health.Peers, conn.health.report(&endpoint))
+	}
+	sort.Sort(peersHealthByID(health.Peers))
+	return health, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestPeerHealth(t *testing.T) {
+	h := newPeerHealth(1, 3)
+	endpoint := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Address: "vp1:30303"}
+	start := time.Now()
+
+	first, state := h.ping(start)
+	if state != pb.PeerHealth_ALIVE {
+		t.Fatalf("Expected a peer to be alive before missing a ping, is %s", state)
+	}
+	h.pong(first, start.Add(10*time.Millisecond))
+	second, _ := h.ping(start.Add(time.Second))
+	h.pong(second, start.Add(time.Second+18*time.Millisecond))
+	if health := h.report(endpoint); health.State != pb.PeerHealth_ALIVE || health.RoundTripTime != 11000 {
+		t.Fatalf("Expected an alive peer with a smoothed round trip time of 11ms, got %s", health)
+	}
+
+	// the peer stops answering
+	var payload []byte
+	for i, expected := range []pb.PeerHealth_State{pb.PeerHealth_ALIVE, pb.PeerHealth_SUSPECT, pb.PeerHealth_SUSPECT, pb.PeerHealth_DEAD} {
+		payload, state = h.ping(start.Add(time.Duration(2+i) * time.Second))
+		if state != expected {
+			t.Fatalf("Expected the peer to be %s after %d unanswered pings, is %s", expected, i, state)
+		}
+	}
+	if health := h.report(endpoint); health.MissedPings != 3 || health.ID.Name != "vp1" {
+		t.Fatalf("Expected vp1 to have missed 3 pings, got %s", health)
+	}
+
+	// a late answer does not count towards the round trip time
+	h.pong(first, start.Add(6*time.Second))
+	if health := h.report(endpoint); health.State != pb.PeerHealth_ALIVE || health.RoundTripTime != 11000 || health.MissedPings != 0 {
+		t.Fatalf("Expected the peer to be alive again with the same round trip time, got %s", health)
+	}
+	h.pong(payload, start.Add(6*time.Second))
+
+	// any message proves the peer alive
+	h.ping(start.Add(7 * time.Second))
+	h.seen(start.Add(7*time.Second + time.Millisecond))
+	if _, state := h.ping(start.Add(8 * time.Second)); state != pb.PeerHealth_ALIVE {
+		t.Fatalf("Expected a peer sending messages to stay alive, is %s", state)
+	}
+}
+
+func TestChatConnectionPing(t *testing.T) {
+	stream := &chanStream{sent: make(chan *pb.Message, 10)}
+	conn := &chatConnection{
+		stream:  stream,
+		limiter: newRateLimiter(0, 0, 0, 0),
+		queue:   make(chan *pb.Message, 10),
+		health:  newPeerHealth(1, 2),
+		dead:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	// pings are answered with their payload, without queueing them
+	if err := conn.receive(&pb.Message{Type: pb.Message_DISC_PING, Payload: []byte("ping")}); err != nil {
+		t.Fatalf("Error receiving ping: %s", err)
+	}
+	if pong := <-stream.sent; pong.Type != pb.Message_DISC_PONG || string(pong.Payload) != "ping" {
+		t.Fatalf("Expected a %s with the payload of the ping, got %s", pb.Message_DISC_PONG, pong)
+	}
+	if len(conn.queue) != 0 {
+		t.Fatalf("Expected the ping not to be queued")
+	}
+
+	// a peer which does not answer is disconnected
+	go conn.probe(time.Millisecond, true)
+	select {
+	case <-conn.dead:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a peer not answering pings to be deemed dead")
+	}
+	for len(stream.sent) > 0 {
+		if ping := <-stream.sent; ping.Type != pb.Message_DISC_PING {
+			t.Fatalf("Expected only pings to be sent, got %s", ping.Type)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/comm"
+)
+
+// ConnectPeer has this peer chat with the peer at address, and keep chatting
+// with it as with the peers it discovered. The chat is established in the
+// background, ConnectPeer returns once it is initiated.
+func (p *PeerImpl) ConnectPeer(address string) error {
+	normalized, err := comm.NormalizeAddress(address)
+	if err != nil || normalized == "" {
+		return fmt.Errorf("Invalid peer address %s: %v", address, err)
+	}
+	self, err := GetPeerEndpoint()
+	if err != nil {
+		return fmt.Errorf("Error getting the endpoint of this peer: %s", err)
+	}
+	if localAddress, _ := GetLocalAddress(); comm.SameAddress(normalized, self.Address) || comm.SameAddress(normalized, localAddress) {
+		return fmt.Errorf("Peer address %s is the address of this peer", address)
+	}
+
+	p.discoverNode(normalized)
+	if !p.startChatting(normalized) {
+		peerLogger.Debugf("Already chatting with %s", normalized)
+		return nil
+	}
+	peerLogger.Infof("Connecting to peer %s", normalized)
+	go p.chatWithPeer(normalized, make(chan token, 1))
+	return nil
+}
+
+// DisconnectPeer ends the chats with the peer of the given address or ID, and
+// forgets its address if it was discovered. A peer whose address is a root
+// node is chatted with again once this peer restarts, and a peer may chat
+// with this peer again of its own accord.
+func (p *PeerImpl) DisconnectPeer(peer string) error {
+	addresses := []string{peer}
+	if normalized, err := comm.NormalizeAddress(peer); err == nil && normalized != peer {
+		addresses = append(addresses, normalized)
+	}
+
+	disconnected := false
+	p.connections.RLock()
+	for conn := range p.connections.m {
+		endpoint, err := conn.handler.To()
+		if err != nil || endpoint.ID == nil {
+			// the peer did not say hello yet
+			continue
+		}
+		if endpoint.ID.Name == peer || comm.SameAddress(endpoint.Address, peer) {
+			addresses = append(addresses, endpoint.Address)
+			conn.drop()
+			disconnected = true
+		}
+	}
+	p.connections.RUnlock()
+
+	forgotten := false
+	for _, address := range addresses {
+		if p.keepChatting(address) {
+			p.stopChatting(address)
+			disconnected = true
+		}
+		if p.discoverySvc != nil && p.discoverySvc.RemoveNode(address) {
+			forgotten = true
+		}
+	}
+	if forgotten && DiscoveryPersist() {
+		if err := p.storeDiscoveryList(); err != nil {
+			peerLogger.Errorf("Error storing the discovered peers: %s", err)
+		}
+	}
+	if !disconnected && !forgotten {
+		return fmt.Errorf("Not connected to peer %s", peer)
+	}
+	peerLogger.Infof("Disconnected peer %s", peer)
+	return nil
+}
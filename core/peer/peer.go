@@ -35,6 +35,8 @@ import (
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/metrics"
+	"github.com/hyperledger/fabric/core/tracing"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
 	"github.com/hyperledger/fabric/core/util"
@@ -57,7 +59,7 @@ type token struct{}
 
 // StateRetriever interface for retrieving state deltas, etc.
 type StateRetriever interface {
-	RequestStateSnapshot() (<-chan *pb.SyncStateSnapshot, error)
+	RequestStateSnapshot(startSequence uint64) (<-chan *pb.SyncStateSnapshot, error)
 	RequestStateDeltas(syncBlockRange *pb.SyncBlockRange) (<-chan *pb.SyncStateDeltas, error)
 }
 
@@ -83,6 +85,13 @@ type BlockChainModifier interface {
 	PutBlock(blockNumber uint64, block *pb.Block) error
 }
 
+// StateTransferProgressTracker interface for persisting how far state transfer got
+type StateTransferProgressTracker interface {
+	CommitStateDeltaWithProgress(id interface{}, progress *ledger.StateTransferProgress) error
+	GetStateTransferProgress() (*ledger.StateTransferProgress, error)
+	ClearStateTransferProgress() error
+}
+
 // BlockChainUtil interface for interrogating the block chain
 type BlockChainUtil interface {
 	HashBlock(block *pb.Block) ([]byte, error)
@@ -111,6 +120,7 @@ type MessageHandlerCoordinator interface {
 	BlockChainAccessor
 	BlockChainModifier
 	BlockChainUtil
+	StateTransferProgressTracker
 	StateAccessor
 	RegisterHandler(messageHandler MessageHandler) error
 	DeregisterHandler(messageHandler MessageHandler) error
@@ -140,21 +150,27 @@ func NewPeerClientConnection() (*grpc.ClientConn, error) {
 	return NewPeerClientConnectionWithAddress(viper.GetString("peer.address"))
 }
 
-// GetLocalIP returns the non loopback local IP of the host
+// GetLocalIP returns the non loopback local IP of the host, an IPv4 address
+// if the host has one, or else a global IPv6 address
 func GetLocalIP() string {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
 		return ""
 	}
+	ipv6 := ""
 	for _, address := range addrs {
 		// check the address type and if it is not a loopback then display it
 		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
 			if ipnet.IP.To4() != nil {
 				return ipnet.IP.String()
 			}
+			// link-local addresses are only reachable with their zone
+			if ipv6 == "" && ipnet.IP.IsGlobalUnicast() {
+				ipv6 = ipnet.IP.String()
+			}
 		}
 	}
-	return ""
+	return ipv6
 }
 
 // NewPeerClientConnectionWithAddress Returns a new grpc.ClientConn to the configured local PEER.
@@ -190,6 +206,37 @@ type PeerImpl struct {
 	engine         Engine
 	isValidator    bool
 	discoverySvc   discovery.Discovery
+	gossip         *blockGossip
+	chatting       chattingAddresses
+	connections    chatConnections
+}
+
+// chatConnections are the connections of the chats in progress
+type chatConnections struct {
+	sync.RWMutex
+	m map[*chatConnection]bool
+}
+
+func (cs *chatConnections) add(conn *chatConnection) {
+	cs.Lock()
+	defer cs.Unlock()
+	if cs.m == nil {
+		cs.m = make(map[*chatConnection]bool)
+	}
+	cs.m[conn] = true
+}
+
+func (cs *chatConnections) remove(conn *chatConnection) {
+	cs.Lock()
+	defer cs.Unlock()
+	delete(cs.m, conn)
+}
+
+// chattingAddresses are the addresses of the peers chatWithPeer keeps
+// chatting with
+type chattingAddresses struct {
+	sync.Mutex
+	m map[string]bool
 }
 
 // TransactionProccesor responsible for processing of Transactions
@@ -232,7 +279,12 @@ func NewPeerWithHandler(secHelperFunc func() crypto.Peer, handlerFact HandlerFac
 	}
 	peer.ledgerWrapper = &ledgerWrapper{ledger: ledgerPtr}
 
-	peer.chatWithSomePeers(peer.discoverySvc.GetRootNodes())
+	if GossipEnabled() {
+		peer.gossip = newBlockGossip(peer, GossipFanout(), GossipMaxBlocks())
+		go peer.gossip.run(GossipPeriod())
+	}
+
+	peer.chatWithBootstrapNodes()
 	return peer, nil
 }
 
@@ -269,8 +321,7 @@ func NewPeerWithEngine(secHelperFunc func() crypto.Peer, engFactory EngineFactor
 	if peer.handlerFactory == nil {
 		return nil, errors.New("Cannot supply nil handler factory")
 	}
-	rootNodes := peer.discoverySvc.GetRootNodes()
-	peer.chatWithSomePeers(rootNodes)
+	peer.chatWithBootstrapNodes()
 	return peer, nil
 
 }
@@ -335,8 +386,8 @@ func (p *PeerImpl) PeersDiscovered(peersMessage *pb.PeersMessage) error {
 	p.handlerMap.RLock()
 	defer p.handlerMap.RUnlock()
 	for _, peerEndpoint := range peersMessage.Peers {
-		// Filter out THIS Peer's endpoint
-		if *getHandlerKeyFromPeerEndpoint(thisPeersEndpoint) == *getHandlerKeyFromPeerEndpoint(peerEndpoint) {
+		// Filter out THIS Peer's endpoint, and the peers which cannot be connected to
+		if *getHandlerKeyFromPeerEndpoint(thisPeersEndpoint) == *getHandlerKeyFromPeerEndpoint(peerEndpoint) || !reachable(peerEndpoint) {
 			// NOOP
 		} else if _, ok := p.handlerMap.m[*getHandlerKeyFromPeerEndpoint(peerEndpoint)]; ok == false {
 			// Start chat with Peer
@@ -365,13 +416,18 @@ func (p *PeerImpl) RegisterHandler(messageHandler MessageHandler) error {
 		return fmt.Errorf("Error registering handler: %s", err)
 	}
 	p.handlerMap.Lock()
-	defer p.handlerMap.Unlock()
 	if _, ok := p.handlerMap.m[*key]; ok == true {
+		p.handlerMap.Unlock()
 		// Duplicate, return error
 		return newDuplicateHandlerError(messageHandler)
 	}
 	p.handlerMap.m[*key] = messageHandler
+	p.handlerMap.Unlock()
 	peerLogger.Debugf("registered handler with key: %s", key)
+
+	if peerEndpoint, err := messageHandler.To(); err == nil && reachable(&peerEndpoint) {
+		p.discoverNode(peerEndpoint.Address)
+	}
 	return nil
 }
 
@@ -506,12 +562,13 @@ func (p *PeerImpl) sendTransactionsToLocalEngine(transaction *pb.Transaction) *p
 	return response
 }
 
-// chatWithSomePeers initiates chat with 1 or all peers according to whether the node is a validator or not
+// chatWithSomePeers initiates chat with 1 or all peers according to whether the node is a validator or not.
+// An outbound only node chats with all of them, as no peer connects to it.
 func (p *PeerImpl) chatWithSomePeers(peers []string) {
 
 	peerCountToChatWith := 1
 
-	if p.isValidator {
+	if p.isValidator || OutboundOnly() {
 		peerCountToChatWith = len(peers)
 	}
 
@@ -523,7 +580,7 @@ func (p *PeerImpl) chatWithSomePeers(peers []string) {
 		}
 		// Skip ourselves
 		if pe, err := GetPeerEndpoint(); err == nil {
-			if rootNode == pe.Address {
+			if localAddress, _ := GetLocalAddress(); comm.SameAddress(rootNode, pe.Address) || comm.SameAddress(rootNode, localAddress) {
 				peerLogger.Debugf(fmt.Sprintf("Skipping my own address(%v)", rootNode))
 				continue
 			}
@@ -531,14 +588,58 @@ func (p *PeerImpl) chatWithSomePeers(peers []string) {
 			peerLogger.Errorf("Failed obtaining peer endpoint, %v", err)
 			return
 		}
+		// Skip the peers we keep chatting with already
+		if !p.startChatting(rootNode) {
+			peerLogger.Debugf("Already chatting with %v", rootNode)
+			continue
+		}
 
 		go p.chatWithPeer(rootNode, chatTokens)
 	}
 }
 
+// startChatting records that chatWithPeer keeps chatting with the peer,
+// returning false if it does already
+func (p *PeerImpl) startChatting(peerAddress string) bool {
+	p.chatting.Lock()
+	defer p.chatting.Unlock()
+	if p.chatting.m == nil {
+		p.chatting.m = make(map[string]bool)
+	}
+	if p.chatting.m[peerAddress] {
+		return false
+	}
+	p.chatting.m[peerAddress] = true
+	return true
+}
+
+// stopChatting has chatWithPeer stop chatting with the peer once the chat in
+// progress ends, e.g. as the peer is no longer a root node
+func (p *PeerImpl) stopChatting(peerAddress string) {
+	p.chatting.Lock()
+	defer p.chatting.Unlock()
+	delete(p.chatting.m, peerAddress)
+}
+
+// keepChatting returns whether chatWithPeer keeps chatting with the peer
+func (p *PeerImpl) keepChatting(peerAddress string) bool {
+	p.chatting.Lock()
+	defer p.chatting.Unlock()
+	return p.chatting.m[peerAddress]
+}
+
+// chatWithPeer chats with the peer until the chat ends, and then chats with it
+// again, backing off exponentially while it cannot be reached. Each chat
+// holds a token, so that a node limited to fewer chats than peers gives the
+// other peers their turn while one cannot be reached.
 func (p *PeerImpl) chatWithPeer(peerAddress string, chatTokens chan token) error {
+	backoff := newReconnectBackoff()
 	for {
-		time.Sleep(1 * time.Second)
+		time.Sleep(backoff.next())
+		if !p.keepChatting(peerAddress) {
+			peerLogger.Debugf("Stopped chatting with peer address: %s", peerAddress)
+			return nil
+		}
 
 		// acquire token
 		chatTokens <- token{}
@@ -553,9 +654,10 @@ func (p *PeerImpl) chatWithPeer(peerAddress string, chatTokens chan token) error
 			continue
 		}
 		serverClient := pb.NewPeerClient(conn)
-		ctx := context.Background()
+		ctx, cancel := context.WithCancel(context.Background())
 		stream, err := serverClient.Chat(ctx)
 		if err != nil {
+			cancel()
 			e := fmt.Errorf("Error establishing chat with peer address=%s:  %s", peerAddress, err)
 			peerLogger.Errorf("%s", e.Error())
 			// relinquish token
@@ -563,15 +665,17 @@ func (p *PeerImpl) chatWithPeer(peerAddress string, chatTokens chan token) error
 			continue
 		}
 		peerLogger.Debugf("Established Chat with peer address: %s", peerAddress)
+		backoff.reset()
 
 		err = p.handleChat(ctx, stream, true)
 		stream.CloseSend()
+		// end the stream if the chat was ended by this peer
+		cancel()
+		// relinquish token
+		<-chatTokens
 		if err != nil {
-			e := fmt.Errorf("Ending chat with peer address=%s due to error:  %s", peerAddress, err)
-			peerLogger.Error(e.Error())
-			return e
+			peerLogger.Errorf("Ending chat with peer address=%s due to error, reconnecting:  %s", peerAddress, err)
 		}
-
 	}
 }
 
@@ -579,38 +683,109 @@ func (p *PeerImpl) chatWithPeer(peerAddress string, chatTokens chan token) error
 func (p *PeerImpl) handleChat(ctx context.Context, stream ChatStream, initiatedStream bool) error {
 	deadline, ok := ctx.Deadline()
 	peerLogger.Debugf("Current context deadline = %s, ok = %v", deadline, ok)
-	handler, err := p.handlerFactory(p, stream, initiatedStream, nil)
+	defer metrics.StreamOpened("peer")()
+	conn := newChatConnection(stream)
+	if SignMessages() {
+		conn.signer = &messageSigner{secHelper: p.secHelper}
+	}
+	handler, err := p.handlerFactory(p, conn, initiatedStream, nil)
 	if err != nil {
 		return fmt.Errorf("Error creating handler during handleChat initiation: %s", err)
 	}
 	defer handler.Stop()
-	for {
-		in, err := stream.Recv()
-		if err == io.EOF {
-			peerLogger.Debug("Received EOF, ending Chat")
-			return nil
+	conn.handler = handler
+	go conn.handle(handler)
+	defer conn.close()
+	if HealthPingPeriod() > 0 {
+		go conn.probe(HealthPingPeriod(), HealthDisconnectDead())
+	}
+	p.connections.add(conn)
+	defer p.connections.remove(conn)
+
+	// Receive in a separate go routine, so that a dead peer can be
+	// disconnected while receiving blocks
+	received := make(chan *pb.Message)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case received <- in:
+			case <-conn.stopped:
+				return
+			}
 		}
-		if err != nil {
+	}()
+	for {
+		select {
+		case in := <-received:
+			if err = conn.receive(in); err != nil {
+				e := fmt.Errorf("Error during Chat, disconnecting: %s", err)
+				peerLogger.Error(e.Error())
+				return e
+			}
+		case err = <-failed:
+			if err == io.EOF {
+				peerLogger.Debug("Received EOF, ending Chat")
+				return nil
+			}
 			e := fmt.Errorf("Error during Chat, stopping handler: %s", err)
 			peerLogger.Error(e.Error())
 			return e
-		}
-		err = handler.HandleMessage(in)
-		if err != nil {
-			peerLogger.Errorf("Error handling message: %s", err)
-			//return err
+		case <-conn.dead:
+			e := fmt.Errorf("Error during Chat, disconnecting dead peer %s: missed %d pings", conn.peerID(), HealthDeadAfter())
+			peerLogger.Error(e.Error())
+			return e
+		case <-conn.dropped:
+			peerLogger.Infof("Ending Chat with peer %s, disconnected", conn.peerID())
+			return nil
 		}
 	}
 }
 
 //ExecuteTransaction executes transactions decides to do execute in dev or prod mode
+//Transactions other than queries are recorded as pending in the ledger until
+//they are committed, for their status to be polled
 func (p *PeerImpl) ExecuteTransaction(transaction *pb.Transaction) (response *pb.Response) {
+	// the transaction starts its trace here unless the client or the peer
+	// which passed it on started it, the next spans being children of this one
+	span := tracing.StartSpan("submit", transaction.TraceContext)
+	if span != nil {
+		span.SetTag("txid", transaction.Uuid)
+		span.SetTag("type", transaction.Type)
+		transaction.TraceContext = span.Context()
+		defer func() {
+			if response.Status == pb.Response_FAILURE {
+				span.SetTag("error", string(response.Msg))
+			}
+			span.Finish()
+		}()
+	}
+	if err := ledger.CheckLedgerID(transaction.LedgerID); err != nil {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+	}
 	if p.isValidator {
 		response = p.sendTransactionsToLocalEngine(transaction)
 	} else {
 		peerAddress := p.discoverySvc.GetRandomNode()
 		response = p.SendTransactionsToPeer(peerAddress, transaction)
 	}
+	if response.Status == pb.Response_FAILURE || transaction.Type == pb.Transaction_CHAINCODE_QUERY {
+		return response
+	}
+	if transaction.LedgerID == "" {
+		p.ledgerWrapper.RLock()
+		p.ledgerWrapper.ledger.TxSubmitted(transaction.Uuid)
+		p.ledgerWrapper.RUnlock()
+	} else if lgr, err := ledger.GetLedgerByID(transaction.LedgerID); err == nil {
+		lgr.TxSubmitted(transaction.Uuid)
+	} else {
+		peerLogger.Errorf("Error getting the ledger %s of transaction %s: %s", transaction.LedgerID, transaction.Uuid, err)
+	}
 	return response
 }
 
@@ -691,6 +866,36 @@ func (p *PeerImpl) CommitStateDelta(id interface{}) error {
 	return p.ledgerWrapper.ledger.CommitStateDelta(id)
 }
 
+// CommitStateDeltaWithProgress makes the result of ApplyStateDelta permanent
+// and records the state transfer progress along with it
+func (p *PeerImpl) CommitStateDeltaWithProgress(id interface{}, progress *ledger.StateTransferProgress) error {
+	p.ledgerWrapper.Lock()
+	defer p.ledgerWrapper.Unlock()
+	return p.ledgerWrapper.ledger.CommitStateDeltaWithProgress(id, progress)
+}
+
+// GetStateTransferProgress returns the recorded state transfer progress, if any
+func (p *PeerImpl) GetStateTransferProgress() (*ledger.StateTransferProgress, error) {
+	p.ledgerWrapper.RLock()
+	defer p.ledgerWrapper.RUnlock()
+	return p.ledgerWrapper.ledger.GetStateTransferProgress()
+}
+
+// ClearStateTransferProgress removes the recorded state transfer progress
+func (p *PeerImpl) ClearStateTransferProgress() error {
+	p.ledgerWrapper.Lock()
+	defer p.ledgerWrapper.Unlock()
+	return p.ledgerWrapper.ledger.ClearStateTransferProgress()
+}
+
+// CommitBlockStateDelta makes the result of ApplyStateDelta permanent as the
+// state delta of the block blockNumber
+func (p *PeerImpl) CommitBlockStateDelta(id interface{}, blockNumber uint64) error {
+	p.ledgerWrapper.Lock()
+	defer p.ledgerWrapper.Unlock()
+	return p.ledgerWrapper.ledger.CommitBlockStateDelta(id, blockNumber)
+}
+
 // RollbackStateDelta undoes the results of ApplyStateDelta to revert
 // the current state back to the state before ApplyStateDelta was invoked
 func (p *PeerImpl) RollbackStateDelta(id interface{}) error {
@@ -727,6 +932,10 @@ func (p *PeerImpl) PutBlock(blockNumber uint64, block *pb.Block) error {
 	return p.ledgerWrapper.ledger.PutRawBlock(block, blockNumber)
 }
 
+func (p *PeerImpl) gossiper() *blockGossip {
+	return p.gossip
+}
+
 // NewOpenchainDiscoveryHello constructs a new HelloMessage for sending
 func (p *PeerImpl) NewOpenchainDiscoveryHello() (*pb.Message, error) {
 	helloMessage, err := p.newHelloMessage()
@@ -19,6 +19,7 @@ package peer
 import (
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"testing"
 	"time"
@@ -122,3 +123,54 @@ func TestServer_Chat(t *testing.T) {
 	t.Skip()
 	performChat(t, peerClientConn)
 }
+
+type recordingStream struct {
+	sent []*pb.Message
+}
+
+func (s *recordingStream) Send(msg *pb.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *recordingStream) Recv() (*pb.Message, error) {
+	return nil, io.EOF
+}
+
+func TestMessageCompression(t *testing.T) {
+	stream := &recordingStream{}
+	handler := &Handler{ChatStream: stream}
+	payload := make([]byte, 4096)
+	msg := &pb.Message{Type: pb.Message_SYNC_BLOCKS, Payload: payload}
+
+	// peers which did not advertise their metadata get uncompressed messages
+	handler.ToPeerEndpoint = &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}}
+	if err := handler.SendMessage(msg); err != nil {
+		t.Fatalf("Failed to send message: %s", err)
+	}
+	if stream.sent[0].Compression != pb.Message_NONE {
+		t.Fatalf("Expected the message to a peer without metadata to be uncompressed")
+	}
+
+	handler.ToPeerEndpoint.Metadata = &pb.EndpointMetadata{Compression: []string{"gzip"}, MaxMessageSize: 1024}
+	if err := handler.SendMessage(msg); err != nil {
+		t.Fatalf("Failed to send message: %s", err)
+	}
+	sent := stream.sent[1]
+	if sent.Compression != pb.Message_GZIP || len(sent.Payload) >= len(payload) {
+		t.Fatalf("Expected the payload to be compressed, got %s payload of %d bytes", sent.Compression, len(sent.Payload))
+	}
+	if len(msg.Payload) != len(payload) || msg.Compression != pb.Message_NONE {
+		t.Fatalf("Expected the message sent not to be modified")
+	}
+	if err := decodeMessage(sent); err != nil || len(sent.Payload) != len(payload) {
+		t.Fatalf("Expected the payload to decompress to %d bytes, got %d (%v)", len(payload), len(sent.Payload), err)
+	}
+
+	// incompressible messages above the size the peer receives are refused
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	if err := handler.SendMessage(&pb.Message{Type: pb.Message_SYNC_BLOCKS, Payload: random}); err == nil {
+		t.Fatalf("Expected a message larger than the peer receives to be refused")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// messageSigner signs the messages a peer sends on a chat stream with its
+// enrollment key, and verifies the messages it receives against the
+// enrollment certificate of the remote peer, identified by the pkiID of its
+// hello. The hello messages are signed over their payload when they are
+// created, and verified by the handler, so they are left as they are.
+type messageSigner struct {
+	sync.Mutex
+	secHelper crypto.Peer
+	pkiID     []byte // of the remote peer, nil until it said hello
+}
+
+// signedBytes returns the bytes of msg its signature is over, which are the
+// message without signature
+func signedBytes(msg *pb.Message) ([]byte, error) {
+	unsigned := *msg
+	unsigned.Signature = nil
+	return proto.Marshal(&unsigned)
+}
+
+// sign returns a signed copy of msg, as it may be broadcast to other peers
+func (s *messageSigner) sign(msg *pb.Message) (*pb.Message, error) {
+	if msg.Type == pb.Message_DISC_HELLO {
+		return msg, nil
+	}
+	raw, err := signedBytes(msg)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling %s message to sign: %s", msg.Type, err)
+	}
+	signature, err := s.secHelper.Sign(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Error signing %s message: %s", msg.Type, err)
+	}
+	signed := *msg
+	signed.Signature = signature
+	return &signed, nil
+}
+
+// verify returns an error unless msg is signed by the remote peer
+func (s *messageSigner) verify(msg *pb.Message) error {
+	s.Lock()
+	defer s.Unlock()
+	if msg.Type == pb.Message_DISC_HELLO {
+		hello := &pb.HelloMessage{}
+		if err := proto.Unmarshal(msg.Payload, hello); err != nil {
+			return fmt.Errorf("Error unmarshalling HelloMessage: %s", err)
+		}
+		var pkiID []byte
+		if hello.PeerEndpoint != nil {
+			pkiID = hello.PeerEndpoint.PkiID
+		}
+		if len(pkiID) == 0 {
+			return fmt.Errorf("Received %s without pkiID", msg.Type)
+		}
+		if s.pkiID != nil && !bytes.Equal(s.pkiID, pkiID) {
+			return fmt.Errorf("Received %s from another peer than the first one", msg.Type)
+		}
+		s.pkiID = pkiID
+		return nil
+	}
+	if s.pkiID == nil {
+		return fmt.Errorf("Received %s message before %s", msg.Type, pb.Message_DISC_HELLO)
+	}
+	if len(msg.Signature) == 0 {
+		return fmt.Errorf("Received unsigned %s message", msg.Type)
+	}
+	raw, err := signedBytes(msg)
+	if err != nil {
+		return fmt.Errorf("Error marshalling %s message to verify: %s", msg.Type, err)
+	}
+	if err := s.secHelper.Verify(s.pkiID, msg.Signature, raw); err != nil {
+		return fmt.Errorf("Invalid signature of %s message: %s", msg.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// mockSecHelper signs a message by prefixing it with its ID, which is its
+// verification key
+type mockSecHelper struct {
+	crypto.Peer
+	id []byte
+}
+
+func (p *mockSecHelper) Sign(msg []byte) ([]byte, error) {
+	return append(append([]byte{}, p.id...), msg...), nil
+}
+
+func (p *mockSecHelper) Verify(vkID, signature, message []byte) error {
+	if !bytes.Equal(signature, append(append([]byte{}, vkID...), message...)) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+func TestMessageSigning(t *testing.T) {
+	alice := &messageSigner{secHelper: &mockSecHelper{id: []byte("alice")}}
+	bob := &messageSigner{secHelper: &mockSecHelper{id: []byte("bob")}}
+
+	msg := &pb.Message{Type: pb.Message_SYNC_BLOCK_ADDED, Payload: []byte("block")}
+	signed, err := alice.sign(msg)
+	if err != nil {
+		t.Fatalf("Error signing message: %s", err)
+	}
+	if msg.Signature != nil {
+		t.Fatalf("Expected the message signed not to be modified")
+	}
+	if err := bob.verify(signed); err == nil {
+		t.Fatalf("Expected a message received before the hello of its sender to be rejected")
+	}
+
+	hello, err := proto.Marshal(&pb.HelloMessage{PeerEndpoint: &pb.PeerEndpoint{ID: &pb.PeerID{Name: "alice"}, PkiID: []byte("alice")}})
+	if err != nil {
+		t.Fatalf("Error marshalling hello: %s", err)
+	}
+	helloMsg := &pb.Message{Type: pb.Message_DISC_HELLO, Payload: hello, Signature: []byte("signed over the payload")}
+	if signedHello, _ := alice.sign(helloMsg); signedHello != helloMsg {
+		t.Fatalf("Expected the hello to be left as it was signed")
+	}
+	if err := bob.verify(helloMsg); err != nil {
+		t.Fatalf("Error verifying hello: %s", err)
+	}
+	if err := bob.verify(signed); err != nil {
+		t.Fatalf("Error verifying a message signed by alice: %s", err)
+	}
+
+	tampered := *signed
+	tampered.Payload = []byte("another block")
+	if err := bob.verify(&tampered); err == nil {
+		t.Fatalf("Expected a tampered message to be rejected")
+	}
+	if err := bob.verify(msg); err == nil {
+		t.Fatalf("Expected an unsigned message to be rejected")
+	}
+	spoofed, _ := (&messageSigner{secHelper: &mockSecHelper{id: []byte("mallory")}}).sign(msg)
+	if err := bob.verify(spoofed); err == nil {
+		t.Fatalf("Expected a message signed by another peer to be rejected")
+	}
+	otherHello, _ := proto.Marshal(&pb.HelloMessage{PeerEndpoint: &pb.PeerEndpoint{ID: &pb.PeerID{Name: "mallory"}, PkiID: []byte("mallory")}})
+	if err := bob.verify(&pb.Message{Type: pb.Message_DISC_HELLO, Payload: otherHello}); err == nil {
+		t.Fatalf("Expected a hello from another peer on the same stream to be rejected")
+	}
+
+	// a connection drops the messages which cannot be verified
+	conn := &chatConnection{
+		stream:  &chanStream{sent: make(chan *pb.Message, 10)},
+		limiter: newRateLimiter(0, 0, 0, 0),
+		queue:   make(chan *pb.Message, 10),
+		health:  newPeerHealth(1, 3),
+		signer:  bob,
+	}
+	for _, m := range []*pb.Message{&tampered, msg, signed} {
+		if err := conn.receive(m); err != nil {
+			t.Fatalf("Error receiving message: %s", err)
+		}
+	}
+	if len(conn.queue) != 1 || <-conn.queue != signed {
+		t.Fatalf("Expected only the message signed by alice to be queued")
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// SnapshotServer serves consistent snapshots of the local state so that new
+// peers can bootstrap without replaying the whole blockchain
+type SnapshotServer struct {
+	ledger *ledger.Ledger
+}
+
+// NewSnapshotServer returns a SnapshotServer serving the state of the local ledger
+func NewSnapshotServer() (*SnapshotServer, error) {
+	l, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting ledger for SnapshotServer: %s", err)
+	}
+	return &SnapshotServer{ledger: l}, nil
+}
+
+// GetStateSnapshot streams the current state to the requestor. With security
+// enabled, the requestor has to present a verified TLS client certificate.
+func (s *SnapshotServer) GetStateSnapshot(request *pb.SyncStateSnapshotRequest, stream pb.Snapshot_GetStateSnapshotServer) error {
+	if SecurityEnabled() {
+		cert := verifiedClientCertificate(stream.Context())
+		if cert == nil {
+			peerLogger.Warning("Denied state snapshot to a caller without a verified client certificate")
+			return grpc.Errorf(codes.PermissionDenied, "State snapshots require a verified client certificate")
+		}
+		peerLogger.Debugf("Serving state snapshot to %s", cert.Subject.CommonName)
+	}
+	snapshot, err := s.ledger.GetStateSnapshot()
+	if err != nil {
+		return fmt.Errorf("Error getting state snapshot: %s", err)
+	}
+	defer snapshot.Release()
+	peerLogger.Debugf("Serving state snapshot for block %d", snapshot.GetBlockNumber())
+	return sendStateSnapshotDeltas(snapshot, request, stream.Send)
+}
+
+// verifiedClientCertificate returns the verified TLS client certificate of the
+// caller, or nil
+func verifiedClientCertificate(ctx context.Context) *x509.Certificate {
+	authInfo, ok := credentials.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := authInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return tlsInfo.State.VerifiedChains[0][0]
+}
+
+// sendStateSnapshotDeltas passes every key of the snapshot to send as a separate
+// SyncStateSnapshot, followed by the terminating message with an empty delta.
+// Keys before the start sequence of the request are skipped.
+func sendStateSnapshotDeltas(snapshot *state.StateSnapshot, request *pb.SyncStateSnapshotRequest, send func(*pb.SyncStateSnapshot) error) error {
+	currBlockNumber := snapshot.GetBlockNumber()
+	var sequence uint64
+	for ; snapshot.Next(); sequence++ {
+		if sequence < request.StartSequence {
+			continue
+		}
+		delta := statemgmt.NewStateDelta()
+		k, v := snapshot.GetRawKeyValue()
+		cID, kID := statemgmt.DecodeCompositeKey(k)
+		delta.Set(cID, kID, v, nil)
+
+		syncStateSnapshot := &pb.SyncStateSnapshot{Delta: delta.Marshal(), Sequence: sequence, BlockNumber: currBlockNumber, Request: request}
+		if err := send(syncStateSnapshot); err != nil {
+			return fmt.Errorf("Error sending syncStateSnapshot for BlockNum = %d: %s", currBlockNumber, err)
+		}
+	}
+
+	syncStateSnapshot := &pb.SyncStateSnapshot{Delta: []byte{}, Sequence: sequence, BlockNumber: currBlockNumber, Request: request}
+	if err := send(syncStateSnapshot); err != nil {
+		return fmt.Errorf("Error sending terminating syncStateSnapshot for BlockNum = %d: %s", currBlockNumber, err)
+	}
+	return nil
+}
+
+// BootstrapFromSnapshot installs the state snapshot served by the peer at the
+// given address, together with the genesis block and the block the snapshot
+// corresponds to. Nothing is done if the local ledger already has blocks. The
+// blocks following the snapshot are retrieved by the regular synchronization.
+func BootstrapFromSnapshot(address string) error {
+	l, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Error getting ledger for bootstrap: %s", err)
+	}
+	if size := l.GetBlockchainSize(); size > 0 {
+		peerLogger.Infof("Ledger already has %d blocks, not bootstrapping from snapshot", size)
+		return nil
+	}
+
+	conn, err := NewPeerClientConnectionWithAddress(address)
+	if err != nil {
+		return fmt.Errorf("Error connecting to snapshot peer %s: %s", address, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := pb.NewSnapshotClient(conn).GetStateSnapshot(ctx, &pb.SyncStateSnapshotRequest{})
+	if err != nil {
+		return fmt.Errorf("Error requesting state snapshot from %s: %s", address, err)
+	}
+	openchainClient := pb.NewOpenchainClient(conn)
+	getBlock := func(blockNumber uint64) (*pb.Block, error) {
+		return openchainClient.GetBlockByNumber(ctx, &pb.BlockNumber{Number: blockNumber})
+	}
+
+	blockNumber, err := installStateSnapshot(l, stream, getBlock)
+	if err != nil {
+		return err
+	}
+	peerLogger.Infof("Bootstrapped from state snapshot of %s at block %d", address, blockNumber)
+	return nil
+}
+
+type stateSnapshotReceiver interface {
+	Recv() (*pb.SyncStateSnapshot, error)
+}
+
+// installStateSnapshot applies the snapshot received from stream to the empty
+// ledger, verifies it against the state hash of the block it corresponds to
+// and stores that block and the genesis block. On error the state is emptied.
+func installStateSnapshot(l *ledger.Ledger, stream stateSnapshotReceiver, getBlock func(uint64) (*pb.Block, error)) (uint64, error) {
+	if size := l.GetBlockchainSize(); size > 0 {
+		return 0, fmt.Errorf("Cannot install state snapshot, ledger already has %d blocks", size)
+	}
+
+	blockNumber, err := applyStateSnapshot(l, stream)
+	if err == nil {
+		err = putSnapshotBlocks(l, blockNumber, getBlock)
+	}
+	if err != nil {
+		if emptyErr := l.DeleteALLStateKeysAndValues(); emptyErr != nil {
+			peerLogger.Errorf("Error emptying state after failed snapshot install: %s", emptyErr)
+		}
+		return 0, err
+	}
+	return blockNumber, nil
+}
+
+func applyStateSnapshot(l *ledger.Ledger, stream stateSnapshotReceiver) (uint64, error) {
+	if err := l.DeleteALLStateKeysAndValues(); err != nil {
+		return 0, fmt.Errorf("Error emptying state before snapshot install: %s", err)
+	}
+
+	var blockNumber uint64
+	for sequence := uint64(0); ; sequence++ {
+		syncStateSnapshot, err := stream.Recv()
+		if err == io.EOF {
+			return 0, fmt.Errorf("State snapshot stream ended before the terminating message")
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Error receiving state snapshot: %s", err)
+		}
+		if syncStateSnapshot.Sequence != sequence {
+			return 0, fmt.Errorf("Received state snapshot message with sequence %d, expected %d", syncStateSnapshot.Sequence, sequence)
+		}
+		if sequence == 0 {
+			blockNumber = syncStateSnapshot.BlockNumber
+		} else if syncStateSnapshot.BlockNumber != blockNumber {
+			return 0, fmt.Errorf("Received state snapshot message for block %d, expected %d", syncStateSnapshot.BlockNumber, blockNumber)
+		}
+		if len(syncStateSnapshot.Delta) == 0 {
+			return blockNumber, nil
+		}
+
+		delta := statemgmt.NewStateDelta()
+		if err := delta.Unmarshal(syncStateSnapshot.Delta); err != nil {
+			return 0, fmt.Errorf("Error unmarshalling state snapshot delta: %s", err)
+		}
+		if err := l.ApplyStateDelta(syncStateSnapshot, delta); err != nil {
+			return 0, fmt.Errorf("Error applying state snapshot delta: %s", err)
+		}
+		if err := l.CommitStateDelta(syncStateSnapshot); err != nil {
+			return 0, fmt.Errorf("Error committing state snapshot delta: %s", err)
+		}
+	}
+}
+
+func putSnapshotBlocks(l *ledger.Ledger, blockNumber uint64, getBlock func(uint64) (*pb.Block, error)) error {
+	block, err := getBlock(blockNumber)
+	if err != nil {
+		return fmt.Errorf("Error getting block %d of state snapshot: %s", blockNumber, err)
+	}
+	stateHash, err := l.GetTempStateHash()
+	if err != nil {
+		return fmt.Errorf("Error computing hash of installed state: %s", err)
+	}
+	if !bytes.Equal(stateHash, block.StateHash) {
+		return fmt.Errorf("Hash of installed state %x does not match state hash %x of block %d", stateHash, block.StateHash, blockNumber)
+	}
+
+	// The genesis block is needed to verify the hashing configuration on start up
+	if blockNumber > 0 {
+		genesisBlock, err := getBlock(0)
+		if err != nil {
+			return fmt.Errorf("Error getting genesis block: %s", err)
+		}
+		if err := l.PutRawBlock(genesisBlock, 0); err != nil {
+			return fmt.Errorf("Error storing genesis block: %s", err)
+		}
+	}
+	if err := l.PutRawBlock(block, blockNumber); err != nil {
+		return fmt.Errorf("Error storing block %d of state snapshot: %s", blockNumber, err)
+	}
+	return nil
+}
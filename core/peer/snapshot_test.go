@@ -0,0 +1,191 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type mockSnapshotStream struct {
+	messages []*pb.SyncStateSnapshot
+}
+
+func (m *mockSnapshotStream) Recv() (*pb.SyncStateSnapshot, error) {
+	if len(m.messages) == 0 {
+		return nil, io.EOF
+	}
+	msg := m.messages[0]
+	m.messages = m.messages[1:]
+	return msg, nil
+}
+
+type mockSnapshotServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	messages []*pb.SyncStateSnapshot
+}
+
+func (m *mockSnapshotServerStream) Context() context.Context {
+	return m.ctx
+}
+
+func (m *mockSnapshotServerStream) Send(msg *pb.SyncStateSnapshot) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// buildSnapshotSource commits a few blocks to a fresh ledger and returns the
+// streamed state snapshot and all blocks of the chain
+func buildSnapshotSource(t *testing.T) ([]*pb.SyncStateSnapshot, []*pb.Block) {
+	l := ledger.InitTestLedger(t)
+	for i := 0; i < 3; i++ {
+		l.BeginTxBatch(i)
+		l.TxBegin("txUUID")
+		l.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+		l.SetState("chaincode2", "key", []byte(fmt.Sprintf("value%d", i)))
+		l.TxFinished("txUUID", true)
+		if err := l.CommitTxBatch(i, []*pb.Transaction{}, nil, []byte("proof")); err != nil {
+			t.Fatalf("Error committing block %d: %s", i, err)
+		}
+	}
+
+	snapshot, err := l.GetStateSnapshot()
+	if err != nil {
+		t.Fatalf("Error getting state snapshot: %s", err)
+	}
+	defer snapshot.Release()
+	var messages []*pb.SyncStateSnapshot
+	send := func(msg *pb.SyncStateSnapshot) error {
+		messages = append(messages, msg)
+		return nil
+	}
+	if err := sendStateSnapshotDeltas(snapshot, &pb.SyncStateSnapshotRequest{}, send); err != nil {
+		t.Fatalf("Error streaming state snapshot: %s", err)
+	}
+
+	var blocks []*pb.Block
+	for i := uint64(0); i < l.GetBlockchainSize(); i++ {
+		block, err := l.GetBlockByNumber(i)
+		if err != nil {
+			t.Fatalf("Error getting block %d: %s", i, err)
+		}
+		blocks = append(blocks, block)
+	}
+	return messages, blocks
+}
+
+func TestInstallStateSnapshot(t *testing.T) {
+	messages, blocks := buildSnapshotSource(t)
+	getBlock := func(blockNumber uint64) (*pb.Block, error) {
+		return blocks[blockNumber], nil
+	}
+
+	l := ledger.InitTestLedger(t)
+	blockNumber, err := installStateSnapshot(l, &mockSnapshotStream{messages}, getBlock)
+	if err != nil {
+		t.Fatalf("Error installing state snapshot: %s", err)
+	}
+	if blockNumber != 2 {
+		t.Fatalf("Expected snapshot for block 2, got %d", blockNumber)
+	}
+	if size := l.GetBlockchainSize(); size != 3 {
+		t.Fatalf("Expected blockchain height 3, got %d", size)
+	}
+	value, err := l.GetState("chaincode2", "key", true)
+	if err != nil || !bytes.Equal(value, []byte("value2")) {
+		t.Fatalf("Expected value2 for chaincode2/key, got %s (err %v)", value, err)
+	}
+	if _, err := l.GetBlockByNumber(0); err != nil {
+		t.Fatalf("Expected genesis block to be installed: %s", err)
+	}
+
+	if _, err := installStateSnapshot(l, &mockSnapshotStream{messages}, getBlock); err == nil {
+		t.Fatal("Expected error installing a snapshot into a non empty ledger")
+	}
+}
+
+func TestInstallStateSnapshotMismatch(t *testing.T) {
+	messages, blocks := buildSnapshotSource(t)
+	getBlock := func(blockNumber uint64) (*pb.Block, error) {
+		return blocks[blockNumber], nil
+	}
+
+	l := ledger.InitTestLedger(t)
+	// Drop a key from the snapshot, the state hash no longer matches the block
+	tampered := append([]*pb.SyncStateSnapshot{}, messages[1:]...)
+	for i, msg := range tampered {
+		tampered[i] = &pb.SyncStateSnapshot{Delta: msg.Delta, Sequence: uint64(i), BlockNumber: msg.BlockNumber}
+	}
+	if _, err := installStateSnapshot(l, &mockSnapshotStream{tampered}, getBlock); err == nil {
+		t.Fatal("Expected error installing a snapshot not matching the block state hash")
+	}
+	if size := l.GetBlockchainSize(); size != 0 {
+		t.Fatalf("Expected no blocks after failed install, got %d", size)
+	}
+	if value, _ := l.GetState("chaincode2", "key", true); value != nil {
+		t.Fatalf("Expected state to be emptied after failed install, got %s", value)
+	}
+
+	// A stream without the terminating message is incomplete
+	l = ledger.InitTestLedger(t)
+	if _, err := installStateSnapshot(l, &mockSnapshotStream{messages[:len(messages)-1]}, getBlock); err == nil {
+		t.Fatal("Expected error installing an incomplete snapshot")
+	}
+}
+
+func TestGetStateSnapshotRequiresCertificate(t *testing.T) {
+	cached, enabled := configurationCached, securityEnabled
+	configurationCached, securityEnabled = true, true
+	defer func() { configurationCached, securityEnabled = cached, enabled }()
+
+	l := ledger.InitTestLedger(t)
+	l.BeginTxBatch(1)
+	if err := l.CommitTxBatch(1, []*pb.Transaction{}, nil, []byte("proof")); err != nil {
+		t.Fatalf("Error committing block: %s", err)
+	}
+	server := &SnapshotServer{ledger: l}
+	stream := &mockSnapshotServerStream{ctx: context.Background()}
+	err := server.GetStateSnapshot(&pb.SyncStateSnapshotRequest{}, stream)
+	if grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected the snapshot to be denied without a client certificate, got %v", err)
+	}
+	if len(stream.messages) != 0 {
+		t.Fatalf("Expected no state snapshot message to be sent, got %d", len(stream.messages))
+	}
+
+	state := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}}}
+	stream.ctx = credentials.NewContext(context.Background(), credentials.TLSInfo{State: state})
+	if err := server.GetStateSnapshot(&pb.SyncStateSnapshotRequest{}, stream); err != nil {
+		t.Fatalf("Error serving state snapshot to a verified caller: %s", err)
+	}
+	if len(stream.messages) == 0 {
+		t.Fatal("Expected the terminating state snapshot message to be sent")
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	_ "github.com/hyperledger/fabric/core" // Logging format init
 
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/protos"
@@ -51,6 +52,7 @@ type PartialStack interface {
 	peer.BlockChainAccessor
 	peer.BlockChainModifier
 	peer.BlockChainUtil
+	peer.StateTransferProgressTracker
 	GetPeers() (*protos.PeersMessage, error)
 	GetPeerEndpoint() (*protos.PeerEndpoint, error)
 	GetRemoteLedger(receiver *protos.PeerID) (peer.RemoteLedger, error)
@@ -98,6 +100,9 @@ type coordinatorImpl struct {
 	maxStateDeltaRange uint64 // The maximum number of state deltas to attempt to retrieve at once, to prevent from overflowing the peer's buffer
 
 	currentStateBlockNumber uint64 // When state transfer does not complete successfully, the current state does not always correspond to the block height
+
+	snapshotChunkSize uint64                        // The number of state snapshot deltas to commit at once, progress is recorded with every chunk
+	snapshotProgress  *ledger.StateTransferProgress // The progress of an interrupted state snapshot retrieval, which should be resumed
 }
 
 // SyncToTarget consumes the calling thread and attempts to perform state transfer until success or an error occurs
@@ -115,6 +120,7 @@ func (sts *coordinatorImpl) SyncToTarget(blockNumber uint64, blockHash []byte, p
 
 	if !sts.inProgress {
 		sts.currentStateBlockNumber = sts.stack.GetBlockchainSize() - 1 // The block height is one more than the latest block number
+		sts.resumeFromProgress()
 		sts.inProgress = true
 	}
 
@@ -122,6 +128,9 @@ func (sts *coordinatorImpl) SyncToTarget(blockNumber uint64, blockHash []byte, p
 
 	if err == nil {
 		sts.inProgress = false
+		if err := sts.stack.ClearStateTransferProgress(); nil != err {
+			logger.Warningf("%v could not clear its state transfer progress: %s", sts.id, err)
+		}
 	}
 
 	return err, recoverable
@@ -210,6 +219,11 @@ func NewCoordinatorImpl(stack PartialStack) Coordinator {
 	}
 	sts.maxStateDeltaRange = uint64(tmp)
 
+	sts.snapshotChunkSize = uint64(viper.GetInt("statetransfer.snapshotchunksize"))
+	if sts.snapshotChunkSize == 0 {
+		panic(fmt.Errorf("Must set statetransfer.snapshotchunksize to be nonzero"))
+	}
+
 	return sts
 }
 
@@ -616,6 +630,28 @@ func (sts *coordinatorImpl) blockThread() {
 	}
 }
 
+// resumeFromProgress picks up a state transfer which was interrupted by a restart, based on the progress recorded in the ledger
+func (sts *coordinatorImpl) resumeFromProgress() {
+	progress, err := sts.stack.GetStateTransferProgress()
+	if nil != err {
+		logger.Warningf("%v could not read its state transfer progress, not resuming: %s", sts.id, err)
+		return
+	}
+	if nil == progress {
+		return
+	}
+
+	if progress.SnapshotInProgress {
+		logger.Infof("%v resuming state snapshot retrieval for block %d after %d deltas", sts.id, progress.StateBlockNumber, progress.SnapshotSequence)
+		sts.stateValid = false
+		sts.snapshotProgress = progress
+		return
+	}
+
+	logger.Infof("%v resuming state transfer with its state at block %d", sts.id, progress.StateBlockNumber)
+	sts.currentStateBlockNumber = progress.StateBlockNumber
+}
+
 func (sts *coordinatorImpl) attemptStateTransfer(mark *blockHashReply) (error, bool) {
 	var err error
 
@@ -782,7 +818,7 @@ func (sts *coordinatorImpl) playStateUpToBlockNumber(fromBlockNumber, toBlockNum
 
 				}
 
-				if nil != sts.stack.CommitStateDelta(deltaMessage) {
+				if nil != sts.stack.CommitStateDeltaWithProgress(deltaMessage, &ledger.StateTransferProgress{StateBlockNumber: deltaMessage.Range.End}) {
 					sts.stateValid = false
 					return fmt.Errorf("%v played state forward according to %v, hashes matched, but failed to commit, invalidated state", sts.id, peerID)
 				}
@@ -805,6 +841,8 @@ func (sts *coordinatorImpl) playStateUpToBlockNumber(fromBlockNumber, toBlockNum
 // This function will retrieve the current state from a peer.
 // Note that no state verification can occur yet, we must wait for the next checkpoint, so it is important
 // not to consider this state as valid
+// The deltas are committed in chunks along with the progress, so that the retrieval may be resumed
+// from the last committed chunk if it is interrupted, provided the peer still serves the same snapshot
 func (sts *coordinatorImpl) syncStateSnapshot(minBlockNumber uint64, peerIDs []*protos.PeerID) (uint64, error) {
 
 	logger.Debugf("%v attempting to retrieve state snapshot from recovery from %v", sts.id, peerIDs)
@@ -812,20 +850,53 @@ func (sts *coordinatorImpl) syncStateSnapshot(minBlockNumber uint64, peerIDs []*
 	currentStateBlock := uint64(0)
 
 	ok := sts.tryOverPeers(peerIDs, func(peerID *protos.PeerID) error {
-		logger.Debugf("%v is initiating state recovery from %v", sts.id, peerID)
+		counter := uint64(0)
+		if nil != sts.snapshotProgress {
+			counter = sts.snapshotProgress.SnapshotSequence
+			currentStateBlock = sts.snapshotProgress.StateBlockNumber
+			logger.Debugf("%v is resuming state recovery from %v after %d deltas", sts.id, peerID, counter)
+		} else {
+			logger.Debugf("%v is initiating state recovery from %v", sts.id, peerID)
 
-		if err := sts.stack.EmptyState(); nil != err {
-			logger.Errorf("Could not empty the current state: %s", err)
+			if err := sts.stack.EmptyState(); nil != err {
+				logger.Errorf("Could not empty the current state: %s", err)
+			}
+			if err := sts.stack.ClearStateTransferProgress(); nil != err {
+				logger.Errorf("Could not clear the state transfer progress: %s", err)
+			}
 		}
 
-		stateChan, err := sts.GetRemoteStateSnapshot(peerID)
+		stateChan, err := sts.GetRemoteStateSnapshot(peerID, counter)
 
 		if err != nil {
 			return err
 		}
 
+		var chunkID interface{} // The id under which the deltas of the current chunk are applied
+		pending := uint64(0)    // The number of deltas applied but not yet committed
+		defer func() {
+			if 0 != pending {
+				if rbErr := sts.stack.RollbackStateDelta(chunkID); nil != rbErr {
+					logger.Errorf("%v could not roll back the uncommitted state snapshot deltas: %s", sts.id, rbErr)
+				}
+			}
+		}()
+
+		commitChunk := func(progress *ledger.StateTransferProgress) error {
+			if err := sts.stack.CommitStateDeltaWithProgress(chunkID, progress); nil != err {
+				return fmt.Errorf("%v could not commit state delta from %v after %d deltas: %s", sts.id, peerID, counter, err)
+			}
+			pending = 0
+			chunkID = nil
+			if progress.SnapshotInProgress {
+				sts.snapshotProgress = progress
+			} else {
+				sts.snapshotProgress = nil
+			}
+			return nil
+		}
+
 		timer := time.NewTimer(sts.StateSnapshotRequestTimeout)
-		counter := 0
 
 		for {
 			select {
@@ -834,6 +905,10 @@ func (sts *coordinatorImpl) syncStateSnapshot(minBlockNumber uint64, peerIDs []*
 					return fmt.Errorf("%v had state snapshot channel close prematurely after %d deltas: %s", sts.id, counter, err)
 				}
 				if 0 == len(piece.Delta) {
+					if err := commitChunk(&ledger.StateTransferProgress{StateBlockNumber: currentStateBlock}); nil != err {
+						return err
+					}
+
 					stateHash, err := sts.stack.GetCurrentStateHash()
 					if nil != err {
 						sts.stateValid = false
@@ -844,16 +919,41 @@ func (sts *coordinatorImpl) syncStateSnapshot(minBlockNumber uint64, peerIDs []*
 					logger.Debugf("%v received final piece of state snapshot from %v after %d deltas, now has hash %x", sts.id, peerID, counter, stateHash)
 					return nil
 				}
+				if piece.Sequence < counter {
+					// The peer started from the beginning, skip the deltas we already have
+					continue
+				}
+				if piece.Sequence != counter {
+					return fmt.Errorf("%v received state delta %d from %v, but expected delta %d", sts.id, piece.Sequence, peerID, counter)
+				}
+				if nil != sts.snapshotProgress && piece.BlockNumber != sts.snapshotProgress.StateBlockNumber {
+					// The snapshot of the peer moved on, the already committed deltas cannot be used
+					sts.snapshotProgress = nil
+					return fmt.Errorf("%v cannot resume state snapshot for block %d from %v, which now serves block %d", sts.id, currentStateBlock, peerID, piece.BlockNumber)
+				}
 				umDelta := &statemgmt.StateDelta{}
 				if err := umDelta.Unmarshal(piece.Delta); nil != err {
 					return fmt.Errorf("%v received a corrupt delta from %v after %d deltas : %s", sts.id, peerID, counter, err)
 				}
-				sts.stack.ApplyStateDelta(piece, umDelta)
-				currentStateBlock = piece.BlockNumber
-				if err := sts.stack.CommitStateDelta(piece); nil != err {
-					return fmt.Errorf("%v could not commit state delta from %v after %d deltas: %s", sts.id, peerID, counter, err)
+				if 0 == pending {
+					chunkID = piece
+				}
+				if err := sts.stack.ApplyStateDelta(chunkID, umDelta); nil != err {
+					return fmt.Errorf("%v could not apply state delta from %v after %d deltas: %s", sts.id, peerID, counter, err)
 				}
+				pending++
 				counter++
+				currentStateBlock = piece.BlockNumber
+				if pending == sts.snapshotChunkSize {
+					progress := &ledger.StateTransferProgress{
+						StateBlockNumber:   currentStateBlock,
+						SnapshotInProgress: true,
+						SnapshotSequence:   counter,
+					}
+					if err := commitChunk(progress); nil != err {
+						return err
+					}
+				}
 			case <-timer.C:
 				return fmt.Errorf("%v timed out during state recovery from %v", sts.id, peerID)
 			}
@@ -878,13 +978,13 @@ func (sts *coordinatorImpl) GetRemoteBlocks(replicaID *protos.PeerID, start, fin
 	})
 }
 
-// GetRemoteStateSnapshot will return a channel to stream a state snapshot from the desired replicaID
-func (sts *coordinatorImpl) GetRemoteStateSnapshot(replicaID *protos.PeerID) (<-chan *protos.SyncStateSnapshot, error) {
+// GetRemoteStateSnapshot will return a channel to stream a state snapshot, starting at the given delta, from the desired replicaID
+func (sts *coordinatorImpl) GetRemoteStateSnapshot(replicaID *protos.PeerID, startSequence uint64) (<-chan *protos.SyncStateSnapshot, error) {
 	remoteLedger, err := sts.stack.GetRemoteLedger(replicaID)
 	if nil != err {
 		return nil, err
 	}
-	return remoteLedger.RequestStateSnapshot()
+	return remoteLedger.RequestStateSnapshot(startSequence)
 }
 
 // GetRemoteStateDeltas will return a channel to stream a state snapshot deltas from the desired replicaID
@@ -24,6 +24,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/protos"
@@ -138,6 +139,9 @@ type MockLedger struct {
 	deltaID       interface{}
 	preDeltaValue uint64
 
+	progress              *ledger.StateTransferProgress
+	snapshotStartSequence uint64
+
 	t *testing.T
 }
 
@@ -198,8 +202,8 @@ type remoteLedger struct {
 func (rl *remoteLedger) RequestBlocks(rng *protos.SyncBlockRange) (<-chan *protos.SyncBlocks, error) {
 	return rl.mockLedger.GetRemoteBlocks(rl.peerID, rng.Start, rng.End)
 }
-func (rl *remoteLedger) RequestStateSnapshot() (<-chan *protos.SyncStateSnapshot, error) {
-	return rl.mockLedger.GetRemoteStateSnapshot(rl.peerID)
+func (rl *remoteLedger) RequestStateSnapshot(startSequence uint64) (<-chan *protos.SyncStateSnapshot, error) {
+	return rl.mockLedger.GetRemoteStateSnapshot(rl.peerID, startSequence)
 }
 func (rl *remoteLedger) RequestStateDeltas(rng *protos.SyncBlockRange) (<-chan *protos.SyncStateDeltas, error) {
 	return rl.mockLedger.GetRemoteStateDeltas(rl.peerID, rng.Start, rng.End)
@@ -308,13 +312,17 @@ func (mock *MockLedger) GetRemoteBlocks(peerID *protos.PeerID, start, finish uin
 	return res, nil
 }
 
-func (mock *MockLedger) GetRemoteStateSnapshot(peerID *protos.PeerID) (<-chan *protos.SyncStateSnapshot, error) {
+func (mock *MockLedger) GetRemoteStateSnapshot(peerID *protos.PeerID, startSequence uint64) (<-chan *protos.SyncStateSnapshot, error) {
 
 	rl, ok := mock.remoteLedgers.GetLedgerByPeerID(peerID)
 	if !ok {
 		return nil, fmt.Errorf("Bad peer ID %v", peerID)
 	}
 
+	mock.mutex.Lock()
+	mock.snapshotStartSequence = startSequence
+	mock.mutex.Unlock()
+
 	remoteBlockHeight := rl.GetBlockchainSize()
 	res := make(chan *protos.SyncStateSnapshot, remoteBlockHeight) // Allows the thread to exit even if the consumer doesn't finish
 	ft := mock.filter(SyncSnapshot, peerID)
@@ -347,11 +355,13 @@ func (mock *MockLedger) GetRemoteStateSnapshot(peerID *protos.PeerID) (<-chan *p
 			i := uint64(0)
 			for deltas := range rds {
 				for _, delta := range deltas.Deltas {
-					res <- &protos.SyncStateSnapshot{
-						Delta:       delta,
-						Sequence:    i,
-						BlockNumber: remoteBlockHeight - 1,
-						Request:     nil,
+					if i >= startSequence {
+						res <- &protos.SyncStateSnapshot{
+							Delta:       delta,
+							Sequence:    i,
+							BlockNumber: remoteBlockHeight - 1,
+							Request:     nil,
+						}
 					}
 					i++
 				}
@@ -521,6 +531,34 @@ func (mock *MockLedger) CommitStateDelta(id interface{}) error {
 	return nil
 }
 
+func (mock *MockLedger) CommitStateDeltaWithProgress(id interface{}, progress *ledger.StateTransferProgress) error {
+	mock.mutex.Lock()
+	defer func() {
+		mock.mutex.Unlock()
+	}()
+
+	mock.deltaID = nil
+	mock.progress = progress
+	return nil
+}
+
+func (mock *MockLedger) GetStateTransferProgress() (*ledger.StateTransferProgress, error) {
+	mock.mutex.Lock()
+	defer func() {
+		mock.mutex.Unlock()
+	}()
+	return mock.progress, nil
+}
+
+func (mock *MockLedger) ClearStateTransferProgress() error {
+	mock.mutex.Lock()
+	defer func() {
+		mock.mutex.Unlock()
+	}()
+	mock.progress = nil
+	return nil
+}
+
 func (mock *MockLedger) RollbackStateDelta(id interface{}) error {
 	mock.mutex.Lock()
 	defer func() {
@@ -768,7 +806,7 @@ func TestMockLedger(t *testing.T) {
 		t.Fatalf("Mangled blockchain did not detect the correct block with the wrong hash, error in mock ledger implementation.")
 	}
 
-	syncStateMessages, err := ml.GetRemoteStateSnapshot(rlPeerID)
+	syncStateMessages, err := ml.GetRemoteStateSnapshot(rlPeerID, 0)
 
 	if nil != err {
 		t.Fatalf("Remote state snapshot call failed, error in mock ledger implementation: %s", err)
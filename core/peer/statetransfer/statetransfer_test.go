@@ -26,6 +26,7 @@ import (
 	"time"
 
 	configSetup "github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/protos"
 
@@ -378,6 +379,59 @@ func TestCatchupSyncSnapshotError(t *testing.T) {
 	}
 }
 
+func TestCatchupResumeSnapshot(t *testing.T) {
+	mrls := createRemoteLedgers(1, 3)
+
+	// Simulate a restart after the deltas of blocks 0-2 of the snapshot for block 7 were committed
+	ml := NewMockLedger(mrls, nil, t)
+	ml.PutBlock(0, SimpleGetBlock(0))
+	ml.state = SimpleGetState(2)
+	ml.progress = &ledger.StateTransferProgress{
+		StateBlockNumber:   7,
+		SnapshotInProgress: true,
+		SnapshotSequence:   3,
+	}
+
+	sts := newTestStateTransfer(ml, mrls)
+	sts.snapshotChunkSize = 2
+	defer sts.Stop()
+	if err := executeStateTransfer(sts, ml, 7, 10, mrls); nil != err {
+		t.Fatalf("Resume snapshot case: %s", err)
+	}
+
+	if ml.snapshotStartSequence != 3 {
+		t.Fatalf("Expected state snapshot to be resumed at delta 3, but it was requested from delta %d", ml.snapshotStartSequence)
+	}
+
+	if progress, _ := ml.GetStateTransferProgress(); nil != progress {
+		t.Fatalf("Expected state transfer progress to be cleared, but got %+v", progress)
+	}
+}
+
+func TestCatchupResumeSnapshotMovedOn(t *testing.T) {
+	mrls := createRemoteLedgers(1, 3)
+
+	// The interrupted snapshot was for block 5, but the peers now serve block 7
+	ml := NewMockLedger(mrls, nil, t)
+	ml.PutBlock(0, SimpleGetBlock(0))
+	ml.state = SimpleGetState(2)
+	ml.progress = &ledger.StateTransferProgress{
+		StateBlockNumber:   5,
+		SnapshotInProgress: true,
+		SnapshotSequence:   3,
+	}
+
+	sts := newTestStateTransfer(ml, mrls)
+	defer sts.Stop()
+	if err := executeStateTransfer(sts, ml, 7, 10, mrls); nil != err {
+		t.Fatalf("Resume moved on snapshot case: %s", err)
+	}
+
+	if ml.snapshotStartSequence != 0 {
+		t.Fatalf("Expected state snapshot to be retrieved from the beginning, but it was requested from delta %d", ml.snapshotStartSequence)
+	}
+}
+
 func TestCatchupSyncDeltasError(t *testing.T) {
 	for _, failureType := range AllFailures {
 		mrls := createRemoteLedgers(1, 3)
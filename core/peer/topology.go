@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// TopologyReporter is implemented by the coordinators which know the
+// topology of the network around them
+type TopologyReporter interface {
+	GetNetworkTopology() (*pb.NetworkTopology, error)
+}
+
+// peerTopology is what a chat learns of the remote peer from the messages it
+// handles: the height of its blockchain from its hello and gossip digests,
+// and the peers it chats with from its DISC_PEERS.
+type peerTopology struct {
+	sync.Mutex
+	height    uint64
+	neighbors []*pb.PeerID
+}
+
+// observe records the topology carried by msg, once decoded
+func (t *peerTopology) observe(msg *pb.Message) {
+	switch msg.Type {
+	case pb.Message_DISC_HELLO:
+		hello := &pb.HelloMessage{}
+		if err := proto.Unmarshal(msg.Payload, hello); err != nil || hello.BlockchainInfo == nil {
+			return
+		}
+		t.setHeight(hello.BlockchainInfo.Height)
+	case pb.Message_SYNC_GOSSIP_DIGEST:
+		info := &pb.BlockchainInfo{}
+		if err := proto.Unmarshal(msg.Payload, info); err != nil {
+			return
+		}
+		t.setHeight(info.Height)
+	case pb.Message_DISC_PEERS:
+		peers := &pb.PeersMessage{}
+		if err := proto.Unmarshal(msg.Payload, peers); err != nil {
+			return
+		}
+		var neighbors []*pb.PeerID
+		for _, endpoint := range peers.Peers {
+			if endpoint.ID != nil {
+				neighbors = append(neighbors, endpoint.ID)
+			}
+		}
+		t.Lock()
+		t.neighbors = neighbors
+		t.Unlock()
+	}
+}
+
+func (t *peerTopology) setHeight(height uint64) {
+	t.Lock()
+	defer t.Unlock()
+	t.height = height
+}
+
+// report returns the topology of the peer of id, with the round trip times
+// of health
+func (t *peerTopology) report(id *pb.PeerID, health *peerHealth) *pb.PeerTopology {
+	t.Lock()
+	defer t.Unlock()
+	topology := &pb.PeerTopology{ID: id, BlockHeight: t.height, Neighbors: t.neighbors}
+	if health != nil {
+		rtt, minRTT, maxRTT := health.roundTripTimes()
+		topology.RoundTripTime = uint64(rtt / time.Microsecond)
+		topology.MinRoundTripTime = uint64(minRTT / time.Microsecond)
+		topology.MaxRoundTripTime = uint64(maxRTT / time.Microsecond)
+	}
+	return topology
+}
+
+type peersTopologyByID []*pb.PeerTopology
+
+func (s peersTopologyByID) Len() int           { return len(s) }
+func (s peersTopologyByID) Less(i, j int) bool { return s[i].ID.Name < s[j].ID.Name }
+func (s peersTopologyByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// GetNetworkTopology returns the endpoints of the peers chatting with this
+// peer and its own, and the topology of each of them, by peer ID
+func (p *PeerImpl) GetNetworkTopology() (*pb.NetworkTopology, error) {
+	peers, err := p.GetPeers()
+	if err != nil {
+		return nil, err
+	}
+	self, err := p.GetPeerEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	network := &pb.NetworkTopology{Peers: append(peers.Peers, self)}
+
+	local := &pb.PeerTopology{ID: self.ID, BlockHeight: p.GetBlockchainSize()}
+	for _, endpoint := range peers.Peers {
+		local.Neighbors = append(local.Neighbors, endpoint.ID)
+	}
+	network.Topology = append(network.Topology, local)
+
+	p.connections.RLock()
+	defer p.connections.RUnlock()
+	for conn := range p.connections.m {
+		endpoint, err := conn.handler.To()
+		if err != nil || endpoint.ID == nil {
+			// the peer did not say hello yet
+			continue
+		}
+		network.Topology = append(network.Topology, conn.topology.report(endpoint.ID, conn.health))
+	}
+	sort.Sort(peersTopologyByID(network.Topology))
+	return network, nil
+}
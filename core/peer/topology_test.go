@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestPeerTopology(t *testing.T) {
+	message := func(msgType pb.Message_Type, payload proto.Message) *pb.Message {
+		raw, err := proto.Marshal(payload)
+		if err != nil {
+			t.Fatalf("Error marshalling %s: %s", msgType, err)
+		}
+		return &pb.Message{Type: msgType, Payload: raw}
+	}
+	topology := &peerTopology{}
+	topology.observe(message(pb.Message_DISC_HELLO, &pb.HelloMessage{BlockchainInfo: &pb.BlockchainInfo{Height: 3}}))
+	topology.observe(message(pb.Message_DISC_PEERS, &pb.PeersMessage{Peers: []*pb.PeerEndpoint{
+		{ID: &pb.PeerID{Name: "vp2"}},
+		{ID: &pb.PeerID{Name: "vp3"}},
+	}}))
+	topology.observe(message(pb.Message_SYNC_GOSSIP_DIGEST, &pb.BlockchainInfo{Height: 5}))
+	// a malformed message is ignored
+	topology.observe(&pb.Message{Type: pb.Message_SYNC_GOSSIP_DIGEST, Payload: []byte{0xff}})
+
+	h := newPeerHealth(1, 3)
+	start := time.Now()
+	for _, rtt := range []time.Duration{8 * time.Millisecond, 16 * time.Millisecond, 4 * time.Millisecond} {
+		payload, _ := h.ping(start)
+		h.pong(payload, start.Add(rtt))
+		start = start.Add(time.Second)
+	}
+
+	report := topology.report(&pb.PeerID{Name: "vp1"}, h)
+	if report.ID.Name != "vp1" || report.BlockHeight != 5 {
+		t.Fatalf("Expected vp1 at height 5, got %s", report)
+	}
+	if len(report.Neighbors) != 2 || report.Neighbors[0].Name != "vp2" || report.Neighbors[1].Name != "vp3" {
+		t.Fatalf("Expected vp1 to chat with vp2 and vp3, got %s", report)
+	}
+	if report.RoundTripTime != 8375 || report.MinRoundTripTime != 4000 || report.MaxRoundTripTime != 16000 {
+		t.Fatalf("Expected round trip times of 8.375ms, 4ms min and 16ms max, got %s", report)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quorum helps clients query chaincodes on several peers and trust
+// the result only once enough of them agree on it. A single peer answering a
+// query may be faulty and return stale or wrong data, but out of f+1 peers
+// returning the same result, f being the number of faulty peers tolerated by
+// the network, at least one is correct.
+package quorum
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/hyperledger/fabric/core/peer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var logger = logging.MustGetLogger("quorum")
+
+// QueryFunc performs a query on one peer
+type QueryFunc func(ctx context.Context, invocation *pb.ChaincodeInvocationSpec) (*pb.Response, error)
+
+// DevopsQuery returns a QueryFunc performing the query through client
+func DevopsQuery(client pb.DevopsClient) QueryFunc {
+	return func(ctx context.Context, invocation *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+		return client.Query(ctx, invocation)
+	}
+}
+
+// Dial connects to the Devops service of the peers at addresses, and returns
+// the QueryFuncs performing queries on them, and the connections to be
+// closed once done
+func Dial(addresses []string) ([]QueryFunc, []*grpc.ClientConn, error) {
+	var queries []QueryFunc
+	var conns []*grpc.ClientConn
+	for _, address := range addresses {
+		conn, err := peer.NewPeerClientConnectionWithAddress(address)
+		if err != nil {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			return nil, nil, fmt.Errorf("Error connecting to peer %s: %s", address, err)
+		}
+		conns = append(conns, conn)
+		queries = append(queries, DevopsQuery(pb.NewDevopsClient(conn)))
+	}
+	return queries, conns, nil
+}
+
+// FaultsTolerated returns the number of faulty validating peers, f, tolerated
+// by a network of n validating peers, n >= 3f+1
+func FaultsTolerated(n int) int {
+	if n < 1 {
+		return 0
+	}
+	return (n - 1) / 3
+}
+
+type queryResult struct {
+	resp *pb.Response
+	err  error
+}
+
+// Query performs the query on all the peers concurrently, and returns the
+// response once quorum of them returned the same status and message. It
+// returns an error as soon as no response can reach the quorum anymore.
+func Query(ctx context.Context, invocation *pb.ChaincodeInvocationSpec, quorum int, peers []QueryFunc) (*pb.Response, error) {
+	if quorum < 1 || quorum > len(peers) {
+		return nil, fmt.Errorf("Error querying: a quorum of %d responses cannot be reached with %d peers", quorum, len(peers))
+	}
+
+	results := make(chan queryResult, len(peers))
+	for _, query := range peers {
+		go func(query QueryFunc) {
+			resp, err := query(ctx, invocation)
+			results <- queryResult{resp, err}
+		}(query)
+	}
+
+	var responses []*pb.Response
+	var counts []int
+	failures := 0
+	for received := 1; received <= len(peers); received++ {
+		result := <-results
+		if result.err != nil || result.resp == nil {
+			logger.Debugf("Query failed on a peer: %v", result.err)
+			failures++
+		} else {
+			i := 0
+			for ; i < len(responses); i++ {
+				if responses[i].Status == result.resp.Status && bytes.Equal(responses[i].Msg, result.resp.Msg) {
+					break
+				}
+			}
+			if i == len(responses) {
+				responses = append(responses, result.resp)
+				counts = append(counts, 0)
+			}
+			counts[i]++
+			if counts[i] >= quorum {
+				return responses[i], nil
+			}
+		}
+
+		best := 0
+		for _, count := range counts {
+			if count > best {
+				best = count
+			}
+		}
+		if best+len(peers)-received < quorum {
+			break
+		}
+	}
+	return nil, fmt.Errorf("Error querying: no quorum of %d matching responses out of %d peers, %d distinct responses and %d failures", quorum, len(peers), len(responses), failures)
+}
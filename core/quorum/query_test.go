@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quorum
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func respond(msg string) QueryFunc {
+	return func(ctx context.Context, invocation *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+		return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(msg)}, nil
+	}
+}
+
+func fail(ctx context.Context, invocation *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	return nil, fmt.Errorf("peer unavailable")
+}
+
+func TestFaultsTolerated(t *testing.T) {
+	for n, f := range map[int]int{0: 0, 1: 0, 3: 0, 4: 1, 6: 1, 7: 2, 10: 3} {
+		if got := FaultsTolerated(n); got != f {
+			t.Fatalf("Expected %d validating peers to tolerate %d faults, got %d", n, f, got)
+		}
+	}
+}
+
+func TestQueryQuorum(t *testing.T) {
+	invocation := &pb.ChaincodeInvocationSpec{}
+
+	// one faulty peer out of four is outvoted
+	resp, err := Query(context.Background(), invocation, 2, []QueryFunc{respond("100"), respond("wrong"), fail, respond("100")})
+	if err != nil || string(resp.Msg) != "100" {
+		t.Fatalf("Expected the value agreed by two peers, got %v (%v)", resp, err)
+	}
+
+	// no value is returned without agreement
+	if resp, err = Query(context.Background(), invocation, 2, []QueryFunc{respond("100"), respond("wrong"), fail, respond("other")}); err == nil {
+		t.Fatalf("Expected an error without a quorum, got %v", resp)
+	}
+
+	if _, err = Query(context.Background(), invocation, 3, []QueryFunc{respond("100"), respond("100")}); err == nil {
+		t.Fatalf("Expected an error with a quorum larger than the peers")
+	}
+}
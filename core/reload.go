@@ -0,0 +1,140 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/flogging"
+	"github.com/hyperledger/fabric/events/producer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// reloadableKeys are the keys of core.yaml, or the sections of keys when
+// ending with a dot, whose changes ReloadConfig applies to the running peer.
+// The changes of the other keys take effect on restart.
+var reloadableKeys = []string{
+	"logging.",
+	"peer.validator.events.buffersize",
+	"peer.validator.events.timeout",
+	"chaincode.deploytimeout",
+	"chaincode.executetimeout",
+}
+
+// reloadRules are the rules the reloadable keys must meet, on top of those of
+// config.CoreRules
+var reloadRules = []config.Rule{
+	{Key: "logging.format", Kind: config.String, Values: []string{flogging.TextFormat, flogging.JSONFormat}},
+	{Key: "chaincode.deploytimeout", Kind: config.Int, Range: &config.Range{Min: 1}},
+	{Key: "chaincode.executetimeout", Kind: config.Int, Range: &config.Range{Min: 0}},
+}
+
+var configReload struct {
+	sync.Mutex
+	enabled   bool
+	envPrefix string
+	reloaders []func() (*pb.ConfigReload, error)
+}
+
+// EnableConfigReload lets ReloadConfig read core.yaml again, with the
+// environment overrides of envPrefix, and have the reloaders, such as the
+// one of the consensus plugin, reload their configurations
+func EnableConfigReload(envPrefix string, reloaders ...func() (*pb.ConfigReload, error)) {
+	configReload.Lock()
+	defer configReload.Unlock()
+	configReload.enabled = true
+	configReload.envPrefix = envPrefix
+	configReload.reloaders = reloaders
+}
+
+func isReloadable(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range reloadableKeys {
+		k = strings.ToLower(k)
+		if key == k || strings.HasSuffix(k, ".") && strings.HasPrefix(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadConfig reads core.yaml again from the file the peer was started with
+// and, if the values of its reloadable keys are valid, applies their changes
+// to the running peer. Every change is logged, and returned along with the
+// keys whose change takes effect on restart.
+func ReloadConfig() (*pb.ConfigReload, error) {
+	configReload.Lock()
+	defer configReload.Unlock()
+	if !configReload.enabled {
+		return nil, fmt.Errorf("Configuration reload is not enabled on this peer")
+	}
+
+	fresh := viper.New()
+	fresh.SetEnvPrefix(configReload.envPrefix)
+	fresh.AutomaticEnv()
+	fresh.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	fresh.SetConfigFile(viper.ConfigFileUsed())
+	if err := fresh.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", viper.ConfigFileUsed(), err)
+	}
+	var rules []config.Rule
+	for _, rule := range append(config.CoreRules(nil, nil), reloadRules...) {
+		if isReloadable(rule.Key) {
+			rules = append(rules, rule)
+		}
+	}
+	if errs := config.Validate(fresh, rules); len(errs) > 0 {
+		return nil, fmt.Errorf("Invalid configuration in %s, keeping the current one: %v", viper.ConfigFileUsed(), errs)
+	}
+
+	result := &pb.ConfigReload{}
+	var loggingChanged, eventsChanged bool
+	for _, change := range config.Diff(config.Global, fresh) {
+		if !isReloadable(change.Key) {
+			log.Warningf("Configuration %s changed, the change takes effect on restart", change.Key)
+			result.RestartRequired = append(result.RestartRequired, change.Key)
+			continue
+		}
+		log.Infof("Configuration %s changed from [%v] to [%v]", change.Key, change.Old, change.New)
+		viper.Set(change.Key, change.New)
+		result.Changes = append(result.Changes, &pb.ConfigChange{Key: change.Key, OldValue: fmt.Sprint(change.Old), NewValue: fmt.Sprint(change.New)})
+		loggingChanged = loggingChanged || strings.HasPrefix(strings.ToLower(change.Key), "logging.")
+		eventsChanged = eventsChanged || strings.HasPrefix(strings.ToLower(change.Key), "peer.validator.events.")
+	}
+	if loggingChanged {
+		reloadLogging()
+	}
+	if eventsChanged {
+		producer.Reconfigure(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"))
+	}
+
+	for _, reloader := range configReload.reloaders {
+		reloaded, err := reloader()
+		if err != nil {
+			log.Errorf("Error reloading the configuration: %s", err)
+			continue
+		}
+		result.Changes = append(result.Changes, reloaded.Changes...)
+		result.RestartRequired = append(result.RestartRequired, reloaded.RestartRequired...)
+	}
+	return result, nil
+}
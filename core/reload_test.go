@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+const reloadTestConfig = `
+logging:
+    peer: info
+peer:
+    address: 0.0.0.0:30303
+    validator:
+        events:
+            buffersize: 100
+            timeout: 10
+chaincode:
+    executetimeout: 30000
+`
+
+func writeReloadTestConfig(t *testing.T, path string, replacements ...string) {
+	config := reloadTestConfig
+	for i := 0; i+1 < len(replacements); i += 2 {
+		config = strings.Replace(config, replacements[i], replacements[i+1], 1)
+	}
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatalf("Error writing the test configuration: %s", err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	file, err := ioutil.TempFile("", "core")
+	if err != nil {
+		t.Fatalf("Error creating the test configuration: %s", err)
+	}
+	file.Close()
+	path := file.Name() + ".yaml"
+	defer os.Remove(file.Name())
+	defer os.Remove(path)
+
+	writeReloadTestConfig(t, path)
+	viper.Reset()
+	defer viper.Reset()
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("Error reading the test configuration: %s", err)
+	}
+
+	consensus := &pb.ConfigReload{Changes: []*pb.ConfigChange{{Key: "pbft.general.timeout.batch", OldValue: "2s", NewValue: "1s"}}}
+	EnableConfigReload("RELOADTEST", func() (*pb.ConfigReload, error) { return consensus, nil })
+	defer func() { configReload.enabled = false }()
+
+	writeReloadTestConfig(t, path, "peer: info", "peer: debug", "executetimeout: 30000", "executetimeout: 1000", "30303", "40404")
+	result, err := ReloadConfig()
+	if err != nil {
+		t.Fatalf("Error reloading the configuration: %s", err)
+	}
+	var changed []string
+	for _, change := range result.Changes {
+		changed = append(changed, change.Key)
+	}
+	if expected := []string{"chaincode.executetimeout", "logging.peer", "pbft.general.timeout.batch"}; !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("Expected changes of %v, got %v", expected, result.Changes)
+	}
+	if expected := []string{"peer.address"}; !reflect.DeepEqual(result.RestartRequired, expected) {
+		t.Fatalf("Expected %v to require a restart, got %v", expected, result.RestartRequired)
+	}
+	if timeout := viper.GetInt("chaincode.executetimeout"); timeout != 1000 {
+		t.Fatalf("Expected the reloaded execute timeout, got %d", timeout)
+	}
+	if address := viper.GetString("peer.address"); address != "0.0.0.0:30303" {
+		t.Fatalf("Expected the address not to be reloaded, got %s", address)
+	}
+
+	writeReloadTestConfig(t, path, "executetimeout: 30000", "executetimeout: soon")
+	if _, err := ReloadConfig(); err == nil {
+		t.Fatalf("Expected an invalid execute timeout to fail the reload")
+	}
+	if timeout := viper.GetInt("chaincode.executetimeout"); timeout != 1000 {
+		t.Fatalf("Expected the execute timeout to be kept, got %d", timeout)
+	}
+
+	os.Setenv("RELOADTEST_CHAINCODE_EXECUTETIMEOUT", "2000")
+	defer os.Unsetenv("RELOADTEST_CHAINCODE_EXECUTETIMEOUT")
+	if _, err := ReloadConfig(); err != nil {
+		t.Fatalf("Error reloading the configuration: %s", err)
+	}
+	if timeout := viper.GetInt("chaincode.executetimeout"); timeout != 2000 {
+		t.Fatalf("Expected the environment override of the execute timeout, got %d", timeout)
+	}
+}
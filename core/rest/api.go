@@ -25,7 +25,10 @@ import (
 	"google/protobuf"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/consensus/helper"
+	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -40,6 +43,18 @@ type PeerInfo interface {
 	GetPeerEndpoint() (*pb.PeerEndpoint, error)
 }
 
+// PeerHealthInfo is implemented by the peers which probe the liveness of the
+// peers they chat with
+type PeerHealthInfo interface {
+	GetPeersHealth() (*pb.PeersHealth, error)
+}
+
+// PeerTopologyInfo is implemented by the peers which know the topology of
+// the network around them
+type PeerTopologyInfo interface {
+	GetNetworkTopology() (*pb.NetworkTopology, error)
+}
+
 // ServerOpenchain defines the Openchain server object, which holds the
 // Ledger data structure and the pointer to the peerServer.
 type ServerOpenchain struct {
@@ -96,28 +111,55 @@ func (s *ServerOpenchain) GetBlockByNumber(ctx context.Context, num *pb.BlockNum
 		}
 	}
 
-	// Remove payload from deploy transactions. This is done to make rest api
-	// calls more lightweight as the payload for these types of transactions
-	// can be very large. If the payload is needed, the caller should fetch the
-	// individual transaction.
+	err = removeCodePackages(block)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetBlockByTxID returns the data contained within the block holding the
+// transaction with the specified ID.
+func (s *ServerOpenchain) GetBlockByTxID(ctx context.Context, txID *pb.TxID) (*pb.Block, error) {
+	block, err := s.ledger.GetBlockByTxID(txID.Uuid)
+	if err != nil {
+		switch err {
+		case ledger.ErrResourceNotFound:
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("Error retrieving block from blockchain: %s", err)
+		}
+	}
+
+	err = removeCodePackages(block)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// removeCodePackages removes the code package from the payload of deploy and
+// upgrade transactions. This is done to make rest api calls more lightweight as the
+// payload for these types of transactions can be very large. If the payload is
+// needed, the caller should fetch the individual transaction.
+func removeCodePackages(block *pb.Block) error {
 	blockTransactions := block.GetTransactions()
 	for _, transaction := range blockTransactions {
-		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY {
+		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY || transaction.Type == pb.Transaction_CHAINCODE_UPGRADE {
 			deploymentSpec := &pb.ChaincodeDeploymentSpec{}
 			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			deploymentSpec.CodePackage = nil
 			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			transaction.Payload = deploymentSpecBytes
 		}
 	}
-
-	return block, nil
+	return nil
 }
 
 // GetBlockCount returns the current number of blocks in the blockchain data
@@ -156,11 +198,100 @@ func (s *ServerOpenchain) GetTransactionByUUID(ctx context.Context, txUUID strin
 	return transaction, nil
 }
 
+// ListTransactions returns the transactions matching filter, skipping the
+// first offset ones and returning at most limit. As with blocks, the code
+// package is removed from the payload of deploy and upgrade transactions.
+func (s *ServerOpenchain) ListTransactions(ctx context.Context, filter *ledger.TxFilter, offset uint64, limit uint64) ([]*ledger.IndexedTransaction, error) {
+	indexed, err := s.ledger.ListTransactions(filter, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing transactions from blockchain: %s", err)
+	}
+	transactions := &pb.Block{}
+	for _, indexedTx := range indexed {
+		transactions.Transactions = append(transactions.Transactions, indexedTx.Transaction)
+	}
+	if err := removeCodePackages(transactions); err != nil {
+		return nil, err
+	}
+	return indexed, nil
+}
+
+// GetTxReadWriteSet returns the keys read and the changes made by the transaction matching the specified UUID
+func (s *ServerOpenchain) GetTxReadWriteSet(ctx context.Context, txUUID string) (*statemgmt.TxReadWriteSet, error) {
+	rwSet, err := s.ledger.GetTxReadWriteSet(txUUID)
+	if err != nil {
+		switch err {
+		case ledger.ErrResourceNotFound:
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("Error retrieving transaction read/write set from ledger: %s", err)
+		}
+	}
+	return rwSet, nil
+}
+
+// GetTransactionStatus returns whether the transaction with the given UUID is
+// pending, committed or failed
+func (s *ServerOpenchain) GetTransactionStatus(ctx context.Context, txUUID string) (*ledger.TxResult, error) {
+	result, err := s.ledger.GetTxResult(txUUID)
+	if err != nil {
+		switch err {
+		case ledger.ErrResourceNotFound:
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("Error retrieving transaction status from ledger: %s", err)
+		}
+	}
+	return result, nil
+}
+
+// GetChaincodeMetrics returns the usage of the chaincodes executed by the
+// target peer, by chaincode name
+func (s *ServerOpenchain) GetChaincodeMetrics(ctx context.Context) (map[string]*chaincode.ChaincodeMetrics, error) {
+	chain := chaincode.GetChain(chaincode.DefaultChain)
+	if chain == nil {
+		return nil, notSupportedError("Chaincodes are not executed by this peer")
+	}
+	return chain.GetMetrics(), nil
+}
+
+// GetConsensusMetrics returns the metrics of the consensus plugin of the
+// target peer, e.g. the current PBFT timeouts.
+func (s *ServerOpenchain) GetConsensusMetrics(ctx context.Context) (interface{}, error) {
+	return helper.GetConsensusMetrics()
+}
+
+// GetConsensusEvidence returns the evidence of misbehavior of other
+// validators collected by the consensus plugin of the target peer.
+func (s *ServerOpenchain) GetConsensusEvidence(ctx context.Context) ([]*pb.Evidence, error) {
+	return helper.GetConsensusEvidence()
+}
+
 // GetPeers returns a list of all peer nodes currently connected to the target peer.
 func (s *ServerOpenchain) GetPeers(ctx context.Context, e *google_protobuf.Empty) (*pb.PeersMessage, error) {
 	return s.peerInfo.GetPeers()
 }
 
+// GetPeersHealth returns the liveness of the peers chatting with the target
+// peer.
+func (s *ServerOpenchain) GetPeersHealth(ctx context.Context) (*pb.PeersHealth, error) {
+	info, ok := s.peerInfo.(PeerHealthInfo)
+	if !ok {
+		return nil, notSupportedError("Peer does not probe the liveness of its peers")
+	}
+	return info.GetPeersHealth()
+}
+
+// GetNetworkTopology returns the topology of the network around the target
+// peer.
+func (s *ServerOpenchain) GetNetworkTopology(ctx context.Context) (*pb.NetworkTopology, error) {
+	info, ok := s.peerInfo.(PeerTopologyInfo)
+	if !ok {
+		return nil, notSupportedError("Peer does not know the topology of the network")
+	}
+	return info.GetNetworkTopology()
+}
+
 // GetPeerEndpoint returns PeerEndpoint info of target peer.
 func (s *ServerOpenchain) GetPeerEndpoint(ctx context.Context, e *google_protobuf.Empty) (*pb.PeersMessage, error) {
 	peers := []*pb.PeerEndpoint{}
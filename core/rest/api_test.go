@@ -18,13 +18,24 @@ package rest
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"google/protobuf"
 
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
@@ -255,6 +266,84 @@ func TestServerOpenchain_API_GetState(t *testing.T) {
 
 }
 
+func TestServerOpenchain_API_GetBlockByTxID(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	// Construct a blockchain with 3 blocks.
+	buildTestLedger1(ledger1, t)
+
+	// Initialize the OpenchainServer object.
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Logf("Error creating OpenchainServer: %s", err)
+		t.Fail()
+	}
+
+	// Retrieve the 2nd block through the UUID of its second transaction.
+	block2, err := server.GetBlockByNumber(context.Background(), &protos.BlockNumber{Number: 2})
+	if err != nil {
+		t.Fatalf("Error retrieving Block from blockchain: %s", err)
+	}
+	block, err := server.GetBlockByTxID(context.Background(), &protos.TxID{Uuid: block2.Transactions[1].Uuid})
+	if err != nil {
+		t.Fatalf("Error retrieving Block by transaction ID: %s", err)
+	} else if !bytes.Equal(block.PreviousBlockHash, block2.PreviousBlockHash) {
+		t.Fatalf("Expected Block #2, but got %v", block)
+	}
+
+	// Retrieve the block of a non-existent transaction, this test should
+	// intentionally fail.
+	block, err = server.GetBlockByTxID(context.Background(), &protos.TxID{Uuid: "non-existent-uuid"})
+	if err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound retrieving Block of non-existent transaction, but got %v, %v", block, err)
+	}
+}
+
+func TestServerOpenchainREST_ListTransactions(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	// Construct a blockchain with 3 blocks, and 3 transactions.
+	buildTestLedger1(ledger1, t)
+
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+	serverOpenchain = server
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).SetOpenchainServer)
+	router.Get("/transactions", (*ServerOpenchainREST).ListTransactions)
+
+	list := func(query string) (int, *transactionList, *restError) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/transactions?"+query, nil)
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			restErr := &restError{}
+			json.Unmarshal(rec.Body.Bytes(), restErr)
+			return rec.Code, nil, restErr
+		}
+		page := &transactionList{}
+		if err := json.Unmarshal(rec.Body.Bytes(), page); err != nil {
+			t.Fatalf("Error unmarshalling the transactions %s: %s", rec.Body.String(), err)
+		}
+		return rec.Code, page, nil
+	}
+
+	_, page, _ := list("limit=2")
+	if len(page.Transactions) != 2 || !page.More || page.Transactions[0].BlockNumber != 1 || page.Transactions[1].BlockNumber != 2 {
+		t.Fatalf("Expected the transactions of blocks 1 and 2 in the first page, got %+v", page)
+	}
+	_, page, _ = list("limit=2&offset=2")
+	if len(page.Transactions) != 1 || page.More || page.Transactions[0].Index != 1 {
+		t.Fatalf("Expected the second transaction of block 2 in the last page, got %+v", page)
+	}
+	for _, query := range []string{"type=BOGUS", "since=yesterday", "limit=0"} {
+		status, _, restErr := list(query)
+		if status != http.StatusBadRequest || restErr.Field != strings.Split(query, "=")[0] {
+			t.Fatalf("Expected %s to be rejected, got %d: %+v", query, status, restErr)
+		}
+	}
+}
+
 // buildTestLedger1 builds a simple ledger data structure that contains a blockchain with 3 blocks.
 func buildTestLedger1(ledger1 *ledger.Ledger, t *testing.T) {
 	// -----------------------------<Block #0>---------------------
@@ -441,3 +530,219 @@ func buildTestLedger2(ledger *ledger.Ledger, t *testing.T) {
 func generateUUID(t *testing.T) string {
 	return util.GenerateUUID()
 }
+
+func TestServerOpenchainREST_GetTransactionStatus(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	// Construct a blockchain with 3 blocks, and 3 transactions.
+	buildTestLedger1(ledger1, t)
+	block, err := ledger1.GetBlockByNumber(1)
+	if err != nil {
+		t.Fatalf("Error getting block 1: %s", err)
+	}
+	ledger1.TxSubmitted("pending")
+	ledger1.TxRejected(&protos.Transaction{Uuid: "rejected"}, protos.RejectionPhase_CONSENSUS, protos.RejectionReason_CONSENSUS_REJECTED, fmt.Errorf("Engine not initialized"))
+
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+	serverOpenchain = server
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).SetOpenchainServer)
+	router.Get("/transactions/:uuid/status", (*ServerOpenchainREST).GetTransactionStatus)
+
+	get := func(uuid string) (int, *transactionStatus) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/transactions/"+uuid+"/status", nil)
+		router.ServeHTTP(rec, req)
+		status := &transactionStatus{}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), status); err != nil {
+				t.Fatalf("Error unmarshalling the status %s: %s", rec.Body.String(), err)
+			}
+		}
+		return rec.Code, status
+	}
+
+	if code, status := get(block.Transactions[0].Uuid); code != http.StatusOK || status.Status != "COMMITTED" || status.BlockNumber == nil || *status.BlockNumber != 1 {
+		t.Fatalf("Expected the transaction to be committed in block 1, got %d: %+v", code, status)
+	}
+	if code, status := get("pending"); code != http.StatusOK || status.Status != "PENDING" || status.BlockNumber != nil {
+		t.Fatalf("Expected the transaction to be pending, got %d: %+v", code, status)
+	}
+	if code, status := get("rejected"); code != http.StatusOK || status.Status != "REJECTED" || status.BlockNumber != nil || status.Phase != "CONSENSUS" || status.Reason != "CONSENSUS_REJECTED" {
+		t.Fatalf("Expected the transaction to be rejected by consensus, got %d: %+v", code, status)
+	}
+	if code, _ := get("unknown"); code != http.StatusNotFound {
+		t.Fatalf("Expected an unknown transaction not to be found, got %d", code)
+	}
+}
+
+func TestServerOpenchainREST_ProcessChaincode(t *testing.T) {
+	router := web.New(ServerOpenchainREST{})
+	router.Post("/chaincode", (*ServerOpenchainREST).ProcessChaincode)
+
+	call := func(payload string, enrollmentID string) (int, *rpcResponse) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/chaincode", strings.NewReader(payload))
+		if enrollmentID != "" {
+			req.Header.Set(enrollmentIDHeader, enrollmentID)
+		}
+		router.ServeHTTP(rec, req)
+		response := &rpcResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+			t.Fatalf("Error unmarshalling the response %s: %s", rec.Body.String(), err)
+		}
+		return rec.Code, response
+	}
+
+	code, response := call(`{"jsonrpc":"2.0","method":"upgrade","params":{"chaincodeID":{"path":"github.com/mycc"},"ctorMsg":{"function":"init"}},"id":1}`, "")
+	if code != http.StatusOK || response.Error == nil || response.Error.Code != InvalidParams.Code {
+		t.Fatalf("Expected an upgrade without the chaincode name to be rejected, got %d: %+v", code, response)
+	}
+	code, response = call(`{"jsonrpc":"2.0","method":"upgrade","params":{"chaincodeID":{"name":"mycc"},"secureContext":"jim"},"id":2}`, "lukas")
+	if code != http.StatusBadRequest || response.Error == nil || response.Error.Code != InvalidParams.Code {
+		t.Fatalf("Expected a secureContext differing from the enrollment ID header to be rejected, got %d: %+v", code, response)
+	}
+	code, response = call(`{"jsonrpc":"2.0","method":"build","params":{},"id":3}`, "")
+	if code != http.StatusNotFound || response.Error == nil || response.Error.Code != MethodNotFound.Code {
+		t.Fatalf("Expected an unknown method not to be found, got %d: %+v", code, response)
+	}
+}
+
+func TestSecureContext(t *testing.T) {
+	tests := []struct {
+		payload string
+		header  string
+		context string
+		caller  *caller
+		ok      bool
+	}{
+		{"", "", "", nil, true},
+		{"jim", "", "jim", nil, true},
+		{"", "lukas", "lukas", nil, true},
+		{"lukas", "lukas", "lukas", nil, true},
+		{"jim", "lukas", "", nil, false},
+		{"jim", "", "jim", &caller{"jim", roleInvoke}, true},
+		{"", "jim", "jim", &caller{"jim", roleInvoke}, true},
+		{"lukas", "", "lukas", &caller{"jim", roleInvoke}, false},
+		{"", "lukas", "lukas", &caller{"jim", roleInvoke}, false},
+		{"lukas", "", "lukas", &caller{"", roleInvoke}, false},
+		{"lukas", "", "lukas", &caller{"diego", roleAdmin}, true},
+		{"", "", "", &caller{"jim", roleInvoke}, true},
+	}
+	for _, test := range tests {
+		httpReq, _ := http.NewRequest("POST", "/chaincode", nil)
+		if test.header != "" {
+			httpReq.Header.Set(enrollmentIDHeader, test.header)
+		}
+		s := &ServerOpenchainREST{caller: test.caller}
+		selected, err := s.secureContext(&web.Request{Request: httpReq}, test.payload)
+		if (err == nil) != test.ok || (test.ok && selected != test.context) {
+			t.Errorf("Expected secureContext %s and header %s to select '%s' (%t) for %+v, got '%s' (%v)", test.payload, test.header, test.context, test.ok, test.caller, selected, err)
+		}
+	}
+}
+
+// batchCoordinator executes every transaction successfully but those of the
+// chaincode named fail
+type batchCoordinator struct {
+	peer.MessageHandlerCoordinator
+}
+
+func (c *batchCoordinator) ExecuteTransaction(tx *protos.Transaction) *protos.Response {
+	spec := &protos.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(tx.Payload, spec); err != nil || spec.ChaincodeSpec.ChaincodeID.Name == "fail" {
+		return &protos.Response{Status: protos.Response_FAILURE, Msg: []byte("failed")}
+	}
+	return &protos.Response{Status: protos.Response_SUCCESS, Msg: []byte(tx.Uuid)}
+}
+
+func TestServerOpenchainREST_ACL(t *testing.T) {
+	viper.Set("peer.acl.enabled", true)
+	viper.Set("peer.acl.operations.invoke", []string{"CN=admin"})
+	defer viper.Set("peer.acl.enabled", false)
+	defer viper.Set("peer.acl.operations.invoke", []string{})
+	acl, err := core.NewACL()
+	if err != nil {
+		t.Fatalf("Failed creating the ACL: %s", err)
+	}
+	defer func(devops protos.DevopsServer) { serverDevops = devops }(serverDevops)
+	serverDevops = acl.GuardDevops(core.NewDevopsServer(&batchCoordinator{}))
+
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).SetOpenchainServer)
+	router.Post("/chaincode/batch", (*ServerOpenchainREST).InvokeBatch)
+
+	// the REST clients are authorized by their verified TLS client certificate
+	call := func(cert *x509.Certificate) int {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/chaincode/batch", strings.NewReader(`[{"chaincodeSpec": {"type": "GOLANG", "chaincodeID": {"name": "mycc"}, "ctorMsg": {"function": "invoke"}}}]`))
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := call(nil); code != http.StatusBadRequest {
+		t.Errorf("Expected the invocation without a client certificate to be denied, got %d", code)
+	}
+	if code := call(&x509.Certificate{Subject: pkix.Name{CommonName: "jim"}}); code != http.StatusBadRequest {
+		t.Errorf("Expected the invocation of a client not allowed to be denied, got %d", code)
+	}
+	if code := call(&x509.Certificate{Subject: pkix.Name{CommonName: "admin"}}); code != http.StatusOK {
+		t.Errorf("Expected the invocation of an allowed client to succeed, got %d", code)
+	}
+}
+
+func TestServerOpenchainREST_InvokeBatch(t *testing.T) {
+	defer func(devops protos.DevopsServer) { serverDevops = devops }(serverDevops)
+	serverDevops = core.NewDevopsServer(&batchCoordinator{})
+
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).SetOpenchainServer)
+	router.Post("/chaincode/batch", (*ServerOpenchainREST).InvokeBatch)
+
+	call := func(payload string, enrollmentID string) (*httptest.ResponseRecorder, []batchResult) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/chaincode/batch", strings.NewReader(payload))
+		if enrollmentID != "" {
+			req.Header.Set(enrollmentIDHeader, enrollmentID)
+		}
+		router.ServeHTTP(rec, req)
+		var results []batchResult
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+				t.Fatalf("Error unmarshalling the response %s: %s", rec.Body.String(), err)
+			}
+		}
+		return rec, results
+	}
+
+	rec, results := call(`[
+		{"chaincodeSpec": {"type": "GOLANG", "chaincodeID": {"name": "mycc"}, "ctorMsg": {"function": "invoke"}}},
+		{"chaincodeSpec": {"type": "GOLANG", "chaincodeID": {"name": "fail"}, "ctorMsg": {"function": "invoke"}}},
+		{"chaincodeSpec": {"type": "GOLANG", "chaincodeID": {"name": "mycc"}, "ctorMsg": {"function": "invoke"}}}]`, "")
+	if rec.Code != http.StatusOK || len(results) != 3 {
+		t.Fatalf("Expected the results of 3 invocations, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if results[0].Message == "" || results[0].Error != "" || results[1].Error != "failed" || results[2].Message == results[0].Message {
+		t.Fatalf("Expected the first and last invocations to return distinct UUIDs and the second to fail, got %+v", results)
+	}
+
+	for _, test := range []struct {
+		payload string
+		field   string
+	}{
+		{`{"chaincodeSpec": {}}`, ""},
+		{`[{"chaincodeSpec": {"chaincodeID": {"name": "mycc"}, "ctorMsg": {"function": "invoke"}}}, {"chaincodeSpec": {"ctorMsg": {"function": "invoke"}}}]`, "[1].chaincodeSpec.chaincodeID.name"},
+		{`[{"chaincodeSpec": {"chaincodeID": {"name": "mycc"}}}]`, "[0].chaincodeSpec.ctorMsg.function"},
+		{`[{"chaincodeSpec": {"chaincodeID": {"name": "mycc"}, "ctorMsg": {"function": "invoke"}, "secureContext": "jim"}}]`, "[0].chaincodeSpec.secureContext"},
+	} {
+		rec, _ = call(test.payload, "lukas")
+		restErr := &restError{}
+		if err := json.Unmarshal(rec.Body.Bytes(), restErr); rec.Code != http.StatusBadRequest || err != nil || restErr.Field != test.field {
+			t.Errorf("Expected %s to be rejected blaming '%s', got %d: %s", test.payload, test.field, rec.Code, rec.Body.String())
+		}
+	}
+}
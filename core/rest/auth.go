@@ -0,0 +1,358 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/attributes"
+)
+
+// role is the role of a REST client. Each role is allowed the requests of the
+// roles below it.
+type role int
+
+const (
+	rolePublic role = iota // no credentials required
+	roleQuery              // read the chain and query chaincodes
+	roleInvoke             // invoke chaincodes and submit transactions
+	roleAdmin              // deploy chaincodes and read consensus evidence
+)
+
+var roleNames = map[string]role{
+	"query":  roleQuery,
+	"invoke": roleInvoke,
+	"admin":  roleAdmin,
+}
+
+func (r role) String() string {
+	for name, value := range roleNames {
+		if value == r {
+			return name
+		}
+	}
+	return "public"
+}
+
+// parseRole returns the role named name
+func parseRole(name string) (role, error) {
+	r, ok := roleNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return rolePublic, fmt.Errorf("Invalid role '%s', expected query, invoke or admin", name)
+	}
+	return r, nil
+}
+
+// authenticator maps the credentials of REST requests to roles. Bearer tokens
+// and basic auth users are configured per role in rest.auth. If
+// rest.auth.attribute is set, the users logged in through /registrar
+// authenticate with their enrollment secret as well, and their role is the
+// value of that attribute in the TCerts the TCA issues them.
+type authenticator struct {
+	tokens    map[string]role
+	users     map[string]role // by "user:password"
+	attribute string
+	caRole    func(enrollID string) (role, error)
+
+	lock    sync.Mutex
+	secrets map[string][sha256.Size]byte // digests of the secrets of the users logged in
+	roles   map[string]role              // roles read from the TCerts of the users logged in
+}
+
+// restAuth authenticates the REST requests, or is nil if rest.auth is disabled
+var restAuth *authenticator
+
+// newAuthenticator creates an authenticator with the credentials configured
+// in rest.auth
+func newAuthenticator() (*authenticator, error) {
+	a := &authenticator{
+		tokens:    make(map[string]role),
+		users:     make(map[string]role),
+		attribute: viper.GetString("rest.auth.attribute"),
+		secrets:   make(map[string][sha256.Size]byte),
+		roles:     make(map[string]role),
+	}
+	a.caRole = a.tcertRole
+	for name, r := range roleNames {
+		for _, token := range viper.GetStringSlice("rest.auth.tokens." + name) {
+			if token != "" {
+				a.tokens[token] = r
+			}
+		}
+		for _, user := range viper.GetStringSlice("rest.auth.users." + name) {
+			if !strings.Contains(user, ":") {
+				return nil, fmt.Errorf("Invalid rest.auth.users.%s entry '%s', expected user:password", name, user)
+			}
+			a.users[user] = r
+		}
+	}
+	if len(a.tokens) == 0 && len(a.users) == 0 && a.attribute == "" {
+		restLogger.Warning("REST authorization is enabled without any credentials, only public requests will be served")
+	}
+	return a, nil
+}
+
+// lookup returns the role of credentials in the map, comparing them in
+// constant time
+func lookup(credentials string, roles map[string]role) (role, bool) {
+	found, r := false, rolePublic
+	for known, knownRole := range roles {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(credentials)) == 1 {
+			found, r = true, knownRole
+		}
+	}
+	return r, found
+}
+
+// caller is the authenticated client of a REST request. The clients
+// authenticated by a bearer token have no enrollment ID.
+type caller struct {
+	enrollID string
+	role     role
+}
+
+// authenticate returns the client of req. It returns false if req carries no
+// credentials or unknown ones.
+func (a *authenticator) authenticate(req *http.Request) (caller, bool) {
+	header := req.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		r, found := lookup(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")), a.tokens)
+		return caller{role: r}, found
+	}
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return caller{}, false
+	}
+	r, found, err := a.userRole(user, password)
+	if err != nil {
+		restLogger.Errorf("Error reading the role of user '%s' from its TCert: %s", user, err)
+	}
+	return caller{enrollID: user, role: r}, found
+}
+
+// userRole returns the role of the basic auth user, configured or logged in
+func (a *authenticator) userRole(user, password string) (role, bool, error) {
+	if r, found := lookup(user+":"+password, a.users); found {
+		return r, true, nil
+	}
+	if a.attribute == "" {
+		return rolePublic, false, nil
+	}
+
+	a.lock.Lock()
+	secret, loggedIn := a.secrets[user]
+	r, known := a.roles[user]
+	a.lock.Unlock()
+	digest := sha256.Sum256([]byte(password))
+	if !loggedIn || subtle.ConstantTimeCompare(secret[:], digest[:]) != 1 {
+		return rolePublic, false, nil
+	}
+	if known {
+		return r, true, nil
+	}
+	r, err := a.caRole(user)
+	if err != nil {
+		return rolePublic, false, err
+	}
+	a.lock.Lock()
+	a.roles[user] = r
+	a.lock.Unlock()
+	return r, true, nil
+}
+
+// tcertRole reads the role of the user enrollID, logged in on this peer, from
+// the role attribute of a TCert it is issued
+func (a *authenticator) tcertRole(enrollID string) (role, error) {
+	client, err := crypto.InitClient(enrollID, nil)
+	if err != nil {
+		return rolePublic, err
+	}
+	defer crypto.CloseClient(client)
+	tcerts, err := client.GetNextTCerts(1, a.attribute)
+	if err != nil {
+		return rolePublic, err
+	}
+	if len(tcerts) == 0 {
+		return rolePublic, fmt.Errorf("No TCert issued")
+	}
+	value, err := attributes.GetValueForAttribute(a.attribute, tcerts[0].GetPreK0(), tcerts[0].GetCertificate())
+	if err != nil {
+		return rolePublic, err
+	}
+	return parseRole(string(value))
+}
+
+// loggedIn records the secret of the user enrollID, who logged in through
+// /registrar, for it to authenticate its requests with
+func (a *authenticator) loggedIn(enrollID string, secret string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.secrets[enrollID] = sha256.Sum256([]byte(secret))
+	delete(a.roles, enrollID)
+}
+
+// loggedOut forgets the secret and role of the user enrollID
+func (a *authenticator) loggedOut(enrollID string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.secrets, enrollID)
+	delete(a.roles, enrollID)
+}
+
+// chaincodeRoles are the roles required by the methods of the /chaincode
+// endpoint
+var chaincodeRoles = map[string]role{
+	"deploy":      roleAdmin,
+	"upgrade":     roleAdmin,
+	"invoke":      roleInvoke,
+	"query":       roleQuery,
+	"queryQuorum": roleQuery,
+}
+
+// requiredRole returns the role required to serve req
+func requiredRole(req *http.Request) role {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	switch req.Method {
+	case "OPTIONS":
+		return rolePublic
+	case "GET":
+		switch {
+		case path == "/network/consensus/evidence":
+			return roleAdmin
+		case strings.HasPrefix(path, "/registrar/") && (strings.HasSuffix(path, "/ecert") || strings.HasSuffix(path, "/tcert")):
+			return roleInvoke
+		}
+		return roleQuery
+	case "POST":
+		switch path {
+		case "/registrar":
+			// the enrollment secret is checked by the CA
+			return rolePublic
+		case "/devops/query":
+			return roleQuery
+		case "/devops/invoke", "/transactions", "/chaincode/batch":
+			return roleInvoke
+		case "/devops/deploy":
+			return roleAdmin
+		case "/chaincode":
+			return chaincodeRole(req)
+		}
+	case "DELETE":
+		if strings.HasPrefix(path, "/registrar/") {
+			return roleInvoke
+		}
+	}
+	return roleAdmin
+}
+
+// chaincodeRole returns the role required by the JSON RPC method of a
+// /chaincode request, leaving the body for the handler to read. Requests
+// which do not parse require the query role, the handler rejects them.
+func chaincodeRole(req *http.Request) role {
+	if req.Body == nil {
+		return roleQuery
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return roleQuery
+	}
+	var rpc struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &rpc); err != nil {
+		return roleQuery
+	}
+	if r, ok := chaincodeRoles[rpc.Method]; ok {
+		return r
+	}
+	return roleQuery
+}
+
+// registrarID returns the enrollment ID of a /registrar/:id request, "" for
+// the other requests
+func registrarID(req *http.Request) string {
+	path := strings.TrimPrefix(strings.TrimSuffix(req.URL.Path, "/"), "/registrar/")
+	if path == req.URL.Path || path == "" {
+		return ""
+	}
+	return strings.SplitN(path, "/", 2)[0]
+}
+
+// checkEnrollmentID returns an error unless the client of the request may act
+// as the user enrollID: a client acts only as itself, unless it has the admin
+// role. Any client is allowed if rest.auth is disabled.
+func (s *ServerOpenchainREST) checkEnrollmentID(enrollID string) error {
+	if s.caller == nil || enrollID == "" || s.caller.role >= roleAdmin || s.caller.enrollID == enrollID {
+		return nil
+	}
+	if s.caller.enrollID == "" {
+		return fmt.Errorf("The client is not allowed to act as user %s.", enrollID)
+	}
+	return fmt.Errorf("User %s is not allowed to act as user %s.", s.caller.enrollID, enrollID)
+}
+
+// Authorize is a middleware function that rejects the requests whose client
+// lacks the role they require, or selects with the enrollment ID header or
+// the path of /registrar another user than itself without the admin role, if
+// rest.auth is enabled.
+func (s *ServerOpenchainREST) Authorize(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	if restAuth == nil {
+		next(rw, req)
+		return
+	}
+	required := requiredRole(req.Request)
+	if required == rolePublic {
+		next(rw, req)
+		return
+	}
+	c, ok := restAuth.authenticate(req.Request)
+	if !ok {
+		restLogger.Errorf("Unauthenticated REST request %s %s", req.Method, req.URL.Path)
+		rw.Header().Add("WWW-Authenticate", "Bearer realm=\"fabric\"")
+		rw.Header().Add("WWW-Authenticate", "Basic realm=\"fabric\"")
+		writeError(rw, http.StatusUnauthorized, "Missing or invalid credentials, %s requires the %s role.", req.URL.Path, required)
+		return
+	}
+	if c.role < required {
+		restLogger.Errorf("REST request %s %s requires the %s role, client has the %s role", req.Method, req.URL.Path, required, c.role)
+		writeError(rw, http.StatusForbidden, "%s requires the %s role, client has the %s role.", req.URL.Path, required, c.role)
+		return
+	}
+	s.caller = &c
+	for _, enrollID := range []string{req.Header.Get(enrollmentIDHeader), registrarID(req.Request)} {
+		if err := s.checkEnrollmentID(enrollID); err != nil {
+			restLogger.Errorf("REST request %s %s denied: %s", req.Method, req.URL.Path, err)
+			writeError(rw, http.StatusForbidden, "%s", err)
+			return
+		}
+	}
+	next(rw, req)
+}
@@ -0,0 +1,183 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/viper"
+)
+
+func TestRequiredRole(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		role   role
+	}{
+		{"GET", "/chain", "", roleQuery},
+		{"GET", "/transactions/abc", "", roleQuery},
+		{"GET", "/registrar/jim/tcert", "", roleInvoke},
+		{"GET", "/network/consensus/evidence", "", roleAdmin},
+		{"POST", "/registrar", "", rolePublic},
+		{"DELETE", "/registrar/jim", "", roleInvoke},
+		{"POST", "/devops/query", "", roleQuery},
+		{"POST", "/devops/invoke", "", roleInvoke},
+		{"POST", "/chaincode/batch", "", roleInvoke},
+		{"POST", "/devops/deploy", "", roleAdmin},
+		{"POST", "/transactions", "", roleInvoke},
+		{"POST", "/chaincode", `{"jsonrpc":"2.0","method":"query","id":1}`, roleQuery},
+		{"POST", "/chaincode", `{"jsonrpc":"2.0","method":"invoke","id":1}`, roleInvoke},
+		{"POST", "/chaincode", `{"jsonrpc":"2.0","method":"deploy","id":1}`, roleAdmin},
+		{"POST", "/chaincode", `{"jsonrpc":"2.0","method":"upgrade","id":1}`, roleAdmin},
+		{"POST", "/chaincode", `not json`, roleQuery},
+		{"PUT", "/chain", "", roleAdmin},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if r := requiredRole(req); r != test.role {
+			t.Errorf("Expected %s %s %s to require the %s role, got %s", test.method, test.path, test.body, test.role, r)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/chaincode", strings.NewReader(`{"method":"deploy"}`))
+	requiredRole(req)
+	body := make([]byte, 64)
+	if n, _ := req.Body.Read(body); string(body[:n]) != `{"method":"deploy"}` {
+		t.Fatalf("Expected the /chaincode payload to be left for the handler, got %s", body[:n])
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	viper.Set("rest.auth.tokens.query", []string{"q-token"})
+	viper.Set("rest.auth.tokens.admin", []string{"a-token"})
+	viper.Set("rest.auth.users.invoke", []string{"jim:secret"})
+	viper.Set("rest.auth.attribute", "role")
+	defer func() {
+		viper.Set("rest.auth.tokens.query", nil)
+		viper.Set("rest.auth.tokens.admin", nil)
+		viper.Set("rest.auth.users.invoke", nil)
+		viper.Set("rest.auth.attribute", "")
+	}()
+	auth, err := newAuthenticator()
+	if err != nil {
+		t.Fatalf("Error creating the authenticator: %s", err)
+	}
+	caCalls := 0
+	auth.caRole = func(enrollID string) (role, error) {
+		caCalls++
+		if enrollID == "diego" {
+			return roleAdmin, nil
+		}
+		return rolePublic, fmt.Errorf("No role attribute")
+	}
+	auth.loggedIn("diego", "diego-secret")
+	auth.loggedIn("binhn", "binhn-secret")
+	restAuth = auth
+	defer func() { restAuth = nil }()
+
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).Authorize)
+	served := func(rw web.ResponseWriter, req *web.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}
+	router.Get("/chain", served)
+	router.Post("/registrar", served)
+	router.Post("/devops/invoke", served)
+	router.Post("/devops/deploy", served)
+	router.Delete("/registrar/:id", served)
+	router.Get("/registrar/:id/ecert", served)
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		user   string
+		secret string
+		header string
+		status int
+	}{
+		{"GET", "/chain", "", "", "", "", http.StatusUnauthorized},
+		{"POST", "/registrar", "", "", "", "", http.StatusOK},
+		{"GET", "/chain", "q-token", "", "", "", http.StatusOK},
+		{"GET", "/chain", "x-token", "", "", "", http.StatusUnauthorized},
+		{"POST", "/devops/invoke", "q-token", "", "", "", http.StatusForbidden},
+		{"POST", "/devops/deploy", "a-token", "", "", "", http.StatusOK},
+		{"POST", "/devops/invoke", "", "jim", "secret", "", http.StatusOK},
+		{"POST", "/devops/invoke", "", "jim", "wrong", "", http.StatusUnauthorized},
+		{"POST", "/devops/deploy", "", "jim", "secret", "", http.StatusForbidden},
+		{"POST", "/devops/deploy", "", "diego", "diego-secret", "", http.StatusOK},
+		{"POST", "/devops/deploy", "", "diego", "diego-secret", "", http.StatusOK},
+		{"GET", "/chain", "", "diego", "wrong", "", http.StatusUnauthorized},
+		{"GET", "/chain", "", "binhn", "binhn-secret", "", http.StatusUnauthorized},
+		{"GET", "/chain", "", "lukas", "lukas-secret", "", http.StatusUnauthorized},
+		{"POST", "/devops/invoke", "", "jim", "secret", "jim", http.StatusOK},
+		{"POST", "/devops/invoke", "", "jim", "secret", "lukas", http.StatusForbidden},
+		{"POST", "/devops/invoke", "q-token", "", "", "lukas", http.StatusForbidden},
+		{"POST", "/devops/deploy", "a-token", "", "", "lukas", http.StatusOK},
+		{"POST", "/devops/deploy", "", "diego", "diego-secret", "lukas", http.StatusOK},
+		{"DELETE", "/registrar/jim", "", "jim", "secret", "", http.StatusOK},
+		{"DELETE", "/registrar/lukas", "", "jim", "secret", "", http.StatusForbidden},
+		{"GET", "/registrar/lukas/ecert", "", "jim", "secret", "", http.StatusForbidden},
+		{"DELETE", "/registrar/lukas", "a-token", "", "", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		if test.user != "" {
+			req.SetBasicAuth(test.user, test.secret)
+		}
+		if test.header != "" {
+			req.Header.Set(enrollmentIDHeader, test.header)
+		}
+		router.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("Expected %s %s with token '%s', user '%s' and header '%s' to return %d, got %d: %s", test.method, test.path, test.token, test.user, test.header, test.status, rec.Code, rec.Body.String())
+			continue
+		}
+		if rec.Code == http.StatusForbidden {
+			restErr := &restError{}
+			if err := json.Unmarshal(rec.Body.Bytes(), restErr); err != nil || restErr.Code != errorCodeForbidden {
+				t.Errorf("Expected a FORBIDDEN error, got %s", rec.Body.String())
+			}
+		}
+		if rec.Code == http.StatusUnauthorized && len(rec.Header()["Www-Authenticate"]) != 2 {
+			t.Errorf("Expected the bearer and basic challenges, got %v", rec.Header()["Www-Authenticate"])
+		}
+	}
+	if caCalls != 2 {
+		t.Errorf("Expected the roles of diego and binhn to be read from their TCerts once, got %d reads", caCalls)
+	}
+
+	auth.loggedOut("diego")
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/chain", nil)
+	req.SetBasicAuth("diego", "diego-secret")
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected a user logged out to be unauthorized, got %d", rec.Code)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package rest
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,11 +29,14 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/gocraft/web"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 
@@ -41,6 +45,7 @@ import (
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/ledger"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -51,14 +56,16 @@ var restLogger = logging.MustGetLogger("rest")
 // the pointer to the underlying Devops object. This is necessary due to
 // how the gocraft/web package implements context initialization.
 var serverOpenchain *ServerOpenchain
-var serverDevops *core.Devops
+var serverDevops pb.DevopsServer
 
 // ServerOpenchainREST defines the Openchain REST service object. It exposes
 // the methods available on the ServerOpenchain service and the Devops service
 // through a REST API.
 type ServerOpenchainREST struct {
 	server *ServerOpenchain
-	devops *core.Devops
+	devops pb.DevopsServer
+	ctx    context.Context
+	caller *caller // authenticated client, nil if rest.auth is disabled
 }
 
 // restResult defines the response payload for a general REST interface request.
@@ -147,26 +154,66 @@ var (
 	ChaincodeDeployError     = &rpcError{Code: -32001, Message: "Deployment failure", Data: "Chaincode deployment has failed."}
 	ChaincodeInvokeError     = &rpcError{Code: -32002, Message: "Invocation failure", Data: "Chaincode invocation has failed."}
 	ChaincodeQueryError      = &rpcError{Code: -32003, Message: "Query failure", Data: "Chaincode query has failed."}
+	ChaincodeUpgradeError    = &rpcError{Code: -32004, Message: "Upgrade failure", Data: "Chaincode upgrade has failed."}
 )
 
+// chaincodeMethods are the JSON RPC methods of the /chaincode endpoint, each
+// the counterpart of a Devops service call
+var chaincodeMethods = map[string]bool{
+	"deploy":      true,
+	"upgrade":     true,
+	"invoke":      true,
+	"query":       true,
+	"queryQuorum": true,
+}
+
+// enrollmentIDHeader names the header selecting the user, logged in through
+// /registrar, whose credentials sign the transactions of a request
+const enrollmentIDHeader = "X-Enrollment-ID"
+
+// secureContext returns the enrollment ID of the user signing the
+// transaction of req: the secureContext of the payload, or else the
+// enrollment ID header. It fails if both are set and differ, or if the
+// client is not allowed to act as that user.
+func (s *ServerOpenchainREST) secureContext(req *web.Request, payloadContext string) (string, error) {
+	headerContext := req.Header.Get(enrollmentIDHeader)
+	if headerContext == "" {
+		return payloadContext, s.checkEnrollmentID(payloadContext)
+	}
+	if payloadContext != "" && payloadContext != headerContext {
+		return "", fmt.Errorf("The secureContext %s of the payload does not match the %s header %s.", payloadContext, enrollmentIDHeader, headerContext)
+	}
+	return headerContext, s.checkEnrollmentID(headerContext)
+}
+
 // SetOpenchainServer is a middleware function that sets the pointer to the
-// underlying ServerOpenchain object and the undeflying Devops object.
+// underlying ServerOpenchain object and the undeflying Devops object, and the
+// context of the Devops calls, which carries the verified TLS client
+// certificate of the request for the ACL of the peer.
 func (s *ServerOpenchainREST) SetOpenchainServer(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
 	s.server = serverOpenchain
 	s.devops = serverDevops
+	s.ctx = context.Background()
+	if req.TLS != nil {
+		s.ctx = credentials.NewContext(s.ctx, credentials.TLSInfo{State: *req.TLS})
+	}
 
 	next(rw, req)
 }
 
 // SetResponseType is a middleware function that sets the appropriate response
 // headers. Currently, it is setting the "Content-Type" to "application/json" as
-// well as the necessary headers in order to enable CORS for Swagger usage.
+// well as the CORS headers of the allowed origins, and the HSTS header of the
+// HTTPS responses.
 func (s *ServerOpenchainREST) SetResponseType(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
 	rw.Header().Set("Content-Type", "application/json")
 
 	// Enable CORS
-	rw.Header().Set("Access-Control-Allow-Origin", "*")
-	rw.Header().Set("Access-Control-Allow-Headers", "accept, content-type")
+	restCORS.setHeaders(rw, req)
+
+	if req.TLS != nil && restHSTS != "" {
+		rw.Header().Set("Strict-Transport-Security", restHSTS)
+	}
 
 	next(rw, req)
 }
@@ -200,12 +247,10 @@ func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request)
 
 		// Client must supply payload
 		if err == io.EOF {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Payload must contain object Secret with enrollId and enrollSecret fields.\"}")
+			writeError(rw, http.StatusBadRequest, "Payload must contain object Secret with enrollId and enrollSecret fields.")
 			restLogger.Error("{\"Error\": \"Payload must contain object Secret with enrollId and enrollSecret fields.\"}")
 		} else {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+			writeError(rw, http.StatusBadRequest, "%s", errVal)
 			restLogger.Errorf("{\"Error\": \"%s\"}", errVal)
 		}
 
@@ -214,8 +259,7 @@ func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request)
 
 	// Check that the enrollId and enrollSecret are not left blank.
 	if (loginSpec.EnrollId == "") || (loginSpec.EnrollSecret == "") {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"enrollId and enrollSecret may not be blank.\"}")
+		writeError(rw, http.StatusBadRequest, "enrollId and enrollSecret may not be blank.")
 		restLogger.Error("{\"Error\": \"enrollId and enrollSecret may not be blank.\"}")
 
 		return
@@ -238,7 +282,7 @@ func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request)
 	// User is not logged in, proceed with login
 	restLogger.Infof("Logging in user '%s' on REST interface...\n", loginSpec.EnrollId)
 
-	loginResult, err := s.devops.Login(context.Background(), &loginSpec)
+	loginResult, err := s.devops.Login(s.ctx, &loginSpec)
 
 	// Check if login is successful
 	if loginResult.Status == pb.Response_SUCCESS {
@@ -247,14 +291,12 @@ func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request)
 			if os.IsNotExist(err) {
 				// Directory does not exist, create it
 				if err := os.Mkdir(localStore, 0755); err != nil {
-					rw.WriteHeader(http.StatusInternalServerError)
-					fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+					writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 					panic(fmt.Errorf("Fatal error when creating %s directory: %s\n", localStore, err))
 				}
 			} else {
 				// Unexpected error
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+				writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 				panic(fmt.Errorf("Fatal error on os.Stat of %s directory: %s\n", localStore, err))
 			}
 		}
@@ -263,19 +305,22 @@ func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request)
 		restLogger.Infof("Storing login token for user '%s'.\n", loginSpec.EnrollId)
 		err = ioutil.WriteFile(localStore+"loginToken_"+loginSpec.EnrollId, []byte(loginSpec.EnrollId), 0755)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 			panic(fmt.Errorf("Fatal error when storing client login token: %s\n", err))
 		}
 
+		// The user authenticates its requests with its secret from now on
+		if restAuth != nil {
+			restAuth.loggedIn(loginSpec.EnrollId, loginSpec.EnrollSecret)
+		}
+
 		rw.WriteHeader(http.StatusOK)
 		fmt.Fprintf(rw, "{\"OK\": \"Login successful for user '%s'.\"}", loginSpec.EnrollId)
 		restLogger.Infof("Login successful for user '%s'.\n", loginSpec.EnrollId)
 	} else {
 		loginErr := strings.Replace(string(loginResult.Msg), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", loginErr)
+		writeError(rw, http.StatusUnauthorized, "%s", loginErr)
 		restLogger.Errorf("Error on client login: %s", loginErr)
 	}
 
@@ -298,8 +343,7 @@ func (s *ServerOpenchainREST) GetEnrollmentID(rw web.ResponseWriter, req *web.Re
 		fmt.Fprintf(rw, "{\"OK\": \"User %s is already logged in.\"}", enrollmentID)
 		restLogger.Infof("User '%s' is already logged in.\n", enrollmentID)
 	} else {
-		rw.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprintf(rw, "{\"Error\": \"User %s must log in.\"}", enrollmentID)
+		writeError(rw, http.StatusUnauthorized, "User %s must log in.", enrollmentID)
 		restLogger.Infof("User '%s' must log in.\n", enrollmentID)
 	}
 
@@ -340,8 +384,7 @@ func (s *ServerOpenchainREST) DeleteEnrollmentID(rw web.ResponseWriter, req *web
 
 	// The user is logged in, delete the user's login token
 	if err := os.RemoveAll(loginTok); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(rw, "{\"Error\": \"Error trying to delete login token for user %s: %s\"}", enrollmentID, err)
+		writeError(rw, http.StatusInternalServerError, "Error trying to delete login token for user %s: %s", enrollmentID, err)
 		restLogger.Errorf("{\"Error\": \"Error trying to delete login token for user %s: %s\"}", enrollmentID, err)
 
 		return
@@ -349,13 +392,17 @@ func (s *ServerOpenchainREST) DeleteEnrollmentID(rw web.ResponseWriter, req *web
 
 	// The user is logged in, delete the user's cert and key directory
 	if err := os.RemoveAll(cryptoDir); err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(rw, "{\"Error\": \"Error trying to delete login directory for user %s: %s\"}", enrollmentID, err)
+		writeError(rw, http.StatusInternalServerError, "Error trying to delete login directory for user %s: %s", enrollmentID, err)
 		restLogger.Errorf("{\"Error\": \"Error trying to delete login directory for user %s: %s\"}", enrollmentID, err)
 
 		return
 	}
 
+	// The user may no longer authenticate its requests with its secret
+	if restAuth != nil {
+		restAuth.loggedOut(enrollmentID)
+	}
+
 	rw.WriteHeader(http.StatusOK)
 	fmt.Fprintf(rw, "{\"OK\": \"Deleted login token and directory for user %s.\"}", enrollmentID)
 	restLogger.Infof("Deleted login token and directory for user %s.\n", enrollmentID)
@@ -379,8 +426,7 @@ func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.
 		// Initialize the security client
 		sec, err := crypto.InitClient(enrollmentID, nil)
 		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+			writeError(rw, http.StatusBadRequest, "%s", err)
 			restLogger.Errorf("{\"Error\": \"%s\"}", err)
 
 			return
@@ -389,8 +435,7 @@ func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.
 		// Obtain the client CertificateHandler
 		handler, err := sec.GetEnrollmentCertificateHandler()
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "%s", err)
 			restLogger.Errorf("{\"Error\": \"%s\"}", err)
 
 			return
@@ -398,8 +443,7 @@ func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.
 
 		// Certificate handler can not be hil
 		if handler == nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Error retrieving certificate handler.\"}")
+			writeError(rw, http.StatusInternalServerError, "Error retrieving certificate handler.")
 			restLogger.Error("{\"Error\": \"Error retrieving certificate handler.\"}")
 
 			return
@@ -410,8 +454,7 @@ func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.
 
 		// Confirm the retrieved enrollment certificate is not nil
 		if certDER == nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Enrollment certificate is nil.\"}")
+			writeError(rw, http.StatusInternalServerError, "Enrollment certificate is nil.")
 			restLogger.Error("{\"Error\": \"Enrollment certificate is nil.\"}")
 
 			return
@@ -419,8 +462,7 @@ func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.
 
 		// Confirm the retrieved enrollment certificate has non-zero length
 		if len(certDER) == 0 {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Enrollment certificate length is 0.\"}")
+			writeError(rw, http.StatusInternalServerError, "Enrollment certificate length is 0.")
 			restLogger.Error("{\"Error\": \"Enrollment certificate length is 0.\"}")
 
 			return
@@ -440,8 +482,7 @@ func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.
 		restLogger.Debugf("Successfully retrieved enrollment certificate for secure context '%s'", enrollmentID)
 	} else {
 		// Security must be enabled to request enrollment certificates
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Security functionality must be enabled before requesting client certificates.\"}")
+		writeError(rw, http.StatusBadRequest, "Security functionality must be enabled before requesting client certificates.")
 		restLogger.Error("{\"Error\": \"Security functionality must be enabled before requesting client certificates.\"}")
 
 		return
@@ -470,8 +511,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 
 		// Check for count parameter being a non-negative integer
 		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Count query parameter must be a non-negative integer.\"}")
+			writeFieldError(rw, http.StatusBadRequest, "count", "Count query parameter must be a non-negative integer.")
 			restLogger.Error("{\"Error\": \"Count query parameter must be a non-negative integer.\"}")
 
 			return
@@ -497,8 +537,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 		// Initialize the security client
 		sec, err := crypto.InitClient(enrollmentID, nil)
 		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+			writeError(rw, http.StatusBadRequest, "%s", err)
 			restLogger.Errorf("{\"Error\": \"%s\"}", err)
 
 			return
@@ -509,8 +548,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 		attributes := []string{}
 		handler, err := sec.GetTCertificateHandlerNext(attributes...)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "%s", err)
 			restLogger.Errorf("{\"Error\": \"%s\"}", err)
 
 			return
@@ -518,8 +556,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 
 		// Certificate handler can not be hil
 		if handler == nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Error retrieving certificate handler.\"}")
+			writeError(rw, http.StatusInternalServerError, "Error retrieving certificate handler.")
 			restLogger.Error("{\"Error\": \"Error retrieving certificate handler.\"}")
 
 			return
@@ -534,8 +571,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 
 			// Confirm the retrieved enrollment certificate is not nil
 			if certDER == nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(rw, "{\"Error\": \"Transaction certificate is nil.\"}")
+				writeError(rw, http.StatusInternalServerError, "Transaction certificate is nil.")
 				restLogger.Error("{\"Error\": \"Transaction certificate is nil.\"}")
 
 				return
@@ -543,8 +579,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 
 			// Confirm the retrieved enrollment certificate has non-zero length
 			if len(certDER) == 0 {
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(rw, "{\"Error\": \"Transaction certificate length is 0.\"}")
+				writeError(rw, http.StatusInternalServerError, "Transaction certificate length is 0.")
 				restLogger.Error("{\"Error\": \"Transaction certificate length is 0.\"}")
 
 				return
@@ -566,8 +601,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 		// Construct a JSON formatted response
 		jsonResponse, err := json.Marshal(tcertArray)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "%s", err)
 			restLogger.Errorf("{\"Error marshalling TCert array\": \"%s\"}", err)
 
 			return
@@ -578,8 +612,7 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 		restLogger.Debugf("Successfully retrieved transaction certificates for secure context '%s'", enrollmentID)
 	} else {
 		// Security must be enabled to request transaction certificates
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Security functionality must be enabled before requesting client certificates.\"}")
+		writeError(rw, http.StatusBadRequest, "Security functionality must be enabled before requesting client certificates.")
 		restLogger.Error("{\"Error\": \"Security functionality must be enabled before requesting client certificates.\"}")
 
 		return
@@ -591,17 +624,13 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 func (s *ServerOpenchainREST) GetBlockchainInfo(rw web.ResponseWriter, req *web.Request) {
 	info, err := s.server.GetBlockchainInfo(context.Background(), &google_protobuf.Empty{})
 
-	encoder := json.NewEncoder(rw)
-
 	// Check for error
 	if err != nil {
 		// Failure
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		writeError(rw, errorStatus(err), "%s", err)
 	} else {
 		// Success
-		rw.WriteHeader(http.StatusOK)
-		encoder.Encode(info)
+		writeResponse(rw, req, info)
 	}
 }
 
@@ -614,29 +643,224 @@ func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.R
 	// Check for proper Block id syntax
 	if err != nil {
 		// Failure
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Block id must be an integer (uint64).\"}")
+		writeFieldError(rw, http.StatusBadRequest, "Block", "Block id must be an integer (uint64).")
 	} else {
 		// Retrieve Block from blockchain
 		block, err := s.server.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: blockNumber})
 
 		// Check for error
-		if err != nil || block == nil {
+		if err != nil {
 			// Failure
-			switch {
-			case err == ErrNotFound || block == nil:
-				rw.WriteHeader(http.StatusNotFound)
-			default:
-				rw.WriteHeader(http.StatusInternalServerError)
-			}
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+			writeError(rw, errorStatus(err), "%s", err)
+		} else if block == nil {
+			writeError(rw, http.StatusNotFound, "%s", ErrNotFound)
 		} else {
 			// Success
-			rw.WriteHeader(http.StatusOK)
-			encoder := json.NewEncoder(rw)
-			encoder.Encode(block)
+			writeResponse(rw, req, block)
+		}
+	}
+}
+
+// GetBlockByTxID returns the block containing the transaction matching the specified UUID
+func (s *ServerOpenchainREST) GetBlockByTxID(rw web.ResponseWriter, req *web.Request) {
+	// Parse out the transaction UUID
+	txUUID := req.PathParams["uuid"]
+
+	// Retrieve the block containing the transaction matching the UUID
+	block, err := s.server.GetBlockByTxID(context.Background(), &pb.TxID{Uuid: txUUID})
+
+	// Check for Error
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			writeError(rw, http.StatusNotFound, "Transaction %s is not found.", txUUID)
+		default:
+			writeError(rw, http.StatusInternalServerError, "Error retrieving block of transaction %s: %s.", txUUID, err)
+			restLogger.Errorf("{\"Error\": \"Error retrieving block of transaction %s: %s.\"}", txUUID, err)
+		}
+	} else {
+		// Return the block
+		writeResponse(rw, req, block)
+	}
+}
+
+// submitRequest is the JSON payload of a transaction submitted by a client,
+// the base64 encoding of the marshalled Transaction
+type submitRequest struct {
+	Transaction []byte `json:"transaction"`
+}
+
+// submitResult is the response payload of a transaction submitted by a client
+type submitResult struct {
+	OK      string `json:",omitempty"`
+	Message string `json:"message,omitempty"` // the UUID of the transaction
+	Result  []byte `json:"result,omitempty"`  // the result of a query, base64 encoded
+}
+
+// SubmitTransaction submits a transaction built and signed by the client,
+// with its own keys, to consensus. The payload is the marshalled Transaction,
+// with a Content-Type of application/x-protobuf or application/octet-stream,
+// or else a JSON submitRequest.
+func (s *ServerOpenchainREST) SubmitTransaction(rw web.ResponseWriter, req *web.Request) {
+	restLogger.Info("REST submitting transaction...")
+
+	raw, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "Error reading payload: %s", err)
+		restLogger.Errorf("{\"Error\": \"Error reading payload: %s\"}", err)
+		return
+	}
+	switch strings.Split(req.Header.Get("Content-Type"), ";")[0] {
+	case "application/x-protobuf", "application/octet-stream":
+	default:
+		var request submitRequest
+		if err := json.Unmarshal(raw, &request); err != nil {
+			errVal := strings.Replace(err.Error(), "\"", "'", -1)
+			writeError(rw, http.StatusBadRequest, "%s", errVal)
+			restLogger.Errorf("{\"Error\": \"%s\"}", errVal)
+			return
+		}
+		raw = request.Transaction
+	}
+	if len(raw) == 0 {
+		writeError(rw, http.StatusBadRequest, "Payload must contain a Transaction.")
+		restLogger.Error("{\"Error\": \"Payload must contain a Transaction.\"}")
+		return
+	}
+
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(raw, tx); err != nil {
+		writeError(rw, http.StatusBadRequest, "Error unmarshalling Transaction: %s", err)
+		restLogger.Errorf("{\"Error\": \"Error unmarshalling Transaction: %s\"}", err)
+		return
+	}
+
+	resp, err := s.devops.Submit(s.ctx, tx)
+	if err != nil {
+		errVal := strings.Replace(err.Error(), "\"", "'", -1)
+		writeError(rw, http.StatusBadRequest, "%s", errVal)
+		restLogger.Errorf("{\"Error\": \"Submitting transaction failed: %s\"}", errVal)
+		return
+	}
+
+	result := submitResult{OK: "Successfully submitted transaction.", Message: tx.Uuid}
+	if tx.Type == pb.Transaction_CHAINCODE_QUERY {
+		result.Result = resp.Msg
+	}
+	rw.WriteHeader(http.StatusOK)
+	json.NewEncoder(rw).Encode(result)
+	restLogger.Infof("Successfully submitted transaction: %s", tx.Uuid)
+}
+
+// Default and maximum number of transactions listed by a request
+const (
+	defaultTransactionsLimit = 100
+	maxTransactionsLimit     = 500
+)
+
+// listedTransaction is a transaction listed by /transactions, along with its
+// position in the blockchain
+type listedTransaction struct {
+	BlockNumber uint64          `json:"blockNumber"`
+	Index       uint64          `json:"index"`
+	Transaction *pb.Transaction `json:"transaction"`
+}
+
+// transactionList is the response payload of /transactions, a page of the
+// transactions matching the query. More is true if the next page is not
+// empty.
+type transactionList struct {
+	Transactions []*listedTransaction `json:"transactions"`
+	Offset       uint64               `json:"offset"`
+	Limit        uint64               `json:"limit"`
+	More         bool                 `json:"more"`
+}
+
+// transactionStatus is the response payload of /transactions/{UUID}/status.
+// The block number is only set once the transaction is committed, the error
+// and the phase and reason of the failure once it failed or was rejected.
+type transactionStatus struct {
+	UUID        string    `json:"uuid"`
+	Status      string    `json:"status"`
+	BlockNumber *uint64   `json:"blockNumber,omitempty"`
+	ErrorCode   uint32    `json:"errorCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Phase       string    `json:"phase,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Updated     time.Time `json:"updated"`
+}
+
+// parseTxFilter parses the query parameters of /transactions into a filter,
+// returning the name of the invalid parameter, if any
+func parseTxFilter(query url.Values) (*ledger.TxFilter, string, error) {
+	filter := &ledger.TxFilter{ChaincodeName: query.Get("chaincodeID")}
+	if txType := query.Get("type"); txType != "" {
+		value, ok := pb.Transaction_Type_value[txType]
+		if !ok || value == int32(pb.Transaction_UNDEFINED) {
+			return nil, "type", fmt.Errorf("Transaction type %s is not valid.", txType)
 		}
+		filter.Type = pb.Transaction_Type(value)
 	}
+	for _, param := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, param.name, fmt.Errorf("%s must be an RFC 3339 time, e.g. 2016-06-01T12:00:00Z.", param.name)
+		}
+		*param.t = t
+	}
+	return filter, "", nil
+}
+
+// ListTransactions lists the transactions matching the chaincodeID, type, and
+// since and until query parameters, a page of limit transactions at a time
+// from the offset-th one on.
+func (s *ServerOpenchainREST) ListTransactions(rw web.ResponseWriter, req *web.Request) {
+	query := req.URL.Query()
+	filter, field, err := parseTxFilter(query)
+	if err != nil {
+		writeFieldError(rw, http.StatusBadRequest, field, "%s", err)
+		return
+	}
+	var offset, limit uint64 = 0, defaultTransactionsLimit
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.ParseUint(value, 10, 64); err != nil {
+			writeFieldError(rw, http.StatusBadRequest, "offset", "offset must be a non-negative integer.")
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.ParseUint(value, 10, 64); err != nil || limit == 0 {
+			writeFieldError(rw, http.StatusBadRequest, "limit", "limit must be a positive integer.")
+			return
+		}
+		if limit > maxTransactionsLimit {
+			limit = maxTransactionsLimit
+		}
+	}
+
+	// list one more transaction to know if there is a next page
+	indexed, err := s.server.ListTransactions(context.Background(), filter, offset, limit+1)
+	if err != nil {
+		writeError(rw, errorStatus(err), "%s", err)
+		restLogger.Errorf("{\"Error\": \"Listing transactions -- %s\"}", err)
+		return
+	}
+	list := &transactionList{Transactions: []*listedTransaction{}, Offset: offset, Limit: limit}
+	for _, indexedTx := range indexed {
+		if uint64(len(list.Transactions)) == limit {
+			list.More = true
+			break
+		}
+		list.Transactions = append(list.Transactions, &listedTransaction{BlockNumber: indexedTx.BlockNumber, Index: indexedTx.TxIndex, Transaction: indexedTx.Transaction})
+	}
+	writeResponse(rw, req, list)
 }
 
 // GetTransactionByUUID returns a transaction matching the specified UUID
@@ -651,22 +875,131 @@ func (s *ServerOpenchainREST) GetTransactionByUUID(rw web.ResponseWriter, req *w
 	if err != nil {
 		switch err {
 		case ErrNotFound:
-			rw.WriteHeader(http.StatusNotFound)
-			fmt.Fprintf(rw, "{\"Error\": \"Transaction %s is not found.\"}", txUUID)
+			writeError(rw, http.StatusNotFound, "Transaction %s is not found.", txUUID)
 		default:
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Error retrieving transaction %s: %s.\"}", txUUID, err)
+			writeError(rw, http.StatusInternalServerError, "Error retrieving transaction %s: %s.", txUUID, err)
 			restLogger.Errorf("{\"Error\": \"Error retrieving transaction %s: %s.\"}", txUUID, err)
 		}
 	} else {
 		// Return existing transaction
-		rw.WriteHeader(http.StatusOK)
-		encoder := json.NewEncoder(rw)
-		encoder.Encode(tx)
+		writeResponse(rw, req, tx)
 		restLogger.Infof("Successfully retrieved transaction: %s", txUUID)
 	}
 }
 
+// GetTxReadWriteSet returns the keys read and the changes made by the transaction matching the specified UUID
+func (s *ServerOpenchainREST) GetTxReadWriteSet(rw web.ResponseWriter, req *web.Request) {
+	// Parse out the transaction UUID
+	txUUID := req.PathParams["uuid"]
+
+	// Retrieve the read/write set of the transaction matching the UUID
+	rwSet, err := s.server.GetTxReadWriteSet(context.Background(), txUUID)
+
+	// Check for Error
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			writeError(rw, http.StatusNotFound, "Read/write set of transaction %s is not found.", txUUID)
+		default:
+			writeError(rw, http.StatusInternalServerError, "Error retrieving read/write set of transaction %s: %s.", txUUID, err)
+			restLogger.Errorf("{\"Error\": \"Error retrieving read/write set of transaction %s: %s.\"}", txUUID, err)
+		}
+	} else {
+		// Return the read/write set
+		writeResponse(rw, req, rwSet)
+		restLogger.Infof("Successfully retrieved read/write set of transaction: %s", txUUID)
+	}
+}
+
+// GetTransactionStatus returns whether a transaction submitted through the
+// target peer is still pending, was committed successfully or failed, or was
+// rejected before being committed, with the error and the phase and reason of
+// the failure.
+func (s *ServerOpenchainREST) GetTransactionStatus(rw web.ResponseWriter, req *web.Request) {
+	// Parse out the transaction UUID
+	txUUID := req.PathParams["uuid"]
+
+	result, err := s.server.GetTransactionStatus(context.Background(), txUUID)
+
+	// Check for Error
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			writeError(rw, http.StatusNotFound, "Transaction %s is not known to this peer.", txUUID)
+		default:
+			writeError(rw, http.StatusInternalServerError, "Error retrieving status of transaction %s: %s.", txUUID, err)
+			restLogger.Errorf("{\"Error\": \"Error retrieving status of transaction %s: %s.\"}", txUUID, err)
+		}
+		return
+	}
+
+	status := &transactionStatus{
+		UUID:      result.UUID,
+		Status:    result.Status.String(),
+		ErrorCode: result.ErrorCode,
+		Error:     result.Error,
+		Updated:   result.Updated,
+	}
+	if result.Status == ledger.TxCommitted || result.Status == ledger.TxFailed {
+		blockNumber := result.BlockNumber
+		status.BlockNumber = &blockNumber
+	}
+	if result.Phase != pb.RejectionPhase_UNDEFINED_PHASE {
+		status.Phase = result.Phase.String()
+	}
+	if result.Reason != pb.RejectionReason_UNDEFINED_REASON {
+		status.Reason = result.Reason.String()
+	}
+	writeResponse(rw, req, status)
+	restLogger.Infof("Successfully retrieved status of transaction: %s", txUUID)
+}
+
+// GetChaincodeMetrics returns the usage of the chaincodes executed by the
+// target peer: the invocations, their execution time, keys read and written,
+// bytes exchanged with the chaincode and the chaincodes it called.
+func (s *ServerOpenchainREST) GetChaincodeMetrics(rw web.ResponseWriter, req *web.Request) {
+	metrics, err := s.server.GetChaincodeMetrics(context.Background())
+
+	// Check for Error
+	if err != nil {
+		writeError(rw, errorStatus(err), "%s", err)
+		restLogger.Errorf("{\"Error\": \"Retrieving chaincode metrics -- %s\"}", err)
+	} else {
+		writeResponse(rw, req, metrics)
+		restLogger.Info("Successfully retrieved chaincode metrics")
+	}
+}
+
+// GetConsensusMetrics returns the metrics of the consensus plugin of the
+// target peer, such as its current timeouts.
+func (s *ServerOpenchainREST) GetConsensusMetrics(rw web.ResponseWriter, req *web.Request) {
+	metrics, err := s.server.GetConsensusMetrics(context.Background())
+
+	// Check for Error
+	if err != nil {
+		writeError(rw, errorStatus(err), "%s", err)
+		restLogger.Errorf("{\"Error\": \"Retrieving consensus metrics -- %s\"}", err)
+	} else {
+		writeResponse(rw, req, metrics)
+		restLogger.Info("Successfully retrieved consensus metrics")
+	}
+}
+
+// GetConsensusEvidence returns the evidence of misbehavior of other validators
+// collected by the consensus plugin of the target peer.
+func (s *ServerOpenchainREST) GetConsensusEvidence(rw web.ResponseWriter, req *web.Request) {
+	evidence, err := s.server.GetConsensusEvidence(context.Background())
+
+	// Check for Error
+	if err != nil {
+		writeError(rw, errorStatus(err), "%s", err)
+		restLogger.Errorf("{\"Error\": \"Retrieving consensus evidence -- %s\"}", err)
+	} else {
+		writeResponse(rw, req, evidence)
+		restLogger.Info("Successfully retrieved consensus evidence")
+	}
+}
+
 // Deploy first builds the chaincode package and subsequently deploys it to the
 // blockchain.
 func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
@@ -688,12 +1021,10 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 
 		// Client must supply payload
 		if err == io.EOF {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeSpec.\"}")
+			writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeSpec.")
 			restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeSpec.\"}")
 		} else {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+			writeError(rw, http.StatusBadRequest, "%s", errVal)
 			restLogger.Errorf("{\"Error\": \"%s\"}", errVal)
 		}
 
@@ -702,8 +1033,7 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the ChaincodeID is not nil.
 	if spec.ChaincodeID == nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeID.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeID.")
 		restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeID.\"}")
 
 		return
@@ -717,8 +1047,7 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 	if viper.GetString("chaincode.mode") == chaincode.DevModeUserRunsChaincode {
 		// Check that the Chaincode name is not blank.
 		if spec.ChaincodeID.Name == "" {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Chaincode name may not be blank in development mode.\"}")
+			writeError(rw, http.StatusBadRequest, "Chaincode name may not be blank in development mode.")
 			restLogger.Error("{\"Error\": \"Chaincode name may not be blank in development mode.\"}")
 
 			return
@@ -726,8 +1055,7 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 	} else {
 		// Check that the Chaincode path is not left blank.
 		if spec.ChaincodeID.Path == "" {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Chaincode path may not be blank.\"}")
+			writeError(rw, http.StatusBadRequest, "Chaincode path may not be blank.")
 			restLogger.Error("{\"Error\": \"Chaincode path may not be blank.\"}")
 
 			return
@@ -736,19 +1064,25 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the CtorMsg is not left blank.
 	if (spec.CtorMsg == nil) || (spec.CtorMsg.Function == "") {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a CtorMsg with a Chaincode function name.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a CtorMsg with a Chaincode function name.")
 		restLogger.Error("{\"Error\": \"Payload must contain a CtorMsg with a Chaincode function name.\"}")
 
 		return
 	}
 
+	// The enrollment ID header selects the user signing the transaction
+	if spec.SecureContext, err = s.secureContext(req, spec.SecureContext); err != nil {
+		writeError(rw, http.StatusBadRequest, "%s", err)
+		restLogger.Errorf("{\"Error\": \"%s\"}", err)
+
+		return
+	}
+
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
 		chaincodeUsr := spec.SecureContext
 		if chaincodeUsr == "" {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
+			writeError(rw, http.StatusBadRequest, "Must supply username for chaincode when security is enabled.")
 			restLogger.Error("{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
 
 			return
@@ -765,8 +1099,7 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 			// Read in the login token
 			token, err := ioutil.ReadFile(localStore + "loginToken_" + chaincodeUsr)
 			if err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+				writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 				panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
 			}
 
@@ -780,27 +1113,24 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 		} else {
 			// Check if the token is not there and fail
 			if os.IsNotExist(err) {
-				rw.WriteHeader(http.StatusUnauthorized)
-				fmt.Fprintf(rw, "{\"Error\": \"User not logged in. Use the '/registrar' endpoint to obtain a security token.\"}")
+				writeError(rw, http.StatusUnauthorized, "User not logged in. Use the '/registrar' endpoint to obtain a security token.")
 				restLogger.Error("{\"Error\": \"User not logged in. Use the '/registrar' endpoint to obtain a security token.\"}")
 
 				return
 			}
 			// Unexpected error
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 			panic(fmt.Errorf("Fatal error when checking for client login token: %s\n", err))
 		}
 	}
 
 	// Deploy the ChaincodeSpec
-	chaincodeDeploymentSpec, err := s.devops.Deploy(context.Background(), &spec)
+	chaincodeDeploymentSpec, err := s.devops.Deploy(s.ctx, &spec)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+		writeError(rw, http.StatusBadRequest, "%s", errVal)
 		restLogger.Errorf("{\"Error\": \"Deploying Chaincode -- %s\"}", errVal)
 
 		return
@@ -834,12 +1164,10 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 		// Client must supply payload
 		if err == io.EOF {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeInvocationSpec.\"}")
+			writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeInvocationSpec.")
 			restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeInvocationSpec.\"}")
 		} else {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+			writeError(rw, http.StatusBadRequest, "%s", errVal)
 			restLogger.Errorf("{\"Error\": \"%s\"}", errVal)
 		}
 
@@ -848,8 +1176,7 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the ChaincodeSpec is not left blank.
 	if spec.ChaincodeSpec == nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeSpec.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeSpec.")
 		restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeSpec.\"}")
 
 		return
@@ -857,8 +1184,7 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the ChaincodeID is not left blank.
 	if spec.ChaincodeSpec.ChaincodeID == nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeID.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeID.")
 		restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeID.\"}")
 
 		return
@@ -866,8 +1192,7 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the Chaincode name is not blank.
 	if spec.ChaincodeSpec.ChaincodeID.Name == "" {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Chaincode name may not be blank.\"}")
+		writeError(rw, http.StatusBadRequest, "Chaincode name may not be blank.")
 		restLogger.Error("{\"Error\": \"Chaincode name may not be blank.\"}")
 
 		return
@@ -875,19 +1200,25 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the CtorMsg is not left blank.
 	if (spec.ChaincodeSpec.CtorMsg == nil) || (spec.ChaincodeSpec.CtorMsg.Function == "") {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a CtorMsg with a Chaincode function name.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a CtorMsg with a Chaincode function name.")
 		restLogger.Error("{\"Error\": \"Payload must contain a CtorMsg with a Chaincode function name.\"}")
 
 		return
 	}
 
+	// The enrollment ID header selects the user signing the transaction
+	if spec.ChaincodeSpec.SecureContext, err = s.secureContext(req, spec.ChaincodeSpec.SecureContext); err != nil {
+		writeError(rw, http.StatusBadRequest, "%s", err)
+		restLogger.Errorf("{\"Error\": \"%s\"}", err)
+
+		return
+	}
+
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
 		chaincodeUsr := spec.ChaincodeSpec.SecureContext
 		if chaincodeUsr == "" {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
+			writeError(rw, http.StatusBadRequest, "Must supply username for chaincode when security is enabled.")
 			restLogger.Error("{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
 
 			return
@@ -904,8 +1235,7 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 			// Read in the login token
 			token, err := ioutil.ReadFile(localStore + "loginToken_" + chaincodeUsr)
 			if err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+				writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 				panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
 			}
 
@@ -919,27 +1249,24 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 		} else {
 			// Check if the token is not there and fail
 			if os.IsNotExist(err) {
-				rw.WriteHeader(http.StatusUnauthorized)
-				fmt.Fprintf(rw, "{\"Error\": \"User not logged in. Use the '/registrar' endpoint to obtain a security token.\"}")
+				writeError(rw, http.StatusUnauthorized, "User not logged in. Use the '/registrar' endpoint to obtain a security token.")
 				restLogger.Error("{\"Error\": \"User not logged in. Use the '/registrar' endpoint to obtain a security token.\"}")
 
 				return
 			}
 			// Unexpected error
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 			panic(fmt.Errorf("Fatal error when checking for client login token: %s\n", err))
 		}
 	}
 
 	// Invoke the chainCode
-	resp, err := s.devops.Invoke(context.Background(), &spec)
+	resp, err := s.devops.Invoke(s.ctx, &spec)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+		writeError(rw, http.StatusBadRequest, "%s", errVal)
 		restLogger.Errorf("{\"Error\": \"Invoking Chaincode -- %s\"}", errVal)
 
 		return
@@ -974,12 +1301,10 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 
 		// Client must supply payload
 		if err == io.EOF {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeInvocationSpec.\"}")
+			writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeInvocationSpec.")
 			restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeInvocationSpec.\"}")
 		} else {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+			writeError(rw, http.StatusBadRequest, "%s", errVal)
 			restLogger.Errorf("{\"Error\": \"%s\"}", errVal)
 		}
 
@@ -988,8 +1313,7 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the ChaincodeSpec is not left blank.
 	if spec.ChaincodeSpec == nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeSpec.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeSpec.")
 		restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeSpec.\"}")
 
 		return
@@ -997,8 +1321,7 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the ChaincodeID is not left blank.
 	if spec.ChaincodeSpec.ChaincodeID == nil {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a ChaincodeID.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a ChaincodeID.")
 		restLogger.Error("{\"Error\": \"Payload must contain a ChaincodeID.\"}")
 
 		return
@@ -1006,8 +1329,7 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the Chaincode name is not blank.
 	if spec.ChaincodeSpec.ChaincodeID.Name == "" {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Chaincode name may not be blank.\"}")
+		writeError(rw, http.StatusBadRequest, "Chaincode name may not be blank.")
 		restLogger.Error("{\"Error\": \"Chaincode name may not be blank.\"}")
 
 		return
@@ -1015,19 +1337,25 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 
 	// Check that the CtorMsg is not left blank.
 	if (spec.ChaincodeSpec.CtorMsg == nil) || (spec.ChaincodeSpec.CtorMsg.Function == "") {
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain a CtorMsg with a Chaincode function name.\"}")
+		writeError(rw, http.StatusBadRequest, "Payload must contain a CtorMsg with a Chaincode function name.")
 		restLogger.Error("{\"Error\": \"Payload must contain a CtorMsg with a Chaincode function name.\"}")
 
 		return
 	}
 
+	// The enrollment ID header selects the user signing the transaction
+	if spec.ChaincodeSpec.SecureContext, err = s.secureContext(req, spec.ChaincodeSpec.SecureContext); err != nil {
+		writeError(rw, http.StatusBadRequest, "%s", err)
+		restLogger.Errorf("{\"Error\": \"%s\"}", err)
+
+		return
+	}
+
 	// If security is enabled, add client login token
 	if core.SecurityEnabled() {
 		chaincodeUsr := spec.ChaincodeSpec.SecureContext
 		if chaincodeUsr == "" {
-			rw.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(rw, "{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
+			writeError(rw, http.StatusBadRequest, "Must supply username for chaincode when security is enabled.")
 			restLogger.Error("{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
 
 			return
@@ -1044,8 +1372,7 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 			// Read in the login token
 			token, err := ioutil.ReadFile(localStore + "loginToken_" + chaincodeUsr)
 			if err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+				writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 				panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
 			}
 
@@ -1059,27 +1386,24 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 		} else {
 			// Check if the token is not there and fail
 			if os.IsNotExist(err) {
-				rw.WriteHeader(http.StatusUnauthorized)
-				fmt.Fprintf(rw, "{\"Error\": \"User not logged in. Use the '/registrar' endpoint to obtain a security token.\"}")
+				writeError(rw, http.StatusUnauthorized, "User not logged in. Use the '/registrar' endpoint to obtain a security token.")
 				restLogger.Error("{\"Error\": \"User not logged in. Use the '/registrar' endpoint to obtain a security token.\"}")
 
 				return
 			}
 			// Unexpected error
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"Fatal error -- %s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "Fatal error -- %s", err)
 			panic(fmt.Errorf("Fatal error when checking for client login token: %s\n", err))
 		}
 	}
 
 	// Query the chainCode
-	resp, err := s.devops.Query(context.Background(), &spec)
+	resp, err := s.devops.Query(s.ctx, &spec)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+		writeError(rw, http.StatusBadRequest, "%s", errVal)
 		restLogger.Errorf("{\"Error\": \"Querying Chaincode -- %s\"}", errVal)
 
 		return
@@ -1094,8 +1418,7 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 		// Response is not JSON formatted, construct a JSON formatted response
 		jsonResponse, err := json.Marshal(restResult{OK: string(resp.Msg)})
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+			writeError(rw, http.StatusInternalServerError, "%s", err)
 			restLogger.Errorf("{\"Error marshalling query response\": \"%s\"}", err)
 
 			return
@@ -1208,7 +1531,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		return
 	}
 
-	// Insure that the JSON method string is present and is either deploy, invoke or query
+	// Insure that the JSON method string is present and is one of the chaincode methods
 	if requestPayload.Method == nil {
 		// If the request is not a notification, produce a response.
 		if !notification {
@@ -1224,7 +1547,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		restLogger.Error("Missing JSON RPC 2.0 method string.")
 
 		return
-	} else if (*(requestPayload.Method) != "deploy") && (*(requestPayload.Method) != "invoke") && (*(requestPayload.Method) != "query") {
+	} else if !chaincodeMethods[*(requestPayload.Method)] {
 		// If the request is not a notification, produce a response.
 		if !notification {
 			// Format the error appropriately
@@ -1248,10 +1571,29 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 	// Variable that will hold the execution result
 	var result rpcResult
 
-	if *(requestPayload.Method) == "deploy" {
+	// The enrollment ID header selects the user signing the transaction
+	if requestPayload.Params != nil {
+		enrollmentID, err := s.secureContext(req, requestPayload.Params.SecureContext)
+		if err != nil {
+			if !notification {
+				error := formatRPCError(InvalidParams.Code, InvalidParams.Message, err.Error())
+				response := formatRPCResponse(error, requestPayload.ID)
+				jsonResponse, _ := json.Marshal(response)
+
+				rw.WriteHeader(http.StatusBadRequest)
+				rw.Write(jsonResponse)
+			}
+			restLogger.Error(err)
+
+			return
+		}
+		requestPayload.Params.SecureContext = enrollmentID
+	}
+
+	if *(requestPayload.Method) == "deploy" || *(requestPayload.Method) == "upgrade" {
 
 		//
-		// Chaincode deployment was requested
+		// Chaincode deployment or upgrade was requested
 		//
 
 		// Payload params field must contain a ChaincodeSpec message
@@ -1259,7 +1601,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 			// If the request is not a notification, produce a response.
 			if !notification {
 				// Format the error appropriately
-				error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Client must supply ChaincodeSpec for chaincode deploy request.")
+				error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Client must supply ChaincodeSpec for chaincode deploy or upgrade request.")
 				// Produce correctly formatted JSON RPC 2.0 response
 				response := formatRPCResponse(error, requestPayload.ID)
 				jsonResponse, _ := json.Marshal(response)
@@ -1267,7 +1609,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 				rw.WriteHeader(http.StatusBadRequest)
 				fmt.Fprintf(rw, string(jsonResponse))
 			}
-			restLogger.Error("Client must supply ChaincodeSpec for chaincode deploy request.")
+			restLogger.Error("Client must supply ChaincodeSpec for chaincode deploy or upgrade request.")
 
 			return
 		}
@@ -1276,7 +1618,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		deploySpec := requestPayload.Params
 
 		// Process the chaincode deployment request and record the result
-		result = s.processChaincodeDeploy(deploySpec)
+		result = s.processChaincodeDeploy(*(requestPayload.Method), deploySpec)
 	} else {
 
 		//
@@ -1333,9 +1675,9 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 	return
 }
 
-// processChaincodeDeploy triggers chaincode deploy and returns a result or an error
-func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpcResult {
-	restLogger.Info("REST deploying chaincode...")
+// processChaincodeDeploy triggers chaincode deploy or upgrade and returns a result or an error
+func (s *ServerOpenchainREST) processChaincodeDeploy(method string, spec *pb.ChaincodeSpec) rpcResult {
+	restLogger.Infof("REST processing chaincode %s...", method)
 
 	// Check that the ChaincodeID is not nil.
 	if spec.ChaincodeID == nil {
@@ -1379,6 +1721,15 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 		}
 	}
 
+	// The chaincode to upgrade is identified by name
+	if method == "upgrade" && spec.ChaincodeID.Name == "" {
+		// Format the error appropriately for further processing
+		error := formatRPCError(InvalidParams.Code, InvalidParams.Message, "Name of the chaincode to upgrade may not be blank.")
+		restLogger.Error("Name of the chaincode to upgrade may not be blank.")
+
+		return error
+	}
+
 	// Check that the CtorMsg is not left blank.
 	if (spec.CtorMsg == nil) || (spec.CtorMsg.Function == "") {
 		// Format the error appropriately for further processing
@@ -1448,9 +1799,13 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 	}
 
 	//
-	// Trigger the chaincode deployment through the devops service
+	// Trigger the chaincode deployment or upgrade through the devops service
 	//
-	chaincodeDeploymentSpec, err := s.devops.Deploy(context.Background(), spec)
+	deploy, deployError := s.devops.Deploy, ChaincodeDeployError
+	if method == "upgrade" {
+		deploy, deployError = s.devops.Upgrade, ChaincodeUpgradeError
+	}
+	chaincodeDeploymentSpec, err := deploy(s.ctx, spec)
 
 	//
 	// Deployment failed
@@ -1461,8 +1816,8 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
 		// Format the error appropriately for further processing
-		error := formatRPCError(ChaincodeDeployError.Code, ChaincodeDeployError.Message, fmt.Sprintf("Error when deploying chaincode: %s", errVal))
-		restLogger.Errorf("Error when deploying chaincode: %s", errVal)
+		error := formatRPCError(deployError.Code, deployError.Message, fmt.Sprintf("Error when processing chaincode %s: %s", method, errVal))
+		restLogger.Errorf("Error when processing chaincode %s: %s", method, errVal)
 
 		return error
 	}
@@ -1586,7 +1941,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		// Trigger the chaincode invoke through the devops service
 		//
 
-		resp, err := s.devops.Invoke(context.Background(), spec)
+		resp, err := s.devops.Invoke(s.ctx, spec)
 
 		//
 		// Invocation failed
@@ -1619,13 +1974,18 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		restLogger.Infof("Successfully submitted invoke transaction with txuuid (%s)", txuuid)
 	}
 
-	if method == "query" {
+	if method == "query" || method == "queryQuorum" {
 
 		//
-		// Trigger the chaincode query through the devops service
+		// Trigger the chaincode query through the devops service, on f+1
+		// validating peers agreeing on the result for a quorum query
 		//
 
-		resp, err := s.devops.Query(context.Background(), spec)
+		query := s.devops.Query
+		if method == "queryQuorum" {
+			query = s.devops.QueryQuorum
+		}
+		resp, err := query(s.ctx, spec)
 
 		//
 		// Query failed
@@ -1660,23 +2020,130 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 	return result
 }
 
+// batchResult is the result of an invocation of a /chaincode/batch request,
+// the UUID of its transaction or the reason it failed
+type batchResult struct {
+	OK      string `json:"OK,omitempty"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"Error,omitempty"`
+}
+
+// loginToken returns the login token of the user logged in through
+// /registrar, or the HTTP status and error if the user is not.
+func loginToken(enrollmentID string) (string, int, error) {
+	token, err := ioutil.ReadFile(getRESTFilePath() + "loginToken_" + enrollmentID)
+	if os.IsNotExist(err) {
+		return "", http.StatusUnauthorized, fmt.Errorf("User %s not logged in. Use the '/registrar' endpoint to obtain a security token.", enrollmentID)
+	} else if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Error reading the login token of %s: %s", enrollmentID, err)
+	}
+	return string(token), http.StatusOK, nil
+}
+
+// InvokeBatch invokes chaincodes with each of the array of
+// ChaincodeInvocationSpecs of the payload, sending their transactions to
+// consensus together. The invocations are all validated before any is sent,
+// and the response is the array of their results, in order.
+func (s *ServerOpenchainREST) InvokeBatch(rw web.ResponseWriter, req *web.Request) {
+	restLogger.Info("REST invoking a batch of chaincode invocations...")
+
+	var raw []json.RawMessage
+	if err := json.NewDecoder(req.Body).Decode(&raw); err != nil {
+		if err == io.EOF {
+			writeError(rw, http.StatusBadRequest, "Payload must contain an array of ChaincodeInvocationSpecs.")
+			restLogger.Error("{\"Error\": \"Payload must contain an array of ChaincodeInvocationSpecs.\"}")
+		} else {
+			errVal := strings.Replace(err.Error(), "\"", "'", -1)
+			writeError(rw, http.StatusBadRequest, "%s", errVal)
+			restLogger.Errorf("{\"Error\": \"%s\"}", errVal)
+		}
+		return
+	}
+
+	batch := &pb.ChaincodeInvocationBatch{}
+	for i, r := range raw {
+		field := fmt.Sprintf("[%d]", i)
+		spec := &pb.ChaincodeInvocationSpec{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(r), spec); err != nil {
+			errVal := strings.Replace(err.Error(), "\"", "'", -1)
+			writeFieldError(rw, http.StatusBadRequest, field, "%s", errVal)
+			restLogger.Errorf("{\"Error\": \"Invocation %d: %s\"}", i, errVal)
+			return
+		}
+		if spec.ChaincodeSpec == nil || spec.ChaincodeSpec.ChaincodeID == nil || spec.ChaincodeSpec.ChaincodeID.Name == "" {
+			writeFieldError(rw, http.StatusBadRequest, field+".chaincodeSpec.chaincodeID.name", "Invocation %d must name a chaincode.", i)
+			restLogger.Errorf("{\"Error\": \"Invocation %d must name a chaincode.\"}", i)
+			return
+		}
+		if spec.ChaincodeSpec.CtorMsg == nil || spec.ChaincodeSpec.CtorMsg.Function == "" {
+			writeFieldError(rw, http.StatusBadRequest, field+".chaincodeSpec.ctorMsg.function", "Invocation %d must contain a CtorMsg with a Chaincode function name.", i)
+			restLogger.Errorf("{\"Error\": \"Invocation %d must contain a CtorMsg with a Chaincode function name.\"}", i)
+			return
+		}
+
+		// The enrollment ID header selects the user signing the transaction
+		enrollmentID, err := s.secureContext(req, spec.ChaincodeSpec.SecureContext)
+		if err != nil {
+			writeFieldError(rw, http.StatusBadRequest, field+".chaincodeSpec.secureContext", "%s", err)
+			restLogger.Errorf("{\"Error\": \"Invocation %d: %s\"}", i, err)
+			return
+		}
+		spec.ChaincodeSpec.SecureContext = enrollmentID
+		if core.SecurityEnabled() {
+			if enrollmentID == "" {
+				writeFieldError(rw, http.StatusBadRequest, field+".chaincodeSpec.secureContext", "Must supply username for chaincode when security is enabled.")
+				restLogger.Error("{\"Error\": \"Must supply username for chaincode when security is enabled.\"}")
+				return
+			}
+			token, status, err := loginToken(enrollmentID)
+			if err != nil {
+				writeError(rw, status, "%s", err)
+				restLogger.Errorf("{\"Error\": \"%s\"}", err)
+				return
+			}
+			spec.ChaincodeSpec.SecureContext = token
+			if viper.GetBool("security.privacy") {
+				spec.ChaincodeSpec.ConfidentialityLevel = pb.ConfidentialityLevel_CONFIDENTIAL
+			}
+		}
+		batch.Invocations = append(batch.Invocations, spec)
+	}
+
+	resp, err := s.devops.InvokeBatch(s.ctx, batch)
+	if err != nil {
+		errVal := strings.Replace(err.Error(), "\"", "'", -1)
+		writeError(rw, http.StatusBadRequest, "%s", errVal)
+		restLogger.Errorf("{\"Error\": \"Invoking the batch -- %s\"}", errVal)
+		return
+	}
+
+	results := make([]batchResult, len(resp.Responses))
+	for i, r := range resp.Responses {
+		if r.Status == pb.Response_FAILURE {
+			results[i].Error = string(r.Msg)
+		} else {
+			results[i].OK = "Successfully submitted invoke transaction."
+			results[i].Message = string(r.Msg)
+		}
+	}
+	rw.WriteHeader(http.StatusOK)
+	json.NewEncoder(rw).Encode(results)
+	restLogger.Infof("Successfully submitted a batch of %d invoke transactions.", len(results))
+}
+
 // GetPeers returns a list of all peer nodes currently connected to the target peer, including itself
 func (s *ServerOpenchainREST) GetPeers(rw web.ResponseWriter, req *web.Request) {
 	peers, err := s.server.GetPeers(context.Background(), &google_protobuf.Empty{})
 	currentPeer, err1 := s.server.GetPeerEndpoint(context.Background(), &google_protobuf.Empty{})
 
-	encoder := json.NewEncoder(rw)
-
 	// Check for error
 	if err != nil {
 		// Failure
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		writeError(rw, errorStatus(err), "%s", err)
 		restLogger.Errorf("{\"Error\": \"Querying network peers -- %s\"}", err)
 	} else if err1 != nil {
 		// Failure
-		rw.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err1)
+		writeError(rw, errorStatus(err1), "%s", err1)
 		restLogger.Errorf("{\"Error\": \"Accesing target peer endpoint data  -- %s\"}", err1)
 	} else {
 		currentPeerFound := false
@@ -1691,34 +2158,75 @@ func (s *ServerOpenchainREST) GetPeers(rw web.ResponseWriter, req *web.Request)
 		if currentPeerFound == false {
 			peersList = append(peersList, currentPeer.Peers...)
 		}
+		// Peers which know the topology of the network report it along with
+		// the peers
+		if topology, err := s.server.GetNetworkTopology(context.Background()); err == nil {
+			writeResponse(rw, req, &pb.NetworkTopology{Peers: peersList, Topology: topology.Topology})
+			return
+		}
 		peersMessage := &pb.PeersMessage{Peers: peersList}
 		// Success
-		rw.WriteHeader(http.StatusOK)
-		encoder.Encode(peersMessage)
+		writeResponse(rw, req, peersMessage)
+	}
+}
+
+// GetPeersHealth returns the liveness of the peers chatting with the target
+// peer, as probed by pinging them.
+func (s *ServerOpenchainREST) GetPeersHealth(rw web.ResponseWriter, req *web.Request) {
+	health, err := s.server.GetPeersHealth(context.Background())
+
+	// Check for Error
+	if err != nil {
+		writeError(rw, errorStatus(err), "%s", err)
+		restLogger.Errorf("{\"Error\": \"Retrieving peers health -- %s\"}", err)
+	} else {
+		writeResponse(rw, req, health)
+		restLogger.Info("Successfully retrieved peers health")
 	}
 }
 
 // NotFound returns a custom landing page when a given hyperledger end point
 // had not been defined.
 func (s *ServerOpenchainREST) NotFound(rw web.ResponseWriter, r *web.Request) {
-	rw.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(rw, "{\"Error\": \"Openchain endpoint not found.\"}")
+	writeError(rw, http.StatusNotFound, "Openchain endpoint not found.")
 }
 
 // StartOpenchainRESTServer initializes the REST service and adds the required
 // middleware and routes.
-func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops) {
+func StartOpenchainRESTServer(server *ServerOpenchain, devops pb.DevopsServer) {
 	// Initialize the REST service object
-	restLogger.Infof("Initializing the REST service on %s, TLS is %s.", viper.GetString("rest.address"), (map[bool]string{true: "enabled", false: "disabled"})[comm.TLSEnabled()])
+	restLogger.Infof("Initializing the REST service on %s, TLS is %s.", viper.GetString("rest.address"), (map[bool]string{true: "enabled", false: "disabled"})[restTLS()])
 	router := web.New(ServerOpenchainREST{})
 
 	// Record the pointer to the underlying ServerOpenchain and Devops objects.
 	serverOpenchain = server
 	serverDevops = devops
 
+	// Load the Swagger definitions the requests are validated against
+	if viper.GetBool("rest.validation.enabled") {
+		spec, err := loadSwaggerSpec(swaggerPath())
+		if err != nil {
+			restLogger.Warningf("Not validating REST requests, error loading the Swagger definitions: %s", err)
+		} else {
+			restSpec = spec
+		}
+	}
+
+	// Map the credentials of the clients to roles
+	if viper.GetBool("rest.auth.enabled") {
+		auth, err := newAuthenticator()
+		if err != nil {
+			restLogger.Errorf("Invalid rest.auth configuration: %s", err)
+			return
+		}
+		restAuth = auth
+	}
+
 	// Add middleware
 	router.Middleware((*ServerOpenchainREST).SetOpenchainServer)
 	router.Middleware((*ServerOpenchainREST).SetResponseType)
+	router.Middleware((*ServerOpenchainREST).Authorize)
+	router.Middleware((*ServerOpenchainREST).ValidateRequest)
 
 	// Add routes
 	router.Post("/registrar", (*ServerOpenchainREST).Register)
@@ -1729,6 +2237,7 @@ func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops) {
 
 	router.Get("/chain", (*ServerOpenchainREST).GetBlockchainInfo)
 	router.Get("/chain/blocks/:id", (*ServerOpenchainREST).GetBlockByNumber)
+	router.Get("/chain/stream", (*ServerOpenchainREST).StreamBlocks)
 
 	// The /devops endpoint is now considered deprecated and superseded by the /chaincode endpoint
 	router.Post("/devops/deploy", (*ServerOpenchainREST).Deploy)
@@ -1737,22 +2246,47 @@ func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops) {
 
 	// The /chaincode endpoint which superceedes the /devops endpoint from above
 	router.Post("/chaincode", (*ServerOpenchainREST).ProcessChaincode)
+	router.Post("/chaincode/batch", (*ServerOpenchainREST).InvokeBatch)
+	router.Get("/chaincode/metrics", (*ServerOpenchainREST).GetChaincodeMetrics)
 
+	router.Get("/transactions", (*ServerOpenchainREST).ListTransactions)
+	router.Post("/transactions", (*ServerOpenchainREST).SubmitTransaction)
 	router.Get("/transactions/:uuid", (*ServerOpenchainREST).GetTransactionByUUID)
+	router.Get("/transactions/:uuid/block", (*ServerOpenchainREST).GetBlockByTxID)
+	router.Get("/transactions/:uuid/readwriteset", (*ServerOpenchainREST).GetTxReadWriteSet)
+	router.Get("/transactions/:uuid/status", (*ServerOpenchainREST).GetTransactionStatus)
 
 	router.Get("/network/peers", (*ServerOpenchainREST).GetPeers)
+	router.Get("/network/peers/health", (*ServerOpenchainREST).GetPeersHealth)
+	router.Get("/network/consensus/metrics", (*ServerOpenchainREST).GetConsensusMetrics)
+	router.Get("/network/consensus/evidence", (*ServerOpenchainREST).GetConsensusEvidence)
 
 	// Add not found page
 	router.NotFound((*ServerOpenchainREST).NotFound)
 
+	// Answer the CORS preflight requests of the allowed origins
+	restCORS = newCORSPolicy()
+	router.OptionsHandler((*ServerOpenchainREST).Preflight)
+
 	// Start server
-	if comm.TLSEnabled() {
-		err := http.ListenAndServeTLS(viper.GetString("rest.address"), viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"), router)
+	address, err := comm.NormalizeAddress(viper.GetString("rest.address"))
+	if err != nil {
+		restLogger.Errorf("Invalid rest.address: %s", err)
+		return
+	}
+	if restTLS() {
+		tlsConfig, err := restTLSConfig()
 		if err != nil {
 			restLogger.Errorf("ListenAndServeTLS: %s", err)
+			return
+		}
+		restHSTS = hstsHeader()
+		server := &http.Server{Addr: address, Handler: router, TLSConfig: tlsConfig}
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			restLogger.Errorf("ListenAndServeTLS: %s", err)
 		}
 	} else {
-		err := http.ListenAndServe(viper.GetString("rest.address"), router)
+		err := http.ListenAndServe(address, router)
 		if err != nil {
 			restLogger.Errorf("ListenAndServe: %s", err)
 		}
@@ -16,7 +16,166 @@ limitations under the License.
 
 package rest
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/proto"
+)
+
+// Media types of the responses of the REST API
+const (
+	mediaTypeJSON     = "application/json"
+	mediaTypeProtobuf = "application/x-protobuf"
+)
+
+// Codes of the errors returned by the REST API
+const (
+	errorCodeInvalidRequest = "INVALID_REQUEST"
+	errorCodeUnauthorized   = "UNAUTHORIZED"
+	errorCodeForbidden      = "FORBIDDEN"
+	errorCodeNotFound       = "NOT_FOUND"
+	errorCodeNotAcceptable  = "NOT_ACCEPTABLE"
+	errorCodeNotSupported   = "NOT_SUPPORTED"
+	errorCodeInternal       = "INTERNAL_ERROR"
+)
+
+// restError defines the response payload of a failed REST request. Field is
+// the request parameter or payload field at fault, if any. Error repeats the
+// message for the clients of the former {"Error": ...} payloads.
+type restError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	Error   string `json:"Error"`
+}
+
+// notSupportedError is returned if the target peer does not provide the
+// requested resource, e.g. the metrics of chaincodes it does not execute.
+type notSupportedError string
+
+func (e notSupportedError) Error() string {
+	return string(e)
+}
+
+// errorStatus returns the HTTP status of a request which failed with err
+func errorStatus(err error) int {
+	switch err.(type) {
+	case notSupportedError:
+		return http.StatusNotImplemented
+	}
+	if err == ErrNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// errorCode returns the error code of the responses with HTTP status status
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		return errorCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return errorCodeUnauthorized
+	case http.StatusForbidden:
+		return errorCodeForbidden
+	case http.StatusNotFound:
+		return errorCodeNotFound
+	case http.StatusNotAcceptable:
+		return errorCodeNotAcceptable
+	case http.StatusNotImplemented:
+		return errorCodeNotSupported
+	default:
+		return errorCodeInternal
+	}
+}
+
+// writeError writes an error response with HTTP status status and the
+// formatted message.
+func writeError(rw web.ResponseWriter, status int, format string, a ...interface{}) {
+	writeFieldError(rw, status, "", format, a...)
+}
+
+// writeFieldError writes an error response with HTTP status status and the
+// formatted message, blaming field of the request.
+func writeFieldError(rw web.ResponseWriter, status int, field string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	rw.Header().Set("Content-Type", mediaTypeJSON)
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(&restError{Code: errorCode(status), Message: message, Field: field, Error: message})
+}
+
+// acceptedMediaType returns the media type of the response to req, protobuf
+// if the Accept header prefers it and the payload is a protobuf message, or
+// else JSON. It returns false if the client accepts neither.
+func acceptedMediaType(req *web.Request, protobuf bool) (string, bool) {
+	accept := req.Header.Get("Accept")
+	if accept == "" {
+		return mediaTypeJSON, true
+	}
+	mediaType, quality := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		var candidate string
+		switch accepted {
+		case mediaTypeProtobuf, "application/protobuf":
+			if !protobuf {
+				continue
+			}
+			candidate = mediaTypeProtobuf
+		case mediaTypeJSON, "application/*", "*/*":
+			candidate = mediaTypeJSON
+		default:
+			continue
+		}
+		// on a tie protobuf wins, as clients only ask for it on purpose
+		if q > quality || (q == quality && candidate == mediaTypeProtobuf) {
+			mediaType, quality = candidate, q
+		}
+	}
+	return mediaType, quality > 0
+}
+
+// writeResponse writes a successful response with payload v, marshalled to
+// the media type negotiated with the client: protobuf messages are written in
+// their binary encoding to the clients preferring application/x-protobuf, and
+// as JSON to the others.
+func writeResponse(rw web.ResponseWriter, req *web.Request, v interface{}) {
+	msg, isMessage := v.(proto.Message)
+	mediaType, ok := acceptedMediaType(req, isMessage)
+	if !ok {
+		writeError(rw, http.StatusNotAcceptable, "Response is available as %s or %s only.", mediaTypeJSON, mediaTypeProtobuf)
+		return
+	}
+	if mediaType == mediaTypeProtobuf {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			writeError(rw, http.StatusInternalServerError, "Error marshalling response: %s", err)
+			return
+		}
+		rw.Header().Set("Content-Type", mediaTypeProtobuf)
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(data)
+		return
+	}
+	rw.Header().Set("Content-Type", mediaTypeJSON)
+	rw.WriteHeader(http.StatusOK)
+	json.NewEncoder(rw).Encode(v)
+}
 
 // isJSON is a helper function to determine if a given string is proper JSON.
 func isJSON(s string) bool {
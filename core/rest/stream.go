@@ -0,0 +1,167 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"google/protobuf"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// streamKeepAlive is the period of the comments sent on an idle stream, so
+// that proxies do not close it
+const streamKeepAlive = 15 * time.Second
+
+// streamedBlock is the summary of a block streamed by /chain/stream: its
+// header, and the outcome of its transactions
+type streamedBlock struct {
+	Number            uint64                     `json:"number"`
+	Hash              []byte                     `json:"hash"`
+	PreviousBlockHash []byte                     `json:"previousBlockHash,omitempty"`
+	StateHash         []byte                     `json:"stateHash"`
+	Timestamp         *google_protobuf.Timestamp `json:"timestamp,omitempty"`
+	Transactions      []streamedTransaction      `json:"transactions"`
+}
+
+// streamedTransaction is the summary of a committed transaction, whose
+// errorCode is 0 if it succeeded
+type streamedTransaction struct {
+	UUID        string `json:"uuid"`
+	Type        string `json:"type"`
+	ChaincodeID string `json:"chaincodeID,omitempty"`
+	ErrorCode   uint32 `json:"errorCode,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// summarizeBlock returns the summary of block number
+func summarizeBlock(number uint64, block *pb.Block) (*streamedBlock, error) {
+	hash, err := block.GetHash()
+	if err != nil {
+		return nil, fmt.Errorf("Error hashing block %d: %s", number, err)
+	}
+	summary := &streamedBlock{
+		Number:            number,
+		Hash:              hash,
+		PreviousBlockHash: block.PreviousBlockHash,
+		StateHash:         block.StateHash,
+		Timestamp:         block.Timestamp,
+		Transactions:      []streamedTransaction{},
+	}
+	results := make(map[string]*pb.TransactionResult)
+	for _, result := range block.GetNonHashData().GetTransactionResults() {
+		results[result.Uuid] = result
+	}
+	for _, tx := range block.Transactions {
+		streamed := streamedTransaction{UUID: tx.Uuid, Type: tx.Type.String()}
+		chaincodeID := &pb.ChaincodeID{}
+		if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err == nil {
+			streamed.ChaincodeID = chaincodeID.Name
+		}
+		if result, ok := results[tx.Uuid]; ok {
+			streamed.ErrorCode = result.ErrorCode
+			streamed.Error = result.Error
+		}
+		summary.Transactions = append(summary.Transactions, streamed)
+	}
+	return summary, nil
+}
+
+// streamBlocks writes the summary of every block from block from onwards to
+// w as server-sent events, whose ID is the block number, waiting for the
+// blocks to be added to the blockchain, until stop is signalled or writing
+// fails
+func streamBlocks(w io.Writer, flush func(), l *ledger.Ledger, from uint64, stop <-chan bool) error {
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	next := from
+	for {
+		// get the channel before the height, not to miss a block added in between
+		added := l.BlockAdded()
+		for ; next < l.GetBlockchainSize(); next++ {
+			block, err := l.GetBlockByNumber(next)
+			if err != nil {
+				return fmt.Errorf("Error getting block %d: %s", next, err)
+			}
+			summary, err := summarizeBlock(next, block)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(summary)
+			if err != nil {
+				return fmt.Errorf("Error marshalling block %d: %s", next, err)
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: block\ndata: %s\n\n", next, data); err != nil {
+				return err
+			}
+		}
+		flush()
+
+		select {
+		case <-added:
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+			flush()
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// StreamBlocks streams the summary of the blocks as they are added to the
+// blockchain, as server-sent events. The stream starts with the block of the
+// from query parameter, or after the block of the Last-Event-ID header of a
+// client resuming an interrupted stream, or else with the next block.
+func (s *ServerOpenchainREST) StreamBlocks(rw web.ResponseWriter, req *web.Request) {
+	from := s.server.ledger.GetBlockchainSize()
+	if lastID := req.Header.Get("Last-Event-ID"); lastID != "" {
+		last, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			writeFieldError(rw, http.StatusBadRequest, "Last-Event-ID", "Last-Event-ID must be a block number.")
+			restLogger.Errorf("{\"Error\": \"Invalid Last-Event-ID %s\"}", lastID)
+			return
+		}
+		from = last + 1
+	} else if start := req.URL.Query().Get("from"); start != "" {
+		number, err := strconv.ParseUint(start, 10, 64)
+		if err != nil {
+			writeFieldError(rw, http.StatusBadRequest, "from", "from must be a block number.")
+			restLogger.Errorf("{\"Error\": \"Invalid from %s\"}", start)
+			return
+		}
+		from = number
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	restLogger.Infof("Streaming blocks from block %d", from)
+	if err := streamBlocks(rw, rw.Flush, s.server.ledger, from, rw.CloseNotify()); err != nil {
+		restLogger.Errorf("Error streaming blocks: %s", err)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// readEvent reads the next event of a stream of server-sent events, skipping
+// comments
+func readEvent(t *testing.T, r *bufio.Reader) (id string, data string) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading the stream: %s", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && id != "":
+			return id, data
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamBlocks(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger1, t)
+
+	r, w := io.Pipe()
+	stop := make(chan bool)
+	done := make(chan error)
+	go func() {
+		done <- streamBlocks(w, func() {}, ledger1, 1, stop)
+	}()
+	reader := bufio.NewReader(r)
+
+	for number := uint64(1); number < 3; number++ {
+		id, data := readEvent(t, reader)
+		if id != fmt.Sprintf("%d", number) {
+			t.Fatalf("Expected the event of block %d, got %s", number, id)
+		}
+		summary := &streamedBlock{}
+		if err := json.Unmarshal([]byte(data), summary); err != nil {
+			t.Fatalf("Error unmarshalling the summary of block %d: %s", number, err)
+		}
+		block, _ := ledger1.GetBlockByNumber(number)
+		if summary.Number != number || len(summary.Transactions) != len(block.Transactions) {
+			t.Fatalf("Expected the summary of block %d with %d transactions, got %s", number, len(block.Transactions), data)
+		}
+		if summary.Transactions[0].UUID != block.Transactions[0].Uuid {
+			t.Fatalf("Expected transaction %s first in block %d, got %s", block.Transactions[0].Uuid, number, summary.Transactions[0].UUID)
+		}
+	}
+
+	// a block committed while streaming is streamed
+	tx, err := protos.NewTransaction(protos.ChaincodeID{Path: "MyContract"}, generateUUID(t), "setX", []string{"{x: \"streamed\"}"})
+	if err != nil {
+		t.Fatalf("Error creating NewTransaction: %s", err)
+	}
+	ledger1.BeginTxBatch(3)
+	ledger1.TxBegin(tx.Uuid)
+	ledger1.SetState("MyContract", "x", []byte("streamed"))
+	ledger1.TxFinished(tx.Uuid, true)
+	if err := ledger1.CommitTxBatch(3, []*protos.Transaction{tx}, nil, []byte("dummy-proof")); err != nil {
+		t.Fatalf("Error in commit: %s", err)
+	}
+	id, data := readEvent(t, reader)
+	if id != "3" || !strings.Contains(data, tx.Uuid) {
+		t.Fatalf("Expected the event of block 3 with transaction %s, got %s: %s", tx.Uuid, id, data)
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("Expected the stream to stop, got %s", err)
+	}
+}
@@ -0,0 +1,385 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/viper"
+)
+
+// restSpec holds the Swagger definitions the requests are validated against,
+// nil if the requests are not validated.
+var restSpec *swaggerSpec
+
+// swaggerSpec is the subset of a Swagger 2.0 document needed to validate the
+// requests: the parameters of the operations and the schemas of the payloads
+type swaggerSpec struct {
+	Paths       map[string]map[string]*swaggerOperation `json:"paths"`
+	Definitions map[string]*swaggerSchema               `json:"definitions"`
+}
+
+type swaggerOperation struct {
+	Parameters []*swaggerParameter `json:"parameters"`
+}
+
+// swaggerParameter is a parameter of an operation: a payload, whose schema
+// is Schema, or else a path, query or header parameter of a simple type
+type swaggerParameter struct {
+	swaggerSchema
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *swaggerSchema `json:"schema"`
+}
+
+type swaggerSchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Format     string                    `json:"format"`
+	Enum       []interface{}             `json:"enum"`
+	Items      *swaggerSchema            `json:"items"`
+	Properties map[string]*swaggerSchema `json:"properties"`
+	Required   []string                  `json:"required"`
+}
+
+// fieldError is an invalid parameter or payload field of a request
+type fieldError struct {
+	field   string
+	message string
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.field, e.message)
+}
+
+// swaggerPath returns the path of the Swagger definitions of the REST API,
+// by default those of the fabric sources in the GOPATH
+func swaggerPath() string {
+	if path := viper.GetString("rest.validation.swagger"); path != "" {
+		return path
+	}
+	gopath := filepath.SplitList(os.Getenv("GOPATH"))
+	if len(gopath) == 0 {
+		return ""
+	}
+	return filepath.Join(gopath[0], "src", "github.com", "hyperledger", "fabric", "core", "rest", "rest_api.json")
+}
+
+// loadSwaggerSpec reads the Swagger definitions at path
+func loadSwaggerSpec(path string) (*swaggerSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := &swaggerSpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", path, err)
+	}
+	return spec, nil
+}
+
+// operation returns the operation of the request for method on path, and
+// the values of its path parameters. Literal path segments take precedence
+// over parameters, as they do in the router.
+func (spec *swaggerSpec) operation(method string, path string) (*swaggerOperation, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var (
+		best       *swaggerOperation
+		bestParams map[string]string
+	)
+	for template, operations := range spec.Paths {
+		op, ok := operations[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+		parts := strings.Split(strings.Trim(template, "/"), "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		params := make(map[string]string)
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				params[part[1:len(part)-1]] = segments[i]
+			} else if part != segments[i] {
+				params = nil
+				break
+			}
+		}
+		if params != nil && (best == nil || len(params) < len(bestParams)) {
+			best, bestParams = op, params
+		}
+	}
+	return best, bestParams
+}
+
+// resolve follows the reference of schema to its definition
+func (spec *swaggerSpec) resolve(schema *swaggerSchema) *swaggerSchema {
+	for schema != nil && schema.Ref != "" {
+		schema = spec.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+	}
+	return schema
+}
+
+// validateRequest validates the parameters and the JSON payload of req
+// against the definition of its operation. Requests for undefined operations,
+// and payloads which are not JSON, are left to the handlers.
+func (spec *swaggerSpec) validateRequest(req *http.Request) error {
+	op, pathParams := spec.operation(req.Method, req.URL.Path)
+	if op == nil {
+		return nil
+	}
+	query := req.URL.Query()
+	for _, param := range op.Parameters {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value, present = pathParams[param.Name]
+		case "query":
+			value, present = query.Get(param.Name), query.Get(param.Name) != ""
+		case "header":
+			value, present = req.Header.Get(param.Name), req.Header.Get(param.Name) != ""
+		case "body":
+			if err := spec.validateBody(req, param); err != nil {
+				return err
+			}
+			continue
+		default:
+			continue
+		}
+		if !present {
+			if param.Required {
+				return &fieldError{param.Name, "is required"}
+			}
+			continue
+		}
+		if err := validateParameter(&param.swaggerSchema, value, param.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBody validates the JSON payload of req against the schema of
+// param, and restores the payload for the handler
+func (spec *swaggerSpec) validateBody(req *http.Request, param *swaggerParameter) error {
+	if req.Body == nil {
+		return nil
+	}
+	switch strings.Split(req.Header.Get("Content-Type"), ";")[0] {
+	case mediaTypeProtobuf, "application/octet-stream":
+		return nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil || len(bytes.TrimSpace(body)) == 0 {
+		// the handlers report the missing payloads
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &fieldError{param.Name, fmt.Sprintf("is not valid JSON: %s", err)}
+	}
+	return spec.validateValue(param.Schema, value, "")
+}
+
+// validateParameter validates the value of a path, query or header parameter
+func validateParameter(schema *swaggerSchema, value string, field string) error {
+	switch schema.Type {
+	case "integer":
+		if err := validateInteger(schema.Format, value); err != nil {
+			return &fieldError{field, err.Error()}
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return &fieldError{field, "must be a number"}
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return &fieldError{field, "must be a boolean"}
+		}
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		return &fieldError{field, fmt.Sprintf("must be one of %v", schema.Enum)}
+	}
+	return nil
+}
+
+// validateInteger validates an integer of format, which defaults to int64
+func validateInteger(format string, value string) error {
+	if format == "" {
+		format = "int64"
+	}
+	var err error
+	switch format {
+	case "uint32":
+		_, err = strconv.ParseUint(value, 10, 32)
+	case "uint64":
+		_, err = strconv.ParseUint(value, 10, 64)
+	case "int32":
+		_, err = strconv.ParseInt(value, 10, 32)
+	default:
+		_, err = strconv.ParseInt(value, 10, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("must be an integer (%s)", format)
+	}
+	return nil
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateValue validates a JSON value decoded with numbers as json.Number
+// against schema. A null value is the default value of a protobuf field, and
+// as such is valid.
+func (spec *swaggerSpec) validateValue(schema *swaggerSchema, value interface{}, field string) error {
+	schema = spec.resolve(schema)
+	if schema == nil || value == nil {
+		return nil
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		return &fieldError{field, fmt.Sprintf("must be one of %v", schema.Enum)}
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return &fieldError{field, "must be an object"}
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return &fieldError{childField(field, name), "is required"}
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := object[name]; ok {
+				if err := spec.validateValue(schema.Properties[name], property, childField(field, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return &fieldError{field, "must be an array"}
+		}
+		for i, item := range array {
+			if err := spec.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return &fieldError{field, "must be a string"}
+		}
+		if schema.Format == "byte" {
+			if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+				return &fieldError{field, "must be base64 encoded"}
+			}
+		}
+	case "integer":
+		// 64-bit integers are strings in the JSON encoding of protobuf messages
+		var s string
+		switch number := value.(type) {
+		case json.Number:
+			s = number.String()
+		case string:
+			s = number
+		default:
+			return &fieldError{field, "must be an integer"}
+		}
+		if err := validateInteger(schema.Format, s); err != nil {
+			return &fieldError{field, err.Error()}
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return &fieldError{field, "must be a number"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return &fieldError{field, "must be a boolean"}
+		}
+	}
+	return nil
+}
+
+func childField(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// ValidateRequest is a middleware function that validates the parameters and
+// the payload of the requests against the Swagger definitions of the REST
+// API, rejecting the invalid requests before they reach their handler.
+func (s *ServerOpenchainREST) ValidateRequest(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	if restSpec == nil {
+		next(rw, req)
+		return
+	}
+	err := restSpec.validateRequest(req.Request)
+	if err == nil {
+		next(rw, req)
+		return
+	}
+	restLogger.Errorf("Invalid REST request %s %s: %s", req.Method, req.URL.Path, err)
+	field, message := "", err.Error()
+	if fieldErr, ok := err.(*fieldError); ok {
+		field, message = fieldErr.field, fieldErr.message
+		if field != "" {
+			message = fmt.Sprintf("%s %s.", field, message)
+		}
+	}
+	if req.URL.Path == "/chaincode" {
+		// the /chaincode endpoint answers with JSON RPC 2.0 errors
+		response := formatRPCResponse(formatRPCError(InvalidRequest.Code, InvalidRequest.Message, message), nil)
+		jsonResponse, _ := json.Marshal(response)
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write(jsonResponse)
+		return
+	}
+	writeFieldError(rw, http.StatusBadRequest, field, "%s", message)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+func TestSwaggerValidation(t *testing.T) {
+	spec, err := loadSwaggerSpec("rest_api.json")
+	if err != nil {
+		t.Fatalf("Error loading the Swagger definitions: %s", err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		field  string // empty if the request is valid
+	}{
+		{"GET", "/chain/blocks/3", "", ""},
+		{"GET", "/chain/blocks/-1", "", "Block"},
+		{"GET", "/chain/stream?from=x", "", "from"},
+		{"GET", "/registrar/alice/tcert?count=10", "", ""},
+		{"GET", "/registrar/alice/tcert?count=ten", "", "count"},
+		{"GET", "/undefined/path", "", ""},
+		{"POST", "/devops/deploy", `{"type": "GOLANG", "chaincodeID": {"path": "github.com/example"}, "ctorMsg": {"function": "init", "args": ["a", "100"]}}`, ""},
+		{"POST", "/devops/deploy", `{"type": 1, "chaincodeID": {"path": "github.com/example"}}`, ""},
+		{"POST", "/devops/deploy", `{"type": "JAVA"}`, "type"},
+		{"POST", "/devops/deploy", `{"chaincodeID": {"name": 42}}`, "chaincodeID.name"},
+		{"POST", "/devops/deploy", `{"ctorMsg": {"args": ["a", 100]}}`, "ctorMsg.args[1]"},
+		{"POST", "/devops/deploy", `{"chaincodeID": `, "ChaincodeSpec"},
+		{"POST", "/devops/deploy", "", ""},
+		{"POST", "/devops/invoke", `{"chaincodeSpec": {"confidentialityLevel": "SECRET"}}`, "chaincodeSpec.confidentialityLevel"},
+		{"POST", "/chaincode", `{"jsonrpc": "2.0", "method": "query", "params": {}, "id": "abc"}`, ""},
+		{"POST", "/chaincode", `{"jsonrpc": "2.0", "params": {}}`, "method"},
+		{"POST", "/transactions", `{"transaction": "not base64!"}`, "transaction"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		err := spec.validateRequest(req)
+		switch {
+		case test.field == "" && err != nil:
+			t.Errorf("Expected %s %s %s to be valid, got %s", test.method, test.path, test.body, err)
+		case test.field != "" && err == nil:
+			t.Errorf("Expected %s %s %s to be invalid", test.method, test.path, test.body)
+		case test.field != "" && err.(*fieldError).field != test.field:
+			t.Errorf("Expected %s %s %s to be invalid because of %s, got %s", test.method, test.path, test.body, test.field, err)
+		}
+	}
+
+	// the handler still reads the payload
+	req, _ := http.NewRequest("POST", "/registrar", strings.NewReader(`{"enrollId": "alice"}`))
+	if err := spec.validateRequest(req); err != nil {
+		t.Fatalf("Expected the request to be valid, got %s", err)
+	}
+	secret := &protos.Secret{}
+	if err := json.NewDecoder(req.Body).Decode(secret); err != nil || secret.EnrollId != "alice" {
+		t.Fatalf("Expected the payload to be restored, got %v: %s", secret, err)
+	}
+}
+
+func TestValidateRequestMiddleware(t *testing.T) {
+	spec, err := loadSwaggerSpec("rest_api.json")
+	if err != nil {
+		t.Fatalf("Error loading the Swagger definitions: %s", err)
+	}
+	restSpec = spec
+	defer func() { restSpec = nil }()
+
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).ValidateRequest)
+	router.Get("/chain/blocks/:id", func(rw web.ResponseWriter, req *web.Request) {
+		writeResponse(rw, req, &protos.BlockchainInfo{Height: 7})
+	})
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/chain/blocks/x", nil)
+	router.ServeHTTP(rec, req)
+	restErr := &restError{}
+	if err := json.Unmarshal(rec.Body.Bytes(), restErr); err != nil {
+		t.Fatalf("Error unmarshalling the error response %s: %s", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusBadRequest || restErr.Code != errorCodeInvalidRequest || restErr.Field != "Block" || restErr.Error != restErr.Message {
+		t.Fatalf("Expected a 400 invalid request error for Block, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/chain/blocks/1", nil)
+	req.Header.Set("Accept", "application/x-protobuf, application/json;q=0.5")
+	router.ServeHTTP(rec, req)
+	info := &protos.BlockchainInfo{}
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != mediaTypeProtobuf {
+		t.Fatalf("Expected a protobuf response, got %d with %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if err := proto.Unmarshal(rec.Body.Bytes(), info); err != nil || info.Height != 7 {
+		t.Fatalf("Expected the protobuf encoding of the blockchain info, got %v: %s", info, err)
+	}
+}
+
+func TestAcceptedMediaType(t *testing.T) {
+	tests := []struct {
+		accept    string
+		protobuf  bool
+		mediaType string
+		ok        bool
+	}{
+		{"", true, mediaTypeJSON, true},
+		{"*/*", true, mediaTypeJSON, true},
+		{"application/x-protobuf", true, mediaTypeProtobuf, true},
+		{"application/x-protobuf", false, "", false},
+		{"application/json;q=0.9, application/x-protobuf;q=0.1", true, mediaTypeJSON, true},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", true, mediaTypeJSON, true},
+		{"text/html", true, "", false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/chain", nil)
+		req.Header.Set("Accept", test.accept)
+		mediaType, ok := acceptedMediaType(&web.Request{Request: req}, test.protobuf)
+		if mediaType != test.mediaType || ok != test.ok {
+			t.Errorf("Expected %q with protobuf %v to select %q %v, got %q %v", test.accept, test.protobuf, test.mediaType, test.ok, mediaType, ok)
+		}
+	}
+}
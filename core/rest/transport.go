@@ -0,0 +1,159 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/comm"
+)
+
+// corsPolicy decides which web origins may call the REST API from a browser
+type corsPolicy struct {
+	enabled          bool
+	origins          map[string]bool
+	anyOrigin        bool
+	allowCredentials bool
+	maxAge           int
+}
+
+// restCORS is the CORS policy of the REST service, any origin being allowed
+// until it is configured
+var restCORS = &corsPolicy{enabled: true, anyOrigin: true}
+
+// newCORSPolicy returns the CORS policy configured in rest.cors
+func newCORSPolicy() *corsPolicy {
+	p := &corsPolicy{
+		enabled:          viper.GetBool("rest.cors.enabled"),
+		origins:          make(map[string]bool),
+		allowCredentials: viper.GetBool("rest.cors.allowCredentials"),
+		maxAge:           viper.GetInt("rest.cors.maxAge"),
+	}
+	for _, origin := range viper.GetStringSlice("rest.cors.allowedOrigins") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			p.anyOrigin = true
+		} else if origin != "" {
+			p.origins[strings.ToLower(origin)] = true
+		}
+	}
+	return p
+}
+
+// allowedOrigin returns the value of the Access-Control-Allow-Origin header
+// of a response to origin, or false if origin may not read it
+func (p *corsPolicy) allowedOrigin(origin string) (string, bool) {
+	if !p.enabled {
+		return "", false
+	}
+	if p.anyOrigin && !p.allowCredentials {
+		return "*", true
+	}
+	if origin == "" {
+		return "", false
+	}
+	if p.anyOrigin || p.origins[strings.ToLower(origin)] {
+		// credentials are only sent to an origin allowed by name
+		return origin, true
+	}
+	return "", false
+}
+
+// setHeaders sets the CORS headers of the response to req
+func (p *corsPolicy) setHeaders(rw web.ResponseWriter, req *web.Request) {
+	allowed, ok := p.allowedOrigin(req.Header.Get("Origin"))
+	if !ok {
+		return
+	}
+	rw.Header().Set("Access-Control-Allow-Origin", allowed)
+	rw.Header().Set("Access-Control-Allow-Headers", "accept, authorization, content-type, last-event-id, x-enrollment-id")
+	if allowed != "*" {
+		rw.Header().Add("Vary", "Origin")
+	}
+	if p.allowCredentials {
+		rw.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Preflight answers the CORS preflight OPTIONS requests with the methods of
+// the path.
+func (s *ServerOpenchainREST) Preflight(rw web.ResponseWriter, req *web.Request, methods []string) {
+	if _, ok := restCORS.allowedOrigin(req.Header.Get("Origin")); ok {
+		rw.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if restCORS.maxAge > 0 {
+			rw.Header().Set("Access-Control-Max-Age", strconv.Itoa(restCORS.maxAge))
+		}
+	}
+	rw.Header().Set("Allow", strings.Join(append(methods, "OPTIONS"), ", "))
+	rw.WriteHeader(http.StatusOK)
+}
+
+// restTLS returns whether the REST service listens for HTTPS, which it does
+// if rest.tls.enabled is set, or with the certificate of the peer if the peer
+// enables TLS
+func restTLS() bool {
+	return viper.GetBool("rest.tls.enabled") || comm.TLSEnabled()
+}
+
+// restTLSConfig returns the TLS configuration of the HTTPS listener of the
+// REST service, with the certificate and key in rest.tls if set, or else
+// those of the peer
+func restTLSConfig() (*tls.Config, error) {
+	certFile, keyFile := viper.GetString("rest.tls.cert.file"), viper.GetString("rest.tls.key.file")
+	if certFile == "" && keyFile == "" {
+		if !comm.TLSEnabled() {
+			return nil, fmt.Errorf("rest.tls.cert.file and rest.tls.key.file must be set when peer.tls is disabled")
+		}
+		// REST clients are not required to present a certificate, unless
+		// the ACL of the peer authorizes them by it
+		return comm.ServerTLSConfig(viper.GetBool("peer.acl.enabled"))
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading the REST TLS certificate %s and key %s: %s", certFile, keyFile, err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// hstsHeader returns the Strict-Transport-Security header of the HTTPS
+// responses configured in rest.tls.hsts, or "" if HSTS is disabled
+func hstsHeader() string {
+	maxAge := viper.GetInt("rest.tls.hsts.maxAge")
+	if maxAge <= 0 {
+		return ""
+	}
+	header := fmt.Sprintf("max-age=%d", maxAge)
+	if viper.GetBool("rest.tls.hsts.includeSubdomains") {
+		header += "; includeSubDomains"
+	}
+	return header
+}
+
+// restHSTS is the Strict-Transport-Security header of the responses, "" if
+// not served over HTTPS or disabled
+var restHSTS string
@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gocraft/web"
+	"github.com/spf13/viper"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	tests := []struct {
+		origins     []string
+		credentials bool
+		origin      string
+		allowed     string
+		ok          bool
+	}{
+		{[]string{"*"}, false, "https://app.example.com", "*", true},
+		{[]string{"*"}, true, "https://app.example.com", "https://app.example.com", true},
+		{[]string{"*"}, true, "", "", false},
+		{[]string{"https://app.example.com/"}, false, "https://APP.example.com", "https://APP.example.com", true},
+		{[]string{"https://app.example.com"}, false, "https://evil.example.com", "", false},
+		{nil, false, "https://app.example.com", "", false},
+	}
+	viper.Set("rest.cors.enabled", true)
+	defer func() {
+		viper.Set("rest.cors.enabled", false)
+		viper.Set("rest.cors.allowedOrigins", nil)
+		viper.Set("rest.cors.allowCredentials", false)
+	}()
+	for _, test := range tests {
+		viper.Set("rest.cors.allowedOrigins", test.origins)
+		viper.Set("rest.cors.allowCredentials", test.credentials)
+		allowed, ok := newCORSPolicy().allowedOrigin(test.origin)
+		if allowed != test.allowed || ok != test.ok {
+			t.Errorf("Expected origins %v with credentials %t to allow %s as '%s' %t, got '%s' %t", test.origins, test.credentials, test.origin, test.allowed, test.ok, allowed, ok)
+		}
+	}
+
+	if _, ok := (&corsPolicy{anyOrigin: true}).allowedOrigin("https://app.example.com"); ok {
+		t.Fatalf("Expected a disabled policy to allow no origin")
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	saved := restCORS
+	restCORS = &corsPolicy{enabled: true, origins: map[string]bool{"https://app.example.com": true}, maxAge: 600}
+	defer func() { restCORS = saved }()
+
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).SetResponseType)
+	router.OptionsHandler((*ServerOpenchainREST).Preflight)
+	router.Post("/chaincode", func(rw web.ResponseWriter, req *web.Request) {})
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/chaincode", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || rec.Header().Get("Access-Control-Allow-Methods") != "POST" || rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("Expected the preflight request of an allowed origin to be answered, got %d with %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("OPTIONS", "/chaincode", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	router.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Fatalf("Expected no CORS headers for an origin not allowed, got %v", rec.Header())
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	defer func() {
+		viper.Set("rest.tls.hsts.maxAge", 0)
+		viper.Set("rest.tls.hsts.includeSubdomains", false)
+		restHSTS = ""
+	}()
+	viper.Set("rest.tls.hsts.maxAge", 0)
+	if header := hstsHeader(); header != "" {
+		t.Fatalf("Expected HSTS to be disabled, got %s", header)
+	}
+	viper.Set("rest.tls.hsts.maxAge", 3600)
+	viper.Set("rest.tls.hsts.includeSubdomains", true)
+	restHSTS = hstsHeader()
+	if restHSTS != "max-age=3600; includeSubDomains" {
+		t.Fatalf("Expected an HSTS header including the subdomains, got %s", restHSTS)
+	}
+
+	router := web.New(ServerOpenchainREST{})
+	router.Middleware((*ServerOpenchainREST).SetResponseType)
+	router.Get("/chain", func(rw web.ResponseWriter, req *web.Request) {})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/chain", nil)
+	router.ServeHTTP(rec, req)
+	if header := rec.Header().Get("Strict-Transport-Security"); header != "" {
+		t.Fatalf("Expected no HSTS header over HTTP, got %s", header)
+	}
+	rec = httptest.NewRecorder()
+	req.TLS = &tls.ConnectionState{}
+	router.ServeHTTP(rec, req)
+	if header := rec.Header().Get("Strict-Transport-Security"); header != restHSTS {
+		t.Fatalf("Expected the HSTS header over HTTPS, got %s", header)
+	}
+}
+
+func TestRESTTLSConfig(t *testing.T) {
+	viper.Set("rest.tls.cert.file", "missing.pem")
+	viper.Set("rest.tls.key.file", "missing.key")
+	defer func() {
+		viper.Set("rest.tls.cert.file", "")
+		viper.Set("rest.tls.key.file", "")
+	}()
+	if _, err := restTLSConfig(); err == nil {
+		t.Fatalf("Expected an error loading a missing certificate")
+	}
+}
@@ -18,6 +18,7 @@ package api
 
 import (
 	"fmt"
+	"sync"
 
 	"golang.org/x/net/context"
 
@@ -27,10 +28,18 @@ import (
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
+	"github.com/spf13/viper"
 )
 
 var sysccLogger = logging.MustGetLogger("sysccapi")
 
+// sysCCs is the registry of the system chaincodes registered with the peer,
+// by name
+var sysCCs = struct {
+	sync.RWMutex
+	chaincodes map[string]*SystemChaincode
+}{chaincodes: make(map[string]*SystemChaincode)}
+
 // SystemChaincode defines the metadata needed to initialize system chaincode
 // when the fabric comes up. SystemChaincodes are installed by adding an
 // entry in importsysccs.go
@@ -52,16 +61,44 @@ type SystemChaincode struct {
 	Chaincode shim.Chaincode
 }
 
+// IsSysCC returns true if name is the name of a system chaincode registered
+// with the peer
+func IsSysCC(name string) bool {
+	sysCCs.RLock()
+	defer sysCCs.RUnlock()
+	_, ok := sysCCs.chaincodes[name]
+	return ok
+}
+
+// GetSysCC returns the system chaincode registered with the peer under name,
+// or nil
+func GetSysCC(name string) *SystemChaincode {
+	sysCCs.RLock()
+	defer sysCCs.RUnlock()
+	return sysCCs.chaincodes[name]
+}
+
+// isWhitelisted returns true if the system chaincode is enabled in the
+// chaincode.system section of core.yaml
+func isWhitelisted(syscc *SystemChaincode) bool {
+	enabled := viper.GetStringMapString("chaincode.system")
+	val, ok := enabled[syscc.Name]
+	return ok && (val == "enable" || val == "true" || val == "yes")
+}
+
 // RegisterSysCC registers the given system chaincode with the peer
 func RegisterSysCC(syscc *SystemChaincode) error {
 	if peer.SecurityEnabled() {
 		sysccLogger.Warning(fmt.Sprintf("Currently system chaincode does support security(%s,%s)", syscc.Name, syscc.Path))
 		return nil
 	}
-	if !syscc.Enabled {
+	if !syscc.Enabled || !isWhitelisted(syscc) {
 		sysccLogger.Info(fmt.Sprintf("system chaincode (%s,%s) disabled", syscc.Name, syscc.Path))
 		return nil
 	}
+	if IsSysCC(syscc.Name) {
+		return fmt.Errorf("system chaincode %s already registered", syscc.Name)
+	}
 
 	err := inproccontroller.Register(syscc.Path, syscc.Chaincode)
 	if err != nil {
@@ -79,7 +116,11 @@ func RegisterSysCC(syscc *SystemChaincode) error {
 		return fmt.Errorf(errStr)
 	}
 
-	sysccLogger.Info("system chaincode %s(%s) registered", syscc.Name, syscc.Path)
+	sysCCs.Lock()
+	sysCCs.chaincodes[syscc.Name] = syscc
+	sysCCs.Unlock()
+
+	sysccLogger.Infof("system chaincode %s(%s) registered", syscc.Name, syscc.Path)
 	return err
 }
 
@@ -21,7 +21,9 @@ import (
 	//import system chain codes here
 )
 
-//see systemchaincode_test.go for an example using "sample_syscc"
+//see systemchaincode_test.go for an example using "sample_syscc". A system
+//chaincode listed here must also be enabled in the chaincode.system section of
+//core.yaml to be registered
 var systemChaincodes = []*api.SystemChaincode{}
 
 //RegisterSysCCs is the hook for system chaincodes where system chaincodes are registered with the fabric
@@ -111,7 +111,13 @@ func TestExecuteDeploySysChaincode(t *testing.T) {
 		},
 	}
 
+	viper.Set("chaincode.system", map[string]string{"sample_syscc": "enable"})
+
 	RegisterSysCCs()
+	if !api.IsSysCC("sample_syscc") {
+		closeListenerAndSleep(lis)
+		t.Fatal("sample_syscc should be registered")
+	}
 
 	url := "github.com/hyperledger/fabric/core/system_chaincode/sample_syscc"
 	f := "putval"
@@ -144,6 +150,25 @@ func TestExecuteDeploySysChaincode(t *testing.T) {
 	closeListenerAndSleep(lis)
 }
 
+// Test that system chaincodes which are not whitelisted are not registered.
+func TestRegisterSysCCNotWhitelisted(t *testing.T) {
+	defer viper.Set("chaincode.system", viper.Get("chaincode.system"))
+	viper.Set("chaincode.system", map[string]string{"sample_syscc": "disable"})
+
+	syscc := &api.SystemChaincode{
+		Enabled:   true,
+		Name:      "disabled_syscc",
+		Path:      "github.com/hyperledger/fabric/core/system_chaincode/disabled_syscc",
+		Chaincode: &samplesyscc.SampleSysCC{},
+	}
+	if err := api.RegisterSysCC(syscc); err != nil {
+		t.Fatalf("Error registering disabled_syscc: %s", err)
+	}
+	if api.IsSysCC("disabled_syscc") {
+		t.Fatal("disabled_syscc should not be registered")
+	}
+}
+
 func TestMain(m *testing.M) {
 	SetupTestConfig()
 	os.Exit(m.Run())
@@ -0,0 +1,74 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import "sync"
+
+// Pending holds the spans started by a part of the peer and finished by
+// another, by the ID of their transaction, such as the consensus span of a
+// transaction from its reception to its execution. The spans of the
+// transactions never finished are dropped, the oldest first, once max are
+// pending.
+type Pending struct {
+	sync.Mutex
+	max   int
+	spans map[string]*Span
+}
+
+// NewPending creates a holder of at most max pending spans
+func NewPending(max int) *Pending {
+	return &Pending{max: max, spans: make(map[string]*Span)}
+}
+
+// Add holds the span of the transaction, if any
+func (p *Pending) Add(txID string, span *Span) {
+	if span == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.spans[txID]; !ok && len(p.spans) >= p.max {
+		var oldest string
+		for id, s := range p.spans {
+			if oldest == "" || s.Start.Before(p.spans[oldest].Start) {
+				oldest = id
+			}
+		}
+		logger.Debugf("Dropping the %s span of transaction %s, which was never finished", p.spans[oldest].Name, oldest)
+		delete(p.spans, oldest)
+	}
+	p.spans[txID] = span
+}
+
+// Finish finishes the span of the transaction, if any, tagged with err
+func (p *Pending) Finish(txID string, err error) {
+	p.Lock()
+	span, ok := p.spans[txID]
+	delete(p.spans, txID)
+	p.Unlock()
+	if ok {
+		span.SetError(err)
+		span.Finish()
+	}
+}
+
+// Len returns the number of pending spans
+func (p *Pending) Len() int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.spans)
+}
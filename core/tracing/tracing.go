@@ -0,0 +1,217 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing traces the transactions through the peers, in the manner of
+// OpenTracing. The peer a transaction is submitted to starts its trace, and
+// each peer it goes through records spans of its processing: submission,
+// consensus, execution and commit. The context of the span of the last peer
+// travels with the transaction, in its traceContext, for the spans of the
+// next peers to be its children, so that a tracing backend such as Zipkin or
+// Jaeger puts the spans of all the peers together.
+package tracing
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var logger = logging.MustGetLogger("tracing")
+
+// Span is a timed operation of the processing of a transaction by a peer. A
+// nil span, as started when tracing is off or the transaction is not traced,
+// does nothing. A span is not safe for concurrent use until it is finished.
+type Span struct {
+	TraceID  uint64
+	SpanID   uint64
+	ParentID uint64 // 0 for the root span of the trace
+	Name     string
+	Service  string // the peer which recorded the span
+	Start    time.Time
+	Duration time.Duration
+	Tags     map[string]string
+
+	tracer *Tracer
+	once   sync.Once
+}
+
+// Context returns the context of the span to propagate with the transaction
+func (s *Span) Context() *pb.SpanContext {
+	if s == nil {
+		return nil
+	}
+	return &pb.SpanContext{TraceID: s.TraceID, SpanID: s.SpanID}
+}
+
+// SetTag annotates the span with the value of key
+func (s *Span) SetTag(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.Tags[key] = fmt.Sprint(value)
+}
+
+// SetError tags the span with err, if any
+func (s *Span) SetError(err error) {
+	if err != nil {
+		s.SetTag("error", err.Error())
+	}
+}
+
+// Finish ends the span and reports it, only the first time
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.Duration = time.Since(s.Start)
+		s.tracer.reporter.Report(s)
+	})
+}
+
+// TraceIDString returns the trace ID as it appears in the tracing backend
+func (s *Span) TraceIDString() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("%016x", s.TraceID)
+}
+
+// Reporter sends the finished spans to a tracing backend
+type Reporter interface {
+	Report(span *Span)
+}
+
+// Tracer starts the spans of a peer
+type Tracer struct {
+	sync.Mutex
+	service      string
+	samplingRate float64
+	reporter     Reporter
+	rand         *rand.Rand
+}
+
+// NewTracer creates a tracer recording the spans as service, tracing the
+// fraction samplingRate of the transactions it starts the trace of
+func NewTracer(service string, samplingRate float64, reporter Reporter) *Tracer {
+	return &Tracer{
+		service:      service,
+		samplingRate: samplingRate,
+		reporter:     reporter,
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (t *Tracer) newID() uint64 {
+	t.Lock()
+	defer t.Unlock()
+	for {
+		if id := uint64(t.rand.Int63())<<1 | uint64(t.rand.Int63()&1); id != 0 {
+			return id
+		}
+	}
+}
+
+func (t *Tracer) sampled() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.rand.Float64() < t.samplingRate
+}
+
+// StartSpan starts a span child of parent, or the root span of a new trace
+// if parent is nil and the trace is sampled
+func (t *Tracer) StartSpan(name string, parent *pb.SpanContext) *Span {
+	if t == nil {
+		return nil
+	}
+	span := &Span{Name: name, Service: t.service, Start: time.Now(), Tags: make(map[string]string), tracer: t}
+	span.SpanID = t.newID()
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else if t.sampled() {
+		span.TraceID = span.SpanID
+	} else {
+		return nil
+	}
+	return span
+}
+
+// StartChildSpan starts a span child of parent, nil if parent is
+func (t *Tracer) StartChildSpan(name string, parent *pb.SpanContext) *Span {
+	if parent == nil {
+		return nil
+	}
+	return t.StartSpan(name, parent)
+}
+
+var (
+	tracerLock sync.RWMutex
+	tracer     *Tracer // nil when tracing is off
+)
+
+// SetTracer sets the tracer of the peer, nil turning tracing off
+func SetTracer(t *Tracer) {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	tracer = t
+}
+
+func getTracer() *Tracer {
+	tracerLock.RLock()
+	defer tracerLock.RUnlock()
+	return tracer
+}
+
+// StartSpan starts a span with the tracer of the peer, see Tracer.StartSpan
+func StartSpan(name string, parent *pb.SpanContext) *Span {
+	return getTracer().StartSpan(name, parent)
+}
+
+// StartChildSpan starts a span with the tracer of the peer, see
+// Tracer.StartChildSpan
+func StartChildSpan(name string, parent *pb.SpanContext) *Span {
+	return getTracer().StartChildSpan(name, parent)
+}
+
+// Init sets the tracer of the peer from peer.tracing, reporting the spans to
+// the Zipkin endpoint if it is set, and logging them otherwise
+func Init() {
+	if !viper.GetBool("peer.tracing.enabled") {
+		SetTracer(nil)
+		return
+	}
+	var reporter Reporter = logReporter{}
+	if endpoint := viper.GetString("peer.tracing.endpoint"); endpoint != "" {
+		reporter = NewZipkinReporter(endpoint)
+	}
+	samplingRate := viper.GetFloat64("peer.tracing.samplingRate")
+	logger.Infof("Tracing %v of the transactions submitted to this peer", samplingRate)
+	SetTracer(NewTracer(viper.GetString("peer.id"), samplingRate, reporter))
+}
+
+// logReporter logs the spans, to inspect them without a tracing backend
+type logReporter struct{}
+
+func (logReporter) Report(span *Span) {
+	logger.Infof("Span %s of trace %s on %s took %v %v", span.Name, span.TraceIDString(), span.Service, span.Duration, span.Tags)
+}
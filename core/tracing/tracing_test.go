@@ -0,0 +1,139 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// recorder records the spans reported
+type recorder struct {
+	sync.Mutex
+	spans []*Span
+}
+
+func (r *recorder) Report(span *Span) {
+	r.Lock()
+	defer r.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func TestSpans(t *testing.T) {
+	r := &recorder{}
+	tracer := NewTracer("vp0", 1, r)
+
+	root := tracer.StartSpan("submit", nil)
+	if root == nil || root.TraceID == 0 || root.TraceID != root.SpanID || root.ParentID != 0 {
+		t.Fatalf("Expected a root span, got %+v", root)
+	}
+	child := tracer.StartChildSpan("execute", root.Context())
+	if child == nil || child.TraceID != root.TraceID || child.ParentID != root.SpanID || child.SpanID == root.SpanID {
+		t.Fatalf("Expected a child of the root span, got %+v", child)
+	}
+	child.SetError(errors.New("failed"))
+	child.Finish()
+	child.Finish()
+	root.Finish()
+
+	if len(r.spans) != 2 || r.spans[0] != child || r.spans[1] != root {
+		t.Fatalf("Expected the child and root spans to be reported once, got %v", r.spans)
+	}
+	if child.Tags["error"] != "failed" || child.Service != "vp0" {
+		t.Errorf("Unexpected child span %+v", child)
+	}
+
+	if span := tracer.StartChildSpan("execute", nil); span != nil {
+		t.Errorf("Expected no span without a parent, got %+v", span)
+	}
+	if span := NewTracer("vp0", 0, r).StartSpan("submit", nil); span != nil {
+		t.Errorf("Expected no span when not sampled, got %+v", span)
+	}
+	if span := NewTracer("vp0", 0, r).StartSpan("submit", root.Context()); span == nil {
+		t.Errorf("Expected a span of the trace started by another peer, even when not sampled")
+	}
+
+	// a nil span, as started when tracing is off, does nothing
+	var off *Tracer
+	span := off.StartSpan("submit", root.Context())
+	span.SetTag("txid", "1")
+	span.Finish()
+	if span != nil || span.Context() != nil {
+		t.Errorf("Expected no span when tracing is off")
+	}
+}
+
+func TestPending(t *testing.T) {
+	r := &recorder{}
+	tracer := NewTracer("vp0", 1, r)
+	parent := &pb.SpanContext{TraceID: 1, SpanID: 1}
+	pending := NewPending(2)
+
+	pending.Add("tx1", tracer.StartChildSpan("consensus", parent))
+	pending.Add("tx2", tracer.StartChildSpan("consensus", parent))
+	pending.Add("tx3", tracer.StartChildSpan("consensus", parent))
+	pending.Add("tx4", nil)
+	if pending.Len() != 2 {
+		t.Fatalf("Expected 2 pending spans, got %d", pending.Len())
+	}
+	pending.Finish("tx1", nil)
+	pending.Finish("tx3", errors.New("rejected"))
+	if len(r.spans) != 1 || r.spans[0].Tags["error"] != "rejected" {
+		t.Fatalf("Expected the oldest span to be dropped and the span of tx3 to be reported, got %v", r.spans)
+	}
+}
+
+func TestZipkinReporter(t *testing.T) {
+	received := make(chan []zipkinSpan, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		var spans []zipkinSpan
+		if err := json.Unmarshal(body, &spans); err != nil {
+			t.Errorf("Failed unmarshalling the spans %s: %s", body, err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		received <- spans
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("vp0", 1, NewZipkinReporter(server.URL))
+	span := tracer.StartChildSpan("commit", &pb.SpanContext{TraceID: 0xabc, SpanID: 0xdef})
+	span.SetTag("block", 3)
+	span.Finish()
+
+	select {
+	case spans := <-received:
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 span, got %v", spans)
+		}
+		zs := spans[0]
+		if zs.TraceID != "0000000000000abc" || zs.ParentID != "0000000000000def" || zs.Name != "commit" ||
+			zs.LocalEndpoint.ServiceName != "vp0" || zs.Tags["block"] != "3" || zs.Duration < 1 {
+			t.Errorf("Unexpected span %+v", zs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the span to be posted")
+	}
+}
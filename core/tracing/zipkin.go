@@ -0,0 +1,136 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	zipkinBatchSize     = 100
+	zipkinFlushInterval = time.Second
+	zipkinQueueSize     = 10000
+)
+
+// zipkinSpan is a span in the JSON format of the v2 API of Zipkin, which
+// Jaeger accepts as well
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Timestamp     int64             `json:"timestamp"` // microseconds since the epoch
+	Duration      int64             `json:"duration"`  // microseconds
+	LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+func newZipkinSpan(span *Span) *zipkinSpan {
+	zs := &zipkinSpan{
+		TraceID:       fmt.Sprintf("%016x", span.TraceID),
+		ID:            fmt.Sprintf("%016x", span.SpanID),
+		Name:          span.Name,
+		Timestamp:     span.Start.UnixNano() / int64(time.Microsecond),
+		Duration:      int64(span.Duration / time.Microsecond),
+		LocalEndpoint: zipkinEndpoint{ServiceName: span.Service},
+		Tags:          span.Tags,
+	}
+	if span.ParentID != 0 {
+		zs.ParentID = fmt.Sprintf("%016x", span.ParentID)
+	}
+	if zs.Duration == 0 {
+		// Zipkin reads a duration of 0 as unknown
+		zs.Duration = 1
+	}
+	return zs
+}
+
+// ZipkinReporter posts the spans in batches to the v2 API of Zipkin at
+// endpoint, e.g. http://localhost:9411/api/v2/spans. The spans reported
+// faster than they are posted are dropped rather than slowing the peer down.
+type ZipkinReporter struct {
+	endpoint string
+	client   *http.Client
+	queue    chan *Span
+}
+
+// NewZipkinReporter creates a reporter posting the spans to endpoint
+func NewZipkinReporter(endpoint string) *ZipkinReporter {
+	r := &ZipkinReporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan *Span, zipkinQueueSize),
+	}
+	go r.run()
+	return r
+}
+
+// Report queues the span to be posted
+func (r *ZipkinReporter) Report(span *Span) {
+	select {
+	case r.queue <- span:
+	default:
+		logger.Debugf("Dropping the %s span of trace %s, the queue of spans to post is full", span.Name, span.TraceIDString())
+	}
+}
+
+func (r *ZipkinReporter) run() {
+	ticker := time.NewTicker(zipkinFlushInterval)
+	defer ticker.Stop()
+	var batch []*zipkinSpan
+	for {
+		select {
+		case span := <-r.queue:
+			batch = append(batch, newZipkinSpan(span))
+			if len(batch) < zipkinBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := r.post(batch); err != nil {
+			logger.Warningf("Failed posting %d spans to %s: %s", len(batch), r.endpoint, err)
+		}
+		batch = nil
+	}
+}
+
+func (r *ZipkinReporter) post(batch []*zipkinSpan) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Post(r.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Unexpected status %s", resp.Status)
+	}
+	return nil
+}
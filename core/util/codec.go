@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Codec serializes values for persistence
+type Codec interface {
+	// ID identifies the codec in the format header, it must never change
+	ID() byte
+	// Name is a human readable name of the codec
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Codecs available for persistence
+var (
+	GobCodec   Codec = gobCodec{}
+	ProtoCodec Codec = protoCodec{}
+	JSONCodec  Codec = jsonCodec{}
+)
+
+var codecs = map[byte]Codec{
+	GobCodec.ID():   GobCodec,
+	ProtoCodec.ID(): ProtoCodec,
+	JSONCodec.ID():  JSONCodec,
+}
+
+const (
+	// formatMagic starts every encoded value. It is an invalid protobuf tag
+	// (wire type 6) and cannot start a JSON document, so it is not confused
+	// with protobuf values written before the header existed
+	formatMagic byte = 0xfe
+
+	// FormatVersion is the version of the on-disk format written by Encode
+	FormatVersion byte = 1
+
+	formatHeaderLen = 3
+)
+
+// Encode serializes v with the given codec and prefixes it with a format
+// header recording the format version and the codec used
+func Encode(codec Codec, v interface{}) ([]byte, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding with %s codec: %s", codec.Name(), err)
+	}
+	return append([]byte{formatMagic, FormatVersion, codec.ID()}, data...), nil
+}
+
+// Decode deserializes data written by Encode into v, using the codec recorded
+// in the header. Data without a header, as written before the header existed,
+// is decoded with the legacy codec.
+func Decode(data []byte, v interface{}, legacy Codec) error {
+	if len(data) < formatHeaderLen || data[0] != formatMagic {
+		if legacy == nil {
+			return fmt.Errorf("Missing format header and no legacy codec given")
+		}
+		return legacy.Unmarshal(data, v)
+	}
+	if data[1] > FormatVersion {
+		return fmt.Errorf("Unsupported format version %d, newest supported is %d", data[1], FormatVersion)
+	}
+	codec, ok := codecs[data[2]]
+	if !ok {
+		return fmt.Errorf("Unknown codec %d", data[2])
+	}
+	if err := codec.Unmarshal(data[formatHeaderLen:], v); err != nil {
+		return fmt.Errorf("Error decoding with %s codec: %s", codec.Name(), err)
+	}
+	return nil
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte     { return 1 }
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) ID() byte     { return 2 }
+func (protoCodec) Name() string { return "protobuf" }
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a protobuf message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a protobuf message", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// jsonCodec produces canonical JSON: map keys are sorted, struct fields are
+// written in declaration order and no insignificant whitespace is emitted
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte     { return 3 }
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	gp "google/protobuf"
+)
+
+type codecTestValue struct {
+	Name  string
+	Count int
+	Tags  map[string]string
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	value := codecTestValue{"foo", 3, map[string]string{"b": "2", "a": "1"}}
+	for _, codec := range []Codec{GobCodec, JSONCodec} {
+		data, err := Encode(codec, &value)
+		if err != nil {
+			t.Fatalf("Error encoding with %s: %s", codec.Name(), err)
+		}
+		var decoded codecTestValue
+		if err = Decode(data, &decoded, nil); err != nil {
+			t.Fatalf("Error decoding with %s: %s", codec.Name(), err)
+		}
+		if !reflect.DeepEqual(value, decoded) {
+			t.Fatalf("Decoded value mismatch with %s. Expected = %v, Got = %v", codec.Name(), value, decoded)
+		}
+	}
+
+	ts := &gp.Timestamp{Seconds: 10, Nanos: 20}
+	data, err := Encode(ProtoCodec, ts)
+	if err != nil {
+		t.Fatalf("Error encoding with protobuf: %s", err)
+	}
+	decodedTs := &gp.Timestamp{}
+	if err = Decode(data, decodedTs, nil); err != nil {
+		t.Fatalf("Error decoding with protobuf: %s", err)
+	}
+	if !proto.Equal(ts, decodedTs) {
+		t.Fatalf("Decoded value mismatch. Expected = %v, Got = %v", ts, decodedTs)
+	}
+
+	if _, err = Encode(ProtoCodec, &value); err == nil {
+		t.Fatalf("Expected error encoding a non protobuf value with protobuf codec")
+	}
+}
+
+func TestCodecJSONCanonical(t *testing.T) {
+	data, _ := Encode(JSONCodec, map[string]int{"b": 2, "a": 1})
+	if string(data[formatHeaderLen:]) != `{"a":1,"b":2}` {
+		t.Fatalf("Expected canonical JSON, Got = %s", data[formatHeaderLen:])
+	}
+}
+
+func TestCodecLegacyAndVersion(t *testing.T) {
+	ts := &gp.Timestamp{Seconds: 10}
+	raw, _ := proto.Marshal(ts)
+
+	decoded := &gp.Timestamp{}
+	if err := Decode(raw, decoded, ProtoCodec); err != nil {
+		t.Fatalf("Error decoding legacy data: %s", err)
+	}
+	if !proto.Equal(ts, decoded) {
+		t.Fatalf("Decoded legacy value mismatch. Expected = %v, Got = %v", ts, decoded)
+	}
+	if err := Decode(raw, decoded, nil); err == nil {
+		t.Fatalf("Expected error decoding headerless data without legacy codec")
+	}
+
+	data, _ := Encode(ProtoCodec, ts)
+	data[1] = FormatVersion + 1
+	if err := Decode(data, decoded, nil); err == nil {
+		t.Fatalf("Expected error decoding unsupported format version")
+	}
+	data[1] = FormatVersion
+	data[2] = 0xff
+	if err := Decode(data, decoded, nil); err == nil {
+		t.Fatalf("Expected error decoding unknown codec")
+	}
+}
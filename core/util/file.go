@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DefaultFilePerm is the permission used by SaveToDisk
+const DefaultFilePerm os.FileMode = 0600
+
+// SaveToDisk atomically replaces the file at path with data using DefaultFilePerm
+func SaveToDisk(path string, data []byte) error {
+	return SaveToDiskAtomic(path, data, DefaultFilePerm)
+}
+
+// SaveToDiskAtomic writes data to a temporary file in the same directory, fsyncs
+// it and renames it over path. A crash during the write leaves either the old or
+// the new content at path, never a partially written file.
+func SaveToDiskAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Error creating temporary file for [%s]: %s", path, err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return fmt.Errorf("Error writing [%s]: %s", tmp.Name(), err)
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("Error setting permissions of [%s]: %s", tmp.Name(), err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("Error syncing [%s]: %s", tmp.Name(), err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("Error closing [%s]: %s", tmp.Name(), err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Error renaming [%s] to [%s]: %s", tmp.Name(), path, err)
+	}
+
+	// Sync the directory so that the rename itself is durable. Not all
+	// platforms support this, so failures are ignored
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveToDiskAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "savetodisk")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data")
+
+	if err = SaveToDiskAtomic(path, []byte("first"), 0640); err != nil {
+		t.Fatalf("Error saving to disk: %s", err)
+	}
+	if err = SaveToDiskAtomic(path, []byte("second"), 0640); err != nil {
+		t.Fatalf("Error saving to disk: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading file: %s", err)
+	}
+	if !bytes.Equal(data, []byte("second")) {
+		t.Fatalf("Unexpected file content. Expected = second, Got = %s", data)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0640 {
+		t.Fatalf("Unexpected file permissions. Expected = 0640, Got = %o", info.Mode().Perm())
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("Expected temporary files to be removed, found %d files", len(files))
+	}
+}
+
+func TestSaveToDiskMissingDir(t *testing.T) {
+	if err := SaveToDisk("/nonexistent/dir/data", []byte("data")); err == nil {
+		t.Fatalf("Expected error saving to a missing directory")
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Names of the hash algorithms registered by default
+const (
+	HashSHAKE256 = "SHAKE256"
+	HashSHA256   = "SHA256"
+	HashSHA3512  = "SHA3-512"
+	HashBLAKE2b  = "BLAKE2b-512"
+)
+
+// HashProvider returns a new instance of a hash algorithm
+type HashProvider func() hash.Hash
+
+var (
+	hashProvidersLock   sync.RWMutex
+	hashProviders       = make(map[string]HashProvider)
+	defaultHashName     = HashSHAKE256
+	defaultHashProvider HashProvider
+)
+
+func init() {
+	RegisterHashProvider(HashSHAKE256, newShake256)
+	RegisterHashProvider(HashSHA256, sha256.New)
+	RegisterHashProvider(HashSHA3512, sha3.New512)
+	RegisterHashProvider(HashBLAKE2b, newBlake2b512)
+	defaultHashProvider = newShake256
+}
+
+// RegisterHashProvider makes a hash algorithm available under the given name.
+// Names are case insensitive. Registering an existing name replaces the provider.
+func RegisterHashProvider(name string, provider HashProvider) {
+	if provider == nil {
+		panic("util: RegisterHashProvider called with nil provider for " + name)
+	}
+	hashProvidersLock.Lock()
+	defer hashProvidersLock.Unlock()
+	hashProviders[strings.ToUpper(name)] = provider
+}
+
+// GetHashProvider returns the provider registered under the given name
+func GetHashProvider(name string) (HashProvider, error) {
+	hashProvidersLock.RLock()
+	defer hashProvidersLock.RUnlock()
+	provider, ok := hashProviders[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown hash algorithm [%s]", name)
+	}
+	return provider, nil
+}
+
+// SetDefaultHash selects the algorithm used by ComputeCryptoHash. This must be
+// called before the ledger is opened and must be the same on all peers of a network.
+func SetDefaultHash(name string) error {
+	provider, err := GetHashProvider(name)
+	if err != nil {
+		return err
+	}
+	hashProvidersLock.Lock()
+	defer hashProvidersLock.Unlock()
+	defaultHashName = name
+	defaultHashProvider = provider
+	return nil
+}
+
+// GetDefaultHashName returns the name of the algorithm used by ComputeCryptoHash
+func GetDefaultHashName() string {
+	hashProvidersLock.RLock()
+	defer hashProvidersLock.RUnlock()
+	return defaultHashName
+}
+
+// IsSameHash returns true if both names refer to the same registered algorithm
+func IsSameHash(name1, name2 string) bool {
+	return strings.ToUpper(name1) == strings.ToUpper(name2)
+}
+
+// NewCryptoHashWriter returns a streaming hasher using the same algorithm as
+// ComputeCryptoHash. Calling Sum(nil) after writing some data returns the same
+// value as ComputeCryptoHash over the concatenation of that data.
+func NewCryptoHashWriter() hash.Hash {
+	return newCryptoHash()
+}
+
+func newCryptoHash() hash.Hash {
+	hashProvidersLock.RLock()
+	defer hashProvidersLock.RUnlock()
+	return defaultHashProvider()
+}
+
+// shake256 adapts the variable length SHAKE256 to hash.Hash with a fixed 64 byte output
+type shake256 struct {
+	sha3.ShakeHash
+}
+
+func newShake256() hash.Hash {
+	return &shake256{sha3.NewShake256()}
+}
+
+func (s *shake256) Sum(b []byte) []byte {
+	out := make([]byte, s.Size())
+	s.Clone().Read(out)
+	return append(b, out...)
+}
+
+func (s *shake256) Size() int {
+	return 64
+}
+
+func (s *shake256) BlockSize() int {
+	return 136
+}
+
+// newBlake2b512 returns an unkeyed BLAKE2b-512 as specified in RFC 7693
+func newBlake2b512() hash.Hash {
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		panic(fmt.Sprintf("Error creating BLAKE2b-512 hash: %s", err))
+	}
+	return h
+}
@@ -24,15 +24,14 @@ import (
 	"time"
 
 	gp "google/protobuf"
-
-	"golang.org/x/crypto/sha3"
 )
 
-// ComputeCryptoHash should be used in openchain code so that we can change the actual algo used for crypto-hash at one place
+// ComputeCryptoHash should be used in openchain code so that we can change the actual algo used for crypto-hash at one place.
+// The algorithm is selected with SetDefaultHash and defaults to SHAKE256 with a 64 byte output
 func ComputeCryptoHash(data []byte) (hash []byte) {
-	hash = make([]byte, 64)
-	sha3.ShakeSum256(hash, data)
-	return
+	h := newCryptoHash()
+	h.Write(data)
+	return h.Sum(nil)
 }
 
 // GenerateBytesUUID returns a UUID based on RFC 4122 returning the generated bytes
@@ -62,6 +61,24 @@ func GenerateIntUUID() *big.Int {
 // GenerateUUID returns a UUID based on RFC 4122
 func GenerateUUID() string {
 	uuid := GenerateBytesUUID()
+	return uuidBytesToStr(uuid)
+}
+
+// GenerateUUIDFromBytes returns a name based UUID (similar to RFC 4122 version 5)
+// derived from the crypto hash of data. The same data always yields the same UUID.
+func GenerateUUIDFromBytes(data []byte) string {
+	uuid := ComputeCryptoHash(data)[:16]
+
+	// variant bits; see section 4.1.1
+	uuid[8] = uuid[8]&^0xc0 | 0x80
+
+	// version 5 (name based); see section 4.1.3
+	uuid[6] = uuid[6]&^0xf0 | 0x50
+
+	return uuidBytesToStr(uuid)
+}
+
+func uuidBytesToStr(uuid []byte) string {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
 }
 
@@ -18,8 +18,13 @@ package util
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/sha3"
 )
 
 func TestComputeCryptoHash(t *testing.T) {
@@ -42,6 +47,22 @@ func TestUUIDGeneration(t *testing.T) {
 	}
 }
 
+func TestUUIDFromBytesGeneration(t *testing.T) {
+	uuid := GenerateUUIDFromBytes([]byte("foobar"))
+	if len(uuid) != 36 {
+		t.Fatalf("UUID length is not correct. Expected = 36, Got = %d", len(uuid))
+	}
+	if uuid[14] != '5' {
+		t.Fatalf("Expected version 5 UUID, Got = %s", uuid)
+	}
+	if uuid != GenerateUUIDFromBytes([]byte("foobar")) {
+		t.Fatalf("Expected UUIDs derived from the same bytes to be equal")
+	}
+	if uuid == GenerateUUIDFromBytes([]byte("foobar1")) {
+		t.Fatalf("Expected UUIDs derived from different bytes to be different")
+	}
+}
+
 func TestIntUUIDGeneration(t *testing.T) {
 	uuid := GenerateIntUUID()
 
@@ -67,3 +88,97 @@ func TestGenerateHashFromSignature(t *testing.T) {
 		t.Fatalf("Expected hashes to be different, but they match")
 	}
 }
+
+func TestComputeCryptoHashDefaultIsShake256(t *testing.T) {
+	expected := make([]byte, 64)
+	sha3.ShakeSum256(expected, []byte("foobar"))
+	if !bytes.Equal(ComputeCryptoHash([]byte("foobar")), expected) {
+		t.Fatalf("Expected default hash to be SHAKE256 with 64 bytes output")
+	}
+}
+
+func TestSetDefaultHash(t *testing.T) {
+	defer SetDefaultHash(HashSHAKE256)
+
+	if err := SetDefaultHash("unknown"); err == nil {
+		t.Fatalf("Expected error for unknown hash algorithm")
+	}
+	if GetDefaultHashName() != HashSHAKE256 {
+		t.Fatalf("Default hash should not change on error. Got = %s", GetDefaultHashName())
+	}
+
+	if err := SetDefaultHash("sha256"); err != nil {
+		t.Fatalf("Error setting default hash: %s", err)
+	}
+	expected := sha256.Sum256([]byte("foobar"))
+	if !bytes.Equal(ComputeCryptoHash([]byte("foobar")), expected[:]) {
+		t.Fatalf("Expected SHA256 hash")
+	}
+
+	if err := SetDefaultHash(HashSHA3512); err != nil {
+		t.Fatalf("Error setting default hash: %s", err)
+	}
+	expected512 := sha3.Sum512([]byte("foobar"))
+	if !bytes.Equal(ComputeCryptoHash([]byte("foobar")), expected512[:]) {
+		t.Fatalf("Expected SHA3-512 hash")
+	}
+}
+
+func TestRegisterHashProvider(t *testing.T) {
+	defer SetDefaultHash(HashSHAKE256)
+
+	RegisterHashProvider("test-sha1", sha1.New)
+	if err := SetDefaultHash("TEST-SHA1"); err != nil {
+		t.Fatalf("Error setting registered hash: %s", err)
+	}
+	expected := sha1.Sum([]byte("foobar"))
+	if !bytes.Equal(ComputeCryptoHash([]byte("foobar")), expected[:]) {
+		t.Fatalf("Expected registered provider to be used")
+	}
+	if !IsSameHash(GetDefaultHashName(), "test-sha1") {
+		t.Fatalf("Unexpected default hash name %s", GetDefaultHashName())
+	}
+}
+
+func TestBlake2b(t *testing.T) {
+	// Test vectors from RFC 7693 appendix A and the reference implementation
+	vectors := map[string]string{
+		"":    "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce",
+		"abc": "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+	}
+	for in, out := range vectors {
+		h := newBlake2b512()
+		h.Write([]byte(in))
+		if got := hex.EncodeToString(h.Sum(nil)); got != out {
+			t.Fatalf("Wrong BLAKE2b-512 digest for [%s]. Expected = %s, Got = %s", in, out, got)
+		}
+	}
+
+	// Incremental writes across block boundaries must match a single write
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	h1 := newBlake2b512()
+	h1.Write(data)
+	h2 := newBlake2b512()
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		h2.Write(data[i:end])
+	}
+	if !bytes.Equal(h1.Sum(nil), h2.Sum(nil)) {
+		t.Fatalf("Incremental BLAKE2b-512 digest does not match")
+	}
+}
+
+func TestCryptoHashWriter(t *testing.T) {
+	hw := NewCryptoHashWriter()
+	hw.Write([]byte("foo"))
+	hw.Write([]byte("bar"))
+	if !bytes.Equal(hw.Sum(nil), ComputeCryptoHash([]byte("foobar"))) {
+		t.Fatalf("Expected streaming hash to match ComputeCryptoHash")
+	}
+}
@@ -28,4 +28,28 @@ type Discovery interface {
 
 	// GetRootNode function for providing all bootstrap addresses for a peer
 	GetRootNodes() []string
+
+	// AddNode adds the address of a discovered peer, returning false if it was known already
+	AddNode(address string) bool
+
+	// RemoveNode removes the address of a discovered peer, returning false if it was not known
+	RemoveNode(address string) bool
+
+	// GetAllNodes returns the bootstrap addresses followed by the addresses of the discovered peers
+	GetAllNodes() []string
+}
+
+// Provider looks up the bootstrap addresses, e.g. in DNS or through the API
+// of a cloud provider, as they change while the peers run
+type Provider interface {
+	// Lookup returns the current bootstrap addresses
+	Lookup() ([]string, error)
+}
+
+// Refresher is implemented by the discoveries whose bootstrap addresses
+// change while the peer runs
+type Refresher interface {
+	// Refresh looks the bootstrap addresses up again, and returns the
+	// addresses added and removed since the last time
+	Refresh() (added []string, removed []string, err error)
 }
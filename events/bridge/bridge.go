@@ -0,0 +1,331 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bridge publishes the events of the event hub to Kafka topics or to
+// an MQTT broker, for existing event pipelines to consume them without a
+// custom consumer of the event hub. The bridge is an event adapter registered
+// with the event hub of the peer like any other consumer: it publishes each
+// event it receives as JSON to the topic of its type, retrying the failed
+// publications.
+package bridge
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/events/consumer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var bridgeLogger = logging.MustGetLogger("eventhub_bridge")
+
+// defaultQueueSize bounds the events waiting to be published if the
+// configuration does not
+const defaultQueueSize = 1000
+
+// Publisher publishes messages to the topics of a broker, reconnecting on the
+// next publication after an error
+type Publisher interface {
+	// Publish publishes value to topic. The key of the message, if any,
+	// selects the partition of the topic, for the brokers which have any.
+	Publish(topic string, key []byte, value []byte) error
+	Close() error
+}
+
+// Config is the configuration of a bridge
+type Config struct {
+	// Interests are the events published
+	Interests []*pb.Interest
+	// Topics maps the event types to the topics their events are published
+	// to. The topics of the chaincode events may contain the {chaincodeID}
+	// and {eventName} placeholders, replaced by those of each event.
+	Topics map[pb.EventType]string
+	// SubscriptionID is the durable subscription the events resume from
+	// after the connection to the event hub is lost, if set
+	SubscriptionID string
+	// MaxAttempts bounds the attempts to publish an event. Backoff is the
+	// wait before the first retry, doubled for each of the next ones up to
+	// MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// QueueSize bounds the events waiting to be published, the events
+	// received while it is full are dropped
+	QueueSize int
+}
+
+// LoadConfig returns the configuration of peer.validator.events.bridge, and
+// the publisher to the broker it configures
+func LoadConfig() (*Config, Publisher, error) {
+	config := &Config{
+		Topics:         make(map[pb.EventType]string),
+		SubscriptionID: viper.GetString("peer.validator.events.bridge.subscriptionID"),
+		MaxAttempts:    viper.GetInt("peer.validator.events.bridge.maxAttempts"),
+		Backoff:        viper.GetDuration("peer.validator.events.bridge.backoff"),
+		MaxBackoff:     viper.GetDuration("peer.validator.events.bridge.maxBackoff"),
+		QueueSize:      viper.GetInt("peer.validator.events.bridge.queueSize"),
+	}
+
+	filter := viper.GetString("peer.validator.events.bridge.filter")
+	for _, name := range viper.GetStringSlice("peer.validator.events.bridge.events") {
+		value, ok := pb.EventType_value[strings.ToUpper(name)]
+		if !ok || value == int32(pb.EventType_REGISTER) {
+			return nil, nil, fmt.Errorf("Invalid bridge event type %s", name)
+		}
+		interest := &pb.Interest{EventType: pb.EventType(value)}
+		if interest.EventType == pb.EventType_CHAINCODE {
+			interest.Filter = filter
+			if filter == "" {
+				interest.Filter = `chaincodeID matches ".*"`
+			}
+		}
+		config.Interests = append(config.Interests, interest)
+		config.Topics[interest.EventType] = viper.GetString("peer.validator.events.bridge.topics." + strings.ToLower(name))
+	}
+
+	var tlsConfig *tls.Config
+	if viper.GetBool("peer.validator.events.bridge.tls.enabled") {
+		tlsConfig = &tls.Config{}
+		if file := viper.GetString("peer.validator.events.bridge.tls.rootcert.file"); file != "" {
+			pem, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error reading the bridge root certificates: %s", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, nil, fmt.Errorf("No certificate found in %s", file)
+			}
+		}
+	}
+
+	var publisher Publisher
+	var err error
+	switch broker := viper.GetString("peer.validator.events.bridge.broker"); broker {
+	case "kafka":
+		publisher, err = NewKafkaProducer(KafkaConfig{
+			Brokers:      viper.GetStringSlice("peer.validator.events.bridge.kafka.brokers"),
+			ClientID:     viper.GetString("peer.validator.events.bridge.kafka.clientID"),
+			RequiredAcks: int16(viper.GetInt("peer.validator.events.bridge.kafka.requiredAcks")),
+			Timeout:      viper.GetDuration("peer.validator.events.bridge.kafka.timeout"),
+			TLS:          tlsConfig,
+		})
+	case "mqtt":
+		publisher, err = NewMQTTClient(MQTTConfig{
+			Broker:    viper.GetString("peer.validator.events.bridge.mqtt.broker"),
+			ClientID:  viper.GetString("peer.validator.events.bridge.mqtt.clientID"),
+			Username:  viper.GetString("peer.validator.events.bridge.mqtt.username"),
+			Password:  viper.GetString("peer.validator.events.bridge.mqtt.password"),
+			QoS:       byte(viper.GetInt("peer.validator.events.bridge.mqtt.qos")),
+			Retain:    viper.GetBool("peer.validator.events.bridge.mqtt.retain"),
+			KeepAlive: viper.GetDuration("peer.validator.events.bridge.mqtt.keepAlive"),
+			Timeout:   viper.GetDuration("peer.validator.events.bridge.mqtt.timeout"),
+			TLS:       tlsConfig,
+		})
+	default:
+		err = fmt.Errorf("Invalid bridge broker %s, expected kafka or mqtt", broker)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, publisher, nil
+}
+
+// Bridge is the event adapter publishing the events
+type Bridge struct {
+	config    Config
+	publisher Publisher
+	queue     chan *pb.Event
+	client    *consumer.EventsClient
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// New returns a bridge publishing the events of config with publisher
+func New(config Config, publisher Publisher) (*Bridge, error) {
+	if len(config.Interests) == 0 {
+		return nil, fmt.Errorf("No bridge event type configured")
+	}
+	for _, ie := range config.Interests {
+		if config.Topics[ie.EventType] == "" {
+			return nil, fmt.Errorf("No bridge topic configured for %s events", ie.EventType)
+		}
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.MaxBackoff < config.Backoff {
+		config.MaxBackoff = config.Backoff
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	return &Bridge{config: config, publisher: publisher, queue: make(chan *pb.Event, config.QueueSize), stop: make(chan struct{})}, nil
+}
+
+// Start connects the bridge to the event hub at peerAddress and publishes the
+// events it receives until Stop is called
+func (b *Bridge) Start(peerAddress string) error {
+	b.client = consumer.NewEventsClient(peerAddress, b)
+	if b.config.SubscriptionID != "" {
+		b.client.SetSubscriptionID(b.config.SubscriptionID)
+	}
+	go b.run()
+	if err := b.client.Start(); err != nil {
+		b.Stop()
+		return fmt.Errorf("Error connecting the bridge to the event hub: %s", err)
+	}
+	bridgeLogger.Info("Publishing events to the bridge broker")
+	return nil
+}
+
+// Stop disconnects the bridge from the event hub and from the broker, the
+// events not published yet are dropped
+func (b *Bridge) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		if b.client != nil {
+			b.client.Stop()
+		}
+	})
+}
+
+func (b *Bridge) stopped() bool {
+	select {
+	case <-b.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetInterestedEvents implements consumer.EventAdapter
+func (b *Bridge) GetInterestedEvents() ([]*pb.Interest, error) {
+	return b.config.Interests, nil
+}
+
+// Recv implements consumer.EventAdapter, queueing the event to be published
+func (b *Bridge) Recv(msg *pb.Event) (bool, error) {
+	select {
+	case b.queue <- msg:
+	default:
+		bridgeLogger.Errorf("Dropping event of block %d, %d events wait to be published", msg.BlockNumber, len(b.queue))
+	}
+	return true, nil
+}
+
+// Disconnected implements consumer.EventAdapter, reconnecting to the event hub
+// until Stop is called
+func (b *Bridge) Disconnected(err error) {
+	if b.stopped() {
+		return
+	}
+	bridgeLogger.Warningf("Bridge disconnected from the event hub (%v), reconnecting", err)
+	go func() {
+		backoff := b.config.Backoff
+		if backoff <= 0 {
+			backoff = time.Second
+		}
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-time.After(backoff):
+			}
+			err := b.client.Start()
+			if err == nil {
+				bridgeLogger.Info("Bridge reconnected to the event hub")
+				return
+			}
+			bridgeLogger.Warningf("Error reconnecting the bridge to the event hub: %s", err)
+			if backoff *= 2; b.config.MaxBackoff > 0 && backoff > b.config.MaxBackoff {
+				backoff = b.config.MaxBackoff
+			}
+		}
+	}()
+}
+
+func (b *Bridge) run() {
+	defer b.publisher.Close()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case e := <-b.queue:
+			b.publish(e)
+		}
+	}
+}
+
+// publish publishes e as JSON to the topic of its type, keyed by the chaincode
+// of the chaincode events
+func (b *Bridge) publish(e *pb.Event) {
+	var eventType pb.EventType
+	var key []byte
+	switch x := e.Event.(type) {
+	case *pb.Event_Block:
+		eventType = pb.EventType_BLOCK
+	case *pb.Event_ChaincodeEvent:
+		eventType, key = pb.EventType_CHAINCODE, []byte(x.ChaincodeEvent.ChaincodeID)
+	case *pb.Event_Rejection:
+		eventType = pb.EventType_REJECTION
+	case *pb.Event_Divergence:
+		eventType = pb.EventType_DIVERGENCE
+	case *pb.Event_Evidence:
+		eventType = pb.EventType_MISBEHAVIOR
+	default:
+		return
+	}
+	topic := b.config.Topics[eventType]
+	if ce := e.GetChaincodeEvent(); ce != nil {
+		topic = strings.NewReplacer("{chaincodeID}", ce.ChaincodeID, "{eventName}", ce.EventName).Replace(topic)
+	}
+	value, err := (&jsonpb.Marshaler{}).MarshalToString(e)
+	if err != nil {
+		bridgeLogger.Errorf("Error marshalling event for the bridge: %s", err)
+		return
+	}
+
+	backoff := b.config.Backoff
+	for attempt := 1; ; attempt++ {
+		err = b.publisher.Publish(topic, key, []byte(value))
+		if err == nil {
+			return
+		}
+		if attempt >= b.config.MaxAttempts {
+			bridgeLogger.Errorf("Giving up publishing %s event of block %d to %s after %d attempts: %s", eventType, e.BlockNumber, topic, attempt, err)
+			return
+		}
+		bridgeLogger.Warningf("Error publishing %s event to %s, attempt %d of %d: %s", eventType, topic, attempt, b.config.MaxAttempts, err)
+		select {
+		case <-b.stop:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > b.config.MaxBackoff {
+			backoff = b.config.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type published struct {
+	topic, key, value string
+}
+
+// mockPublisher records the messages it publishes, after failing the first
+// failures publications
+type mockPublisher struct {
+	sync.Mutex
+	failures  int
+	attempts  int
+	published []published
+	done      chan struct{}
+}
+
+func newMockPublisher(failures int) *mockPublisher {
+	return &mockPublisher{failures: failures, done: make(chan struct{}, 10)}
+}
+
+func (p *mockPublisher) Publish(topic string, key []byte, value []byte) error {
+	p.Lock()
+	defer p.Unlock()
+	p.attempts++
+	if p.attempts <= p.failures {
+		return fmt.Errorf("broker unavailable")
+	}
+	p.published = append(p.published, published{topic, string(key), string(value)})
+	p.done <- struct{}{}
+	return nil
+}
+
+func (p *mockPublisher) Close() error {
+	return nil
+}
+
+func (p *mockPublisher) wait(t *testing.T) {
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a publication")
+	}
+}
+
+func testConfig() Config {
+	return Config{
+		Interests: []*pb.Interest{{EventType: pb.EventType_BLOCK}, {EventType: pb.EventType_CHAINCODE, Filter: `chaincodeID matches ".*"`}},
+		Topics: map[pb.EventType]string{
+			pb.EventType_BLOCK:     "fabric-blocks",
+			pb.EventType_CHAINCODE: "fabric/{chaincodeID}/{eventName}",
+		},
+		Backoff: time.Millisecond,
+	}
+}
+
+func TestPublish(t *testing.T) {
+	p := newMockPublisher(0)
+	b, err := New(testConfig(), p)
+	if err != nil {
+		t.Fatalf("Error creating bridge: %s", err)
+	}
+	go b.run()
+	defer b.Stop()
+
+	events := []*pb.Event{
+		{Event: &pb.Event_Block{Block: &pb.Block{}}, BlockNumber: 3},
+		{Event: &pb.Event_ChaincodeEvent{ChaincodeEvent: &pb.ChaincodeEvent{ChaincodeID: "mycc", EventName: "transfer"}}, BlockNumber: 3},
+	}
+	for _, e := range events {
+		b.Recv(e)
+		p.wait(t)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	expected := []published{{"fabric-blocks", "", ""}, {"fabric/mycc/transfer", "mycc", ""}}
+	for i := range expected {
+		if p.published[i].topic != expected[i].topic || p.published[i].key != expected[i].key {
+			t.Errorf("Expected event %d published to %s with key %q, got %s and %q", i, expected[i].topic, expected[i].key, p.published[i].topic, p.published[i].key)
+		}
+		e := &pb.Event{}
+		if err := jsonpb.UnmarshalString(p.published[i].value, e); err != nil || !proto.Equal(e, events[i]) {
+			t.Errorf("Expected event %v, got %s (%v)", events[i], p.published[i].value, err)
+		}
+	}
+}
+
+func TestPublishRetry(t *testing.T) {
+	p := newMockPublisher(2)
+	config := testConfig()
+	config.MaxAttempts = 3
+	b, _ := New(config, p)
+	go b.run()
+	defer b.Stop()
+
+	b.Recv(&pb.Event{Event: &pb.Event_Block{Block: &pb.Block{}}})
+	p.wait(t)
+	p.Lock()
+	defer p.Unlock()
+	if p.attempts != 3 || len(p.published) != 1 {
+		t.Errorf("Expected the event to be published on the third attempt, got %d attempts", p.attempts)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	if _, err := New(Config{}, newMockPublisher(0)); err == nil {
+		t.Errorf("Expected an error without event types")
+	}
+	config := testConfig()
+	delete(config.Topics, pb.EventType_CHAINCODE)
+	if _, err := New(config, newMockPublisher(0)); err == nil {
+		t.Errorf("Expected an error without the topic of the chaincode events")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	viper.Set("peer.validator.events.bridge.events", []string{"block", "chaincode"})
+	viper.Set("peer.validator.events.bridge.topics.block", "blocks")
+	viper.Set("peer.validator.events.bridge.topics.chaincode", "events")
+	viper.Set("peer.validator.events.bridge.filter", "chaincodeID == mycc")
+	viper.Set("peer.validator.events.bridge.broker", "mqtt")
+	viper.Set("peer.validator.events.bridge.mqtt.broker", "tcp://localhost:1883")
+	defer viper.Reset()
+
+	config, publisher, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Error loading config: %s", err)
+	}
+	defer publisher.Close()
+	if len(config.Interests) != 2 || config.Interests[1].Filter != "chaincodeID == mycc" {
+		t.Errorf("Expected block and filtered chaincode interests, got %v", config.Interests)
+	}
+	if config.Topics[pb.EventType_BLOCK] != "blocks" || config.Topics[pb.EventType_CHAINCODE] != "events" {
+		t.Errorf("Expected the configured topics, got %v", config.Topics)
+	}
+	if _, ok := publisher.(*mqttClient); !ok {
+		t.Errorf("Expected an MQTT client, got %T", publisher)
+	}
+
+	viper.Set("peer.validator.events.bridge.broker", "amqp")
+	if _, _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an unknown broker to be rejected")
+	}
+}
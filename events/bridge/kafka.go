@@ -0,0 +1,410 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kafka producer speaking version 0 of the Metadata and Produce requests of
+// the Kafka protocol, which all brokers support. Messages with a key go to
+// the partition of the hash of the key, so that the messages of a key stay in
+// order, and messages without one to partition 0.
+
+const (
+	kafkaProduceKey  = 0
+	kafkaMetadataKey = 3
+
+	// kafkaMaxPartitions bounds the partition ids accepted in the metadata
+	kafkaMaxPartitions = 1 << 16
+)
+
+// KafkaConfig is the configuration of a Kafka producer
+type KafkaConfig struct {
+	// Brokers are the host:port addresses of the brokers the metadata of the
+	// topics is requested from
+	Brokers  []string
+	ClientID string
+	// RequiredAcks is the number of replicas which must acknowledge a
+	// message, -1 for all the in sync replicas
+	RequiredAcks int16
+	Timeout      time.Duration
+	// TLS configures the connections to the brokers, plain TCP if nil
+	TLS *tls.Config
+}
+
+type kafkaPartitions struct {
+	leaders []int32 // leader of each partition
+}
+
+// kafkaProducer publishes messages to Kafka topics. Connections to the
+// leaders are opened as needed, and closed along with the metadata on error,
+// so that the next message reconnects to the current leader.
+type kafkaProducer struct {
+	sync.Mutex
+	config        KafkaConfig
+	correlationID int32
+	brokers       map[int32]string // node id to address
+	topics        map[string]*kafkaPartitions
+	conns         map[int32]*kafkaConn
+}
+
+type kafkaConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewKafkaProducer returns a producer publishing to the brokers of config
+func NewKafkaProducer(config KafkaConfig) (Publisher, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("No Kafka broker configured")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &kafkaProducer{config: config, conns: make(map[int32]*kafkaConn)}, nil
+}
+
+func (p *kafkaProducer) Publish(topic string, key []byte, value []byte) error {
+	p.Lock()
+	defer p.Unlock()
+	err := p.produce(topic, key, value)
+	if err != nil {
+		p.reset()
+	}
+	return err
+}
+
+func (p *kafkaProducer) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	p.reset()
+	return nil
+}
+
+func (p *kafkaProducer) reset() {
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = make(map[int32]*kafkaConn)
+	p.brokers, p.topics = nil, nil
+}
+
+func (p *kafkaProducer) produce(topic string, key []byte, value []byte) error {
+	partitions, err := p.partitions(topic)
+	if err != nil {
+		return err
+	}
+	partition := int32(0)
+	if key != nil {
+		partition = int32(crc32.ChecksumIEEE(key) % uint32(len(partitions.leaders)))
+	}
+	leader := partitions.leaders[partition]
+	conn, err := p.conn(leader)
+	if err != nil {
+		return err
+	}
+
+	var req kafkaEncoder
+	req.int16(p.config.RequiredAcks)
+	req.int32(int32(p.config.Timeout / time.Millisecond))
+	req.int32(1)
+	req.string(topic)
+	req.int32(1)
+	req.int32(partition)
+	set := kafkaMessageSet(key, value)
+	req.int32(int32(len(set)))
+	req.buf.Write(set)
+	if p.config.RequiredAcks == 0 {
+		// the broker does not respond
+		_, err = p.send(conn, kafkaProduceKey, req.buf.Bytes())
+		return err
+	}
+	resp, err := p.roundTrip(conn, kafkaProduceKey, req.buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	d := &kafkaDecoder{buf: resp}
+	for topics := d.int32(); topics > 0 && d.err == nil; topics-- {
+		d.string()
+		for n := d.int32(); n > 0 && d.err == nil; n-- {
+			d.int32()
+			if code := d.int16(); code != 0 && d.err == nil {
+				return fmt.Errorf("Kafka error %d producing to %s partition %d", code, topic, partition)
+			}
+			d.int64()
+		}
+	}
+	return d.err
+}
+
+// partitions returns the leaders of the partitions of topic, requesting the
+// metadata from the brokers if unknown
+func (p *kafkaProducer) partitions(topic string) (*kafkaPartitions, error) {
+	if partitions, ok := p.topics[topic]; ok {
+		return partitions, nil
+	}
+
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(topic)
+	var lastErr error
+	for _, address := range p.config.Brokers {
+		conn, err := p.dial(address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := p.roundTrip(conn, kafkaMetadataKey, req.buf.Bytes())
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err = p.parseMetadata(resp); err != nil {
+			return nil, err
+		}
+		partitions, ok := p.topics[topic]
+		if !ok {
+			return nil, fmt.Errorf("Kafka topic %s not found", topic)
+		}
+		return partitions, nil
+	}
+	return nil, fmt.Errorf("Error getting the metadata of Kafka topic %s: %s", topic, lastErr)
+}
+
+func (p *kafkaProducer) parseMetadata(resp []byte) error {
+	d := &kafkaDecoder{buf: resp}
+	brokers := make(map[int32]string)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id, host, port := d.int32(), d.string(), d.int32()
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	topics := make(map[string]*kafkaPartitions)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code, topic := d.int16(), d.string()
+		partitions := &kafkaPartitions{}
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int16()
+			id, leader := d.int32(), d.int32()
+			for r := d.int32(); r > 0 && d.err == nil; r-- {
+				d.int32()
+			}
+			for r := d.int32(); r > 0 && d.err == nil; r-- {
+				d.int32()
+			}
+			if id < 0 || id >= kafkaMaxPartitions {
+				d.err = fmt.Errorf("invalid partition %d", id)
+				break
+			}
+			for int(id) >= len(partitions.leaders) {
+				partitions.leaders = append(partitions.leaders, -1)
+			}
+			partitions.leaders[id] = leader
+		}
+		if d.err != nil {
+			break
+		}
+		if code != 0 {
+			return fmt.Errorf("Kafka error %d getting the metadata of topic %s", code, topic)
+		}
+		for id, leader := range partitions.leaders {
+			if leader < 0 {
+				return fmt.Errorf("Kafka topic %s partition %d has no leader", topic, id)
+			}
+		}
+		if len(partitions.leaders) == 0 {
+			return fmt.Errorf("Kafka topic %s has no partition", topic)
+		}
+		topics[topic] = partitions
+	}
+	if d.err != nil {
+		return fmt.Errorf("Invalid Kafka metadata response: %s", d.err)
+	}
+	p.brokers, p.topics = brokers, topics
+	return nil
+}
+
+func (p *kafkaProducer) conn(id int32) (*kafkaConn, error) {
+	if conn, ok := p.conns[id]; ok {
+		return conn, nil
+	}
+	address, ok := p.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown Kafka broker %d", id)
+	}
+	conn, err := p.dial(address)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[id] = conn
+	return conn, nil
+}
+
+func (p *kafkaProducer) dial(address string) (*kafkaConn, error) {
+	dialer := &net.Dialer{Timeout: p.config.Timeout}
+	var conn net.Conn
+	var err error
+	if p.config.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, p.config.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// send writes the request with its header, and returns its correlation id
+func (p *kafkaProducer) send(conn *kafkaConn, apiKey int16, body []byte) (int32, error) {
+	p.correlationID++
+	var header kafkaEncoder
+	header.int16(apiKey)
+	header.int16(0)
+	header.int32(p.correlationID)
+	header.string(p.config.ClientID)
+
+	var req kafkaEncoder
+	req.int32(int32(header.buf.Len() + len(body)))
+	req.buf.Write(header.buf.Bytes())
+	req.buf.Write(body)
+	conn.SetDeadline(time.Now().Add(p.config.Timeout))
+	_, err := conn.Write(req.buf.Bytes())
+	return p.correlationID, err
+}
+
+// roundTrip sends the request and returns the body of its response
+func (p *kafkaProducer) roundTrip(conn *kafkaConn, apiKey int16, body []byte) ([]byte, error) {
+	correlationID, err := p.send(conn, apiKey, body)
+	if err != nil {
+		return nil, err
+	}
+	var size int32
+	if err = binary.Read(conn.r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 4 {
+		return nil, fmt.Errorf("Invalid Kafka response size %d", size)
+	}
+	resp := make([]byte, size)
+	if _, err = io.ReadFull(conn.r, resp); err != nil {
+		return nil, err
+	}
+	if id := int32(binary.BigEndian.Uint32(resp)); id != correlationID {
+		return nil, fmt.Errorf("Kafka response to request %d instead of %d", id, correlationID)
+	}
+	return resp[4:], nil
+}
+
+// kafkaMessageSet encodes a message set of one version 0 message
+func kafkaMessageSet(key []byte, value []byte) []byte {
+	var msg kafkaEncoder
+	msg.int8(0) // magic
+	msg.int8(0) // attributes, no compression
+	msg.bytes(key)
+	msg.bytes(value)
+
+	var set kafkaEncoder
+	set.int64(0) // offset, set by the broker
+	set.int32(int32(4 + msg.buf.Len()))
+	set.int32(int32(crc32.ChecksumIEEE(msg.buf.Bytes())))
+	set.buf.Write(msg.buf.Bytes())
+	return set.buf.Bytes()
+}
+
+type kafkaEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.buf.WriteByte(byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { binary.Write(&e.buf, binary.BigEndian, v) }
+func (e *kafkaEncoder) int32(v int32) { binary.Write(&e.buf, binary.BigEndian, v) }
+func (e *kafkaEncoder) int64(v int64) { binary.Write(&e.buf, binary.BigEndian, v) }
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.buf.Write(b)
+}
+
+// kafkaDecoder decodes a response, recording the first error
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
@@ -0,0 +1,203 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type kafkaMessage struct {
+	topic      string
+	partition  int32
+	key, value string
+}
+
+// fakeKafka is a broker leading the two partitions of every topic, which
+// answers the produce requests with errorCode
+type fakeKafka struct {
+	sync.Mutex
+	listener  net.Listener
+	errorCode int16
+	metadata  int
+	messages  []kafkaMessage
+}
+
+func newFakeKafka(t *testing.T) *fakeKafka {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	k := &fakeKafka{listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go k.serve(conn)
+		}
+	}()
+	return k
+}
+
+func (k *fakeKafka) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var size int32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		req := make([]byte, size)
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := &kafkaDecoder{buf: req}
+		apiKey, _, correlationID, _ := d.int16(), d.int16(), d.int32(), d.string()
+
+		var resp kafkaEncoder
+		resp.int32(correlationID)
+		switch apiKey {
+		case kafkaMetadataKey:
+			k.Lock()
+			k.metadata++
+			k.Unlock()
+			host, port, _ := net.SplitHostPort(k.listener.Addr().String())
+			portNumber, _ := strconv.Atoi(port)
+			resp.int32(1)
+			resp.int32(0)
+			resp.string(host)
+			resp.int32(int32(portNumber))
+			topics := d.int32()
+			resp.int32(topics)
+			for ; topics > 0; topics-- {
+				resp.int16(0)
+				resp.string(d.string())
+				resp.int32(2)
+				for partition := int32(0); partition < 2; partition++ {
+					resp.int16(0)
+					resp.int32(partition)
+					resp.int32(0)
+					resp.int32(1)
+					resp.int32(0)
+					resp.int32(1)
+					resp.int32(0)
+				}
+			}
+		case kafkaProduceKey:
+			d.int16()
+			d.int32()
+			d.int32()
+			topic := d.string()
+			d.int32()
+			partition := d.int32()
+			set := &kafkaDecoder{buf: d.take(int(d.int32()))}
+			set.int64()
+			msg := set.take(int(set.int32()))
+			m := &kafkaDecoder{buf: msg[4:]}
+			m.take(2)
+			key, value := kafkaBytes(m), kafkaBytes(m)
+			errorCode := k.errorCode
+			if crc := binary.BigEndian.Uint32(msg); crc != crc32.ChecksumIEEE(msg[4:]) || m.err != nil {
+				errorCode = 2 // corrupt message
+			}
+			k.Lock()
+			k.messages = append(k.messages, kafkaMessage{topic, partition, string(key), string(value)})
+			k.Unlock()
+			resp.int32(1)
+			resp.string(topic)
+			resp.int32(1)
+			resp.int32(partition)
+			resp.int16(errorCode)
+			resp.int64(0)
+		}
+		var framed kafkaEncoder
+		framed.int32(int32(resp.buf.Len()))
+		framed.buf.Write(resp.buf.Bytes())
+		conn.Write(framed.buf.Bytes())
+	}
+}
+
+// kafkaBytes decodes bytes, which are null if their length is -1
+func kafkaBytes(d *kafkaDecoder) []byte {
+	if n := d.int32(); n >= 0 {
+		return d.take(int(n))
+	}
+	return nil
+}
+
+func TestKafkaProducer(t *testing.T) {
+	k := newFakeKafka(t)
+	defer k.listener.Close()
+	p, err := NewKafkaProducer(KafkaConfig{Brokers: []string{k.listener.Addr().String()}, ClientID: "test", RequiredAcks: 1})
+	if err != nil {
+		t.Fatalf("Error creating producer: %s", err)
+	}
+	defer p.Close()
+
+	if err = p.Publish("blocks", nil, []byte("block")); err != nil {
+		t.Fatalf("Error publishing: %s", err)
+	}
+	if err = p.Publish("events", []byte("mycc"), []byte("event")); err != nil {
+		t.Fatalf("Error publishing: %s", err)
+	}
+
+	k.Lock()
+	defer k.Unlock()
+	expected := []kafkaMessage{
+		{"blocks", 0, "", "block"},
+		{"events", int32(crc32.ChecksumIEEE([]byte("mycc")) % 2), "mycc", "event"},
+	}
+	if len(k.messages) != len(expected) {
+		t.Fatalf("Expected messages %v, got %v", expected, k.messages)
+	}
+	for i := range expected {
+		if k.messages[i] != expected[i] {
+			t.Errorf("Expected message %v, got %v", expected[i], k.messages[i])
+		}
+	}
+}
+
+func TestKafkaProducerError(t *testing.T) {
+	k := newFakeKafka(t)
+	defer k.listener.Close()
+	k.errorCode = 6 // not the leader of the partition
+	p, _ := NewKafkaProducer(KafkaConfig{Brokers: []string{k.listener.Addr().String()}, RequiredAcks: 1})
+	defer p.Close()
+
+	if err := p.Publish("blocks", nil, []byte("block")); err == nil {
+		t.Fatalf("Expected the error of the broker to be returned")
+	}
+	k.Lock()
+	k.errorCode = 0
+	k.Unlock()
+	if err := p.Publish("blocks", nil, []byte("block")); err != nil {
+		t.Fatalf("Error publishing: %s", err)
+	}
+	k.Lock()
+	defer k.Unlock()
+	if k.metadata != 2 {
+		t.Errorf("Expected the metadata to be requested again after the error, got %d requests", k.metadata)
+	}
+}
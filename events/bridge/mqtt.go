@@ -0,0 +1,316 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MQTT client speaking version 3.1.1 of the protocol, which connects to the
+// broker when publishing and publishes at QoS 0 or 1. At QoS 1, a message is
+// published once the broker acknowledged it.
+
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttPingreq    = 12
+	mqttPingresp   = 13
+	mqttDisconnect = 14
+)
+
+// MQTTConfig is the configuration of an MQTT client
+type MQTTConfig struct {
+	// Broker is the URL of the broker, tcp://host:port or ssl://host:port
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// QoS is the quality of service of the messages, 0 or 1
+	QoS    byte
+	Retain bool
+	// KeepAlive is the period of the pings keeping the connection open, no
+	// pings being sent if 0
+	KeepAlive time.Duration
+	Timeout   time.Duration
+	// TLS configures the ssl connections
+	TLS *tls.Config
+}
+
+type mqttClient struct {
+	sync.Mutex
+	config   MQTTConfig
+	address  string
+	secure   bool
+	conn     net.Conn
+	r        *bufio.Reader
+	packetID uint16
+	lastSent time.Time
+	done     chan struct{}
+}
+
+// NewMQTTClient returns a client publishing to the broker of config
+func NewMQTTClient(config MQTTConfig) (Publisher, error) {
+	u, err := url.Parse(config.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid MQTT broker %s: %s", config.Broker, err)
+	}
+	c := &mqttClient{config: config, address: u.Host, done: make(chan struct{})}
+	switch u.Scheme {
+	case "tcp":
+	case "ssl", "tls":
+		c.secure = true
+	default:
+		return nil, fmt.Errorf("Invalid MQTT broker %s, expected a tcp:// or ssl:// URL", config.Broker)
+	}
+	if config.QoS > 1 {
+		return nil, fmt.Errorf("Unsupported MQTT QoS %d, expected 0 or 1", config.QoS)
+	}
+	if c.config.Timeout <= 0 {
+		c.config.Timeout = 10 * time.Second
+	}
+	if config.KeepAlive > 0 {
+		go c.keepAlive()
+	}
+	return c, nil
+}
+
+func (c *mqttClient) Publish(topic string, key []byte, value []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	err := c.publish(topic, value)
+	if err != nil {
+		c.disconnect()
+	}
+	return err
+}
+
+func (c *mqttClient) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+	if c.conn != nil {
+		c.conn.Write([]byte{mqttDisconnect << 4, 0})
+		c.disconnect()
+	}
+	return nil
+}
+
+func (c *mqttClient) disconnect() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+}
+
+func (c *mqttClient) publish(topic string, value []byte) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mqttString(&body, topic)
+	flags := c.config.QoS << 1
+	if c.config.Retain {
+		flags |= 1
+	}
+	var id uint16
+	if c.config.QoS > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		id = c.packetID
+		binary.Write(&body, binary.BigEndian, id)
+	}
+	body.Write(value)
+	if err := c.write(mqttPublish<<4|flags, body.Bytes()); err != nil {
+		return err
+	}
+	if c.config.QoS == 0 {
+		return nil
+	}
+
+	for {
+		typ, resp, err := c.read()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case mqttPuback:
+			if len(resp) != 2 || binary.BigEndian.Uint16(resp) != id {
+				return fmt.Errorf("Unexpected MQTT acknowledgement %v of message %d", resp, id)
+			}
+			return nil
+		case mqttPingresp:
+		default:
+			return fmt.Errorf("Unexpected MQTT packet type %d", typ)
+		}
+	}
+}
+
+func (c *mqttClient) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+	dialer := &net.Dialer{Timeout: c.config.Timeout}
+	var conn net.Conn
+	var err error
+	if c.secure {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.address, c.config.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", c.address)
+	}
+	if err != nil {
+		return err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+
+	var body bytes.Buffer
+	mqttString(&body, "MQTT")
+	body.WriteByte(4)   // protocol level 3.1.1
+	flags := byte(0x02) // clean session
+	if c.config.Username != "" {
+		flags |= 0x80
+		if c.config.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body.WriteByte(flags)
+	binary.Write(&body, binary.BigEndian, uint16(c.config.KeepAlive/time.Second))
+	mqttString(&body, c.config.ClientID)
+	if c.config.Username != "" {
+		mqttString(&body, c.config.Username)
+		if c.config.Password != "" {
+			mqttString(&body, c.config.Password)
+		}
+	}
+	if err = c.write(mqttConnect<<4, body.Bytes()); err != nil {
+		c.disconnect()
+		return err
+	}
+	typ, resp, err := c.read()
+	if err == nil && (typ != mqttConnack || len(resp) != 2) {
+		err = fmt.Errorf("Unexpected MQTT packet type %d instead of CONNACK", typ)
+	}
+	if err == nil && resp[1] != 0 {
+		err = fmt.Errorf("MQTT broker refused the connection with code %d", resp[1])
+	}
+	if err != nil {
+		c.disconnect()
+		return err
+	}
+	return nil
+}
+
+// keepAlive pings the broker when nothing was sent for half the keep alive
+// period, until the client is closed
+func (c *mqttClient) keepAlive() {
+	ticker := time.NewTicker(c.config.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		c.Lock()
+		if c.conn != nil && time.Since(c.lastSent) >= c.config.KeepAlive/2 {
+			err := c.write(mqttPingreq<<4, nil)
+			if err == nil {
+				var typ byte
+				typ, _, err = c.read()
+				if err == nil && typ != mqttPingresp {
+					err = fmt.Errorf("Unexpected MQTT packet type %d instead of PINGRESP", typ)
+				}
+			}
+			if err != nil {
+				bridgeLogger.Warningf("Error pinging MQTT broker %s: %s", c.config.Broker, err)
+				c.disconnect()
+			}
+		}
+		c.Unlock()
+	}
+}
+
+func (c *mqttClient) write(header byte, body []byte) error {
+	var packet bytes.Buffer
+	packet.WriteByte(header)
+	// remaining length, 7 bits per byte
+	for n := len(body); ; {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		packet.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	packet.Write(body)
+	c.conn.SetDeadline(time.Now().Add(c.config.Timeout))
+	_, err := c.conn.Write(packet.Bytes())
+	c.lastSent = time.Now()
+	return err
+}
+
+func (c *mqttClient) read() (byte, []byte, error) {
+	c.conn.SetDeadline(time.Now().Add(c.config.Timeout))
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		if i == 4 {
+			return 0, nil, fmt.Errorf("Invalid MQTT remaining length")
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func mqttString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bridge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mqttMessage struct {
+	topic, payload string
+	qos            byte
+	retain         bool
+}
+
+// fakeMQTT is a broker accepting the connections with returnCode, and
+// acknowledging the messages published at QoS 1
+type fakeMQTT struct {
+	sync.Mutex
+	listener   net.Listener
+	returnCode byte
+	connects   []string
+	messages   []mqttMessage
+}
+
+func newFakeMQTT(t *testing.T) *fakeMQTT {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	b := &fakeMQTT{listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeMQTT) serve(conn net.Conn) {
+	defer conn.Close()
+	// the packets are framed the same way in both directions
+	c := &mqttClient{config: MQTTConfig{Timeout: 5 * time.Second}, conn: conn, r: bufio.NewReader(conn)}
+	for {
+		// read drops the flags of the fixed header
+		header, err := c.r.Peek(1)
+		if err != nil {
+			return
+		}
+		flags := header[0] & 0x0f
+		typ, body, err := c.read()
+		if err != nil {
+			return
+		}
+		switch typ {
+		case mqttConnect:
+			// protocol name, level, flags and keep alive precede the client ID
+			id := body[10:]
+			b.Lock()
+			b.connects = append(b.connects, string(id[2:2+binary.BigEndian.Uint16(id)]))
+			b.Unlock()
+			c.write(mqttConnack<<4, []byte{0, b.returnCode})
+		case mqttPublish:
+			n := binary.BigEndian.Uint16(body)
+			msg := mqttMessage{topic: string(body[2 : 2+n]), qos: flags >> 1 & 3, retain: flags&1 == 1}
+			body = body[2+n:]
+			if msg.qos > 0 {
+				c.write(mqttPuback<<4, body[:2])
+				body = body[2:]
+			}
+			msg.payload = string(body)
+			b.Lock()
+			b.messages = append(b.messages, msg)
+			b.Unlock()
+		case mqttPingreq:
+			c.write(mqttPingresp<<4, nil)
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+func TestMQTTClient(t *testing.T) {
+	b := newFakeMQTT(t)
+	defer b.listener.Close()
+	c, err := NewMQTTClient(MQTTConfig{Broker: "tcp://" + b.listener.Addr().String(), ClientID: "peer", QoS: 1, Retain: true, KeepAlive: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	defer c.Close()
+
+	for _, payload := range []string{"first", "second"} {
+		if err = c.Publish("fabric/blocks", nil, []byte(payload)); err != nil {
+			t.Fatalf("Error publishing: %s", err)
+		}
+	}
+	// the keep alive pings must not get in the way of the acknowledgements
+	time.Sleep(300 * time.Millisecond)
+	if err = c.Publish("fabric/blocks", nil, []byte("third")); err != nil {
+		t.Fatalf("Error publishing after a ping: %s", err)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if len(b.connects) != 1 || b.connects[0] != "peer" {
+		t.Errorf("Expected a single connection of client peer, got %v", b.connects)
+	}
+	if len(b.messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %v", b.messages)
+	}
+	for i, payload := range []string{"first", "second", "third"} {
+		expected := mqttMessage{"fabric/blocks", payload, 1, true}
+		if b.messages[i] != expected {
+			t.Errorf("Expected message %v, got %v", expected, b.messages[i])
+		}
+	}
+}
+
+func TestMQTTClientRefused(t *testing.T) {
+	b := newFakeMQTT(t)
+	defer b.listener.Close()
+	b.returnCode = 5 // not authorized
+	c, _ := NewMQTTClient(MQTTConfig{Broker: "tcp://" + b.listener.Addr().String()})
+	defer c.Close()
+	if err := c.Publish("fabric/blocks", nil, []byte("block")); err == nil {
+		t.Fatalf("Expected the refused connection to fail the publication")
+	}
+
+	for _, config := range []MQTTConfig{{Broker: "http://localhost:1883"}, {Broker: "tcp://localhost:1883", QoS: 2}} {
+		if _, err := NewMQTTClient(config); err == nil {
+			t.Errorf("Expected an error creating a client with %+v", config)
+		}
+	}
+}
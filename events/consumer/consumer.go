@@ -33,11 +33,29 @@ type EventsClient struct {
 	peerAddress string
 	stream      ehpb.Events_ChatClient
 	adapter     EventAdapter
+
+	subscriptionID string
+	replay         bool
+	fromBlock      uint64
 }
 
 //NewEventsClient Returns a new grpc.ClientConn to the configured local PEER.
 func NewEventsClient(peerAddress string, adapter EventAdapter) *EventsClient {
-	return &EventsClient{peerAddress, nil, adapter}
+	return &EventsClient{peerAddress: peerAddress, adapter: adapter}
+}
+
+//SetSubscriptionID registers the client as the durable subscription id, so
+//that the peer replays the events it missed since the last one delivered to it
+//when it starts again, e.g. after it was disconnected. The events of the block
+//of the last event delivered are delivered again.
+func (ec *EventsClient) SetSubscriptionID(id string) {
+	ec.subscriptionID = id
+}
+
+//ReplayFrom has the peer replay the events of the blocks committed from
+//blockNumber on the next Start, before the live events
+func (ec *EventsClient) ReplayFrom(blockNumber uint64) {
+	ec.replay, ec.fromBlock = true, blockNumber
 }
 
 //newEventsClientConnectionWithAddress Returns a new grpc.ClientConn to the configured local PEER.
@@ -49,7 +67,7 @@ func newEventsClientConnectionWithAddress(peerAddress string) (*grpc.ClientConn,
 }
 
 func (ec *EventsClient) register(ies []*ehpb.Interest) error {
-	emsg := &ehpb.Event{Event: &ehpb.Event_Register{Register: &ehpb.Register{Events: ies}}}
+	emsg := &ehpb.Event{Event: &ehpb.Event_Register{Register: &ehpb.Register{Events: ies, Replay: ec.replay, FromBlock: ec.fromBlock, SubscriptionID: ec.subscriptionID}}}
 	var err error
 	if err = ec.stream.Send(emsg); err != nil {
 		fmt.Printf("error on Register send %s\n", err)
@@ -131,6 +149,8 @@ func (ec *EventsClient) Start() error {
 	if err = ec.register(ies); err != nil {
 		return err
 	}
+	//replay once, the next Start resumes the durable subscription if any
+	ec.replay, ec.fromBlock = false, 0
 
 	go ec.processEvents()
 
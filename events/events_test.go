@@ -17,15 +17,20 @@ limitations under the License.
 package events
 
 import (
+	"bytes"
 	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/events/consumer"
 	"github.com/hyperledger/fabric/events/producer"
+	"github.com/hyperledger/fabric/events/webhook"
 	ehpb "github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
@@ -163,6 +168,300 @@ func TestFailReceive(t *testing.T) {
 	}
 }
 
+//replayAdapter collects the events it receives
+type replayAdapter struct {
+	interests    []*ehpb.Interest
+	events       chan *ehpb.Event
+	disconnected chan struct{}
+}
+
+func newReplayAdapter(interests ...*ehpb.Interest) *replayAdapter {
+	return &replayAdapter{interests: interests, events: make(chan *ehpb.Event, 100), disconnected: make(chan struct{}, 1)}
+}
+
+func (a *replayAdapter) GetInterestedEvents() ([]*ehpb.Interest, error) {
+	return a.interests, nil
+}
+
+func (a *replayAdapter) Recv(msg *ehpb.Event) (bool, error) {
+	a.events <- msg
+	return true, nil
+}
+
+func (a *replayAdapter) Disconnected(err error) {
+	a.disconnected <- struct{}{}
+}
+
+//expect checks that the next events received are the expected ones, described
+//as type:block
+func (a *replayAdapter) expect(t *testing.T, expected ...string) {
+	for _, exp := range expected {
+		select {
+		case e := <-a.events:
+			var typ string
+			switch {
+			case e.GetBlock() != nil:
+				typ = "block"
+			case e.GetChaincodeEvent() != nil:
+				typ = "chaincode"
+			case e.GetRejection() != nil:
+				typ = "rejection"
+			default:
+				typ = fmt.Sprintf("%T", e.Event)
+			}
+			if got := fmt.Sprintf("%s:%d", typ, e.BlockNumber); got != exp {
+				t.Fatalf("Expected event %s, got %s", exp, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for event %s", exp)
+		}
+	}
+	select {
+	case e := <-a.events:
+		t.Fatalf("Expected no more events, got %v", e)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+//mockLedger is a blockchain whose blocks each have a transaction setting an
+//event of chaincode replaycc and a failed transaction
+type mockLedger struct {
+	sync.Mutex
+	blocks []*ehpb.Block
+}
+
+func (l *mockLedger) GetBlockchainSize() uint64 {
+	l.Lock()
+	defer l.Unlock()
+	return uint64(len(l.blocks))
+}
+
+func (l *mockLedger) GetBlockByNumber(blockNumber uint64) (*ehpb.Block, error) {
+	l.Lock()
+	defer l.Unlock()
+	if blockNumber >= uint64(len(l.blocks)) {
+		return nil, fmt.Errorf("block %d does not exist", blockNumber)
+	}
+	return l.blocks[blockNumber], nil
+}
+
+//commit adds a block and returns its events, to be sent as the ledger does
+func (l *mockLedger) commit() []*ehpb.Event {
+	l.Lock()
+	n := uint64(len(l.blocks))
+	ok, failed := fmt.Sprintf("tx%d", n), fmt.Sprintf("failedtx%d", n)
+	deploy, _ := proto.Marshal(&ehpb.ChaincodeDeploymentSpec{CodePackage: []byte("code")})
+	block := &ehpb.Block{
+		Transactions: []*ehpb.Transaction{
+			{Uuid: ok, Type: ehpb.Transaction_CHAINCODE_DEPLOY, Payload: deploy},
+			{Uuid: failed, Type: ehpb.Transaction_CHAINCODE_INVOKE},
+		},
+		NonHashData: &ehpb.NonHashData{TransactionResults: []*ehpb.TransactionResult{
+			{Uuid: ok, ChaincodeEvent: &ehpb.ChaincodeEvent{ChaincodeID: "replaycc", TxID: ok, EventName: "event1"}},
+			{Uuid: failed, ErrorCode: 1, Error: "failed", Phase: ehpb.RejectionPhase_EXECUTION, Reason: ehpb.RejectionReason_CHAINCODE_ERROR},
+		}},
+	}
+	l.blocks = append(l.blocks, block)
+	l.Unlock()
+	return producer.CreateBlockEvents(block, n)
+}
+
+var replayLedger = &mockLedger{}
+
+var replayInterests = []*ehpb.Interest{
+	{EventType: ehpb.EventType_CHAINCODE, RegInfo: &ehpb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: &ehpb.ChaincodeReg{ChaincodeID: "replaycc"}}},
+	{EventType: ehpb.EventType_REJECTION},
+}
+
+//sendLive sends the chaincode and rejection events of a block, leaving out
+//the block event which the other consumers of the tests register for
+func sendLive(t *testing.T, events []*ehpb.Event) {
+	for _, e := range events[1:] {
+		if err := producer.Send(e); err != nil {
+			t.Fatalf("Error sending event: %s", err)
+		}
+	}
+}
+
+func TestCreateBlockEvents(t *testing.T) {
+	events := (&mockLedger{}).commit()
+	if len(events) != 3 || events[0].GetBlock() == nil || events[1].GetChaincodeEvent() == nil || events[2].GetRejection() == nil {
+		t.Fatalf("Expected a block, a chaincode and a rejection event, got %v", events)
+	}
+	spec := &ehpb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(events[0].GetBlock().Transactions[0].Payload, spec); err != nil || spec.CodePackage != nil {
+		t.Fatalf("Expected the code package to be stripped from the block event, got %v (%v)", spec, err)
+	}
+	if rejection := events[2].GetRejection(); rejection.Tx.Uuid != "failedtx0" || rejection.TxID != "failedtx0" || rejection.ErrorMsg != "failed" ||
+		rejection.Phase != ehpb.RejectionPhase_EXECUTION || rejection.Reason != ehpb.RejectionReason_CHAINCODE_ERROR {
+		t.Fatalf("Expected the rejection of failedtx0 by its chaincode, got %v", rejection)
+	}
+}
+
+func TestCreateTxRejectionEvent(t *testing.T) {
+	deploy, _ := proto.Marshal(&ehpb.ChaincodeDeploymentSpec{CodePackage: []byte("code")})
+	tx := &ehpb.Transaction{Uuid: "rejectedtx", Type: ehpb.Transaction_CHAINCODE_DEPLOY, Payload: deploy}
+	rejection := producer.CreateTxRejectionEvent(tx, ehpb.RejectionPhase_COMMIT, ehpb.RejectionReason_COMMIT_FAILED, "disk full").GetRejection()
+	if rejection == nil || rejection.TxID != "rejectedtx" || rejection.ErrorMsg != "disk full" ||
+		rejection.Phase != ehpb.RejectionPhase_COMMIT || rejection.Reason != ehpb.RejectionReason_COMMIT_FAILED {
+		t.Fatalf("Expected the rejection of rejectedtx by a failed commit, got %v", rejection)
+	}
+	spec := &ehpb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(rejection.Tx.Payload, spec); err != nil || spec.CodePackage != nil {
+		t.Fatalf("Expected the code package to be stripped from the rejection event, got %v (%v)", spec, err)
+	}
+	if !bytes.Equal(tx.Payload, deploy) {
+		t.Fatalf("Expected the transaction not to be modified")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	n := replayLedger.GetBlockchainSize()
+	for i := 0; i < 3; i++ {
+		replayLedger.commit()
+	}
+	a := newReplayAdapter(append([]*ehpb.Interest{{EventType: ehpb.EventType_BLOCK}}, replayInterests...)...)
+	client := consumer.NewEventsClient(peerAddress, a)
+	client.ReplayFrom(n + 1)
+	if err := client.Start(); err != nil {
+		t.Fatalf("Error starting client: %s", err)
+	}
+	defer client.Stop()
+	a.expect(t, fmt.Sprintf("block:%d", n+1), fmt.Sprintf("chaincode:%d", n+1), fmt.Sprintf("rejection:%d", n+1),
+		fmt.Sprintf("block:%d", n+2), fmt.Sprintf("chaincode:%d", n+2), fmt.Sprintf("rejection:%d", n+2))
+
+	//events of blocks replayed already are not sent again
+	last, _ := replayLedger.GetBlockByNumber(n + 2)
+	sendLive(t, producer.CreateBlockEvents(last, n+2))
+	sendLive(t, replayLedger.commit())
+	a.expect(t, fmt.Sprintf("chaincode:%d", n+3), fmt.Sprintf("rejection:%d", n+3))
+
+	tooFar := consumer.NewEventsClient(peerAddress, newReplayAdapter(replayInterests...))
+	tooFar.ReplayFrom(replayLedger.GetBlockchainSize() + 1)
+	if err := tooFar.Start(); err == nil {
+		t.Fatalf("Expected replaying from a block not committed yet to fail")
+	}
+}
+
+func TestDurableSubscription(t *testing.T) {
+	a := newReplayAdapter(replayInterests...)
+	client := consumer.NewEventsClient(peerAddress, a)
+	client.SetSubscriptionID(fmt.Sprintf("durable%d", time.Now().UnixNano()))
+	if err := client.Start(); err != nil {
+		t.Fatalf("Error starting client: %s", err)
+	}
+	live := replayLedger.commit()
+	sendLive(t, live)
+	a.expect(t, fmt.Sprintf("chaincode:%d", live[0].BlockNumber), fmt.Sprintf("rejection:%d", live[0].BlockNumber))
+
+	client.Stop()
+	select {
+	case <-a.disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the client to disconnect")
+	}
+	missed := replayLedger.commit()
+	sendLive(t, missed)
+
+	//the events of the block of the last event delivered are delivered again
+	if err := client.Start(); err != nil {
+		t.Fatalf("Error restarting client: %s", err)
+	}
+	defer client.Stop()
+	a.expect(t, fmt.Sprintf("chaincode:%d", live[0].BlockNumber), fmt.Sprintf("rejection:%d", live[0].BlockNumber),
+		fmt.Sprintf("chaincode:%d", missed[0].BlockNumber), fmt.Sprintf("rejection:%d", missed[0].BlockNumber))
+}
+
+func TestFilteredChaincodeEvents(t *testing.T) {
+	a := newReplayAdapter(&ehpb.Interest{EventType: ehpb.EventType_CHAINCODE, Filter: `chaincodeID == filtercc && eventName matches "transfer.*"`})
+	client := consumer.NewEventsClient(peerAddress, a)
+	if err := client.Start(); err != nil {
+		t.Fatalf("Error starting client: %s", err)
+	}
+	defer client.Stop()
+
+	for _, e := range []*ehpb.Event{
+		createTestChaincodeEvent("filtercc", "transferFunds"),
+		createTestChaincodeEvent("filtercc", "audit"),
+		createTestChaincodeEvent("othercc", "transferFunds"),
+	} {
+		if err := producer.Send(e); err != nil {
+			t.Fatalf("Error sending event: %s", err)
+		}
+	}
+	a.expect(t, "chaincode:0")
+
+	invalid := consumer.NewEventsClient(peerAddress, newReplayAdapter(&ehpb.Interest{EventType: ehpb.EventType_CHAINCODE, Filter: "eventName matches"}))
+	if err := invalid.Start(); err == nil {
+		t.Fatalf("Expected registering an invalid filter to fail")
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	a := newReplayAdapter(&ehpb.Interest{EventType: ehpb.EventType_CHAINCODE, Filter: "chaincodeID == resizecc"})
+	client := consumer.NewEventsClient(peerAddress, a)
+	if err := client.Start(); err != nil {
+		t.Fatalf("Error starting client: %s", err)
+	}
+	defer client.Stop()
+	defer producer.Reconfigure(100, 0)
+
+	send := func(name string) {
+		if err := producer.Send(createTestChaincodeEvent("resizecc", name)); err != nil {
+			t.Fatalf("Error sending event %s: %s", name, err)
+		}
+	}
+	send("before")
+	producer.Reconfigure(5, 10)
+	send("resized")
+	producer.Reconfigure(5, -1)
+	send("timeout")
+
+	for _, expected := range []string{"before", "resized", "timeout"} {
+		select {
+		case e := <-a.events:
+			if name := e.GetChaincodeEvent().EventName; name != expected {
+				t.Fatalf("Expected event %s, got %s", expected, name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for event %s", expected)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received <- req.Header.Get(webhook.EventTypeHeader)
+	}))
+	defer server.Close()
+
+	w, err := webhook.New(webhook.Config{
+		Endpoints: []string{server.URL},
+		Interests: []*ehpb.Interest{{EventType: ehpb.EventType_CHAINCODE, Filter: "chaincodeID == webhookcc"}},
+		Client:    server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating webhook: %s", err)
+	}
+	if err = w.Start(peerAddress); err != nil {
+		t.Fatalf("Error starting webhook: %s", err)
+	}
+	defer w.Stop()
+
+	if err = producer.Send(createTestChaincodeEvent("webhookcc", "event1")); err != nil {
+		t.Fatalf("Error sending event: %s", err)
+	}
+	select {
+	case eventType := <-received:
+		if eventType != "CHAINCODE" {
+			t.Fatalf("Expected a chaincode event to be delivered, got %s", eventType)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the webhook delivery")
+	}
+}
+
 func BenchmarkMessages(b *testing.B) {
 	numMessages := 10000
 
@@ -215,6 +514,7 @@ func TestMain(m *testing.M) {
 	// Register EventHub server
 	// use a buffer of 100 and blocking timeout
 	ehServer := producer.NewEventsServer(100, 0)
+	ehServer.EnableReplay(replayLedger, 10)
 	ehpb.RegisterEventsServer(grpcServer, ehServer)
 
 	fmt.Printf("Starting events server\n")
@@ -17,6 +17,8 @@ limitations under the License.
 package producer
 
 import (
+	"github.com/golang/protobuf/proto"
+
 	ehpb "github.com/hyperledger/fabric/protos"
 )
 
@@ -29,3 +31,77 @@ func CreateBlockEvent(te *ehpb.Block) *ehpb.Event {
 func CreateChaincodeEvent(te *ehpb.ChaincodeEvent) *ehpb.Event {
 	return &ehpb.Event{Event: &ehpb.Event_ChaincodeEvent{ChaincodeEvent: te}}
 }
+
+//CreateDivergenceEvent creates a Event from a Divergence
+func CreateDivergenceEvent(te *ehpb.Divergence) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_Divergence{Divergence: te}}
+}
+
+//CreateEvidenceEvent creates a Event from an Evidence of misbehavior
+func CreateEvidenceEvent(te *ehpb.Evidence) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_Evidence{Evidence: te}}
+}
+
+//CreateRejectionEvent creates a Event from a Rejection
+func CreateRejectionEvent(te *ehpb.Rejection) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_Rejection{Rejection: te}}
+}
+
+//CreateTxRejectionEvent creates the rejection event of the transaction tx,
+//which failed in phase for reason before being committed. As in the block
+//events, the code package of a deploy or upgrade transaction is stripped.
+func CreateTxRejectionEvent(tx *ehpb.Transaction, phase ehpb.RejectionPhase, reason ehpb.RejectionReason, errorMsg string) *ehpb.Event {
+	return CreateRejectionEvent(&ehpb.Rejection{Tx: stripCodePackage(tx), ErrorMsg: errorMsg, TxID: tx.Uuid, Phase: phase, Reason: reason})
+}
+
+//CreateBlockEvents creates the events of the committed block blockNumber: the
+//block event, followed by the chaincode events and the rejections of the
+//failed transactions in the order of the transactions. The payload of the
+//deploy and upgrade transactions is stripped of the code package, which can
+//be very large, to keep the events lightweight. The block is not modified.
+func CreateBlockEvents(block *ehpb.Block, blockNumber uint64) []*ehpb.Event {
+	stripped := *block
+	stripped.Transactions = make([]*ehpb.Transaction, len(block.Transactions))
+	txs := make(map[string]*ehpb.Transaction)
+	for i, tx := range block.Transactions {
+		stripped.Transactions[i] = stripCodePackage(tx)
+		txs[tx.Uuid] = stripped.Transactions[i]
+	}
+
+	events := []*ehpb.Event{CreateBlockEvent(&stripped)}
+	for _, tr := range block.GetNonHashData().GetTransactionResults() {
+		if tr == nil {
+			continue
+		}
+		if tr.ChaincodeEvent != nil {
+			events = append(events, CreateChaincodeEvent(tr.ChaincodeEvent))
+		}
+		if tr.ErrorCode != 0 {
+			events = append(events, CreateRejectionEvent(&ehpb.Rejection{Tx: txs[tr.Uuid], ErrorMsg: tr.Error, TxID: tr.Uuid, Phase: tr.Phase, Reason: tr.Reason}))
+		}
+	}
+	for _, e := range events {
+		e.BlockNumber = blockNumber
+	}
+	return events
+}
+
+func stripCodePackage(tx *ehpb.Transaction) *ehpb.Transaction {
+	if tx.Type != ehpb.Transaction_CHAINCODE_DEPLOY && tx.Type != ehpb.Transaction_CHAINCODE_UPGRADE {
+		return tx
+	}
+	deploymentSpec := &ehpb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(tx.Payload, deploymentSpec); err != nil {
+		producerLogger.Errorf("Error unmarshalling deployment transaction for block event: %s", err)
+		return tx
+	}
+	deploymentSpec.CodePackage = nil
+	payload, err := proto.Marshal(deploymentSpec)
+	if err != nil {
+		producerLogger.Errorf("Error marshalling deployment transaction for block event: %s", err)
+		return tx
+	}
+	strippedTx := *tx
+	strippedTx.Payload = payload
+	return &strippedTx
+}
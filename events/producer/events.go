@@ -50,12 +50,51 @@ type chaincodeHandlerList struct {
 	sync.RWMutex
 	// this map used as a list - add/del/iterate
 	handlers map[string]map[string]map[*handler]bool
+	// filters of the handlers registered with a filter expression, which
+	// are evaluated for every chaincode event
+	filtered map[*handler]map[*pb.Interest]eventFilter
+}
+
+func (hl *chaincodeHandlerList) addFiltered(ie *pb.Interest, h *handler) (bool, error) {
+	f := h.filters[ie]
+	if f == nil {
+		var err error
+		if f, err = interestFilter(ie); err != nil {
+			return false, err
+		}
+	}
+	if hl.filtered == nil {
+		hl.filtered = make(map[*handler]map[*pb.Interest]eventFilter)
+	}
+	filters := hl.filtered[h]
+	if filters == nil {
+		filters = make(map[*pb.Interest]eventFilter)
+		hl.filtered[h] = filters
+	}
+	filters[ie] = f
+	return true, nil
+}
+
+func (hl *chaincodeHandlerList) delFiltered(ie *pb.Interest, h *handler) (bool, error) {
+	filters := hl.filtered[h]
+	if _, ok := filters[ie]; !ok {
+		return false, fmt.Errorf("handler not registered for filter %s", ie.Filter)
+	}
+	delete(filters, ie)
+	if len(filters) == 0 {
+		delete(hl.filtered, h)
+	}
+	return true, nil
 }
 
 func (hl *chaincodeHandlerList) add(ie *pb.Interest, h *handler) (bool, error) {
 	hl.Lock()
 	defer hl.Unlock()
 
+	if ie.Filter != "" {
+		return hl.addFiltered(ie, h)
+	}
+
 	//chaincode registration info must be non-nil
 	if ie.GetChaincodeRegInfo() == nil {
 		return false, fmt.Errorf("chaincode information not provided for registering")
@@ -89,6 +128,10 @@ func (hl *chaincodeHandlerList) del(ie *pb.Interest, h *handler) (bool, error) {
 	hl.Lock()
 	defer hl.Unlock()
 
+	if ie.Filter != "" {
+		return hl.delFiltered(ie, h)
+	}
+
 	//chaincode registration info must be non-nil
 	if ie.GetChaincodeRegInfo() == nil {
 		return false, fmt.Errorf("chaincode information not provided for de-registering")
@@ -158,6 +201,16 @@ func (hl *chaincodeHandlerList) foreach(e *pb.Event, action func(h *handler)) {
 			}
 		}
 	}
+
+	//send once to the handlers with a filter matching the event
+	for h, filters := range hl.filtered {
+		for _, f := range filters {
+			if f.match(e.GetChaincodeEvent()) {
+				action(h)
+				break
+			}
+		}
+	}
 }
 
 func (hl *genericHandlerList) add(ie *pb.Interest, h *handler) (bool, error) {
@@ -199,6 +252,10 @@ type eventProcessor struct {
 	sync.RWMutex
 	eventConsumers map[pb.EventType]handlerList
 
+	//sendLock is held by the producers while they send, to replace the event
+	//channel and the timeout once no producer sends to the previous channel
+	sendLock sync.RWMutex
+
 	//we could generalize this with mutiple channels each with its own size
 	eventChannel chan *pb.Event
 
@@ -207,6 +264,15 @@ type eventProcessor struct {
 	//if 0, if buffer full, will block and guarantee the event will be sent out
 	//if > 0, if buffer full, blocks till timeout
 	timeout int
+
+	//resized passes the new event channel to the event processor, which
+	//delivers the events left in the previous one first
+	resized chan chan *pb.Event
+
+	//blockchain the events of committed blocks are replayed from and
+	//positions of the durable subscriptions, nil unless replay is enabled
+	ledger        Ledger
+	subscriptions *subscriptionCache
 }
 
 //global eventProcessor singleton created by initializeEvents. Openchain producers
@@ -215,28 +281,41 @@ var gEventProcessor *eventProcessor
 
 func (ep *eventProcessor) start() {
 	producerLogger.Info("event processor started")
+	eventChannel := ep.eventChannel
 	for {
 		//wait for event
-		e := <-ep.eventChannel
-
-		var hl handlerList
-		eType := getMessageType(e)
-		ep.Lock()
-		if hl, _ = ep.eventConsumers[eType]; hl == nil {
-			producerLogger.Errorf("Event of type %s does not exist", eType)
-			ep.Unlock()
+		var e *pb.Event
+		select {
+		case e = <-eventChannel:
+		case next := <-ep.resized:
+			//no producer sends to the previous channel any more
+			for len(eventChannel) > 0 {
+				ep.deliver(<-eventChannel)
+			}
+			eventChannel = next
 			continue
 		}
-		//lock the handler map lock
-		ep.Unlock()
-
-		hl.foreach(e, func(h *handler) {
-			if e.Event != nil {
-				h.SendMessage(e)
-			}
-		})
+		ep.deliver(e)
+	}
+}
 
+func (ep *eventProcessor) deliver(e *pb.Event) {
+	var hl handlerList
+	eType := getMessageType(e)
+	ep.Lock()
+	if hl, _ = ep.eventConsumers[eType]; hl == nil {
+		producerLogger.Errorf("Event of type %s does not exist", eType)
+		ep.Unlock()
+		return
 	}
+	//lock the handler map lock
+	ep.Unlock()
+
+	hl.foreach(e, func(h *handler) {
+		if e.Event != nil {
+			h.SendMessage(e)
+		}
+	})
 }
 
 //initialize and start
@@ -245,7 +324,7 @@ func initializeEvents(bufferSize uint, tout int) {
 		panic("should not be called twice")
 	}
 
-	gEventProcessor = &eventProcessor{eventConsumers: make(map[pb.EventType]handlerList), eventChannel: make(chan *pb.Event, bufferSize), timeout: tout}
+	gEventProcessor = &eventProcessor{eventConsumers: make(map[pb.EventType]handlerList), eventChannel: make(chan *pb.Event, bufferSize), timeout: tout, resized: make(chan chan *pb.Event)}
 
 	addInternalEventTypes()
 
@@ -253,6 +332,23 @@ func initializeEvents(bufferSize uint, tout int) {
 	go gEventProcessor.start()
 }
 
+//Reconfigure sets the size of the buffer of the events sent and not
+//delivered yet, and the timeout of the producers sending to a full buffer,
+//as the arguments of NewEventsServer. The events buffered when the size
+//changes are delivered before those sent after.
+func Reconfigure(bufferSize uint, tout int) {
+	if gEventProcessor == nil {
+		return
+	}
+	gEventProcessor.sendLock.Lock()
+	defer gEventProcessor.sendLock.Unlock()
+	gEventProcessor.timeout = tout
+	if uint(cap(gEventProcessor.eventChannel)) != bufferSize {
+		gEventProcessor.eventChannel = make(chan *pb.Event, bufferSize)
+		gEventProcessor.resized <- gEventProcessor.eventChannel
+	}
+}
+
 //AddEventType supported event
 func AddEventType(eventType pb.EventType) error {
 	gEventProcessor.Lock()
@@ -267,12 +363,22 @@ func AddEventType(eventType pb.EventType) error {
 		gEventProcessor.eventConsumers[eventType] = &genericHandlerList{handlers: make(map[*handler]bool)}
 	case pb.EventType_CHAINCODE:
 		gEventProcessor.eventConsumers[eventType] = &chaincodeHandlerList{handlers: make(map[string]map[string]map[*handler]bool)}
+	default:
+		gEventProcessor.eventConsumers[eventType] = &genericHandlerList{handlers: make(map[*handler]bool)}
 	}
 	gEventProcessor.Unlock()
 
 	return nil
 }
 
+//replaySource returns the ledger and the durable subscriptions, nil unless
+//replay is enabled
+func (ep *eventProcessor) replaySource() (Ledger, *subscriptionCache) {
+	ep.RLock()
+	defer ep.RUnlock()
+	return ep.ledger, ep.subscriptions
+}
+
 func registerHandler(ie *pb.Interest, h *handler) error {
 	producerLogger.Debugf("registerHandler %s", ie.EventType)
 
@@ -314,6 +420,8 @@ func Send(e *pb.Event) error {
 		return nil
 	}
 
+	gEventProcessor.sendLock.RLock()
+	defer gEventProcessor.sendLock.RUnlock()
 	if gEventProcessor.timeout < 0 {
 		select {
 		case gEventProcessor.eventChannel <- e:
@@ -0,0 +1,286 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// A filter selects the chaincode events a consumer registered for with an
+// expression evaluated by the peer, so that the other events are not sent.
+// It compares the fields chaincodeID, eventName and txID of the events with
+// == and !=, or with matches and a regular expression which must match the
+// whole field. Comparisons are combined with &&, || and !, and grouped with
+// parentheses. Values are strings quoted as in Go, or words without spaces,
+// parentheses or operators. For instance
+//
+//	chaincodeID == mycc && eventName matches "transfer.*"
+
+type eventFilter interface {
+	match(e *pb.ChaincodeEvent) bool
+}
+
+type andFilter struct {
+	left, right eventFilter
+}
+
+func (f *andFilter) match(e *pb.ChaincodeEvent) bool {
+	return f.left.match(e) && f.right.match(e)
+}
+
+type orFilter struct {
+	left, right eventFilter
+}
+
+func (f *orFilter) match(e *pb.ChaincodeEvent) bool {
+	return f.left.match(e) || f.right.match(e)
+}
+
+type notFilter struct {
+	filter eventFilter
+}
+
+func (f *notFilter) match(e *pb.ChaincodeEvent) bool {
+	return !f.filter.match(e)
+}
+
+// comparison compares a field of the events with value, or matches it with re
+type comparison struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+var filterFields = map[string]func(e *pb.ChaincodeEvent) string{
+	"chaincodeID": func(e *pb.ChaincodeEvent) string { return e.ChaincodeID },
+	"eventName":   func(e *pb.ChaincodeEvent) string { return e.EventName },
+	"txID":        func(e *pb.ChaincodeEvent) string { return e.TxID },
+}
+
+func (f *comparison) match(e *pb.ChaincodeEvent) bool {
+	value := filterFields[f.field](e)
+	switch f.op {
+	case "==":
+		return value == f.value
+	case "!=":
+		return value != f.value
+	default:
+		return f.re.MatchString(value)
+	}
+}
+
+// filterParser parses a filter expression by recursive descent
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseFilter parses the filter expression
+func parseFilter(expr string) (eventFilter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s in filter", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+func tokenizeFilter(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, expr[i:i+1])
+			i++
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"),
+			strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		case c == '!':
+			tokens = append(tokens, "!")
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, expr[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(expr) && !unicode.IsSpace(rune(expr[end])) && !strings.ContainsRune("()!&|=\"", rune(expr[end])) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected %q in filter", c)
+			}
+			tokens = append(tokens, expr[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) or() (eventFilter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &orFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (eventFilter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) unary() (eventFilter, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &notFilter{f}, nil
+	case "(":
+		p.pos++
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing, err := p.next(); err != nil || closing != ")" {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		return f, nil
+	default:
+		return p.comparison()
+	}
+}
+
+func (p *filterParser) comparison() (eventFilter, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := filterFields[field]; !ok {
+		return nil, fmt.Errorf("unknown field %s in filter, expected chaincodeID, eventName or txID", field)
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if op != "==" && op != "!=" && op != "matches" {
+		return nil, fmt.Errorf("unexpected %s after %s in filter, expected ==, != or matches", op, field)
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch value {
+	case "(", ")", "!", "&&", "||", "==", "!=":
+		return nil, fmt.Errorf("unexpected %s after %s %s in filter, expected a value", value, field, op)
+	}
+	if strings.HasPrefix(value, "\"") {
+		if value, err = strconv.Unquote(value); err != nil {
+			return nil, fmt.Errorf("invalid string in filter: %s", err)
+		}
+	}
+
+	f := &comparison{field: field, op: op, value: value}
+	if op == "matches" {
+		if f.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid regular expression in filter: %s", err)
+		}
+	}
+	return f, nil
+}
+
+// interestFilter returns the filter of a chaincode interest, which must also
+// match its chaincode registration information if set
+func interestFilter(ie *pb.Interest) (eventFilter, error) {
+	f, err := parseFilter(ie.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if reg := ie.GetChaincodeRegInfo(); reg != nil && reg.ChaincodeID != "" {
+		f = &andFilter{&comparison{field: "chaincodeID", op: "==", value: reg.ChaincodeID}, f}
+		if reg.EventName != "" {
+			f = &andFilter{&comparison{field: "eventName", op: "==", value: reg.EventName}, f}
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestFilterMatch(t *testing.T) {
+	transfer := &pb.ChaincodeEvent{ChaincodeID: "mycc", EventName: "transferFunds", TxID: "tx1"}
+	other := &pb.ChaincodeEvent{ChaincodeID: "othercc", EventName: "transferFunds", TxID: "tx2"}
+
+	for _, tc := range []struct {
+		filter          string
+		transfer, other bool
+	}{
+		{`chaincodeID == mycc && eventName matches "transfer.*"`, true, false},
+		{`chaincodeID == "mycc"`, true, false},
+		{`chaincodeID != mycc`, false, true},
+		{`eventName matches transfer`, false, false},
+		{`eventName matches "transfer.*" && !(txID == tx1)`, false, true},
+		{`txID == tx1 || chaincodeID == othercc`, true, true},
+		{`chaincodeID == mycc || chaincodeID == othercc && txID == tx1`, true, false},
+		{`(chaincodeID == mycc || chaincodeID == othercc) && txID == tx2`, false, true},
+	} {
+		f, err := parseFilter(tc.filter)
+		if err != nil {
+			t.Errorf("Error parsing %s: %s", tc.filter, err)
+			continue
+		}
+		if f.match(transfer) != tc.transfer || f.match(other) != tc.other {
+			t.Errorf("Expected %s to match %v and %v, got %v and %v", tc.filter, tc.transfer, tc.other, f.match(transfer), f.match(other))
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`chaincodeID`,
+		`chaincodeID ==`,
+		`payload == x`,
+		`chaincodeID < x`,
+		`chaincodeID == (`,
+		`chaincodeID == "mycc`,
+		`(chaincodeID == mycc`,
+		`chaincodeID == mycc)`,
+		`chaincodeID == mycc &&`,
+		`eventName matches "("`,
+		`chaincodeID == mycc & eventName == x`,
+	} {
+		if _, err := parseFilter(filter); err == nil {
+			t.Errorf("Expected parsing %q to fail", filter)
+		}
+	}
+}
+
+func TestInterestFilter(t *testing.T) {
+	ie := &pb.Interest{
+		EventType: pb.EventType_CHAINCODE,
+		RegInfo:   &pb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: &pb.ChaincodeReg{ChaincodeID: "mycc"}},
+		Filter:    `eventName matches "transfer.*"`,
+	}
+	f, err := interestFilter(ie)
+	if err != nil {
+		t.Fatalf("Error compiling filter: %s", err)
+	}
+	if !f.match(&pb.ChaincodeEvent{ChaincodeID: "mycc", EventName: "transfer"}) {
+		t.Errorf("Expected the filter to match an event of mycc")
+	}
+	if f.match(&pb.ChaincodeEvent{ChaincodeID: "othercc", EventName: "transfer"}) {
+		t.Errorf("Expected the filter not to match an event of another chaincode than the registered one")
+	}
+}
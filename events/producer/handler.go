@@ -18,6 +18,7 @@ package producer
 
 import (
 	"fmt"
+	"sync"
 
 	pb "github.com/hyperledger/fabric/protos"
 )
@@ -28,13 +29,33 @@ type handler struct {
 	registered bool
 	// PM: this should be a list, add/del, iterate
 	interestedEvents []*pb.Interest
+
+	//sendLock serializes the sends on the stream and guards the replay state
+	sendLock sync.Mutex
+	//live events are queued while the events of the committed blocks are
+	//replayed, and those of the blocks below replayedTo are not sent again
+	replaying  bool
+	pending    []*pb.Event
+	replayedTo uint64
+	//durable subscription whose position is updated as events are delivered
+	subscriptionID string
+	subscriptions  *subscriptionCache
+	//compiled filters of the interests with a filter expression
+	filters map[*pb.Interest]eventFilter
+}
+
+// registrationError is returned when a consumer cannot get the events it
+// registered for, which ends the chat
+type registrationError struct {
+	error
 }
 
 func newEventHandler(stream pb.Events_ChatServer) (*handler, error) {
 	d := &handler{
 		ChatStream: stream,
 	}
-	d.doneChan = make(chan bool)
+	//buffered for Stop not to block the end of the chat, nobody waits on it
+	d.doneChan = make(chan bool, 1)
 	return d, nil
 }
 
@@ -73,6 +94,28 @@ func (d *handler) register(iMsg []*pb.Interest) error {
 	return nil
 }
 
+// compileFilters compiles the filter expressions of the interests, which are
+// only supported for chaincode events
+func (d *handler) compileFilters(interests []*pb.Interest) error {
+	for _, ie := range interests {
+		if ie.Filter == "" {
+			continue
+		}
+		if ie.EventType != pb.EventType_CHAINCODE {
+			return &registrationError{fmt.Errorf("filters are only supported for %s events, not %s", pb.EventType_CHAINCODE, ie.EventType)}
+		}
+		f, err := interestFilter(ie)
+		if err != nil {
+			return &registrationError{fmt.Errorf("invalid filter %q: %s", ie.Filter, err)}
+		}
+		if d.filters == nil {
+			d.filters = make(map[*pb.Interest]eventFilter)
+		}
+		d.filters[ie] = f
+	}
+	return nil
+}
+
 func (d *handler) deregister() {
 	for _, v := range d.interestedEvents {
 		if err := deRegisterHandler(v, d); err != nil {
@@ -93,25 +136,72 @@ func (d *handler) HandleMessage(msg *pb.Event) error {
 		return fmt.Errorf("Invalid object from consumer %v", msg.GetEvent())
 	}
 
+	d.sendLock.Lock()
+	replaying := d.replaying
+	d.sendLock.Unlock()
+	if replaying {
+		return fmt.Errorf("Cannot register events while replaying")
+	}
+
+	if err := d.compileFilters(eventsObj.Events); err != nil {
+		return err
+	}
+
+	from, replay, err := replayStart(eventsObj)
+	if err != nil {
+		return err
+	}
+
+	//queue the live events from the registration on, until replayed
+	d.sendLock.Lock()
+	d.replaying = replay
+	d.subscriptionID = eventsObj.SubscriptionID
+	_, d.subscriptions = gEventProcessor.replaySource()
+	d.sendLock.Unlock()
+
 	if err := d.register(eventsObj.Events); err != nil {
 		return fmt.Errorf("Could not register events %s", err)
 	}
 
 	//TODO return supported events.. for now just return the received msg
-	if err := d.ChatStream.Send(msg); err != nil {
+	d.sendLock.Lock()
+	err = d.ChatStream.Send(msg)
+	d.sendLock.Unlock()
+	if err != nil {
 		return fmt.Errorf("Error sending response to %v:  %s", msg, err)
 	}
 
 	d.registered = true
 
+	if replay {
+		return d.replay(from)
+	}
 	return nil
 }
 
 // SendMessage sends a message to the remote PEER through the stream
 func (d *handler) SendMessage(msg *pb.Event) error {
+	d.sendLock.Lock()
+	defer d.sendLock.Unlock()
+	if d.replaying {
+		d.pending = append(d.pending, msg)
+		return nil
+	}
+	if d.replayed(msg) {
+		return nil
+	}
+	return d.send(msg)
+}
+
+// send sends msg through the stream and records it as delivered to the
+// durable subscription, under the sendLock
+func (d *handler) send(msg *pb.Event) error {
 	err := d.ChatStream.Send(msg)
 	if err != nil {
 		return fmt.Errorf("Error Sending message through ChatStream: %s", err)
 	}
+	if d.subscriptionID != "" && d.subscriptions != nil && isBlockEvent(msg) {
+		d.subscriptions.delivered(d.subscriptionID, msg.BlockNumber)
+	}
 	return nil
 }
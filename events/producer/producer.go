@@ -21,6 +21,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/hyperledger/fabric/core/metrics"
 	pb "github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
 )
@@ -47,6 +48,16 @@ func NewEventsServer(bufferSize uint, timeout int) *EventsServer {
 	return globalEventsServer
 }
 
+// EnableReplay lets the consumers replay the events of the blocks committed
+// to ledger, and remembers the position of up to subscriptions durable
+// subscriptions, the least recently delivered to being forgotten first
+func (p *EventsServer) EnableReplay(ledger Ledger, subscriptions int) {
+	gEventProcessor.Lock()
+	defer gEventProcessor.Unlock()
+	gEventProcessor.ledger = ledger
+	gEventProcessor.subscriptions = newSubscriptionCache(subscriptions)
+}
+
 // Chat implementation of the the Chat bidi streaming RPC function
 func (p *EventsServer) Chat(stream pb.Events_ChatServer) error {
 	handler, err := newEventHandler(stream)
@@ -54,6 +65,7 @@ func (p *EventsServer) Chat(stream pb.Events_ChatServer) error {
 		return fmt.Errorf("Error creating handler during handleChat initiation: %s", err)
 	}
 	defer handler.Stop()
+	defer metrics.StreamOpened("events")()
 	for {
 		in, err := stream.Recv()
 		if err == io.EOF {
@@ -68,6 +80,11 @@ func (p *EventsServer) Chat(stream pb.Events_ChatServer) error {
 		err = handler.HandleMessage(in)
 		if err != nil {
 			producerLogger.Errorf("Error handling message: %s", err)
+			//the consumer cannot get the events it asked for, end the chat so
+			//that it knows
+			if _, ok := err.(*registrationError); ok {
+				return err
+			}
 			//return err
 		}
 
@@ -39,6 +39,12 @@ func getMessageType(e *pb.Event) pb.EventType {
 		return pb.EventType_BLOCK
 	case *pb.Event_ChaincodeEvent:
 		return pb.EventType_CHAINCODE
+	case *pb.Event_Divergence:
+		return pb.EventType_DIVERGENCE
+	case *pb.Event_Evidence:
+		return pb.EventType_MISBEHAVIOR
+	case *pb.Event_Rejection:
+		return pb.EventType_REJECTION
 	default:
 		return -1
 	}
@@ -48,4 +54,7 @@ func getMessageType(e *pb.Event) pb.EventType {
 func addInternalEventTypes() {
 	AddEventType(pb.EventType_BLOCK)
 	AddEventType(pb.EventType_CHAINCODE)
+	AddEventType(pb.EventType_DIVERGENCE)
+	AddEventType(pb.EventType_MISBEHAVIOR)
+	AddEventType(pb.EventType_REJECTION)
 }
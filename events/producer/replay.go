@@ -0,0 +1,207 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Ledger is the blockchain the events of the committed blocks are replayed
+// from
+type Ledger interface {
+	GetBlockchainSize() uint64
+	GetBlockByNumber(blockNumber uint64) (*pb.Block, error)
+}
+
+// defaultSubscriptions bounds the durable subscriptions remembered if
+// EnableReplay is given no bound
+const defaultSubscriptions = 1000
+
+// subscription is the position of a durable subscription: the number of the
+// block of the last event delivered to it, or of the block its events started
+// from if none was delivered yet
+type subscription struct {
+	id    string
+	block uint64
+}
+
+// subscriptionCache remembers the position of the latest durable
+// subscriptions, evicting the least recently delivered to once full
+type subscriptionCache struct {
+	sync.Mutex
+	capacity  int
+	order     *list.List // of *subscription, least recently delivered to first
+	positions map[string]*list.Element
+}
+
+func newSubscriptionCache(capacity int) *subscriptionCache {
+	if capacity <= 0 {
+		capacity = defaultSubscriptions
+	}
+	return &subscriptionCache{capacity: capacity, order: list.New(), positions: make(map[string]*list.Element)}
+}
+
+// position returns the position of subscription id, if known
+func (c *subscriptionCache) position(id string) (uint64, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if elem, ok := c.positions[id]; ok {
+		return elem.Value.(*subscription).block, true
+	}
+	return 0, false
+}
+
+// delivered records block as the position of subscription id
+func (c *subscriptionCache) delivered(id string, block uint64) {
+	c.Lock()
+	defer c.Unlock()
+	if elem, ok := c.positions[id]; ok {
+		c.order.Remove(elem)
+	}
+	c.positions[id] = c.order.PushBack(&subscription{id: id, block: block})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.positions, oldest.Value.(*subscription).id)
+	}
+}
+
+// isBlockEvent returns whether e comes from a committed block, and so can be
+// replayed
+func isBlockEvent(e *pb.Event) bool {
+	switch x := e.Event.(type) {
+	case *pb.Event_Block, *pb.Event_ChaincodeEvent:
+		return true
+	case *pb.Event_Rejection:
+		// the transactions rejected by consensus or whose block failed to
+		// commit are in no block
+		phase := x.Rejection.Phase
+		return phase != pb.RejectionPhase_CONSENSUS && phase != pb.RejectionPhase_COMMIT
+	default:
+		return false
+	}
+}
+
+// replayStart returns the block the events registered by reg are replayed
+// from, if any. The events of a durable subscription resume from the block of
+// the last event delivered to it, which is delivered again, so consumers
+// should ignore the events of the blocks they already processed.
+func replayStart(reg *pb.Register) (uint64, bool, error) {
+	if !reg.Replay && reg.SubscriptionID == "" {
+		return 0, false, nil
+	}
+	ledger, subscriptions := gEventProcessor.replaySource()
+	if ledger == nil {
+		return 0, false, &registrationError{fmt.Errorf("replay is not enabled on this peer")}
+	}
+
+	from, replay := reg.FromBlock, reg.Replay
+	if !replay && reg.SubscriptionID != "" {
+		from, replay = subscriptions.position(reg.SubscriptionID)
+	}
+	size := ledger.GetBlockchainSize()
+	if !replay {
+		if reg.SubscriptionID != "" {
+			//events of a new durable subscription start from the next block
+			subscriptions.delivered(reg.SubscriptionID, size)
+		}
+		return 0, false, nil
+	}
+	if from > size {
+		return 0, false, &registrationError{fmt.Errorf("cannot replay from block %d, the blockchain has %d blocks", from, size)}
+	}
+	if reg.SubscriptionID != "" {
+		subscriptions.delivered(reg.SubscriptionID, from)
+	}
+	return from, true, nil
+}
+
+// interested returns whether the handler registered for e
+func (d *handler) interested(e *pb.Event) bool {
+	eType := getMessageType(e)
+	for _, ie := range d.interestedEvents {
+		if ie.EventType != eType {
+			continue
+		}
+		if eType != pb.EventType_CHAINCODE {
+			return true
+		}
+		reg, ce := ie.GetChaincodeRegInfo(), e.GetChaincodeEvent()
+		if f := d.filters[ie]; f != nil {
+			if f.match(ce) {
+				return true
+			}
+		} else if reg != nil && reg.ChaincodeID == ce.ChaincodeID && (reg.EventName == "" || reg.EventName == ce.EventName) {
+			return true
+		}
+	}
+	return false
+}
+
+// replay sends the events the handler registered for of the blocks from
+// from to the last committed one, then the live events queued meanwhile
+// which were not replayed
+func (d *handler) replay(from uint64) error {
+	ledger, _ := gEventProcessor.replaySource()
+	to := ledger.GetBlockchainSize()
+
+	d.sendLock.Lock()
+	d.replayedTo = to
+	d.sendLock.Unlock()
+
+	producerLogger.Debugf("Replaying the events of blocks %d to %d", from, to)
+	for blockNumber := from; blockNumber < to; blockNumber++ {
+		block, err := ledger.GetBlockByNumber(blockNumber)
+		if err != nil {
+			return &registrationError{fmt.Errorf("Error getting block %d to replay its events: %s", blockNumber, err)}
+		}
+		for _, e := range CreateBlockEvents(block, blockNumber) {
+			if !d.interested(e) {
+				continue
+			}
+			d.sendLock.Lock()
+			err = d.send(e)
+			d.sendLock.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	d.sendLock.Lock()
+	defer d.sendLock.Unlock()
+	pending := d.pending
+	d.pending, d.replaying = nil, false
+	for _, e := range pending {
+		if d.replayed(e) {
+			continue
+		}
+		if err := d.send(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayed returns whether e was replayed already
+func (d *handler) replayed(e *pb.Event) bool {
+	return isBlockEvent(e) && e.BlockNumber < d.replayedTo
+}
@@ -0,0 +1,415 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook delivers the events of the event hub to HTTPS endpoints, for
+// the consumers which cannot hold a gRPC stream open. The webhook is an event
+// adapter registered with the event hub of the peer like any other consumer:
+// it POSTs each event it receives as JSON to every endpoint, retrying the
+// failed deliveries, and logs the events it gives up on as dead letters.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/events/consumer"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var webhookLogger = logging.MustGetLogger("eventhub_webhook")
+
+// defaultQueueSize bounds the events waiting for an endpoint if the
+// configuration does not
+const defaultQueueSize = 1000
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the body, keyed
+	// with the secret of the webhook, prefixed with sha256=
+	SignatureHeader = "X-Fabric-Signature"
+	// EventTypeHeader carries the type of the event, such as BLOCK
+	EventTypeHeader = "X-Fabric-Event-Type"
+	// BlockNumberHeader carries the number of the block the event comes from
+	BlockNumberHeader = "X-Fabric-Block-Number"
+)
+
+// Config is the configuration of a webhook
+type Config struct {
+	// Endpoints are the HTTPS URLs the events are POSTed to
+	Endpoints []string
+	// Secret keys the signature of the bodies, which are not signed if empty
+	Secret []byte
+	// Interests are the events delivered
+	Interests []*pb.Interest
+	// SubscriptionID is the durable subscription the events resume from
+	// after the connection to the event hub is lost, if set
+	SubscriptionID string
+	// MaxAttempts bounds the attempts to deliver an event to an endpoint.
+	// Backoff is the wait before the first retry, doubled for each of the
+	// next ones up to MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// QueueSize bounds the events waiting to be delivered to an endpoint,
+	// the events received while it is full are dead letters
+	QueueSize int
+	// DeadLetterFile is the file the dead letters are appended to as JSON
+	// lines, besides being logged, if set
+	DeadLetterFile string
+	// Client performs the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// LoadConfig returns the configuration of peer.validator.events.webhook
+func LoadConfig() (*Config, error) {
+	config := &Config{
+		Endpoints:      viper.GetStringSlice("peer.validator.events.webhook.endpoints"),
+		Secret:         []byte(viper.GetString("peer.validator.events.webhook.secret")),
+		SubscriptionID: viper.GetString("peer.validator.events.webhook.subscriptionID"),
+		MaxAttempts:    viper.GetInt("peer.validator.events.webhook.maxAttempts"),
+		Backoff:        viper.GetDuration("peer.validator.events.webhook.backoff"),
+		MaxBackoff:     viper.GetDuration("peer.validator.events.webhook.maxBackoff"),
+		QueueSize:      viper.GetInt("peer.validator.events.webhook.queueSize"),
+		DeadLetterFile: viper.GetString("peer.validator.events.webhook.deadLetterFile"),
+	}
+
+	filter := viper.GetString("peer.validator.events.webhook.filter")
+	for _, name := range viper.GetStringSlice("peer.validator.events.webhook.events") {
+		value, ok := pb.EventType_value[strings.ToUpper(name)]
+		if !ok || value == int32(pb.EventType_REGISTER) {
+			return nil, fmt.Errorf("Invalid webhook event type %s", name)
+		}
+		interest := &pb.Interest{EventType: pb.EventType(value)}
+		if interest.EventType == pb.EventType_CHAINCODE {
+			interest.Filter = filter
+			if filter == "" {
+				interest.Filter = `chaincodeID matches ".*"`
+			}
+		}
+		config.Interests = append(config.Interests, interest)
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if file := viper.GetString("peer.validator.events.webhook.rootcert.file"); file != "" {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the webhook root certificates: %s", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in %s", file)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	config.Client = &http.Client{Transport: transport, Timeout: viper.GetDuration("peer.validator.events.webhook.timeout")}
+	return config, nil
+}
+
+// Webhook is the event adapter delivering the events to the endpoints
+type Webhook struct {
+	config    Config
+	endpoints []*endpoint
+	client    *consumer.EventsClient
+
+	stopOnce sync.Once
+	stop     chan struct{}
+
+	deadLetters sync.Mutex // serializes the writes to the dead letter file
+}
+
+type endpoint struct {
+	url   string
+	queue chan *delivery
+}
+
+// delivery is an event to deliver, marshalled once for all the endpoints
+type delivery struct {
+	eventType   string
+	blockNumber uint64
+	body        []byte
+}
+
+// deadLetter is the record of an event the webhook gave up delivering
+type deadLetter struct {
+	Endpoint  string          `json:"endpoint"`
+	Attempts  int             `json:"attempts"`
+	Error     string          `json:"error"`
+	Time      time.Time       `json:"time"`
+	EventType string          `json:"eventType"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// New returns a webhook delivering the events to the endpoints of config
+func New(config Config) (*Webhook, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("No webhook endpoint configured")
+	}
+	if len(config.Interests) == 0 {
+		return nil, fmt.Errorf("No webhook event type configured")
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.MaxBackoff < config.Backoff {
+		config.MaxBackoff = config.Backoff
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	w := &Webhook{config: config, stop: make(chan struct{})}
+	for _, endpointURL := range config.Endpoints {
+		u, err := url.Parse(endpointURL)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid webhook endpoint %s: %s", endpointURL, err)
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("Invalid webhook endpoint %s, expected an https URL", endpointURL)
+		}
+		w.endpoints = append(w.endpoints, &endpoint{url: endpointURL, queue: make(chan *delivery, config.QueueSize)})
+	}
+	return w, nil
+}
+
+// Start connects the webhook to the event hub at peerAddress and delivers the
+// events it receives until Stop is called
+func (w *Webhook) Start(peerAddress string) error {
+	w.client = consumer.NewEventsClient(peerAddress, w)
+	if w.config.SubscriptionID != "" {
+		w.client.SetSubscriptionID(w.config.SubscriptionID)
+	}
+	w.startDelivery()
+	if err := w.client.Start(); err != nil {
+		w.Stop()
+		return fmt.Errorf("Error connecting the webhook to the event hub: %s", err)
+	}
+	webhookLogger.Infof("Delivering events to %d webhook endpoints", len(w.endpoints))
+	return nil
+}
+
+func (w *Webhook) startDelivery() {
+	for _, ep := range w.endpoints {
+		go w.run(ep)
+	}
+}
+
+// Stop disconnects the webhook from the event hub, the events not delivered
+// yet are dropped
+func (w *Webhook) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		if w.client != nil {
+			w.client.Stop()
+		}
+	})
+}
+
+func (w *Webhook) stopped() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetInterestedEvents implements consumer.EventAdapter
+func (w *Webhook) GetInterestedEvents() ([]*pb.Interest, error) {
+	return w.config.Interests, nil
+}
+
+// Recv implements consumer.EventAdapter, queueing the event for every endpoint
+func (w *Webhook) Recv(msg *pb.Event) (bool, error) {
+	body, err := (&jsonpb.Marshaler{}).MarshalToString(msg)
+	if err != nil {
+		webhookLogger.Errorf("Error marshalling event for the webhook: %s", err)
+		return true, nil
+	}
+	d := &delivery{eventType: eventType(msg), blockNumber: msg.BlockNumber, body: []byte(body)}
+	for _, ep := range w.endpoints {
+		select {
+		case ep.queue <- d:
+		default:
+			w.deadLetter(ep, d, 0, fmt.Errorf("delivery queue full"))
+		}
+	}
+	return true, nil
+}
+
+// Disconnected implements consumer.EventAdapter, reconnecting to the event hub
+// until Stop is called
+func (w *Webhook) Disconnected(err error) {
+	if w.stopped() {
+		return
+	}
+	webhookLogger.Warningf("Webhook disconnected from the event hub (%v), reconnecting", err)
+	go func() {
+		backoff := w.config.Backoff
+		if backoff <= 0 {
+			backoff = time.Second
+		}
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(backoff):
+			}
+			err := w.client.Start()
+			if err == nil {
+				webhookLogger.Info("Webhook reconnected to the event hub")
+				return
+			}
+			webhookLogger.Warningf("Error reconnecting the webhook to the event hub: %s", err)
+			if backoff *= 2; w.config.MaxBackoff > 0 && backoff > w.config.MaxBackoff {
+				backoff = w.config.MaxBackoff
+			}
+		}
+	}()
+}
+
+func (w *Webhook) run(ep *endpoint) {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case d := <-ep.queue:
+			w.deliver(ep, d)
+		}
+	}
+}
+
+// deliver POSTs d to the endpoint, retrying on network errors and on 5xx and
+// 429 statuses
+func (w *Webhook) deliver(ep *endpoint, d *delivery) {
+	backoff := w.config.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ep, d)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= w.config.MaxAttempts {
+			w.deadLetter(ep, d, attempt, err)
+			return
+		}
+		webhookLogger.Warningf("Error delivering %s event to %s, attempt %d of %d: %s", d.eventType, ep.url, attempt, w.config.MaxAttempts, err)
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > w.config.MaxBackoff {
+			backoff = w.config.MaxBackoff
+		}
+	}
+}
+
+func (w *Webhook) post(ep *endpoint, d *delivery) (bool, error) {
+	req, err := http.NewRequest("POST", ep.url, bytes.NewReader(d.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, d.eventType)
+	req.Header.Set(BlockNumberHeader, strconv.FormatUint(d.blockNumber, 10))
+	if len(w.config.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.config.Secret, d.body))
+	}
+
+	resp, err := w.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("status %s", resp.Status)
+	}
+}
+
+func (w *Webhook) deadLetter(ep *endpoint, d *delivery, attempts int, err error) {
+	webhookLogger.Errorf("Giving up delivering %s event of block %d to %s after %d attempts: %s", d.eventType, d.blockNumber, ep.url, attempts, err)
+	if w.config.DeadLetterFile == "" {
+		return
+	}
+	line, merr := json.Marshal(&deadLetter{Endpoint: ep.url, Attempts: attempts, Error: err.Error(), Time: time.Now().UTC(), EventType: d.eventType, Event: d.body})
+	if merr != nil {
+		webhookLogger.Errorf("Error marshalling dead letter: %s", merr)
+		return
+	}
+
+	w.deadLetters.Lock()
+	defer w.deadLetters.Unlock()
+	f, ferr := os.OpenFile(w.config.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if ferr != nil {
+		webhookLogger.Errorf("Error opening dead letter file %s: %s", w.config.DeadLetterFile, ferr)
+		return
+	}
+	defer f.Close()
+	if _, ferr = f.Write(append(line, '\n')); ferr != nil {
+		webhookLogger.Errorf("Error writing dead letter file %s: %s", w.config.DeadLetterFile, ferr)
+	}
+}
+
+// Sign returns the value of the signature header of body for secret, for
+// endpoints to check that the events come from the peer
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func eventType(e *pb.Event) string {
+	switch e.Event.(type) {
+	case *pb.Event_Block:
+		return pb.EventType_BLOCK.String()
+	case *pb.Event_ChaincodeEvent:
+		return pb.EventType_CHAINCODE.String()
+	case *pb.Event_Rejection:
+		return pb.EventType_REJECTION.String()
+	case *pb.Event_Divergence:
+		return pb.EventType_DIVERGENCE.String()
+	case *pb.Event_Evidence:
+		return pb.EventType_MISBEHAVIOR.String()
+	default:
+		return pb.EventType_REGISTER.String()
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// recorder is an endpoint answering the statuses given, and 200 once they
+// are exhausted
+type recorder struct {
+	sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	received chan struct{}
+}
+
+func (r *recorder) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.Lock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	r.Unlock()
+	rw.WriteHeader(status)
+	r.received <- struct{}{}
+}
+
+func newTestWebhook(t *testing.T, statuses []int, config Config) (*Webhook, *recorder, func()) {
+	r := &recorder{statuses: statuses, received: make(chan struct{}, 10)}
+	server := httptest.NewTLSServer(r)
+	config.Endpoints = []string{server.URL}
+	config.Interests = []*pb.Interest{{EventType: pb.EventType_CHAINCODE, Filter: `chaincodeID matches ".*"`}}
+	config.Client = server.Client()
+	w, err := New(config)
+	if err != nil {
+		t.Fatalf("Error creating webhook: %s", err)
+	}
+	w.startDelivery()
+	return w, r, func() {
+		w.Stop()
+		server.Close()
+	}
+}
+
+func (r *recorder) wait(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for request %d", i+1)
+		}
+	}
+}
+
+func testEvent() *pb.Event {
+	return &pb.Event{BlockNumber: 7, Event: &pb.Event_ChaincodeEvent{ChaincodeEvent: &pb.ChaincodeEvent{ChaincodeID: "mycc", TxID: "tx1", EventName: "transfer", Payload: []byte("payload")}}}
+}
+
+func TestDelivery(t *testing.T) {
+	secret := []byte("secret")
+	w, r, stop := newTestWebhook(t, nil, Config{Secret: secret})
+	defer stop()
+
+	w.Recv(testEvent())
+	r.wait(t, 1)
+
+	r.Lock()
+	defer r.Unlock()
+	req, body := r.requests[0], r.bodies[0]
+	if req.Method != "POST" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON POST, got %s of %s", req.Method, req.Header.Get("Content-Type"))
+	}
+	if req.Header.Get(EventTypeHeader) != "CHAINCODE" || req.Header.Get(BlockNumberHeader) != "7" {
+		t.Errorf("Expected the type and block of the event in the headers, got %s and %s", req.Header.Get(EventTypeHeader), req.Header.Get(BlockNumberHeader))
+	}
+	if sig := req.Header.Get(SignatureHeader); sig != Sign(secret, body) {
+		t.Errorf("Expected the body to be signed, got signature %s", sig)
+	}
+	event := &pb.Event{}
+	if err := jsonpb.UnmarshalString(string(body), event); err != nil {
+		t.Fatalf("Error unmarshalling the delivered event: %s", err)
+	}
+	if ce := event.GetChaincodeEvent(); ce == nil || ce.ChaincodeID != "mycc" || string(ce.Payload) != "payload" || event.BlockNumber != 7 {
+		t.Errorf("Expected the chaincode event of mycc, got %v", event)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	w, r, stop := newTestWebhook(t, []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, Config{MaxAttempts: 3, Backoff: time.Millisecond})
+	defer stop()
+
+	w.Recv(testEvent())
+	r.wait(t, 3)
+	select {
+	case <-r.received:
+		t.Fatalf("Expected no request after the delivery succeeded")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "deadletters")
+
+	// 500s until the attempts are exhausted, then a 400 which is not retried
+	w, r, stop := newTestWebhook(t, []int{500, 500, 400}, Config{MaxAttempts: 2, Backoff: time.Millisecond, DeadLetterFile: file})
+	defer stop()
+
+	w.Recv(testEvent())
+	w.Recv(testEvent())
+	r.wait(t, 3)
+
+	var letters []deadLetter
+	for deadline := time.Now().Add(5 * time.Second); len(letters) < 2 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		letters = nil
+		if f, err := os.Open(file); err == nil {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				var letter deadLetter
+				if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+					t.Fatalf("Error unmarshalling dead letter %s: %s", scanner.Text(), err)
+				}
+				letters = append(letters, letter)
+			}
+			f.Close()
+		}
+	}
+	if len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %v", letters)
+	}
+	if letters[0].Attempts != 2 || letters[1].Attempts != 1 || letters[0].EventType != "CHAINCODE" {
+		t.Errorf("Expected the events to be given up after 2 and 1 attempts, got %v", letters)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	interests := []*pb.Interest{{EventType: pb.EventType_BLOCK}}
+	if _, err := New(Config{Endpoints: []string{"http://example.com/hook"}, Interests: interests}); err == nil {
+		t.Errorf("Expected a plain HTTP endpoint to be rejected")
+	}
+	if _, err := New(Config{Interests: interests}); err == nil {
+		t.Errorf("Expected a webhook without endpoints to be rejected")
+	}
+	if _, err := New(Config{Endpoints: []string{"https://example.com/hook"}}); err == nil {
+		t.Errorf("Expected a webhook without events to be rejected")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	viper.Set("peer.validator.events.webhook.endpoints", []string{"https://example.com/hook"})
+	viper.Set("peer.validator.events.webhook.events", []string{"block", "chaincode"})
+	viper.Set("peer.validator.events.webhook.filter", "chaincodeID == mycc")
+	viper.Set("peer.validator.events.webhook.backoff", "2s")
+	defer viper.Reset()
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Error loading config: %s", err)
+	}
+	if len(config.Interests) != 2 || config.Interests[0].EventType != pb.EventType_BLOCK || config.Interests[1].Filter != "chaincodeID == mycc" {
+		t.Errorf("Expected block and filtered chaincode interests, got %v", config.Interests)
+	}
+	if config.Backoff != 2*time.Second {
+		t.Errorf("Expected a backoff of 2s, got %v", config.Backoff)
+	}
+
+	viper.Set("peer.validator.events.webhook.events", []string{"register"})
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected registration events to be rejected")
+	}
+}
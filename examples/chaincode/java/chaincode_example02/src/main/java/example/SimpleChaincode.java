@@ -0,0 +1,126 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package example;
+
+import java.nio.charset.StandardCharsets;
+
+import org.hyperledger.java.shim.ChaincodeBase;
+import org.hyperledger.java.shim.ChaincodeStub;
+
+/**
+ * SimpleChaincode is the Java version of chaincode_example02, which transfers
+ * asset holdings between two entities.
+ */
+public class SimpleChaincode extends ChaincodeBase {
+
+	@Override
+	public byte[] init(ChaincodeStub stub, String function, String[] args) throws Exception {
+		if (args.length != 4) {
+			throw new IllegalArgumentException("Incorrect number of arguments. Expecting 4");
+		}
+
+		// Initialize the chaincode
+		int aval = parseHolding(args[1]);
+		int bval = parseHolding(args[3]);
+		System.out.printf("Aval = %d, Bval = %d%n", aval, bval);
+
+		// Write the state to the ledger
+		putHolding(stub, args[0], aval);
+		putHolding(stub, args[2], bval);
+		return null;
+	}
+
+	// Transaction makes payment of X units from A to B
+	@Override
+	public byte[] invoke(ChaincodeStub stub, String function, String[] args) throws Exception {
+		if (function.equals("delete")) {
+			// Deletes an entity from its state
+			return delete(stub, args);
+		}
+
+		if (args.length != 3) {
+			throw new IllegalArgumentException("Incorrect number of arguments. Expecting 3");
+		}
+
+		// Get the state from the ledger
+		int aval = getHolding(stub, args[0]);
+		int bval = getHolding(stub, args[1]);
+
+		// Perform the execution
+		int x = parseHolding(args[2]);
+		aval = aval - x;
+		bval = bval + x;
+		System.out.printf("Aval = %d, Bval = %d%n", aval, bval);
+
+		// Write the state back to the ledger
+		putHolding(stub, args[0], aval);
+		putHolding(stub, args[1], bval);
+		return null;
+	}
+
+	// Deletes an entity from state
+	private byte[] delete(ChaincodeStub stub, String[] args) throws Exception {
+		if (args.length != 1) {
+			throw new IllegalArgumentException("Incorrect number of arguments. Expecting 1");
+		}
+		stub.delState(args[0]);
+		return null;
+	}
+
+	// Query callback representing the query of a chaincode
+	@Override
+	public byte[] query(ChaincodeStub stub, String function, String[] args) throws Exception {
+		if (!function.equals("query")) {
+			throw new IllegalArgumentException("Invalid query function name. Expecting \"query\"");
+		}
+		if (args.length != 1) {
+			throw new IllegalArgumentException("Incorrect number of arguments. Expecting name of the person to query");
+		}
+
+		byte[] avalbytes = stub.getState(args[0]);
+		if (avalbytes == null) {
+			throw new IllegalStateException("{\"Error\":\"Nil amount for " + args[0] + "\"}");
+		}
+		System.out.printf("Query Response:{\"Name\":\"%s\",\"Amount\":\"%s\"}%n", args[0],
+				new String(avalbytes, StandardCharsets.UTF_8));
+		return avalbytes;
+	}
+
+	private static int parseHolding(String value) {
+		try {
+			return Integer.parseInt(value);
+		} catch (NumberFormatException e) {
+			throw new IllegalArgumentException("Expecting integer value for asset holding");
+		}
+	}
+
+	private static int getHolding(ChaincodeStub stub, String entity) throws Exception {
+		byte[] holding = stub.getState(entity);
+		if (holding == null) {
+			throw new IllegalStateException("Entity not found");
+		}
+		return Integer.parseInt(new String(holding, StandardCharsets.UTF_8));
+	}
+
+	private static void putHolding(ChaincodeStub stub, String entity, int holding) throws Exception {
+		stub.putState(entity, Integer.toString(holding).getBytes(StandardCharsets.UTF_8));
+	}
+
+	public static void main(String[] args) {
+		new SimpleChaincode().start(args);
+	}
+}
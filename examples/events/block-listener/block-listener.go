@@ -29,11 +29,26 @@ import (
 )
 
 type adapter struct {
-	notfy chan *pb.Event_Block
+	notfy       chan *pb.Event_Block
+	cEvent      chan *pb.Event_ChaincodeEvent
+	chaincodeID string
+	filter      string
 }
 
 //GetInterestedEvents implements consumer.EventAdapter interface for registering interested events
 func (a *adapter) GetInterestedEvents() ([]*pb.Interest, error) {
+	if a.filter != "" {
+		//the peer only sends the chaincode events matching the filter
+		return []*pb.Interest{
+			{EventType: pb.EventType_BLOCK},
+			{EventType: pb.EventType_CHAINCODE, Filter: a.filter}}, nil
+	}
+	if a.chaincodeID != "" {
+		//an empty event name registers for all the events of the chaincode
+		return []*pb.Interest{
+			{EventType: pb.EventType_BLOCK},
+			{EventType: pb.EventType_CHAINCODE, RegInfo: &pb.Interest_ChaincodeRegInfo{ChaincodeRegInfo: &pb.ChaincodeReg{ChaincodeID: a.chaincodeID, EventName: ""}}}}, nil
+	}
 	return []*pb.Interest{{EventType: pb.EventType_BLOCK}}, nil
 }
 
@@ -43,6 +58,9 @@ func (a *adapter) Recv(msg *pb.Event) (bool, error) {
 	case *pb.Event_Block:
 		a.notfy <- msg.Event.(*pb.Event_Block)
 		return true, nil
+	case *pb.Event_ChaincodeEvent:
+		a.cEvent <- msg.Event.(*pb.Event_ChaincodeEvent)
+		return true, nil
 	default:
 		a.notfy <- nil
 		return false, nil
@@ -55,12 +73,15 @@ func (a *adapter) Disconnected(err error) {
 	os.Exit(1)
 }
 
-func createEventClient(eventAddress string) *adapter {
+func createEventClient(eventAddress string, chaincodeID string, filter string, replayFrom int64) *adapter {
 	var obcEHClient *consumer.EventsClient
 
 	done := make(chan *pb.Event_Block)
-	adapter := &adapter{notfy: done}
+	adapter := &adapter{notfy: done, cEvent: make(chan *pb.Event_ChaincodeEvent), chaincodeID: chaincodeID, filter: filter}
 	obcEHClient = consumer.NewEventsClient(eventAddress, adapter)
+	if replayFrom >= 0 {
+		obcEHClient.ReplayFrom(uint64(replayFrom))
+	}
 	if err := obcEHClient.Start(); err != nil {
 		fmt.Printf("could not start chat %s\n", err)
 		obcEHClient.Stop()
@@ -72,19 +93,31 @@ func createEventClient(eventAddress string) *adapter {
 
 func main() {
 	var eventAddress string
+	var chaincodeID string
 	flag.StringVar(&eventAddress, "events-address", "0.0.0.0:31315", "address of events server")
+	flag.StringVar(&chaincodeID, "events-from-chaincode", "", "listen to the events of the chaincode with this name")
+	filter := flag.String("events-filter", "", "listen to the chaincode events matching this filter, e.g. 'chaincodeID == mycc && eventName matches \"transfer.*\"'")
+	replayFrom := flag.Int64("replay-from", -1, "replay the events of the blocks committed from this block number before the live ones")
 	flag.Parse()
 
 	fmt.Printf("Event Address: %s\n", eventAddress)
 
-	a := createEventClient(eventAddress)
+	a := createEventClient(eventAddress, chaincodeID, *filter, *replayFrom)
 	if a == nil {
 		fmt.Printf("Error creating event client\n")
 		return
 	}
 
 	for {
-		b := <-a.notfy
+		var b *pb.Event_Block
+		select {
+		case b = <-a.notfy:
+		case ce := <-a.cEvent:
+			fmt.Printf("Received chaincode event\n")
+			fmt.Printf("------------------------\n")
+			fmt.Printf("Chaincode Event:%v\n", ce)
+			continue
+		}
 		if b.Block.NonHashData.TransactionResults == nil {
 			fmt.Printf("INVALID BLOCK ... NO TRANSACTION RESULTS %v\n", b)
 		} else {
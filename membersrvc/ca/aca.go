@@ -21,22 +21,18 @@ import (
 	"errors"
 	"fmt"
 	"google/protobuf"
-	"math/big"
 	"strings"
 	"time"
 
-	"crypto/ecdsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 
-	"database/sql"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
-	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 )
 
@@ -77,7 +73,7 @@ func IsAttributeOID(oid asn1.ObjectIdentifier) bool {
 	return ACAAttribute[l-1] < oid[l-1]
 }
 
-func initializeACATables(db *sql.DB) error {
+func initializeACATables(db *DB) error {
 	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Attributes (row INTEGER PRIMARY KEY, id VARCHAR(64), affiliation VARCHAR(64), attributeName VARCHAR(64), validFrom DATETIME, validTo DATETIME,  attributeValue BLOB)"); err != nil {
 		return err
 	}
@@ -308,7 +304,7 @@ func (aca *ACA) populateAttributes(attrs []*AttributePair) error {
 	return nil
 }
 
-func (aca *ACA) populateAttribute(tx *sql.Tx, attr *AttributePair) error {
+func (aca *ACA) populateAttribute(tx *Tx, attr *AttributePair) error {
 	fmt.Printf("*********************** ATTR %v %v %v\n", attr.GetID(), attr.attributeName, string(attr.attributeValue))
 
 	var count int
@@ -386,18 +382,11 @@ func (acap *ACAP) FetchAttributes(ctx context.Context, in *pb.ACAFetchAttrReq) (
 		return &pb.ACAFetchAttrResp{Status: pb.ACAFetchAttrResp_FAILURE}, errors.New("Error getting ECA certificate.")
 	}
 
-	ecaPub := cert.PublicKey.(*ecdsa.PublicKey)
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(in.Signature.R)
-	s.UnmarshalText(in.Signature.S)
-
+	sig := in.Signature
 	in.Signature = nil
 
-	hash := primitives.NewHash()
 	raw, _ := proto.Marshal(in)
-	hash.Write(raw)
-
-	if ecdsa.Verify(ecaPub, hash.Sum(nil), r, s) == false {
+	if !verifySignature(cert.PublicKey, raw, sig) {
 		return &pb.ACAFetchAttrResp{Status: pb.ACAFetchAttrResp_FAILURE, Msg: "Signature does not verify"}, nil
 	}
 
@@ -427,16 +416,11 @@ func (acap *ACAP) createRequestAttributeResponse(status pb.ACAAttrResp_StatusCod
 		return &pb.ACAAttrResp{Status: pb.ACAAttrResp_FAILURE, Cert: nil, Signature: nil}
 	}
 
-	r, s, err := primitives.ECDSASignDirect(acap.aca.priv, rawReq)
+	resp.Signature, err = acap.aca.sign(rawReq)
 	if err != nil {
 		return &pb.ACAAttrResp{Status: pb.ACAAttrResp_FAILURE, Cert: nil, Signature: nil}
 	}
 
-	R, _ := r.MarshalText()
-	S, _ := s.MarshalText()
-
-	resp.Signature = &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
-
 	return resp
 }
 
@@ -476,17 +460,11 @@ func (acap *ACAP) RequestAttributes(ctx context.Context, in *pb.ACAAttrReq) (*pb
 		return acap.createRequestAttributeResponse(pb.ACAAttrResp_FAILURE, nil), errors.New("Error getting TCA certificate.")
 	}
 
-	tcaPub := cert.PublicKey.(*ecdsa.PublicKey)
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(in.Signature.R)
-	s.UnmarshalText(in.Signature.S)
-
+	sig := in.Signature
 	in.Signature = nil
 
-	hash := primitives.NewHash()
 	raw, _ := proto.Marshal(in)
-	hash.Write(raw)
-	if ecdsa.Verify(tcaPub, hash.Sum(nil), r, s) == false {
+	if !verifySignature(cert.PublicKey, raw, sig) {
 		return acap.createRequestAttributeResponse(pb.ACAAttrResp_FAILURE, nil), errors.New("Signature does not verify")
 	}
 
@@ -17,7 +17,8 @@ limitations under the License.
 package ca
 
 import (
-	"crypto/ecdsa"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -25,7 +26,6 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"errors"
-	"io/ioutil"
 	"math/big"
 	"os"
 	"strconv"
@@ -42,11 +42,11 @@ import (
 
 // CA is the base certificate authority.
 type CA struct {
-	db *sql.DB
+	db *DB
 
 	path string
 
-	priv *ecdsa.PrivateKey
+	priv crypto.Signer
 	cert *x509.Certificate
 	raw  []byte
 }
@@ -190,9 +190,9 @@ func (spec *CertificateSpec) GetExtensions() *[]pkix.Extension {
 }
 
 // TableInitializer is a function type for table initialization
-type TableInitializer func(*sql.DB) error
+type TableInitializer func(*DB) error
 
-func initializeCommonTables(db *sql.DB) error {
+func initializeCommonTables(db *DB) error {
 	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Certificates (row INTEGER PRIMARY KEY, id VARCHAR(64), timestamp INTEGER, usage INTEGER, cert BLOB, hash BLOB, kdfkey BLOB)"); err != nil {
 		return err
 	}
@@ -202,6 +202,14 @@ func initializeCommonTables(db *sql.DB) error {
 	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))"); err != nil {
 		return err
 	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Revocations (row INTEGER PRIMARY KEY, id VARCHAR(64), serial VARCHAR(64), hash BLOB, timestamp INTEGER)"); err != nil {
+		return err
+	}
+	if db.shared {
+		if _, err := db.Exec("CREATE TABLE IF NOT EXISTS KeyMaterial (name VARCHAR(64) PRIMARY KEY, material BLOB)"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -219,7 +227,7 @@ func NewCA(name string, initTables TableInitializer) *CA {
 	}
 
 	// open or create certificate database
-	db, err := sql.Open("sqlite3", ca.path+"/"+name+".db")
+	db, err := openDatabase(name, ca.path)
 	if err != nil {
 		Panic.Panicln(err)
 	}
@@ -243,7 +251,7 @@ func NewCA(name string, initTables TableInitializer) *CA {
 	// read CA certificate, or create a self-signed CA certificate
 	raw, err := ca.readCACertificate(name)
 	if err != nil {
-		raw = ca.createCACertificate(name, &ca.priv.PublicKey)
+		raw = ca.createCACertificate(name, ca.priv.Public())
 	}
 	cert, err := x509.ParseCertificate(raw)
 	if err != nil {
@@ -261,35 +269,41 @@ func (ca *CA) Close() {
 	ca.db.Close()
 }
 
-func (ca *CA) createCAKeyPair(name string) *ecdsa.PrivateKey {
+// getSignatureSuite returns the signature suite of the keys of the CAs,
+// configured by security.signatureAlgorithm
+func getSignatureSuite() (primitives.SignatureSuite, error) {
+	return primitives.GetSignatureSuite(viper.GetString("security.signatureAlgorithm"))
+}
+
+func (ca *CA) createCAKeyPair(name string) crypto.Signer {
 	Trace.Println("Creating CA key pair.")
 
-	curve := primitives.GetDefaultCurve()
+	suite, err := getSignatureSuite()
+	if err != nil {
+		Panic.Panicln(err)
+	}
 
-	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
-	if err == nil {
-		raw, _ := x509.MarshalECPrivateKey(priv)
-		cooked := pem.EncodeToMemory(
-			&pem.Block{
-				Type:  "ECDSA PRIVATE KEY",
-				Bytes: raw,
-			})
-		err = ioutil.WriteFile(ca.path+"/"+name+".priv", cooked, 0644)
+	// another replica may have created the key pair in the meantime
+	cooked, err := ca.readOrCreateKeyMaterial(name+".priv", func() ([]byte, error) {
+		priv, err := suite.GenerateKey()
 		if err != nil {
-			Panic.Panicln(err)
+			return nil, err
 		}
+		return primitives.PrivateKeyToPEM(priv, nil)
+	})
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	priv, err := parseCAPrivateKey(cooked)
+	if err != nil {
+		Panic.Panicln(err)
+	}
 
-		raw, _ = x509.MarshalPKIXPublicKey(&priv.PublicKey)
-		cooked = pem.EncodeToMemory(
-			&pem.Block{
-				Type:  "ECDSA PUBLIC KEY",
-				Bytes: raw,
-			})
-		err = ioutil.WriteFile(ca.path+"/"+name+".pub", cooked, 0644)
-		if err != nil {
-			Panic.Panicln(err)
-		}
+	cooked, err = primitives.PublicKeyToPEM(priv.Public(), nil)
+	if err != nil {
+		Panic.Panicln(err)
 	}
+	err = ca.writeKeyMaterial(name+".pub", cooked)
 	if err != nil {
 		Panic.Panicln(err)
 	}
@@ -297,43 +311,58 @@ func (ca *CA) createCAKeyPair(name string) *ecdsa.PrivateKey {
 	return priv
 }
 
-func (ca *CA) readCAPrivateKey(name string) (*ecdsa.PrivateKey, error) {
+func (ca *CA) readCAPrivateKey(name string) (crypto.Signer, error) {
 	Trace.Println("Reading CA private key.")
 
-	cooked, err := ioutil.ReadFile(ca.path + "/" + name + ".priv")
+	cooked, err := ca.readKeyMaterial(name + ".priv")
 	if err != nil {
 		return nil, err
 	}
+	return parseCAPrivateKey(cooked)
+}
 
-	block, _ := pem.Decode(cooked)
-	return x509.ParseECPrivateKey(block.Bytes)
+func parseCAPrivateKey(cooked []byte) (crypto.Signer, error) {
+	priv, err := primitives.PEMtoPrivateKey(cooked, nil)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("Invalid CA private key.")
+	}
+	return signer, nil
 }
 
-func (ca *CA) createCACertificate(name string, pub *ecdsa.PublicKey) []byte {
+func (ca *CA) createCACertificate(name string, pub interface{}) []byte {
 	Trace.Println("Creating CA certificate.")
 
-	raw, err := ca.newCertificate(name, pub, x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign, nil)
-	if err != nil {
-		Panic.Panicln(err)
-	}
+	cooked, err := ca.readOrCreateKeyMaterial(name+".cert", func() ([]byte, error) {
+		raw, err := ca.newCertificate(name, pub, x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign, nil)
+		if err != nil {
+			return nil, err
+		}
 
-	cooked := pem.EncodeToMemory(
-		&pem.Block{
-			Type:  "CERTIFICATE",
-			Bytes: raw,
-		})
-	err = ioutil.WriteFile(ca.path+"/"+name+".cert", cooked, 0644)
+		return pem.EncodeToMemory(
+			&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: raw,
+			}), nil
+	})
 	if err != nil {
 		Panic.Panicln(err)
 	}
 
-	return raw
+	block, _ := pem.Decode(cooked)
+	if block == nil {
+		Panic.Panicln("Invalid CA certificate.")
+	}
+	return block.Bytes
 }
 
 func (ca *CA) readCACertificate(name string) ([]byte, error) {
 	Trace.Println("Reading CA certificate.")
 
-	cooked, err := ioutil.ReadFile(ca.path + "/" + name + ".cert")
+	cooked, err := ca.readKeyMaterial(name + ".cert")
 	if err != nil {
 		return nil, err
 	}
@@ -400,7 +429,7 @@ func (ca *CA) newCertificateFromSpec(spec *CertificateSpec) ([]byte, error) {
 		NotAfter:  *notAfter,
 
 		SubjectKeyId:       *spec.GetSubjectKeyID(),
-		SignatureAlgorithm: spec.GetSignatureAlgorithm(),
+		SignatureAlgorithm: ca.getSignatureAlgorithm(spec),
 		KeyUsage:           spec.GetUsage(),
 
 		BasicConstraintsValid: true,
@@ -429,11 +458,57 @@ func (ca *CA) newCertificateFromSpec(spec *CertificateSpec) ([]byte, error) {
 	return raw, err
 }
 
+// getSignatureAlgorithm returns the algorithm the CA signs the certificate of
+// spec with, Ed25519 keys having a single one
+func (ca *CA) getSignatureAlgorithm(spec *CertificateSpec) x509.SignatureAlgorithm {
+	if _, ok := ca.priv.Public().(ed25519.PublicKey); ok {
+		return x509.PureEd25519
+	}
+	return spec.GetSignatureAlgorithm()
+}
+
+// cryptoTypeOf returns the type of the public key in the messages of the CAs
+func cryptoTypeOf(pub interface{}) (pb.CryptoType, error) {
+	suite, err := primitives.GetSignatureSuiteOf(pub)
+	if err != nil {
+		return pb.CryptoType_ECDSA, err
+	}
+	if primitives.IsECDSASignatureSuite(suite) {
+		return pb.CryptoType_ECDSA, nil
+	}
+	return pb.CryptoType_ED25519, nil
+}
+
+// sign signs raw with the key of the CA
+func (ca *CA) sign(raw []byte) (*pb.Signature, error) {
+	cryptoType, err := cryptoTypeOf(ca.priv.Public())
+	if err != nil {
+		return nil, err
+	}
+	R, S, err := primitives.SignDirect(ca.priv, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Signature{Type: cryptoType, R: R, S: S}, nil
+}
+
+// verifySignature returns whether sig is a signature of raw under pub, of the
+// type of pub
+func verifySignature(pub interface{}, raw []byte, sig *pb.Signature) bool {
+	cryptoType, err := cryptoTypeOf(pub)
+	if err != nil || sig == nil || sig.Type != cryptoType {
+		return false
+	}
+	ok, err := primitives.VerifyDirect(pub, raw, sig.R, sig.S)
+	return err == nil && ok
+}
+
 func (ca *CA) readCertificateByKeyUsage(id string, usage x509.KeyUsage) ([]byte, error) {
 	Trace.Printf("Reading certificate for %s and usage %v", id, usage)
 
 	var raw []byte
-	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=?", id, usage).Scan(&raw)
+	// the latest certificate, enrollment certificates being renewed
+	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=? ORDER BY row DESC", id, usage).Scan(&raw)
 
 	Trace.Printf("err %v", err)
 
@@ -456,7 +531,7 @@ func (ca *CA) readCertificates(id string, opt ...int64) (*sql.Rows, error) {
 		return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=? AND timestamp=? ORDER BY usage", id, opt[0])
 	}
 
-	return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=?", id)
+	return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=? ORDER BY row", id)
 }
 
 func (ca *CA) readCertificateSets(id string, start, end int64) (*sql.Rows, error) {
@@ -475,6 +550,101 @@ func (ca *CA) readCertificateByHash(hash []byte) ([]byte, error) {
 	return raw, err
 }
 
+func (ca *CA) readCertificateOwner(raw []byte) (string, int64, error) {
+	Trace.Println("Reading owner of certificate.")
+
+	hash := primitives.NewHash()
+	hash.Write(raw)
+
+	var id string
+	var timestamp int64
+	err := ca.db.QueryRow("SELECT id, timestamp FROM Certificates WHERE hash=?", hash.Sum(nil)).Scan(&id, &timestamp)
+
+	return id, timestamp, err
+}
+
+// newSerialNumber returns a random serial number, the revocation lists
+// identifying the certificates by serial number.
+//
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func (ca *CA) revokeCertificate(id string, raw []byte, timestamp int64) error {
+	Trace.Println("Revoking certificate of " + id + ".")
+
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+	// certificates issued before serial numbers were random all have serial number 1
+	if cert.SerialNumber.Cmp(big.NewInt(1)) == 0 {
+		return errors.New("Certificate cannot be revoked, it has the serial number shared by the certificates issued before revocation was supported.")
+	}
+
+	if _, revoked, err := ca.readRevocation(raw); err != nil || revoked {
+		return err
+	}
+
+	hash := primitives.NewHash()
+	hash.Write(raw)
+	if _, err = ca.db.Exec("INSERT INTO Revocations (id, serial, hash, timestamp) VALUES (?, ?, ?, ?)", id, cert.SerialNumber.String(), hash.Sum(nil), timestamp); err != nil {
+		Error.Println(err)
+	}
+	return err
+}
+
+func (ca *CA) readRevocation(raw []byte) (int64, bool, error) {
+	hash := primitives.NewHash()
+	hash.Write(raw)
+
+	var timestamp int64
+	err := ca.db.QueryRow("SELECT timestamp FROM Revocations WHERE hash=?", hash.Sum(nil)).Scan(&timestamp)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+
+	return timestamp, err == nil, err
+}
+
+// readRevocations returns all the certificates revoked so far.
+//
+func (ca *CA) readRevocations() ([]pkix.RevokedCertificate, error) {
+	rows, err := ca.db.Query("SELECT serial, timestamp FROM Revocations ORDER BY row")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revoked []pkix.RevokedCertificate
+	for rows.Next() {
+		var serial string
+		var timestamp int64
+		if err = rows.Scan(&serial, &timestamp); err != nil {
+			return nil, err
+		}
+		serialNumber, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			return nil, errors.New("Invalid serial number " + serial + " of revoked certificate.")
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serialNumber, RevocationTime: time.Unix(0, timestamp).UTC()})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// createCRL creates the certificate revocation list of the revoked
+// certificates, signed by the CA.
+//
+func (ca *CA) createCRL(revoked []pkix.RevokedCertificate, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	Trace.Println("Creating certificate revocation list.")
+
+	return ca.cert.CreateCRL(rand.Reader, ca.priv, revoked, thisUpdate, nextUpdate)
+}
+
 func (ca *CA) isValidAffiliation(affiliation string) (bool, error) {
 	Trace.Println("Validating affiliation: " + affiliation)
 
@@ -608,7 +778,9 @@ func (ca *CA) registerAffiliationGroup(name string, parentName string) error {
 
 	Trace.Println("Registering affiliation group " + name + " parent " + parentName + ".")
 
-	var parentID int
+	// the root groups have no parent, which is NULL for the databases
+	// enforcing the foreign key on the parent
+	var parentID interface{}
 	var err error
 	var count int
 	err = ca.db.QueryRow("SELECT count(row) FROM AffiliationGroups WHERE name=?", name).Scan(&count)
@@ -620,10 +792,12 @@ func (ca *CA) registerAffiliationGroup(name string, parentName string) error {
 	}
 
 	if strings.Compare(parentName, "") != 0 {
-		err = ca.db.QueryRow("SELECT row FROM AffiliationGroups WHERE name=?", parentName).Scan(&parentID)
+		var row int64
+		err = ca.db.QueryRow("SELECT row FROM AffiliationGroups WHERE name=?", parentName).Scan(&row)
 		if err != nil {
 			return err
 		}
+		parentID = row
 	}
 
 	_, err = ca.db.Exec("INSERT INTO AffiliationGroups (name, parent) VALUES (?, ?)", name, parentID)
@@ -688,7 +862,8 @@ func (ca *CA) readRole(id string) int {
 func (ca *CA) readAffiliationGroups() ([]*AffiliationGroup, error) {
 	Trace.Println("Reading affilition groups.")
 
-	rows, err := ca.db.Query("SELECT row, name, parent FROM AffiliationGroups")
+	// the parent of root groups is NULL, or 0 in the databases created before
+	rows, err := ca.db.Query("SELECT row, name, COALESCE(parent, 0) FROM AffiliationGroups")
 	if err != nil {
 		return nil, err
 	}
@@ -780,6 +955,19 @@ func (ca *CA) canRegister(registrar string, newMemberRole string, newMemberMetad
 	return registrarMetadata.canRegister(registrar, newMemberRole, newMemberMetadata)
 }
 
+// Return whether member 'id' is a registrar, allowed to register members of some role
+func (ca *CA) isRegistrar(id string) bool {
+	var metadataStr string
+	if err := ca.db.QueryRow("SELECT metadata FROM Users WHERE id=?", id).Scan(&metadataStr); err != nil {
+		return false
+	}
+	metadata, err := newMemberMetadata(metadataStr)
+	if err != nil || metadata == nil {
+		return false
+	}
+	return len(metadata.Registrar.Roles) > 0
+}
+
 // Convert a string to a MemberMetadata
 func newMemberMetadata(metadata string) (*MemberMetadata, error) {
 	if metadata == "" {
@@ -798,6 +986,7 @@ func newMemberMetadata(metadata string) (*MemberMetadata, error) {
 // MemberMetadata Additional member metadata
 type MemberMetadata struct {
 	Registrar Registrar `json:"registrar"`
+	Directory bool      `json:"directory,omitempty"` // authenticated by the directory until enrolled
 }
 
 // Registrar metadata
@@ -21,8 +21,6 @@ import (
 	"os"
 	"testing"
 
-	"database/sql"
-
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/spf13/viper"
@@ -93,6 +91,6 @@ func TestNewCA(t *testing.T) {
 }
 
 // Empty initializer for CA
-func initializeTables(db *sql.DB) error {
+func initializeTables(db *DB) error {
 	return nil
 }
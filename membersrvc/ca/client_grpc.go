@@ -4,6 +4,7 @@ import (
 	"time"
 
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	obc "github.com/hyperledger/fabric/protos"
 	"google.golang.org/grpc"
 
 	"github.com/spf13/viper"
@@ -28,3 +29,15 @@ func GetACAClient() (*grpc.ClientConn, pb.ACAPClient, error) {
 
 	return conn, client, nil
 }
+
+//GetDevopsClient returns a client to the peer the ECA publishes its certificate revocation lists to.
+func GetDevopsClient() (*grpc.ClientConn, obc.DevopsClient, error) {
+	conn, err := GetClientConn(viper.GetString("eca.crl.peer.address"), viper.GetString("eca.crl.peer.server-name"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := obc.NewDevopsClient(conn)
+
+	return conn, client, nil
+}
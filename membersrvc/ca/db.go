@@ -0,0 +1,238 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// The CAs store their state in a database configured by server.database. By
+// default each CA has its own SQLite database in the CA directory, and the
+// keys of the CAs are files of that directory. Replicas of the CAs serving
+// behind a load balancer instead share a PostgreSQL or MySQL database, in
+// which the tables of each CA are prefixed by the name of the CA and which
+// also stores the keys, so that the replicas keep no state of their own. The
+// statements of the CAs are written for SQLite and adapted to the dialect of
+// the database. The drivers of PostgreSQL and MySQL are compiled in with the
+// postgres and mysql build tags respectively.
+
+const (
+	sqliteDriver   = "sqlite3"
+	postgresDriver = "postgres"
+	mysqlDriver    = "mysql"
+)
+
+// dialect adapts the statements written for SQLite to a database
+type dialect struct {
+	types    *strings.Replacer // column types of the CREATE TABLE statements
+	quote    string            // quotes the column names reserved by the database
+	numbered bool              // placeholders are $1, $2, ... rather than ?
+}
+
+var dialects = map[string]*dialect{
+	sqliteDriver: {
+		quote: "\"",
+	},
+	postgresDriver: {
+		types:    strings.NewReplacer("INTEGER PRIMARY KEY", "BIGSERIAL PRIMARY KEY", "INTEGER", "BIGINT", "BLOB", "BYTEA", "DATETIME", "TIMESTAMP"),
+		quote:    "\"",
+		numbered: true,
+	},
+	mysqlDriver: {
+		types: strings.NewReplacer("INTEGER PRIMARY KEY", "BIGINT PRIMARY KEY AUTO_INCREMENT", "INTEGER", "BIGINT"),
+		quote: "`",
+	},
+}
+
+var (
+	// the tables private to each CA, prefixed by the name of the CA in a
+	// shared database
+	caTables = regexp.MustCompile(`\b(Certificates|Users|AffiliationGroups|Revocations|Attributes|TCertificateSets|TCertificates)\b`)
+
+	// the column names reserved by PostgreSQL or MySQL
+	reservedColumns = regexp.MustCompile(`\b(row|key|usage)\b`)
+)
+
+// DB is the database of a CA.
+type DB struct {
+	*sql.DB
+	dialect *dialect
+	prefix  string
+	shared  bool
+}
+
+// Tx is a transaction on the database of a CA.
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+// openDatabase opens the database of CA name, whose directory is path
+func openDatabase(name string, path string) (*DB, error) {
+	driver := viper.GetString("server.database.driver")
+	if driver == "" || driver == sqliteDriver {
+		db, err := sql.Open(sqliteDriver, path+"/"+name+".db")
+		if err != nil {
+			return nil, err
+		}
+		return &DB{DB: db, dialect: dialects[sqliteDriver]}, nil
+	}
+
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("Invalid database driver %s, expected %s, %s or %s", driver, sqliteDriver, postgresDriver, mysqlDriver)
+	}
+	registered := false
+	for _, drv := range sql.Drivers() {
+		registered = registered || drv == driver
+	}
+	if !registered {
+		return nil, fmt.Errorf("Database driver %s is not compiled in, build the CA with the %s tag", driver, driver)
+	}
+	db, err := sql.Open(driver, viper.GetString("server.database.datasource"))
+	if err != nil {
+		return nil, err
+	}
+	return &DB{DB: db, dialect: d, prefix: name + "_", shared: true}, nil
+}
+
+// rebind adapts a statement written for SQLite to the database
+func (db *DB) rebind(query string) string {
+	if !db.shared {
+		return query
+	}
+	if db.dialect.types != nil && strings.HasPrefix(query, "CREATE TABLE") {
+		query = db.dialect.types.Replace(query)
+	}
+	query = caTables.ReplaceAllString(query, db.prefix+"$1")
+	query = reservedColumns.ReplaceAllString(query, db.dialect.quote+"$1"+db.dialect.quote)
+	if !db.dialect.numbered {
+		return query
+	}
+
+	var rebound []byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			rebound = append(rebound, query[i])
+			continue
+		}
+		n++
+		rebound = append(rebound, '$')
+		rebound = strconv.AppendInt(rebound, int64(n), 10)
+	}
+	return string(rebound)
+}
+
+// Exec executes a statement
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.rebind(query), args...)
+}
+
+// Query executes a query returning rows
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.rebind(query), args...)
+}
+
+// QueryRow executes a query returning at most one row
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.rebind(query), args...)
+}
+
+// Begin starts a transaction
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx, db}, nil
+}
+
+// Exec executes a statement in the transaction
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.db.rebind(query), args...)
+}
+
+// Query executes a query returning rows in the transaction
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.db.rebind(query), args...)
+}
+
+// QueryRow executes a query returning at most one row in the transaction
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.db.rebind(query), args...)
+}
+
+// readKeyMaterial reads the key material name, which is the file name of the
+// CA directory or the entry of the KeyMaterial table of a shared database
+func (ca *CA) readKeyMaterial(name string) ([]byte, error) {
+	if !ca.db.shared {
+		return ioutil.ReadFile(ca.path + "/" + name)
+	}
+	var raw []byte
+	err := ca.db.QueryRow("SELECT material FROM KeyMaterial WHERE name=?", name).Scan(&raw)
+	return raw, err
+}
+
+// writeKeyMaterial writes the key material name, replacing the previous one
+func (ca *CA) writeKeyMaterial(name string, raw []byte) error {
+	if !ca.db.shared {
+		return ioutil.WriteFile(ca.path+"/"+name, raw, 0644)
+	}
+	tx, err := ca.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM KeyMaterial WHERE name=?", name); err == nil {
+		_, err = tx.Exec("INSERT INTO KeyMaterial (name, material) VALUES (?, ?)", name, raw)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// readOrCreateKeyMaterial reads the key material name, or creates it if
+// there is none. Among replicas creating it at the same time, the first one
+// storing it wins and the others read it back.
+func (ca *CA) readOrCreateKeyMaterial(name string, create func() ([]byte, error)) ([]byte, error) {
+	if raw, err := ca.readKeyMaterial(name); err == nil {
+		return raw, nil
+	}
+	raw, err := create()
+	if err != nil {
+		return nil, err
+	}
+	if !ca.db.shared {
+		return raw, ca.writeKeyMaterial(name, raw)
+	}
+	if _, err = ca.db.Exec("INSERT INTO KeyMaterial (name, material) VALUES (?, ?)", name, raw); err != nil {
+		if stored, rerr := ca.readKeyMaterial(name); rerr == nil {
+			return stored, nil
+		}
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,30 @@
+// +build mysql
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import "testing"
+
+// TestMySQLDatabase runs the CA statements against the MySQL database of
+// the data source in CA_TEST_MYSQL_DATASOURCE, e.g.
+// root@tcp(localhost:3306)/ca_test?parseTime=true
+func TestMySQLDatabase(t *testing.T) {
+	db, drop := openTestDatabase(t, mysqlDriver, "CA_TEST_MYSQL_DATASOURCE")
+	defer drop()
+	testSharedDatabase(t, db)
+}
@@ -0,0 +1,30 @@
+// +build postgres
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import "testing"
+
+// TestPostgresDatabase runs the CA statements against the PostgreSQL
+// database of the data source in CA_TEST_POSTGRES_DATASOURCE, e.g.
+// postgres://postgres@localhost/ca_test?sslmode=disable
+func TestPostgresDatabase(t *testing.T) {
+	db, drop := openTestDatabase(t, postgresDriver, "CA_TEST_POSTGRES_DATASOURCE")
+	defer drop()
+	testSharedDatabase(t, db)
+}
@@ -0,0 +1,334 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+)
+
+func TestRebind(t *testing.T) {
+	query := "SELECT row, cert FROM Certificates WHERE id=? AND usage=? ORDER BY row"
+	expected := map[string]string{
+		postgresDriver: `SELECT "row", cert FROM eca_Certificates WHERE id=$1 AND "usage"=$2 ORDER BY "row"`,
+		mysqlDriver:    "SELECT `row`, cert FROM eca_Certificates WHERE id=? AND `usage`=? ORDER BY `row`",
+	}
+	for driver, rebound := range expected {
+		db := &DB{dialect: dialects[driver], prefix: "eca_", shared: true}
+		if q := db.rebind(query); q != rebound {
+			t.Errorf("Expected %s to rebind to %s, got %s", driver, rebound, q)
+		}
+	}
+
+	db := &DB{dialect: dialects[postgresDriver], prefix: "tca_", shared: true}
+	ddl := db.rebind("CREATE TABLE IF NOT EXISTS TCertificateSets (row INTEGER PRIMARY KEY, enrollmentID VARCHAR(64), timestamp INTEGER, nonce BLOB, kdfkey BLOB)")
+	if ddl != `CREATE TABLE IF NOT EXISTS tca_TCertificateSets ("row" BIGSERIAL PRIMARY KEY, enrollmentID VARCHAR(64), timestamp BIGINT, nonce BYTEA, kdfkey BYTEA)` {
+		t.Errorf("Unexpected PostgreSQL table definition %s", ddl)
+	}
+
+	db = &DB{dialect: dialects[sqliteDriver]}
+	if q := db.rebind(query); q != query {
+		t.Errorf("Expected the statements of a SQLite database of its own to be unchanged, got %s", q)
+	}
+}
+
+func TestOpenDatabaseWithoutDriver(t *testing.T) {
+	defer viper.Set("server.database.driver", viper.GetString("server.database.driver"))
+
+	viper.Set("server.database.driver", "oracle")
+	if _, err := openDatabase("eca", os.TempDir()); err == nil {
+		t.Fatal("Expected an unsupported database driver to be rejected")
+	}
+	for _, driver := range []string{postgresDriver, mysqlDriver} {
+		registered := false
+		for _, drv := range sql.Drivers() {
+			registered = registered || drv == driver
+		}
+		if registered {
+			continue
+		}
+		viper.Set("server.database.driver", driver)
+		if _, err := openDatabase("eca", os.TempDir()); err == nil {
+			t.Fatalf("Expected the %s driver, which is not compiled in, to be rejected", driver)
+		}
+	}
+}
+
+func TestSharedKeyMaterial(t *testing.T) {
+	path, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	// a SQLite database standing for a shared one exercises the adapted
+	// statements
+	raw, err := sql.Open(sqliteDriver, path+"/shared.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{DB: raw, dialect: dialects[sqliteDriver], prefix: "shared_", shared: true}
+	defer db.Close()
+	if err = initializeCommonTables(db); err != nil {
+		t.Fatalf("Failed creating the tables: %s", err)
+	}
+	replica := &CA{db: db, path: path + "/replica"}
+
+	first, err := replica.readOrCreateKeyMaterial("obc.aes", func() ([]byte, error) { return []byte("first"), nil })
+	if err != nil || string(first) != "first" {
+		t.Fatalf("Failed creating the key material: %v %s", err, first)
+	}
+	other, err := replica.readOrCreateKeyMaterial("obc.aes", func() ([]byte, error) { return []byte("other"), nil })
+	if err != nil || !bytes.Equal(other, first) {
+		t.Fatalf("Expected the key material created first to be read back, got %v %s", err, other)
+	}
+	if _, err = os.Stat(replica.path); !os.IsNotExist(err) {
+		t.Fatal("Expected the key material not to be written to the CA directory")
+	}
+	if err = replica.writeKeyMaterial("obc.aes", []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := replica.readKeyMaterial("obc.aes"); err != nil || string(raw) != "replaced" {
+		t.Fatalf("Expected the key material to be replaced, got %v %s", err, raw)
+	}
+
+	if _, err = db.Exec("INSERT INTO Users (id, enrollmentId, token, role, metadata, state) VALUES (?, ?, ?, ?, ?, ?)", "jim", "jim", []byte("tok"), 1, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	var key []byte
+	if err = db.QueryRow("SELECT key FROM shared_Users WHERE id=?", "jim").Scan(&key); err != nil {
+		t.Fatalf("Expected the tables of the CA to be prefixed: %s", err)
+	}
+}
+
+func TestSharedDatabaseForeignKeys(t *testing.T) {
+	path, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	// unlike SQLite by default, PostgreSQL and MySQL enforce the foreign keys
+	raw, err := sql.Open(sqliteDriver, path+"/shared.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.SetMaxOpenConns(1)
+	if _, err = raw.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{DB: raw, dialect: dialects[sqliteDriver], prefix: "shared_", shared: true}
+	defer db.Close()
+	testSharedDatabase(t, db)
+}
+
+// openTestDatabase opens the shared database of driver whose data source is
+// in the environment variable datasourceVar, skipping the test if it is unset.
+// The tables are prefixed by a name of their own, and dropped by the returned
+// function.
+func openTestDatabase(t *testing.T, driver string, datasourceVar string) (*DB, func()) {
+	datasource := os.Getenv(datasourceVar)
+	if datasource == "" {
+		t.Skipf("Set %s to run the test against a %s database", datasourceVar, driver)
+	}
+	defer viper.Set("server.database.driver", viper.GetString("server.database.driver"))
+	defer viper.Set("server.database.datasource", viper.GetString("server.database.datasource"))
+	viper.Set("server.database.driver", driver)
+	viper.Set("server.database.datasource", datasource)
+
+	db, err := openDatabase(fmt.Sprintf("test%d", time.Now().UnixNano()), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Ping(); err != nil {
+		t.Fatalf("Failed connecting to the %s database: %s", driver, err)
+	}
+	return db, func() {
+		for _, table := range []string{"TCertificates", "TCertificateSets", "Attributes", "Revocations", "AffiliationGroups", "Users", "Certificates"} {
+			db.Exec("DROP TABLE IF EXISTS " + table)
+		}
+		db.Exec("DELETE FROM KeyMaterial WHERE name LIKE ?", db.prefix+"%")
+		db.Close()
+	}
+}
+
+// testSharedDatabase runs the schema and the statements of the CAs against a
+// shared database
+func testSharedDatabase(t *testing.T, db *DB) {
+	for _, initTables := range []TableInitializer{initializeECATables, initializeTCATables, initializeACATables} {
+		if err := initTables(db); err != nil {
+			t.Fatalf("Failed creating the tables: %s", err)
+		}
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &CA{db: db, path: os.TempDir() + "/" + db.prefix, priv: priv}
+
+	// key material
+	keyName := db.prefix + "obc.aes"
+	if raw, err := ca.readOrCreateKeyMaterial(keyName, func() ([]byte, error) { return []byte("key"), nil }); err != nil || string(raw) != "key" {
+		t.Fatalf("Failed creating the key material: %v %s", err, raw)
+	}
+	if err = ca.writeKeyMaterial(keyName, []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := ca.readKeyMaterial(keyName); err != nil || string(raw) != "replaced" {
+		t.Fatalf("Expected the key material to be replaced, got %v %s", err, raw)
+	}
+
+	if ca.cert, err = x509.ParseCertificate(ca.createCACertificate(db.prefix+"ca", &priv.PublicKey)); err != nil {
+		t.Fatalf("Failed creating the CA certificate: %s", err)
+	}
+
+	// affiliation groups
+	if err = ca.registerAffiliationGroup("bank_a", ""); err != nil {
+		t.Fatalf("Failed registering a root affiliation group: %s", err)
+	}
+	if err = ca.registerAffiliationGroup("branch_1", "bank_a"); err != nil {
+		t.Fatalf("Failed registering an affiliation group: %s", err)
+	}
+	groups, err := ca.readAffiliationGroups()
+	if err != nil || len(groups) != 2 {
+		t.Fatalf("Expected 2 affiliation groups, got %v %v", err, groups)
+	}
+	for _, group := range groups {
+		if group.name == "branch_1" && (group.parent == nil || group.parent.name != "bank_a") {
+			t.Fatalf("Expected the parent of branch_1 to be bank_a, got %v", group.parent)
+		}
+		if group.name == "bank_a" && group.parentID != 0 {
+			t.Fatalf("Expected bank_a to have no parent, got %d", group.parentID)
+		}
+	}
+
+	// users
+	token, err := ca.registerUserWithEnrollID("jim", "jim\\bank_a\\1", pb.Role_CLIENT, "")
+	if err != nil {
+		t.Fatalf("Failed registering a user: %s", err)
+	}
+	if _, err = db.Exec("UPDATE Users SET token=?, state=?, key=? WHERE id=? AND state=?", []byte(token), 1, []byte("pub"), "jim", 0); err != nil {
+		t.Fatal(err)
+	}
+	var role, state int
+	var tok, key []byte
+	var enrollID string
+	if err = ca.readUser("jim").Scan(&role, &tok, &state, &key, &enrollID); err != nil {
+		t.Fatalf("Failed reading the user: %s", err)
+	}
+	if role != int(pb.Role_CLIENT) || string(tok) != token || state != 1 || string(key) != "pub" || enrollID != "jim\\bank_a\\1" {
+		t.Fatalf("Unexpected user %d %s %d %s %s", role, tok, state, key, enrollID)
+	}
+	rows, err := ca.readUsers(int(pb.Role_CLIENT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := 0
+	for rows.Next() {
+		users++
+	}
+	rows.Close()
+	if users != 1 {
+		t.Fatalf("Expected 1 client, got %d", users)
+	}
+
+	// certificates and revocations
+	now := time.Now().UnixNano()
+	serial, err := newSerialNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := NewDefaultPeriodCertificateSpec("jim", serial, &priv.PublicKey, x509.KeyUsageDigitalSignature)
+	raw, err := ca.createCertificateFromSpec(spec, now, []byte("kdf"), true)
+	if err != nil {
+		t.Fatalf("Failed creating a certificate: %s", err)
+	}
+	if cert, err := ca.readCertificateByKeyUsage("jim", x509.KeyUsageDigitalSignature); err != nil || !bytes.Equal(cert, raw) {
+		t.Fatalf("Failed reading the certificate by key usage: %v", err)
+	}
+	if id, ts, err := ca.readCertificateOwner(raw); err != nil || id != "jim" || ts != now {
+		t.Fatalf("Unexpected owner of the certificate %v %s %d", err, id, ts)
+	}
+	if rows, err = ca.readCertificateSets("jim", now, now); err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal("Expected the certificate in the certificate sets")
+	}
+	rows.Close()
+	if err = ca.revokeCertificate("jim", raw, now); err != nil {
+		t.Fatalf("Failed revoking the certificate: %s", err)
+	}
+	if ts, revoked, err := ca.readRevocation(raw); err != nil || !revoked || ts != now {
+		t.Fatalf("Expected the certificate to be revoked, got %v %v %d", err, revoked, ts)
+	}
+	revoked, err := ca.readRevocations()
+	if err != nil || len(revoked) != 1 || revoked[0].SerialNumber.Cmp(serial) != 0 {
+		t.Fatalf("Expected the certificate in the revocations, got %v %v", err, revoked)
+	}
+	if _, err = ca.createCRL(revoked, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed creating the CRL: %s", err)
+	}
+
+	// TCert sets and attributes
+	tca := &TCA{CA: ca}
+	if err = tca.persistCertificateSet("jim", now, []byte("nonce"), []byte("kdf")); err != nil {
+		t.Fatal(err)
+	}
+	if sets, err := tca.getCertificateSets("jim"); err != nil || len(sets) != 1 || sets[0].Ts != now {
+		t.Fatalf("Unexpected certificate sets %v %v", err, sets)
+	}
+	ecert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tca.persistCertificates(ecert, []*pb.TCert{{Cert: raw}}); err != nil {
+		t.Fatal(err)
+	}
+	if tcerts, err := tca.readRevokedCertificates(revoked, time.Now()); err != nil || len(tcerts) != 1 {
+		t.Fatalf("Expected the TCert of the revoked certificate, got %v %v", err, tcerts)
+	}
+	aca := &ACA{CA: ca}
+	owner := &AttributeOwner{"jim", "bank_a"}
+	validFrom := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	attr := &AttributePair{owner, "company", []byte("ACompany"), validFrom, validFrom.Add(48 * time.Hour)}
+	if err = aca.populateAttributes([]*AttributePair{attr}); err != nil {
+		t.Fatalf("Failed populating the attributes: %s", err)
+	}
+	if found, err := aca.findAttribute(owner, "company"); err != nil || found == nil || string(found.attributeValue) != "ACompany" {
+		t.Fatalf("Failed finding the attribute: %v %v", err, found)
+	}
+
+	if err = ca.deleteUser("jim"); err != nil {
+		t.Fatal(err)
+	}
+	if err = ca.readUser("jim").Scan(&role, &tok, &state, &key, &enrollID); err != sql.ErrNoRows {
+		t.Fatalf("Expected the user to be deleted, got %v", err)
+	}
+}
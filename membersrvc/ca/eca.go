@@ -30,8 +30,6 @@ import (
 	"encoding/pem"
 	"errors"
 	"google/protobuf"
-	"io/ioutil"
-	"math/big"
 	"strconv"
 	"strings"
 	"time"
@@ -41,6 +39,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	obc "github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -58,6 +57,8 @@ type ECA struct {
 	*CA
 	obcKey          []byte
 	obcPriv, obcPub []byte
+	directory       *directory
+	tca             *TCA
 }
 
 // ECAP serves the public GRPC interface of the ECA.
@@ -72,35 +73,28 @@ type ECAA struct {
 	eca *ECA
 }
 
-func initializeECATables(db *sql.DB) error {
+func initializeECATables(db *DB) error {
 	return initializeCommonTables(db)
 }
 
 // NewECA sets up a new ECA.
 //
 func NewECA() *ECA {
-	eca := &ECA{NewCA("eca", initializeECATables), nil, nil, nil}
+	eca := &ECA{NewCA("eca", initializeECATables), nil, nil, nil, nil, nil}
 
 	{
 		// read or create global symmetric encryption key
-		var cooked string
-
-		raw, err := ioutil.ReadFile(eca.path + "/obc.aes")
-		if err != nil {
+		raw, err := eca.readOrCreateKeyMaterial("obc.aes", func() ([]byte, error) {
 			rand := rand.Reader
 			key := make([]byte, 32) // AES-256
 			rand.Read(key)
-			cooked = base64.StdEncoding.EncodeToString(key)
-
-			err = ioutil.WriteFile(eca.path+"/obc.aes", []byte(cooked), 0644)
-			if err != nil {
-				Panic.Panicln(err)
-			}
-		} else {
-			cooked = string(raw)
+			return []byte(base64.StdEncoding.EncodeToString(key)), nil
+		})
+		if err != nil {
+			Panic.Panicln(err)
 		}
 
-		eca.obcKey, err = base64.StdEncoding.DecodeString(cooked)
+		eca.obcKey, err = base64.StdEncoding.DecodeString(string(raw))
 		if err != nil {
 			Panic.Panicln(err)
 		}
@@ -108,30 +102,29 @@ func NewECA() *ECA {
 
 	{
 		// read or create global ECDSA key pair for ECIES
-		var priv *ecdsa.PrivateKey
-		cooked, err := ioutil.ReadFile(eca.path + "/obc.ecies")
-		if err == nil {
-			block, _ := pem.Decode(cooked)
-			priv, err = x509.ParseECPrivateKey(block.Bytes)
-			if err != nil {
-				Panic.Panicln(err)
-			}
-		} else {
-			priv, err = ecdsa.GenerateKey(primitives.GetDefaultCurve(), rand.Reader)
+		cooked, err := eca.readOrCreateKeyMaterial("obc.ecies", func() ([]byte, error) {
+			priv, err := ecdsa.GenerateKey(primitives.GetDefaultCurve(), rand.Reader)
 			if err != nil {
-				Panic.Panicln(err)
+				return nil, err
 			}
 
 			raw, _ := x509.MarshalECPrivateKey(priv)
-			cooked = pem.EncodeToMemory(
+			return pem.EncodeToMemory(
 				&pem.Block{
 					Type:  "ECDSA PRIVATE KEY",
 					Bytes: raw,
-				})
-			err := ioutil.WriteFile(eca.path+"/obc.ecies", cooked, 0644)
-			if err != nil {
-				Panic.Panicln(err)
-			}
+				}), nil
+		})
+		if err != nil {
+			Panic.Panicln(err)
+		}
+		block, _ := pem.Decode(cooked)
+		if block == nil {
+			Panic.Panicln("Invalid ECIES private key.")
+		}
+		priv, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			Panic.Panicln(err)
 		}
 
 		eca.obcPriv = cooked
@@ -143,6 +136,12 @@ func NewECA() *ECA {
 			})
 	}
 
+	directory, err := newDirectory()
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	eca.directory = directory
+
 	eca.populateAffiliationGroupsTable()
 	eca.populateUsersTable()
 	return eca
@@ -247,19 +246,11 @@ func (ecap *ECAP) fetchAttributes(cert *pb.Cert) error {
 		return err
 	}
 
-	var r, s *big.Int
-
-	r, s, err = primitives.ECDSASignDirect(ecap.eca.priv, rawReq)
-
+	req.Signature, err = ecap.eca.sign(rawReq)
 	if err != nil {
 		return err
 	}
 
-	R, _ := r.MarshalText()
-	S, _ := s.MarshalText()
-
-	req.Signature = &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
-
 	resp, err := acaP.FetchAttributes(context.Background(), req)
 	if err != nil {
 		return err
@@ -283,6 +274,13 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 
 	id := in.Id.Id
 	err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
+	if ecap.eca.directory != nil && (err == sql.ErrNoRows || (err == nil && state == 0 && ecap.eca.isDirectoryUser(id))) {
+		// the directory checks the password of the users it registers
+		if err = ecap.eca.authenticateDirectoryUser(id, in.Tok.Tok); err == nil {
+			err = ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
+			tok = in.Tok.Tok
+		}
+	}
 	if err != nil {
 		errMsg := "Identity lookup error: " + err.Error()
 		Trace.Println(errMsg)
@@ -298,13 +296,14 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		return nil, err
 	}
 
-	fetchResult := pb.FetchAttrsResult{Status: pb.FetchAttrsResult_SUCCESS, Msg: ""}
 	switch {
 	case state == 0:
 		// initial request, create encryption challenge
 		tok = []byte(randomString(12))
 
-		_, err = ecap.eca.db.Exec("UPDATE Users SET token=?, state=?, key=? WHERE id=?", tok, 1, in.Enc.Key, id)
+		// the state only moves forward once, whichever replica of the ECA
+		// serves the request
+		err = ecap.eca.transitionUser("UPDATE Users SET token=?, state=?, key=? WHERE id=? AND state=?", tok, 1, in.Enc.Key, id, 0)
 		if err != nil {
 			Error.Println(err)
 			return nil, err
@@ -335,74 +334,224 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		sig := in.Sig
 		in.Sig = nil
 
-		r, s := big.NewInt(0), big.NewInt(0)
-		r.UnmarshalText(sig.R)
-		s.UnmarshalText(sig.S)
-
-		if in.Sign.Type != pb.CryptoType_ECDSA {
+		if in.Sign.Type != pb.CryptoType_ECDSA && in.Sign.Type != pb.CryptoType_ED25519 {
 			return nil, errors.New("Unsupported (signing) key type.")
 		}
 		skey, err := x509.ParsePKIXPublicKey(in.Sign.Key)
 		if err != nil {
 			return nil, err
 		}
+		if skeyType, err := cryptoTypeOf(skey); err != nil || skeyType != in.Sign.Type {
+			return nil, errors.New("Unsupported (signing) key type.")
+		}
+
+		// TCerts are derived from the enrollment key of clients
+		if in.Sign.Type != pb.CryptoType_ECDSA && ecap.eca.readRole(id) == int(pb.Role_CLIENT) {
+			return nil, errors.New("Clients must enroll with an ECDSA signing key.")
+		}
 
-		hash := primitives.NewHash()
 		raw, _ := proto.Marshal(in)
-		hash.Write(raw)
-		if ecdsa.Verify(skey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+		if !verifySignature(skey, raw, sig) {
 			return nil, errors.New("Signature verification failed.")
 		}
 
-		// create new certificate pair
-		ts := time.Now().Add(-1 * time.Minute).UnixNano()
+		return ecap.issueCertificatePair(id, enrollID, role, skey, ekey, func() error {
+			return ecap.eca.transitionUser("UPDATE Users SET state=? WHERE id=? AND state=?", 2, id, 1)
+		})
+	}
 
-		spec := NewDefaultCertificateSpecWithCommonName(id, enrollID, skey.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
-		sraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
-		if err != nil {
-			Error.Println(err)
-			return nil, err
-		}
+	return nil, errors.New("Invalid (=expired) certificate creation token provided.")
+}
 
-		spec = NewDefaultCertificateSpecWithCommonName(id, enrollID, ekey.(*ecdsa.PublicKey), x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
-		eraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
-		if err != nil {
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
-			Error.Println(err)
-			return nil, err
-		}
+// authenticateDirectoryUser checks the password of user id against the
+// directory, and registers the user with the role and affiliation of its
+// directory groups unless it is already registered
+//
+func (eca *ECA) authenticateDirectoryUser(id string, password []byte) error {
+	group, err := eca.directory.authenticate(id, password)
+	if err != nil {
+		return err
+	}
+	if eca.isDirectoryUser(id) {
+		return nil
+	}
+	metadata, err := group.memberMetadata()
+	if err != nil {
+		return err
+	}
+	Info.Printf("Registering directory user %s.\n", id)
+	_, err = eca.registerUser(id, group.affiliation, group.affiliationRole, group.role, "", metadata)
+	return err
+}
 
-		_, err = ecap.eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 2, id)
-		if err != nil {
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
+// isDirectoryUser returns whether user id was registered from the directory
+//
+func (eca *ECA) isDirectoryUser(id string) bool {
+	var metadataStr string
+	if err := eca.db.QueryRow("SELECT metadata FROM Users WHERE id=?", id).Scan(&metadataStr); err != nil {
+		return false
+	}
+	metadata, err := newMemberMetadata(metadataStr)
+	return err == nil && metadata != nil && metadata.Directory
+}
+
+// transitionUser moves the enrollment state of a user with query, which only
+// updates the user in the expected state
+//
+func (eca *ECA) transitionUser(query string, args ...interface{}) error {
+	res, err := eca.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errors.New("Invalid (=expired) certificate creation token provided.")
+	}
+	return nil
+}
+
+// issueCertificatePair creates and persists a new enrollment certificate pair
+// for the signing key skey and the encryption key ekey of member id.  commit,
+// if not nil, runs once both certificates are persisted, which are removed if
+// it fails.
+//
+func (ecap *ECAP) issueCertificatePair(id, enrollID string, role int, skey, ekey interface{}, commit func() error) (*pb.ECertCreateResp, error) {
+	fetchResult := pb.FetchAttrsResult{Status: pb.FetchAttrsResult_SUCCESS, Msg: ""}
+
+	if _, ok := ekey.(*ecdsa.PublicKey); !ok {
+		return nil, errors.New("Unsupported (encryption) key type.")
+	}
+
+	// create new certificate pair
+	ts := time.Now().Add(-1 * time.Minute).UnixNano()
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	spec := NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, serialNumber, skey, x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+	sraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	serialNumber, err = newSerialNumber()
+	if err != nil {
+		ecap.eca.db.Exec("DELETE FROM Certificates Where id=? AND timestamp=?", id, ts)
+		return nil, err
+	}
+	spec = NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, serialNumber, ekey, x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+	eraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
+	if err != nil {
+		ecap.eca.db.Exec("DELETE FROM Certificates Where id=? AND timestamp=?", id, ts)
+		Error.Println(err)
+		return nil, err
+	}
+
+	if commit != nil {
+		if err = commit(); err != nil {
+			ecap.eca.db.Exec("DELETE FROM Certificates Where id=? AND timestamp=?", id, ts)
 			Error.Println(err)
 			return nil, err
 		}
+	}
 
-		var obcECKey []byte
-		if role == int(pb.Role_VALIDATOR) {
-			obcECKey = ecap.eca.obcPriv
-		} else {
-			obcECKey = ecap.eca.obcPub
-		}
-		if role == int(pb.Role_CLIENT) {
-			//Only client have to fetch attributes.
-			if viper.GetBool("aca.enabled") {
-				err = ecap.fetchAttributes(&pb.Cert{Cert: sraw})
-				if err != nil {
-					fetchResult = pb.FetchAttrsResult{Status: pb.FetchAttrsResult_FAILURE, Msg: err.Error()}
+	var obcECKey []byte
+	if role == int(pb.Role_VALIDATOR) {
+		obcECKey = ecap.eca.obcPriv
+	} else {
+		obcECKey = ecap.eca.obcPub
+	}
+	if role == int(pb.Role_CLIENT) {
+		//Only client have to fetch attributes.
+		if viper.GetBool("aca.enabled") {
+			err = ecap.fetchAttributes(&pb.Cert{Cert: sraw})
+			if err != nil {
+				fetchResult = pb.FetchAttrsResult{Status: pb.FetchAttrsResult_FAILURE, Msg: err.Error()}
 
-				}
 			}
 		}
+	}
+
+	return &pb.ECertCreateResp{Certs: &pb.CertPair{Sign: sraw, Enc: eraw}, Chain: &pb.Token{Tok: ecap.eca.obcKey}, Pkchain: obcECKey, Tok: nil, FetchResult: &fetchResult}, nil
+}
 
-		return &pb.ECertCreateResp{Certs: &pb.CertPair{Sign: sraw, Enc: eraw}, Chain: &pb.Token{Tok: ecap.eca.obcKey}, Pkchain: obcECKey, Tok: nil, FetchResult: &fetchResult}, nil
+// RenewCertificatePair issues a fresh enrollment certificate pair to a member
+// holding a valid enrollment certificate, without the one-time token of the
+// registration.  The new certificate for signing certifies the key of the
+// current one, under which the request is signed, and the certificates issued
+// before remain valid until they expire.
+//
+func (ecap *ECAP) RenewCertificatePair(ctx context.Context, in *pb.ECertRenewReq) (*pb.ECertCreateResp, error) {
+	Trace.Println("gRPC ECAP:RenewCertificatePair")
+
+	if in.Id == nil || in.Cert == nil || in.Enc == nil {
+		return nil, errors.New("Identity, certificate to renew and encryption key are required.")
 	}
+	id := in.Id.Id
 
-	return nil, errors.New("Invalid (=expired) certificate creation token provided.")
+	var tok, prev []byte
+	var role, state int
+	var enrollID string
+	if err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID); err != nil {
+		errMsg := "Identity lookup error: " + err.Error()
+		Trace.Println(errMsg)
+		return nil, errors.New(errMsg)
+	}
+	if state != 2 {
+		return nil, errors.New("Identity is not enrolled.")
+	}
+
+	owner, _, err := ecap.eca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, errors.New("Certificate was not issued by the ECA.")
+	}
+	if owner != id {
+		return nil, errors.New("Access denied.")
+	}
+	cert, err := x509.ParseCertificate(in.Cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return nil, errors.New("Certificate to renew is not the enrollment certificate for signing.")
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, errors.New("Certificate to renew has expired.")
+	}
+	_, revoked, err := ecap.eca.readRevocation(in.Cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("Certificate to renew is revoked.")
+	}
+
+	if in.Enc.Type != pb.CryptoType_ECDSA {
+		return nil, errors.New("Unsupported (encryption) key type.")
+	}
+	ekey, err := x509.ParsePKIXPublicKey(in.Enc.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	raw, _ := proto.Marshal(in)
+	if sig == nil || !verifySignature(cert.PublicKey, raw, sig) {
+		return nil, errors.New("Signature verification failed.")
+	}
+
+	resp, err := ecap.issueCertificatePair(id, enrollID, role, cert.PublicKey, ekey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	Info.Printf("Enrollment certificates of %s renewed.", id)
+	return resp, nil
 }
 
-// ReadCertificatePair reads an enrollment certificate pair from the ECA.
+// ReadCertificatePair reads the latest enrollment certificate pair from the ECA.
 //
 func (ecap *ECAP) ReadCertificatePair(ctx context.Context, in *pb.ECertReadReq) (*pb.CertPair, error) {
 	Trace.Println("gRPC ECAP:ReadCertificate")
@@ -413,14 +562,23 @@ func (ecap *ECAP) ReadCertificatePair(ctx context.Context, in *pb.ECertReadReq)
 	var certs [][]byte
 	if err == nil {
 		for rows.Next() {
-			var raw []byte
-			err = rows.Scan(&raw)
+			var raw, kdfKey []byte
+			if err = rows.Scan(&raw, &kdfKey); err != nil {
+				return nil, err
+			}
 			certs = append(certs, raw)
 		}
 		err = rows.Err()
 	}
+	if err == nil && len(certs) < 2 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return &pb.CertPair{Sign: certs[0], Enc: certs[1]}, err
+	// the latest pair, once renewed
+	return &pb.CertPair{Sign: certs[len(certs)-2], Enc: certs[len(certs)-1]}, nil
 }
 
 // ReadCertificateByHash reads a single enrollment certificate by hash from the ECA.
@@ -432,12 +590,136 @@ func (ecap *ECAP) ReadCertificateByHash(ctx context.Context, hash *pb.Hash) (*pb
 	return &pb.Cert{Cert: raw}, err
 }
 
-// RevokeCertificatePair revokes a certificate pair from the ECA.  Not yet implemented.
+// RevokeCertificatePair revokes the enrollment certificate pair of the user,
+// one certificate of which is given.  The peers reject the certificates once
+// a certificate revocation list listing them is published.
 //
-func (ecap *ECAP) RevokeCertificatePair(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
-	Trace.Println("gRPC ECAP:RevokeCertificate")
+func (ecap *ECAP) RevokeCertificatePair(ctx context.Context, in *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+	Trace.Println("gRPC ECAP:RevokeCertificatePair")
+
+	if in.Id == nil || in.Cert == nil {
+		return nil, errors.New("Identity and certificate to revoke are required.")
+	}
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecap.eca.checkSignature(id, in, sig); err != nil {
+		return nil, err
+	}
 
-	return nil, errors.New("ECAP:RevokeCertificate method not (yet) implemented")
+	owner, ts, err := ecap.eca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, errors.New("Certificate was not issued by the ECA.")
+	}
+	if owner != id {
+		return nil, errors.New("Access denied.")
+	}
+
+	rows, err := ecap.eca.readCertificates(owner, ts)
+	if err != nil {
+		return nil, err
+	}
+	var certs [][]byte
+	for rows.Next() {
+		var raw []byte
+		var kdfKey []byte
+		if err = rows.Scan(&raw, &kdfKey); err != nil {
+			break
+		}
+		certs = append(certs, raw)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	for _, raw := range certs {
+		if err = ecap.eca.revokeCertificate(owner, raw, now); err != nil {
+			return nil, err
+		}
+	}
+
+	Info.Printf("Enrollment certificates of %s revoked.", owner)
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ReadCertificateStatus returns the status of an enrollment certificate,
+// signed by the ECA, to check online whether it is revoked rather than
+// against the last certificate revocation list published.
+//
+func (ecap *ECAP) ReadCertificateStatus(ctx context.Context, in *pb.Cert) (*pb.ECertStatus, error) {
+	Trace.Println("gRPC ECAP:ReadCertificateStatus")
+
+	hash := primitives.NewHash()
+	hash.Write(in.Cert)
+	status := &pb.ECertStatus{
+		Status: pb.ECertStatus_GOOD,
+		Hash:   hash.Sum(nil),
+		Ts:     &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0}}
+
+	if _, _, err := ecap.eca.readCertificateOwner(in.Cert); err == sql.ErrNoRows {
+		status.Status = pb.ECertStatus_UNKNOWN
+	} else if err != nil {
+		return nil, err
+	} else {
+		ts, revoked, err := ecap.eca.readRevocation(in.Cert)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			status.Status = pb.ECertStatus_REVOKED
+			status.Revoked = &google_protobuf.Timestamp{Seconds: ts / int64(time.Second), Nanos: int32(ts % int64(time.Second))}
+		}
+	}
+
+	raw, err := proto.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	status.Sig, err = ecap.eca.sign(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// checkSignature verifies that sig is the signature of msg, without its
+// signature, under the enrollment certificate of member id, which must not be
+// revoked.
+//
+func (eca *ECA) checkSignature(id string, msg proto.Message, sig *pb.Signature) error {
+	if sig == nil {
+		return errors.New("Signature is missing.")
+	}
+
+	raw, err := eca.readCertificateByKeyUsage(id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return err
+	}
+	_, revoked, err := eca.readRevocation(raw)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New("Certificate of " + id + " is revoked.")
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+
+	raw, _ = proto.Marshal(msg)
+	if !verifySignature(cert.PublicKey, raw, sig) {
+		return errors.New("Signature verification failed.")
+	}
+
+	return nil
 }
 
 // RegisterUser registers a new user with the ECA.  If the user had been registered before
@@ -496,16 +778,10 @@ func (ecaa *ECAA) checkRegistrarSignature(in *pb.RegisterUserReq) error {
 	in.Sig = nil
 
 	// Marshall the raw bytes
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(sig.R)
-	s.UnmarshalText(sig.S)
-
-	hash := primitives.NewHash()
 	raw, _ = proto.Marshal(in)
-	hash.Write(raw)
 
 	// Check the signature
-	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+	if !verifySignature(cert.PublicKey, raw, sig) {
 		// Signature verification failure
 		Trace.Printf("ECAA.checkRegistrarSignature: failure for %s\n", registrar)
 		return errors.New("Signature verification failed.")
@@ -538,14 +814,8 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	sig := in.Sig
 	in.Sig = nil
 
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(sig.R)
-	s.UnmarshalText(sig.S)
-
-	hash := primitives.NewHash()
 	raw, _ = proto.Marshal(in)
-	hash.Write(raw)
-	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+	if !verifySignature(cert.PublicKey, raw, sig) {
 		return nil, errors.New("Signature verification failed.")
 	}
 
@@ -570,18 +840,124 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	return &pb.UserSet{Users: users}, err
 }
 
-// RevokeCertificate revokes a certificate from the ECA.  Not yet implemented.
+// RevokeCertificate revokes an enrollment certificate.  The admin must be a
+// registrar allowed to register members of the role of its owner.
 //
-func (ecaa *ECAA) RevokeCertificate(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) RevokeCertificate(ctx context.Context, in *pb.ECertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC ECAA:RevokeCertificate")
 
-	return nil, errors.New("ECAA:RevokeCertificate method not (yet) implemented")
+	if in.Id == nil || in.Cert == nil {
+		return nil, errors.New("Identity and certificate to revoke are required.")
+	}
+	admin := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.checkSignature(admin, in, sig); err != nil {
+		return nil, err
+	}
+
+	owner, _, err := ecaa.eca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, errors.New("Certificate was not issued by the ECA.")
+	}
+	if owner != admin {
+		if err := ecaa.eca.canRegister(admin, role2String(ecaa.eca.readRole(owner)), ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ecaa.eca.revokeCertificate(owner, in.Cert.Cert, time.Now().UnixNano()); err != nil {
+		return nil, err
+	}
+
+	Info.Printf("Enrollment certificate of %s revoked by %s.", owner, admin)
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the ECA.  Not yet implemented.
+// PublishCRL creates the certificate revocation list of the enrollment
+// certificates revoked so far, writes it to eca.crl and sends it to the peer
+// configured in eca.crl.peer, which publishes it in the blockchain.  The admin
+// must be a registrar.
 //
-func (ecaa *ECAA) PublishCRL(context.Context, *pb.ECertCRLReq) (*pb.CAStatus, error) {
-	Trace.Println("gRPC ECAA:CreateCRL")
+func (ecaa *ECAA) PublishCRL(ctx context.Context, in *pb.ECertCRLReq) (*pb.CAStatus, error) {
+	Trace.Println("gRPC ECAA:PublishCRL")
+
+	if in.Id == nil {
+		return nil, errors.New("Identity is required.")
+	}
+	admin := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.checkSignature(admin, in, sig); err != nil {
+		return nil, err
+	}
+	if !ecaa.eca.isRegistrar(admin) {
+		return nil, errors.New("Access denied.")
+	}
+
+	if err := ecaa.eca.publishCRL(); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+func (eca *ECA) publishCRL() error {
+	// The peers only accept a list more recent than the one they have
+	thisUpdate := time.Now().UTC().Truncate(time.Second)
+	if cooked, err := eca.readKeyMaterial("eca.crl"); err == nil {
+		if previous, err := x509.ParseCRL(cooked); err == nil && !thisUpdate.After(previous.TBSCertList.ThisUpdate) {
+			thisUpdate = previous.TBSCertList.ThisUpdate.Add(time.Second)
+		}
+	}
+	validity := viper.GetDuration("eca.crl.validity")
+	if validity <= 0 {
+		validity = 24 * time.Hour
+	}
+
+	revoked, err := eca.readRevocations()
+	if err != nil {
+		return err
+	}
+	// The TCerts derived from a revoked enrollment certificate are revoked
+	// with it
+	if eca.tca != nil {
+		tcerts, err := eca.tca.readRevokedCertificates(revoked, thisUpdate)
+		if err != nil {
+			return err
+		}
+		revoked = append(revoked, tcerts...)
+	}
+	raw, err := eca.createCRL(revoked, thisUpdate, thisUpdate.Add(validity))
+	if err != nil {
+		return err
+	}
+	cooked := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "X509 CRL",
+			Bytes: raw,
+		})
+	if err = eca.writeKeyMaterial("eca.crl", cooked); err != nil {
+		return err
+	}
 
-	return nil, errors.New("ECAA:PublishCRL method not (yet) implemented")
+	if viper.GetString("eca.crl.peer.address") == "" {
+		Info.Println("Certificate revocation list written to eca.crl.")
+		return nil
+	}
+	sock, devops, err := GetDevopsClient()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+
+	resp, err := devops.UpdateRevocationList(context.Background(), &obc.RevocationList{Crl: raw})
+	if err != nil {
+		return err
+	}
+
+	Info.Printf("Certificate revocation list published in transaction %s.", resp.Msg)
+	return nil
 }
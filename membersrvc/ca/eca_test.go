@@ -21,7 +21,10 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"errors"
+	"io/ioutil"
+	"math/big"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -438,31 +441,322 @@ func TestCreateCertificatePairBadToken(t *testing.T) {
 	}
 }
 
+//sign a request with the enrollment key of the user
+func signRequest(user User, req proto.Message) (*pb.Signature, error) {
+	hash := primitives.NewHash()
+	raw, _ := proto.Marshal(req)
+	hash.Write(raw)
+
+	r, s, err := ecdsa.Sign(rand.Reader, user.enrollPrivKey, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}, nil
+}
+
+//register and enroll a user whose certificates are revoked by a test
+func enrollRevokedUser(t *testing.T, user *User) *pb.CertPair {
+	if err := registerUser(testAdmin, user); err != nil {
+		t.Fatalf("Failed to register %s: [%s]", user.enrollID, err)
+	}
+	if err := enrollUser(user); err != nil {
+		t.Fatalf("Failed to enroll %s: [%s]", user.enrollID, err)
+	}
+	ecap := &ECAP{eca}
+	certs, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: user.enrollID}})
+	if err != nil {
+		t.Fatalf("Failed to read certificate pair of %s: [%s]", user.enrollID, err)
+	}
+	return certs
+}
+
+func revokeRequest(t *testing.T, user User, cert []byte) *pb.ECertRevokeReq {
+	req := &pb.ECertRevokeReq{Id: &pb.Identity{Id: user.enrollID}, Cert: &pb.Cert{Cert: cert}}
+	sig, err := signRequest(user, req)
+	if err != nil {
+		t.Fatalf("Failed to sign revocation request: [%s]", err)
+	}
+	req.Sig = sig
+	return req
+}
+
+func checkCertificateStatus(t *testing.T, cert []byte, expected pb.ECertStatus_StatusCode) {
+	ecap := &ECAP{eca}
+	status, err := ecap.ReadCertificateStatus(context.Background(), &pb.Cert{Cert: cert})
+	if err != nil {
+		t.Fatalf("Failed to read certificate status: [%s]", err)
+	}
+	if status.Status != expected {
+		t.Fatalf("Expected certificate status %s, got %s", expected, status.Status)
+	}
+	if (status.Revoked != nil) != (expected == pb.ECertStatus_REVOKED) {
+		t.Fatalf("Expected the revocation time only for a revoked certificate, got %v", status.Revoked)
+	}
+
+	sig := status.Sig
+	status.Sig = nil
+	raw, _ := proto.Marshal(status)
+	r, s := big.NewInt(0), big.NewInt(0)
+	r.UnmarshalText(sig.R)
+	s.UnmarshalText(sig.S)
+	if !ecdsa.Verify(eca.cert.PublicKey.(*ecdsa.PublicKey), primitives.Hash(raw), r, s) {
+		t.Fatalf("Certificate status is not signed by the ECA")
+	}
+}
+
 func TestRevokeCertificatePair(t *testing.T) {
+	user := User{enrollID: "testRevokedUser", role: 1, affiliation: "institution_a", affiliationRole: "00001"}
+	certs := enrollRevokedUser(t, &user)
 
 	ecap := &ECAP{eca}
 
-	_, err := ecap.RevokeCertificatePair(context.Background(), &pb.ECertRevokeReq{})
-	if err.Error() != "ECAP:RevokeCertificate method not (yet) implemented" {
-		t.Errorf("Expected error was not returned: [%s]", err.Error())
+	// users can only revoke their own certificates
+	if _, err := ecap.RevokeCertificatePair(context.Background(), revokeRequest(t, testUser, certs.Sign)); err == nil {
+		t.Fatalf("Revoked the certificates of another user")
+	}
+	checkCertificateStatus(t, certs.Sign, pb.ECertStatus_GOOD)
+
+	// the request must be signed by the user
+	req := revokeRequest(t, user, certs.Sign)
+	req.Sig.R = req.Sig.S
+	if _, err := ecap.RevokeCertificatePair(context.Background(), req); err == nil {
+		t.Fatalf("Revoked the certificates without a valid signature")
+	}
+
+	status, err := ecap.RevokeCertificatePair(context.Background(), revokeRequest(t, user, certs.Enc))
+	if err != nil {
+		t.Fatalf("Failed to revoke certificate pair: [%s]", err)
+	}
+	if status.Status != pb.CAStatus_OK {
+		t.Fatalf("Expected status OK, got %s", status.Status)
+	}
+	checkCertificateStatus(t, certs.Sign, pb.ECertStatus_REVOKED)
+	checkCertificateStatus(t, certs.Enc, pb.ECertStatus_REVOKED)
+
+	// a revoked member gets no TCerts
+	req2, err := buildCertificateSetRequest(user.enrollID, user.enrollPrivKey, 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to build certificate set request: [%s]", err)
+	}
+	if _, err = (&TCAP{tca}).CreateCertificateSet(context.Background(), req2); err == nil {
+		t.Fatalf("Issued TCerts to a revoked member")
 	}
 }
 
 func TestRevokeCertificate(t *testing.T) {
+	user := User{enrollID: "testRevokedUser2", role: 1, affiliation: "institution_a", affiliationRole: "00001"}
+	certs := enrollRevokedUser(t, &user)
 
 	ecaa := &ECAA{eca}
 
-	_, err := ecaa.RevokeCertificate(context.Background(), &pb.ECertRevokeReq{})
-	if err.Error() != "ECAA:RevokeCertificate method not (yet) implemented" {
-		t.Errorf("Expected error was not returned: [%s]", err.Error())
+	// only registrars allowed to register the member can revoke its certificates
+	if _, err := ecaa.RevokeCertificate(context.Background(), revokeRequest(t, testUser, certs.Sign)); err == nil {
+		t.Fatalf("Revoked a certificate without being a registrar")
+	}
+	checkCertificateStatus(t, certs.Sign, pb.ECertStatus_GOOD)
+
+	if _, err := ecaa.RevokeCertificate(context.Background(), revokeRequest(t, testAdmin, certs.Sign)); err != nil {
+		t.Fatalf("Failed to revoke certificate: [%s]", err)
+	}
+	checkCertificateStatus(t, certs.Sign, pb.ECertStatus_REVOKED)
+	checkCertificateStatus(t, certs.Enc, pb.ECertStatus_GOOD)
+
+	// revoking twice is harmless
+	if _, err := ecaa.RevokeCertificate(context.Background(), revokeRequest(t, testAdmin, certs.Sign)); err != nil {
+		t.Fatalf("Failed to revoke certificate again: [%s]", err)
+	}
+
+	// a revoked member cannot sign requests anymore
+	if _, err := ecaa.RevokeCertificate(context.Background(), revokeRequest(t, user, certs.Enc)); err == nil {
+		t.Fatalf("Accepted a request signed with a revoked certificate")
 	}
 }
 
+func TestReadCertificateStatus(t *testing.T) {
+	ecap := &ECAP{eca}
+
+	certs, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: testAdmin.enrollID}})
+	if err != nil {
+		t.Fatalf("Failed to read certificate pair: [%s]", err)
+	}
+	checkCertificateStatus(t, certs.Sign, pb.ECertStatus_GOOD)
+	checkCertificateStatus(t, eca.raw, pb.ECertStatus_UNKNOWN)
+}
+
 func TestPublishCRL(t *testing.T) {
 	ecaa := &ECAA{eca}
 
-	_, err := ecaa.PublishCRL(context.Background(), &pb.ECertCRLReq{})
-	if err.Error() != "ECAA:PublishCRL method not (yet) implemented" {
-		t.Errorf("Expected error was not returned: [%s]", err.Error())
+	req := &pb.ECertCRLReq{Id: &pb.Identity{Id: testUser.enrollID}}
+	sig, err := signRequest(testUser, req)
+	if err != nil {
+		t.Fatalf("Failed to sign request: [%s]", err)
+	}
+	req.Sig = sig
+	if _, err = ecaa.PublishCRL(context.Background(), req); err == nil {
+		t.Fatalf("Published a revocation list without being a registrar")
+	}
+
+	var previous time.Time
+	for i := 0; i < 2; i++ {
+		req = &pb.ECertCRLReq{Id: &pb.Identity{Id: testAdmin.enrollID}}
+		if req.Sig, err = signRequest(testAdmin, req); err != nil {
+			t.Fatalf("Failed to sign request: [%s]", err)
+		}
+		if _, err = ecaa.PublishCRL(context.Background(), req); err != nil {
+			t.Fatalf("Failed to publish revocation list: [%s]", err)
+		}
+
+		cooked, err := ioutil.ReadFile(eca.path + "/eca.crl")
+		if err != nil {
+			t.Fatalf("Failed to read revocation list: [%s]", err)
+		}
+		crl, err := x509.ParseCRL(cooked)
+		if err != nil {
+			t.Fatalf("Failed to parse revocation list: [%s]", err)
+		}
+		if err = eca.cert.CheckCRLSignature(crl); err != nil {
+			t.Fatalf("Revocation list is not signed by the ECA: [%s]", err)
+		}
+		// the pair of the first user and the signing certificate of the second
+		if len(crl.TBSCertList.RevokedCertificates) != 3 {
+			t.Fatalf("Expected 3 revoked certificates, got %d", len(crl.TBSCertList.RevokedCertificates))
+		}
+		// lists published in the same second are still ordered
+		if !crl.TBSCertList.ThisUpdate.After(previous) {
+			t.Fatalf("Expected the revocation list to be more recent than %v, is of %v", previous, crl.TBSCertList.ThisUpdate)
+		}
+		previous = crl.TBSCertList.ThisUpdate
+	}
+}
+
+func TestPublishCRLRevokesTCerts(t *testing.T) {
+	user := User{enrollID: "testRevokedUser3", role: 1, affiliation: "institution_a", affiliationRole: "00001"}
+	certs := enrollRevokedUser(t, &user)
+
+	req, err := buildCertificateSetRequest(user.enrollID, user.enrollPrivKey, 2, 0)
+	if err != nil {
+		t.Fatalf("Failed to build certificate set request: [%s]", err)
+	}
+	set, err := (&TCAP{tca}).CreateCertificateSet(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to create certificate set: [%s]", err)
+	}
+
+	ecaa := &ECAA{eca}
+	if _, err = ecaa.RevokeCertificate(context.Background(), revokeRequest(t, testAdmin, certs.Sign)); err != nil {
+		t.Fatalf("Failed to revoke certificate: [%s]", err)
+	}
+	crlReq := &pb.ECertCRLReq{Id: &pb.Identity{Id: testAdmin.enrollID}}
+	if crlReq.Sig, err = signRequest(testAdmin, crlReq); err != nil {
+		t.Fatalf("Failed to sign request: [%s]", err)
+	}
+	if _, err = ecaa.PublishCRL(context.Background(), crlReq); err != nil {
+		t.Fatalf("Failed to publish revocation list: [%s]", err)
+	}
+
+	cooked, err := ioutil.ReadFile(eca.path + "/eca.crl")
+	if err != nil {
+		t.Fatalf("Failed to read revocation list: [%s]", err)
+	}
+	crl, err := x509.ParseCRL(cooked)
+	if err != nil {
+		t.Fatalf("Failed to parse revocation list: [%s]", err)
+	}
+	revoked := make(map[string]bool)
+	for _, cert := range crl.TBSCertList.RevokedCertificates {
+		revoked[cert.SerialNumber.String()] = true
+	}
+	for _, tcert := range set.Certs.Certs {
+		cert, err := x509.ParseCertificate(tcert.Cert)
+		if err != nil {
+			t.Fatalf("Failed to parse TCert: [%s]", err)
+		}
+		if !revoked[cert.SerialNumber.String()] {
+			t.Fatalf("Expected TCert %s to be revoked with its enrollment certificate", cert.SerialNumber)
+		}
+	}
+}
+
+func renewRequest(t *testing.T, user User, cert []byte) *pb.ECertRenewReq {
+	encPriv, err := primitives.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Failed to generate encryption key: [%s]", err)
+	}
+	encPub, err := x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal encryption key: [%s]", err)
+	}
+
+	req := &pb.ECertRenewReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:   &pb.Identity{Id: user.enrollID},
+		Cert: &pb.Cert{Cert: cert},
+		Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: encPub}}
+	sig, err := signRequest(user, req)
+	if err != nil {
+		t.Fatalf("Failed to sign renewal request: [%s]", err)
+	}
+	req.Sig = sig
+	return req
+}
+
+func TestRenewCertificatePair(t *testing.T) {
+	user := User{enrollID: "testRenewedUser", role: 1, affiliation: "institution_a", affiliationRole: "00001"}
+	certs := enrollRevokedUser(t, &user)
+
+	ecap := &ECAP{eca}
+
+	// users can only renew their own certificates
+	if _, err := ecap.RenewCertificatePair(context.Background(), renewRequest(t, testUser, certs.Sign)); err == nil {
+		t.Fatalf("Renewed the certificates of another user")
+	}
+
+	// the request must be signed with the key of the certificate
+	req := renewRequest(t, user, certs.Sign)
+	req.Sig.R = req.Sig.S
+	if _, err := ecap.RenewCertificatePair(context.Background(), req); err == nil {
+		t.Fatalf("Renewed the certificates without a valid signature")
+	}
+
+	// only the certificate for signing is renewed
+	if _, err := ecap.RenewCertificatePair(context.Background(), renewRequest(t, user, certs.Enc)); err == nil {
+		t.Fatalf("Renewed the certificate for encrypting")
+	}
+
+	resp, err := ecap.RenewCertificatePair(context.Background(), renewRequest(t, user, certs.Sign))
+	if err != nil {
+		t.Fatalf("Failed to renew certificate pair: [%s]", err)
+	}
+	old, _ := x509.ParseCertificate(certs.Sign)
+	renewed, err := x509.ParseCertificate(resp.Certs.Sign)
+	if err != nil {
+		t.Fatalf("Failed to parse renewed certificate: [%s]", err)
+	}
+	if !reflect.DeepEqual(renewed.PublicKey, old.PublicKey) {
+		t.Fatalf("Expected the renewed certificate to certify the same signing key")
+	}
+	if renewed.SerialNumber.Cmp(old.SerialNumber) == 0 {
+		t.Fatalf("Expected the renewed certificate to have a new serial number")
+	}
+	checkCertificateStatus(t, resp.Certs.Sign, pb.ECertStatus_GOOD)
+	checkCertificateStatus(t, certs.Sign, pb.ECertStatus_GOOD)
+
+	// the renewed pair is the one read from now on
+	latest, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: user.enrollID}})
+	if err != nil {
+		t.Fatalf("Failed to read certificate pair: [%s]", err)
+	}
+	if !reflect.DeepEqual(latest, resp.Certs) {
+		t.Fatalf("Expected to read the renewed certificate pair")
+	}
+
+	// revoked certificates cannot be renewed
+	if _, err = ecap.RevokeCertificatePair(context.Background(), revokeRequest(t, user, resp.Certs.Sign)); err != nil {
+		t.Fatalf("Failed to revoke certificate pair: [%s]", err)
+	}
+	if _, err = ecap.RenewCertificatePair(context.Background(), renewRequest(t, user, resp.Certs.Sign)); err == nil {
+		t.Fatalf("Renewed a revoked certificate")
 	}
 }
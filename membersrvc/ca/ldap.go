@@ -0,0 +1,574 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+)
+
+// The directory authenticates the users the ECA does not know of against an
+// LDAP directory, such as Active Directory, configured by eca.ldap. Such a
+// user enrolls with its name and password in the directory: the ECA binds
+// with its own account, looks the user up, binds as the user to check the
+// password, and registers the user with the role and affiliation its groups
+// map to before carrying on with the enrollment. Until it is enrolled, the
+// password of a user registered this way is checked by the directory rather
+// than the users table, while the users registered otherwise, those of
+// eca.users in particular, are still authenticated by the users table.
+//
+// Only the part of LDAPv3 (RFC 4511) the directory needs is implemented: the
+// simple bind, the search of an entry by the value of one of its attributes,
+// and the BER encoding of their messages.
+
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+	berSet         = 0x31
+	berBoolean     = 0x01
+
+	ldapBindRequest           = 0x60
+	ldapBindResponse          = 0x61
+	ldapUnbindRequest         = 0x42
+	ldapSearchRequest         = 0x63
+	ldapSearchResultEntry     = 0x64
+	ldapSearchResultDone      = 0x65
+	ldapSearchResultReference = 0x73
+	ldapAuthSimple            = 0x80
+	ldapFilterEquality        = 0xa3
+
+	ldapSuccess          = 0
+	ldapScopeSubtree     = 2
+	ldapDerefNever       = 0
+	ldapMaxMessageSize   = 1 << 20
+	ldapDefaultTimeout   = 10 * time.Second
+	ldapDefaultUserAttr  = "uid"
+	ldapDefaultGroupAttr = "memberOf"
+)
+
+// directoryGroup is the role and affiliation a directory group maps to
+type directoryGroup struct {
+	role            pb.Role
+	affiliation     string
+	affiliationRole string
+	metadata        *MemberMetadata
+}
+
+// directory authenticates users against an LDAP directory
+type directory struct {
+	addr           string
+	tlsConfig      *tls.Config // nil for ldap:// URLs
+	base           string
+	bindDN         string
+	bindPassword   string
+	userAttribute  string
+	groupAttribute string
+	groups         map[string]*directoryGroup // by normalized DN
+	timeout        time.Duration
+}
+
+// newDirectory returns the directory configured by eca.ldap, or nil if none
+// is enabled
+func newDirectory() (*directory, error) {
+	if !viper.GetBool("eca.ldap.enabled") {
+		return nil, nil
+	}
+
+	u, err := url.Parse(viper.GetString("eca.ldap.url"))
+	if err != nil {
+		return nil, err
+	}
+	dir := &directory{
+		base:           viper.GetString("eca.ldap.base"),
+		bindDN:         viper.GetString("eca.ldap.binddn"),
+		bindPassword:   viper.GetString("eca.ldap.bindpassword"),
+		userAttribute:  viper.GetString("eca.ldap.userattribute"),
+		groupAttribute: viper.GetString("eca.ldap.groupattribute"),
+		groups:         make(map[string]*directoryGroup),
+		timeout:        viper.GetDuration("eca.ldap.timeout"),
+	}
+	if dir.userAttribute == "" {
+		dir.userAttribute = ldapDefaultUserAttr
+	}
+	if dir.groupAttribute == "" {
+		dir.groupAttribute = ldapDefaultGroupAttr
+	}
+	if dir.timeout <= 0 {
+		dir.timeout = ldapDefaultTimeout
+	}
+
+	host, port := u.Host, ""
+	if h, p, err := net.SplitHostPort(u.Host); err == nil {
+		host, port = h, p
+	}
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		dir.tlsConfig = &tls.Config{ServerName: host}
+		if file := viper.GetString("eca.ldap.tls.rootcert"); file != "" {
+			pem, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			dir.tlsConfig.RootCAs = x509.NewCertPool()
+			if !dir.tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("Invalid LDAP root certificate %s", file)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Invalid LDAP URL %s, expected an ldap:// or ldaps:// URL", u)
+	}
+	dir.addr = net.JoinHostPort(host, port)
+
+	for dn, flds := range viper.GetStringMapString("eca.ldap.groups") {
+		group, err := parseDirectoryGroup(flds)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid mapping of directory group %s: %s", dn, err)
+		}
+		dir.groups[normalizeDN(dn)] = group
+	}
+	return dir, nil
+}
+
+// parseDirectoryGroup parses the mapping of a directory group, formatted as
+// <role> <affiliation> <affiliation_role> <JSON_Metadata>, the last three
+// fields being optional
+func parseDirectoryGroup(flds string) (*directoryGroup, error) {
+	vals := strings.Fields(flds)
+	if len(vals) == 0 {
+		return nil, errors.New("missing role")
+	}
+	role, err := strconv.Atoi(vals[0])
+	if err != nil {
+		return nil, err
+	}
+	group := &directoryGroup{role: pb.Role(role)}
+	if len(vals) >= 3 {
+		group.affiliation = vals[1]
+		group.affiliationRole = vals[2]
+	}
+	if len(vals) >= 4 {
+		if group.metadata, err = newMemberMetadata(removeQuotes(strings.Join(vals[3:], " "))); err != nil {
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// normalizeDN normalizes a DN for comparison, as directories ignore the case
+// of DNs and the spaces around their separators
+func normalizeDN(dn string) string {
+	rdns := strings.Split(strings.ToLower(dn), ",")
+	for i := range rdns {
+		rdns[i] = strings.TrimSpace(rdns[i])
+	}
+	return strings.Join(rdns, ",")
+}
+
+// authenticate checks the password of user id, and returns what the groups
+// of the user map to
+func (dir *directory) authenticate(id string, password []byte) (*directoryGroup, error) {
+	// the directory accepts a simple bind with an empty password as an
+	// anonymous bind
+	if id == "" || len(password) == 0 {
+		return nil, errors.New("Invalid directory credentials.")
+	}
+
+	c, err := dir.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	if dir.bindDN != "" {
+		if err = c.bind(dir.bindDN, []byte(dir.bindPassword)); err != nil {
+			return nil, fmt.Errorf("Directory bind failed: %s", err)
+		}
+	}
+	dn, groups, err := c.search(dir.base, dir.userAttribute, id, dir.groupAttribute)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.bind(dn, password); err != nil {
+		Trace.Printf("Directory authentication of %s failed: %s\n", id, err)
+		return nil, errors.New("Invalid directory credentials.")
+	}
+	return dir.mapGroups(id, groups)
+}
+
+// mapGroups combines what the groups of user id map to: the roles add up,
+// and the groups mapping to an affiliation must map to the same one
+func (dir *directory) mapGroups(id string, groups []string) (*directoryGroup, error) {
+	var mapped *directoryGroup
+	for _, dn := range groups {
+		group := dir.groups[normalizeDN(dn)]
+		if group == nil {
+			continue
+		}
+		if mapped == nil {
+			mapped = &directoryGroup{}
+		}
+		mapped.role |= group.role
+		if group.affiliation != "" {
+			if mapped.affiliation != "" && (mapped.affiliation != group.affiliation || mapped.affiliationRole != group.affiliationRole) {
+				return nil, fmt.Errorf("The directory groups of %s map to several affiliations.", id)
+			}
+			mapped.affiliation, mapped.affiliationRole = group.affiliation, group.affiliationRole
+		}
+		if group.metadata != nil {
+			mapped.metadata = group.metadata
+		}
+	}
+	if mapped == nil || mapped.role == 0 {
+		return nil, fmt.Errorf("No directory group of %s maps to a role.", id)
+	}
+	return mapped, nil
+}
+
+// memberMetadata returns the member metadata of the users registered from
+// the directory
+func (group *directoryGroup) memberMetadata() (string, error) {
+	metadata := MemberMetadata{Directory: true}
+	if group.metadata != nil {
+		metadata.Registrar = group.metadata.Registrar
+	}
+	raw, err := json.Marshal(&metadata)
+	return string(raw), err
+}
+
+// ldapConn is a connection to an LDAP directory
+type ldapConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	msgID   int
+	timeout time.Duration
+}
+
+func (dir *directory) dial() (*ldapConn, error) {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: dir.timeout}
+	if dir.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", dir.addr, dir.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", dir.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(dir.timeout))
+	return &ldapConn{conn: conn, reader: bufio.NewReader(conn), timeout: dir.timeout}, nil
+}
+
+func (c *ldapConn) close() {
+	c.send(berEncode(ldapUnbindRequest))
+	c.conn.Close()
+}
+
+// send sends the protocol operation op in a new message
+func (c *ldapConn) send(op []byte) error {
+	c.msgID++
+	_, err := c.conn.Write(berEncode(berSequence, berEncodeInt(berInteger, c.msgID), op))
+	return err
+}
+
+// receive returns the protocol operation of the next response to the last
+// message sent
+func (c *ldapConn) receive() (byte, []byte, error) {
+	for {
+		raw, err := berRead(c.reader)
+		if err != nil {
+			return 0, nil, err
+		}
+		tag, msg, _, err := berDecode(raw)
+		if err != nil {
+			return 0, nil, err
+		}
+		if tag != berSequence {
+			return 0, nil, errors.New("Invalid LDAP message.")
+		}
+		tag, id, rest, err := berDecode(msg)
+		if err != nil || tag != berInteger {
+			return 0, nil, errors.New("Invalid LDAP message identifier.")
+		}
+		msgID, err := berDecodeInt(id)
+		if err != nil {
+			return 0, nil, err
+		}
+		// unsolicited notifications have identifier 0, and end the session
+		if msgID == 0 {
+			return 0, nil, errors.New("The directory closed the connection.")
+		}
+		if msgID != c.msgID {
+			continue
+		}
+		tag, op, _, err := berDecode(rest)
+		return tag, op, err
+	}
+}
+
+// ldapResult checks the LDAPResult opening a response
+func ldapResult(op []byte) error {
+	tag, code, rest, err := berDecode(op)
+	if err != nil || tag != berEnumerated {
+		return errors.New("Invalid LDAP result.")
+	}
+	resultCode, err := berDecodeInt(code)
+	if err != nil {
+		return err
+	}
+	if resultCode == ldapSuccess {
+		return nil
+	}
+	var diagnostic []byte
+	if _, _, rest, err = berDecode(rest); err == nil {
+		_, diagnostic, _, _ = berDecode(rest)
+	}
+	return fmt.Errorf("LDAP error %d: %s", resultCode, diagnostic)
+}
+
+func (c *ldapConn) bind(dn string, password []byte) error {
+	err := c.send(berEncode(ldapBindRequest,
+		berEncodeInt(berInteger, 3),
+		berEncode(berOctetString, []byte(dn)),
+		berEncode(ldapAuthSimple, password)))
+	if err != nil {
+		return err
+	}
+	tag, op, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if tag != ldapBindResponse {
+		return errors.New("Unexpected LDAP response to a bind request.")
+	}
+	return ldapResult(op)
+}
+
+// search returns the DN of the only entry under base whose attribute attr
+// has value, and the values of its attribute groupAttr
+func (c *ldapConn) search(base, attr, value, groupAttr string) (string, []string, error) {
+	err := c.send(berEncode(ldapSearchRequest,
+		berEncode(berOctetString, []byte(base)),
+		berEncodeInt(berEnumerated, ldapScopeSubtree),
+		berEncodeInt(berEnumerated, ldapDerefNever),
+		berEncodeInt(berInteger, 2),
+		berEncodeInt(berInteger, int(c.timeout/time.Second)),
+		berEncode(berBoolean, []byte{0}),
+		berEncode(ldapFilterEquality, berEncode(berOctetString, []byte(attr)), berEncode(berOctetString, []byte(value))),
+		berEncode(berSequence, berEncode(berOctetString, []byte(groupAttr)))))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var dns []string
+	var groups []string
+	for {
+		tag, op, err := c.receive()
+		if err != nil {
+			return "", nil, err
+		}
+		switch tag {
+		case ldapSearchResultEntry:
+			dn, values, err := parseSearchResultEntry(op, groupAttr)
+			if err != nil {
+				return "", nil, err
+			}
+			dns = append(dns, dn)
+			groups = values
+		case ldapSearchResultReference:
+		case ldapSearchResultDone:
+			if err = ldapResult(op); err != nil {
+				return "", nil, err
+			}
+			if len(dns) != 1 {
+				return "", nil, fmt.Errorf("%d directory entries found for %s.", len(dns), value)
+			}
+			return dns[0], groups, nil
+		default:
+			return "", nil, errors.New("Unexpected LDAP response to a search request.")
+		}
+	}
+}
+
+// parseSearchResultEntry returns the DN of an entry and the values of its
+// attribute attr
+func parseSearchResultEntry(op []byte, attr string) (string, []string, error) {
+	tag, dn, rest, err := berDecode(op)
+	if err != nil || tag != berOctetString {
+		return "", nil, errors.New("Invalid LDAP entry.")
+	}
+	tag, attrs, _, err := berDecode(rest)
+	if err != nil || tag != berSequence {
+		return "", nil, errors.New("Invalid LDAP entry attributes.")
+	}
+
+	var values []string
+	for len(attrs) > 0 {
+		var partial []byte
+		if tag, partial, attrs, err = berDecode(attrs); err != nil || tag != berSequence {
+			return "", nil, errors.New("Invalid LDAP entry attribute.")
+		}
+		tag, typ, vals, err := berDecode(partial)
+		if err != nil || tag != berOctetString {
+			return "", nil, errors.New("Invalid LDAP entry attribute type.")
+		}
+		if !strings.EqualFold(string(typ), attr) {
+			continue
+		}
+		if tag, vals, _, err = berDecode(vals); err != nil || tag != berSet {
+			return "", nil, errors.New("Invalid LDAP entry attribute values.")
+		}
+		for len(vals) > 0 {
+			var val []byte
+			if tag, val, vals, err = berDecode(vals); err != nil || tag != berOctetString {
+				return "", nil, errors.New("Invalid LDAP entry attribute value.")
+			}
+			values = append(values, string(val))
+		}
+	}
+	return string(dn), values, nil
+}
+
+// berEncode encodes an element of tag with the concatenation of content
+func berEncode(tag byte, content ...[]byte) []byte {
+	var n int
+	for _, c := range content {
+		n += len(c)
+	}
+	raw := []byte{tag}
+	if n < 0x80 {
+		raw = append(raw, byte(n))
+	} else {
+		var length []byte
+		for l := n; l > 0; l >>= 8 {
+			length = append([]byte{byte(l)}, length...)
+		}
+		raw = append(raw, 0x80|byte(len(length)))
+		raw = append(raw, length...)
+	}
+	for _, c := range content {
+		raw = append(raw, c...)
+	}
+	return raw
+}
+
+// berEncodeInt encodes the non-negative integer v as an element of tag
+func berEncodeInt(tag byte, v int) []byte {
+	var content []byte
+	for ; v > 0; v >>= 8 {
+		content = append([]byte{byte(v)}, content...)
+	}
+	if len(content) == 0 || content[0]&0x80 != 0 {
+		content = append([]byte{0}, content...)
+	}
+	return berEncode(tag, content)
+}
+
+// berDecode decodes the first element of raw, returning its tag, content and
+// the elements following it. Directories, Active Directory in particular,
+// encode lengths in more bytes than needed, which DER forbids.
+func berDecode(raw []byte) (byte, []byte, []byte, error) {
+	if len(raw) < 2 {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	tag := raw[0]
+	if tag&0x1f == 0x1f {
+		return 0, nil, nil, errors.New("Unsupported BER tag.")
+	}
+	n, offset := int(raw[1]), 2
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(raw) < 2+size {
+			return 0, nil, nil, errors.New("Unsupported BER length.")
+		}
+		n = 0
+		for _, b := range raw[2 : 2+size] {
+			n = n<<8 | int(b)
+		}
+		offset += size
+	}
+	if n < 0 || n > len(raw)-offset {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	return tag, raw[offset : offset+n], raw[offset+n:], nil
+}
+
+// berDecodeInt decodes the content of an integer or enumerated element
+func berDecodeInt(content []byte) (int, error) {
+	if len(content) == 0 || len(content) > 4 {
+		return 0, errors.New("Invalid BER integer.")
+	}
+	v := int(int8(content[0]))
+	for _, b := range content[1:] {
+		v = v<<8 | int(b)
+	}
+	return v, nil
+}
+
+// berRead reads an element from r
+func berRead(r *bufio.Reader) ([]byte, error) {
+	header, err := r.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	size := 0
+	if header[1]&0x80 != 0 {
+		size = int(header[1] & 0x7f)
+		if size == 0 || size > 4 {
+			return nil, errors.New("Unsupported BER length.")
+		}
+	}
+	if header, err = r.Peek(2 + size); err != nil {
+		return nil, err
+	}
+	n := int(header[1])
+	if size > 0 {
+		n = 0
+		for _, b := range header[2:] {
+			n = n<<8 | int(b)
+		}
+	}
+	if n > ldapMaxMessageSize {
+		return nil, errors.New("LDAP message too large.")
+	}
+	raw := make([]byte, 2+size+n)
+	_, err = io.ReadFull(r, raw)
+	return raw, err
+}
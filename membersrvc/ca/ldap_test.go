@@ -0,0 +1,247 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
+)
+
+type directoryEntry struct {
+	dn       string
+	password string
+	groups   []string
+}
+
+// testDirectory serves a few LDAP entries, encoding the lengths of its
+// responses in four bytes as Active Directory does
+type testDirectory struct {
+	listener net.Listener
+	entries  map[string]*directoryEntry // by uid
+}
+
+var (
+	testServiceDN = "cn=membersrvc,dc=example,dc=com"
+	testClients   = "cn=clients,ou=groups,dc=example,dc=com"
+	testAuditors  = "cn=auditors,ou=groups,dc=example,dc=com"
+)
+
+func newTestDirectory(t *testing.T) *testDirectory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := &testDirectory{listener: listener, entries: map[string]*directoryEntry{
+		"membersrvc":   {dn: testServiceDN, password: "secret"},
+		"dirClient":    {dn: "uid=dirClient,ou=people,dc=example,dc=com", password: "pw1", groups: []string{"CN=Clients, OU=Groups, DC=example, DC=com", "cn=others,ou=groups,dc=example,dc=com"}},
+		"dirAuditor":   {dn: "uid=dirAuditor,ou=people,dc=example,dc=com", password: "pw2", groups: []string{testAuditors, testClients}},
+		"dirUnmapped":  {dn: "uid=dirUnmapped,ou=people,dc=example,dc=com", password: "pw3", groups: []string{"cn=others,ou=groups,dc=example,dc=com"}},
+		"dirDuplicate": {dn: "uid=dirDuplicate,ou=people,dc=example,dc=com", password: "pw4"},
+	}}
+	go dir.serve()
+
+	viper.Set("eca.ldap.enabled", true)
+	viper.Set("eca.ldap.url", "ldap://"+listener.Addr().String())
+	viper.Set("eca.ldap.base", "dc=example,dc=com")
+	viper.Set("eca.ldap.binddn", testServiceDN)
+	viper.Set("eca.ldap.bindpassword", "secret")
+	viper.Set("eca.ldap.userattribute", "uid")
+	viper.Set("eca.ldap.groups", map[string]string{
+		testClients:  "1 bank_a 00001",
+		testAuditors: "8",
+	})
+	return dir
+}
+
+func (dir *testDirectory) close() {
+	viper.Set("eca.ldap.enabled", false)
+	dir.listener.Close()
+}
+
+func ldapLongEncode(tag byte, content ...[]byte) []byte {
+	raw := berEncode(tag, bytes.Join(content, nil))
+	_, c, _, _ := berDecode(raw)
+	n := len(c)
+	return append([]byte{tag, 0x84, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, c...)
+}
+
+func ldapTestResult(code int) []byte {
+	return bytes.Join([][]byte{berEncodeInt(berEnumerated, code), berEncode(berOctetString), berEncode(berOctetString)}, nil)
+}
+
+func (dir *testDirectory) serve() {
+	for {
+		conn, err := dir.listener.Accept()
+		if err != nil {
+			return
+		}
+		go dir.handle(conn)
+	}
+}
+
+func (dir *testDirectory) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		raw, err := berRead(reader)
+		if err != nil {
+			return
+		}
+		_, msg, _, _ := berDecode(raw)
+		_, id, rest, _ := berDecode(msg)
+		msgID, _ := berDecodeInt(id)
+		tag, op, _, _ := berDecode(rest)
+		respond := func(tag byte, content ...[]byte) {
+			conn.Write(ldapLongEncode(berSequence, berEncodeInt(berInteger, msgID), ldapLongEncode(tag, content...)))
+		}
+
+		switch tag {
+		case ldapBindRequest:
+			_, _, rest, _ := berDecode(op)
+			_, dn, rest, _ := berDecode(rest)
+			_, password, _, _ := berDecode(rest)
+			code := 49 // invalidCredentials
+			for _, entry := range dir.entries {
+				if entry.dn == string(dn) && entry.password == string(password) {
+					code = ldapSuccess
+				}
+			}
+			respond(ldapBindResponse, ldapTestResult(code))
+		case ldapSearchRequest:
+			rest := op
+			for i := 0; i < 6; i++ {
+				_, _, rest, _ = berDecode(rest)
+			}
+			_, filter, _, _ := berDecode(rest)
+			_, attr, filter, _ := berDecode(filter)
+			_, value, _, _ := berDecode(filter)
+			for uid, entry := range dir.entries {
+				if string(attr) != "uid" || (uid != string(value) && !(string(value) == "dirDuplicate" && uid == "dirUnmapped")) {
+					continue
+				}
+				var vals [][]byte
+				for _, group := range entry.groups {
+					vals = append(vals, berEncode(berOctetString, []byte(group)))
+				}
+				respond(ldapSearchResultEntry, berEncode(berOctetString, []byte(entry.dn)),
+					berEncode(berSequence, berEncode(berSequence, berEncode(berOctetString, []byte("memberOf")), berEncode(berSet, vals...))))
+			}
+			respond(ldapSearchResultDone, ldapTestResult(ldapSuccess))
+		default:
+			return
+		}
+	}
+}
+
+func TestBERDecode(t *testing.T) {
+	raw := ldapLongEncode(berSequence, berEncodeInt(berInteger, 200), berEncode(berOctetString, []byte("dn")))
+	tag, content, rest, err := berDecode(raw)
+	if err != nil || tag != berSequence || len(rest) != 0 {
+		t.Fatalf("Failed decoding a long form length: %v", err)
+	}
+	_, v, content, err := berDecode(content)
+	if n, _ := berDecodeInt(v); err != nil || n != 200 {
+		t.Fatalf("Expected 200, got %d", n)
+	}
+	if _, s, _, err := berDecode(content); err != nil || string(s) != "dn" {
+		t.Fatalf("Expected dn, got %s", s)
+	}
+	if _, _, _, err := berDecode(raw[:len(raw)-1]); err == nil {
+		t.Fatal("Expected a truncated element to be rejected")
+	}
+}
+
+func TestDirectoryAuthenticate(t *testing.T) {
+	td := newTestDirectory(t)
+	defer td.close()
+	dir, err := newDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := dir.authenticate("dirClient", []byte("pw1"))
+	if err != nil {
+		t.Fatalf("Failed authenticating a directory user: %s", err)
+	}
+	if group.role != pb.Role_CLIENT || group.affiliation != "bank_a" || group.affiliationRole != "00001" {
+		t.Fatalf("Unexpected mapping of the directory groups %+v", group)
+	}
+	if group, err = dir.authenticate("dirAuditor", []byte("pw2")); err != nil || group.role != pb.Role_CLIENT|pb.Role_AUDITOR {
+		t.Fatalf("Expected the roles of the directory groups to add up, got %v %v", group, err)
+	}
+
+	for id, password := range map[string]string{
+		"dirClient":    "bad",
+		"dirClient ":   "pw1",
+		"unknown":      "pw1",
+		"dirUnmapped":  "pw3",
+		"dirDuplicate": "pw4",
+	} {
+		if _, err := dir.authenticate(id, []byte(password)); err == nil {
+			t.Errorf("Expected the directory authentication of %s with %s to fail", id, password)
+		}
+	}
+	if _, err := dir.authenticate("dirClient", nil); err == nil {
+		t.Error("Expected an empty password to be rejected rather than bound anonymously")
+	}
+}
+
+func TestDirectoryEnrollment(t *testing.T) {
+	td := newTestDirectory(t)
+	defer td.close()
+	dir, err := newDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eca.directory = dir
+	defer func() { eca.directory = nil }()
+
+	user := User{enrollID: "dirClient", enrollPwd: []byte("bad")}
+	if err := enrollUser(&user); err == nil {
+		t.Fatal("Expected the enrollment with a wrong directory password to fail")
+	}
+	user.enrollPwd = []byte("pw1")
+	if err := enrollUser(&user); err != nil {
+		t.Fatalf("Failed enrolling a directory user: %s", err)
+	}
+	if !eca.isDirectoryUser("dirClient") || eca.readRole("dirClient") != int(pb.Role_CLIENT) {
+		t.Fatal("Expected the directory user to be registered as a client")
+	}
+	var role, state int
+	var tok, key []byte
+	var enrollID string
+	if err := eca.readUser("dirClient").Scan(&role, &tok, &state, &key, &enrollID); err != nil || state != 2 {
+		t.Fatalf("Expected the directory user to be enrolled, got state %d %v", state, err)
+	}
+	if _, _, affiliation, err := eca.parseEnrollID(enrollID); err != nil || affiliation != "bank_a" {
+		t.Fatalf("Expected the directory user to be affiliated with bank_a, got %s %v", affiliation, err)
+	}
+	if err := enrollUser(&user); err == nil {
+		t.Fatal("Expected an enrolled directory user not to enroll again")
+	}
+
+	// the users of the users table are not authenticated by the directory
+	admin := User{enrollID: "admin", enrollPwd: []byte("pw1")}
+	if err := enrollUser(&admin); err == nil {
+		t.Fatal("Expected a registered user to be authenticated by the users table")
+	}
+}
@@ -18,6 +18,7 @@ package ca
 
 import (
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/x509"
@@ -27,9 +28,9 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -54,6 +55,9 @@ var (
 	// TCertAttributesHeaders is the ASN1 object identifier of attributes header.
 	TCertAttributesHeaders = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 9}
 
+	// TCertSplitKeyNonce is the ASN1 object identifier of the nonce of a split-key TCert.
+	TCertSplitKeyNonce = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 7, 1}
+
 	// Padding for encryption.
 	Padding = []byte{255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255}
 
@@ -61,6 +65,10 @@ var (
 	RootPreKeySize = 48
 )
 
+// preKeysMutex guards the pre-key tree, which is rebuilt when an affiliation
+// group is missing
+var preKeysMutex = &sync.RWMutex{}
+
 // TCA is the transaction certificate authority.
 type TCA struct {
 	*CA
@@ -88,7 +96,7 @@ type TCertSet struct {
 	Key          []byte
 }
 
-func initializeTCATables(db *sql.DB) error {
+func initializeTCATables(db *DB) error {
 	var err error
 
 	err = initializeCommonTables(db)
@@ -100,12 +108,17 @@ func initializeTCATables(db *sql.DB) error {
 		return err
 	}
 
+	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS TCertificates (row INTEGER PRIMARY KEY, ecertSerial VARCHAR(64), serial VARCHAR(64), notAfter INTEGER)"); err != nil {
+		return err
+	}
+
 	return err
 }
 
 // NewTCA sets up a new TCA.
 func NewTCA(eca *ECA) *TCA {
 	tca := &TCA{NewCA("tca", initializeTCATables), eca, nil, nil, nil}
+	eca.tca = tca
 
 	err := tca.readHmacKey()
 	if err != nil {
@@ -124,45 +137,33 @@ func NewTCA(eca *ECA) *TCA {
 	return tca
 }
 
-// Read the hcmac key from the file system.
+// Read the hcmac key from the CA directory or the shared database.
 func (tca *TCA) readHmacKey() error {
-	var cooked string
-	raw, err := ioutil.ReadFile(tca.path + "/tca.hmac")
-	if err != nil {
+	raw, err := tca.readOrCreateKeyMaterial("tca.hmac", func() ([]byte, error) {
 		key := make([]byte, 49)
 		rand.Reader.Read(key)
-		cooked = base64.StdEncoding.EncodeToString(key)
-
-		err = ioutil.WriteFile(tca.path+"/tca.hmac", []byte(cooked), 0644)
-		if err != nil {
-			Panic.Panicln(err)
-		}
-	} else {
-		cooked = string(raw)
+		return []byte(base64.StdEncoding.EncodeToString(key)), nil
+	})
+	if err != nil {
+		Panic.Panicln(err)
 	}
 
-	tca.hmacKey, err = base64.StdEncoding.DecodeString(cooked)
+	tca.hmacKey, err = base64.StdEncoding.DecodeString(string(raw))
 	return err
 }
 
-// Read the root pre key from the file system.
+// Read the root pre key from the CA directory or the shared database.
 func (tca *TCA) readRootPreKey() error {
-	var cooked string
-	raw, err := ioutil.ReadFile(tca.path + "/root_pk.hmac")
-	if err != nil {
+	raw, err := tca.readOrCreateKeyMaterial("root_pk.hmac", func() ([]byte, error) {
 		key := make([]byte, RootPreKeySize)
 		rand.Reader.Read(key)
-		cooked = base64.StdEncoding.EncodeToString(key)
-
-		err = ioutil.WriteFile(tca.path+"/root_pk.hmac", []byte(cooked), 0644)
-		if err != nil {
-			Panic.Panicln(err)
-		}
-	} else {
-		cooked = string(raw)
+		return []byte(base64.StdEncoding.EncodeToString(key)), nil
+	})
+	if err != nil {
+		Panic.Panicln(err)
 	}
 
-	tca.rootPreKey, err = base64.StdEncoding.DecodeString(cooked)
+	tca.rootPreKey, err = base64.StdEncoding.DecodeString(string(raw))
 	return err
 }
 
@@ -200,7 +201,7 @@ func (tca *TCA) initializePreKeyTree() error {
 	if err != nil {
 		return err
 	}
-	tca.preKeys = make(map[string][]byte)
+	preKeys := make(map[string][]byte)
 	for _, group := range groups {
 		if group.preKey == nil {
 			err = tca.initializePreKeyGroup(group)
@@ -209,9 +210,12 @@ func (tca *TCA) initializePreKeyTree() error {
 			}
 		}
 		Trace.Println("Initializing PK group ", group.name)
-		tca.preKeys[group.name] = group.preKey
+		preKeys[group.name] = group.preKey
 	}
 
+	preKeysMutex.Lock()
+	tca.preKeys = preKeys
+	preKeysMutex.Unlock()
 	return nil
 }
 
@@ -220,7 +224,19 @@ func (tca *TCA) getPreKFrom(enrollmentCertificate *x509.Certificate) ([]byte, er
 	if err != nil {
 		return nil, err
 	}
+	preKeysMutex.RLock()
 	preK := tca.preKeys[affiliation]
+	preKeysMutex.RUnlock()
+	if preK == nil {
+		// the affiliation group may have been added by another replica of
+		// the ECA since the tree was initialized
+		if err = tca.initializePreKeyTree(); err != nil {
+			return nil, err
+		}
+		preKeysMutex.RLock()
+		preK = tca.preKeys[affiliation]
+		preKeysMutex.RUnlock()
+	}
 	if preK == nil {
 		return nil, errors.New("Could not be found a pre-k to the affiliation group " + affiliation + ".")
 	}
@@ -317,19 +333,11 @@ func (tcap *TCAP) requestAttributes(id string, ecert []byte, attrs []*pb.TCertAt
 		return nil, err
 	}
 
-	var r, s *big.Int
-
-	r, s, err = primitives.ECDSASignDirect(tcap.tca.priv, rawReq)
-
+	req.Signature, err = tcap.tca.sign(rawReq)
 	if err != nil {
 		return nil, err
 	}
 
-	R, _ := r.MarshalText()
-	S, _ := s.MarshalText()
-
-	req.Signature = &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
-
 	resp, err := acaP.RequestAttributes(context.Background(), req)
 	if err != nil {
 		return nil, err
@@ -342,6 +350,34 @@ func (tcap *TCAP) requestAttributes(id string, ecert []byte, attrs []*pb.TCertAt
 	return tcap.selectValidAttributes(resp.Cert.Cert)
 }
 
+// readEnrollmentAttribute returns the attribute of the enrollment certificate
+// named attributeName, or nil if the certificate does not carry it, e.g. no
+// affiliation for the members registered without any.
+func (tcap *TCAP) readEnrollmentAttribute(ecert *x509.Certificate, attributeName string) (*pb.ACAAttribute, error) {
+	var value string
+	switch attributeName {
+	case attributes.EnrollmentRoleAttributeName:
+		raw, err := primitives.GetCriticalExtension(ecert, ECertSubjectRole)
+		if err != nil {
+			return nil, err
+		}
+		role, err := strconv.Atoi(string(raw))
+		if err != nil {
+			return nil, err
+		}
+		value = pb.Role(role).String()
+	case attributes.EnrollmentAffiliationAttributeName:
+		_, _, affiliation, err := tcap.tca.parseEnrollID(ecert.Subject.CommonName)
+		if err != nil {
+			return nil, nil
+		}
+		value = affiliation
+	default:
+		return nil, errors.New("Unknown enrollment attribute " + attributeName)
+	}
+	return &pb.ACAAttribute{AttributeName: attributeName, AttributeValue: []byte(value)}, nil
+}
+
 // CreateCertificateSet requests the creation of a new transaction certificate set by the TCA.
 func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSetReq) (*pb.TCertCreateSetResp, error) {
 	Trace.Println("grpc TCAP:CreateCertificateSet")
@@ -352,6 +388,16 @@ func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSe
 		return nil, err
 	}
 
+	// The peers cannot tell which enrollment certificate a TCert derives
+	// from, so revoked members must not get any
+	_, revoked, err := tcap.tca.eca.readRevocation(raw)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("Enrollment certificate of " + id + " is revoked.")
+	}
+
 	return tcap.createCertificateSet(ctx, raw, in)
 }
 
@@ -361,8 +407,19 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 	var id = in.Id.Id
 	var timestamp = in.Ts.Seconds
 
-	if in.Attributes != nil && viper.GetBool("aca.enabled") {
-		attrs, err = tcap.requestAttributes(id, raw, in.Attributes)
+	// The attributes of the enrollment are read from the enrollment
+	// certificate, the others are asked to the ACA
+	var enrollmentAttrs, acaAttrs []*pb.TCertAttribute
+	for _, att := range in.Attributes {
+		if att != nil && attributes.IsEnrollmentAttribute(att.AttributeName) {
+			enrollmentAttrs = append(enrollmentAttrs, att)
+		} else {
+			acaAttrs = append(acaAttrs, att)
+		}
+	}
+
+	if acaAttrs != nil && viper.GetBool("aca.enabled") {
+		attrs, err = tcap.requestAttributes(id, raw, acaAttrs)
 		if err != nil {
 			return nil, err
 		}
@@ -373,22 +430,30 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		return nil, err
 	}
 
-	pub := cert.PublicKey.(*ecdsa.PublicKey)
-
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(in.Sig.R)
-	s.UnmarshalText(in.Sig.S)
+	// TCerts derive from the enrollment key, which must be an ECDSA key
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("TCerts can only be issued for ECDSA enrollment keys")
+	}
 
-	//sig := in.Sig
+	sig := in.Sig
 	in.Sig = nil
 
-	hash := primitives.NewHash()
 	raw, _ = proto.Marshal(in)
-	hash.Write(raw)
-	if ecdsa.Verify(pub, hash.Sum(nil), r, s) == false {
+	if !verifySignature(pub, raw, sig) {
 		return nil, errors.New("signature does not verify")
 	}
 
+	for _, att := range enrollmentAttrs {
+		acaAtt, err := tcap.readEnrollmentAttribute(cert, att.AttributeName)
+		if err != nil {
+			return nil, err
+		}
+		if acaAtt != nil {
+			attrs = append(attrs, acaAtt)
+		}
+	}
+
 	// Generate nonce for TCertIndex
 	nonce := make([]byte, 16) // 8 bytes rand, 8 bytes timestamp
 	rand.Reader.Read(nonce[:8])
@@ -403,6 +468,19 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		num = 1
 	}
 
+	if isSplitKeyDerivation() {
+		set, err := tcap.createSplitKeyCertificateSet(id, cert, pub, kdfKey, attrs, num, in)
+		if err != nil {
+			return nil, err
+		}
+		if err = tcap.tca.persistCertificates(cert, set); err != nil {
+			return nil, err
+		}
+		tcap.tca.persistCertificateSet(id, timestamp, nil, kdfKey)
+
+		return &pb.TCertCreateSetResp{Certs: &pb.CertSet{Ts: in.Ts, Id: in.Id, Key: kdfKey, Certs: set}}, nil
+	}
+
 	// the batch of TCerts
 	var set []*pb.TCert
 
@@ -453,11 +531,72 @@ func (tcap *TCAP) createCertificateSet(ctx context.Context, raw []byte, in *pb.T
 		set = append(set, &pb.TCert{Cert: raw, Prek0: preK0})
 	}
 
+	if err = tcap.tca.persistCertificates(cert, set); err != nil {
+		return nil, err
+	}
 	tcap.tca.persistCertificateSet(id, timestamp, nonce, kdfKey)
 
 	return &pb.TCertCreateSetResp{Certs: &pb.CertSet{Ts: in.Ts, Id: in.Id, Key: kdfKey, Certs: set}}, nil
 }
 
+// createSplitKeyCertificateSet creates a split-key TCert per key share of in.
+// The key of a TCert is the enrollment key pub, plus the ExpansionValue G the
+// owner derives from the nonce of the TCert, plus the key share, whose
+// blinding value only the owner knows. The TCerts carry neither the enrollment
+// ID nor a TCertIndex the TCA could decrypt, their validity starts on the hour
+// so that the TCerts of a batch can not be told from the others, and the key
+// shares are not persisted: once issued, the TCA can only link them to the
+// enrollment certificate by their serial numbers, which it keeps to revoke
+// them with it.
+func (tcap *TCAP) createSplitKeyCertificateSet(id string, cert *x509.Certificate, pub *ecdsa.PublicKey, kdfKey []byte, attrs []*pb.ACAAttribute, num int, in *pb.TCertCreateSetReq) ([]*pb.TCert, error) {
+	if len(in.KeyShares) != num {
+		return nil, fmt.Errorf("Split-key TCerts require a key share per certificate, got %d for %d certificates.", len(in.KeyShares), num)
+	}
+	shares := make([][]byte, num)
+	for i, keyShare := range in.KeyShares {
+		if len(keyShare.Nonce) == 0 {
+			return nil, errors.New("Invalid key share. Its nonce is empty.")
+		}
+		shares[i] = keyShare.Share
+	}
+	if err := primitives.VerifyKeyShareBatchProof(pub.Curve, shares, in.KeyShareProof, cert.Raw); err != nil {
+		return nil, err
+	}
+
+	expansionKey := primitives.HMAC(kdfKey, []byte{2})
+	notBefore := time.Now().Truncate(time.Hour).Add(-1 * time.Hour)
+	notAfter := notBefore.Add(time.Hour * 24 * 90)
+
+	var set []*pb.TCert
+	for i, keyShare := range in.KeyShares {
+		tcertid := util.GenerateIntUUID()
+
+		k := primitives.ExpandScalar(pub.Curve, expansionKey, keyShare.Nonce)
+		tmpX, tmpY := pub.ScalarBaseMult(k.Bytes())
+		txX, txY := pub.Curve.Add(pub.X, pub.Y, tmpX, tmpY)
+		shareX, shareY := elliptic.Unmarshal(pub.Curve, shares[i])
+		txX, txY = pub.Curve.Add(txX, txY, shareX, shareY)
+		txPub := ecdsa.PublicKey{Curve: pub.Curve, X: txX, Y: txY}
+
+		extensions, preK0, err := tcap.generateAttributeExtensions(tcertid, cert, attrs)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{Id: TCertSplitKeyNonce, Critical: true, Value: keyShare.Nonce})
+
+		spec := NewCertificateSpec(id, "", tcertid, &txPub, x509.KeyUsageDigitalSignature, &notBefore, &notAfter, extensions...)
+		raw, err := tcap.tca.createCertificateFromSpec(spec, in.Ts.Seconds, kdfKey, false)
+		if err != nil {
+			Error.Println(err)
+			return nil, err
+		}
+
+		set = append(set, &pb.TCert{Cert: raw, Prek0: preK0})
+	}
+
+	return set, nil
+}
+
 func (tca *TCA) getCertificateSets(enrollmentID string) ([]*TCertSet, error) {
 	var sets = []*TCertSet{}
 	var err error
@@ -500,23 +639,63 @@ func (tca *TCA) retrieveCertificateSets(enrollmentID string) (*sql.Rows, error)
 	return tca.db.Query("SELECT enrollmentID, timestamp, nonce, kdfkey FROM TCertificateSets WHERE enrollmentID=?", enrollmentID)
 }
 
+// persistCertificates records the serial numbers of the TCerts of set, derived
+// from the enrollment certificate ecert, so that revoking ecert revokes them.
+func (tca *TCA) persistCertificates(ecert *x509.Certificate, set []*pb.TCert) error {
+	for _, tcert := range set {
+		cert, err := x509.ParseCertificate(tcert.Cert)
+		if err != nil {
+			return err
+		}
+		if _, err = tca.db.Exec("INSERT INTO TCertificates (ecertSerial, serial, notAfter) VALUES (?, ?, ?)", ecert.SerialNumber.String(), cert.SerialNumber.String(), cert.NotAfter.Unix()); err != nil {
+			Error.Println(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// readRevokedCertificates returns the TCerts derived from the revoked
+// enrollment certificates that are not expired at now, revoked at the same
+// time as their enrollment certificate.
+func (tca *TCA) readRevokedCertificates(revoked []pkix.RevokedCertificate, now time.Time) ([]pkix.RevokedCertificate, error) {
+	var tcerts []pkix.RevokedCertificate
+	for _, ecert := range revoked {
+		rows, err := tca.db.Query("SELECT serial FROM TCertificates WHERE ecertSerial=? AND notAfter>?", ecert.SerialNumber.String(), now.Unix())
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var serial string
+			if err = rows.Scan(&serial); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			serialNumber, ok := new(big.Int).SetString(serial, 10)
+			if !ok {
+				rows.Close()
+				return nil, errors.New("Invalid serial number " + serial + " of TCert.")
+			}
+			tcerts = append(tcerts, pkix.RevokedCertificate{SerialNumber: serialNumber, RevocationTime: ecert.RevocationTime})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tcerts, nil
+}
+
 // Generate encrypted extensions to be included into the TCert (TCertIndex, EnrollmentID and attributes).
 func (tcap *TCAP) generateExtensions(tcertid *big.Int, tidx []byte, enrollmentCert *x509.Certificate, attrs []*pb.ACAAttribute) ([]pkix.Extension, []byte, error) {
-	// For each TCert we need to store and retrieve to the user the list of Ks used to encrypt the EnrollmentID and the attributes.
-	extensions := make([]pkix.Extension, len(attrs))
-
-	// Compute preK_1 to encrypt attributes and enrollment ID
-	preK1, err := tcap.tca.getPreKFrom(enrollmentCert)
+	extensions, preK0, err := tcap.generateAttributeExtensions(tcertid, enrollmentCert, attrs)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	mac := hmac.New(primitives.GetDefaultHash(), preK1)
-	mac.Write(tcertid.Bytes())
-	preK0 := mac.Sum(nil)
-
 	// Compute encrypted EnrollmentID
-	mac = hmac.New(primitives.GetDefaultHash(), preK0)
+	mac := hmac.New(primitives.GetDefaultHash(), preK0)
 	mac.Write([]byte("enrollmentID"))
 	enrollmentIDKey := mac.Sum(nil)[:32]
 
@@ -528,6 +707,30 @@ func (tcap *TCAP) generateExtensions(tcertid *big.Int, tidx []byte, enrollmentCe
 		return nil, nil, err
 	}
 
+	// Append the TCertIndex to the extensions
+	extensions = append(extensions, pkix.Extension{Id: TCertEncTCertIndex, Critical: true, Value: tidx})
+
+	// Append the encrypted EnrollmentID to the extensions
+	extensions = append(extensions, pkix.Extension{Id: TCertEncEnrollmentID, Critical: false, Value: encEnrollmentID})
+
+	return extensions, preK0, nil
+}
+
+// Generate the extensions of the attributes to be included into the TCert, and the preK0 they are encrypted with.
+func (tcap *TCAP) generateAttributeExtensions(tcertid *big.Int, enrollmentCert *x509.Certificate, attrs []*pb.ACAAttribute) ([]pkix.Extension, []byte, error) {
+	// For each TCert we need to store and retrieve to the user the list of Ks used to encrypt the EnrollmentID and the attributes.
+	extensions := make([]pkix.Extension, len(attrs))
+
+	// Compute preK_1 to encrypt attributes and enrollment ID
+	preK1, err := tcap.tca.getPreKFrom(enrollmentCert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mac := hmac.New(primitives.GetDefaultHash(), preK1)
+	mac.Write(tcertid.Bytes())
+	preK0 := mac.Sum(nil)
+
 	attributeIdentifierIndex := 9
 	count := 0
 	attrsHeader := make(map[string]int)
@@ -554,12 +757,6 @@ func (tcap *TCAP) generateExtensions(tcertid *big.Int, tidx []byte, enrollmentCe
 		extensions[count-1] = pkix.Extension{Id: TCertEncAttributes, Critical: false, Value: value}
 	}
 
-	// Append the TCertIndex to the extensions
-	extensions = append(extensions, pkix.Extension{Id: TCertEncTCertIndex, Critical: true, Value: tidx})
-
-	// Append the encrypted EnrollmentID to the extensions
-	extensions = append(extensions, pkix.Extension{Id: TCertEncEnrollmentID, Critical: false, Value: encEnrollmentID})
-
 	// Append the attributes header if there was attributes to include in the TCert
 	if len(attrs) > 0 {
 		headerValue, err := attributes.BuildAttributesHeader(attrsHeader)
@@ -613,6 +810,12 @@ func (tcaa *TCAA) PublishCRL(context.Context, *pb.TCertCRLReq) (*pb.CAStatus, er
 	return nil, errors.New("not yet implemented")
 }
 
+// isSplitKeyDerivation returns whether the TCA issues split-key TCerts, which
+// it can not link to each other once issued
+func isSplitKeyDerivation() bool {
+	return viper.GetString("tca.derivation") == "split"
+}
+
 func isEnabledAttributesEncryption() bool {
 	//TODO this code is commented because attributes encryption is not yet implemented.
 	//return viper.GetBool("tca.attribute-encryption.enabled")
@@ -23,7 +23,9 @@ import (
 	"fmt"
 	"google/protobuf"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -31,8 +33,10 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/attributes"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/membersrvc/protos"
+	"github.com/spf13/viper"
 )
 
 func TestNewTCA(t *testing.T) {
@@ -106,6 +110,166 @@ func TestCreateCertificateSet(t *testing.T) {
 	}
 }
 
+func TestCreateCertificateSetWithEnrollmentAttributes(t *testing.T) {
+	tca, err := initTCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollmentID := "test_user0"
+	ecertRaw, priv, err := loadECertAndEnrollmentPrivateKey(enrollmentID, "MS9qrN8hFjlE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificateSetRequest, err := buildCertificateSetRequest(enrollmentID, priv, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificateSetRequest.Attributes = []*protos.TCertAttribute{
+		{AttributeName: attributes.EnrollmentRoleAttributeName},
+		{AttributeName: attributes.EnrollmentAffiliationAttributeName},
+	}
+	certificateSetRequest.Sig = nil
+	rawReq, err := proto.Marshal(certificateSetRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, err := primitives.ECDSASignDirect(priv, rawReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	certificateSetRequest.Sig = &protos.Signature{Type: protos.CryptoType_ECDSA, R: R, S: S}
+
+	response, err := (&TCAP{tca}).createCertificateSet(context.Background(), ecertRaw, certificateSetRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcert, err := x509.ParseCertificate(response.GetCerts().Certs[0].Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		attributes.EnrollmentRoleAttributeName:        "CLIENT",
+		attributes.EnrollmentAffiliationAttributeName: "bank_a",
+	}
+	for name, value := range expected {
+		raw, _, err := attributes.ReadTCertAttribute(tcert, name, nil)
+		if err != nil {
+			t.Fatalf("Failed reading attribute %s: %s", name, err)
+		}
+		if string(raw) != value {
+			t.Fatalf("Expected attribute %s to be %s, got %s", name, value, raw)
+		}
+	}
+}
+
+func TestCreateSplitKeyCertificateSet(t *testing.T) {
+	viper.Set("tca.derivation", "split")
+	defer viper.Set("tca.derivation", "")
+
+	tca, err := initTCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enrollmentID := "test_user0"
+	ecertRaw, priv, err := loadECertAndEnrollmentPrivateKey(enrollmentID, "MS9qrN8hFjlE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	curve := priv.Curve
+
+	ncerts := 2
+	var secrets []*big.Int
+	var shares [][]byte
+	var keyShares []*protos.TCertKeyShare
+	for i := 0; i < ncerts; i++ {
+		nonce, err := primitives.GetRandomBytes(16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := primitives.ExpandScalar(curve, []byte("blinding key"), nonce)
+		secrets = append(secrets, secret)
+		shares = append(shares, primitives.NewKeyShare(curve, secret))
+		keyShares = append(keyShares, &protos.TCertKeyShare{Nonce: nonce, Share: shares[i]})
+	}
+	proof, err := primitives.NewKeyShareBatchProof(curve, secrets, shares, ecertRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(req *protos.TCertCreateSetReq) {
+		req.Sig = nil
+		rawReq, err := proto.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, s, err := primitives.ECDSASignDirect(priv, rawReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		R, _ := r.MarshalText()
+		S, _ := s.MarshalText()
+		req.Sig = &protos.Signature{Type: protos.CryptoType_ECDSA, R: R, S: S}
+	}
+
+	req, err := buildCertificateSetRequest(enrollmentID, priv, ncerts, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (&TCAP{tca}).createCertificateSet(context.Background(), ecertRaw, req); err == nil {
+		t.Fatal("Split-key TCerts must not be issued without key shares")
+	}
+
+	req.KeyShares = keyShares
+	req.KeyShareProof, err = primitives.NewKeyShareBatchProof(curve, secrets, shares, []byte("another enrollment certificate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign(req)
+	if _, err = (&TCAP{tca}).createCertificateSet(context.Background(), ecertRaw, req); err == nil {
+		t.Fatal("Split-key TCerts must not be issued with an invalid key share proof")
+	}
+
+	req.KeyShareProof = proof
+	sign(req)
+	response, err := (&TCAP{tca}).createCertificateSet(context.Background(), ecertRaw, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expansionKey := primitives.HMAC(response.GetCerts().Key, []byte{2})
+	for i, tcertRaw := range response.GetCerts().Certs {
+		tcert, err := x509.ParseCertificate(tcertRaw.Cert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tcert.Subject.CommonName != "" {
+			t.Fatalf("Split-key TCert must not name its owner, got %s", tcert.Subject.CommonName)
+		}
+		nonce, err := primitives.GetCriticalExtension(tcert, TCertSplitKeyNonce)
+		if err != nil || !reflect.DeepEqual(nonce, keyShares[i].Nonce) {
+			t.Fatalf("Split-key TCert must carry the nonce of its key share: %v", err)
+		}
+		if _, err = primitives.GetCriticalExtension(tcert, TCertEncTCertIndex); err == nil {
+			t.Fatal("Split-key TCert must not carry a TCertIndex")
+		}
+
+		// The key of the TCert is the enrollment key plus the expansion value plus the blinding value
+		d := new(big.Int).Add(priv.D, primitives.ExpandScalar(curve, expansionKey, nonce))
+		d.Add(d, secrets[i])
+		d.Mod(d, curve.Params().N)
+		x, y := curve.ScalarBaseMult(d.Bytes())
+		pub := tcert.PublicKey.(*ecdsa.PublicKey)
+		if pub.X.Cmp(x) != 0 || pub.Y.Cmp(y) != 0 {
+			t.Fatal("Split-key TCert key does not derive from the enrollment key and the key share")
+		}
+	}
+}
+
 func loadECertAndEnrollmentPrivateKey(enrollmentID string, password string) ([]byte, *ecdsa.PrivateKey, error) {
 	cooked, err := ioutil.ReadFile("./test_resources/key_" + enrollmentID + ".dump")
 	if err != nil {
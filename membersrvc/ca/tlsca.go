@@ -19,12 +19,9 @@ package ca
 import (
 	"crypto/ecdsa"
 	"crypto/x509"
-	"database/sql"
 	"errors"
-	"math/big"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/hyperledger/fabric/core/crypto/primitives"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -49,7 +46,7 @@ type TLSCAA struct {
 	tlsca *TLSCA
 }
 
-func initializeTLSCATables(db *sql.DB) error {
+func initializeTLSCATables(db *DB) error {
 	return initializeCommonTables(db)
 }
 
@@ -96,11 +93,6 @@ func (tlscap *TLSCAP) CreateCertificate(ctx context.Context, in *pb.TLSCertCreat
 	sig := in.Sig
 	in.Sig = nil
 
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(sig.R)
-	s.UnmarshalText(sig.S)
-
-	raw := in.Pub.Key
 	if in.Pub.Type != pb.CryptoType_ECDSA {
 		return nil, errors.New("unsupported key type")
 	}
@@ -108,15 +100,16 @@ func (tlscap *TLSCAP) CreateCertificate(ctx context.Context, in *pb.TLSCertCreat
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := pub.(*ecdsa.PublicKey); !ok {
+		return nil, errors.New("unsupported key type")
+	}
 
-	hash := primitives.NewHash()
-	raw, _ = proto.Marshal(in)
-	hash.Write(raw)
-	if ecdsa.Verify(pub.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+	raw, _ := proto.Marshal(in)
+	if !verifySignature(pub, raw, sig) {
 		return nil, errors.New("signature does not verify")
 	}
 
-	if raw, err = tlscap.tlsca.createCertificate(id, pub.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, in.Ts.Seconds, nil); err != nil {
+	if raw, err = tlscap.tlsca.createCertificate(id, pub, x509.KeyUsageDigitalSignature, in.Ts.Seconds, nil); err != nil {
 		Error.Println(err)
 		return nil, err
 	}
@@ -26,11 +26,14 @@ It has these top-level messages:
 	ECertCreateResp
 	ECertReadReq
 	ECertRevokeReq
+	ECertRenewReq
 	ECertCRLReq
+	ECertStatus
 	TCertCreateReq
 	TCertCreateResp
 	TCertCreateSetReq
 	TCertAttribute
+	TCertKeyShare
 	TCertCreateSetResp
 	TCertReadSetsReq
 	TCertRevokeReq
@@ -73,20 +76,23 @@ var _ = math.Inf
 type CryptoType int32
 
 const (
-	CryptoType_ECDSA CryptoType = 0
-	CryptoType_RSA   CryptoType = 1
-	CryptoType_DSA   CryptoType = 2
+	CryptoType_ECDSA   CryptoType = 0
+	CryptoType_RSA     CryptoType = 1
+	CryptoType_DSA     CryptoType = 2
+	CryptoType_ED25519 CryptoType = 3
 )
 
 var CryptoType_name = map[int32]string{
 	0: "ECDSA",
 	1: "RSA",
 	2: "DSA",
+	3: "ED25519",
 }
 var CryptoType_value = map[string]int32{
-	"ECDSA": 0,
-	"RSA":   1,
-	"DSA":   2,
+	"ECDSA":   0,
+	"RSA":     1,
+	"DSA":     2,
+	"ED25519": 3,
 }
 
 func (x CryptoType) String() string {
@@ -147,6 +153,30 @@ func (x CAStatus_StatusCode) String() string {
 	return proto.EnumName(CAStatus_StatusCode_name, int32(x))
 }
 
+type ECertStatus_StatusCode int32
+
+const (
+	ECertStatus_GOOD    ECertStatus_StatusCode = 0
+	ECertStatus_REVOKED ECertStatus_StatusCode = 1
+	// not issued by the ECA
+	ECertStatus_UNKNOWN ECertStatus_StatusCode = 2
+)
+
+var ECertStatus_StatusCode_name = map[int32]string{
+	0: "GOOD",
+	1: "REVOKED",
+	2: "UNKNOWN",
+}
+var ECertStatus_StatusCode_value = map[string]int32{
+	"GOOD":    0,
+	"REVOKED": 1,
+	"UNKNOWN": 2,
+}
+
+func (x ECertStatus_StatusCode) String() string {
+	return proto.EnumName(ECertStatus_StatusCode_name, int32(x))
+}
+
 type ACAAttrResp_StatusCode int32
 
 const (
@@ -306,7 +336,7 @@ func (m *PrivateKey) Reset()         { *m = PrivateKey{} }
 func (m *PrivateKey) String() string { return proto.CompactTextString(m) }
 func (*PrivateKey) ProtoMessage()    {}
 
-// Signature.
+// Signature. ECDSA signatures are the (r, s) pair, Ed25519 signatures are r.
 //
 type Signature struct {
 	Type CryptoType `protobuf:"varint,1,opt,name=type,enum=protos.CryptoType" json:"type,omitempty"`
@@ -567,6 +597,53 @@ func (m *ECertRevokeReq) GetSig() *Signature {
 	return nil
 }
 
+type ECertRenewReq struct {
+	Ts   *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Id   *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Cert *Cert                      `protobuf:"bytes,3,opt,name=cert" json:"cert,omitempty"`
+	Enc  *PublicKey                 `protobuf:"bytes,4,opt,name=enc" json:"enc,omitempty"`
+	Sig  *Signature                 `protobuf:"bytes,5,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *ECertRenewReq) Reset()         { *m = ECertRenewReq{} }
+func (m *ECertRenewReq) String() string { return proto.CompactTextString(m) }
+func (*ECertRenewReq) ProtoMessage()    {}
+
+func (m *ECertRenewReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetCert() *Cert {
+	if m != nil {
+		return m.Cert
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetEnc() *PublicKey {
+	if m != nil {
+		return m.Enc
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
 type ECertCRLReq struct {
 	Id  *Identity  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Sig *Signature `protobuf:"bytes,2,opt,name=sig" json:"sig,omitempty"`
@@ -590,6 +667,39 @@ func (m *ECertCRLReq) GetSig() *Signature {
 	return nil
 }
 
+type ECertStatus struct {
+	Status  ECertStatus_StatusCode     `protobuf:"varint,1,opt,name=status,enum=protos.ECertStatus_StatusCode" json:"status,omitempty"`
+	Hash    []byte                     `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Ts      *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=ts" json:"ts,omitempty"`
+	Revoked *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=revoked" json:"revoked,omitempty"`
+	Sig     *Signature                 `protobuf:"bytes,5,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *ECertStatus) Reset()         { *m = ECertStatus{} }
+func (m *ECertStatus) String() string { return proto.CompactTextString(m) }
+func (*ECertStatus) ProtoMessage()    {}
+
+func (m *ECertStatus) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *ECertStatus) GetRevoked() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Revoked
+	}
+	return nil
+}
+
+func (m *ECertStatus) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
 type TCertCreateReq struct {
 	Ts  *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
 	Id  *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
@@ -650,6 +760,9 @@ type TCertCreateSetReq struct {
 	Num        uint32                     `protobuf:"varint,3,opt,name=num" json:"num,omitempty"`
 	Attributes []*TCertAttribute          `protobuf:"bytes,4,rep,name=attributes" json:"attributes,omitempty"`
 	Sig        *Signature                 `protobuf:"bytes,5,opt,name=sig" json:"sig,omitempty"`
+	KeyShares  []*TCertKeyShare           `protobuf:"bytes,6,rep,name=keyShares" json:"keyShares,omitempty"`
+	// proof of knowledge of the blinding values of keyShares
+	KeyShareProof []byte `protobuf:"bytes,7,opt,name=keyShareProof,proto3" json:"keyShareProof,omitempty"`
 }
 
 func (m *TCertCreateSetReq) Reset()         { *m = TCertCreateSetReq{} }
@@ -684,6 +797,24 @@ func (m *TCertCreateSetReq) GetSig() *Signature {
 	return nil
 }
 
+func (m *TCertCreateSetReq) GetKeyShares() []*TCertKeyShare {
+	if m != nil {
+		return m.KeyShares
+	}
+	return nil
+}
+
+// TCertKeyShare is the nonce of a split-key TCert, and the blinding value of
+// its key times the base point.
+type TCertKeyShare struct {
+	Nonce []byte `protobuf:"bytes,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Share []byte `protobuf:"bytes,2,opt,name=share,proto3" json:"share,omitempty"`
+}
+
+func (m *TCertKeyShare) Reset()         { *m = TCertKeyShare{} }
+func (m *TCertKeyShare) String() string { return proto.CompactTextString(m) }
+func (*TCertKeyShare) ProtoMessage()    {}
+
 type TCertAttribute struct {
 	AttributeName string `protobuf:"bytes,1,opt,name=attributeName" json:"attributeName,omitempty"`
 }
@@ -1191,6 +1322,7 @@ func init() {
 	proto.RegisterEnum("protos.CryptoType", CryptoType_name, CryptoType_value)
 	proto.RegisterEnum("protos.Role", Role_name, Role_value)
 	proto.RegisterEnum("protos.CAStatus_StatusCode", CAStatus_StatusCode_name, CAStatus_StatusCode_value)
+	proto.RegisterEnum("protos.ECertStatus_StatusCode", ECertStatus_StatusCode_name, ECertStatus_StatusCode_value)
 	proto.RegisterEnum("protos.ACAAttrResp_StatusCode", ACAAttrResp_StatusCode_name, ACAAttrResp_StatusCode_value)
 	proto.RegisterEnum("protos.ACAFetchAttrResp_StatusCode", ACAFetchAttrResp_StatusCode_name, ACAFetchAttrResp_StatusCode_value)
 	proto.RegisterEnum("protos.FetchAttrsResult_StatusCode", FetchAttrsResult_StatusCode_name, FetchAttrsResult_StatusCode_value)
@@ -1208,6 +1340,8 @@ type ECAPClient interface {
 	ReadCertificatePair(ctx context.Context, in *ECertReadReq, opts ...grpc.CallOption) (*CertPair, error)
 	ReadCertificateByHash(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*Cert, error)
 	RevokeCertificatePair(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadCertificateStatus(ctx context.Context, in *Cert, opts ...grpc.CallOption) (*ECertStatus, error)
+	RenewCertificatePair(ctx context.Context, in *ECertRenewReq, opts ...grpc.CallOption) (*ECertCreateResp, error)
 }
 
 type eCAPClient struct {
@@ -1263,6 +1397,24 @@ func (c *eCAPClient) RevokeCertificatePair(ctx context.Context, in *ECertRevokeR
 	return out, nil
 }
 
+func (c *eCAPClient) ReadCertificateStatus(ctx context.Context, in *Cert, opts ...grpc.CallOption) (*ECertStatus, error) {
+	out := new(ECertStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAP/ReadCertificateStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAPClient) RenewCertificatePair(ctx context.Context, in *ECertRenewReq, opts ...grpc.CallOption) (*ECertCreateResp, error) {
+	out := new(ECertCreateResp)
+	err := grpc.Invoke(ctx, "/protos.ECAP/RenewCertificatePair", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for ECAP service
 
 type ECAPServer interface {
@@ -1271,6 +1423,8 @@ type ECAPServer interface {
 	ReadCertificatePair(context.Context, *ECertReadReq) (*CertPair, error)
 	ReadCertificateByHash(context.Context, *Hash) (*Cert, error)
 	RevokeCertificatePair(context.Context, *ECertRevokeReq) (*CAStatus, error)
+	ReadCertificateStatus(context.Context, *Cert) (*ECertStatus, error)
+	RenewCertificatePair(context.Context, *ECertRenewReq) (*ECertCreateResp, error)
 }
 
 func RegisterECAPServer(s *grpc.Server, srv ECAPServer) {
@@ -1337,6 +1491,30 @@ func _ECAP_RevokeCertificatePair_Handler(srv interface{}, ctx context.Context, d
 	return out, nil
 }
 
+func _ECAP_ReadCertificateStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Cert)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).ReadCertificateStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAP_RenewCertificatePair_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ECertRenewReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).RenewCertificatePair(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _ECAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.ECAP",
 	HandlerType: (*ECAPServer)(nil),
@@ -1361,6 +1539,14 @@ var _ECAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificatePair",
 			Handler:    _ECAP_RevokeCertificatePair_Handler,
 		},
+		{
+			MethodName: "ReadCertificateStatus",
+			Handler:    _ECAP_ReadCertificateStatus_Handler,
+		},
+		{
+			MethodName: "RenewCertificatePair",
+			Handler:    _ECAP_RenewCertificatePair_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
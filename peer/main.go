@@ -17,13 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -36,27 +39,37 @@ import (
 
 	"google/protobuf"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/howeyc/gopass"
 	"github.com/op/go-logging"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
+	"gopkg.in/yaml.v2"
 
 	"net/http"
 	_ "net/http/pprof"
 
 	"github.com/hyperledger/fabric/consensus/helper"
+	"github.com/hyperledger/fabric/consensus/obcpbft"
 	"github.com/hyperledger/fabric/core"
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/container"
 	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/genesis"
+	"github.com/hyperledger/fabric/core/metrics"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/rest"
 	"github.com/hyperledger/fabric/core/system_chaincode"
+	"github.com/hyperledger/fabric/core/tracing"
+	"github.com/hyperledger/fabric/events/bridge"
 	"github.com/hyperledger/fabric/events/producer"
+	"github.com/hyperledger/fabric/events/webhook"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -67,6 +80,9 @@ const fabric = "hyperledger"
 const nodeFuncName = "node"
 const networkFuncName = "network"
 const chainFuncName = "chaincode"
+const ledgerFuncName = "ledger"
+const configFuncName = "config"
+const loggingFuncName = "logging"
 const cmdRoot = "core"
 const undefinedParamValue = ""
 
@@ -110,6 +126,15 @@ var (
 	stopPidFile string
 )
 
+var nodeReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reloads the configuration of the running node.",
+	Long:  `Has the running node read core.yaml, and the configuration of its consensus plugin, again and apply the changes of the values it can change while running: the logging levels, the event buffer size and timeout, and the chaincode and PBFT timeouts. The node reloads them on SIGHUP as well.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return reload()
+	},
+}
+
 var nodeStopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stops the running node.",
@@ -159,17 +184,58 @@ var networkListCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
 	Short:   "Lists all network peers.",
-	Long:    `Returns a list of all existing network connections for the target peer node, includes both validating and non-validating peers.`,
+	Long:    `Returns a list of all existing network connections for the target peer node, includes both validating and non-validating peers, with the heights of their blockchains.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return networkList()
 	},
 }
 
+var networkStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Returns the network status of the peer.",
+	Long:  `Returns the endpoint and blockchain height of the target peer node, the peers it is connected to and their blockchain heights, and the metrics of its consensus plugin.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkStatus()
+	},
+}
+
+var networkJoinCmd = &cobra.Command{
+	Use:   "join <address>",
+	Short: "Connects the peer to another peer.",
+	Long:  `Has the target peer node connect to the peer at the address, and keep connecting to it as to the peers it discovered.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkJoin(args)
+	},
+}
+
+var networkRemoveCmd = &cobra.Command{
+	Use:   "remove <address|ID>",
+	Short: "Disconnects the peer from another peer.",
+	Long:  `Has the target peer node end its connections to the peer of the address or ID, and forget the address if it was discovered.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkRemove(args)
+	},
+}
+
+var networkReconfigureCmd = &cobra.Command{
+	Use:   "reconfigure <N> <f>",
+	Short: "Reconfigures the validating peers taking part in consensus.",
+	Long:  `Submits a transaction reconfiguring consensus to N validating peers tolerating f faults, signed by the consensus admin logged in as username. The validating peers must be running with reconfiguration enabled.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkReconfigure(cmd, args)
+	},
+}
+
 // login related variables.
 var (
 	loginPW string
 )
 
+// reconfiguration related variables.
+var (
+	reconfigureUsr string
+)
+
 // Chaincode-related variables.
 var (
 	chaincodeLang           string
@@ -180,7 +246,9 @@ var (
 	chaincodeUsr            string
 	chaincodeQueryRaw       bool
 	chaincodeQueryHex       bool
+	chaincodeQueryQuorum    bool
 	chaincodeAttributesJSON string
+	chaincodePackageOutput  string
 )
 
 var chaincodeCmd = &cobra.Command{
@@ -204,6 +272,53 @@ var chaincodeDeployCmd = &cobra.Command{
 	},
 }
 
+var chaincodeUpgradeCmd = &cobra.Command{
+	Use:       "upgrade",
+	Short:     fmt.Sprintf("Upgrade the specified %s to a new version.", chainFuncName),
+	Long:      fmt.Sprintf(`Deploy the %s at the given path as the new version of the named %s, which keeps its state.`, chainFuncName, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeUpgrade(cmd, args)
+	},
+}
+
+var chaincodePackageCmd = &cobra.Command{
+	Use:   "package",
+	Short: fmt.Sprintf("Package the specified %s for deployment.", chainFuncName),
+	Long:  fmt.Sprintf(`Build the deployment package of the %s at the given path with the given constructor, offline, and write it to the output file, <name>.cds by default. The name of the %s is printed.`, chainFuncName, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodePackage(cmd, args)
+	},
+}
+
+var chaincodeSignCmd = &cobra.Command{
+	Use:   "sign <package>",
+	Short: fmt.Sprintf("Sign a %s package.", chainFuncName),
+	Long:  fmt.Sprintf(`Sign the %s package with the enrollment key of the user, who must be registered on this host, and write it to the output file, the package itself by default.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeSign(cmd, args)
+	},
+}
+
+var chaincodeInstallCmd = &cobra.Command{
+	Use:   "install <package>",
+	Short: fmt.Sprintf("Deploy a %s package to the network.", chainFuncName),
+	Long:  fmt.Sprintf(`Deploy the %s package built by the package command, keeping its signature if signed. The name of the %s is printed.`, chainFuncName, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeInstall(cmd, args)
+	},
+}
+
+var chaincodeListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   fmt.Sprintf("List the deployed %ss.", chainFuncName),
+	Long:    fmt.Sprintf(`List the %ss deployed to the network, with their versions and the hashes of their packages.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeList()
+	},
+}
+
 var chaincodeInvokeCmd = &cobra.Command{
 	Use:       "invoke",
 	Short:     fmt.Sprintf("Invoke the specified %s.", chainFuncName),
@@ -224,6 +339,137 @@ var chaincodeQueryCmd = &cobra.Command{
 	},
 }
 
+var ledgerCmd = &cobra.Command{
+	Use:   ledgerFuncName,
+	Short: fmt.Sprintf("%s specific commands.", ledgerFuncName),
+	Long:  fmt.Sprintf("%s specific commands. These commands open the local ledger and require the node to be stopped.", ledgerFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(ledgerFuncName)
+	},
+}
+
+var (
+	ledgerStartBlock int64
+	ledgerEndBlock   int64
+
+	ledgerNumBuckets             int
+	ledgerMaxGroupingAtEachLevel int
+)
+
+var ledgerVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies the integrity of the ledger.",
+	Long:  `Verifies the hash chaining, the indexes and, for the last block, the state hash of the blocks of the local ledger.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerVerify(false)
+	},
+}
+
+var ledgerRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Rebuilds the indexes of the ledger.",
+	Long:  `Rebuilds the indexes of the blocks of the local ledger from the block store, then verifies the ledger.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerVerify(true)
+	},
+}
+
+var ledgerExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Exports the chain to a file.",
+	Long:  `Exports the blocks of the local ledger and the state at the last block to a file, which can be imported into the ledger of another peer.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerExport(args)
+	},
+}
+
+var ledgerImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Imports a chain from a file.",
+	Long:  `Imports a chain exported by the ledger export command into the local ledger, which must be empty. The blocks and the state are verified against their hashes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerImport(args)
+	},
+}
+
+var ledgerRehashCmd = &cobra.Command{
+	Use:   "rehash",
+	Short: "Rebuilds the bucket tree of the state for a new configuration.",
+	Long: `Rebuilds the bucket tree of the world state of the local ledger, which was built with the bucket tree configuration of the core.yaml in use, for the given numBuckets and maxGroupingAtEachLevel.
+The core.yaml must be updated with the new configuration before restarting the peer. All the peers of a network must be rehashed at the same block, as the state hashes of the blocks following it depend on the configuration.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerRehash()
+	},
+}
+
+var ledgerRotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Rotates the data key of the encrypted local DB.",
+	Long:  `Makes a new data key current for the encrypted DB of the local ledger, as set by peer.db.encryption, re-encrypts all the values with it and retires the previous keys. A running peer rotates its key every peer.db.encryption.rotationInterval instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerRotateKey()
+	},
+}
+
+var configCmd = &cobra.Command{
+	Use:   configFuncName,
+	Short: fmt.Sprintf("%s specific commands.", configFuncName),
+	Long:  fmt.Sprintf("%s specific commands. These commands check the configuration of the peer without starting it.", configFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(configFuncName)
+	},
+}
+
+var (
+	configShowEffective bool
+	configShowConsensus bool
+)
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates the configuration.",
+	Long:  `Checks the types, ranges and required keys of the peer, consensus, security and vm sections of core.yaml, and of the configuration of the PBFT plugin if it is selected, environment overrides included.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return configValidate()
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Prints the configuration.",
+	Long:  `Prints the configuration read from core.yaml, or from the configuration of the consensus plugin, as YAML. With --effective, the values are the ones the peer uses, including the environment overrides of the keys of the file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return configShow()
+	},
+}
+
+var loggingCmd = &cobra.Command{
+	Use:   loggingFuncName,
+	Short: fmt.Sprintf("%s specific commands.", loggingFuncName),
+	Long:  fmt.Sprintf("%s specific commands. These commands get and set the logging levels of the running node.", loggingFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(loggingFuncName)
+	},
+}
+
+var loggingGetCmd = &cobra.Command{
+	Use:   "get [module]",
+	Short: "Returns the logging levels of the node.",
+	Long:  `Returns the logging levels in effect for the module and its submodules, or for all the modules of the running node.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return loggingGet(args)
+	},
+}
+
+var loggingSetCmd = &cobra.Command{
+	Use:   "set <spec>...",
+	Short: "Sets the logging levels of the node.",
+	Long:  `Sets the logging levels of the running node until it restarts, with a specification of the syntax of core.yaml, e.g. consensus=debug or warning:chaincode,ledger=debug. The level of a module also applies to its submodules without a level of their own.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return loggingSet(args)
+	},
+}
+
 func main() {
 	// For environment variables.
 	viper.SetEnvPrefix(cmdRoot)
@@ -236,6 +482,8 @@ func main() {
 	mainFlags := mainCmd.PersistentFlags()
 	mainFlags.String("logging-level", "", "Default logging level and overrides, see core.yaml for full syntax")
 	viper.BindPFlag("logging_level", mainFlags.Lookup("logging-level"))
+	mainFlags.String("logging-format", "", "Format of the log output, text or json")
+	viper.BindPFlag("logging_format", mainFlags.Lookup("logging-format"))
 
 	// Set the flags on the node start command.
 	flags := nodeStartCmd.Flags()
@@ -273,6 +521,7 @@ func main() {
 
 	nodeStopCmd.Flags().StringVarP(&stopPidFile, "stop-peer-pid-file", "", viper.GetString("peer.fileSystemPath"), "Location of peer pid local file, for forces kill")
 	nodeCmd.AddCommand(nodeStopCmd)
+	nodeCmd.AddCommand(nodeReloadCmd)
 
 	mainCmd.AddCommand(nodeCmd)
 
@@ -285,6 +534,11 @@ func main() {
 	// mainCmd.AddCommand(vmCmd)
 
 	networkCmd.AddCommand(networkListCmd)
+	networkCmd.AddCommand(networkStatusCmd)
+	networkCmd.AddCommand(networkJoinCmd)
+	networkCmd.AddCommand(networkRemoveCmd)
+	networkReconfigureCmd.Flags().StringVarP(&reconfigureUsr, "username", "u", undefinedParamValue, "Username of the consensus admin signing the reconfiguration")
+	networkCmd.AddCommand(networkReconfigureCmd)
 
 	mainCmd.AddCommand(networkCmd)
 
@@ -297,18 +551,64 @@ func main() {
 
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryRaw, "raw", "r", false, "If true, output the query value as raw bytes, otherwise format as a printable string")
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryHex, "hex", "x", false, "If true, output the query value byte array in hexadecimal. Incompatible with --raw")
+	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryQuorum, "quorum", "q", false, "If true, query the validating peers through the target peer, and output the value only if f+1 of them agree on it")
+
+	chaincodePackageCmd.Flags().StringVarP(&chaincodePackageOutput, "output", "o", "", "File the package is written to")
+	chaincodeSignCmd.Flags().StringVarP(&chaincodePackageOutput, "output", "o", "", "File the signed package is written to")
 
 	chaincodeCmd.AddCommand(chaincodeDeployCmd)
+	chaincodeCmd.AddCommand(chaincodeUpgradeCmd)
+	chaincodeCmd.AddCommand(chaincodePackageCmd)
+	chaincodeCmd.AddCommand(chaincodeSignCmd)
+	chaincodeCmd.AddCommand(chaincodeInstallCmd)
+	chaincodeCmd.AddCommand(chaincodeListCmd)
 	chaincodeCmd.AddCommand(chaincodeInvokeCmd)
 	chaincodeCmd.AddCommand(chaincodeQueryCmd)
 
 	mainCmd.AddCommand(chaincodeCmd)
 
+	for _, cmd := range []*cobra.Command{ledgerVerifyCmd, ledgerRepairCmd} {
+		cmd.Flags().Int64VarP(&ledgerStartBlock, "start-block", "s", 0, "First block of the range of blocks")
+		cmd.Flags().Int64VarP(&ledgerEndBlock, "end-block", "e", -1, "Last block of the range of blocks, the last block of the chain if negative")
+	}
+
+	ledgerRehashCmd.Flags().IntVar(&ledgerNumBuckets, "numBuckets", 0, "Number of buckets of the new bucket tree configuration")
+	ledgerRehashCmd.Flags().IntVar(&ledgerMaxGroupingAtEachLevel, "maxGroupingAtEachLevel", 0, "Number of buckets grouped at each level of the new bucket tree configuration")
+
+	ledgerCmd.AddCommand(ledgerVerifyCmd)
+	ledgerCmd.AddCommand(ledgerRepairCmd)
+	ledgerCmd.AddCommand(ledgerExportCmd)
+	ledgerCmd.AddCommand(ledgerImportCmd)
+	ledgerCmd.AddCommand(ledgerRehashCmd)
+	ledgerCmd.AddCommand(ledgerRotateKeyCmd)
+
+	mainCmd.AddCommand(ledgerCmd)
+
+	configShowCmd.Flags().BoolVarP(&configShowEffective, "effective", "e", false, "Print the values including the environment overrides")
+	configShowCmd.Flags().BoolVarP(&configShowConsensus, "consensus", "c", false, "Print the configuration of the PBFT consensus plugin")
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	mainCmd.AddCommand(configCmd)
+
+	loggingCmd.AddCommand(loggingGetCmd)
+	loggingCmd.AddCommand(loggingSetCmd)
+
+	mainCmd.AddCommand(loggingCmd)
+
 	runtime.GOMAXPROCS(viper.GetInt("peer.gomaxprocs"))
 
-	// Init the crypto layer
+	// The command-line options are only parsed when the command runs, the
+	// format of core.yaml or CORE_LOGGING_FORMAT applies until then
+	core.LoggingFormatInit()
+
+	// Init the crypto layer. The config commands report an invalid security
+	// configuration rather than failing on it.
 	if err := crypto.Init(); err != nil {
-		panic(fmt.Errorf("Failed to initialize the crypto layer: %s", err))
+		if cmd, _, _ := mainCmd.Find(os.Args[1:]); cmd == nil || (cmd != configCmd && cmd.Parent() != configCmd) {
+			panic(fmt.Errorf("Failed to initialize the crypto layer: %s", err))
+		}
 	}
 
 	// On failure Cobra prints the usage message and error string, so we only
@@ -318,12 +618,41 @@ func main() {
 	}
 }
 
+// createLocalSocketServer creates the server of the devops, openchain and
+// admin services on the unix socket of peer.localSocket.path, if set, for
+// the clients running on the host of the peer. Its access is controlled by
+// the permissions of the socket, peer.localSocket.mode, rather than by TLS.
+func createLocalSocketServer(devops pb.DevopsServer, openchain pb.OpenchainServer, admin pb.AdminServer) (*grpc.Server, net.Listener, error) {
+	path := viper.GetString("peer.localSocket.path")
+	if path == "" {
+		return nil, nil, nil
+	}
+	mode, err := strconv.ParseUint(viper.GetString("peer.localSocket.mode"), 8, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid peer.localSocket.mode, expected octal permissions: %s", err)
+	}
+	lis, err := comm.ListenUnixSocket(path, os.FileMode(mode))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to listen on the local socket: %s", err)
+	}
+	logger.Infof("Serving the client services on unix socket %s", path)
+	server := grpc.NewServer(comm.ServerOptions()...)
+	pb.RegisterDevopsServer(server, devops)
+	pb.RegisterOpenchainServer(server, openchain)
+	pb.RegisterAdminServer(server, admin)
+	return server, lis, nil
+}
+
 func createEventHubServer() (net.Listener, *grpc.Server, error) {
 	var lis net.Listener
 	var grpcServer *grpc.Server
 	var err error
 	if peer.ValidatorEnabled() {
-		lis, err = net.Listen("tcp", viper.GetString("peer.validator.events.address"))
+		address, err := comm.NormalizeAddress(viper.GetString("peer.validator.events.address"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid peer.validator.events.address: %v", err)
+		}
+		lis, err = net.Listen("tcp", address)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to listen: %v", err)
 		}
@@ -331,7 +660,7 @@ func createEventHubServer() (net.Listener, *grpc.Server, error) {
 		//TODO - do we need different SSL material for events ?
 		var opts []grpc.ServerOption
 		if comm.TLSEnabled() {
-			creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
+			creds, err := comm.ServerTLSCredentials()
 			if err != nil {
 				return nil, nil, fmt.Errorf("Failed to generate credentials %v", err)
 			}
@@ -340,11 +669,73 @@ func createEventHubServer() (net.Listener, *grpc.Server, error) {
 
 		grpcServer = grpc.NewServer(opts...)
 		ehServer := producer.NewEventsServer(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"))
+		ledgerPtr, err := ledger.GetLedger()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get the ledger to replay events from: %v", err)
+		}
+		ehServer.EnableReplay(ledgerPtr, viper.GetInt("peer.validator.events.subscriptions"))
 		pb.RegisterEventsServer(grpcServer, ehServer)
 	}
 	return lis, grpcServer, err
 }
 
+// createChaincodeServer creates the server the chaincodes register with if
+// chaincode.listenAddress is set, so that they do not reach the other
+// services of the peer, with TLS if chaincode.tls.enabled is set
+func createChaincodeServer() (net.Listener, *grpc.Server, error) {
+	if viper.GetString("chaincode.listenAddress") == "" {
+		return nil, nil, nil
+	}
+	address, err := comm.NormalizeAddress(viper.GetString("chaincode.listenAddress"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid chaincode.listenAddress: %v", err)
+	}
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen: %v", err)
+	}
+
+	var opts []grpc.ServerOption
+	if viper.GetBool("chaincode.tls.enabled") {
+		creds, err := comm.ChaincodeServerTLSCredentials()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to generate credentials %v", err)
+		}
+		opts = []grpc.ServerOption{grpc.Creds(creds)}
+	}
+	opts = append(opts, comm.ServerOptions()...)
+	return lis, grpc.NewServer(opts...), nil
+}
+
+// startWebhook delivers the events of the event hub at address to the
+// configured webhook endpoints
+func startWebhook(address string) error {
+	config, err := webhook.LoadConfig()
+	if err != nil {
+		return err
+	}
+	w, err := webhook.New(*config)
+	if err != nil {
+		return err
+	}
+	return w.Start(address)
+}
+
+// startBridge publishes the events of the event hub at address to the
+// configured Kafka or MQTT broker
+func startBridge(address string) error {
+	config, publisher, err := bridge.LoadConfig()
+	if err != nil {
+		return err
+	}
+	b, err := bridge.New(*config, publisher)
+	if err != nil {
+		publisher.Close()
+		return err
+	}
+	return b.Start(address)
+}
+
 var once sync.Once
 
 //this should be called exactly once and the result cached
@@ -400,6 +791,7 @@ func serve(args []string) error {
 	if err := peer.CacheConfiguration(); err != nil {
 		return err
 	}
+	tracing.Init()
 
 	peerEndpoint, err := peer.GetPeerEndpoint()
 	if err != nil {
@@ -412,6 +804,8 @@ func serve(args []string) error {
 	if "" == listenAddr {
 		logger.Debug("Listen address not specified, using peer endpoint address")
 		listenAddr = peerEndpoint.Address
+	} else if listenAddr, err = comm.NormalizeAddress(listenAddr); err != nil {
+		return fmt.Errorf("Invalid peer.listenAddress: %s", err)
 	}
 
 	lis, err := net.Listen("tcp", listenAddr)
@@ -424,6 +818,11 @@ func serve(args []string) error {
 		grpclog.Fatalf("Failed to create ehub server: %v", err)
 	}
 
+	ccLis, ccGrpcServer, err := createChaincodeServer()
+	if err != nil {
+		grpclog.Fatalf("Failed to create chaincode server: %v", err)
+	}
+
 	logger.Infof("Security enabled status: %t", core.SecurityEnabled())
 	if viper.GetBool("security.privacy") {
 		if core.SecurityEnabled() {
@@ -437,12 +836,13 @@ func serve(args []string) error {
 
 	var opts []grpc.ServerOption
 	if comm.TLSEnabled() {
-		creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
+		creds, err := comm.ServerTLSCredentials()
 		if err != nil {
 			grpclog.Fatalf("Failed to generate credentials %v", err)
 		}
 		opts = []grpc.ServerOption{grpc.Creds(creds)}
 	}
+	opts = append(opts, comm.ServerOptions()...)
 
 	grpcServer := grpc.NewServer(opts...)
 
@@ -455,11 +855,32 @@ func serve(args []string) error {
 		return secHelper
 	}
 
-	registerChaincodeSupport(chaincode.DefaultChain, grpcServer, secHelper)
+	if ccGrpcServer != nil {
+		registerChaincodeSupport(chaincode.DefaultChain, ccGrpcServer, secHelper)
+		go ccGrpcServer.Serve(ccLis)
+	} else {
+		registerChaincodeSupport(chaincode.DefaultChain, grpcServer, secHelper)
+	}
 
 	var peerServer *peer.PeerImpl
 
-	discInstance := core.NewStaticDiscovery(viper.GetString("peer.discovery.rootnode"))
+	provider, err := core.NewDiscoveryProvider()
+	if err != nil {
+		return err
+	}
+	var discInstance *core.StaticDiscovery
+	if provider != nil {
+		discInstance = core.NewDiscoveryWithProvider(viper.GetString("peer.discovery.rootnode"), provider)
+	} else {
+		discInstance = core.NewStaticDiscovery(viper.GetString("peer.discovery.rootnode"))
+	}
+
+	// Install the state of an existing peer before the genesis block is made
+	if address := viper.GetString("peer.snapshot.bootstrapAddress"); address != "" {
+		if err := peer.BootstrapFromSnapshot(address); err != nil {
+			return fmt.Errorf("Error bootstrapping from snapshot: %s", err)
+		}
+	}
 
 	//create the peerServer....
 	if peer.ValidatorEnabled() {
@@ -485,11 +906,28 @@ func serve(args []string) error {
 	//pb.RegisterPeerServer(grpcServer, openchain.NewPeer())
 	pb.RegisterPeerServer(grpcServer, peerServer)
 
-	// Register the Admin server
-	pb.RegisterAdminServer(grpcServer, core.NewAdminServer())
+	// Register the Snapshot server
+	if viper.GetBool("peer.snapshot.enabled") {
+		snapshotServer, err := peer.NewSnapshotServer()
+		if err != nil {
+			return fmt.Errorf("Error creating SnapshotServer: %s", err)
+		}
+		pb.RegisterSnapshotServer(grpcServer, snapshotServer)
+	}
 
-	// Register Devops server
-	serverDevops := core.NewDevopsServer(peerServer)
+	// Register the Admin and Devops servers, guarded by the ACL of
+	// peer.acl if enabled. REST and the local socket use them guarded too.
+	acl, err := core.NewACL()
+	if err != nil {
+		return err
+	}
+	var serverDevops pb.DevopsServer = core.NewDevopsServer(peerServer)
+	var serverAdmin pb.AdminServer = core.NewAdminServerWithNetwork(peerServer, helper.GetConsensusMetrics)
+	if acl != nil {
+		serverAdmin = acl.GuardAdmin(serverAdmin)
+		serverDevops = acl.GuardDevops(serverDevops)
+	}
+	pb.RegisterAdminServer(grpcServer, serverAdmin)
 	pb.RegisterDevopsServer(grpcServer, serverDevops)
 
 	// Register the ServerOpenchain server
@@ -501,6 +939,15 @@ func serve(args []string) error {
 
 	pb.RegisterOpenchainServer(grpcServer, serverOpenchain)
 
+	// Serve the client services on the local socket too, if configured
+	localServer, localLis, err := createLocalSocketServer(serverDevops, serverOpenchain, serverAdmin)
+	if err != nil {
+		return err
+	}
+	if localServer != nil {
+		go localServer.Serve(localLis)
+	}
+
 	// Create and register the REST service if configured
 	if viper.GetBool("rest.enabled") {
 		go rest.StartOpenchainRESTServer(serverOpenchain, serverDevops)
@@ -529,9 +976,64 @@ func serve(args []string) error {
 		return err
 	}
 
+	// Start the chaincodes the first transactions should not wait for, they
+	// connect to the grpc server
+	if peer.ValidatorEnabled() {
+		go chaincode.GetChain(chaincode.DefaultChain).PrestartChaincodes(context.Background())
+	}
+
 	//start the event hub server
 	if ehubGrpcServer != nil && ehubLis != nil {
 		go ehubGrpcServer.Serve(ehubLis)
+		if viper.GetBool("peer.validator.events.webhook.enabled") {
+			if err := startWebhook(ehubLis.Addr().String()); err != nil {
+				logger.Errorf("Failed to start the events webhook: %s", err)
+			}
+		}
+		if viper.GetBool("peer.validator.events.bridge.enabled") {
+			if err := startBridge(ehubLis.Addr().String()); err != nil {
+				logger.Errorf("Failed to start the events bridge: %s", err)
+			}
+		}
+	}
+
+	// Reload the configuration, and rotate the TLS certificates, on SIGHUP
+	var reloaders []func() (*pb.ConfigReload, error)
+	if peer.ValidatorEnabled() && strings.ToLower(viper.GetString("peer.validator.consensus.plugin")) == "pbft" {
+		reloaders = append(reloaders, helper.ReloadConsensusConfig)
+	}
+	core.EnableConfigReload(cmdRoot, reloaders...)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			logger.Info("Reloading the configuration on SIGHUP")
+			if _, err := core.ReloadConfig(); err != nil {
+				logger.Errorf("Error reloading the configuration: %s", err)
+			}
+			if comm.TLSEnabled() {
+				if err := comm.ReloadTLSCredentials(); err != nil {
+					logger.Errorf("Error reloading the TLS credentials, keeping the current ones: %s", err)
+				}
+			}
+		}
+	}()
+
+	if viper.GetBool("peer.metrics.enabled") {
+		metricsListenAddress := viper.GetString("peer.metrics.listenAddress")
+		if viper.GetBool("peer.profile.enabled") && metricsListenAddress == viper.GetString("peer.profile.listenAddress") {
+			// served by the profiling server
+			http.Handle("/metrics", metrics.Handler())
+		} else {
+			go func() {
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", metrics.Handler())
+				logger.Infof("Starting metrics server with listenAddress = %s", metricsListenAddress)
+				if metricsErr := http.ListenAndServe(metricsListenAddress, mux); metricsErr != nil {
+					logger.Errorf("Error starting metrics server: %s", metricsErr)
+				}
+			}()
+		}
 	}
 
 	if viper.GetBool("peer.profile.enabled") {
@@ -548,6 +1050,20 @@ func serve(args []string) error {
 	return <-serve
 }
 
+func reload() error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+	result, err := adminClient.ReloadConfig(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return fmt.Errorf("Error reloading the configuration: %s", err)
+	}
+	jsonOutput, _ := json.Marshal(result)
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
 func status() (err error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
@@ -775,6 +1291,14 @@ func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 	return
 }
 
+func getAdminClient() (pb.AdminClient, error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return nil, fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	return pb.NewAdminClient(clientConn), nil
+}
+
 func getDevopsClient(cmd *cobra.Command) (pb.DevopsClient, error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
@@ -871,64 +1395,300 @@ func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
 	return nil
 }
 
-func chaincodeInvoke(cmd *cobra.Command, args []string) error {
-	return chaincodeInvokeOrQuery(cmd, args, true)
-}
-
-func chaincodeQuery(cmd *cobra.Command, args []string) error {
-	return chaincodeInvokeOrQuery(cmd, args, false)
-}
-
-// chaincodeInvokeOrQuery invokes or queries the chaincode. If successful, the
-// INVOKE form prints the transaction ID on STDOUT, and the QUERY form prints
-// the query result on STDOUT. A command-line flag (-r, --raw) determines
-// whether the query result is output as raw bytes, or as a printable string.
-// The printable form is optionally (-x, --hex) a hexadecimal representation
-// of the query response. If the query response is NIL, nothing is output.
-func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err error) {
-
-	if err = checkChaincodeCmdParams(cmd); err != nil {
+// chaincodeUpgrade upgrades the chaincode named by the name parameter to the
+// chaincode at the path parameter. On success, the name (hash) of the new
+// version is printed to STDOUT.
+func chaincodeUpgrade(cmd *cobra.Command, args []string) (err error) {
+	if chaincodeName == undefinedParamValue || chaincodePath == undefinedParamValue {
+		err = fmt.Errorf("Must supply the name of the %s to upgrade and the path of the new version.\n", chainFuncName)
 		return
 	}
-
-	if chaincodeName == "" {
-		err = errors.New("Name not given for invoke/query")
+	if err = checkChaincodeCmdParams(cmd); err != nil {
 		return
 	}
-
 	devopsClient, err := getDevopsClient(cmd)
 	if err != nil {
 		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
 		return
 	}
-	// Build the spec
 	input := &pb.ChaincodeInput{}
 	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
 		err = fmt.Errorf("Chaincode argument error: %s", err)
 		return
 	}
 
-	var attributes []string
-	if err = json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
-		err = fmt.Errorf("Chaincode argument error: %s", err)
-		return
-	}
-
 	chaincodeLang = strings.ToUpper(chaincodeLang)
 	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
-		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input, Attributes: attributes}
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input}
 
-	// If security is enabled, add client login token
+	// If security is enabled, add client login token. The package of the new
+	// version is signed with the enrollment key of the user, who must be the
+	// deployer of the chaincode or one of the allowed upgraders.
 	if core.SecurityEnabled() {
 		if chaincodeUsr == undefinedParamValue {
 			err = errors.New("Must supply username for chaincode when security is enabled")
 			return
 		}
+		var token []byte
+		token, err = ioutil.ReadFile(getCliFilePath() + "loginToken_" + chaincodeUsr)
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", chaincodeUsr)
+			return
+		} else if err != nil {
+			panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
+		}
+		spec.SecureContext = string(token)
+	} else if chaincodeUsr != undefinedParamValue {
+		logger.Warning("Username supplied but security is disabled.")
+	}
+
+	chaincodeDeploymentSpec, err := devopsClient.Upgrade(context.Background(), spec)
+	if err != nil {
+		err = fmt.Errorf("Error upgrading %s: %s\n", chainFuncName, err)
+		return
+	}
+	logger.Infof("Upgrade result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
+	return nil
+}
+
+// chaincodePackage builds the deployment package of the chaincode at the path
+// parameter, without a peer, and writes it to the output file. On success,
+// the name (hash) of the chaincode is printed to STDOUT.
+func chaincodePackage(cmd *cobra.Command, args []string) (err error) {
+	if chaincodePath == undefinedParamValue {
+		err = fmt.Errorf("Must supply value for %s path parameter.\n", chainFuncName)
+		return
+	}
+	if err = checkChaincodeCmdParams(cmd); err != nil {
+		return
+	}
+	input := &pb.ChaincodeInput{}
+	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
+		err = fmt.Errorf("Chaincode argument error: %s", err)
+		return
+	}
+
+	chaincodeLang = strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath}, CtorMsg: input}
+	if err = core.CheckSpec(spec); err != nil {
+		err = fmt.Errorf("Error packaging %s: %s", chainFuncName, err)
+		return
+	}
+	codePackage, err := container.GetChaincodePackageBytes(spec)
+	if err != nil {
+		err = fmt.Errorf("Error packaging %s: %s", chainFuncName, err)
+		return
+	}
+	cds := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: codePackage}
+
+	output := chaincodePackageOutput
+	if output == "" {
+		output = spec.ChaincodeID.Name + ".cds"
+	}
+	if err = writeChaincodePackage(output, cds); err != nil {
+		return
+	}
+	logger.Infof("Wrote package of %s %s, hash %s, to %s", chainFuncName, spec.ChaincodeID.Name, chaincode.CodePackageHash(cds), output)
+	fmt.Println(spec.ChaincodeID.Name)
+	return nil
+}
+
+// chaincodeSign signs the chaincode package given as the only parameter with
+// the enrollment key of the username parameter, and writes it to the output
+// file, the package itself by default
+func chaincodeSign(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		err = fmt.Errorf("Must supply the %s package as the only parameter", chainFuncName)
+		return
+	}
+	if chaincodeUsr == undefinedParamValue {
+		err = errors.New("Must supply the username of the signer")
+		return
+	}
+	cds, err := readChaincodePackage(args[0])
+	if err != nil {
+		return
+	}
+
+	sec, err := crypto.InitClient(chaincodeUsr, nil)
+	if err != nil {
+		err = fmt.Errorf("Error initializing the keys of user '%s', who must be registered on this host: %s", chaincodeUsr, err)
+		return
+	}
+	defer crypto.CloseClient(sec)
+	ecertHandler, err := sec.GetEnrollmentCertificateHandler()
+	if err != nil {
+		err = fmt.Errorf("Error getting the enrollment certificate of user '%s': %s", chaincodeUsr, err)
+		return
+	}
+	if err = chaincode.SignCodePackage(ecertHandler, cds); err != nil {
+		return
+	}
+
+	output := chaincodePackageOutput
+	if output == "" {
+		output = args[0]
+	}
+	if err = writeChaincodePackage(output, cds); err != nil {
+		return
+	}
+	logger.Infof("Signed package of %s %s as '%s' to %s", chainFuncName, cds.ChaincodeSpec.ChaincodeID.Name, chaincodeUsr, output)
+	return nil
+}
+
+// chaincodeInstall deploys the chaincode package given as the only parameter.
+// On success, the name (hash) of the chaincode is printed to STDOUT.
+func chaincodeInstall(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 1 {
+		err = fmt.Errorf("Must supply the %s package as the only parameter", chainFuncName)
+		return
+	}
+	cds, err := readChaincodePackage(args[0])
+	if err != nil {
+		return
+	}
+
+	// If security is enabled, add client login token
+	if core.SecurityEnabled() {
+		if chaincodeUsr == undefinedParamValue {
+			err = errors.New("Must supply username for chaincode when security is enabled")
+			return
+		}
+		var token []byte
+		token, err = ioutil.ReadFile(getCliFilePath() + "loginToken_" + chaincodeUsr)
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", chaincodeUsr)
+			return
+		} else if err != nil {
+			panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
+		}
+		cds.ChaincodeSpec.SecureContext = string(token)
+		if viper.GetBool("security.privacy") {
+			logger.Info("Set confidentiality level to CONFIDENTIAL.\n")
+			cds.ChaincodeSpec.ConfidentialityLevel = pb.ConfidentialityLevel_CONFIDENTIAL
+		}
+	} else if chaincodeUsr != undefinedParamValue {
+		logger.Warning("Username supplied but security is disabled.")
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error installing %s: %s", chainFuncName, err)
+		return
+	}
+	chaincodeDeploymentSpec, err := devopsClient.Install(context.Background(), cds)
+	if err != nil {
+		err = fmt.Errorf("Error installing %s: %s\n", chainFuncName, err)
+		return
+	}
+	logger.Infof("Install result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
+	return nil
+}
+
+// chaincodeList prints the deployed chaincodes to STDOUT, in JSON
+func chaincodeList() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = fmt.Errorf("Error trying to connect to local peer: %s", err)
+		return
+	}
+	deployments, err := pb.NewDevopsClient(clientConn).ListChaincodes(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		err = fmt.Errorf("Error listing %ss: %s", chainFuncName, err)
+		return
+	}
+	jsonOutput, _ := json.Marshal(deployments)
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
+func readChaincodePackage(path string) (*pb.ChaincodeDeploymentSpec, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s package: %s", chainFuncName, err)
+	}
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err = proto.Unmarshal(raw, cds); err != nil {
+		return nil, fmt.Errorf("Error reading %s package %s: %s", chainFuncName, path, err)
+	}
+	if cds.ChaincodeSpec == nil || cds.ChaincodeSpec.ChaincodeID == nil {
+		return nil, fmt.Errorf("Error reading %s package %s: not a deployment package", chainFuncName, path)
+	}
+	return cds, nil
+}
+
+func writeChaincodePackage(path string, cds *pb.ChaincodeDeploymentSpec) error {
+	raw, err := proto.Marshal(cds)
+	if err != nil {
+		return fmt.Errorf("Error marshalling %s package: %s", chainFuncName, err)
+	}
+	if err = ioutil.WriteFile(path, raw, 0644); err != nil {
+		return fmt.Errorf("Error writing %s package: %s", chainFuncName, err)
+	}
+	return nil
+}
+
+func chaincodeInvoke(cmd *cobra.Command, args []string) error {
+	return chaincodeInvokeOrQuery(cmd, args, true)
+}
+
+func chaincodeQuery(cmd *cobra.Command, args []string) error {
+	return chaincodeInvokeOrQuery(cmd, args, false)
+}
+
+// chaincodeInvokeOrQuery invokes or queries the chaincode. If successful, the
+// INVOKE form prints the transaction ID on STDOUT, and the QUERY form prints
+// the query result on STDOUT. A command-line flag (-r, --raw) determines
+// whether the query result is output as raw bytes, or as a printable string.
+// The printable form is optionally (-x, --hex) a hexadecimal representation
+// of the query response. If the query response is NIL, nothing is output.
+func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err error) {
+
+	if err = checkChaincodeCmdParams(cmd); err != nil {
+		return
+	}
+
+	if chaincodeName == "" {
+		err = errors.New("Name not given for invoke/query")
+		return
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
+		return
+	}
+	// Build the spec
+	input := &pb.ChaincodeInput{}
+	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
+		err = fmt.Errorf("Chaincode argument error: %s", err)
+		return
+	}
+
+	var attributes []string
+	if err = json.Unmarshal([]byte(chaincodeAttributesJSON), &attributes); err != nil {
+		err = fmt.Errorf("Chaincode argument error: %s", err)
+		return
+	}
+
+	chaincodeLang = strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
+		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input, Attributes: attributes}
+
+	// If security is enabled, add client login token
+	if core.SecurityEnabled() {
+		if chaincodeUsr == undefinedParamValue {
+			err = errors.New("Must supply username for chaincode when security is enabled")
+			return
+		}
+
+		// Retrieve the CLI data storage path
+		// Returns /var/openchain/production/client/
+		localStore := getCliFilePath()
 
-		// Retrieve the CLI data storage path
-		// Returns /var/openchain/production/client/
-		localStore := getCliFilePath()
-
 		// Check if the user is logged in before sending transaction
 		if _, err = os.Stat(localStore + "loginToken_" + chaincodeUsr); err == nil {
 			logger.Infof("Local user '%s' is already logged in. Retrieving login token.\n", chaincodeUsr)
@@ -971,8 +1731,10 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	var resp *pb.Response
 	if invoke {
 		resp, err = devopsClient.Invoke(context.Background(), invocation)
+	} else if chaincodeQueryQuorum {
+		resp, err = devopsClient.QueryQuorum(context.Background(), invocation)
 	} else {
-		resp, err = devopsClient.Query(context.Background(), invocation)
+		resp, err = queryStream(devopsClient, invocation)
 	}
 
 	if err != nil {
@@ -1008,23 +1770,395 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	return nil
 }
 
+// queryStream performs the query through the streaming Devops API and returns
+// the response with the chunks of the result concatenated
+func queryStream(devopsClient pb.DevopsClient, invocation *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	stream, err := devopsClient.QueryStream(context.Background(), invocation)
+	if err != nil {
+		return nil, err
+	}
+	var resp *pb.Response
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			resp = chunk
+		} else {
+			resp.Msg = append(resp.Msg, chunk.Msg...)
+		}
+	}
+	if resp == nil {
+		return nil, errors.New("Received no response to the query")
+	}
+	return resp, nil
+}
+
 // Show a list of all existing network connections for the target peer node,
 // includes both validating and non-validating peers
 func networkList() (err error) {
-	clientConn, err := peer.NewPeerClientConnection()
+	adminClient, err := getAdminClient()
 	if err != nil {
-		err = fmt.Errorf("Error trying to connect to local peer: %s", err)
 		return
 	}
-	openchainClient := pb.NewOpenchainClient(clientConn)
-	peers, err := openchainClient.GetPeers(context.Background(), &google_protobuf.Empty{})
-
+	status, err := adminClient.GetNetworkStatus(context.Background(), &google_protobuf.Empty{})
 	if err != nil {
 		err = fmt.Errorf("Error trying to get peers: %s", err)
 		return
 	}
 
-	jsonOutput, _ := json.Marshal(peers)
+	jsonOutput, _ := json.Marshal(&pb.NetworkStatus{Peers: status.Peers})
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
+// networkStatusOutput is the network status printed by networkStatus, with
+// the consensus metrics inlined
+type networkStatusOutput struct {
+	Self           *pb.PeerEndpoint `json:"self"`
+	BlockHeight    uint64           `json:"blockHeight"`
+	Peers          []*pb.PeerStatus `json:"peers"`
+	Consensus      json.RawMessage  `json:"consensus,omitempty"`
+	ConsensusError string           `json:"consensusError,omitempty"`
+}
+
+// networkStatus prints the network status of the target peer to STDOUT, in
+// JSON.
+func networkStatus() (err error) {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return
+	}
+	status, err := adminClient.GetNetworkStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		err = fmt.Errorf("Error trying to get the network status: %s", err)
+		return
+	}
+
+	output := &networkStatusOutput{Self: status.Self, BlockHeight: status.BlockHeight, Peers: status.Peers, ConsensusError: status.ConsensusError}
+	if status.Consensus != "" {
+		output.Consensus = json.RawMessage(status.Consensus)
+	}
+	jsonOutput, _ := json.Marshal(output)
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
+// networkJoin has the target peer connect to the peer at the address given
+// as the only parameter.
+func networkJoin(args []string) (err error) {
+	if len(args) != 1 {
+		err = errors.New("Must supply the address of the peer as the only parameter")
+		return
+	}
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return
+	}
+	if _, err = adminClient.ConnectPeer(context.Background(), &pb.NetworkPeer{Address: args[0]}); err != nil {
+		err = fmt.Errorf("Error connecting to peer %s: %s", args[0], err)
+		return
+	}
+	logger.Infof("Connecting to peer %s", args[0])
+	return nil
+}
+
+// networkRemove has the target peer disconnect from the peer of the address
+// or ID given as the only parameter.
+func networkRemove(args []string) (err error) {
+	if len(args) != 1 {
+		err = errors.New("Must supply the address or ID of the peer as the only parameter")
+		return
+	}
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return
+	}
+	if _, err = adminClient.DisconnectPeer(context.Background(), &pb.NetworkPeer{Address: args[0]}); err != nil {
+		err = fmt.Errorf("Error disconnecting peer %s: %s", args[0], err)
+		return
+	}
+	logger.Infof("Disconnected peer %s", args[0])
+	return nil
+}
+
+// networkReconfigure submits a reconfiguration of the validator set to N
+// validating peers tolerating f faults, signed by the consensus admin logged
+// in as the username. On success, the UUID of the reconfiguration
+// transaction is printed to STDOUT.
+func networkReconfigure(cmd *cobra.Command, args []string) (err error) {
+	if len(args) != 2 {
+		err = errors.New("Must supply N and f as the only parameters")
+		return
+	}
+	var n, f uint64
+	if n, err = strconv.ParseUint(args[0], 10, 32); err != nil {
+		err = fmt.Errorf("Invalid N %s: %s", args[0], err)
+		return
+	}
+	if f, err = strconv.ParseUint(args[1], 10, 32); err != nil {
+		err = fmt.Errorf("Invalid f %s: %s", args[1], err)
+		return
+	}
+
+	validatorSet := &pb.ValidatorSet{N: uint32(n), F: uint32(f)}
+	if reconfigureUsr == undefinedParamValue {
+		err = errors.New("Must supply the username of the consensus admin")
+		return
+	}
+	var token []byte
+	token, err = ioutil.ReadFile(getCliFilePath() + "loginToken_" + reconfigureUsr)
+	if os.IsNotExist(err) {
+		err = fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", reconfigureUsr)
+		return
+	} else if err != nil {
+		panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
+	}
+	validatorSet.SecureContext = string(token)
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error reconfiguring: %s", err)
+		return
+	}
+	resp, err := devopsClient.Reconfigure(context.Background(), validatorSet)
+	if err != nil {
+		err = fmt.Errorf("Error reconfiguring: %s", err)
+		return
+	}
+	logger.Infof("Reconfiguration transaction ID: %s", resp.Msg)
+	fmt.Println(string(resp.Msg))
+	return nil
+}
+
+// ledgerVerify verifies the blocks of the ledger selected by the start and
+// end block flags, after rebuilding their indexes if repair is true
+func ledgerVerify(repair bool) (err error) {
+	ledgerPtr, err := ledger.GetLedger()
+	if err != nil {
+		err = fmt.Errorf("Error opening ledger: %s", err)
+		return
+	}
+	size := ledgerPtr.GetBlockchainSize()
+	if size == 0 {
+		err = errors.New("The ledger has no blocks")
+		return
+	}
+	endBlock := size - 1
+	if ledgerEndBlock >= 0 {
+		endBlock = uint64(ledgerEndBlock)
+	}
+	if ledgerStartBlock < 0 || uint64(ledgerStartBlock) > endBlock || endBlock >= size {
+		err = fmt.Errorf("Invalid block range [%d, %d], the ledger has %d blocks", ledgerStartBlock, endBlock, size)
+		return
+	}
+	startBlock := uint64(ledgerStartBlock)
+
+	if repair {
+		err = ledgerPtr.RepairIndexes(startBlock, endBlock)
+		if err != nil {
+			err = fmt.Errorf("Error repairing indexes: %s", err)
+			return
+		}
+		logger.Infof("Rebuilt indexes of blocks %d to %d", startBlock, endBlock)
+	}
+
+	report, err := ledgerPtr.VerifyIntegrity(startBlock, endBlock)
+	if err != nil {
+		err = fmt.Errorf("Error verifying ledger: %s", err)
+		return
+	}
+	jsonOutput, _ := json.Marshal(report)
+	fmt.Println(string(jsonOutput))
+	if !report.IsValid() {
+		err = fmt.Errorf("Ledger verification found problems in blocks %d to %d", startBlock, endBlock)
+	}
+	return
+}
+
+func ledgerExport(args []string) (err error) {
+	if len(args) != 1 {
+		err = errors.New("Must supply the file to export to")
+		return
+	}
+	ledgerPtr, err := ledger.GetLedger()
+	if err != nil {
+		err = fmt.Errorf("Error opening ledger: %s", err)
+		return
+	}
+	file, err := os.Create(args[0])
+	if err != nil {
+		err = fmt.Errorf("Error creating export file: %s", err)
+		return
+	}
+	writer := bufio.NewWriter(file)
+	err = ledgerPtr.Export(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		err = fmt.Errorf("Error exporting chain: %s", err)
+		return
+	}
+	logger.Infof("Exported %d blocks to %s", ledgerPtr.GetBlockchainSize(), args[0])
+	return
+}
+
+func ledgerImport(args []string) (err error) {
+	if len(args) != 1 {
+		err = errors.New("Must supply the file to import from")
+		return
+	}
+	ledgerPtr, err := ledger.GetLedger()
+	if err != nil {
+		err = fmt.Errorf("Error opening ledger: %s", err)
+		return
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		err = fmt.Errorf("Error opening import file: %s", err)
+		return
+	}
+	defer file.Close()
+	err = ledgerPtr.Import(file)
+	if err != nil {
+		err = fmt.Errorf("Error importing chain: %s", err)
+		return
+	}
+	logger.Infof("Imported %d blocks from %s", ledgerPtr.GetBlockchainSize(), args[0])
+	return
+}
+
+func ledgerRehash() (err error) {
+	if ledgerNumBuckets <= 0 || ledgerMaxGroupingAtEachLevel <= 0 {
+		err = errors.New("Must supply --numBuckets and --maxGroupingAtEachLevel")
+		return
+	}
+	ledgerPtr, err := ledger.GetLedger()
+	if err != nil {
+		err = fmt.Errorf("Error opening ledger: %s", err)
+		return
+	}
+	scratchDir := filepath.Join(viper.GetString("peer.fileSystemPath"), "rehash")
+	stateHash, err := ledgerPtr.RehashState(ledgerNumBuckets, ledgerMaxGroupingAtEachLevel, scratchDir)
+	if err != nil {
+		return
+	}
+	logger.Infof("Rehashed the state for numBuckets=%d and maxGroupingAtEachLevel=%d, the new state hash is %x. Update ledger.state.dataStructure.configs in core.yaml before restarting the peer.",
+		ledgerNumBuckets, ledgerMaxGroupingAtEachLevel, stateHash)
+	return
+}
+
+func ledgerRotateKey() error {
+	openchainDB := db.GetDBHandle()
+	defer openchainDB.CloseDB()
+	if err := openchainDB.RotateEncryptionKey(); err != nil {
+		return fmt.Errorf("Error rotating the data key: %s", err)
+	}
+	logger.Info("Rotated the data key of the DB")
+	return nil
+}
+
+func configValidate() error {
+	errs := config.Validate(config.Global, config.CoreRules(db.Drivers(), container.VMProviders()))
+	if peer.ValidatorEnabled() && strings.ToLower(viper.GetString("peer.validator.consensus.plugin")) == "pbft" {
+		for _, err := range obcpbft.ValidateConfig(obcpbft.Config()) {
+			errs = append(errs, fmt.Errorf("pbft %s", err))
+		}
+	}
+	if len(errs) == 0 {
+		fmt.Printf("Configuration %s is valid\n", viper.ConfigFileUsed())
+		return nil
+	}
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	return fmt.Errorf("Configuration %s has %d problems", viper.ConfigFileUsed(), len(errs))
+}
+
+func configShow() error {
+	var v config.Source = config.Global
+	if configShowConsensus {
+		v = obcpbft.Config()
+	}
+	out, err := yaml.Marshal(config.Settings(v, configShowEffective))
+	if err != nil {
+		return fmt.Errorf("Error marshaling the configuration: %s", err)
+	}
+	fmt.Print(string(out))
+	return nil
+}
+
+func loggingGet(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Expected at most one module, got %v", args)
+	}
+	module := ""
+	if len(args) == 1 {
+		module = args[0]
+	}
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+	levels, err := adminClient.GetModuleLogLevels(context.Background(), &pb.ModuleLogLevel{Module: module})
+	if err != nil {
+		return fmt.Errorf("Error getting the logging levels: %s", err)
+	}
+	jsonOutput, _ := json.Marshal(levels)
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
+func loggingSet(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Expected a logging specification, e.g. consensus=debug")
+	}
+	// Parse the specification as LoggingInit, a level by itself being the
+	// default level, which is set first
+	var requests []*pb.ModuleLogLevel
+	for _, spec := range args {
+		for _, field := range strings.Split(spec, ":") {
+			split := strings.Split(field, "=")
+			switch len(split) {
+			case 1:
+				requests = append([]*pb.ModuleLogLevel{{Level: field}}, requests...)
+			case 2:
+				if split[0] == "" {
+					return fmt.Errorf("Invalid logging override specification '%s' - no module specified", field)
+				}
+				for _, module := range strings.Split(split[0], ",") {
+					requests = append(requests, &pb.ModuleLogLevel{Module: module, Level: split[1]})
+				}
+			default:
+				return fmt.Errorf("Invalid logging override '%s'; Missing ':' ?", field)
+			}
+		}
+	}
+
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+	result := &pb.ModuleLogLevels{}
+	for _, request := range requests {
+		levels, err := adminClient.SetModuleLogLevel(context.Background(), request)
+		if err != nil {
+			return fmt.Errorf("Error setting the logging level of module '%s': %s", request.Module, err)
+		}
+		if request.Module == "" {
+			result.Modules = append(result.Modules, &pb.ModuleLogLevel{Level: strings.ToUpper(request.Level)})
+		} else {
+			result.Modules = append(result.Modules, levels.Modules...)
+		}
+	}
+	jsonOutput, _ := json.Marshal(result)
 	fmt.Println(string(jsonOutput))
 	return nil
 }
@@ -16,6 +16,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	BlockNumber
+	TxID
 	BlockCount
 	ChaincodeEvent
 	ChaincodeID
@@ -85,6 +86,16 @@ func (m *BlockNumber) Reset()         { *m = BlockNumber{} }
 func (m *BlockNumber) String() string { return proto.CompactTextString(m) }
 func (*BlockNumber) ProtoMessage()    {}
 
+// Specifies the ID of the transaction whose block is to be returned from the
+// blockchain.
+type TxID struct {
+	Uuid string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+}
+
+func (m *TxID) Reset()         { *m = TxID{} }
+func (m *TxID) String() string { return proto.CompactTextString(m) }
+func (*TxID) ProtoMessage()    {}
+
 // Specifies the current number of blocks in the blockchain.
 type BlockCount struct {
 	Count uint64 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
@@ -107,6 +118,9 @@ type OpenchainClient interface {
 	// GetBlockByNumber returns the data contained within a specific block in the
 	// blockchain. The genesis block is block zero.
 	GetBlockByNumber(ctx context.Context, in *BlockNumber, opts ...grpc.CallOption) (*Block, error)
+	// GetBlockByTxID returns the block containing the transaction with the
+	// given ID.
+	GetBlockByTxID(ctx context.Context, in *TxID, opts ...grpc.CallOption) (*Block, error)
 	// GetBlockCount returns the current number of blocks in the blockchain data
 	// structure.
 	GetBlockCount(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*BlockCount, error)
@@ -141,6 +155,15 @@ func (c *openchainClient) GetBlockByNumber(ctx context.Context, in *BlockNumber,
 	return out, nil
 }
 
+func (c *openchainClient) GetBlockByTxID(ctx context.Context, in *TxID, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetBlockByTxID", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *openchainClient) GetBlockCount(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*BlockCount, error) {
 	out := new(BlockCount)
 	err := grpc.Invoke(ctx, "/protos.Openchain/GetBlockCount", in, out, c.cc, opts...)
@@ -168,6 +191,9 @@ type OpenchainServer interface {
 	// GetBlockByNumber returns the data contained within a specific block in the
 	// blockchain. The genesis block is block zero.
 	GetBlockByNumber(context.Context, *BlockNumber) (*Block, error)
+	// GetBlockByTxID returns the block containing the transaction with the
+	// given ID.
+	GetBlockByTxID(context.Context, *TxID) (*Block, error)
 	// GetBlockCount returns the current number of blocks in the blockchain data
 	// structure.
 	GetBlockCount(context.Context, *google_protobuf1.Empty) (*BlockCount, error)
@@ -204,6 +230,18 @@ func _Openchain_GetBlockByNumber_Handler(srv interface{}, ctx context.Context, d
 	return out, nil
 }
 
+func _Openchain_GetBlockByTxID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TxID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetBlockByTxID(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _Openchain_GetBlockCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(google_protobuf1.Empty)
 	if err := dec(in); err != nil {
@@ -240,6 +278,10 @@ var _Openchain_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetBlockByNumber",
 			Handler:    _Openchain_GetBlockByNumber_Handler,
 		},
+		{
+			MethodName: "GetBlockByTxID",
+			Handler:    _Openchain_GetBlockByTxID_Handler,
+		},
 		{
 			MethodName: "GetBlockCount",
 			Handler:    _Openchain_GetBlockCount_Handler,
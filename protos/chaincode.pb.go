@@ -47,6 +47,7 @@ const (
 	ChaincodeSpec_GOLANG    ChaincodeSpec_Type = 1
 	ChaincodeSpec_NODE      ChaincodeSpec_Type = 2
 	ChaincodeSpec_CAR       ChaincodeSpec_Type = 3
+	ChaincodeSpec_JAVA      ChaincodeSpec_Type = 4
 )
 
 var ChaincodeSpec_Type_name = map[int32]string{
@@ -54,12 +55,14 @@ var ChaincodeSpec_Type_name = map[int32]string{
 	1: "GOLANG",
 	2: "NODE",
 	3: "CAR",
+	4: "JAVA",
 }
 var ChaincodeSpec_Type_value = map[string]int32{
 	"UNDEFINED": 0,
 	"GOLANG":    1,
 	"NODE":      2,
 	"CAR":       3,
+	"JAVA":      4,
 }
 
 func (x ChaincodeSpec_Type) String() string {
@@ -109,6 +112,15 @@ const (
 	ChaincodeMessage_RANGE_QUERY_STATE       ChaincodeMessage_Type = 17
 	ChaincodeMessage_RANGE_QUERY_STATE_NEXT  ChaincodeMessage_Type = 18
 	ChaincodeMessage_RANGE_QUERY_STATE_CLOSE ChaincodeMessage_Type = 19
+	ChaincodeMessage_GET_STATE_MULTIPLE_KEYS ChaincodeMessage_Type = 20
+	ChaincodeMessage_PUT_STATE_MULTIPLE_KEYS ChaincodeMessage_Type = 21
+	// A part of a query result larger than chaincode.queryChunkSize, the
+	// result ends with the payload of the QUERY_COMPLETED message
+	ChaincodeMessage_QUERY_COMPLETED_CHUNK  ChaincodeMessage_Type = 22
+	ChaincodeMessage_GET_PRIVATE_STATE      ChaincodeMessage_Type = 23
+	ChaincodeMessage_GET_PRIVATE_STATE_HASH ChaincodeMessage_Type = 24
+	ChaincodeMessage_PUT_PRIVATE_STATE      ChaincodeMessage_Type = 25
+	ChaincodeMessage_DEL_PRIVATE_STATE      ChaincodeMessage_Type = 26
 )
 
 var ChaincodeMessage_Type_name = map[int32]string{
@@ -132,6 +144,13 @@ var ChaincodeMessage_Type_name = map[int32]string{
 	17: "RANGE_QUERY_STATE",
 	18: "RANGE_QUERY_STATE_NEXT",
 	19: "RANGE_QUERY_STATE_CLOSE",
+	20: "GET_STATE_MULTIPLE_KEYS",
+	21: "PUT_STATE_MULTIPLE_KEYS",
+	22: "QUERY_COMPLETED_CHUNK",
+	23: "GET_PRIVATE_STATE",
+	24: "GET_PRIVATE_STATE_HASH",
+	25: "PUT_PRIVATE_STATE",
+	26: "DEL_PRIVATE_STATE",
 }
 var ChaincodeMessage_Type_value = map[string]int32{
 	"UNDEFINED":               0,
@@ -154,6 +173,13 @@ var ChaincodeMessage_Type_value = map[string]int32{
 	"RANGE_QUERY_STATE":       17,
 	"RANGE_QUERY_STATE_NEXT":  18,
 	"RANGE_QUERY_STATE_CLOSE": 19,
+	"GET_STATE_MULTIPLE_KEYS": 20,
+	"PUT_STATE_MULTIPLE_KEYS": 21,
+	"QUERY_COMPLETED_CHUNK":   22,
+	"GET_PRIVATE_STATE":       23,
+	"GET_PRIVATE_STATE_HASH":  24,
+	"PUT_PRIVATE_STATE":       25,
+	"DEL_PRIVATE_STATE":       26,
 }
 
 func (x ChaincodeMessage_Type) String() string {
@@ -200,6 +226,11 @@ type ChaincodeSpec struct {
 	ConfidentialityLevel ConfidentialityLevel `protobuf:"varint,6,opt,name=confidentialityLevel,enum=protos.ConfidentialityLevel" json:"confidentialityLevel,omitempty"`
 	Metadata             []byte               `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
 	Attributes           []string             `protobuf:"bytes,8,rep,name=attributes" json:"attributes,omitempty"`
+	// ledger of the peer the chaincode is executed against, empty for the default ledger
+	LedgerID string `protobuf:"bytes,9,opt,name=ledgerID" json:"ledgerID,omitempty"`
+	// confidentiality protocol version of the confidential transactions of the spec,
+	// empty for the version configured by the client
+	ConfidentialityProtocolVersion string `protobuf:"bytes,10,opt,name=confidentialityProtocolVersion" json:"confidentialityProtocolVersion,omitempty"`
 }
 
 func (m *ChaincodeSpec) Reset()         { *m = ChaincodeSpec{} }
@@ -228,6 +259,14 @@ type ChaincodeDeploymentSpec struct {
 	EffectiveDate *google_protobuf.Timestamp                   `protobuf:"bytes,2,opt,name=effectiveDate" json:"effectiveDate,omitempty"`
 	CodePackage   []byte                                       `protobuf:"bytes,3,opt,name=codePackage,proto3" json:"codePackage,omitempty"`
 	ExecEnv       ChaincodeDeploymentSpec_ExecutionEnvironment `protobuf:"varint,4,opt,name=execEnv,enum=protos.ChaincodeDeploymentSpec_ExecutionEnvironment" json:"execEnv,omitempty"`
+	// name of the deployed chaincode replaced by a CHAINCODE_UPGRADE
+	// transaction, whose state the new chaincode takes over
+	UpgradedChaincodeName string `protobuf:"bytes,5,opt,name=upgradedChaincodeName" json:"upgradedChaincodeName,omitempty"`
+	// DER enrollment certificate of the deployer, whose enrollment key
+	// signed the chaincode package
+	DeployerCert []byte `protobuf:"bytes,6,opt,name=deployerCert,proto3" json:"deployerCert,omitempty"`
+	// signature of the chaincode name and package by the deployer
+	CodeSignature []byte `protobuf:"bytes,7,opt,name=codeSignature,proto3" json:"codeSignature,omitempty"`
 }
 
 func (m *ChaincodeDeploymentSpec) Reset()         { *m = ChaincodeDeploymentSpec{} }
@@ -248,9 +287,34 @@ func (m *ChaincodeDeploymentSpec) GetEffectiveDate() *google_protobuf.Timestamp
 	return nil
 }
 
+// Record of a deployed chaincode, written to the state by its deploy or
+// upgrade transaction.
+type ChaincodeDeployment struct {
+	// name of the chaincode, the hash of its code and constructor
+	Name string             `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Path string             `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+	Type ChaincodeSpec_Type `protobuf:"varint,3,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
+	// 1 for a deployed chaincode, the version of the upgraded chaincode
+	// plus 1 for a chaincode deployed by an upgrade
+	Version               uint64 `protobuf:"varint,4,opt,name=version" json:"version,omitempty"`
+	UpgradedChaincodeName string `protobuf:"bytes,5,opt,name=upgradedChaincodeName" json:"upgradedChaincodeName,omitempty"`
+	// name of the chaincode which upgraded this one, empty for the latest
+	// version. It is not recorded, but looked up when listing.
+	Successor string `protobuf:"bytes,6,opt,name=successor" json:"successor,omitempty"`
+	// hex SHA-256 hash of the chaincode package
+	CodeHash string `protobuf:"bytes,7,opt,name=codeHash" json:"codeHash,omitempty"`
+	// whether the chaincode package was signed by its deployer
+	Signed bool `protobuf:"varint,8,opt,name=signed" json:"signed,omitempty"`
+}
+
+func (m *ChaincodeDeployment) Reset()         { *m = ChaincodeDeployment{} }
+func (m *ChaincodeDeployment) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeDeployment) ProtoMessage()    {}
+
 // Carries the chaincode function and its arguments.
 type ChaincodeInvocationSpec struct {
-	ChaincodeSpec *ChaincodeSpec `protobuf:"bytes,1,opt,name=chaincodeSpec" json:"chaincodeSpec,omitempty"`
+	ChaincodeSpec   *ChaincodeSpec `protobuf:"bytes,1,opt,name=chaincodeSpec" json:"chaincodeSpec,omitempty"`
+	IdGenerationAlg string         `protobuf:"bytes,3,opt,name=idGenerationAlg" json:"idGenerationAlg,omitempty"`
 }
 
 func (m *ChaincodeInvocationSpec) Reset()         { *m = ChaincodeInvocationSpec{} }
@@ -276,6 +340,7 @@ type ChaincodeSecurityContext struct {
 	Metadata       []byte                     `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`
 	ParentMetadata []byte                     `protobuf:"bytes,6,opt,name=parentMetadata,proto3" json:"parentMetadata,omitempty"`
 	TxTimestamp    *google_protobuf.Timestamp `protobuf:"bytes,7,opt,name=txTimestamp" json:"txTimestamp,omitempty"`
+	Signers        [][]byte                   `protobuf:"bytes,8,rep,name=signers,proto3" json:"signers,omitempty"`
 }
 
 func (m *ChaincodeSecurityContext) Reset()         { *m = ChaincodeSecurityContext{} }
@@ -299,6 +364,8 @@ type ChaincodeMessage struct {
 	// This event is then stored (currently)
 	// with Block.NonHashData.TransactionResult
 	ChaincodeEvent *ChaincodeEvent `protobuf:"bytes,6,opt,name=chaincodeEvent" json:"chaincodeEvent,omitempty"`
+	// ledger of the transaction, empty for the default ledger
+	LedgerID string `protobuf:"bytes,7,opt,name=ledgerID" json:"ledgerID,omitempty"`
 }
 
 func (m *ChaincodeMessage) Reset()         { *m = ChaincodeMessage{} }
@@ -335,6 +402,58 @@ func (m *PutStateInfo) Reset()         { *m = PutStateInfo{} }
 func (m *PutStateInfo) String() string { return proto.CompactTextString(m) }
 func (*PutStateInfo) ProtoMessage()    {}
 
+// GetStateMultipleKeys is the payload of ChaincodeMessage.GET_STATE_MULTIPLE_KEYS,
+// which gets the values of the keys in a single round trip
+type GetStateMultipleKeys struct {
+	Keys []string `protobuf:"bytes,1,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *GetStateMultipleKeys) Reset()         { *m = GetStateMultipleKeys{} }
+func (m *GetStateMultipleKeys) String() string { return proto.CompactTextString(m) }
+func (*GetStateMultipleKeys) ProtoMessage()    {}
+
+// GetStateMultipleKeysResponse is the payload of the response to a
+// GET_STATE_MULTIPLE_KEYS, with the values in the order of the keys. The value
+// of a key which does not exist is empty.
+type GetStateMultipleKeysResponse struct {
+	Values [][]byte `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+}
+
+func (m *GetStateMultipleKeysResponse) Reset()         { *m = GetStateMultipleKeysResponse{} }
+func (m *GetStateMultipleKeysResponse) String() string { return proto.CompactTextString(m) }
+func (*GetStateMultipleKeysResponse) ProtoMessage()    {}
+
+// PutStateMultipleKeys is the payload of ChaincodeMessage.PUT_STATE_MULTIPLE_KEYS,
+// which writes the key-values in a single round trip
+type PutStateMultipleKeys struct {
+	KeyValues []*PutStateInfo `protobuf:"bytes,1,rep,name=keyValues" json:"keyValues,omitempty"`
+}
+
+func (m *PutStateMultipleKeys) Reset()         { *m = PutStateMultipleKeys{} }
+func (m *PutStateMultipleKeys) String() string { return proto.CompactTextString(m) }
+func (*PutStateMultipleKeys) ProtoMessage()    {}
+
+func (m *PutStateMultipleKeys) GetKeyValues() []*PutStateInfo {
+	if m != nil {
+		return m.KeyValues
+	}
+	return nil
+}
+
+// PrivateStateInfo is the payload of ChaincodeMessage.GET_PRIVATE_STATE,
+// GET_PRIVATE_STATE_HASH, PUT_PRIVATE_STATE and DEL_PRIVATE_STATE, which access
+// a key of a private state collection of the chaincode. The value is only set
+// by PUT_PRIVATE_STATE.
+type PrivateStateInfo struct {
+	Collection string `protobuf:"bytes,1,opt,name=collection" json:"collection,omitempty"`
+	Key        string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Value      []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *PrivateStateInfo) Reset()         { *m = PrivateStateInfo{} }
+func (m *PrivateStateInfo) String() string { return proto.CompactTextString(m) }
+func (*PrivateStateInfo) ProtoMessage()    {}
+
 type RangeQueryState struct {
 	StartKey string `protobuf:"bytes,1,opt,name=startKey" json:"startKey,omitempty"`
 	EndKey   string `protobuf:"bytes,2,opt,name=endKey" json:"endKey,omitempty"`
@@ -7,6 +7,7 @@ package protos
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf1 "google/protobuf"
 
 import (
 	context "golang.org/x/net/context"
@@ -69,6 +70,54 @@ func (m *BuildResult) GetDeploymentSpec() *ChaincodeDeploymentSpec {
 	return nil
 }
 
+// ChaincodeInvocationBatch is a batch of chaincode invocations
+type ChaincodeInvocationBatch struct {
+	Invocations []*ChaincodeInvocationSpec `protobuf:"bytes,1,rep,name=invocations" json:"invocations,omitempty"`
+}
+
+func (m *ChaincodeInvocationBatch) Reset()         { *m = ChaincodeInvocationBatch{} }
+func (m *ChaincodeInvocationBatch) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeInvocationBatch) ProtoMessage()    {}
+
+func (m *ChaincodeInvocationBatch) GetInvocations() []*ChaincodeInvocationSpec {
+	if m != nil {
+		return m.Invocations
+	}
+	return nil
+}
+
+// BatchResponse holds a response for each request of a batch, in order
+type BatchResponse struct {
+	Responses []*Response `protobuf:"bytes,1,rep,name=responses" json:"responses,omitempty"`
+}
+
+func (m *BatchResponse) Reset()         { *m = BatchResponse{} }
+func (m *BatchResponse) String() string { return proto.CompactTextString(m) }
+func (*BatchResponse) ProtoMessage()    {}
+
+func (m *BatchResponse) GetResponses() []*Response {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+// ChaincodeDeployments lists deployed chaincodes
+type ChaincodeDeployments struct {
+	Chaincodes []*ChaincodeDeployment `protobuf:"bytes,1,rep,name=chaincodes" json:"chaincodes,omitempty"`
+}
+
+func (m *ChaincodeDeployments) Reset()         { *m = ChaincodeDeployments{} }
+func (m *ChaincodeDeployments) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeDeployments) ProtoMessage()    {}
+
+func (m *ChaincodeDeployments) GetChaincodes() []*ChaincodeDeployment {
+	if m != nil {
+		return m.Chaincodes
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("protos.BuildResult_StatusCode", BuildResult_StatusCode_name, BuildResult_StatusCode_value)
 }
@@ -87,10 +136,40 @@ type DevopsClient interface {
 	Build(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
 	// Deploy the chaincode package to the chain.
 	Deploy(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
+	// Upgrade the deployed chaincode named by the chaincodeID of the spec to
+	// the chaincode package of the spec.
+	Upgrade(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
+	// Deploy a chaincode package built beforehand, as by Build, and
+	// possibly signed by its deployer.
+	Install(ctx context.Context, in *ChaincodeDeploymentSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
+	// List the chaincodes deployed to the chain, with their versions.
+	ListChaincodes(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ChaincodeDeployments, error)
 	// Invoke chaincode.
 	Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
 	// Invoke chaincode.
 	Query(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
+	// Query chaincode, streaming the result in chunks of at most
+	// chaincode.queryChunkSize bytes. The result is the concatenation of the
+	// messages of the stream.
+	QueryStream(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (Devops_QueryStreamClient, error)
+	// Query chaincode on this peer and on the other validating peers, and
+	// return the result once f+1 of them returned the same, f being the
+	// number of faulty validating peers tolerated.
+	QueryQuorum(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
+	// Change the validating peers of the consensus quorum with a
+	// CONSENSUS_RECONFIGURE transaction, signed by the admin named by the
+	// secureContext.
+	Reconfigure(ctx context.Context, in *ValidatorSet, opts ...grpc.CallOption) (*Response, error)
+	// Replace the certificate revocation list of the ECA with a
+	// PKI_CRL_UPDATE transaction.
+	UpdateRevocationList(ctx context.Context, in *RevocationList, opts ...grpc.CallOption) (*Response, error)
+	// Submit a transaction built and signed by the client, with its own
+	// keys, to consensus.
+	Submit(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Response, error)
+	// Invoke chaincode with each invocation of the batch, sending the
+	// transactions to consensus together. The responses are in the order of
+	// the invocations, the msg of a successful one being the transaction UUID.
+	InvokeBatch(ctx context.Context, in *ChaincodeInvocationBatch, opts ...grpc.CallOption) (*BatchResponse, error)
 }
 
 type devopsClient struct {
@@ -128,6 +207,33 @@ func (c *devopsClient) Deploy(ctx context.Context, in *ChaincodeSpec, opts ...gr
 	return out, nil
 }
 
+func (c *devopsClient) Upgrade(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error) {
+	out := new(ChaincodeDeploymentSpec)
+	err := grpc.Invoke(ctx, "/protos.Devops/Upgrade", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) Install(ctx context.Context, in *ChaincodeDeploymentSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error) {
+	out := new(ChaincodeDeploymentSpec)
+	err := grpc.Invoke(ctx, "/protos.Devops/Install", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) ListChaincodes(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ChaincodeDeployments, error) {
+	out := new(ChaincodeDeployments)
+	err := grpc.Invoke(ctx, "/protos.Devops/ListChaincodes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *devopsClient) Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
 	err := grpc.Invoke(ctx, "/protos.Devops/Invoke", in, out, c.cc, opts...)
@@ -146,6 +252,83 @@ func (c *devopsClient) Query(ctx context.Context, in *ChaincodeInvocationSpec, o
 	return out, nil
 }
 
+func (c *devopsClient) QueryStream(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (Devops_QueryStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Devops_serviceDesc.Streams[0], c.cc, "/protos.Devops/QueryStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &devopsQueryStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Devops_QueryStreamClient interface {
+	Recv() (*Response, error)
+	grpc.ClientStream
+}
+
+type devopsQueryStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *devopsQueryStreamClient) Recv() (*Response, error) {
+	m := new(Response)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *devopsClient) QueryQuorum(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/QueryQuorum", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) Reconfigure(ctx context.Context, in *ValidatorSet, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/Reconfigure", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) UpdateRevocationList(ctx context.Context, in *RevocationList, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/UpdateRevocationList", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) Submit(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/Submit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) InvokeBatch(ctx context.Context, in *ChaincodeInvocationBatch, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := grpc.Invoke(ctx, "/protos.Devops/InvokeBatch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Devops service
 
 type DevopsServer interface {
@@ -156,10 +339,40 @@ type DevopsServer interface {
 	Build(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
 	// Deploy the chaincode package to the chain.
 	Deploy(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
+	// Upgrade the deployed chaincode named by the chaincodeID of the spec to
+	// the chaincode package of the spec.
+	Upgrade(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
+	// Deploy a chaincode package built beforehand, as by Build, and
+	// possibly signed by its deployer.
+	Install(context.Context, *ChaincodeDeploymentSpec) (*ChaincodeDeploymentSpec, error)
+	// List the chaincodes deployed to the chain, with their versions.
+	ListChaincodes(context.Context, *google_protobuf1.Empty) (*ChaincodeDeployments, error)
 	// Invoke chaincode.
 	Invoke(context.Context, *ChaincodeInvocationSpec) (*Response, error)
 	// Invoke chaincode.
 	Query(context.Context, *ChaincodeInvocationSpec) (*Response, error)
+	// Query chaincode, streaming the result in chunks of at most
+	// chaincode.queryChunkSize bytes. The result is the concatenation of the
+	// messages of the stream.
+	QueryStream(*ChaincodeInvocationSpec, Devops_QueryStreamServer) error
+	// Query chaincode on this peer and on the other validating peers, and
+	// return the result once f+1 of them returned the same, f being the
+	// number of faulty validating peers tolerated.
+	QueryQuorum(context.Context, *ChaincodeInvocationSpec) (*Response, error)
+	// Change the validating peers of the consensus quorum with a
+	// CONSENSUS_RECONFIGURE transaction, signed by the admin named by the
+	// secureContext.
+	Reconfigure(context.Context, *ValidatorSet) (*Response, error)
+	// Replace the certificate revocation list of the ECA with a
+	// PKI_CRL_UPDATE transaction.
+	UpdateRevocationList(context.Context, *RevocationList) (*Response, error)
+	// Submit a transaction built and signed by the client, with its own
+	// keys, to consensus.
+	Submit(context.Context, *Transaction) (*Response, error)
+	// Invoke chaincode with each invocation of the batch, sending the
+	// transactions to consensus together. The responses are in the order of
+	// the invocations, the msg of a successful one being the transaction UUID.
+	InvokeBatch(context.Context, *ChaincodeInvocationBatch) (*BatchResponse, error)
 }
 
 func RegisterDevopsServer(s *grpc.Server, srv DevopsServer) {
@@ -202,6 +415,42 @@ func _Devops_Deploy_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return out, nil
 }
 
+func _Devops_Upgrade_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Upgrade(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_Install_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeDeploymentSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Install(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_ListChaincodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).ListChaincodes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _Devops_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(ChaincodeInvocationSpec)
 	if err := dec(in); err != nil {
@@ -226,6 +475,87 @@ func _Devops_Query_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return out, nil
 }
 
+func _Devops_QueryStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChaincodeInvocationSpec)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DevopsServer).QueryStream(m, &devopsQueryStreamServer{stream})
+}
+
+type Devops_QueryStreamServer interface {
+	Send(*Response) error
+	grpc.ServerStream
+}
+
+type devopsQueryStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *devopsQueryStreamServer) Send(m *Response) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Devops_QueryQuorum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeInvocationSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).QueryQuorum(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_Reconfigure_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ValidatorSet)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Reconfigure(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_UpdateRevocationList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(RevocationList)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).UpdateRevocationList(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_Submit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Submit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_InvokeBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeInvocationBatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).InvokeBatch(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Devops_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Devops",
 	HandlerType: (*DevopsServer)(nil),
@@ -242,6 +572,18 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Deploy",
 			Handler:    _Devops_Deploy_Handler,
 		},
+		{
+			MethodName: "Upgrade",
+			Handler:    _Devops_Upgrade_Handler,
+		},
+		{
+			MethodName: "Install",
+			Handler:    _Devops_Install_Handler,
+		},
+		{
+			MethodName: "ListChaincodes",
+			Handler:    _Devops_ListChaincodes_Handler,
+		},
 		{
 			MethodName: "Invoke",
 			Handler:    _Devops_Invoke_Handler,
@@ -250,6 +592,32 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Query",
 			Handler:    _Devops_Query_Handler,
 		},
+		{
+			MethodName: "QueryQuorum",
+			Handler:    _Devops_QueryQuorum_Handler,
+		},
+		{
+			MethodName: "Reconfigure",
+			Handler:    _Devops_Reconfigure_Handler,
+		},
+		{
+			MethodName: "UpdateRevocationList",
+			Handler:    _Devops_UpdateRevocationList_Handler,
+		},
+		{
+			MethodName: "Submit",
+			Handler:    _Devops_Submit_Handler,
+		},
+		{
+			MethodName: "InvokeBatch",
+			Handler:    _Devops_InvokeBatch_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryStream",
+			Handler:       _Devops_QueryStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
@@ -7,6 +7,7 @@ package protos
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "google/protobuf"
 
 import (
 	context "golang.org/x/net/context"
@@ -21,26 +22,58 @@ var _ = math.Inf
 type EventType int32
 
 const (
-	EventType_REGISTER  EventType = 0
-	EventType_BLOCK     EventType = 1
-	EventType_CHAINCODE EventType = 2
+	EventType_REGISTER    EventType = 0
+	EventType_BLOCK       EventType = 1
+	EventType_CHAINCODE   EventType = 2
+	EventType_DIVERGENCE  EventType = 3
+	EventType_MISBEHAVIOR EventType = 4
+	EventType_REJECTION   EventType = 5
 )
 
 var EventType_name = map[int32]string{
 	0: "REGISTER",
 	1: "BLOCK",
 	2: "CHAINCODE",
+	3: "DIVERGENCE",
+	4: "MISBEHAVIOR",
+	5: "REJECTION",
 }
 var EventType_value = map[string]int32{
-	"REGISTER":  0,
-	"BLOCK":     1,
-	"CHAINCODE": 2,
+	"REGISTER":    0,
+	"BLOCK":       1,
+	"CHAINCODE":   2,
+	"DIVERGENCE":  3,
+	"MISBEHAVIOR": 4,
+	"REJECTION":   5,
 }
 
 func (x EventType) String() string {
 	return proto.EnumName(EventType_name, int32(x))
 }
 
+type Evidence_Type int32
+
+const (
+	Evidence_CONFLICTING_PRE_PREPARE Evidence_Type = 0
+	Evidence_CONFLICTING_PREPARE     Evidence_Type = 1
+	Evidence_CONFLICTING_COMMIT      Evidence_Type = 2
+)
+
+var Evidence_Type_name = map[int32]string{
+	0: "CONFLICTING_PRE_PREPARE",
+	1: "CONFLICTING_PREPARE",
+	2: "CONFLICTING_COMMIT",
+}
+var Evidence_Type_value = map[string]int32{
+	"CONFLICTING_PRE_PREPARE": 0,
+	"CONFLICTING_PREPARE":     1,
+	"CONFLICTING_COMMIT":      2,
+}
+
+func (x Evidence_Type) String() string {
+	return proto.EnumName(Evidence_Type_name, int32(x))
+}
+
 // ChaincodeReg is used for registering chaincode Interests
 // when EventType is CHAINCODE
 type ChaincodeReg struct {
@@ -62,6 +95,10 @@ type Interest struct {
 	// Types that are valid to be assigned to RegInfo:
 	//	*Interest_ChaincodeRegInfo
 	RegInfo isInterest_RegInfo `protobuf_oneof:"RegInfo"`
+	// filter expression selecting the events of a CHAINCODE interest, such as
+	// chaincodeID == mycc && eventName matches "transfer.*", which the events
+	// must match in addition to chaincodeRegInfo if set
+	Filter string `protobuf:"bytes,3,opt,name=filter" json:"filter,omitempty"`
 }
 
 func (m *Interest) Reset()         { *m = Interest{} }
@@ -134,8 +171,17 @@ func _Interest_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffe
 // ---------- consumer events ---------
 // Register is sent by consumers for registering events
 // string type - "register"
+// A consumer may ask for the events of the blocks already committed to be
+// replayed before the live ones, from fromBlock if replay is set, or else from
+// the last block whose events were delivered to the durable subscription
+// subscriptionID, if the peer knows it. Registering a subscriptionID makes the
+// peer remember the last block delivered to it, so that it resumes there on the
+// next registration, e.g. after the consumer reconnects.
 type Register struct {
-	Events []*Interest `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+	Events         []*Interest `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+	Replay         bool        `protobuf:"varint,2,opt,name=replay" json:"replay,omitempty"`
+	FromBlock      uint64      `protobuf:"varint,3,opt,name=fromBlock" json:"fromBlock,omitempty"`
+	SubscriptionID string      `protobuf:"bytes,4,opt,name=subscriptionID" json:"subscriptionID,omitempty"`
 }
 
 func (m *Register) Reset()         { *m = Register{} }
@@ -149,14 +195,109 @@ func (m *Register) GetEvents() []*Interest {
 	return nil
 }
 
+// Rejection is sent for a transaction which failed, either in a committed
+// block or before being committed, with the error it failed with and the phase
+// and reason of the failure. tx is not set if the transaction is not known
+type Rejection struct {
+	Tx       *Transaction    `protobuf:"bytes,1,opt,name=tx" json:"tx,omitempty"`
+	ErrorMsg string          `protobuf:"bytes,2,opt,name=errorMsg" json:"errorMsg,omitempty"`
+	TxID     string          `protobuf:"bytes,3,opt,name=txID" json:"txID,omitempty"`
+	Phase    RejectionPhase  `protobuf:"varint,4,opt,name=phase,enum=protos.RejectionPhase" json:"phase,omitempty"`
+	Reason   RejectionReason `protobuf:"varint,5,opt,name=reason,enum=protos.RejectionReason" json:"reason,omitempty"`
+}
+
+func (m *Rejection) Reset()         { *m = Rejection{} }
+func (m *Rejection) String() string { return proto.CompactTextString(m) }
+func (*Rejection) ProtoMessage()    {}
+
+func (m *Rejection) GetTx() *Transaction {
+	if m != nil {
+		return m.Tx
+	}
+	return nil
+}
+
+// Divergence is sent when the changes made by the transactions of a committed
+// block differ from those made by another validating peer, which happens when
+// chaincode is not deterministic
+type Divergence struct {
+	BlockNumber uint64 `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	// name of the validating peer whose results differ
+	PeerID          string          `protobuf:"bytes,2,opt,name=peerID" json:"peerID,omitempty"`
+	LocalStateHash  []byte          `protobuf:"bytes,3,opt,name=localStateHash,proto3" json:"localStateHash,omitempty"`
+	RemoteStateHash []byte          `protobuf:"bytes,4,opt,name=remoteStateHash,proto3" json:"remoteStateHash,omitempty"`
+	Transactions    []*TxDivergence `protobuf:"bytes,5,rep,name=transactions" json:"transactions,omitempty"`
+}
+
+func (m *Divergence) Reset()         { *m = Divergence{} }
+func (m *Divergence) String() string { return proto.CompactTextString(m) }
+func (*Divergence) ProtoMessage()    {}
+
+func (m *Divergence) GetTransactions() []*TxDivergence {
+	if m != nil {
+		return m.Transactions
+	}
+	return nil
+}
+
+// TxDivergence is a transaction whose changes differ. The write sets are the
+// serialized state changes made by the transaction on this and on the other
+// peer, empty if the transaction failed.
+type TxDivergence struct {
+	Uuid           string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	LocalFailed    bool   `protobuf:"varint,2,opt,name=localFailed" json:"localFailed,omitempty"`
+	LocalWriteSet  []byte `protobuf:"bytes,3,opt,name=localWriteSet,proto3" json:"localWriteSet,omitempty"`
+	RemoteFailed   bool   `protobuf:"varint,4,opt,name=remoteFailed" json:"remoteFailed,omitempty"`
+	RemoteWriteSet []byte `protobuf:"bytes,5,opt,name=remoteWriteSet,proto3" json:"remoteWriteSet,omitempty"`
+}
+
+func (m *TxDivergence) Reset()         { *m = TxDivergence{} }
+func (m *TxDivergence) String() string { return proto.CompactTextString(m) }
+func (*TxDivergence) ProtoMessage()    {}
+
+// Evidence is sent when a validating peer detects a replica of the consensus
+// sending conflicting messages for the same view and sequence number. The
+// consensus messages are not signed by their sender, the evidence is signed by
+// the reporting replica.
+type Evidence struct {
+	Type Evidence_Type `protobuf:"varint,1,opt,name=type,enum=protos.Evidence_Type" json:"type,omitempty"`
+	// replica which sent the conflicting messages
+	ReplicaID      uint64 `protobuf:"varint,2,opt,name=replicaID" json:"replicaID,omitempty"`
+	View           uint64 `protobuf:"varint,3,opt,name=view" json:"view,omitempty"`
+	SequenceNumber uint64 `protobuf:"varint,4,opt,name=sequenceNumber" json:"sequenceNumber,omitempty"`
+	// the conflicting consensus messages, serialized
+	First      []byte                     `protobuf:"bytes,5,opt,name=first,proto3" json:"first,omitempty"`
+	Second     []byte                     `protobuf:"bytes,6,opt,name=second,proto3" json:"second,omitempty"`
+	Timestamp  *google_protobuf.Timestamp `protobuf:"bytes,7,opt,name=timestamp" json:"timestamp,omitempty"`
+	ReporterID uint64                     `protobuf:"varint,8,opt,name=reporterID" json:"reporterID,omitempty"`
+	// signature of the reporter over the evidence without signature
+	Signature []byte `protobuf:"bytes,9,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *Evidence) Reset()         { *m = Evidence{} }
+func (m *Evidence) String() string { return proto.CompactTextString(m) }
+func (*Evidence) ProtoMessage()    {}
+
+func (m *Evidence) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
 // Event is used by
 //  - consumers (adapters) to send Register
 //  - producer to advertise supported types and events
 type Event struct {
+	// number of the block the block, chaincode and rejection events come from
+	BlockNumber uint64 `protobuf:"varint,7,opt,name=blockNumber" json:"blockNumber,omitempty"`
 	// Types that are valid to be assigned to Event:
 	//	*Event_Register
 	//	*Event_Block
 	//	*Event_ChaincodeEvent
+	//	*Event_Divergence
+	//	*Event_Evidence
+	//	*Event_Rejection
 	Event isEvent_Event `protobuf_oneof:"Event"`
 }
 
@@ -177,10 +318,22 @@ type Event_Block struct {
 type Event_ChaincodeEvent struct {
 	ChaincodeEvent *ChaincodeEvent `protobuf:"bytes,3,opt,name=chaincodeEvent,oneof"`
 }
+type Event_Divergence struct {
+	Divergence *Divergence `protobuf:"bytes,4,opt,name=divergence,oneof"`
+}
+type Event_Evidence struct {
+	Evidence *Evidence `protobuf:"bytes,5,opt,name=evidence,oneof"`
+}
+type Event_Rejection struct {
+	Rejection *Rejection `protobuf:"bytes,6,opt,name=rejection,oneof"`
+}
 
 func (*Event_Register) isEvent_Event()       {}
 func (*Event_Block) isEvent_Event()          {}
 func (*Event_ChaincodeEvent) isEvent_Event() {}
+func (*Event_Divergence) isEvent_Event()     {}
+func (*Event_Evidence) isEvent_Event()       {}
+func (*Event_Rejection) isEvent_Event()      {}
 
 func (m *Event) GetEvent() isEvent_Event {
 	if m != nil {
@@ -210,12 +363,36 @@ func (m *Event) GetChaincodeEvent() *ChaincodeEvent {
 	return nil
 }
 
+func (m *Event) GetDivergence() *Divergence {
+	if x, ok := m.GetEvent().(*Event_Divergence); ok {
+		return x.Divergence
+	}
+	return nil
+}
+
+func (m *Event) GetEvidence() *Evidence {
+	if x, ok := m.GetEvent().(*Event_Evidence); ok {
+		return x.Evidence
+	}
+	return nil
+}
+
+func (m *Event) GetRejection() *Rejection {
+	if x, ok := m.GetEvent().(*Event_Rejection); ok {
+		return x.Rejection
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Event) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), []interface{}) {
 	return _Event_OneofMarshaler, _Event_OneofUnmarshaler, []interface{}{
 		(*Event_Register)(nil),
 		(*Event_Block)(nil),
 		(*Event_ChaincodeEvent)(nil),
+		(*Event_Divergence)(nil),
+		(*Event_Evidence)(nil),
+		(*Event_Rejection)(nil),
 	}
 }
 
@@ -238,6 +415,21 @@ func _Event_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.ChaincodeEvent); err != nil {
 			return err
 		}
+	case *Event_Divergence:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Divergence); err != nil {
+			return err
+		}
+	case *Event_Evidence:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Evidence); err != nil {
+			return err
+		}
+	case *Event_Rejection:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Rejection); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Event.Event has unexpected type %T", x)
@@ -272,6 +464,30 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 		err := b.DecodeMessage(msg)
 		m.Event = &Event_ChaincodeEvent{msg}
 		return true, err
+	case 4: // Event.divergence
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Divergence)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_Divergence{msg}
+		return true, err
+	case 5: // Event.evidence
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Evidence)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_Evidence{msg}
+		return true, err
+	case 6: // Event.rejection
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Rejection)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_Rejection{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -279,6 +495,7 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 
 func init() {
 	proto.RegisterEnum("protos.EventType", EventType_name, EventType_value)
+	proto.RegisterEnum("protos.Evidence_Type", Evidence_Type_name, Evidence_Type_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -19,6 +19,96 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+// RejectionPhase is the phase of the processing of a transaction in which it
+// was rejected
+type RejectionPhase int32
+
+const (
+	RejectionPhase_UNDEFINED_PHASE RejectionPhase = 0
+	// building the image of a deployed chaincode
+	RejectionPhase_VM_BUILD RejectionPhase = 1
+	// executing the transaction, including launching its chaincode
+	RejectionPhase_EXECUTION RejectionPhase = 2
+	// ordering the transaction with the other validating peers
+	RejectionPhase_CONSENSUS RejectionPhase = 3
+	// committing the block of the transaction to the ledger
+	RejectionPhase_COMMIT RejectionPhase = 4
+)
+
+var RejectionPhase_name = map[int32]string{
+	0: "UNDEFINED_PHASE",
+	1: "VM_BUILD",
+	2: "EXECUTION",
+	3: "CONSENSUS",
+	4: "COMMIT",
+}
+var RejectionPhase_value = map[string]int32{
+	"UNDEFINED_PHASE": 0,
+	"VM_BUILD":        1,
+	"EXECUTION":       2,
+	"CONSENSUS":       3,
+	"COMMIT":          4,
+}
+
+func (x RejectionPhase) String() string {
+	return proto.EnumName(RejectionPhase_name, int32(x))
+}
+
+// RejectionReason is the reason a transaction was rejected
+type RejectionReason int32
+
+const (
+	RejectionReason_UNDEFINED_REASON RejectionReason = 0
+	// the transaction is malformed, cannot be decrypted or is not for the
+	// default ledger
+	RejectionReason_INVALID_TRANSACTION RejectionReason = 1
+	// the signature of the code package of a deployment did not verify
+	RejectionReason_INVALID_CODE_PACKAGE RejectionReason = 2
+	// the chaincode image could not be built
+	RejectionReason_BUILD_FAILED RejectionReason = 3
+	// the chaincode container could not be started or did not register
+	RejectionReason_LAUNCH_FAILED RejectionReason = 4
+	// the chaincode returned an error
+	RejectionReason_CHAINCODE_ERROR RejectionReason = 5
+	// the chaincode did not complete in time
+	RejectionReason_TIMEOUT RejectionReason = 6
+	// a chaincode invoked by the chaincode of the transaction failed
+	RejectionReason_NESTED_FAILURE RejectionReason = 7
+	// the consensus plugin did not accept the transaction
+	RejectionReason_CONSENSUS_REJECTED RejectionReason = 8
+	// the block of the transaction could not be committed
+	RejectionReason_COMMIT_FAILED RejectionReason = 9
+)
+
+var RejectionReason_name = map[int32]string{
+	0: "UNDEFINED_REASON",
+	1: "INVALID_TRANSACTION",
+	2: "INVALID_CODE_PACKAGE",
+	3: "BUILD_FAILED",
+	4: "LAUNCH_FAILED",
+	5: "CHAINCODE_ERROR",
+	6: "TIMEOUT",
+	7: "NESTED_FAILURE",
+	8: "CONSENSUS_REJECTED",
+	9: "COMMIT_FAILED",
+}
+var RejectionReason_value = map[string]int32{
+	"UNDEFINED_REASON":     0,
+	"INVALID_TRANSACTION":  1,
+	"INVALID_CODE_PACKAGE": 2,
+	"BUILD_FAILED":         3,
+	"LAUNCH_FAILED":        4,
+	"CHAINCODE_ERROR":      5,
+	"TIMEOUT":              6,
+	"NESTED_FAILURE":       7,
+	"CONSENSUS_REJECTED":   8,
+	"COMMIT_FAILED":        9,
+}
+
+func (x RejectionReason) String() string {
+	return proto.EnumName(RejectionReason_name, int32(x))
+}
+
 type Transaction_Type int32
 
 const (
@@ -31,6 +121,15 @@ const (
 	Transaction_CHAINCODE_QUERY Transaction_Type = 3
 	// terminate a chaincode; not implemented yet
 	Transaction_CHAINCODE_TERMINATE Transaction_Type = 4
+	// deploy a new version of a chaincode, which takes over the state of
+	// the chaincode, and call its `Init` function
+	Transaction_CHAINCODE_UPGRADE Transaction_Type = 5
+	// change the validating peers of the consensus quorum to the
+	// ValidatorSet of the payload; no chaincode is executed
+	Transaction_CONSENSUS_RECONFIGURE Transaction_Type = 6
+	// replace the certificate revocation list of the ECA with the
+	// RevocationList of the payload; no chaincode is executed
+	Transaction_PKI_CRL_UPDATE Transaction_Type = 7
 )
 
 var Transaction_Type_name = map[int32]string{
@@ -39,13 +138,19 @@ var Transaction_Type_name = map[int32]string{
 	2: "CHAINCODE_INVOKE",
 	3: "CHAINCODE_QUERY",
 	4: "CHAINCODE_TERMINATE",
+	5: "CHAINCODE_UPGRADE",
+	6: "CONSENSUS_RECONFIGURE",
+	7: "PKI_CRL_UPDATE",
 }
 var Transaction_Type_value = map[string]int32{
-	"UNDEFINED":           0,
-	"CHAINCODE_DEPLOY":    1,
-	"CHAINCODE_INVOKE":    2,
-	"CHAINCODE_QUERY":     3,
-	"CHAINCODE_TERMINATE": 4,
+	"UNDEFINED":             0,
+	"CHAINCODE_DEPLOY":      1,
+	"CHAINCODE_INVOKE":      2,
+	"CHAINCODE_QUERY":       3,
+	"CHAINCODE_TERMINATE":   4,
+	"CHAINCODE_UPGRADE":     5,
+	"CONSENSUS_RECONFIGURE": 6,
+	"PKI_CRL_UPDATE":        7,
 }
 
 func (x Transaction_Type) String() string {
@@ -85,6 +190,10 @@ const (
 	Message_DISC_PEERS              Message_Type = 4
 	Message_DISC_NEWMSG             Message_Type = 5
 	Message_CHAIN_TRANSACTION       Message_Type = 6
+	Message_DISC_PAUSE              Message_Type = 7
+	Message_DISC_RESUME             Message_Type = 8
+	Message_DISC_PING               Message_Type = 9
+	Message_DISC_PONG               Message_Type = 10
 	Message_SYNC_GET_BLOCKS         Message_Type = 11
 	Message_SYNC_BLOCKS             Message_Type = 12
 	Message_SYNC_BLOCK_ADDED        Message_Type = 13
@@ -92,8 +201,10 @@ const (
 	Message_SYNC_STATE_SNAPSHOT     Message_Type = 15
 	Message_SYNC_STATE_GET_DELTAS   Message_Type = 16
 	Message_SYNC_STATE_DELTAS       Message_Type = 17
+	Message_SYNC_GOSSIP_DIGEST      Message_Type = 18
 	Message_RESPONSE                Message_Type = 20
 	Message_CONSENSUS               Message_Type = 21
+	Message_EXECUTION_RESULT        Message_Type = 22
 )
 
 var Message_Type_name = map[int32]string{
@@ -104,6 +215,10 @@ var Message_Type_name = map[int32]string{
 	4:  "DISC_PEERS",
 	5:  "DISC_NEWMSG",
 	6:  "CHAIN_TRANSACTION",
+	7:  "DISC_PAUSE",
+	8:  "DISC_RESUME",
+	9:  "DISC_PING",
+	10: "DISC_PONG",
 	11: "SYNC_GET_BLOCKS",
 	12: "SYNC_BLOCKS",
 	13: "SYNC_BLOCK_ADDED",
@@ -111,8 +226,10 @@ var Message_Type_name = map[int32]string{
 	15: "SYNC_STATE_SNAPSHOT",
 	16: "SYNC_STATE_GET_DELTAS",
 	17: "SYNC_STATE_DELTAS",
+	18: "SYNC_GOSSIP_DIGEST",
 	20: "RESPONSE",
 	21: "CONSENSUS",
+	22: "EXECUTION_RESULT",
 }
 var Message_Type_value = map[string]int32{
 	"UNDEFINED":               0,
@@ -122,6 +239,10 @@ var Message_Type_value = map[string]int32{
 	"DISC_PEERS":              4,
 	"DISC_NEWMSG":             5,
 	"CHAIN_TRANSACTION":       6,
+	"DISC_PAUSE":              7,
+	"DISC_RESUME":             8,
+	"DISC_PING":               9,
+	"DISC_PONG":               10,
 	"SYNC_GET_BLOCKS":         11,
 	"SYNC_BLOCKS":             12,
 	"SYNC_BLOCK_ADDED":        13,
@@ -129,14 +250,59 @@ var Message_Type_value = map[string]int32{
 	"SYNC_STATE_SNAPSHOT":     15,
 	"SYNC_STATE_GET_DELTAS":   16,
 	"SYNC_STATE_DELTAS":       17,
+	"SYNC_GOSSIP_DIGEST":      18,
 	"RESPONSE":                20,
 	"CONSENSUS":               21,
+	"EXECUTION_RESULT":        22,
 }
 
 func (x Message_Type) String() string {
 	return proto.EnumName(Message_Type_name, int32(x))
 }
 
+type Message_Compression int32
+
+const (
+	Message_NONE Message_Compression = 0
+	Message_GZIP Message_Compression = 1
+)
+
+var Message_Compression_name = map[int32]string{
+	0: "NONE",
+	1: "GZIP",
+}
+var Message_Compression_value = map[string]int32{
+	"NONE": 0,
+	"GZIP": 1,
+}
+
+func (x Message_Compression) String() string {
+	return proto.EnumName(Message_Compression_name, int32(x))
+}
+
+type PeerHealth_State int32
+
+const (
+	PeerHealth_ALIVE   PeerHealth_State = 0
+	PeerHealth_SUSPECT PeerHealth_State = 1
+	PeerHealth_DEAD    PeerHealth_State = 2
+)
+
+var PeerHealth_State_name = map[int32]string{
+	0: "ALIVE",
+	1: "SUSPECT",
+	2: "DEAD",
+}
+var PeerHealth_State_value = map[string]int32{
+	"ALIVE":   0,
+	"SUSPECT": 1,
+	"DEAD":    2,
+}
+
+func (x PeerHealth_State) String() string {
+	return proto.EnumName(PeerHealth_State_name, int32(x))
+}
+
 type Response_StatusCode int32
 
 const (
@@ -179,6 +345,18 @@ type Transaction struct {
 	ToValidators                   []byte                     `protobuf:"bytes,10,opt,name=toValidators,proto3" json:"toValidators,omitempty"`
 	Cert                           []byte                     `protobuf:"bytes,11,opt,name=cert,proto3" json:"cert,omitempty"`
 	Signature                      []byte                     `protobuf:"bytes,12,opt,name=signature,proto3" json:"signature,omitempty"`
+	// ledger of the peer the transaction is executed against, empty for the default ledger
+	LedgerID string `protobuf:"bytes,13,opt,name=ledgerID" json:"ledgerID,omitempty"`
+	// keys of a confidential transaction encrypted to the auditors of its
+	// chaincode, each able to decrypt the transaction but not the state
+	ToAuditors []*AuditorKeyShare `protobuf:"bytes,14,rep,name=toAuditors" json:"toAuditors,omitempty"`
+	// signatures of the co-signers of the transaction, and the policy they
+	// must satisfy for the transaction to be executed
+	MultiSignature *MultiSignature `protobuf:"bytes,15,opt,name=multiSignature" json:"multiSignature,omitempty"`
+	// span of the trace of the transaction on the peer it was last passed
+	// on by, set after the transaction is signed and removed before it is
+	// committed, so neither the signatures nor the blocks cover it
+	TraceContext *SpanContext `protobuf:"bytes,16,opt,name=traceContext" json:"traceContext,omitempty"`
 }
 
 func (m *Transaction) Reset()         { *m = Transaction{} }
@@ -192,6 +370,124 @@ func (m *Transaction) GetTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *Transaction) GetToAuditors() []*AuditorKeyShare {
+	if m != nil {
+		return m.ToAuditors
+	}
+	return nil
+}
+
+func (m *Transaction) GetMultiSignature() *MultiSignature {
+	if m != nil {
+		return m.MultiSignature
+	}
+	return nil
+}
+
+func (m *Transaction) GetTraceContext() *SpanContext {
+	if m != nil {
+		return m.TraceContext
+	}
+	return nil
+}
+
+// SpanContext identifies a span of the trace of a transaction, propagated
+// with the transaction for the spans of the next peers to be its children.
+type SpanContext struct {
+	TraceID uint64 `protobuf:"varint,1,opt,name=traceID" json:"traceID,omitempty"`
+	SpanID  uint64 `protobuf:"varint,2,opt,name=spanID" json:"spanID,omitempty"`
+}
+
+func (m *SpanContext) Reset()         { *m = SpanContext{} }
+func (m *SpanContext) String() string { return proto.CompactTextString(m) }
+func (*SpanContext) ProtoMessage()    {}
+
+// AuditorKeyShare is the key of a confidential transaction encrypted with the
+// public key of the auditor auditorID.
+type AuditorKeyShare struct {
+	AuditorID string `protobuf:"bytes,1,opt,name=auditorID" json:"auditorID,omitempty"`
+	Key       []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (m *AuditorKeyShare) Reset()         { *m = AuditorKeyShare{} }
+func (m *AuditorKeyShare) String() string { return proto.CompactTextString(m) }
+func (*AuditorKeyShare) ProtoMessage()    {}
+
+// MultiSignature carries the signatures of the co-signers of a transaction,
+// which must satisfy its policy. The submitter and the co-signers all sign
+// the transaction without its signature and without these signatures, so
+// the policy is covered by every signature.
+type MultiSignature struct {
+	Policy     *MultiSignaturePolicy   `protobuf:"bytes,1,opt,name=policy" json:"policy,omitempty"`
+	Signatures []*TransactionSignature `protobuf:"bytes,2,rep,name=signatures" json:"signatures,omitempty"`
+}
+
+func (m *MultiSignature) Reset()         { *m = MultiSignature{} }
+func (m *MultiSignature) String() string { return proto.CompactTextString(m) }
+func (*MultiSignature) ProtoMessage()    {}
+
+func (m *MultiSignature) GetPolicy() *MultiSignaturePolicy {
+	if m != nil {
+		return m.Policy
+	}
+	return nil
+}
+
+func (m *MultiSignature) GetSignatures() []*TransactionSignature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+// MultiSignaturePolicy requires the signatures of at least threshold of the
+// enrollment certificates whose SHA3-256 hashes are listed.
+type MultiSignaturePolicy struct {
+	Threshold  uint32   `protobuf:"varint,1,opt,name=threshold" json:"threshold,omitempty"`
+	CertHashes [][]byte `protobuf:"bytes,2,rep,name=certHashes,proto3" json:"certHashes,omitempty"`
+}
+
+func (m *MultiSignaturePolicy) Reset()         { *m = MultiSignaturePolicy{} }
+func (m *MultiSignaturePolicy) String() string { return proto.CompactTextString(m) }
+func (*MultiSignaturePolicy) ProtoMessage()    {}
+
+// TransactionSignature is the signature of a co-signer of a transaction,
+// with the DER encoded enrollment certificate it verifies under.
+type TransactionSignature struct {
+	Cert      []byte `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *TransactionSignature) Reset()         { *m = TransactionSignature{} }
+func (m *TransactionSignature) String() string { return proto.CompactTextString(m) }
+func (*TransactionSignature) ProtoMessage()    {}
+
+// ValidatorSet is the payload of a CONSENSUS_RECONFIGURE transaction: the
+// consensus quorum is made of the N validating peers vp0 to vpN-1, of which f
+// may be faulty. The transaction must be signed by the enrollment certificate
+// of a consensus admin, or co-signed by a quorum of the validating peers.
+type ValidatorSet struct {
+	N uint32 `protobuf:"varint,1,opt,name=N" json:"N,omitempty"`
+	F uint32 `protobuf:"varint,2,opt,name=f" json:"f,omitempty"`
+	// enrollment ID of the admin signing the reconfiguration submitted to
+	// Devops, removed from the payload
+	SecureContext string `protobuf:"bytes,3,opt,name=secureContext" json:"secureContext,omitempty"`
+}
+
+func (m *ValidatorSet) Reset()         { *m = ValidatorSet{} }
+func (m *ValidatorSet) String() string { return proto.CompactTextString(m) }
+func (*ValidatorSet) ProtoMessage()    {}
+
+// RevocationList is the payload of a PKI_CRL_UPDATE transaction: the DER
+// encoded X.509 certificate revocation list of the ECA, signed by the ECA.
+type RevocationList struct {
+	Crl []byte `protobuf:"bytes,1,opt,name=crl,proto3" json:"crl,omitempty"`
+}
+
+func (m *RevocationList) Reset()         { *m = RevocationList{} }
+func (m *RevocationList) String() string { return proto.CompactTextString(m) }
+func (*RevocationList) ProtoMessage()    {}
+
 // TransactionBlock carries a batch of transactions.
 type TransactionBlock struct {
 	Transactions []*Transaction `protobuf:"bytes,1,rep,name=transactions" json:"transactions,omitempty"`
@@ -215,12 +511,15 @@ func (m *TransactionBlock) GetTransactions() []*Transaction {
 // errorCode - An error code. 5xx will be logged as a failure in the dashboard.
 // error - An error string for logging an issue.
 // chaincodeEvent - any event emitted by a transaction
+// phase, reason - The phase and the reason of the failure of the transaction.
 type TransactionResult struct {
 	Uuid           string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
 	Result         []byte          `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
 	ErrorCode      uint32          `protobuf:"varint,3,opt,name=errorCode" json:"errorCode,omitempty"`
 	Error          string          `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
 	ChaincodeEvent *ChaincodeEvent `protobuf:"bytes,5,opt,name=chaincodeEvent" json:"chaincodeEvent,omitempty"`
+	Phase          RejectionPhase  `protobuf:"varint,6,opt,name=phase,enum=protos.RejectionPhase" json:"phase,omitempty"`
+	Reason         RejectionReason `protobuf:"varint,7,opt,name=reason,enum=protos.RejectionReason" json:"reason,omitempty"`
 }
 
 func (m *TransactionResult) Reset()         { *m = TransactionResult{} }
@@ -254,6 +553,7 @@ type Block struct {
 	PreviousBlockHash []byte                     `protobuf:"bytes,5,opt,name=previousBlockHash,proto3" json:"previousBlockHash,omitempty"`
 	ConsensusMetadata []byte                     `protobuf:"bytes,6,opt,name=consensusMetadata,proto3" json:"consensusMetadata,omitempty"`
 	NonHashData       *NonHashData               `protobuf:"bytes,7,opt,name=nonHashData" json:"nonHashData,omitempty"`
+	HashAlgorithm     string                     `protobuf:"bytes,8,opt,name=hashAlgorithm" json:"hashAlgorithm,omitempty"`
 }
 
 func (m *Block) Reset()         { *m = Block{} }
@@ -339,10 +639,11 @@ func (m *PeerID) String() string { return proto.CompactTextString(m) }
 func (*PeerID) ProtoMessage()    {}
 
 type PeerEndpoint struct {
-	ID      *PeerID           `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
-	Address string            `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
-	Type    PeerEndpoint_Type `protobuf:"varint,3,opt,name=type,enum=protos.PeerEndpoint_Type" json:"type,omitempty"`
-	PkiID   []byte            `protobuf:"bytes,4,opt,name=pkiID,proto3" json:"pkiID,omitempty"`
+	ID       *PeerID           `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	Address  string            `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	Type     PeerEndpoint_Type `protobuf:"varint,3,opt,name=type,enum=protos.PeerEndpoint_Type" json:"type,omitempty"`
+	PkiID    []byte            `protobuf:"bytes,4,opt,name=pkiID,proto3" json:"pkiID,omitempty"`
+	Metadata *EndpointMetadata `protobuf:"bytes,5,opt,name=metadata" json:"metadata,omitempty"`
 }
 
 func (m *PeerEndpoint) Reset()         { *m = PeerEndpoint{} }
@@ -356,6 +657,30 @@ func (m *PeerEndpoint) GetID() *PeerID {
 	return nil
 }
 
+func (m *PeerEndpoint) GetMetadata() *EndpointMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+// EndpointMetadata is what a peer supports on its chat streams, advertised
+// in its hello message: the compressions it accepts message payloads in, and
+// the maximum size of the messages it receives, 0 if unbounded. A peer
+// which only chats with the peers it connects to, e.g. behind a NAT, is
+// outboundOnly: the other peers do not connect to its address. The version
+// is the software version of the peer, its peer.version.
+type EndpointMetadata struct {
+	Compression    []string `protobuf:"bytes,1,rep,name=compression" json:"compression,omitempty"`
+	MaxMessageSize uint32   `protobuf:"varint,2,opt,name=maxMessageSize" json:"maxMessageSize,omitempty"`
+	OutboundOnly   bool     `protobuf:"varint,3,opt,name=outboundOnly" json:"outboundOnly,omitempty"`
+	Version        string   `protobuf:"bytes,4,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *EndpointMetadata) Reset()         { *m = EndpointMetadata{} }
+func (m *EndpointMetadata) String() string { return proto.CompactTextString(m) }
+func (*EndpointMetadata) ProtoMessage()    {}
+
 type PeersMessage struct {
 	Peers []*PeerEndpoint `protobuf:"bytes,1,rep,name=peers" json:"peers,omitempty"`
 }
@@ -371,6 +696,118 @@ func (m *PeersMessage) GetPeers() []*PeerEndpoint {
 	return nil
 }
 
+// PeersAddresses is the list of the addresses of the discovered peers a peer
+// persists to chat with them again after a restart
+type PeersAddresses struct {
+	Addresses []string `protobuf:"bytes,1,rep,name=addresses" json:"addresses,omitempty"`
+}
+
+func (m *PeersAddresses) Reset()         { *m = PeersAddresses{} }
+func (m *PeersAddresses) String() string { return proto.CompactTextString(m) }
+func (*PeersAddresses) ProtoMessage()    {}
+
+// PeerHealth is the liveness of a peer chatting with this peer, as probed by
+// pinging it: ALIVE while it answers, SUSPECT once it missed a ping, and DEAD
+// once it missed too many. The roundTripTime is the smoothed round trip time
+// of the pings, in microseconds.
+type PeerHealth struct {
+	ID            *PeerID                    `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	Address       string                     `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	State         PeerHealth_State           `protobuf:"varint,3,opt,name=state,enum=protos.PeerHealth_State" json:"state,omitempty"`
+	RoundTripTime uint64                     `protobuf:"varint,4,opt,name=roundTripTime" json:"roundTripTime,omitempty"`
+	MissedPings   uint32                     `protobuf:"varint,5,opt,name=missedPings" json:"missedPings,omitempty"`
+	LastSeen      *google_protobuf.Timestamp `protobuf:"bytes,6,opt,name=lastSeen" json:"lastSeen,omitempty"`
+}
+
+func (m *PeerHealth) Reset()         { *m = PeerHealth{} }
+func (m *PeerHealth) String() string { return proto.CompactTextString(m) }
+func (*PeerHealth) ProtoMessage()    {}
+
+func (m *PeerHealth) GetID() *PeerID {
+	if m != nil {
+		return m.ID
+	}
+	return nil
+}
+
+func (m *PeerHealth) GetLastSeen() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.LastSeen
+	}
+	return nil
+}
+
+type PeersHealth struct {
+	Peers []*PeerHealth `protobuf:"bytes,1,rep,name=peers" json:"peers,omitempty"`
+}
+
+func (m *PeersHealth) Reset()         { *m = PeersHealth{} }
+func (m *PeersHealth) String() string { return proto.CompactTextString(m) }
+func (*PeersHealth) ProtoMessage()    {}
+
+func (m *PeersHealth) GetPeers() []*PeerHealth {
+	if m != nil {
+		return m.Peers
+	}
+	return nil
+}
+
+// PeerTopology is what a peer knows of a peer, itself included: the height
+// of its blockchain, as of its last hello or gossip digest, the peers it
+// chats with, as of its last DISC_PEERS, and the smoothed, minimum and
+// maximum round trip times of the pings to it, in microseconds.
+type PeerTopology struct {
+	ID               *PeerID   `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+	BlockHeight      uint64    `protobuf:"varint,2,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	Neighbors        []*PeerID `protobuf:"bytes,3,rep,name=neighbors" json:"neighbors,omitempty"`
+	RoundTripTime    uint64    `protobuf:"varint,4,opt,name=roundTripTime" json:"roundTripTime,omitempty"`
+	MinRoundTripTime uint64    `protobuf:"varint,5,opt,name=minRoundTripTime" json:"minRoundTripTime,omitempty"`
+	MaxRoundTripTime uint64    `protobuf:"varint,6,opt,name=maxRoundTripTime" json:"maxRoundTripTime,omitempty"`
+}
+
+func (m *PeerTopology) Reset()         { *m = PeerTopology{} }
+func (m *PeerTopology) String() string { return proto.CompactTextString(m) }
+func (*PeerTopology) ProtoMessage()    {}
+
+func (m *PeerTopology) GetID() *PeerID {
+	if m != nil {
+		return m.ID
+	}
+	return nil
+}
+
+func (m *PeerTopology) GetNeighbors() []*PeerID {
+	if m != nil {
+		return m.Neighbors
+	}
+	return nil
+}
+
+// NetworkTopology is the network as seen by a peer: the endpoints of the
+// peers it chats with and its own, and their topology.
+type NetworkTopology struct {
+	Peers    []*PeerEndpoint `protobuf:"bytes,1,rep,name=peers" json:"peers,omitempty"`
+	Topology []*PeerTopology `protobuf:"bytes,2,rep,name=topology" json:"topology,omitempty"`
+}
+
+func (m *NetworkTopology) Reset()         { *m = NetworkTopology{} }
+func (m *NetworkTopology) String() string { return proto.CompactTextString(m) }
+func (*NetworkTopology) ProtoMessage()    {}
+
+func (m *NetworkTopology) GetPeers() []*PeerEndpoint {
+	if m != nil {
+		return m.Peers
+	}
+	return nil
+}
+
+func (m *NetworkTopology) GetTopology() []*PeerTopology {
+	if m != nil {
+		return m.Topology
+	}
+	return nil
+}
+
 type HelloMessage struct {
 	PeerEndpoint   *PeerEndpoint   `protobuf:"bytes,1,opt,name=peerEndpoint" json:"peerEndpoint,omitempty"`
 	BlockchainInfo *BlockchainInfo `protobuf:"bytes,2,opt,name=blockchainInfo" json:"blockchainInfo,omitempty"`
@@ -399,6 +836,8 @@ type Message struct {
 	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
 	Payload   []byte                     `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
 	Signature []byte                     `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	// compression of the payload, the signature is over the uncompressed one
+	Compression Message_Compression `protobuf:"varint,5,opt,name=compression,enum=protos.Message_Compression" json:"compression,omitempty"`
 }
 
 func (m *Message) Reset()         { *m = Message{} }
@@ -425,7 +864,10 @@ func (*Response) ProtoMessage()    {}
 // commits a new block to the ledger, it will notify its connected NVPs of the
 // block and the delta state. The NVP may call the ledger APIs to apply the
 // block and the delta state to its ledger if the block's previousBlockHash
-// equals to the NVP's current block hash
+// equals to the NVP's current block hash. NVPs gossip the blocks they apply
+// to other NVPs the same way, and push the blocks a peer is missing when it
+// sends them a Message.SYNC_GOSSIP_DIGEST, whose payload is the
+// BlockchainInfo of the sender.
 type BlockState struct {
 	Block      *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
 	StateDelta []byte `protobuf:"bytes,2,opt,name=stateDelta,proto3" json:"stateDelta,omitempty"`
@@ -485,6 +927,9 @@ func (m *SyncBlocks) GetBlocks() []*Block {
 // SyncSnapshotRequest Payload for the penchainMessage.SYNC_GET_SNAPSHOT message.
 type SyncStateSnapshotRequest struct {
 	CorrelationId uint64 `protobuf:"varint,1,opt,name=correlationId" json:"correlationId,omitempty"`
+	// Sequence number of the first delta to send, used to resume an
+	// interrupted snapshot transfer
+	StartSequence uint64 `protobuf:"varint,2,opt,name=startSequence" json:"startSequence,omitempty"`
 }
 
 func (m *SyncStateSnapshotRequest) Reset()         { *m = SyncStateSnapshotRequest{} }
@@ -550,10 +995,51 @@ func (m *SyncStateDeltas) GetRange() *SyncBlockRange {
 	return nil
 }
 
+// BlockExecutionResult is the payload of Message.EXECUTION_RESULT. When a VP
+// commits a block, it sends the hashes of the changes made by each transaction
+// of the block to the other VPs, which compare them with their own results so
+// that non-deterministic transactions are detected. A VP whose results differ
+// sends back a response with the write sets of the differing transactions.
+type BlockExecutionResult struct {
+	BlockNumber uint64               `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	StateHash   []byte               `protobuf:"bytes,2,opt,name=stateHash,proto3" json:"stateHash,omitempty"`
+	TxResults   []*TxExecutionResult `protobuf:"bytes,3,rep,name=txResults" json:"txResults,omitempty"`
+	Response    bool                 `protobuf:"varint,4,opt,name=response" json:"response,omitempty"`
+}
+
+func (m *BlockExecutionResult) Reset()         { *m = BlockExecutionResult{} }
+func (m *BlockExecutionResult) String() string { return proto.CompactTextString(m) }
+func (*BlockExecutionResult) ProtoMessage()    {}
+
+func (m *BlockExecutionResult) GetTxResults() []*TxExecutionResult {
+	if m != nil {
+		return m.TxResults
+	}
+	return nil
+}
+
+// TxExecutionResult is the result of a transaction of a committed block.
+// writeSetHash is the hash of the state changes made by the transaction and
+// writeSet, the serialized state changes, is only set in responses.
+type TxExecutionResult struct {
+	Uuid         string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Failed       bool   `protobuf:"varint,2,opt,name=failed" json:"failed,omitempty"`
+	WriteSetHash []byte `protobuf:"bytes,3,opt,name=writeSetHash,proto3" json:"writeSetHash,omitempty"`
+	WriteSet     []byte `protobuf:"bytes,4,opt,name=writeSet,proto3" json:"writeSet,omitempty"`
+}
+
+func (m *TxExecutionResult) Reset()         { *m = TxExecutionResult{} }
+func (m *TxExecutionResult) String() string { return proto.CompactTextString(m) }
+func (*TxExecutionResult) ProtoMessage()    {}
+
 func init() {
+	proto.RegisterEnum("protos.RejectionPhase", RejectionPhase_name, RejectionPhase_value)
+	proto.RegisterEnum("protos.RejectionReason", RejectionReason_name, RejectionReason_value)
 	proto.RegisterEnum("protos.Transaction_Type", Transaction_Type_name, Transaction_Type_value)
 	proto.RegisterEnum("protos.PeerEndpoint_Type", PeerEndpoint_Type_name, PeerEndpoint_Type_value)
+	proto.RegisterEnum("protos.PeerHealth_State", PeerHealth_State_name, PeerHealth_State_value)
 	proto.RegisterEnum("protos.Message_Type", Message_Type_name, Message_Type_value)
+	proto.RegisterEnum("protos.Message_Compression", Message_Compression_name, Message_Compression_value)
 	proto.RegisterEnum("protos.Response_StatusCode", Response_StatusCode_name, Response_StatusCode_value)
 }
 
@@ -689,3 +1175,99 @@ var _Peer_serviceDesc = grpc.ServiceDesc{
 		},
 	},
 }
+
+// Client API for Snapshot service
+
+type SnapshotClient interface {
+	// Streams a consistent snapshot of the state. Every message carries the
+	// block number the snapshot corresponds to, the stream is terminated by
+	// a message with an empty delta.
+	GetStateSnapshot(ctx context.Context, in *SyncStateSnapshotRequest, opts ...grpc.CallOption) (Snapshot_GetStateSnapshotClient, error)
+}
+
+type snapshotClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSnapshotClient(cc *grpc.ClientConn) SnapshotClient {
+	return &snapshotClient{cc}
+}
+
+func (c *snapshotClient) GetStateSnapshot(ctx context.Context, in *SyncStateSnapshotRequest, opts ...grpc.CallOption) (Snapshot_GetStateSnapshotClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Snapshot_serviceDesc.Streams[0], c.cc, "/protos.Snapshot/GetStateSnapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &snapshotGetStateSnapshotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Snapshot_GetStateSnapshotClient interface {
+	Recv() (*SyncStateSnapshot, error)
+	grpc.ClientStream
+}
+
+type snapshotGetStateSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *snapshotGetStateSnapshotClient) Recv() (*SyncStateSnapshot, error) {
+	m := new(SyncStateSnapshot)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Snapshot service
+
+type SnapshotServer interface {
+	// Streams a consistent snapshot of the state. Every message carries the
+	// block number the snapshot corresponds to, the stream is terminated by
+	// a message with an empty delta.
+	GetStateSnapshot(*SyncStateSnapshotRequest, Snapshot_GetStateSnapshotServer) error
+}
+
+func RegisterSnapshotServer(s *grpc.Server, srv SnapshotServer) {
+	s.RegisterService(&_Snapshot_serviceDesc, srv)
+}
+
+func _Snapshot_GetStateSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SyncStateSnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SnapshotServer).GetStateSnapshot(m, &snapshotGetStateSnapshotServer{stream})
+}
+
+type Snapshot_GetStateSnapshotServer interface {
+	Send(*SyncStateSnapshot) error
+	grpc.ServerStream
+}
+
+type snapshotGetStateSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *snapshotGetStateSnapshotServer) Send(m *SyncStateSnapshot) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Snapshot_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.Snapshot",
+	HandlerType: (*SnapshotServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetStateSnapshot",
+			Handler:       _Snapshot_GetStateSnapshot_Handler,
+			ServerStreams: true,
+		},
+	},
+}
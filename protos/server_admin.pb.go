@@ -59,6 +59,118 @@ func (m *ServerStatus) Reset()         { *m = ServerStatus{} }
 func (m *ServerStatus) String() string { return proto.CompactTextString(m) }
 func (*ServerStatus) ProtoMessage()    {}
 
+// PeerStatus is a peer the server chats with, and the height of its
+// blockchain as of its last hello or gossip digest.
+type PeerStatus struct {
+	Endpoint    *PeerEndpoint `protobuf:"bytes,1,opt,name=endpoint" json:"endpoint,omitempty"`
+	BlockHeight uint64        `protobuf:"varint,2,opt,name=blockHeight" json:"blockHeight,omitempty"`
+}
+
+func (m *PeerStatus) Reset()         { *m = PeerStatus{} }
+func (m *PeerStatus) String() string { return proto.CompactTextString(m) }
+func (*PeerStatus) ProtoMessage()    {}
+
+func (m *PeerStatus) GetEndpoint() *PeerEndpoint {
+	if m != nil {
+		return m.Endpoint
+	}
+	return nil
+}
+
+// NetworkStatus is the network as seen by the server: its own endpoint and
+// height, the peers it chats with, and the metrics of its consensus plugin,
+// in JSON, or why they are not available.
+type NetworkStatus struct {
+	Self           *PeerEndpoint `protobuf:"bytes,1,opt,name=self" json:"self,omitempty"`
+	BlockHeight    uint64        `protobuf:"varint,2,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	Peers          []*PeerStatus `protobuf:"bytes,3,rep,name=peers" json:"peers,omitempty"`
+	Consensus      string        `protobuf:"bytes,4,opt,name=consensus" json:"consensus,omitempty"`
+	ConsensusError string        `protobuf:"bytes,5,opt,name=consensusError" json:"consensusError,omitempty"`
+}
+
+func (m *NetworkStatus) Reset()         { *m = NetworkStatus{} }
+func (m *NetworkStatus) String() string { return proto.CompactTextString(m) }
+func (*NetworkStatus) ProtoMessage()    {}
+
+func (m *NetworkStatus) GetSelf() *PeerEndpoint {
+	if m != nil {
+		return m.Self
+	}
+	return nil
+}
+
+func (m *NetworkStatus) GetPeers() []*PeerStatus {
+	if m != nil {
+		return m.Peers
+	}
+	return nil
+}
+
+// NetworkPeer is the address, or the ID, of a peer.
+type NetworkPeer struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *NetworkPeer) Reset()         { *m = NetworkPeer{} }
+func (m *NetworkPeer) String() string { return proto.CompactTextString(m) }
+func (*NetworkPeer) ProtoMessage()    {}
+
+// ConfigChange is the change of the value of a configuration key.
+type ConfigChange struct {
+	Key      string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	OldValue string `protobuf:"bytes,2,opt,name=oldValue" json:"oldValue,omitempty"`
+	NewValue string `protobuf:"bytes,3,opt,name=newValue" json:"newValue,omitempty"`
+}
+
+func (m *ConfigChange) Reset()         { *m = ConfigChange{} }
+func (m *ConfigChange) String() string { return proto.CompactTextString(m) }
+func (*ConfigChange) ProtoMessage()    {}
+
+// ConfigReload is the outcome of a configuration reload: the changes
+// applied, and the keys whose change only takes effect on restart.
+type ConfigReload struct {
+	Changes         []*ConfigChange `protobuf:"bytes,1,rep,name=changes" json:"changes,omitempty"`
+	RestartRequired []string        `protobuf:"bytes,2,rep,name=restartRequired" json:"restartRequired,omitempty"`
+}
+
+func (m *ConfigReload) Reset()         { *m = ConfigReload{} }
+func (m *ConfigReload) String() string { return proto.CompactTextString(m) }
+func (*ConfigReload) ProtoMessage()    {}
+
+func (m *ConfigReload) GetChanges() []*ConfigChange {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
+// ModuleLogLevel is the logging level of a logging module, such as
+// consensus/obcpbft.
+type ModuleLogLevel struct {
+	Module string `protobuf:"bytes,1,opt,name=module" json:"module,omitempty"`
+	Level  string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+}
+
+func (m *ModuleLogLevel) Reset()         { *m = ModuleLogLevel{} }
+func (m *ModuleLogLevel) String() string { return proto.CompactTextString(m) }
+func (*ModuleLogLevel) ProtoMessage()    {}
+
+// ModuleLogLevels are the logging levels of modules.
+type ModuleLogLevels struct {
+	Modules []*ModuleLogLevel `protobuf:"bytes,1,rep,name=modules" json:"modules,omitempty"`
+}
+
+func (m *ModuleLogLevels) Reset()         { *m = ModuleLogLevels{} }
+func (m *ModuleLogLevels) String() string { return proto.CompactTextString(m) }
+func (*ModuleLogLevels) ProtoMessage()    {}
+
+func (m *ModuleLogLevels) GetModules() []*ModuleLogLevel {
+	if m != nil {
+		return m.Modules
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("protos.ServerStatus_StatusCode", ServerStatus_StatusCode_name, ServerStatus_StatusCode_value)
 }
@@ -74,6 +186,25 @@ type AdminClient interface {
 	GetStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StartServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StopServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
+	// GetNetworkStatus returns the peers the server chats with, the heights
+	// of their blockchains and the status of its consensus plugin.
+	GetNetworkStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NetworkStatus, error)
+	// ConnectPeer has the server chat with the peer at the address.
+	ConnectPeer(ctx context.Context, in *NetworkPeer, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// DisconnectPeer has the server end its chats with the peer of the
+	// address or ID.
+	DisconnectPeer(ctx context.Context, in *NetworkPeer, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ReloadConfig has the server read its configuration again, and apply
+	// the changes of the values it can change while running.
+	ReloadConfig(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConfigReload, error)
+	// GetModuleLogLevels returns the logging levels in effect for the module
+	// and its submodules, or for all the modules with an empty module.
+	GetModuleLogLevels(ctx context.Context, in *ModuleLogLevel, opts ...grpc.CallOption) (*ModuleLogLevels, error)
+	// SetModuleLogLevel sets the logging level of the module, which also
+	// applies to its submodules without a level of their own, until the
+	// server restarts, and returns the levels then in effect as
+	// GetModuleLogLevels.
+	SetModuleLogLevel(ctx context.Context, in *ModuleLogLevel, opts ...grpc.CallOption) (*ModuleLogLevels, error)
 }
 
 type adminClient struct {
@@ -111,6 +242,60 @@ func (c *adminClient) StopServer(ctx context.Context, in *google_protobuf1.Empty
 	return out, nil
 }
 
+func (c *adminClient) GetNetworkStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*NetworkStatus, error) {
+	out := new(NetworkStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetNetworkStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ConnectPeer(ctx context.Context, in *NetworkPeer, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/protos.Admin/ConnectPeer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DisconnectPeer(ctx context.Context, in *NetworkPeer, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/protos.Admin/DisconnectPeer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ReloadConfig(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ConfigReload, error) {
+	out := new(ConfigReload)
+	err := grpc.Invoke(ctx, "/protos.Admin/ReloadConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetModuleLogLevels(ctx context.Context, in *ModuleLogLevel, opts ...grpc.CallOption) (*ModuleLogLevels, error) {
+	out := new(ModuleLogLevels)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetModuleLogLevels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetModuleLogLevel(ctx context.Context, in *ModuleLogLevel, opts ...grpc.CallOption) (*ModuleLogLevels, error) {
+	out := new(ModuleLogLevels)
+	err := grpc.Invoke(ctx, "/protos.Admin/SetModuleLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Admin service
 
 type AdminServer interface {
@@ -118,6 +303,25 @@ type AdminServer interface {
 	GetStatus(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StartServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StopServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
+	// GetNetworkStatus returns the peers the server chats with, the heights
+	// of their blockchains and the status of its consensus plugin.
+	GetNetworkStatus(context.Context, *google_protobuf1.Empty) (*NetworkStatus, error)
+	// ConnectPeer has the server chat with the peer at the address.
+	ConnectPeer(context.Context, *NetworkPeer) (*google_protobuf1.Empty, error)
+	// DisconnectPeer has the server end its chats with the peer of the
+	// address or ID.
+	DisconnectPeer(context.Context, *NetworkPeer) (*google_protobuf1.Empty, error)
+	// ReloadConfig has the server read its configuration again, and apply
+	// the changes of the values it can change while running.
+	ReloadConfig(context.Context, *google_protobuf1.Empty) (*ConfigReload, error)
+	// GetModuleLogLevels returns the logging levels in effect for the module
+	// and its submodules, or for all the modules with an empty module.
+	GetModuleLogLevels(context.Context, *ModuleLogLevel) (*ModuleLogLevels, error)
+	// SetModuleLogLevel sets the logging level of the module, which also
+	// applies to its submodules without a level of their own, until the
+	// server restarts, and returns the levels then in effect as
+	// GetModuleLogLevels.
+	SetModuleLogLevel(context.Context, *ModuleLogLevel) (*ModuleLogLevels, error)
 }
 
 func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
@@ -160,6 +364,78 @@ func _Admin_StopServer_Handler(srv interface{}, ctx context.Context, dec func(in
 	return out, nil
 }
 
+func _Admin_GetNetworkStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetNetworkStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ConnectPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(NetworkPeer)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ConnectPeer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_DisconnectPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(NetworkPeer)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).DisconnectPeer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ReloadConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetModuleLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ModuleLogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetModuleLogLevels(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_SetModuleLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ModuleLogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).SetModuleLogLevel(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Admin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Admin",
 	HandlerType: (*AdminServer)(nil),
@@ -176,6 +452,30 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StopServer",
 			Handler:    _Admin_StopServer_Handler,
 		},
+		{
+			MethodName: "GetNetworkStatus",
+			Handler:    _Admin_GetNetworkStatus_Handler,
+		},
+		{
+			MethodName: "ConnectPeer",
+			Handler:    _Admin_ConnectPeer_Handler,
+		},
+		{
+			MethodName: "DisconnectPeer",
+			Handler:    _Admin_DisconnectPeer_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _Admin_ReloadConfig_Handler,
+		},
+		{
+			MethodName: "GetModuleLogLevels",
+			Handler:    _Admin_GetModuleLogLevels_Handler,
+		},
+		{
+			MethodName: "SetModuleLogLevel",
+			Handler:    _Admin_SetModuleLogLevel_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
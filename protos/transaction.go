@@ -77,6 +77,9 @@ func NewChaincodeDeployTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentS
 		}
 		transaction.ChaincodeID = data
 	}
+	if chaincodeDeploymentSpec.ChaincodeSpec != nil {
+		transaction.LedgerID = chaincodeDeploymentSpec.ChaincodeSpec.LedgerID
+	}
 	//if chaincodeDeploymentSpec.ChaincodeSpec.GetCtorMsg() != nil {
 	//	transaction.Function = chaincodeDeploymentSpec.ChaincodeSpec.GetCtorMsg().Function
 	//	transaction.Args = chaincodeDeploymentSpec.ChaincodeSpec.GetCtorMsg().Args
@@ -90,6 +93,48 @@ func NewChaincodeDeployTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentS
 	return transaction, nil
 }
 
+// NewChaincodeUpgradeTransaction is used to upgrade the deployed chaincode
+// named by chaincodeDeploymentSpec.UpgradedChaincodeName to the chaincode of
+// the deployment spec.
+func NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentSpec, uuid string) (*Transaction, error) {
+	transaction, err := NewChaincodeDeployTransaction(chaincodeDeploymentSpec, uuid)
+	if err != nil {
+		return nil, err
+	}
+	transaction.Type = Transaction_CHAINCODE_UPGRADE
+	return transaction, nil
+}
+
+// NewReconfigureTransaction is used to reconfigure the set of validating peers
+// taking part in consensus to validatorSet.
+func NewReconfigureTransaction(validatorSet *ValidatorSet, uuid string) (*Transaction, error) {
+	transaction := new(Transaction)
+	transaction.Type = Transaction_CONSENSUS_RECONFIGURE
+	transaction.Uuid = uuid
+	transaction.Timestamp = util.CreateUtcTimestamp()
+	data, err := proto.Marshal(validatorSet)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal payload for reconfiguration: %s", err)
+	}
+	transaction.Payload = data
+	return transaction, nil
+}
+
+// NewRevocationListTransaction is used to replace the certificate revocation
+// list of the ECA with revocationList.
+func NewRevocationListTransaction(revocationList *RevocationList, uuid string) (*Transaction, error) {
+	transaction := new(Transaction)
+	transaction.Type = Transaction_PKI_CRL_UPDATE
+	transaction.Uuid = uuid
+	transaction.Timestamp = util.CreateUtcTimestamp()
+	data, err := proto.Marshal(revocationList)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal payload for revocation list update: %s", err)
+	}
+	transaction.Payload = data
+	return transaction, nil
+}
+
 // NewChaincodeExecute is used to deploy chaincode.
 func NewChaincodeExecute(chaincodeInvocationSpec *ChaincodeInvocationSpec, uuid string, typ Transaction_Type) (*Transaction, error) {
 	transaction := new(Transaction)
@@ -104,6 +149,9 @@ func NewChaincodeExecute(chaincodeInvocationSpec *ChaincodeInvocationSpec, uuid
 		}
 		transaction.ChaincodeID = data
 	}
+	if chaincodeInvocationSpec.ChaincodeSpec != nil {
+		transaction.LedgerID = chaincodeInvocationSpec.ChaincodeSpec.LedgerID
+	}
 	data, err := proto.Marshal(chaincodeInvocationSpec)
 	if err != nil {
 		return nil, fmt.Errorf("Could not marshal payload for chaincode invocation: %s", err)
@@ -0,0 +1,21 @@
+#!/bin/bash
+
+# Runs the tests of the pkcs11 key provider against a SoftHSM token, created
+# in a temporary directory. SOFTHSM2_LIB is the path of the SoftHSM library.
+
+set -e
+
+SOFTHSM2_LIB=${SOFTHSM2_LIB:-/usr/lib/softhsm/libsofthsm2.so}
+TOKEN_DIR=`mktemp -d`
+trap "rm -rf $TOKEN_DIR" 0
+
+export SOFTHSM2_CONF=$TOKEN_DIR/softhsm2.conf
+echo "directories.tokendir = $TOKEN_DIR" > $SOFTHSM2_CONF
+
+echo -n "Creating SoftHSM token.."
+softhsm2-util --init-token --free --label fabric --pin 98765432 --so-pin 12345678 > /dev/null
+echo "DONE!"
+
+echo "Running tests..."
+PKCS11_TEST_LIBRARY=$SOFTHSM2_LIB PKCS11_TEST_PIN=98765432 PKCS11_TEST_LABEL=fabric \
+    go test -tags pkcs11 github.com/hyperledger/fabric/core/crypto/primitives/pkcs11
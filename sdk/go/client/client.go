@@ -0,0 +1,279 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client connects applications to the Devops and Openchain services
+// of a peer over gRPC, so that they need not dial the peer and call the
+// generated protos stubs themselves. Reads and queries failing because the
+// peer is unavailable are retried with an exponential backoff; deployments,
+// upgrades and invocations are not, as a transaction whose response was lost
+// may have been submitted nonetheless.
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+
+	"google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+const (
+	defaultDialTimeout = 3 * time.Second
+	defaultBackoff     = 100 * time.Millisecond
+	defaultMaxBackoff  = 5 * time.Second
+)
+
+// Config is the configuration of a client
+type Config struct {
+	Address            string        // host:port of the peer
+	TLS                bool          // whether the peer serves TLS
+	RootCertFile       string        // PEM root certificate of the peer, the system roots if empty
+	ServerHostOverride string        // name expected in the certificate of the peer, if not its host
+	DialTimeout        time.Duration // defaults to 3s
+	Retries            int           // retries of a read failing because the peer is unavailable
+	Backoff            time.Duration // delay before the first retry, doubled for every other, defaults to 100ms
+	MaxBackoff         time.Duration // bound of the delay between retries, defaults to 5s
+}
+
+// Client is a connection to the Devops and Openchain services of a peer,
+// safe for concurrent use
+type Client struct {
+	config    Config
+	conn      *grpc.ClientConn
+	devops    pb.DevopsClient
+	openchain pb.OpenchainClient
+}
+
+// New connects to the peer at config.Address, waiting for the connection to
+// be established for up to config.DialTimeout
+func New(config Config) (*Client, error) {
+	if config.Address == "" {
+		return nil, errors.New("No peer address configured")
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+
+	opts := []grpc.DialOption{grpc.WithTimeout(config.DialTimeout), grpc.WithBlock()}
+	if config.TLS {
+		creds, err := transportCredentials(config)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	conn, err := grpc.Dial(config.Address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to peer %s: %s", config.Address, err)
+	}
+	return NewWithConn(config, conn), nil
+}
+
+// NewWithConn returns a client calling the peer over conn, e.g. a connection
+// dialed with options New does not support. Closing the client closes conn.
+func NewWithConn(config Config, conn *grpc.ClientConn) *Client {
+	if config.Backoff <= 0 {
+		config.Backoff = defaultBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+	return &Client{
+		config:    config,
+		conn:      conn,
+		devops:    pb.NewDevopsClient(conn),
+		openchain: pb.NewOpenchainClient(conn),
+	}
+}
+
+func transportCredentials(config Config) (credentials.TransportAuthenticator, error) {
+	if config.RootCertFile == "" {
+		return credentials.NewTLS(nil), nil
+	}
+	creds, err := credentials.NewClientTLSFromFile(config.RootCertFile, config.ServerHostOverride)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading the root certificate %s: %s", config.RootCertFile, err)
+	}
+	return creds, nil
+}
+
+// Close closes the connection to the peer
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// retry calls fn until it succeeds, fails with an error other than the peer
+// being unavailable, the retries are exhausted or ctx is done
+func (c *Client) retry(ctx context.Context, fn func() error) error {
+	backoff := c.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || grpc.Code(err) != codes.Unavailable || attempt >= c.config.Retries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff *= 2; backoff > c.config.MaxBackoff {
+			backoff = c.config.MaxBackoff
+		}
+	}
+}
+
+// ResponseError is the error of a call the peer answered with a failure
+type ResponseError struct {
+	Msg string
+}
+
+func (e *ResponseError) Error() string {
+	return e.Msg
+}
+
+// result returns the message of a successful response, or a ResponseError
+func result(resp *pb.Response, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != pb.Response_SUCCESS {
+		return nil, &ResponseError{Msg: string(resp.Msg)}
+	}
+	return resp.Msg, nil
+}
+
+// Login logs the enrollment ID in on the peer, which then signs the
+// transactions of the invocations whose secure context is enrollID
+func (c *Client) Login(ctx context.Context, enrollID string, enrollSecret string) error {
+	_, err := result(c.devops.Login(ctx, &pb.Secret{EnrollId: enrollID, EnrollSecret: enrollSecret}))
+	return err
+}
+
+// Deploy deploys the chaincode, and returns its deployment spec, whose
+// chaincode ID names the chaincode in later invocations
+func (c *Client) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	return c.devops.Deploy(ctx, spec)
+}
+
+// Upgrade upgrades the deployed chaincode named by the chaincode ID of spec
+func (c *Client) Upgrade(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	return c.devops.Upgrade(ctx, spec)
+}
+
+// Invoke invokes the chaincode, and returns the UUID of the transaction
+func (c *Client) Invoke(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (string, error) {
+	uuid, err := result(c.devops.Invoke(ctx, spec))
+	return string(uuid), err
+}
+
+// InvokeBatch invokes the chaincodes with all the invocations, whose
+// transactions the peer sends to consensus together. It returns the response
+// of each invocation in order, the message of a successful one being the UUID
+// of its transaction, and fails only if the batch as a whole is rejected.
+func (c *Client) InvokeBatch(ctx context.Context, specs ...*pb.ChaincodeInvocationSpec) ([]*pb.Response, error) {
+	resp, err := c.devops.InvokeBatch(ctx, &pb.ChaincodeInvocationBatch{Invocations: specs})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Responses, nil
+}
+
+// Query queries the chaincode on the peer, and returns the result
+func (c *Client) Query(ctx context.Context, spec *pb.ChaincodeInvocationSpec) ([]byte, error) {
+	var payload []byte
+	err := c.retry(ctx, func() (err error) {
+		payload, err = result(c.devops.Query(ctx, spec))
+		return err
+	})
+	return payload, err
+}
+
+// QueryQuorum queries the chaincode on the peer and the validating peers it
+// is connected to, and returns the result once enough of them agree on it
+func (c *Client) QueryQuorum(ctx context.Context, spec *pb.ChaincodeInvocationSpec) ([]byte, error) {
+	var payload []byte
+	err := c.retry(ctx, func() (err error) {
+		payload, err = result(c.devops.QueryQuorum(ctx, spec))
+		return err
+	})
+	return payload, err
+}
+
+// BlockchainInfo returns the height and the current and previous block
+// hashes of the blockchain
+func (c *Client) BlockchainInfo(ctx context.Context) (*pb.BlockchainInfo, error) {
+	var info *pb.BlockchainInfo
+	err := c.retry(ctx, func() (err error) {
+		info, err = c.openchain.GetBlockchainInfo(ctx, &google_protobuf.Empty{})
+		return err
+	})
+	return info, err
+}
+
+// BlockCount returns the number of blocks of the blockchain
+func (c *Client) BlockCount(ctx context.Context) (uint64, error) {
+	var count *pb.BlockCount
+	err := c.retry(ctx, func() (err error) {
+		count, err = c.openchain.GetBlockCount(ctx, &google_protobuf.Empty{})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count.Count, nil
+}
+
+// Block returns the block of the given number, the genesis block being 0
+func (c *Client) Block(ctx context.Context, number uint64) (*pb.Block, error) {
+	var block *pb.Block
+	err := c.retry(ctx, func() (err error) {
+		block, err = c.openchain.GetBlockByNumber(ctx, &pb.BlockNumber{Number: number})
+		return err
+	})
+	return block, err
+}
+
+// BlockByTxID returns the block containing the transaction of the UUID
+func (c *Client) BlockByTxID(ctx context.Context, uuid string) (*pb.Block, error) {
+	var block *pb.Block
+	err := c.retry(ctx, func() (err error) {
+		block, err = c.openchain.GetBlockByTxID(ctx, &pb.TxID{Uuid: uuid})
+		return err
+	})
+	return block, err
+}
+
+// Peers returns the peers the peer is connected to
+func (c *Client) Peers(ctx context.Context) ([]*pb.PeerEndpoint, error) {
+	var peers *pb.PeersMessage
+	err := c.retry(ctx, func() (err error) {
+		peers, err = c.openchain.GetPeers(ctx, &google_protobuf.Empty{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return peers.Peers, nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// mockPeer serves the Devops and Openchain services, failing the first calls
+// as unavailable
+type mockPeer struct {
+	pb.DevopsServer
+	pb.OpenchainServer
+	unavailable int
+	calls       int
+}
+
+func (p *mockPeer) fail() error {
+	p.calls++
+	if p.calls <= p.unavailable {
+		return grpc.Errorf(codes.Unavailable, "peer unavailable")
+	}
+	return nil
+}
+
+func (p *mockPeer) Login(ctx context.Context, secret *pb.Secret) (*pb.Response, error) {
+	if secret.EnrollSecret != "secret" {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("wrong secret")}, nil
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS}, nil
+}
+
+func (p *mockPeer) Invoke(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	if err := p.fail(); err != nil {
+		return nil, err
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte("uuid")}, nil
+}
+
+func (p *mockPeer) InvokeBatch(ctx context.Context, batch *pb.ChaincodeInvocationBatch) (*pb.BatchResponse, error) {
+	resp := &pb.BatchResponse{}
+	for _, spec := range batch.Invocations {
+		resp.Responses = append(resp.Responses, &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(spec.ChaincodeSpec.ChaincodeID.Name)})
+	}
+	return resp, nil
+}
+
+func (p *mockPeer) Query(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	if err := p.fail(); err != nil {
+		return nil, err
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(spec.ChaincodeSpec.ChaincodeID.Name)}, nil
+}
+
+func (p *mockPeer) GetBlockCount(ctx context.Context, e *google_protobuf.Empty) (*pb.BlockCount, error) {
+	if err := p.fail(); err != nil {
+		return nil, err
+	}
+	return &pb.BlockCount{Count: 7}, nil
+}
+
+func startMockPeer(t *testing.T, p *mockPeer) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterDevopsServer(server, p)
+	pb.RegisterOpenchainServer(server, p)
+	go server.Serve(lis)
+	return lis.Addr().String(), server.Stop
+}
+
+func invocation(name string) *pb.ChaincodeInvocationSpec {
+	return &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: name}}}
+}
+
+func TestClientRetries(t *testing.T) {
+	p := &mockPeer{unavailable: 2}
+	address, stop := startMockPeer(t, p)
+	defer stop()
+
+	c, err := New(Config{Address: address, Retries: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Error connecting to the mock peer: %s", err)
+	}
+	defer c.Close()
+
+	count, err := c.BlockCount(context.Background())
+	if err != nil || count != 7 {
+		t.Fatalf("Expected 7 blocks after 2 retries, got %d, %v", count, err)
+	}
+	if p.calls != 3 {
+		t.Fatalf("Expected 3 calls, got %d", p.calls)
+	}
+
+	p.calls, p.unavailable = 0, 3
+	if _, err = c.Query(context.Background(), invocation("mycc")); grpc.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected the query to fail once the retries are exhausted, got %v", err)
+	}
+
+	p.calls, p.unavailable = 0, 1
+	if _, err = c.Invoke(context.Background(), invocation("mycc")); grpc.Code(err) != codes.Unavailable || p.calls != 1 {
+		t.Fatalf("Expected the invocation not to be retried, got %v after %d calls", err, p.calls)
+	}
+	uuid, err := c.Invoke(context.Background(), invocation("mycc"))
+	if err != nil || uuid != "uuid" {
+		t.Fatalf("Expected the invocation to return its UUID, got %s, %v", uuid, err)
+	}
+}
+
+func TestClientResponses(t *testing.T) {
+	p := &mockPeer{}
+	address, stop := startMockPeer(t, p)
+	defer stop()
+
+	c, err := New(Config{Address: address})
+	if err != nil {
+		t.Fatalf("Error connecting to the mock peer: %s", err)
+	}
+	defer c.Close()
+
+	if err = c.Login(context.Background(), "jim", "secret"); err != nil {
+		t.Fatalf("Expected the login to succeed, got %s", err)
+	}
+	err = c.Login(context.Background(), "jim", "wrong")
+	if rerr, ok := err.(*ResponseError); !ok || rerr.Msg != "wrong secret" {
+		t.Fatalf("Expected the failed login to return a ResponseError, got %v", err)
+	}
+	payload, err := c.Query(context.Background(), invocation("mycc"))
+	if err != nil || string(payload) != "mycc" {
+		t.Fatalf("Expected the query to return mycc, got %s, %v", payload, err)
+	}
+	responses, err := c.InvokeBatch(context.Background(), invocation("a"), invocation("b"))
+	if err != nil || len(responses) != 2 || string(responses[1].Msg) != "b" {
+		t.Fatalf("Expected the responses of the batch in order, got %v, %v", responses, err)
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Fatalf("Expected an error without an address")
+	}
+	if _, err := New(Config{Address: "127.0.0.1:1", TLS: true, RootCertFile: "/missing.pem"}); err == nil {
+		t.Fatalf("Expected an error with a missing root certificate")
+	}
+}
@@ -0,0 +1,357 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command generate generates the JavaScript client of the REST API of the
+// peer from its OpenAPI (Swagger 2.0) definition, core/rest/rest_api.json.
+// Every operation of the definition becomes a method of RestClient named by
+// its operationId, taking the parameters of the operation by name, except the
+// streaming operations which a request/response client cannot consume.
+//
+//	go run sdk/js/generate/main.go -spec core/rest/rest_api.json -out sdk/js/rest_client.js
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const mediaTypeEventStream = "text/event-stream"
+
+type spec struct {
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	} `json:"info"`
+	Host  string                          `json:"host"`
+	Paths map[string]map[string]operation `json:"paths"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Produces    []string    `json:"produces"`
+	Parameters  []parameter `json:"parameters"`
+}
+
+type parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// method is an operation as the template renders it
+type method struct {
+	Name     string
+	Method   string
+	Path     string
+	Summary  string
+	Params   []param
+	PathExpr string
+	Query    []param
+	Headers  []param
+	Body     *param
+}
+
+type param struct {
+	Name        string
+	Expr        string // JavaScript expression of the parameter
+	Type        string
+	Description string
+	Required    bool
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func paramExpr(name string) string {
+	if identifier.MatchString(name) {
+		return "params." + name
+	}
+	return "params['" + name + "']"
+}
+
+func jsType(t string) string {
+	switch t {
+	case "integer":
+		return "number"
+	case "":
+		return "Object"
+	}
+	return t
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// pathExpr returns the JavaScript expression building path, the path
+// parameters of the operation being URI encoded
+func pathExpr(path string) string {
+	var parts []string
+	last := 0
+	for _, loc := range pathParam.FindAllStringSubmatchIndex(path, -1) {
+		if loc[0] > last {
+			parts = append(parts, "'"+path[last:loc[0]]+"'")
+		}
+		parts = append(parts, "encodeURIComponent("+paramExpr(path[loc[2]:loc[3]])+")")
+		last = loc[1]
+	}
+	if last < len(path) {
+		parts = append(parts, "'"+path[last:]+"'")
+	}
+	return strings.Join(parts, " + ")
+}
+
+func streaming(op operation) bool {
+	return len(op.Produces) == 1 && op.Produces[0] == mediaTypeEventStream
+}
+
+func methods(s *spec) ([]method, error) {
+	var paths []string
+	for path := range s.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ms []method
+	for _, path := range paths {
+		var verbs []string
+		for verb := range s.Paths[path] {
+			verbs = append(verbs, verb)
+		}
+		sort.Strings(verbs)
+		for _, verb := range verbs {
+			op := s.Paths[path][verb]
+			if streaming(op) {
+				continue
+			}
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("Operation %s %s has no operationId", strings.ToUpper(verb), path)
+			}
+			m := method{
+				Name:     op.OperationID,
+				Method:   strings.ToUpper(verb),
+				Path:     path,
+				Summary:  op.Summary,
+				PathExpr: pathExpr(path),
+			}
+			for _, p := range op.Parameters {
+				mp := param{
+					Name:        p.Name,
+					Expr:        paramExpr(p.Name),
+					Type:        jsType(p.Type),
+					Description: strings.Join(strings.Fields(p.Description), " "),
+					Required:    p.Required || p.In == "path",
+				}
+				m.Params = append(m.Params, mp)
+				switch p.In {
+				case "path":
+				case "query":
+					m.Query = append(m.Query, mp)
+				case "header":
+					m.Headers = append(m.Headers, mp)
+				case "body":
+					body := mp
+					m.Body = &body
+				default:
+					return nil, fmt.Errorf("Parameter %s of %s has unsupported location %s", p.Name, op.OperationID, p.In)
+				}
+			}
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+var client = template.Must(template.New("client").Parse(`/*
+ * Code generated by sdk/js/generate from core/rest/rest_api.json. DO NOT EDIT.
+ *
+ * {{.Info.Title}} {{.Info.Version}}: {{.Info.Description}}
+ */
+
+'use strict';
+
+var http = require('http');
+var https = require('https');
+var url = require('url');
+
+/**
+ * RestClient calls the REST API of the peer at baseURL, by default
+ * http://{{.Host}}.
+ *
+ * @param {string} [baseURL]
+ * @param {Object} [options]
+ * @param {string} [options.token] sent as a bearer token
+ * @param {string} [options.username] sent with options.password as basic credentials
+ * @param {string} [options.password]
+ * @param {Object} [options.headers] default headers, e.g. X-Enrollment-ID
+ * @constructor
+ */
+function RestClient(baseURL, options) {
+    if (!(this instanceof RestClient)) {
+        return new RestClient(baseURL, options);
+    }
+    this.baseURL = url.parse(baseURL || 'http://{{.Host}}');
+    this.options = options || {};
+}
+
+/**
+ * request sends a request to the peer and calls callback with the error or
+ * the decoded JSON response. The error of a failed request has the HTTP
+ * status, and the code and field of the error response of the peer.
+ */
+RestClient.prototype.request = function (method, path, query, headers, body, callback) {
+    var search = [];
+    Object.keys(query).forEach(function (name) {
+        if (query[name] !== undefined) {
+            search.push(encodeURIComponent(name) + '=' + encodeURIComponent(query[name]));
+        }
+    });
+    var opts = {
+        protocol: this.baseURL.protocol,
+        hostname: this.baseURL.hostname,
+        port: this.baseURL.port,
+        method: method,
+        path: (this.baseURL.pathname || '/').replace(/\/$/, '') + path + (search.length ? '?' + search.join('&') : ''),
+        headers: {'Accept': 'application/json'}
+    };
+    if (this.options.token) {
+        opts.headers['Authorization'] = 'Bearer ' + this.options.token;
+    } else if (this.options.username) {
+        opts.auth = this.options.username + ':' + (this.options.password || '');
+    }
+    var defaults = this.options.headers || {};
+    Object.keys(defaults).forEach(function (name) {
+        opts.headers[name] = defaults[name];
+    });
+    Object.keys(headers).forEach(function (name) {
+        if (headers[name] !== undefined) {
+            opts.headers[name] = headers[name];
+        }
+    });
+    var payload;
+    if (body !== undefined) {
+        payload = JSON.stringify(body);
+        opts.headers['Content-Type'] = 'application/json';
+        opts.headers['Content-Length'] = Buffer.byteLength(payload);
+    }
+
+    var req = (opts.protocol === 'https:' ? https : http).request(opts, function (res) {
+        var chunks = [];
+        res.on('data', function (chunk) {
+            chunks.push(chunk);
+        });
+        res.on('end', function () {
+            var text = Buffer.concat(chunks).toString();
+            var result = text;
+            try {
+                result = text ? JSON.parse(text) : undefined;
+            } catch (e) {
+            }
+            if (res.statusCode >= 400) {
+                var err = new Error(result && result.message || res.statusMessage);
+                err.status = res.statusCode;
+                if (result && result.code) {
+                    err.code = result.code;
+                    err.field = result.field;
+                }
+                return callback(err);
+            }
+            callback(null, result);
+        });
+    });
+    req.on('error', callback);
+    if (payload !== undefined) {
+        req.write(payload);
+    }
+    req.end();
+};
+{{range .Methods}}
+/**
+ * {{.Summary}}
+ *
+ * {{.Method}} {{.Path}}
+ *
+ * @param {Object} {{if not .Params}}[params]{{else}}params{{end}}
+{{- range .Params}}
+ * @param { {{- .Type -}} } {{if .Required}}params.{{.Name}}{{else}}[params.{{.Name}}]{{end}} {{.Description}}
+{{- end}}
+ * @param {function(Error, Object)} callback
+ */
+RestClient.prototype.{{.Name}} = function (params, callback) {
+    if (typeof params === 'function') {
+        callback = params;
+        params = {};
+    }
+{{- range .Params}}{{if .Required}}
+    if ({{.Expr}} === undefined) {
+        return callback(new Error('Missing required parameter {{.Name}}'));
+    }
+{{- end}}{{end}}
+    this.request('{{.Method}}', {{.PathExpr}}, { {{- range $i, $p := .Query}}{{if $i}}, {{end}}'{{$p.Name}}': {{$p.Expr}}{{end -}} }, { {{- range $i, $p := .Headers}}{{if $i}}, {{end}}'{{$p.Name}}': {{$p.Expr}}{{end -}} }, {{if .Body}}{{.Body.Expr}}{{else}}undefined{{end}}, callback);
+};
+{{end}}
+module.exports = RestClient;
+`))
+
+// generate returns the JavaScript client of the API defined by raw
+func generate(raw []byte) ([]byte, error) {
+	s := &spec{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, fmt.Errorf("Error parsing the API definition: %s", err)
+	}
+	ms, err := methods(s)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := client.Execute(&out, struct {
+		*spec
+		Methods []method
+	}{s, ms}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func main() {
+	specFile := flag.String("spec", "core/rest/rest_api.json", "OpenAPI definition of the REST API")
+	outFile := flag.String("out", "sdk/js/rest_client.js", "JavaScript client to generate")
+	flag.Parse()
+
+	raw, err := ioutil.ReadFile(*specFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %s\n", *specFile, err)
+		os.Exit(1)
+	}
+	out, err := generate(raw)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*outFile, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", *outFile, err)
+		os.Exit(1)
+	}
+}
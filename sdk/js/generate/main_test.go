@@ -0,0 +1,74 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedClient checks the committed client was generated from the
+// current API definition
+func TestGeneratedClient(t *testing.T) {
+	raw, err := ioutil.ReadFile("../../../core/rest/rest_api.json")
+	if err != nil {
+		t.Fatalf("Error reading the API definition: %s", err)
+	}
+	out, err := generate(raw)
+	if err != nil {
+		t.Fatalf("Error generating the client: %s", err)
+	}
+	committed, err := ioutil.ReadFile("../rest_client.js")
+	if err != nil {
+		t.Fatalf("Error reading the client: %s", err)
+	}
+	if !bytes.Equal(out, committed) {
+		t.Fatalf("sdk/js/rest_client.js is out of date with core/rest/rest_api.json, run make js-sdk")
+	}
+}
+
+func TestMethods(t *testing.T) {
+	raw := []byte(`{"paths": {
+		"/chain/stream": {"get": {"operationId": "streamBlocks", "produces": ["text/event-stream"]}},
+		"/transactions/{UUID}/status": {"get": {"operationId": "getTransactionStatus", "parameters": [
+			{"name": "UUID", "in": "path", "type": "string"},
+			{"name": "X-Enrollment-ID", "in": "header", "type": "string"}]}}}}`)
+	out, err := generate(raw)
+	if err != nil {
+		t.Fatalf("Error generating the client: %s", err)
+	}
+	js := string(out)
+	if strings.Contains(js, "streamBlocks") {
+		t.Errorf("Expected the streaming operation to be skipped")
+	}
+	for _, expected := range []string{
+		"RestClient.prototype.getTransactionStatus = function (params, callback)",
+		"if (params.UUID === undefined)",
+		"'/transactions/' + encodeURIComponent(params.UUID) + '/status'",
+		"{'X-Enrollment-ID': params['X-Enrollment-ID']}",
+	} {
+		if !strings.Contains(js, expected) {
+			t.Errorf("Expected the client to contain %s", expected)
+		}
+	}
+
+	if _, err := generate([]byte(`{"paths": {"/chain": {"get": {}}}}`)); err == nil {
+		t.Errorf("Expected an error for an operation without operationId")
+	}
+}
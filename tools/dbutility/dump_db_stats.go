@@ -1,3 +1,5 @@
+// +build cgo
+
 /*
 Copyright IBM Corp. 2016 All Rights Reserved.
 
@@ -23,9 +25,9 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/util"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 const (
@@ -78,7 +80,12 @@ func main() {
 
 func printLiveFilesMetaData(openchainDB *db.OpenchainDB) {
 	fmt.Println("------ Details of LiveFilesMetaData ---")
-	db := openchainDB.DB
+	rocksDB, ok := openchainDB.Driver().(*db.RocksDBDriver)
+	if !ok {
+		fmt.Println("Not available, the DB does not use the rocksdb driver")
+		return
+	}
+	db := rocksDB.DB
 	liveFileMetadata := db.GetLiveFilesMetaData()
 	for _, file := range liveFileMetadata {
 		fmt.Printf("file.Name=[%s], file.Level=[%d], file.Size=[%d]\n",
@@ -88,53 +95,54 @@ func printLiveFilesMetaData(openchainDB *db.OpenchainDB) {
 
 func printProperties(openchainDB *db.OpenchainDB) {
 	fmt.Println("------ Details of Properties ---")
-	db := openchainDB.DB
+	db, ok := openchainDB.Driver().(db.PropertyReader)
+	if !ok {
+		fmt.Println("Not available, the DB driver does not report properties")
+		return
+	}
 	fmt.Printf("rocksdb.estimate-live-data-size:- BlockchainCF:%s, StateCF:%s, StateDeltaCF:%s, IndexesCF:%s, PersistCF:%s\n\n",
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.BlockchainCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.StateCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.StateDeltaCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.IndexesCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.PersistCF))
-	fmt.Printf("Default:%s\n", db.GetProperty("rocksdb.estimate-live-data-size"))
+		db.GetProperty(openchainDB.BlockchainCF, "rocksdb.estimate-live-data-size"),
+		db.GetProperty(openchainDB.StateCF, "rocksdb.estimate-live-data-size"),
+		db.GetProperty(openchainDB.StateDeltaCF, "rocksdb.estimate-live-data-size"),
+		db.GetProperty(openchainDB.IndexesCF, "rocksdb.estimate-live-data-size"),
+		db.GetProperty(openchainDB.PersistCF, "rocksdb.estimate-live-data-size"))
+	fmt.Printf("Default:%s\n", db.GetProperty(nil, "rocksdb.estimate-live-data-size"))
 
 	fmt.Printf("rocksdb.num-live-versions:- BlockchainCF:%s, StateCF:%s, StateDeltaCF:%s, IndexesCF:%s, PersistCF:%s\n\n",
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.BlockchainCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.StateCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.StateDeltaCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.IndexesCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.PersistCF))
+		db.GetProperty(openchainDB.BlockchainCF, "rocksdb.num-live-versions"),
+		db.GetProperty(openchainDB.StateCF, "rocksdb.num-live-versions"),
+		db.GetProperty(openchainDB.StateDeltaCF, "rocksdb.num-live-versions"),
+		db.GetProperty(openchainDB.IndexesCF, "rocksdb.num-live-versions"),
+		db.GetProperty(openchainDB.PersistCF, "rocksdb.num-live-versions"))
 
 	fmt.Printf("rocksdb.cfstats:\n %s %s %s %s %s\n\n",
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.BlockchainCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.StateCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.StateDeltaCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.IndexesCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.PersistCF))
+		db.GetProperty(openchainDB.BlockchainCF, "rocksdb.cfstats"),
+		db.GetProperty(openchainDB.StateCF, "rocksdb.cfstats"),
+		db.GetProperty(openchainDB.StateDeltaCF, "rocksdb.cfstats"),
+		db.GetProperty(openchainDB.IndexesCF, "rocksdb.cfstats"),
+		db.GetProperty(openchainDB.PersistCF, "rocksdb.cfstats"))
 }
 
-func scan(openchainDB *db.OpenchainDB, cfName string, cf *gorocksdb.ColumnFamilyHandle, printer detailPrinter) (int, int) {
+func scan(openchainDB *db.OpenchainDB, cfName string, cf db.ColumnFamily, printer detailPrinter) (int, int) {
 	fmt.Printf("------- Printing Key-values larger than [%d] bytes in Column family [%s]--------\n", MaxValueSize, cfName)
 	itr := openchainDB.GetIterator(cf)
 	totalKVs := 0
 	overSizeKVs := 0
 	itr.SeekToFirst()
 	for ; itr.Valid(); itr.Next() {
-		k := itr.Key()
-		v := itr.Value()
-		keyBytes := k.Data()
-		valueSize := v.Size()
+		keyBytes := itr.Key()
+		value := itr.Value()
+		valueSize := len(value)
 		totalKVs++
 		if valueSize >= MaxValueSize {
 			overSizeKVs++
 			fmt.Printf("key=[%x], valueSize=[%d]\n", keyBytes, valueSize)
 			if printer != nil {
 				fmt.Println("=== KV Details === ")
-				printer(v.Data())
+				printer(value)
 				fmt.Println("")
 			}
 		}
-		k.Free()
-		v.Free()
 	}
 	itr.Close()
 	fmt.Printf("totalKVs=[%d], overSizeKVs=[%d]\n", totalKVs, overSizeKVs)
@@ -142,7 +150,7 @@ func scan(openchainDB *db.OpenchainDB, cfName string, cf *gorocksdb.ColumnFamily
 }
 
 func blockDetailPrinter(blockBytes []byte) {
-	block, _ := protos.UnmarshallBlock(blockBytes)
+	block, _ := protos.UnmarshallBlock(util.Decompress(blockBytes))
 	txs := block.GetTransactions()
 	fmt.Printf("Number of transactions = [%d]\n", len(txs))
 	for _, tx := range txs {